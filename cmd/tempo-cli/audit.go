@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/tempo/pkg/audit"
+)
+
+const auditTimeout = 5 * time.Second
+
+// recordAudit posts an entry for the action to the audit endpoint, if configured. Failures are reported
+// but never fail the command.
+func (g *globalOptions) recordAudit(action, target string) {
+	if g.AuditEndpoint == "" {
+		return
+	}
+
+	// the server records who made the change and when
+	b, err := json.Marshal(&audit.Entry{
+		Action: action,
+		Target: target,
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "failed to marshal audit entry:", err)
+		return
+	}
+
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(g.AuditEndpoint, "/")+"/audit", bytes.NewReader(b))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "failed to create audit request:", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if g.AuditOrgID != "" {
+		req.Header.Set(user.OrgIDHeaderName, g.AuditOrgID)
+	}
+
+	client := &http.Client{Timeout: auditTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "failed to record audit entry:", err)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		fmt.Fprintln(os.Stderr, "failed to record audit entry:", resp.Status)
+	}
+}
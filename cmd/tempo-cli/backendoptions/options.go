@@ -0,0 +1,153 @@
+// Package backendoptions holds the backend flags shared by the tempo-cli commands that access the storage backend
+// directly.
+package backendoptions
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/grafana/dskit/flagext"
+	"gopkg.in/yaml.v2"
+
+	"github.com/grafana/tempo/cmd/tempo/app"
+	"github.com/grafana/tempo/tempodb"
+	"github.com/grafana/tempo/tempodb/backend"
+	"github.com/grafana/tempo/tempodb/backend/azure"
+	"github.com/grafana/tempo/tempodb/backend/gcs"
+	"github.com/grafana/tempo/tempodb/backend/local"
+	"github.com/grafana/tempo/tempodb/backend/s3"
+)
+
+const (
+	// Azure credentials are read from these environment variables if neither the config file nor the flags set them.
+	// S3 and GCS credentials don't need this: the s3 backend reads the AWS and Minio variables and the GCS client uses
+	// the application default credentials.
+	envAzureStorageAccount = "AZURE_STORAGE_ACCOUNT"
+	envAzureStorageKey     = "AZURE_STORAGE_KEY"
+)
+
+// Options are the backend flags of a command. They are applied on top of the storage section of the tempo config
+// file, which is loaded the same way the server loads it.
+type Options struct {
+	Backend string `help:"backend to connect to (s3/gcs/local/azure), optional, overrides backend in config file" enum:",s3,gcs,local,azure"`
+	Bucket  string `help:"bucket (or path on local backend) to scan, optional, overrides bucket in config file"`
+	Prefix  string `help:"path within the bucket the tenants are stored under, optional"`
+
+	S3Endpoint string `name:"s3-endpoint" help:"s3 endpoint (s3.dualstack.us-east-2.amazonaws.com), optional, overrides endpoint in config file"`
+	S3User     string `name:"s3-user" help:"s3 username, optional, overrides username in config file"`
+	S3Pass     string `name:"s3-pass" help:"s3 password, optional, overrides password in config file"`
+}
+
+// Validate checks the combination of flags. The flags themselves are checked when parsing.
+func (o *Options) Validate() error {
+	s3Flags := o.S3Endpoint != "" || o.S3User != "" || o.S3Pass != ""
+	if s3Flags && o.Backend != "" && o.Backend != "s3" {
+		return fmt.Errorf("s3 options can't be used with the %s backend", o.Backend)
+	}
+	if (o.S3User == "") != (o.S3Pass == "") {
+		return fmt.Errorf("s3-user and s3-pass must be set together")
+	}
+
+	for _, p := range strings.Split(strings.Trim(o.Prefix, "/"), "/") {
+		if p == "." || p == ".." {
+			return fmt.Errorf("invalid prefix %s", o.Prefix)
+		}
+	}
+
+	return nil
+}
+
+// Config returns the trace storage config of the config file with the options applied. An empty configFile starts
+// from the defaults of the server.
+func (o *Options) Config(configFile string) (*tempodb.Config, error) {
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Defaults
+	cfg := app.Config{}
+	cfg.RegisterFlagsAndApplyDefaults("", &flag.FlagSet{})
+
+	// Existing config
+	if configFile != "" {
+		buff, err := ioutil.ReadFile(configFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read configFile %s: %w", configFile, err)
+		}
+
+		err = yaml.UnmarshalStrict(buff, &cfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse configFile %s: %w", configFile, err)
+		}
+	}
+
+	trace := &cfg.StorageConfig.Trace
+
+	// cli overrides
+	if o.Backend != "" {
+		trace.Backend = o.Backend
+	}
+
+	if o.Bucket != "" {
+		trace.Local.Path = o.Bucket
+		trace.GCS.BucketName = o.Bucket
+		trace.S3.Bucket = o.Bucket
+		trace.Azure.ContainerName = o.Bucket
+	}
+
+	if o.S3Endpoint != "" {
+		trace.S3.Endpoint = o.S3Endpoint
+	}
+	if o.S3User != "" {
+		trace.S3.AccessKey = flagext.Secret{Value: o.S3User}
+		trace.S3.SecretKey = flagext.Secret{Value: o.S3Pass}
+	}
+
+	// environment
+	if trace.Azure.StorageAccountName.Value == "" {
+		trace.Azure.StorageAccountName.Value = os.Getenv(envAzureStorageAccount)
+	}
+	if trace.Azure.StorageAccountKey.Value == "" {
+		trace.Azure.StorageAccountKey.Value = os.Getenv(envAzureStorageKey)
+	}
+
+	return trace, nil
+}
+
+// Load connects to the backend of the config file with the options applied.
+func (o *Options) Load(configFile string) (backend.Reader, backend.Writer, backend.Compactor, error) {
+	cfg, err := o.Config(configFile)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	var r backend.RawReader
+	var w backend.RawWriter
+	var c backend.Compactor
+
+	switch cfg.Backend {
+	case "local":
+		r, w, c, err = local.New(cfg.Local)
+	case "gcs":
+		r, w, c, err = gcs.New(cfg.GCS)
+	case "s3":
+		r, w, c, err = s3.New(cfg.S3)
+	case "azure":
+		r, w, c, err = azure.New(cfg.Azure)
+	default:
+		err = fmt.Errorf("unknown backend %s", cfg.Backend)
+	}
+
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if prefix := strings.Trim(o.Prefix, "/"); prefix != "" {
+		r, w, c = newPrefixed(prefix, r, w, c)
+	}
+
+	return backend.NewReader(r), backend.NewWriter(w), c, nil
+}
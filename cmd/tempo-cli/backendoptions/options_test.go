@@ -0,0 +1,136 @@
+package backendoptions
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tempo/tempodb/backend"
+)
+
+func TestConfig(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "tempo.yaml")
+	require.NoError(t, ioutil.WriteFile(configFile, []byte(`
+storage:
+  trace:
+    backend: s3
+    s3:
+      bucket: from-config
+      endpoint: s3.example.com
+      access_key: config-user
+      secret_key: config-pass
+`), 0644))
+
+	// the config file
+	o := &Options{}
+	cfg, err := o.Config(configFile)
+	require.NoError(t, err)
+	assert.Equal(t, "s3", cfg.Backend)
+	assert.Equal(t, "from-config", cfg.S3.Bucket)
+	assert.Equal(t, "s3.example.com", cfg.S3.Endpoint)
+	assert.Equal(t, "config-user", cfg.S3.AccessKey.Value)
+
+	// overridden by the options
+	o = &Options{Bucket: "from-flags", S3Endpoint: "localhost:9000", S3User: "user", S3Pass: "pass"}
+	cfg, err = o.Config(configFile)
+	require.NoError(t, err)
+	assert.Equal(t, "from-flags", cfg.S3.Bucket)
+	assert.Equal(t, "localhost:9000", cfg.S3.Endpoint)
+	assert.Equal(t, "user", cfg.S3.AccessKey.Value)
+	assert.Equal(t, "pass", cfg.S3.SecretKey.Value)
+
+	// azure credentials from the environment
+	t.Setenv(envAzureStorageAccount, "account")
+	t.Setenv(envAzureStorageKey, "key")
+	o = &Options{Backend: "azure", Bucket: "container"}
+	cfg, err = o.Config("")
+	require.NoError(t, err)
+	assert.Equal(t, "azure", cfg.Backend)
+	assert.Equal(t, "container", cfg.Azure.ContainerName)
+	assert.Equal(t, "account", cfg.Azure.StorageAccountName.Value)
+	assert.Equal(t, "key", cfg.Azure.StorageAccountKey.Value)
+
+	_, err = o.Config(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.Error(t, err)
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		options Options
+		err     bool
+	}{
+		{name: "empty", options: Options{}},
+		{name: "s3", options: Options{Backend: "s3", S3Endpoint: "localhost", S3User: "user", S3Pass: "pass"}},
+		{name: "s3 from config", options: Options{S3Endpoint: "localhost"}},
+		{name: "s3 options on gcs", options: Options{Backend: "gcs", S3Endpoint: "localhost"}, err: true},
+		{name: "s3 user without pass", options: Options{Backend: "s3", S3User: "user"}, err: true},
+		{name: "prefix", options: Options{Prefix: "/a/b/"}},
+		{name: "relative prefix", options: Options{Prefix: "a/../b"}, err: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.options.Validate()
+			if tc.err {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestLoadPrefix(t *testing.T) {
+	bucket := t.TempDir()
+	blockID := uuid.New()
+	ctx := context.Background()
+
+	o := &Options{Backend: "local", Bucket: bucket, Prefix: "/a/b/"}
+	r, w, c, err := o.Load("")
+	require.NoError(t, err)
+
+	meta := backend.NewBlockMeta("tenant", blockID, "v2", backend.EncNone, "")
+	require.NoError(t, w.WriteBlockMeta(ctx, meta))
+	_, err = os.Stat(filepath.Join(bucket, "a", "b", "tenant", blockID.String(), backend.MetaName))
+	require.NoError(t, err)
+
+	tenants, err := r.Tenants(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"tenant"}, tenants)
+
+	blocks, err := r.Blocks(ctx, "tenant")
+	require.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{blockID}, blocks)
+
+	require.NoError(t, c.MarkBlockCompacted(blockID, "tenant"))
+	compacted, err := c.CompactedBlockMeta(blockID, "tenant")
+	require.NoError(t, err)
+	assert.Equal(t, blockID, compacted.BlockID)
+
+	require.NoError(t, w.Write(ctx, "data", blockID, "tenant", []byte("data"), false))
+	buffer := make([]byte, 2)
+	require.NoError(t, r.ReadRange(ctx, "data", blockID, "tenant", 1, buffer))
+	assert.Equal(t, []byte("at"), buffer)
+	data, err := r.Read(ctx, "data", blockID, "tenant", false)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("data"), data)
+
+	require.NoError(t, c.ClearBlock(blockID, "tenant"))
+	_, err = os.Stat(filepath.Join(bucket, "a", "b", "tenant", blockID.String()))
+	assert.True(t, os.IsNotExist(err))
+
+	// without the prefix the tenant is not found
+	o.Prefix = ""
+	r, _, _, err = o.Load("")
+	require.NoError(t, err)
+	tenants, err = r.Tenants(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a"}, tenants)
+}
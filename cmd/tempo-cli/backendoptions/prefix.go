@@ -0,0 +1,81 @@
+package backendoptions
+
+import (
+	"context"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/grafana/tempo/tempodb/backend"
+)
+
+// newPrefixed wraps the backend so all tenants are read and written below the prefix.
+func newPrefixed(prefix string, r backend.RawReader, w backend.RawWriter, c backend.Compactor) (backend.RawReader, backend.RawWriter, backend.Compactor) {
+	keypath := backend.KeyPath(strings.Split(prefix, "/"))
+
+	return &prefixedReader{RawReader: r, prefix: keypath},
+		&prefixedWriter{RawWriter: w, prefix: keypath},
+		&prefixedCompactor{Compactor: c, prefix: prefix}
+}
+
+type prefixedReader struct {
+	backend.RawReader
+	prefix backend.KeyPath
+}
+
+func (r *prefixedReader) List(ctx context.Context, keypath backend.KeyPath) ([]string, error) {
+	return r.RawReader.List(ctx, withPrefix(r.prefix, keypath))
+}
+
+func (r *prefixedReader) Read(ctx context.Context, name string, keypath backend.KeyPath, shouldCache bool) (io.ReadCloser, int64, error) {
+	return r.RawReader.Read(ctx, name, withPrefix(r.prefix, keypath), shouldCache)
+}
+
+func (r *prefixedReader) ReadRange(ctx context.Context, name string, keypath backend.KeyPath, offset uint64, buffer []byte) error {
+	return r.RawReader.ReadRange(ctx, name, withPrefix(r.prefix, keypath), offset, buffer)
+}
+
+type prefixedWriter struct {
+	backend.RawWriter
+	prefix backend.KeyPath
+}
+
+func (w *prefixedWriter) Write(ctx context.Context, name string, keypath backend.KeyPath, data io.Reader, size int64, shouldCache bool) error {
+	return w.RawWriter.Write(ctx, name, withPrefix(w.prefix, keypath), data, size, shouldCache)
+}
+
+func (w *prefixedWriter) Append(ctx context.Context, name string, keypath backend.KeyPath, tracker backend.AppendTracker, buffer []byte) (backend.AppendTracker, error) {
+	return w.RawWriter.Append(ctx, name, withPrefix(w.prefix, keypath), tracker, buffer)
+}
+
+// prefixedCompactor prepends the prefix to the tenant, the backends join it into the path of the block.
+type prefixedCompactor struct {
+	backend.Compactor
+	prefix string
+}
+
+func (c *prefixedCompactor) MarkBlockCompacted(blockID uuid.UUID, tenantID string) error {
+	return c.Compactor.MarkBlockCompacted(blockID, c.tenant(tenantID))
+}
+
+func (c *prefixedCompactor) ClearBlock(blockID uuid.UUID, tenantID string) error {
+	return c.Compactor.ClearBlock(blockID, c.tenant(tenantID))
+}
+
+func (c *prefixedCompactor) CompactedBlockMeta(blockID uuid.UUID, tenantID string) (*backend.CompactedBlockMeta, error) {
+	return c.Compactor.CompactedBlockMeta(blockID, c.tenant(tenantID))
+}
+
+// tenant keeps an empty tenant empty so the checks of the backends against it still apply.
+func (c *prefixedCompactor) tenant(tenantID string) string {
+	if tenantID == "" {
+		return ""
+	}
+	return path.Join(c.prefix, tenantID)
+}
+
+func withPrefix(prefix backend.KeyPath, keypath backend.KeyPath) backend.KeyPath {
+	return append(append(make(backend.KeyPath, 0, len(prefix)+len(keypath)), prefix...), keypath...)
+}
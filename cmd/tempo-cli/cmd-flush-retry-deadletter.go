@@ -0,0 +1,26 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/grafana/tempo/tempodb/wal"
+)
+
+type flushRetryDeadLetterCmd struct {
+	WALPath  string `arg:"" help:"path to the ingester wal"`
+	TenantID string `help:"only requeue blocks of this tenant, optional"`
+}
+
+func (cmd *flushRetryDeadLetterCmd) Run(ctx *globalOptions) error {
+	moved, err := wal.RequeueDeadLetterBlocks(cmd.WALPath, cmd.TenantID)
+	for _, b := range moved {
+		fmt.Println("requeued", b)
+		ctx.recordAudit("cli.flush.retry-deadletter", b)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("requeued %d blocks. they will be flushed when the ingester is restarted\n", len(moved))
+	return nil
+}
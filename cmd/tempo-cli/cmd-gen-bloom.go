@@ -5,12 +5,12 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"os"
 	"strconv"
 
 	"github.com/google/uuid"
 	willf_bloom "github.com/willf/bloom"
 
+	"github.com/grafana/tempo/cmd/tempo-cli/backendoptions"
 	"github.com/grafana/tempo/tempodb/backend"
 	"github.com/grafana/tempo/tempodb/encoding"
 	"github.com/grafana/tempo/tempodb/encoding/common"
@@ -21,24 +21,23 @@ type bloomCmd struct {
 	BlockID        string  `arg:"" help:"block ID to list"`
 	BloomFP        float64 `arg:"" help:"bloom filter false positive rate (use prod settings!)"`
 	BloomShardSize int     `arg:"" help:"bloom filter shard size (use prod settings!)"`
-	backendOptions
+	backendoptions.Options
+	notifyOptions
 }
 
 type forEachRecord func(id common.ID) error
 
-func ReplayBlockAndDoForEachRecord(meta *backend.BlockMeta, filepath string, forEach forEachRecord) error {
+func ReplayBlockAndDoForEachRecord(meta *backend.BlockMeta, r backend.Reader, forEach forEachRecord) error {
 	v, err := encoding.FromVersion(meta.Version)
 	if err != nil {
 		return err
 	}
 
 	// replay file to extract records
-	f, err := os.OpenFile(filepath, os.O_RDONLY, 0644)
-	if err != nil {
-		return err
-	}
+	f := newObjectReader(meta, dataFilename, r)
+	defer f.Close()
 
-	dataReader, err := v.NewDataReader(backend.NewContextReaderWithAllReader(f), meta.Encoding)
+	dataReader, err := v.NewDataReader(f, meta.Encoding)
 	if err != nil {
 		return fmt.Errorf("error creating data reader: %w", err)
 	}
@@ -83,7 +82,7 @@ func (cmd *bloomCmd) Run(ctx *globalOptions) error {
 		return err
 	}
 
-	r, w, _, err := loadBackend(&cmd.backendOptions, ctx)
+	r, w, _, err := cmd.Options.Load(ctx.ConfigFile)
 	if err != nil {
 		return err
 	}
@@ -106,7 +105,7 @@ func (cmd *bloomCmd) Run(ctx *globalOptions) error {
 		return nil
 	}
 
-	err = ReplayBlockAndDoForEachRecord(meta, cmd.backendOptions.Bucket+cmd.TenantID+"/"+cmd.BlockID+"/"+dataFilename, addToBloom)
+	err = ReplayBlockAndDoForEachRecord(meta, r, addToBloom)
 	if err != nil {
 		fmt.Println("error replaying block", err)
 		return err
@@ -128,6 +127,8 @@ func (cmd *bloomCmd) Run(ctx *globalOptions) error {
 	}
 
 	fmt.Println("bloom written to backend successfully")
+	ctx.recordAudit("cli.gen.bloom", cmd.TenantID+"/"+cmd.BlockID)
+	cmd.notify(cmd.TenantID)
 
 	// verify generated bloom
 	shardedBloomFilter := make([]*willf_bloom.BloomFilter, meta.BloomShardCount)
@@ -152,7 +153,7 @@ func (cmd *bloomCmd) Run(ctx *globalOptions) error {
 		}
 		return nil
 	}
-	err = ReplayBlockAndDoForEachRecord(meta, cmd.backendOptions.Bucket+cmd.TenantID+"/"+cmd.BlockID+"/"+dataFilename, testBloom)
+	err = ReplayBlockAndDoForEachRecord(meta, r, testBloom)
 	if err != nil {
 		fmt.Println("error replaying block", err)
 		return err
@@ -5,10 +5,10 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"os"
 
 	"github.com/google/uuid"
 
+	"github.com/grafana/tempo/cmd/tempo-cli/backendoptions"
 	"github.com/grafana/tempo/tempodb/backend"
 	"github.com/grafana/tempo/tempodb/encoding"
 	"github.com/grafana/tempo/tempodb/encoding/common"
@@ -17,10 +17,11 @@ import (
 type indexCmd struct {
 	TenantID string `arg:"" help:"tenant-id within the bucket"`
 	BlockID  string `arg:"" help:"block ID to list"`
-	backendOptions
+	backendoptions.Options
+	notifyOptions
 }
 
-func ReplayBlockAndGetRecords(meta *backend.BlockMeta, filepath string) ([]common.Record, error, error) {
+func ReplayBlockAndGetRecords(meta *backend.BlockMeta, r backend.Reader) ([]common.Record, error, error) {
 	v, err := encoding.FromVersion(meta.Version)
 	if err != nil {
 		return nil, nil, err
@@ -28,12 +29,10 @@ func ReplayBlockAndGetRecords(meta *backend.BlockMeta, filepath string) ([]commo
 
 	var replayError error
 	// replay file to extract records
-	f, err := os.OpenFile(filepath, os.O_RDONLY, 0644)
-	if err != nil {
-		return nil, nil, err
-	}
+	f := newObjectReader(meta, dataFilename, r)
+	defer f.Close()
 
-	dataReader, err := v.NewDataReader(backend.NewContextReaderWithAllReader(f), meta.Encoding)
+	dataReader, err := v.NewDataReader(f, meta.Encoding)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -110,7 +109,7 @@ func (cmd *indexCmd) Run(ctx *globalOptions) error {
 		return err
 	}
 
-	r, w, _, err := loadBackend(&cmd.backendOptions, ctx)
+	r, w, _, err := cmd.Options.Load(ctx.ConfigFile)
 	if err != nil {
 		return err
 	}
@@ -119,9 +118,12 @@ func (cmd *indexCmd) Run(ctx *globalOptions) error {
 	if err != nil {
 		return err
 	}
+	if meta.NoIndex {
+		return fmt.Errorf("block %s is written without an index", blockID)
+	}
 
 	// replay file to extract records
-	records, replayError, err := ReplayBlockAndGetRecords(meta, cmd.backendOptions.Bucket+cmd.TenantID+"/"+cmd.BlockID+"/"+dataFilename)
+	records, replayError, err := ReplayBlockAndGetRecords(meta, r)
 	if replayError != nil {
 		fmt.Println("error replaying block. data file likely corrupt", replayError)
 		return replayError
@@ -146,39 +148,27 @@ func (cmd *indexCmd) Run(ctx *globalOptions) error {
 	}
 
 	// write to the local backend
-	err = w.Write(context.TODO(), "index", blockID, cmd.TenantID, indexBytes, false)
+	err = w.Write(context.TODO(), indexFilename, blockID, cmd.TenantID, indexBytes, false)
 	if err != nil {
 		fmt.Println("error writing index to backend", err)
 		return err
 	}
 
 	fmt.Println("index written to backend successfully")
+	ctx.recordAudit("cli.gen.index", cmd.TenantID+"/"+cmd.BlockID)
+	cmd.notify(cmd.TenantID)
 
 	// verify generated index
 
 	// get index file with records
-	indexFilePath := cmd.backendOptions.Bucket + cmd.TenantID + "/" + cmd.BlockID + "/" + indexFilename
-	indexFile, err := os.OpenFile(indexFilePath, os.O_RDONLY, 0644)
-	if err != nil {
-		fmt.Println("error opening index file")
-		return err
-	}
-
-	indexReader, err := v.NewIndexReader(backend.NewContextReaderWithAllReader(indexFile), int(meta.IndexPageSize), len(records))
+	indexReader, err := v.NewIndexReader(backend.NewContextReader(meta, indexFilename, r, false), int(meta.IndexPageSize), len(records))
 	if err != nil {
 		fmt.Println("error reading index file")
 		return err
 	}
 
 	// data reader
-	dataFilePath := cmd.backendOptions.Bucket + cmd.TenantID + "/" + cmd.BlockID + "/" + dataFilename
-	dataFile, err := os.OpenFile(dataFilePath, os.O_RDONLY, 0644)
-	if err != nil {
-		fmt.Println("error opening data file")
-		return err
-	}
-
-	dataReader, err := v.NewDataReader(backend.NewContextReaderWithAllReader(dataFile), meta.Encoding)
+	dataReader, err := v.NewDataReader(backend.NewContextReader(meta, dataFilename, r, false), meta.Encoding)
 	if err != nil {
 		fmt.Println("error reading data file")
 		return err
@@ -0,0 +1,358 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/grafana/dskit/backoff"
+	"github.com/pkg/errors"
+	"golang.org/x/time/rate"
+
+	"github.com/grafana/tempo/pkg/model"
+	"github.com/grafana/tempo/pkg/tempopb"
+)
+
+const (
+	importFormatJaeger = "jaeger_json"
+	importFormatZipkin = "zipkin_json"
+	importFormatOTLP   = "otlp_json"
+
+	importPushPath    = "/v1/traces"
+	importPushTimeout = 30 * time.Second
+)
+
+type importSpansCmd struct {
+	Format           string        `required:"" enum:"jaeger_json,zipkin_json,otlp_json" help:"format of the dump (jaeger_json/zipkin_json/otlp_json)"`
+	File             string        `required:"" type:"existingfile" help:"path to the dump"`
+	Tenant           string        `help:"tenant to push the spans as, optional"`
+	Endpoint         string        `required:"" help:"otlp http endpoint of the distributor, i.e. http://distributor:55681"`
+	Rate             int           `default:"1000" help:"maximum spans pushed per second, 0 disables the limit"`
+	BatchSpans       int           `default:"1000" help:"spans pushed per request"`
+	Offset           int           `default:"0" help:"number of records of the dump to skip, used to resume an import"`
+	ProgressInterval time.Duration `default:"10s" help:"interval of the progress report"`
+}
+
+// spanDecoder reads the records of a dump one at a time. A record is a trace of a Jaeger dump, a span or a list of
+// spans of a Zipkin dump and a batch of resource spans of an OTLP dump. It returns io.EOF after the last record.
+type spanDecoder interface {
+	next() (*tempopb.Trace, error)
+}
+
+func (cmd *importSpansCmd) Run(_ *globalOptions) error {
+	if cmd.BatchSpans <= 0 {
+		return fmt.Errorf("batch-spans must be greater than 0")
+	}
+
+	f, err := os.Open(cmd.File)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	dec, err := newSpanDecoder(cmd.Format, bufio.NewReader(f))
+	if err != nil {
+		return err
+	}
+
+	imp := &spanImporter{
+		cmd:     cmd,
+		client:  &http.Client{Timeout: importPushTimeout},
+		limiter: rate.NewLimiter(rate.Inf, 0),
+	}
+	if cmd.Rate > 0 {
+		imp.limiter = rate.NewLimiter(rate.Limit(cmd.Rate), cmd.Rate)
+	}
+
+	err = imp.run(context.Background(), dec)
+	if err != nil {
+		return errors.Wrapf(err, "import failed. resume with --offset=%d", imp.offset)
+	}
+
+	fmt.Printf("imported %d spans of %d records\n", imp.spans, imp.offset-cmd.Offset)
+	return nil
+}
+
+type spanImporter struct {
+	cmd     *importSpansCmd
+	client  *http.Client
+	limiter *rate.Limiter
+
+	// offset is the number of records of the dump that were skipped or pushed
+	offset int
+	spans  int
+}
+
+func (i *spanImporter) run(ctx context.Context, dec spanDecoder) error {
+	start := time.Now()
+	lastProgress := start
+
+	batch := &tempopb.Trace{}
+	batchRecords := 0
+	batchSpans := 0
+	flush := func() error {
+		if batchSpans > 0 {
+			if err := i.push(ctx, batch, batchSpans); err != nil {
+				return err
+			}
+		}
+		i.offset += batchRecords
+		i.spans += batchSpans
+		batch = &tempopb.Trace{}
+		batchRecords = 0
+		batchSpans = 0
+		return nil
+	}
+
+	for {
+		t, err := dec.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return errors.Wrapf(err, "error reading record %d", i.offset+batchRecords)
+		}
+
+		// skipped records are decoded anyway to find where the next one starts
+		if i.offset < i.cmd.Offset {
+			i.offset++
+			continue
+		}
+
+		batch.Batches = append(batch.Batches, t.Batches...)
+		batchRecords++
+		batchSpans += spanCount(t)
+		if batchSpans < i.cmd.BatchSpans {
+			continue
+		}
+
+		if err := flush(); err != nil {
+			return err
+		}
+		if time.Since(lastProgress) >= i.cmd.ProgressInterval {
+			lastProgress = time.Now()
+			fmt.Printf("pushed %d spans of %d records in %s. resume offset %d\n", i.spans, i.offset-i.cmd.Offset, time.Since(start).Round(time.Second), i.offset)
+		}
+	}
+
+	return flush()
+}
+
+// push sends the batch as an OTLP export request, tempopb.Trace has the same encoding. It is retried with backoff on
+// 5xx responses and transport errors.
+func (i *spanImporter) push(ctx context.Context, batch *tempopb.Trace, spans int) error {
+	// the limiter can't wait for more than its burst at a time
+	for remaining := spans; remaining > 0 && i.limiter.Limit() != rate.Inf; remaining -= i.limiter.Burst() {
+		n := remaining
+		if n > i.limiter.Burst() {
+			n = i.limiter.Burst()
+		}
+		if err := i.limiter.WaitN(ctx, n); err != nil {
+			return err
+		}
+	}
+
+	body, err := proto.Marshal(batch)
+	if err != nil {
+		return err
+	}
+
+	b := backoff.New(ctx, backoff.Config{
+		MinBackoff: 100 * time.Millisecond,
+		MaxBackoff: 10 * time.Second,
+		MaxRetries: 10,
+	})
+	for b.Ongoing() {
+		var retry bool
+		retry, err = i.pushOnce(ctx, body)
+		if !retry {
+			return err
+		}
+		b.Wait()
+	}
+	if err == nil {
+		err = b.Err()
+	}
+	return err
+}
+
+func (i *spanImporter) pushOnce(ctx context.Context, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(i.cmd.Endpoint, "/")+importPushPath, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	if i.cmd.Tenant != "" {
+		req.Header.Set("X-Scope-OrgID", i.cmd.Tenant)
+	}
+
+	resp, err := i.client.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return resp.StatusCode/100 == 5, fmt.Errorf("push responded with %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return false, nil
+}
+
+func spanCount(t *tempopb.Trace) int {
+	count := 0
+	for _, b := range t.Batches {
+		for _, ils := range b.InstrumentationLibrarySpans {
+			count += len(ils.Spans)
+		}
+	}
+	return count
+}
+
+func newSpanDecoder(format string, r io.Reader) (spanDecoder, error) {
+	dec := json.NewDecoder(r)
+	switch format {
+	case importFormatJaeger:
+		// numeric tags are decoded as json.Number to not lose the precision of int64 values
+		dec.UseNumber()
+		return newJaegerDecoder(dec)
+	case importFormatZipkin:
+		return newZipkinDecoder(dec)
+	case importFormatOTLP:
+		return &otlpDecoder{dec: dec}, nil
+	default:
+		return nil, fmt.Errorf("unknown format %s", format)
+	}
+}
+
+// jaegerDecoder reads the traces of a response of the Jaeger query api, {"data": [traces]}, or of a list of traces.
+type jaegerDecoder struct {
+	dec *json.Decoder
+}
+
+func newJaegerDecoder(dec *json.Decoder) (*jaegerDecoder, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	if tok == json.Delim('{') {
+		// skip to the data field
+		for {
+			tok, err = dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			if tok == json.Delim('}') {
+				return nil, fmt.Errorf("jaeger dump has no data field")
+			}
+			if tok == "data" {
+				break
+			}
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return nil, err
+			}
+		}
+		tok, err = dec.Token()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if tok != json.Delim('[') {
+		return nil, fmt.Errorf("jaeger dump should be a list of traces")
+	}
+	return &jaegerDecoder{dec: dec}, nil
+}
+
+func (d *jaegerDecoder) next() (*tempopb.Trace, error) {
+	if !d.dec.More() {
+		return nil, io.EOF
+	}
+
+	var jt model.JaegerTrace
+	if err := d.dec.Decode(&jt); err != nil {
+		return nil, err
+	}
+	return model.JaegerToTrace(&jt)
+}
+
+// zipkinDecoder reads a list of Zipkin v2 spans. Its entries may be lists of spans too, like the responses of the
+// traces endpoint of the Zipkin api.
+type zipkinDecoder struct {
+	dec *json.Decoder
+}
+
+func newZipkinDecoder(dec *json.Decoder) (*zipkinDecoder, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	if tok != json.Delim('[') {
+		return nil, fmt.Errorf("zipkin dump should be a list of spans")
+	}
+	return &zipkinDecoder{dec: dec}, nil
+}
+
+func (d *zipkinDecoder) next() (*tempopb.Trace, error) {
+	if !d.dec.More() {
+		return nil, io.EOF
+	}
+
+	var raw json.RawMessage
+	if err := d.dec.Decode(&raw); err != nil {
+		return nil, err
+	}
+
+	var spans []model.ZipkinSpan
+	if bytes.HasPrefix(raw, []byte("[")) {
+		if err := json.Unmarshal(raw, &spans); err != nil {
+			return nil, err
+		}
+	} else {
+		spans = make([]model.ZipkinSpan, 1)
+		if err := json.Unmarshal(raw, &spans[0]); err != nil {
+			return nil, err
+		}
+	}
+	return model.ZipkinToTrace(spans)
+}
+
+// otlpDecoder reads a sequence of OTLP json export requests, {"resourceSpans": [...]}, or traces of the Tempo api,
+// {"batches": [...]}. Ids are base64 encoded like the Tempo api returns them.
+type otlpDecoder struct {
+	dec *json.Decoder
+}
+
+func (d *otlpDecoder) next() (*tempopb.Trace, error) {
+	var fields map[string]json.RawMessage
+	if err := d.dec.Decode(&fields); err != nil {
+		return nil, err
+	}
+
+	if rs, ok := fields["resourceSpans"]; ok {
+		fields["batches"] = rs
+		delete(fields, "resourceSpans")
+	}
+	b, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &tempopb.Trace{}
+	if err := (&jsonpb.Unmarshaler{AllowUnknownFields: true}).Unmarshal(bytes.NewReader(b), t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
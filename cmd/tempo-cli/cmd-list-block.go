@@ -12,12 +12,13 @@ import (
 
 	"github.com/dustin/go-humanize"
 	"github.com/google/uuid"
+	"github.com/grafana/tempo/cmd/tempo-cli/backendoptions"
 	tempodb_backend "github.com/grafana/tempo/tempodb/backend"
 	"github.com/grafana/tempo/tempodb/encoding"
 )
 
 type listBlockCmd struct {
-	backendOptions
+	backendoptions.Options
 
 	TenantID string `arg:"" help:"tenant-id within the bucket"`
 	BlockID  string `arg:"" help:"block ID to list"`
@@ -25,7 +26,7 @@ type listBlockCmd struct {
 }
 
 func (cmd *listBlockCmd) Run(ctx *globalOptions) error {
-	r, _, c, err := loadBackend(&cmd.backendOptions, ctx)
+	r, _, c, err := cmd.Options.Load(ctx.ConfigFile)
 	if err != nil {
 		return err
 	}
@@ -58,6 +59,7 @@ func dumpBlock(r tempodb_backend.Reader, c tempodb_backend.Compactor, tenantID s
 	fmt.Println("Total Objects : ", unifiedMeta.TotalObjects)
 	fmt.Println("Data Size     : ", humanize.Bytes(unifiedMeta.Size))
 	fmt.Println("Encoding      : ", unifiedMeta.Encoding)
+	fmt.Println("Indexed       : ", !unifiedMeta.NoIndex)
 	fmt.Println("Level         : ", unifiedMeta.CompactionLevel)
 	fmt.Println("Window        : ", unifiedMeta.window)
 	fmt.Println("Start         : ", unifiedMeta.StartTime)
@@ -3,21 +3,24 @@ package main
 import (
 	"fmt"
 	"os"
+	"sort"
 	"strconv"
 	"time"
 
 	"github.com/dustin/go-humanize"
 	"github.com/olekukonko/tablewriter"
+
+	"github.com/grafana/tempo/cmd/tempo-cli/backendoptions"
 )
 
 type listBlocksCmd struct {
 	TenantID         string `arg:"" help:"tenant-id within the bucket"`
 	IncludeCompacted bool   `help:"include compacted blocks"`
-	backendOptions
+	backendoptions.Options
 }
 
 func (l *listBlocksCmd) Run(ctx *globalOptions) error {
-	r, _, c, err := loadBackend(&l.backendOptions, ctx)
+	r, _, c, err := l.Options.Load(ctx.ConfigFile)
 	if err != nil {
 		return err
 	}
@@ -36,13 +39,14 @@ func (l *listBlocksCmd) Run(ctx *globalOptions) error {
 
 func displayResults(results []blockStats, windowDuration time.Duration, includeCompacted bool) {
 
-	columns := []string{"id", "lvl", "objects", "size", "encoding", "vers", "window", "start", "end", "duration", "age"}
+	columns := []string{"id", "lvl", "objects", "size", "encoding", "data", "vers", "window", "start", "end", "duration", "age"}
 	if includeCompacted {
 		columns = append(columns, "cmp")
 	}
 
 	totalObjects := 0
 	totalBytes := uint64(0)
+	dataEncodings := map[string]*dataEncodingStats{}
 
 	out := make([][]string, 0)
 	for _, r := range results {
@@ -62,6 +66,8 @@ func displayResults(results []blockStats, windowDuration time.Duration, includeC
 				s = fmt.Sprintf("%v", humanize.Bytes(r.Size))
 			case "encoding":
 				s = r.Encoding.String()
+			case "data":
+				s = dataEncodingName(r.DataEncoding)
 			case "vers":
 				s = r.Version
 			case "window":
@@ -92,6 +98,17 @@ func displayResults(results []blockStats, windowDuration time.Duration, includeC
 		out = append(out, line)
 		totalObjects += r.TotalObjects
 		totalBytes += r.Size
+
+		if !r.compacted {
+			stats, ok := dataEncodings[r.DataEncoding]
+			if !ok {
+				stats = &dataEncodingStats{}
+				dataEncodings[r.DataEncoding] = stats
+			}
+			stats.blocks++
+			stats.objects += r.TotalObjects
+			stats.size += r.Size
+		}
 	}
 
 	footer := make([]string, 0)
@@ -112,4 +129,46 @@ func displayResults(results []blockStats, windowDuration time.Duration, includeC
 	w.SetFooter(footer)
 	w.AppendBulk(out)
 	w.Render()
+
+	displayDataEncodings(dataEncodings)
+}
+
+type dataEncodingStats struct {
+	blocks  int
+	objects int
+	size    uint64
+}
+
+// displayDataEncodings shows the distribution of data encodings over the blocks that are not compacted. A migration
+// to a new data encoding is complete once it is the only one left.
+func displayDataEncodings(dataEncodings map[string]*dataEncodingStats) {
+	names := make([]string, 0, len(dataEncodings))
+	for e := range dataEncodings {
+		names = append(names, e)
+	}
+	sort.Strings(names)
+
+	out := make([][]string, 0, len(names))
+	for _, e := range names {
+		stats := dataEncodings[e]
+		out = append(out, []string{
+			dataEncodingName(e),
+			strconv.Itoa(stats.blocks),
+			strconv.Itoa(stats.objects),
+			humanize.Bytes(stats.size),
+		})
+	}
+
+	fmt.Println()
+	w := tablewriter.NewWriter(os.Stdout)
+	w.SetHeader([]string{"data encoding", "blocks", "objects", "size"})
+	w.AppendBulk(out)
+	w.Render()
+}
+
+func dataEncodingName(dataEncoding string) string {
+	if dataEncoding == "" {
+		return "(none)"
+	}
+	return dataEncoding
 }
@@ -6,15 +6,17 @@ import (
 	"time"
 
 	"github.com/olekukonko/tablewriter"
+
+	"github.com/grafana/tempo/cmd/tempo-cli/backendoptions"
 )
 
 type listCacheSummaryCmd struct {
 	TenantID string `arg:"" help:"tenant-id within the bucket"`
-	backendOptions
+	backendoptions.Options
 }
 
 func (l *listCacheSummaryCmd) Run(ctx *globalOptions) error {
-	r, _, c, err := loadBackend(&l.backendOptions, ctx)
+	r, _, c, err := l.Options.Load(ctx.ConfigFile)
 	if err != nil {
 		return err
 	}
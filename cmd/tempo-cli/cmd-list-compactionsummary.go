@@ -9,15 +9,17 @@ import (
 
 	"github.com/dustin/go-humanize"
 	"github.com/olekukonko/tablewriter"
+
+	"github.com/grafana/tempo/cmd/tempo-cli/backendoptions"
 )
 
 type listCompactionSummaryCmd struct {
 	TenantID string `arg:"" help:"tenant-id within the bucket"`
-	backendOptions
+	backendoptions.Options
 }
 
 func (l *listCompactionSummaryCmd) Run(ctx *globalOptions) error {
-	r, _, c, err := loadBackend(&l.backendOptions, ctx)
+	r, _, c, err := l.Options.Load(ctx.ConfigFile)
 	if err != nil {
 		return err
 	}
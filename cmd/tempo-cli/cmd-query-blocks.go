@@ -8,6 +8,7 @@ import (
 
 	"github.com/gogo/protobuf/jsonpb"
 	"github.com/google/uuid"
+	"github.com/grafana/tempo/cmd/tempo-cli/backendoptions"
 	"github.com/grafana/tempo/pkg/boundedwaitgroup"
 	"github.com/grafana/tempo/pkg/model"
 	"github.com/grafana/tempo/pkg/tempopb"
@@ -23,14 +24,14 @@ type queryResults struct {
 }
 
 type queryBlocksCmd struct {
-	backendOptions
+	backendoptions.Options
 
 	TraceID  string `arg:"" help:"trace ID to retrieve"`
 	TenantID string `arg:"" help:"tenant ID to search"`
 }
 
 func (cmd *queryBlocksCmd) Run(ctx *globalOptions) error {
-	r, _, c, err := loadBackend(&cmd.backendOptions, ctx)
+	r, _, c, err := cmd.Options.Load(ctx.ConfigFile)
 	if err != nil {
 		return err
 	}
@@ -1,33 +1,112 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 
+	"github.com/grafana/tempo/pkg/tempoclient"
+	"github.com/grafana/tempo/pkg/tempopb"
 	"github.com/grafana/tempo/pkg/util"
 )
 
-type queryCmd struct {
+type apiOptions struct {
 	APIEndpoint string `arg:"" help:"tempo api endpoint"`
-	TraceID     string `arg:"" help:"trace ID to retrieve"`
 
 	OrgID string `help:"optional orgID"`
 }
 
+func (opts apiOptions) client() tempoclient.Client {
+	cfg := tempoclient.DefaultConfig(opts.APIEndpoint)
+	cfg.OrgID = opts.OrgID
+
+	return tempoclient.New(cfg)
+}
+
+type queryCmd struct {
+	apiOptions
+
+	TraceID    string `arg:"" help:"trace ID to retrieve"`
+	Mode       string `help:"optional query mode (ingesters/blocks/all)" enum:",ingesters,blocks,all"`
+	BlockStart string `help:"optional block ID to start searching backend blocks at"`
+	BlockEnd   string `help:"optional block ID to stop searching backend blocks at"`
+}
+
 func (cmd *queryCmd) Run(_ *globalOptions) error {
-	client := util.NewClient(cmd.APIEndpoint, cmd.OrgID)
+	id, err := util.HexStringToTraceID(cmd.TraceID)
+	if err != nil {
+		return err
+	}
+
+	trace, err := cmd.client().TraceByID(context.Background(), id, tempoclient.TraceByIDOptions{
+		BlockStart: cmd.BlockStart,
+		BlockEnd:   cmd.BlockEnd,
+		Mode:       cmd.Mode,
+	})
+	if err != nil {
+		return err
+	}
+
+	return printAsJSON(trace)
+}
+
+type querySearchCmd struct {
+	apiOptions
+
+	Tags        map[string]string `arg:"" optional:"" help:"tags to search for as key=value"`
+	MinDuration uint32            `help:"optional minimum trace duration in milliseconds"`
+	MaxDuration uint32            `help:"optional maximum trace duration in milliseconds"`
+	Limit       uint32            `help:"optional maximum number of traces returned"`
+}
 
-	// util.QueryTrace will only add orgID header if len(orgID) > 0
-	trace, err := client.QueryTrace(cmd.TraceID)
+func (cmd *querySearchCmd) Run(_ *globalOptions) error {
+	resp, err := cmd.client().Search(context.Background(), &tempopb.SearchRequest{
+		Tags:          cmd.Tags,
+		MinDurationMs: cmd.MinDuration,
+		MaxDurationMs: cmd.MaxDuration,
+		Limit:         cmd.Limit,
+	})
 	if err != nil {
 		return err
 	}
 
-	traceJSON, err := json.Marshal(trace)
+	return printAsJSON(resp)
+}
+
+type queryTagsCmd struct {
+	apiOptions
+}
+
+func (cmd *queryTagsCmd) Run(_ *globalOptions) error {
+	resp, err := cmd.client().TagNames(context.Background())
+	if err != nil {
+		return err
+	}
+
+	return printAsJSON(resp)
+}
+
+type queryTagValuesCmd struct {
+	apiOptions
+
+	TagName string `arg:"" help:"tag to list the values of"`
+}
+
+func (cmd *queryTagValuesCmd) Run(_ *globalOptions) error {
+	resp, err := cmd.client().TagValues(context.Background(), cmd.TagName)
+	if err != nil {
+		return err
+	}
+
+	return printAsJSON(resp)
+}
+
+func printAsJSON(v interface{}) error {
+	b, err := json.Marshal(v)
 	if err != nil {
 		return err
 	}
 
-	fmt.Println(string(traceJSON))
+	fmt.Println(string(b))
 	return nil
 }
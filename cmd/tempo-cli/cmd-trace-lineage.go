@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/grafana/tempo/cmd/tempo-cli/backendoptions"
+	"github.com/grafana/tempo/tempodb/backend"
+	"github.com/grafana/tempo/tempodb/encoding"
+)
+
+type traceLineageCmd struct {
+	backendoptions.Options
+
+	TenantID string `arg:"" help:"tenant-id within the bucket"`
+	BlockID  string `arg:"" help:"block ID to show the lineage of"`
+	MaxDepth int    `help:"maximum number of compactions to walk in each direction" default:"10"`
+}
+
+func (cmd *traceLineageCmd) Run(ctx *globalOptions) error {
+	r, _, c, err := cmd.Options.Load(ctx.ConfigFile)
+	if err != nil {
+		return err
+	}
+
+	id, err := uuid.Parse(cmd.BlockID)
+	if err != nil {
+		return err
+	}
+
+	blocks, err := loadBucket(r, c, cmd.TenantID, time.Hour, true)
+	if err != nil {
+		return err
+	}
+	fmt.Println()
+
+	// index the known compaction inputs of every block to walk forward
+	metas := map[uuid.UUID]blockStats{}
+	outputs := map[uuid.UUID][]uuid.UUID{}
+	for _, b := range blocks {
+		metas[b.BlockID] = b
+
+		inputs := b.CompactedFrom
+		if b.CompactedFromCount > len(inputs) {
+			if l, err := encoding.ReadLineage(context.Background(), r, b.BlockID, cmd.TenantID); err == nil {
+				inputs = lineageInputIDs(l)
+			}
+		}
+		for _, in := range inputs {
+			outputs[in] = append(outputs[in], b.BlockID)
+		}
+	}
+
+	fmt.Println("Compacted from:")
+	cmd.printInputs(r, metas, id, 1)
+
+	fmt.Println()
+	fmt.Println("Compacted into:")
+	cmd.printOutputs(metas, outputs, id, 1)
+
+	return nil
+}
+
+func (cmd *traceLineageCmd) printInputs(r backend.Reader, metas map[uuid.UUID]blockStats, id uuid.UUID, depth int) {
+	if depth > cmd.MaxDepth {
+		return
+	}
+
+	var inputs []encoding.LineageInput
+	l, err := encoding.ReadLineage(context.Background(), r, id, cmd.TenantID)
+	switch {
+	case err == nil:
+		inputs = l.Inputs
+		if l.TotalInputs > len(l.Inputs) {
+			fmt.Printf("%s(%d inputs not recorded)\n", indent(depth), l.TotalInputs-len(l.Inputs))
+		}
+	case err == backend.ErrDoesNotExist:
+		// blocks written before lineage objects existed or cleared by retention only have the ids in the meta
+		m, ok := metas[id]
+		if !ok {
+			return
+		}
+		for _, in := range m.CompactedFrom {
+			inputs = append(inputs, encoding.LineageInput{BlockID: in})
+		}
+	default:
+		fmt.Printf("%serror reading lineage of %s: %v\n", indent(depth), id, err)
+		return
+	}
+
+	for _, in := range inputs {
+		fmt.Printf("%s%s %s\n", indent(depth), in.BlockID, describeBlock(metas, in))
+		cmd.printInputs(r, metas, in.BlockID, depth+1)
+	}
+}
+
+func (cmd *traceLineageCmd) printOutputs(metas map[uuid.UUID]blockStats, outputs map[uuid.UUID][]uuid.UUID, id uuid.UUID, depth int) {
+	if depth > cmd.MaxDepth {
+		return
+	}
+
+	for _, out := range outputs[id] {
+		fmt.Printf("%s%s %s\n", indent(depth), out, describeBlock(metas, encoding.LineageInput{BlockID: out}))
+		cmd.printOutputs(metas, outputs, out, depth+1)
+	}
+}
+
+// describeBlock prefers the current meta of the block and falls back to what was recorded in the lineage.
+func describeBlock(metas map[uuid.UUID]blockStats, in encoding.LineageInput) string {
+	m, ok := metas[in.BlockID]
+	if !ok {
+		if in.StartTime.IsZero() {
+			return "(deleted)"
+		}
+		return fmt.Sprintf("(deleted) lvl: %d objects: %d start: %s end: %s", in.CompactionLevel, in.TotalObjects, in.StartTime, in.EndTime)
+	}
+
+	state := "live"
+	if m.compacted {
+		state = "compacted"
+	}
+	return fmt.Sprintf("(%s) lvl: %d objects: %d start: %s end: %s", state, m.CompactionLevel, m.TotalObjects, m.StartTime, m.EndTime)
+}
+
+func lineageInputIDs(l *encoding.Lineage) []uuid.UUID {
+	ids := make([]uuid.UUID, 0, len(l.Inputs))
+	for _, in := range l.Inputs {
+		ids = append(ids, in.BlockID)
+	}
+	return ids
+}
+
+func indent(depth int) string {
+	return strings.Repeat("  ", depth)
+}
@@ -6,11 +6,12 @@ import (
 	"fmt"
 
 	"github.com/google/uuid"
+	"github.com/grafana/tempo/cmd/tempo-cli/backendoptions"
 	"github.com/grafana/tempo/tempodb/encoding"
 )
 
 type viewIndexCmd struct {
-	backendOptions
+	backendoptions.Options
 
 	TenantID string `arg:"" help:"tenant-id within the bucket"`
 	BlockID  string `arg:"" help:"block ID to list"`
@@ -22,7 +23,7 @@ func (cmd *viewIndexCmd) Run(ctx *globalOptions) error {
 		return err
 	}
 
-	r, _, _, err := loadBackend(&cmd.backendOptions, ctx)
+	r, _, _, err := cmd.Options.Load(ctx.ConfigFile)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/kong"
+	"github.com/fsouza/fake-gcs-server/fakestorage"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tempo/tempodb/backend"
+	"github.com/grafana/tempo/tempodb/backend/local"
+	"github.com/grafana/tempo/tempodb/encoding/encodingtest"
+)
+
+const (
+	testTenantID = "single-tenant"
+	testBlockID  = "b18beca6-4d7f-4464-9f72-f343e688a4a0"
+	testPrefix   = "tempo/traces"
+	testBucket   = "tempo"
+)
+
+// TestCommandsBackends runs every command that accesses the backend against the local backend and a fake GCS server
+// with the same flags, apart from the backend itself.
+func TestCommandsBackends(t *testing.T) {
+	commands := [][]string{
+		{"list", "block", testTenantID, testBlockID, "--scan"},
+		{"list", "blocks", testTenantID, "--include-compacted"},
+		{"list", "compaction-summary", testTenantID},
+		{"list", "cache-summary", testTenantID},
+		{"list", "index", testTenantID, testBlockID},
+		{"view", "index", testTenantID, testBlockID},
+		{"gen", "index", testTenantID, testBlockID},
+		{"gen", "bloom", testTenantID, testBlockID, "0.05", "100000"},
+		{"query", "blocks", "1", testTenantID},
+		{"trace", "lineage", testTenantID, testBlockID},
+	}
+
+	objects := testBlockObjects(t)
+	configFile := writeTestConfig(t, writeLocalBucket(t, objects), newFakeGCS(t, objects))
+
+	for _, backend := range []string{"local", "gcs"} {
+		for _, command := range commands {
+			t.Run(backend+"/"+strings.Join(command[:2], "-"), func(t *testing.T) {
+				runCommand(t, append(command, "--config-file", configFile, "--backend", backend, "--prefix", testPrefix)...)
+			})
+		}
+	}
+}
+
+// TestGenIndexBackends regenerates the index of the test block on each backend and compares it to the original.
+func TestGenIndexBackends(t *testing.T) {
+	objects := testBlockObjects(t)
+	expected := objects[indexFilename]
+	delete(objects, indexFilename)
+
+	configFile := writeTestConfig(t, writeLocalBucket(t, objects), newFakeGCS(t, objects))
+
+	for _, backend := range []string{"local", "gcs"} {
+		t.Run(backend, func(t *testing.T) {
+			runCommand(t, "gen", "index", testTenantID, testBlockID, "--config-file", configFile, "--backend", backend, "--prefix", testPrefix)
+
+			r, _, _, err := cli.Gen.Index.Options.Load(configFile)
+			require.NoError(t, err)
+			index, err := r.Read(context.Background(), indexFilename, uuid.MustParse(testBlockID), testTenantID, false)
+			require.NoError(t, err)
+			assert.Equal(t, expected, index)
+		})
+	}
+}
+
+func runCommand(t *testing.T, args ...string) {
+	parser, err := kong.New(&cli)
+	require.NoError(t, err)
+	ctx, err := parser.Parse(args)
+	require.NoError(t, err)
+	require.NoError(t, ctx.Run(&cli.globalOptions))
+}
+
+// testBlockObjects returns the objects of a generated block by name.
+func testBlockObjects(t *testing.T) map[string][]byte {
+	dir := t.TempDir()
+	_, rawW, _, err := local.New(&local.Config{Path: dir})
+	require.NoError(t, err)
+	_, err = encodingtest.WriteBlock(context.Background(), backend.NewWriter(rawW), encodingtest.BlockOptions{
+		TenantID:           testTenantID,
+		BlockID:            uuid.MustParse(testBlockID),
+		Encoding:           backend.EncZstd,
+		Objects:            500,
+		MinObjectBytes:     100,
+		MaxObjectBytes:     2000,
+		DuplicateRatio:     0.02,
+		IndexPageSizeBytes: 1000,
+	})
+	require.NoError(t, err)
+
+	blockDir := filepath.Join(dir, testTenantID, testBlockID)
+	files, err := ioutil.ReadDir(blockDir)
+	require.NoError(t, err)
+
+	objects := map[string][]byte{}
+	for _, f := range files {
+		b, err := ioutil.ReadFile(filepath.Join(blockDir, f.Name()))
+		require.NoError(t, err)
+		objects[f.Name()] = b
+	}
+	return objects
+}
+
+func writeLocalBucket(t *testing.T, objects map[string][]byte) string {
+	bucket := t.TempDir()
+	dir := filepath.Join(bucket, testPrefix, testTenantID, testBlockID)
+	require.NoError(t, os.MkdirAll(dir, 0755))
+
+	for name, b := range objects {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), b, 0644))
+	}
+	return bucket
+}
+
+// newFakeGCS starts a fake GCS server holding the objects and returns its endpoint. The client downloads objects from
+// the host of the endpoint while the fake server only serves downloads for the default host, and it treats the end of
+// a range as exclusive, so requests go through a proxy that rewrites both.
+func newFakeGCS(t *testing.T, objects map[string][]byte) string {
+	initial := make([]fakestorage.Object, 0, len(objects))
+	for name, b := range objects {
+		initial = append(initial, fakestorage.Object{
+			BucketName: testBucket,
+			Name:       path.Join(testPrefix, testTenantID, testBlockID, name),
+			Content:    b,
+		})
+	}
+
+	server, err := fakestorage.NewServerWithOptions(fakestorage.Options{
+		InitialObjects: initial,
+		NoListener:     true,
+	})
+	require.NoError(t, err)
+	client := server.HTTPClient()
+
+	proxy := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u := *r.URL
+		u.Scheme = "https"
+		u.Host = r.Host
+		if !strings.HasPrefix(u.Path, "/storage/v1/") && !strings.HasPrefix(u.Path, "/upload/") {
+			u.Host = "storage.googleapis.com"
+		}
+
+		req, err := http.NewRequestWithContext(r.Context(), r.Method, u.String(), r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		req.Header = r.Header.Clone()
+		if rng := r.Header.Get("Range"); rng != "" {
+			req.Header.Set("Range", exclusiveRange(rng))
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer resp.Body.Close()
+
+		for k, v := range resp.Header {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(resp.StatusCode)
+		_, _ = io.Copy(w, resp.Body)
+	}))
+	t.Cleanup(proxy.Close)
+
+	return proxy.URL + "/storage/v1/"
+}
+
+func exclusiveRange(r string) string {
+	var start, end int
+	if _, err := fmt.Sscanf(r, "bytes=%d-%d", &start, &end); err != nil {
+		return r
+	}
+	return fmt.Sprintf("bytes=%d-%d", start, end+1)
+}
+
+// writeTestConfig writes a config file of both backends.
+func writeTestConfig(t *testing.T, localPath string, gcsEndpoint string) string {
+	config := `
+storage:
+  trace:
+    local:
+      path: ` + localPath + `
+    gcs:
+      bucket_name: ` + testBucket + `
+      endpoint: ` + gcsEndpoint + `
+      insecure: true
+`
+	configFile := filepath.Join(t.TempDir(), "tempo.yaml")
+	require.NoError(t, ioutil.WriteFile(configFile, []byte(config), 0644))
+	return configFile
+}
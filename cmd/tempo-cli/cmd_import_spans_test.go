@@ -0,0 +1,125 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/alecthomas/kong"
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+)
+
+const (
+	testJaegerDump = `{"data": [
+		{"traceID": "01", "spans": [{"traceID": "01", "spanID": "01", "operationName": "a", "processID": "p1"}], "processes": {"p1": {"serviceName": "svc", "tags": [{"key": "host", "type": "string", "value": "h"}]}}},
+		{"traceID": "02", "spans": [{"traceID": "02", "spanID": "02", "operationName": "b", "processID": "p1"}, {"traceID": "02", "spanID": "03", "operationName": "c", "processID": "p1"}], "processes": {"p1": {"serviceName": "svc"}}}
+	], "total": 2, "errors": null}`
+	testZipkinDump = `[
+		[{"traceId": "01", "id": "01", "name": "a"}, {"traceId": "01", "id": "02", "name": "b"}],
+		{"traceId": "02", "id": "03", "name": "c"}
+	]`
+	testOTLPDump = `{"resourceSpans": [{"instrumentationLibrarySpans": [{"spans": [{"traceId": "AAAAAAAAAAAAAAAAAAAAAQ==", "spanId": "AAAAAAAAAAE=", "name": "a"}]}]}]}
+{"batches": [{"instrumentationLibrarySpans": [{"spans": [{"traceId": "AAAAAAAAAAAAAAAAAAAAAg==", "spanId": "AAAAAAAAAAI=", "name": "b"}, {"traceId": "AAAAAAAAAAAAAAAAAAAAAg==", "spanId": "AAAAAAAAAAM=", "name": "c"}]}]}]}`
+)
+
+// testPushServer records the names of the spans pushed to it. It fails the pushes with the given status codes in turn.
+type testPushServer struct {
+	mtx      sync.Mutex
+	names    []string
+	tenants  []string
+	failures []int
+}
+
+func (s *testPushServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if r.URL.Path != importPushPath || r.Header.Get("Content-Type") != "application/x-protobuf" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if len(s.failures) > 0 {
+		code := s.failures[0]
+		s.failures = s.failures[1:]
+		w.WriteHeader(code)
+		return
+	}
+
+	body, _ := ioutil.ReadAll(r.Body)
+	t := &tempopb.Trace{}
+	if err := proto.Unmarshal(body, t); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	for _, b := range t.Batches {
+		for _, ils := range b.InstrumentationLibrarySpans {
+			for _, span := range ils.Spans {
+				s.names = append(s.names, span.Name)
+			}
+		}
+	}
+	s.tenants = append(s.tenants, r.Header.Get("X-Scope-OrgID"))
+	w.WriteHeader(http.StatusOK)
+}
+
+func TestImportSpans(t *testing.T) {
+	tests := []struct {
+		format string
+		dump   string
+		// resumed are the spans after the first record
+		resumed []string
+	}{
+		{format: importFormatJaeger, dump: testJaegerDump, resumed: []string{"b", "c"}},
+		{format: importFormatZipkin, dump: testZipkinDump, resumed: []string{"c"}},
+		{format: importFormatOTLP, dump: testOTLPDump, resumed: []string{"b", "c"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.format, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "dump.json")
+			require.NoError(t, ioutil.WriteFile(file, []byte(tc.dump), 0644))
+
+			srv := &testPushServer{}
+			server := httptest.NewServer(srv)
+			defer server.Close()
+
+			run := func(batchSpans int, offset int) error {
+				parser, err := kong.New(&cli)
+				require.NoError(t, err)
+				ctx, err := parser.Parse([]string{"import", "spans", "--format", tc.format, "--file", file, "--tenant", "test",
+					"--endpoint", server.URL, "--rate", "0", "--batch-spans", strconv.Itoa(batchSpans), "--offset", strconv.Itoa(offset)})
+				require.NoError(t, err)
+				return ctx.Run(&cli.globalOptions)
+			}
+
+			// every record is pushed, the records are batched up to the batch size
+			require.NoError(t, run(10, 0))
+			assert.Equal(t, []string{"a", "b", "c"}, srv.names)
+			assert.Equal(t, []string{"test"}, srv.tenants)
+
+			// 5xx responses are retried
+			srv.names, srv.tenants, srv.failures = nil, nil, []int{http.StatusServiceUnavailable}
+			require.NoError(t, run(1, 0))
+			assert.Equal(t, []string{"a", "b", "c"}, srv.names)
+			assert.Len(t, srv.tenants, 2)
+
+			// a failed push reports the offset to resume at, the records pushed before the failure are skipped
+			srv.names, srv.tenants, srv.failures = nil, nil, []int{http.StatusOK, http.StatusBadRequest}
+			err := run(1, 0)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "--offset=1")
+
+			srv.names = nil
+			require.NoError(t, run(1, 1))
+			assert.Equal(t, tc.resumed, srv.names)
+		})
+	}
+}
@@ -1,19 +1,7 @@
 package main
 
 import (
-	"flag"
-	"fmt"
-	"io/ioutil"
-
-	"github.com/grafana/tempo/cmd/tempo/app"
-	"github.com/grafana/tempo/tempodb/backend"
-	"github.com/grafana/tempo/tempodb/backend/local"
-	"gopkg.in/yaml.v2"
-
 	"github.com/alecthomas/kong"
-	"github.com/grafana/tempo/tempodb/backend/azure"
-	"github.com/grafana/tempo/tempodb/backend/gcs"
-	"github.com/grafana/tempo/tempodb/backend/s3"
 )
 
 const (
@@ -23,16 +11,9 @@ const (
 )
 
 type globalOptions struct {
-	ConfigFile string `type:"path" short:"c" help:"Path to tempo config file"`
-}
-
-type backendOptions struct {
-	Backend string `help:"backend to connect to (s3/gcs/local/azure), optional, overrides backend in config file" enum:",s3,gcs,local,azure"`
-	Bucket  string `help:"bucket (or path on local backend) to scan, optional, overrides bucket in config file"`
-
-	S3Endpoint string `name:"s3-endpoint" help:"s3 endpoint (s3.dualstack.us-east-2.amazonaws.com), optional, overrides endpoint in config file"`
-	S3User     string `name:"s3-user" help:"s3 username, optional, overrides username in config file"`
-	S3Pass     string `name:"s3-pass" help:"s3 password, optional, overrides password in config file"`
+	ConfigFile    string `type:"path" short:"c" help:"Path to tempo config file"`
+	AuditEndpoint string `help:"tempo http endpoint to record changes made by commands in the audit log, optional"`
+	AuditOrgID    string `help:"id the changes are recorded as in the audit log, defaults to the client address"`
 }
 
 var cli struct {
@@ -56,8 +37,23 @@ var cli struct {
 	} `cmd:""`
 
 	Query struct {
-		API    queryCmd       `cmd:"" help:"query tempo http api"`
-		Blocks queryBlocksCmd `cmd:"" help:"query for a traceid directly from backend blocks"`
+		API       queryCmd          `cmd:"" help:"query tempo http api"`
+		Search    querySearchCmd    `cmd:"" help:"search traces with the tempo http api"`
+		Tags      queryTagsCmd      `cmd:"" help:"list the tags that can be searched with the tempo http api"`
+		TagValues queryTagValuesCmd `cmd:"" help:"list the values of a tag with the tempo http api"`
+		Blocks    queryBlocksCmd    `cmd:"" help:"query for a traceid directly from backend blocks"`
+	} `cmd:""`
+
+	Trace struct {
+		Lineage traceLineageCmd `cmd:"" help:"show the blocks a block was compacted from and into"`
+	} `cmd:""`
+
+	Import struct {
+		Spans importSpansCmd `cmd:"" help:"convert a jaeger, zipkin or otlp json dump to otlp and push it to a distributor"`
+	} `cmd:""`
+
+	Flush struct {
+		RetryDeadLetter flushRetryDeadLetterCmd `cmd:"" name:"retry-deadletter" help:"move dead-lettered blocks back into the ingester wal to be flushed"`
 	} `cmd:""`
 }
 
@@ -71,62 +67,3 @@ func main() {
 	err := ctx.Run(&cli.globalOptions)
 	ctx.FatalIfErrorf(err)
 }
-
-func loadBackend(b *backendOptions, g *globalOptions) (backend.Reader, backend.Writer, backend.Compactor, error) {
-	// Defaults
-	cfg := app.Config{}
-	cfg.RegisterFlagsAndApplyDefaults("", &flag.FlagSet{})
-
-	// Existing config
-	if g.ConfigFile != "" {
-		buff, err := ioutil.ReadFile(g.ConfigFile)
-		if err != nil {
-			return nil, nil, nil, fmt.Errorf("failed to read configFile %s: %w", g.ConfigFile, err)
-		}
-
-		err = yaml.UnmarshalStrict(buff, &cfg)
-		if err != nil {
-			return nil, nil, nil, fmt.Errorf("failed to parse configFile %s: %w", g.ConfigFile, err)
-		}
-	}
-
-	// cli overrides
-	if b.Backend != "" {
-		cfg.StorageConfig.Trace.Backend = b.Backend
-	}
-
-	if b.Bucket != "" {
-		cfg.StorageConfig.Trace.Local.Path = b.Bucket
-		cfg.StorageConfig.Trace.GCS.BucketName = b.Bucket
-		cfg.StorageConfig.Trace.S3.Bucket = b.Bucket
-		cfg.StorageConfig.Trace.Azure.ContainerName = b.Bucket
-	}
-
-	if b.S3Endpoint != "" {
-		cfg.StorageConfig.Trace.S3.Endpoint = b.S3Endpoint
-	}
-
-	var err error
-	var r backend.RawReader
-	var w backend.RawWriter
-	var c backend.Compactor
-
-	switch cfg.StorageConfig.Trace.Backend {
-	case "local":
-		r, w, c, err = local.New(cfg.StorageConfig.Trace.Local)
-	case "gcs":
-		r, w, c, err = gcs.New(cfg.StorageConfig.Trace.GCS)
-	case "s3":
-		r, w, c, err = s3.New(cfg.StorageConfig.Trace.S3)
-	case "azure":
-		r, w, c, err = azure.New(cfg.StorageConfig.Trace.Azure)
-	default:
-		err = fmt.Errorf("unknown backend %s", cfg.StorageConfig.Trace.Backend)
-	}
-
-	if err != nil {
-		return nil, nil, nil, err
-	}
-
-	return backend.NewReader(r), backend.NewWriter(w), c, nil
-}
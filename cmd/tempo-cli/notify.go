@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/grafana/tempo/tempodb/blocklist"
+)
+
+const notifyTimeout = 5 * time.Minute
+
+// notifyOptions are the flags of the commands that change blocks in the backend.
+type notifyOptions struct {
+	Notify []string `help:"tempo http endpoints of compactors and queriers to re-poll the blocklist of the tenant after the change, optional. endpoints are notified in order, list the compactors first as queriers read the tenant index they write"`
+}
+
+// notify asks the endpoints to re-poll the blocklist of the tenant so the change is served without waiting for the
+// next poll cycle. Failures are reported but never fail the command, the change is picked up by the next poll.
+func (o *notifyOptions) notify(tenantID string) {
+	client := &http.Client{Timeout: notifyTimeout}
+
+	for _, endpoint := range o.Notify {
+		u := strings.TrimSuffix(endpoint, "/") + "/flush-blocklist?" + url.Values{"tenant": []string{tenantID}}.Encode()
+
+		resp, err := client.Post(u, "", nil)
+		if err != nil {
+			fmt.Println("failed to notify", endpoint, err)
+			continue
+		}
+
+		var changes blocklist.Changes
+		if resp.StatusCode/100 != 2 {
+			fmt.Println("failed to notify", endpoint, resp.Status)
+		} else if err := json.NewDecoder(resp.Body).Decode(&changes); err != nil {
+			fmt.Println("failed to read response of", endpoint, err)
+		} else {
+			fmt.Printf("notified %s: %d blocks added, %d blocks removed\n", endpoint, changes.Added, changes.Removed)
+		}
+		resp.Body.Close()
+	}
+}
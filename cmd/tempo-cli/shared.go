@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"fmt"
+	"io"
 	"sort"
 	"strconv"
 	"time"
@@ -119,3 +120,43 @@ func loadBlock(r backend.Reader, c backend.Compactor, tenantID string, id uuid.U
 		unifiedBlockMeta: getMeta(meta, compactedMeta, windowRange),
 	}, nil
 }
+
+// objectReader reads an object of a block from the backend. Unlike the ContextReader of the backend it also streams
+// the whole object for sequential reads, so replaying a block doesn't load it into memory.
+type objectReader struct {
+	backend.ContextReader
+
+	meta *backend.BlockMeta
+	name string
+	r    backend.Reader
+	rc   io.ReadCloser
+}
+
+func newObjectReader(meta *backend.BlockMeta, name string, r backend.Reader) *objectReader {
+	return &objectReader{
+		ContextReader: backend.NewContextReader(meta, name, r, false),
+		meta:          meta,
+		name:          name,
+		r:             r,
+	}
+}
+
+// Reader implements backend.ContextReader
+func (o *objectReader) Reader() (io.Reader, error) {
+	if o.rc == nil {
+		rc, _, err := o.r.StreamReader(context.TODO(), o.name, o.meta.BlockID, o.meta.TenantID)
+		if err != nil {
+			return nil, err
+		}
+		o.rc = rc
+	}
+	return o.rc, nil
+}
+
+// Close closes the stream opened by Reader, if any.
+func (o *objectReader) Close() error {
+	if o.rc == nil {
+		return nil
+	}
+	return o.rc.Close()
+}
@@ -15,6 +15,7 @@ import (
 
 	"github.com/go-test/deep"
 	"github.com/grafana/tempo/pkg/model"
+	"github.com/grafana/tempo/pkg/tempoclient"
 	"github.com/grafana/tempo/pkg/tempopb"
 	"github.com/grafana/tempo/pkg/util"
 	jaeger_grpc "github.com/jaegertracing/jaeger/cmd/agent/app/reporter/grpc"
@@ -151,7 +152,7 @@ func main() {
 				zap.String("org_id", tempoOrgID),
 			)
 
-			client := util.NewClient(tempoQueryURL, tempoOrgID)
+			client := newClient()
 
 			// query the trace
 			queryMetrics, err := queryTrace(client, seed)
@@ -187,7 +188,7 @@ func main() {
 				zap.String("org_id", tempoOrgID),
 			)
 
-			client := util.NewClient(tempoQueryURL, tempoOrgID)
+			client := newClient()
 
 			// query a tag we expect the trace to be found within
 			searchMetrics, err := searchTag(client, seed)
@@ -248,6 +249,14 @@ func newJaegerGRPCClient(endpoint string) (*jaeger_grpc.Reporter, error) {
 	return jaeger_grpc.NewReporter(conn, nil, logger), err
 }
 
+func newClient() tempoclient.Client {
+	cfg := tempoclient.DefaultConfig(tempoQueryURL)
+	cfg.OrgID = tempoOrgID
+	cfg.Backoff.MaxRetries = 1 // failed requests are reported instead of retried
+
+	return tempoclient.New(cfg)
+}
+
 func newRand(t time.Time) *rand.Rand {
 	return rand.New(rand.NewSource(t.Unix()))
 }
@@ -315,7 +324,7 @@ func generateRandomInt(min int64, max int64, r *rand.Rand) int64 {
 	return number
 }
 
-func searchTag(client *util.Client, seed time.Time) (traceMetrics, error) {
+func searchTag(client tempoclient.Client, seed time.Time) (traceMetrics, error) {
 	tm := traceMetrics{
 		requested: 1,
 	}
@@ -364,7 +373,9 @@ func searchTag(client *util.Client, seed time.Time) (traceMetrics, error) {
 	logger.Info("searching Tempo")
 
 	// Use the search API to find details about the expected trace
-	resp, err := client.SearchTag(attr.Key, attr.Value.GetStringValue())
+	resp, err := client.Search(context.Background(), &tempopb.SearchRequest{
+		Tags: map[string]string{attr.Key: attr.Value.GetStringValue()},
+	})
 	if err != nil {
 		logger.Error(fmt.Sprintf("failed to query tag values for %s: %s", attr.Key, err.Error()))
 		tm.requestFailed++
@@ -379,7 +390,7 @@ func searchTag(client *util.Client, seed time.Time) (traceMetrics, error) {
 	return tm, nil
 }
 
-func queryTrace(client *util.Client, seed time.Time) (traceMetrics, error) {
+func queryTrace(client tempoclient.Client, seed time.Time) (traceMetrics, error) {
 	tm := traceMetrics{
 		requested: 1,
 	}
@@ -394,9 +405,14 @@ func queryTrace(client *util.Client, seed time.Time) (traceMetrics, error) {
 	)
 	logger.Info("querying Tempo")
 
-	trace, err := client.QueryTrace(hexID)
+	traceID, err := util.HexStringToTraceID(hexID)
+	if err != nil {
+		return tm, err
+	}
+
+	trace, err := client.TraceByID(context.Background(), traceID, tempoclient.TraceByIDOptions{})
 	if err != nil {
-		if err == util.ErrTraceNotFound {
+		if err == tempoclient.ErrTraceNotFound {
 			tm.notFoundByID++
 		} else {
 			tm.requestFailed++
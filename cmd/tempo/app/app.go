@@ -11,7 +11,9 @@ import (
 	"time"
 
 	cortex_frontend "github.com/cortexproject/cortex/pkg/frontend/v1"
+	cortex_frontend_v2 "github.com/cortexproject/cortex/pkg/frontend/v2"
 	"github.com/cortexproject/cortex/pkg/ring"
+	"github.com/cortexproject/cortex/pkg/scheduler"
 	"github.com/cortexproject/cortex/pkg/util/grpc/healthcheck"
 	"github.com/cortexproject/cortex/pkg/util/log"
 	"github.com/go-kit/kit/log/level"
@@ -38,6 +40,7 @@ import (
 	"github.com/grafana/tempo/modules/overrides"
 	"github.com/grafana/tempo/modules/querier"
 	"github.com/grafana/tempo/modules/storage"
+	"github.com/grafana/tempo/pkg/audit"
 	tempo_util "github.com/grafana/tempo/pkg/util"
 	"github.com/grafana/tempo/tempodb"
 )
@@ -50,20 +53,24 @@ type Config struct {
 	Target              string `yaml:"target,omitempty"`
 	AuthEnabled         bool   `yaml:"auth_enabled,omitempty"`
 	MultitenancyEnabled bool   `yaml:"multitenancy_enabled,omitempty"`
-	SearchEnabled       bool   `yaml:"search_enabled,omitempty"`
-	HTTPAPIPrefix       string `yaml:"http_api_prefix"`
-	UseOTelTracer       bool   `yaml:"use_otel_tracer,omitempty"`
+	// MultitenancyFederationEnabled enables the queries of all federated tenants at once in the query frontend.
+	MultitenancyFederationEnabled bool   `yaml:"multitenancy_federation_enabled,omitempty"`
+	SearchEnabled                 bool   `yaml:"search_enabled,omitempty"`
+	HTTPAPIPrefix                 string `yaml:"http_api_prefix"`
+	UseOTelTracer                 bool   `yaml:"use_otel_tracer,omitempty"`
 
 	Server         server.Config          `yaml:"server,omitempty"`
 	Distributor    distributor.Config     `yaml:"distributor,omitempty"`
 	IngesterClient ingester_client.Config `yaml:"ingester_client,omitempty"`
 	Querier        querier.Config         `yaml:"querier,omitempty"`
 	Frontend       frontend.Config        `yaml:"query_frontend,omitempty"`
+	QueryScheduler scheduler.Config       `yaml:"query_scheduler,omitempty"`
 	Compactor      compactor.Config       `yaml:"compactor,omitempty"`
 	Ingester       ingester.Config        `yaml:"ingester,omitempty"`
 	StorageConfig  storage.Config         `yaml:"storage,omitempty"`
 	LimitsConfig   overrides.Limits       `yaml:"overrides,omitempty"`
 	MemberlistKV   memberlist.KVConfig    `yaml:"memberlist,omitempty"`
+	Audit          audit.Config           `yaml:"audit,omitempty"`
 }
 
 // RegisterFlagsAndApplyDefaults registers flag.
@@ -73,6 +80,7 @@ func (c *Config) RegisterFlagsAndApplyDefaults(prefix string, f *flag.FlagSet) {
 	f.StringVar(&c.Target, "target", All, "target module")
 	f.BoolVar(&c.AuthEnabled, "auth.enabled", false, "Set to true to enable auth (deprecated: use multitenancy.enabled)")
 	f.BoolVar(&c.MultitenancyEnabled, "multitenancy.enabled", false, "Set to true to enable multitenancy.")
+	f.BoolVar(&c.MultitenancyFederationEnabled, "multitenancy.federation-enabled", false, "Set to true to enable federated queries of all tenants in query_frontend.federation.tenants. Requires multitenancy.")
 	f.BoolVar(&c.SearchEnabled, "search.enabled", false, "Set to true to enable search (unstable).")
 	f.StringVar(&c.HTTPAPIPrefix, "http-api-prefix", "", "String prefix for all http api endpoints.")
 	f.BoolVar(&c.UseOTelTracer, "use-otel-tracer", false, "Set to true to replace the OpenTracing tracer with the OpenTelemetry tracer")
@@ -108,6 +116,7 @@ func (c *Config) RegisterFlagsAndApplyDefaults(prefix string, f *flag.FlagSet) {
 	flagext.DefaultValues(&c.IngesterClient)
 	c.IngesterClient.GRPCClientConfig.GRPCCompression = "snappy"
 	flagext.DefaultValues(&c.LimitsConfig)
+	flagext.DefaultValues(&c.QueryScheduler)
 
 	c.Distributor.RegisterFlagsAndApplyDefaults(tempo_util.PrefixConfig(prefix, "distributor"), f)
 	c.Ingester.RegisterFlagsAndApplyDefaults(tempo_util.PrefixConfig(prefix, "ingester"), f)
@@ -115,6 +124,7 @@ func (c *Config) RegisterFlagsAndApplyDefaults(prefix string, f *flag.FlagSet) {
 	c.Frontend.RegisterFlagsAndApplyDefaults(tempo_util.PrefixConfig(prefix, "frontend"), f)
 	c.Compactor.RegisterFlagsAndApplyDefaults(tempo_util.PrefixConfig(prefix, "compactor"), f)
 	c.StorageConfig.RegisterFlagsAndApplyDefaults(tempo_util.PrefixConfig(prefix, "storage"), f)
+	c.Audit.RegisterFlagsAndApplyDefaults(tempo_util.PrefixConfig(prefix, "audit"), f)
 
 }
 
@@ -123,46 +133,123 @@ func (c *Config) MultitenancyIsEnabled() bool {
 	return c.MultitenancyEnabled || c.AuthEnabled
 }
 
-// CheckConfig checks if config values are suspect.
-func (c *Config) CheckConfig() {
+// ConfigWarning describes a suspect config value found by CheckConfig.
+type ConfigWarning struct {
+	Message string
+	Explain string
+}
+
+// CheckConfig checks if config values are suspect and returns a warning for each suspect value.
+func (c *Config) CheckConfig() []ConfigWarning {
+	var warnings []ConfigWarning
+
 	if c.Ingester.CompleteBlockTimeout < c.StorageConfig.Trace.BlocklistPoll {
-		level.Warn(log.Logger).Log("msg", "ingester.complete_block_timeout < storage.trace.blocklist_poll",
-			"explan", "You may receive 404s between the time the ingesters have flushed a trace and the querier is aware of the new block")
+		warnings = append(warnings, ConfigWarning{
+			Message: "ingester.complete_block_timeout < storage.trace.blocklist_poll",
+			Explain: "You may receive 404s between the time the ingesters have flushed a trace and the querier is aware of the new block",
+		})
 	}
 
 	if c.Compactor.Compactor.BlockRetention < c.StorageConfig.Trace.BlocklistPoll {
-		level.Warn(log.Logger).Log("msg", "compactor.compaction.compacted_block_timeout < storage.trace.blocklist_poll",
-			"explan", "Queriers and Compactors may attempt to read a block that no longer exists")
+		warnings = append(warnings, ConfigWarning{
+			Message: "compactor.compaction.compacted_block_timeout < storage.trace.blocklist_poll",
+			Explain: "Queriers and Compactors may attempt to read a block that no longer exists",
+		})
 	}
 
 	if c.Compactor.Compactor.RetentionConcurrency == 0 {
-		level.Warn(log.Logger).Log("msg", "c.Compactor.Compactor.RetentionConcurrency must be greater than zero. Using default.", "default", tempodb.DefaultRetentionConcurrency)
+		warnings = append(warnings, ConfigWarning{
+			Message: "c.Compactor.Compactor.RetentionConcurrency must be greater than zero",
+			Explain: fmt.Sprintf("Using default %d", tempodb.DefaultRetentionConcurrency),
+		})
 	}
 
 	if c.StorageConfig.Trace.Backend == "s3" && c.Compactor.Compactor.FlushSizeBytes < 5242880 {
-		level.Warn(log.Logger).Log("msg", "c.Compactor.Compactor.FlushSizeBytes < 5242880",
-			"explan", "Compaction flush size should be 5MB or higher for S3 backend")
+		warnings = append(warnings, ConfigWarning{
+			Message: "c.Compactor.Compactor.FlushSizeBytes < 5242880",
+			Explain: "Compaction flush size should be 5MB or higher for S3 backend",
+		})
 	}
 
 	if c.StorageConfig.Trace.BlocklistPollConcurrency == 0 {
-		level.Warn(log.Logger).Log("msg", "c.StorageConfig.Trace.BlocklistPollConcurrency must be greater than zero. Using default.", "default", tempodb.DefaultBlocklistPollConcurrency)
+		warnings = append(warnings, ConfigWarning{
+			Message: "c.StorageConfig.Trace.BlocklistPollConcurrency must be greater than zero",
+			Explain: fmt.Sprintf("Using default %d", tempodb.DefaultBlocklistPollConcurrency),
+		})
 	}
+
+	return append(warnings, c.checkGRPCMessageSizes()...)
+}
+
+// checkGRPCMessageSizes compares the gRPC message size limits of the components that talk to each other. Every
+// component is assumed to run with the same server config. A mismatch otherwise surfaces as an opaque
+// "received message larger than max" error at runtime.
+func (c *Config) checkGRPCMessageSizes() []ConfigWarning {
+	var warnings []ConfigWarning
+
+	serverMaxRecv := c.Server.GPRCServerMaxRecvMsgSize
+	serverMaxSend := c.Server.GRPCServerMaxSendMsgSize
+	ingesterClientMaxSend := c.IngesterClient.GRPCClientConfig.MaxSendMsgSize
+	ingesterClientMaxRecv := c.IngesterClient.GRPCClientConfig.MaxRecvMsgSize
+	querierMaxSend := c.Querier.Worker.GRPCClientConfig.MaxSendMsgSize
+	maxBytesPerTrace := c.LimitsConfig.MaxBytesPerTrace
+
+	if ingesterClientMaxSend > serverMaxRecv {
+		warnings = append(warnings, ConfigWarning{
+			Message: fmt.Sprintf("ingester_client.grpc_client_config.max_send_msg_size (%d) > server.grpc_server_max_recv_msg_size (%d)", ingesterClientMaxSend, serverMaxRecv),
+			Explain: fmt.Sprintf("Ingesters reject pushes larger than %d bytes that distributors are allowed to send. Set server.grpc_server_max_recv_msg_size to at least %d or lower ingester_client.grpc_client_config.max_send_msg_size", serverMaxRecv, ingesterClientMaxSend),
+		})
+	}
+
+	if serverMaxSend > ingesterClientMaxRecv {
+		warnings = append(warnings, ConfigWarning{
+			Message: fmt.Sprintf("server.grpc_server_max_send_msg_size (%d) > ingester_client.grpc_client_config.max_recv_msg_size (%d)", serverMaxSend, ingesterClientMaxRecv),
+			Explain: fmt.Sprintf("Queriers fail to receive traces larger than %d bytes returned by ingesters. Set ingester_client.grpc_client_config.max_recv_msg_size to at least %d", ingesterClientMaxRecv, serverMaxSend),
+		})
+	}
+
+	if querierMaxSend > serverMaxRecv {
+		warnings = append(warnings, ConfigWarning{
+			Message: fmt.Sprintf("querier.frontend_worker.grpc_client_config.max_send_msg_size (%d) > server.grpc_server_max_recv_msg_size (%d)", querierMaxSend, serverMaxRecv),
+			Explain: fmt.Sprintf("Query frontends reject query results larger than %d bytes that queriers are allowed to send. Set server.grpc_server_max_recv_msg_size to at least %d", serverMaxRecv, querierMaxSend),
+		})
+	}
+
+	// a single trace can't be split across pushes
+	if maxBytesPerTrace > 0 && maxBytesPerTrace > ingesterClientMaxSend {
+		warnings = append(warnings, ConfigWarning{
+			Message: fmt.Sprintf("overrides.max_bytes_per_trace (%d) > ingester_client.grpc_client_config.max_send_msg_size (%d)", maxBytesPerTrace, ingesterClientMaxSend),
+			Explain: fmt.Sprintf("Distributors reject the spans of a trace that add up to more than %d bytes in a single push. Set ingester_client.grpc_client_config.max_send_msg_size to at least %d", ingesterClientMaxSend, maxBytesPerTrace),
+		})
+	}
+
+	if maxBytesPerTrace > 0 && maxBytesPerTrace > serverMaxSend {
+		warnings = append(warnings, ConfigWarning{
+			Message: fmt.Sprintf("overrides.max_bytes_per_trace (%d) > server.grpc_server_max_send_msg_size (%d)", maxBytesPerTrace, serverMaxSend),
+			Explain: fmt.Sprintf("Ingesters fail to return traces larger than %d bytes to queriers. Set server.grpc_server_max_send_msg_size to at least %d", serverMaxSend, maxBytesPerTrace),
+		})
+	}
+
+	return warnings
 }
 
 // App is the root datastructure.
 type App struct {
 	cfg Config
 
-	Server       *server.Server
-	ring         *ring.Ring
-	overrides    *overrides.Overrides
-	distributor  *distributor.Distributor
-	querier      *querier.Querier
-	frontend     *cortex_frontend.Frontend
-	compactor    *compactor.Compactor
-	ingester     *ingester.Ingester
-	store        storage.Store
-	MemberlistKV *memberlist.KVInitService
+	Server         *server.Server
+	ring           *ring.Ring
+	overrides      *overrides.Overrides
+	distributor    *distributor.Distributor
+	querier        *querier.Querier
+	frontend       *cortex_frontend.Frontend
+	frontendV2     *cortex_frontend_v2.Frontend
+	queryScheduler *scheduler.Scheduler
+	compactor      *compactor.Compactor
+	ingester       *ingester.Ingester
+	store          storage.Store
+	audit          *audit.Logger
+	MemberlistKV   *memberlist.KVInitService
 
 	HTTPAuthMiddleware middleware.Interface
 	ModuleManager      *modules.Manager
@@ -191,6 +278,9 @@ func (t *App) setupAuthMiddleware() {
 		noGRPCAuthOn := []string{
 			"/frontend.Frontend/Process",
 			"/frontend.Frontend/NotifyClientShutdown",
+			"/schedulerpb.SchedulerForFrontend/FrontendLoop",
+			"/schedulerpb.SchedulerForQuerier/QuerierLoop",
+			"/schedulerpb.SchedulerForQuerier/NotifyQuerierShutdown",
 		}
 		ignoredMethods := map[string]bool{}
 		for _, m := range noGRPCAuthOn {
@@ -345,6 +435,15 @@ func (t *App) readyHandler(sm *services.Manager) http.HandlerFunc {
 			}
 		}
 
+		// Distributor has a special check that makes sure receivers are running and ingesters are reachable
+		// so load balancers stop routing traffic to a broken instance
+		if t.distributor != nil {
+			if err := t.distributor.CheckReady(r.Context()); err != nil {
+				http.Error(w, "Distributor not ready: "+err.Error(), http.StatusServiceUnavailable)
+				return
+			}
+		}
+
 		// Query Frontend has a special check that makes sure that a querier is attached before it signals
 		// itself as ready
 		if t.frontend != nil {
@@ -354,6 +453,14 @@ func (t *App) readyHandler(sm *services.Manager) http.HandlerFunc {
 			}
 		}
 
+		// with a separate scheduler the frontend is ready once it is connected to a query-scheduler
+		if t.frontendV2 != nil {
+			if err := t.frontendV2.CheckReady(r.Context()); err != nil {
+				http.Error(w, "Query Frontend not ready: "+err.Error(), http.StatusServiceUnavailable)
+				return
+			}
+		}
+
 		http.Error(w, "ready", http.StatusOK)
 	}
 }
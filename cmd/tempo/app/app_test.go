@@ -0,0 +1,92 @@
+package app
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckGRPCMessageSizes(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      func(c *Config)
+		expected []string
+	}{
+		{
+			name: "defaults",
+			cfg:  func(c *Config) {},
+			expected: []string{
+				"ingester_client.grpc_client_config.max_send_msg_size (16777216) > server.grpc_server_max_recv_msg_size (4194304)",
+				"querier.frontend_worker.grpc_client_config.max_send_msg_size (16777216) > server.grpc_server_max_recv_msg_size (4194304)",
+				"overrides.max_bytes_per_trace (5000000) > server.grpc_server_max_send_msg_size (4194304)",
+			},
+		},
+		{
+			name: "matching sizes",
+			cfg:  withServerMsgSizes(16<<20, 16<<20),
+		},
+		{
+			name: "server sends more than the ingester client receives",
+			cfg: func(c *Config) {
+				withServerMsgSizes(16<<20, 16<<20)(c)
+				c.IngesterClient.GRPCClientConfig.MaxRecvMsgSize = 8 << 20
+			},
+			expected: []string{
+				"server.grpc_server_max_send_msg_size (16777216) > ingester_client.grpc_client_config.max_recv_msg_size (8388608)",
+			},
+		},
+		{
+			name: "traces larger than a message",
+			cfg: func(c *Config) {
+				withServerMsgSizes(16<<20, 16<<20)(c)
+				c.LimitsConfig.MaxBytesPerTrace = 32 << 20
+			},
+			expected: []string{
+				"overrides.max_bytes_per_trace (33554432) > ingester_client.grpc_client_config.max_send_msg_size (16777216)",
+				"overrides.max_bytes_per_trace (33554432) > server.grpc_server_max_send_msg_size (16777216)",
+			},
+		},
+		{
+			name: "unlimited traces",
+			cfg: func(c *Config) {
+				withServerMsgSizes(16<<20, 16<<20)(c)
+				c.LimitsConfig.MaxBytesPerTrace = 0
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Config{}
+			c.RegisterFlagsAndApplyDefaults("", flag.NewFlagSet("", flag.PanicOnError))
+			tt.cfg(c)
+
+			var actual []string
+			for _, w := range c.checkGRPCMessageSizes() {
+				assert.NotEmpty(t, w.Explain)
+				actual = append(actual, w.Message)
+			}
+			assert.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestCheckConfig(t *testing.T) {
+	c := &Config{}
+	c.RegisterFlagsAndApplyDefaults("", flag.NewFlagSet("", flag.PanicOnError))
+
+	// the message size warnings are returned with the others
+	var messages []string
+	for _, w := range c.CheckConfig() {
+		messages = append(messages, w.Message)
+	}
+	assert.Contains(t, messages, "ingester_client.grpc_client_config.max_send_msg_size (16777216) > server.grpc_server_max_recv_msg_size (4194304)")
+}
+
+func withServerMsgSizes(maxRecv, maxSend int) func(c *Config) {
+	return func(c *Config) {
+		c.Server.GPRCServerMaxRecvMsgSize = maxRecv
+		c.Server.GRPCServerMaxSendMsgSize = maxSend
+	}
+}
@@ -0,0 +1,148 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"gopkg.in/yaml.v3"
+)
+
+var metricDeprecatedFieldsInUse = promauto.NewGauge(prometheus.GaugeOpts{
+	Namespace: metricsNamespace,
+	Name:      "config_deprecated_fields_in_use",
+	Help:      "The number of deprecated configuration fields set in the loaded config file.",
+})
+
+// DeprecatedField describes a config field that has been renamed. Paths are dot separated yaml keys.
+type DeprecatedField struct {
+	Path           string
+	Replacement    string
+	RemovalVersion string
+}
+
+// deprecatedFields is the registry of renamed config fields. Add an entry here whenever a field
+// is renamed so that old configs continue to work for at least one release. Only register fields
+// that were moved or renamed without changing their meaning or unit, TestDeprecatedFields checks
+// that every entry maps to a field of the current config.
+var deprecatedFields []DeprecatedField
+
+// DeprecationWarning is returned for every deprecated field found in a config file.
+type DeprecationWarning struct {
+	DeprecatedField
+
+	// Ignored is true if the replacement field was also set. In this case the replacement wins.
+	Ignored bool
+}
+
+func (w DeprecationWarning) String() string {
+	if w.Ignored {
+		return fmt.Sprintf("%s is deprecated and ignored because %s is also set. it will be removed in %s", w.Path, w.Replacement, w.RemovalVersion)
+	}
+	return fmt.Sprintf("%s is deprecated, use %s instead. it will be removed in %s", w.Path, w.Replacement, w.RemovalVersion)
+}
+
+// MigrateDeprecatedConfig rewrites deprecated fields in the yaml config to their replacement location
+// and returns a warning for each one. If no deprecated fields are present the input is returned unchanged.
+func MigrateDeprecatedConfig(buff []byte) ([]byte, []DeprecationWarning, error) {
+	out, warnings, err := migrateDeprecatedFields(buff, deprecatedFields)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	metricDeprecatedFieldsInUse.Set(float64(len(warnings)))
+	return out, warnings, nil
+}
+
+func migrateDeprecatedFields(buff []byte, fields []DeprecatedField) ([]byte, []DeprecationWarning, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(buff, &doc); err != nil {
+		return nil, nil, err
+	}
+
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return buff, nil, nil
+	}
+	root := doc.Content[0]
+
+	var warnings []DeprecationWarning
+	for _, f := range fields {
+		v := removeNode(root, strings.Split(f.Path, "."))
+		if v == nil {
+			continue
+		}
+
+		replaced := setNode(root, strings.Split(f.Replacement, "."), v)
+		warnings = append(warnings, DeprecationWarning{
+			DeprecatedField: f,
+			Ignored:         !replaced,
+		})
+	}
+
+	if len(warnings) == 0 {
+		return buff, nil, nil
+	}
+
+	out, err := yaml.Marshal(&doc)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return out, warnings, nil
+}
+
+// removeNode removes the key at path from the mapping node and returns its value. It returns nil
+// if the path does not exist.
+func removeNode(m *yaml.Node, path []string) *yaml.Node {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value != path[0] {
+			continue
+		}
+
+		v := m.Content[i+1]
+		if len(path) == 1 {
+			m.Content = append(m.Content[:i], m.Content[i+2:]...)
+			return v
+		}
+
+		if v.Kind != yaml.MappingNode {
+			return nil
+		}
+		return removeNode(v, path[1:])
+	}
+
+	return nil
+}
+
+// setNode sets the value at path in the mapping node creating intermediate mappings as necessary.
+// If the path already exists it is left unchanged and false is returned.
+func setNode(m *yaml.Node, path []string, v *yaml.Node) bool {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value != path[0] {
+			continue
+		}
+
+		if len(path) == 1 {
+			return false
+		}
+
+		child := m.Content[i+1]
+		if child.Kind != yaml.MappingNode {
+			// the parent is explicitly null or a scalar. replace it with a mapping
+			child = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			m.Content[i+1] = child
+		}
+		return setNode(child, path[1:], v)
+	}
+
+	key := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: path[0]}
+	if len(path) == 1 {
+		m.Content = append(m.Content, key, v)
+		return true
+	}
+
+	child := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	m.Content = append(m.Content, key, child)
+	return setNode(child, path[1:], v)
+}
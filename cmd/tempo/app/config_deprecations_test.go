@@ -0,0 +1,160 @@
+package app
+
+import (
+	"bytes"
+	"flag"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+// testDeprecatedFields are representative renames of the kinds the registry supports.
+var testDeprecatedFields = []DeprecatedField{
+	{Path: "old_top_level", Replacement: "new_top_level", RemovalVersion: "v2.0"},
+	{Path: "storage.trace.old_poll", Replacement: "storage.trace.blocklist_poll", RemovalVersion: "v2.0"},
+	{Path: "storage.trace.block.old_bloom_filter_false_positive", Replacement: "storage.trace.block.bloom_filter_false_positive", RemovalVersion: "v2.0"},
+	{Path: "compactor.compaction.old_block_retention", Replacement: "compactor.compaction.block_retention", RemovalVersion: "v2.0"},
+}
+
+func TestMigrateDeprecatedFields(t *testing.T) {
+	tests := []struct {
+		name             string
+		in               string
+		expected         string
+		expectedWarnings []DeprecationWarning
+	}{
+		{
+			name:     "empty",
+			in:       ``,
+			expected: ``,
+		},
+		{
+			name: "no deprecated fields",
+			in: `
+storage:
+  trace:
+    blocklist_poll: 5m
+`,
+			expected: `
+storage:
+  trace:
+    blocklist_poll: 5m
+`,
+		},
+		{
+			name: "top level rename",
+			in: `
+old_top_level: true
+`,
+			expected: `
+new_top_level: true
+`,
+			expectedWarnings: []DeprecationWarning{
+				{DeprecatedField: testDeprecatedFields[0]},
+			},
+		},
+		{
+			name: "nested rename into existing parent",
+			in: `
+storage:
+  trace:
+    backend: local
+    old_poll: 1m
+`,
+			expected: `
+storage:
+  trace:
+    backend: local
+    blocklist_poll: 1m
+`,
+			expectedWarnings: []DeprecationWarning{
+				{DeprecatedField: testDeprecatedFields[1]},
+			},
+		},
+		{
+			name: "nested rename creates missing parents",
+			in: `
+compactor:
+  compaction:
+    old_block_retention: 1h
+`,
+			expected: `
+compactor:
+  compaction:
+    block_retention: 1h
+`,
+			expectedWarnings: []DeprecationWarning{
+				{DeprecatedField: testDeprecatedFields[3]},
+			},
+		},
+		{
+			name: "replacement already set wins",
+			in: `
+storage:
+  trace:
+    block:
+      old_bloom_filter_false_positive: 0.1
+      bloom_filter_false_positive: 0.2
+`,
+			expected: `
+storage:
+  trace:
+    block:
+      bloom_filter_false_positive: 0.2
+`,
+			expectedWarnings: []DeprecationWarning{
+				{DeprecatedField: testDeprecatedFields[2], Ignored: true},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			out, warnings, err := migrateDeprecatedFields([]byte(tc.in), testDeprecatedFields)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedWarnings, warnings)
+
+			var actual, expected interface{}
+			require.NoError(t, yaml.Unmarshal(out, &actual))
+			require.NoError(t, yaml.Unmarshal([]byte(tc.expected), &expected))
+			assert.Equal(t, expected, actual)
+		})
+	}
+}
+
+func TestDeprecatedFields(t *testing.T) {
+	for _, f := range deprecatedFields {
+		t.Run(f.Path, func(t *testing.T) {
+			cfg := &Config{}
+			cfg.RegisterFlagsAndApplyDefaults("", flag.NewFlagSet("", flag.PanicOnError))
+			buff, err := yaml.Marshal(cfg)
+			require.NoError(t, err)
+
+			var defaults yaml.Node
+			require.NoError(t, yaml.Unmarshal(buff, &defaults))
+			root := defaults.Content[0]
+
+			// the old field is gone and the replacement is a field of the current config
+			assert.Nil(t, removeNode(root, strings.Split(f.Path, ".")), "%s is still a config field", f.Path)
+			v := removeNode(root, strings.Split(f.Replacement, "."))
+			require.NotNil(t, v, "%s is not a config field", f.Replacement)
+
+			// a config that sets the old field loads
+			old := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			require.True(t, setNode(old, strings.Split(f.Path, "."), v))
+			buff, err = yaml.Marshal(old)
+			require.NoError(t, err)
+
+			buff, warnings, err := migrateDeprecatedFields(buff, []DeprecatedField{f})
+			require.NoError(t, err)
+			assert.Equal(t, []DeprecationWarning{{DeprecatedField: f}}, warnings)
+
+			dec := yaml.NewDecoder(bytes.NewReader(buff))
+			dec.KnownFields(true)
+			require.NoError(t, dec.Decode(&Config{}))
+		})
+	}
+}
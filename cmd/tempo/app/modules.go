@@ -1,6 +1,7 @@
 package app
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"path"
@@ -9,9 +10,13 @@ import (
 	cortex_frontend "github.com/cortexproject/cortex/pkg/frontend"
 	cortex_transport "github.com/cortexproject/cortex/pkg/frontend/transport"
 	cortex_frontend_v1pb "github.com/cortexproject/cortex/pkg/frontend/v1/frontendv1pb"
+	cortex_frontend_v2pb "github.com/cortexproject/cortex/pkg/frontend/v2/frontendv2pb"
 	"github.com/cortexproject/cortex/pkg/ring"
+	"github.com/cortexproject/cortex/pkg/scheduler"
+	"github.com/cortexproject/cortex/pkg/scheduler/schedulerpb"
 	"github.com/cortexproject/cortex/pkg/util/log"
 	"github.com/go-kit/kit/log/level"
+	"github.com/grafana/dskit/backoff"
 	"github.com/grafana/dskit/kv/codec"
 	"github.com/grafana/dskit/kv/memberlist"
 	"github.com/grafana/dskit/modules"
@@ -28,31 +33,42 @@ import (
 	"github.com/grafana/tempo/modules/overrides"
 	"github.com/grafana/tempo/modules/querier"
 	tempo_storage "github.com/grafana/tempo/modules/storage"
+	"github.com/grafana/tempo/pkg/audit"
 	tempo_ring "github.com/grafana/tempo/pkg/ring"
 	"github.com/grafana/tempo/pkg/tempopb"
+	"github.com/grafana/tempo/tempodb"
+	"github.com/grafana/tempo/tempodb/backend"
 )
 
 // The various modules that make up tempo.
 const (
-	Ring          string = "ring"
-	Overrides     string = "overrides"
-	Server        string = "server"
-	Distributor   string = "distributor"
-	Ingester      string = "ingester"
-	Querier       string = "querier"
-	QueryFrontend string = "query-frontend"
-	Compactor     string = "compactor"
-	Store         string = "store"
-	MemberlistKV  string = "memberlist-kv"
-	All           string = "all"
+	Ring           string = "ring"
+	Overrides      string = "overrides"
+	Server         string = "server"
+	Distributor    string = "distributor"
+	Ingester       string = "ingester"
+	Querier        string = "querier"
+	QueryFrontend  string = "query-frontend"
+	QueryScheduler string = "query-scheduler"
+	Compactor      string = "compactor"
+	Store          string = "store"
+	MemberlistKV   string = "memberlist-kv"
+	Audit          string = "audit"
+	All            string = "all"
 )
 
 const (
 	apiPathTraces          string = "/api/traces/{traceID}"
+	apiPathTracesBatchGet  string = "/api/traces:batchGet"
 	apiPathSearch          string = "/api/search"
 	apiPathSearchTags      string = "/api/search/tags"
 	apiPathSearchTagValues string = "/api/search/tag/{tagName}/values"
 	apiPathEcho            string = "/api/echo"
+	apiPathQuery           string = "/api/queries/{" + frontend.QueryIDVar + "}"
+	apiPathTopServices     string = "/api/debug/top-services"
+	apiPathDeleteTrace     string = "/api/admin/traces/{traceID}"
+	apiPathFlushBlocklist  string = "/flush-blocklist"
+	apiPathAudit           string = "/audit"
 )
 
 func (t *App) initServer() (services.Service, error) {
@@ -84,14 +100,14 @@ func (t *App) initServer() (services.Service, error) {
 }
 
 func (t *App) initRing() (services.Service, error) {
-	ring, err := tempo_ring.New(t.cfg.Ingester.LifecyclerConfig.RingConfig, "ingester", t.cfg.Ingester.OverrideRingKey, prometheus.DefaultRegisterer)
+	ring, err := tempo_ring.New(t.cfg.Ingester.LifecyclerConfig.RingConfig, "ingester", t.cfg.Ingester.OverrideRingKey, t.cfg.Distributor.IngesterRingKVOutageGracePeriod, prometheus.DefaultRegisterer)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create ring %w", err)
 	}
 	t.ring = ring
 
 	prometheus.MustRegister(t.ring)
-	t.Server.HTTP.Handle("/ingester/ring", t.ring)
+	t.Server.HTTP.Handle("/ingester/ring", t.audit.WrapMutating("ingester.ring", t.ring))
 
 	return t.ring, nil
 }
@@ -107,8 +123,13 @@ func (t *App) initOverrides() (services.Service, error) {
 }
 
 func (t *App) initDistributor() (services.Service, error) {
+	canaryRings, err := distributor.NewCanaryRings(t.cfg.Distributor.Canary, t.cfg.Ingester.LifecyclerConfig.RingConfig, t.cfg.Ingester.OverrideRingKey, t.cfg.Distributor.IngesterRingKVOutageGracePeriod, prometheus.DefaultRegisterer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create canary rings %w", err)
+	}
+
 	// todo: make ingester client a module instead of passing the config everywhere
-	distributor, err := distributor.New(t.cfg.Distributor, t.cfg.IngesterClient, t.ring, t.overrides, t.cfg.MultitenancyIsEnabled(), t.cfg.Server.LogLevel, t.cfg.SearchEnabled)
+	distributor, err := distributor.New(t.cfg.Distributor, t.cfg.IngesterClient, t.ring, canaryRings, t.overrides, t.cfg.MultitenancyIsEnabled(), t.cfg.Server.LogLevel, t.cfg.SearchEnabled)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create distributor %w", err)
 	}
@@ -116,13 +137,19 @@ func (t *App) initDistributor() (services.Service, error) {
 
 	if distributor.DistributorRing != nil {
 		prometheus.MustRegister(distributor.DistributorRing)
-		t.Server.HTTP.Handle("/distributor/ring", distributor.DistributorRing)
+		t.Server.HTTP.Handle("/distributor/ring", t.audit.WrapMutating("distributor.ring", distributor.DistributorRing))
 	}
 
+	t.Server.HTTP.Handle(addHTTPAPIPrefix(&t.cfg, apiPathTopServices), http.HandlerFunc(t.distributor.TopServicesHandler))
+
 	return t.distributor, nil
 }
 
 func (t *App) initIngester() (services.Service, error) {
+	if err := t.cfg.Ingester.Validate(t.cfg.StorageConfig.Trace.Block); err != nil {
+		return nil, err
+	}
+
 	t.cfg.Ingester.LifecyclerConfig.ListenPort = t.cfg.Server.GRPCListenPort
 	ingester, err := ingester.New(t.cfg.Ingester, t.store, t.overrides)
 	if err != nil {
@@ -132,21 +159,33 @@ func (t *App) initIngester() (services.Service, error) {
 
 	tempopb.RegisterPusherServer(t.Server.GRPC, t.ingester)
 	tempopb.RegisterQuerierServer(t.Server.GRPC, t.ingester)
-	t.Server.HTTP.Path("/flush").Handler(http.HandlerFunc(t.ingester.FlushHandler))
-	t.Server.HTTP.Path("/shutdown").Handler(http.HandlerFunc(t.ingester.ShutdownHandler))
+	t.Server.HTTP.Path("/flush").Handler(t.audit.Wrap("ingester.flush", http.HandlerFunc(t.ingester.FlushHandler)))
+	t.Server.HTTP.Path("/shutdown").Handler(t.audit.Wrap("ingester.shutdown", http.HandlerFunc(t.ingester.ShutdownHandler)))
+
+	if t.cfg.SearchEnabled {
+		t.Server.HTTP.Handle(path.Join("/ingester", addHTTPAPIPrefix(&t.cfg, apiPathSearchTags)), t.HTTPAuthMiddleware.Wrap(http.HandlerFunc(t.ingester.SearchTagsHandler)))
+		t.Server.HTTP.Handle(path.Join("/ingester", addHTTPAPIPrefix(&t.cfg, apiPathSearchTagValues)), t.HTTPAuthMiddleware.Wrap(http.HandlerFunc(t.ingester.SearchTagValuesHandler)))
+	}
 	return t.ingester, nil
 }
 
 func (t *App) initQuerier() (services.Service, error) {
 	// validate worker config
-	// if we're not in single binary mode and worker address is not specified - bail
-	if t.cfg.Target != All && t.cfg.Querier.Worker.FrontendAddress == "" {
-		return nil, fmt.Errorf("frontend worker address not specified")
+	// if we're not in single binary mode and neither a frontend nor a scheduler address is specified - bail
+	workerAddressEmpty := t.cfg.Querier.Worker.FrontendAddress == "" && t.cfg.Querier.Worker.SchedulerAddress == ""
+	if t.cfg.Target != All && workerAddressEmpty {
+		return nil, fmt.Errorf("frontend or scheduler worker address not specified")
 	} else if t.cfg.Target == All {
 		// if we're in single binary mode with no worker address specified, register default endpoint
-		if t.cfg.Querier.Worker.FrontendAddress == "" {
-			t.cfg.Querier.Worker.FrontendAddress = fmt.Sprintf("127.0.0.1:%d", t.cfg.Server.GRPCListenPort)
-			level.Warn(log.Logger).Log("msg", "Worker address is empty in single binary mode.  Attempting automatic worker configuration.  If queries are unresponsive consider configuring the worker explicitly.", "address", t.cfg.Querier.Worker.FrontendAddress)
+		if workerAddressEmpty {
+			address := fmt.Sprintf("127.0.0.1:%d", t.cfg.Server.GRPCListenPort)
+			// with the v2 protocol the queriers pull from the scheduler embedded in the frontend
+			if t.cfg.Frontend.Protocol == frontend.ProtocolV2 {
+				t.cfg.Querier.Worker.SchedulerAddress = address
+			} else {
+				t.cfg.Querier.Worker.FrontendAddress = address
+			}
+			level.Warn(log.Logger).Log("msg", "Worker address is empty in single binary mode.  Attempting automatic worker configuration.  If queries are unresponsive consider configuring the worker explicitly.", "address", address)
 		}
 	}
 
@@ -167,6 +206,21 @@ func (t *App) initQuerier() (services.Service, error) {
 	tracesHandler := middleware.Wrap(http.HandlerFunc(t.querier.TraceByIDHandler))
 	t.Server.HTTP.Handle(path.Join("/querier", addHTTPAPIPrefix(&t.cfg, apiPathTraces)), tracesHandler)
 
+	// batches are served by the queriers only, the frontend would shard them like single trace by id queries
+	tracesBatchHandler := middleware.Wrap(http.HandlerFunc(t.querier.TraceByIDBatchHandler))
+	t.Server.HTTP.Handle(path.Join("/querier", addHTTPAPIPrefix(&t.cfg, apiPathTracesBatchGet)), tracesBatchHandler).Methods(http.MethodPost)
+
+	if t.cfg.Querier.TraceDeletionEnabled {
+		deleteTraceHandler := middleware.Wrap(t.audit.Wrap("querier.delete_trace", http.HandlerFunc(t.querier.DeleteTraceHandler)))
+		t.Server.HTTP.Handle(path.Join("/querier", addHTTPAPIPrefix(&t.cfg, apiPathDeleteTrace)), deleteTraceHandler).Methods(http.MethodDelete)
+	}
+
+	// the querier only polls the blocklist outside of the single binary, there the compactor serves the endpoint
+	if t.cfg.Querier.BlocklistFlushEnabled && enablePolling {
+		flushBlocklistHandler := t.audit.Wrap("querier.flush_blocklist", tempo_storage.FlushBlocklistHandler(t.store))
+		t.Server.HTTP.Handle(apiPathFlushBlocklist, flushBlocklistHandler).Methods(http.MethodPost)
+	}
+
 	if t.cfg.SearchEnabled {
 		searchHandler := middleware.Wrap(http.HandlerFunc(t.querier.SearchHandler))
 		t.Server.HTTP.Handle(path.Join("/querier", addHTTPAPIPrefix(&t.cfg, apiPathSearch)), searchHandler)
@@ -186,41 +240,135 @@ func (t *App) initQueryFrontend() (services.Service, error) {
 		return nil, fmt.Errorf("frontend query shards should be between %d and %d (both inclusive)", frontend.MinQueryShards, frontend.MaxQueryShards)
 	}
 
-	cortexTripper, v1, _, err := cortex_frontend.InitFrontend(t.cfg.Frontend.Config, frontend.CortexNoQuerierLimits{}, 0, log.Logger, prometheus.DefaultRegisterer)
+	// federated queries read the traces of other tenants, they need both multitenancy and the explicit flag
+	t.cfg.Frontend.Federation.Enabled = t.cfg.MultitenancyIsEnabled() && t.cfg.MultitenancyFederationEnabled
+	if t.cfg.MultitenancyFederationEnabled && !t.cfg.MultitenancyIsEnabled() {
+		level.Warn(log.Logger).Log("msg", "multitenancy.federation-enabled is ignored, multitenancy is disabled")
+	}
+	if err := t.cfg.Frontend.Validate(); err != nil {
+		return nil, err
+	}
+
+	// the queues are embedded in the frontend unless a scheduler address is configured, in which case requests are
+	// enqueued with the query-schedulers and queriers pull them from there. with the v2 protocol the frontend embeds
+	// a query-scheduler and enqueues its requests there.
+	frontendCfg := t.cfg.Frontend.Config
+	var embeddedScheduler *scheduler.Scheduler
+	if t.cfg.Frontend.Protocol == frontend.ProtocolV2 {
+		schedulerCfg := t.cfg.QueryScheduler
+		schedulerCfg.MaxOutstandingPerTenant = frontendCfg.FrontendV1.MaxOutstandingPerTenant
+		s, err := scheduler.NewScheduler(schedulerCfg, frontend.CortexNoQuerierLimits{}, log.Logger, prometheus.DefaultRegisterer)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create embedded query scheduler %w", err)
+		}
+		embeddedScheduler = s
+
+		schedulerpb.RegisterSchedulerForFrontendServer(t.Server.GRPC, s)
+		schedulerpb.RegisterSchedulerForQuerierServer(t.Server.GRPC, s)
+		frontendCfg.FrontendV2.SchedulerAddress = fmt.Sprintf("127.0.0.1:%d", t.cfg.Server.GRPCListenPort)
+	}
+
+	cortexTripper, v1, v2, err := cortex_frontend.InitFrontend(frontendCfg, frontend.CortexNoQuerierLimits{}, t.cfg.Server.GRPCListenPort, log.Logger, prometheus.DefaultRegisterer)
 	if err != nil {
 		return nil, err
 	}
-	t.frontend = v1
+	cortexTripper = frontend.MaxJobSizeWare(t.cfg.Frontend.MaxJobSize)(cortexTripper)
+	if len(t.cfg.Frontend.DownstreamRoutes) > 0 {
+		backoffCfg := backoff.Config{MinBackoff: t.cfg.Frontend.RetryMinBackoff, MaxBackoff: t.cfg.Frontend.RetryMaxBackoff}
+		cortexTripper, err = frontend.NewDownstreamRouter(t.cfg.HTTPAPIPrefix, t.cfg.Frontend.DownstreamRoutes, cortexTripper, backoffCfg, prometheus.DefaultRegisterer)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-	tripperware, err := frontend.NewTripperware(t.cfg.Frontend, t.cfg.HTTPAPIPrefix, log.Logger, prometheus.DefaultRegisterer)
+	tripperwares, err := frontend.NewTripperware(t.cfg.Frontend, t.cfg.HTTPAPIPrefix, t.overrides, t.audit, log.Logger, prometheus.DefaultRegisterer)
 	if err != nil {
 		return nil, err
 	}
-	roundTripper := tripperware(cortexTripper)
+	roundTripper := tripperwares.Queries(cortexTripper)
 
-	frontendHandler := cortex_transport.NewHandler(t.cfg.Frontend.Config.Handler, roundTripper, log.Logger, prometheus.DefaultRegisterer)
+	cortexHandler := cortex_transport.NewHandler(t.cfg.Frontend.Config.Handler, roundTripper, log.Logger, prometheus.DefaultRegisterer)
 
-	frontendHandler = middleware.Merge(
+	// every query gets an id it can be cancelled with while it's in flight, through any of the frontends
+	queries := frontend.NewQueryRegistry(t.cfg.Frontend.QueryCancelPeers, prometheus.DefaultRegisterer)
+
+	frontendHandler := middleware.Merge(
 		t.HTTPAuthMiddleware,
-	).Wrap(frontendHandler)
+	).Wrap(queries.Wrap(cortexHandler))
 
-	// register grpc server for queriers to connect to
-	cortex_frontend_v1pb.RegisterFrontendServer(t.Server.GRPC, t.frontend)
+	// searches that accept text/event-stream or application/x-ndjson are streamed shard by shard, the queues can only
+	// return whole responses. both share the per tenant limits and queues of the other queries
+	searchHandler := middleware.Merge(
+		t.HTTPAuthMiddleware,
+	).Wrap(queries.Wrap(frontend.NewSearchStreamingHandler(t.cfg.Frontend, cortexHandler, cortexTripper, tripperwares, log.Logger)))
+
+	// register grpc server for queriers to connect to, or to return their results to
+	var frontendService services.Service
+	if v2 != nil {
+		t.frontendV2 = v2
+		frontendService = v2
+		cortex_frontend_v2pb.RegisterFrontendForQuerierServer(t.Server.GRPC, v2)
+	} else {
+		t.frontend = v1
+		frontendService = v1
+		cortex_frontend_v1pb.RegisterFrontendServer(t.Server.GRPC, v1)
+	}
+
+	// the embedded scheduler runs as long as the frontend that enqueues with it
+	if embeddedScheduler != nil {
+		frontendService = withEmbeddedScheduler(embeddedScheduler, frontendService)
+	}
 
 	// http query endpoint
 	t.Server.HTTP.Handle(addHTTPAPIPrefix(&t.cfg, apiPathTraces), frontendHandler)
 
 	// http search endpoints
 	if t.cfg.SearchEnabled {
-		t.Server.HTTP.Handle(addHTTPAPIPrefix(&t.cfg, apiPathSearch), frontendHandler)
+		t.Server.HTTP.Handle(addHTTPAPIPrefix(&t.cfg, apiPathSearch), searchHandler)
 		t.Server.HTTP.Handle(addHTTPAPIPrefix(&t.cfg, apiPathSearchTags), frontendHandler)
 		t.Server.HTTP.Handle(addHTTPAPIPrefix(&t.cfg, apiPathSearchTagValues), frontendHandler)
 	}
 
+	// http query cancel endpoint
+	cancelHandler := middleware.Merge(
+		t.HTTPAuthMiddleware,
+	).Wrap(http.HandlerFunc(queries.CancelHandler))
+	t.Server.HTTP.Handle(addHTTPAPIPrefix(&t.cfg, apiPathQuery), cancelHandler).Methods(http.MethodDelete)
+
 	// http query echo endpoint
 	t.Server.HTTP.Handle(addHTTPAPIPrefix(&t.cfg, apiPathEcho), echoHandler())
 
-	return t.frontend, nil
+	return frontendService, nil
+}
+
+// withEmbeddedScheduler returns a service that starts the scheduler before the frontend and stops it after the
+// frontend.
+func withEmbeddedScheduler(s *scheduler.Scheduler, frontendService services.Service) services.Service {
+	return services.NewIdleService(func(ctx context.Context) error {
+		if err := services.StartAndAwaitRunning(ctx, s); err != nil {
+			return err
+		}
+		return services.StartAndAwaitRunning(ctx, frontendService)
+	}, func(_ error) error {
+		err := services.StopAndAwaitTerminated(context.Background(), frontendService)
+		if schedulerErr := services.StopAndAwaitTerminated(context.Background(), s); err == nil {
+			err = schedulerErr
+		}
+		return err
+	})
+}
+
+func (t *App) initQueryScheduler() (services.Service, error) {
+	s, err := scheduler.NewScheduler(t.cfg.QueryScheduler, frontend.CortexNoQuerierLimits{}, log.Logger, prometheus.DefaultRegisterer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create query scheduler %w", err)
+	}
+	t.queryScheduler = s
+
+	schedulerpb.RegisterSchedulerForFrontendServer(t.Server.GRPC, s)
+	schedulerpb.RegisterSchedulerForQuerierServer(t.Server.GRPC, s)
+
+	return s, nil
 }
 
 func (t *App) initCompactor() (services.Service, error) {
@@ -232,7 +380,12 @@ func (t *App) initCompactor() (services.Service, error) {
 
 	if t.compactor.Ring != nil {
 		prometheus.MustRegister(t.compactor.Ring)
-		t.Server.HTTP.Handle("/compactor/ring", t.compactor.Ring)
+		t.Server.HTTP.Handle("/compactor/ring", t.audit.WrapMutating("compactor.ring", t.compactor.Ring))
+	}
+
+	if t.cfg.Compactor.BlocklistFlushEnabled {
+		flushBlocklistHandler := t.audit.Wrap("compactor.flush_blocklist", tempo_storage.FlushBlocklistHandler(t.store))
+		t.Server.HTTP.Handle(apiPathFlushBlocklist, flushBlocklistHandler).Methods(http.MethodPost)
 	}
 
 	return t.compactor, nil
@@ -248,6 +401,36 @@ func (t *App) initStore() (services.Service, error) {
 	return t.store, nil
 }
 
+func (t *App) initAudit() (services.Service, error) {
+	var r backend.RawReader
+	var w backend.RawWriter
+	if t.cfg.Audit.Enabled && t.cfg.Audit.Store == audit.StoreBackend {
+		var err error
+		r, w, _, err = tempodb.NewBackend(&t.cfg.StorageConfig.Trace)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create audit backend %w", err)
+		}
+	}
+
+	logger, err := audit.New(&t.cfg.Audit, r, w, t.cfg.Ingester.LifecyclerConfig.ID, log.Logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create audit logger %w", err)
+	}
+	if logger == nil {
+		return nil, nil
+	}
+	t.audit = logger
+
+	// the audit log records actions of all tenants and is served next to the other admin pages, outside of the
+	// tenant api
+	t.Server.HTTP.Handle(apiPathAudit, http.HandlerFunc(t.audit.Handler))
+
+	return services.NewIdleService(nil, func(_ error) error {
+		t.audit.Shutdown()
+		return nil
+	}), nil
+}
+
 func (t *App) initMemberlistKV() (services.Service, error) {
 	reg := prometheus.DefaultRegisterer
 	t.cfg.MemberlistKV.MetricsRegisterer = reg
@@ -287,22 +470,26 @@ func (t *App) setupModuleManager() error {
 	mm.RegisterModule(Ingester, t.initIngester)
 	mm.RegisterModule(Querier, t.initQuerier)
 	mm.RegisterModule(QueryFrontend, t.initQueryFrontend)
+	mm.RegisterModule(QueryScheduler, t.initQueryScheduler)
 	mm.RegisterModule(Compactor, t.initCompactor)
 	mm.RegisterModule(Store, t.initStore, modules.UserInvisibleModule)
+	mm.RegisterModule(Audit, t.initAudit, modules.UserInvisibleModule)
 	mm.RegisterModule(All, nil)
 
 	deps := map[string][]string{
 		// Server:       nil,
 		// Store:        nil,
-		Overrides:     {Server},
-		MemberlistKV:  {Server},
-		QueryFrontend: {Server},
-		Ring:          {Server, MemberlistKV},
-		Distributor:   {Ring, Server, Overrides},
-		Ingester:      {Store, Server, Overrides, MemberlistKV},
-		Querier:       {Store, Ring},
-		Compactor:     {Store, Server, Overrides, MemberlistKV},
-		All:           {Compactor, QueryFrontend, Querier, Ingester, Distributor},
+		Overrides:      {Server},
+		MemberlistKV:   {Server},
+		Audit:          {Server},
+		QueryFrontend:  {Server, Overrides, Audit},
+		QueryScheduler: {Server},
+		Ring:           {Server, MemberlistKV, Audit},
+		Distributor:    {Ring, Server, Overrides, Audit},
+		Ingester:       {Store, Server, Overrides, MemberlistKV, Audit},
+		Querier:        {Store, Ring, Audit},
+		Compactor:      {Store, Server, Overrides, MemberlistKV, Audit},
+		All:            {Compactor, QueryFrontend, Querier, Ingester, Distributor},
 	}
 
 	for mod, targets := range deps {
@@ -0,0 +1,183 @@
+package app
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	cortex_worker "github.com/cortexproject/cortex/pkg/querier/worker"
+	"github.com/go-kit/kit/log"
+	"github.com/grafana/dskit/services"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	httpgrpc_server "github.com/weaveworks/common/httpgrpc/server"
+	"go.uber.org/atomic"
+)
+
+// TestQuerySchedulerSeparation runs a query-scheduler, a query-frontend and a querier worker in process and checks
+// that a query received by the frontend is queued in the scheduler and answered by the querier.
+func TestQuerySchedulerSeparation(t *testing.T) {
+	scheduler, startScheduler := newTestApp(t, func(cfg *Config) {})
+	startTestService(t, scheduler.initQueryScheduler)
+	startScheduler()
+	schedulerAddress := fmt.Sprintf("127.0.0.1:%d", scheduler.cfg.Server.GRPCListenPort)
+
+	frontend, startFrontend := newTestApp(t, func(cfg *Config) {
+		cfg.Frontend.Config.FrontendV2.SchedulerAddress = schedulerAddress
+		cfg.Frontend.Config.FrontendV2.Addr = "127.0.0.1"
+	})
+	startTestService(t, frontend.initOverrides)
+	startTestService(t, frontend.initQueryFrontend)
+	startFrontend()
+	require.Nil(t, frontend.frontend)
+	require.NotNil(t, frontend.frontendV2)
+
+	requests := atomic.NewInt32(0)
+	querierHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Inc()
+		http.Error(w, "not found", http.StatusNotFound)
+	})
+
+	workerCfg := frontend.cfg.Querier.Worker
+	workerCfg.SchedulerAddress = schedulerAddress
+	workerCfg.MaxConcurrentRequests = frontend.cfg.Querier.MaxConcurrentQueries
+	worker, err := cortex_worker.NewQuerierWorker(workerCfg, httpgrpc_server.NewServer(querierHandler), log.NewNopLogger(), nil)
+	require.NoError(t, err)
+	startTestService(t, func() (services.Service, error) { return worker, nil })
+
+	require.Eventually(t, func() bool {
+		return frontend.frontendV2.CheckReady(context.Background()) == nil
+	}, 10*time.Second, 10*time.Millisecond)
+
+	res, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d/api/traces/1234", frontend.cfg.Server.HTTPListenPort))
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	assert.Equal(t, int32(frontend.cfg.Frontend.QueryShards), requests.Load())
+}
+
+// TestQueryFrontendProtocolV2 runs a query-frontend with the v2 protocol and a querier worker in process and checks
+// that the querier pulls the requests from the scheduler embedded in the frontend.
+func TestQueryFrontendProtocolV2(t *testing.T) {
+	frontend, startFrontend := newTestApp(t, func(cfg *Config) {
+		cfg.Frontend.Protocol = "v2"
+		cfg.Frontend.Config.FrontendV2.Addr = "127.0.0.1"
+	})
+	startTestService(t, frontend.initOverrides)
+	startTestService(t, frontend.initQueryFrontend)
+	startFrontend()
+	require.Nil(t, frontend.frontend)
+	require.NotNil(t, frontend.frontendV2)
+
+	requests := atomic.NewInt32(0)
+	querierHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Inc()
+		http.Error(w, "not found", http.StatusNotFound)
+	})
+
+	workerCfg := frontend.cfg.Querier.Worker
+	workerCfg.SchedulerAddress = fmt.Sprintf("127.0.0.1:%d", frontend.cfg.Server.GRPCListenPort)
+	workerCfg.MaxConcurrentRequests = frontend.cfg.Querier.MaxConcurrentQueries
+	worker, err := cortex_worker.NewQuerierWorker(workerCfg, httpgrpc_server.NewServer(querierHandler), log.NewNopLogger(), nil)
+	require.NoError(t, err)
+	startTestService(t, func() (services.Service, error) { return worker, nil })
+
+	require.Eventually(t, func() bool {
+		return frontend.frontendV2.CheckReady(context.Background()) == nil
+	}, 10*time.Second, 10*time.Millisecond)
+
+	res, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d/api/traces/1234", frontend.cfg.Server.HTTPListenPort))
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	assert.Equal(t, int32(frontend.cfg.Frontend.QueryShards), requests.Load())
+}
+
+func TestQueryFrontendProtocolValidation(t *testing.T) {
+	frontend, _ := newTestApp(t, func(cfg *Config) {
+		cfg.Frontend.Protocol = "v3"
+	})
+	_, err := frontend.initQueryFrontend()
+	assert.EqualError(t, err, `unknown frontend protocol "v3", expected v1 or v2`)
+
+	frontend, _ = newTestApp(t, func(cfg *Config) {
+		cfg.Frontend.Protocol = "v2"
+		cfg.Frontend.Config.FrontendV2.SchedulerAddress = "scheduler:9095"
+	})
+	_, err = frontend.initQueryFrontend()
+	assert.Error(t, err)
+
+	// federation needs tenants once it's enabled
+	frontend, _ = newTestApp(t, func(cfg *Config) {
+		cfg.MultitenancyEnabled = true
+		cfg.MultitenancyFederationEnabled = true
+	})
+	_, err = frontend.initQueryFrontend()
+	assert.EqualError(t, err, "federation is enabled without tenants")
+}
+
+func TestQueryFrontendEmbeddedQueue(t *testing.T) {
+	frontend, _ := newTestApp(t, func(cfg *Config) {})
+	startTestService(t, frontend.initOverrides)
+	startTestService(t, frontend.initQueryFrontend)
+
+	// without a scheduler address the queue is embedded in the frontend
+	assert.NotNil(t, frontend.frontend)
+	assert.Nil(t, frontend.frontendV2)
+}
+
+// newTestApp creates an app listening on free local ports and returns it with a func that starts its server. The
+// server must be started after the modules registered their grpc services.
+func newTestApp(t *testing.T, mutate func(cfg *Config)) (*App, func()) {
+	cfg := Config{}
+	cfg.RegisterFlagsAndApplyDefaults("", flag.NewFlagSet("", flag.PanicOnError))
+	cfg.Server.HTTPListenAddress = "127.0.0.1"
+	cfg.Server.HTTPListenPort = freePort(t)
+	cfg.Server.GRPCListenAddress = "127.0.0.1"
+	cfg.Server.GRPCListenPort = freePort(t)
+	cfg.Server.ServerGracefulShutdownTimeout = time.Second
+	mutate(&cfg)
+
+	app, err := New(cfg)
+	require.NoError(t, err)
+
+	// every app registers the same server and module metrics
+	defaultRegisterer := prometheus.DefaultRegisterer
+	prometheus.DefaultRegisterer = prometheus.NewRegistry()
+	t.Cleanup(func() { prometheus.DefaultRegisterer = defaultRegisterer })
+
+	_, err = app.initServer()
+	require.NoError(t, err)
+	t.Cleanup(app.Server.Shutdown)
+
+	start := func() {
+		go func() {
+			_ = app.Server.Run()
+		}()
+	}
+	return app, start
+}
+
+func startTestService(t *testing.T, init func() (services.Service, error)) {
+	s, err := init()
+	require.NoError(t, err)
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), s))
+	t.Cleanup(func() {
+		_ = services.StopAndAwaitTerminated(context.Background(), s)
+	})
+}
+
+func freePort(t *testing.T) int {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+
+	return l.Addr().(*net.TCPAddr).Port
+}
@@ -58,7 +58,7 @@ func main() {
 	ballastMBs := flag.Int("mem-ballast-size-mbs", 0, "Size of memory ballast to allocate in MBs.")
 	mutexProfileFraction := flag.Int("mutex-profile-fraction", 0, "Enable mutex profiling.")
 
-	config, err := loadConfig()
+	config, deprecationWarnings, err := loadConfig()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "failed parsing config: %v\n", err)
 		os.Exit(1)
@@ -75,6 +75,10 @@ func main() {
 	}
 	log.InitLogger(&config.Server)
 
+	for _, w := range deprecationWarnings {
+		level.Warn(log.Logger).Log("msg", "deprecated config field in use", "path", w.Path, "replacement", w.Replacement, "removal_version", w.RemovalVersion, "ignored", w.Ignored)
+	}
+
 	// Init tracer
 	var shutdownTracer func()
 	if config.UseOTelTracer {
@@ -96,7 +100,9 @@ func main() {
 	ballast := make([]byte, *ballastMBs*1024*1024)
 
 	// Warn the user for suspect configurations
-	config.CheckConfig()
+	for _, w := range config.CheckConfig() {
+		level.Warn(log.Logger).Log("msg", w.Message, "explain", w.Explain)
+	}
 
 	// Start Tempo
 	t, err := app.New(*config)
@@ -116,7 +122,7 @@ func main() {
 	level.Info(log.Logger).Log("msg", "Tempo running")
 }
 
-func loadConfig() (*app.Config, error) {
+func loadConfig() (*app.Config, []app.DeprecationWarning, error) {
 	const (
 		configFileOption      = "config.file"
 		configExpandEnvOption = "config.expand-env"
@@ -125,6 +131,7 @@ func loadConfig() (*app.Config, error) {
 	var (
 		configFile      string
 		configExpandEnv bool
+		warnings        []app.DeprecationWarning
 	)
 
 	args := os.Args[1:]
@@ -152,20 +159,25 @@ func loadConfig() (*app.Config, error) {
 	if configFile != "" {
 		buff, err := ioutil.ReadFile(configFile)
 		if err != nil {
-			return nil, fmt.Errorf("failed to read configFile %s: %w", configFile, err)
+			return nil, nil, fmt.Errorf("failed to read configFile %s: %w", configFile, err)
 		}
 
 		if configExpandEnv {
 			s, err := envsubst.EvalEnv(string(buff))
 			if err != nil {
-				return nil, fmt.Errorf("failed to expand env vars from configFile %s: %w", configFile, err)
+				return nil, nil, fmt.Errorf("failed to expand env vars from configFile %s: %w", configFile, err)
 			}
 			buff = []byte(s)
 		}
 
+		buff, warnings, err = app.MigrateDeprecatedConfig(buff)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to migrate deprecated fields in configFile %s: %w", configFile, err)
+		}
+
 		err = yaml.UnmarshalStrict(buff, config)
 		if err != nil {
-			return nil, fmt.Errorf("failed to parse configFile %s: %w", configFile, err)
+			return nil, nil, fmt.Errorf("failed to parse configFile %s: %w", configFile, err)
 		}
 	}
 
@@ -182,7 +194,7 @@ func loadConfig() (*app.Config, error) {
 		config.Ingester.LifecyclerConfig.Addr = "127.0.0.1"
 	}
 
-	return config, nil
+	return config, warnings, nil
 }
 
 func installOpenTracingTracer(config *app.Config) (func(), error) {
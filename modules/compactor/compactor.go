@@ -16,6 +16,7 @@ import (
 	"github.com/grafana/tempo/modules/overrides"
 	"github.com/grafana/tempo/modules/storage"
 	"github.com/grafana/tempo/pkg/model"
+	"github.com/grafana/tempo/tempodb"
 )
 
 const (
@@ -39,6 +40,12 @@ type Compactor struct {
 
 // New makes a new Compactor.
 func New(cfg Config, store storage.Store, overrides *overrides.Overrides) (*Compactor, error) {
+	switch cfg.Compactor.MaxTraceBytesPolicy {
+	case tempodb.MaxTraceBytesPolicyTruncate, tempodb.MaxTraceBytesPolicyKeepLargest:
+	default:
+		return nil, fmt.Errorf("unknown max trace bytes policy %q", cfg.Compactor.MaxTraceBytesPolicy)
+	}
+
 	c := &Compactor{
 		cfg:       &cfg,
 		store:     store,
@@ -166,6 +173,11 @@ func (c *Compactor) BlockRetentionForTenant(tenantID string) time.Duration {
 	return c.overrides.BlockRetention(tenantID)
 }
 
+// MaxBytesPerTraceForTenant implements CompactorOverrides
+func (c *Compactor) MaxBytesPerTraceForTenant(tenantID string) int {
+	return c.overrides.MaxBytesPerTrace(tenantID)
+}
+
 func (c *Compactor) waitRingActive(ctx context.Context) error {
 	for {
 		// Check if the ingester is ACTIVE in the ring and our ring client
@@ -15,16 +15,26 @@ type Config struct {
 	ShardingRing    cortex_compactor.RingConfig `yaml:"ring,omitempty"`
 	Compactor       tempodb.CompactorConfig     `yaml:"compaction"`
 	OverrideRingKey string                      `yaml:"override_ring_key"`
+
+	// BlocklistFlushEnabled registers the admin endpoint that polls the blocklist without waiting for the next poll
+	// cycle.
+	BlocklistFlushEnabled bool `yaml:"blocklist_flush_enabled"`
 }
 
 // RegisterFlagsAndApplyDefaults registers the flags.
 func (cfg *Config) RegisterFlagsAndApplyDefaults(prefix string, f *flag.FlagSet) {
 	cfg.Compactor = tempodb.CompactorConfig{
-		ChunkSizeBytes:          5 * 1024 * 1024, // 5 MiB
+		ChunkSizeBytes:          tempodb.DefaultChunkSizeBytes,
 		FlushSizeBytes:          tempodb.DefaultFlushSizeBytes,
 		CompactedBlockRetention: time.Hour,
 		RetentionConcurrency:    tempodb.DefaultRetentionConcurrency,
 		IteratorBufferSize:      tempodb.DefaultIteratorBufferSize,
+		MaxTraceBytesPolicy:     tempodb.MaxTraceBytesPolicyTruncate,
+		Scrubber: tempodb.ScrubberConfig{
+			BlocksPerTenantPerHour: 10,
+			MaxBytesPerHour:        1024 * 1024 * 1024, // 1 GiB
+			MaxRequestsPerSecond:   5,
+		},
 	}
 
 	flagext.DefaultValues(&cfg.ShardingRing)
@@ -34,5 +44,8 @@ func (cfg *Config) RegisterFlagsAndApplyDefaults(prefix string, f *flag.FlagSet)
 	f.IntVar(&cfg.Compactor.MaxCompactionObjects, util.PrefixConfig(prefix, "compaction.max-objects-per-block"), 6000000, "Maximum number of traces in a compacted block.")
 	f.Uint64Var(&cfg.Compactor.MaxBlockBytes, util.PrefixConfig(prefix, "compaction.max-block-bytes"), 100*1024*1024*1024 /* 100GB */, "Maximum size of a compacted block.")
 	f.DurationVar(&cfg.Compactor.MaxCompactionRange, util.PrefixConfig(prefix, "compaction.compaction-window"), time.Hour, "Maximum time window across which to compact blocks.")
+	f.StringVar(&cfg.Compactor.MaxTraceBytesPolicy, util.PrefixConfig(prefix, "compaction.max-trace-bytes-policy"), tempodb.MaxTraceBytesPolicyTruncate, "How combined traces over the max bytes per trace are handled. Either truncate or keep_largest.")
+	f.BoolVar(&cfg.Compactor.Scrubber.Enabled, util.PrefixConfig(prefix, "compaction.scrubber.enabled"), false, "Continuously verify a random sample of backend blocks.")
+	f.BoolVar(&cfg.BlocklistFlushEnabled, util.PrefixConfig(prefix, "blocklist-flush-enabled"), false, "Enable the admin endpoint that polls the blocklist without waiting for the next poll cycle.")
 	cfg.OverrideRingKey = ring.CompactorRingKey
 }
@@ -0,0 +1,191 @@
+package distributor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/cortexproject/cortex/pkg/ring"
+	"go.uber.org/atomic"
+)
+
+var errSendWorkersStopped = errors.New("distributor is stopping")
+
+// sendWorkers runs the ingester sends of all pushes on a fixed number of goroutines, so a burst of pushes to many
+// ingesters queues its sends instead of starting a goroutine for each of them.
+type sendWorkers struct {
+	sends chan func()
+	quit  chan struct{}
+}
+
+func newSendWorkers(workers int) *sendWorkers {
+	w := &sendWorkers{
+		sends: make(chan func()),
+		quit:  make(chan struct{}),
+	}
+	for i := 0; i < workers; i++ {
+		go w.work()
+	}
+	return w
+}
+
+func (w *sendWorkers) work() {
+	for {
+		select {
+		case send := <-w.sends:
+			send()
+		case <-w.quit:
+			return
+		}
+	}
+}
+
+// run runs send on a free worker and waits for one if all of them are busy. It returns an error without running
+// send if ctx is done or the workers are stopped first. A nil sendWorkers runs send on a new goroutine.
+func (w *sendWorkers) run(ctx context.Context, send func()) error {
+	if w == nil {
+		go send()
+		return nil
+	}
+
+	select {
+	case w.sends <- send:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-w.quit:
+		return errSendWorkersStopped
+	}
+}
+
+func (w *sendWorkers) stop() {
+	if w != nil {
+		close(w.quit)
+	}
+}
+
+type batchTracker struct {
+	rpcsPending atomic.Int32
+	rpcsFailed  atomic.Int32
+	done        chan struct{}
+	err         chan error
+}
+
+type batchInstance struct {
+	desc         ring.InstanceDesc
+	itemTrackers []*itemTracker
+	indexes      []int
+}
+
+type itemTracker struct {
+	minSuccess  int
+	maxFailures int
+	succeeded   atomic.Int32
+	failed      atomic.Int32
+}
+
+// doBatch is ring.DoBatch with the callbacks run by workers instead of a goroutine per instance. Every key still
+// needs a quorum of its instances to succeed, an instance whose callback can't be scheduled fails its keys.
+func doBatch(ctx context.Context, op ring.Operation, r ring.ReadRing, keys []uint32, workers *sendWorkers, callback func(ring.InstanceDesc, []int) error, cleanup func()) error {
+	if r.InstancesCount() <= 0 {
+		return fmt.Errorf("DoBatch: InstancesCount <= 0")
+	}
+	expectedTrackers := len(keys) * (r.ReplicationFactor() + 1) / r.InstancesCount()
+	itemTrackers := make([]itemTracker, len(keys))
+	instances := make(map[string]batchInstance, r.InstancesCount())
+
+	var (
+		bufDescs [ring.GetBufferSize]ring.InstanceDesc
+		bufHosts [ring.GetBufferSize]string
+		bufZones [ring.GetBufferSize]string
+	)
+	for i, key := range keys {
+		replicationSet, err := r.Get(key, op, bufDescs[:0], bufHosts[:0], bufZones[:0])
+		if err != nil {
+			return err
+		}
+		itemTrackers[i].minSuccess = len(replicationSet.Instances) - replicationSet.MaxErrors
+		itemTrackers[i].maxFailures = replicationSet.MaxErrors
+
+		for _, desc := range replicationSet.Instances {
+			curr, found := instances[desc.Addr]
+			if !found {
+				curr.itemTrackers = make([]*itemTracker, 0, expectedTrackers)
+				curr.indexes = make([]int, 0, expectedTrackers)
+			}
+			instances[desc.Addr] = batchInstance{
+				desc:         desc,
+				itemTrackers: append(curr.itemTrackers, &itemTrackers[i]),
+				indexes:      append(curr.indexes, i),
+			}
+		}
+	}
+
+	tracker := batchTracker{
+		done: make(chan struct{}, 1),
+		err:  make(chan error, 1),
+	}
+	tracker.rpcsPending.Store(int32(len(itemTrackers)))
+
+	var wg sync.WaitGroup
+
+	wg.Add(len(instances))
+	for _, i := range instances {
+		i := i
+		err := workers.run(ctx, func() {
+			err := callback(i.desc, i.indexes)
+			tracker.record(i.itemTrackers, err)
+			wg.Done()
+		})
+		if err != nil {
+			tracker.record(i.itemTrackers, err)
+			wg.Done()
+		}
+	}
+
+	// Perform cleanup at the end.
+	go func() {
+		wg.Wait()
+
+		cleanup()
+	}()
+
+	select {
+	case err := <-tracker.err:
+		return err
+	case <-tracker.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (b *batchTracker) record(sampleTrackers []*itemTracker, err error) {
+	// If we succeed, decrement each sample's pending count by one.  If we reach
+	// the required number of successful puts on this sample, then decrement the
+	// number of pending samples by one.  If we successfully push all samples to
+	// min success instances, wake up the waiting rpc so it can return early.
+	// Similarly, track the number of errors, and if it exceeds maxFailures
+	// shortcut the waiting rpc.
+	//
+	// The use of atomic increments here guarantees only a single sendSamples
+	// goroutine will write to either channel.
+	for i := range sampleTrackers {
+		if err != nil {
+			if sampleTrackers[i].failed.Inc() <= int32(sampleTrackers[i].maxFailures) {
+				continue
+			}
+			if b.rpcsFailed.Inc() == 1 {
+				b.err <- err
+			}
+		} else {
+			if sampleTrackers[i].succeeded.Inc() != int32(sampleTrackers[i].minSuccess) {
+				continue
+			}
+			if b.rpcsPending.Dec() == 0 {
+				b.done <- struct{}{}
+			}
+		}
+	}
+}
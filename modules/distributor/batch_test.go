@@ -0,0 +1,94 @@
+package distributor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/ring"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+)
+
+func TestDoBatch(t *testing.T) {
+	r := &mockRing{replicationFactor: 3}
+	for i := 0; i < 5; i++ {
+		r.ingesters = append(r.ingesters, ring.InstanceDesc{Addr: fmt.Sprintf("ingester%d", i)})
+	}
+	keys := []uint32{0, 1, 2, 3, 4}
+
+	tcs := []struct {
+		name    string
+		workers *sendWorkers
+		fail    map[string]bool
+		err     error
+	}{
+		{
+			name: "goroutine per send",
+		},
+		{
+			name:    "send workers",
+			workers: newSendWorkers(2),
+		},
+		{
+			name:    "quorum",
+			workers: newSendWorkers(2),
+			fail:    map[string]bool{"ingester0": true},
+		},
+		{
+			name:    "no quorum",
+			workers: newSendWorkers(2),
+			fail:    map[string]bool{"ingester0": true, "ingester1": true},
+			err:     errors.New("failed"),
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			defer tc.workers.stop()
+
+			sends := &concurrentSends{}
+			cleanedUp := make(chan struct{})
+			err := doBatch(context.Background(), ring.Write, r, keys, tc.workers, func(ingester ring.InstanceDesc, _ []int) error {
+				sends.start()
+				defer sends.done()
+				time.Sleep(5 * time.Millisecond)
+
+				if tc.fail[ingester.Addr] {
+					return errors.New("failed")
+				}
+				return nil
+			}, func() { close(cleanedUp) })
+			assert.Equal(t, tc.err, err)
+
+			<-cleanedUp
+			if tc.workers != nil {
+				assert.LessOrEqual(t, int(sends.max.Load()), 2)
+			}
+		})
+	}
+}
+
+func TestDoBatchStoppedWorkers(t *testing.T) {
+	r := &mockRing{replicationFactor: 3}
+	for i := 0; i < 3; i++ {
+		r.ingesters = append(r.ingesters, ring.InstanceDesc{Addr: fmt.Sprintf("ingester%d", i)})
+	}
+
+	workers := newSendWorkers(1)
+	workers.stop()
+
+	calls := atomic.NewInt32(0)
+	cleanedUp := make(chan struct{})
+	err := doBatch(context.Background(), ring.Write, r, []uint32{0}, workers, func(ring.InstanceDesc, []int) error {
+		calls.Inc()
+		return nil
+	}, func() { close(cleanedUp) })
+	require.ErrorIs(t, err, errSendWorkersStopped)
+
+	<-cleanedUp
+	assert.Equal(t, int32(0), calls.Load())
+}
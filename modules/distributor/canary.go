@@ -0,0 +1,89 @@
+package distributor
+
+import (
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/ring"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+
+	tempo_ring "github.com/grafana/tempo/pkg/ring"
+)
+
+// CanaryConfig routes a deterministic slice of trace ids to a set of canary ingesters. Canary ingesters
+// are identified by their availability zone in the ingester ring and receive no other traces. The instances of the
+// ring have no labels other than their zone, so the zone is the only selector of the canary ingesters.
+type CanaryConfig struct {
+	// Zone of the canary ingesters. Canary routing is disabled if empty.
+	Zone string `yaml:"zone"`
+	// Trace ids with a first byte in [TraceIDFirstByteMin, TraceIDFirstByteMax) are routed to canary ingesters.
+	TraceIDFirstByteMin uint8 `yaml:"trace_id_first_byte_min"`
+	TraceIDFirstByteMax uint8 `yaml:"trace_id_first_byte_max"`
+	// Tenants to route to canary ingesters. All tenants if empty.
+	Tenants []string `yaml:"tenants"`
+}
+
+func (cfg *CanaryConfig) enabled() bool {
+	return cfg.Zone != "" && cfg.TraceIDFirstByteMin < cfg.TraceIDFirstByteMax
+}
+
+func (cfg *CanaryConfig) enabledForTenant(userID string) bool {
+	if !cfg.enabled() {
+		return false
+	}
+
+	if len(cfg.Tenants) == 0 {
+		return true
+	}
+
+	for _, t := range cfg.Tenants {
+		if t == userID {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (cfg *CanaryConfig) matches(traceID []byte) bool {
+	return len(traceID) > 0 && traceID[0] >= cfg.TraceIDFirstByteMin && traceID[0] < cfg.TraceIDFirstByteMax
+}
+
+// CanaryRings are the ingester ring split by the canary zone: Canary only has the canary ingesters and Normal all
+// other ingesters. Both place keys on the tokens of their instances like the ingester ring, so the canary ingesters
+// don't change the placement of the other traces beyond removing themselves.
+type CanaryRings struct {
+	Canary *ring.Ring
+	Normal *ring.Ring
+}
+
+// NewCanaryRings creates the canary rings from the config of the ingester ring stored at key. It returns nil if
+// canary routing is disabled. The rings must be started before they're used.
+func NewCanaryRings(cfg CanaryConfig, ringCfg ring.Config, key string, kvOutageGracePeriod time.Duration, reg prometheus.Registerer) (*CanaryRings, error) {
+	if !cfg.enabled() {
+		return nil, nil
+	}
+
+	// the canary ingesters share a zone, their replicas can't be spread over zones
+	canaryCfg := ringCfg
+	canaryCfg.ZoneAwarenessEnabled = false
+	canary, err := tempo_ring.NewFiltered(canaryCfg, "ingester-canary", key, kvOutageGracePeriod, func(i ring.InstanceDesc) bool { return i.Zone == cfg.Zone }, reg)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to initialize canary ingester ring")
+	}
+	normal, err := tempo_ring.NewFiltered(ringCfg, "ingester-non-canary", key, kvOutageGracePeriod, func(i ring.InstanceDesc) bool { return i.Zone != cfg.Zone }, reg)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to initialize non canary ingester ring")
+	}
+
+	return &CanaryRings{
+		Canary: canary,
+		Normal: normal,
+	}, nil
+}
+
+// canaryHealthy returns true if the canary ingesters can take writes, i.e. enough replicas of a key are healthy.
+func (r *CanaryRings) canaryHealthy() bool {
+	_, err := r.Canary.Get(0, ring.Write, nil, nil, nil)
+	return err == nil
+}
@@ -0,0 +1,136 @@
+package distributor
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/ring"
+	"github.com/go-kit/kit/log"
+	"github.com/grafana/dskit/flagext"
+	"github.com/grafana/dskit/kv/consul"
+	"github.com/grafana/dskit/services"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanaryConfig(t *testing.T) {
+	cfg := CanaryConfig{
+		Zone:                "canary",
+		TraceIDFirstByteMin: 0x00,
+		TraceIDFirstByteMax: 0x0D,
+	}
+
+	assert.True(t, cfg.enabledForTenant("test"))
+	assert.True(t, cfg.matches([]byte{0x00, 0x01}))
+	assert.True(t, cfg.matches([]byte{0x0C, 0xFF}))
+	assert.False(t, cfg.matches([]byte{0x0D, 0x00}))
+	assert.False(t, cfg.matches([]byte{}))
+
+	cfg.Tenants = []string{"canary-tenant"}
+	assert.False(t, cfg.enabledForTenant("test"))
+	assert.True(t, cfg.enabledForTenant("canary-tenant"))
+
+	// the canary ingesters are excluded from the placement of the other tenants
+	assert.True(t, cfg.enabled())
+
+	// disabled without a zone or with an empty range
+	assert.False(t, (&CanaryConfig{TraceIDFirstByteMax: 0x0D}).enabled())
+	assert.False(t, (&CanaryConfig{TraceIDFirstByteMax: 0x0D}).enabledForTenant("test"))
+	assert.False(t, (&CanaryConfig{Zone: "canary"}).enabledForTenant("test"))
+}
+
+func TestCanaryRings(t *testing.T) {
+	rings, err := NewCanaryRings(CanaryConfig{}, ring.Config{}, ring.IngesterRingKey, 0, prometheus.NewRegistry())
+	require.NoError(t, err)
+	assert.Nil(t, rings)
+
+	store, _ := consul.NewInMemoryClient(ring.GetCodec(), log.NewNopLogger(), nil)
+
+	ringConfig := ring.Config{}
+	flagext.DefaultValues(&ringConfig)
+	ringConfig.KVStore.Mock = store
+	ringConfig.ReplicationFactor = 3
+	ringConfig.ZoneAwarenessEnabled = true
+
+	updateRing := func(f func(desc *ring.Desc)) {
+		err := store.CAS(context.Background(), ring.IngesterRingKey, func(in interface{}) (interface{}, bool, error) {
+			desc := ring.GetOrCreateRingDesc(in)
+			f(desc)
+			return desc, true, nil
+		})
+		require.NoError(t, err)
+	}
+	updateRing(func(desc *ring.Desc) {
+		for i, zone := range []string{"a", "b", "c", "a", "b", "c", "canary", "canary", "canary"} {
+			id := fmt.Sprintf("ingester%d", i)
+			desc.AddIngester(id, id, zone, ring.GenerateTokens(128, nil), ring.ACTIVE, time.Now())
+		}
+	})
+
+	cfg := CanaryConfig{Zone: "canary", TraceIDFirstByteMax: 0x0D}
+	rings, err = NewCanaryRings(cfg, ringConfig, ring.IngesterRingKey, 0, prometheus.NewRegistry())
+	require.NoError(t, err)
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), rings.Canary))
+	defer services.StopAndAwaitTerminated(context.Background(), rings.Canary) //nolint:errcheck
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), rings.Normal))
+	defer services.StopAndAwaitTerminated(context.Background(), rings.Normal) //nolint:errcheck
+
+	assert.Equal(t, 3, rings.Canary.InstancesCount())
+	assert.Equal(t, 6, rings.Normal.InstancesCount())
+	assert.True(t, rings.canaryHealthy())
+
+	keys := make([]uint32, 100)
+	for i := range keys {
+		keys[i] = rand.Uint32()
+	}
+	placement := map[uint32][]string{}
+	for _, key := range keys {
+		rs, err := rings.Canary.Get(key, ring.Write, nil, nil, nil)
+		require.NoError(t, err)
+		require.Len(t, rs.Instances, 3)
+		for _, i := range rs.Instances {
+			assert.Equal(t, "canary", i.Zone)
+		}
+
+		// the other traces are never placed on the canary instances and keep one replica per zone
+		rs, err = rings.Normal.Get(key, ring.Write, nil, nil, nil)
+		require.NoError(t, err)
+		require.Len(t, rs.Instances, 3)
+		var zones []string
+		for _, i := range rs.Instances {
+			zones = append(zones, i.Zone)
+			placement[key] = append(placement[key], i.Addr)
+		}
+		assert.ElementsMatch(t, []string{"a", "b", "c"}, zones)
+	}
+
+	// adding a canary instance doesn't move the other traces
+	updateRing(func(desc *ring.Desc) {
+		desc.AddIngester("ingester9", "ingester9", "canary", ring.GenerateTokens(128, nil), ring.ACTIVE, time.Now())
+	})
+	require.Eventually(t, func() bool { return rings.Canary.InstancesCount() == 4 }, 5*time.Second, 10*time.Millisecond)
+	for _, key := range keys {
+		rs, err := rings.Normal.Get(key, ring.Write, nil, nil, nil)
+		require.NoError(t, err)
+		var addrs []string
+		for _, i := range rs.Instances {
+			addrs = append(addrs, i.Addr)
+		}
+		assert.Equal(t, placement[key], addrs)
+	}
+
+	// canary traces fall back to normal placement once no canary instance is healthy
+	updateRing(func(desc *ring.Desc) {
+		for id, i := range desc.Ingesters {
+			if i.Zone == "canary" {
+				i.Timestamp = time.Now().Add(-time.Hour).Unix()
+				desc.Ingesters[id] = i
+			}
+		}
+	})
+	require.Eventually(t, func() bool { return !rings.canaryHealthy() }, 5*time.Second, 10*time.Millisecond)
+}
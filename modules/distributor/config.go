@@ -8,6 +8,8 @@ import (
 	"github.com/cortexproject/cortex/pkg/ring"
 	ring_client "github.com/cortexproject/cortex/pkg/ring/client"
 	"github.com/grafana/dskit/flagext"
+
+	"github.com/grafana/tempo/modules/distributor/receiver"
 )
 
 var defaultReceivers = map[string]interface{}{
@@ -39,6 +41,34 @@ type Config struct {
 	//  note that setting these two config values reduces tolerance to failures on rollout b/c there is always one guaranteed to be failing replica
 	ExtendWrites bool `yaml:"extend_writes"`
 
+	// search data is extracted concurrently with marshalling. pushes wait up to SearchDataTimeout for it and are sent
+	//  without search data afterwards. set SearchDataSynchronous to always wait for extraction to complete.
+	SearchDataSynchronous bool          `yaml:"search_data_synchronous"`
+	SearchDataTimeout     time.Duration `yaml:"search_data_timeout"`
+	SearchDataConcurrency int           `yaml:"search_data_concurrency"`
+
+	// maximum number of ingester sends in progress across all pushes. the sends run on this many workers, pushes wait
+	//  for a free worker to send to each ingester. 0 starts a goroutine per send
+	MaxConcurrentIngesterSends int `yaml:"max_concurrent_ingester_sends"`
+
+	// routes a deterministic slice of trace ids to canary ingesters
+	Canary CanaryConfig `yaml:"canary"`
+
+	// tracks the services sending the most data per tenant
+	TopServices TopServicesConfig `yaml:"top_services"`
+
+	// period on which the live traces of all ingesters are polled to enforce the global live traces limit. only used
+	//  with the global live traces strategy
+	LiveTracesPollPeriod time.Duration `yaml:"live_traces_poll_period"`
+
+	// while the kv store of the ingester ring is unreachable the last known ring is used to route writes for up to
+	//  this long before ingesters with stale heartbeats are considered unhealthy. 0 to disable
+	IngesterRingKVOutageGracePeriod time.Duration `yaml:"ingester_ring_kv_outage_grace_period"`
+
+	// authenticates pushes with the bearer tokens of the push_tokens overrides. the tenant of a valid token replaces
+	//  the X-Scope-OrgID header
+	PushTokenAuth receiver.TokenAuthConfig `yaml:"push_token_auth"`
+
 	// For testing.
 	factory func(addr string) (ring_client.PoolClient, error) `yaml:"-"`
 }
@@ -52,5 +82,16 @@ func (cfg *Config) RegisterFlagsAndApplyDefaults(prefix string, f *flag.FlagSet)
 	cfg.OverrideRingKey = ring.DistributorRingKey
 	cfg.ExtendWrites = true
 
+	f.BoolVar(&cfg.SearchDataSynchronous, prefix+".search-data-synchronous", false, "Extract search data synchronously on the push path instead of concurrently with marshalling.")
+	f.DurationVar(&cfg.SearchDataTimeout, prefix+".search-data-timeout", 50*time.Millisecond, "Time to wait for asynchronous search data extraction before sending pushes without it.")
+	f.IntVar(&cfg.SearchDataConcurrency, prefix+".search-data-concurrency", 256, "Maximum number of concurrent asynchronous search data extractions.")
+	f.IntVar(&cfg.MaxConcurrentIngesterSends, prefix+".max-concurrent-ingester-sends", 0, "Maximum number of ingester sends in progress across all pushes. 0 starts a goroutine per send.")
+	f.BoolVar(&cfg.TopServices.Enabled, prefix+".top-services.enabled", false, "Track the services sending the most data per tenant.")
+	f.IntVar(&cfg.TopServices.Capacity, prefix+".top-services.capacity", 100, "Number of services tracked per tenant.")
+	f.DurationVar(&cfg.TopServices.ResetInterval, prefix+".top-services.reset-interval", time.Hour, "Interval on which top services counts are reset.")
+	f.IntVar(&cfg.TopServices.MetricsTopN, prefix+".top-services.metrics-top-n", 0, "Number of top services per tenant to publish as metrics at the end of every interval. 0 to disable.")
+	f.DurationVar(&cfg.LiveTracesPollPeriod, prefix+".live-traces-poll-period", 15*time.Second, "Period on which the live traces of all ingesters are polled when using the global live traces strategy.")
+	f.DurationVar(&cfg.IngesterRingKVOutageGracePeriod, prefix+".ingester-ring-kv-outage-grace-period", 0, "Time to keep routing writes with the last known ingester ring while its kv store is unreachable. 0 to disable.")
+	cfg.PushTokenAuth.RegisterFlagsAndApplyDefaults(prefix+".push-token-auth", f)
 	f.BoolVar(&cfg.LogReceivedTraces, prefix+".log-received-traces", false, "Enable to log every received trace id to help debug ingestion.")
 }
@@ -4,7 +4,6 @@ import (
 	"context"
 	"encoding/hex"
 	"fmt"
-	"strings"
 	"time"
 
 	"github.com/cortexproject/cortex/pkg/ring"
@@ -57,6 +56,11 @@ var (
 		Name:      "distributor_ingester_append_failures_total",
 		Help:      "The total number of failed batch appends sent to ingesters.",
 	}, []string{"ingester"})
+	metricSplitPushes = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "distributor_split_pushes_total",
+		Help:      "The total number of batch appends split into several pushes because they exceeded the max message size of the ingester client.",
+	})
 	metricSpansIngested = promauto.NewCounterVec(prometheus.CounterOpts{
 		Namespace: "tempo",
 		Name:      "distributor_spans_received_total",
@@ -83,6 +87,21 @@ var (
 		Name:      "discarded_spans_total",
 		Help:      "The total number of samples that were discarded.",
 	}, []string{discardReasonLabel, "tenant"})
+	metricCanarySpans = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "distributor_canary_spans_total",
+		Help:      "The total number of spans routed to canary ingesters.",
+	}, []string{"tenant"})
+	metricIngesterSendsActive = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "tempo",
+		Name:      "distributor_ingester_sends_active",
+		Help:      "The current number of batch appends being sent to ingesters.",
+	})
+	metricSearchDataSkipped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "distributor_search_data_skipped_total",
+		Help:      "The total number of traces pushed without search data because extraction did not complete in time.",
+	}, []string{"tenant"})
 )
 
 // Distributor coordinates replicates and distribution of log streams.
@@ -92,9 +111,19 @@ type Distributor struct {
 	cfg             Config
 	clientCfg       ingester_client.Config
 	ingestersRing   ring.ReadRing
+	canaryRings     *CanaryRings
 	pool            *ring_client.Pool
 	DistributorRing *ring.Ring
 	searchEnabled   bool
+	searchDataSem   chan struct{}
+	sendWorkers     *sendWorkers
+	topServices     *topServicesTracker
+	liveTraces      *liveTracesTracker
+	overrides       *overrides.Overrides
+
+	// used to determine readiness
+	receivers  services.Service
+	lifecycler *ring.Lifecycler
 
 	// Per-user rate limiter.
 	ingestionRateLimiter *limiter.RateLimiter
@@ -104,8 +133,8 @@ type Distributor struct {
 	subservicesWatcher *services.FailureWatcher
 }
 
-// New a distributor creates.
-func New(cfg Config, clientCfg ingester_client.Config, ingestersRing ring.ReadRing, o *overrides.Overrides, multitenancyEnabled bool, level logging.Level, searchEnabled bool) (*Distributor, error) {
+// New a distributor creates. canaryRings are nil unless canary routing is enabled.
+func New(cfg Config, clientCfg ingester_client.Config, ingestersRing ring.ReadRing, canaryRings *CanaryRings, o *overrides.Overrides, multitenancyEnabled bool, level logging.Level, searchEnabled bool) (*Distributor, error) {
 	factory := cfg.factory
 	if factory == nil {
 		factory = func(addr string) (ring_client.PoolClient, error) {
@@ -118,6 +147,7 @@ func New(cfg Config, clientCfg ingester_client.Config, ingestersRing ring.ReadRi
 	// Create the configured ingestion rate limit strategy (local or global).
 	var ingestionRateStrategy limiter.RateLimiterStrategy
 	var distributorRing *ring.Ring
+	var distributorLifecycler *ring.Lifecycler
 
 	if o.IngestionRateStrategy() == overrides.GlobalIngestionRateStrategy {
 		lifecyclerCfg := cfg.DistributorRing.ToLifecyclerConfig()
@@ -127,6 +157,7 @@ func New(cfg Config, clientCfg ingester_client.Config, ingestersRing ring.ReadRi
 		}
 		subservices = append(subservices, lifecycler)
 		ingestionRateStrategy = newGlobalIngestionRateStrategy(o, lifecycler)
+		distributorLifecycler = lifecycler
 
 		ring, err := ring.New(lifecyclerCfg.RingConfig, "distributor", cfg.OverrideRingKey, prometheus.DefaultRegisterer)
 		if err != nil {
@@ -147,14 +178,37 @@ func New(cfg Config, clientCfg ingester_client.Config, ingestersRing ring.ReadRi
 
 	subservices = append(subservices, pool)
 
+	if canaryRings != nil {
+		subservices = append(subservices, canaryRings.Canary, canaryRings.Normal)
+	}
+
 	d := &Distributor{
 		cfg:                  cfg,
 		clientCfg:            clientCfg,
 		ingestersRing:        ingestersRing,
+		canaryRings:          canaryRings,
 		pool:                 pool,
 		DistributorRing:      distributorRing,
+		lifecycler:           distributorLifecycler,
 		ingestionRateLimiter: limiter.NewRateLimiter(ingestionRateStrategy, 10*time.Second),
 		searchEnabled:        searchEnabled,
+		overrides:            o,
+	}
+
+	if searchEnabled && !cfg.SearchDataSynchronous && cfg.SearchDataConcurrency > 0 {
+		d.searchDataSem = make(chan struct{}, cfg.SearchDataConcurrency)
+	}
+
+	if cfg.MaxConcurrentIngesterSends > 0 {
+		d.sendWorkers = newSendWorkers(cfg.MaxConcurrentIngesterSends)
+	}
+
+	if cfg.TopServices.Enabled && cfg.TopServices.Capacity > 0 {
+		d.topServices = newTopServicesTracker(cfg.TopServices)
+	}
+
+	if o.LiveTracesStrategy() == overrides.GlobalLiveTracesStrategy {
+		d.liveTraces = newLiveTracesTracker(ingestersRing, pool, cfg.LiveTracesPollPeriod, clientCfg.RemoteTimeout)
 	}
 
 	cfgReceivers := cfg.Receivers
@@ -162,11 +216,12 @@ func New(cfg Config, clientCfg ingester_client.Config, ingestersRing ring.ReadRi
 		cfgReceivers = defaultReceivers
 	}
 
-	receivers, err := receiver.New(cfgReceivers, d, multitenancyEnabled, level)
+	receivers, err := receiver.New(cfgReceivers, d, multitenancyEnabled, cfg.PushTokenAuth, o, level)
 	if err != nil {
 		return nil, err
 	}
 	subservices = append(subservices, receivers)
+	d.receivers = receivers
 
 	d.subservices, err = services.NewManager(subservices...)
 	if err != nil {
@@ -190,6 +245,14 @@ func (d *Distributor) starting(ctx context.Context) error {
 }
 
 func (d *Distributor) running(ctx context.Context) error {
+	if d.topServices != nil && d.cfg.TopServices.ResetInterval > 0 {
+		go d.topServices.run(ctx)
+	}
+
+	if d.liveTraces != nil {
+		go d.liveTraces.run(ctx)
+	}
+
 	select {
 	case <-ctx.Done():
 		return nil
@@ -200,9 +263,51 @@ func (d *Distributor) running(ctx context.Context) error {
 
 // Called after distributor is asked to stop via StopAsync.
 func (d *Distributor) stopping(_ error) error {
+	d.sendWorkers.stop()
 	return services.StopManagerAndAwaitStopped(context.Background(), d.subservices)
 }
 
+// CheckReady returns an error if the distributor is not able to accept writes. It verifies that the receivers
+// are running, that the distributor is ACTIVE in the distributor ring when using the global rate strategy, and
+// that at least one ingester client is healthy.
+func (d *Distributor) CheckReady(ctx context.Context) error {
+	if s := d.receivers.State(); s != services.Running {
+		return fmt.Errorf("receivers not running: %v", s)
+	}
+
+	if d.lifecycler != nil {
+		if s := d.lifecycler.GetState(); s != ring.ACTIVE {
+			return fmt.Errorf("distributor not ACTIVE in ring: %v", s)
+		}
+	}
+
+	return d.checkIngesterClients(ctx)
+}
+
+// checkIngesterClients returns nil as soon as it finds one healthy ingester client.
+func (d *Distributor) checkIngesterClients(ctx context.Context) error {
+	rs, err := d.ingestersRing.GetAllHealthy(ring.Write)
+	if err != nil {
+		return fmt.Errorf("no healthy ingesters in ring: %w", err)
+	}
+
+	for _, ingester := range rs.Instances {
+		c, err := d.pool.GetClientFor(ingester.Addr)
+		if err != nil {
+			continue
+		}
+
+		localCtx, cancel := context.WithTimeout(ctx, d.clientCfg.PoolConfig.HealthCheckTimeout)
+		resp, err := c.Check(localCtx, &grpc_health_v1.HealthCheckRequest{})
+		cancel()
+		if err == nil && resp.Status == grpc_health_v1.HealthCheckResponse_SERVING {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("no healthy ingester clients out of %d ingesters", len(rs.Instances))
+}
+
 // Push a set of streams.
 func (d *Distributor) Push(ctx context.Context, req *tempopb.PushRequest) (*tempopb.PushResponse, error) {
 	userID, err := user.ExtractOrgID(ctx)
@@ -232,46 +337,58 @@ func (d *Distributor) Push(ctx context.Context, req *tempopb.PushRequest) (*temp
 	}
 	metricSpansIngested.WithLabelValues(userID).Add(float64(spanCount))
 
+	if d.topServices != nil {
+		d.topServices.record(userID, req.Batch, size, spanCount)
+	}
+
 	// check limits
 	now := time.Now()
 	if !d.ingestionRateLimiter.AllowN(now, userID, req.Size()) {
 		metricDiscardedSpans.WithLabelValues(reasonRateLimited, userID).Add(float64(spanCount))
-		return nil, status.Errorf(codes.ResourceExhausted,
-			"%s ingestion rate limit (%d bytes) exceeded while adding %d bytes",
-			overrides.ErrorPrefixRateLimited,
+		return nil, overrides.NewLimitError(overrides.ErrRateLimited,
+			"ingestion rate limit (%d bytes) exceeded while adding %d bytes",
 			int(d.ingestionRateLimiter.Limit(now, userID)),
 			req.Size())
 	}
 
+	if d.overrides.AttributeNormalizationEnabled(userID) {
+		normalizeAttributes(userID, req.Batch, d.overrides.AttributeRenames(userID))
+	}
+
 	keys, traces, ids, err := requestsByTraceID(req, userID, spanCount)
 	if err != nil {
 		metricDiscardedSpans.WithLabelValues(reasonInternalError, userID).Add(float64(spanCount))
 		return nil, err
 	}
 
-	//var
-	var searchData [][]byte
+	var searchData *asyncSearchData
 	if d.searchEnabled {
-		searchData = extractSearchDataAll(traces, ids)
+		searchData = extractSearchDataAsync(d.searchDataSem, traces, ids)
 	}
 
-	err = d.sendToIngestersViaBytes(ctx, userID, traces, searchData, keys, ids)
+	rejections := newTraceRejections(d.ingestersRing.ReplicationFactor())
+	err = d.sendToIngestersViaBytes(ctx, userID, now, traces, searchData, keys, ids, rejections)
 	if err != nil {
 		recordDiscaredSpans(err, userID, spanCount)
+		return nil, err
 	}
 
-	return nil, err // PushRequest is ignored, so no reason to create one
+	// the response is only created if some traces were rejected
+	return partialSuccess(userID, traces, rejections.rejected())
 }
 
-func (d *Distributor) sendToIngestersViaBytes(ctx context.Context, userID string, traces []*tempopb.Trace, searchData [][]byte, keys []uint32, ids [][]byte) error {
+func (d *Distributor) sendToIngestersViaBytes(ctx context.Context, userID string, ingestionTime time.Time, traces []*tempopb.Trace, asyncSearchData *asyncSearchData, keys []uint32, ids [][]byte, rejections *traceRejections) error {
 	// Marshal to bytes once
-	marshalledTraces := make([][]byte, len(traces))
-	for i, t := range traces {
-		b, err := t.Marshal()
-		if err != nil {
-			return errors.Wrap(err, "failed to marshal PushRequest")
-		}
-		marshalledTraces[i] = b
+	marshalled, err := marshalTraces(traces)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal PushRequest")
+	}
+	marshalledTraces := marshalled.traces
+
+	// Search data is optional. Don't hold up the push if it isn't ready in time.
+	searchData, ok := asyncSearchData.wait(d.cfg.SearchDataTimeout)
+	if !ok {
+		metricSearchDataSkipped.WithLabelValues(userID).Add(float64(len(traces)))
 	}
 
 	op := ring.WriteNoExtend
@@ -279,25 +396,99 @@ func (d *Distributor) sendToIngestersViaBytes(ctx context.Context, userID string
 		op = ring.Write
 	}
 
-	err := ring.DoBatch(ctx, op, d.ingestersRing, keys, func(ingester ring.InstanceDesc, indexes []int) error {
+	// The canary ingesters only receive the canary traces, the other traces are placed on the other ingesters unless
+	// there are none.
+	var normal ring.ReadRing = d.ingestersRing
+	if d.canaryRings != nil && d.canaryRings.Normal.InstancesCount() > 0 {
+		normal = d.canaryRings.Normal
+	}
+
+	if d.canaryRings == nil || !d.cfg.Canary.enabledForTenant(userID) {
+		return d.pushBatch(ctx, op, ingestionTime, normal, userID, keys, nil, marshalledTraces, searchData, ids, rejections, marshalled.release)
+	}
+
+	var (
+		normalKeys, canaryKeys       []uint32
+		normalIndexes, canaryIndexes []int
+		canarySpans                  int
+	)
+	for i, id := range ids {
+		if d.cfg.Canary.matches(id) {
+			canaryKeys = append(canaryKeys, keys[i])
+			canaryIndexes = append(canaryIndexes, i)
+			canarySpans += countSpans(traces[i])
+		} else {
+			normalKeys = append(normalKeys, keys[i])
+			normalIndexes = append(normalIndexes, i)
+		}
+	}
+
+	// Route a deterministic slice of traces to the canary ingesters if any are healthy. Otherwise
+	// fall back to normal placement.
+	if len(canaryKeys) == 0 || !d.canaryRings.canaryHealthy() {
+		return d.pushBatch(ctx, op, ingestionTime, normal, userID, keys, nil, marshalledTraces, searchData, ids, rejections, marshalled.release)
+	}
+	metricCanarySpans.WithLabelValues(userID).Add(float64(canarySpans))
+
+	if len(normalKeys) > 0 {
+		// the marshalled traces are released once both pushes are done
+		marshalled.retain()
+	}
+
+	canaryErr := make(chan error, 1)
+	go func() {
+		canaryErr <- d.pushBatch(ctx, op, ingestionTime, d.canaryRings.Canary, userID, canaryKeys, canaryIndexes, marshalledTraces, searchData, ids, rejections, marshalled.release)
+	}()
+
+	if len(normalKeys) > 0 {
+		err = d.pushBatch(ctx, op, ingestionTime, normal, userID, normalKeys, normalIndexes, marshalledTraces, searchData, ids, rejections, marshalled.release)
+	}
+
+	if cErr := <-canaryErr; err == nil {
+		err = cErr
+	}
+
+	return err
+}
+
+// pushBatch sends the traces identified by keys to the ingesters in the given ring. If indexes is non-nil it maps
+// the position of each key to its position in marshalledTraces, searchData and ids. Traces rejected by an ingester
+// because of a per tenant limit are added to rejections. All ingesters receive the same ingestion time so the replicas
+// of a trace agree on it. The traces of an ingester are split into several pushes if they exceed the max message size
+// of the ingester client, traces that exceed it by themselves are rejected. The sends to the ingesters wait for a free
+// send worker if MaxConcurrentIngesterSends is set. cleanup is called once every ingester is done, which may be after
+// pushBatch returned.
+func (d *Distributor) pushBatch(ctx context.Context, op ring.Operation, ingestionTime time.Time, r ring.ReadRing, userID string, keys []uint32, indexes []int, marshalledTraces [][]byte, searchData [][]byte, ids [][]byte, rejections *traceRejections, cleanup func()) error {
+	maxBytes := d.clientCfg.GRPCClientConfig.MaxSendMsgSize
+	rejectNewTraces := d.rejectNewTraces(userID)
+
+	return doBatch(ctx, op, r, keys, d.sendWorkers, func(ingester ring.InstanceDesc, keyIndexes []int) error {
 		localCtx, cancel := context.WithTimeout(context.Background(), d.clientCfg.RemoteTimeout)
 		defer cancel()
 		localCtx = user.InjectOrgID(localCtx, userID)
 
-		req := tempopb.PushBytesRequest{
-			Traces:     make([]tempopb.PreallocBytes, len(indexes)),
-			Ids:        make([]tempopb.PreallocBytes, len(indexes)),
-			SearchData: make([]tempopb.PreallocBytes, len(indexes)),
-		}
-
-		for i, j := range indexes {
-			req.Traces[i].Slice = marshalledTraces[j][0:]
-			req.Ids[i].Slice = ids[j]
+		metricIngesterSendsActive.Inc()
+		defer metricIngesterSendsActive.Dec()
 
-			// Search data optional
-			if len(searchData) > j {
-				req.SearchData[i].Slice = searchData[j]
+		traceIndexes := make([]int, len(keyIndexes))
+		for i, j := range keyIndexes {
+			if indexes != nil {
+				j = indexes[j]
 			}
+			traceIndexes[i] = j
+		}
+
+		req := tempopb.PushBytesRequest{
+			PartialSuccess:        true,
+			IngestionTimeUnixNano: uint64(ingestionTime.UnixNano()),
+			RejectNewTraces:       rejectNewTraces,
+		}
+		pushes, tooLarge := splitPush(&req, traceIndexes, marshalledTraces, ids, searchData, maxBytes)
+		for _, j := range tooLarge {
+			rejections.add(j, traceTooLargeToSendError(len(marshalledTraces[j]), maxBytes))
+		}
+		if len(pushes) > 1 {
+			metricSplitPushes.Inc()
 		}
 
 		c, err := d.pool.GetClientFor(ingester.Addr)
@@ -305,15 +496,37 @@ func (d *Distributor) sendToIngestersViaBytes(ctx context.Context, userID string
 			return err
 		}
 
-		_, err = c.(tempopb.PusherClient).PushBytes(localCtx, &req)
-		metricIngesterAppends.WithLabelValues(ingester.Addr).Inc()
-		if err != nil {
-			metricIngesterAppendFailures.WithLabelValues(ingester.Addr).Inc()
-		}
-		return err
-	}, func() {})
+		for _, push := range pushes {
+			req.Traces = make([]tempopb.PreallocBytes, len(push))
+			req.Ids = make([]tempopb.PreallocBytes, len(push))
+			req.SearchData = make([]tempopb.PreallocBytes, len(push))
 
-	return err
+			for i, j := range push {
+				req.Traces[i].Slice = marshalledTraces[j][0:]
+				req.Ids[i].Slice = ids[j]
+
+				// Search data optional
+				if len(searchData) > j {
+					req.SearchData[i].Slice = searchData[j]
+				}
+			}
+
+			resp, err := c.(tempopb.PusherClient).PushBytes(localCtx, &req)
+			metricIngesterAppends.WithLabelValues(ingester.Addr).Inc()
+			if err != nil {
+				metricIngesterAppendFailures.WithLabelValues(ingester.Addr).Inc()
+				return err
+			}
+
+			for _, traceErr := range resp.GetTraceErrors() {
+				if int(traceErr.Index) >= len(push) {
+					continue
+				}
+				rejections.add(push[traceErr.Index], traceError(traceErr))
+			}
+		}
+		return nil
+	}, cleanup)
 }
 
 // PushBytes Not used by the distributor
@@ -401,20 +614,44 @@ func requestsByTraceID(req *tempopb.PushRequest, userID string, spanCount int) (
 	return keys, traces, ids, nil
 }
 
+func countSpans(t *tempopb.Trace) int {
+	count := 0
+	for _, b := range t.Batches {
+		for _, ils := range b.InstrumentationLibrarySpans {
+			count += len(ils.Spans)
+		}
+	}
+	return count
+}
+
 func recordDiscaredSpans(err error, userID string, spanCount int) {
-	s := status.Convert(err)
-	if s == nil {
-		return
+	metricDiscardedSpans.WithLabelValues(discardReason(err), userID).Add(float64(spanCount))
+}
+
+// discardReason returns the reason label of the discarded spans metric for an error. The errors of the ingesters
+// are classified by the per tenant limit that rejected them.
+func discardReason(err error) string {
+	if limitErr, ok := overrides.AsLimitError(err); ok {
+		err = limitErr
 	}
-	desc := s.Message()
 
-	if strings.HasPrefix(desc, overrides.ErrorPrefixLiveTracesExceeded) {
-		metricDiscardedSpans.WithLabelValues(reasonLiveTracesExceeded, userID).Add(float64(spanCount))
-	} else if strings.HasPrefix(desc, overrides.ErrorPrefixTraceTooLarge) {
-		metricDiscardedSpans.WithLabelValues(reasonTraceTooLarge, userID).Add(float64(spanCount))
-	} else {
-		metricDiscardedSpans.WithLabelValues(reasonInternalError, userID).Add(float64(spanCount))
+	switch {
+	case errors.Is(err, overrides.ErrLiveTracesExceeded):
+		return reasonLiveTracesExceeded
+	case errors.Is(err, overrides.ErrTraceTooLarge):
+		return reasonTraceTooLarge
+	case errors.Is(err, overrides.ErrRateLimited):
+		return reasonRateLimited
+	}
+	return reasonInternalError
+}
+
+// traceError returns the error of a trace rejected by an ingester in a partial success.
+func traceError(traceErr *tempopb.PushTraceError) error {
+	if limitErr, ok := overrides.TraceLimitError(traceErr.Reason, traceErr.Error); ok {
+		return limitErr
 	}
+	return status.Error(codes.FailedPrecondition, traceErr.Error)
 }
 
 func logTraces(batch *v1.ResourceSpans) {
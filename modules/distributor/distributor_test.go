@@ -2,17 +2,22 @@ package distributor
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math/rand"
 	"strconv"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/cortexproject/cortex/pkg/ring"
 	ring_client "github.com/cortexproject/cortex/pkg/ring/client"
+	"github.com/go-kit/kit/log"
 	"github.com/gogo/status"
 	"github.com/grafana/dskit/flagext"
 	"github.com/grafana/dskit/kv"
+	"github.com/grafana/dskit/kv/consul"
+	"github.com/grafana/dskit/services"
 	v1_common "github.com/grafana/tempo/pkg/tempopb/common/v1"
 	v1_resource "github.com/grafana/tempo/pkg/tempopb/resource/v1"
 	v1 "github.com/grafana/tempo/pkg/tempopb/trace/v1"
@@ -23,12 +28,14 @@ import (
 	"github.com/stretchr/testify/require"
 	"github.com/weaveworks/common/logging"
 	"github.com/weaveworks/common/user"
+	"go.uber.org/atomic"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/health/grpc_health_v1"
 
 	ingester_client "github.com/grafana/tempo/modules/ingester/client"
 	"github.com/grafana/tempo/modules/overrides"
+	tempo_ring "github.com/grafana/tempo/pkg/ring"
 	"github.com/grafana/tempo/pkg/tempopb"
 	"github.com/grafana/tempo/pkg/util"
 	"github.com/grafana/tempo/pkg/util/test"
@@ -331,6 +338,22 @@ func TestRequestsByTraceID(t *testing.T) {
 	}
 }
 
+func TestRequestsByTraceIDPreservesAllFields(t *testing.T) {
+	expected := test.MakeTraceWithAllFields(nil)
+
+	_, traces, _, err := requestsByTraceID(&tempopb.PushRequest{Batch: expected.Batches[0]}, util.FakeTenantID, 2)
+	require.NoError(t, err)
+	require.Len(t, traces, 1)
+
+	// marshal as the distributor does before sending to the ingesters
+	b, err := proto.Marshal(traces[0])
+	require.NoError(t, err)
+	actual := &tempopb.Trace{}
+	require.NoError(t, proto.Unmarshal(b, actual))
+
+	assert.True(t, proto.Equal(expected, actual))
+}
+
 func BenchmarkTestsByRequestID(b *testing.B) {
 	spansPer := 100
 	batches := 10
@@ -393,31 +416,401 @@ func TestDistributor(t *testing.T) {
 	}
 }
 
-func prepare(t *testing.T, limits *overrides.Limits, kvStore kv.Client) *Distributor {
-	var (
-		distributorConfig Config
-		clientConfig      ingester_client.Config
-	)
-	flagext.DefaultValues(&clientConfig)
+func TestDistributorCheckReady(t *testing.T) {
+	limits := &overrides.Limits{}
+	flagext.DefaultValues(limits)
 
-	overrides, err := overrides.NewOverrides(*limits)
+	d := prepare(t, limits, nil)
+
+	// receivers have not been started
+	err := d.CheckReady(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "receivers not running")
+
+	// ingesters are healthy
+	assert.NoError(t, d.checkIngesterClients(context.Background()))
+
+	// no ingester is serving
+	rs, err := d.ingestersRing.GetAllHealthy(ring.Write)
 	require.NoError(t, err)
+	for _, ingester := range rs.Instances {
+		c, err := d.pool.GetClientFor(ingester.Addr)
+		require.NoError(t, err)
+		c.(*mockIngester).notServing = true
+	}
+	assert.Error(t, d.checkIngesterClients(context.Background()))
+}
 
-	// Mock the ingesters ring
+func TestDistributorGlobalLiveTraces(t *testing.T) {
+	limits := &overrides.Limits{}
+	flagext.DefaultValues(limits)
+	limits.LiveTracesStrategy = overrides.GlobalLiveTracesStrategy
+	limits.MaxGlobalTracesPerUser = 10
+
+	d := prepare(t, limits, nil)
+	require.NotNil(t, d.liveTraces)
+
+	traceIDA := []byte{0x0A, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0A, 0x0B, 0x0C, 0x0D, 0x0E, 0x0F}
+	traceIDB := []byte{0x0B, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0A, 0x0B, 0x0C, 0x0D, 0x0E, 0x0F}
+
+	rs, err := d.ingestersRing.GetAllHealthy(ring.Read)
+	require.NoError(t, err)
+	setLiveTraces := func(traces uint64) {
+		for _, ingester := range rs.Instances {
+			c, err := d.pool.GetClientFor(ingester.Addr)
+			require.NoError(t, err)
+			c.(*mockIngester).setLiveTraces(map[string]uint64{"test": traces})
+		}
+		d.liveTraces.poll(context.Background())
+	}
+
+	// 5 ingesters with 5 traces each and a replication factor of 3
+	setLiveTraces(5)
+	assert.Equal(t, uint64(8), d.liveTraces.liveTraces("test"))
+	resp, err := d.Push(ctx, test.MakeRequest(10, traceIDA))
+	require.NoError(t, err)
+	assert.Nil(t, resp)
+
+	// at the limit only new traces are rejected, spans of live traces are still appended
+	setLiveTraces(6)
+	assert.Equal(t, uint64(10), d.liveTraces.liveTraces("test"))
+
+	request := test.MakeRequest(3, traceIDA)
+	request.Batch.InstrumentationLibrarySpans = append(request.Batch.InstrumentationLibrarySpans, test.MakeRequest(5, traceIDB).Batch.InstrumentationLibrarySpans...)
+
+	before, err := test.GetCounterValue(metricDiscardedSpans.WithLabelValues(reasonLiveTracesExceeded, "test"))
+	require.NoError(t, err)
+
+	resp, err = d.Push(ctx, request)
+	require.NoError(t, err)
+	require.NotNil(t, resp.GetPartialSuccess())
+	assert.Equal(t, int64(5), resp.PartialSuccess.RejectedSpans)
+	assert.Contains(t, resp.PartialSuccess.ErrorMessage, "1 of 2 traces rejected (live_traces_exceeded: 1)")
+
+	after, err := test.GetCounterValue(metricDiscardedSpans.WithLabelValues(reasonLiveTracesExceeded, "test"))
+	require.NoError(t, err)
+	assert.Equal(t, float64(5), after-before)
+
+	// a push with only new traces fails
+	_, err = d.Push(ctx, test.MakeRequest(5, traceIDB))
+	require.Error(t, err)
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	assert.ErrorIs(t, err, overrides.ErrLiveTracesExceeded)
+	assert.Contains(t, err.Error(), overrides.ErrorPrefixLiveTracesExceeded)
+
+	// other tenants are limited by their own live traces
+	_, err = d.Push(user.InjectOrgID(context.Background(), "new"), test.MakeRequest(10, traceIDB))
+	require.NoError(t, err)
+}
+
+func TestDistributorPartialSuccess(t *testing.T) {
+	limits := &overrides.Limits{}
+	flagext.DefaultValues(limits)
+
+	d := prepare(t, limits, nil)
+
+	traceIDA := []byte{0x0A, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0A, 0x0B, 0x0C, 0x0D, 0x0E, 0x0F}
+	traceIDB := []byte{0x0B, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0A, 0x0B, 0x0C, 0x0D, 0x0E, 0x0F}
+
+	rs, err := d.ingestersRing.GetAllHealthy(ring.Write)
+	require.NoError(t, err)
+	for _, ingester := range rs.Instances {
+		c, err := d.pool.GetClientFor(ingester.Addr)
+		require.NoError(t, err)
+		c.(*mockIngester).rejectTraces = map[string]bool{string(traceIDB): true}
+	}
+
+	request := test.MakeRequest(3, traceIDA)
+	request.Batch.InstrumentationLibrarySpans = append(request.Batch.InstrumentationLibrarySpans, test.MakeRequest(5, traceIDB).Batch.InstrumentationLibrarySpans...)
+
+	before, err := test.GetCounterValue(metricDiscardedSpans.WithLabelValues(reasonTraceTooLarge, "test"))
+	require.NoError(t, err)
+
+	resp, err := d.Push(ctx, request)
+	require.NoError(t, err)
+	require.NotNil(t, resp.GetPartialSuccess())
+	assert.Equal(t, int64(5), resp.PartialSuccess.RejectedSpans)
+	assert.Contains(t, resp.PartialSuccess.ErrorMessage, "1 of 2 traces rejected (trace_too_large: 1)")
+
+	after, err := test.GetCounterValue(metricDiscardedSpans.WithLabelValues(reasonTraceTooLarge, "test"))
+	require.NoError(t, err)
+	assert.Equal(t, float64(5), after-before)
+
+	// a push with only rejected traces fails
+	_, err = d.Push(ctx, test.MakeRequest(5, traceIDB))
+	require.Error(t, err)
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	assert.ErrorIs(t, err, overrides.ErrTraceTooLarge)
+	assert.Contains(t, err.Error(), overrides.ErrorPrefixTraceTooLarge)
+
+	// a push without rejected traces has no response
+	resp, err = d.Push(ctx, test.MakeRequest(5, traceIDA))
+	require.NoError(t, err)
+	assert.Nil(t, resp)
+}
+
+func TestDiscardReason(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected string
+	}{
+		{name: "live traces", err: overrides.NewLimitError(overrides.ErrLiveTracesExceeded, "exceeded"), expected: reasonLiveTracesExceeded},
+		{name: "trace too large", err: overrides.NewLimitError(overrides.ErrTraceTooLarge, "exceeded"), expected: reasonTraceTooLarge},
+		{name: "rate limited", err: overrides.NewLimitError(overrides.ErrRateLimited, "exceeded"), expected: reasonRateLimited},
+		{name: "wrapped", err: fmt.Errorf("push: %w", overrides.NewLimitError(overrides.ErrTraceTooLarge, "exceeded")), expected: reasonTraceTooLarge},
+		{name: "ingester status", err: status.ErrorProto(status.Convert(overrides.NewLimitError(overrides.ErrLiveTracesExceeded, "exceeded")).Proto()), expected: reasonLiveTracesExceeded},
+		{name: "deprecated prefix", err: status.Error(codes.FailedPrecondition, overrides.ErrorPrefixTraceTooLarge+" exceeded"), expected: reasonTraceTooLarge},
+		{name: "other", err: errors.New("error"), expected: reasonInternalError},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, discardReason(tc.err))
+		})
+	}
+}
+
+func TestTraceError(t *testing.T) {
+	err := traceError(&tempopb.PushTraceError{Error: "max size of trace exceeded", Reason: overrides.ReasonTraceTooLarge})
+	assert.ErrorIs(t, err, overrides.ErrTraceTooLarge)
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	// ingesters that predate reasons only send the prefix
+	err = traceError(&tempopb.PushTraceError{Error: overrides.ErrorPrefixLiveTracesExceeded + " exceeded"})
+	assert.ErrorIs(t, err, overrides.ErrLiveTracesExceeded)
+
+	err = traceError(&tempopb.PushTraceError{Error: "unknown"})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	assert.Equal(t, reasonInternalError, discardReason(err))
+}
+
+func TestDistributorSplitsLargePushes(t *testing.T) {
+	limits := &overrides.Limits{}
+	flagext.DefaultValues(limits)
+
+	const maxBytes = 1000
+	d := prepare(t, limits, nil)
+	d.clientCfg.GRPCClientConfig.MaxSendMsgSize = maxBytes
+
+	var ingesters []*mockIngester
+	rs, err := d.ingestersRing.GetAllHealthy(ring.Write)
+	require.NoError(t, err)
+	for _, ingester := range rs.Instances {
+		c, err := d.pool.GetClientFor(ingester.Addr)
+		require.NoError(t, err)
+		c.(*mockIngester).maxPushBytes = maxBytes
+		ingesters = append(ingesters, c.(*mockIngester))
+	}
+
+	// small traces that add up to more than the max message size
+	request := &tempopb.PushRequest{Batch: &v1.ResourceSpans{}}
+	for i := 0; i < 20; i++ {
+		traceID := make([]byte, 16)
+		traceID[0] = byte(i + 1)
+		request.Batch.InstrumentationLibrarySpans = append(request.Batch.InstrumentationLibrarySpans, test.MakeRequest(2, traceID).Batch.InstrumentationLibrarySpans...)
+	}
+
+	resp, err := d.Push(ctx, request)
+	require.NoError(t, err)
+	assert.Nil(t, resp)
+
+	pushes := 0
+	for _, ingester := range ingesters {
+		pushes += int(ingester.pushes.Load())
+	}
+	assert.Greater(t, pushes, len(ingesters))
+
+	// a trace that doesn't fit in a push by itself is rejected by the distributor
+	largeTraceID := make([]byte, 16)
+	largeTraceID[0] = 0xFF
+	request.Batch.InstrumentationLibrarySpans = append(request.Batch.InstrumentationLibrarySpans, test.MakeRequest(50, largeTraceID).Batch.InstrumentationLibrarySpans...)
+
+	resp, err = d.Push(ctx, request)
+	require.NoError(t, err)
+	require.NotNil(t, resp.GetPartialSuccess())
+	assert.Equal(t, int64(50), resp.PartialSuccess.RejectedSpans)
+	assert.Contains(t, resp.PartialSuccess.ErrorMessage, "1 of 21 traces rejected (trace_too_large: 1)")
+	assert.Contains(t, resp.PartialSuccess.ErrorMessage, "max_send_msg_size: 1000")
+}
+
+func TestDistributorMaxConcurrentIngesterSends(t *testing.T) {
+	limits := &overrides.Limits{}
+	flagext.DefaultValues(limits)
+
+	const (
+		instances = 100
+		maxSends  = 8
+	)
+
+	sends := &concurrentSends{}
+	ingestersRing := &mockRing{replicationFactor: 3}
 	ingesters := map[string]*mockIngester{}
-	for i := 0; i < numIngesters; i++ {
-		ingesters[fmt.Sprintf("ingester%d", i)] = &mockIngester{}
+	for i := 0; i < instances; i++ {
+		addr := fmt.Sprintf("ingester%d", i)
+		ingestersRing.ingesters = append(ingestersRing.ingesters, ring.InstanceDesc{Addr: addr})
+		ingesters[addr] = &mockIngester{pushDelay: 5 * time.Millisecond, sends: sends}
+	}
+
+	cfg := Config{MaxConcurrentIngesterSends: maxSends}
+	d := prepareWithIngesters(t, limits, nil, ingestersRing, cfg, ingesters)
+
+	// every push touches all the ingesters of the ring
+	request := func() *tempopb.PushRequest {
+		req := &tempopb.PushRequest{Batch: &v1.ResourceSpans{}}
+		for i := 0; i < instances; i++ {
+			traceID := make([]byte, 16)
+			_, err := rand.Read(traceID)
+			require.NoError(t, err)
+			req.Batch.InstrumentationLibrarySpans = append(req.Batch.InstrumentationLibrarySpans, test.MakeRequest(1, traceID).Batch.InstrumentationLibrarySpans...)
+		}
+		return req
+	}
+
+	const pushes = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, pushes)
+	for i := 0; i < pushes; i++ {
+		req := request()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := d.Push(ctx, req)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		require.NoError(t, err)
+	}
+	// the limit applies across all pushes
+	assert.LessOrEqual(t, int(sends.max.Load()), maxSends)
+	assert.Greater(t, int(sends.max.Load()), 0)
+
+	// pushes return on quorum, the sends to the remaining ingesters finish afterwards
+	require.Eventually(t, func() bool { return sends.active.Load() == 0 }, 5*time.Second, 10*time.Millisecond)
+
+	// every ingester received its traces
+	for _, ingester := range ingesters {
+		assert.Greater(t, ingester.pushes.Load(), int32(0))
+	}
+}
+
+func TestDistributorIngesterRingKVOutage(t *testing.T) {
+	limits := &overrides.Limits{}
+	flagext.DefaultValues(limits)
+
+	store, _ := consul.NewInMemoryClient(ring.GetCodec(), log.NewNopLogger(), nil)
+	failingStore := &failingKV{Client: store, fail: atomic.NewBool(false)}
+
+	// heartbeats are stored with a resolution of seconds
+	ringConfig := ring.Config{}
+	flagext.DefaultValues(&ringConfig)
+	ringConfig.KVStore.Mock = failingStore
+	ringConfig.HeartbeatTimeout = 2 * time.Second
+	ringConfig.ReplicationFactor = 3
+
+	err := store.CAS(context.Background(), ring.IngesterRingKey, func(in interface{}) (interface{}, bool, error) {
+		desc := ring.NewDesc()
+		for i := 0; i < numIngesters; i++ {
+			desc.AddIngester(fmt.Sprintf("ingester%d", i), fmt.Sprintf("ingester%d", i), "", ring.GenerateTokens(128, nil), ring.ACTIVE, time.Now())
+		}
+		return desc, true, nil
+	})
+	require.NoError(t, err)
+
+	ingestersRing, err := tempo_ring.New(ringConfig, "ingester", ring.IngesterRingKey, 4*time.Second, prometheus.NewRegistry())
+	require.NoError(t, err)
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), ingestersRing))
+	defer services.StopAndAwaitTerminated(context.Background(), ingestersRing) //nolint:errcheck
+
+	d := prepareWithRing(t, limits, nil, ingestersRing)
+
+	_, err = d.Push(ctx, test.MakeRequest(10, []byte{}))
+	require.NoError(t, err)
+
+	// the heartbeats go stale while the kv store is unreachable but the last known ring is used within the grace period
+	failingStore.fail.Store(true)
+	time.Sleep(3 * time.Second)
+	_, err = d.Push(ctx, test.MakeRequest(10, []byte{}))
+	require.NoError(t, err)
+
+	// writes fail once the grace period expires
+	time.Sleep(2 * time.Second)
+	_, err = d.Push(ctx, test.MakeRequest(10, []byte{}))
+	require.Error(t, err)
+
+	// and succeed again once the kv store is reachable and the ingesters heartbeat
+	failingStore.fail.Store(false)
+	err = store.CAS(context.Background(), ring.IngesterRingKey, func(in interface{}) (interface{}, bool, error) {
+		desc := in.(*ring.Desc)
+		for id, ingester := range desc.Ingesters {
+			ingester.Timestamp = time.Now().Unix()
+			desc.Ingesters[id] = ingester
+		}
+		return desc, true, nil
+	})
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		_, err := d.Push(ctx, test.MakeRequest(10, []byte{}))
+		return err == nil
+	}, 5*time.Second, 100*time.Millisecond)
+}
+
+// failingKV is a kv client that fails reads and writes on demand. Watches are passed through and don't see updates
+// while no writes succeed.
+type failingKV struct {
+	kv.Client
+	fail *atomic.Bool
+}
+
+func (f *failingKV) Get(ctx context.Context, key string) (interface{}, error) {
+	if f.fail.Load() {
+		return nil, errors.New("kv store unreachable")
 	}
+	return f.Client.Get(ctx, key)
+}
 
+func (f *failingKV) CAS(ctx context.Context, key string, fn func(in interface{}) (out interface{}, retry bool, err error)) error {
+	if f.fail.Load() {
+		return errors.New("kv store unreachable")
+	}
+	return f.Client.CAS(ctx, key, fn)
+}
+
+func prepare(t *testing.T, limits *overrides.Limits, kvStore kv.Client) *Distributor {
+	// Mock the ingesters ring
 	ingestersRing := &mockRing{
 		replicationFactor: 3,
 	}
-	for addr := range ingesters {
+	for i := 0; i < numIngesters; i++ {
 		ingestersRing.ingesters = append(ingestersRing.ingesters, ring.InstanceDesc{
-			Addr: addr,
+			Addr: fmt.Sprintf("ingester%d", i),
 		})
 	}
 
+	return prepareWithRing(t, limits, kvStore, ingestersRing)
+}
+
+// prepareWithRing creates a distributor that pushes to mock ingesters with the addresses ingester0 to ingester4
+func prepareWithRing(t *testing.T, limits *overrides.Limits, kvStore kv.Client, ingestersRing ring.ReadRing) *Distributor {
+	ingesters := map[string]*mockIngester{}
+	for i := 0; i < numIngesters; i++ {
+		ingesters[fmt.Sprintf("ingester%d", i)] = &mockIngester{}
+	}
+
+	return prepareWithIngesters(t, limits, kvStore, ingestersRing, Config{}, ingesters)
+}
+
+// prepareWithIngesters creates a distributor with the given config that pushes to the mock ingesters by address
+func prepareWithIngesters(t *testing.T, limits *overrides.Limits, kvStore kv.Client, ingestersRing ring.ReadRing, distributorConfig Config, ingesters map[string]*mockIngester) *Distributor {
+	var clientConfig ingester_client.Config
+	flagext.DefaultValues(&clientConfig)
+
+	overrides, err := overrides.NewOverrides(*limits)
+	require.NoError(t, err)
+
 	distributorConfig.DistributorRing.HeartbeatPeriod = 100 * time.Millisecond
 	distributorConfig.DistributorRing.InstanceID = strconv.Itoa(rand.Int())
 	distributorConfig.DistributorRing.KVStore.Mock = kvStore
@@ -428,7 +821,7 @@ func prepare(t *testing.T, limits *overrides.Limits, kvStore kv.Client) *Distrib
 
 	l := logging.Level{}
 	_ = l.Set("error")
-	d, err := New(distributorConfig, clientConfig, ingestersRing, overrides, true, l, false)
+	d, err := New(distributorConfig, clientConfig, ingestersRing, nil, overrides, true, l, false)
 	require.NoError(t, err)
 
 	return d
@@ -436,6 +829,42 @@ func prepare(t *testing.T, limits *overrides.Limits, kvStore kv.Client) *Distrib
 
 type mockIngester struct {
 	grpc_health_v1.HealthClient
+	tempopb.QuerierClient
+
+	notServing bool
+	// live traces per tenant returned by LiveTraceStats. the ids of all pushed traces are kept once set and
+	// pushes that reject new traces only append to them
+	liveMtx    sync.Mutex
+	liveTraces map[string]uint64
+	liveIDs    map[string]bool
+	// trace ids rejected as too large if the push allows partial success
+	rejectTraces map[string]bool
+	// pushes larger than this fail like in the grpc layer, 0 to disable
+	maxPushBytes int
+	pushes       atomic.Int32
+	// pushes take this long and are counted in sends if set
+	pushDelay time.Duration
+	sends     *concurrentSends
+}
+
+// concurrentSends tracks the number of concurrent pushes to a set of mock ingesters.
+type concurrentSends struct {
+	active atomic.Int32
+	max    atomic.Int32
+}
+
+func (s *concurrentSends) start() {
+	active := s.active.Inc()
+	for {
+		max := s.max.Load()
+		if active <= max || s.max.CAS(max, active) {
+			return
+		}
+	}
+}
+
+func (s *concurrentSends) done() {
+	s.active.Dec()
 }
 
 var _ tempopb.PusherClient = (*mockIngester)(nil)
@@ -445,7 +874,71 @@ func (i *mockIngester) Push(ctx context.Context, in *tempopb.PushRequest, opts .
 }
 
 func (i *mockIngester) PushBytes(ctx context.Context, in *tempopb.PushBytesRequest, opts ...grpc.CallOption) (*tempopb.PushResponse, error) {
-	return nil, nil
+	if i.maxPushBytes > 0 && in.Size() > i.maxPushBytes {
+		return nil, status.Errorf(codes.ResourceExhausted, "grpc: received message larger than max (%d vs. %d)", in.Size(), i.maxPushBytes)
+	}
+	i.pushes.Inc()
+	if i.sends != nil {
+		i.sends.start()
+		defer i.sends.done()
+	}
+	time.Sleep(i.pushDelay)
+
+	i.liveMtx.Lock()
+	defer i.liveMtx.Unlock()
+
+	resp := &tempopb.PushResponse{}
+	for j, id := range in.Ids {
+		if in.PartialSuccess && i.rejectTraces[string(id.Slice)] {
+			resp.TraceErrors = append(resp.TraceErrors, &tempopb.PushTraceError{
+				Index:  uint32(j),
+				Error:  overrides.ErrorPrefixTraceTooLarge + " max size of trace exceeded",
+				Reason: overrides.ReasonTraceTooLarge,
+			})
+			continue
+		}
+		if i.liveIDs == nil {
+			continue
+		}
+		if in.PartialSuccess && in.RejectNewTraces && !i.liveIDs[string(id.Slice)] {
+			resp.TraceErrors = append(resp.TraceErrors, &tempopb.PushTraceError{
+				Index:  uint32(j),
+				Error:  overrides.ErrorPrefixLiveTracesExceeded + " max live traces per tenant exceeded",
+				Reason: overrides.ReasonLiveTracesExceeded,
+			})
+			continue
+		}
+		i.liveIDs[string(id.Slice)] = true
+	}
+	return resp, nil
+}
+
+func (i *mockIngester) setLiveTraces(traces map[string]uint64) {
+	i.liveMtx.Lock()
+	defer i.liveMtx.Unlock()
+
+	i.liveTraces = traces
+	if i.liveIDs == nil {
+		i.liveIDs = map[string]bool{}
+	}
+}
+
+func (i *mockIngester) LiveTraceStats(ctx context.Context, in *tempopb.LiveTraceStatsRequest, opts ...grpc.CallOption) (*tempopb.LiveTraceStatsResponse, error) {
+	i.liveMtx.Lock()
+	defer i.liveMtx.Unlock()
+
+	resp := &tempopb.LiveTraceStatsResponse{}
+	for tenant, traces := range i.liveTraces {
+		resp.Tenants = append(resp.Tenants, &tempopb.TenantLiveTraceStats{TenantID: tenant, LiveTraces: traces})
+	}
+	return resp, nil
+}
+
+func (i *mockIngester) Check(ctx context.Context, in *grpc_health_v1.HealthCheckRequest, opts ...grpc.CallOption) (*grpc_health_v1.HealthCheckResponse, error) {
+	if i.notServing {
+		return &grpc_health_v1.HealthCheckResponse{Status: grpc_health_v1.HealthCheckResponse_NOT_SERVING}, nil
+	}
+	return &grpc_health_v1.HealthCheckResponse{Status: grpc_health_v1.HealthCheckResponse_SERVING}, nil
 }
 
 func (i *mockIngester) Close() error {
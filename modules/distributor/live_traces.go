@@ -0,0 +1,155 @@
+package distributor
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/ring"
+	ring_client "github.com/cortexproject/cortex/pkg/ring/client"
+	cortex_util "github.com/cortexproject/cortex/pkg/util/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+)
+
+var (
+	metricLiveTraces = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "tempo",
+		Name:      "distributor_live_traces",
+		Help:      "The number of live traces per tenant across all ingesters as of the last poll.",
+	}, []string{"tenant"})
+	metricLiveTracesPollFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "distributor_live_traces_poll_failures_total",
+		Help:      "The total number of failed requests for the live traces of an ingester.",
+	})
+)
+
+// liveTracesTracker periodically polls the live traces of every tenant from all ingesters. The counts are cached
+// between polls so the global live traces limit is checked on every push without a request to the ingesters.
+// Tenants at the limit are flagged in their pushes and the ingesters reject the traces that aren't live yet.
+type liveTracesTracker struct {
+	ring    ring.ReadRing
+	pool    *ring_client.Pool
+	period  time.Duration
+	timeout time.Duration
+
+	mtx    sync.RWMutex
+	traces map[string]uint64
+}
+
+func newLiveTracesTracker(r ring.ReadRing, pool *ring_client.Pool, period time.Duration, timeout time.Duration) *liveTracesTracker {
+	return &liveTracesTracker{
+		ring:    r,
+		pool:    pool,
+		period:  period,
+		timeout: timeout,
+		traces:  map[string]uint64{},
+	}
+}
+
+// liveTraces returns the number of live traces of the tenant across the cluster as of the last poll.
+func (t *liveTracesTracker) liveTraces(userID string) uint64 {
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+
+	return t.traces[userID]
+}
+
+func (t *liveTracesTracker) run(ctx context.Context) {
+	ticker := time.NewTicker(t.period)
+	defer ticker.Stop()
+
+	for {
+		t.poll(ctx)
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// poll requests the live traces from all healthy ingesters and replaces the cached counts. Every trace is pushed to
+// replication factor ingesters so the totals are divided by it. Ingesters that fail to respond are left out, which
+// undercounts in favor of the tenant until the next poll.
+func (t *liveTracesTracker) poll(ctx context.Context) {
+	rs, err := t.ring.GetAllHealthy(ring.Read)
+	if err != nil {
+		level.Warn(cortex_util.Logger).Log("msg", "failed to get ingesters to poll live traces", "err", err)
+		return
+	}
+
+	var (
+		wg     sync.WaitGroup
+		mtx    sync.Mutex
+		totals = map[string]uint64{}
+	)
+	for _, ingester := range rs.Instances {
+		wg.Add(1)
+		go func(addr string) {
+			defer wg.Done()
+
+			resp, err := t.pollIngester(ctx, addr)
+			if err != nil {
+				metricLiveTracesPollFailures.Inc()
+				level.Warn(cortex_util.Logger).Log("msg", "failed to poll live traces", "ingester", addr, "err", err)
+				return
+			}
+
+			mtx.Lock()
+			for _, s := range resp.Tenants {
+				totals[s.TenantID] += s.LiveTraces
+			}
+			mtx.Unlock()
+		}(ingester.Addr)
+	}
+	wg.Wait()
+
+	if rf := uint64(t.ring.ReplicationFactor()); rf > 1 {
+		for tenant := range totals {
+			totals[tenant] /= rf
+		}
+	}
+
+	t.mtx.Lock()
+	for tenant := range t.traces {
+		if _, ok := totals[tenant]; !ok {
+			metricLiveTraces.DeleteLabelValues(tenant)
+		}
+	}
+	for tenant, traces := range totals {
+		metricLiveTraces.WithLabelValues(tenant).Set(float64(traces))
+	}
+	t.traces = totals
+	t.mtx.Unlock()
+}
+
+func (t *liveTracesTracker) pollIngester(ctx context.Context, addr string) (*tempopb.LiveTraceStatsResponse, error) {
+	c, err := t.pool.GetClientFor(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	localCtx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	localCtx = user.InjectOrgID(localCtx, "fake") // the stats cover all tenants but the ingester requires an org id
+
+	return c.(tempopb.QuerierClient).LiveTraceStats(localCtx, &tempopb.LiveTraceStatsRequest{})
+}
+
+// rejectNewTraces returns whether the tenant reached max_global_traces_per_user as of the last poll. Only used with
+// the global live traces strategy.
+func (d *Distributor) rejectNewTraces(userID string) bool {
+	if d.liveTraces == nil {
+		return false
+	}
+
+	limit := d.overrides.MaxGlobalTracesPerUser(userID)
+	return limit > 0 && d.liveTraces.liveTraces(userID) >= uint64(limit)
+}
@@ -0,0 +1,129 @@
+package distributor
+
+import (
+	"runtime"
+	"sync"
+
+	"go.uber.org/atomic"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+)
+
+// minTracesPerMarshalWorker keeps small pushes from paying for goroutines they don't need.
+const minTracesPerMarshalWorker = 16
+
+var marshalBufferPool = sync.Pool{
+	New: func() interface{} {
+		return new([]byte)
+	},
+}
+
+// marshalledTraces are traces marshalled into buffers of marshalBufferPool. The buffers are returned to the pool by
+// release once every push of the traces is done. gRPC doesn't hold on to the request after a push returns. Buffers
+// that are never released, e.g. if the ring fails before pushing, are collected like any other allocation.
+type marshalledTraces struct {
+	traces  [][]byte
+	buffers []*[]byte
+	refs    atomic.Int32
+}
+
+// marshalTraces marshals traces with up to GOMAXPROCS goroutines. Every goroutine marshals a contiguous range of the
+// traces into a single pooled buffer, so marshalling doesn't allocate per trace once the pool is warm. The marshalled
+// traces keep the indexes of traces. The first error stops all goroutines and is returned.
+func marshalTraces(traces []*tempopb.Trace) (*marshalledTraces, error) {
+	workers := runtime.GOMAXPROCS(0)
+	if max := (len(traces) + minTracesPerMarshalWorker - 1) / minTracesPerMarshalWorker; max < workers {
+		workers = max
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	m := &marshalledTraces{
+		traces:  make([][]byte, len(traces)),
+		buffers: make([]*[]byte, workers),
+	}
+	m.refs.Store(1)
+
+	var (
+		wg     sync.WaitGroup
+		failed atomic.Bool
+		errs   = make([]error, workers)
+		sizes  = make([]int, len(traces))
+		per    = (len(traces) + workers - 1) / workers
+	)
+	for w := 0; w < workers; w++ {
+		start, end := w*per, (w+1)*per
+		if end > len(traces) {
+			end = len(traces)
+		}
+		m.buffers[w] = marshalBufferPool.Get().(*[]byte)
+
+		// the last range is marshalled by the calling goroutine
+		if w == workers-1 {
+			errs[w] = m.marshalRange(traces, sizes, start, end, m.buffers[w], &failed)
+			continue
+		}
+
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			errs[w] = m.marshalRange(traces, sizes, start, end, m.buffers[w], &failed)
+		}(w)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			m.release()
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+func (m *marshalledTraces) marshalRange(traces []*tempopb.Trace, sizes []int, start int, end int, buffer *[]byte, failed *atomic.Bool) error {
+	total := 0
+	for i := start; i < end; i++ {
+		sizes[i] = traces[i].Size()
+		total += sizes[i]
+	}
+	if cap(*buffer) < total {
+		*buffer = make([]byte, total)
+	}
+	b := (*buffer)[:total]
+
+	offset := 0
+	for i := start; i < end; i++ {
+		if failed.Load() {
+			return nil
+		}
+
+		// the capacity is limited so appending to a trace can't overwrite the next one
+		next := offset + sizes[i]
+		_, err := traces[i].MarshalToSizedBuffer(b[offset:next:next])
+		if err != nil {
+			failed.Store(true)
+			return err
+		}
+		m.traces[i] = b[offset:next:next]
+		offset = next
+	}
+	return nil
+}
+
+// retain adds a push that releases the traces.
+func (m *marshalledTraces) retain() {
+	m.refs.Inc()
+}
+
+// release returns the buffers to the pool once it was called by every push of the traces.
+func (m *marshalledTraces) release() {
+	if m.refs.Dec() > 0 {
+		return
+	}
+
+	for _, b := range m.buffers {
+		marshalBufferPool.Put(b)
+	}
+}
@@ -0,0 +1,83 @@
+package distributor
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+	"github.com/grafana/tempo/pkg/util/test"
+)
+
+func makeTraces(n int) []*tempopb.Trace {
+	traces := make([]*tempopb.Trace, n)
+	for i := range traces {
+		traces[i] = test.MakeTraceWithSpanCount(1, 5, []byte{byte(i >> 8), byte(i)})
+	}
+	return traces
+}
+
+func TestMarshalTraces(t *testing.T) {
+	for _, n := range []int{0, 1, minTracesPerMarshalWorker + 1, 500} {
+		t.Run(fmt.Sprint(n), func(t *testing.T) {
+			traces := makeTraces(n)
+
+			// marshal twice so the second time reuses the pooled buffers
+			for i := 0; i < 2; i++ {
+				m, err := marshalTraces(traces)
+				require.NoError(t, err)
+				require.Len(t, m.traces, n)
+
+				for j, trace := range traces {
+					expected, err := trace.Marshal()
+					require.NoError(t, err)
+					assert.Equal(t, expected, m.traces[j])
+					assert.Equal(t, len(m.traces[j]), cap(m.traces[j]))
+				}
+				m.release()
+			}
+		})
+	}
+}
+
+func TestMarshalledTracesRelease(t *testing.T) {
+	m, err := marshalTraces(makeTraces(1))
+	require.NoError(t, err)
+
+	m.retain()
+	m.release()
+	assert.Equal(t, int32(1), m.refs.Load())
+	m.release()
+	assert.Equal(t, int32(0), m.refs.Load())
+}
+
+func BenchmarkMarshalTraces(b *testing.B) {
+	traces := makeTraces(500)
+
+	b.Run("serial", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			marshalled := make([][]byte, len(traces))
+			for j, t := range traces {
+				bytes, err := t.Marshal()
+				if err != nil {
+					b.Fatal(err)
+				}
+				marshalled[j] = bytes
+			}
+		}
+	})
+
+	b.Run("parallel", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			m, err := marshalTraces(traces)
+			if err != nil {
+				b.Fatal(err)
+			}
+			m.release()
+		}
+	})
+}
@@ -0,0 +1,102 @@
+package distributor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+)
+
+// traceRejections collects the traces of a push that the ingesters rejected because of a per tenant limit. The
+// other traces of the push are still appended.
+type traceRejections struct {
+	mtx               sync.Mutex
+	replicationFactor int
+	traces            map[int]*traceRejection
+}
+
+type traceRejection struct {
+	replicas int   // the number of ingesters that rejected the trace
+	err      error // the first error returned for the trace
+}
+
+func newTraceRejections(replicationFactor int) *traceRejections {
+	return &traceRejections{
+		replicationFactor: replicationFactor,
+		traces:            map[int]*traceRejection{},
+	}
+}
+
+func (r *traceRejections) add(trace int, err error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	rejection, ok := r.traces[trace]
+	if !ok {
+		rejection = &traceRejection{err: err}
+		r.traces[trace] = rejection
+	}
+	rejection.replicas++
+}
+
+// rejected returns the errors of the traces rejected by so many ingesters that fewer than a quorum stored them,
+// keyed by the index of the trace. Like a failed write, a trace stored by less than a quorum counts as rejected.
+func (r *traceRejections) rejected() map[int]error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	minSuccess := r.replicationFactor/2 + 1
+	maxRejections := r.replicationFactor - minSuccess
+
+	rejected := map[int]error{}
+	for trace, rejection := range r.traces {
+		if rejection.replicas > maxRejections {
+			rejected[trace] = rejection.err
+		}
+	}
+	return rejected
+}
+
+// partialSuccess records the spans of the rejected traces as discarded and returns a response describing them. If
+// every trace was rejected the push fails with the error of the first trace instead.
+func partialSuccess(userID string, traces []*tempopb.Trace, rejected map[int]error) (*tempopb.PushResponse, error) {
+	if len(rejected) == 0 {
+		return nil, nil
+	}
+
+	indexes := make([]int, 0, len(rejected))
+	for i := range rejected {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+
+	reasons := map[string]int{}
+	rejectedSpans := 0
+	for _, i := range indexes {
+		reason := discardReason(rejected[i])
+		spans := countSpans(traces[i])
+
+		metricDiscardedSpans.WithLabelValues(reason, userID).Add(float64(spans))
+		reasons[reason]++
+		rejectedSpans += spans
+	}
+
+	if len(rejected) == len(traces) {
+		return nil, rejected[indexes[0]]
+	}
+
+	counts := make([]string, 0, len(reasons))
+	for reason, count := range reasons {
+		counts = append(counts, fmt.Sprintf("%s: %d", reason, count))
+	}
+	sort.Strings(counts)
+
+	return &tempopb.PushResponse{
+		PartialSuccess: &tempopb.PushPartialSuccess{
+			RejectedSpans: int64(rejectedSpans),
+			ErrorMessage:  fmt.Sprintf("%d of %d traces rejected (%s). first error: %s", len(rejected), len(traces), strings.Join(counts, ", "), rejected[indexes[0]].Error()),
+		},
+	}, nil
+}
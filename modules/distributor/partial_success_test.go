@@ -0,0 +1,39 @@
+package distributor
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTraceRejections(t *testing.T) {
+	tests := []struct {
+		name              string
+		replicationFactor int
+		rejections        int
+		expectedRejected  bool
+	}{
+		{name: "rf 1", replicationFactor: 1, rejections: 1, expectedRejected: true},
+		{name: "rf 3 stored by a quorum", replicationFactor: 3, rejections: 1, expectedRejected: false},
+		{name: "rf 3 stored by less than a quorum", replicationFactor: 3, rejections: 2, expectedRejected: true},
+		{name: "rf 3 rejected by all", replicationFactor: 3, rejections: 3, expectedRejected: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := errors.New("error")
+			r := newTraceRejections(tc.replicationFactor)
+			for i := 0; i < tc.rejections; i++ {
+				r.add(1, err)
+			}
+
+			rejected := r.rejected()
+			if tc.expectedRejected {
+				assert.Equal(t, map[int]error{1: err}, rejected)
+			} else {
+				assert.Empty(t, rejected)
+			}
+		})
+	}
+}
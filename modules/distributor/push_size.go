@@ -0,0 +1,58 @@
+package distributor
+
+import (
+	"github.com/gogo/protobuf/proto"
+
+	"github.com/grafana/tempo/modules/overrides"
+	"github.com/grafana/tempo/pkg/tempopb"
+)
+
+// splitPush groups the traces sent to an ingester into pushes the ingester client is allowed to send, so a large
+// push doesn't fail as a whole with an opaque "message larger than max" error from the gRPC layer. Traces that
+// don't fit in a push by themselves are returned as tooLarge. A maxBytes of 0 or less doesn't split.
+func splitPush(req *tempopb.PushBytesRequest, traceIndexes []int, marshalledTraces [][]byte, ids [][]byte, searchData [][]byte, maxBytes int) (pushes [][]int, tooLarge []int) {
+	if maxBytes <= 0 {
+		return [][]int{traceIndexes}, nil
+	}
+
+	// the fields other than the traces, ids and search data
+	overhead := req.Size()
+
+	var push []int
+	size := overhead
+	for _, j := range traceIndexes {
+		entry := bytesFieldSize(len(marshalledTraces[j])) + bytesFieldSize(len(ids[j]))
+		if len(searchData) > j {
+			entry += bytesFieldSize(len(searchData[j]))
+		} else {
+			entry += bytesFieldSize(0)
+		}
+
+		if overhead+entry > maxBytes {
+			tooLarge = append(tooLarge, j)
+			continue
+		}
+
+		if size+entry > maxBytes {
+			pushes = append(pushes, push)
+			push = nil
+			size = overhead
+		}
+		push = append(push, j)
+		size += entry
+	}
+
+	if len(push) > 0 {
+		pushes = append(pushes, push)
+	}
+	return pushes, tooLarge
+}
+
+// bytesFieldSize is the size of an element of a repeated bytes field of length l in a marshalled message.
+func bytesFieldSize(l int) int {
+	return 1 + proto.SizeVarint(uint64(l)) + l
+}
+
+func traceTooLargeToSendError(size int, maxBytes int) error {
+	return overrides.NewLimitError(overrides.ErrTraceTooLarge, "trace of %d bytes in a single push exceeds the max message size of the ingester client (ingester_client.grpc_client_config.max_send_msg_size: %d)", size, maxBytes)
+}
@@ -0,0 +1,65 @@
+package distributor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+)
+
+func TestSplitPush(t *testing.T) {
+	req := &tempopb.PushBytesRequest{PartialSuccess: true, IngestionTimeUnixNano: 1}
+	traces := [][]byte{make([]byte, 10), make([]byte, 10), make([]byte, 100), make([]byte, 10)}
+	ids := [][]byte{{0x01}, {0x02}, {0x03}, {0x04}}
+
+	// the size of a push with a single trace of 10 bytes
+	single := *req
+	single.Traces = []tempopb.PreallocBytes{{Slice: traces[0]}}
+	single.Ids = []tempopb.PreallocBytes{{Slice: ids[0]}}
+	single.SearchData = []tempopb.PreallocBytes{{}}
+	singleSize := single.Size()
+	entrySize := singleSize - req.Size()
+
+	tests := []struct {
+		name             string
+		maxBytes         int
+		expectedPushes   [][]int
+		expectedTooLarge []int
+	}{
+		{
+			name:           "no limit",
+			expectedPushes: [][]int{{0, 1, 2, 3}},
+		},
+		{
+			name:           "everything fits",
+			maxBytes:       1000,
+			expectedPushes: [][]int{{0, 1, 2, 3}},
+		},
+		{
+			name:             "two small traces per push",
+			maxBytes:         singleSize + entrySize,
+			expectedPushes:   [][]int{{0, 1}, {3}},
+			expectedTooLarge: []int{2},
+		},
+		{
+			name:             "one small trace per push",
+			maxBytes:         singleSize,
+			expectedPushes:   [][]int{{0}, {1}, {3}},
+			expectedTooLarge: []int{2},
+		},
+		{
+			name:             "nothing fits",
+			maxBytes:         singleSize - 1,
+			expectedTooLarge: []int{0, 1, 2, 3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pushes, tooLarge := splitPush(req, []int{0, 1, 2, 3}, traces, ids, nil, tt.maxBytes)
+			assert.Equal(t, tt.expectedPushes, pushes)
+			assert.Equal(t, tt.expectedTooLarge, tooLarge)
+		})
+	}
+}
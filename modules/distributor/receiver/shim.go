@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"contrib.go.opencensus.io/exporter/prometheus"
@@ -31,6 +33,8 @@ import (
 	"go.opentelemetry.io/collector/receiver/zipkinreceiver"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
 
 	"github.com/grafana/tempo/pkg/tempopb"
 	tempo_util "github.com/grafana/tempo/pkg/util"
@@ -38,25 +42,38 @@ import (
 
 const (
 	logsPerSecond = 10
+
+	// the partial success of a push is returned in these response headers. the OTLP HTTP receiver prefixes them
+	//  with Grpc-Metadata-
+	headerRejectedSpans         = "tempo-rejected-spans"
+	headerPartialSuccessMessage = "tempo-partial-success"
 )
 
 type receiversShim struct {
 	services.Service
 
 	multitenancyEnabled bool
+	tokenAuth           *tokenAuth
 	receivers           []component.Receiver
 	pusher              tempopb.PusherServer
 	logger              *tempo_util.RateLimitedLogger
 	metricViews         []*view.View
 }
 
-func New(receiverCfg map[string]interface{}, pusher tempopb.PusherServer, multitenancyEnabled bool, logLevel logging.Level) (services.Service, error) {
+func New(receiverCfg map[string]interface{}, pusher tempopb.PusherServer, multitenancyEnabled bool, tokenAuthCfg TokenAuthConfig, tokens PushTokens, logLevel logging.Level) (services.Service, error) {
 	shim := &receiversShim{
 		multitenancyEnabled: multitenancyEnabled,
 		pusher:              pusher,
 		logger:              tempo_util.NewRateLimitedLogger(logsPerSecond, level.Error(log.Logger)),
 	}
 
+	if tokenAuthCfg.Enabled {
+		if !multitenancyEnabled {
+			return nil, fmt.Errorf("push token auth requires multitenancy to be enabled")
+		}
+		shim.tokenAuth = &tokenAuth{cfg: tokenAuthCfg, tokens: tokens}
+	}
+
 	v := viper.New()
 	err := v.MergeConfigMap(map[string]interface{}{
 		"receivers": receiverCfg,
@@ -154,9 +171,24 @@ func (r *receiversShim) stopping(_ error) error {
 
 // implements consumer.TraceConsumer
 func (r *receiversShim) ConsumeTraces(ctx context.Context, td pdata.Traces) error {
-	if !r.multitenancyEnabled {
+	// the receivers don't take custom gRPC interceptors, the token of the request is read from the incoming metadata
+	//  of the unary or streaming call instead
+	authenticated := false
+	if r.tokenAuth != nil {
+		var err error
+		ctx, authenticated, err = r.tokenAuth.authenticate(ctx)
+		if err != nil {
+			r.logger.Log("msg", "failed to authenticate push", "err", err)
+			return err
+		}
+	}
+
+	switch {
+	case authenticated:
+		// the tenant of the token replaces the X-Scope-OrgID header
+	case !r.multitenancyEnabled:
 		ctx = user.InjectOrgID(ctx, tempo_util.FakeTenantID)
-	} else {
+	default:
 		var err error
 		_, ctx, err = user.ExtractFromGRPCRequest(ctx)
 		if err != nil {
@@ -182,19 +214,45 @@ func (r *receiversShim) ConsumeTraces(ctx context.Context, td pdata.Traces) erro
 		return err
 	}
 
+	var (
+		rejectedSpans int64
+		messages      []string
+	)
 	for _, batch := range trace.Batches {
-		_, err = r.pusher.Push(ctx, &tempopb.PushRequest{
+		var resp *tempopb.PushResponse
+		resp, err = r.pusher.Push(ctx, &tempopb.PushRequest{
 			Batch: batch,
 		})
 		if err != nil {
 			r.logger.Log("msg", "pusher failed to consume trace data", "err", err)
 			break
 		}
+
+		if ps := resp.GetPartialSuccess(); ps != nil && ps.RejectedSpans > 0 {
+			rejectedSpans += ps.RejectedSpans
+			messages = append(messages, ps.ErrorMessage)
+		}
+	}
+
+	if err == nil && rejectedSpans > 0 {
+		message := strings.Join(messages, "; ")
+		r.logger.Log("msg", "pusher rejected some of the trace data", "rejectedSpans", rejectedSpans, "err", message)
+		setPartialSuccess(ctx, rejectedSpans, message)
 	}
 
 	return err
 }
 
+// setPartialSuccess returns the partial success of a push to the client in the response headers. The receivers
+// return an empty response and the vendored collector predates the OTLP ExportTracePartialSuccess message. Receivers
+// not served by gRPC or the gRPC gateway, like the jaeger thrift receivers, have no way to return it.
+func setPartialSuccess(ctx context.Context, rejectedSpans int64, message string) {
+	_ = grpc.SetHeader(ctx, metadata.Pairs(
+		headerRejectedSpans, strconv.FormatInt(rejectedSpans, 10),
+		headerPartialSuccessMessage, message,
+	))
+}
+
 // implements component.Host
 func (r *receiversShim) ReportFatalError(err error) {
 	level.Error(log.Logger).Log("msg", "fatal error reported", "err", err)
@@ -0,0 +1,197 @@
+package receiver
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/gogo/protobuf/proto"
+	"github.com/grpc-ecosystem/grpc-gateway/runtime"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+	"go.opentelemetry.io/collector/consumer/pdata"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+	tempo_util "github.com/grafana/tempo/pkg/util"
+	"github.com/grafana/tempo/pkg/util/test"
+)
+
+type mockPusher struct {
+	reqs    []*tempopb.PushRequest
+	tenants []string
+	resp    *tempopb.PushResponse
+}
+
+func (m *mockPusher) Push(ctx context.Context, req *tempopb.PushRequest) (*tempopb.PushResponse, error) {
+	m.reqs = append(m.reqs, req)
+	tenant, _ := user.ExtractOrgID(ctx)
+	m.tenants = append(m.tenants, tenant)
+	return m.resp, nil
+}
+
+func (m *mockPusher) PushBytes(context.Context, *tempopb.PushBytesRequest) (*tempopb.PushResponse, error) {
+	return &tempopb.PushResponse{}, nil
+}
+
+func TestShimPreservesAllFields(t *testing.T) {
+	pusher := &mockPusher{}
+	shim := &receiversShim{
+		pusher: pusher,
+		logger: tempo_util.NewRateLimitedLogger(logsPerSecond, log.NewNopLogger()),
+	}
+
+	expected := test.MakeTraceWithAllFields(nil)
+
+	// receivers hand the shim pdata.Traces, which is wire-compatible with tempopb.Trace
+	b, err := proto.Marshal(expected)
+	require.NoError(t, err)
+	td := pdata.NewTraces()
+	require.NoError(t, td.FromOtlpProtoBytes(b))
+
+	err = shim.ConsumeTraces(context.Background(), td)
+	require.NoError(t, err)
+
+	require.Len(t, pusher.reqs, 1)
+	require.True(t, proto.Equal(expected.Batches[0], pusher.reqs[0].Batch))
+}
+
+func TestShimPartialSuccess(t *testing.T) {
+	pusher := &mockPusher{
+		resp: &tempopb.PushResponse{
+			PartialSuccess: &tempopb.PushPartialSuccess{
+				RejectedSpans: 3,
+				ErrorMessage:  "1 of 2 traces rejected",
+			},
+		},
+	}
+	shim := &receiversShim{
+		pusher: pusher,
+		logger: tempo_util.NewRateLimitedLogger(logsPerSecond, log.NewNopLogger()),
+	}
+
+	trace := test.MakeTrace(2, nil)
+	b, err := proto.Marshal(trace)
+	require.NoError(t, err)
+	td := pdata.NewTraces()
+	require.NoError(t, td.FromOtlpProtoBytes(b))
+
+	// the stream the gRPC gateway uses for the OTLP HTTP receiver
+	stream := &runtime.ServerTransportStream{}
+	ctx := grpc.NewContextWithServerTransportStream(context.Background(), stream)
+
+	err = shim.ConsumeTraces(ctx, td)
+	require.NoError(t, err)
+
+	require.Len(t, pusher.reqs, 2)
+	assert.Equal(t, []string{"6"}, stream.Header().Get(headerRejectedSpans))
+	assert.Equal(t, []string{"1 of 2 traces rejected; 1 of 2 traces rejected"}, stream.Header().Get(headerPartialSuccessMessage))
+
+	// receivers that are not served by grpc are unaffected
+	err = shim.ConsumeTraces(context.Background(), td)
+	require.NoError(t, err)
+}
+
+type mockPushTokens map[string]map[string]string
+
+func (m mockPushTokens) ForEachPushToken(fn func(tenantID, tokenID, hash string)) {
+	for tenantID, tokens := range m {
+		for tokenID, hash := range tokens {
+			fn(tenantID, tokenID, hash)
+		}
+	}
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestShimTokenAuth(t *testing.T) {
+	tokens := mockPushTokens{
+		"team-a": {"ci": hashToken("token-a")},
+		"team-b": {"prod": strings.ToUpper(hashToken("token-b")), "shared": hashToken("token-shared")},
+		"team-c": {"shared": hashToken("token-shared")},
+	}
+
+	tests := []struct {
+		name           string
+		required       bool
+		md             metadata.MD
+		expectedTenant string
+		expectedErr    error
+	}{
+		{
+			name:           "grpc token",
+			md:             metadata.Pairs("authorization", "Bearer token-a"),
+			expectedTenant: "team-a",
+		},
+		{
+			name:           "token replaces org id",
+			md:             metadata.Pairs("authorization", "bearer token-b", user.OrgIDHeaderName, "team-a"),
+			expectedTenant: "team-b",
+		},
+		{
+			name:           "gateway token",
+			md:             metadata.Pairs(headerGatewayAuthorization, "Bearer token-a"),
+			expectedTenant: "team-a",
+		},
+		{
+			name:           "org id without token",
+			md:             metadata.Pairs(user.OrgIDHeaderName, "team-c"),
+			expectedTenant: "team-c",
+		},
+		{
+			name:        "required token",
+			required:    true,
+			md:          metadata.Pairs(user.OrgIDHeaderName, "team-c"),
+			expectedErr: errMissingPushToken,
+		},
+		{
+			name:        "invalid token",
+			md:          metadata.Pairs("authorization", "Bearer token-x", user.OrgIDHeaderName, "team-a"),
+			expectedErr: errInvalidPushToken,
+		},
+		{
+			name:        "token of several tenants",
+			md:          metadata.Pairs("authorization", "Bearer token-shared"),
+			expectedErr: errInvalidPushToken,
+		},
+	}
+
+	trace := test.MakeTrace(1, nil)
+	b, err := proto.Marshal(trace)
+	require.NoError(t, err)
+	td := pdata.NewTraces()
+	require.NoError(t, td.FromOtlpProtoBytes(b))
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			pusher := &mockPusher{}
+			shim := &receiversShim{
+				multitenancyEnabled: true,
+				tokenAuth:           &tokenAuth{cfg: TokenAuthConfig{Enabled: true, Required: tc.required}, tokens: tokens},
+				pusher:              pusher,
+				logger:              tempo_util.NewRateLimitedLogger(logsPerSecond, log.NewNopLogger()),
+			}
+
+			err := shim.ConsumeTraces(metadata.NewIncomingContext(context.Background(), tc.md), td)
+			if tc.expectedErr != nil {
+				assert.Equal(t, tc.expectedErr, err)
+				assert.Equal(t, codes.Unauthenticated, status.Code(err))
+				assert.Empty(t, pusher.reqs)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, []string{tc.expectedTenant}, pusher.tenants)
+		})
+	}
+}
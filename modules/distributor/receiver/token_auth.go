@@ -0,0 +1,122 @@
+package receiver
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"flag"
+	"strings"
+
+	"github.com/grpc-ecosystem/grpc-gateway/runtime"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/weaveworks/common/user"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	headerAuthorization = "authorization"
+	// the gRPC gateway of the OTLP HTTP receiver forwards the Authorization header with this prefix
+	headerGatewayAuthorization = runtime.MetadataPrefix + headerAuthorization
+
+	bearerPrefix = "bearer "
+)
+
+var (
+	metricPushTokenRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "distributor_push_token_requests_total",
+		Help:      "The total number of pushes authenticated by each push token.",
+	}, []string{"tenant", "token"})
+	metricPushTokenRejected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "distributor_push_token_rejected_total",
+		Help:      "The total number of pushes rejected because of an invalid or missing push token.",
+	}, []string{"reason"})
+
+	errMissingPushToken = status.Error(codes.Unauthenticated, "missing push token")
+	errInvalidPushToken = status.Error(codes.Unauthenticated, "invalid push token")
+)
+
+// TokenAuthConfig configures the authentication of pushes with the bearer tokens of the push_tokens overrides.
+type TokenAuthConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// rejects pushes without a token instead of reading the tenant from the X-Scope-OrgID header
+	Required bool `yaml:"required"`
+}
+
+// RegisterFlagsAndApplyDefaults registers flags and applies defaults
+func (cfg *TokenAuthConfig) RegisterFlagsAndApplyDefaults(prefix string, f *flag.FlagSet) {
+	f.BoolVar(&cfg.Enabled, prefix+".enabled", false, "Authenticate pushes with the bearer tokens of the push_tokens overrides.")
+	f.BoolVar(&cfg.Required, prefix+".required", false, "Reject pushes without a bearer token instead of reading the tenant from the X-Scope-OrgID header.")
+}
+
+// PushTokens iterates the push tokens of all tenants. It's implemented by the overrides, which reload them with the
+// runtime config.
+type PushTokens interface {
+	ForEachPushToken(fn func(tenantID, tokenID, hash string))
+}
+
+type tokenAuth struct {
+	cfg    TokenAuthConfig
+	tokens PushTokens
+}
+
+// authenticate injects the tenant of the bearer token of the request into the context. authenticated is false if the
+// request has no token and the tenant should be read from the X-Scope-OrgID header as usual.
+func (a *tokenAuth) authenticate(ctx context.Context) (_ context.Context, authenticated bool, err error) {
+	token, ok := bearerToken(ctx)
+	if !ok {
+		if a.cfg.Required {
+			metricPushTokenRejected.WithLabelValues("missing").Inc()
+			return ctx, false, errMissingPushToken
+		}
+		return ctx, false, nil
+	}
+
+	tenantID, tokenID, ok := a.lookup(token)
+	if !ok {
+		metricPushTokenRejected.WithLabelValues("invalid").Inc()
+		return ctx, false, errInvalidPushToken
+	}
+
+	metricPushTokenRequests.WithLabelValues(tenantID, tokenID).Inc()
+	return user.InjectOrgID(ctx, tenantID), true, nil
+}
+
+// lookup returns the tenant and id of the token. The hash of the token is compared to every configured hash in
+// constant time, so the time taken doesn't tell how close the token is to a valid one. A hash configured for more
+// than one tenant is rejected.
+func (a *tokenAuth) lookup(token string) (tenantID, tokenID string, ok bool) {
+	sum := sha256.Sum256([]byte(token))
+	hash := []byte(hex.EncodeToString(sum[:]))
+
+	matches := 0
+	a.tokens.ForEachPushToken(func(tenant, id, h string) {
+		if subtle.ConstantTimeCompare(hash, []byte(strings.ToLower(h))) == 1 {
+			matches++
+			tenantID, tokenID = tenant, id
+		}
+	})
+
+	return tenantID, tokenID, matches == 1
+}
+
+// bearerToken returns the bearer token of the authorization metadata of a gRPC request, or of the Authorization
+// header forwarded by the gRPC gateway.
+func bearerToken(ctx context.Context) (string, bool) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	for _, key := range []string{headerAuthorization, headerGatewayAuthorization} {
+		for _, v := range md.Get(key) {
+			if len(v) > len(bearerPrefix) && strings.EqualFold(v[:len(bearerPrefix)], bearerPrefix) {
+				return strings.TrimSpace(v[len(bearerPrefix):]), true
+			}
+		}
+	}
+
+	return "", false
+}
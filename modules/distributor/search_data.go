@@ -1,107 +1,72 @@
 package distributor
 
 import (
-	"fmt"
-	"strconv"
+	"time"
 
-	"github.com/grafana/tempo/pkg/tempofb"
 	"github.com/grafana/tempo/pkg/tempopb"
-	common_v1 "github.com/grafana/tempo/pkg/tempopb/common/v1"
 	"github.com/grafana/tempo/tempodb/search"
 )
 
-// extractSearchDataAll returns flatbuffer search data for every trace.
-func extractSearchDataAll(traces []*tempopb.Trace, ids [][]byte) [][]byte {
-	headers := make([][]byte, len(traces))
-
-	for i, t := range traces {
-		headers[i] = extractSearchData(t, ids[i])
-	}
-
-	return headers
+// asyncSearchData is the result of extracting search data off the push critical path.
+type asyncSearchData struct {
+	done chan struct{}
+	data [][]byte
 }
 
-// extractSearchData returns the flatbuffer search data for the given trace.  It is extracted here
-// in the distributor because this is the only place on the ingest path where the trace is available
-// in object form.
-func extractSearchData(trace *tempopb.Trace, id []byte) []byte {
-	data := &tempofb.SearchEntryMutable{}
-
-	data.TraceID = id
-
-	for _, b := range trace.Batches {
-		// Batch attrs
-		if b.Resource != nil {
-			for _, a := range b.Resource.Attributes {
-				if s, ok := extractValueAsString(a.Value); ok {
-					data.AddTag(a.Key, s)
-				}
-			}
-		}
-
-		for _, ils := range b.InstrumentationLibrarySpans {
-			for _, s := range ils.Spans {
-
-				// Root span
-				if len(s.ParentSpanId) == 0 {
-
-					data.AddTag(search.RootSpanNameTag, s.Name)
-
-					// Span attrs
-					for _, a := range s.Attributes {
-						if s, ok := extractValueAsString(a.Value); ok {
-							data.AddTag(fmt.Sprint(search.RootSpanPrefix, a.Key), s)
-						}
-					}
-
-					// Batch attrs
-					if b.Resource != nil {
-						for _, a := range b.Resource.Attributes {
-							if s, ok := extractValueAsString(a.Value); ok {
-								data.AddTag(fmt.Sprint(search.RootSpanPrefix, a.Key), s)
-							}
-						}
-					}
-				}
-
-				// Collect for any spans
-				data.AddTag(search.SpanNameTag, s.Name)
-				data.SetStartTimeUnixNano(s.StartTimeUnixNano)
-				data.SetEndTimeUnixNano(s.EndTimeUnixNano)
+// extractSearchDataAsync starts extracting search data in a goroutine if a slot is available on the
+// semaphore. If no slot is available the data is extracted synchronously so that the number of
+// in-flight extractions stays bounded. A nil semaphore always extracts synchronously.
+func extractSearchDataAsync(sem chan struct{}, traces []*tempopb.Trace, ids [][]byte) *asyncSearchData {
+	a := &asyncSearchData{
+		done: make(chan struct{}),
+	}
 
-				for _, a := range s.Attributes {
-					if s, ok := extractValueAsString(a.Value); ok {
-						data.AddTag(a.Key, s)
-					}
-				}
-			}
-		}
+	select {
+	case sem <- struct{}{}:
+		go func() {
+			defer func() { <-sem }()
+			a.data = extractSearchDataAll(traces, ids)
+			close(a.done)
+		}()
+	default:
+		a.data = extractSearchDataAll(traces, ids)
+		close(a.done)
 	}
 
-	return data.ToBytes()
+	return a
 }
 
-func extractValueAsString(v *common_v1.AnyValue) (s string, ok bool) {
-	vv := v.GetValue()
-	if vv == nil {
-		return "", false
+// wait returns the extracted search data. If extraction did not complete within the timeout
+// it returns false and the caller should proceed without search data.
+func (a *asyncSearchData) wait(timeout time.Duration) ([][]byte, bool) {
+	if a == nil {
+		return nil, true
 	}
 
-	if s, ok := vv.(*common_v1.AnyValue_StringValue); ok {
-		return s.StringValue, true
+	select {
+	case <-a.done:
+		return a.data, true
+	default:
 	}
 
-	if b, ok := vv.(*common_v1.AnyValue_BoolValue); ok {
-		return strconv.FormatBool(b.BoolValue), true
-	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
 
-	if i, ok := vv.(*common_v1.AnyValue_IntValue); ok {
-		return strconv.FormatInt(i.IntValue, 10), true
+	select {
+	case <-a.done:
+		return a.data, true
+	case <-timer.C:
+		return nil, false
 	}
+}
 
-	if d, ok := vv.(*common_v1.AnyValue_DoubleValue); ok {
-		return strconv.FormatFloat(d.DoubleValue, 'g', -1, 64), true
+// extractSearchDataAll returns flatbuffer search data for every trace.
+func extractSearchDataAll(traces []*tempopb.Trace, ids [][]byte) [][]byte {
+	headers := make([][]byte, len(traces))
+
+	for i, t := range traces {
+		headers[i] = search.ExtractSearchData(t, ids[i])
 	}
 
-	return "", false
+	return headers
 }
@@ -2,83 +2,47 @@ package distributor
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
-	"github.com/grafana/tempo/pkg/tempofb"
 	"github.com/grafana/tempo/pkg/tempopb"
-	v1_common "github.com/grafana/tempo/pkg/tempopb/common/v1"
-	v1_resource "github.com/grafana/tempo/pkg/tempopb/resource/v1"
-	v1 "github.com/grafana/tempo/pkg/tempopb/trace/v1"
-	"github.com/grafana/tempo/tempodb/search"
+	"github.com/grafana/tempo/pkg/util/test"
 )
 
-func TestExtractSearchData(t *testing.T) {
-	traceIDA := []byte{0x0A, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0A, 0x0B, 0x0C, 0x0D, 0x0E, 0x0F}
+func TestExtractSearchDataAsync(t *testing.T) {
+	id := []byte{0x0A, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0A, 0x0B, 0x0C, 0x0D, 0x0E, 0x0F}
+	traces := []*tempopb.Trace{test.MakeTrace(10, id)}
+	ids := [][]byte{id}
+	expected := extractSearchDataAll(traces, ids)
 
-	testCases := []struct {
-		name       string
-		trace      *tempopb.Trace
-		id         []byte
-		searchData *tempofb.SearchEntryMutable
-	}{
-		{
-			name: "trace with root span",
-			trace: &tempopb.Trace{
-				Batches: []*v1.ResourceSpans{
-					{
-						Resource: &v1_resource.Resource{
-							Attributes: []*v1_common.KeyValue{
-								{
-									Key: "foo",
-									Value: &v1_common.AnyValue{
-										Value: &v1_common.AnyValue_StringValue{StringValue: "bar"},
-									},
-								},
-								{
-									Key: "service.name",
-									Value: &v1_common.AnyValue{
-										Value: &v1_common.AnyValue_StringValue{StringValue: "baz"},
-									},
-								},
-							},
-						},
-						InstrumentationLibrarySpans: []*v1.InstrumentationLibrarySpans{
-							{
-								InstrumentationLibrary: &v1_common.InstrumentationLibrary{
-									Name: "test",
-								},
-								Spans: []*v1.Span{
-									{
-										TraceId: traceIDA,
-										Name:    "firstSpan",
-									},
-								},
-							},
-						},
-					},
-				},
-			},
-			id: traceIDA,
-			searchData: &tempofb.SearchEntryMutable{
-				TraceID: traceIDA,
-				Tags: tempofb.SearchDataMap{
-					"foo":                         []string{"bar"},
-					search.RootSpanPrefix + "foo": []string{"bar"},
-					search.RootSpanNameTag:        []string{"firstSpan"},
-					search.SpanNameTag:            []string{"firstSpan"},
-					search.RootServiceNameTag:     []string{"baz"},
-					search.ServiceNameTag:         []string{"baz"},
-				},
-				StartTimeUnixNano: 0,
-				EndTimeUnixNano:   0,
-			},
-		},
-	}
+	// async
+	sem := make(chan struct{}, 1)
+	data, ok := extractSearchDataAsync(sem, traces, ids).wait(time.Second)
+	require.True(t, ok)
+	assert.Equal(t, expected, data)
 
-	for _, tc := range testCases {
-		t.Run(tc.name, func(t *testing.T) {
-			assert.Equal(t, tc.searchData.ToBytes(), extractSearchData(tc.trace, tc.id))
-		})
-	}
+	// semaphore full falls back to synchronous
+	sem <- struct{}{}
+	a := extractSearchDataAsync(sem, traces, ids)
+	data, ok = a.wait(0)
+	require.True(t, ok)
+	assert.Equal(t, expected, data)
+
+	// nil semaphore is synchronous
+	data, ok = extractSearchDataAsync(nil, traces, ids).wait(0)
+	require.True(t, ok)
+	assert.Equal(t, expected, data)
+
+	// extraction not completed in time
+	a = &asyncSearchData{done: make(chan struct{})}
+	data, ok = a.wait(10 * time.Millisecond)
+	assert.False(t, ok)
+	assert.Nil(t, data)
+
+	// search disabled
+	data, ok = (*asyncSearchData)(nil).wait(0)
+	assert.True(t, ok)
+	assert.Nil(t, data)
 }
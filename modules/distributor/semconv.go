@@ -0,0 +1,162 @@
+package distributor
+
+import (
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	v1_common "github.com/grafana/tempo/pkg/tempopb/common/v1"
+	v1 "github.com/grafana/tempo/pkg/tempopb/trace/v1"
+)
+
+var metricAttributesRenamed = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "tempo",
+	Name:      "distributor_attributes_renamed_total",
+	Help:      "The total number of span and resource attributes renamed by each attribute normalization rule.",
+}, []string{"tenant", "rule"})
+
+// DefaultAttributeRenames maps deprecated OpenTelemetry semantic convention attributes to their current names.
+// It is used when attribute normalization is enabled for a tenant without a mapping of its own. The network attributes
+// of the host and the peer are renamed by the kind of the span in addition, see sideAttributeRenames.
+var DefaultAttributeRenames = map[string]string{
+	"http.method":                  "http.request.method",
+	"http.status_code":             "http.response.status_code",
+	"http.url":                     "url.full",
+	"http.scheme":                  "url.scheme",
+	"http.target":                  urlPathAttribute,
+	"http.user_agent":              "user_agent.original",
+	"http.client_ip":               "client.address",
+	"http.request_content_length":  "http.request.body.size",
+	"http.response_content_length": "http.response.body.size",
+	"net.sock.peer.addr":           "network.peer.address",
+	"net.sock.peer.port":           "network.peer.port",
+	"net.protocol.name":            "network.protocol.name",
+	"net.protocol.version":         "network.protocol.version",
+	"messaging.destination":        "messaging.destination.name",
+	"messaging.message_id":         "messaging.message.id",
+	"messaging.kafka.partition":    "messaging.kafka.destination.partition",
+	"db.cassandra.keyspace":        "db.name",
+	"faas.execution":               "faas.invocation_id",
+}
+
+const (
+	urlPathAttribute  = "url.path"
+	urlQueryAttribute = "url.query"
+)
+
+// The host of server and consumer spans is the server, the peer of all other spans is the server. The address of the
+// client is taken from http.client_ip, so the other network attributes are kept as they are. The resource attributes
+// describe the host of a server.
+var (
+	serverSideRenames = withSideRenames(map[string]string{
+		"net.host.name": "server.address",
+		"net.host.port": "server.port",
+	})
+	clientSideRenames = withSideRenames(map[string]string{
+		"net.peer.name": "server.address",
+		"net.peer.port": "server.port",
+	})
+)
+
+func withSideRenames(side map[string]string) map[string]string {
+	renames := make(map[string]string, len(DefaultAttributeRenames)+len(side))
+	for from, to := range DefaultAttributeRenames {
+		renames[from] = to
+	}
+	for from, to := range side {
+		renames[from] = to
+	}
+	return renames
+}
+
+// sideAttributeRenames returns the default mapping for the attributes of a span of the kind.
+func sideAttributeRenames(kind v1.Span_SpanKind) map[string]string {
+	if kind == v1.Span_SPAN_KIND_SERVER || kind == v1.Span_SPAN_KIND_CONSUMER {
+		return serverSideRenames
+	}
+	return clientSideRenames
+}
+
+// normalizeAttributes renames the resource and span attributes of the batch using the renames mapping, or the default
+// mapping if it is empty. If the batch already has an attribute with the new name the deprecated attribute is dropped.
+// An attribute renamed to url.path is split into url.path and url.query. Hits are counted per rule.
+func normalizeAttributes(userID string, batch *v1.ResourceSpans, renames map[string]string) {
+	var hits map[string]int
+
+	resourceRenames := renames
+	if len(renames) == 0 {
+		resourceRenames = serverSideRenames
+	}
+
+	if batch.Resource != nil {
+		batch.Resource.Attributes = renameAttributes(batch.Resource.Attributes, resourceRenames, &hits)
+	}
+	for _, ils := range batch.InstrumentationLibrarySpans {
+		for _, span := range ils.Spans {
+			spanRenames := renames
+			if len(renames) == 0 {
+				spanRenames = sideAttributeRenames(span.Kind)
+			}
+			span.Attributes = renameAttributes(span.Attributes, spanRenames, &hits)
+		}
+	}
+
+	for rule, n := range hits {
+		metricAttributesRenamed.WithLabelValues(userID, rule).Add(float64(n))
+	}
+}
+
+func renameAttributes(attrs []*v1_common.KeyValue, renames map[string]string, hits *map[string]int) []*v1_common.KeyValue {
+	for i := 0; i < len(attrs); i++ {
+		to, ok := renames[attrs[i].Key]
+		if !ok || to == attrs[i].Key {
+			continue
+		}
+
+		if *hits == nil {
+			*hits = map[string]int{}
+		}
+		(*hits)[attrs[i].Key]++
+
+		if hasAttribute(attrs, to) {
+			attrs = append(attrs[:i], attrs[i+1:]...)
+			i--
+			continue
+		}
+		attrs[i].Key = to
+
+		if to == urlPathAttribute {
+			attrs = splitURLQuery(attrs, attrs[i])
+		}
+	}
+
+	return attrs
+}
+
+// splitURLQuery moves the query of a url.path attribute, i.e. a deprecated http.target, to a url.query attribute.
+func splitURLQuery(attrs []*v1_common.KeyValue, path *v1_common.KeyValue) []*v1_common.KeyValue {
+	target := path.Value.GetStringValue()
+	idx := strings.IndexByte(target, '?')
+	if idx < 0 {
+		return attrs
+	}
+
+	path.Value = &v1_common.AnyValue{Value: &v1_common.AnyValue_StringValue{StringValue: target[:idx]}}
+	if hasAttribute(attrs, urlQueryAttribute) {
+		return attrs
+	}
+	return append(attrs, &v1_common.KeyValue{
+		Key:   urlQueryAttribute,
+		Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_StringValue{StringValue: target[idx+1:]}},
+	})
+}
+
+func hasAttribute(attrs []*v1_common.KeyValue, key string) bool {
+	for _, a := range attrs {
+		if a.Key == key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,207 @@
+package distributor
+
+import (
+	"testing"
+
+	"github.com/grafana/dskit/flagext"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tempo/modules/overrides"
+	v1_common "github.com/grafana/tempo/pkg/tempopb/common/v1"
+	v1_resource "github.com/grafana/tempo/pkg/tempopb/resource/v1"
+	v1 "github.com/grafana/tempo/pkg/tempopb/trace/v1"
+	"github.com/grafana/tempo/pkg/util/test"
+)
+
+func stringAttribute(key, value string) *v1_common.KeyValue {
+	return &v1_common.KeyValue{
+		Key:   key,
+		Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_StringValue{StringValue: value}},
+	}
+}
+
+func attributeKeys(attrs []*v1_common.KeyValue) []string {
+	keys := make([]string, 0, len(attrs))
+	for _, a := range attrs {
+		keys = append(keys, a.Key)
+	}
+	return keys
+}
+
+func TestNormalizeAttributes(t *testing.T) {
+	batch := &v1.ResourceSpans{
+		Resource: &v1_resource.Resource{
+			Attributes: []*v1_common.KeyValue{
+				stringAttribute("service.name", "svc"),
+				stringAttribute("net.host.name", "host"),
+			},
+		},
+		InstrumentationLibrarySpans: []*v1.InstrumentationLibrarySpans{
+			{
+				Spans: []*v1.Span{
+					{
+						Attributes: []*v1_common.KeyValue{
+							stringAttribute("http.status_code", "200"),
+							stringAttribute("http.method", "GET"),
+						},
+					},
+					{
+						// both old and new name present, the deprecated attribute is dropped
+						Attributes: []*v1_common.KeyValue{
+							stringAttribute("http.status_code", "500"),
+							stringAttribute("http.response.status_code", "500"),
+							stringAttribute("custom", "value"),
+						},
+					},
+				},
+			},
+		},
+	}
+
+	before, err := test.GetCounterValue(metricAttributesRenamed.WithLabelValues("test-normalize", "http.status_code"))
+	require.NoError(t, err)
+
+	normalizeAttributes("test-normalize", batch, nil)
+
+	spans := batch.InstrumentationLibrarySpans[0].Spans
+	assert.Equal(t, []string{"service.name", "server.address"}, attributeKeys(batch.Resource.Attributes))
+	assert.Equal(t, []string{"http.response.status_code", "http.request.method"}, attributeKeys(spans[0].Attributes))
+	assert.Equal(t, "200", spans[0].Attributes[0].Value.GetStringValue())
+	assert.Equal(t, []string{"http.response.status_code", "custom"}, attributeKeys(spans[1].Attributes))
+
+	after, err := test.GetCounterValue(metricAttributesRenamed.WithLabelValues("test-normalize", "http.status_code"))
+	require.NoError(t, err)
+	assert.Equal(t, 2.0, after-before)
+}
+
+func TestNormalizeNetworkAttributes(t *testing.T) {
+	attributes := func() []*v1_common.KeyValue {
+		return []*v1_common.KeyValue{
+			stringAttribute("net.host.name", "host"),
+			stringAttribute("net.peer.name", "peer"),
+		}
+	}
+	batch := &v1.ResourceSpans{
+		InstrumentationLibrarySpans: []*v1.InstrumentationLibrarySpans{
+			{
+				Spans: []*v1.Span{
+					{Kind: v1.Span_SPAN_KIND_SERVER, Attributes: attributes()},
+					{Kind: v1.Span_SPAN_KIND_CLIENT, Attributes: attributes()},
+				},
+			},
+		},
+	}
+
+	normalizeAttributes("test", batch, nil)
+
+	// the server is the host of server spans and the peer of client spans, the other attribute is kept
+	spans := batch.InstrumentationLibrarySpans[0].Spans
+	assert.Equal(t, []string{"server.address", "net.peer.name"}, attributeKeys(spans[0].Attributes))
+	assert.Equal(t, "host", spans[0].Attributes[0].Value.GetStringValue())
+	assert.Equal(t, []string{"net.host.name", "server.address"}, attributeKeys(spans[1].Attributes))
+	assert.Equal(t, "peer", spans[1].Attributes[1].Value.GetStringValue())
+}
+
+func TestNormalizeHTTPTarget(t *testing.T) {
+	tests := []struct {
+		target   string
+		expected []*v1_common.KeyValue
+	}{
+		{
+			target:   "/api/users",
+			expected: []*v1_common.KeyValue{stringAttribute("url.path", "/api/users")},
+		},
+		{
+			target: "/api/users?id=1&sort=asc",
+			expected: []*v1_common.KeyValue{
+				stringAttribute("url.path", "/api/users"),
+				stringAttribute("url.query", "id=1&sort=asc"),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		batch := &v1.ResourceSpans{
+			InstrumentationLibrarySpans: []*v1.InstrumentationLibrarySpans{
+				{
+					Spans: []*v1.Span{
+						{Attributes: []*v1_common.KeyValue{stringAttribute("http.target", tt.target)}},
+					},
+				},
+			},
+		}
+
+		normalizeAttributes("test", batch, nil)
+		assert.Equal(t, tt.expected, batch.InstrumentationLibrarySpans[0].Spans[0].Attributes, tt.target)
+	}
+}
+
+func TestDefaultAttributeRenamesUnique(t *testing.T) {
+	for _, renames := range []map[string]string{serverSideRenames, clientSideRenames} {
+		seen := map[string]string{}
+		for from, to := range renames {
+			other, ok := seen[to]
+			assert.False(t, ok, "%s and %s are both renamed to %s", from, other, to)
+			seen[to] = from
+		}
+	}
+}
+
+func TestDistributorNormalizesAttributes(t *testing.T) {
+	for _, enabled := range []bool{true, false} {
+		limits := &overrides.Limits{}
+		flagext.DefaultValues(limits)
+		limits.AttributeNormalizationEnabled = enabled
+		limits.AttributeRenames = map[string]string{"old": "new"}
+
+		d := prepare(t, limits, nil)
+
+		request := test.MakeRequest(10, []byte{})
+		span := request.Batch.InstrumentationLibrarySpans[0].Spans[0]
+		span.Attributes = append(span.Attributes, stringAttribute("old", "value"), stringAttribute("http.method", "GET"))
+
+		_, err := d.Push(ctx, request)
+		require.NoError(t, err)
+
+		keys := attributeKeys(span.Attributes)
+		if enabled {
+			// the tenant mapping replaces the default mapping
+			assert.Contains(t, keys, "new")
+			assert.Contains(t, keys, "http.method")
+		} else {
+			assert.Contains(t, keys, "old")
+		}
+	}
+}
+
+func BenchmarkNormalizeAttributes(b *testing.B) {
+	request := test.MakeRequest(1000, []byte{})
+	for _, ils := range request.Batch.InstrumentationLibrarySpans {
+		for _, span := range ils.Spans {
+			span.Attributes = append(span.Attributes,
+				stringAttribute("http.url", "http://example.com"),
+				stringAttribute("component", "net/http"),
+				stringAttribute("http.status_code", "200"),
+				stringAttribute("http.method", "GET"),
+			)
+		}
+	}
+
+	// swapping the mapping renames every deprecated attribute on every iteration
+	reverse := make(map[string]string, len(DefaultAttributeRenames))
+	for from, to := range DefaultAttributeRenames {
+		reverse[to] = from
+	}
+
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		if i%2 == 0 {
+			normalizeAttributes("test", request.Batch, DefaultAttributeRenames)
+		} else {
+			normalizeAttributes("test", request.Batch, reverse)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package distributor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	v1 "github.com/grafana/tempo/pkg/tempopb/trace/v1"
+)
+
+const (
+	urlParamTenant = "tenant"
+
+	serviceNameAttribute = "service.name"
+	unknownServiceName   = "unknown"
+)
+
+var (
+	metricTopServicesBytes = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "tempo",
+		Name:      "distributor_top_services_bytes",
+		Help:      "Approximate proto bytes received per service for the top services of each tenant over the last completed interval.",
+	}, []string{"tenant", "service"})
+	metricTopServicesSpans = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "tempo",
+		Name:      "distributor_top_services_spans",
+		Help:      "Approximate spans received per service for the top services of each tenant over the last completed interval.",
+	}, []string{"tenant", "service"})
+)
+
+// TopServicesConfig controls tracking of the services sending the most data per tenant.
+type TopServicesConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Number of services tracked per tenant. Memory is bounded by this value and counts are
+	//  approximate once a tenant has more services than this.
+	Capacity int `yaml:"capacity"`
+	// Counts are reset on this interval.
+	ResetInterval time.Duration `yaml:"reset_interval"`
+	// If > 0 the top N services per tenant are published as metrics at the end of every interval.
+	MetricsTopN int `yaml:"metrics_top_n"`
+}
+
+// ServiceCount is the approximate volume of a single service. The true value is in [Count-Error, Count].
+type ServiceCount struct {
+	Service string `json:"service"`
+	Count   int64  `json:"count"`
+	Error   int64  `json:"error"`
+}
+
+// TopServices is the response of the top services endpoint.
+type TopServices struct {
+	Tenant string         `json:"tenant"`
+	Since  time.Time      `json:"since"`
+	Bytes  []ServiceCount `json:"bytes"`
+	Spans  []ServiceCount `json:"spans"`
+}
+
+// spaceSaving is a space-saving top-k sketch. It tracks at most capacity keys. When full, a new key replaces
+// the key with the smallest count and inherits that count as its error.
+type spaceSaving struct {
+	capacity int
+	counts   map[string]*ServiceCount
+}
+
+func newSpaceSaving(capacity int) *spaceSaving {
+	return &spaceSaving{
+		capacity: capacity,
+		counts:   make(map[string]*ServiceCount, capacity),
+	}
+}
+
+func (s *spaceSaving) add(key string, n int64) {
+	if c, ok := s.counts[key]; ok {
+		c.Count += n
+		return
+	}
+
+	if len(s.counts) < s.capacity {
+		s.counts[key] = &ServiceCount{Service: key, Count: n}
+		return
+	}
+
+	var min *ServiceCount
+	for _, c := range s.counts {
+		if min == nil || c.Count < min.Count {
+			min = c
+		}
+	}
+
+	delete(s.counts, min.Service)
+	s.counts[key] = &ServiceCount{
+		Service: key,
+		Count:   min.Count + n,
+		Error:   min.Count,
+	}
+}
+
+// top returns the n keys with the highest counts. All keys are returned if n <= 0.
+func (s *spaceSaving) top(n int) []ServiceCount {
+	res := make([]ServiceCount, 0, len(s.counts))
+	for _, c := range s.counts {
+		res = append(res, *c)
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Count == res[j].Count {
+			return res[i].Service < res[j].Service
+		}
+		return res[i].Count > res[j].Count
+	})
+
+	if n > 0 && len(res) > n {
+		res = res[:n]
+	}
+	return res
+}
+
+type tenantServices struct {
+	bytes *spaceSaving
+	spans *spaceSaving
+}
+
+// topServicesTracker maintains a bytes and a spans sketch per tenant.
+type topServicesTracker struct {
+	cfg TopServicesConfig
+
+	mtx     sync.Mutex
+	since   time.Time
+	tenants map[string]*tenantServices
+}
+
+func newTopServicesTracker(cfg TopServicesConfig) *topServicesTracker {
+	return &topServicesTracker{
+		cfg:     cfg,
+		since:   time.Now(),
+		tenants: map[string]*tenantServices{},
+	}
+}
+
+// record adds the bytes and spans of the batch to the service it belongs to.
+func (t *topServicesTracker) record(userID string, batch *v1.ResourceSpans, bytes int, spans int) {
+	service := serviceName(batch)
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	ts, ok := t.tenants[userID]
+	if !ok {
+		ts = &tenantServices{
+			bytes: newSpaceSaving(t.cfg.Capacity),
+			spans: newSpaceSaving(t.cfg.Capacity),
+		}
+		t.tenants[userID] = ts
+	}
+
+	ts.bytes.add(service, int64(bytes))
+	ts.spans.add(service, int64(spans))
+}
+
+func (t *topServicesTracker) top(userID string) *TopServices {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	res := &TopServices{
+		Tenant: userID,
+		Since:  t.since,
+		Bytes:  []ServiceCount{},
+		Spans:  []ServiceCount{},
+	}
+
+	if ts, ok := t.tenants[userID]; ok {
+		res.Bytes = ts.bytes.top(0)
+		res.Spans = ts.spans.top(0)
+	}
+
+	return res
+}
+
+// reset publishes the metrics for the completed interval and clears all counts.
+func (t *topServicesTracker) reset() {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	if t.cfg.MetricsTopN > 0 {
+		metricTopServicesBytes.Reset()
+		metricTopServicesSpans.Reset()
+
+		for userID, ts := range t.tenants {
+			for _, c := range ts.bytes.top(t.cfg.MetricsTopN) {
+				metricTopServicesBytes.WithLabelValues(userID, c.Service).Set(float64(c.Count))
+			}
+			for _, c := range ts.spans.top(t.cfg.MetricsTopN) {
+				metricTopServicesSpans.WithLabelValues(userID, c.Service).Set(float64(c.Count))
+			}
+		}
+	}
+
+	t.since = time.Now()
+	t.tenants = map[string]*tenantServices{}
+}
+
+func (t *topServicesTracker) run(ctx context.Context) {
+	ticker := time.NewTicker(t.cfg.ResetInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			t.reset()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func serviceName(batch *v1.ResourceSpans) string {
+	if batch.Resource == nil {
+		return unknownServiceName
+	}
+
+	for _, a := range batch.Resource.Attributes {
+		if a.Key == serviceNameAttribute {
+			if s := a.GetValue().GetStringValue(); s != "" {
+				return s
+			}
+		}
+	}
+
+	return unknownServiceName
+}
+
+// TopServicesHandler returns the approximate top services by bytes and spans for the tenant
+// in the tenant query param.
+func (d *Distributor) TopServicesHandler(w http.ResponseWriter, r *http.Request) {
+	if d.topServices == nil {
+		http.Error(w, "top services tracking is disabled", http.StatusNotFound)
+		return
+	}
+
+	userID := r.URL.Query().Get(urlParamTenant)
+	if userID == "" {
+		http.Error(w, "please provide a tenant", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(d.topServices.top(userID))
+}
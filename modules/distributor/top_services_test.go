@@ -0,0 +1,115 @@
+package distributor
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	v1_common "github.com/grafana/tempo/pkg/tempopb/common/v1"
+	v1_resource "github.com/grafana/tempo/pkg/tempopb/resource/v1"
+	v1 "github.com/grafana/tempo/pkg/tempopb/trace/v1"
+)
+
+func batchForService(service string) *v1.ResourceSpans {
+	return &v1.ResourceSpans{
+		Resource: &v1_resource.Resource{
+			Attributes: []*v1_common.KeyValue{
+				{
+					Key:   serviceNameAttribute,
+					Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_StringValue{StringValue: service}},
+				},
+			},
+		},
+	}
+}
+
+func TestSpaceSavingApproximatesExactCounts(t *testing.T) {
+	const (
+		capacity = 20
+		services = 200
+		pushes   = 100000
+	)
+
+	r := rand.New(rand.NewSource(1))
+	zipf := rand.NewZipf(r, 1.2, 1, services-1)
+
+	sketch := newSpaceSaving(capacity)
+	exact := map[string]int64{}
+	for i := 0; i < pushes; i++ {
+		service := fmt.Sprintf("service-%d", zipf.Uint64())
+		n := int64(r.Intn(1000) + 1)
+
+		sketch.add(service, n)
+		exact[service] += n
+	}
+
+	top := sketch.top(0)
+	require.Len(t, top, capacity)
+
+	// every tracked count bounds the exact count
+	for _, c := range top {
+		assert.LessOrEqual(t, c.Count-c.Error, exact[c.Service], c.Service)
+		assert.GreaterOrEqual(t, c.Count, exact[c.Service], c.Service)
+	}
+
+	// the heaviest services are reported first and in order
+	for i := 0; i < 5; i++ {
+		assert.Equal(t, fmt.Sprintf("service-%d", i), top[i].Service)
+	}
+}
+
+func TestSpaceSavingBoundedMemory(t *testing.T) {
+	sketch := newSpaceSaving(3)
+	for i := 0; i < 100; i++ {
+		sketch.add(fmt.Sprintf("service-%d", i), 1)
+	}
+	assert.Len(t, sketch.counts, 3)
+	assert.Len(t, sketch.top(2), 2)
+}
+
+func TestTopServicesTracker(t *testing.T) {
+	tracker := newTopServicesTracker(TopServicesConfig{Capacity: 10, MetricsTopN: 1})
+
+	tracker.record("tenant", batchForService("a"), 100, 1)
+	tracker.record("tenant", batchForService("b"), 10, 5)
+	tracker.record("tenant", batchForService("a"), 100, 1)
+	tracker.record("tenant", &v1.ResourceSpans{}, 1, 1)
+	tracker.record("other", batchForService("c"), 1, 1)
+
+	top := tracker.top("tenant")
+	assert.Equal(t, []ServiceCount{{Service: "a", Count: 200}, {Service: "b", Count: 10}, {Service: unknownServiceName, Count: 1}}, top.Bytes)
+	assert.Equal(t, []ServiceCount{{Service: "b", Count: 5}, {Service: "a", Count: 2}, {Service: unknownServiceName, Count: 1}}, top.Spans)
+
+	tracker.reset()
+	assert.Empty(t, tracker.top("tenant").Bytes)
+}
+
+func TestTopServicesHandler(t *testing.T) {
+	d := &Distributor{}
+
+	w := httptest.NewRecorder()
+	d.TopServicesHandler(w, httptest.NewRequest(http.MethodGet, "/api/debug/top-services?tenant=test", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	d.topServices = newTopServicesTracker(TopServicesConfig{Capacity: 10})
+	d.topServices.record("test", batchForService("a"), 10, 1)
+
+	w = httptest.NewRecorder()
+	d.TopServicesHandler(w, httptest.NewRequest(http.MethodGet, "/api/debug/top-services", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	d.TopServicesHandler(w, httptest.NewRequest(http.MethodGet, "/api/debug/top-services?tenant=test", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	res := &TopServices{}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(res))
+	assert.Equal(t, "test", res.Tenant)
+	assert.Equal(t, []ServiceCount{{Service: "a", Count: 10}}, res.Bytes)
+}
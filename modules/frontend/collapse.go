@@ -0,0 +1,139 @@
+package frontend
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/tempo/pkg/util"
+)
+
+// CollapsingWare collapses identical trace by id queries of a tenant that are in flight at the same time. Queries for
+// the same trace id with the same parameters, i.e. time range hints, wait for the result of the first one instead of
+// sending their own requests to the queriers. Results are not kept once the first query returned, a query that starts
+// afterwards is sent to the queriers again.
+func CollapsingWare(registerer prometheus.Registerer) Middleware {
+	collapsed := promauto.With(registerer).NewCounter(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "query_frontend_collapsed_requests_total",
+		Help:      "The total number of trace by id queries that waited for an identical query in flight instead of querying the queriers.",
+	})
+
+	return MiddlewareFunc(func(next Handler) Handler {
+		return &collapser{
+			next:      next,
+			calls:     map[string]*collapsedCall{},
+			collapsed: collapsed,
+		}
+	})
+}
+
+type collapser struct {
+	next Handler
+
+	mtx   sync.Mutex
+	calls map[string]*collapsedCall
+
+	collapsed prometheus.Counter
+}
+
+// collapsedCall is a query in flight. Its result is set before done is closed.
+type collapsedCall struct {
+	done chan struct{}
+
+	statusCode int
+	header     http.Header
+	body       []byte
+	err        error
+}
+
+// Do implements Handler
+func (c *collapser) Do(r *http.Request) (*http.Response, error) {
+	key, ok := collapseKey(r)
+	if !ok {
+		return c.next.Do(r)
+	}
+
+	c.mtx.Lock()
+	if call, ok := c.calls[key]; ok {
+		c.mtx.Unlock()
+		c.collapsed.Inc()
+		return c.wait(r, call)
+	}
+	call := &collapsedCall{done: make(chan struct{})}
+	c.calls[key] = call
+	c.mtx.Unlock()
+
+	resp, err := c.next.Do(r)
+	if err == nil {
+		call.statusCode = resp.StatusCode
+		call.header = resp.Header
+		call.body, err = io.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+	call.err = err
+
+	// removed before the waiting queries are released so a query that starts afterwards doesn't get this result
+	c.mtx.Lock()
+	delete(c.calls, key)
+	c.mtx.Unlock()
+	close(call.done)
+
+	return call.response()
+}
+
+// wait returns the result of a query in flight. If the query in flight was cancelled, i.e. because its client went
+// away, the query is sent to the queriers.
+func (c *collapser) wait(r *http.Request, call *collapsedCall) (*http.Response, error) {
+	span, ctx := opentracing.StartSpanFromContext(r.Context(), "frontend.Collapse")
+	defer span.Finish()
+
+	select {
+	case <-call.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	if errors.Is(call.err, context.Canceled) || errors.Is(call.err, context.DeadlineExceeded) {
+		span.SetTag("retried", true)
+		return c.next.Do(r)
+	}
+	return call.response()
+}
+
+// response returns a copy of the result of the call, every query it was returned to reads its own body.
+func (call *collapsedCall) response() (*http.Response, error) {
+	if call.err != nil {
+		return nil, call.err
+	}
+	return &http.Response{
+		StatusCode:    call.statusCode,
+		Header:        call.header.Clone(),
+		Body:          ioutil.NopCloser(bytes.NewReader(call.body)),
+		ContentLength: int64(len(call.body)),
+	}, nil
+}
+
+// collapseKey returns the key of identical trace by id queries, the tenant, the trace id and the parameters of the
+// query.
+func collapseKey(r *http.Request) (string, bool) {
+	tenantID, err := user.ExtractOrgID(r.Context())
+	if err != nil {
+		return "", false
+	}
+	traceID, err := util.ParseTraceID(r)
+	if err != nil {
+		return "", false
+	}
+	return tenantID + ":" + hex.EncodeToString(traceID) + "?" + r.URL.Query().Encode(), true
+}
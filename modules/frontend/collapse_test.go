@@ -0,0 +1,145 @@
+package frontend
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+	"go.uber.org/atomic"
+
+	"github.com/grafana/tempo/pkg/util"
+	"github.com/grafana/tempo/pkg/util/test"
+)
+
+func TestCollapsingWare(t *testing.T) {
+	calls := atomic.NewInt32(0)
+	release := make(chan struct{})
+	next := HandlerFunc(func(r *http.Request) (*http.Response, error) {
+		calls.Inc()
+		select {
+		case <-release:
+		case <-r.Context().Done():
+			return nil, r.Context().Err()
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{},
+			Body:       ioutil.NopCloser(bytes.NewReader([]byte(r.URL.RawQuery))),
+		}, nil
+	})
+	collapser := CollapsingWare(prometheus.NewRegistry()).Wrap(next).(*collapser)
+
+	request := func(ctx context.Context, query string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, apiPathTraces+"/0102?"+query, nil)
+		r = r.WithContext(user.InjectOrgID(ctx, "tenant"))
+		return mux.SetURLVars(r, map[string]string{util.TraceIDVar: "0102"})
+	}
+	type result struct {
+		body string
+		err  error
+	}
+	do := func(r *http.Request) chan result {
+		ch := make(chan result, 1)
+		go func() {
+			resp, err := collapser.Do(r)
+			if err != nil {
+				ch <- result{err: err}
+				return
+			}
+			body, err := ioutil.ReadAll(resp.Body)
+			ch <- result{body: string(body), err: err}
+		}()
+		return ch
+	}
+	collapsed := func() int {
+		v, err := test.GetCounterValue(collapser.collapsed)
+		require.NoError(t, err)
+		return int(v)
+	}
+
+	// identical queries wait for the first one, queries with other time hints don't
+	first := do(request(context.Background(), "start=1&end=2"))
+	require.Eventually(t, func() bool { return calls.Load() == 1 }, time.Second, time.Millisecond)
+	var waiting []chan result
+	for i := 0; i < 5; i++ {
+		waiting = append(waiting, do(request(context.Background(), "start=1&end=2")))
+	}
+	other := do(request(context.Background(), "start=1&end=3"))
+	require.Eventually(t, func() bool { return collapsed() == 5 && calls.Load() == 2 }, time.Second, time.Millisecond)
+
+	close(release)
+	for _, ch := range append(waiting, first) {
+		res := <-ch
+		require.NoError(t, res.err)
+		assert.Equal(t, "start=1&end=2", res.body)
+	}
+	res := <-other
+	require.NoError(t, res.err)
+	assert.Equal(t, "start=1&end=3", res.body)
+	assert.Equal(t, int32(2), calls.Load())
+
+	// a query that starts after the first one returned is sent again
+	res = <-do(request(context.Background(), "start=1&end=2"))
+	require.NoError(t, res.err)
+	assert.Equal(t, int32(3), calls.Load())
+	assert.Equal(t, 5, collapsed())
+}
+
+func TestCollapsingWareCancelled(t *testing.T) {
+	release := make(chan struct{})
+	next := HandlerFunc(func(r *http.Request) (*http.Response, error) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+			return nil, r.Context().Err()
+		}
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: ioutil.NopCloser(bytes.NewReader(nil))}, nil
+	})
+	collapser := CollapsingWare(prometheus.NewRegistry()).Wrap(next).(*collapser)
+
+	request := func(ctx context.Context) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, apiPathTraces+"/0102", nil)
+		r = r.WithContext(user.InjectOrgID(ctx, "tenant"))
+		return mux.SetURLVars(r, map[string]string{util.TraceIDVar: "0102"})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	firstErr := make(chan error, 1)
+	go func() {
+		_, err := collapser.Do(request(ctx))
+		firstErr <- err
+	}()
+	require.Eventually(t, func() bool {
+		collapser.mtx.Lock()
+		defer collapser.mtx.Unlock()
+		return len(collapser.calls) == 1
+	}, time.Second, time.Millisecond)
+
+	waiting := make(chan error, 1)
+	go func() {
+		resp, err := collapser.Do(request(context.Background()))
+		if err == nil {
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+		}
+		waiting <- err
+	}()
+	require.Eventually(t, func() bool {
+		v, err := test.GetCounterValue(collapser.collapsed)
+		return err == nil && v == 1
+	}, time.Second, time.Millisecond)
+
+	// the cancelled query fails, the waiting one is sent itself
+	cancel()
+	assert.ErrorIs(t, <-firstErr, context.Canceled)
+	close(release)
+	assert.NoError(t, <-waiting)
+}
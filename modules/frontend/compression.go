@@ -0,0 +1,170 @@
+package frontend
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/cortexproject/cortex/pkg/querier/queryrange"
+	"github.com/grafana/dskit/flagext"
+)
+
+const (
+	acceptEncodingHeader  = "Accept-Encoding"
+	contentEncodingHeader = "Content-Encoding"
+
+	encodingGzip    = "gzip"
+	encodingDeflate = "deflate"
+)
+
+// CompressionConfig configures the compression of the responses of the frontend.
+type CompressionConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// MinSize is the size in bytes of the smallest response that is compressed.
+	MinSize int `yaml:"min_size"`
+	// ContentTypes are the media types of the responses that are compressed.
+	ContentTypes flagext.StringSliceCSV `yaml:"content_types"`
+}
+
+var (
+	gzipWriters = sync.Pool{New: func() interface{} { return gzip.NewWriter(nil) }}
+	zlibWriters = sync.Pool{New: func() interface{} { return zlib.NewWriter(nil) }}
+)
+
+// CompressionWare compresses the responses of the frontend with gzip or deflate as negotiated by the Accept-Encoding
+// header of the request. Only responses of the configured content types and at least the min size are compressed. The
+// requests sent downstream don't accept compressed responses, so the middlewares that combine the responses of the
+// shards always read uncompressed bodies.
+func CompressionWare(cfg CompressionConfig) queryrange.Tripperware {
+	contentTypes := map[string]struct{}{}
+	for _, ct := range cfg.ContentTypes {
+		contentTypes[strings.TrimSpace(ct)] = struct{}{}
+	}
+
+	return func(next http.RoundTripper) http.RoundTripper {
+		if !cfg.Enabled {
+			return next
+		}
+
+		return queryrange.RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+			encoding := negotiateEncoding(req.Header.Get(acceptEncodingHeader))
+			if req.Header.Get(acceptEncodingHeader) != "" {
+				req = req.Clone(req.Context())
+				req.Header.Del(acceptEncodingHeader)
+			}
+
+			resp, err := next.RoundTrip(req)
+			if err != nil || encoding == "" || resp.Header.Get(contentEncodingHeader) != "" {
+				return resp, err
+			}
+			if mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type")); err != nil {
+				return resp, nil
+			} else if _, ok := contentTypes[mediaType]; !ok {
+				return resp, nil
+			}
+
+			body, err := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if err != nil {
+				return nil, err
+			}
+			resp.Header.Add("Vary", acceptEncodingHeader)
+			if len(body) < cfg.MinSize {
+				resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+				return resp, nil
+			}
+
+			compressed, err := compress(encoding, body)
+			if err != nil {
+				return nil, err
+			}
+			resp.Header.Set(contentEncodingHeader, encoding)
+			resp.Header.Set("Content-Length", strconv.Itoa(len(compressed)))
+			resp.Body = ioutil.NopCloser(bytes.NewReader(compressed))
+			resp.ContentLength = int64(len(compressed))
+			return resp, nil
+		})
+	}
+}
+
+// negotiateEncoding returns the encoding of the response for the Accept-Encoding header of the request, gzip or
+// deflate, the one with the higher quality and gzip if they are equal. It returns an empty string if neither is
+// accepted.
+func negotiateEncoding(acceptEncoding string) string {
+	qualities := map[string]float64{}
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		params := strings.Split(part, ";")
+		coding := strings.ToLower(strings.TrimSpace(params[0]))
+		if coding == "" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if !strings.HasPrefix(param, "q=") {
+				continue
+			}
+			var err error
+			if q, err = strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64); err != nil {
+				q = 0
+			}
+		}
+		qualities[coding] = q
+	}
+
+	// the wildcard covers the encodings not listed
+	for _, encoding := range []string{encodingGzip, encodingDeflate} {
+		if _, ok := qualities[encoding]; !ok {
+			if q, ok := qualities["*"]; ok {
+				qualities[encoding] = q
+			}
+		}
+	}
+
+	encoding := ""
+	best := 0.0
+	for _, e := range []string{encodingGzip, encodingDeflate} {
+		if q := qualities[e]; q > best {
+			encoding, best = e, q
+		}
+	}
+	return encoding
+}
+
+// compress returns body compressed with gzip or deflate, the zlib format of the deflate content coding.
+func compress(encoding string, body []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Grow(len(body) / 4)
+
+	var w interface {
+		io.WriteCloser
+		Reset(io.Writer)
+	}
+	switch encoding {
+	case encodingGzip:
+		gw := gzipWriters.Get().(*gzip.Writer)
+		defer gzipWriters.Put(gw)
+		w = gw
+	default:
+		zw := zlibWriters.Get().(*zlib.Writer)
+		defer zlibWriters.Put(zw)
+		w = zw
+	}
+
+	w.Reset(&buf)
+	if _, err := w.Write(body); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
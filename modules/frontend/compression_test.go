@@ -0,0 +1,162 @@
+package frontend
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cortexproject/cortex/pkg/querier/queryrange"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+	"github.com/grafana/tempo/pkg/util"
+	"github.com/grafana/tempo/pkg/util/test"
+)
+
+func testCompressionConfig() CompressionConfig {
+	return CompressionConfig{
+		Enabled:      true,
+		MinSize:      100,
+		ContentTypes: []string{util.ProtobufTypeHeaderValue, util.JSONTypeHeaderValue},
+	}
+}
+
+func TestCompressionWare(t *testing.T) {
+	large := strings.Repeat("trace", 100)
+
+	tests := []struct {
+		name             string
+		acceptEncoding   string
+		contentType      string
+		body             string
+		expectedEncoding string
+	}{
+		{name: "gzip", acceptEncoding: "gzip", contentType: util.JSONTypeHeaderValue, body: large, expectedEncoding: "gzip"},
+		{name: "deflate", acceptEncoding: "deflate", contentType: util.ProtobufTypeHeaderValue, body: large, expectedEncoding: "deflate"},
+		{name: "gzip preferred", acceptEncoding: "deflate, gzip, br", contentType: util.JSONTypeHeaderValue, body: large, expectedEncoding: "gzip"},
+		{name: "quality", acceptEncoding: "gzip;q=0.5, deflate;q=0.8", contentType: util.JSONTypeHeaderValue, body: large, expectedEncoding: "deflate"},
+		{name: "refused", acceptEncoding: "gzip;q=0, deflate;q=0", contentType: util.JSONTypeHeaderValue, body: large},
+		{name: "wildcard", acceptEncoding: "*", contentType: util.JSONTypeHeaderValue, body: large, expectedEncoding: "gzip"},
+		{name: "content type params", acceptEncoding: "gzip", contentType: "application/json; charset=utf-8", body: large, expectedEncoding: "gzip"},
+		{name: "not accepted", contentType: util.JSONTypeHeaderValue, body: large},
+		{name: "unsupported encoding", acceptEncoding: "br", contentType: util.JSONTypeHeaderValue, body: large},
+		{name: "small", acceptEncoding: "gzip", contentType: util.JSONTypeHeaderValue, body: "not found"},
+		{name: "other content type", acceptEncoding: "gzip", contentType: "text/plain", body: large},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rt := CompressionWare(testCompressionConfig())(queryrange.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+				// the responses of the shards are never compressed
+				assert.Empty(t, r.Header.Get("Accept-Encoding"))
+				return &http.Response{
+					StatusCode:    http.StatusOK,
+					Header:        http.Header{"Content-Type": []string{tt.contentType}},
+					Body:          ioutil.NopCloser(strings.NewReader(tt.body)),
+					ContentLength: int64(len(tt.body)),
+				}, nil
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/api/traces/1234", nil)
+			if tt.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+			resp, err := rt.RoundTrip(req)
+			require.NoError(t, err)
+			assert.Equal(t, tt.acceptEncoding, req.Header.Get("Accept-Encoding"))
+
+			assert.Equal(t, tt.expectedEncoding, resp.Header.Get("Content-Encoding"))
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Equal(t, int64(len(body)), resp.ContentLength)
+			assert.Equal(t, tt.body, decompress(t, tt.expectedEncoding, body))
+		})
+	}
+}
+
+func TestCompressionWareDisabled(t *testing.T) {
+	cfg := testCompressionConfig()
+	cfg.Enabled = false
+	rt := CompressionWare(cfg)(queryrange.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+		assert.Equal(t, "gzip", r.Header.Get("Accept-Encoding"))
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/traces/1234", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	_, err := rt.RoundTrip(req)
+	require.NoError(t, err)
+}
+
+func decompress(t testing.TB, encoding string, body []byte) string {
+	var r io.Reader = bytes.NewReader(body)
+	var err error
+	switch encoding {
+	case "gzip":
+		r, err = gzip.NewReader(r)
+	case "deflate":
+		r, err = zlib.NewReader(r)
+	}
+	require.NoError(t, err)
+
+	decompressed, err := io.ReadAll(r)
+	require.NoError(t, err)
+	return string(decompressed)
+}
+
+// BenchmarkCompressionWare compresses a trace of 10MB in protobuf and in JSON and reports the size of the compressed
+// response relative to the uncompressed one.
+func BenchmarkCompressionWare(b *testing.B) {
+	trace := &tempopb.Trace{}
+	for trace.Size() < 10<<20 {
+		trace.Batches = append(trace.Batches, test.MakeTraceWithSpanCount(10, 100, []byte{0x01}).Batches...)
+	}
+	pb, err := proto.Marshal(trace)
+	require.NoError(b, err)
+	var js bytes.Buffer
+	require.NoError(b, (&jsonpb.Marshaler{}).Marshal(&js, trace))
+
+	for _, bc := range []struct {
+		name        string
+		contentType string
+		body        []byte
+	}{
+		{name: "protobuf", contentType: util.ProtobufTypeHeaderValue, body: pb},
+		{name: "json", contentType: util.JSONTypeHeaderValue, body: js.Bytes()},
+	} {
+		for _, encoding := range []string{"gzip", "deflate"} {
+			b.Run(bc.name+"/"+encoding, func(b *testing.B) {
+				rt := CompressionWare(testCompressionConfig())(queryrange.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+					return &http.Response{
+						StatusCode: http.StatusOK,
+						Header:     http.Header{"Content-Type": []string{bc.contentType}},
+						Body:       ioutil.NopCloser(bytes.NewReader(bc.body)),
+					}, nil
+				}))
+				req := httptest.NewRequest(http.MethodGet, "/api/traces/1234", nil)
+				req.Header.Set("Accept-Encoding", encoding)
+
+				var compressed int64
+				b.SetBytes(int64(len(bc.body)))
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					resp, err := rt.RoundTrip(req)
+					require.NoError(b, err)
+					compressed = resp.ContentLength
+				}
+				b.ReportMetric(float64(len(bc.body)), "uncompressed-bytes")
+				b.ReportMetric(float64(compressed), "compressed-bytes")
+				b.ReportMetric(float64(compressed)/float64(len(bc.body)), "ratio")
+			})
+		}
+	}
+}
@@ -1,16 +1,75 @@
 package frontend
 
 import (
+	"errors"
 	"flag"
+	"fmt"
+	"time"
 
+	cortex_cache "github.com/cortexproject/cortex/pkg/chunk/cache"
 	"github.com/cortexproject/cortex/pkg/frontend"
 	v1 "github.com/cortexproject/cortex/pkg/frontend/v1"
+	"github.com/grafana/dskit/flagext"
+
+	"github.com/grafana/tempo/pkg/util"
+)
+
+const (
+	// ProtocolV1 queues the requests in the frontend, queriers connect to the frontend with the v1 frontend protocol.
+	ProtocolV1 = "v1"
+	// ProtocolV2 queues the requests in a query-scheduler embedded in the frontend, queriers pull them with the v2
+	// scheduler protocol and return the results to the frontend.
+	ProtocolV2 = "v2"
 )
 
 type Config struct {
-	Config      frontend.CombinedFrontendConfig `yaml:",inline"`
-	MaxRetries  int                             `yaml:"max_retries,omitempty"`
-	QueryShards int                             `yaml:"query_shards,omitempty"`
+	Config frontend.CombinedFrontendConfig `yaml:",inline"`
+	// Protocol is the protocol queriers pull requests from this frontend with, v1 or v2. With v1 and a scheduler
+	// address the requests are queued in the query-schedulers instead. v2 combined with a scheduler address is an
+	// error.
+	Protocol string `yaml:"protocol,omitempty"`
+	// DownstreamRoutes send the requests of some paths to their own downstream URLs, the others go to the downstream
+	// url or the queue.
+	DownstreamRoutes []DownstreamRoute `yaml:"downstream_routes,omitempty"`
+	// QueryCancelPeers are the base URLs of the other frontends, the cancellations of queries that aren't in flight
+	// in this frontend are forwarded to them.
+	QueryCancelPeers []string `yaml:"query_cancel_peers,omitempty"`
+	// MaxJobSize rejects the requests to the queriers larger than this many bytes before they are queued. 0 disables
+	// the limit.
+	MaxJobSize int `yaml:"max_job_size,omitempty"`
+
+	MaxRetries int `yaml:"max_retries,omitempty"`
+	// RetryMinBackoff and RetryMaxBackoff bound the jittered exponential backoff between the tries of a request.
+	RetryMinBackoff time.Duration `yaml:"retry_min_backoff,omitempty"`
+	RetryMaxBackoff time.Duration `yaml:"retry_max_backoff,omitempty"`
+	QueryShards     int           `yaml:"query_shards,omitempty"`
+	// TargetBlocksPerShard sizes the block shards of trace by id queries by the block count of the tenant, up to
+	// QueryShards-1 block shards. 0 always uses QueryShards-1 block shards.
+	TargetBlocksPerShard int `yaml:"target_blocks_per_shard,omitempty"`
+	// SearchStreamShards is the number of shards of the ingesters searches are split into when the results are
+	// streamed. 0 disables streaming.
+	SearchStreamShards int `yaml:"search_stream_shards,omitempty"`
+	// SearchStreamFlushResults is the number of traces written in a line by searches streamed as newline delimited
+	// JSON.
+	SearchStreamFlushResults int `yaml:"search_stream_flush_results,omitempty"`
+	// SearchShardInterval splits searches of longer time ranges into searches of the backend blocks of every interval
+	// of the range, SearchShardConcurrency of them run at once. 0 disables the split. The interval is widened to split
+	// a range into at most SearchShardMaxShards searches of the blocks, 0 for no limit.
+	SearchShardInterval    time.Duration `yaml:"search_shard_interval,omitempty"`
+	SearchShardMaxShards   int           `yaml:"search_shard_max_shards,omitempty"`
+	SearchShardConcurrency int           `yaml:"search_shard_concurrency,omitempty"`
+	// Priority dispatches the requests of interactive queries before the ones of batch queries.
+	Priority PriorityConfig `yaml:"query_priority"`
+	// Compression compresses the responses of the frontend as accepted by the clients.
+	Compression CompressionConfig `yaml:"response_compression"`
+	// Federation sends the queries of the federation org id to the federated tenants. Only enabled with
+	// multitenancy.federation-enabled.
+	Federation FederationConfig `yaml:"federation"`
+	// TraceCache caches the responses of trace by id queries for traces that no longer change.
+	TraceCache TraceCacheConfig `yaml:"trace_cache"`
+	// LogSlowQueriesThreshold logs the queries slower than this with their tenant, trace id or search parameters and
+	// stats. 0 falls back to log_queries_longer_than, a negative threshold logs all queries.
+	LogSlowQueriesThreshold time.Duration `yaml:"log_slow_queries_threshold,omitempty"`
 }
 
 func (cfg *Config) RegisterFlagsAndApplyDefaults(prefix string, f *flag.FlagSet) {
@@ -18,7 +77,78 @@ func (cfg *Config) RegisterFlagsAndApplyDefaults(prefix string, f *flag.FlagSet)
 	cfg.Config.Handler.LogQueriesLongerThan = 0
 	cfg.Config.FrontendV1.MaxOutstandingPerTenant = 100
 	cfg.MaxRetries = 2
+	cfg.Protocol = ProtocolV1
+	cfg.RetryMinBackoff = 100 * time.Millisecond
+	cfg.RetryMaxBackoff = time.Second
 	cfg.QueryShards = 20
+	cfg.TargetBlocksPerShard = 100
+	cfg.SearchStreamShards = 4
+	cfg.SearchStreamFlushResults = 100
+	cfg.SearchShardInterval = time.Hour
+	cfg.SearchShardMaxShards = 168
+	cfg.SearchShardConcurrency = 20
+
+	cfg.TraceCache.BackgroundCache = &cortex_cache.BackgroundConfig{
+		WriteBackBuffer:     10000,
+		WriteBackGoroutines: 10,
+	}
+	f.StringVar(&cfg.Protocol, prefix+".protocol", ProtocolV1, "Protocol queriers pull requests from the frontend with, v1 or v2. With v2 the requests are queued in a query-scheduler embedded in the frontend, v2 can't be combined with a scheduler address.")
+	f.IntVar(&cfg.MaxJobSize, prefix+".max-job-size", 0, "Requests to the queriers larger than this many bytes are rejected before they are queued. 0 disables the limit.")
+	f.DurationVar(&cfg.LogSlowQueriesThreshold, prefix+".log-slow-queries-threshold", 0, "Queries slower than this are logged with their tenant, trace id or search parameters and stats. 0 falls back to log_queries_longer_than.")
+
+	f.IntVar(&cfg.Priority.MaxDispatchedPerTenant, prefix+".query-priority.max-dispatched-per-tenant", 0, "Requests of a tenant sent to the queue at once, the others wait by priority class. 0 sends all requests to the queue right away.")
+	f.IntVar(&cfg.Priority.InteractiveWeight, prefix+".query-priority.interactive-weight", 4, "Weight of the interactive queries when dispatching the waiting requests of a tenant.")
+	f.IntVar(&cfg.Priority.BatchWeight, prefix+".query-priority.batch-weight", 1, "Weight of the batch queries when dispatching the waiting requests of a tenant.")
+
+	cfg.Compression.ContentTypes = []string{util.ProtobufTypeHeaderValue, util.JSONTypeHeaderValue, util.JaegerJSONTypeHeaderValue}
+	f.BoolVar(&cfg.Compression.Enabled, prefix+".response-compression.enabled", true, "Compress the responses of the frontend with gzip or deflate if the client accepts them.")
+	f.IntVar(&cfg.Compression.MinSize, prefix+".response-compression.min-size", 1024, "Responses smaller than this many bytes are not compressed.")
+	f.Var(&cfg.Compression.ContentTypes, prefix+".response-compression.content-types", "Comma separated media types of the responses that are compressed.")
+
+	f.StringVar(&cfg.Federation.OrgID, prefix+".federation.org-id", "__all__", "Org id of the queries sent to all federated tenants.")
+	f.Var(&cfg.Federation.Tenants, prefix+".federation.tenants", "Comma separated tenants federated queries are sent to.")
+
+	f.StringVar(&cfg.TraceCache.Cache, prefix+".trace-cache.cache", "", "Cache of the responses of trace by id queries, redis or memcached. Disabled if empty.")
+	f.DurationVar(&cfg.TraceCache.MinTraceAge, prefix+".trace-cache.min-trace-age", time.Hour, "Only traces whose newest span ended longer ago are cached.")
+	f.DurationVar(&cfg.TraceCache.TTL, prefix+".trace-cache.ttl", 24*time.Hour, "Time traces are kept in the trace cache.")
+	f.IntVar(&cfg.TraceCache.MaxItemBytes, prefix+".trace-cache.max-item-bytes", 1<<20, "Traces larger than this are not cached. 0 disables the limit.")
+
+	// queries are queued in the frontend unless a query-scheduler address is set
+	flagext.DefaultValues(&cfg.Config.FrontendV2)
+	f.StringVar(&cfg.Config.FrontendV2.SchedulerAddress, prefix+".scheduler-address", "", "Address of the query-schedulers, in host:port format. Every address the host resolves to is used. Queries are queued in the frontend if empty.")
+}
+
+// Validate returns an error if the protocol is unknown, the v2 protocol is combined with a scheduler address or
+// federation is enabled without tenants.
+func (cfg *Config) Validate() error {
+	if cfg.Federation.Enabled {
+		if cfg.Federation.OrgID == "" {
+			return errors.New("federation is enabled without an org id")
+		}
+		if len(cfg.Federation.Tenants) == 0 {
+			return errors.New("federation is enabled without tenants")
+		}
+	}
+
+	switch cfg.Protocol {
+	case ProtocolV1:
+	case ProtocolV2:
+		if cfg.Config.FrontendV2.SchedulerAddress != "" {
+			return errors.New("frontend protocol v2 embeds a query-scheduler and can't be combined with a scheduler address")
+		}
+	default:
+		return fmt.Errorf("unknown frontend protocol %q, expected %s or %s", cfg.Protocol, ProtocolV1, ProtocolV2)
+	}
+	return nil
+}
+
+// slowQueriesThreshold returns the threshold of the slow query log. log_queries_longer_than enabled it before
+// log_slow_queries_threshold existed.
+func (cfg *Config) slowQueriesThreshold() time.Duration {
+	if cfg.LogSlowQueriesThreshold != 0 {
+		return cfg.LogSlowQueriesThreshold
+	}
+	return cfg.Config.Handler.LogQueriesLongerThan
 }
 
 type CortexNoQuerierLimits struct{}
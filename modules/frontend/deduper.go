@@ -78,10 +78,16 @@ func (s spanIDDeduper) Do(req *http.Request) (*http.Response, error) {
 			return nil, err
 		}
 
+		// keep the headers of the response, like the trace truncated flag
+		header := resp.Header
+		if header == nil {
+			header = http.Header{}
+		}
+
 		return &http.Response{
 			StatusCode:    http.StatusOK,
 			Body:          ioutil.NopCloser(bytes.NewReader(traceBytes)),
-			Header:        http.Header{},
+			Header:        header,
 			ContentLength: resp.ContentLength,
 		}, nil
 	}
@@ -0,0 +1,171 @@
+package frontend
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	cortex_frontend "github.com/cortexproject/cortex/pkg/frontend"
+	"github.com/grafana/dskit/backoff"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/weaveworks/common/httpgrpc"
+)
+
+// defaultRoute is the route label of the requests that match no downstream route.
+const defaultRoute = "default"
+
+// DownstreamRoute sends the requests whose path starts with PathPrefix, after the http api prefix, to URL instead
+// of the downstream url or the queue.
+type DownstreamRoute struct {
+	PathPrefix string `yaml:"path_prefix"`
+	URL        string `yaml:"url"`
+	// Timeout bounds every try of a request, 0 for no timeout.
+	Timeout time.Duration `yaml:"timeout"`
+	// MaxRetries is the number of tries of a request that failed with a 5xx or a transport error, 0 or 1 to not retry.
+	// The requests of the route aren't retried by the RetryWare of the frontend.
+	MaxRetries int `yaml:"max_retries"`
+}
+
+type downstreamRouter struct {
+	apiPrefix string
+	// routes are sorted by the length of their prefix, longest first, so the most specific route matches
+	routes   []downstreamRoute
+	fallback http.RoundTripper
+
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+}
+
+type downstreamRoute struct {
+	prefix string
+	next   Handler
+}
+
+// NewDownstreamRouter sends the requests to the URL of the route they match, the others to fallback. Requests and
+// their durations are counted by route.
+func NewDownstreamRouter(apiPrefix string, routes []DownstreamRoute, fallback http.RoundTripper, backoffCfg backoff.Config, registerer prometheus.Registerer) (http.RoundTripper, error) {
+	r := &downstreamRouter{
+		apiPrefix: apiPrefix,
+		fallback:  fallback,
+		requests: promauto.With(registerer).NewCounterVec(prometheus.CounterOpts{
+			Namespace: "tempo",
+			Name:      "query_frontend_downstream_requests_total",
+			Help:      "The total number of requests sent downstream by route and status code.",
+		}, []string{"route", "status_code"}),
+		duration: promauto.With(registerer).NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "tempo",
+			Name:      "query_frontend_downstream_request_duration_seconds",
+			Help:      "Time of the requests sent downstream by route, including their retries.",
+			Buckets:   prometheus.ExponentialBuckets(0.01, 4, 8),
+		}, []string{"route"}),
+	}
+	retriesCount := promauto.With(registerer).NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "tempo",
+		Name:      "query_frontend_downstream_retries",
+		Help:      "Number of times a request sent downstream is retried by route.",
+		Buckets:   []float64{0, 1, 2, 3, 4, 5},
+	}, []string{"route"})
+	retriesTotal := promauto.With(registerer).NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "query_frontend_downstream_retries_total",
+		Help:      "The total number of retried requests sent downstream by route and the reason of the retry.",
+	}, []string{"route", "reason"})
+
+	for _, route := range routes {
+		if route.PathPrefix == "" {
+			return nil, fmt.Errorf("downstream route to %s has no path prefix", route.URL)
+		}
+		rt, err := cortex_frontend.NewDownstreamRoundTripper(route.URL, http.DefaultTransport)
+		if err != nil {
+			return nil, fmt.Errorf("invalid url of the downstream route %s: %w", route.PathPrefix, err)
+		}
+
+		r.routes = append(r.routes, downstreamRoute{
+			prefix: route.PathPrefix,
+			next: retryWare{
+				next:         downstreamTry{next: rt, timeout: route.Timeout},
+				maxRetries:   route.MaxRetries,
+				backoff:      backoffCfg,
+				retriesCount: retriesCount.WithLabelValues(route.PathPrefix),
+				retriesTotal: retriesTotal.MustCurryWith(prometheus.Labels{"route": route.PathPrefix}),
+			},
+		})
+	}
+	sort.SliceStable(r.routes, func(i, j int) bool {
+		return len(r.routes[i].prefix) > len(r.routes[j].prefix)
+	})
+
+	return r, nil
+}
+
+// RoundTrip implements http.RoundTripper
+func (r *downstreamRouter) RoundTrip(req *http.Request) (*http.Response, error) {
+	route, next := r.route(req.URL.Path)
+
+	start := time.Now()
+	resp, err := next.Do(req)
+	r.duration.WithLabelValues(route).Observe(time.Since(start).Seconds())
+
+	statusCode := http.StatusInternalServerError
+	if resp != nil {
+		statusCode = resp.StatusCode
+	} else if httpResp, ok := httpgrpc.HTTPResponseFromError(err); ok {
+		statusCode = int(httpResp.Code)
+	}
+	r.requests.WithLabelValues(route, strconv.Itoa(statusCode)).Inc()
+
+	return resp, err
+}
+
+// route returns the route of the path and the handler of the route, the fallback if the path matches no route.
+func (r *downstreamRouter) route(path string) (string, Handler) {
+	path = strings.TrimPrefix(path, r.apiPrefix)
+	for _, route := range r.routes {
+		if strings.HasPrefix(path, route.prefix) {
+			return route.prefix, route.next
+		}
+	}
+	return defaultRoute, roundTripper{next: r.fallback}
+}
+
+// downstreamTry is a try of a request sent to the URL of a route, bounded by the timeout of the route.
+type downstreamTry struct {
+	next    http.RoundTripper
+	timeout time.Duration
+}
+
+// Do implements Handler
+func (d downstreamTry) Do(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	if d.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.timeout)
+		defer cancel()
+	}
+
+	// the downstream round tripper rewrites the url of the request, every try gets its own copy
+	req = req.Clone(ctx)
+	req.RequestURI = ""
+	resp, err := d.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// the body is read before the timeout of the try is cancelled
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	return resp, nil
+}
@@ -0,0 +1,134 @@
+package frontend
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/querier/queryrange"
+	"github.com/grafana/dskit/backoff"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+
+	"github.com/grafana/tempo/pkg/util/test"
+)
+
+func TestDownstreamRouter(t *testing.T) {
+	server := func(name string) *httptest.Server {
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(name + " " + r.URL.Path))
+		}))
+		t.Cleanup(s.Close)
+		return s
+	}
+	traces := server("traces")
+	search := server("search")
+	tags := server("tags")
+
+	fallback := queryrange.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewReader([]byte("fallback " + r.URL.Path)))}, nil
+	})
+	registry := prometheus.NewRegistry()
+	rt, err := NewDownstreamRouter("/tempo", []DownstreamRoute{
+		{PathPrefix: "/api/traces/", URL: traces.URL + "/querier"},
+		{PathPrefix: "/api/search", URL: search.URL},
+		{PathPrefix: "/api/search/tags", URL: tags.URL},
+	}, fallback, backoff.Config{}, registry)
+	require.NoError(t, err)
+
+	for path, expected := range map[string]string{
+		"/tempo/api/traces/1234":           "traces /querier/tempo/api/traces/1234",
+		"/tempo/api/search?tags=a":         "search /tempo/api/search",
+		"/tempo/api/search/tags":           "tags /tempo/api/search/tags",
+		"/tempo/api/search/tag/a/values":   "search /tempo/api/search/tag/a/values",
+		"/tempo/api/echo":                  "fallback /tempo/api/echo",
+		"/tempo/api/traces/1234?start=100": "traces /querier/tempo/api/traces/1234",
+	} {
+		resp, err := rt.RoundTrip(httptest.NewRequest(http.MethodGet, path, nil))
+		require.NoError(t, err)
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Equal(t, expected, string(body), path)
+	}
+
+	router := rt.(*downstreamRouter)
+	for route, expected := range map[string]float64{"/api/traces/": 2, "/api/search": 2, "/api/search/tags": 1, defaultRoute: 1} {
+		requests, err := test.GetCounterValue(router.requests.WithLabelValues(route, "200"))
+		require.NoError(t, err)
+		assert.Equal(t, expected, requests, route)
+	}
+}
+
+func TestDownstreamRouterRetries(t *testing.T) {
+	tries := atomic.NewInt32(0)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if tries.Inc() < 3 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer s.Close()
+
+	tests := []struct {
+		maxRetries     int
+		expectedStatus int
+		expectedTries  int32
+	}{
+		{maxRetries: 0, expectedStatus: http.StatusServiceUnavailable, expectedTries: 1},
+		{maxRetries: 2, expectedStatus: http.StatusServiceUnavailable, expectedTries: 2},
+		{maxRetries: 5, expectedStatus: http.StatusOK, expectedTries: 3},
+	}
+	for _, tt := range tests {
+		tries.Store(0)
+		rt, err := NewDownstreamRouter("", []DownstreamRoute{{PathPrefix: "/api/search", URL: s.URL, MaxRetries: tt.maxRetries}}, nil, backoff.Config{}, prometheus.NewRegistry())
+		require.NoError(t, err)
+
+		resp, err := rt.RoundTrip(httptest.NewRequest(http.MethodGet, "/api/search", nil))
+		require.NoError(t, err)
+		assert.Equal(t, tt.expectedStatus, resp.StatusCode)
+		assert.Equal(t, tt.expectedTries, tries.Load())
+
+		// the RetryWare of the frontend doesn't retry the requests retried by the route
+		tries.Store(0)
+		resp, err = NewRoundTripper(rt, RetryWare(5, backoff.Config{}, prometheus.NewRegistry())).RoundTrip(httptest.NewRequest(http.MethodGet, "/api/search", nil))
+		require.NoError(t, err)
+		assert.Equal(t, tt.expectedStatus, resp.StatusCode)
+		assert.Equal(t, tt.expectedTries, tries.Load())
+	}
+}
+
+func TestDownstreamRouterTimeout(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	}))
+	defer s.Close()
+
+	rt, err := NewDownstreamRouter("", []DownstreamRoute{
+		{PathPrefix: "/api/traces/", URL: s.URL, Timeout: 10 * time.Millisecond},
+	}, nil, backoff.Config{}, prometheus.NewRegistry())
+	require.NoError(t, err)
+
+	start := time.Now()
+	_, err = rt.RoundTrip(httptest.NewRequest(http.MethodGet, "/api/traces/1234", nil))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestDownstreamRouterInvalidRoutes(t *testing.T) {
+	_, err := NewDownstreamRouter("", []DownstreamRoute{{URL: "http://querier"}}, nil, backoff.Config{}, prometheus.NewRegistry())
+	assert.Error(t, err)
+
+	_, err = NewDownstreamRouter("", []DownstreamRoute{{PathPrefix: "/api/search", URL: ":querier"}}, nil, backoff.Config{}, prometheus.NewRegistry())
+	assert.Error(t, err)
+}
@@ -0,0 +1,283 @@
+package frontend
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/cortexproject/cortex/pkg/querier/queryrange"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/grafana/dskit/flagext"
+	"github.com/opentracing/opentracing-go"
+	"github.com/pkg/errors"
+	"github.com/weaveworks/common/httpgrpc"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/tempo/modules/querier"
+	"github.com/grafana/tempo/pkg/audit"
+	"github.com/grafana/tempo/pkg/tempopb"
+	"github.com/grafana/tempo/pkg/util"
+)
+
+// FederatedTenantHeader is the tenant whose trace a federated trace by id query returned.
+const FederatedTenantHeader = "X-Tempo-Federated-Tenant"
+
+// FederationConfig configures the queries of all tenants at once.
+type FederationConfig struct {
+	// Enabled is set by multitenancy.federation-enabled, federation is off unless it's explicitly enabled.
+	Enabled bool `yaml:"-"`
+	// OrgID is the org id of the federated queries.
+	OrgID string `yaml:"org_id"`
+	// Tenants are the tenants federated queries are sent to.
+	Tenants flagext.StringSliceCSV `yaml:"tenants"`
+}
+
+// FederationWare sends the queries of the federation org id to every federated tenant. A trace by id query returns
+// the trace of the first tenant that has it, in the order of the tenants. A search returns the results of all tenants
+// with the tenant of every result. Every federated query is recorded in the audit log.
+func FederationWare(cfg FederationConfig, apiPrefix string, auditLogger *audit.Logger, logger log.Logger) queryrange.Tripperware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		if !cfg.Enabled {
+			return next
+		}
+
+		return queryrange.RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+			orgID, err := user.ExtractOrgID(req.Context())
+			if err != nil || orgID != cfg.OrgID {
+				return next.RoundTrip(req)
+			}
+
+			level.Info(logger).Log("msg", "federated query", "path", req.URL.Path, "params", req.URL.RawQuery, "tenants", strings.Join(cfg.Tenants, ","), "remote_addr", req.RemoteAddr)
+			auditLogger.Log(&audit.Entry{
+				Actor:  orgID,
+				Action: "query_frontend.federated_query",
+				Target: req.URL.RequestURI(),
+			})
+
+			path := strings.TrimPrefix(req.URL.Path, apiPrefix)
+			switch {
+			case strings.HasPrefix(path, apiPathTraces):
+				return federateTraceByID(req, cfg.Tenants, next)
+			case path == apiPathSearch:
+				return federateSearch(req, cfg.Tenants, next, logger)
+			}
+			return nil, httpgrpc.Errorf(http.StatusBadRequest, "federated queries only support trace by id queries and searches")
+		})
+	}
+}
+
+// rejectFederatedStreams rejects the streamed searches of the federation org id. The shards of a stream are searches of
+// a single tenant.
+func rejectFederatedStreams(cfg FederationConfig) queryrange.Tripperware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		if !cfg.Enabled {
+			return next
+		}
+
+		return queryrange.RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+			if orgID, err := user.ExtractOrgID(req.Context()); err == nil && orgID == cfg.OrgID {
+				return nil, httpgrpc.Errorf(http.StatusBadRequest, "federated searches can not be streamed")
+			}
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+type federatedResult struct {
+	tenant string
+	resp   *http.Response
+	body   []byte
+	err    error
+}
+
+// fanOut sends the request to every tenant at once and returns the results in the order of the tenants.
+func fanOut(req *http.Request, tenants []string, next http.RoundTripper) []federatedResult {
+	span, ctx := opentracing.StartSpanFromContext(req.Context(), "frontend.Federation")
+	defer span.Finish()
+	span.SetTag("tenants", len(tenants))
+
+	results := make([]federatedResult, len(tenants))
+	var wg sync.WaitGroup
+	for i, tenant := range tenants {
+		wg.Add(1)
+		go func(i int, tenant string) {
+			defer wg.Done()
+
+			tenantReq := req.Clone(user.InjectOrgID(ctx, tenant))
+			tenantReq.Header.Set(user.OrgIDHeaderName, tenant)
+
+			result := federatedResult{tenant: tenant}
+			result.resp, result.err = next.RoundTrip(tenantReq)
+			if result.err == nil {
+				result.body, result.err = io.ReadAll(result.resp.Body)
+				result.resp.Body.Close()
+			}
+			results[i] = result
+		}(i, tenant)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// federateTraceByID returns the trace of the first tenant that has it. The query fails if none has it and the query of
+// a tenant failed, the trace might have been in that tenant.
+func federateTraceByID(req *http.Request, tenants []string, next http.RoundTripper) (*http.Response, error) {
+	results := fanOut(req, tenants, next)
+
+	for _, result := range results {
+		if result.err == nil && result.resp.StatusCode == http.StatusOK {
+			result.resp.Header.Set(FederatedTenantHeader, result.tenant)
+			return result.response(), nil
+		}
+	}
+	for _, result := range results {
+		if result.err != nil {
+			return nil, result.err
+		}
+		if result.resp.StatusCode != http.StatusNotFound {
+			return result.response(), nil
+		}
+	}
+	return results[0].response(), nil
+}
+
+// federateSearch merges the results of the searches of all tenants like the results of the shards of a search. Every
+// result has the tenant it was found in. A failed search of a tenant returns the results of the others as partial
+// with a warning.
+func federateSearch(req *http.Request, tenants []string, next http.RoundTripper, logger log.Logger) (*http.Response, error) {
+	limit := 0
+	if l := req.URL.Query().Get(urlParamLimit); l != "" {
+		limit, _ = strconv.Atoi(l)
+	}
+
+	type tenantTrace struct {
+		tenant string
+		trace  *tempopb.TraceSearchMetadata
+	}
+	var traces []tenantTrace
+	metrics := &tempopb.SearchMetrics{}
+	partial := false
+	var warnings []string
+	var firstFailure *federatedResult
+
+	results := fanOut(req, tenants, next)
+	for i, result := range results {
+		if result.err == nil && result.resp.StatusCode == http.StatusOK {
+			searchResp := &tempopb.SearchResponse{}
+			if err := jsonpb.Unmarshal(bytes.NewReader(result.body), searchResp); err != nil {
+				result.err = errors.Wrap(err, "error unmarshalling federated search response")
+			} else {
+				for _, t := range searchResp.Traces {
+					traces = append(traces, tenantTrace{tenant: result.tenant, trace: t})
+				}
+				if m := searchResp.Metrics; m != nil {
+					metrics.InspectedBytes += m.InspectedBytes
+					metrics.InspectedTraces += m.InspectedTraces
+					metrics.InspectedBlocks += m.InspectedBlocks
+					metrics.SkippedBlocks += m.SkippedBlocks
+				}
+				partial = partial || result.resp.Header.Get(querier.SearchPartialHeader) == "true"
+				warnings = append(warnings, result.resp.Header.Values(querier.WarningHeader)...)
+				continue
+			}
+		}
+
+		if firstFailure == nil {
+			firstFailure = &results[i]
+		}
+		msg := ""
+		if result.err != nil {
+			msg = result.err.Error()
+		} else {
+			msg = strings.TrimSpace(string(result.body))
+		}
+		level.Warn(logger).Log("msg", "federated search of tenant failed", "tenant", result.tenant, "err", msg)
+		warnings = append(warnings, fmt.Sprintf("search of tenant %s failed: %s", result.tenant, msg))
+	}
+
+	// the search fails if the searches of all tenants failed
+	if allFailed(results) {
+		if firstFailure.err != nil {
+			return nil, firstFailure.err
+		}
+		return firstFailure.response(), nil
+	}
+
+	sort.SliceStable(traces, func(i, j int) bool {
+		return traces[i].trace.StartTimeUnixNano > traces[j].trace.StartTimeUnixNano
+	})
+	if limit > 0 && limit < len(traces) {
+		traces = traces[:limit]
+	}
+
+	// the results are the json of the search results of a tenant with the tenant added
+	resp := struct {
+		Traces  []map[string]json.RawMessage `json:"traces"`
+		Metrics json.RawMessage              `json:"metrics"`
+	}{Traces: []map[string]json.RawMessage{}}
+	marshaler := &jsonpb.Marshaler{}
+	for _, t := range traces {
+		s, err := marshaler.MarshalToString(t.trace)
+		if err != nil {
+			return nil, errors.Wrap(err, "error marshalling federated search response")
+		}
+		fields := map[string]json.RawMessage{}
+		if err := json.Unmarshal([]byte(s), &fields); err != nil {
+			return nil, errors.Wrap(err, "error marshalling federated search response")
+		}
+		fields["tenant"], _ = json.Marshal(t.tenant)
+		resp.Traces = append(resp.Traces, fields)
+	}
+	s, err := marshaler.MarshalToString(metrics)
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshalling federated search response")
+	}
+	resp.Metrics = json.RawMessage(s)
+
+	body, err := json.Marshal(resp)
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshalling federated search response")
+	}
+
+	header := http.Header{}
+	header.Set("Content-Type", util.JSONTypeHeaderValue)
+	if partial || firstFailure != nil {
+		header.Set(querier.SearchPartialHeader, "true")
+	}
+	for _, warning := range warnings {
+		header.Add(querier.WarningHeader, warning)
+	}
+	return &http.Response{
+		StatusCode:    http.StatusOK,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+	}, nil
+}
+
+func allFailed(results []federatedResult) bool {
+	for _, result := range results {
+		if result.err == nil && result.resp.StatusCode == http.StatusOK {
+			return false
+		}
+	}
+	return true
+}
+
+// response returns the response of the tenant with its body.
+func (r federatedResult) response() *http.Response {
+	resp := *r.resp
+	resp.Body = ioutil.NopCloser(bytes.NewReader(r.body))
+	resp.ContentLength = int64(len(r.body))
+	return &resp
+}
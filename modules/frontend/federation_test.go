@@ -0,0 +1,166 @@
+package frontend
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cortexproject/cortex/pkg/querier/queryrange"
+	"github.com/go-kit/kit/log"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/httpgrpc"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/tempo/modules/querier"
+	"github.com/grafana/tempo/pkg/tempopb"
+)
+
+func TestFederationTraceByID(t *testing.T) {
+	tests := []struct {
+		name           string
+		statusCodes    map[string]int
+		expectedStatus int
+		expectedTenant string
+	}{
+		{
+			name:           "first tenant with the trace",
+			statusCodes:    map[string]int{"a": http.StatusNotFound, "b": http.StatusOK, "c": http.StatusOK},
+			expectedStatus: http.StatusOK,
+			expectedTenant: "b",
+		},
+		{
+			name:           "no tenant with the trace",
+			statusCodes:    map[string]int{"a": http.StatusNotFound, "b": http.StatusNotFound, "c": http.StatusNotFound},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "failed tenant",
+			statusCodes:    map[string]int{"a": http.StatusNotFound, "b": http.StatusInternalServerError, "c": http.StatusNotFound},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rt := FederationWare(FederationConfig{Enabled: true, OrgID: "__all__", Tenants: []string{"a", "b", "c"}}, "", nil, log.NewNopLogger())(
+				queryrange.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+					tenant, err := user.ExtractOrgID(r.Context())
+					require.NoError(t, err)
+					assert.Equal(t, tenant, r.Header.Get(user.OrgIDHeaderName))
+					return &http.Response{
+						StatusCode: tt.statusCodes[tenant],
+						Header:     http.Header{},
+						Body:       ioutil.NopCloser(bytes.NewReader([]byte(tenant))),
+					}, nil
+				}))
+
+			resp, err := rt.RoundTrip(federatedRequest("/api/traces/1234"))
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, resp.StatusCode)
+			assert.Equal(t, tt.expectedTenant, resp.Header.Get(FederatedTenantHeader))
+			if tt.expectedTenant != "" {
+				body, err := io.ReadAll(resp.Body)
+				require.NoError(t, err)
+				assert.Equal(t, tt.expectedTenant, string(body))
+			}
+		})
+	}
+}
+
+func TestFederationSearch(t *testing.T) {
+	results := map[string]*tempopb.SearchResponse{
+		"a": {
+			Traces: []*tempopb.TraceSearchMetadata{
+				{TraceID: "1", StartTimeUnixNano: 100},
+				{TraceID: "2", StartTimeUnixNano: 300},
+			},
+			Metrics: &tempopb.SearchMetrics{InspectedTraces: 2, InspectedBytes: 10},
+		},
+		"b": {
+			Traces:  []*tempopb.TraceSearchMetadata{{TraceID: "3", StartTimeUnixNano: 200}},
+			Metrics: &tempopb.SearchMetrics{InspectedTraces: 1, InspectedBytes: 5},
+		},
+	}
+	rt := FederationWare(FederationConfig{Enabled: true, OrgID: "__all__", Tenants: []string{"a", "b", "c"}}, "/tempo", nil, log.NewNopLogger())(
+		queryrange.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+			tenant, err := user.ExtractOrgID(r.Context())
+			require.NoError(t, err)
+			result, ok := results[tenant]
+			if !ok {
+				return nil, httpgrpc.Errorf(http.StatusInternalServerError, "unavailable")
+			}
+			s, err := (&jsonpb.Marshaler{}).MarshalToString(result)
+			require.NoError(t, err)
+			return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: ioutil.NopCloser(bytes.NewReader([]byte(s)))}, nil
+		}))
+
+	resp, err := rt.RoundTrip(federatedRequest("/tempo/api/search?limit=2"))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	// the search of tenant c failed, the results of a and b are partial
+	assert.Equal(t, "true", resp.Header.Get(querier.SearchPartialHeader))
+	assert.Len(t, resp.Header.Values(querier.WarningHeader), 1)
+
+	var actual struct {
+		Traces []struct {
+			TraceID           string `json:"traceID"`
+			StartTimeUnixNano string `json:"startTimeUnixNano"`
+			Tenant            string `json:"tenant"`
+		} `json:"traces"`
+		Metrics struct {
+			InspectedTraces int `json:"inspectedTraces"`
+		} `json:"metrics"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&actual))
+	require.Len(t, actual.Traces, 2)
+	assert.Equal(t, "2", actual.Traces[0].TraceID)
+	assert.Equal(t, "a", actual.Traces[0].Tenant)
+	assert.Equal(t, "300", actual.Traces[0].StartTimeUnixNano)
+	assert.Equal(t, "3", actual.Traces[1].TraceID)
+	assert.Equal(t, "b", actual.Traces[1].Tenant)
+	assert.Equal(t, 3, actual.Metrics.InspectedTraces)
+}
+
+func TestFederationPassThrough(t *testing.T) {
+	var tenants []string
+	next := queryrange.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+		tenant, err := user.ExtractOrgID(r.Context())
+		require.NoError(t, err)
+		tenants = append(tenants, tenant)
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewReader(nil))}, nil
+	})
+	cfg := FederationConfig{OrgID: "__all__", Tenants: []string{"a", "b"}}
+
+	// federation is off unless it's enabled
+	_, err := FederationWare(cfg, "", nil, log.NewNopLogger())(next).RoundTrip(federatedRequest("/api/traces/1234"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"__all__"}, tenants)
+
+	// other org ids are not federated
+	cfg.Enabled = true
+	tenants = nil
+	req := httptest.NewRequest(http.MethodGet, "/api/traces/1234", nil)
+	_, err = FederationWare(cfg, "", nil, log.NewNopLogger())(next).RoundTrip(req.WithContext(user.InjectOrgID(context.Background(), "a")))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a"}, tenants)
+
+	// only trace by id queries and searches are federated
+	tenants = nil
+	_, err = FederationWare(cfg, "", nil, log.NewNopLogger())(next).RoundTrip(federatedRequest("/api/search/tags"))
+	resp, ok := httpgrpc.HTTPResponseFromError(err)
+	require.True(t, ok)
+	assert.Equal(t, int32(http.StatusBadRequest), resp.Code)
+	assert.Empty(t, tenants)
+}
+
+func federatedRequest(target string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	return req.WithContext(user.InjectOrgID(context.Background(), "__all__"))
+}
@@ -8,11 +8,13 @@ import (
 	"strings"
 	"time"
 
+	cortex_cache "github.com/cortexproject/cortex/pkg/chunk/cache"
 	"github.com/cortexproject/cortex/pkg/querier/queryrange"
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
 	"github.com/golang/protobuf/jsonpb"
 	"github.com/golang/protobuf/proto"
+	"github.com/grafana/dskit/backoff"
 	"github.com/opentracing/opentracing-go"
 	ot_log "github.com/opentracing/opentracing-go/log"
 	"github.com/pkg/errors"
@@ -22,6 +24,10 @@ import (
 	"github.com/weaveworks/common/tracing"
 	"github.com/weaveworks/common/user"
 
+	"github.com/grafana/tempo/modules/overrides"
+	"github.com/grafana/tempo/modules/querier"
+	"github.com/grafana/tempo/pkg/audit"
+	"github.com/grafana/tempo/pkg/model"
 	"github.com/grafana/tempo/pkg/tempopb"
 	"github.com/grafana/tempo/pkg/util"
 )
@@ -31,21 +37,76 @@ const (
 	apiPathSearch = "/api/search"
 )
 
-// NewTripperware returns a Tripperware configured with a middleware to route, split and dedupe requests.
-func NewTripperware(cfg Config, apiPrefix string, logger log.Logger, registerer prometheus.Registerer) (queryrange.Tripperware, error) {
+// Tripperwares are the middlewares of the queries of the frontend. The queries and the streamed searches share the per
+// tenant limits and the priority queue.
+type Tripperwares struct {
+	// Queries routes, splits and dedupes the queries served by the cortex handler.
+	Queries queryrange.Tripperware
+	// SearchStreams wraps a streamed search as a whole. It rejects the search at the per tenant limits, bounds its
+	// duration and logs it if it's slow.
+	SearchStreams queryrange.Tripperware
+	// SearchStreamShards wraps the requests the shards of a streamed search are sent to the queue with.
+	SearchStreamShards queryrange.Tripperware
+}
+
+// NewTripperware returns the Tripperwares configured with a middleware to route, split and dedupe requests. The per
+// tenant limits of the query frontend are read from limits. Federated queries are recorded in auditLogger.
+func NewTripperware(cfg Config, apiPrefix string, limits *overrides.Overrides, auditLogger *audit.Logger, logger log.Logger, registerer prometheus.Registerer) (Tripperwares, error) {
 	level.Info(logger).Log("msg", "creating tripperware in query frontend")
 
-	tracesTripperware := NewTracesTripperware(cfg, logger, registerer)
-	searchTripperware := NewSearchTripperware()
+	traceCache, err := newTraceCacheClient(cfg.TraceCache, logger)
+	if err != nil {
+		return Tripperwares{}, err
+	}
 
-	return func(next http.RoundTripper) http.RoundTripper {
-		traces := tracesTripperware(next)
-		search := searchTripperware(next)
+	tracesTripperware := NewTracesTripperware(cfg, traceCache, logger, registerer)
+	searchTripperware := NewSearchTripperware(cfg, logger)
 
-		return newFrontendRoundTripper(apiPrefix, next, traces, search, logger, registerer)
+	tenantLimits := newTenantLimits(limits, cfg.Config.FrontendV1.MaxOutstandingPerTenant, registerer)
+	priorityQueue := newPriorityQueue(cfg.Priority)
+
+	queue := func(next http.RoundTripper) http.RoundTripper {
+		return tenantLimits.countOutstanding(priorityQueue.wrap(next))
+	}
+
+	return Tripperwares{
+		Queries: func(next http.RoundTripper) http.RoundTripper {
+			next = queue(next)
+			traces := tracesTripperware(next)
+			search := searchTripperware(next)
+
+			rt := withQueryPriority(tenantLimits.limitQueries(newFrontendRoundTripper(apiPrefix, next, traces, search, logger, registerer)))
+			rt = SlowQueryWare(apiPrefix, cfg.slowQueriesThreshold(), logger)(rt)
+			rt = FederationWare(cfg.Federation, apiPrefix, auditLogger, logger)(rt)
+			return removeQueryShardHeader(CompressionWare(cfg.Compression)(rt))
+		},
+		// the stream writes its response itself, it's neither compressed nor federated
+		SearchStreams: func(next http.RoundTripper) http.RoundTripper {
+			rt := withQueryPriority(tenantLimits.limitQueries(next))
+			rt = SlowQueryWare(apiPrefix, cfg.slowQueriesThreshold(), logger)(rt)
+			return rejectFederatedStreams(cfg.Federation)(rt)
+		},
+		SearchStreamShards: queue,
 	}, nil
 }
 
+// removeQueryShardHeader removes the querier.QueryShardHeader from the requests of clients, only the shards of the
+// query-frontend skip the query rate limit of the queriers.
+func removeQueryShardHeader(next http.RoundTripper) http.RoundTripper {
+	return queryrange.RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if req.Header.Get(querier.QueryShardHeader) != "" {
+			req = req.Clone(req.Context())
+			req.Header.Del(querier.QueryShardHeader)
+		}
+		return next.RoundTrip(req)
+	})
+}
+
+// markQueryShard marks req as a shard of a query that is already counted by the query rate limit of the queriers.
+func markQueryShard(req *http.Request) {
+	req.Header.Set(querier.QueryShardHeader, "true")
+}
+
 type frontendRoundTripper struct {
 	apiPrefix            string
 	next, traces, search http.RoundTripper
@@ -95,6 +156,7 @@ func (r frontendRoundTripper) RoundTrip(req *http.Request) (resp *http.Response,
 		resp, err = r.next.RoundTrip(req)
 	}
 
+	duration := time.Since(start)
 	traceID, _ := tracing.ExtractTraceID(ctx)
 	statusCode := 500
 	var contentLength int64 = 0
@@ -111,7 +173,7 @@ func (r frontendRoundTripper) RoundTrip(req *http.Request) (resp *http.Response,
 		"method", req.Method,
 		"traceID", traceID,
 		"url", req.URL.RequestURI(),
-		"duration", time.Since(start).String(),
+		"duration", duration.String(),
 		"response_size", contentLength,
 		"status", statusCode,
 	)
@@ -144,14 +206,17 @@ func getOperation(prefix, path string) RequestOp {
 	}
 }
 
-// NewTracesTripperware creates a new frontend tripperware responsible for handling get traces requests.
-func NewTracesTripperware(cfg Config, logger log.Logger, registerer prometheus.Registerer) func(next http.RoundTripper) http.RoundTripper {
+// NewTracesTripperware creates a new frontend tripperware responsible for handling get traces requests. A nil
+// traceCache disables the cache of trace by id responses.
+func NewTracesTripperware(cfg Config, traceCache cortex_cache.Cache, logger log.Logger, registerer prometheus.Registerer) func(next http.RoundTripper) http.RoundTripper {
 	return func(next http.RoundTripper) http.RoundTripper {
 		// We're constructing middleware in this statement, each middleware wraps the next one from left-to-right
+		// - the TraceCacheWare answers queries for traces that no longer change from the cache
+		// - the CollapsingWare lets identical queries in flight wait for the result of the first one
 		// - the Deduper dedupes Span IDs for Zipkin support
-		// - the ShardingWare shards queries by splitting the block ID space
-		// - the RetryWare retries requests that have failed (error or http status 500)
-		rt := NewRoundTripper(next, Deduper(logger), ShardingWare(cfg.QueryShards, logger), RetryWare(cfg.MaxRetries, registerer))
+		// - the ShardingWare shards queries by splitting the block ID space, sized by the block count of the tenant
+		// - the RetryWare retries requests that have failed with a 5xx or a transport error, with backoff
+		rt := NewRoundTripper(next, TraceCacheWare(traceCache, cfg.TraceCache, registerer), CollapsingWare(registerer), Deduper(logger), ShardingWare(cfg.QueryShards, cfg.TargetBlocksPerShard, logger), RetryWare(cfg.MaxRetries, backoff.Config{MinBackoff: cfg.RetryMinBackoff, MaxBackoff: cfg.RetryMaxBackoff}, registerer))
 
 		return queryrange.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
 			// don't start a new span, this is already handled by frontendRoundTripper
@@ -169,18 +234,15 @@ func NewTracesTripperware(cfg Config, logger log.Logger, registerer prometheus.R
 			span.LogFields(ot_log.String("msg", "validated traceID"))
 
 			// check marshalling format
-			marshallingFormat := util.JSONTypeHeaderValue
-			if r.Header.Get(util.AcceptHeaderKey) == util.ProtobufTypeHeaderValue {
-				marshallingFormat = util.ProtobufTypeHeaderValue
-			}
+			marshallingFormat := util.NegotiateTraceFormat(r.Header.Get(util.AcceptHeaderKey))
 
 			// Enforce all communication internal to Tempo to be in protobuf bytes
 			r.Header.Set(util.AcceptHeaderKey, util.ProtobufTypeHeaderValue)
 
 			resp, err := rt.RoundTrip(r)
 
-			if resp != nil && resp.StatusCode == http.StatusOK && marshallingFormat == util.JSONTypeHeaderValue {
-				// if request is for application/json, unmarshal into proto object and re-marshal into json bytes
+			if resp != nil && resp.StatusCode == http.StatusOK && marshallingFormat != util.ProtobufTypeHeaderValue {
+				// if request is for json, unmarshal into proto object and re-marshal into json bytes
 				body, err := io.ReadAll(resp.Body)
 				resp.Body.Close()
 				if err != nil {
@@ -192,13 +254,24 @@ func NewTracesTripperware(cfg Config, logger log.Logger, registerer prometheus.R
 					return nil, err
 				}
 
-				var jsonTrace bytes.Buffer
-				marshaller := &jsonpb.Marshaler{}
-				err = marshaller.Marshal(&jsonTrace, traceObject)
+				var jsonTrace []byte
+				if marshallingFormat == util.JaegerJSONTypeHeaderValue {
+					jsonTrace, err = model.MarshalJaegerJSON(traceObject)
+				} else {
+					var buf bytes.Buffer
+					marshaller := &jsonpb.Marshaler{}
+					err = marshaller.Marshal(&buf, traceObject)
+					jsonTrace = buf.Bytes()
+				}
 				if err != nil {
 					return nil, err
 				}
-				resp.Body = ioutil.NopCloser(bytes.NewReader(jsonTrace.Bytes()))
+				resp.Body = ioutil.NopCloser(bytes.NewReader(jsonTrace))
+				resp.ContentLength = int64(len(jsonTrace))
+				if resp.Header == nil {
+					resp.Header = http.Header{}
+				}
+				resp.Header.Set("Content-Type", marshallingFormat)
 			}
 			span.SetTag("response marshalling format", marshallingFormat)
 
@@ -208,10 +281,14 @@ func NewTracesTripperware(cfg Config, logger log.Logger, registerer prometheus.R
 }
 
 // NewSearchTripperware creates a new frontend tripperware to handle search and search tags requests.
-func NewSearchTripperware() queryrange.Tripperware {
-	return func(rt http.RoundTripper) http.RoundTripper {
+func NewSearchTripperware(cfg Config, logger log.Logger) queryrange.Tripperware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		// - the SearchShardingWare splits searches of long time ranges into searches of intervals of the range
+		rt := NewRoundTripper(next, SearchShardingWare(cfg.SearchShardInterval, cfg.SearchShardMaxShards, cfg.SearchShardConcurrency, logger))
+
 		return queryrange.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
 			orgID, _ := user.ExtractOrgID(r.Context())
+			setQueryShards(r.Context(), 1)
 
 			r.Header.Set(user.OrgIDHeaderName, orgID)
 			r.RequestURI = querierPrefix + r.RequestURI
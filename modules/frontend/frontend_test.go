@@ -2,15 +2,30 @@ package frontend
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"testing"
 
+	"github.com/cortexproject/cortex/pkg/querier/queryrange"
 	"github.com/go-kit/kit/log"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/gorilla/mux"
+	"github.com/opentracing/opentracing-go"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/tempo/modules/querier"
+	"github.com/grafana/tempo/pkg/model"
+	"github.com/grafana/tempo/pkg/tempopb"
+	"github.com/grafana/tempo/pkg/util"
+	"github.com/grafana/tempo/pkg/util/test"
 )
 
 type mockNextTripperware struct{}
@@ -99,3 +114,80 @@ func TestFrontendRoundTripper(t *testing.T) {
 		})
 	}
 }
+
+func TestRemoveQueryShardHeader(t *testing.T) {
+	var header string
+	rt := removeQueryShardHeader(queryrange.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+		header = r.Header.Get(querier.QueryShardHeader)
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewReader(nil))}, nil
+	}))
+
+	// clients can't skip the query rate limit of the queriers
+	req := httptest.NewRequest(http.MethodGet, apiPathTraces+"/0102", nil)
+	req.Header.Set(querier.QueryShardHeader, "true")
+	_, err := rt.RoundTrip(req)
+	require.NoError(t, err)
+	assert.Empty(t, header)
+	assert.Equal(t, "true", req.Header.Get(querier.QueryShardHeader))
+}
+
+func TestTracesTripperwareFormats(t *testing.T) {
+	trace := test.MakeTrace(2, []byte{0x01, 0x02})
+	b, err := proto.Marshal(trace)
+	require.NoError(t, err)
+	next := queryrange.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+		// the queriers are always asked for protobuf, the trace returned by both shards is combined into one
+		assert.Equal(t, util.ProtobufTypeHeaderValue, r.Header.Get(util.AcceptHeaderKey))
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(bytes.NewReader(b)),
+			Header:     http.Header{},
+		}, nil
+	})
+	tripper := NewTracesTripperware(Config{QueryShards: 2}, nil, log.NewNopLogger(), prometheus.NewRegistry())(next)
+
+	roundTrip := func(accept string) *http.Response {
+		span, ctx := opentracing.StartSpanFromContext(user.InjectOrgID(context.Background(), "tenant"), "test")
+		defer span.Finish()
+		r := httptest.NewRequest(http.MethodGet, apiPathTraces+"/0102", nil).WithContext(ctx)
+		r = mux.SetURLVars(r, map[string]string{util.TraceIDVar: "0102"})
+		if accept != "" {
+			r.Header.Set(util.AcceptHeaderKey, accept)
+		}
+		resp, err := tripper.RoundTrip(r)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		return resp
+	}
+
+	resp := roundTrip(util.ProtobufTypeHeaderValue)
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	actual := &tempopb.Trace{}
+	require.NoError(t, proto.Unmarshal(body, actual))
+	assert.Equal(t, len(trace.Batches), len(actual.Batches))
+
+	for _, accept := range []string{"", util.JSONTypeHeaderValue, "*/*"} {
+		resp = roundTrip(accept)
+		assert.Equal(t, util.JSONTypeHeaderValue, resp.Header.Get("Content-Type"))
+		actual = &tempopb.Trace{}
+		require.NoError(t, jsonpb.Unmarshal(resp.Body, actual))
+		assert.Equal(t, len(trace.Batches), len(actual.Batches))
+	}
+
+	resp = roundTrip("application/json;q=0.5, " + util.JaegerJSONTypeHeaderValue)
+	assert.Equal(t, util.JaegerJSONTypeHeaderValue, resp.Header.Get("Content-Type"))
+	body, err = ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(body)), resp.ContentLength)
+	jaegerResp := &model.JaegerResponse{}
+	require.NoError(t, json.Unmarshal(body, jaegerResp))
+	require.Len(t, jaegerResp.Data, 1)
+	spans := 0
+	for _, b := range trace.Batches {
+		for _, ils := range b.InstrumentationLibrarySpans {
+			spans += len(ils.Spans)
+		}
+	}
+	assert.Len(t, jaegerResp.Data[0].Spans, spans)
+}
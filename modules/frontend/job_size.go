@@ -0,0 +1,35 @@
+package frontend
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/cortexproject/cortex/pkg/querier/queryrange"
+	"github.com/weaveworks/common/httpgrpc"
+	"github.com/weaveworks/common/httpgrpc/server"
+)
+
+// MaxJobSizeWare rejects the requests to the queriers whose httpgrpc encoding, the job that is queued and sent to a
+// querier, is larger than maxBytes with 413. 0 disables the limit.
+func MaxJobSizeWare(maxBytes int) queryrange.Tripperware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		if maxBytes <= 0 {
+			return next
+		}
+
+		return queryrange.RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+			job, err := server.HTTPRequest(req)
+			if err != nil {
+				return nil, err
+			}
+			// the body was read to size the job, the queue reads it again
+			req.Body = ioutil.NopCloser(bytes.NewReader(job.Body))
+
+			if size := job.Size(); size > maxBytes {
+				return nil, httpgrpc.Errorf(http.StatusRequestEntityTooLarge, "job of %d bytes exceeds the max job size of %d bytes", size, maxBytes)
+			}
+			return next.RoundTrip(req)
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package frontend
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cortexproject/cortex/pkg/querier/queryrange"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/httpgrpc"
+)
+
+func TestMaxJobSizeWare(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxBytes int
+		query    string
+		body     string
+		rejected bool
+	}{
+		{name: "disabled", maxBytes: 0, query: strings.Repeat("a", 1000)},
+		{name: "small job", maxBytes: 1000, query: "tags=a"},
+		{name: "large query", maxBytes: 1000, query: "tags=" + strings.Repeat("a", 1000), rejected: true},
+		{name: "large body", maxBytes: 1000, body: strings.Repeat("a", 1000), rejected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var queuedBody string
+			rt := MaxJobSizeWare(tt.maxBytes)(queryrange.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+				b, err := ioutil.ReadAll(r.Body)
+				require.NoError(t, err)
+				queuedBody = string(b)
+				return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewReader(nil))}, nil
+			}))
+
+			req := httptest.NewRequest(http.MethodPost, apiPathSearch+"?"+tt.query, strings.NewReader(tt.body))
+			resp, err := rt.RoundTrip(req)
+
+			if tt.rejected {
+				errResp, ok := httpgrpc.HTTPResponseFromError(err)
+				require.True(t, ok)
+				assert.Equal(t, int32(http.StatusRequestEntityTooLarge), errResp.Code)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			// the body read to size the job is still sent
+			assert.Equal(t, tt.body, queuedBody)
+		})
+	}
+}
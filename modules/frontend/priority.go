@@ -0,0 +1,190 @@
+package frontend
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/cortexproject/cortex/pkg/querier/queryrange"
+	"github.com/opentracing/opentracing-go"
+	"github.com/weaveworks/common/user"
+)
+
+const (
+	// PriorityHeader is the header of the priority class of a query, interactive or batch.
+	PriorityHeader = "X-Tempo-Query-Priority"
+
+	PriorityInteractive = "interactive"
+	PriorityBatch       = "batch"
+)
+
+// priorities are the priority classes, in the order of their sub-queues
+var priorities = []string{PriorityInteractive, PriorityBatch}
+
+// PriorityConfig configures the priority classes of the queue of the frontend.
+type PriorityConfig struct {
+	// MaxDispatchedPerTenant is the number of requests of a tenant that are sent to the queue at once. Requests over
+	// it wait in the sub-queue of their priority class. 0 sends all requests to the queue right away.
+	MaxDispatchedPerTenant int `yaml:"max_dispatched_per_tenant"`
+	// InteractiveWeight and BatchWeight are the ratio the sub-queues of a tenant are dequeued with.
+	InteractiveWeight int `yaml:"interactive_weight"`
+	BatchWeight       int `yaml:"batch_weight"`
+}
+
+type priorityKey struct{}
+
+// queryPriority returns the priority class of the query of ctx, interactive if none was set.
+func queryPriority(ctx context.Context) string {
+	if p, ok := ctx.Value(priorityKey{}).(string); ok {
+		return p
+	}
+	return PriorityInteractive
+}
+
+// withQueryPriority sets the priority class of the query from the PriorityHeader of the request. Unknown classes fall
+// back to interactive.
+func withQueryPriority(next http.RoundTripper) http.RoundTripper {
+	return queryrange.RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+		priority := PriorityInteractive
+		if strings.EqualFold(strings.TrimSpace(req.Header.Get(PriorityHeader)), PriorityBatch) {
+			priority = PriorityBatch
+		}
+		return next.RoundTrip(req.WithContext(context.WithValue(req.Context(), priorityKey{}, priority)))
+	})
+}
+
+// priorityQueue holds the requests of a tenant over max dispatched in a sub-queue per priority class and dispatches
+// them to the queue with a weighted round robin over the sub-queues, so a class with waiting requests is never
+// starved by the other.
+type priorityQueue struct {
+	maxDispatched int
+	// schedule is the round robin of the classes, every class as often as its weight
+	schedule []int
+
+	mtx     sync.Mutex
+	tenants map[string]*priorityTenant
+}
+
+type priorityTenant struct {
+	dispatched int
+	next       int
+	waiting    [][]*priorityWaiter
+}
+
+type priorityWaiter struct {
+	ready      chan struct{}
+	dispatched bool
+}
+
+func newPriorityQueue(cfg PriorityConfig) *priorityQueue {
+	var schedule []int
+	for i, weight := range []int{cfg.InteractiveWeight, cfg.BatchWeight} {
+		if weight < 1 {
+			weight = 1
+		}
+		for j := 0; j < weight; j++ {
+			schedule = append(schedule, i)
+		}
+	}
+
+	return &priorityQueue{
+		maxDispatched: cfg.MaxDispatchedPerTenant,
+		schedule:      schedule,
+		tenants:       map[string]*priorityTenant{},
+	}
+}
+
+// wrap dispatches the requests to next by their priority class.
+func (q *priorityQueue) wrap(next http.RoundTripper) http.RoundTripper {
+	return queryrange.RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+		tenantID, err := user.ExtractOrgID(req.Context())
+		if q.maxDispatched <= 0 || err != nil {
+			return next.RoundTrip(req)
+		}
+
+		if err := q.wait(req.Context(), tenantID, queryPriority(req.Context())); err != nil {
+			return nil, err
+		}
+		defer q.done(tenantID)
+		return next.RoundTrip(req)
+	})
+}
+
+// wait returns once the request may be sent to the queue, or with the error of ctx if it's done first.
+func (q *priorityQueue) wait(ctx context.Context, tenantID, priority string) error {
+	class := 0
+	if priority == PriorityBatch {
+		class = 1
+	}
+
+	q.mtx.Lock()
+	t, ok := q.tenants[tenantID]
+	if !ok {
+		t = &priorityTenant{waiting: make([][]*priorityWaiter, len(priorities))}
+		q.tenants[tenantID] = t
+	}
+	if t.dispatched < q.maxDispatched {
+		t.dispatched++
+		q.mtx.Unlock()
+		return nil
+	}
+	w := &priorityWaiter{ready: make(chan struct{})}
+	t.waiting[class] = append(t.waiting[class], w)
+	q.mtx.Unlock()
+
+	span, ctx := opentracing.StartSpanFromContext(ctx, "frontend.PriorityQueue")
+	defer span.Finish()
+	span.SetTag("priority", priority)
+
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+	}
+
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+	if w.dispatched {
+		// dispatched while the request was cancelled, the slot goes to the next one
+		q.dispatchNext(tenantID, t)
+		return ctx.Err()
+	}
+	for i, waiting := range t.waiting[class] {
+		if waiting == w {
+			t.waiting[class] = append(t.waiting[class][:i], t.waiting[class][i+1:]...)
+			break
+		}
+	}
+	return ctx.Err()
+}
+
+// done releases the slot of a dispatched request of the tenant.
+func (q *priorityQueue) done(tenantID string) {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+	q.dispatchNext(tenantID, q.tenants[tenantID])
+}
+
+// dispatchNext hands the slot of a request that returned to the next waiting request of the round robin, or frees it
+// if none is waiting. It must be called with the lock held.
+func (q *priorityQueue) dispatchNext(tenantID string, t *priorityTenant) {
+	for range q.schedule {
+		class := q.schedule[t.next]
+		t.next = (t.next + 1) % len(q.schedule)
+		if len(t.waiting[class]) == 0 {
+			continue
+		}
+
+		w := t.waiting[class][0]
+		t.waiting[class] = t.waiting[class][1:]
+		w.dispatched = true
+		close(w.ready)
+		return
+	}
+
+	t.dispatched--
+	if t.dispatched == 0 {
+		delete(q.tenants, tenantID)
+	}
+}
@@ -0,0 +1,169 @@
+package frontend
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/querier/queryrange"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/tempo/modules/overrides"
+	"github.com/grafana/tempo/pkg/util/test"
+)
+
+func TestQueryPriority(t *testing.T) {
+	tests := []struct {
+		header   string
+		expected string
+	}{
+		{header: "", expected: PriorityInteractive},
+		{header: "interactive", expected: PriorityInteractive},
+		{header: "batch", expected: PriorityBatch},
+		{header: " Batch ", expected: PriorityBatch},
+		{header: "urgent", expected: PriorityInteractive},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			limits, err := overrides.NewOverrides(overrides.Limits{})
+			require.NoError(t, err)
+			l := newTenantLimits(limits, 0, prometheus.NewRegistry())
+
+			var priority string
+			var queueLength float64
+			rt := withQueryPriority(l.countOutstanding(queryrange.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+				priority = queryPriority(r.Context())
+				queueLength, err = test.GetGaugeValue(l.queueLength.WithLabelValues("a", tt.expected))
+				require.NoError(t, err)
+				return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewReader(nil))}, nil
+			})))
+
+			req := httptest.NewRequest(http.MethodGet, apiPathSearch, nil).WithContext(user.InjectOrgID(context.Background(), "a"))
+			if tt.header != "" {
+				req.Header.Set(PriorityHeader, tt.header)
+			}
+			_, err = rt.RoundTrip(req)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.expected, priority)
+			// the request is counted in the queue length of its priority class
+			assert.Equal(t, float64(1), queueLength)
+		})
+	}
+}
+
+func TestPriorityQueueWeights(t *testing.T) {
+	q := newPriorityQueue(PriorityConfig{MaxDispatchedPerTenant: 1, InteractiveWeight: 4, BatchWeight: 1})
+
+	release := make(chan struct{})
+	var mtx sync.Mutex
+	var dispatched []string
+	rt := withQueryPriority(q.wrap(queryrange.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if r.URL.Query().Get("blocking") != "" {
+			<-release
+			return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewReader(nil))}, nil
+		}
+		mtx.Lock()
+		dispatched = append(dispatched, queryPriority(r.Context())[:1])
+		mtx.Unlock()
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewReader(nil))}, nil
+	})))
+	request := func(priority, query string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, apiPathSearch+"?"+query, nil).WithContext(user.InjectOrgID(context.Background(), "a"))
+		req.Header.Set(PriorityHeader, priority)
+		return req
+	}
+	waiting := func(class int) int {
+		q.mtx.Lock()
+		defer q.mtx.Unlock()
+		return len(q.tenants["a"].waiting[class])
+	}
+
+	var wg sync.WaitGroup
+	roundTrip := func(req *http.Request) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := rt.RoundTrip(req)
+			assert.NoError(t, err)
+		}()
+	}
+
+	// the only slot of the tenant is taken, the batch queries wait before the interactive ones
+	roundTrip(request(PriorityBatch, "blocking=true"))
+	require.Eventually(t, func() bool { return tenants(q) == 1 }, time.Second, time.Millisecond)
+	for i := 0; i < 10; i++ {
+		roundTrip(request(PriorityBatch, ""))
+	}
+	require.Eventually(t, func() bool { return waiting(1) == 10 }, time.Second, time.Millisecond)
+	for i := 0; i < 10; i++ {
+		roundTrip(request(PriorityInteractive, ""))
+	}
+	require.Eventually(t, func() bool { return waiting(0) == 10 }, time.Second, time.Millisecond)
+
+	close(release)
+	wg.Wait()
+
+	// 4 interactive requests for every batch request until the interactive ones ran out
+	assert.Equal(t, "iiiibiiiibiibbbbbbbb", strings.Join(dispatched, ""))
+	assert.Equal(t, 0, tenants(q))
+}
+
+func TestPriorityQueueCancel(t *testing.T) {
+	q := newPriorityQueue(PriorityConfig{MaxDispatchedPerTenant: 1, InteractiveWeight: 4, BatchWeight: 1})
+
+	release := make(chan struct{})
+	rt := q.wrap(queryrange.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+		<-release
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewReader(nil))}, nil
+	}))
+
+	ctx := user.InjectOrgID(context.Background(), "a")
+	done := make(chan struct{})
+	go func() {
+		_, err := rt.RoundTrip(httptest.NewRequest(http.MethodGet, apiPathSearch, nil).WithContext(ctx))
+		assert.NoError(t, err)
+		close(done)
+	}()
+	require.Eventually(t, func() bool { return tenants(q) == 1 }, time.Second, time.Millisecond)
+
+	// a request that times out while waiting leaves the sub-queue
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	_, err := rt.RoundTrip(httptest.NewRequest(http.MethodGet, apiPathSearch, nil).WithContext(timeoutCtx))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	q.mtx.Lock()
+	assert.Empty(t, q.tenants["a"].waiting[0])
+	q.mtx.Unlock()
+
+	close(release)
+	<-done
+	assert.Equal(t, 0, tenants(q))
+}
+
+func TestPriorityQueueDisabled(t *testing.T) {
+	q := newPriorityQueue(PriorityConfig{InteractiveWeight: 4, BatchWeight: 1})
+
+	rt := q.wrap(queryrange.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewReader(nil))}, nil
+	}))
+	_, err := rt.RoundTrip(httptest.NewRequest(http.MethodGet, apiPathSearch, nil).WithContext(user.InjectOrgID(context.Background(), "a")))
+	require.NoError(t, err)
+	assert.Equal(t, 0, tenants(q))
+}
+
+func tenants(q *priorityQueue) int {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+	return len(q.tenants)
+}
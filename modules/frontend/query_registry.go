@@ -0,0 +1,192 @@
+package frontend
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/weaveworks/common/user"
+	"go.uber.org/atomic"
+)
+
+const (
+	// QueryIDHeader holds the id of a query to the frontend. It's set on every response and can be set on a request to
+	// choose the id, so the query can be cancelled before its response headers are returned.
+	QueryIDHeader = "X-Tempo-Query-ID"
+	// QueryIDVar is the var of the id of the query to cancel in the path of the cancel endpoint.
+	QueryIDVar = "queryID"
+	// cancelForwardedHeader marks the cancellations forwarded by another frontend, which aren't forwarded again.
+	cancelForwardedHeader = "X-Tempo-Cancel-Forwarded"
+
+	maxQueryIDLength = 128
+
+	cancelForwardTimeout = 10 * time.Second
+)
+
+type queryIDKey struct{}
+
+// queryID returns the id of the query of ctx, empty if it has none.
+func queryID(ctx context.Context) string {
+	id, _ := ctx.Value(queryIDKey{}).(string)
+	return id
+}
+
+// QueryRegistry tracks the in-flight queries to the frontend by tenant and id so they can be cancelled through the
+// api. Cancelling a query cancels its context, which aborts all of its shards. The queries are only known to the
+// frontend that runs them, cancellations of queries unknown to it are forwarded to the peers.
+type QueryRegistry struct {
+	mtx     sync.Mutex
+	queries map[inflightQueryKey]context.CancelFunc
+
+	// peers are the base URLs of the other frontends
+	peers  []string
+	client *http.Client
+
+	cancelled *prometheus.CounterVec
+}
+
+type inflightQueryKey struct {
+	tenant string
+	id     string
+}
+
+// NewQueryRegistry returns a registry that forwards the cancellations of queries it doesn't know to the frontends at
+// the base URLs of peers.
+func NewQueryRegistry(peers []string, registerer prometheus.Registerer) *QueryRegistry {
+	return &QueryRegistry{
+		queries: map[inflightQueryKey]context.CancelFunc{},
+		peers:   peers,
+		client:  &http.Client{Timeout: cancelForwardTimeout},
+		cancelled: promauto.With(registerer).NewCounterVec(prometheus.CounterOpts{
+			Namespace: "tempo",
+			Name:      "query_frontend_queries_cancelled_total",
+			Help:      "The total number of in-flight queries cancelled through the api per tenant.",
+		}, []string{"tenant"}),
+	}
+}
+
+// Wrap registers the queries to next for as long as they run. A query gets the id of its QueryIDHeader or a new one,
+// which is returned in the QueryIDHeader of the response. A query with the id of an in-flight query of the same tenant
+// is rejected.
+func (r *QueryRegistry) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		tenant, err := user.ExtractOrgID(req.Context())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		id := req.Header.Get(QueryIDHeader)
+		if len(id) > maxQueryIDLength {
+			http.Error(w, "query id is too long", http.StatusBadRequest)
+			return
+		}
+		if id == "" {
+			id = uuid.New().String()
+		}
+
+		ctx, cancel := context.WithCancel(context.WithValue(req.Context(), queryIDKey{}, id))
+		defer cancel()
+		key := inflightQueryKey{tenant: tenant, id: id}
+		if !r.register(key, cancel) {
+			http.Error(w, "a query with the id "+id+" is already in flight", http.StatusConflict)
+			return
+		}
+		defer r.unregister(key)
+
+		w.Header().Set(QueryIDHeader, id)
+		next.ServeHTTP(w, req.WithContext(ctx))
+	})
+}
+
+// CancelHandler cancels the in-flight query of the tenant with the id of the path. A query that isn't in flight in
+// this frontend is cancelled in the peer that runs it. It returns 404 if the tenant has no in-flight query with the id
+// in any of them.
+func (r *QueryRegistry) CancelHandler(w http.ResponseWriter, req *http.Request) {
+	tenant, err := user.ExtractOrgID(req.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	key := inflightQueryKey{tenant: tenant, id: mux.Vars(req)[QueryIDVar]}
+	r.mtx.Lock()
+	cancel, ok := r.queries[key]
+	r.mtx.Unlock()
+	if !ok {
+		if req.Header.Get(cancelForwardedHeader) != "" || !r.cancelInPeers(req, tenant) {
+			http.Error(w, "no in-flight query with the id "+key.id, http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	cancel()
+	r.cancelled.WithLabelValues(tenant).Inc()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// cancelInPeers forwards the cancellation to all peers at once and returns whether one of them cancelled the query.
+func (r *QueryRegistry) cancelInPeers(req *http.Request, tenant string) bool {
+	var cancelled atomic.Bool
+	var wg sync.WaitGroup
+	for _, peer := range r.peers {
+		wg.Add(1)
+		go func(peer string) {
+			defer wg.Done()
+
+			forward, err := http.NewRequestWithContext(req.Context(), http.MethodDelete, strings.TrimSuffix(peer, "/")+req.URL.Path, nil)
+			if err != nil {
+				return
+			}
+			forward.Header.Set(user.OrgIDHeaderName, tenant)
+			forward.Header.Set(cancelForwardedHeader, "true")
+
+			resp, err := r.client.Do(forward)
+			if err != nil {
+				return
+			}
+			_, _ = io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusNoContent {
+				cancelled.Store(true)
+			}
+		}(peer)
+	}
+	wg.Wait()
+
+	return cancelled.Load()
+}
+
+func (r *QueryRegistry) register(key inflightQueryKey, cancel context.CancelFunc) bool {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if _, ok := r.queries[key]; ok {
+		return false
+	}
+	r.queries[key] = cancel
+	return true
+}
+
+func (r *QueryRegistry) unregister(key inflightQueryKey) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	delete(r.queries, key)
+}
+
+// inflight returns the number of in-flight queries.
+func (r *QueryRegistry) inflight() int {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return len(r.queries)
+}
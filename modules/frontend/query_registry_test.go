@@ -0,0 +1,146 @@
+package frontend
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/tempo/pkg/util/test"
+)
+
+func TestQueryRegistryID(t *testing.T) {
+	r := NewQueryRegistry(nil, prometheus.NewRegistry())
+
+	var id string
+	handler := r.Wrap(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		id = queryID(req.Context())
+		assert.Equal(t, 1, r.inflight())
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, tenantRequest(http.MethodGet, "/api/search", "a"))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotEmpty(t, id)
+	assert.Equal(t, id, w.Header().Get(QueryIDHeader))
+	// completed queries leave the registry
+	assert.Equal(t, 0, r.inflight())
+
+	// the id of the request is used
+	req := tenantRequest(http.MethodGet, "/api/search", "a")
+	req.Header.Set(QueryIDHeader, "my-query")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "my-query", id)
+	assert.Equal(t, "my-query", w.Header().Get(QueryIDHeader))
+
+	req.Header.Set(QueryIDHeader, strings.Repeat("a", maxQueryIDLength+1))
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestQueryRegistryCancel(t *testing.T) {
+	r := NewQueryRegistry(nil, prometheus.NewRegistry())
+
+	started := make(chan struct{})
+	done := make(chan error)
+	handler := r.Wrap(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		close(started)
+		select {
+		case <-req.Context().Done():
+			done <- req.Context().Err()
+		case <-time.After(5 * time.Second):
+			done <- nil
+		}
+	}))
+	router := mux.NewRouter()
+	router.Path("/api/queries/{" + QueryIDVar + "}").Methods(http.MethodDelete).HandlerFunc(r.CancelHandler)
+	cancel := func(tenant, id string) int {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, tenantRequest(http.MethodDelete, "/api/queries/"+id, tenant))
+		return w.Code
+	}
+
+	go func() {
+		req := tenantRequest(http.MethodGet, "/api/search", "a")
+		req.Header.Set(QueryIDHeader, "q1")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}()
+	<-started
+
+	// a query with the id of an in-flight query of the tenant is rejected
+	req := tenantRequest(http.MethodGet, "/api/search", "a")
+	req.Header.Set(QueryIDHeader, "q1")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	// only the tenant of the query can cancel it
+	assert.Equal(t, http.StatusNotFound, cancel("b", "q1"))
+	assert.Equal(t, http.StatusNotFound, cancel("a", "q2"))
+
+	assert.Equal(t, http.StatusNoContent, cancel("a", "q1"))
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "timed out waiting for cancellation")
+	}
+	require.Eventually(t, func() bool { return r.inflight() == 0 }, time.Second, time.Millisecond)
+	assert.Equal(t, http.StatusNotFound, cancel("a", "q1"))
+
+	cancelled, err := test.GetCounterValue(r.cancelled.WithLabelValues("a"))
+	require.NoError(t, err)
+	assert.Equal(t, float64(1), cancelled)
+}
+
+func TestQueryRegistryCancelPeers(t *testing.T) {
+	// the query runs in the peer
+	peer := NewQueryRegistry(nil, prometheus.NewRegistry())
+	peer.register(inflightQueryKey{tenant: "a", id: "q1"}, func() {})
+	peerRouter := mux.NewRouter()
+	peerRouter.Path("/api/queries/{" + QueryIDVar + "}").Methods(http.MethodDelete).HandlerFunc(peer.CancelHandler)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_, ctx, err := user.ExtractOrgIDFromHTTPRequest(req)
+		require.NoError(t, err)
+		peerRouter.ServeHTTP(w, req.WithContext(ctx))
+	}))
+	defer server.Close()
+
+	r := NewQueryRegistry([]string{server.URL + "/"}, prometheus.NewRegistry())
+	router := mux.NewRouter()
+	router.Path("/api/queries/{" + QueryIDVar + "}").Methods(http.MethodDelete).HandlerFunc(r.CancelHandler)
+	cancel := func(tenant, id string, forwarded bool) int {
+		req := tenantRequest(http.MethodDelete, "/api/queries/"+id, tenant)
+		if forwarded {
+			req.Header.Set(cancelForwardedHeader, "true")
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusNotFound, cancel("b", "q1", false))
+	assert.Equal(t, http.StatusNotFound, cancel("a", "q2", false))
+	// forwarded cancellations aren't forwarded again
+	assert.Equal(t, http.StatusNotFound, cancel("a", "q1", true))
+	assert.Equal(t, http.StatusNoContent, cancel("a", "q1", false))
+
+	cancelled, err := test.GetCounterValue(peer.cancelled.WithLabelValues("a"))
+	require.NoError(t, err)
+	assert.Equal(t, float64(1), cancelled)
+}
+
+func tenantRequest(method, target, tenant string) *http.Request {
+	req := httptest.NewRequest(method, target, nil)
+	return req.WithContext(user.InjectOrgID(req.Context(), tenant))
+}
@@ -5,44 +5,57 @@ import (
 	"context"
 	"encoding/binary"
 	"encoding/hex"
+	"encoding/json"
 	"io"
 	"io/ioutil"
 	"net/http"
-	"strings"
+	"strconv"
+	"sync"
 
 	"github.com/go-kit/kit/log"
+	"github.com/gogo/protobuf/proto"
 	"github.com/opentracing/opentracing-go"
 	"github.com/pkg/errors"
 	"github.com/weaveworks/common/user"
 
 	"github.com/grafana/tempo/modules/querier"
 	"github.com/grafana/tempo/pkg/model"
+	"github.com/grafana/tempo/pkg/querystats"
+	"github.com/grafana/tempo/pkg/util"
 )
 
 const (
 	MinQueryShards = 2
 	MaxQueryShards = 256
 
-	querierPrefix  = "/querier"
-	queryDelimiter = "?"
+	querierPrefix = "/querier"
 )
 
-func ShardingWare(queryShards int, logger log.Logger) Middleware {
+// ShardingWare splits a trace by id query into one query of the ingesters and up to queryShards-1 queries of the
+// blocks, each searching a contiguous range of the block id space. If targetBlocksPerShard is set the blocks of a
+// tenant are split into as many shards as hold about targetBlocksPerShard blocks each, so tenants with few blocks
+// don't fan out to queryShards queries. The block count of a tenant is reported by the queriers with every query, until
+// the first one returns all block shards are used.
+func ShardingWare(queryShards int, targetBlocksPerShard int, logger log.Logger) Middleware {
 	return MiddlewareFunc(func(next Handler) Handler {
 		return shardQuery{
-			next:            next,
-			queryShards:     queryShards,
-			logger:          logger,
-			blockBoundaries: createBlockBoundaries(queryShards - 1), // one shard will be used to query ingesters
+			next:                 next,
+			queryShards:          queryShards,
+			targetBlocksPerShard: targetBlocksPerShard,
+			logger:               logger,
+			blockCounts:          &tenantBlockCounts{counts: map[string]int{}},
+			blockBoundaries:      createBlockBoundaries(queryShards - 1), // one shard will be used to query ingesters
 		}
 	})
 }
 
 type shardQuery struct {
-	next            Handler
-	queryShards     int
-	logger          log.Logger
-	blockBoundaries [][]byte
+	next                 Handler
+	queryShards          int
+	targetBlocksPerShard int
+	logger               log.Logger
+	blockCounts          *tenantBlockCounts
+	blockBoundaries      [][]byte
 }
 
 // Do implements Handler
@@ -59,35 +72,113 @@ func (s shardQuery) Do(r *http.Request) (*http.Response, error) {
 		return nil, err
 	}
 
-	reqs := make([]*http.Request, s.queryShards)
-	for i := 0; i < s.queryShards; i++ {
+	blockShards := s.blockShards(userID)
+	blockBoundaries := s.blockBoundaries
+	if blockShards != len(blockBoundaries)-1 {
+		blockBoundaries = createBlockBoundaries(blockShards)
+	}
+	span.SetTag("blockShards", blockShards)
+	setQueryShards(ctx, blockShards+1)
+
+	// the shards are sent concurrently and can't share the body of the request
+	var body []byte
+	if r.Body != nil {
+		body, err = io.ReadAll(r.Body)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	reqs := make([]*http.Request, blockShards+1)
+	for i := range reqs {
 		reqs[i] = r.Clone(r.Context())
+		reqs[i].Body = ioutil.NopCloser(bytes.NewReader(body))
 
 		q := reqs[i].URL.Query()
-		if i == (s.queryShards - 1) { // one shard dedicated to querying ingesters
+		if i == blockShards { // one shard dedicated to querying ingesters
 			q.Add(querier.QueryModeKey, querier.QueryModeIngesters)
 		} else {
-			q.Add(querier.BlockStartKey, hex.EncodeToString(s.blockBoundaries[i]))
-			q.Add(querier.BlockEndKey, hex.EncodeToString(s.blockBoundaries[i+1]))
+			q.Add(querier.BlockStartKey, hex.EncodeToString(blockBoundaries[i]))
+			q.Add(querier.BlockEndKey, hex.EncodeToString(blockBoundaries[i+1]))
 			q.Add(querier.QueryModeKey, querier.QueryModeBlocks)
+			markQueryShard(reqs[i])
 		}
 
 		reqs[i].Header.Set(user.OrgIDHeaderName, userID)
 
+		// the parameters of the request, like the time range hints, are passed on with the ones of the shard
+		reqs[i].URL.RawQuery = q.Encode()
+
 		// adding to RequestURI only because weaveworks/common uses the RequestURI field to
 		// translate from http.Request to httpgrpc.Request
 		// https://github.com/weaveworks/common/blob/47e357f4e1badb7da17ad74bae63e228bdd76e8f/httpgrpc/server/server.go#L48
-		reqs[i].RequestURI = querierPrefix + reqs[i].URL.RequestURI() + queryDelimiter + q.Encode()
+		reqs[i].RequestURI = querierPrefix + reqs[i].URL.RequestURI()
 	}
 
 	rrs, err := doRequests(reqs, s.next)
 	if err != nil {
 		return nil, err
 	}
+	s.observeBlockCount(userID, rrs)
 
 	return mergeResponses(ctx, rrs)
 }
 
+// blockShards returns the number of shards the blocks of the tenant are split into.
+func (s shardQuery) blockShards(tenantID string) int {
+	maxShards := s.queryShards - 1
+	if s.targetBlocksPerShard <= 0 {
+		return maxShards
+	}
+	blocks, ok := s.blockCounts.get(tenantID)
+	if !ok {
+		return maxShards
+	}
+
+	// a tenant without blocks still gets a shard for the blocks flushed since the last poll of the queriers
+	shards := (blocks + s.targetBlocksPerShard - 1) / s.targetBlocksPerShard
+	if shards < 1 {
+		return 1
+	}
+	if shards > maxShards {
+		return maxShards
+	}
+	return shards
+}
+
+// observeBlockCount records the largest block count reported by the block shards. The queriers poll the blocklist
+// independently and may be a poll apart.
+func (s shardQuery) observeBlockCount(tenantID string, rrs []RequestResponse) {
+	blocks := -1
+	for _, rr := range rrs {
+		if b, err := strconv.Atoi(rr.Response.Header.Get(querier.BlockCountHeader)); err == nil && b > blocks {
+			blocks = b
+		}
+	}
+	if blocks >= 0 {
+		s.blockCounts.set(tenantID, blocks)
+	}
+}
+
+// tenantBlockCounts holds the last block count reported by the queriers per tenant.
+type tenantBlockCounts struct {
+	mtx    sync.Mutex
+	counts map[string]int
+}
+
+func (c *tenantBlockCounts) get(tenantID string) (int, bool) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	blocks, ok := c.counts[tenantID]
+	return blocks, ok
+}
+
+func (c *tenantBlockCounts) set(tenantID string, blocks int) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.counts[tenantID] = blocks
+}
+
 // createBlockBoundaries splits the range of blockIDs into queryShards parts
 func createBlockBoundaries(queryShards int) [][]byte {
 	if queryShards == 0 {
@@ -156,8 +247,36 @@ func mergeResponses(ctx context.Context, rrs []RequestResponse) (*http.Response,
 	var errBody io.ReadCloser
 	var combinedTrace []byte
 	var shardMissCount = 0
+	var truncated = false
+	var partial = false
+	var warnings []string
+	var ingestionTime uint64
+	var maxBytes int
+	var stats querystats.Stats
+	// the structured bodies of the shards that didn't find the trace, nil once a shard returned a plain text one
+	notFound := &querier.TraceNotFoundResponse{Status: querier.TraceNotFoundStatus}
 	for _, rr := range rrs {
+		// a shard that didn't find the trace may not have searched all blocks either
+		partial = partial || rr.Response.Header.Get(querier.TracePartialHeader) == "true"
+		warnings = append(warnings, rr.Response.Header.Values(querier.WarningHeader)...)
+
+		// stats are diagnostics, a shard with invalid stats doesn't fail the query
+		if h := rr.Response.Header.Get(querystats.Header); h != "" {
+			if shardStats, err := querystats.Decode(h); err == nil {
+				stats.Merge(shardStats)
+			}
+		}
+
 		if rr.Response.StatusCode == http.StatusOK {
+			truncated = truncated || rr.Response.Header.Get(querier.TraceTruncatedHeader) == "true"
+			// the shards found parts of the same trace, the earliest part was ingested first
+			if t, err := strconv.ParseUint(rr.Response.Header.Get(querier.IngestionTimeHeader), 10, 64); err == nil {
+				ingestionTime = model.EarliestIngestionTime(ingestionTime, t)
+			}
+			if m, err := strconv.Atoi(rr.Response.Header.Get(querier.MaxTraceBytesHeader)); err == nil && m > 0 && (maxBytes == 0 || m < maxBytes) {
+				maxBytes = m
+			}
+
 			body, err := io.ReadAll(rr.Response.Body)
 			rr.Response.Body.Close()
 			if err != nil {
@@ -178,25 +297,84 @@ func mergeResponses(ctx context.Context, rrs []RequestResponse) (*http.Response,
 			errBody = rr.Response.Body
 		} else {
 			shardMissCount++
+			notFound = mergeTraceNotFound(notFound, rr.Response)
 		}
 	}
 
+	statsHeader, err := stats.Encode()
+	if err != nil {
+		return nil, errors.Wrap(err, "error encoding query stats at query frontend")
+	}
+
 	if shardMissCount == len(rrs) {
+		header := http.Header{}
+		header.Set(querystats.Header, statsHeader)
+		if partial {
+			header.Set(querier.TracePartialHeader, "true")
+		}
+		for _, warning := range warnings {
+			header.Add(querier.WarningHeader, warning)
+		}
+
+		body := []byte("trace not found in Tempo")
+		if notFound != nil {
+			body, err = json.Marshal(notFound)
+			if err != nil {
+				return nil, errors.Wrap(err, "error encoding not found response at query frontend")
+			}
+			header.Set("Content-Type", util.JSONTypeHeaderValue)
+		}
 		return &http.Response{
-			StatusCode: http.StatusNotFound,
-			Body:       ioutil.NopCloser(strings.NewReader("trace not found in Tempo")),
-			Header:     http.Header{},
+			StatusCode:    http.StatusNotFound,
+			Body:          ioutil.NopCloser(bytes.NewReader(body)),
+			ContentLength: int64(len(body)),
+			Header:        header,
 		}, nil
 	}
 
+	if errCode == http.StatusOK && maxBytes > 0 && len(combinedTrace) > maxBytes {
+		// every shard stayed below the limit of the queriers, the combined trace may not
+		combinedTrace, err = truncateTrace(combinedTrace, maxBytes)
+		if err != nil {
+			return nil, errors.Wrap(err, "error truncating trace at query frontend")
+		}
+		truncated = true
+	}
+
 	if errCode == http.StatusOK {
+		header := http.Header{}
+		header.Set(querystats.Header, statsHeader)
+		if truncated {
+			// the trace is too large and some shards only returned its earliest spans
+			header.Set(querier.TraceTruncatedHeader, "true")
+		}
+		if partial {
+			// some shards stopped at the max blocks per trace query or an external endpoint failed
+			header.Set(querier.TracePartialHeader, "true")
+		}
+		if ingestionTime != 0 {
+			header.Set(querier.IngestionTimeHeader, strconv.FormatUint(ingestionTime, 10))
+		}
+		for _, warning := range warnings {
+			header.Add(querier.WarningHeader, warning)
+		}
+
 		return &http.Response{
 			StatusCode: http.StatusOK,
 			Body:       ioutil.NopCloser(bytes.NewReader(combinedTrace)),
 			// ContentLength header is added to log the size of response in the Tripperware in frontend.go
 			// This could be overwritten if the query client and Tempo negotiate compression
 			ContentLength: int64(len(combinedTrace)),
-			Header:        http.Header{},
+			Header:        header,
+		}, nil
+	}
+
+	// the per tenant query limits of the queriers are returned as is, retrying the query right away doesn't help
+	if errCode == http.StatusTooManyRequests || errCode == http.StatusUnprocessableEntity {
+		return &http.Response{
+			StatusCode: errCode,
+			Body:       errBody,
+			Header:     http.Header{},
 		}, nil
 	}
 
@@ -207,3 +385,35 @@ func mergeResponses(ctx context.Context, rrs []RequestResponse) (*http.Response,
 		Header:     http.Header{},
 	}, nil
 }
+
+// truncateTrace drops the latest spans of the encoded trace until it is at most maxBytes, like the queriers do.
+func truncateTrace(obj []byte, maxBytes int) ([]byte, error) {
+	trace, err := model.Unmarshal(obj, model.TracePBEncoding)
+	if err != nil {
+		return nil, err
+	}
+
+	trace, _ = model.TruncateTrace(trace, maxBytes)
+	return proto.Marshal(trace)
+}
+
+// mergeTraceNotFound adds the checked ingesters and blocks of the structured 404 body of a shard to the merged one. It
+// returns nil if the shard or a previous one returned a plain text body, i.e. the queriers don't have
+// structured_not_found enabled.
+func mergeTraceNotFound(merged *querier.TraceNotFoundResponse, resp *http.Response) *querier.TraceNotFoundResponse {
+	defer resp.Body.Close()
+
+	if merged == nil || resp.Header.Get("Content-Type") != util.JSONTypeHeaderValue {
+		return nil
+	}
+
+	shard := &querier.TraceNotFoundResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(shard); err != nil || shard.Status != querier.TraceNotFoundStatus {
+		return nil
+	}
+
+	merged.TraceID = shard.TraceID
+	merged.CheckedIngesters += shard.CheckedIngesters
+	merged.CheckedBlocks += shard.CheckedBlocks
+	return merged
+}
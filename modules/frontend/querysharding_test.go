@@ -3,15 +3,31 @@ package frontend
 import (
 	"bytes"
 	"context"
+	"encoding/hex"
+	"encoding/json"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 
+	"github.com/go-kit/kit/log"
 	"github.com/golang/protobuf/proto"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
 
+	"github.com/grafana/tempo/modules/querier"
 	"github.com/grafana/tempo/pkg/model"
+	"github.com/grafana/tempo/pkg/querystats"
+	"github.com/grafana/tempo/pkg/tempopb"
+	v1 "github.com/grafana/tempo/pkg/tempopb/trace/v1"
+	"github.com/grafana/tempo/pkg/util"
 	"github.com/grafana/tempo/pkg/util/test"
 )
 
@@ -65,6 +81,18 @@ func TestMergeResponses(t *testing.T) {
 	combinedTrace, _, err := model.CombineTraceBytes(b1, b2, model.TracePBEncoding, model.TracePBEncoding)
 	assert.NoError(t, err)
 
+	// the shards stayed below the max trace bytes of the queriers but the combined trace does not
+	maxBytes := len(b1)
+	if len(b2) > maxBytes {
+		maxBytes = len(b2)
+	}
+	combined, err := model.Unmarshal(combinedTrace, model.TracePBEncoding)
+	assert.NoError(t, err)
+	truncated, _ := model.TruncateTrace(combined, maxBytes)
+	truncatedTrace, err := proto.Marshal(truncated)
+	assert.NoError(t, err)
+	assert.Less(t, len(truncatedTrace), len(combinedTrace))
+
 	tests := []struct {
 		name            string
 		requestResponse []RequestResponse
@@ -98,6 +126,139 @@ func TestMergeResponses(t *testing.T) {
 				ContentLength: int64(len(combinedTrace)),
 			},
 		},
+		{
+			name: "flag truncated traces",
+			requestResponse: []RequestResponse{
+				{
+					Response: &http.Response{
+						StatusCode: http.StatusOK,
+						Body:       ioutil.NopCloser(bytes.NewReader(b1)),
+						Header:     http.Header{querier.TraceTruncatedHeader: []string{"true"}},
+					},
+				},
+				{
+					Response: &http.Response{
+						StatusCode: http.StatusOK,
+						Body:       ioutil.NopCloser(bytes.NewReader(b2)),
+					},
+				},
+			},
+			expected: &http.Response{
+				StatusCode:    http.StatusOK,
+				Body:          ioutil.NopCloser(bytes.NewReader(combinedTrace)),
+				ContentLength: int64(len(combinedTrace)),
+				Header:        http.Header{querier.TraceTruncatedHeader: []string{"true"}},
+			},
+		},
+		{
+			name: "truncate combined traces",
+			requestResponse: []RequestResponse{
+				{
+					Response: &http.Response{
+						StatusCode: http.StatusOK,
+						Body:       ioutil.NopCloser(bytes.NewReader(b1)),
+						Header:     http.Header{querier.MaxTraceBytesHeader: []string{strconv.Itoa(maxBytes)}},
+					},
+				},
+				{
+					Response: &http.Response{
+						StatusCode: http.StatusOK,
+						Body:       ioutil.NopCloser(bytes.NewReader(b2)),
+						Header:     http.Header{querier.MaxTraceBytesHeader: []string{strconv.Itoa(maxBytes)}},
+					},
+				},
+			},
+			expected: &http.Response{
+				StatusCode:    http.StatusOK,
+				Body:          ioutil.NopCloser(bytes.NewReader(truncatedTrace)),
+				ContentLength: int64(len(truncatedTrace)),
+				Header:        http.Header{querier.TraceTruncatedHeader: []string{"true"}},
+			},
+		},
+		{
+			name: "flag partial traces",
+			requestResponse: []RequestResponse{
+				{
+					Response: &http.Response{
+						StatusCode: http.StatusOK,
+						Body:       ioutil.NopCloser(bytes.NewReader(b1)),
+					},
+				},
+				{
+					Response: &http.Response{
+						StatusCode: http.StatusNotFound,
+						Body:       ioutil.NopCloser(bytes.NewReader([]byte("foo"))),
+						Header:     http.Header{querier.TracePartialHeader: []string{"true"}},
+					},
+				},
+			},
+			expected: &http.Response{
+				StatusCode:    http.StatusOK,
+				Body:          ioutil.NopCloser(bytes.NewReader(b1)),
+				ContentLength: int64(len(b1)),
+				Header:        http.Header{querier.TracePartialHeader: []string{"true"}},
+			},
+		},
+		{
+			name: "keep earliest ingestion time",
+			requestResponse: []RequestResponse{
+				{
+					Response: &http.Response{
+						StatusCode: http.StatusOK,
+						Body:       ioutil.NopCloser(bytes.NewReader(b1)),
+						Header:     http.Header{querier.IngestionTimeHeader: []string{"20"}},
+					},
+				},
+				{
+					Response: &http.Response{
+						StatusCode: http.StatusOK,
+						Body:       ioutil.NopCloser(bytes.NewReader(b2)),
+						Header:     http.Header{querier.IngestionTimeHeader: []string{"10"}},
+					},
+				},
+				{
+					Response: &http.Response{
+						StatusCode: http.StatusOK,
+						Body:       ioutil.NopCloser(bytes.NewReader(b2)),
+					},
+				},
+			},
+			expected: &http.Response{
+				StatusCode:    http.StatusOK,
+				Body:          ioutil.NopCloser(bytes.NewReader(combinedTrace)),
+				ContentLength: int64(len(combinedTrace)),
+				Header:        http.Header{querier.IngestionTimeHeader: []string{"10"}},
+			},
+		},
+		{
+			name: "merge warnings",
+			requestResponse: []RequestResponse{
+				{
+					Response: &http.Response{
+						StatusCode: http.StatusNotFound,
+						Body:       ioutil.NopCloser(bytes.NewReader([]byte("foo"))),
+						Header: http.Header{
+							querier.TracePartialHeader: []string{"true"},
+							querier.WarningHeader:      []string{"external endpoint a failed"},
+						},
+					},
+				},
+				{
+					Response: &http.Response{
+						StatusCode: http.StatusNotFound,
+						Body:       ioutil.NopCloser(bytes.NewReader([]byte("foo"))),
+					},
+				},
+			},
+			expected: &http.Response{
+				StatusCode: http.StatusNotFound,
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte("trace not found in Tempo"))),
+				Header: http.Header{
+					querier.TracePartialHeader: []string{"true"},
+					querier.WarningHeader:      []string{"external endpoint a failed"},
+				},
+			},
+		},
 		{
 			name: "report 5xx with hit",
 			requestResponse: []RequestResponse{
@@ -161,6 +322,27 @@ func TestMergeResponses(t *testing.T) {
 				Body:       ioutil.NopCloser(bytes.NewReader([]byte("foo"))),
 			},
 		},
+		{
+			name: "return query limits as is",
+			requestResponse: []RequestResponse{
+				{
+					Response: &http.Response{
+						StatusCode: http.StatusOK,
+						Body:       ioutil.NopCloser(bytes.NewReader(b1)),
+					},
+				},
+				{
+					Response: &http.Response{
+						StatusCode: http.StatusUnprocessableEntity,
+						Body:       ioutil.NopCloser(bytes.NewReader([]byte("too large"))),
+					},
+				},
+			},
+			expected: &http.Response{
+				StatusCode: http.StatusUnprocessableEntity,
+				Body:       ioutil.NopCloser(bytes.NewReader([]byte("too large"))),
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -175,7 +357,264 @@ func TestMergeResponses(t *testing.T) {
 			if tt.expected.ContentLength > 0 {
 				assert.Equal(t, tt.expected.ContentLength, merged.ContentLength)
 			}
+			assert.Equal(t, tt.expected.Header.Get(querier.TraceTruncatedHeader), merged.Header.Get(querier.TraceTruncatedHeader))
+			assert.Equal(t, tt.expected.Header.Get(querier.TracePartialHeader), merged.Header.Get(querier.TracePartialHeader))
+			assert.Equal(t, tt.expected.Header.Values(querier.WarningHeader), merged.Header.Values(querier.WarningHeader))
+			assert.Equal(t, tt.expected.Header.Get(querier.IngestionTimeHeader), merged.Header.Get(querier.IngestionTimeHeader))
 		})
 	}
 
 }
+
+func TestMergeResponsesStats(t *testing.T) {
+	b, err := proto.Marshal(test.MakeTrace(10, []byte{0x01, 0x02}))
+	require.NoError(t, err)
+
+	response := func(status int, body []byte, stats querystats.Stats) RequestResponse {
+		h, err := stats.Encode()
+		require.NoError(t, err)
+		return RequestResponse{
+			Response: &http.Response{
+				StatusCode: status,
+				Body:       ioutil.NopCloser(bytes.NewReader(body)),
+				Header:     http.Header{querystats.Header: []string{h}},
+			},
+		}
+	}
+
+	// the stats of all shards are merged, including the ones that didn't find the trace
+	merged, err := mergeResponses(context.Background(), []RequestResponse{
+		response(http.StatusOK, b, querystats.Stats{BlocksInspected: 2, BloomHits: 1, BloomMisses: 1, BytesRead: 100, StoreWallTimeMs: 5, TotalWallTimeMs: 6}),
+		response(http.StatusNotFound, nil, querystats.Stats{BlocksInspected: 1, BloomMisses: 1, BytesRead: 10, StoreWallTimeMs: 8, TotalWallTimeMs: 9}),
+		response(http.StatusOK, b, querystats.Stats{IngestersQueried: 3, IngestersWallTimeMs: 2, CombineWallTimeMs: 1, TotalWallTimeMs: 3}),
+	})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, merged.StatusCode)
+
+	stats, err := querystats.Decode(merged.Header.Get(querystats.Header))
+	require.NoError(t, err)
+	assert.Equal(t, querystats.Stats{
+		BlocksInspected:     3,
+		BloomHits:           1,
+		BloomMisses:         2,
+		BytesRead:           110,
+		IngestersQueried:    3,
+		IngestersWallTimeMs: 2,
+		StoreWallTimeMs:     8,
+		CombineWallTimeMs:   1,
+		TotalWallTimeMs:     9,
+	}, stats)
+
+	// a trace that isn't found still reports the stats
+	merged, err = mergeResponses(context.Background(), []RequestResponse{
+		response(http.StatusNotFound, nil, querystats.Stats{BlocksInspected: 1}),
+		response(http.StatusNotFound, nil, querystats.Stats{BlocksInspected: 2}),
+	})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNotFound, merged.StatusCode)
+
+	stats, err = querystats.Decode(merged.Header.Get(querystats.Header))
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), stats.BlocksInspected)
+}
+
+func TestMergeResponsesTraceNotFound(t *testing.T) {
+	notFound := func(body string, contentType string) RequestResponse {
+		return RequestResponse{
+			Response: &http.Response{
+				StatusCode: http.StatusNotFound,
+				Body:       ioutil.NopCloser(strings.NewReader(body)),
+				Header:     http.Header{"Content-Type": []string{contentType}},
+			},
+		}
+	}
+	structured := func(ingesters, blocks int64) RequestResponse {
+		b, err := json.Marshal(&querier.TraceNotFoundResponse{TraceID: "0102", Status: querier.TraceNotFoundStatus, CheckedIngesters: ingesters, CheckedBlocks: blocks})
+		require.NoError(t, err)
+		return notFound(string(b), util.JSONTypeHeaderValue)
+	}
+
+	// the checked ingesters and blocks of the shards are summed
+	merged, err := mergeResponses(context.Background(), []RequestResponse{
+		structured(0, 2),
+		structured(0, 3),
+		structured(3, 0),
+	})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNotFound, merged.StatusCode)
+	assert.Equal(t, util.JSONTypeHeaderValue, merged.Header.Get("Content-Type"))
+	actual := &querier.TraceNotFoundResponse{}
+	require.NoError(t, json.NewDecoder(merged.Body).Decode(actual))
+	assert.Equal(t, &querier.TraceNotFoundResponse{TraceID: "0102", Status: querier.TraceNotFoundStatus, CheckedIngesters: 3, CheckedBlocks: 5}, actual)
+
+	// plain text if any shard returned plain text
+	merged, err = mergeResponses(context.Background(), []RequestResponse{
+		structured(0, 2),
+		notFound("Unable to find 0102", "text/plain; charset=utf-8"),
+	})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNotFound, merged.StatusCode)
+	body, err := io.ReadAll(merged.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "trace not found in Tempo", string(body))
+
+	// a trace found by some shards is returned
+	b, err := proto.Marshal(test.MakeTrace(1, []byte{0x01, 0x02}))
+	require.NoError(t, err)
+	merged, err = mergeResponses(context.Background(), []RequestResponse{
+		structured(0, 2),
+		{Response: &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewReader(b))}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, merged.StatusCode)
+}
+
+func TestShardQueryPassesParams(t *testing.T) {
+	var mtx sync.Mutex
+	var uris []string
+	queryShards := map[string]bool{}
+	next := HandlerFunc(func(req *http.Request) (*http.Response, error) {
+		mtx.Lock()
+		uris = append(uris, req.RequestURI)
+		queryShards[req.URL.Query().Get(querier.QueryModeKey)] = req.Header.Get(querier.QueryShardHeader) != ""
+		mtx.Unlock()
+		return &http.Response{
+			StatusCode: http.StatusNotFound,
+			Body:       ioutil.NopCloser(strings.NewReader("")),
+			Header:     http.Header{},
+		}, nil
+	})
+
+	req := httptest.NewRequest("GET", "/api/traces/0102?start=1600000000&end=1600003600", nil)
+	req = req.WithContext(user.InjectOrgID(req.Context(), "tenant"))
+	_, err := ShardingWare(3, 0, log.NewNopLogger()).Wrap(next).Do(req)
+	require.NoError(t, err)
+
+	require.Len(t, uris, 3)
+	for _, uri := range uris {
+		u, err := url.ParseRequestURI(uri)
+		require.NoError(t, err)
+		assert.Equal(t, querierPrefix+"/api/traces/0102", u.Path)
+		assert.Equal(t, []string{"1600000000"}, u.Query()[querier.TraceStartKey])
+		assert.Equal(t, []string{"1600003600"}, u.Query()[querier.TraceEndKey])
+		assert.Len(t, u.Query()[querier.QueryModeKey], 1)
+	}
+
+	// the queriers count the query once, by the shard of the ingesters
+	assert.Equal(t, map[string]bool{querier.QueryModeIngesters: false, querier.QueryModeBlocks: true}, queryShards)
+}
+
+func TestShardQueryAdaptiveShards(t *testing.T) {
+	var mtx sync.Mutex
+	var reqs []*http.Request
+	blockCount := "250"
+	next := HandlerFunc(func(req *http.Request) (*http.Response, error) {
+		mtx.Lock()
+		reqs = append(reqs, req)
+		mtx.Unlock()
+		header := http.Header{}
+		if req.URL.Query().Get(querier.QueryModeKey) == querier.QueryModeBlocks {
+			header.Set(querier.BlockCountHeader, blockCount)
+		}
+		return &http.Response{
+			StatusCode: http.StatusNotFound,
+			Body:       ioutil.NopCloser(strings.NewReader("")),
+			Header:     header,
+		}, nil
+	})
+	sharder := ShardingWare(21, 100, log.NewNopLogger()).Wrap(next)
+
+	do := func() []*http.Request {
+		reqs = nil
+		req := httptest.NewRequest("GET", "/api/traces/0102", nil)
+		req = req.WithContext(user.InjectOrgID(req.Context(), "tenant"))
+		_, err := sharder.Do(req)
+		require.NoError(t, err)
+		return reqs
+	}
+	// the block shards cover the whole block id space and one shard queries the ingesters
+	assertShards := func(reqs []*http.Request, blockShards int) {
+		require.Len(t, reqs, blockShards+1)
+
+		var ingesters int
+		var ranges [][2]string
+		for _, req := range reqs {
+			q := req.URL.Query()
+			if q.Get(querier.QueryModeKey) == querier.QueryModeIngesters {
+				ingesters++
+				assert.Empty(t, q.Get(querier.BlockStartKey))
+				continue
+			}
+			ranges = append(ranges, [2]string{q.Get(querier.BlockStartKey), q.Get(querier.BlockEndKey)})
+		}
+		assert.Equal(t, 1, ingesters)
+
+		sort.Slice(ranges, func(i, j int) bool { return ranges[i][0] < ranges[j][0] })
+		assert.Equal(t, strings.Repeat("00", 16), ranges[0][0])
+		assert.Equal(t, strings.Repeat("ff", 16), ranges[len(ranges)-1][1])
+		for i := 1; i < len(ranges); i++ {
+			assert.Equal(t, ranges[i-1][1], ranges[i][0])
+		}
+	}
+
+	// all shards until the block count of the tenant is known
+	assertShards(do(), 20)
+	assertShards(do(), 3)
+
+	// a tenant without blocks still has a block shard
+	blockCount = "0"
+	do()
+	assertShards(do(), 1)
+
+	// and a large one is capped at the query shards
+	blockCount = "100000"
+	do()
+	assertShards(do(), 20)
+}
+
+func TestMergeResponsesOverlappingShards(t *testing.T) {
+	traceID := []byte{0x01, 0x02}
+	blocks := test.MakeTrace(3, traceID)
+	// the ingesters still hold a part of the trace that was flushed to the blocks and spans received since
+	ingesters := &tempopb.Trace{Batches: append([]*v1.ResourceSpans{blocks.Batches[0]}, test.MakeTrace(2, traceID).Batches...)}
+
+	response := func(trace *tempopb.Trace) RequestResponse {
+		b, err := proto.Marshal(trace)
+		require.NoError(t, err)
+		return RequestResponse{Response: &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewReader(b)), Header: http.Header{}}}
+	}
+
+	merged, err := mergeResponses(context.Background(), []RequestResponse{response(ingesters), response(blocks)})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, merged.StatusCode)
+
+	body, err := io.ReadAll(merged.Body)
+	require.NoError(t, err)
+	actual := &tempopb.Trace{}
+	require.NoError(t, proto.Unmarshal(body, actual))
+
+	// the overlapping spans are returned once
+	expected := map[string]struct{}{}
+	for _, trace := range []*tempopb.Trace{blocks, ingesters} {
+		for _, id := range spanIDs(trace) {
+			expected[id] = struct{}{}
+		}
+	}
+	actualIDs := spanIDs(actual)
+	assert.Len(t, actualIDs, len(expected))
+	for _, id := range actualIDs {
+		assert.Contains(t, expected, id)
+	}
+}
+
+func spanIDs(trace *tempopb.Trace) []string {
+	var ids []string
+	for _, b := range trace.Batches {
+		for _, ils := range b.InstrumentationLibrarySpans {
+			for _, s := range ils.Spans {
+				ids = append(ids, hex.EncodeToString(s.SpanId))
+			}
+		}
+	}
+	return ids
+}
@@ -1,35 +1,69 @@
 package frontend
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"time"
 
+	"github.com/grafana/dskit/backoff"
 	"github.com/opentracing/opentracing-go"
 	ot_log "github.com/opentracing/opentracing-go/log"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/weaveworks/common/httpgrpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
-func RetryWare(maxRetries int, registerer prometheus.Registerer) Middleware {
+const (
+	retryReason5xx       = "5xx"
+	retryReasonTransport = "transport"
+)
+
+// RetryWare retries requests that failed with a 5xx or a transport error up to maxRetries tries in total. The tries
+// are spaced by a jittered exponential backoff and no try is started that couldn't complete before the deadline of
+// the request.
+func RetryWare(maxRetries int, backoffCfg backoff.Config, registerer prometheus.Registerer) Middleware {
+	retriesCount := promauto.With(registerer).NewHistogram(prometheus.HistogramOpts{
+		Namespace: "tempo",
+		Name:      "query_frontend_retries",
+		Help:      "Number of times a request is retried.",
+		Buckets:   []float64{0, 1, 2, 3, 4, 5},
+	})
+	retriesTotal := promauto.With(registerer).NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "query_frontend_retries_total",
+		Help:      "The total number of retried requests by the reason of the retry.",
+	}, []string{"reason"})
+
 	return MiddlewareFunc(func(next Handler) Handler {
 		return retryWare{
-			next:       next,
-			maxRetries: maxRetries,
-			retriesCount: promauto.With(registerer).NewHistogram(prometheus.HistogramOpts{
-				Namespace: "tempo",
-				Name:      "query_frontend_retries",
-				Help:      "Number of times a request is retried.",
-				Buckets:   []float64{0, 1, 2, 3, 4, 5},
-			}),
+			next:         next,
+			maxRetries:   maxRetries,
+			backoff:      backoffCfg,
+			retriesCount: retriesCount,
+			retriesTotal: retriesTotal,
 		}
 	})
 }
 
+// retryScope is stored in the context of a request by a retryWare. A retryWare below it, like the one of a
+// downstream route, marks it as handled and the request is then only retried by the inner one, so the tries of the
+// two don't multiply.
+type retryScope struct {
+	handled bool
+}
+
+type retryScopeKey struct{}
+
 type retryWare struct {
 	next         Handler
 	maxRetries   int
-	retriesCount prometheus.Histogram
+	backoff      backoff.Config
+	retriesCount prometheus.Observer
+	retriesTotal *prometheus.CounterVec
 }
 
 // Do implements Handler
@@ -38,33 +72,34 @@ func (r retryWare) Do(req *http.Request) (*http.Response, error) {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "frontend.Retry")
 	defer span.Finish()
 
+	if outer, ok := ctx.Value(retryScopeKey{}).(*retryScope); ok {
+		outer.handled = true
+	}
+	scope := &retryScope{}
+	ctx = context.WithValue(ctx, retryScopeKey{}, scope)
+
 	// context propagation
 	req = req.WithContext(ctx)
 
-	tries := 0
-	defer func() { r.retriesCount.Observe(float64(tries)) }()
+	retries := 0
+	defer func() { r.retriesCount.Observe(float64(retries)) }()
 
-	for {
+	b := backoff.New(ctx, r.backoff)
+	for tries := 1; ; tries++ {
 		if ctx.Err() != nil {
 			return nil, ctx.Err()
 		}
 
 		resp, err := r.next.Do(req)
 
-		// do not retry if no error and response is not HTTP 5xx
-		if err == nil && !shouldRetry(resp.StatusCode) {
-			return resp, nil
-		}
-
-		// do not retry if GRPC error contains response that is not HTTP 5xx
-		httpResp, ok := httpgrpc.HTTPResponseFromError(err)
-		if ok && !shouldRetry(int(httpResp.Code)) {
+		reason := retryReason(resp, err)
+		if reason == "" || tries >= r.maxRetries || scope.handled {
 			return resp, err
 		}
 
-		// reached max retries
-		tries++
-		if tries >= r.maxRetries {
+		// don't wait for a try that can't complete in time
+		delay := b.NextDelay()
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(delay).After(deadline) {
 			return resp, err
 		}
 
@@ -72,7 +107,7 @@ func (r retryWare) Do(req *http.Request) (*http.Response, error) {
 		if resp != nil {
 			statusCode = resp.StatusCode
 		}
-		if httpResp != nil {
+		if httpResp, ok := httpgrpc.HTTPResponseFromError(err); ok {
 			statusCode = int(httpResp.Code)
 		}
 
@@ -85,10 +120,48 @@ func (r retryWare) Do(req *http.Request) (*http.Response, error) {
 			ot_log.Int("try", tries),
 			ot_log.Int("status_code", statusCode),
 			ot_log.String("errMsg", errMsg),
+			ot_log.String("reason", reason),
+			ot_log.String("backoff", delay.String()),
 		)
+
+		retries++
+		r.retriesTotal.WithLabelValues(reason).Inc()
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
 	}
 }
 
-func shouldRetry(statusCode int) bool {
-	return statusCode/100 == 5
+// retryReason returns why a failed request is retried or an empty string if it isn't. Only 5xx responses and
+// transport errors are retried. Client errors and limits, like 400, 404, 429 and resource exhausted, fail the same
+// way when retried and are returned right away, as are the errors of cancelled requests.
+func retryReason(resp *http.Response, err error) string {
+	if err == nil {
+		if resp.StatusCode/100 == 5 {
+			return retryReason5xx
+		}
+		return ""
+	}
+
+	if httpResp, ok := httpgrpc.HTTPResponseFromError(err); ok {
+		if httpResp.Code/100 == 5 {
+			return retryReason5xx
+		}
+		return ""
+	}
+
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return ""
+	}
+
+	switch status.Code(err) {
+	case codes.Canceled, codes.DeadlineExceeded, codes.InvalidArgument, codes.NotFound, codes.ResourceExhausted,
+		codes.FailedPrecondition, codes.PermissionDenied, codes.Unauthenticated:
+		return ""
+	}
+
+	return retryReasonTransport
 }
@@ -6,11 +6,16 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/grafana/dskit/backoff"
 	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/require"
 	"github.com/weaveworks/common/httpgrpc"
 	"go.uber.org/atomic"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type HandlerFunc func(req *http.Request) (*http.Response, error)
@@ -22,6 +27,7 @@ func (q HandlerFunc) Do(req *http.Request) (*http.Response, error) {
 
 func TestRetry(t *testing.T) {
 	var try atomic.Int32
+	errResourceExhausted := status.Error(codes.ResourceExhausted, "too many outstanding requests")
 
 	for _, tc := range []struct {
 		name          string
@@ -66,6 +72,52 @@ func TestRetry(t *testing.T) {
 			expectedRes:   nil,
 			expectedErr:   httpgrpc.ErrorFromHTTPResponse(&httpgrpc.HTTPResponse{Code: 400}),
 		},
+		{
+			name: "don't retry 404's and 429's",
+			handler: HandlerFunc(func(req *http.Request) (*http.Response, error) {
+				if try.Inc() == 1 {
+					return &http.Response{StatusCode: 404}, nil
+				}
+				return &http.Response{StatusCode: 429}, nil
+			}),
+			maxRetries:    5,
+			expectedTries: 1,
+			expectedRes:   &http.Response{StatusCode: 404},
+			expectedErr:   nil,
+		},
+		{
+			name: "don't retry GRPC request with HTTP 429's",
+			handler: HandlerFunc(func(req *http.Request) (*http.Response, error) {
+				try.Inc()
+				return nil, httpgrpc.ErrorFromHTTPResponse(&httpgrpc.HTTPResponse{Code: 429})
+			}),
+			maxRetries:    5,
+			expectedTries: 1,
+			expectedRes:   nil,
+			expectedErr:   httpgrpc.ErrorFromHTTPResponse(&httpgrpc.HTTPResponse{Code: 429}),
+		},
+		{
+			name: "don't retry resource exhausted",
+			handler: HandlerFunc(func(req *http.Request) (*http.Response, error) {
+				try.Inc()
+				return nil, errResourceExhausted
+			}),
+			maxRetries:    5,
+			expectedTries: 1,
+			expectedRes:   nil,
+			expectedErr:   errResourceExhausted,
+		},
+		{
+			name: "retry GRPC request with HTTP 500's",
+			handler: HandlerFunc(func(req *http.Request) (*http.Response, error) {
+				try.Inc()
+				return nil, httpgrpc.ErrorFromHTTPResponse(&httpgrpc.HTTPResponse{Code: 502})
+			}),
+			maxRetries:    3,
+			expectedTries: 3,
+			expectedRes:   nil,
+			expectedErr:   httpgrpc.ErrorFromHTTPResponse(&httpgrpc.HTTPResponse{Code: 502}),
+		},
 		{
 			name: "retry 500s",
 			handler: HandlerFunc(func(req *http.Request) (*http.Response, error) {
@@ -116,7 +168,7 @@ func TestRetry(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			try.Store(0)
 
-			retryWare := RetryWare(tc.maxRetries, prometheus.NewRegistry())
+			retryWare := RetryWare(tc.maxRetries, backoff.Config{}, prometheus.NewRegistry())
 			handler := retryWare.Wrap(tc.handler)
 
 			req := httptest.NewRequest("GET", "http://example.com", nil)
@@ -130,6 +182,79 @@ func TestRetry(t *testing.T) {
 	}
 }
 
+func TestRetryReasons(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	var try atomic.Int32
+	handler := RetryWare(4, backoff.Config{}, registry).Wrap(HandlerFunc(func(req *http.Request) (*http.Response, error) {
+		switch try.Inc() {
+		case 1:
+			return &http.Response{StatusCode: 503}, nil
+		case 2:
+			return nil, errors.New("connection reset")
+		case 3:
+			return nil, httpgrpc.ErrorFromHTTPResponse(&httpgrpc.HTTPResponse{Code: 500})
+		default:
+			return &http.Response{StatusCode: 200}, nil
+		}
+	}))
+
+	res, err := handler.Do(httptest.NewRequest("GET", "http://example.com", nil))
+	require.NoError(t, err)
+	require.Equal(t, 200, res.StatusCode)
+
+	metrics, err := registry.Gather()
+	require.NoError(t, err)
+	retries := map[string]float64{}
+	var perRequest *dto.Histogram
+	for _, m := range metrics {
+		switch m.GetName() {
+		case "tempo_query_frontend_retries_total":
+			for _, s := range m.GetMetric() {
+				retries[s.GetLabel()[0].GetValue()] = s.GetCounter().GetValue()
+			}
+		case "tempo_query_frontend_retries":
+			perRequest = m.GetMetric()[0].GetHistogram()
+		}
+	}
+	require.Equal(t, map[string]float64{retryReason5xx: 2, retryReasonTransport: 1}, retries)
+	require.NotNil(t, perRequest)
+	require.Equal(t, uint64(1), perRequest.GetSampleCount())
+	require.Equal(t, float64(3), perRequest.GetSampleSum())
+}
+
+func TestRetryBackoff(t *testing.T) {
+	var tries []time.Time
+	handler := RetryWare(3, backoff.Config{MinBackoff: 50 * time.Millisecond, MaxBackoff: 100 * time.Millisecond}, prometheus.NewRegistry()).
+		Wrap(HandlerFunc(func(req *http.Request) (*http.Response, error) {
+			tries = append(tries, time.Now())
+			return &http.Response{StatusCode: 500}, nil
+		}))
+
+	res, err := handler.Do(httptest.NewRequest("GET", "http://example.com", nil))
+	require.NoError(t, err)
+	require.Equal(t, 500, res.StatusCode)
+	require.Len(t, tries, 3)
+	require.GreaterOrEqual(t, int64(tries[1].Sub(tries[0])), int64(50*time.Millisecond))
+	require.GreaterOrEqual(t, int64(tries[2].Sub(tries[1])), int64(50*time.Millisecond))
+
+	// no try is started that couldn't complete before the deadline
+	tries = nil
+	ctx, cancel := context.WithTimeout(context.Background(), 75*time.Millisecond)
+	defer cancel()
+	handler = RetryWare(3, backoff.Config{MinBackoff: time.Second, MaxBackoff: time.Second}, prometheus.NewRegistry()).
+		Wrap(HandlerFunc(func(req *http.Request) (*http.Response, error) {
+			tries = append(tries, time.Now())
+			return &http.Response{StatusCode: 500}, nil
+		}))
+
+	start := time.Now()
+	res, err = handler.Do(httptest.NewRequest("GET", "http://example.com", nil).WithContext(ctx))
+	require.NoError(t, err)
+	require.Equal(t, 500, res.StatusCode)
+	require.Len(t, tries, 1)
+	require.Less(t, int64(time.Since(start)), int64(75*time.Millisecond))
+}
+
 func TestRetry_CancelledRequest(t *testing.T) {
 	var try atomic.Int32
 
@@ -140,7 +265,7 @@ func TestRetry_CancelledRequest(t *testing.T) {
 	req, err := http.NewRequestWithContext(ctx, "GET", "http://example.com", nil)
 	require.NoError(t, err)
 
-	_, err = RetryWare(5, prometheus.NewRegistry()).
+	_, err = RetryWare(5, backoff.Config{}, prometheus.NewRegistry()).
 		Wrap(HandlerFunc(func(req *http.Request) (*http.Response, error) {
 			try.Inc()
 			return nil, ctx.Err()
@@ -156,7 +281,7 @@ func TestRetry_CancelledRequest(t *testing.T) {
 	req, err = http.NewRequestWithContext(ctx, "GET", "http://example.com", nil)
 	require.NoError(t, err)
 
-	_, err = RetryWare(5, prometheus.NewRegistry()).
+	_, err = RetryWare(5, backoff.Config{}, prometheus.NewRegistry()).
 		Wrap(HandlerFunc(func(req *http.Request) (*http.Response, error) {
 			try.Inc()
 			cancel()
@@ -0,0 +1,160 @@
+package frontend
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/golang/protobuf/jsonpb"
+
+	"github.com/grafana/tempo/modules/querier"
+	"github.com/grafana/tempo/pkg/tempopb"
+	"github.com/grafana/tempo/pkg/util"
+)
+
+var ndjsonPadding = bytes.Repeat([]byte(" "), eventStreamPadding)
+
+// ndjsonLine is a line of a search streamed as newline delimited JSON, either a batch of traces or the metadata
+// written after the traces.
+type ndjsonLine struct {
+	Traces   []json.RawMessage     `json:"traces,omitempty"`
+	Metadata *searchStreamMetadata `json:"metadata,omitempty"`
+}
+
+// serveNDJSON streams the results of a search as newline delimited JSON. The queriers return every shard as a whole
+// through the queues, so the traces of every shard are written as soon as it returns, sorted by start time within the
+// shard, newest first, in lines of flushResults traces without the traces already sent by other shards. Traces are not
+// sorted across shards. Once all shards returned, or the limit of the search is reached and the outstanding shards are
+// cancelled, a line with the metadata of the search is written. The shards are cancelled as well when the client
+// disconnects.
+func (h *searchStreamingHandler) serveNDJSON(w http.ResponseWriter, r *http.Request, orgID string, limit int) {
+	// cancelled when the client disconnects or the stream is done, which cancels the outstanding shards
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	shards := h.shards
+	if r.URL.Query().Get(querier.SearchStartKey) != "" || r.URL.Query().Get(querier.SearchEndKey) != "" {
+		shards++
+	}
+	responses := h.searchShards(ctx, r, shards)
+
+	w.Header().Set("Content-Type", util.NDJSONTypeHeaderValue)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	flushResults := h.flushResults
+	if flushResults <= 0 {
+		flushResults = 1
+	}
+	marshaler := &jsonpb.Marshaler{}
+	var batch []json.RawMessage
+	writeBatch := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		err := writeLine(w, &ndjsonLine{Traces: batch})
+		batch = batch[:0]
+		return err
+	}
+
+	metrics := &tempopb.SearchMetrics{}
+	sent := map[string]struct{}{}
+	completed, failed := 0, 0
+	partial := false
+	for i := 0; i < shards && (limit <= 0 || len(sent) < limit); i++ {
+		var resp searchShardResponse
+		select {
+		case resp = <-responses:
+		case <-ctx.Done():
+			return
+		}
+
+		if resp.err != nil {
+			failed++
+			level.Warn(h.logger).Log("msg", "search shard failed", "tenant", orgID, "shard", resp.shard, "err", resp.err)
+			continue
+		}
+		completed++
+		partial = partial || resp.partial
+
+		if m := resp.response.Metrics; m != nil {
+			metrics.InspectedBytes += m.InspectedBytes
+			metrics.InspectedTraces += m.InspectedTraces
+			metrics.InspectedBlocks += m.InspectedBlocks
+			metrics.SkippedBlocks += m.SkippedBlocks
+		}
+
+		traces := resp.response.Traces
+		sort.SliceStable(traces, func(i, j int) bool {
+			return traces[i].StartTimeUnixNano > traces[j].StartTimeUnixNano
+		})
+		for _, t := range traces {
+			if limit > 0 && len(sent) >= limit {
+				break
+			}
+			if _, ok := sent[t.TraceID]; ok {
+				continue
+			}
+			sent[t.TraceID] = struct{}{}
+			s, err := marshaler.MarshalToString(t)
+			if err != nil {
+				level.Error(h.logger).Log("msg", "error marshalling search results", "tenant", orgID, "err", err)
+				return
+			}
+			batch = append(batch, json.RawMessage(s))
+			if len(batch) >= flushResults {
+				if err := writeBatch(); err != nil {
+					return
+				}
+			}
+		}
+		if err := writeBatch(); err != nil {
+			return
+		}
+	}
+	// the shards that are still running are cancelled, the search is complete once the limit is reached
+	cancel()
+
+	var data bytes.Buffer
+	err := marshaler.Marshal(&data, metrics)
+	if err != nil {
+		level.Error(h.logger).Log("msg", "error marshalling search metrics", "tenant", orgID, "err", err)
+		return
+	}
+	_ = writeLine(w, &ndjsonLine{Metadata: &searchStreamMetadata{
+		Metrics:         data.Bytes(),
+		CompletedShards: completed,
+		FailedShards:    failed,
+		Complete:        failed == 0,
+		Partial:         partial,
+	}})
+}
+
+// writeLine writes a line of newline delimited JSON and sends it to the client. If the response can't be flushed the
+// line is padded with whitespace that fills the write buffers of the server, an event stream comment would not be JSON.
+func writeLine(w http.ResponseWriter, line *ndjsonLine) error {
+	b, err := json.Marshal(line)
+	if err != nil {
+		return err
+	}
+
+	f, ok := w.(http.Flusher)
+	if !ok {
+		b = append(b, ndjsonPadding...)
+	}
+	if _, err = w.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	if ok {
+		f.Flush()
+	}
+	return nil
+}
+
+// acceptsNDJSON returns true if the search should be streamed as newline delimited JSON.
+func acceptsNDJSON(r *http.Request) bool {
+	return r.Header.Get(util.AcceptHeaderKey) == util.NDJSONTypeHeaderValue
+}
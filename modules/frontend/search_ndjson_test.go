@@ -0,0 +1,234 @@
+package frontend
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/querier/queryrange"
+	"github.com/go-kit/kit/log"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/tempo/modules/overrides"
+	"github.com/grafana/tempo/modules/querier"
+	"github.com/grafana/tempo/pkg/tempopb"
+	"github.com/grafana/tempo/pkg/util"
+)
+
+func TestSearchStreamingNDJSON(t *testing.T) {
+	w := &firstWriteRecorder{ResponseRecorder: httptest.NewRecorder(), written: make(chan struct{})}
+	rt := queryrange.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+		assert.Equal(t, util.JSONTypeHeaderValue, r.Header.Get(util.AcceptHeaderKey))
+
+		switch r.URL.Query().Get(querier.SearchShardKey) {
+		case "0":
+			return searchResponse(t, &tempopb.SearchResponse{
+				Traces:  searchTraces("a", 10, "b", 50, "c", 30),
+				Metrics: &tempopb.SearchMetrics{InspectedTraces: 3},
+			}), nil
+		case "1":
+			// returns once the traces of the first shard were written
+			<-w.written
+			return searchResponse(t, &tempopb.SearchResponse{
+				Traces:  searchTraces("d", 60, "b", 50, "e", 20),
+				Metrics: &tempopb.SearchMetrics{InspectedTraces: 2},
+			}), nil
+		default:
+			return &http.Response{StatusCode: http.StatusInternalServerError, Body: ioutil.NopCloser(strings.NewReader("failed"))}, nil
+		}
+	})
+
+	handler := NewSearchStreamingHandler(Config{SearchStreamShards: 3, SearchStreamFlushResults: 2}, http.NotFoundHandler(), rt, testTripperwares(t, Config{}, overrides.Limits{}), log.NewNopLogger())
+	req := httptest.NewRequest(http.MethodGet, "/api/search?service.name=svc", nil)
+	req.Header.Set(util.AcceptHeaderKey, util.NDJSONTypeHeaderValue)
+	handler.ServeHTTP(w, req.WithContext(user.InjectOrgID(req.Context(), "test")))
+
+	assert.Equal(t, util.NDJSONTypeHeaderValue, w.Header().Get("Content-Type"))
+	batches, metadata := readNDJSON(t, w.Body)
+	// the traces of every shard are sorted by start time, newest first, and written every 2 traces without the traces
+	// of the shards that returned before
+	assert.Equal(t, [][]string{{"b", "c"}, {"a"}, {"d", "e"}}, batches)
+	require.NotNil(t, metadata)
+	assert.Equal(t, 2, metadata.CompletedShards)
+	assert.Equal(t, 1, metadata.FailedShards)
+	assert.False(t, metadata.Complete)
+	metrics := &tempopb.SearchMetrics{}
+	require.NoError(t, jsonpb.UnmarshalString(string(metadata.Metrics), metrics))
+	assert.Equal(t, uint32(5), metrics.InspectedTraces)
+}
+
+func TestSearchStreamingNDJSONStreamed(t *testing.T) {
+	release := make(chan struct{})
+	rt := queryrange.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if r.URL.Query().Get(querier.SearchShardKey) == "0" {
+			return searchResponse(t, &tempopb.SearchResponse{Traces: searchTraces("a", 10)}), nil
+		}
+		select {
+		case <-release:
+		case <-r.Context().Done():
+			return nil, r.Context().Err()
+		}
+		return searchResponse(t, &tempopb.SearchResponse{Traces: searchTraces("b", 20)}), nil
+	})
+
+	handler := NewSearchStreamingHandler(Config{SearchStreamShards: 2, SearchStreamFlushResults: 10}, http.NotFoundHandler(), rt, testTripperwares(t, Config{}, overrides.Limits{}), log.NewNopLogger())
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler.ServeHTTP(w, r.WithContext(user.InjectOrgID(r.Context(), "test")))
+	}))
+	defer server.Close()
+
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/api/search", nil)
+	require.NoError(t, err)
+	req.Header.Set(util.AcceptHeaderKey, util.NDJSONTypeHeaderValue)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	// the traces of the first shard are read while the second one is still running
+	reader := bufio.NewReader(resp.Body)
+	line, err := reader.ReadBytes('\n')
+	require.NoError(t, err)
+	batches, metadata := readNDJSON(t, bytes.NewReader(line))
+	assert.Equal(t, [][]string{{"a"}}, batches)
+	assert.Nil(t, metadata)
+
+	close(release)
+	batches, metadata = readNDJSON(t, reader)
+	assert.Equal(t, [][]string{{"b"}}, batches)
+	require.NotNil(t, metadata)
+	assert.Equal(t, 2, metadata.CompletedShards)
+	assert.True(t, metadata.Complete)
+}
+
+func TestSearchStreamingNDJSONLimit(t *testing.T) {
+	cancelled := make(chan struct{})
+	rt := queryrange.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if r.URL.Query().Get(querier.SearchShardKey) == "0" {
+			return searchResponse(t, &tempopb.SearchResponse{Traces: searchTraces("c", 30, "a", 50, "b", 40)}), nil
+		}
+
+		// the second shard never returns
+		<-r.Context().Done()
+		close(cancelled)
+		return nil, r.Context().Err()
+	})
+
+	handler := NewSearchStreamingHandler(Config{SearchStreamShards: 2, SearchStreamFlushResults: 10}, http.NotFoundHandler(), rt, testTripperwares(t, Config{}, overrides.Limits{}), log.NewNopLogger())
+	req := httptest.NewRequest(http.MethodGet, "/api/search?limit=2", nil)
+	req.Header.Set(util.AcceptHeaderKey, util.NDJSONTypeHeaderValue)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req.WithContext(user.InjectOrgID(req.Context(), "test")))
+
+	// the limit applies to all shards, the outstanding shard is cancelled once it's reached
+	batches, metadata := readNDJSON(t, w.Body)
+	assert.Equal(t, [][]string{{"a", "b"}}, batches)
+	require.NotNil(t, metadata)
+	assert.True(t, metadata.Complete)
+	select {
+	case <-cancelled:
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "timed out waiting for cancellation")
+	}
+}
+
+func TestSearchStreamingNDJSONCancelled(t *testing.T) {
+	started := make(chan struct{}, 2)
+	cancelled := make(chan struct{}, 2)
+	rt := queryrange.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+		started <- struct{}{}
+		<-r.Context().Done()
+		cancelled <- struct{}{}
+		return nil, r.Context().Err()
+	})
+
+	handler := NewSearchStreamingHandler(Config{SearchStreamShards: 2}, http.NotFoundHandler(), rt, testTripperwares(t, Config{}, overrides.Limits{}), log.NewNopLogger())
+	ctx, cancel := context.WithCancel(user.InjectOrgID(context.Background(), "test"))
+	req := httptest.NewRequest(http.MethodGet, "/api/search", nil).WithContext(ctx)
+	req.Header.Set(util.AcceptHeaderKey, util.NDJSONTypeHeaderValue)
+
+	done := make(chan struct{})
+	go func() {
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		close(done)
+	}()
+
+	// the shards are cancelled when the client disconnects
+	<-started
+	<-started
+	cancel()
+	for i := 0; i < 2; i++ {
+		select {
+		case <-cancelled:
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "timed out waiting for cancellation")
+		}
+	}
+	<-done
+}
+
+// firstWriteRecorder closes written once the first line is written.
+type firstWriteRecorder struct {
+	*httptest.ResponseRecorder
+	written chan struct{}
+	once    sync.Once
+}
+
+func (r *firstWriteRecorder) Write(b []byte) (int, error) {
+	n, err := r.ResponseRecorder.Write(b)
+	r.once.Do(func() { close(r.written) })
+	return n, err
+}
+
+// searchTraces returns traces from pairs of trace ids and start times.
+func searchTraces(idsAndStarts ...interface{}) []*tempopb.TraceSearchMetadata {
+	var traces []*tempopb.TraceSearchMetadata
+	for i := 0; i < len(idsAndStarts); i += 2 {
+		traces = append(traces, &tempopb.TraceSearchMetadata{
+			TraceID:           idsAndStarts[i].(string),
+			StartTimeUnixNano: uint64(idsAndStarts[i+1].(int)),
+		})
+	}
+	return traces
+}
+
+func searchResponse(t *testing.T, resp *tempopb.SearchResponse) *http.Response {
+	var body bytes.Buffer
+	require.NoError(t, (&jsonpb.Marshaler{}).Marshal(&body, resp))
+	return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: ioutil.NopCloser(&body)}
+}
+
+// readNDJSON returns the trace ids of every batch of a search streamed as newline delimited JSON and its metadata.
+func readNDJSON(t *testing.T, r io.Reader) ([][]string, *searchStreamMetadata) {
+	var batches [][]string
+	var metadata *searchStreamMetadata
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := &ndjsonLine{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), line))
+		if line.Metadata != nil {
+			metadata = line.Metadata
+			continue
+		}
+		var ids []string
+		for _, raw := range line.Traces {
+			trace := &tempopb.TraceSearchMetadata{}
+			require.NoError(t, jsonpb.UnmarshalString(string(raw), trace))
+			ids = append(ids, trace.TraceID)
+		}
+		batches = append(batches, ids)
+	}
+	require.NoError(t, scanner.Err())
+	return batches, metadata
+}
@@ -0,0 +1,261 @@
+package frontend
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/opentracing/opentracing-go"
+	"github.com/pkg/errors"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/tempo/modules/querier"
+	"github.com/grafana/tempo/pkg/tempopb"
+	"github.com/grafana/tempo/pkg/util"
+)
+
+// SearchShardingWare splits a search of a time range longer than interval into one search of the ingesters and one
+// search of the backend blocks of every interval of the range, at most concurrency of them at once, 0 for all. The
+// interval is widened to split a range into at most maxShards searches of the blocks, 0 for no limit. The
+// results are deduped by trace id, sorted by start time and cut at the limit of the search like the ones of a querier.
+// A failed search of an interval doesn't fail the search, the results of the others are returned as partial with a
+// warning. Streamed searches and searches restricted to a query mode or a shard of the ingesters aren't split.
+func SearchShardingWare(interval time.Duration, maxShards, concurrency int, logger log.Logger) Middleware {
+	return MiddlewareFunc(func(next Handler) Handler {
+		return searchSharder{
+			next:        next,
+			interval:    interval,
+			maxShards:   maxShards,
+			concurrency: concurrency,
+			logger:      logger,
+		}
+	})
+}
+
+type searchSharder struct {
+	next        Handler
+	interval    time.Duration
+	maxShards   int
+	concurrency int
+	logger      log.Logger
+}
+
+type searchShardResult struct {
+	response *tempopb.SearchResponse
+	partial  bool
+	warnings []string
+	// the status and the body of a failed search, status is 0 if the request itself failed
+	status int
+	body   string
+	err    error
+}
+
+// Do implements Handler
+func (s searchSharder) Do(r *http.Request) (*http.Response, error) {
+	q := r.URL.Query()
+	// only searches of traces are split, not the lookups of tag names and values
+	if s.interval <= 0 || !strings.HasSuffix(r.URL.Path, apiPathSearch) || q.Get(querier.QueryModeKey) != "" || q.Get(querier.SearchShardsKey) != "" {
+		return s.next.Do(r)
+	}
+	// invalid ranges and limits are rejected by the queriers
+	start, err := strconv.ParseInt(q.Get(querier.SearchStartKey), 10, 64)
+	if err != nil {
+		return s.next.Do(r)
+	}
+	end, err := strconv.ParseInt(q.Get(querier.SearchEndKey), 10, 64)
+	if err != nil || time.Duration(end-start)*time.Second <= s.interval {
+		return s.next.Do(r)
+	}
+	limit := 0
+	if l := q.Get(urlParamLimit); l != "" {
+		if limit, err = strconv.Atoi(l); err != nil {
+			return s.next.Do(r)
+		}
+	}
+
+	span, ctx := opentracing.StartSpanFromContext(r.Context(), "frontend.SearchSharding")
+	defer span.Finish()
+	r = r.WithContext(ctx)
+
+	reqs := s.shardRequests(r, start, end)
+	span.SetTag("shards", len(reqs))
+	setQueryShards(ctx, len(reqs))
+
+	concurrency := s.concurrency
+	if concurrency <= 0 {
+		concurrency = len(reqs)
+	}
+	results := make([]searchShardResult, len(reqs))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, req := range reqs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, req *http.Request) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = s.search(req)
+		}(i, req)
+	}
+	wg.Wait()
+
+	orgID, _ := user.ExtractOrgID(ctx)
+	return s.mergeResults(orgID, limit, reqs, results)
+}
+
+// shardRequests returns the search of the ingesters followed by the searches of the blocks of every interval of the
+// range. The intervals don't overlap, the last one holds the end of the range.
+func (s searchSharder) shardRequests(r *http.Request, start, end int64) []*http.Request {
+	interval := int64(s.interval / time.Second)
+	if interval < 1 {
+		interval = 1
+	}
+	// widen the interval so that the range is split into at most maxShards intervals
+	if max := int64(s.maxShards); max > 0 && (end-start+interval-1)/interval > max {
+		interval = (end - start + max - 1) / max
+	}
+
+	reqs := []*http.Request{shardRequest(r, querier.QueryModeIngesters, start, end)}
+	for shardStart := start; ; shardStart += interval {
+		if shardStart+interval >= end {
+			return append(reqs, shardRequest(r, querier.QueryModeBlocks, shardStart, end))
+		}
+		reqs = append(reqs, shardRequest(r, querier.QueryModeBlocks, shardStart, shardStart+interval-1))
+	}
+}
+
+func shardRequest(r *http.Request, mode string, start, end int64) *http.Request {
+	req := r.Clone(r.Context())
+
+	q := req.URL.Query()
+	q.Set(querier.QueryModeKey, mode)
+	q.Set(querier.SearchStartKey, strconv.FormatInt(start, 10))
+	q.Set(querier.SearchEndKey, strconv.FormatInt(end, 10))
+	req.URL.RawQuery = q.Encode()
+	req.Header.Set(util.AcceptHeaderKey, util.JSONTypeHeaderValue)
+	if mode == querier.QueryModeBlocks {
+		markQueryShard(req)
+	}
+
+	// weaveworks/common translates from http.Request to httpgrpc.Request by the RequestURI
+	req.RequestURI = querierPrefix + req.URL.RequestURI()
+	return req
+}
+
+func (s searchSharder) search(req *http.Request) searchShardResult {
+	resp, err := s.next.Do(req)
+	if err != nil {
+		return searchShardResult{err: err}
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return searchShardResult{err: errors.Wrap(err, "error reading search shard response")}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return searchShardResult{status: resp.StatusCode, body: strings.TrimSpace(string(body))}
+	}
+
+	searchResp := &tempopb.SearchResponse{}
+	if err := jsonpb.Unmarshal(bytes.NewReader(body), searchResp); err != nil {
+		return searchShardResult{err: errors.Wrap(err, "error unmarshalling search shard response")}
+	}
+	return searchShardResult{
+		response: searchResp,
+		partial:  resp.Header.Get(querier.SearchPartialHeader) == "true",
+		warnings: resp.Header.Values(querier.WarningHeader),
+	}
+}
+
+// mergeResults combines the results of the shards. The search only fails if all shards failed, with the failure of
+// the first one.
+func (s searchSharder) mergeResults(orgID string, limit int, reqs []*http.Request, results []searchShardResult) (*http.Response, error) {
+	response := &tempopb.SearchResponse{Metrics: &tempopb.SearchMetrics{}}
+	traces := map[string]*tempopb.TraceSearchMetadata{}
+	partial := false
+	var warnings []string
+	failed := 0
+	for i, result := range results {
+		if result.response == nil {
+			failed++
+			q := reqs[i].URL.Query()
+			msg := result.body
+			if result.err != nil {
+				msg = result.err.Error()
+			}
+			level.Warn(s.logger).Log("msg", "search shard failed", "tenant", orgID, "mode", q.Get(querier.QueryModeKey),
+				"start", q.Get(querier.SearchStartKey), "end", q.Get(querier.SearchEndKey), "status", result.status, "err", msg)
+			warnings = append(warnings, fmt.Sprintf("search of the %s from %s to %s failed: %s", q.Get(querier.QueryModeKey),
+				q.Get(querier.SearchStartKey), q.Get(querier.SearchEndKey), msg))
+			continue
+		}
+
+		partial = partial || result.partial
+		warnings = append(warnings, result.warnings...)
+		for _, t := range result.response.Traces {
+			if _, ok := traces[t.TraceID]; !ok {
+				traces[t.TraceID] = t
+			}
+		}
+		if m := result.response.Metrics; m != nil {
+			response.Metrics.InspectedBytes += m.InspectedBytes
+			response.Metrics.InspectedTraces += m.InspectedTraces
+			response.Metrics.InspectedBlocks += m.InspectedBlocks
+			response.Metrics.SkippedBlocks += m.SkippedBlocks
+		}
+	}
+
+	if failed == len(results) {
+		first := results[0]
+		if first.err != nil {
+			return nil, first.err
+		}
+		return &http.Response{
+			StatusCode:    first.status,
+			Body:          ioutil.NopCloser(strings.NewReader(first.body)),
+			Header:        http.Header{},
+			ContentLength: int64(len(first.body)),
+		}, nil
+	}
+
+	for _, t := range traces {
+		response.Traces = append(response.Traces, t)
+	}
+	sort.Slice(response.Traces, func(i, j int) bool {
+		return response.Traces[i].StartTimeUnixNano > response.Traces[j].StartTimeUnixNano
+	})
+	if limit > 0 && limit < len(response.Traces) {
+		response.Traces = response.Traces[:limit]
+	}
+
+	var body bytes.Buffer
+	if err := (&jsonpb.Marshaler{}).Marshal(&body, response); err != nil {
+		return nil, errors.Wrap(err, "error marshalling search response at query frontend")
+	}
+
+	header := http.Header{}
+	header.Set("Content-Type", util.JSONTypeHeaderValue)
+	if partial || failed > 0 {
+		header.Set(querier.SearchPartialHeader, "true")
+	}
+	for _, warning := range warnings {
+		header.Add(querier.WarningHeader, warning)
+	}
+	return &http.Response{
+		StatusCode:    http.StatusOK,
+		Body:          ioutil.NopCloser(&body),
+		Header:        header,
+		ContentLength: int64(body.Len()),
+	}, nil
+}
@@ -0,0 +1,146 @@
+package frontend
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/tempo/modules/querier"
+	"github.com/grafana/tempo/pkg/tempopb"
+)
+
+func TestSearchShardingWare(t *testing.T) {
+	searchResponse := func(traces ...*tempopb.TraceSearchMetadata) *http.Response {
+		var b bytes.Buffer
+		require.NoError(t, (&jsonpb.Marshaler{}).Marshal(&b, &tempopb.SearchResponse{
+			Traces:  traces,
+			Metrics: &tempopb.SearchMetrics{InspectedTraces: uint32(len(traces))},
+		}))
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(&b), Header: http.Header{}}
+	}
+
+	var mtx sync.Mutex
+	var queries []url.Values
+	// the ingesters and every hour of the blocks hold a trace, the trace "a" is found by two shards
+	next := HandlerFunc(func(r *http.Request) (*http.Response, error) {
+		mtx.Lock()
+		queries = append(queries, r.URL.Query())
+		mtx.Unlock()
+
+		q := r.URL.Query()
+		switch {
+		case q.Get(querier.QueryModeKey) == querier.QueryModeIngesters:
+			return searchResponse(&tempopb.TraceSearchMetadata{TraceID: "a", StartTimeUnixNano: 4}), nil
+		case q.Get(querier.SearchStartKey) == "0":
+			return searchResponse(&tempopb.TraceSearchMetadata{TraceID: "b", StartTimeUnixNano: 1}), nil
+		case q.Get(querier.SearchStartKey) == "3600":
+			return searchResponse(&tempopb.TraceSearchMetadata{TraceID: "c", StartTimeUnixNano: 2}), nil
+		case q.Get(querier.SearchStartKey) == "7200" && q.Get("fail") != "":
+			return &http.Response{StatusCode: http.StatusInternalServerError, Body: ioutil.NopCloser(strings.NewReader("boom")), Header: http.Header{}}, nil
+		default:
+			return searchResponse(&tempopb.TraceSearchMetadata{TraceID: "a", StartTimeUnixNano: 4}, &tempopb.TraceSearchMetadata{TraceID: "d", StartTimeUnixNano: 3}), nil
+		}
+	})
+	sharder := SearchShardingWare(time.Hour, 0, 2, log.NewNopLogger()).Wrap(next)
+
+	search := func(query string) (*http.Response, *tempopb.SearchResponse) {
+		mtx.Lock()
+		queries = nil
+		mtx.Unlock()
+
+		r := httptest.NewRequest(http.MethodGet, apiPathSearch+"?"+query, nil)
+		r = r.WithContext(user.InjectOrgID(context.Background(), "tenant"))
+		resp, err := sharder.Do(r)
+		require.NoError(t, err)
+		if resp.StatusCode != http.StatusOK {
+			return resp, nil
+		}
+		searchResp := &tempopb.SearchResponse{}
+		require.NoError(t, jsonpb.Unmarshal(resp.Body, searchResp))
+		return resp, searchResp
+	}
+	traceIDs := func(resp *tempopb.SearchResponse) []string {
+		var ids []string
+		for _, tr := range resp.Traces {
+			ids = append(ids, tr.TraceID)
+		}
+		return ids
+	}
+	ranges := func() []string {
+		mtx.Lock()
+		defer mtx.Unlock()
+		var r []string
+		for _, q := range queries {
+			r = append(r, q.Get(querier.QueryModeKey)+" "+q.Get(querier.SearchStartKey)+"-"+q.Get(querier.SearchEndKey))
+		}
+		sort.Strings(r)
+		return r
+	}
+
+	// a search of 3 hours is split into the ingesters and 3 intervals of the blocks. the results are deduped, sorted by
+	// start time and cut at the limit
+	resp, searchResp := search("start=0&end=10800&limit=3")
+	assert.Equal(t, []string{"blocks 0-3599", "blocks 3600-7199", "blocks 7200-10800", "ingesters 0-10800"}, ranges())
+	assert.Equal(t, []string{"a", "d", "c"}, traceIDs(searchResp))
+	assert.Equal(t, uint32(5), searchResp.Metrics.InspectedTraces)
+	assert.Empty(t, resp.Header.Get(querier.SearchPartialHeader))
+
+	// a failed interval returns the results of the others with a warning
+	resp, searchResp = search("start=0&end=10800&fail=true")
+	assert.Equal(t, []string{"a", "c", "b"}, traceIDs(searchResp))
+	assert.Equal(t, "true", resp.Header.Get(querier.SearchPartialHeader))
+	require.Len(t, resp.Header.Values(querier.WarningHeader), 1)
+	assert.Contains(t, resp.Header.Get(querier.WarningHeader), "search of the blocks from 7200 to 10800 failed: boom")
+
+	// short ranges, searches without a range and searches of a mode or a shard of the ingesters aren't split
+	for _, query := range []string{"start=0&end=3600", "limit=3", "start=0&end=10800&mode=blocks", "start=0&end=10800&ingesterShard=0&ingesterShards=2"} {
+		search(query)
+		assert.Len(t, ranges(), 1, query)
+	}
+}
+
+func TestSearchShardingWareFailed(t *testing.T) {
+	next := HandlerFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusBadRequest, Body: ioutil.NopCloser(strings.NewReader("invalid tag")), Header: http.Header{}}, nil
+	})
+	sharder := SearchShardingWare(time.Hour, 0, 0, log.NewNopLogger()).Wrap(next)
+
+	// the search fails if all intervals failed
+	r := httptest.NewRequest(http.MethodGet, apiPathSearch+"?start=0&end=10800", nil)
+	resp, err := sharder.Do(r.WithContext(user.InjectOrgID(context.Background(), "tenant")))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "invalid tag", string(body))
+}
+
+func TestSearchShardingWareMaxShards(t *testing.T) {
+	s := searchSharder{interval: time.Hour, maxShards: 4}
+	r := httptest.NewRequest(http.MethodGet, apiPathSearch+"?start=0&end=36000", nil)
+
+	var ranges []string
+	for _, req := range s.shardRequests(r, 0, 36000) {
+		q := req.URL.Query()
+		ranges = append(ranges, q.Get(querier.QueryModeKey)+" "+q.Get(querier.SearchStartKey)+"-"+q.Get(querier.SearchEndKey))
+	}
+	// 10 hours are split into 4 intervals of 2.5 hours instead of 10 of an hour
+	assert.Equal(t, []string{"ingesters 0-36000", "blocks 0-8999", "blocks 9000-17999", "blocks 18000-26999", "blocks 27000-36000"}, ranges)
+
+	// ranges within the limit keep the interval
+	assert.Len(t, s.shardRequests(r, 0, 10800), 4)
+}
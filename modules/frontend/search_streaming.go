@@ -0,0 +1,322 @@
+package frontend
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/cortexproject/cortex/pkg/querier/queryrange"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/pkg/errors"
+	httpgrpc_server "github.com/weaveworks/common/httpgrpc/server"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/tempo/modules/querier"
+	"github.com/grafana/tempo/pkg/tempopb"
+	"github.com/grafana/tempo/pkg/util"
+)
+
+const (
+	searchEventTraces   = "traces"
+	searchEventMetadata = "metadata"
+
+	urlParamLimit = "limit"
+
+	// eventStreamPadding is larger than the write buffers of the http server. It is written after every event if the
+	// response can't be flushed so the event is sent to the client.
+	eventStreamPadding = 8 * 1024
+)
+
+var eventStreamPaddingComment = ":" + strings.Repeat(" ", eventStreamPadding) + "\n\n"
+
+// searchStreamMetadata is the data of the last event of a streamed search.
+type searchStreamMetadata struct {
+	Metrics         json.RawMessage `json:"metrics"`
+	CompletedShards int             `json:"completedShards"`
+	FailedShards    int             `json:"failedShards"`
+	Complete        bool            `json:"complete"`
+	Partial         bool            `json:"partial"`
+}
+
+type searchShardResponse struct {
+	shard    int
+	response *tempopb.SearchResponse
+	partial  bool
+	err      error
+}
+
+// searchStreamingHandler streams the results of searches that accept text/event-stream, or application/x-ndjson, see
+// serveNDJSON. The search is split into shards of the ingesters that are sent to the queriers in parallel. The traces
+// of every shard are written as a traces event as soon as the shard returns, without the traces already sent by other
+// shards. Once all shards returned a metadata event with the combined metrics and whether every shard succeeded is
+// written. Traces are not sorted across events. Searches with a time range also search the backend blocks of the range
+// as an additional shard. Partial is set in the metadata if that shard stopped at the max_search_bytes_read of the
+// tenant. All other requests are served by next. Streamed searches are limited per tenant like the other queries: a
+// search of a tenant at its limits is rejected before anything is streamed and the shards are sent to the same queue.
+type searchStreamingHandler struct {
+	next   http.Handler
+	stream queryrange.Tripperware
+	search http.RoundTripper
+	shards int
+	// flushResults is the number of traces written in a line by searches streamed as newline delimited JSON
+	flushResults int
+	logger       log.Logger
+}
+
+// NewSearchStreamingHandler returns a handler that streams searches to the queriers through rt and serves all other
+// requests with next. The searches and their shards are wrapped by the search stream tripperwares. Searches are not
+// streamed if search_stream_shards is 0.
+func NewSearchStreamingHandler(cfg Config, next http.Handler, rt http.RoundTripper, tripperwares Tripperwares, logger log.Logger) http.Handler {
+	return &searchStreamingHandler{
+		next:         next,
+		stream:       tripperwares.SearchStreams,
+		search:       NewSearchTripperware(cfg, logger)(tripperwares.SearchStreamShards(rt)),
+		shards:       cfg.SearchStreamShards,
+		flushResults: cfg.SearchStreamFlushResults,
+		logger:       logger,
+	}
+}
+
+func (h *searchStreamingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// only the shards of the query-frontend skip the query rate limit of the queriers
+	r.Header.Del(querier.QueryShardHeader)
+
+	if h.shards <= 0 || (r.Header.Get(util.AcceptHeaderKey) != util.EventStreamTypeHeaderValue && !acceptsNDJSON(r)) {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+
+	orgID, err := user.ExtractOrgID(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	limit := 0
+	if s := r.URL.Query().Get(urlParamLimit); s != "" {
+		limit, err = strconv.Atoi(s)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// the stream is written to w as it runs, the response returned to the tripperware only tells it how it went
+	stream := queryrange.RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if acceptsNDJSON(req) {
+			h.serveNDJSON(w, req, orgID, limit)
+		} else {
+			h.serveEvents(w, req, orgID, limit)
+		}
+		return &http.Response{
+			StatusCode:    http.StatusOK,
+			Header:        http.Header{},
+			Body:          http.NoBody,
+			ContentLength: -1,
+		}, nil
+	})
+
+	_, err = h.stream(stream).RoundTrip(r)
+	if err != nil {
+		httpgrpc_server.WriteError(w, err)
+	}
+}
+
+// serveEvents streams the results of a search as server-sent events.
+func (h *searchStreamingHandler) serveEvents(w http.ResponseWriter, r *http.Request, orgID string, limit int) {
+	// cancelled when the client disconnects or the stream is done, which cancels the outstanding shards
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	shards := h.shards
+	if r.URL.Query().Get(querier.SearchStartKey) != "" || r.URL.Query().Get(querier.SearchEndKey) != "" {
+		shards++
+	}
+
+	responses := h.searchShards(ctx, r, shards)
+
+	w.Header().Set("Content-Type", util.EventStreamTypeHeaderValue)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	if err := flush(w); err != nil {
+		return
+	}
+
+	metrics := &tempopb.SearchMetrics{}
+	sent := map[string]struct{}{}
+	completed, failed := 0, 0
+	partial := false
+	for i := 0; i < shards; i++ {
+		var resp searchShardResponse
+		select {
+		case resp = <-responses:
+		case <-ctx.Done():
+			return
+		}
+
+		if resp.err != nil {
+			failed++
+			level.Warn(h.logger).Log("msg", "search shard failed", "tenant", orgID, "shard", resp.shard, "err", resp.err)
+			continue
+		}
+		completed++
+		partial = partial || resp.partial
+
+		if m := resp.response.Metrics; m != nil {
+			metrics.InspectedBytes += m.InspectedBytes
+			metrics.InspectedTraces += m.InspectedTraces
+			metrics.InspectedBlocks += m.InspectedBlocks
+			metrics.SkippedBlocks += m.SkippedBlocks
+		}
+
+		batch := &tempopb.SearchResponse{}
+		for _, t := range resp.response.Traces {
+			if limit > 0 && len(sent) >= limit {
+				break
+			}
+			if _, ok := sent[t.TraceID]; ok {
+				continue
+			}
+			sent[t.TraceID] = struct{}{}
+			batch.Traces = append(batch.Traces, t)
+		}
+		if len(batch.Traces) == 0 {
+			continue
+		}
+
+		var data bytes.Buffer
+		err := (&jsonpb.Marshaler{}).Marshal(&data, batch)
+		if err != nil {
+			level.Error(h.logger).Log("msg", "error marshalling search results", "tenant", orgID, "err", err)
+			return
+		}
+		if err = writeEvent(w, searchEventTraces, data.Bytes()); err != nil {
+			return
+		}
+	}
+
+	var data bytes.Buffer
+	err := (&jsonpb.Marshaler{}).Marshal(&data, metrics)
+	if err != nil {
+		level.Error(h.logger).Log("msg", "error marshalling search metrics", "tenant", orgID, "err", err)
+		return
+	}
+	b, err := json.Marshal(&searchStreamMetadata{
+		Metrics:         data.Bytes(),
+		CompletedShards: completed,
+		FailedShards:    failed,
+		Complete:        failed == 0,
+		Partial:         partial,
+	})
+	if err != nil {
+		level.Error(h.logger).Log("msg", "error marshalling search metadata", "tenant", orgID, "err", err)
+		return
+	}
+	_ = writeEvent(w, searchEventMetadata, b)
+}
+
+// searchShards sends the shards of the search to the queriers in parallel and returns the channel their responses are
+// sent to as they return. All shards but the last one, if the search has more shards than search_stream_shards, are
+// shards of the ingesters, the last one searches the backend blocks.
+func (h *searchStreamingHandler) searchShards(ctx context.Context, r *http.Request, shards int) <-chan searchShardResponse {
+	setQueryShards(ctx, shards)
+
+	responses := make(chan searchShardResponse, shards)
+	for i := 0; i < shards; i++ {
+		go func(shard int) {
+			var resp *tempopb.SearchResponse
+			var partial bool
+			var err error
+			if shard < h.shards {
+				resp, partial, err = h.searchShard(ctx, r, shard)
+			} else {
+				resp, partial, err = h.searchBlocks(ctx, r)
+			}
+			responses <- searchShardResponse{
+				shard:    shard,
+				response: resp,
+				partial:  partial,
+				err:      err,
+			}
+		}(i)
+	}
+	return responses
+}
+
+// searchShard sends the search restricted to the shard of the ingesters to a querier.
+func (h *searchStreamingHandler) searchShard(ctx context.Context, r *http.Request, shard int) (*tempopb.SearchResponse, bool, error) {
+	q := r.URL.Query()
+	q.Set(querier.SearchShardKey, strconv.Itoa(shard))
+	q.Set(querier.SearchShardsKey, strconv.Itoa(h.shards))
+	return h.roundTrip(ctx, r, q, shard != 0)
+}
+
+// searchBlocks sends the search restricted to the backend blocks of the time range to a querier.
+func (h *searchStreamingHandler) searchBlocks(ctx context.Context, r *http.Request) (*tempopb.SearchResponse, bool, error) {
+	q := r.URL.Query()
+	q.Set(querier.QueryModeKey, querier.QueryModeBlocks)
+	return h.roundTrip(ctx, r, q, true)
+}
+
+// roundTrip sends the search with the given query to a querier and returns whether the results are partial. All
+// shards but the first shard of the ingesters are marked as queryShard, so the queriers count the search once.
+func (h *searchStreamingHandler) roundTrip(ctx context.Context, r *http.Request, q url.Values, queryShard bool) (*tempopb.SearchResponse, bool, error) {
+	// the shards of the stream are counted by searchShards, not by the search tripperware of every shard
+	req := r.Clone(context.WithValue(ctx, queryShardsKey{}, &queryShards{}))
+	req.URL.RawQuery = q.Encode()
+	req.RequestURI = req.URL.RequestURI()
+	req.Header.Set(util.AcceptHeaderKey, util.JSONTypeHeaderValue)
+	if queryShard {
+		markQueryShard(req)
+	}
+
+	resp, err := h.search.RoundTrip(req)
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "error reading search shard response")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, false, fmt.Errorf("search shard returned %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	searchResp := &tempopb.SearchResponse{}
+	err = jsonpb.Unmarshal(bytes.NewReader(body), searchResp)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "error unmarshalling search shard response")
+	}
+	return searchResp, resp.Header.Get(querier.SearchPartialHeader) == "true", nil
+}
+
+// writeEvent writes a server-sent event and sends it to the client.
+func writeEvent(w http.ResponseWriter, event string, data []byte) error {
+	_, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+	if err != nil {
+		return err
+	}
+	return flush(w)
+}
+
+// flush sends everything written so far to the client. The default middlewares of the http server hide http.Flusher,
+// in which case a comment that fills the write buffers of the server is written.
+func flush(w http.ResponseWriter) error {
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+		return nil
+	}
+
+	_, err := io.WriteString(w, eventStreamPaddingComment)
+	return err
+}
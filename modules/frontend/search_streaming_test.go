@@ -0,0 +1,361 @@
+package frontend
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/querier/queryrange"
+	"github.com/go-kit/kit/log"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/tempo/modules/overrides"
+	"github.com/grafana/tempo/modules/querier"
+	"github.com/grafana/tempo/pkg/tempopb"
+	"github.com/grafana/tempo/pkg/util"
+)
+
+type searchEvent struct {
+	event string
+	data  string
+}
+
+func TestSearchStreaming(t *testing.T) {
+	// every shard waits for its release
+	release := map[string]chan struct{}{
+		"0": make(chan struct{}),
+		"1": make(chan struct{}),
+		"2": make(chan struct{}),
+	}
+	cancelled := make(chan string, 3)
+	traces := map[string][]string{
+		"0": {"a", "b"},
+		"1": {"b", "c"},
+	}
+
+	rt := queryrange.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+		assert.True(t, strings.HasPrefix(r.RequestURI, querierPrefix+"/api/search?"))
+		assert.Equal(t, "3", r.URL.Query().Get(querier.SearchShardsKey))
+		assert.Equal(t, "test", r.Header.Get(user.OrgIDHeaderName))
+
+		shard := r.URL.Query().Get(querier.SearchShardKey)
+		select {
+		case <-release[shard]:
+		case <-r.Context().Done():
+			cancelled <- shard
+			return nil, r.Context().Err()
+		}
+
+		if shard == "2" {
+			return &http.Response{
+				StatusCode: http.StatusInternalServerError,
+				Body:       ioutil.NopCloser(strings.NewReader("failed")),
+			}, nil
+		}
+
+		resp := &tempopb.SearchResponse{
+			Metrics: &tempopb.SearchMetrics{InspectedTraces: 2},
+		}
+		for _, id := range traces[shard] {
+			resp.Traces = append(resp.Traces, &tempopb.TraceSearchMetadata{TraceID: id})
+		}
+		var body bytes.Buffer
+		err := (&jsonpb.Marshaler{}).Marshal(&body, resp)
+		require.NoError(t, err)
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(&body),
+		}, nil
+	})
+
+	handler := NewSearchStreamingHandler(Config{SearchStreamShards: 3}, http.NotFoundHandler(), rt, testTripperwares(t, Config{}, overrides.Limits{}), log.NewNopLogger())
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler.ServeHTTP(w, r.WithContext(user.InjectOrgID(r.Context(), "test")))
+	}))
+	defer server.Close()
+
+	get := func() (*http.Response, <-chan searchEvent) {
+		req, err := http.NewRequest(http.MethodGet, server.URL+"/api/search?service.name=svc", nil)
+		require.NoError(t, err)
+		req.Header.Set(util.AcceptHeaderKey, util.EventStreamTypeHeaderValue)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, util.EventStreamTypeHeaderValue, resp.Header.Get("Content-Type"))
+
+		events := make(chan searchEvent)
+		go func() {
+			defer close(events)
+			scanner := bufio.NewScanner(resp.Body)
+			e := searchEvent{}
+			for scanner.Scan() {
+				line := scanner.Text()
+				switch {
+				case strings.HasPrefix(line, "event: "):
+					e.event = strings.TrimPrefix(line, "event: ")
+				case strings.HasPrefix(line, "data: "):
+					e.data = strings.TrimPrefix(line, "data: ")
+				case line == "" && e.event != "":
+					events <- e
+					e = searchEvent{}
+				}
+			}
+		}()
+		return resp, events
+	}
+
+	next := func(events <-chan searchEvent) searchEvent {
+		select {
+		case e := <-events:
+			return e
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "timed out waiting for event")
+			return searchEvent{}
+		}
+	}
+	traceIDs := func(e searchEvent) []string {
+		require.Equal(t, searchEventTraces, e.event)
+		resp := &tempopb.SearchResponse{}
+		require.NoError(t, jsonpb.UnmarshalString(e.data, resp))
+		var ids []string
+		for _, t := range resp.Traces {
+			ids = append(ids, t.TraceID)
+		}
+		return ids
+	}
+
+	resp, events := get()
+
+	// the results of a shard are delivered while the other shards are outstanding
+	close(release["1"])
+	assert.Equal(t, []string{"b", "c"}, traceIDs(next(events)))
+
+	// traces already sent are dropped
+	close(release["0"])
+	assert.Equal(t, []string{"a"}, traceIDs(next(events)))
+
+	close(release["2"])
+	e := next(events)
+	require.Equal(t, searchEventMetadata, e.event)
+	metadata := &searchStreamMetadata{}
+	require.NoError(t, json.Unmarshal([]byte(e.data), metadata))
+	assert.Equal(t, 2, metadata.CompletedShards)
+	assert.Equal(t, 1, metadata.FailedShards)
+	assert.False(t, metadata.Complete)
+	metrics := &tempopb.SearchMetrics{}
+	require.NoError(t, jsonpb.UnmarshalString(string(metadata.Metrics), metrics))
+	assert.Equal(t, uint32(4), metrics.InspectedTraces)
+
+	_, ok := <-events
+	assert.False(t, ok)
+	resp.Body.Close()
+
+	// a client that disconnects cancels the outstanding shards
+	for shard := range release {
+		release[shard] = make(chan struct{})
+	}
+	resp, events = get()
+	close(release["0"])
+	assert.Equal(t, []string{"a", "b"}, traceIDs(next(events)))
+	resp.Body.Close()
+
+	var shards []string
+	for i := 0; i < 2; i++ {
+		select {
+		case shard := <-cancelled:
+			shards = append(shards, shard)
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "timed out waiting for cancellation")
+		}
+	}
+	assert.ElementsMatch(t, []string{"1", "2"}, shards)
+}
+
+func TestSearchStreamingDisabled(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("next"))
+	})
+	rt := queryrange.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+		require.FailNow(t, "search should not be streamed")
+		return nil, nil
+	})
+
+	tests := []struct {
+		name   string
+		shards int
+		accept string
+	}{
+		{
+			name:   "not requested",
+			shards: 3,
+			accept: util.JSONTypeHeaderValue,
+		},
+		{
+			name:   "disabled",
+			shards: 0,
+			accept: util.EventStreamTypeHeaderValue,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := NewSearchStreamingHandler(Config{SearchStreamShards: tc.shards}, next, rt, testTripperwares(t, Config{}, overrides.Limits{}), log.NewNopLogger())
+
+			req := httptest.NewRequest(http.MethodGet, "/api/search", nil)
+			req.Header.Set(util.AcceptHeaderKey, tc.accept)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			assert.Equal(t, "next", w.Body.String())
+		})
+	}
+}
+
+func TestSearchStreamingBlocks(t *testing.T) {
+	rt := queryrange.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+		q := r.URL.Query()
+		assert.Equal(t, "10", q.Get(querier.SearchStartKey))
+		assert.Equal(t, "20", q.Get(querier.SearchEndKey))
+
+		resp := &tempopb.SearchResponse{}
+		header := http.Header{}
+		if q.Get(querier.QueryModeKey) == querier.QueryModeBlocks {
+			// the blocks are searched once, not per shard of the ingesters
+			assert.Empty(t, q.Get(querier.SearchShardsKey))
+			resp.Traces = []*tempopb.TraceSearchMetadata{{TraceID: "b"}}
+			header.Set(querier.SearchPartialHeader, "true")
+		} else {
+			assert.Equal(t, "1", q.Get(querier.SearchShardsKey))
+			resp.Traces = []*tempopb.TraceSearchMetadata{{TraceID: "a"}}
+		}
+
+		var body bytes.Buffer
+		err := (&jsonpb.Marshaler{}).Marshal(&body, resp)
+		require.NoError(t, err)
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     header,
+			Body:       ioutil.NopCloser(&body),
+		}, nil
+	})
+
+	handler := NewSearchStreamingHandler(Config{SearchStreamShards: 1}, http.NotFoundHandler(), rt, testTripperwares(t, Config{}, overrides.Limits{}), log.NewNopLogger())
+
+	req := httptest.NewRequest(http.MethodGet, "/api/search?service.name=svc&start=10&end=20", nil)
+	req.Header.Set(util.AcceptHeaderKey, util.EventStreamTypeHeaderValue)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req.WithContext(user.InjectOrgID(req.Context(), "test")))
+
+	var traceIDs []string
+	metadata := &searchStreamMetadata{}
+	for _, event := range strings.Split(strings.TrimSpace(w.Body.String()), "\n\n") {
+		lines := strings.Split(event, "\n")
+		require.Len(t, lines, 2)
+		data := strings.TrimPrefix(lines[1], "data: ")
+
+		switch strings.TrimPrefix(lines[0], "event: ") {
+		case searchEventTraces:
+			resp := &tempopb.SearchResponse{}
+			require.NoError(t, jsonpb.UnmarshalString(data, resp))
+			for _, t := range resp.Traces {
+				traceIDs = append(traceIDs, t.TraceID)
+			}
+		case searchEventMetadata:
+			require.NoError(t, json.Unmarshal([]byte(data), metadata))
+		}
+	}
+
+	assert.ElementsMatch(t, []string{"a", "b"}, traceIDs)
+	assert.Equal(t, 2, metadata.CompletedShards)
+	assert.True(t, metadata.Complete)
+	assert.True(t, metadata.Partial)
+}
+
+func TestSearchStreamingTenantLimits(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	rt := queryrange.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+		started <- struct{}{}
+		<-release
+
+		var body bytes.Buffer
+		err := (&jsonpb.Marshaler{}).Marshal(&body, &tempopb.SearchResponse{})
+		require.NoError(t, err)
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(&body),
+		}, nil
+	})
+
+	cfg := Config{SearchStreamShards: 1}
+	handler := NewSearchStreamingHandler(cfg, http.NotFoundHandler(), rt, testTripperwares(t, cfg, overrides.Limits{MaxOutstandingPerTenant: 1}), log.NewNopLogger())
+
+	search := func(accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/search?service.name=svc", nil)
+		req.Header.Set(util.AcceptHeaderKey, accept)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req.WithContext(user.InjectOrgID(req.Context(), "test")))
+		return w
+	}
+
+	// the shard of the first stream fills the queue of the tenant
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		done <- search(util.EventStreamTypeHeaderValue)
+	}()
+	<-started
+
+	for _, accept := range []string{util.EventStreamTypeHeaderValue, util.NDJSONTypeHeaderValue} {
+		w := search(accept)
+		assert.Equal(t, http.StatusTooManyRequests, w.Code, accept)
+		assert.NotEmpty(t, w.Header().Get("Retry-After"), accept)
+	}
+
+	close(release)
+	assert.Equal(t, http.StatusOK, (<-done).Code)
+
+	// the queue drained
+	go func() {
+		<-started
+	}()
+	assert.Equal(t, http.StatusOK, search(util.NDJSONTypeHeaderValue).Code)
+}
+
+func TestSearchStreamingFederated(t *testing.T) {
+	rt := queryrange.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+		require.FailNow(t, "federated search should not be streamed")
+		return nil, nil
+	})
+
+	cfg := Config{
+		SearchStreamShards: 1,
+		Federation:         FederationConfig{Enabled: true, OrgID: "__all__", Tenants: []string{"a", "b"}},
+	}
+	handler := NewSearchStreamingHandler(cfg, http.NotFoundHandler(), rt, testTripperwares(t, cfg, overrides.Limits{}), log.NewNopLogger())
+
+	req := httptest.NewRequest(http.MethodGet, "/api/search?service.name=svc", nil)
+	req.Header.Set(util.AcceptHeaderKey, util.EventStreamTypeHeaderValue)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req.WithContext(user.InjectOrgID(req.Context(), "__all__")))
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func testTripperwares(t *testing.T, cfg Config, limits overrides.Limits) Tripperwares {
+	o, err := overrides.NewOverrides(limits)
+	require.NoError(t, err)
+
+	tripperwares, err := NewTripperware(cfg, "", o, nil, log.NewNopLogger(), prometheus.NewRegistry())
+	require.NoError(t, err)
+	return tripperwares
+}
@@ -0,0 +1,123 @@
+package frontend
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/querier/queryrange"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/weaveworks/common/httpgrpc"
+	"github.com/weaveworks/common/tracing"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/tempo/modules/querier"
+	"github.com/grafana/tempo/pkg/querystats"
+)
+
+type queryShardsKey struct{}
+
+// queryShards is the number of shards a query was split into. It's set by the middlewares that send the query to the
+// queriers, a query answered by the frontend itself, i.e. from the trace cache, has no shards.
+type queryShards struct {
+	shards int
+}
+
+// setQueryShards records the number of shards of the query of ctx for the slow query log.
+func setQueryShards(ctx context.Context, shards int) {
+	if s, ok := ctx.Value(queryShardsKey{}).(*queryShards); ok {
+		s.shards = shards
+	}
+}
+
+// SlowQueryWare logs the queries slower than threshold with the trace id or the search parameters of the query, its
+// duration, status, number of shards, the bytes returned and the query stats of the queriers if the response has
+// them. 0 disables the log, a negative threshold logs all queries.
+func SlowQueryWare(apiPrefix string, threshold time.Duration, logger log.Logger) queryrange.Tripperware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		if threshold == 0 {
+			return next
+		}
+
+		return queryrange.RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+			start := time.Now()
+			shards := &queryShards{}
+			resp, err := next.RoundTrip(req.WithContext(context.WithValue(req.Context(), queryShardsKey{}, shards)))
+
+			if duration := time.Since(start); duration > threshold {
+				logSlowQuery(logger, apiPrefix, req, duration, shards.shards, resp, err)
+			}
+			return resp, err
+		})
+	}
+}
+
+func logSlowQuery(logger log.Logger, apiPrefix string, req *http.Request, duration time.Duration, shards int, resp *http.Response, err error) {
+	orgID, _ := user.ExtractOrgID(req.Context())
+	traceID, _ := tracing.ExtractTraceID(req.Context())
+
+	statusCode := http.StatusInternalServerError
+	var contentLength int64
+	if resp != nil {
+		statusCode = resp.StatusCode
+		contentLength = resp.ContentLength
+	} else if httpResp, ok := httpgrpc.HTTPResponseFromError(err); ok {
+		statusCode = int(httpResp.Code)
+		contentLength = int64(len(httpResp.Body))
+	}
+
+	keyvals := []interface{}{
+		"msg", "slow query",
+		"tenant", orgID,
+		"traceID", traceID,
+		"query_id", queryID(req.Context()),
+		"path", req.URL.Path,
+	}
+	switch getOperation(apiPrefix, req.URL.Path) {
+	case TracesOp:
+		keyvals = append(keyvals, "query_trace_id", queriedTraceID(apiPrefix, req.URL.Path))
+		q := req.URL.Query()
+		for _, param := range []string{querier.TraceStartKey, querier.TraceEndKey, querier.BlockStartKey, querier.BlockEndKey, querier.QueryModeKey} {
+			if v := q.Get(param); v != "" {
+				keyvals = append(keyvals, param, v)
+			}
+		}
+	case SearchOp:
+		keyvals = append(keyvals, "search_params", req.URL.Query().Encode())
+	}
+	keyvals = append(keyvals,
+		"duration", duration.String(),
+		"status", statusCode,
+		"shards", shards,
+		"response_size", contentLength,
+	)
+
+	// stats are diagnostics, a query with invalid stats is logged without them
+	if resp != nil {
+		if h := resp.Header.Get(querystats.Header); h != "" {
+			if stats, err := querystats.Decode(h); err == nil {
+				keyvals = append(keyvals,
+					"blocks_inspected", stats.BlocksInspected,
+					"bytes_read", stats.BytesRead,
+					"bloom_bytes_read", stats.BloomBytesRead,
+					"index_bytes_read", stats.IndexBytesRead,
+					"data_bytes_read", stats.DataBytesRead,
+					"meta_bytes_read", stats.MetaBytesRead,
+					"ingesters_queried", stats.IngestersQueried,
+					"store_wall_time_ms", stats.StoreWallTimeMs,
+					"ingesters_wall_time_ms", stats.IngestersWallTimeMs,
+				)
+			}
+		}
+	}
+
+	level.Info(logger).Log(keyvals...)
+}
+
+// queriedTraceID returns the trace id of the path of a trace by id query, <prefix>/api/traces/<traceID>.
+func queriedTraceID(prefix, path string) string {
+	path = strings.TrimPrefix(path[len(prefix)+len(apiPathTraces):], "/")
+	return strings.SplitN(path, "/", 2)[0]
+}
@@ -0,0 +1,97 @@
+package frontend
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/querier/queryrange"
+	"github.com/go-kit/kit/log"
+	"github.com/gorilla/mux"
+	"github.com/opentracing/opentracing-go"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/httpgrpc"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/tempo/pkg/querystats"
+	"github.com/grafana/tempo/pkg/util"
+)
+
+func TestSlowQueryWare(t *testing.T) {
+	statsHeader, err := querystats.Stats{BytesRead: 30, BloomBytesRead: 5, IndexBytesRead: 10, DataBytesRead: 15}.Encode()
+	require.NoError(t, err)
+	next := queryrange.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+		setQueryShards(r.Context(), 3)
+		return &http.Response{
+			StatusCode:    http.StatusOK,
+			Body:          ioutil.NopCloser(bytes.NewReader([]byte("traces"))),
+			Header:        http.Header{querystats.Header: []string{statsHeader}},
+			ContentLength: 6,
+		}, nil
+	})
+
+	roundTrip := func(threshold time.Duration, next http.RoundTripper, path string) string {
+		buf := &bytes.Buffer{}
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		r = r.WithContext(user.InjectOrgID(context.Background(), "tenant"))
+		_, _ = SlowQueryWare("/tempo", threshold, log.NewLogfmtLogger(buf))(next).RoundTrip(r)
+		return buf.String()
+	}
+
+	// a negative threshold logs all queries
+	logged := roundTrip(-1, next, "/tempo"+apiPathTraces+"/0102?start=10&end=20")
+	assert.Contains(t, logged, "msg=\"slow query\" tenant=tenant")
+	assert.Contains(t, logged, "query_trace_id=0102 start=10 end=20")
+	assert.Contains(t, logged, "status=200 shards=3 response_size=6")
+	assert.Contains(t, logged, "bytes_read=30 bloom_bytes_read=5 index_bytes_read=10 data_bytes_read=15 meta_bytes_read=0")
+
+	logged = roundTrip(-1, next, "/tempo"+apiPathSearch+"?tags=service.name%3Dfoo&limit=10")
+	assert.Contains(t, logged, "search_params=\"limit=10&tags=service.name%3Dfoo\"")
+	assert.NotContains(t, logged, "query_trace_id")
+
+	// failed queries are logged with the status of the error
+	failed := queryrange.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+		return nil, httpgrpc.Errorf(http.StatusTooManyRequests, "too many outstanding requests")
+	})
+	assert.Contains(t, roundTrip(-1, failed, "/tempo"+apiPathSearch), "status=429 shards=0")
+
+	assert.Empty(t, roundTrip(0, next, "/tempo"+apiPathTraces+"/0102"))
+	assert.Empty(t, roundTrip(time.Hour, next, "/tempo"+apiPathTraces+"/0102"))
+}
+
+func TestSlowQueryWareShards(t *testing.T) {
+	next := queryrange.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusNotFound,
+			Body:       ioutil.NopCloser(bytes.NewReader(nil)),
+			Header:     http.Header{},
+		}, nil
+	})
+
+	buf := &bytes.Buffer{}
+	traces := NewTracesTripperware(Config{QueryShards: 4}, nil, log.NewNopLogger(), prometheus.NewRegistry())(next)
+	search := NewSearchTripperware(Config{}, log.NewNopLogger())(next)
+	rt := SlowQueryWare("", -1, log.NewLogfmtLogger(buf))(newFrontendRoundTripper("", next, traces, search, log.NewNopLogger(), prometheus.NewRegistry()))
+
+	span, ctx := opentracing.StartSpanFromContext(user.InjectOrgID(context.Background(), "tenant"), "test")
+	defer span.Finish()
+
+	// trace by id queries are split into query_shards shards
+	r := httptest.NewRequest(http.MethodGet, apiPathTraces+"/0102", nil).WithContext(ctx)
+	r = mux.SetURLVars(r, map[string]string{util.TraceIDVar: "0102"})
+	_, err := rt.RoundTrip(r)
+	require.NoError(t, err)
+	assert.Contains(t, buf.String(), "status=404 shards=4")
+
+	// searches aren't split
+	buf.Reset()
+	_, err = rt.RoundTrip(httptest.NewRequest(http.MethodGet, apiPathSearch, nil).WithContext(ctx))
+	require.NoError(t, err)
+	assert.Contains(t, buf.String(), "shards=1")
+}
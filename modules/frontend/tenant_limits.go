@@ -0,0 +1,263 @@
+package frontend
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/querier/queryrange"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/weaveworks/common/httpgrpc"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/tempo/modules/overrides"
+	"github.com/grafana/tempo/pkg/util"
+)
+
+const (
+	// queueFullMessage is the error of the requests rejected because the queue of the tenant was full, by this
+	// frontend or by the queues of the frontend and the query-schedulers.
+	queueFullMessage = "too many outstanding requests"
+
+	// the Retry-After of a rejected query is the time the queue of the tenant takes to drain at the rate requests left
+	// it in the last drainWindow, between these bounds. A queue that didn't drain at all gets the max.
+	minRetryAfterSeconds = 1
+	maxRetryAfterSeconds = 30
+	drainWindow          = 10 * time.Second
+)
+
+// tenantLimits enforces the per tenant query frontend overrides. The outstanding requests of a tenant are the requests
+// the queries of the tenant have sent to the queue of this frontend, one per shard, that haven't returned yet.
+type tenantLimits struct {
+	limits *overrides.Overrides
+	// queueLimit is the max outstanding requests per tenant of the queue the requests are sent to
+	queueLimit int
+	now        func() time.Time
+
+	mtx         sync.Mutex
+	outstanding map[string]int
+	drains      map[string]*drain
+
+	queueLength *prometheus.GaugeVec
+	rejected    *prometheus.CounterVec
+}
+
+// queueFullError is the body of the queries rejected because the queue of the tenant was full.
+type queueFullError struct {
+	Error             string `json:"error"`
+	Tenant            string `json:"tenant"`
+	QueueLength       int    `json:"queue_length"`
+	Limit             int    `json:"limit"`
+	RetryAfterSeconds int    `json:"retry_after_seconds"`
+}
+
+func newTenantLimits(limits *overrides.Overrides, queueLimit int, registerer prometheus.Registerer) *tenantLimits {
+	return &tenantLimits{
+		limits:      limits,
+		queueLimit:  queueLimit,
+		now:         time.Now,
+		outstanding: map[string]int{},
+		drains:      map[string]*drain{},
+		queueLength: promauto.With(registerer).NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "tempo",
+			Name:      "query_frontend_queue_length",
+			Help:      "The number of outstanding requests of the tenant in the queue of the query frontend by priority class.",
+		}, []string{"tenant", "priority"}),
+		rejected: promauto.With(registerer).NewCounterVec(prometheus.CounterOpts{
+			Namespace: "tempo",
+			Name:      "query_frontend_rejected_queries_total",
+			Help:      "The total number of queries rejected because the tenant had too many outstanding requests.",
+		}, []string{"tenant"}),
+	}
+}
+
+// limitQueries rejects the queries of tenants that have max_outstanding_per_tenant outstanding requests and bounds
+// the duration of the others by the max_query_duration of the tenant. An admitted query may send all of its shards
+// even if they exceed the limit.
+func (l *tenantLimits) limitQueries(next http.RoundTripper) http.RoundTripper {
+	return queryrange.RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+		tenantID, err := user.ExtractOrgID(req.Context())
+		if err != nil {
+			return next.RoundTrip(req)
+		}
+
+		if limit := l.limits.MaxOutstandingPerTenant(tenantID); limit > 0 && l.outstandingRequests(tenantID) >= limit {
+			l.rejected.WithLabelValues(tenantID).Inc()
+			return nil, l.queueFull(tenantID, limit)
+		}
+
+		if d := l.limits.MaxQueryDuration(tenantID); d > 0 {
+			ctx, cancel := context.WithTimeout(req.Context(), d)
+			defer cancel()
+			req = req.WithContext(ctx)
+		}
+		return next.RoundTrip(req)
+	})
+}
+
+// countOutstanding counts the requests sent to the queue by tenant. Requests rejected by the queue because it was full
+// fail with the same error as the queries rejected by limitQueries.
+func (l *tenantLimits) countOutstanding(next http.RoundTripper) http.RoundTripper {
+	return queryrange.RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+		tenantID, err := user.ExtractOrgID(req.Context())
+		if err != nil {
+			return next.RoundTrip(req)
+		}
+
+		priority := queryPriority(req.Context())
+		l.addOutstanding(tenantID, priority)
+		resp, err := next.RoundTrip(req)
+		queueFull := isQueueFull(resp, err)
+		// rejected requests didn't drain the queue
+		l.removeOutstanding(tenantID, priority, !queueFull)
+
+		if queueFull {
+			l.rejected.WithLabelValues(tenantID).Inc()
+			if resp != nil {
+				resp.Body.Close()
+			}
+			return nil, l.queueFull(tenantID, l.queueLimit)
+		}
+		return resp, err
+	})
+}
+
+// isQueueFull returns true if the queue rejected a request because the queue of the tenant was full. The queue of the
+// frontend fails the request, the query-schedulers respond with a 429.
+func isQueueFull(resp *http.Response, err error) bool {
+	if errResp, ok := httpgrpc.HTTPResponseFromError(err); ok {
+		return errResp.Code == http.StatusTooManyRequests && string(errResp.Body) == queueFullMessage
+	}
+	if err != nil || resp == nil || resp.StatusCode != http.StatusTooManyRequests {
+		return false
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return err == nil && string(body) == queueFullMessage
+}
+
+// queueFull returns the 429 of a query rejected because the queue of the tenant was full. The body has the queue
+// length and the limit of the tenant.
+func (l *tenantLimits) queueFull(tenantID string, limit int) error {
+	l.mtx.Lock()
+	queueLength := l.outstanding[tenantID]
+	rate := 0.0
+	if d, ok := l.drains[tenantID]; ok {
+		rate = d.rate(l.now())
+	}
+	l.mtx.Unlock()
+
+	retryAfterSeconds := retryAfter(queueLength, rate)
+
+	body, _ := json.Marshal(queueFullError{
+		Error:             queueFullMessage,
+		Tenant:            tenantID,
+		QueueLength:       queueLength,
+		Limit:             limit,
+		RetryAfterSeconds: retryAfterSeconds,
+	})
+	return httpgrpc.ErrorFromHTTPResponse(&httpgrpc.HTTPResponse{
+		Code: http.StatusTooManyRequests,
+		Headers: []*httpgrpc.Header{
+			{Key: "Content-Type", Values: []string{util.JSONTypeHeaderValue}},
+			{Key: "Retry-After", Values: []string{strconv.Itoa(retryAfterSeconds)}},
+		},
+		Body: body,
+	})
+}
+
+// retryAfter returns the seconds a queue of queueLength requests takes to drain at rate requests per second.
+func retryAfter(queueLength int, rate float64) int {
+	if rate <= 0 {
+		return maxRetryAfterSeconds
+	}
+	seconds := int(math.Ceil(float64(queueLength) / rate))
+	if seconds < minRetryAfterSeconds {
+		return minRetryAfterSeconds
+	}
+	if seconds > maxRetryAfterSeconds {
+		return maxRetryAfterSeconds
+	}
+	return seconds
+}
+
+func (l *tenantLimits) outstandingRequests(tenantID string) int {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	return l.outstanding[tenantID]
+}
+
+func (l *tenantLimits) addOutstanding(tenantID, priority string) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	// the drain rate of a tenant is measured from its first request
+	if _, ok := l.drains[tenantID]; !ok {
+		now := l.now()
+		l.drains[tenantID] = &drain{start: now, windowStart: now}
+	}
+
+	l.outstanding[tenantID]++
+	l.queueLength.WithLabelValues(tenantID, priority).Inc()
+}
+
+// removeOutstanding removes a request that returned from the outstanding requests of the tenant. Drained requests
+// count towards the drain rate of the queue.
+func (l *tenantLimits) removeOutstanding(tenantID, priority string, drained bool) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	if drained {
+		l.drains[tenantID].add(l.now())
+	}
+
+	l.outstanding[tenantID]--
+	l.queueLength.WithLabelValues(tenantID, priority).Dec()
+	if l.outstanding[tenantID] == 0 {
+		delete(l.outstanding, tenantID)
+	}
+}
+
+// drain counts the requests that left the queue of a tenant in the current and the previous drainWindow since start.
+type drain struct {
+	start       time.Time
+	windowStart time.Time
+	current     int
+	previous    int
+}
+
+func (d *drain) add(now time.Time) {
+	d.roll(now)
+	d.current++
+}
+
+func (d *drain) roll(now time.Time) {
+	switch elapsed := now.Sub(d.windowStart); {
+	case elapsed >= 2*drainWindow:
+		d.previous, d.current, d.windowStart = 0, 0, now
+	case elapsed >= drainWindow:
+		d.previous, d.current, d.windowStart = d.current, 0, d.windowStart.Add(drainWindow)
+	}
+}
+
+// rate returns the requests per second that left the queue in the previous window and the current one so far.
+func (d *drain) rate(now time.Time) float64 {
+	d.roll(now)
+	elapsed := now.Sub(d.windowStart) + drainWindow
+	if sinceStart := now.Sub(d.start); sinceStart < elapsed {
+		elapsed = sinceStart
+	}
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(d.previous+d.current) / elapsed.Seconds()
+}
@@ -0,0 +1,295 @@
+package frontend
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/querier/queryrange"
+	"github.com/prometheus/client_golang/prometheus"
+	prom_model "github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/httpgrpc"
+	"github.com/weaveworks/common/user"
+	"go.uber.org/atomic"
+
+	"github.com/grafana/tempo/modules/overrides"
+	"github.com/grafana/tempo/pkg/util/test"
+)
+
+func TestTenantLimitsMaxOutstanding(t *testing.T) {
+	limits, err := overrides.NewOverrides(overrides.Limits{MaxOutstandingPerTenant: 2})
+	require.NoError(t, err)
+	l := newTenantLimits(limits, 0, prometheus.NewRegistry())
+
+	release := make(chan struct{})
+	started := make(chan struct{}, 1)
+	queue := l.countOutstanding(queryrange.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+		started <- struct{}{}
+		<-release
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewReader(nil))}, nil
+	}))
+	rt := l.limitQueries(queue)
+
+	request := func(tenant string) *http.Request {
+		return httptest.NewRequest(http.MethodGet, apiPathSearch, nil).WithContext(user.InjectOrgID(context.Background(), tenant))
+	}
+	queueLength := func(tenant string) int {
+		v, err := test.GetGaugeValue(l.queueLength.WithLabelValues(tenant, PriorityInteractive))
+		require.NoError(t, err)
+		return int(v)
+	}
+
+	// fill the queue of the tenant
+	done := make(chan struct{})
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, _ = rt.RoundTrip(request("a"))
+			done <- struct{}{}
+		}()
+		<-started
+	}
+	assert.Equal(t, 2, queueLength("a"))
+
+	_, err = rt.RoundTrip(request("a"))
+	resp, ok := httpgrpc.HTTPResponseFromError(err)
+	require.True(t, ok)
+	assert.Equal(t, int32(http.StatusTooManyRequests), resp.Code)
+	// nothing left the queue yet
+	assert.Equal(t, queueFullError{
+		Error:             "too many outstanding requests",
+		Tenant:            "a",
+		QueueLength:       2,
+		Limit:             2,
+		RetryAfterSeconds: maxRetryAfterSeconds,
+	}, decodeQueueFull(t, resp))
+
+	rejected, err := test.GetCounterVecValue(l.rejected, "a")
+	require.NoError(t, err)
+	assert.Equal(t, float64(1), rejected)
+
+	// other tenants aren't limited
+	go func() {
+		_, _ = rt.RoundTrip(request("b"))
+		done <- struct{}{}
+	}()
+	<-started
+
+	close(release)
+	for i := 0; i < 3; i++ {
+		<-done
+	}
+	assert.Equal(t, 0, queueLength("a"))
+
+	_, err = rt.RoundTrip(request("a"))
+	assert.NoError(t, err)
+}
+
+func TestTenantLimitsMaxQueryDuration(t *testing.T) {
+	limits, err := overrides.NewOverrides(overrides.Limits{MaxQueryDuration: prom_model.Duration(time.Minute)})
+	require.NoError(t, err)
+	l := newTenantLimits(limits, 0, prometheus.NewRegistry())
+
+	var deadline time.Time
+	rt := l.limitQueries(queryrange.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+		deadline, _ = r.Context().Deadline()
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewReader(nil))}, nil
+	}))
+
+	// the deadline of the request is clamped to the max query duration
+	ctx, cancel := context.WithTimeout(user.InjectOrgID(context.Background(), "a"), time.Hour)
+	defer cancel()
+	_, err = rt.RoundTrip(httptest.NewRequest(http.MethodGet, apiPathSearch, nil).WithContext(ctx))
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), deadline, 10*time.Second)
+
+	// shorter deadlines are kept
+	ctx, cancel = context.WithTimeout(user.InjectOrgID(context.Background(), "a"), time.Second)
+	defer cancel()
+	_, err = rt.RoundTrip(httptest.NewRequest(http.MethodGet, apiPathSearch, nil).WithContext(ctx))
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(time.Second), deadline, time.Second)
+}
+
+func TestTenantLimitsConcurrent(t *testing.T) {
+	const limit = 10
+	limits, err := overrides.NewOverrides(overrides.Limits{MaxOutstandingPerTenant: limit})
+	require.NoError(t, err)
+	l := newTenantLimits(limits, 0, prometheus.NewRegistry())
+
+	release := make(chan struct{})
+	started := make(chan struct{}, limit)
+	rt := l.limitQueries(l.countOutstanding(queryrange.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+		started <- struct{}{}
+		<-release
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewReader(nil))}, nil
+	})))
+	ctx := user.InjectOrgID(context.Background(), "a")
+
+	var wg sync.WaitGroup
+	for i := 0; i < limit; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := rt.RoundTrip(httptest.NewRequest(http.MethodGet, apiPathSearch, nil).WithContext(ctx))
+			assert.NoError(t, err)
+		}()
+	}
+	for i := 0; i < limit; i++ {
+		<-started
+	}
+
+	// all queries over the limit are rejected while the queue is full
+	var rejected atomic.Int32
+	var rejectedWg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		rejectedWg.Add(1)
+		go func() {
+			defer rejectedWg.Done()
+			_, err := rt.RoundTrip(httptest.NewRequest(http.MethodGet, apiPathSearch, nil).WithContext(ctx))
+			resp, ok := httpgrpc.HTTPResponseFromError(err)
+			if assert.True(t, ok) && assert.Equal(t, int32(http.StatusTooManyRequests), resp.Code) {
+				body := decodeQueueFull(t, resp)
+				assert.Equal(t, limit, body.QueueLength)
+				assert.Equal(t, limit, body.Limit)
+				rejected.Inc()
+			}
+		}()
+	}
+	rejectedWg.Wait()
+	assert.Equal(t, int32(50), rejected.Load())
+
+	close(release)
+	wg.Wait()
+	assert.Equal(t, 0, l.outstandingRequests("a"))
+}
+
+func TestTenantLimitsQueueFull(t *testing.T) {
+	tests := []struct {
+		name   string
+		resp   *http.Response
+		err    error
+		mapped bool
+	}{
+		{
+			name:   "frontend queue",
+			err:    httpgrpc.Errorf(http.StatusTooManyRequests, "too many outstanding requests"),
+			mapped: true,
+		},
+		{
+			name:   "query-scheduler",
+			resp:   &http.Response{StatusCode: http.StatusTooManyRequests, Body: ioutil.NopCloser(bytes.NewReader([]byte("too many outstanding requests")))},
+			mapped: true,
+		},
+		{
+			name: "other 429",
+			resp: &http.Response{StatusCode: http.StatusTooManyRequests, Body: ioutil.NopCloser(bytes.NewReader([]byte("max search bytes exceeded")))},
+		},
+		{
+			name: "other error",
+			err:  httpgrpc.Errorf(http.StatusInternalServerError, "too many outstanding requests"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limits, err := overrides.NewOverrides(overrides.Limits{})
+			require.NoError(t, err)
+			l := newTenantLimits(limits, 100, prometheus.NewRegistry())
+
+			rt := l.countOutstanding(queryrange.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+				return tt.resp, tt.err
+			}))
+			resp, err := rt.RoundTrip(httptest.NewRequest(http.MethodGet, apiPathSearch, nil).WithContext(user.InjectOrgID(context.Background(), "a")))
+
+			rejected, counterErr := test.GetCounterVecValue(l.rejected, "a")
+			require.NoError(t, counterErr)
+			if !tt.mapped {
+				assert.Equal(t, float64(0), rejected)
+				if tt.resp != nil {
+					require.NoError(t, err)
+					// the body is still readable after it was checked
+					body, err := ioutil.ReadAll(resp.Body)
+					require.NoError(t, err)
+					assert.Equal(t, "max search bytes exceeded", string(body))
+				} else {
+					assert.Equal(t, tt.err, err)
+				}
+				return
+			}
+
+			assert.Equal(t, float64(1), rejected)
+			errResp, ok := httpgrpc.HTTPResponseFromError(err)
+			require.True(t, ok)
+			assert.Equal(t, int32(http.StatusTooManyRequests), errResp.Code)
+			assert.Equal(t, 100, decodeQueueFull(t, errResp).Limit)
+		})
+	}
+}
+
+func TestTenantLimitsRetryAfter(t *testing.T) {
+	limits, err := overrides.NewOverrides(overrides.Limits{MaxOutstandingPerTenant: 20})
+	require.NoError(t, err)
+	l := newTenantLimits(limits, 0, prometheus.NewRegistry())
+	now := time.Unix(1000, 0)
+	l.now = func() time.Time { return now }
+
+	// 10 requests drained in 5s
+	for i := 0; i < 10; i++ {
+		l.addOutstanding("a", PriorityInteractive)
+	}
+	now = now.Add(5 * time.Second)
+	for i := 0; i < 10; i++ {
+		l.removeOutstanding("a", PriorityInteractive, true)
+	}
+	// 20 requests outstanding drain in 10s
+	for i := 0; i < 20; i++ {
+		l.addOutstanding("a", PriorityInteractive)
+	}
+
+	rt := l.limitQueries(queryrange.RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+		return nil, nil
+	}))
+	_, err = rt.RoundTrip(httptest.NewRequest(http.MethodGet, apiPathSearch, nil).WithContext(user.InjectOrgID(context.Background(), "a")))
+	resp, ok := httpgrpc.HTTPResponseFromError(err)
+	require.True(t, ok)
+	assert.Equal(t, 10, decodeQueueFull(t, resp).RetryAfterSeconds)
+	assert.Equal(t, []string{"10"}, header(resp, "Retry-After"))
+	assert.Equal(t, []string{"application/json"}, header(resp, "Content-Type"))
+
+	// only requests of the last window count
+	now = now.Add(time.Minute)
+	_, err = rt.RoundTrip(httptest.NewRequest(http.MethodGet, apiPathSearch, nil).WithContext(user.InjectOrgID(context.Background(), "a")))
+	resp, ok = httpgrpc.HTTPResponseFromError(err)
+	require.True(t, ok)
+	assert.Equal(t, maxRetryAfterSeconds, decodeQueueFull(t, resp).RetryAfterSeconds)
+}
+
+func TestRetryAfter(t *testing.T) {
+	assert.Equal(t, maxRetryAfterSeconds, retryAfter(10, 0))
+	assert.Equal(t, minRetryAfterSeconds, retryAfter(1, 100))
+	assert.Equal(t, 4, retryAfter(10, 3))
+	assert.Equal(t, maxRetryAfterSeconds, retryAfter(1000, 1))
+}
+
+func decodeQueueFull(t *testing.T, resp *httpgrpc.HTTPResponse) queueFullError {
+	body := queueFullError{}
+	require.NoError(t, json.Unmarshal(resp.Body, &body))
+	return body
+}
+
+func header(resp *httpgrpc.HTTPResponse, key string) []string {
+	for _, h := range resp.Headers {
+		if h.Key == key {
+			return h.Values
+		}
+	}
+	return nil
+}
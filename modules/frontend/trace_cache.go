@@ -0,0 +1,207 @@
+package frontend
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	cortex_cache "github.com/cortexproject/cortex/pkg/chunk/cache"
+	"github.com/go-kit/kit/log"
+	"github.com/golang/protobuf/proto"
+	"github.com/opentracing/opentracing-go"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/tempo/modules/querier"
+	"github.com/grafana/tempo/pkg/tempopb"
+	"github.com/grafana/tempo/pkg/util"
+	"github.com/grafana/tempo/tempodb/backend/cache/memcached"
+	"github.com/grafana/tempo/tempodb/backend/cache/redis"
+)
+
+// TraceCacheConfig configures the cache of the responses of trace by id queries. Only traces whose newest span ended
+// more than MinTraceAge ago are cached, they are complete in the backend and no longer change.
+type TraceCacheConfig struct {
+	// Cache is the cache backend, redis or memcached. Empty disables the cache.
+	Cache           string                         `yaml:"cache"`
+	MinTraceAge     time.Duration                  `yaml:"min_trace_age"`
+	TTL             time.Duration                  `yaml:"ttl"`
+	MaxItemBytes    int                            `yaml:"max_item_bytes"`
+	BackgroundCache *cortex_cache.BackgroundConfig `yaml:"background_cache"`
+	Memcached       *memcached.Config              `yaml:"memcached"`
+	Redis           *redis.Config                  `yaml:"redis"`
+}
+
+// newTraceCacheClient returns the configured cache backend or nil if the cache is disabled.
+func newTraceCacheClient(cfg TraceCacheConfig, logger log.Logger) (cortex_cache.Cache, error) {
+	switch cfg.Cache {
+	case "":
+		return nil, nil
+	case "redis":
+		if cfg.Redis == nil {
+			return nil, fmt.Errorf("trace cache redis config missing")
+		}
+		cfg.Redis.TTL = cfg.TTL
+		return redis.NewClient(cfg.Redis, cfg.BackgroundCache, logger), nil
+	case "memcached":
+		if cfg.Memcached == nil {
+			return nil, fmt.Errorf("trace cache memcached config missing")
+		}
+		cfg.Memcached.TTL = cfg.TTL
+		return memcached.NewClient(cfg.Memcached, cfg.BackgroundCache, logger), nil
+	default:
+		return nil, fmt.Errorf("unknown trace cache %s. it should be redis or memcached", cfg.Cache)
+	}
+}
+
+// TraceCacheWare answers trace by id queries from the cache and caches the responses of traces older than
+// min_trace_age. Queries restricted to some blocks, a query mode or a time range aren't cached, neither are partial
+// or truncated traces. A nil cache disables the middleware.
+func TraceCacheWare(cache cortex_cache.Cache, cfg TraceCacheConfig, registerer prometheus.Registerer) Middleware {
+	if cache == nil {
+		return MiddlewareFunc(func(next Handler) Handler { return next })
+	}
+
+	hits := promauto.With(registerer).NewCounter(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "query_frontend_trace_cache_hits_total",
+		Help:      "The total number of trace by id queries answered from the trace cache.",
+	})
+	misses := promauto.With(registerer).NewCounter(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "query_frontend_trace_cache_misses_total",
+		Help:      "The total number of trace by id queries not found in the trace cache.",
+	})
+	tooBig := promauto.With(registerer).NewCounter(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "query_frontend_trace_cache_too_big_total",
+		Help:      "The total number of traces not cached because they exceeded max_item_bytes.",
+	})
+
+	return MiddlewareFunc(func(next Handler) Handler {
+		return traceCacheWare{
+			next:         next,
+			cache:        cache,
+			minTraceAge:  cfg.MinTraceAge,
+			maxItemBytes: cfg.MaxItemBytes,
+			hits:         hits,
+			misses:       misses,
+			tooBig:       tooBig,
+			now:          time.Now,
+		}
+	})
+}
+
+type traceCacheWare struct {
+	next         Handler
+	cache        cortex_cache.Cache
+	minTraceAge  time.Duration
+	maxItemBytes int
+
+	hits   prometheus.Counter
+	misses prometheus.Counter
+	tooBig prometheus.Counter
+	now    func() time.Time
+}
+
+// Do implements Handler
+func (c traceCacheWare) Do(req *http.Request) (*http.Response, error) {
+	key, ok := traceCacheKey(req)
+	if !ok {
+		return c.next.Do(req)
+	}
+
+	span, ctx := opentracing.StartSpanFromContext(req.Context(), "frontend.TraceCache")
+	defer span.Finish()
+
+	found, bufs, _ := c.cache.Fetch(ctx, []string{key})
+	if len(found) == 1 {
+		c.hits.Inc()
+		span.SetTag("cached", true)
+		header := http.Header{}
+		header.Set("Content-Type", util.ProtobufTypeHeaderValue)
+		return &http.Response{
+			StatusCode:    http.StatusOK,
+			Body:          ioutil.NopCloser(bytes.NewReader(bufs[0])),
+			Header:        header,
+			ContentLength: int64(len(bufs[0])),
+		}, nil
+	}
+	c.misses.Inc()
+
+	resp, err := c.next.Do(req.WithContext(ctx))
+	if err != nil || resp.StatusCode != http.StatusOK ||
+		resp.Header.Get(querier.TracePartialHeader) == "true" || resp.Header.Get(querier.TraceTruncatedHeader) == "true" {
+		return resp, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	trace := &tempopb.Trace{}
+	if err := proto.Unmarshal(body, trace); err != nil {
+		return nil, err
+	}
+	// recent traces may still receive spans
+	if newest := newestSpanEnd(trace); newest.IsZero() || c.now().Sub(newest) < c.minTraceAge {
+		return resp, nil
+	}
+	if c.maxItemBytes > 0 && len(body) > c.maxItemBytes {
+		c.tooBig.Inc()
+		return resp, nil
+	}
+
+	c.cache.Store(ctx, []string{key}, [][]byte{body})
+	return resp, nil
+}
+
+// traceCacheKey returns the cache key of a trace by id query. Only queries for the whole trace are cached.
+func traceCacheKey(req *http.Request) (string, bool) {
+	q := req.URL.Query()
+	for _, param := range []string{querier.BlockStartKey, querier.BlockEndKey, querier.QueryModeKey, querier.TraceStartKey, querier.TraceEndKey} {
+		if q.Get(param) != "" {
+			return "", false
+		}
+	}
+	if q.Get(querier.CacheKey) == "false" {
+		return "", false
+	}
+
+	tenantID, err := user.ExtractOrgID(req.Context())
+	if err != nil {
+		return "", false
+	}
+	traceID, err := util.ParseTraceID(req)
+	if err != nil {
+		return "", false
+	}
+
+	// memcached limits the length and characters of keys
+	return cortex_cache.HashKey("trace:" + tenantID + ":" + hex.EncodeToString(traceID)), true
+}
+
+func newestSpanEnd(trace *tempopb.Trace) time.Time {
+	var newest uint64
+	for _, b := range trace.Batches {
+		for _, ils := range b.InstrumentationLibrarySpans {
+			for _, s := range ils.Spans {
+				if s.EndTimeUnixNano > newest {
+					newest = s.EndTimeUnixNano
+				}
+			}
+		}
+	}
+	if newest == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, int64(newest))
+}
@@ -0,0 +1,127 @@
+package frontend
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	cortex_cache "github.com/cortexproject/cortex/pkg/chunk/cache"
+	"github.com/golang/protobuf/proto"
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/tempo/modules/querier"
+	"github.com/grafana/tempo/pkg/tempopb"
+	"github.com/grafana/tempo/pkg/util"
+	"github.com/grafana/tempo/pkg/util/test"
+)
+
+func TestTraceCacheWare(t *testing.T) {
+	now := time.Now()
+	trace := func(end time.Time) *tempopb.Trace {
+		tr := test.MakeTrace(2, []byte{0x01, 0x02})
+		for _, b := range tr.Batches {
+			for _, ils := range b.InstrumentationLibrarySpans {
+				for _, s := range ils.Spans {
+					s.EndTimeUnixNano = uint64(end.UnixNano())
+				}
+			}
+		}
+		return tr
+	}
+
+	var (
+		resp   *tempopb.Trace
+		header http.Header
+		calls  int
+	)
+	next := HandlerFunc(func(req *http.Request) (*http.Response, error) {
+		calls++
+		b, err := proto.Marshal(resp)
+		require.NoError(t, err)
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(bytes.NewReader(b)),
+			Header:     header.Clone(),
+		}, nil
+	})
+
+	newWare := func(maxItemBytes int) traceCacheWare {
+		cfg := TraceCacheConfig{MinTraceAge: time.Hour, MaxItemBytes: maxItemBytes}
+		ware := TraceCacheWare(cortex_cache.NewMockCache(), cfg, prometheus.NewRegistry()).Wrap(next).(traceCacheWare)
+		ware.now = func() time.Time { return now }
+		return ware
+	}
+	request := func(ware traceCacheWare, tenant string, query string) *tempopb.Trace {
+		r := httptest.NewRequest(http.MethodGet, apiPathTraces+"/0102"+query, nil)
+		r = r.WithContext(user.InjectOrgID(context.Background(), tenant))
+		r = mux.SetURLVars(r, map[string]string{util.TraceIDVar: "0102"})
+
+		res, err := ware.Do(r)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		body, err := ioutil.ReadAll(res.Body)
+		require.NoError(t, err)
+		actual := &tempopb.Trace{}
+		require.NoError(t, proto.Unmarshal(body, actual))
+		return actual
+	}
+	counter := func(c prometheus.Counter) int {
+		v, err := test.GetCounterValue(c)
+		require.NoError(t, err)
+		return int(v)
+	}
+
+	// an old trace is cached and served without querying again
+	ware := newWare(0)
+	resp, header, calls = trace(now.Add(-2*time.Hour)), http.Header{}, 0
+	expected := resp
+	assert.True(t, proto.Equal(expected, request(ware, "a", "")))
+	resp = trace(now)
+	assert.True(t, proto.Equal(expected, request(ware, "a", "")))
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, 1, counter(ware.hits))
+	assert.Equal(t, 1, counter(ware.misses))
+
+	// the cache is per tenant
+	request(ware, "b", "")
+	assert.Equal(t, 2, calls)
+
+	// queries for some blocks, modes or time ranges and queries that skip the cache are not cached
+	for _, query := range []string{"?blockStart=00000000-0000-0000-0000-000000000000", "?mode=blocks", "?start=10", "?cache=false"} {
+		request(ware, "a", query)
+	}
+	assert.Equal(t, 6, calls)
+	assert.Equal(t, 2, counter(ware.misses))
+
+	// recent traces are not cached
+	ware = newWare(0)
+	resp, calls = trace(now.Add(-time.Minute)), 0
+	request(ware, "a", "")
+	request(ware, "a", "")
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, 0, counter(ware.hits))
+
+	// neither are partial traces
+	resp = trace(now.Add(-2 * time.Hour))
+	header = http.Header{}
+	header.Set(querier.TracePartialHeader, "true")
+	request(ware, "a", "")
+	request(ware, "a", "")
+	assert.Equal(t, 4, calls)
+
+	// or traces larger than max item bytes
+	ware = newWare(10)
+	header, calls = http.Header{}, 0
+	request(ware, "a", "")
+	request(ware, "a", "")
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, 2, counter(ware.tooBig))
+}
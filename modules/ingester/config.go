@@ -1,6 +1,7 @@
 package ingester
 
 import (
+	"errors"
 	"flag"
 	"os"
 	"time"
@@ -10,6 +11,7 @@ import (
 	"github.com/go-kit/kit/log/level"
 	"github.com/grafana/dskit/flagext"
 	"github.com/grafana/tempo/tempodb"
+	"github.com/grafana/tempo/tempodb/encoding"
 )
 
 // Config for an ingester.
@@ -17,13 +19,42 @@ type Config struct {
 	LifecyclerConfig ring.LifecyclerConfig `yaml:"lifecycler,omitempty"`
 
 	ConcurrentFlushes    int           `yaml:"concurrent_flushes"`
+	ConcurrentCompletes  int           `yaml:"concurrent_block_completions"`
 	FlushCheckPeriod     time.Duration `yaml:"flush_check_period"`
 	FlushOpTimeout       time.Duration `yaml:"flush_op_timeout"`
+	MaxFlushAttempts     int           `yaml:"max_flush_attempts"`
+	MaxFlushBackoff      time.Duration `yaml:"max_flush_backoff"`
 	MaxTraceIdle         time.Duration `yaml:"trace_idle_period"`
 	MaxBlockDuration     time.Duration `yaml:"max_block_duration"`
 	MaxBlockBytes        uint64        `yaml:"max_block_bytes"`
 	CompleteBlockTimeout time.Duration `yaml:"complete_block_timeout"`
 	OverrideRingKey      string        `yaml:"override_ring_key"`
+
+	FlushAllOnShutdown        bool          `yaml:"flush_all_on_shutdown"`
+	FlushAllOnShutdownTimeout time.Duration `yaml:"flush_all_on_shutdown_timeout"`
+
+	// FlushCompleteBlocksOnLeave flushes the complete blocks before the ingester unregisters from the ring
+	FlushCompleteBlocksOnLeave bool          `yaml:"flush_complete_blocks_on_leave"`
+	LeaveConcurrentFlushes     int           `yaml:"leave_concurrent_flushes"`
+	LeaveFlushCheckPeriod      time.Duration `yaml:"leave_flush_check_period"`
+	MaxLeaveDrainDuration      time.Duration `yaml:"max_leave_drain_duration"`
+
+	WALReplayConcurrency uint `yaml:"wal_replay_concurrency"`
+
+	// MaxUnflushedBlockBytes and MaxWALBytes reject writes while the disk usage of the ingester exceeds them
+	MaxUnflushedBlockBytes uint64 `yaml:"max_unflushed_block_bytes"`
+	MaxWALBytes            uint64 `yaml:"max_wal_bytes"`
+
+	// MaxBlockDiskUtilization is the fraction of the volume of the local blocks above which flushed complete blocks
+	// are deleted before the complete block timeout
+	MaxBlockDiskUtilization float64 `yaml:"max_block_disk_utilization"`
+
+	// SearchTagsMaxResults bounds the tag names and values returned from live search data
+	SearchTagsMaxResults int `yaml:"search_tags_max_results"`
+
+	// VerifyBlocksBeforeFlush re-reads every page of a completed block before it is flushed. it requires data page
+	// checksums
+	VerifyBlocksBeforeFlush bool `yaml:"verify_blocks_before_flush"`
 }
 
 // RegisterFlagsAndApplyDefaults registers the flags.
@@ -37,10 +68,25 @@ func (cfg *Config) RegisterFlagsAndApplyDefaults(prefix string, f *flag.FlagSet)
 	cfg.ConcurrentFlushes = 16
 	cfg.FlushCheckPeriod = 10 * time.Second
 	cfg.FlushOpTimeout = 5 * time.Minute
+	cfg.MaxFlushAttempts = 10
+	cfg.MaxFlushBackoff = 2 * time.Minute
+	cfg.FlushAllOnShutdownTimeout = 5 * time.Minute
+	cfg.LeaveConcurrentFlushes = 64
+	cfg.LeaveFlushCheckPeriod = time.Second
 
 	f.DurationVar(&cfg.MaxTraceIdle, prefix+".trace-idle-period", 10*time.Second, "Duration after which to consider a trace complete if no spans have been received")
 	f.DurationVar(&cfg.MaxBlockDuration, prefix+".max-block-duration", time.Hour, "Maximum duration which the head block can be appended to before cutting it.")
 	f.Uint64Var(&cfg.MaxBlockBytes, prefix+".max-block-bytes", 1024*1024*1024, "Maximum size of the head block before cutting it.")
+	f.BoolVar(&cfg.FlushAllOnShutdown, prefix+".flush-all-on-shutdown", false, "Flush all traces to the backend before leaving the ring on shutdown.")
+	f.BoolVar(&cfg.FlushCompleteBlocksOnLeave, prefix+".flush-complete-blocks-on-leave", false, "Flush all complete blocks to the backend while LEAVING the ring and unregister once they are flushed.")
+	f.DurationVar(&cfg.MaxLeaveDrainDuration, prefix+".max-leave-drain-duration", 5*time.Minute, "Maximum time to spend flushing complete blocks while LEAVING the ring. Blocks not flushed are left on disk.")
+	f.IntVar(&cfg.ConcurrentCompletes, prefix+".concurrent-block-completions", 2, "Maximum number of blocks completed at the same time. Completions over the limit wait in a queue. 0 disables the limit.")
+	f.UintVar(&cfg.WALReplayConcurrency, prefix+".wal-replay-concurrency", 4, "Number of wal files to replay concurrently on startup.")
+	f.Uint64Var(&cfg.MaxUnflushedBlockBytes, prefix+".max-unflushed-block-bytes", 0, "Maximum total size of complete blocks not yet flushed to the backend before writes are rejected. 0 disables the limit.")
+	f.Uint64Var(&cfg.MaxWALBytes, prefix+".max-wal-bytes", 0, "Maximum total size of the wal before writes are rejected. 0 disables the limit.")
+	f.Float64Var(&cfg.MaxBlockDiskUtilization, prefix+".max-block-disk-utilization", 0, "Fraction of the volume of the local blocks, e.g. 0.8, above which flushed complete blocks are deleted oldest first. Unflushed blocks are never deleted. 0 disables it.")
+	f.IntVar(&cfg.SearchTagsMaxResults, prefix+".search-tags-max-results", 1000, "Maximum number of tag names or tag values returned by search tag lookups.")
+	f.BoolVar(&cfg.VerifyBlocksBeforeFlush, prefix+".verify-blocks-before-flush", false, "Re-read and verify every page of a completed block, including its checksum, before flushing it. Requires data page checksums. Blocks that fail are completed again from the wal.")
 	f.DurationVar(&cfg.CompleteBlockTimeout, prefix+".complete-block-timeout", 3*tempodb.DefaultBlocklistPoll, "Duration to keep head blocks in the ingester after they have been cut.")
 
 	hostname, err := os.Hostname()
@@ -52,3 +98,12 @@ func (cfg *Config) RegisterFlagsAndApplyDefaults(prefix string, f *flag.FlagSet)
 
 	cfg.OverrideRingKey = ring.IngesterRingKey
 }
+
+// Validate returns an error if blocks are verified before flush without data page checksums. Pages without a checksum
+// always pass verification.
+func (cfg *Config) Validate(blockCfg *encoding.BlockConfig) error {
+	if cfg.VerifyBlocksBeforeFlush && (blockCfg == nil || !blockCfg.DataPageChecksums) {
+		return errors.New("ingester.verify_blocks_before_flush requires storage.trace.block.data_page_checksums")
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package ingester
+
+import (
+	"sort"
+
+	"github.com/cortexproject/cortex/pkg/util/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/gogo/status"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"google.golang.org/grpc/codes"
+
+	"github.com/grafana/tempo/tempodb/backend"
+)
+
+var (
+	metricUnflushedBlockBytes = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "tempo",
+		Name:      "ingester_unflushed_block_bytes",
+		Help:      "The total size of the complete blocks of all tenants that have not been flushed to the backend.",
+	})
+	metricWALBytes = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "tempo",
+		Name:      "ingester_wal_bytes",
+		Help:      "The total size of the head and completing blocks of all tenants in the wal.",
+	})
+	metricBlockDiskUtilization = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "tempo",
+		Name:      "ingester_block_disk_utilization",
+		Help:      "The used fraction of the volume of the local blocks.",
+	})
+	metricBlocksEvictedTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "ingester_blocks_evicted_total",
+		Help:      "The total number of flushed complete blocks deleted before the complete block timeout because of disk pressure.",
+	})
+)
+
+// checkDiskLimits sums the disk usage of all instances and rejects writes while it exceeds the configured maximums.
+// Writes are accepted again once flushes catch up and the usage drops back under the limits.
+func (i *Ingester) checkDiskLimits() {
+	var unflushedBytes, walBytes uint64
+	for _, instance := range i.getInstances() {
+		u, w := instance.DiskUsage()
+		unflushedBytes += u
+		walBytes += w
+	}
+
+	metricUnflushedBlockBytes.Set(float64(unflushedBytes))
+	metricWALBytes.Set(float64(walBytes))
+
+	var err error
+	switch {
+	case i.cfg.MaxUnflushedBlockBytes > 0 && unflushedBytes > i.cfg.MaxUnflushedBlockBytes:
+		err = status.Errorf(codes.ResourceExhausted, "ingester unflushed block bytes %d exceed the limit of %d", unflushedBytes, i.cfg.MaxUnflushedBlockBytes)
+	case i.cfg.MaxWALBytes > 0 && walBytes > i.cfg.MaxWALBytes:
+		err = status.Errorf(codes.ResourceExhausted, "ingester wal bytes %d exceed the limit of %d", walBytes, i.cfg.MaxWALBytes)
+	}
+
+	prev := i.diskLimitErr.Load()
+	i.diskLimitErr.Store(err)
+	if err != nil && prev == nil {
+		level.Warn(log.Logger).Log("msg", "ingester disk limit exceeded, rejecting writes until blocks are flushed", "err", err)
+	} else if err == nil && prev != nil {
+		level.Info(log.Logger).Log("msg", "ingester disk usage back under limits, accepting writes", "unflushedBlockBytes", unflushedBytes, "walBytes", walBytes)
+	}
+}
+
+// evictFlushedBlocks deletes flushed complete blocks of all instances, oldest first, while the utilization of the
+// volume of the local blocks exceeds the configured maximum. Blocks are deleted before the complete block timeout so
+// queries of recent traces go to the backend instead. Unflushed blocks, and the blocks of tenants with do_not_flush set
+// that were never written to the backend, are never deleted.
+func (i *Ingester) evictFlushedBlocks() {
+	if i.cfg.MaxBlockDiskUtilization <= 0 {
+		return
+	}
+
+	utilization, err := i.diskUtilization()
+	if err != nil {
+		level.Error(log.Logger).Log("msg", "failed to get block disk utilization", "err", err)
+		return
+	}
+	metricBlockDiskUtilization.Set(utilization)
+	if utilization <= i.cfg.MaxBlockDiskUtilization {
+		return
+	}
+
+	type flushedBlock struct {
+		instance *instance
+		meta     *backend.BlockMeta
+	}
+	var blocks []flushedBlock
+	for _, instance := range i.getInstances() {
+		for _, meta := range instance.FlushedBlocks() {
+			blocks = append(blocks, flushedBlock{instance: instance, meta: meta})
+		}
+	}
+	sort.Slice(blocks, func(a, b int) bool {
+		return blocks[a].meta.EndTime.Before(blocks[b].meta.EndTime)
+	})
+
+	for _, b := range blocks {
+		if utilization <= i.cfg.MaxBlockDiskUtilization {
+			return
+		}
+
+		// the block may have been cleared since it was listed
+		cleared, err := b.instance.ClearFlushedBlock(b.meta.BlockID)
+		if err != nil {
+			level.Error(log.Logger).Log("msg", "failed to evict flushed block", "tenant", b.instance.instanceID, "block", b.meta.BlockID, "err", err)
+			continue
+		}
+		if !cleared {
+			continue
+		}
+		metricBlocksEvictedTotal.Inc()
+		level.Warn(log.Logger).Log("msg", "evicted flushed block under disk pressure", "tenant", b.instance.instanceID, "block", b.meta.BlockID,
+			"blockEndTime", b.meta.EndTime, "utilization", utilization, "maxUtilization", i.cfg.MaxBlockDiskUtilization)
+
+		utilization, err = i.diskUtilization()
+		if err != nil {
+			level.Error(log.Logger).Log("msg", "failed to get block disk utilization", "err", err)
+			return
+		}
+		metricBlockDiskUtilization.Set(utilization)
+	}
+
+	if utilization > i.cfg.MaxBlockDiskUtilization {
+		level.Warn(log.Logger).Log("msg", "block disk utilization exceeds the maximum with no flushed blocks left to evict", "utilization", utilization, "maxUtilization", i.cfg.MaxBlockDiskUtilization)
+	}
+}
@@ -13,6 +13,8 @@ import (
 	"github.com/go-kit/kit/log/level"
 	"github.com/google/uuid"
 	"github.com/grafana/dskit/services"
+	"github.com/grafana/tempo/tempodb"
+	"github.com/grafana/tempo/tempodb/wal"
 	ot "github.com/opentracing/opentracing-go"
 	"github.com/opentracing/opentracing-go/ext"
 	otlog "github.com/opentracing/opentracing-go/log"
@@ -32,7 +34,37 @@ var (
 	metricFailedFlushes = promauto.NewCounter(prometheus.CounterOpts{
 		Namespace: "tempo",
 		Name:      "ingester_failed_flushes_total",
-		Help:      "The total number of failed traces",
+		Help:      "The total number of failed flushes",
+	})
+	metricOldestUnflushedBlockAge = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "tempo",
+		Name:      "ingester_oldest_unflushed_block_age_seconds",
+		Help:      "The age of the oldest complete block that has not been flushed to the backend. 0 if all blocks are flushed.",
+	})
+	metricBlocksDeadLettered = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "ingester_blocks_dead_lettered_total",
+		Help:      "The total number of blocks moved to the dead-letter folder after exceeding the max flush attempts.",
+	})
+	metricBlockCompletionsPending = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "tempo",
+		Name:      "ingester_block_completions_pending",
+		Help:      "The number of blocks waiting for a free slot to be completed.",
+	})
+	metricShutdownDrainDuration = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "tempo",
+		Name:      "ingester_shutdown_drain_duration_seconds",
+		Help:      "Time spent flushing all traces to the backend on shutdown. Updated as the drain progresses.",
+	})
+	metricBlocksNotFlushed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "ingester_blocks_not_flushed_total",
+		Help:      "The total number of complete blocks kept only until the complete block timeout because the tenant has do_not_flush set.",
+	}, []string{"tenant"})
+	metricBlockVerificationFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "ingester_block_verification_failures_total",
+		Help:      "The total number of completed blocks that failed verification and were completed again from the wal.",
 	})
 	metricFlushDuration = promauto.NewHistogram(prometheus.HistogramOpts{
 		Namespace: "tempo",
@@ -55,6 +87,11 @@ const (
 	maxCompleteAttempts = 3
 )
 
+const (
+	urlParamTenant = "tenant"
+	urlParamWait   = "wait"
+)
+
 const (
 	opKindComplete = iota
 	opKindFlush
@@ -62,8 +99,18 @@ const (
 
 // Flush triggers a flush of all in memory traces to disk.  This is called
 // by the lifecycler on shutdown and will put our traces in the WAL to be
-// replayed.
+// replayed.  If FlushAllOnShutdown is set the traces are flushed to the backend
+// instead. If FlushCompleteBlocksOnLeave is set the complete blocks are flushed first.
 func (i *Ingester) Flush() {
+	if i.cfg.FlushCompleteBlocksOnLeave && i.leaving() {
+		i.flushCompleteBlocksOnLeave()
+	}
+
+	if i.cfg.FlushAllOnShutdown {
+		i.drainToBackend()
+		return
+	}
+
 	instances := i.getInstances()
 
 	for _, instance := range instances {
@@ -74,6 +121,80 @@ func (i *Ingester) Flush() {
 	}
 }
 
+// drainToBackend cuts all live traces into a block per tenant, completes it and flushes it to the backend. It then
+// waits for queued flushes. Anything not flushed within FlushAllOnShutdownTimeout is left on disk and recovered on startup.
+func (i *Ingester) drainToBackend() {
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), i.cfg.FlushAllOnShutdownTimeout)
+	defer cancel()
+
+	level.Info(log.Logger).Log("msg", "flushing all traces to the backend on shutdown", "timeout", i.cfg.FlushAllOnShutdownTimeout)
+	metricShutdownDrainDuration.Set(0)
+
+	type drainBlock struct {
+		instance *instance
+		blockID  uuid.UUID
+	}
+
+	var blocks []drainBlock
+	for _, instance := range i.getInstances() {
+		err := instance.CutCompleteTraces(0, true)
+		if err != nil {
+			level.Error(log.WithUserID(instance.instanceID, log.Logger)).Log("msg", "failed to cut complete traces on shutdown", "err", err)
+			continue
+		}
+
+		blockID, err := instance.CutBlockIfReady(0, 0, true)
+		if err != nil {
+			level.Error(log.WithUserID(instance.instanceID, log.Logger)).Log("msg", "failed to cut block on shutdown", "err", err)
+			continue
+		}
+
+		if blockID != uuid.Nil {
+			blocks = append(blocks, drainBlock{instance: instance, blockID: blockID})
+		}
+	}
+
+	for j, b := range blocks {
+		if ctx.Err() != nil {
+			level.Warn(log.Logger).Log("msg", "timed out flushing blocks on shutdown. remaining blocks will be recovered from the wal on startup", "remaining", len(blocks)-j)
+			break
+		}
+
+		err := i.drainBlock(ctx, b.instance, b.blockID)
+		metricShutdownDrainDuration.Set(time.Since(start).Seconds())
+		if err != nil {
+			level.Error(log.WithUserID(b.instance.instanceID, log.Logger)).Log("msg", "failed to flush block on shutdown", "block", b.blockID.String(), "err", err)
+			continue
+		}
+
+		level.Info(log.Logger).Log("msg", "flushed block on shutdown", "userid", b.instance.instanceID, "block", b.blockID.String(),
+			"remaining", len(blocks)-j-1, "elapsed", time.Since(start))
+	}
+
+	for !i.flushQueues.IsEmpty() && ctx.Err() == nil {
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	metricShutdownDrainDuration.Set(time.Since(start).Seconds())
+	level.Info(log.Logger).Log("msg", "finished flushing all traces to the backend on shutdown", "duration", time.Since(start), "timedOut", ctx.Err() != nil)
+}
+
+func (i *Ingester) drainBlock(ctx context.Context, instance *instance, blockID uuid.UUID) error {
+	err := i.completeBlock(instance, blockID)
+	if err != nil {
+		return err
+	}
+
+	err = instance.ClearCompletingBlock(blockID)
+	if err != nil {
+		return errors.Wrap(err, "error clearing completing block")
+	}
+
+	_, err = i.handleFlush(ctx, instance.instanceID, blockID)
+	return err
+}
+
 // ShutdownHandler handles a graceful shutdown for an ingester. It does the following things in order
 // * Stop incoming writes by exiting from the ring
 // * Flush all blocks to backend
@@ -103,10 +224,63 @@ func (i *Ingester) ShutdownHandler(w http.ResponseWriter, _ *http.Request) {
 	_, _ = w.Write([]byte("shutdown job acknowledged"))
 }
 
-// FlushHandler calls sweepAllInstances(true) which will force push all traces into the WAL and force
-//  mark all head blocks as ready to flush.
-func (i *Ingester) FlushHandler(w http.ResponseWriter, _ *http.Request) {
-	i.sweepAllInstances(true)
+// FlushHandler calls sweepInstance(true) on all instances, or only the instance passed in the tenant
+//  query param, which will force push all traces into the WAL and force mark all head blocks as ready to flush.
+//  If wait=true it blocks until the cut blocks are flushed to the backend or the request is cancelled.
+func (i *Ingester) FlushHandler(w http.ResponseWriter, r *http.Request) {
+	wait := false
+	if s := r.URL.Query().Get(urlParamWait); s != "" {
+		var err error
+		wait, err = strconv.ParseBool(s)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid %s: %v", urlParamWait, err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	var instances []*instance
+	if tenant := r.URL.Query().Get(urlParamTenant); tenant != "" {
+		inst, ok := i.getInstanceByID(tenant)
+		if !ok {
+			http.Error(w, fmt.Sprintf("tenant %s not found", tenant), http.StatusNotFound)
+			return
+		}
+		instances = []*instance{inst}
+	} else {
+		instances = i.getInstances()
+	}
+
+	type cutBlock struct {
+		instance *instance
+		blockID  uuid.UUID
+	}
+
+	var blocks []cutBlock
+	for _, instance := range instances {
+		if blockID := i.sweepInstance(instance, true); blockID != uuid.Nil {
+			blocks = append(blocks, cutBlock{instance: instance, blockID: blockID})
+		}
+	}
+
+	if wait {
+		ticker := time.NewTicker(100 * time.Millisecond)
+		defer ticker.Stop()
+
+		for len(blocks) > 0 {
+			if blocks[0].instance.BlockFlushed(blocks[0].blockID) {
+				blocks = blocks[1:]
+				continue
+			}
+
+			select {
+			case <-ticker.C:
+			case <-r.Context().Done():
+				http.Error(w, fmt.Sprintf("%d blocks not flushed: %v", len(blocks), r.Context().Err()), http.StatusServiceUnavailable)
+				return
+			}
+		}
+	}
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -133,24 +307,41 @@ func (o *flushOp) Priority() int64 {
 func (i *Ingester) sweepAllInstances(immediate bool) {
 	instances := i.getInstances()
 
+	var oldest time.Time
 	for _, instance := range instances {
 		i.sweepInstance(instance, immediate)
+
+		t := instance.OldestUnflushedBlockTime()
+		if !t.IsZero() && (oldest.IsZero() || t.Before(oldest)) {
+			oldest = t
+		}
+	}
+
+	age := time.Duration(0)
+	if !oldest.IsZero() {
+		age = time.Since(oldest)
 	}
+	metricOldestUnflushedBlockAge.Set(age.Seconds())
+
+	i.checkDiskLimits()
+	i.evictFlushedBlocks()
 }
 
-func (i *Ingester) sweepInstance(instance *instance, immediate bool) {
+// sweepInstance cuts traces and blocks that are ready and enqueues them to be completed and flushed. It returns the id
+// of the cut block or uuid.Nil if no block was cut.
+func (i *Ingester) sweepInstance(instance *instance, immediate bool) uuid.UUID {
 	// cut traces internally
 	err := instance.CutCompleteTraces(i.cfg.MaxTraceIdle, immediate)
 	if err != nil {
 		level.Error(log.WithUserID(instance.instanceID, log.Logger)).Log("msg", "failed to cut traces", "err", err)
-		return
+		return uuid.Nil
 	}
 
 	// see if it's ready to cut a block
 	blockID, err := instance.CutBlockIfReady(i.cfg.MaxBlockDuration, i.cfg.MaxBlockBytes, immediate)
 	if err != nil {
 		level.Error(log.WithUserID(instance.instanceID, log.Logger)).Log("msg", "failed to cut block", "err", err)
-		return
+		return uuid.Nil
 	}
 
 	if blockID != uuid.Nil {
@@ -170,8 +361,38 @@ func (i *Ingester) sweepInstance(instance *instance, immediate bool) {
 		level.Error(log.WithUserID(instance.instanceID, log.Logger)).Log("msg", "failed to complete block", "err", err)
 	}
 
+	instance.PruneTombstones()
+
 	// periodically purge tag cache, keep tags within complete block timeout (i.e. data that is locally)
 	instance.PurgeExpiredSearchTags(time.Now().Add(-i.cfg.CompleteBlockTimeout))
+
+	return blockID
+}
+
+// completeBlock completes the block once a completion slot is free. Completing a block buffers its pages in memory
+// so bounding the number running at once keeps the memory of the ingester flat when many blocks are cut together.
+func (i *Ingester) completeBlock(instance *instance, blockID uuid.UUID) error {
+	if i.completeSlots != nil {
+		metricBlockCompletionsPending.Inc()
+		i.completeSlots <- struct{}{}
+		metricBlockCompletionsPending.Dec()
+		defer func() { <-i.completeSlots }()
+	}
+
+	err := instance.CompleteBlock(blockID)
+	if err != nil {
+		return err
+	}
+
+	if i.cfg.VerifyBlocksBeforeFlush {
+		err = instance.VerifyCompleteBlock(context.Background(), blockID)
+		if err != nil {
+			metricBlockVerificationFailures.Inc()
+			return err
+		}
+	}
+
+	return nil
 }
 
 func (i *Ingester) flushLoop(j int) {
@@ -201,6 +422,11 @@ func (i *Ingester) flushLoop(j int) {
 			handleFailedOp(op, err)
 		}
 
+		if retry && op.kind == opKindFlush && i.cfg.MaxFlushAttempts > 0 && op.attempts >= uint(i.cfg.MaxFlushAttempts) {
+			i.handleDeadLetter(op, err)
+			retry = false
+		}
+
 		if retry {
 			i.requeue(op)
 		} else {
@@ -220,6 +446,35 @@ func handleAbandonedOp(op *flushOp) {
 		"op", op.kind, "block", op.blockID.String(), "attempts", op.attempts)
 }
 
+// handleDeadLetter moves a block that persistently fails to flush out of the way so the flush queue can move on.
+// The block can be requeued with `tempo-cli flush retry-deadletter` once the underlying issue is fixed.
+func (i *Ingester) handleDeadLetter(op *flushOp, flushErr error) {
+	level.Error(log.WithUserID(op.userID, log.Logger)).Log("msg", "Block exceeded max flush attempts. Moving to dead-letter folder",
+		"userID", op.userID, "attempts", op.attempts, "block", op.blockID.String(), "err", flushErr)
+
+	instance, err := i.getOrCreateInstance(op.userID)
+	if err != nil {
+		level.Error(log.WithUserID(op.userID, log.Logger)).Log("msg", "failed to dead-letter block", "block", op.blockID.String(), "err", err)
+		return
+	}
+
+	report := wal.DeadLetterReport{
+		Attempts: op.attempts,
+		Time:     time.Now(),
+	}
+	if flushErr != nil {
+		report.Error = flushErr.Error()
+	}
+
+	err = instance.DeadLetterBlock(op.blockID, report)
+	if err != nil {
+		level.Error(log.WithUserID(op.userID, log.Logger)).Log("msg", "failed to dead-letter block", "block", op.blockID.String(), "err", err)
+		return
+	}
+
+	metricBlocksDeadLettered.Inc()
+}
+
 func (i *Ingester) handleComplete(op *flushOp) (retry bool, err error) {
 	// No point in proceeding if shutdown has been initiated since
 	// we won't be able to queue up the next flush op
@@ -235,7 +490,7 @@ func (i *Ingester) handleComplete(op *flushOp) (retry bool, err error) {
 		return false, err
 	}
 
-	err = instance.CompleteBlock(op.blockID)
+	err = i.completeBlock(instance, op.blockID)
 	level.Info(log.Logger).Log("msg", "block completed", "userid", op.userID, "blockID", op.blockID, "duration", time.Since(start))
 	if err != nil {
 		handleFailedOp(op, err)
@@ -297,12 +552,33 @@ func (i *Ingester) handleFlush(ctx context.Context, userID string, blockID uuid.
 	}
 
 	if block := instance.GetBlockToBeFlushed(blockID); block != nil {
+		if i.limiter.limits.DoNotFlush(userID) {
+			err = skipFlush(ctx, userID, block)
+			if err != nil {
+				return true, err
+			}
+			return false, nil
+		}
+
 		ctx := user.InjectOrgID(ctx, userID)
 		ctx, cancel := context.WithTimeout(ctx, i.cfg.FlushOpTimeout)
 		defer cancel()
 
+		// traces deleted from the block are dropped by writing it again instead of copying it
+		var writeable tempodb.WriteableBlock = block
+		if drop := instance.tombstonedIn(blockID); drop != nil {
+			writeable = &tombstonedBlock{
+				LocalBlock: block,
+				writer:     i.store,
+				drop:       drop,
+			}
+		}
+
 		start := time.Now()
-		err = i.store.WriteBlock(ctx, block)
+		err = i.store.WriteBlock(ctx, &rateLimitedBlock{
+			WriteableBlock: writeable,
+			limiter:        i.uploadLimiter,
+		})
 		metricFlushDuration.Observe(time.Since(start).Seconds())
 		metricFlushSize.Observe(float64(block.BlockMeta().Size))
 		if err != nil {
@@ -319,6 +595,20 @@ func (i *Ingester) handleFlush(ctx context.Context, userID string, blockID uuid.
 	return false, nil
 }
 
+// skipFlush marks a block of a tenant with do_not_flush set as flushed without writing it to the backend. The block
+// is served by the ingester and cleared with the flushed blocks after the complete block timeout.
+func skipFlush(ctx context.Context, userID string, block *wal.LocalBlock) error {
+	level.Info(log.Logger).Log("msg", "not flushing block of tenant with do_not_flush set", "userid", userID, "block", block.BlockMeta().BlockID.String())
+
+	err := block.SetFlushSkipped(ctx)
+	if err != nil {
+		return errors.Wrap(err, "error marking block flushed")
+	}
+
+	metricBlocksNotFlushed.WithLabelValues(userID).Inc()
+	return nil
+}
+
 func (i *Ingester) enqueue(op *flushOp, jitter bool) {
 	delay := time.Duration(0)
 
@@ -328,38 +618,54 @@ func (i *Ingester) enqueue(op *flushOp, jitter bool) {
 
 	op.at = time.Now().Add(delay)
 
+	// enqueue immediately if there is no delay so the op is visible in the flush queues as soon as this returns
+	if delay == 0 {
+		i.enqueueNow(op)
+		return
+	}
+
 	go func() {
 		time.Sleep(delay)
+		i.enqueueNow(op)
+	}()
+}
 
-		// Check if shutdown initiated
-		if i.flushQueues.IsStopped() {
-			handleAbandonedOp(op)
-			return
-		}
+func (i *Ingester) enqueueNow(op *flushOp) {
+	// Check if shutdown initiated
+	if i.flushQueues.IsStopped() {
+		handleAbandonedOp(op)
+		return
+	}
 
-		err := i.flushQueues.Enqueue(op)
-		if err != nil {
-			handleFailedOp(op, err)
-		}
-	}()
+	err := i.flushQueues.Enqueue(op)
+	if err != nil {
+		handleFailedOp(op, err)
+	}
 }
 
 func (i *Ingester) requeue(op *flushOp) {
+	limit := i.cfg.MaxFlushBackoff
+	if limit <= 0 {
+		limit = maxBackoff
+	}
+
 	op.backoff *= 2
 	if op.backoff < initialBackoff {
 		op.backoff = initialBackoff
 	}
-	if op.backoff > maxBackoff {
-		op.backoff = maxBackoff
+	if op.backoff > limit {
+		op.backoff = limit
 	}
 
-	op.at = time.Now().Add(op.backoff)
+	// jitter the delay between half and the full backoff so failing ops don't retry in lockstep
+	delay := op.backoff/2 + time.Duration(rand.Int63n(int64(op.backoff/2)+1))
+	op.at = time.Now().Add(delay)
 
 	level.Info(log.WithUserID(op.userID, log.Logger)).Log("msg", "retrying op in flushQueue",
-		"op", op.kind, "block", op.blockID.String(), "backoff", op.backoff)
+		"op", op.kind, "block", op.blockID.String(), "backoff", delay)
 
 	go func() {
-		time.Sleep(op.backoff)
+		time.Sleep(delay)
 
 		// Check if shutdown initiated
 		if i.flushQueues.IsStopped() {
@@ -0,0 +1,101 @@
+package ingester
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/ring"
+	"github.com/cortexproject/cortex/pkg/util/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var metricLeaveBlocksRemaining = promauto.NewGauge(prometheus.GaugeOpts{
+	Namespace: "tempo",
+	Name:      "ingester_leave_blocks_remaining",
+	Help:      "The number of completing and complete blocks left to flush before the ingester unregisters from the ring.",
+})
+
+// leaving returns true once the lifecycler has marked the ingester LEAVING in the ring.
+func (i *Ingester) leaving() bool {
+	return i.lifecycler != nil && i.lifecycler.GetState() == ring.LEAVING
+}
+
+// flushCompleteBlocksOnLeave flushes the complete blocks of all tenants to the backend with LeaveConcurrentFlushes
+// workers. It is called by the lifecycler after the ingester is marked LEAVING and before it unregisters from the
+// ring, so a replacement does not have to wait for the blocks to be replayed. Blocks still completing are picked up
+// once the flush queue completes them. Every LeaveFlushCheckPeriod the remaining blocks are rechecked and failed
+// flushes retried until none remain or MaxLeaveDrainDuration passes. Anything left stays on disk and is recovered
+// by the next ingester that starts on the same volume.
+func (i *Ingester) flushCompleteBlocksOnLeave() {
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), i.cfg.MaxLeaveDrainDuration)
+	defer cancel()
+
+	level.Info(log.Logger).Log("msg", "flushing complete blocks before leaving the ring", "timeout", i.cfg.MaxLeaveDrainDuration)
+
+	ticker := time.NewTicker(i.cfg.LeaveFlushCheckPeriod)
+	defer ticker.Stop()
+
+	var (
+		wg       sync.WaitGroup
+		mtx      sync.Mutex
+		inFlight = map[uuid.UUID]struct{}{}
+		slots    = make(chan struct{}, i.cfg.LeaveConcurrentFlushes)
+	)
+
+drain:
+	for {
+		remaining := 0
+		for _, instance := range i.getInstances() {
+			completing, complete := instance.UnflushedBlocks()
+			remaining += completing + len(complete)
+
+			for _, blockID := range complete {
+				mtx.Lock()
+				_, ok := inFlight[blockID]
+				inFlight[blockID] = struct{}{}
+				mtx.Unlock()
+				if ok {
+					continue
+				}
+
+				wg.Add(1)
+				go func(userID string, blockID uuid.UUID) {
+					defer wg.Done()
+
+					slots <- struct{}{}
+					_, err := i.handleFlush(ctx, userID, blockID)
+					<-slots
+
+					if err != nil {
+						level.Error(log.WithUserID(userID, log.Logger)).Log("msg", "failed to flush block while leaving the ring", "block", blockID.String(), "err", err)
+					}
+
+					// released so a failed flush is retried on the next check
+					mtx.Lock()
+					delete(inFlight, blockID)
+					mtx.Unlock()
+				}(instance.instanceID, blockID)
+			}
+		}
+		metricLeaveBlocksRemaining.Set(float64(remaining))
+
+		if remaining == 0 {
+			break
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			level.Warn(log.Logger).Log("msg", "timed out flushing complete blocks before leaving the ring. remaining blocks are left on disk to be recovered on startup", "remaining", remaining)
+			break drain
+		}
+	}
+
+	wg.Wait()
+	level.Info(log.Logger).Log("msg", "finished flushing complete blocks before leaving the ring", "duration", time.Since(start), "timedOut", ctx.Err() != nil)
+}
@@ -2,6 +2,7 @@ package ingester
 
 import (
 	"context"
+	"encoding/hex"
 	"fmt"
 	"sync"
 	"time"
@@ -17,12 +18,15 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/weaveworks/common/user"
+	"go.uber.org/atomic"
 	"google.golang.org/grpc/codes"
 
 	"github.com/grafana/tempo/modules/overrides"
 	"github.com/grafana/tempo/modules/storage"
 	"github.com/grafana/tempo/pkg/flushqueues"
+	"github.com/grafana/tempo/pkg/model"
 	"github.com/grafana/tempo/pkg/tempopb"
+	"github.com/grafana/tempo/pkg/util"
 	"github.com/grafana/tempo/pkg/validation"
 	"github.com/grafana/tempo/tempodb/backend"
 	"github.com/grafana/tempo/tempodb/backend/local"
@@ -32,11 +36,18 @@ import (
 // attempted.
 var ErrReadOnly = errors.New("Ingester is shutting down")
 
-var metricFlushQueueLength = promauto.NewGauge(prometheus.GaugeOpts{
-	Namespace: "tempo",
-	Name:      "ingester_flush_queue_length",
-	Help:      "The total number of series pending in the flush queue.",
-})
+var (
+	metricFlushQueueLength = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "tempo",
+		Name:      "ingester_flush_queue_length",
+		Help:      "The total number of series pending in the flush queue.",
+	})
+	metricWALReplayDuration = promauto.NewSummary(prometheus.SummaryOpts{
+		Namespace: "tempo",
+		Name:      "ingester_wal_replay_duration_seconds",
+		Help:      "Time spent replaying the wal on startup.",
+	})
+)
 
 // Ingester builds blocks out of incoming traces
 type Ingester struct {
@@ -56,8 +67,20 @@ type Ingester struct {
 	flushQueues     *flushqueues.ExclusiveQueues
 	flushQueuesDone sync.WaitGroup
 
+	// diskLimitErr is set while the disk usage exceeds the configured limits and returned to writes
+	diskLimitErr atomic.Error
+
+	// diskUtilization returns the used fraction of the volume of the local blocks
+	diskUtilization func() (float64, error)
+
+	// completeSlots bounds the number of blocks completed at once, nil if unbounded
+	completeSlots chan struct{}
+
 	limiter *Limiter
 
+	// uploadLimiter limits the bandwidth of all flushes to the backend
+	uploadLimiter *uploadLimiter
+
 	subservicesWatcher *services.FailureWatcher
 }
 
@@ -72,6 +95,15 @@ func New(cfg Config, store storage.Store, limits *overrides.Overrides) (*Ingeste
 	}
 
 	i.local = store.WAL().LocalBackend()
+	blocksPath := store.WAL().BlocksFilepath()
+	i.diskUtilization = func() (float64, error) {
+		return util.VolumeUtilization(blocksPath)
+	}
+	i.uploadLimiter = newUploadLimiter(limits.FlushUploadRateLimitBytes)
+
+	if cfg.ConcurrentCompletes > 0 {
+		i.completeSlots = make(chan struct{}, cfg.ConcurrentCompletes)
+	}
 
 	i.flushQueuesDone.Add(cfg.ConcurrentFlushes)
 	for j := 0; j < cfg.ConcurrentFlushes; j++ {
@@ -106,9 +138,6 @@ func (i *Ingester) starting(ctx context.Context) error {
 		return fmt.Errorf("failed to rediscover local blocks %w", err)
 	}
 
-	// Search data is considered experimental and removed on every startup.
-	i.clearSearchData()
-
 	// Now that user states have been created, we can start the lifecycler.
 	// Important: we want to keep lifecycler running until we ask it to stop, so we need to give it independent context
 	if err := i.lifecycler.StartAsync(context.Background()); err != nil {
@@ -184,12 +213,17 @@ func (i *Ingester) Push(ctx context.Context, req *tempopb.PushRequest) (*tempopb
 	return &tempopb.PushResponse{}, err
 }
 
-// PushBytes implements tempopb.Pusher.PushBytes
+// PushBytes implements tempopb.Pusher.PushBytes. It takes ownership of the slices of req, see tempopb.PreallocBytes.
+// Trace bytes and search data are kept in the live traces until they are cut.
 func (i *Ingester) PushBytes(ctx context.Context, req *tempopb.PushBytesRequest) (*tempopb.PushResponse, error) {
 	if i.readonly {
 		return nil, ErrReadOnly
 	}
 
+	if err := i.diskLimitErr.Load(); err != nil {
+		return nil, err
+	}
+
 	if len(req.Traces) != len(req.Ids) {
 		return nil, status.Errorf(codes.InvalidArgument, "mismatched traces/ids length: %d, %d", len(req.Traces), len(req.Ids))
 	}
@@ -218,6 +252,8 @@ func (i *Ingester) PushBytes(ctx context.Context, req *tempopb.PushBytesRequest)
 		}
 	}
 
+	resp := &tempopb.PushResponse{}
+
 	// Unmarshal and push each trace
 	for i := range req.Traces {
 
@@ -227,13 +263,34 @@ func (i *Ingester) PushBytes(ctx context.Context, req *tempopb.PushBytesRequest)
 			searchData = req.SearchData[i].Slice
 		}
 
-		err := instance.PushBytes(ctx, req.Ids[i].Slice, req.Traces[i].Slice, searchData)
+		err := instance.PushBytes(ctx, req.Ids[i].Slice, req.Traces[i].Slice, searchData, req.IngestionTimeUnixNano, req.RejectNewTraces)
 		if err != nil {
+			if limitErr, ok := rejectedByLimit(err); ok && req.PartialSuccess {
+				resp.TraceErrors = append(resp.TraceErrors, &tempopb.PushTraceError{
+					Index:  uint32(i),
+					Error:  limitErr.Error(),
+					Reason: limitErr.Reason(),
+				})
+				continue
+			}
 			return nil, err
 		}
 	}
 
-	return &tempopb.PushResponse{}, nil
+	return resp, nil
+}
+
+// rejectedByLimit returns the LimitError of err if it rejected a single trace because of a per tenant limit. The
+// other traces of the push can still be appended.
+func rejectedByLimit(err error) (*overrides.LimitError, bool) {
+	var limitErr *overrides.LimitError
+	if !errors.As(err, &limitErr) {
+		return nil, false
+	}
+	if !errors.Is(limitErr, overrides.ErrLiveTracesExceeded) && !errors.Is(limitErr, overrides.ErrTraceTooLarge) {
+		return nil, false
+	}
+	return limitErr, true
 }
 
 // FindTraceByID implements tempopb.Querier.f
@@ -255,18 +312,85 @@ func (i *Ingester) FindTraceByID(ctx context.Context, req *tempopb.TraceByIDRequ
 		return &tempopb.TraceByIDResponse{}, nil
 	}
 
-	trace, err := inst.FindTraceByID(ctx, req.TraceID)
+	done, err := inst.startQuery()
 	if err != nil {
 		return nil, err
 	}
+	defer done()
+
+	// the errors of the local blocks are returned with the gRPC code of their kind for the querier to map them to the
+	// http status of the query
+	trace, err := inst.FindTraceByID(ctx, req.TraceID)
+	if err != nil {
+		return nil, backend.GRPCError(err)
+	}
 
 	span.LogFields(ot_log.Bool("trace found", trace != nil))
 
-	return &tempopb.TraceByIDResponse{
+	resp := &tempopb.TraceByIDResponse{
 		Trace: trace,
+	}
+
+	// return the earliest spans of traces over the requested size instead of the whole trace
+	if trace != nil && req.MaxBytes > 0 && uint64(trace.Size()) > req.MaxBytes {
+		var dropped int
+		resp.Trace, dropped = model.TruncateTrace(trace, int(req.MaxBytes))
+		resp.TraceTruncated = true
+		span.LogFields(ot_log.Int("truncated spans", dropped))
+	}
+
+	return resp, nil
+}
+
+// DeleteTrace implements tempopb.Querier. It removes a trace of the tenant that has not been flushed to the backend
+// yet and returns the number of spans removed. Deleting a trace again removes the spans received since.
+func (i *Ingester) DeleteTrace(ctx context.Context, req *tempopb.DeleteTraceRequest) (*tempopb.DeleteTraceResponse, error) {
+	if !validation.ValidTraceID(req.TraceID) {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid trace id")
+	}
+
+	instanceID, err := user.ExtractOrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	inst, ok := i.getInstanceByID(instanceID)
+	if !ok || inst == nil {
+		return &tempopb.DeleteTraceResponse{}, nil
+	}
+
+	spans, err := inst.DeleteTrace(req.TraceID)
+	if err != nil {
+		return nil, err
+	}
+
+	metricDeletedSpansTotal.WithLabelValues(instanceID).Add(float64(spans))
+	level.Info(log.Logger).Log("msg", "deleted trace", "tenant", instanceID, "traceID", hex.EncodeToString(req.TraceID), "spansRemoved", spans)
+
+	return &tempopb.DeleteTraceResponse{
+		SpansRemoved: spans,
 	}, nil
 }
 
+// LiveTraceStats implements tempopb.Querier. It returns the live traces of every tenant in this ingester and is
+// polled by the distributors to enforce the global live traces limit.
+func (i *Ingester) LiveTraceStats(ctx context.Context, req *tempopb.LiveTraceStatsRequest) (*tempopb.LiveTraceStatsResponse, error) {
+	instances := i.getInstances()
+
+	resp := &tempopb.LiveTraceStatsResponse{
+		Tenants: make([]*tempopb.TenantLiveTraceStats, 0, len(instances)),
+	}
+	for _, inst := range instances {
+		traces, traceBytes := inst.LiveTraceStats()
+		resp.Tenants = append(resp.Tenants, &tempopb.TenantLiveTraceStats{
+			TenantID:       inst.instanceID,
+			LiveTraces:     traces,
+			LiveTraceBytes: traceBytes,
+		})
+	}
+
+	return resp, nil
+}
+
 func (i *Ingester) CheckReady(ctx context.Context) error {
 	if err := i.lifecycler.CheckReady(ctx); err != nil {
 		return fmt.Errorf("ingester check ready failed %w", err)
@@ -328,12 +452,37 @@ func (i *Ingester) TransferOut(ctx context.Context) error {
 }
 
 func (i *Ingester) replayWal() error {
-	level.Info(log.Logger).Log("msg", "beginning wal replay")
-
-	blocks, err := i.store.WAL().RescanBlocks(log.Logger)
+	level.Info(log.Logger).Log("msg", "beginning wal replay", "concurrency", i.cfg.WALReplayConcurrency)
+	start := time.Now()
+
+	// The search data is replayed concurrently with the wal blocks so it adds little to the replay time.
+	var (
+		searchData *replayedSearchData
+		searchErr  error
+		searchWg   sync.WaitGroup
+	)
+	searchWg.Add(1)
+	go func() {
+		defer searchWg.Done()
+		searchData, searchErr = i.replaySearchData()
+	}()
+
+	blocks, err := i.store.WAL().RescanBlocks(i.cfg.WALReplayConcurrency, log.Logger)
+	searchWg.Wait()
 	if err != nil {
 		return fmt.Errorf("fatal error replaying wal %w", err)
 	}
+	if searchErr != nil {
+		// Search data is optional, replay the blocks without it.
+		level.Warn(log.Logger).Log("msg", "failed to replay search data", "err", searchErr)
+		searchData = &replayedSearchData{}
+	}
+
+	// The tombstones are replayed after the blocks were rescanned as this creates the instances and their head blocks.
+	err = i.replayTombstones()
+	if err != nil {
+		return fmt.Errorf("fatal error replaying tombstones %w", err)
+	}
 
 	for _, b := range blocks {
 		tenantID := b.Meta().TenantID
@@ -349,13 +498,18 @@ func (i *Ingester) replayWal() error {
 		// deleted (because it was rescanned above). This can happen for reasons
 		// such as a crash or restart. In this situation we err on the side of
 		// caution and replay the wal block.
-		err = instance.local.ClearBlock(b.Meta().BlockID, tenantID)
+		err = instance.clearLocalBlock(b.Meta().BlockID)
 		if err != nil {
 			return err
 		}
 
 		instance.AddCompletingBlock(b)
 
+		if sb, ok := searchData.blocks[b.Meta().BlockID]; ok && sb.tenantID == tenantID {
+			delete(searchData.blocks, b.Meta().BlockID)
+			instance.AddCompletingSearchBlock(b, sb.b)
+		}
+
 		i.enqueue(&flushOp{
 			kind:    opKindComplete,
 			userID:  tenantID,
@@ -363,7 +517,20 @@ func (i *Ingester) replayWal() error {
 		}, i.replayJitter)
 	}
 
-	level.Info(log.Logger).Log("msg", "wal replay complete")
+	// Search data without a wal block, i.e. of an empty head block, is not needed.
+	for _, sb := range searchData.blocks {
+		_ = sb.b.Clear()
+	}
+
+	// Nothing is pushed before the lifecycler starts so the rebuilt tag caches can replace the empty ones.
+	for tenantID, tagCache := range searchData.tagCaches {
+		if instance, ok := i.getInstanceByID(tenantID); ok {
+			instance.searchTagCache = tagCache
+		}
+	}
+
+	metricWALReplayDuration.Observe(time.Since(start).Seconds())
+	level.Info(log.Logger).Log("msg", "wal replay complete", "blocks", len(blocks), "duration", time.Since(start))
 
 	return nil
 }
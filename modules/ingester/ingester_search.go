@@ -2,14 +2,44 @@ package ingester
 
 import (
 	"context"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
 
 	"github.com/cortexproject/cortex/pkg/util/log"
 	"github.com/go-kit/kit/log/level"
-	"github.com/grafana/tempo/pkg/tempopb"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/weaveworks/common/user"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/grafana/tempo/pkg/boundedwaitgroup"
+	"github.com/grafana/tempo/pkg/tempofb"
+	"github.com/grafana/tempo/pkg/tempopb"
+	"github.com/grafana/tempo/tempodb/backend"
+	"github.com/grafana/tempo/tempodb/search"
+	"github.com/grafana/tempo/tempodb/wal"
+)
+
+const (
+	searchDir      = "search"
+	searchDataFile = "searchdata"
+
+	urlParamTagName = "tagName"
 )
 
-const searchDir = "search"
+var metricSearchReplayDuration = promauto.NewSummary(prometheus.SummaryOpts{
+	Namespace: "tempo",
+	Name:      "ingester_search_replay_duration_seconds",
+	Help:      "Time spent replaying the search data of the wal on startup.",
+})
 
 func (i *Ingester) Search(ctx context.Context, req *tempopb.SearchRequest) (*tempopb.SearchResponse, error) {
 	instanceID, err := user.ExtractOrgID(ctx)
@@ -21,10 +51,16 @@ func (i *Ingester) Search(ctx context.Context, req *tempopb.SearchRequest) (*tem
 		return &tempopb.SearchResponse{}, nil
 	}
 
-	res, err := inst.Search(ctx, req)
+	done, err := inst.startQuery()
 	if err != nil {
 		return nil, err
 	}
+	defer done()
+
+	res, err := inst.Search(ctx, req)
+	if err != nil {
+		return nil, backend.GRPCError(err)
+	}
 
 	return res, nil
 }
@@ -39,10 +75,16 @@ func (i *Ingester) SearchTags(ctx context.Context, req *tempopb.SearchTagsReques
 		return &tempopb.SearchTagsResponse{}, nil
 	}
 
+	done, err := inst.startQuery()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
 	tags := inst.GetSearchTags()
 
 	resp := &tempopb.SearchTagsResponse{
-		TagNames: tags,
+		TagNames: limitResults(tags, i.cfg.SearchTagsMaxResults),
 	}
 
 	return resp, nil
@@ -58,19 +100,194 @@ func (i *Ingester) SearchTagValues(ctx context.Context, req *tempopb.SearchTagVa
 		return &tempopb.SearchTagValuesResponse{}, nil
 	}
 
+	done, err := inst.startQuery()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
 	vals := inst.GetSearchTagValues(req.TagName)
 
 	resp := &tempopb.SearchTagValuesResponse{
-		TagValues: vals,
+		TagValues: limitResults(vals, i.cfg.SearchTagsMaxResults),
 	}
 
 	return resp, nil
 }
 
-func (i *Ingester) clearSearchData() {
-	// clear wal
-	err := i.store.WAL().ClearFolder(searchDir)
+// SearchTagsHandler returns the tag names in the live search data of the tenant.
+func (i *Ingester) SearchTagsHandler(w http.ResponseWriter, r *http.Request) {
+	resp, err := i.SearchTags(r.Context(), &tempopb.SearchTagsRequest{})
 	if err != nil {
-		level.Error(log.Logger).Log("msg", "error clearing search data from wal")
+		http.Error(w, err.Error(), searchErrorStatus(err))
+		return
+	}
+
+	writeSearchResponse(w, resp)
+}
+
+// SearchTagValuesHandler returns the values of the tagName in the live search data of the tenant.
+func (i *Ingester) SearchTagValuesHandler(w http.ResponseWriter, r *http.Request) {
+	tagName, ok := mux.Vars(r)[urlParamTagName]
+	if !ok || tagName == "" {
+		http.Error(w, "please provide a tagName", http.StatusBadRequest)
+		return
+	}
+
+	resp, err := i.SearchTagValues(r.Context(), &tempopb.SearchTagValuesRequest{TagName: tagName})
+	if err != nil {
+		http.Error(w, err.Error(), searchErrorStatus(err))
+		return
+	}
+
+	writeSearchResponse(w, resp)
+}
+
+// searchErrorStatus returns the http status of an error of a search handler. Queries rejected by the concurrent query
+// limit of the tenant are returned as too many requests.
+func searchErrorStatus(err error) int {
+	if status.Code(err) == codes.ResourceExhausted {
+		return http.StatusTooManyRequests
+	}
+	return http.StatusBadRequest
+}
+
+func writeSearchResponse(w http.ResponseWriter, resp proto.Message) {
+	w.Header().Set("Content-Type", "application/json")
+	marshaller := &jsonpb.Marshaler{}
+	err := marshaller.Marshal(w, resp)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// limitResults returns at most max of the sorted results. All results are returned if max <= 0.
+func limitResults(results []string, max int) []string {
+	if max > 0 && len(results) > max {
+		return results[:max]
 	}
+	return results
+}
+
+// replayedSearchData is the search data of the wal replayed on startup.
+type replayedSearchData struct {
+	// blocks by the id of their wal block
+	blocks map[uuid.UUID]*replayedSearchBlock
+	// tag caches rebuilt from the blocks by tenant
+	tagCaches map[string]*search.TagCache
+}
+
+type replayedSearchBlock struct {
+	tenantID string
+	b        *search.StreamingSearchBlock
+}
+
+// replaySearchData replays the search data of the wal blocks and rebuilds the tag caches. It runs concurrently with
+// the replay of the wal blocks and replays up to WALReplayConcurrency files at once. Files that can not be replayed
+// are removed.
+func (i *Ingester) replaySearchData() (*replayedSearchData, error) {
+	start := time.Now()
+
+	files, err := i.store.WAL().Files(searchDir)
+	if err != nil {
+		return nil, err
+	}
+
+	concurrency := i.cfg.WALReplayConcurrency
+	if concurrency == 0 {
+		concurrency = 1
+	}
+
+	var (
+		mtx      sync.Mutex
+		replayed = &replayedSearchData{
+			blocks:    map[uuid.UUID]*replayedSearchBlock{},
+			tagCaches: map[string]*search.TagCache{},
+		}
+		bg = boundedwaitgroup.New(concurrency)
+	)
+	for _, f := range files {
+		if f.Name != searchDataFile {
+			continue
+		}
+
+		mtx.Lock()
+		tagCache, ok := replayed.tagCaches[f.TenantID]
+		if !ok {
+			tagCache = search.NewTagCache()
+			replayed.tagCaches[f.TenantID] = tagCache
+		}
+		mtx.Unlock()
+
+		bg.Add(1)
+		go func(f wal.File) {
+			defer bg.Done()
+
+			b := i.replaySearchFile(f, tagCache)
+			if b == nil {
+				return
+			}
+
+			mtx.Lock()
+			replayed.blocks[f.BlockID] = &replayedSearchBlock{
+				tenantID: f.TenantID,
+				b:        b,
+			}
+			mtx.Unlock()
+		}(f)
+	}
+	bg.Wait()
+
+	metricSearchReplayDuration.Observe(time.Since(start).Seconds())
+	level.Info(log.Logger).Log("msg", "search data replay complete", "blocks", len(replayed.blocks), "duration", time.Since(start))
+
+	return replayed, nil
+}
+
+// replaySearchFile replays a single search data file and adds its entries to the tag cache. It returns nil if the file
+// could not be replayed and was removed.
+func (i *Ingester) replaySearchFile(f wal.File, tagCache *search.TagCache) *search.StreamingSearchBlock {
+	file, err := i.store.WAL().NewFile(f.BlockID, f.TenantID, searchDir, f.Name)
+	if err != nil {
+		level.Warn(log.Logger).Log("msg", "failed to open search data", "block", f.BlockID, "tenant", f.TenantID, "err", err)
+		return nil
+	}
+
+	b, warning, err := search.NewStreamingSearchBlockFromFile(file)
+	if err != nil {
+		level.Warn(log.Logger).Log("msg", "failed to replay search data. removing.", "file", file.Name(), "err", err)
+		_ = file.Close()
+		_ = os.Remove(file.Name())
+		return nil
+	}
+	if warning != nil {
+		level.Warn(log.Logger).Log("msg", "received warning while replaying search data. truncated at corruption point.", "file", file.Name(), "warning", warning)
+	}
+
+	iter, err := b.Iterator()
+	if err != nil {
+		level.Warn(log.Logger).Log("msg", "failed to rebuild search tags", "file", file.Name(), "err", err)
+		return b
+	}
+	defer iter.Close()
+
+	now := time.Now()
+	for {
+		id, data, err := iter.Next(context.TODO())
+		if err != nil && err != io.EOF {
+			level.Warn(log.Logger).Log("msg", "failed to rebuild search tags", "file", file.Name(), "err", err)
+			break
+		}
+		if id == nil {
+			break
+		}
+
+		if len(data) == 0 {
+			continue
+		}
+
+		tagCache.SetData(now, tempofb.SearchEntryFromBytes(data))
+	}
+
+	return b
 }
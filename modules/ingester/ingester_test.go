@@ -2,20 +2,32 @@ package ingester
 
 import (
 	"context"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"math/rand"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path"
+	"runtime"
+	"runtime/debug"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/cortexproject/cortex/pkg/ring"
 	"github.com/go-kit/kit/log"
 	"github.com/gogo/protobuf/proto"
+	"github.com/google/uuid"
 	"github.com/grafana/dskit/flagext"
 	"github.com/grafana/dskit/kv/consul"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/weaveworks/common/user"
+	"go.uber.org/atomic"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	"github.com/grafana/tempo/modules/overrides"
 	"github.com/grafana/tempo/modules/storage"
@@ -63,6 +75,72 @@ func TestPushQuery(t *testing.T) {
 	}
 }
 
+func TestPushQueryIngestionTime(t *testing.T) {
+	ctx := user.InjectOrgID(context.Background(), "test")
+	ingester, _, _ := defaultIngester(t, t.TempDir())
+
+	traceID := make([]byte, 16)
+	_, err := rand.Read(traceID)
+	require.NoError(t, err)
+	trace := test.MakeTrace(3, traceID)
+	for j, ingestionTime := range []uint64{20, 10, 0} {
+		b, err := proto.Marshal(&tempopb.Trace{Batches: trace.Batches[j : j+1]})
+		require.NoError(t, err)
+
+		_, err = ingester.PushBytes(ctx, &tempopb.PushBytesRequest{
+			Traces:                []tempopb.PreallocBytes{{Slice: b}},
+			Ids:                   []tempopb.PreallocBytes{{Slice: traceID}},
+			IngestionTimeUnixNano: ingestionTime,
+		})
+		require.NoError(t, err)
+	}
+
+	// the earliest ingestion time is kept for the live trace and the trace cut to the head block
+	foundTrace, err := ingester.FindTraceByID(ctx, &tempopb.TraceByIDRequest{TraceID: traceID})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(10), foundTrace.Trace.IngestionTimeUnixNano)
+	assert.Len(t, foundTrace.Trace.Batches, 3)
+
+	inst, ok := ingester.getInstanceByID("test")
+	require.True(t, ok)
+	require.NoError(t, inst.CutCompleteTraces(0, true))
+
+	foundTrace, err = ingester.FindTraceByID(ctx, &tempopb.TraceByIDRequest{TraceID: traceID})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(10), foundTrace.Trace.IngestionTimeUnixNano)
+	assert.Len(t, foundTrace.Trace.Batches, 3)
+}
+
+func TestPushQueryTruncated(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("/tmp", "")
+	assert.NoError(t, err, "unexpected error getting tempdir")
+	defer os.RemoveAll(tmpDir)
+
+	ctx := user.InjectOrgID(context.Background(), "test")
+	ingester, traces, traceIDs := defaultIngester(t, tmpDir)
+
+	for i, traceID := range traceIDs {
+		// a limit the trace fits in returns the whole trace
+		foundTrace, err := ingester.FindTraceByID(ctx, &tempopb.TraceByIDRequest{
+			TraceID:  traceID,
+			MaxBytes: uint64(traces[i].Size()),
+		})
+		require.NoError(t, err)
+		assert.False(t, foundTrace.TraceTruncated)
+		assert.True(t, proto.Equal(traces[i], foundTrace.Trace))
+
+		maxBytes := traces[i].Size() / 2
+		foundTrace, err = ingester.FindTraceByID(ctx, &tempopb.TraceByIDRequest{
+			TraceID:  traceID,
+			MaxBytes: uint64(maxBytes),
+		})
+		require.NoError(t, err)
+		assert.True(t, foundTrace.TraceTruncated)
+		assert.LessOrEqual(t, foundTrace.Trace.Size(), maxBytes)
+		assert.NotEmpty(t, foundTrace.Trace.Batches)
+	}
+}
+
 func TestFullTraceReturned(t *testing.T) {
 	tmpDir, err := ioutil.TempDir("/tmp", "")
 	assert.NoError(t, err, "unexpected error getting tempdir")
@@ -293,8 +371,204 @@ func TestFlush(t *testing.T) {
 	}
 }
 
+func TestLiveTraceStats(t *testing.T) {
+	ingester, traces, _ := defaultIngester(t, t.TempDir())
+
+	expectedBytes := 0
+	for _, trace := range traces {
+		for _, batch := range trace.Batches {
+			expectedBytes += (&tempopb.Trace{Batches: []*v1.ResourceSpans{batch}}).Size()
+		}
+	}
+
+	resp, err := ingester.LiveTraceStats(context.Background(), &tempopb.LiveTraceStatsRequest{})
+	require.NoError(t, err)
+	require.Len(t, resp.Tenants, 1)
+	assert.Equal(t, "test", resp.Tenants[0].TenantID)
+	assert.Equal(t, uint64(len(traces)), resp.Tenants[0].LiveTraces)
+	assert.Equal(t, uint64(expectedBytes), resp.Tenants[0].LiveTraceBytes)
+
+	// cut traces are no longer live
+	inst, ok := ingester.getInstanceByID("test")
+	require.True(t, ok)
+	require.NoError(t, inst.CutCompleteTraces(0, true))
+
+	resp, err = ingester.LiveTraceStats(context.Background(), &tempopb.LiveTraceStatsRequest{})
+	require.NoError(t, err)
+	require.Len(t, resp.Tenants, 1)
+	assert.Equal(t, uint64(0), resp.Tenants[0].LiveTraces)
+	assert.Equal(t, uint64(0), resp.Tenants[0].LiveTraceBytes)
+}
+
+func TestPushBytesRejectNewTraces(t *testing.T) {
+	ctx := user.InjectOrgID(context.Background(), "test")
+	ingester, traces, traceIDs := defaultIngester(t, t.TempDir())
+
+	newID := make([]byte, 16)
+	_, err := rand.Read(newID)
+	require.NoError(t, err)
+
+	liveBytes, err := proto.Marshal(test.MakeTrace(1, traceIDs[0]))
+	require.NoError(t, err)
+	newBytes, err := proto.Marshal(test.MakeTrace(1, newID))
+	require.NoError(t, err)
+
+	// the flag is set by the distributor once the tenant reached its global live traces limit
+	sent := &tempopb.PushBytesRequest{
+		Traces:          []tempopb.PreallocBytes{{Slice: liveBytes}, {Slice: newBytes}},
+		Ids:             []tempopb.PreallocBytes{{Slice: traceIDs[0]}, {Slice: newID}},
+		PartialSuccess:  true,
+		RejectNewTraces: true,
+	}
+	wire, err := sent.Marshal()
+	require.NoError(t, err)
+	req := &tempopb.PushBytesRequest{}
+	require.NoError(t, req.Unmarshal(wire))
+	require.True(t, req.RejectNewTraces)
+
+	resp, err := ingester.PushBytes(ctx, req)
+	require.NoError(t, err)
+	require.Len(t, resp.TraceErrors, 1)
+	assert.Equal(t, uint32(1), resp.TraceErrors[0].Index)
+	assert.Equal(t, overrides.ReasonLiveTracesExceeded, resp.TraceErrors[0].Reason)
+	assert.Contains(t, resp.TraceErrors[0].Error, overrides.ErrorPrefixLiveTracesExceeded)
+
+	// the spans of the live trace are appended, the new trace isn't created
+	foundTrace, err := ingester.FindTraceByID(ctx, &tempopb.TraceByIDRequest{TraceID: traceIDs[0]})
+	require.NoError(t, err)
+	assert.Len(t, foundTrace.Trace.Batches, len(traces[0].Batches)+1)
+
+	foundTrace, err = ingester.FindTraceByID(ctx, &tempopb.TraceByIDRequest{TraceID: newID})
+	require.NoError(t, err)
+	assert.Nil(t, foundTrace.Trace)
+}
+
+// TestPushBytesBufferOwnership pushes concurrently while traces are cut and queried. Requests are decoded like gRPC does
+// from a wire buffer that is overwritten once PushBytes returns, so slices retained past the call are caught by the
+// race detector or by corrupted traces.
+func TestPushBytesBufferOwnership(t *testing.T) {
+	ctx := user.InjectOrgID(context.Background(), "test")
+	ingester, _, _ := defaultIngester(t, t.TempDir())
+	inst, ok := ingester.getInstanceByID("test")
+	require.True(t, ok)
+
+	const pushers = 8
+	const tracesPerPusher = 20
+
+	expected := make([][]*tempopb.Trace, pushers)
+	ids := make([][][]byte, pushers)
+	for p := 0; p < pushers; p++ {
+		for j := 0; j < tracesPerPusher; j++ {
+			id := make([]byte, 16)
+			_, err := rand.Read(id)
+			require.NoError(t, err)
+
+			trace := test.MakeTrace(2, id)
+			model.SortTrace(trace)
+			expected[p] = append(expected[p], trace)
+			ids[p] = append(ids[p], id)
+		}
+	}
+
+	done := make(chan struct{})
+	wg := sync.WaitGroup{}
+
+	// cut and query while pushing
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+
+			assert.NoError(t, inst.CutCompleteTraces(0, true))
+			_, err := inst.CutBlockIfReady(0, 0, false)
+			assert.NoError(t, err)
+			_, err = ingester.FindTraceByID(ctx, &tempopb.TraceByIDRequest{TraceID: ids[rand.Intn(pushers)][rand.Intn(tracesPerPusher)]})
+			assert.NoError(t, err)
+		}
+	}()
+
+	pushWG := sync.WaitGroup{}
+	for p := 0; p < pushers; p++ {
+		pushWG.Add(1)
+		go func(p int) {
+			defer pushWG.Done()
+
+			var wire []byte
+			for j, trace := range expected[p] {
+				for _, batch := range trace.Batches {
+					traceBytes, err := proto.Marshal(&tempopb.Trace{Batches: []*v1.ResourceSpans{batch}})
+					if !assert.NoError(t, err) {
+						return
+					}
+
+					sent := &tempopb.PushBytesRequest{
+						Traces: []tempopb.PreallocBytes{{Slice: traceBytes}},
+						Ids:    []tempopb.PreallocBytes{{Slice: ids[p][j]}},
+					}
+					wire = append(wire[:0], make([]byte, sent.Size())...)
+					_, err = sent.MarshalToSizedBuffer(wire)
+					if !assert.NoError(t, err) {
+						return
+					}
+
+					req := &tempopb.PushBytesRequest{}
+					if !assert.NoError(t, req.Unmarshal(wire)) {
+						return
+					}
+					_, err = ingester.PushBytes(ctx, req)
+					if !assert.NoError(t, err) {
+						return
+					}
+
+					// the wire buffer is reused by the next request
+					for k := range wire {
+						wire[k] = 0xFF
+					}
+				}
+			}
+		}(p)
+	}
+	pushWG.Wait()
+	close(done)
+	wg.Wait()
+
+	require.NoError(t, inst.CutCompleteTraces(0, true))
+	for p := range expected {
+		for j, trace := range expected[p] {
+			found, err := ingester.FindTraceByID(ctx, &tempopb.TraceByIDRequest{TraceID: ids[p][j]})
+			require.NoError(t, err)
+			require.NotNil(t, found.Trace)
+
+			model.SortTrace(found.Trace)
+			assert.True(t, proto.Equal(trace, found.Trace))
+		}
+	}
+}
+
 func defaultIngester(t *testing.T, tmpDir string) (*Ingester, []*tempopb.Trace, [][]byte) {
-	ingesterConfig := defaultIngesterTestConfig()
+	return defaultIngesterWithConfig(t, tmpDir, defaultIngesterTestConfig())
+}
+
+func defaultIngesterWithConfig(t *testing.T, tmpDir string, ingesterConfig Config) (*Ingester, []*tempopb.Trace, [][]byte) {
+	return defaultIngesterWithBlockConfig(t, tmpDir, ingesterConfig, defaultBlockTestConfig())
+}
+
+func defaultBlockTestConfig() *encoding.BlockConfig {
+	return &encoding.BlockConfig{
+		IndexDownsampleBytes: 2,
+		BloomFP:              0.01,
+		BloomShardSizeBytes:  100_000,
+		Encoding:             backend.EncLZ4_1M,
+		IndexPageSizeBytes:   1000,
+	}
+}
+
+func defaultIngesterWithBlockConfig(t *testing.T, tmpDir string, ingesterConfig Config, blockConfig *encoding.BlockConfig) (*Ingester, []*tempopb.Trace, [][]byte) {
 	limits, err := overrides.NewOverrides(defaultLimitsTestConfig())
 	require.NoError(t, err, "unexpected error creating overrides")
 
@@ -304,13 +578,7 @@ func defaultIngester(t *testing.T, tmpDir string) (*Ingester, []*tempopb.Trace,
 			Local: &local.Config{
 				Path: tmpDir,
 			},
-			Block: &encoding.BlockConfig{
-				IndexDownsampleBytes: 2,
-				BloomFP:              0.01,
-				BloomShardSizeBytes:  100_000,
-				Encoding:             backend.EncLZ4_1M,
-				IndexPageSizeBytes:   1000,
-			},
+			Block: blockConfig,
 			WAL: &wal.Config{
 				Filepath: tmpDir,
 			},
@@ -424,3 +692,828 @@ func pushDeprecatedBatch(t *testing.T, i *Ingester, batch *v1.ResourceSpans) {
 	})
 	require.NoError(t, err)
 }
+
+func TestDiskLimits(t *testing.T) {
+	ctx := user.InjectOrgID(context.Background(), "test")
+	cfg := defaultIngesterTestConfig()
+	cfg.MaxWALBytes = 1
+	cfg.MaxUnflushedBlockBytes = 1
+
+	i, traces, traceIDs := defaultIngesterWithConfig(t, t.TempDir(), cfg)
+	inst, ok := i.getInstanceByID("test")
+	require.True(t, ok)
+
+	pushErr := func() error {
+		buffer, err := proto.Marshal(traces[0])
+		require.NoError(t, err)
+
+		_, err = i.PushBytes(ctx, &tempopb.PushBytesRequest{
+			Traces: []tempopb.PreallocBytes{{Slice: buffer}},
+			Ids:    []tempopb.PreallocBytes{{Slice: traceIDs[0]}},
+		})
+		return err
+	}
+
+	// traces in the head block exceed the wal limit
+	require.NoError(t, inst.CutCompleteTraces(0, true))
+	i.checkDiskLimits()
+	require.Equal(t, codes.ResourceExhausted, status.Code(pushErr()))
+
+	// the completed block exceeds the unflushed block limit
+	blockID, err := inst.CutBlockIfReady(0, 0, true)
+	require.NoError(t, err)
+	require.NoError(t, inst.CompleteBlock(blockID))
+	require.NoError(t, inst.ClearCompletingBlock(blockID))
+	i.checkDiskLimits()
+	err = pushErr()
+	require.Equal(t, codes.ResourceExhausted, status.Code(err))
+	require.Contains(t, err.Error(), "unflushed block bytes")
+
+	// writes are accepted once the block is flushed
+	retry, err := i.handleFlush(context.Background(), "test", blockID)
+	require.NoError(t, err)
+	require.False(t, retry)
+	i.checkDiskLimits()
+	require.NoError(t, pushErr())
+
+	err = i.stopping(nil)
+	require.NoError(t, err)
+}
+
+func TestEvictFlushedBlocks(t *testing.T) {
+	cfg := defaultIngesterTestConfig()
+	cfg.MaxBlockDiskUtilization = 0.6
+
+	tmpDir := t.TempDir()
+	i, traces, traceIDs := defaultIngesterWithConfig(t, tmpDir, cfg)
+	inst, ok := i.getInstanceByID("test")
+	require.True(t, ok)
+
+	// every complete block uses a quarter of the volume
+	i.diskUtilization = func() (float64, error) {
+		inst.blocksMtx.RLock()
+		defer inst.blocksMtx.RUnlock()
+		return 0.25 * float64(len(inst.completeBlocks)), nil
+	}
+
+	// complete 4 blocks ending in order and flush all but the second. the third one is skipped like the blocks of a
+	// tenant with do_not_flush set
+	start := time.Now()
+	blockIDs := make([]uuid.UUID, 4)
+	for j := range blockIDs {
+		if j > 0 {
+			for _, batch := range traces[j].Batches {
+				pushBatch(t, i, batch, traceIDs[j])
+			}
+		}
+		require.NoError(t, inst.CutCompleteTraces(0, true))
+		blockID, err := inst.CutBlockIfReady(0, 0, true)
+		require.NoError(t, err)
+		require.NoError(t, inst.CompleteBlock(blockID))
+		require.NoError(t, inst.ClearCompletingBlock(blockID))
+		inst.GetBlockToBeFlushed(blockID).BlockMeta().EndTime = start.Add(time.Duration(j) * time.Minute)
+
+		switch j {
+		case 1:
+		case 2:
+			require.NoError(t, skipFlush(context.Background(), "test", inst.GetBlockToBeFlushed(blockID)))
+		default:
+			retry, err := i.handleFlush(context.Background(), "test", blockID)
+			require.NoError(t, err)
+			require.False(t, retry)
+		}
+		blockIDs[j] = blockID
+	}
+
+	remaining := func() []uuid.UUID {
+		inst.blocksMtx.RLock()
+		defer inst.blocksMtx.RUnlock()
+		var ids []uuid.UUID
+		for _, b := range inst.completeBlocks {
+			ids = append(ids, b.BlockMeta().BlockID)
+		}
+		return ids
+	}
+
+	// the oldest flushed blocks are evicted until under the maximum
+	evictedBefore, err := test.GetCounterValue(metricBlocksEvictedTotal)
+	require.NoError(t, err)
+	i.evictFlushedBlocks()
+	require.ElementsMatch(t, []uuid.UUID{blockIDs[1], blockIDs[2]}, remaining())
+	evicted, err := test.GetCounterValue(metricBlocksEvictedTotal)
+	require.NoError(t, err)
+	require.Equal(t, 2.0, evicted-evictedBefore)
+
+	// unflushed and skipped blocks are kept even if still over the maximum
+	i.cfg.MaxBlockDiskUtilization = 0.1
+	i.evictFlushedBlocks()
+	require.ElementsMatch(t, []uuid.UUID{blockIDs[1], blockIDs[2]}, remaining())
+
+	// disabled
+	i.cfg.MaxBlockDiskUtilization = 0
+	retry, err := i.handleFlush(context.Background(), "test", blockIDs[1])
+	require.NoError(t, err)
+	require.False(t, retry)
+	i.evictFlushedBlocks()
+	require.ElementsMatch(t, []uuid.UUID{blockIDs[1], blockIDs[2]}, remaining())
+
+	err = i.stopping(nil)
+	require.NoError(t, err)
+
+	// the block is still skipped once reloaded
+	i, _, _ = defaultIngesterWithConfig(t, tmpDir, cfg)
+	inst, ok = i.getInstanceByID("test")
+	require.True(t, ok)
+	var flushed []uuid.UUID
+	for _, meta := range inst.FlushedBlocks() {
+		flushed = append(flushed, meta.BlockID)
+	}
+	require.Equal(t, []uuid.UUID{blockIDs[1]}, flushed)
+
+	err = i.stopping(nil)
+	require.NoError(t, err)
+}
+
+func TestDeleteTrace(t *testing.T) {
+	tmpDir := t.TempDir()
+	ctx := user.InjectOrgID(context.Background(), "test")
+
+	i, traces, traceIDs := defaultIngester(t, tmpDir)
+	inst, ok := i.getInstanceByID("test")
+	require.True(t, ok)
+
+	deleteTrace := func(j int) uint64 {
+		resp, err := i.DeleteTrace(ctx, &tempopb.DeleteTraceRequest{TraceID: traceIDs[j]})
+		require.NoError(t, err)
+		return resp.SpansRemoved
+	}
+	findTrace := func(j int) *tempopb.Trace {
+		resp, err := i.FindTraceByID(ctx, &tempopb.TraceByIDRequest{TraceID: traceIDs[j]})
+		require.NoError(t, err)
+		return resp.Trace
+	}
+
+	// live trace
+	require.Equal(t, uint64(traceSpanCount(traces[0])), deleteTrace(0))
+	require.Nil(t, findTrace(0))
+	require.Equal(t, uint64(0), deleteTrace(0))
+
+	// head block
+	require.NoError(t, inst.CutCompleteTraces(0, true))
+	require.Equal(t, uint64(traceSpanCount(traces[1])), deleteTrace(1))
+	require.Nil(t, findTrace(1))
+	require.Equal(t, uint64(0), deleteTrace(1))
+
+	// the trace is dropped when the block is completed
+	blockID, err := inst.CutBlockIfReady(0, 0, true)
+	require.NoError(t, err)
+	require.NoError(t, inst.CompleteBlock(blockID))
+	require.NoError(t, inst.ClearCompletingBlock(blockID))
+	block := inst.GetBlockToBeFlushed(blockID)
+	require.NotNil(t, block)
+	found, err := block.Find(context.Background(), traceIDs[1])
+	require.NoError(t, err)
+	require.Nil(t, found)
+
+	// the trace is dropped from the complete block when it is flushed
+	require.Equal(t, uint64(traceSpanCount(traces[2])), deleteTrace(2))
+	require.Nil(t, findTrace(2))
+	require.NotNil(t, findTrace(3))
+
+	retry, err := i.handleFlush(context.Background(), "test", blockID)
+	require.NoError(t, err)
+	require.False(t, retry)
+
+	l, err := local.NewBackend(&local.Config{Path: tmpDir})
+	require.NoError(t, err)
+	r := backend.NewReader(l)
+	meta, err := r.BlockMeta(context.Background(), blockID, "test")
+	require.NoError(t, err)
+	flushed, err := encoding.NewBackendBlock(meta, r)
+	require.NoError(t, err)
+	for j := range traceIDs {
+		found, err = flushed.Find(context.Background(), traceIDs[j])
+		require.NoError(t, err)
+		require.Equal(t, j > 2, found != nil, "trace %d", j)
+	}
+
+	// the tombstones are removed once their blocks are cleared. the last deletion also tombstoned the current head block
+	require.NoError(t, inst.ClearFlushedBlocks(-time.Hour))
+	inst.PruneTombstones()
+	require.False(t, inst.tombstoned(traceIDs[0]))
+	require.False(t, inst.tombstoned(traceIDs[1]))
+	require.True(t, inst.tombstoned(traceIDs[2]))
+
+	err = i.stopping(nil)
+	require.NoError(t, err)
+}
+
+func TestDeleteTraceAgain(t *testing.T) {
+	ctx := user.InjectOrgID(context.Background(), "test")
+
+	i, traces, traceIDs := defaultIngester(t, t.TempDir())
+	inst, ok := i.getInstanceByID("test")
+	require.True(t, ok)
+
+	deleteTrace := func() uint64 {
+		resp, err := i.DeleteTrace(ctx, &tempopb.DeleteTraceRequest{TraceID: traceIDs[0]})
+		require.NoError(t, err)
+		return resp.SpansRemoved
+	}
+	completeBlock := func() uuid.UUID {
+		require.NoError(t, inst.CutCompleteTraces(0, true))
+		blockID, err := inst.CutBlockIfReady(0, 0, true)
+		require.NoError(t, err)
+		require.NoError(t, inst.CompleteBlock(blockID))
+		require.NoError(t, inst.ClearCompletingBlock(blockID))
+		return blockID
+	}
+
+	require.Equal(t, uint64(traceSpanCount(traces[0])), deleteTrace())
+	completeBlock()
+
+	// spans received after the block tombstoned by the first deletion was cut are removed by the second one
+	for _, batch := range traces[0].Batches {
+		pushBatch(t, i, batch, traceIDs[0])
+	}
+	require.NoError(t, inst.CutCompleteTraces(0, true))
+	require.Equal(t, uint64(traceSpanCount(traces[0])), deleteTrace())
+	require.Equal(t, uint64(0), deleteTrace())
+
+	resp, err := i.FindTraceByID(ctx, &tempopb.TraceByIDRequest{TraceID: traceIDs[0]})
+	require.NoError(t, err)
+	require.Nil(t, resp.Trace)
+
+	block := inst.GetBlockToBeFlushed(completeBlock())
+	require.NotNil(t, block)
+	found, err := block.Find(context.Background(), traceIDs[0])
+	require.NoError(t, err)
+	require.Nil(t, found)
+
+	err = i.stopping(nil)
+	require.NoError(t, err)
+}
+
+func TestDeleteTraceReplay(t *testing.T) {
+	tmpDir := t.TempDir()
+	ctx := user.InjectOrgID(context.Background(), "test")
+
+	i, traces, traceIDs := defaultIngester(t, tmpDir)
+	inst, ok := i.getInstanceByID("test")
+	require.True(t, ok)
+
+	require.NoError(t, inst.CutCompleteTraces(0, true))
+	_, err := i.DeleteTrace(ctx, &tempopb.DeleteTraceRequest{TraceID: traceIDs[0]})
+	require.NoError(t, err)
+
+	// the tombstone is replayed with the wal and the trace is dropped when the replayed block is completed
+	i, _, _ = defaultIngester(t, tmpDir)
+	inst, ok = i.getInstanceByID("test")
+	require.True(t, ok)
+	require.True(t, inst.tombstoned(traceIDs[0]))
+
+	for !i.flushQueues.IsEmpty() {
+		time.Sleep(100 * time.Millisecond)
+	}
+	require.Len(t, inst.completeBlocks, 1)
+
+	for j := range traceIDs {
+		found, err := inst.completeBlocks[0].Find(context.Background(), traceIDs[j])
+		require.NoError(t, err)
+		require.Equal(t, j > 0, found != nil, "trace %d", j)
+
+		resp, err := i.FindTraceByID(ctx, &tempopb.TraceByIDRequest{TraceID: traceIDs[j]})
+		require.NoError(t, err)
+		if j == 0 {
+			require.Nil(t, resp.Trace)
+		} else {
+			require.True(t, proto.Equal(traces[j], resp.Trace))
+		}
+	}
+
+	// the tombstones file is removed with the tombstones of the cleared blocks
+	blockID := inst.completeBlocks[0].BlockMeta().BlockID
+	require.NoError(t, inst.ClearFlushedBlocks(-time.Hour))
+	require.Len(t, inst.completeBlocks, 0)
+	inst.PruneTombstones()
+	_, err = os.Stat(path.Join(tmpDir, tombstonesDir, fmt.Sprintf("%v:test:%v", blockID, tombstonesFile)))
+	require.True(t, os.IsNotExist(err))
+
+	err = i.stopping(nil)
+	require.NoError(t, err)
+}
+
+func TestDeadLetterBlock(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	i, _, _ := defaultIngester(t, tmpDir)
+	inst, ok := i.getInstanceByID("test")
+	require.True(t, ok)
+
+	// Write and complete block
+	err := inst.CutCompleteTraces(0, true)
+	require.NoError(t, err)
+	blockID, err := inst.CutBlockIfReady(0, 0, true)
+	require.NoError(t, err)
+	err = inst.CompleteBlock(blockID)
+	require.NoError(t, err)
+	err = inst.ClearCompletingBlock(blockID)
+	require.NoError(t, err)
+
+	err = inst.DeadLetterBlock(blockID, wal.DeadLetterReport{Error: "flush failed", Attempts: 10})
+	require.NoError(t, err)
+	require.Len(t, inst.completeBlocks, 0)
+	require.Nil(t, inst.GetBlockToBeFlushed(blockID))
+
+	// Dead-lettered blocks are not rediscovered on restart
+	err = i.stopping(nil)
+	require.NoError(t, err)
+	i, _, _ = defaultIngester(t, tmpDir)
+	inst, ok = i.getInstanceByID("test")
+	require.True(t, ok)
+	require.Len(t, inst.completeBlocks, 0)
+	err = i.stopping(nil)
+	require.NoError(t, err)
+
+	// Requeued blocks are rediscovered on restart
+	moved, err := wal.RequeueDeadLetterBlocks(tmpDir, "")
+	require.NoError(t, err)
+	require.Len(t, moved, 1)
+
+	// the wal of the previous ingester is replayed and completed concurrently, so only look for the requeued block
+	i, _, _ = defaultIngester(t, tmpDir)
+	inst, ok = i.getInstanceByID("test")
+	require.True(t, ok)
+	inst.blocksMtx.RLock()
+	found := false
+	for _, b := range inst.completeBlocks {
+		found = found || b.BlockMeta().BlockID == blockID
+	}
+	inst.blocksMtx.RUnlock()
+	require.True(t, found)
+	err = i.stopping(nil)
+	require.NoError(t, err)
+}
+
+func TestFlushResumesAfterRestart(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	i, _, _ := defaultIngester(t, tmpDir)
+	inst, ok := i.getInstanceByID("test")
+	require.True(t, ok)
+	require.True(t, inst.OldestUnflushedBlockTime().IsZero())
+
+	// Complete a block without flushing it
+	err := inst.CutCompleteTraces(0, true)
+	require.NoError(t, err)
+	blockID, err := inst.CutBlockIfReady(0, 0, true)
+	require.NoError(t, err)
+	err = inst.CompleteBlock(blockID)
+	require.NoError(t, err)
+	err = inst.ClearCompletingBlock(blockID)
+	require.NoError(t, err)
+	require.False(t, inst.OldestUnflushedBlockTime().IsZero())
+
+	err = i.stopping(nil)
+	require.NoError(t, err)
+
+	// On restart the unflushed block is rediscovered and flushed
+	i, _, _ = defaultIngester(t, tmpDir)
+	inst, ok = i.getInstanceByID("test")
+	require.True(t, ok)
+
+	require.Eventually(t, func() bool {
+		return inst.OldestUnflushedBlockTime().IsZero()
+	}, 10*time.Second, 100*time.Millisecond)
+
+	// the flushed block is kept locally until the complete block timeout
+	require.Len(t, inst.completeBlocks, 1)
+	require.Equal(t, blockID, inst.completeBlocks[0].BlockMeta().BlockID)
+	require.False(t, inst.completeBlocks[0].FlushedTime().IsZero())
+
+	err = i.stopping(nil)
+	require.NoError(t, err)
+}
+
+func TestDoNotFlush(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	i, traces, traceIDs := defaultIngester(t, tmpDir)
+	limits := defaultLimitsTestConfig()
+	limits.DoNotFlush = true
+	o, err := overrides.NewOverrides(limits)
+	require.NoError(t, err)
+	i.limiter = NewLimiter(o, i.lifecycler, 1)
+
+	inst, ok := i.getInstanceByID("test")
+	require.True(t, ok)
+
+	err = inst.CutCompleteTraces(0, true)
+	require.NoError(t, err)
+	blockID, err := inst.CutBlockIfReady(0, 0, true)
+	require.NoError(t, err)
+	err = inst.CompleteBlock(blockID)
+	require.NoError(t, err)
+	err = inst.ClearCompletingBlock(blockID)
+	require.NoError(t, err)
+
+	flushedBefore, err := test.GetCounterValue(metricBlocksFlushed)
+	require.NoError(t, err)
+	notFlushedBefore, err := test.GetCounterValue(metricBlocksNotFlushed.WithLabelValues("test"))
+	require.NoError(t, err)
+
+	// the flush is skipped but the block is considered flushed
+	retry, err := i.handleFlush(context.Background(), "test", blockID)
+	require.NoError(t, err)
+	require.False(t, retry)
+	require.True(t, inst.BlockFlushed(blockID))
+	require.True(t, inst.OldestUnflushedBlockTime().IsZero())
+
+	flushed, err := test.GetCounterValue(metricBlocksFlushed)
+	require.NoError(t, err)
+	assert.Equal(t, flushedBefore, flushed)
+	notFlushed, err := test.GetCounterValue(metricBlocksNotFlushed.WithLabelValues("test"))
+	require.NoError(t, err)
+	assert.Equal(t, notFlushedBefore+1, notFlushed)
+
+	// nothing was written to the backend
+	_, err = os.Stat(path.Join(tmpDir, "test", blockID.String()))
+	assert.True(t, os.IsNotExist(err))
+
+	// traces are served from the ingester until the block is cleared
+	ctx := user.InjectOrgID(context.Background(), "test")
+	for pos, traceID := range traceIDs {
+		resp, err := i.FindTraceByID(ctx, &tempopb.TraceByIDRequest{TraceID: traceID})
+		require.NoError(t, err)
+		assert.Equal(t, traces[pos], resp.Trace)
+	}
+
+	err = inst.ClearFlushedBlocks(0)
+	require.NoError(t, err)
+	require.Len(t, inst.completeBlocks, 0)
+
+	err = i.stopping(nil)
+	require.NoError(t, err)
+}
+
+func TestVerifyBlocksBeforeFlushRequiresChecksums(t *testing.T) {
+	cfg := defaultIngesterTestConfig()
+	blockConfig := defaultBlockTestConfig()
+	require.NoError(t, cfg.Validate(blockConfig))
+
+	// pages without a checksum always pass verification
+	cfg.VerifyBlocksBeforeFlush = true
+	require.Error(t, cfg.Validate(blockConfig))
+
+	blockConfig.DataPageChecksums = true
+	require.NoError(t, cfg.Validate(blockConfig))
+}
+
+func TestVerifyCompleteBlock(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	// corrupted pages are found by their checksums
+	blockConfig := defaultBlockTestConfig()
+	blockConfig.DataPageChecksums = true
+	i, traces, traceIDs := defaultIngesterWithBlockConfig(t, tmpDir, defaultIngesterTestConfig(), blockConfig)
+	inst, ok := i.getInstanceByID("test")
+	require.True(t, ok)
+
+	err := inst.CutCompleteTraces(0, true)
+	require.NoError(t, err)
+	blockID, err := inst.CutBlockIfReady(0, 0, true)
+	require.NoError(t, err)
+
+	err = inst.CompleteBlock(blockID)
+	require.NoError(t, err)
+	err = inst.VerifyCompleteBlock(context.Background(), blockID)
+	require.NoError(t, err)
+	require.Len(t, inst.completeBlocks, 1)
+
+	// corrupt a byte of the first data page
+	dataPath := path.Join(tmpDir, "blocks", "test", blockID.String(), "data")
+	data, err := ioutil.ReadFile(dataPath)
+	require.NoError(t, err)
+	data[len(data)/2]++
+	require.NoError(t, ioutil.WriteFile(dataPath, data, 0644))
+
+	// the block is removed and the wal kept
+	err = inst.VerifyCompleteBlock(context.Background(), blockID)
+	require.Error(t, err)
+	require.Len(t, inst.completeBlocks, 0)
+	require.Len(t, inst.completingBlocks, 1)
+	_, err = os.Stat(dataPath)
+	assert.True(t, os.IsNotExist(err))
+
+	// and the block can be completed again from the wal
+	err = inst.CompleteBlock(blockID)
+	require.NoError(t, err)
+	err = inst.VerifyCompleteBlock(context.Background(), blockID)
+	require.NoError(t, err)
+
+	ctx := user.InjectOrgID(context.Background(), "test")
+	for pos, traceID := range traceIDs {
+		resp, err := i.FindTraceByID(ctx, &tempopb.TraceByIDRequest{TraceID: traceID})
+		require.NoError(t, err)
+		assert.True(t, proto.Equal(traces[pos], resp.Trace))
+	}
+
+	err = i.stopping(nil)
+	require.NoError(t, err)
+}
+
+func TestFlushAllOnShutdown(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	cfg := defaultIngesterTestConfig()
+	cfg.FlushAllOnShutdown = true
+	cfg.FlushAllOnShutdownTimeout = time.Minute
+
+	i, _, _ := defaultIngesterWithConfig(t, tmpDir, cfg)
+	inst, ok := i.getInstanceByID("test")
+	require.True(t, ok)
+	require.NotEmpty(t, inst.traces)
+
+	err := i.stopping(nil)
+	require.NoError(t, err)
+
+	// all live traces were cut, completed and flushed before leaving the ring
+	require.Empty(t, inst.traces)
+	require.Len(t, inst.completingBlocks, 0)
+	require.Len(t, inst.completeBlocks, 1)
+	require.False(t, inst.completeBlocks[0].FlushedTime().IsZero())
+	require.Zero(t, inst.headBlock.DataLength())
+
+	// nothing is left in the wal to replay
+	blocks, err := i.store.WAL().RescanBlocks(1, log.NewNopLogger())
+	require.NoError(t, err)
+	require.Len(t, blocks, 0)
+}
+
+func TestFlushCompleteBlocksOnLeave(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	cfg := defaultIngesterTestConfig()
+	cfg.FlushCompleteBlocksOnLeave = true
+	cfg.LeaveConcurrentFlushes = 4
+	cfg.LeaveFlushCheckPeriod = 10 * time.Millisecond
+	cfg.MaxLeaveDrainDuration = time.Minute
+
+	i, _, _ := defaultIngesterWithConfig(t, tmpDir, cfg)
+	inst, ok := i.getInstanceByID("test")
+	require.True(t, ok)
+	require.Eventually(t, func() bool {
+		return i.lifecycler.GetState() == ring.ACTIVE
+	}, 10*time.Second, 10*time.Millisecond)
+
+	// a complete block waiting to be flushed
+	require.NoError(t, inst.CutCompleteTraces(0, true))
+	blockID, err := inst.CutBlockIfReady(0, 0, true)
+	require.NoError(t, err)
+	require.NoError(t, inst.CompleteBlock(blockID))
+	require.NoError(t, inst.ClearCompletingBlock(blockID))
+
+	// a block still completing is picked up once the flush queue completes it
+	id := make([]byte, 16)
+	id[15] = 0x01
+	pushBatch(t, i, test.MakeTrace(1, id).Batches[0], id)
+	require.NoError(t, inst.CutCompleteTraces(0, true))
+	completingID, err := inst.CutBlockIfReady(0, 0, true)
+	require.NoError(t, err)
+	i.enqueue(&flushOp{kind: opKindComplete, userID: "test", blockID: completingID}, false)
+
+	err = i.stopping(nil)
+	require.NoError(t, err)
+
+	require.Len(t, inst.completingBlocks, 0)
+	require.Len(t, inst.completeBlocks, 2)
+	for _, b := range inst.completeBlocks {
+		require.False(t, b.FlushedTime().IsZero())
+	}
+	remaining, err := test.GetGaugeValue(metricLeaveBlocksRemaining)
+	require.NoError(t, err)
+	require.Equal(t, float64(0), remaining)
+}
+
+func TestFlushCompleteBlocksOnLeaveTimeout(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	cfg := defaultIngesterTestConfig()
+	cfg.FlushCompleteBlocksOnLeave = true
+	cfg.LeaveConcurrentFlushes = 1
+	cfg.LeaveFlushCheckPeriod = 10 * time.Millisecond
+	cfg.MaxLeaveDrainDuration = 100 * time.Millisecond
+
+	i, _, _ := defaultIngesterWithConfig(t, tmpDir, cfg)
+	inst, ok := i.getInstanceByID("test")
+	require.True(t, ok)
+	require.Eventually(t, func() bool {
+		return i.lifecycler.GetState() == ring.ACTIVE
+	}, 10*time.Second, 10*time.Millisecond)
+
+	// a completing block that is never completed holds up the drain until it gives up
+	require.NoError(t, inst.CutCompleteTraces(0, true))
+	_, err := inst.CutBlockIfReady(0, 0, true)
+	require.NoError(t, err)
+
+	start := time.Now()
+	err = i.stopping(nil)
+	require.NoError(t, err)
+	require.Less(t, time.Since(start), 10*time.Second)
+
+	// left in the wal to be replayed
+	require.Len(t, inst.completingBlocks, 1)
+	blocks, err := i.store.WAL().RescanBlocks(1, log.NewNopLogger())
+	require.NoError(t, err)
+	require.Len(t, blocks, 1)
+}
+
+func TestFlushHandler(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	i, _, _ := defaultIngester(t, tmpDir)
+	inst, ok := i.getInstanceByID("test")
+	require.True(t, ok)
+
+	w := httptest.NewRecorder()
+	i.FlushHandler(w, httptest.NewRequest(http.MethodPost, "/flush?tenant=unknown", nil))
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	i.FlushHandler(w, httptest.NewRequest(http.MethodPost, "/flush?wait=notabool", nil))
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	// waits until the cut block is flushed to the backend
+	w = httptest.NewRecorder()
+	i.FlushHandler(w, httptest.NewRequest(http.MethodPost, "/flush?tenant=test&wait=true", nil))
+	require.Equal(t, http.StatusNoContent, w.Code)
+
+	require.Empty(t, inst.traces)
+	require.Len(t, inst.completingBlocks, 0)
+	require.Len(t, inst.completeBlocks, 1)
+	require.False(t, inst.completeBlocks[0].FlushedTime().IsZero())
+
+	// a cancelled request returns once the context is done
+	id := make([]byte, 16)
+	_, err := rand.Read(id)
+	require.NoError(t, err)
+	pushBatch(t, i, test.MakeTrace(1, id).Batches[0], id)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	w = httptest.NewRecorder()
+	i.FlushHandler(w, httptest.NewRequest(http.MethodPost, "/flush?wait=true", nil).WithContext(ctx))
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	err = i.stopping(nil)
+	require.NoError(t, err)
+}
+
+// BenchmarkConcurrentBlockCompletions completes 10 blocks at once and reports the peak heap in use while they are
+// completed, with and without a bound on the number of concurrent completions.
+func BenchmarkConcurrentBlockCompletions(b *testing.B) {
+	for _, slots := range []int{0, 2} {
+		b.Run(fmt.Sprintf("concurrent_completions=%d", slots), func(b *testing.B) {
+			benchmarkConcurrentBlockCompletions(b, slots, 10)
+		})
+	}
+}
+
+func benchmarkConcurrentBlockCompletions(b *testing.B, slots int, blocks int) {
+	tempDir, err := ioutil.TempDir("/tmp", "")
+	require.NoError(b, err, "unexpected error getting temp dir")
+	defer os.RemoveAll(tempDir)
+
+	ingester := &Ingester{}
+	if slots > 0 {
+		ingester.completeSlots = make(chan struct{}, slots)
+	}
+	instance := defaultInstance(b, tempDir)
+
+	// collect often so the heap in use follows the live memory of the completions rather than their garbage
+	defer debug.SetGCPercent(debug.SetGCPercent(10))
+
+	var peak uint64
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		b.StopTimer()
+		blockIDs := make([]uuid.UUID, 0, blocks)
+		for j := 0; j < blocks; j++ {
+			for k := 0; k < 500; k++ {
+				id := make([]byte, 16)
+				_, err = rand.Read(id)
+				require.NoError(b, err)
+				require.NoError(b, instance.Push(context.Background(), test.MakeRequest(100, id)))
+			}
+			require.NoError(b, instance.CutCompleteTraces(0, true))
+			blockID, err := instance.CutBlockIfReady(0, 0, true)
+			require.NoError(b, err)
+			blockIDs = append(blockIDs, blockID)
+		}
+		runtime.GC()
+
+		var base runtime.MemStats
+		runtime.ReadMemStats(&base)
+
+		done := make(chan struct{})
+		sampled := make(chan uint64)
+		go func() {
+			var max uint64
+			var m runtime.MemStats
+			for {
+				select {
+				case <-done:
+					sampled <- max
+					return
+				case <-time.After(time.Millisecond):
+					runtime.ReadMemStats(&m)
+					if m.HeapInuse > base.HeapInuse && m.HeapInuse-base.HeapInuse > max {
+						max = m.HeapInuse - base.HeapInuse
+					}
+				}
+			}
+		}()
+		b.StartTimer()
+
+		wg := sync.WaitGroup{}
+		for _, blockID := range blockIDs {
+			wg.Add(1)
+			go func(blockID uuid.UUID) {
+				defer wg.Done()
+				assert.NoError(b, ingester.completeBlock(instance, blockID))
+			}(blockID)
+		}
+		wg.Wait()
+
+		b.StopTimer()
+		close(done)
+		if max := <-sampled; max > peak {
+			peak = max
+		}
+		for _, blockID := range blockIDs {
+			require.NoError(b, instance.ClearCompletingBlock(blockID))
+		}
+		b.StartTimer()
+	}
+
+	b.ReportMetric(float64(peak), "peak-heap-bytes")
+}
+
+// streamingWriter reads everything passed to StreamWriter like a backend would.
+type streamingWriter struct {
+	backend.MockWriter
+	streamed int
+}
+
+func (w *streamingWriter) StreamWriter(_ context.Context, _ string, _ uuid.UUID, _ string, data io.Reader, _ int64) error {
+	b, err := ioutil.ReadAll(data)
+	w.streamed += len(b)
+	return err
+}
+
+func TestUploadLimiter(t *testing.T) {
+	limit := atomic.NewInt64(10_000)
+	limiter := newUploadLimiter(func() int { return int(limit.Load()) })
+
+	gauge, err := test.GetGaugeValue(metricFlushUploadRateLimit)
+	require.NoError(t, err)
+	assert.Equal(t, 10_000.0, gauge)
+
+	w := &streamingWriter{}
+	rw := &rateLimitedWriter{Writer: w, limiter: limiter}
+	blockID := uuid.New()
+
+	// the first second of uploads is in the bucket, the rest waits for the limit
+	throttledBefore, err := test.GetCounterValue(metricFlushUploadThrottledSeconds)
+	require.NoError(t, err)
+
+	start := time.Now()
+	err = rw.StreamWriter(context.Background(), "data", blockID, "test", io.LimitReader(rand.New(rand.NewSource(1)), 20_000), 20_000)
+	require.NoError(t, err)
+	require.NoError(t, rw.Write(context.Background(), "bloom", blockID, "test", make([]byte, 5_000), false))
+	elapsed := time.Since(start)
+
+	assert.Equal(t, 20_000, w.streamed)
+	assert.GreaterOrEqual(t, elapsed, 1400*time.Millisecond)
+	throttled, err := test.GetCounterValue(metricFlushUploadThrottledSeconds)
+	require.NoError(t, err)
+	assert.Greater(t, throttled-throttledBefore, 1.0)
+
+	// removing the limit at runtime applies to the next upload
+	limit.Store(0)
+	start = time.Now()
+	err = rw.StreamWriter(context.Background(), "data", blockID, "test", io.LimitReader(rand.New(rand.NewSource(1)), 1_000_000), 1_000_000)
+	require.NoError(t, err)
+	assert.Less(t, time.Since(start), time.Second)
+
+	gauge, err = test.GetGaugeValue(metricFlushUploadRateLimit)
+	require.NoError(t, err)
+	assert.Equal(t, 0.0, gauge)
+
+	// waiting uploads stop with the context
+	limit.Store(1_000)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err = rw.Write(ctx, "bloom", blockID, "test", make([]byte, 10_000), false)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
@@ -11,7 +11,6 @@ import (
 
 	"github.com/cortexproject/cortex/pkg/util/log"
 	"github.com/go-kit/kit/log/level"
-	"github.com/gogo/protobuf/proto"
 	"github.com/gogo/status"
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
@@ -50,6 +49,21 @@ var (
 		Name:      "ingester_bytes_written_total",
 		Help:      "The total bytes written per tenant.",
 	}, []string{"tenant"})
+	metricLiveTraces = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "tempo",
+		Name:      "ingester_live_traces",
+		Help:      "The current number of live traces per tenant.",
+	}, []string{"tenant"})
+	metricQueriesInFlight = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "tempo",
+		Name:      "ingester_queries_in_flight",
+		Help:      "The current number of trace by id and search queries per tenant.",
+	}, []string{"tenant"})
+	metricQueriesRejectedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "ingester_queries_rejected_total",
+		Help:      "The total number of queries rejected because the tenant exceeded its max concurrent queries.",
+	}, []string{"tenant"})
 	metricBlocksClearedTotal = promauto.NewCounter(prometheus.CounterOpts{
 		Namespace: "tempo",
 		Name:      "ingester_blocks_cleared_total",
@@ -62,6 +76,8 @@ type instance struct {
 	traces     map[uint32]*trace
 	traceCount atomic.Int32
 
+	queriesInFlight atomic.Int32
+
 	blocksMtx        sync.RWMutex
 	headBlock        *wal.AppendBlock
 	completingBlocks []*wal.AppendBlock
@@ -72,11 +88,18 @@ type instance struct {
 	searchCompleteBlocks map[*wal.LocalBlock]*searchLocalBlockEntry
 	searchTagCache       *search.TagCache
 
+	// tombstones holds the blocks each deleted trace is dropped from by trace id
+	tombstonesMtx sync.Mutex
+	tombstones    map[string]map[uuid.UUID]struct{}
+
 	lastBlockCut time.Time
 
 	instanceID         string
 	tracesCreatedTotal prometheus.Counter
 	bytesWrittenTotal  prometheus.Counter
+	liveTraces         prometheus.Gauge
+	queriesGauge       prometheus.Gauge
+	queriesRejected    prometheus.Counter
 	limiter            *Limiter
 	writer             tempodb.Writer
 
@@ -103,10 +126,14 @@ func newInstance(instanceID string, limiter *Limiter, writer tempodb.Writer, l *
 		searchAppendBlocks:   map[*wal.AppendBlock]*searchStreamingBlockEntry{},
 		searchCompleteBlocks: map[*wal.LocalBlock]*searchLocalBlockEntry{},
 		searchTagCache:       search.NewTagCache(),
+		tombstones:           map[string]map[uuid.UUID]struct{}{},
 
 		instanceID:         instanceID,
 		tracesCreatedTotal: metricTracesCreatedTotal.WithLabelValues(instanceID),
 		bytesWrittenTotal:  metricBytesWrittenTotal.WithLabelValues(instanceID),
+		liveTraces:         metricLiveTraces.WithLabelValues(instanceID),
+		queriesGauge:       metricQueriesInFlight.WithLabelValues(instanceID),
+		queriesRejected:    metricQueriesRejectedTotal.WithLabelValues(instanceID),
 		limiter:            limiter,
 		writer:             writer,
 
@@ -129,7 +156,7 @@ func (i *instance) Push(ctx context.Context, req *tempopb.PushRequest) error {
 	// check for max traces before grabbing the lock to better load shed
 	err := i.limiter.AssertMaxTracesPerUser(i.instanceID, int(i.traceCount.Load()))
 	if err != nil {
-		return status.Errorf(codes.FailedPrecondition, "%s max live traces per tenant exceeded: %v", overrides.ErrorPrefixLiveTracesExceeded, err)
+		return overrides.NewLimitError(overrides.ErrLiveTracesExceeded, "max live traces per tenant exceeded: %v", err)
 	}
 
 	i.tracesMtx.Lock()
@@ -153,12 +180,17 @@ func (i *instance) Push(ctx context.Context, req *tempopb.PushRequest) error {
 		return err
 	}
 
-	trace := i.getOrCreateTrace(id)
+	trace, err := i.getOrCreateTrace(id, false)
+	if err != nil {
+		return err
+	}
 	return trace.Push(ctx, i.instanceID, buffer, nil)
 }
 
-// PushBytes is used to push an unmarshalled tempopb.Trace to the instance
-func (i *instance) PushBytes(ctx context.Context, id []byte, traceBytes []byte, searchData []byte) error {
+// PushBytes is used to push an unmarshalled tempopb.Trace to the instance. ingestionTime is the time the distributor
+// received the trace in unix nanoseconds, 0 if the distributor didn't send it. rejectNewTraces is set by the distributor
+// once the tenant reached its global live traces limit, the trace is then only appended if it's already live.
+func (i *instance) PushBytes(ctx context.Context, id []byte, traceBytes []byte, searchData []byte, ingestionTime uint64, rejectNewTraces bool) error {
 	if !validation.ValidTraceID(id) {
 		return status.Errorf(codes.InvalidArgument, "%s is not a valid traceid", hex.EncodeToString(id))
 	}
@@ -166,7 +198,7 @@ func (i *instance) PushBytes(ctx context.Context, id []byte, traceBytes []byte,
 	// check for max traces before grabbing the lock to better load shed
 	err := i.limiter.AssertMaxTracesPerUser(i.instanceID, int(i.traceCount.Load()))
 	if err != nil {
-		return status.Errorf(codes.FailedPrecondition, "%s max live traces per tenant exceeded: %v", overrides.ErrorPrefixLiveTracesExceeded, err)
+		return overrides.NewLimitError(overrides.ErrLiveTracesExceeded, "max live traces per tenant exceeded: %v", err)
 	}
 
 	if searchData != nil {
@@ -176,18 +208,29 @@ func (i *instance) PushBytes(ctx context.Context, id []byte, traceBytes []byte,
 	i.tracesMtx.Lock()
 	defer i.tracesMtx.Unlock()
 
-	trace := i.getOrCreateTrace(id)
-	return trace.Push(ctx, i.instanceID, traceBytes, searchData)
+	trace, err := i.getOrCreateTrace(id, rejectNewTraces)
+	if err != nil {
+		return err
+	}
+	err = trace.Push(ctx, i.instanceID, traceBytes, searchData)
+	if err != nil {
+		return err
+	}
+
+	trace.recordIngestionTime(ingestionTime)
+	return nil
 }
 
-// Moves any complete traces out of the map to complete traces
+// CutCompleteTraces moves traces that have not been appended to for the idle period, or that have been live for longer
+// than the max live period, out of the map and into the head block. The per-tenant overrides take precedence over the
+// passed idle period.
 func (i *instance) CutCompleteTraces(cutoff time.Duration, immediate bool) error {
 	tracesToCut := i.tracesToCut(cutoff, immediate)
 
 	for _, t := range tracesToCut {
 		model.SortTraceBytes(t.traceBytes)
 
-		out, err := proto.Marshal(t.traceBytes)
+		out, err := t.marshal()
 		if err != nil {
 			return err
 		}
@@ -252,7 +295,7 @@ func (i *instance) CompleteBlock(blockID uuid.UUID) error {
 
 	ctx := context.Background()
 
-	backendBlock, err := i.writer.CompleteBlockWithBackend(ctx, completingBlock, model.ObjectCombiner, i.localReader, i.localWriter)
+	backendBlock, err := i.writer.CompleteBlockWithBackend(ctx, completingBlock, model.ObjectCombiner, i.localReader, i.localWriter, i.tombstonedIn(blockID))
 	if err != nil {
 		return errors.Wrap(err, "error completing wal block with local backend")
 	}
@@ -290,6 +333,54 @@ func (i *instance) CompleteBlock(blockID uuid.UUID) error {
 	return nil
 }
 
+// VerifyCompleteBlock re-reads every page of a complete block, which verifies the page checksums, and checks the
+// objects against the index and bloom filters. A block that fails verification is removed so that it can be
+// completed again from the wal, which must not have been cleared yet.
+func (i *instance) VerifyCompleteBlock(ctx context.Context, blockID uuid.UUID) error {
+	i.blocksMtx.RLock()
+	var completeBlock *wal.LocalBlock
+	for _, iterBlock := range i.completeBlocks {
+		if iterBlock.BlockMeta().BlockID == blockID {
+			completeBlock = iterBlock
+			break
+		}
+	}
+	i.blocksMtx.RUnlock()
+
+	if completeBlock == nil {
+		return fmt.Errorf("error finding completeBlock")
+	}
+
+	err := completeBlock.Verify(ctx, int(completeBlock.BlockMeta().TotalRecords))
+	if err == nil {
+		return nil
+	}
+
+	level.Error(log.Logger).Log("msg", "complete block failed verification. removing.", "tenant", i.instanceID, "block", blockID.String(), "err", err)
+
+	i.blocksMtx.Lock()
+	defer i.blocksMtx.Unlock()
+
+	for j, iterBlock := range i.completeBlocks {
+		if iterBlock == completeBlock {
+			i.completeBlocks = append(i.completeBlocks[:j], i.completeBlocks[j+1:]...)
+			break
+		}
+	}
+	if searchEntry := i.searchCompleteBlocks[completeBlock]; searchEntry != nil {
+		searchEntry.mtx.Lock()
+		defer searchEntry.mtx.Unlock()
+		delete(i.searchCompleteBlocks, completeBlock)
+	}
+
+	clearErr := i.clearLocalBlock(blockID)
+	if clearErr != nil {
+		return errors.Wrapf(clearErr, "error clearing block that failed verification: %v", err)
+	}
+
+	return errors.Wrap(err, "error verifying complete block")
+}
+
 func (i *instance) ClearCompletingBlock(blockID uuid.UUID) error {
 	i.blocksMtx.Lock()
 	defer i.blocksMtx.Unlock()
@@ -332,6 +423,122 @@ func (i *instance) GetBlockToBeFlushed(blockID uuid.UUID) *wal.LocalBlock {
 	return nil
 }
 
+// BlockFlushed returns true once the block is no longer completing and has either been flushed or is no longer
+// tracked, e.g. because it was dead-lettered.
+func (i *instance) BlockFlushed(blockID uuid.UUID) bool {
+	i.blocksMtx.RLock()
+	defer i.blocksMtx.RUnlock()
+
+	for _, b := range i.completingBlocks {
+		if b.BlockID() == blockID {
+			return false
+		}
+	}
+
+	for _, b := range i.completeBlocks {
+		if b.BlockMeta().BlockID == blockID {
+			return !b.FlushedTime().IsZero()
+		}
+	}
+
+	return true
+}
+
+// OldestUnflushedBlockTime returns the time the oldest complete block that has not been flushed was last
+// written to. It returns the zero time if all complete blocks are flushed.
+func (i *instance) OldestUnflushedBlockTime() time.Time {
+	i.blocksMtx.RLock()
+	defer i.blocksMtx.RUnlock()
+
+	var oldest time.Time
+	for _, b := range i.completeBlocks {
+		if !b.FlushedTime().IsZero() {
+			continue
+		}
+
+		if t := b.BlockMeta().EndTime; oldest.IsZero() || t.Before(oldest) {
+			oldest = t
+		}
+	}
+
+	return oldest
+}
+
+// UnflushedBlocks returns the number of completing blocks and the ids of the complete blocks that have not been
+// flushed to the backend.
+func (i *instance) UnflushedBlocks() (completing int, complete []uuid.UUID) {
+	i.blocksMtx.RLock()
+	defer i.blocksMtx.RUnlock()
+
+	for _, b := range i.completeBlocks {
+		if b.FlushedTime().IsZero() {
+			complete = append(complete, b.BlockMeta().BlockID)
+		}
+	}
+
+	return len(i.completingBlocks), complete
+}
+
+// LiveTraceStats returns the number of live traces and the bytes pushed to them.
+func (i *instance) LiveTraceStats() (traces uint64, traceBytes uint64) {
+	i.tracesMtx.Lock()
+	defer i.tracesMtx.Unlock()
+
+	for _, t := range i.traces {
+		traceBytes += uint64(t.currentBytes)
+	}
+
+	return uint64(len(i.traces)), traceBytes
+}
+
+// DiskUsage returns the bytes of the complete blocks not yet flushed to the backend and the bytes of the head and
+// completing blocks in the wal.
+func (i *instance) DiskUsage() (unflushedBlockBytes uint64, walBytes uint64) {
+	i.blocksMtx.RLock()
+	defer i.blocksMtx.RUnlock()
+
+	for _, b := range i.completeBlocks {
+		if b.FlushedTime().IsZero() {
+			unflushedBlockBytes += b.BlockMeta().Size
+		}
+	}
+
+	if i.headBlock != nil {
+		walBytes += i.headBlock.DataLength()
+	}
+	for _, b := range i.completingBlocks {
+		walBytes += b.DataLength()
+	}
+
+	return unflushedBlockBytes, walBytes
+}
+
+// DeadLetterBlock stops tracking a complete block that could not be flushed and moves it to the
+// dead-letter folder of the wal.
+func (i *instance) DeadLetterBlock(blockID uuid.UUID, report wal.DeadLetterReport) error {
+	i.blocksMtx.Lock()
+	defer i.blocksMtx.Unlock()
+
+	for idx, b := range i.completeBlocks {
+		if b.BlockMeta().BlockID != blockID {
+			continue
+		}
+
+		i.completeBlocks = append(i.completeBlocks[:idx], i.completeBlocks[idx+1:]...)
+
+		searchEntry := i.searchCompleteBlocks[b]
+		if searchEntry != nil {
+			searchEntry.mtx.Lock()
+			defer searchEntry.mtx.Unlock()
+			delete(i.searchCompleteBlocks, b)
+		}
+
+		return i.writer.WAL().DeadLetterBlock(blockID, i.instanceID, report)
+	}
+
+	return fmt.Errorf("error finding complete block %s to dead-letter", blockID)
+}
+
 func (i *instance) ClearFlushedBlocks(completeBlockTimeout time.Duration) error {
 	var err error
 
@@ -345,26 +552,95 @@ func (i *instance) ClearFlushedBlocks(completeBlockTimeout time.Duration) error
 		}
 
 		if flushedTime.Add(completeBlockTimeout).Before(time.Now()) {
-			i.completeBlocks = append(i.completeBlocks[:idx], i.completeBlocks[idx+1:]...)
+			err = i.clearCompleteBlock(idx)
+			break
+		}
+	}
 
-			searchEntry := i.searchCompleteBlocks[b]
-			if searchEntry != nil {
-				searchEntry.mtx.Lock()
-				defer searchEntry.mtx.Unlock()
-				delete(i.searchCompleteBlocks, b)
-			}
+	return err
+}
 
-			err = i.local.ClearBlock(b.BlockMeta().BlockID, i.instanceID)
-			if err == nil {
-				metricBlocksClearedTotal.Inc()
-			}
-			break
+// FlushedBlocks returns the metas of the complete blocks that have been flushed to the backend. Blocks of tenants
+// with do_not_flush set are only in the ingester and not returned.
+func (i *instance) FlushedBlocks() []*backend.BlockMeta {
+	i.blocksMtx.RLock()
+	defer i.blocksMtx.RUnlock()
+
+	var metas []*backend.BlockMeta
+	for _, b := range i.completeBlocks {
+		if !b.FlushedTime().IsZero() && !b.FlushSkipped() {
+			metas = append(metas, b.BlockMeta())
 		}
 	}
+	return metas
+}
+
+// ClearFlushedBlock deletes the complete block before the complete block timeout. It returns false without deleting
+// it if the block is no longer complete or has not been flushed to the backend.
+func (i *instance) ClearFlushedBlock(blockID uuid.UUID) (bool, error) {
+	i.blocksMtx.Lock()
+	defer i.blocksMtx.Unlock()
+
+	for idx, b := range i.completeBlocks {
+		if b.BlockMeta().BlockID != blockID {
+			continue
+		}
+		if b.FlushedTime().IsZero() || b.FlushSkipped() {
+			return false, nil
+		}
+		return true, i.clearCompleteBlock(idx)
+	}
+
+	return false, nil
+}
+
+// clearCompleteBlock removes the complete block at idx and deletes it from disk. Must be called under blocksMtx.
+func (i *instance) clearCompleteBlock(idx int) error {
+	b := i.completeBlocks[idx]
+	i.completeBlocks = append(i.completeBlocks[:idx], i.completeBlocks[idx+1:]...)
+
+	searchEntry := i.searchCompleteBlocks[b]
+	if searchEntry != nil {
+		searchEntry.mtx.Lock()
+		defer searchEntry.mtx.Unlock()
+		delete(i.searchCompleteBlocks, b)
+	}
 
+	err := i.clearLocalBlock(b.BlockMeta().BlockID)
+	if err == nil {
+		metricBlocksClearedTotal.Inc()
+	}
 	return err
 }
 
+// clearLocalBlock deletes a block and its skeleton from disk.
+func (i *instance) clearLocalBlock(blockID uuid.UUID) error {
+	err := i.local.ClearBlock(blockID, i.instanceID)
+	if err != nil {
+		return err
+	}
+
+	return i.local.ClearBlock(blockID, backend.SkeletonTenantID(i.instanceID))
+}
+
+// startQuery reserves one of the concurrent queries of the tenant. Queries over the limit are rejected instead of
+// waiting so a tenant can't starve others of the query capacity of the ingester. The returned func must be called
+// once the query is done.
+func (i *instance) startQuery() (func(), error) {
+	limit := i.limiter.limits.MaxConcurrentQueriesPerTenant(i.instanceID)
+	if inFlight := i.queriesInFlight.Inc(); limit > 0 && int(inFlight) > limit {
+		i.queriesInFlight.Dec()
+		i.queriesRejected.Inc()
+		return nil, status.Errorf(codes.ResourceExhausted, "max concurrent queries per tenant (%d) exceeded", limit)
+	}
+	i.queriesGauge.Inc()
+
+	return func() {
+		i.queriesInFlight.Dec()
+		i.queriesGauge.Dec()
+	}, nil
+}
+
 func (i *instance) FindTraceByID(ctx context.Context, id []byte) (*tempopb.Trace, error) {
 	var err error
 	var allBytes []byte
@@ -372,7 +648,7 @@ func (i *instance) FindTraceByID(ctx context.Context, id []byte) (*tempopb.Trace
 	// live traces
 	i.tracesMtx.Lock()
 	if liveTrace, ok := i.traces[i.tokenForTraceID(id)]; ok {
-		allBytes, err = proto.Marshal(liveTrace.traceBytes)
+		allBytes, err = liveTrace.marshal()
 		if err != nil {
 			i.tracesMtx.Unlock()
 			return nil, fmt.Errorf("unable to marshal liveTrace: %w", err)
@@ -383,18 +659,30 @@ func (i *instance) FindTraceByID(ctx context.Context, id []byte) (*tempopb.Trace
 	i.blocksMtx.Lock()
 	defer i.blocksMtx.Unlock()
 
-	// headBlock
-	foundBytes, err := i.headBlock.Find(id, model.ObjectCombiner)
-	if err != nil {
-		return nil, fmt.Errorf("headBlock.Find failed: %w", err)
+	// deleted traces are skipped in the blocks they have not been dropped from yet
+	tombstoned := func(blockID uuid.UUID) bool {
+		drop := i.tombstonedIn(blockID)
+		return drop != nil && drop(id)
 	}
-	allBytes, _, err = model.CombineTraceBytes(allBytes, foundBytes, model.CurrentEncoding, i.headBlock.Meta().DataEncoding)
-	if err != nil {
-		return nil, fmt.Errorf("post headBlock combine failed: %w", err)
+
+	// headBlock
+	var foundBytes []byte
+	if !tombstoned(i.headBlock.BlockID()) {
+		foundBytes, err = i.headBlock.Find(id, model.ObjectCombiner)
+		if err != nil {
+			return nil, fmt.Errorf("headBlock.Find failed: %w", err)
+		}
+		allBytes, _, err = model.CombineTraceBytes(allBytes, foundBytes, model.CurrentEncoding, i.headBlock.Meta().DataEncoding)
+		if err != nil {
+			return nil, fmt.Errorf("post headBlock combine failed: %w", err)
+		}
 	}
 
 	// completingBlock
 	for _, c := range i.completingBlocks {
+		if tombstoned(c.BlockID()) {
+			continue
+		}
 		foundBytes, err = c.Find(id, model.ObjectCombiner)
 		if err != nil {
 			return nil, fmt.Errorf("completingBlock.Find failed: %w", err)
@@ -407,6 +695,9 @@ func (i *instance) FindTraceByID(ctx context.Context, id []byte) (*tempopb.Trace
 
 	// completeBlock
 	for _, c := range i.completeBlocks {
+		if tombstoned(c.BlockMeta().BlockID) {
+			continue
+		}
 		foundBytes, err = c.Find(ctx, id)
 		if err != nil {
 			return nil, fmt.Errorf("completeBlock.Find failed: %w", err)
@@ -440,23 +731,46 @@ func (i *instance) AddCompletingBlock(b *wal.AppendBlock) {
 	i.completingBlocks = append(i.completingBlocks, b)
 }
 
-// getOrCreateTrace will return a new trace object for the given request
+// AddCompletingSearchBlock adds the search data replayed from the wal for a block added with AddCompletingBlock.
+func (i *instance) AddCompletingSearchBlock(b *wal.AppendBlock, sb *search.StreamingSearchBlock) {
+	i.blocksMtx.Lock()
+	defer i.blocksMtx.Unlock()
+
+	i.searchAppendBlocks[b] = &searchStreamingBlockEntry{
+		b: sb,
+	}
+}
+
+// getOrCreateTrace will return a new trace object for the given request. No trace is created if rejectNewTraces is set
 //  It must be called under the i.tracesMtx lock
-func (i *instance) getOrCreateTrace(traceID []byte) *trace {
+func (i *instance) getOrCreateTrace(traceID []byte, rejectNewTraces bool) (*trace, error) {
 	fp := i.tokenForTraceID(traceID)
 	trace, ok := i.traces[fp]
 	if ok {
-		return trace
+		return trace, nil
+	}
+
+	// only the creation of new traces is limited. spans are still appended to existing traces
+	err := i.limiter.AssertMaxLiveTraces(i.instanceID, len(i.traces))
+	if err != nil {
+		return nil, overrides.NewLimitError(overrides.ErrLiveTracesExceeded, "max live traces per tenant exceeded: %v", err)
+	}
+	if rejectNewTraces {
+		return nil, overrides.NewLimitError(overrides.ErrLiveTracesExceeded, "max live traces per tenant exceeded: "+errMaxGlobalTracesLimitExceeded,
+			i.limiter.limits.MaxGlobalTracesPerUser(i.instanceID))
 	}
 
 	maxBytes := i.limiter.limits.MaxBytesPerTrace(i.instanceID)
 	maxSearchBytes := i.limiter.limits.MaxSearchBytesPerTrace(i.instanceID)
-	trace = newTrace(traceID, maxBytes, maxSearchBytes)
+	dedupeSpans := i.limiter.limits.DedupeSpans(i.instanceID)
+	// the id is kept by the wal for the life of the block. it's copied so a small id doesn't pin a pooled request slice
+	trace = newTrace(append([]byte(nil), traceID...), maxBytes, maxSearchBytes, dedupeSpans)
 	i.traces[fp] = trace
 	i.tracesCreatedTotal.Inc()
 	i.traceCount.Inc()
+	i.liveTraces.Set(float64(len(i.traces)))
 
-	return trace
+	return trace, nil
 }
 
 // tokenForTraceID hash trace ID, should be called under lock
@@ -479,7 +793,7 @@ func (i *instance) resetHeadBlock() error {
 	i.lastBlockCut = time.Now()
 
 	// Create search data wal file
-	f, err := i.writer.WAL().NewFile(i.headBlock.BlockID(), i.instanceID, searchDir, "searchdata")
+	f, err := i.writer.WAL().NewFile(i.headBlock.BlockID(), i.instanceID, searchDir, searchDataFile)
 	if err != nil {
 		return err
 	}
@@ -501,16 +815,24 @@ func (i *instance) tracesToCut(cutoff time.Duration, immediate bool) []*trace {
 	i.tracesMtx.Lock()
 	defer i.tracesMtx.Unlock()
 
-	cutoffTime := time.Now().Add(cutoff)
+	if idle := i.limiter.limits.TraceIdlePeriod(i.instanceID); idle > 0 {
+		cutoff = idle
+	}
+	maxLive := i.limiter.limits.MaxTraceLivePeriod(i.instanceID)
+
+	now := time.Now()
+	idleTime := now.Add(-cutoff)
+	liveTime := now.Add(-maxLive)
 	tracesToCut := make([]*trace, 0, len(i.traces))
 
 	for key, trace := range i.traces {
-		if cutoffTime.After(trace.lastAppend) || immediate {
+		if immediate || idleTime.After(trace.lastAppend) || (maxLive > 0 && liveTime.After(trace.created)) {
 			tracesToCut = append(tracesToCut, trace)
 			delete(i.traces, key)
 		}
 	}
 	i.traceCount.Store(int32(len(i.traces)))
+	i.liveTraces.Set(float64(len(i.traces)))
 
 	return tracesToCut
 }
@@ -585,7 +907,7 @@ func (i *instance) rediscoverLocalBlocks(ctx context.Context) error {
 			if err == backend.ErrDoesNotExist {
 				// Partial/incomplete block found, remove, it will be recreated from data in the wal.
 				level.Warn(log.Logger).Log("msg", "Unable to reload meta for local block. This indicates an incomplete block and will be deleted", "tenant", i.instanceID, "block", id.String())
-				err = i.local.ClearBlock(id, i.instanceID)
+				err = i.clearLocalBlock(id)
 				if err != nil {
 					return errors.Wrapf(err, "deleting bad local block tenant %v block %v", i.instanceID, id.String())
 				}
@@ -605,11 +927,22 @@ func (i *instance) rediscoverLocalBlocks(ctx context.Context) error {
 			return err
 		}
 
-		//sb := search.OpenBackendSearchBlock(i.local, b.BlockMeta().BlockID, b.BlockMeta().TenantID)
+		// Search data (optional) is complete if its meta, which is written last, exists.
+		var sb search.SearchableBlock
+		_, err = search.ReadSearchBlockMeta(ctx, i.local, id, i.instanceID)
+		if err == nil {
+			sb = search.OpenBackendSearchBlock(i.local, id, i.instanceID)
+		} else if err != backend.ErrDoesNotExist {
+			level.Warn(log.Logger).Log("msg", "unable to reload search data for local block", "tenant", i.instanceID, "block", id.String(), "err", err)
+		}
 
 		i.blocksMtx.Lock()
 		i.completeBlocks = append(i.completeBlocks, ib)
-		//i.searchCompleteBlocks[ib] = sb
+		if sb != nil {
+			i.searchCompleteBlocks[ib] = &searchLocalBlockEntry{
+				b: sb,
+			}
+		}
 		i.blocksMtx.Unlock()
 
 		level.Info(log.Logger).Log("msg", "reloaded local block", "tenantID", i.instanceID, "block", id.String(), "flushed", ib.FlushedTime())
@@ -8,6 +8,7 @@ import (
 
 	"github.com/grafana/tempo/pkg/tempofb"
 	"github.com/grafana/tempo/pkg/tempopb"
+	"github.com/grafana/tempo/pkg/util"
 	"github.com/grafana/tempo/tempodb/search"
 	"github.com/grafana/tempo/tempodb/wal"
 )
@@ -33,6 +34,11 @@ func (i *instance) Search(ctx context.Context, req *tempopb.SearchRequest) (*tem
 	resultsMap := map[string]*tempopb.TraceSearchMetadata{}
 
 	for result := range sr.Results() {
+		// deleted traces may still be found in the blocks they are dropped from
+		if id, err := util.HexStringToTraceID(result.TraceID); err == nil && i.tombstoned(id) {
+			continue
+		}
+
 		// Dedupe/combine results
 		if existing := resultsMap[result.TraceID]; existing != nil {
 			search.CombineSearchResults(existing, result)
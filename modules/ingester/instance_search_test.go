@@ -6,11 +6,14 @@ import (
 	"fmt"
 	"io/ioutil"
 	"math/rand"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/gorilla/mux"
 	"github.com/grafana/tempo/modules/overrides"
 	"github.com/grafana/tempo/pkg/model"
 	"github.com/grafana/tempo/pkg/tempofb"
@@ -20,6 +23,9 @@ import (
 	"github.com/grafana/tempo/tempodb/search"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func checkEqual(t *testing.T, ids [][]byte, sr *tempopb.SearchResponse) {
@@ -80,7 +86,7 @@ func TestInstanceSearch(t *testing.T) {
 		}
 
 		// searchData will be nil if not
-		err = i.PushBytes(context.Background(), id, traceBytes, searchData)
+		err = i.PushBytes(context.Background(), id, traceBytes, searchData, 0, false)
 		require.NoError(t, err)
 
 		assert.Equal(t, int(i.traceCount.Load()), len(i.traces))
@@ -135,10 +141,98 @@ func TestInstanceSearch(t *testing.T) {
 	i, ok := ingester.getInstanceByID("fake")
 	assert.True(t, ok)
 
+	// search data is replayed with the wal
+	for !ingester.flushQueues.IsEmpty() {
+		time.Sleep(100 * time.Millisecond)
+	}
+
 	sr, err = i.Search(context.Background(), req)
 	assert.NoError(t, err)
-	// note: search is experimental and removed on every startup. Verify no search results now
-	assert.Len(t, sr.Traces, 0)
+	assert.Len(t, sr.Traces, numTraces/searchAnnotatedFractionDenominator)
+	checkEqual(t, ids, sr)
+	assert.Equal(t, []string{tagValue}, i.GetSearchTagValues(tagKey))
+}
+
+func TestInstanceSearchAfterRestart(t *testing.T) {
+	tempDir := t.TempDir()
+
+	ingester, _, _ := defaultIngester(t, tempDir)
+	i, err := ingester.getOrCreateInstance("fake")
+	require.NoError(t, err)
+
+	push := func(tagValue string) [][]byte {
+		ids := [][]byte{}
+		for j := 0; j < 10; j++ {
+			id := make([]byte, 16)
+			rand.Read(id)
+
+			traceBytes, err := test.MakeTrace(10, id).Marshal()
+			require.NoError(t, err)
+
+			data := &tempofb.SearchEntryMutable{}
+			data.TraceID = id
+			data.AddTag("foo", tagValue)
+
+			err = i.PushBytes(context.Background(), id, traceBytes, data.ToBytes(), 0, false)
+			require.NoError(t, err)
+			ids = append(ids, id)
+		}
+
+		err := i.CutCompleteTraces(0, true)
+		require.NoError(t, err)
+		return ids
+	}
+
+	search := func(i *instance, tagValue string) *tempopb.SearchResponse {
+		sr, err := i.Search(context.Background(), &tempopb.SearchRequest{
+			Tags: map[string]string{"foo": tagValue},
+		})
+		require.NoError(t, err)
+		return sr
+	}
+
+	// local block
+	localIDs := push("local")
+	blockID, err := i.CutBlockIfReady(0, 0, true)
+	require.NoError(t, err)
+	require.NoError(t, i.CompleteBlock(blockID))
+	require.NoError(t, i.ClearCompletingBlock(blockID))
+
+	// wal block
+	walIDs := push("wal")
+	_, err = i.CutBlockIfReady(0, 0, true)
+	require.NoError(t, err)
+
+	// head block
+	headIDs := push("head")
+
+	err = ingester.stopping(nil)
+	require.NoError(t, err)
+
+	// restart
+	ingester, _, _ = defaultIngester(t, tempDir)
+	i, ok := ingester.getInstanceByID("fake")
+	require.True(t, ok)
+
+	// the replayed wal blocks are completed with their search data
+	for !ingester.flushQueues.IsEmpty() {
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	sr := search(i, "local")
+	assert.Len(t, sr.Traces, len(localIDs))
+	checkEqual(t, localIDs, sr)
+
+	sr = search(i, "wal")
+	assert.Len(t, sr.Traces, len(walIDs))
+	checkEqual(t, walIDs, sr)
+
+	sr = search(i, "head")
+	assert.Len(t, sr.Traces, len(headIDs))
+	checkEqual(t, headIDs, sr)
+
+	// the tags are rebuilt from the wal blocks only
+	assert.Equal(t, []string{"head", "wal"}, i.GetSearchTagValues("foo"))
 }
 
 func TestInstanceSearchNoData(t *testing.T) {
@@ -207,7 +301,7 @@ func TestInstanceSearchDoesNotRace(t *testing.T) {
 		searchBytes := searchData.ToBytes()
 
 		// searchData will be nil if not
-		err = i.PushBytes(context.Background(), id, traceBytes, searchBytes)
+		err = i.PushBytes(context.Background(), id, traceBytes, searchBytes, 0, false)
 		require.NoError(t, err)
 	})
 
@@ -296,7 +390,7 @@ func TestWALBlockDeletedDuringSearch(t *testing.T) {
 		entry.AddTag("foo", "bar")
 		searchBytes := entry.ToBytes()
 
-		err = i.PushBytes(context.Background(), id, traceBytes, searchBytes)
+		err = i.PushBytes(context.Background(), id, traceBytes, searchBytes, 0, false)
 		require.NoError(t, err)
 	}
 
@@ -350,7 +444,7 @@ func TestInstanceSearchMetrics(t *testing.T) {
 
 		numBytes += uint64(len(searchData))
 
-		err = i.PushBytes(context.Background(), id, traceBytes, searchData)
+		err = i.PushBytes(context.Background(), id, traceBytes, searchData, 0, false)
 		require.NoError(t, err)
 
 		assert.Equal(t, int(i.traceCount.Load()), len(i.traces))
@@ -432,7 +526,7 @@ func BenchmarkInstanceSearchUnderLoad(b *testing.B) {
 		searchBytes := searchData.ToBytes()
 
 		// searchData will be nil if not
-		err = i.PushBytes(context.Background(), id, traceBytes, searchBytes)
+		err = i.PushBytes(context.Background(), id, traceBytes, searchBytes, 0, false)
 		require.NoError(b, err)
 	})
 
@@ -472,3 +566,93 @@ func BenchmarkInstanceSearchUnderLoad(b *testing.B) {
 	// exiting and cleaning up
 	time.Sleep(1 * time.Second)
 }
+
+func TestIngesterSearchTagsHandler(t *testing.T) {
+	cfg := defaultIngesterTestConfig()
+	cfg.SearchTagsMaxResults = 2
+	ingester, _, _ := defaultIngesterWithConfig(t, t.TempDir(), cfg)
+
+	inst, err := ingester.getOrCreateInstance("test")
+	require.NoError(t, err)
+
+	data := &tempofb.SearchEntryMutable{}
+	data.AddTag("service.name", "foo")
+	data.AddTag("service.name", "bar")
+	data.AddTag("service.name", "baz")
+	data.AddTag("http.method", "GET")
+	inst.RecordSearchLookupValues(data.ToBytes())
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/search/tags", ingester.SearchTagsHandler)
+	router.HandleFunc("/api/search/tag/{tagName}/values", ingester.SearchTagValuesHandler)
+
+	tests := []struct {
+		url      string
+		expected string
+	}{
+		{
+			url:      "/api/search/tags",
+			expected: `{"tagNames":["http.method","service.name"]}`,
+		},
+		{
+			url:      "/api/search/tag/service.name/values",
+			expected: `{"tagValues":["bar","baz"]}`,
+		},
+		{
+			url:      "/api/search/tag/unknown/values",
+			expected: `{}`,
+		},
+	}
+
+	for _, tc := range tests {
+		req := httptest.NewRequest(http.MethodGet, tc.url, nil)
+		req = req.WithContext(user.InjectOrgID(req.Context(), "test"))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code, tc.url)
+		assert.JSONEq(t, tc.expected, w.Body.String(), tc.url)
+	}
+}
+
+func TestIngesterSearchTagsMaxConcurrentQueries(t *testing.T) {
+	ingester, _, _ := defaultIngester(t, t.TempDir())
+
+	inst, err := ingester.getOrCreateInstance("test")
+	require.NoError(t, err)
+
+	limits, err := overrides.NewOverrides(overrides.Limits{
+		MaxConcurrentQueriesPerTenant: 1,
+	})
+	require.NoError(t, err)
+	inst.limiter = NewLimiter(limits, &ringCountMock{count: 1}, 1)
+
+	done, err := inst.startQuery()
+	require.NoError(t, err)
+
+	ctx := user.InjectOrgID(context.Background(), "test")
+	_, err = ingester.SearchTags(ctx, &tempopb.SearchTagsRequest{})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	_, err = ingester.SearchTagValues(ctx, &tempopb.SearchTagValuesRequest{TagName: "service.name"})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/search/tags", ingester.SearchTagsHandler)
+	router.HandleFunc("/api/search/tag/{tagName}/values", ingester.SearchTagValuesHandler)
+	for _, url := range []string{"/api/search/tags", "/api/search/tag/service.name/values"} {
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		req = req.WithContext(user.InjectOrgID(req.Context(), "test"))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusTooManyRequests, w.Code, url)
+	}
+
+	// the tag lookups release their queries
+	done()
+	_, err = ingester.SearchTags(ctx, &tempopb.SearchTagsRequest{})
+	require.NoError(t, err)
+	_, err = ingester.SearchTagValues(ctx, &tempopb.SearchTagValuesRequest{TagName: "service.name"})
+	require.NoError(t, err)
+	assert.Equal(t, int32(0), inst.queriesInFlight.Load())
+}
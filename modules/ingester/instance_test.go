@@ -1,18 +1,27 @@
 package ingester
 
 import (
+	"bytes"
 	"context"
 	"encoding/binary"
+	"encoding/hex"
+	"fmt"
 	"io/ioutil"
 	"math/rand"
 	"os"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/go-kit/kit/log"
+	"github.com/gogo/protobuf/proto"
+	"github.com/gogo/status"
+	"github.com/golang/protobuf/jsonpb"
 	"github.com/google/uuid"
+	prom_model "github.com/prometheus/common/model"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
 
 	"github.com/grafana/tempo/modules/overrides"
 	"github.com/grafana/tempo/modules/storage"
@@ -110,7 +119,7 @@ func TestInstanceFind(t *testing.T) {
 		traceBytes, err := trace.Marshal()
 		require.NoError(t, err)
 
-		err = i.PushBytes(context.Background(), id, traceBytes, nil)
+		err = i.PushBytes(context.Background(), id, traceBytes, nil, 0, false)
 		require.NoError(t, err)
 		assert.Equal(t, int(i.traceCount.Load()), len(i.traces))
 
@@ -128,7 +137,7 @@ func TestInstanceFind(t *testing.T) {
 		traceBytes, err := traces[j].Marshal()
 		require.NoError(t, err)
 
-		err = i.PushBytes(context.Background(), ids[j], traceBytes, nil)
+		err = i.PushBytes(context.Background(), ids[j], traceBytes, nil, 0, false)
 		require.NoError(t, err)
 	}
 
@@ -334,6 +343,169 @@ func TestInstanceLimits(t *testing.T) {
 	}
 }
 
+func TestInstanceMaxLiveTraces(t *testing.T) {
+	maxLiveTraces := 10
+	limits, err := overrides.NewOverrides(overrides.Limits{
+		MaxLiveTraces: maxLiveTraces,
+	})
+	require.NoError(t, err, "unexpected error creating limits")
+	limiter := NewLimiter(limits, &ringCountMock{count: 1}, 1)
+
+	tempDir, err := ioutil.TempDir("/tmp", "")
+	require.NoError(t, err, "unexpected error getting temp dir")
+	defer os.RemoveAll(tempDir)
+
+	ingester, _, _ := defaultIngester(t, tempDir)
+	i, err := newInstance("fake", limiter, ingester.store, ingester.local)
+	require.NoError(t, err, "unexpected error creating new instance")
+
+	pushes := 50
+	ids := make([][]byte, pushes)
+	errs := make([]error, pushes)
+	wg := sync.WaitGroup{}
+	for j := 0; j < pushes; j++ {
+		ids[j] = make([]byte, 16)
+		rand.Read(ids[j])
+
+		traceBytes, err := test.MakeTrace(1, ids[j]).Marshal()
+		require.NoError(t, err)
+
+		wg.Add(1)
+		go func(j int) {
+			defer wg.Done()
+			errs[j] = i.PushBytes(context.Background(), ids[j], traceBytes, nil, 0, false)
+		}(j)
+	}
+	wg.Wait()
+
+	var accepted [][]byte
+	for j, err := range errs {
+		if err == nil {
+			accepted = append(accepted, ids[j])
+			continue
+		}
+		assert.Contains(t, err.Error(), overrides.ErrorPrefixLiveTracesExceeded)
+	}
+	assert.Len(t, accepted, maxLiveTraces)
+	assert.Len(t, i.traces, maxLiveTraces)
+
+	// pushes to existing traces are still accepted
+	for _, id := range accepted {
+		traceBytes, err := test.MakeTrace(1, id).Marshal()
+		require.NoError(t, err)
+		assert.NoError(t, i.PushBytes(context.Background(), id, traceBytes, nil, 0, false))
+	}
+	assert.Len(t, i.traces, maxLiveTraces)
+}
+
+func TestInstanceMaxConcurrentQueries(t *testing.T) {
+	limits, err := overrides.NewOverrides(overrides.Limits{
+		MaxConcurrentQueriesPerTenant: 2,
+	})
+	require.NoError(t, err, "unexpected error creating limits")
+	limiter := NewLimiter(limits, &ringCountMock{count: 1}, 1)
+
+	tempDir, err := ioutil.TempDir("/tmp", "")
+	require.NoError(t, err, "unexpected error getting temp dir")
+	defer os.RemoveAll(tempDir)
+
+	ingester, _, _ := defaultIngester(t, tempDir)
+	i, err := newInstance("concurrent-queries", limiter, ingester.store, ingester.local)
+	require.NoError(t, err, "unexpected error creating new instance")
+
+	doneA, err := i.startQuery()
+	require.NoError(t, err)
+	doneB, err := i.startQuery()
+	require.NoError(t, err)
+
+	inFlight, err := test.GetGaugeValue(metricQueriesInFlight.WithLabelValues("concurrent-queries"))
+	require.NoError(t, err)
+	assert.Equal(t, 2.0, inFlight)
+
+	// queries over the limit are rejected
+	_, err = i.startQuery()
+	require.Error(t, err)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	rejected, err := test.GetCounterValue(metricQueriesRejectedTotal.WithLabelValues("concurrent-queries"))
+	require.NoError(t, err)
+	assert.Equal(t, 1.0, rejected)
+
+	// and accepted again once a query is done
+	doneA()
+	doneC, err := i.startQuery()
+	require.NoError(t, err)
+
+	doneB()
+	doneC()
+	inFlight, err = test.GetGaugeValue(metricQueriesInFlight.WithLabelValues("concurrent-queries"))
+	require.NoError(t, err)
+	assert.Equal(t, 0.0, inFlight)
+}
+
+func TestInstanceMaxBytesPerTrace(t *testing.T) {
+	id := make([]byte, 16)
+	rand.Read(id)
+
+	batches := make([][]byte, 0, 3)
+	for j := 0; j < 3; j++ {
+		traceBytes, err := test.MakeTrace(1, id).Marshal()
+		require.NoError(t, err)
+		batches = append(batches, traceBytes)
+	}
+
+	limits, err := overrides.NewOverrides(overrides.Limits{
+		MaxBytesPerTrace: len(batches[0]) + len(batches[1]),
+	})
+	require.NoError(t, err, "unexpected error creating limits")
+	limiter := NewLimiter(limits, &ringCountMock{count: 1}, 1)
+
+	tempDir := t.TempDir()
+	ingester, _, _ := defaultIngester(t, tempDir)
+	i, err := newInstance("fake", limiter, ingester.store, ingester.local)
+	require.NoError(t, err, "unexpected error creating new instance")
+
+	require.NoError(t, i.PushBytes(context.Background(), id, batches[0], nil, 0, false))
+	require.NoError(t, i.PushBytes(context.Background(), id, batches[1], nil, 0, false))
+
+	// the overflow is rejected
+	err = i.PushBytes(context.Background(), id, batches[2], nil, 0, false)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), overrides.ErrorPrefixTraceTooLarge)
+	assert.Contains(t, err.Error(), hex.EncodeToString(id))
+	assert.Contains(t, err.Error(), fmt.Sprintf("current size %d", len(batches[0])+len(batches[1])))
+
+	// spans that were already accepted remain queryable
+	expected := &tempopb.Trace{}
+	for _, b := range batches[:2] {
+		tr := &tempopb.Trace{}
+		require.NoError(t, proto.Unmarshal(b, tr))
+		expected.Batches = append(expected.Batches, tr.Batches...)
+	}
+
+	trace, err := i.FindTraceByID(context.Background(), id)
+	require.NoError(t, err)
+	require.NotNil(t, trace)
+	assert.Equal(t, traceSpanCount(expected), traceSpanCount(trace))
+
+	// and after they are cut to the head block
+	require.NoError(t, i.CutCompleteTraces(0, true))
+	trace, err = i.FindTraceByID(context.Background(), id)
+	require.NoError(t, err)
+	require.NotNil(t, trace)
+	assert.Equal(t, traceSpanCount(expected), traceSpanCount(trace))
+}
+
+func traceSpanCount(tr *tempopb.Trace) int {
+	count := 0
+	for _, b := range tr.Batches {
+		for _, ils := range b.InstrumentationLibrarySpans {
+			count += len(ils.Spans)
+		}
+	}
+	return count
+}
+
 func TestInstanceCutCompleteTraces(t *testing.T) {
 	tempDir, _ := ioutil.TempDir("/tmp", "")
 	defer os.RemoveAll(tempDir)
@@ -384,11 +556,19 @@ func TestInstanceCutCompleteTraces(t *testing.T) {
 			expectedNotExist: []*trace{pastTrace},
 		},
 		{
-			name:             "cut all time",
-			cutoff:           2 * time.Hour,
+			name:             "cut idle",
+			cutoff:           30 * time.Minute,
 			immediate:        false,
 			input:            []*trace{pastTrace, nowTrace},
-			expectedNotExist: []*trace{pastTrace, nowTrace},
+			expectedExist:    []*trace{nowTrace},
+			expectedNotExist: []*trace{pastTrace},
+		},
+		{
+			name:          "cut none",
+			cutoff:        2 * time.Hour,
+			immediate:     false,
+			input:         []*trace{pastTrace, nowTrace},
+			expectedExist: []*trace{pastTrace, nowTrace},
 		},
 	}
 
@@ -418,6 +598,71 @@ func TestInstanceCutCompleteTraces(t *testing.T) {
 	}
 }
 
+func TestInstanceCutCompleteTracesOverrides(t *testing.T) {
+	tempDir := t.TempDir()
+
+	newTestTrace := func(created, lastAppend time.Time) *trace {
+		id := make([]byte, 16)
+		rand.Read(id)
+		// trace bytes are returned to the byte pool when cut so they must not be allocated outside of it
+		return &trace{
+			traceID:    id,
+			traceBytes: &tempopb.TraceBytes{},
+			created:    created,
+			lastAppend: lastAppend,
+		}
+	}
+
+	now := time.Now()
+	idleTrace := newTestTrace(now.Add(-10*time.Minute), now.Add(-5*time.Minute))
+	longLivedTrace := newTestTrace(now.Add(-2*time.Hour), now)
+	activeTrace := newTestTrace(now.Add(-time.Minute), now)
+
+	tt := []struct {
+		name             string
+		limits           overrides.Limits
+		expectedNotExist []*trace
+	}{
+		{
+			name:             "ingester default",
+			expectedNotExist: []*trace{idleTrace},
+		},
+		{
+			name:   "tenant idle period",
+			limits: overrides.Limits{TraceIdlePeriod: prom_model.Duration(10 * time.Minute)},
+		},
+		{
+			name:             "tenant max live period",
+			limits:           overrides.Limits{MaxTraceLivePeriod: prom_model.Duration(30 * time.Minute)},
+			expectedNotExist: []*trace{idleTrace, longLivedTrace},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			instance := defaultInstance(t, tempDir)
+
+			limits, err := overrides.NewOverrides(tc.limits)
+			require.NoError(t, err)
+			instance.limiter = NewLimiter(limits, &ringCountMock{count: 1}, 1)
+
+			all := []*trace{idleTrace, longLivedTrace, activeTrace}
+			for _, trace := range all {
+				instance.traces[instance.tokenForTraceID(trace.traceID)] = trace
+			}
+
+			err = instance.CutCompleteTraces(time.Minute, false)
+			require.NoError(t, err)
+
+			assert.Len(t, instance.traces, len(all)-len(tc.expectedNotExist))
+			for _, trace := range tc.expectedNotExist {
+				_, ok := instance.traces[instance.tokenForTraceID(trace.traceID)]
+				assert.False(t, ok)
+			}
+		})
+	}
+}
+
 func TestInstanceCutBlockIfReady(t *testing.T) {
 	tempDir, _ := ioutil.TempDir("/tmp", "")
 	defer os.RemoveAll(tempDir)
@@ -587,3 +832,56 @@ func BenchmarkInstanceFindTraceByID(b *testing.B) {
 		assert.NoError(b, err)
 	}
 }
+
+// TestInstancePreservesAllFields pushes a trace that sets every supported OTLP field and asserts it
+// is returned unchanged from live traces, the head block and a complete block, and after marshalling
+// to json as the query frontend does.
+func TestInstancePreservesAllFields(t *testing.T) {
+	limits, err := overrides.NewOverrides(overrides.Limits{})
+	require.NoError(t, err, "unexpected error creating limits")
+	limiter := NewLimiter(limits, &ringCountMock{count: 1}, 1)
+
+	ingester, _, _ := defaultIngester(t, t.TempDir())
+	i, err := newInstance("fake", limiter, ingester.store, ingester.local)
+	require.NoError(t, err, "unexpected error creating new instance")
+
+	id := make([]byte, 16)
+	rand.Read(id)
+	expected := test.MakeTraceWithAllFields(id)
+
+	traceBytes, err := expected.Marshal()
+	require.NoError(t, err)
+	err = i.PushBytes(context.Background(), id, traceBytes, nil, 0, false)
+	require.NoError(t, err)
+
+	assertFound := func(stage string) {
+		actual, err := i.FindTraceByID(context.Background(), id)
+		require.NoError(t, err)
+		require.NotNil(t, actual, stage)
+		assert.Truef(t, proto.Equal(expected, actual), "trace differs after %s", stage)
+	}
+
+	assertFound("push")
+
+	err = i.CutCompleteTraces(0, true)
+	require.NoError(t, err)
+	assertFound("cutting to the head block")
+
+	blockID, err := i.CutBlockIfReady(0, 0, true)
+	require.NoError(t, err)
+	err = i.CompleteBlock(blockID)
+	require.NoError(t, err)
+	err = i.ClearCompletingBlock(blockID)
+	require.NoError(t, err)
+	assertFound("completing the block")
+
+	actual, err := i.FindTraceByID(context.Background(), id)
+	require.NoError(t, err)
+	var buffer bytes.Buffer
+	err = (&jsonpb.Marshaler{}).Marshal(&buffer, actual)
+	require.NoError(t, err)
+	fromJSON := &tempopb.Trace{}
+	err = jsonpb.Unmarshal(&buffer, fromJSON)
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(expected, fromJSON), "trace differs after json round trip")
+}
@@ -9,6 +9,8 @@ import (
 
 const (
 	errMaxTracesPerUserLimitExceeded = "per-user traces limit (local: %d global: %d actual local: %d) exceeded"
+	errMaxLiveTracesLimitExceeded    = "per-user live traces limit (%d) exceeded"
+	errMaxGlobalTracesLimitExceeded  = "global live traces limit (%d) exceeded"
 )
 
 // RingCount is the interface exposed by a ring implementation which allows
@@ -48,13 +50,27 @@ func (l *Limiter) AssertMaxTracesPerUser(userID string, traces int) error {
 	return fmt.Errorf(errMaxTracesPerUserLimitExceeded, localLimit, globalLimit, actualLimit)
 }
 
+// AssertMaxLiveTraces ensures a new trace can be created given the current number of live traces
+// and returns an error if not.
+func (l *Limiter) AssertMaxLiveTraces(userID string, traces int) error {
+	limit := l.limits.MaxLiveTraces(userID)
+	if limit == 0 || traces < limit {
+		return nil
+	}
+
+	return fmt.Errorf(errMaxLiveTracesLimitExceeded, limit)
+}
+
 func (l *Limiter) maxTracesPerUser(userID string) int {
 	localLimit := l.limits.MaxLocalTracesPerUser(userID)
 
 	// We can assume that traces are evenly distributed across ingesters
-	// so we do convert the global limit into a local limit
-	globalLimit := l.limits.MaxGlobalTracesPerUser(userID)
-	localLimit = l.minNonZero(localLimit, l.convertGlobalToLocalLimit(globalLimit))
+	// so we do convert the global limit into a local limit. With the global
+	// strategy new traces are rejected when the distributor flags the push instead.
+	if l.limits.LiveTracesStrategy() != overrides.GlobalLiveTracesStrategy {
+		globalLimit := l.limits.MaxGlobalTracesPerUser(userID)
+		localLimit = l.minNonZero(localLimit, l.convertGlobalToLocalLimit(globalLimit))
+	}
 
 	// If both the local and global limits are disabled, we just
 	// use the largest int value
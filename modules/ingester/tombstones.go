@@ -0,0 +1,372 @@
+package ingester
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+
+	"github.com/cortexproject/cortex/pkg/util/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/gogo/protobuf/proto"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/grafana/tempo/pkg/model"
+	"github.com/grafana/tempo/pkg/tempopb"
+	"github.com/grafana/tempo/tempodb"
+	"github.com/grafana/tempo/tempodb/backend"
+	"github.com/grafana/tempo/tempodb/encoding/common"
+	"github.com/grafana/tempo/tempodb/wal"
+)
+
+const (
+	tombstonesDir  = "tombstones"
+	tombstonesFile = "tombstones"
+)
+
+var (
+	metricDeletedSpansTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "ingester_deleted_spans_total",
+		Help:      "The total number of spans removed by trace deletion requests per tenant.",
+	}, []string{"tenant"})
+	metricTombstonedBlocksRewrittenTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "ingester_tombstoned_blocks_rewritten_total",
+		Help:      "The total number of complete blocks rewritten without deleted traces when they were flushed.",
+	})
+)
+
+// DeleteTrace removes the trace from the live traces and records a tombstone for the blocks that hold it, which drops
+// the trace when they are completed and flushed and hides it from queries until they are cleared. The tombstones are
+// written to the wal and replayed with it. Blocks that have already been flushed are not changed. Deleting a trace
+// again removes the spans received since from the live traces and the blocks not tombstoned yet. It returns the number
+// of spans removed.
+func (i *instance) DeleteTrace(id []byte) (uint64, error) {
+	i.blocksMtx.Lock()
+	defer i.blocksMtx.Unlock()
+
+	removed, err := i.deleteLiveTrace(id)
+	if err != nil {
+		return 0, err
+	}
+
+	i.tombstonesMtx.Lock()
+	defer i.tombstonesMtx.Unlock()
+
+	// blocks tombstoned by a previous deletion already drop the trace
+	key := string(id)
+	tombstoned := i.tombstones[key]
+	blocks := map[uuid.UUID]struct{}{}
+
+	// the head block is always tombstoned as a trace being cut may be appended to it after it left the live traces
+	if _, ok := tombstoned[i.headBlock.BlockID()]; !ok {
+		found, err := i.headBlock.Find(id, model.ObjectCombiner)
+		if err != nil {
+			return 0, err
+		}
+		spans, err := countObjectSpans(found, i.headBlock.Meta().DataEncoding)
+		if err != nil {
+			return 0, err
+		}
+		removed += spans
+		blocks[i.headBlock.BlockID()] = struct{}{}
+	}
+
+	for _, b := range i.completingBlocks {
+		if _, ok := tombstoned[b.BlockID()]; ok {
+			continue
+		}
+		found, err := b.Find(id, model.ObjectCombiner)
+		if err != nil {
+			return 0, err
+		}
+		if found == nil {
+			continue
+		}
+		spans, err := countObjectSpans(found, b.Meta().DataEncoding)
+		if err != nil {
+			return 0, err
+		}
+		removed += spans
+		blocks[b.BlockID()] = struct{}{}
+	}
+
+	for _, b := range i.completeBlocks {
+		if !b.FlushedTime().IsZero() {
+			continue
+		}
+		if _, ok := tombstoned[b.BlockMeta().BlockID]; ok {
+			continue
+		}
+		found, err := b.Find(context.Background(), id)
+		if err != nil {
+			return 0, err
+		}
+		if found == nil {
+			continue
+		}
+		spans, err := countObjectSpans(found, b.BlockMeta().DataEncoding)
+		if err != nil {
+			return 0, err
+		}
+		removed += spans
+		blocks[b.BlockMeta().BlockID] = struct{}{}
+	}
+
+	err = i.writeTombstones(id, blocks)
+	if err != nil {
+		return 0, err
+	}
+
+	if tombstoned == nil {
+		i.tombstones[key] = blocks
+		return removed, nil
+	}
+	for blockID := range blocks {
+		tombstoned[blockID] = struct{}{}
+	}
+	return removed, nil
+}
+
+// writeTombstones appends the trace id to the tombstones file of each block in the wal.
+func (i *instance) writeTombstones(id []byte, blocks map[uuid.UUID]struct{}) error {
+	for blockID := range blocks {
+		f, err := i.writer.WAL().NewFile(blockID, i.instanceID, tombstonesDir, tombstonesFile)
+		if err != nil {
+			return errors.Wrap(err, "error opening tombstones file")
+		}
+
+		_, err = f.Seek(0, io.SeekEnd)
+		if err == nil {
+			_, err = fmt.Fprintln(f, hex.EncodeToString(id))
+		}
+		if err == nil {
+			err = f.Sync()
+		}
+		closeErr := f.Close()
+		if err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return errors.Wrap(err, "error writing tombstones file")
+		}
+	}
+
+	return nil
+}
+
+// addTombstones records the tombstones of a block replayed from the wal.
+func (i *instance) addTombstones(blockID uuid.UUID, ids [][]byte) {
+	i.tombstonesMtx.Lock()
+	defer i.tombstonesMtx.Unlock()
+
+	for _, id := range ids {
+		key := string(id)
+		blocks, ok := i.tombstones[key]
+		if !ok {
+			blocks = map[uuid.UUID]struct{}{}
+			i.tombstones[key] = blocks
+		}
+		blocks[blockID] = struct{}{}
+	}
+}
+
+// replayTombstones restores the tombstones of every tenant from the wal. It runs before the replayed wal blocks are
+// completed so they are completed without the deleted traces.
+func (i *Ingester) replayTombstones() error {
+	files, err := i.store.WAL().Files(tombstonesDir)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		if f.Name != tombstonesFile {
+			continue
+		}
+
+		ids, err := i.readTombstones(f)
+		if err != nil {
+			return err
+		}
+
+		inst, err := i.getOrCreateInstance(f.TenantID)
+		if err != nil {
+			return err
+		}
+		inst.addTombstones(f.BlockID, ids)
+	}
+
+	return nil
+}
+
+// readTombstones returns the trace ids of a tombstones file. A line that can't be decoded, i.e. a write cut short by
+// a crash, is skipped.
+func (i *Ingester) readTombstones(f wal.File) ([][]byte, error) {
+	file, err := i.store.WAL().NewFile(f.BlockID, f.TenantID, tombstonesDir, f.Name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var ids [][]byte
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		id, err := hex.DecodeString(scanner.Text())
+		if err != nil || len(id) == 0 {
+			level.Warn(log.Logger).Log("msg", "skipping invalid tombstone", "file", file.Name(), "err", err)
+			continue
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, scanner.Err()
+}
+
+// deleteLiveTrace removes the trace from the live traces and returns the number of its spans.
+func (i *instance) deleteLiveTrace(id []byte) (uint64, error) {
+	i.tracesMtx.Lock()
+	defer i.tracesMtx.Unlock()
+
+	token := i.tokenForTraceID(id)
+	t, ok := i.traces[token]
+	if !ok || !bytes.Equal(t.traceID, id) {
+		return 0, nil
+	}
+
+	var spans uint64
+	for _, b := range t.traceBytes.Traces {
+		tr := &tempopb.Trace{}
+		err := proto.Unmarshal(b, tr)
+		if err != nil {
+			return 0, err
+		}
+		spans += uint64(countSpans(tr))
+	}
+
+	delete(i.traces, token)
+	i.traceCount.Store(int32(len(i.traces)))
+	i.liveTraces.Set(float64(len(i.traces)))
+	tempopb.ReuseTraceBytes(t.traceBytes)
+
+	return spans, nil
+}
+
+// tombstonedIn returns a func that returns true for the traces deleted from the block, nil if none were.
+func (i *instance) tombstonedIn(blockID uuid.UUID) func(common.ID) bool {
+	i.tombstonesMtx.Lock()
+	defer i.tombstonesMtx.Unlock()
+
+	found := false
+	for _, blocks := range i.tombstones {
+		if _, ok := blocks[blockID]; ok {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil
+	}
+
+	return func(id common.ID) bool {
+		i.tombstonesMtx.Lock()
+		defer i.tombstonesMtx.Unlock()
+
+		_, ok := i.tombstones[string(id)][blockID]
+		return ok
+	}
+}
+
+// tombstoned returns true if the trace has been deleted from any block of the instance.
+func (i *instance) tombstoned(id []byte) bool {
+	i.tombstonesMtx.Lock()
+	defer i.tombstonesMtx.Unlock()
+
+	_, ok := i.tombstones[string(id)]
+	return ok
+}
+
+// PruneTombstones removes the tombstones of blocks that have been cleared, along with their files in the wal.
+func (i *instance) PruneTombstones() {
+	i.blocksMtx.RLock()
+	defer i.blocksMtx.RUnlock()
+
+	current := map[uuid.UUID]struct{}{
+		i.headBlock.BlockID(): {},
+	}
+	for _, b := range i.completingBlocks {
+		current[b.BlockID()] = struct{}{}
+	}
+	for _, b := range i.completeBlocks {
+		current[b.BlockMeta().BlockID] = struct{}{}
+	}
+
+	i.tombstonesMtx.Lock()
+	defer i.tombstonesMtx.Unlock()
+
+	cleared := map[uuid.UUID]struct{}{}
+	for key, blocks := range i.tombstones {
+		for blockID := range blocks {
+			if _, ok := current[blockID]; !ok {
+				delete(blocks, blockID)
+				cleared[blockID] = struct{}{}
+			}
+		}
+		if len(blocks) == 0 {
+			delete(i.tombstones, key)
+		}
+	}
+
+	for blockID := range cleared {
+		err := i.writer.WAL().RemoveFile(blockID, i.instanceID, tombstonesDir, tombstonesFile)
+		if err != nil {
+			level.Warn(log.Logger).Log("msg", "failed to remove tombstones file", "block", blockID, "tenant", i.instanceID, "err", err)
+		}
+	}
+}
+
+// countObjectSpans returns the number of spans in a trace object, 0 if it is nil.
+func countObjectSpans(obj []byte, dataEncoding string) (uint64, error) {
+	if obj == nil {
+		return 0, nil
+	}
+
+	tr, err := model.Unmarshal(obj, dataEncoding)
+	if err != nil {
+		return 0, err
+	}
+	return uint64(countSpans(tr)), nil
+}
+
+// countSpans returns the number of spans in the trace.
+func countSpans(tr *tempopb.Trace) int {
+	count := 0
+	for _, b := range tr.Batches {
+		for _, ils := range b.InstrumentationLibrarySpans {
+			count += len(ils.Spans)
+		}
+	}
+	return count
+}
+
+// tombstonedBlock flushes a complete block without the traces deleted after it was completed.
+type tombstonedBlock struct {
+	*wal.LocalBlock
+	writer tempodb.Writer
+	drop   func(common.ID) bool
+}
+
+func (b *tombstonedBlock) Write(ctx context.Context, w backend.Writer) error {
+	_, err := b.writer.RewriteBlockWithBackend(ctx, &b.LocalBlock.BackendBlock, w, b.drop)
+	if err != nil {
+		return errors.Wrap(err, "error rewriting block without deleted traces to remote backend")
+	}
+	metricTombstonedBlocksRewrittenTotal.Inc()
+
+	return b.LocalBlock.SetFlushed(ctx)
+}
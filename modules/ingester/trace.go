@@ -7,12 +7,15 @@ import (
 
 	cortex_util "github.com/cortexproject/cortex/pkg/util/log"
 	"github.com/go-kit/kit/log/level"
+	"github.com/gogo/protobuf/proto"
 	"github.com/gogo/status"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/segmentio/fasthash/fnv1a"
 	"google.golang.org/grpc/codes"
 
 	"github.com/grafana/tempo/modules/overrides"
+	"github.com/grafana/tempo/pkg/model"
 	"github.com/grafana/tempo/pkg/tempopb"
 )
 
@@ -22,10 +25,20 @@ var (
 		Name:      "ingester_trace_search_bytes_discarded_total",
 		Help:      "The total number of trace search bytes discarded per tenant.",
 	}, []string{"tenant"})
+	metricDuplicateSpansDroppedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "ingester_duplicate_spans_dropped_total",
+		Help:      "The total number of spans dropped because the same span was already appended to the live trace.",
+	}, []string{"tenant"})
 )
 
+// maxDedupeSpans bounds the number of spans remembered per trace for deduplication. Spans beyond it are checked
+// against the spans already remembered but are not remembered themselves.
+const maxDedupeSpans = 100_000
+
 type trace struct {
 	traceBytes   *tempopb.TraceBytes
+	created      time.Time
 	lastAppend   time.Time
 	traceID      []byte
 	maxBytes     int
@@ -35,33 +48,68 @@ type trace struct {
 	searchData         [][]byte
 	maxSearchBytes     int
 	currentSearchBytes int
+
+	// hashes of the span id and start time of every span appended, nil if deduplication is disabled
+	spans map[uint64]struct{}
+
+	// earliest time a distributor received the trace in unix nanoseconds, 0 if unknown
+	ingestionTime uint64
 }
 
-func newTrace(traceID []byte, maxBytes int, maxSearchBytes int) *trace {
-	return &trace{
+func newTrace(traceID []byte, maxBytes int, maxSearchBytes int, dedupeSpans bool) *trace {
+	now := time.Now()
+	t := &trace{
 		traceBytes: &tempopb.TraceBytes{
 			Traces: make([][]byte, 0, 10), // 10 for luck
 		},
-		lastAppend:     time.Now(),
+		created:        now,
+		lastAppend:     now,
 		traceID:        traceID,
 		maxBytes:       maxBytes,
 		maxSearchBytes: maxSearchBytes,
 	}
+
+	if dedupeSpans {
+		t.spans = map[uint64]struct{}{}
+	}
+
+	return t
 }
 
 func (t *trace) Push(_ context.Context, instanceID string, trace []byte, searchData []byte) error {
 	t.lastAppend = time.Now()
-	if t.maxBytes != 0 {
-		reqSize := len(trace)
-		if t.currentBytes+reqSize > t.maxBytes {
-			return status.Errorf(codes.FailedPrecondition, "%s max size of trace (%d) exceeded while adding %d bytes to trace %s", overrides.ErrorPrefixTraceTooLarge, t.maxBytes, reqSize, hex.EncodeToString(t.traceID))
+
+	var spanKeys []uint64
+	if t.spans != nil {
+		var err error
+		trace, spanKeys, err = t.dedupe(instanceID, trace)
+		if err != nil {
+			return err
 		}
+		if trace == nil {
+			return nil
+		}
+	}
 
-		t.currentBytes += reqSize
+	// the size is enforced as bytes are appended so a single huge trace can't consume unbounded memory
+	//  before it is cut. bytes that have already been accepted are kept.
+	reqSize := len(trace)
+	if t.maxBytes != 0 && t.currentBytes+reqSize > t.maxBytes {
+		return overrides.NewLimitError(overrides.ErrTraceTooLarge, "max size of trace (%d) exceeded while adding %d bytes to trace %s with current size %d",
+			t.maxBytes, reqSize, hex.EncodeToString(t.traceID), t.currentBytes)
 	}
+	t.currentBytes += reqSize
 
 	t.traceBytes.Traces = append(t.traceBytes.Traces, trace)
 
+	// spans are only remembered once they are appended so a rejected push can be retried
+	for _, key := range spanKeys {
+		if len(t.spans) >= maxDedupeSpans {
+			break
+		}
+		t.spans[key] = struct{}{}
+	}
+
 	if searchDataSize := len(searchData); searchDataSize > 0 {
 		// disable limit when set to 0
 		if t.maxSearchBytes == 0 || t.currentSearchBytes+searchDataSize <= t.maxSearchBytes {
@@ -76,3 +124,78 @@ func (t *trace) Push(_ context.Context, instanceID string, trace []byte, searchD
 
 	return nil
 }
+
+// recordIngestionTime keeps the earliest of the ingestion times of the pushes of the trace.
+func (t *trace) recordIngestionTime(ingestionTime uint64) {
+	t.ingestionTime = model.EarliestIngestionTime(t.ingestionTime, ingestionTime)
+}
+
+// marshal returns the trace bytes with the ingestion time appended as an inner trace without batches. Like the spans
+// it is kept when the trace is combined with other parts of it. traceBytes is not modified so its byte slices can
+// still be reused.
+func (t *trace) marshal() ([]byte, error) {
+	if t.ingestionTime == 0 {
+		return proto.Marshal(t.traceBytes)
+	}
+
+	ingestionTime, err := proto.Marshal(&tempopb.Trace{IngestionTimeUnixNano: t.ingestionTime})
+	if err != nil {
+		return nil, err
+	}
+
+	traces := t.traceBytes.Traces
+	return proto.Marshal(&tempopb.TraceBytes{
+		Traces: append(traces[:len(traces):len(traces)], ingestionTime),
+	})
+}
+
+// dedupe drops the spans of the marshalled tempopb.Trace that were already appended, identified by their span id and
+// start time. It returns the trace unchanged if nothing was dropped, and nil if every span was dropped. The keys of the
+// kept spans are returned to be remembered once the trace is appended.
+func (t *trace) dedupe(instanceID string, traceBytes []byte) ([]byte, []uint64, error) {
+	pbTrace := &tempopb.Trace{}
+	err := pbTrace.Unmarshal(traceBytes)
+	if err != nil {
+		return nil, nil, status.Errorf(codes.InvalidArgument, "failed to unmarshal trace %s: %v", hex.EncodeToString(t.traceID), err)
+	}
+
+	dropped := 0
+	var kept []uint64
+	seen := map[uint64]struct{}{}
+	for _, b := range pbTrace.Batches {
+		for _, ils := range b.InstrumentationLibrarySpans {
+			spans := ils.Spans[:0]
+			for _, span := range ils.Spans {
+				key := fnv1a.AddUint64(fnv1a.HashString64(string(span.SpanId)), span.StartTimeUnixNano)
+				if _, ok := t.spans[key]; ok {
+					dropped++
+					continue
+				}
+				// the same span can be repeated within a push
+				if _, ok := seen[key]; ok {
+					dropped++
+					continue
+				}
+				seen[key] = struct{}{}
+				kept = append(kept, key)
+				spans = append(spans, span)
+			}
+			ils.Spans = spans
+		}
+	}
+
+	if dropped == 0 {
+		return traceBytes, kept, nil
+	}
+	metricDuplicateSpansDroppedTotal.WithLabelValues(instanceID).Add(float64(dropped))
+	if len(kept) == 0 {
+		return nil, nil, nil
+	}
+
+	buffer := tempopb.SliceFromBytePool(pbTrace.Size())
+	_, err = pbTrace.MarshalToSizedBuffer(buffer)
+	if err != nil {
+		return nil, nil, status.Errorf(codes.Internal, "failed to marshal trace %s: %v", hex.EncodeToString(t.traceID), err)
+	}
+	return buffer, kept, nil
+}
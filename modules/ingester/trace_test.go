@@ -4,14 +4,19 @@ import (
 	"context"
 	"testing"
 
+	"github.com/gogo/protobuf/proto"
 	prom_dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tempo/modules/overrides"
+	"github.com/grafana/tempo/pkg/tempopb"
+	"github.com/grafana/tempo/pkg/util/test"
 )
 
 func TestTraceMaxSearchBytes(t *testing.T) {
 	tenantID := "fake"
 	maxSearchBytes := 100
-	tr := newTrace(nil, 0, maxSearchBytes)
+	tr := newTrace(nil, 0, maxSearchBytes, false)
 
 	getMetric := func() float64 {
 		m := &prom_dto.Metric{}
@@ -34,3 +39,80 @@ func TestTraceMaxSearchBytes(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, float64(tooMany*2), getMetric())
 }
+
+func TestTraceDedupeSpans(t *testing.T) {
+	tenantID := "fake-dedupe"
+	id := []byte{0x01, 0x02, 0x03, 0x04}
+	tr := newTrace(id, 0, 0, true)
+
+	getMetric := func() float64 {
+		m := &prom_dto.Metric{}
+		err := metricDuplicateSpansDroppedTotal.WithLabelValues(tenantID).Write(m)
+		require.NoError(t, err)
+		return m.Counter.GetValue()
+	}
+
+	original := test.MakeTrace(2, id)
+	b, err := proto.Marshal(original)
+	require.NoError(t, err)
+
+	err = tr.Push(context.TODO(), tenantID, b, nil)
+	require.NoError(t, err)
+	require.Len(t, tr.traceBytes.Traces, 1)
+	require.Equal(t, float64(0), getMetric())
+
+	// an exact retry is dropped entirely
+	err = tr.Push(context.TODO(), tenantID, b, nil)
+	require.NoError(t, err)
+	require.Len(t, tr.traceBytes.Traces, 1)
+	spans := float64(spanCount(original))
+	require.Equal(t, spans, getMetric())
+
+	// only the new spans of a partial duplicate are appended
+	partial := test.MakeTrace(1, id)
+	partial.Batches = append(partial.Batches, original.Batches[0])
+	b, err = proto.Marshal(partial)
+	require.NoError(t, err)
+
+	err = tr.Push(context.TODO(), tenantID, b, nil)
+	require.NoError(t, err)
+	require.Len(t, tr.traceBytes.Traces, 2)
+	require.Equal(t, spans+float64(spanCount(&tempopb.Trace{Batches: original.Batches[:1]})), getMetric())
+
+	appended := &tempopb.Trace{}
+	require.NoError(t, proto.Unmarshal(tr.traceBytes.Traces[1], appended))
+	require.Equal(t, spanCount(&tempopb.Trace{Batches: partial.Batches[:1]}), spanCount(appended))
+}
+
+func TestTraceDedupeSpansRejectedPush(t *testing.T) {
+	tenantID := "fake-dedupe-rejected"
+	id := []byte{0x01, 0x02, 0x03, 0x04}
+
+	first, err := proto.Marshal(test.MakeTrace(1, id))
+	require.NoError(t, err)
+	second, err := proto.Marshal(test.MakeTrace(1, id))
+	require.NoError(t, err)
+
+	tr := newTrace(id, len(first), 0, true)
+	require.NoError(t, tr.Push(context.TODO(), tenantID, first, nil))
+
+	// the spans of a push rejected by the size limit are not remembered
+	err = tr.Push(context.TODO(), tenantID, second, nil)
+	require.ErrorIs(t, err, overrides.ErrTraceTooLarge)
+	require.Len(t, tr.traceBytes.Traces, 1)
+
+	// so a retry once the trace has room isn't dropped as a duplicate
+	tr.maxBytes = len(first) + len(second)
+	require.NoError(t, tr.Push(context.TODO(), tenantID, second, nil))
+	require.Len(t, tr.traceBytes.Traces, 2)
+}
+
+func spanCount(trace *tempopb.Trace) int {
+	count := 0
+	for _, b := range trace.Batches {
+		for _, ils := range b.InstrumentationLibrarySpans {
+			count += len(ils.Spans)
+		}
+	}
+	return count
+}
@@ -0,0 +1,183 @@
+package ingester
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"golang.org/x/time/rate"
+
+	"github.com/grafana/tempo/tempodb"
+	"github.com/grafana/tempo/tempodb/backend"
+)
+
+var (
+	metricFlushUploadRateLimit = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "tempo",
+		Name:      "ingester_flush_upload_rate_limit_bytes",
+		Help:      "The configured limit of bytes per second uploaded to the backend by flushes. 0 if unlimited.",
+	})
+	metricFlushUploadThrottledSeconds = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "ingester_flush_upload_throttled_seconds_total",
+		Help:      "The total time flushes waited for the upload rate limit.",
+	})
+)
+
+// uploadLimiter is a token bucket shared by all flushes of an ingester that limits the bytes per second uploaded to
+// the backend. The limit is read before every wait so changes to the runtime config apply to flushes in progress.
+type uploadLimiter struct {
+	limit func() int
+
+	mtx     sync.Mutex
+	current int
+	limiter *rate.Limiter // nil if unlimited
+}
+
+func newUploadLimiter(limit func() int) *uploadLimiter {
+	l := &uploadLimiter{
+		limit: limit,
+	}
+	l.refresh()
+
+	return l
+}
+
+// refresh applies the configured limit and returns the token bucket, nil if unlimited. The bucket holds one second
+// of uploads.
+func (l *uploadLimiter) refresh() *rate.Limiter {
+	limit := l.limit()
+	if limit < 0 {
+		limit = 0
+	}
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	if limit != l.current {
+		l.current = limit
+		l.limiter = nil
+		if limit > 0 {
+			l.limiter = rate.NewLimiter(rate.Limit(limit), limit)
+		}
+		metricFlushUploadRateLimit.Set(float64(limit))
+	}
+
+	return l.limiter
+}
+
+// burst returns the most bytes that can be uploaded at once, 0 if unlimited.
+func (l *uploadLimiter) burst() int {
+	limiter := l.refresh()
+	if limiter == nil {
+		return 0
+	}
+	return limiter.Burst()
+}
+
+// wait blocks until n bytes may be uploaded or the context is done.
+func (l *uploadLimiter) wait(ctx context.Context, n int) error {
+	for n > 0 {
+		limiter := l.refresh()
+		if limiter == nil {
+			return nil
+		}
+
+		take := n
+		if take > limiter.Burst() {
+			take = limiter.Burst()
+		}
+		n -= take
+
+		r := limiter.ReserveN(time.Now(), take)
+		delay := r.Delay()
+		if delay == 0 {
+			continue
+		}
+		metricFlushUploadThrottledSeconds.Add(delay.Seconds())
+
+		t := time.NewTimer(delay)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			r.Cancel()
+			return ctx.Err()
+		}
+	}
+
+	return nil
+}
+
+// rateLimitedBlock uploads a block through the upload limiter.
+type rateLimitedBlock struct {
+	tempodb.WriteableBlock
+	limiter *uploadLimiter
+}
+
+func (b *rateLimitedBlock) Write(ctx context.Context, w backend.Writer) error {
+	return b.WriteableBlock.Write(ctx, &rateLimitedWriter{
+		Writer:  w,
+		limiter: b.limiter,
+	})
+}
+
+// rateLimitedWriter waits for the upload limiter before writing data to the backend. Metas and tenant indexes are
+// small and written without waiting.
+type rateLimitedWriter struct {
+	backend.Writer
+	limiter *uploadLimiter
+}
+
+func (w *rateLimitedWriter) Write(ctx context.Context, name string, blockID uuid.UUID, tenantID string, buffer []byte, shouldCache bool) error {
+	err := w.limiter.wait(ctx, len(buffer))
+	if err != nil {
+		return err
+	}
+
+	return w.Writer.Write(ctx, name, blockID, tenantID, buffer, shouldCache)
+}
+
+func (w *rateLimitedWriter) StreamWriter(ctx context.Context, name string, blockID uuid.UUID, tenantID string, data io.Reader, size int64) error {
+	return w.Writer.StreamWriter(ctx, name, blockID, tenantID, &rateLimitedReader{
+		ctx:     ctx,
+		r:       data,
+		limiter: w.limiter,
+	}, size)
+}
+
+func (w *rateLimitedWriter) Append(ctx context.Context, name string, blockID uuid.UUID, tenantID string, tracker backend.AppendTracker, buffer []byte) (backend.AppendTracker, error) {
+	err := w.limiter.wait(ctx, len(buffer))
+	if err != nil {
+		return nil, err
+	}
+
+	return w.Writer.Append(ctx, name, blockID, tenantID, tracker, buffer)
+}
+
+// rateLimitedReader is passed to StreamWriter. Reads are at most one burst of the upload limiter so the backend
+// client sends the data at a steady rate.
+type rateLimitedReader struct {
+	ctx     context.Context
+	r       io.Reader
+	limiter *uploadLimiter
+}
+
+func (r *rateLimitedReader) Read(p []byte) (int, error) {
+	if burst := r.limiter.burst(); burst > 0 && len(p) > burst {
+		p = p[:burst]
+	}
+
+	n, err := r.r.Read(p)
+	if n > 0 {
+		if waitErr := r.limiter.wait(r.ctx, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+
+	return n, err
+}
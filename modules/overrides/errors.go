@@ -0,0 +1,118 @@
+package overrides
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gogo/googleapis/google/rpc"
+	"github.com/gogo/status"
+	"google.golang.org/grpc/codes"
+	grpc_status "google.golang.org/grpc/status"
+)
+
+// Reasons of the pushes rejected because of a per tenant limit. They are the reasons of the ErrorInfo detail of the
+// gRPC status of a LimitError and of the traces rejected in a partial success.
+const (
+	ReasonLiveTracesExceeded = "LIVE_TRACES_EXCEEDED"
+	ReasonTraceTooLarge      = "TRACE_TOO_LARGE"
+	ReasonRateLimited        = "RATE_LIMITED"
+
+	errorDomain = "tempo"
+)
+
+var (
+	// ErrLiveTracesExceeded is the limit of the pushes rejected b/c the tenant had too many live traces
+	ErrLiveTracesExceeded = errors.New("live traces exceeded")
+	// ErrTraceTooLarge is the limit of the pushes rejected b/c they exceeded the single trace limit
+	ErrTraceTooLarge = errors.New("trace too large")
+	// ErrRateLimited is the limit of the pushes rejected b/c they exceeded the spans/second of the tenant
+	ErrRateLimited = errors.New("rate limited")
+)
+
+type limit struct {
+	err    error
+	reason string
+	prefix string
+	code   codes.Code
+}
+
+var limits = []*limit{
+	{err: ErrLiveTracesExceeded, reason: ReasonLiveTracesExceeded, prefix: ErrorPrefixLiveTracesExceeded, code: codes.FailedPrecondition},
+	{err: ErrTraceTooLarge, reason: ReasonTraceTooLarge, prefix: ErrorPrefixTraceTooLarge, code: codes.FailedPrecondition},
+	{err: ErrRateLimited, reason: ReasonRateLimited, prefix: ErrorPrefixRateLimited, code: codes.ResourceExhausted},
+}
+
+// LimitError is a push, or a trace of a push, rejected because of a per tenant limit. errors.Is is true for the
+// Err* error of the limit. The message starts with the ErrorPrefix* of the limit for the distributors that still
+// match the prefixes.
+type LimitError struct {
+	limit *limit
+	msg   string
+}
+
+// NewLimitError returns a LimitError of the limit, one of ErrLiveTracesExceeded, ErrTraceTooLarge or ErrRateLimited.
+func NewLimitError(limitErr error, format string, args ...interface{}) error {
+	for _, l := range limits {
+		if l.err == limitErr {
+			return &LimitError{limit: l, msg: l.prefix + " " + fmt.Sprintf(format, args...)}
+		}
+	}
+	return fmt.Errorf(format, args...)
+}
+
+func (e *LimitError) Error() string {
+	return e.msg
+}
+
+func (e *LimitError) Is(target error) bool {
+	return target == e.limit.err
+}
+
+// Reason returns the reason of the limit, i.e. ReasonTraceTooLarge.
+func (e *LimitError) Reason() string {
+	return e.limit.reason
+}
+
+// GRPCStatus returns the status a LimitError is sent with. It has the code of the limit and an ErrorInfo with the
+// reason of the limit.
+func (e *LimitError) GRPCStatus() *grpc_status.Status {
+	s := status.New(e.limit.code, e.msg)
+	if withInfo, err := s.WithDetails(&rpc.ErrorInfo{Reason: e.limit.reason, Domain: errorDomain}); err == nil {
+		s = withInfo
+	}
+	return grpc_status.Convert(s.Err())
+}
+
+// AsLimitError returns the LimitError of err. err is a LimitError or the error of a gRPC call that failed with one.
+// Errors of ingesters that predate typed errors are recognized by the prefix of their message, this fallback is
+// deprecated and will be removed.
+func AsLimitError(err error) (*LimitError, bool) {
+	var limitErr *LimitError
+	if errors.As(err, &limitErr) {
+		return limitErr, true
+	}
+
+	s, ok := status.FromError(err)
+	if !ok {
+		return nil, false
+	}
+	for _, detail := range s.Details() {
+		if info, ok := detail.(*rpc.ErrorInfo); ok && info.Domain == errorDomain {
+			return TraceLimitError(info.Reason, s.Message())
+		}
+	}
+	return TraceLimitError("", s.Message())
+}
+
+// TraceLimitError returns the LimitError of a trace rejected in a partial success with the given reason and message.
+// Traces without a reason are recognized by the prefix of their message, this fallback is deprecated and will be
+// removed.
+func TraceLimitError(reason string, msg string) (*LimitError, bool) {
+	for _, l := range limits {
+		if reason == l.reason || (reason == "" && strings.HasPrefix(msg, l.prefix)) {
+			return &LimitError{limit: l, msg: msg}, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,78 @@
+package overrides
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/gogo/googleapis/google/rpc"
+	"github.com/gogo/status"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+)
+
+func TestLimitError(t *testing.T) {
+	err := NewLimitError(ErrTraceTooLarge, "max size of trace (%d) exceeded", 10)
+	assert.ErrorIs(t, err, ErrTraceTooLarge)
+	assert.NotErrorIs(t, err, ErrLiveTracesExceeded)
+	assert.Equal(t, ErrorPrefixTraceTooLarge+" max size of trace (10) exceeded", err.Error())
+
+	// the status has the code of the limit and its reason
+	s, ok := status.FromError(err)
+	require.True(t, ok)
+	assert.Equal(t, codes.FailedPrecondition, s.Code())
+	assert.Equal(t, err.Error(), s.Message())
+	require.Len(t, s.Details(), 1)
+	info, ok := s.Details()[0].(*rpc.ErrorInfo)
+	require.True(t, ok)
+	assert.Equal(t, ReasonTraceTooLarge, info.Reason)
+
+	rateLimited := NewLimitError(ErrRateLimited, "ingestion rate limit exceeded")
+	assert.Equal(t, codes.ResourceExhausted, status.Code(rateLimited))
+
+	// errors that aren't limits are plain errors
+	assert.Equal(t, "a 1", NewLimitError(errors.New("b"), "a %d", 1).Error())
+}
+
+func TestAsLimitError(t *testing.T) {
+	limitErr := NewLimitError(ErrLiveTracesExceeded, "max live traces per tenant exceeded")
+
+	tests := []struct {
+		name   string
+		err    error
+		reason string
+	}{
+		{name: "wrapped", err: fmt.Errorf("push: %w", limitErr), reason: ReasonLiveTracesExceeded},
+		{name: "status", err: status.ErrorProto(status.Convert(limitErr).Proto()), reason: ReasonLiveTracesExceeded},
+		{name: "prefix", err: status.Error(codes.FailedPrecondition, ErrorPrefixRateLimited+" too many spans"), reason: ReasonRateLimited},
+		{name: "other status", err: status.Error(codes.Internal, "internal error")},
+		{name: "other", err: errors.New("error")},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, ok := AsLimitError(tc.err)
+			if tc.reason == "" {
+				assert.False(t, ok)
+				return
+			}
+			require.True(t, ok)
+			assert.Equal(t, tc.reason, actual.Reason())
+		})
+	}
+}
+
+func TestTraceLimitError(t *testing.T) {
+	err, ok := TraceLimitError(ReasonTraceTooLarge, "max size of trace exceeded")
+	require.True(t, ok)
+	assert.ErrorIs(t, err, ErrTraceTooLarge)
+	assert.Equal(t, "max size of trace exceeded", err.Error())
+
+	// traces without a reason are matched by the prefix of the message
+	err, ok = TraceLimitError("", ErrorPrefixLiveTracesExceeded+" max live traces exceeded")
+	require.True(t, ok)
+	assert.ErrorIs(t, err, ErrLiveTracesExceeded)
+
+	_, ok = TraceLimitError("", "max size of trace exceeded")
+	assert.False(t, ok)
+}
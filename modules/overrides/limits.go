@@ -12,6 +12,12 @@ const (
 	// GlobalIngestionRateStrategy indicates that an attempt should be made to consider this limit across the entire Tempo cluster
 	GlobalIngestionRateStrategy = "global"
 
+	// LocalLiveTracesStrategy indicates that the global live traces limit is converted into a limit per ingester
+	LocalLiveTracesStrategy = "local"
+	// GlobalLiveTracesStrategy indicates that the distributors check the global live traces limit against the live traces polled from all ingesters
+	// and the ingesters reject new traces of the tenants at the limit
+	GlobalLiveTracesStrategy = "global"
+
 	// ErrorPrefixLiveTracesExceeded is used to flag batches from the ingester that were rejected b/c they had too many traces
 	ErrorPrefixLiveTracesExceeded = "LIVE_TRACES_EXCEEDED:"
 	// ErrorPrefixTraceTooLarge is used to flag batches from the ingester that were rejected b/c they exceeded the single trace limit
@@ -28,11 +34,55 @@ type Limits struct {
 	IngestionRateLimitBytes int    `yaml:"ingestion_rate_limit_bytes" json:"ingestion_rate_limit_bytes"`
 	IngestionBurstSizeBytes int    `yaml:"ingestion_burst_size_bytes" json:"ingestion_burst_size_bytes"`
 
+	// Distributor attribute normalization. The built-in mapping of deprecated semantic conventions is used if
+	// AttributeRenames is empty.
+	AttributeNormalizationEnabled bool              `yaml:"attribute_normalization_enabled" json:"attribute_normalization_enabled"`
+	AttributeRenames              map[string]string `yaml:"attribute_renames" json:"attribute_renames"`
+
+	// Distributor push authentication. Maps the ids of the bearer tokens that push to the tenant to their hex encoded
+	// SHA-256 hashes. Only read from tenant-specific overrides.
+	PushTokens map[string]string `yaml:"push_tokens" json:"push_tokens"`
+
 	// Ingester enforced limits.
-	MaxLocalTracesPerUser  int `yaml:"max_traces_per_user" json:"max_traces_per_user"`
-	MaxGlobalTracesPerUser int `yaml:"max_global_traces_per_user" json:"max_global_traces_per_user"`
-	MaxBytesPerTrace       int `yaml:"max_bytes_per_trace" json:"max_bytes_per_trace"`
-	MaxSearchBytesPerTrace int `yaml:"max_search_bytes_per_trace" json:"max_search_bytes_per_trace"`
+	MaxLocalTracesPerUser  int    `yaml:"max_traces_per_user" json:"max_traces_per_user"`
+	MaxGlobalTracesPerUser int    `yaml:"max_global_traces_per_user" json:"max_global_traces_per_user"`
+	LiveTracesStrategy     string `yaml:"live_traces_strategy" json:"live_traces_strategy"`
+	MaxLiveTraces          int    `yaml:"max_live_traces" json:"max_live_traces"`
+	MaxBytesPerTrace       int    `yaml:"max_bytes_per_trace" json:"max_bytes_per_trace"`
+	MaxSearchBytesPerTrace int    `yaml:"max_search_bytes_per_trace" json:"max_search_bytes_per_trace"`
+
+	// Ingester query limits. Trace by id and search queries over the limit are rejected instead of queued.
+	MaxConcurrentQueriesPerTenant int `yaml:"max_concurrent_queries_per_tenant" json:"max_concurrent_queries_per_tenant"`
+
+	// Querier enforced limits.
+	MaxBytesPerTagValuesQuery int `yaml:"max_bytes_per_tag_values_query" json:"max_bytes_per_tag_values_query"`
+	MaxSearchBytesRead        int `yaml:"max_search_bytes_read" json:"max_search_bytes_read"`
+	MaxBlocksPerTraceQuery    int `yaml:"max_blocks_per_trace_query" json:"max_blocks_per_trace_query"`
+	MaxBytesPerTraceQuery     int `yaml:"max_bytes_per_trace_query" json:"max_bytes_per_trace_query"`
+
+	// Querier query rate limits. Like the local ingestion rate strategy the limits apply to each querier.
+	MaxQueriesPerSecond int `yaml:"max_queries_per_second" json:"max_queries_per_second"`
+	MaxQueriesBurst     int `yaml:"max_queries_burst" json:"max_queries_burst"`
+
+	// Query frontend enforced limits. Queries of a tenant with MaxOutstandingPerTenant requests in the queue of a
+	// frontend are rejected, MaxQueryDuration bounds the time a query of the tenant may take.
+	MaxOutstandingPerTenant int            `yaml:"max_outstanding_per_tenant" json:"max_outstanding_per_tenant"`
+	MaxQueryDuration        model.Duration `yaml:"max_query_duration" json:"max_query_duration"`
+
+	// Ingester flush upload bandwidth in bytes per second. Like the strategies it applies to each ingester and can't be
+	// overridden per tenant, but it's reloaded with the runtime config.
+	FlushUploadRateLimitBytes int `yaml:"flush_upload_rate_limit_bytes" json:"flush_upload_rate_limit_bytes"`
+
+	// Ingester flushing. Blocks of tenants with DoNotFlush set are only kept in the ingester until the complete block
+	// timeout and never written to the backend.
+	DoNotFlush bool `yaml:"do_not_flush" json:"do_not_flush"`
+
+	// Ingester span deduplication.
+	DedupeSpans bool `yaml:"dedupe_spans" json:"dedupe_spans"`
+
+	// Ingester trace cutting.
+	TraceIdlePeriod    model.Duration `yaml:"trace_idle_period" json:"trace_idle_period"`
+	MaxTraceLivePeriod model.Duration `yaml:"max_trace_live_period" json:"max_trace_live_period"`
 
 	// Compactor enforced limits.
 	BlockRetention model.Duration `yaml:"block_retention" json:"block_retention"`
@@ -49,11 +99,36 @@ func (l *Limits) RegisterFlags(f *flag.FlagSet) {
 	f.IntVar(&l.IngestionRateLimitBytes, "distributor.ingestion-rate-limit-bytes", 15e6, "Per-user ingestion rate limit in bytes per second.")
 	f.IntVar(&l.IngestionBurstSizeBytes, "distributor.ingestion-burst-size-bytes", 20e6, "Per-user ingestion burst size in bytes. Should be set to the expected size (in bytes) of a single push request.")
 
+	f.BoolVar(&l.AttributeNormalizationEnabled, "distributor.attribute-normalization-enabled", false, "Rename deprecated semantic convention attributes on ingest.")
+
 	// Ingester limits
 	f.IntVar(&l.MaxLocalTracesPerUser, "ingester.max-traces-per-user", 10e3, "Maximum number of active traces per user, per ingester. 0 to disable.")
 	f.IntVar(&l.MaxGlobalTracesPerUser, "ingester.max-global-traces-per-user", 0, "Maximum number of active traces per user, across the cluster. 0 to disable.")
+	f.StringVar(&l.LiveTracesStrategy, "ingester.live-traces-strategy", "local", "Whether the global traces limit is converted into a limit per ingester (local), or checked by the distributors against the live traces of all ingesters, rejecting new traces at the limit (global).")
+	f.IntVar(&l.MaxLiveTraces, "ingester.max-live-traces", 0, "Maximum number of live traces per user, per ingester. Only the creation of new traces is rejected. 0 to disable.")
 	f.IntVar(&l.MaxBytesPerTrace, "ingester.max-bytes-per-trace", 50e5, "Maximum size of a trace in bytes.  0 to disable.")
-	f.IntVar(&l.MaxBytesPerTrace, "ingester.max-search-bytes-per-trace", 50e3, "Maximum size of search data per trace in bytes.  0 to disable.")
+	f.IntVar(&l.MaxSearchBytesPerTrace, "ingester.max-search-bytes-per-trace", 50e3, "Maximum size of search data per trace in bytes.  0 to disable.")
+
+	f.IntVar(&l.MaxConcurrentQueriesPerTenant, "ingester.max-concurrent-queries-per-tenant", 0, "Maximum number of trace by id, search and search tag queries per user an ingester runs at once. 0 to disable.")
+
+	f.IntVar(&l.MaxBytesPerTagValuesQuery, "querier.max-bytes-per-tag-values-query", 5e6, "Maximum size in bytes of the tag names or values returned by a search tag lookup. 0 to disable.")
+	f.IntVar(&l.MaxSearchBytesRead, "querier.max-search-bytes-read", 1e9, "Maximum number of bytes a search of backend blocks inspects before it returns partial results. 0 to disable.")
+	f.IntVar(&l.MaxBlocksPerTraceQuery, "querier.max-blocks-per-trace-query", 0, "Maximum number of backend blocks a trace by id query inspects before it returns a partial trace. 0 to disable.")
+	f.IntVar(&l.MaxBytesPerTraceQuery, "querier.max-bytes-per-trace-query", 0, "Maximum size in bytes of a trace assembled by a trace by id query. Larger traces are rejected. 0 to disable.")
+	f.IntVar(&l.MaxQueriesPerSecond, "querier.max-queries-per-second", 0, "Per-user trace by id and search queries per second each querier accepts. 0 to disable.")
+	f.IntVar(&l.MaxQueriesBurst, "querier.max-queries-burst", 0, "Per-user burst of trace by id and search queries each querier accepts. 0 to use the queries per second.")
+
+	f.IntVar(&l.MaxOutstandingPerTenant, "frontend.tenant-max-outstanding-requests", 0, "Per-user number of requests in the queue of each query frontend above which new queries are rejected with 429. 0 to only apply the max_outstanding_per_tenant of the query frontend.")
+	f.Var(&l.MaxQueryDuration, "frontend.max-query-duration", "Per-user maximum duration of a query in the query frontend. 0 to disable.")
+
+	f.IntVar(&l.FlushUploadRateLimitBytes, "ingester.flush-upload-rate-limit-bytes", 0, "Bytes per second each ingester may upload to the backend across all flushes. 0 to disable.")
+
+	f.BoolVar(&l.DoNotFlush, "ingester.do-not-flush", false, "Keep complete blocks in the ingester until the complete block timeout instead of flushing them to the backend.")
+
+	f.BoolVar(&l.DedupeSpans, "ingester.dedupe-spans", false, "Drop spans with the same span id and start time as a span already appended to the live trace.")
+
+	f.Var(&l.TraceIdlePeriod, "ingester.tenant-trace-idle-period", "Duration after which to consider a trace complete if no spans have been received. 0 to use the ingester trace_idle_period.")
+	f.Var(&l.MaxTraceLivePeriod, "ingester.max-trace-live-period", "Duration after which a trace is cut even if spans are still being received. 0 to disable.")
 
 	f.StringVar(&l.PerTenantOverrideConfig, "limits.per-user-override-config", "", "File name of per-user overrides.")
 	_ = l.PerTenantOverridePeriod.Set("10s")
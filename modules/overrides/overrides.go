@@ -223,6 +223,18 @@ func (o *Overrides) MaxGlobalTracesPerUser(userID string) int {
 	return o.getOverridesForUser(userID).MaxGlobalTracesPerUser
 }
 
+// LiveTracesStrategy returns whether the global traces limit should be converted into a limit per ingester (local)
+// or checked by the distributors against the live traces of all ingesters so that new traces are rejected (global).
+func (o *Overrides) LiveTracesStrategy() string {
+	// The live traces strategy can't be overridden on a per-tenant basis.
+	return o.getOverridesForUser("").LiveTracesStrategy
+}
+
+// MaxLiveTraces returns the maximum number of live traces a user is allowed to create in a single ingester.
+func (o *Overrides) MaxLiveTraces(userID string) int {
+	return o.getOverridesForUser(userID).MaxLiveTraces
+}
+
 // MaxBytesPerTrace returns the maximum size of a single trace in bytes allowed for a user.
 func (o *Overrides) MaxBytesPerTrace(userID string) int {
 	return o.getOverridesForUser(userID).MaxBytesPerTrace
@@ -233,11 +245,98 @@ func (o *Overrides) MaxSearchBytesPerTrace(userID string) int {
 	return o.getOverridesForUser(userID).MaxSearchBytesPerTrace
 }
 
+// MaxConcurrentQueriesPerTenant returns the maximum number of queries of a user each ingester runs at once.
+func (o *Overrides) MaxConcurrentQueriesPerTenant(userID string) int {
+	return o.getOverridesForUser(userID).MaxConcurrentQueriesPerTenant
+}
+
+// MaxBytesPerTagValuesQuery returns the maximum size in bytes of the tag names or values returned by a search tag
+// lookup of a user.
+func (o *Overrides) MaxBytesPerTagValuesQuery(userID string) int {
+	return o.getOverridesForUser(userID).MaxBytesPerTagValuesQuery
+}
+
+// MaxSearchBytesRead returns the maximum number of bytes a search of the backend blocks of a user inspects.
+func (o *Overrides) MaxSearchBytesRead(userID string) int {
+	return o.getOverridesForUser(userID).MaxSearchBytesRead
+}
+
+// MaxBlocksPerTraceQuery returns the maximum number of backend blocks a trace by id query of a user inspects.
+func (o *Overrides) MaxBlocksPerTraceQuery(userID string) int {
+	return o.getOverridesForUser(userID).MaxBlocksPerTraceQuery
+}
+
+// MaxBytesPerTraceQuery returns the maximum size of a trace assembled by a trace by id query of a user.
+func (o *Overrides) MaxBytesPerTraceQuery(userID string) int {
+	return o.getOverridesForUser(userID).MaxBytesPerTraceQuery
+}
+
+// MaxQueriesPerSecond is the number of trace by id and search queries per second each querier accepts from a user.
+func (o *Overrides) MaxQueriesPerSecond(userID string) float64 {
+	return float64(o.getOverridesForUser(userID).MaxQueriesPerSecond)
+}
+
+// MaxQueriesBurst is the burst of trace by id and search queries each querier accepts from a user.
+func (o *Overrides) MaxQueriesBurst(userID string) int {
+	return o.getOverridesForUser(userID).MaxQueriesBurst
+}
+
+// MaxOutstandingPerTenant is the number of requests of a user in the queue of each query frontend above which new
+// queries of the user are rejected. 0 if unlimited.
+func (o *Overrides) MaxOutstandingPerTenant(userID string) int {
+	return o.getOverridesForUser(userID).MaxOutstandingPerTenant
+}
+
+// MaxQueryDuration is the maximum duration of a query of a user in the query frontend. 0 if unlimited.
+func (o *Overrides) MaxQueryDuration(userID string) time.Duration {
+	return time.Duration(o.getOverridesForUser(userID).MaxQueryDuration)
+}
+
+// FlushUploadRateLimitBytes is the number of bytes per second each ingester may upload to the backend across all of
+// its flushes. 0 if unlimited.
+func (o *Overrides) FlushUploadRateLimitBytes() int {
+	// The upload limit applies to the whole ingester and can't be overridden on a per-tenant basis.
+	return o.getOverridesForUser("").FlushUploadRateLimitBytes
+}
+
+// DoNotFlush is whether the blocks of this tenant are deleted after the complete block timeout instead of being
+// flushed to the backend
+func (o *Overrides) DoNotFlush(userID string) bool {
+	return o.getOverridesForUser(userID).DoNotFlush
+}
+
+// DedupeSpans is whether duplicate spans appended to a live trace are dropped by the ingester for this tenant
+func (o *Overrides) DedupeSpans(userID string) bool {
+	return o.getOverridesForUser(userID).DedupeSpans
+}
+
+// TraceIdlePeriod is the duration after which a trace without new spans is cut for this tenant. 0 if the ingester
+// default should be used.
+func (o *Overrides) TraceIdlePeriod(userID string) time.Duration {
+	return time.Duration(o.getOverridesForUser(userID).TraceIdlePeriod)
+}
+
+// MaxTraceLivePeriod is the duration after which a trace is cut for this tenant regardless of new spans. 0 if disabled.
+func (o *Overrides) MaxTraceLivePeriod(userID string) time.Duration {
+	return time.Duration(o.getOverridesForUser(userID).MaxTraceLivePeriod)
+}
+
 // IngestionRateLimitBytes is the number of spans per second allowed for this tenant
 func (o *Overrides) IngestionRateLimitBytes(userID string) float64 {
 	return float64(o.getOverridesForUser(userID).IngestionRateLimitBytes)
 }
 
+// AttributeNormalizationEnabled is whether deprecated attributes are renamed on ingest for this tenant
+func (o *Overrides) AttributeNormalizationEnabled(userID string) bool {
+	return o.getOverridesForUser(userID).AttributeNormalizationEnabled
+}
+
+// AttributeRenames is the mapping of old to new attribute names for this tenant. nil if the default mapping
+// should be used.
+func (o *Overrides) AttributeRenames(userID string) map[string]string {
+	return o.getOverridesForUser(userID).AttributeRenames
+}
+
 // IngestionBurstSizeBytes is the burst size in spans allowed for this tenant
 func (o *Overrides) IngestionBurstSizeBytes(userID string) int {
 	return o.getOverridesForUser(userID).IngestionBurstSizeBytes
@@ -248,6 +347,24 @@ func (o *Overrides) BlockRetention(userID string) time.Duration {
 	return time.Duration(o.getOverridesForUser(userID).BlockRetention)
 }
 
+// ForEachPushToken calls fn with every push token of the tenant-specific overrides. The wildcard tenant is skipped as
+// each token has to map to a single tenant.
+func (o *Overrides) ForEachPushToken(fn func(tenantID, tokenID, hash string)) {
+	tenantOverrides := o.tenantOverrides()
+	if tenantOverrides == nil {
+		return
+	}
+
+	for tenantID, l := range tenantOverrides.TenantLimits {
+		if tenantID == wildcardTenant || l == nil {
+			continue
+		}
+		for tokenID, hash := range l.PushTokens {
+			fn(tenantID, tokenID, hash)
+		}
+	}
+}
+
 func (o *Overrides) getOverridesForUser(userID string) *Limits {
 	if tenantOverrides := o.tenantOverrides(); tenantOverrides != nil {
 		l := tenantOverrides.forUser(userID)
@@ -148,3 +148,37 @@ func TestOverrides(t *testing.T) {
 		})
 	}
 }
+
+func TestForEachPushToken(t *testing.T) {
+	overridesFile := filepath.Join(t.TempDir(), "overrides.yaml")
+	err := ioutil.WriteFile(overridesFile, []byte(`
+overrides:
+  team-a:
+    push_tokens:
+      ci: aaaa
+      prod: bbbb
+  team-b:
+    max_traces_per_user: 1
+  "*":
+    push_tokens:
+      any: cccc
+`), os.ModePerm)
+	require.NoError(t, err)
+
+	prometheus.DefaultRegisterer = prometheus.NewRegistry()
+	overrides, err := NewOverrides(Limits{
+		PerTenantOverrideConfig: overridesFile,
+		PerTenantOverridePeriod: model.Duration(time.Hour),
+	})
+	require.NoError(t, err)
+	require.NoError(t, services.StartAndAwaitRunning(context.TODO(), overrides))
+	defer func() {
+		require.NoError(t, services.StopAndAwaitTerminated(context.TODO(), overrides))
+	}()
+
+	tokens := map[string]string{}
+	overrides.ForEachPushToken(func(tenantID, tokenID, hash string) {
+		tokens[tenantID+"/"+tokenID] = hash
+	})
+	assert.Equal(t, map[string]string{"team-a/ci": "aaaa", "team-a/prod": "bbbb"}, tokens)
+}
@@ -0,0 +1,225 @@
+package querier
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/cortexproject/cortex/pkg/util/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/grafana/dskit/services"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/common/expfmt"
+)
+
+var metricConcurrentQueriesLimit = promauto.NewGauge(prometheus.GaugeOpts{
+	Namespace: "tempo",
+	Name:      "querier_concurrent_queries_limit",
+	Help:      "The number of queries of the query-frontend the querier runs at once.",
+})
+
+// workerPool runs the frontend workers that pull the queries of the query-frontends. Every worker processes a fixed
+// number of queries at once, so the number of queries the querier pulls is changed by starting and stopping workers. A
+// stopped worker cancels the queries it's running, the query-frontend retries them.
+type workerPool struct {
+	newWorker func(concurrency int) (services.Service, error)
+	watcher   *services.FailureWatcher
+
+	mtx     sync.Mutex
+	workers []poolWorker
+	size    int
+}
+
+type poolWorker struct {
+	service     services.Service
+	concurrency int
+}
+
+func newWorkerPool(newWorker func(concurrency int) (services.Service, error)) *workerPool {
+	return &workerPool{
+		newWorker: newWorker,
+		watcher:   services.NewFailureWatcher(),
+	}
+}
+
+// resize starts and stops workers until they process size queries at once, at most step queries per worker.
+func (p *workerPool) resize(ctx context.Context, size int, step int) error {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	for p.size > size {
+		w := p.workers[len(p.workers)-1]
+		p.workers = p.workers[:len(p.workers)-1]
+		p.size -= w.concurrency
+		if err := services.StopAndAwaitTerminated(ctx, w.service); err != nil {
+			return fmt.Errorf("failed to stop frontend worker: %w", err)
+		}
+	}
+
+	for p.size < size {
+		concurrency := size - p.size
+		if step > 0 && concurrency > step {
+			concurrency = step
+		}
+
+		worker, err := p.newWorker(concurrency)
+		if err != nil {
+			return err
+		}
+		p.watcher.WatchService(worker)
+		if err := services.StartAndAwaitRunning(ctx, worker); err != nil {
+			return fmt.Errorf("failed to start frontend worker: %w", err)
+		}
+		p.workers = append(p.workers, poolWorker{service: worker, concurrency: concurrency})
+		p.size += concurrency
+	}
+
+	return nil
+}
+
+// adaptiveConcurrency adjusts the number of queries the frontend workers pull from the query-frontends at once to the
+// queue length of the query-frontends.
+type adaptiveConcurrency struct {
+	cfg     AdaptiveConcurrencyConfig
+	max     int
+	limit   int
+	workers *workerPool
+	client  *http.Client
+}
+
+func newAdaptiveConcurrency(cfg AdaptiveConcurrencyConfig, max int, newWorker func(concurrency int) (services.Service, error)) *adaptiveConcurrency {
+	min := cfg.MinConcurrentQueries
+	if min <= 0 || min > max {
+		min = max
+	}
+	cfg.MinConcurrentQueries = min
+	metricConcurrentQueriesLimit.Set(float64(min))
+
+	return &adaptiveConcurrency{
+		cfg:     cfg,
+		max:     max,
+		limit:   min,
+		workers: newWorkerPool(newWorker),
+		client:  &http.Client{Timeout: cfg.PollInterval},
+	}
+}
+
+// service starts the workers for the min concurrent queries and then polls the queue length every poll interval.
+func (a *adaptiveConcurrency) service() services.Service {
+	return services.NewTimerService(a.cfg.PollInterval, a.starting, a.iteration, a.stopping)
+}
+
+func (a *adaptiveConcurrency) starting(ctx context.Context) error {
+	return a.workers.resize(ctx, a.limit, a.cfg.ScaleStep)
+}
+
+func (a *adaptiveConcurrency) iteration(ctx context.Context) error {
+	select {
+	case err := <-a.workers.watcher.Chan():
+		return fmt.Errorf("frontend worker failed: %w", err)
+	default:
+	}
+
+	return a.poll(ctx)
+}
+
+func (a *adaptiveConcurrency) stopping(_ error) error {
+	return a.workers.resize(context.Background(), 0, a.cfg.ScaleStep)
+}
+
+// poll grows the limit by a step if more queries are queued than the scale up queue length and shrinks it by a step if
+// none are. The limit is kept if the queue length can't be read.
+func (a *adaptiveConcurrency) poll(ctx context.Context) error {
+	queued, err := a.queueLength(ctx)
+	if err != nil {
+		level.Warn(log.Logger).Log("msg", "failed to read query-frontend queue length. keeping concurrent queries limit", "err", err)
+		return nil
+	}
+
+	return a.adjust(ctx, queued)
+}
+
+func (a *adaptiveConcurrency) adjust(ctx context.Context, queued int) error {
+	current := a.limit
+	limit := current
+	switch {
+	case queued > a.cfg.ScaleUpQueueLength:
+		limit += a.cfg.ScaleStep
+	case queued == 0:
+		limit -= a.cfg.ScaleStep
+	}
+	if limit > a.max {
+		limit = a.max
+	}
+	if limit < a.cfg.MinConcurrentQueries {
+		limit = a.cfg.MinConcurrentQueries
+	}
+	if limit == current {
+		return nil
+	}
+
+	level.Info(log.Logger).Log("msg", "changing concurrent queries limit", "from", current, "to", limit, "queued", queued)
+	err := a.workers.resize(ctx, limit, a.cfg.ScaleStep)
+	if err != nil {
+		return err
+	}
+	a.limit = limit
+	metricConcurrentQueriesLimit.Set(float64(limit))
+	return nil
+}
+
+// queueLength sums the queue length metric of all query-frontends. Frontends that can't be read are left out, it fails
+// if none can be read.
+func (a *adaptiveConcurrency) queueLength(ctx context.Context) (int, error) {
+	var total float64
+	var lastErr error
+	read := 0
+	for _, url := range a.cfg.MetricsURLs {
+		queued, err := a.readQueueLength(ctx, url)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		total += queued
+		read++
+	}
+
+	if read == 0 && lastErr != nil {
+		return 0, lastErr
+	}
+	return int(total), nil
+}
+
+func (a *adaptiveConcurrency) readQueueLength(ctx context.Context, url string) (float64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("%s returned %d", url, resp.StatusCode)
+	}
+
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(resp.Body)
+	if err != nil {
+		return 0, fmt.Errorf("error parsing metrics of %s: %w", url, err)
+	}
+
+	// the queue length is reported per tenant
+	var queued float64
+	if family, ok := families[a.cfg.QueueLengthMetric]; ok {
+		for _, m := range family.GetMetric() {
+			queued += m.GetGauge().GetValue()
+		}
+	}
+	return queued, nil
+}
@@ -0,0 +1,134 @@
+package querier
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/grafana/dskit/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+)
+
+// fakeWorkers creates idle services in place of frontend workers and tracks the queries they'd process
+type fakeWorkers struct {
+	mtx     sync.Mutex
+	running map[services.Service]int
+}
+
+func (f *fakeWorkers) newWorker(concurrency int) (services.Service, error) {
+	var worker services.Service
+	worker = services.NewIdleService(func(context.Context) error {
+		f.mtx.Lock()
+		defer f.mtx.Unlock()
+		f.running[worker] = concurrency
+		return nil
+	}, func(error) error {
+		f.mtx.Lock()
+		defer f.mtx.Unlock()
+		delete(f.running, worker)
+		return nil
+	})
+	return worker, nil
+}
+
+// concurrency returns the number of running workers and the queries they process at once
+func (f *fakeWorkers) concurrency() (int, int) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	total := 0
+	for _, c := range f.running {
+		total += c
+	}
+	return len(f.running), total
+}
+
+func TestWorkerPool(t *testing.T) {
+	f := &fakeWorkers{running: map[services.Service]int{}}
+	p := newWorkerPool(f.newWorker)
+
+	require.NoError(t, p.resize(context.Background(), 5, 2))
+	workers, concurrency := f.concurrency()
+	assert.Equal(t, 3, workers)
+	assert.Equal(t, 5, concurrency)
+
+	// shrinking stops the last workers and starts a smaller one for the rest
+	require.NoError(t, p.resize(context.Background(), 2, 2))
+	workers, concurrency = f.concurrency()
+	assert.Equal(t, 1, workers)
+	assert.Equal(t, 2, concurrency)
+
+	require.NoError(t, p.resize(context.Background(), 0, 2))
+	workers, concurrency = f.concurrency()
+	assert.Equal(t, 0, workers)
+	assert.Equal(t, 0, concurrency)
+}
+
+func TestAdaptiveConcurrency(t *testing.T) {
+	var queued atomic.Int64
+	var fail atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprintf(w, "# TYPE cortex_query_frontend_queue_length gauge\n")
+		fmt.Fprintf(w, "cortex_query_frontend_queue_length{user=\"a\"} %d\n", queued.Load())
+		fmt.Fprintf(w, "cortex_query_frontend_queue_length{user=\"b\"} 1\n")
+	}))
+	defer srv.Close()
+
+	f := &fakeWorkers{running: map[services.Service]int{}}
+	a := newAdaptiveConcurrency(AdaptiveConcurrencyConfig{
+		MinConcurrentQueries: 2,
+		MetricsURLs:          []string{srv.URL},
+		QueueLengthMetric:    "cortex_query_frontend_queue_length",
+		PollInterval:         time.Second,
+		ScaleUpQueueLength:   5,
+		ScaleStep:            2,
+	}, 5, f.newWorker)
+	require.NoError(t, a.starting(context.Background()))
+	assert.Equal(t, 2, a.limit)
+
+	// the workers pull as many queries at once as the limit
+	assertConcurrency := func(expected int) {
+		assert.Equal(t, expected, a.limit)
+		_, concurrency := f.concurrency()
+		assert.Equal(t, expected, concurrency)
+	}
+
+	// the queue length of all tenants counts
+	queued.Store(5)
+	require.NoError(t, a.poll(context.Background()))
+	assertConcurrency(4)
+	require.NoError(t, a.poll(context.Background()))
+	assertConcurrency(5)
+
+	// the limit is kept if the queue can't be read
+	fail.Store(true)
+	require.NoError(t, a.poll(context.Background()))
+	assertConcurrency(5)
+	fail.Store(false)
+
+	// a short queue keeps the limit
+	queued.Store(3)
+	require.NoError(t, a.poll(context.Background()))
+	assertConcurrency(5)
+
+	// an empty queue shrinks it down to the min
+	require.NoError(t, a.adjust(context.Background(), 0))
+	assertConcurrency(3)
+	require.NoError(t, a.adjust(context.Background(), 0))
+	assertConcurrency(2)
+
+	// all workers are stopped with the querier
+	require.NoError(t, a.stopping(nil))
+	workers, _ := f.concurrency()
+	assert.Equal(t, 0, workers)
+}
@@ -0,0 +1,221 @@
+package querier
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/opentracing/opentracing-go"
+	ot_log "github.com/opentracing/opentracing-go/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/weaveworks/common/user"
+	"go.uber.org/atomic"
+
+	"github.com/grafana/tempo/pkg/boundedwaitgroup"
+	"github.com/grafana/tempo/pkg/tempopb"
+	"github.com/grafana/tempo/pkg/util"
+)
+
+// The statuses of the traces of a batch trace by id query.
+const (
+	BatchStatusFound    = "found"
+	BatchStatusNotFound = "not_found"
+	BatchStatusFailed   = "failed"
+	// BatchStatusSizeLimitExceeded is returned for traces that were found after the traces returned so far exceeded
+	// the max bytes of a batch. They can be queried again in another batch.
+	BatchStatusSizeLimitExceeded = "size_limit_exceeded"
+
+	// the max length of a trace id in a batch request, a hex encoded 128 bit id in quotes followed by a comma
+	batchTraceIDBytes = 35
+)
+
+var metricBatchTraceLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "tempo",
+	Name:      "querier_batch_trace_lookups_total",
+	Help:      "The total number of traces looked up by batch trace by id queries by status.",
+}, []string{"status"})
+
+// TraceByIDBatchHandler is a http.HandlerFunc to retrieve many traces at once. The trace ids are posted as a
+// TraceByIDBatchRequest, either as json or, with a Content-Type of application/protobuf, as protobuf. The traces are
+// looked up concurrently and every distinct trace id gets a result with its status in the returned
+// TraceByIDBatchResponse. The mode, blockStart and blockEnd parameters apply to every trace like for TraceByIDHandler.
+func (q *Querier) TraceByIDBatchHandler(w http.ResponseWriter, r *http.Request) {
+	span, ctx := opentracing.StartSpanFromContext(r.Context(), "Querier.TraceByIDBatchHandler")
+	defer span.Finish()
+
+	userID, err := user.ExtractOrgID(ctx)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	blockStart, blockEnd, queryMode, err := validateAndSanitizeRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !q.allowQuery(r, userID) {
+		http.Error(w, q.rateLimitedError(userID), http.StatusTooManyRequests)
+		return
+	}
+
+	batchReq, err := q.parseBatchRequest(w, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// duplicate ids are looked up once, the results keep the order of the request
+	var hexIDs []string
+	var traceIDs [][]byte
+	seen := map[string]struct{}{}
+	for _, hexID := range batchReq.TraceIDs {
+		id, err := util.HexStringToTraceID(hexID)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid trace id %s: %v", hexID, err), http.StatusBadRequest)
+			return
+		}
+
+		if _, ok := seen[string(id)]; ok {
+			continue
+		}
+		seen[string(id)] = struct{}{}
+		hexIDs = append(hexIDs, hexID)
+		traceIDs = append(traceIDs, id)
+	}
+
+	if len(traceIDs) == 0 {
+		http.Error(w, "no trace ids", http.StatusBadRequest)
+		return
+	}
+	if max := q.cfg.TraceBatch.MaxTraceIDs; max > 0 && len(traceIDs) > max {
+		http.Error(w, fmt.Sprintf("too many trace ids: %d. the max is %d", len(traceIDs), max), http.StatusBadRequest)
+		return
+	}
+	span.LogFields(ot_log.Int("traceIDs", len(traceIDs)), ot_log.String("queryMode", queryMode))
+
+	resp := &tempopb.TraceByIDBatchResponse{
+		Results: q.findTracesByID(ctx, userID, hexIDs, traceIDs, blockStart, blockEnd, queryMode),
+	}
+
+	if r.Header.Get(util.AcceptHeaderKey) == util.ProtobufTypeHeaderValue {
+		span.SetTag("response marshalling format", util.ProtobufTypeHeaderValue)
+		b, err := proto.Marshal(resp)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		_, err = w.Write(b)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	span.SetTag("response marshalling format", util.JSONTypeHeaderValue)
+	marshaller := &jsonpb.Marshaler{}
+	err = marshaller.Marshal(w, resp)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// parseBatchRequest reads the body of a batch request. The body is limited to the size of the max trace ids.
+func (q *Querier) parseBatchRequest(w http.ResponseWriter, r *http.Request) (*tempopb.TraceByIDBatchRequest, error) {
+	body := r.Body
+	if max := q.cfg.TraceBatch.MaxTraceIDs; max > 0 {
+		body = http.MaxBytesReader(w, r.Body, int64(max*batchTraceIDBytes)+1024)
+	}
+
+	b, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading request body: %w", err)
+	}
+
+	req := &tempopb.TraceByIDBatchRequest{}
+	if r.Header.Get("Content-Type") == util.ProtobufTypeHeaderValue {
+		err = proto.Unmarshal(b, req)
+	} else {
+		err = jsonpb.UnmarshalString(string(b), req)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error parsing request body: %w", err)
+	}
+
+	return req, nil
+}
+
+// findTracesByID looks up the traces with at most MaxConcurrentQueries lookups at a time. Each lookup has the query
+// timeout. Once the traces found exceed the max bytes of a batch, the traces found afterwards are left out.
+func (q *Querier) findTracesByID(ctx context.Context, userID string, hexIDs []string, traceIDs [][]byte, blockStart string, blockEnd string, queryMode string) []*tempopb.TraceByIDBatchResult {
+	concurrency := q.cfg.MaxConcurrentQueries
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	results := make([]*tempopb.TraceByIDBatchResult, len(traceIDs))
+	var bytes atomic.Int64
+	bg := boundedwaitgroup.New(uint(concurrency))
+	for i := range traceIDs {
+		bg.Add(1)
+		go func(i int) {
+			defer bg.Done()
+
+			req := &tempopb.TraceByIDRequest{
+				TraceID:    traceIDs[i],
+				BlockStart: blockStart,
+				BlockEnd:   blockEnd,
+				QueryMode:  queryMode,
+			}
+			result := q.findTraceForBatch(ctx, userID, req, &bytes)
+			result.TraceID = hexIDs[i]
+			metricBatchTraceLookups.WithLabelValues(result.Status).Inc()
+			results[i] = result
+		}(i)
+	}
+	bg.Wait()
+
+	return results
+}
+
+func (q *Querier) findTraceForBatch(ctx context.Context, userID string, req *tempopb.TraceByIDRequest, bytes *atomic.Int64) *tempopb.TraceByIDBatchResult {
+	var resp *tempopb.TraceByIDResponse
+	var cached bool
+	if q.traceCache != nil {
+		resp, cached = q.traceCache.get(newTraceCacheKey(userID, req, traceTimeRange{}))
+	}
+
+	if !cached {
+		ctx, cancel := context.WithTimeout(ctx, q.cfg.QueryTimeout)
+		defer cancel()
+
+		var err error
+		resp, _, _, err = q.findTraceByID(ctx, req, q.cfg.ReplicaVerification.Enabled, false)
+		if err != nil {
+			countRejectedTrace(userID, err)
+			return &tempopb.TraceByIDBatchResult{Status: BatchStatusFailed, Error: err.Error()}
+		}
+	}
+
+	if resp.Trace == nil || len(resp.Trace.Batches) == 0 {
+		return &tempopb.TraceByIDBatchResult{Status: BatchStatusNotFound, Partial: resp.Partial}
+	}
+
+	size := int64(resp.Trace.Size())
+	if max := int64(q.cfg.TraceBatch.MaxBytes); max > 0 && bytes.Add(size) > max {
+		bytes.Sub(size)
+		return &tempopb.TraceByIDBatchResult{Status: BatchStatusSizeLimitExceeded}
+	}
+
+	return &tempopb.TraceByIDBatchResult{
+		Status:         BatchStatusFound,
+		Trace:          resp.Trace,
+		TraceTruncated: resp.TraceTruncated,
+		Partial:        resp.Partial,
+		Warnings:       resp.Warnings,
+	}
+}
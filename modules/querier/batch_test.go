@@ -0,0 +1,131 @@
+package querier
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/tempo/modules/storage"
+	"github.com/grafana/tempo/pkg/tempopb"
+	"github.com/grafana/tempo/pkg/util"
+	"github.com/grafana/tempo/pkg/util/test"
+	"github.com/grafana/tempo/tempodb"
+	"github.com/grafana/tempo/tempodb/encoding/common"
+)
+
+// batchStore returns a different trace or error for every trace id.
+type batchStore struct {
+	storage.Store
+	traces map[string]*tempopb.Trace
+	errs   map[string]error
+}
+
+func (s *batchStore) Find(_ context.Context, _ string, id common.ID, _ string, _ string, _ time.Time, _ time.Time, _ int) ([][]byte, []string, tempodb.FindMetrics, error) {
+	if err := s.errs[string(id)]; err != nil {
+		return nil, nil, tempodb.FindMetrics{}, err
+	}
+	trace, ok := s.traces[string(id)]
+	if !ok {
+		return nil, nil, tempodb.FindMetrics{}, nil
+	}
+
+	b, err := proto.Marshal(trace)
+	if err != nil {
+		return nil, nil, tempodb.FindMetrics{}, err
+	}
+	return [][]byte{b}, []string{""}, tempodb.FindMetrics{}, nil
+}
+
+func (s *batchStore) FindEach(ctx context.Context, tenantID string, id common.ID, blockStart string, blockEnd string, start time.Time, end time.Time, maxBlocks int, fn tempodb.FoundFunc) (tempodb.FindMetrics, error) {
+	return foundEach(fn)(s.Find(ctx, tenantID, id, blockStart, blockEnd, start, end, maxBlocks))
+}
+
+func (s *batchStore) FindSkeleton(context.Context, string, common.ID, string, string, time.Time, time.Time) ([][]byte, []string, error) {
+	return nil, nil, nil
+}
+
+func TestTraceByIDBatchHandler(t *testing.T) {
+	traceID := func(b byte) []byte {
+		id := make([]byte, 16)
+		id[15] = b
+		return id
+	}
+	found := test.MakeTraceWithSpanCount(1, 10, traceID(0x01))
+	store := &batchStore{
+		traces: map[string]*tempopb.Trace{
+			string(traceID(0x01)): found,
+			string(traceID(0x02)): test.MakeTraceWithSpanCount(1, 10, traceID(0x02)),
+		},
+		errs: map[string]error{
+			string(traceID(0x04)): errors.New("backend down"),
+		},
+	}
+
+	request := func(cfg TraceBatchConfig, body string, contentType string) *httptest.ResponseRecorder {
+		q := zoneQuerier(Config{QueryTimeout: 10 * time.Second, MaxConcurrentQueries: 2, TraceBatch: cfg}, nil)
+		q.store = store
+
+		r := httptest.NewRequest(http.MethodPost, "/api/traces:batchGet?mode=blocks", bytes.NewBufferString(body))
+		r = r.WithContext(user.InjectOrgID(r.Context(), "tenant"))
+		r.Header.Set("Content-Type", contentType)
+		r.Header.Set(util.AcceptHeaderKey, util.ProtobufTypeHeaderValue)
+
+		w := httptest.NewRecorder()
+		q.TraceByIDBatchHandler(w, r)
+		return w
+	}
+	results := func(w *httptest.ResponseRecorder) map[string]*tempopb.TraceByIDBatchResult {
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		resp := &tempopb.TraceByIDBatchResponse{}
+		require.NoError(t, proto.Unmarshal(w.Body.Bytes(), resp))
+
+		results := map[string]*tempopb.TraceByIDBatchResult{}
+		for _, r := range resp.Results {
+			results[r.TraceID] = r
+		}
+		return results
+	}
+
+	// every distinct trace id gets a result, ids are padded like in trace by id queries
+	actual := results(request(TraceBatchConfig{}, `{"traceIDs": ["01", "03", "04", "`+hex.EncodeToString(traceID(0x01))+`"]}`, util.JSONTypeHeaderValue))
+	require.Len(t, actual, 3)
+	assert.Equal(t, BatchStatusFound, actual["01"].Status)
+	assert.ElementsMatch(t, spanIDs(found), spanIDs(actual["01"].Trace))
+	assert.Equal(t, BatchStatusNotFound, actual["03"].Status)
+	assert.Nil(t, actual["03"].Trace)
+	assert.Equal(t, BatchStatusFailed, actual["04"].Status)
+	assert.Contains(t, actual["04"].Error, "backend down")
+
+	// protobuf requests
+	b, err := proto.Marshal(&tempopb.TraceByIDBatchRequest{TraceIDs: []string{"02"}})
+	require.NoError(t, err)
+	actual = results(request(TraceBatchConfig{}, string(b), util.ProtobufTypeHeaderValue))
+	require.Len(t, actual, 1)
+	assert.Equal(t, BatchStatusFound, actual["02"].Status)
+
+	// traces over the max bytes are left out, only one of the traces fits
+	maxBytes := found.Size()
+	if size := store.traces[string(traceID(0x02))].Size(); size > maxBytes {
+		maxBytes = size
+	}
+	actual = results(request(TraceBatchConfig{MaxBytes: maxBytes}, `{"traceIDs": ["01", "02"]}`, util.JSONTypeHeaderValue))
+	require.Len(t, actual, 2)
+	statuses := []string{actual["01"].Status, actual["02"].Status}
+	assert.ElementsMatch(t, []string{BatchStatusFound, BatchStatusSizeLimitExceeded}, statuses)
+
+	// invalid requests
+	assert.Equal(t, http.StatusBadRequest, request(TraceBatchConfig{}, `{"traceIDs": []}`, util.JSONTypeHeaderValue).Code)
+	assert.Equal(t, http.StatusBadRequest, request(TraceBatchConfig{}, `{"traceIDs": ["zz"]}`, util.JSONTypeHeaderValue).Code)
+	assert.Equal(t, http.StatusBadRequest, request(TraceBatchConfig{MaxTraceIDs: 1}, `{"traceIDs": ["01", "02"]}`, util.JSONTypeHeaderValue).Code)
+	assert.Equal(t, http.StatusBadRequest, request(TraceBatchConfig{}, `not json`, util.JSONTypeHeaderValue).Code)
+}
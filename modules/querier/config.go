@@ -2,6 +2,8 @@ package querier
 
 import (
 	"flag"
+	"os"
+	"path/filepath"
 	"time"
 
 	cortex_worker "github.com/cortexproject/cortex/pkg/querier/worker"
@@ -9,12 +11,137 @@ import (
 	"github.com/grafana/dskit/backoff"
 )
 
+// zoneEnvVar is read for the zone of the querier if not configured
+const zoneEnvVar = "TEMPO_ZONE"
+
 // Config for a querier.
 type Config struct {
 	QueryTimeout         time.Duration        `yaml:"query_timeout"`
 	ExtraQueryDelay      time.Duration        `yaml:"extra_query_delay,omitempty"`
 	MaxConcurrentQueries int                  `yaml:"max_concurrent_queries"`
 	Worker               cortex_worker.Config `yaml:"frontend_worker"`
+
+	ReplicaVerification ReplicaVerificationConfig `yaml:"replica_verification"`
+
+	// Zone is the availability zone of the querier. Defaults to the TEMPO_ZONE environment variable
+	// which can be populated with the downward API.
+	Zone string `yaml:"zone"`
+	// If true trace by id and search requests are first sent only to ingesters in the querier's zone.
+	// Ingesters in other zones are queried if a local ingester fails, times out or nothing is found.
+	PreferLocalZone  bool          `yaml:"prefer_local_zone"`
+	LocalZoneTimeout time.Duration `yaml:"local_zone_timeout"`
+
+	TraceSpill TraceSpillConfig `yaml:"trace_spill"`
+
+	// Traces larger than MaxTraceBytes are truncated to their earliest spans and returned with a flag instead of
+	// whole. 0 disables it.
+	MaxTraceBytes int `yaml:"max_trace_bytes"`
+
+	// TraceDeletionEnabled registers the admin endpoint that deletes traces which have not been flushed yet from
+	// the ingesters.
+	TraceDeletionEnabled bool `yaml:"trace_deletion_enabled"`
+
+	// StructuredNotFound returns a TraceNotFoundResponse with the 404 of a trace by id query that didn't find the
+	// trace instead of a plain text message. It is opt-in for one release so clients parsing the message can adapt.
+	StructuredNotFound bool `yaml:"structured_not_found"`
+
+	// BlocklistFlushEnabled registers the admin endpoint that polls the blocklist without waiting for the next poll
+	// cycle.
+	BlocklistFlushEnabled bool `yaml:"blocklist_flush_enabled"`
+
+	// ExternalEndpoints are the base urls of the query-frontends of other Tempo clusters. Trace by id and search queries
+	// are sent to them as well and their results are combined with the local ones. An endpoint that fails or doesn't
+	// answer within ExternalEndpointTimeout is left out of the results.
+	ExternalEndpoints       []string      `yaml:"external_endpoints"`
+	ExternalEndpointTimeout time.Duration `yaml:"external_endpoint_timeout"`
+
+	TraceCache TraceCacheConfig `yaml:"trace_cache"`
+
+	TraceBatch TraceBatchConfig `yaml:"trace_batch"`
+
+	AdaptiveConcurrency AdaptiveConcurrencyConfig `yaml:"adaptive_concurrency"`
+
+	Search SearchConfig `yaml:"search"`
+}
+
+// SearchConfig sets deadlines for the fan-out of trace by id queries and searches to the ingesters and to the backend,
+// within the QueryTimeout of the whole query. Ingesters should answer in milliseconds and can be given a short timeout
+// while the backend gets the remaining time. The stricter of a timeout and the time left of the query applies. If a
+// query searches both and one times out, the results of the other are returned as partial with a warning. 0 leaves the
+// time left of the query.
+type SearchConfig struct {
+	QueryIngestersTimeout time.Duration `yaml:"query_ingesters_timeout"`
+	QueryBackendTimeout   time.Duration `yaml:"query_backend_timeout"`
+
+	// QueryIngestersUntil is how long the ingesters keep a trace before it is only in the backend. Trace by id
+	// queries with an end hint older than it don't query the ingesters. It should cover the max_block_duration and
+	// complete_block_timeout of the ingesters. 0 always queries the ingesters.
+	QueryIngestersUntil time.Duration `yaml:"query_ingesters_until"`
+
+	// SlowerTierGrace is how long the ingesters or the backend still get to answer a trace by id query once the other
+	// one found the trace. The search is cancelled afterwards and the trace is returned without its parts, as a
+	// partial trace with a warning that isn't cached. 0 waits for both.
+	SlowerTierGrace time.Duration `yaml:"slower_tier_grace"`
+
+	// ConcurrentBlocks is the number of backend blocks a search searches at once. Every one of the
+	// MaxConcurrentQueries searches of a querier searches this many blocks at once.
+	ConcurrentBlocks int `yaml:"concurrent_blocks"`
+}
+
+// AdaptiveConcurrencyConfig makes the number of queries of the query-frontend a querier runs at once follow the queue
+// of the query-frontends. The querier pulls only as many queries at once as the current limit, from frontend workers
+// of up to ScaleStep queries each that are started and stopped as the limit changes. Every PollInterval the QueueLengthMetric is read from the MetricsURLs of the frontends,
+// or schedulers, and summed. The limit grows by ScaleStep if more than ScaleUpQueueLength queries are queued and
+// shrinks by ScaleStep if none are, between MinConcurrentQueries and MaxConcurrentQueries.
+type AdaptiveConcurrencyConfig struct {
+	Enabled              bool          `yaml:"enabled"`
+	MinConcurrentQueries int           `yaml:"min_concurrent_queries"`
+	MetricsURLs          []string      `yaml:"metrics_urls"`
+	QueueLengthMetric    string        `yaml:"queue_length_metric"`
+	PollInterval         time.Duration `yaml:"poll_interval"`
+	ScaleUpQueueLength   int           `yaml:"scale_up_queue_length"`
+	ScaleStep            int           `yaml:"scale_step"`
+}
+
+// TraceBatchConfig limits batch trace by id queries. A batch holds at most MaxTraceIDs trace ids and returns at most
+// MaxBytes of traces, the traces found after the limit is reached are left out of the response. 0 disables a limit.
+type TraceBatchConfig struct {
+	MaxTraceIDs int `yaml:"max_trace_ids"`
+	MaxBytes    int `yaml:"max_bytes"`
+}
+
+// TraceCacheConfig controls the in-process cache of the traces returned by trace by id queries. Up to MaxBytes of
+// marshalled responses are cached for TTL. Traces found in the ingesters and partial traces are not cached because
+// they may still change. A max bytes or ttl of 0 disables the cache.
+type TraceCacheConfig struct {
+	MaxBytes int           `yaml:"max_bytes"`
+	TTL      time.Duration `yaml:"ttl"`
+}
+
+// TraceSpillConfig controls assembling very large traces on disk. Once the partial traces found in the backend exceed
+// ThresholdBytes they are moved to a temporary file in Path, and every later one is added to it as soon as it's found.
+// The response is streamed from there. Only
+// protobuf responses, as requested by the query frontend, are spilled.
+type TraceSpillConfig struct {
+	ThresholdBytes int    `yaml:"threshold_bytes"`
+	Path           string `yaml:"path"`
+}
+
+// ReplicaVerificationConfig controls comparing the traces returned by ingester replicas. This is a debug mode
+// and is expensive. If enabled every trace by id query is verified. Admin tenants may opt in per request
+// by setting the X-Tempo-Verify-Replicas header.
+type ReplicaVerificationConfig struct {
+	Enabled      bool     `yaml:"enabled"`
+	AdminTenants []string `yaml:"admin_tenants"`
+}
+
+func (cfg *ReplicaVerificationConfig) allowedForTenant(tenantID string) bool {
+	for _, t := range cfg.AdminTenants {
+		if t == tenantID {
+			return true
+		}
+	}
+	return false
 }
 
 // RegisterFlagsAndApplyDefaults register flags.
@@ -22,6 +149,16 @@ func (cfg *Config) RegisterFlagsAndApplyDefaults(prefix string, f *flag.FlagSet)
 	cfg.QueryTimeout = 10 * time.Second
 	cfg.ExtraQueryDelay = 0
 	cfg.MaxConcurrentQueries = 5
+	cfg.LocalZoneTimeout = 2 * time.Second
+	cfg.ExternalEndpointTimeout = 5 * time.Second
+	cfg.TraceSpill.Path = filepath.Join(os.TempDir(), "tempo-querier-spill")
+	cfg.AdaptiveConcurrency = AdaptiveConcurrencyConfig{
+		MinConcurrentQueries: 1,
+		QueueLengthMetric:    "cortex_query_frontend_queue_length",
+		PollInterval:         5 * time.Second,
+		ScaleUpQueueLength:   0,
+		ScaleStep:            1,
+	}
 	cfg.Worker = cortex_worker.Config{
 		MatchMaxConcurrency:   true,
 		MaxConcurrentRequests: cfg.MaxConcurrentQueries,
@@ -40,4 +177,22 @@ func (cfg *Config) RegisterFlagsAndApplyDefaults(prefix string, f *flag.FlagSet)
 	}
 
 	f.StringVar(&cfg.Worker.FrontendAddress, prefix+".frontend-address", "", "Address of query frontend service, in host:port format.")
+	f.StringVar(&cfg.Worker.SchedulerAddress, prefix+".scheduler-address", "", "Address of the query-schedulers, in host:port format. Every address the host resolves to is used. Takes precedence over the frontend address.")
+	f.StringVar(&cfg.Zone, prefix+".zone", os.Getenv(zoneEnvVar), "Availability zone of the querier.")
+	f.IntVar(&cfg.TraceSpill.ThresholdBytes, prefix+".trace-spill-threshold-bytes", 0, "Size of the partial traces found in the backend above which the trace is assembled on disk. 0 disables spilling.")
+	f.IntVar(&cfg.MaxTraceBytes, prefix+".max-trace-bytes", 0, "Size above which traces are truncated to their earliest spans. 0 disables truncation.")
+	f.BoolVar(&cfg.PreferLocalZone, prefix+".prefer-local-zone", false, "Query ingesters in the querier's zone first and fall back to other zones.")
+	f.IntVar(&cfg.TraceCache.MaxBytes, prefix+".trace-cache.max-bytes", 100<<20, "Size of the cache of recently returned traces. 0 disables the cache.")
+	f.DurationVar(&cfg.TraceCache.TTL, prefix+".trace-cache.ttl", time.Minute, "Time traces are kept in the cache of recently returned traces.")
+	f.IntVar(&cfg.TraceBatch.MaxTraceIDs, prefix+".trace-batch.max-trace-ids", 1000, "Max number of trace ids of a batch trace by id query. 0 disables the limit.")
+	f.IntVar(&cfg.TraceBatch.MaxBytes, prefix+".trace-batch.max-bytes", 64<<20, "Max size of the traces returned by a batch trace by id query. 0 disables the limit.")
+	f.DurationVar(&cfg.Search.QueryIngestersTimeout, prefix+".search.query-ingesters-timeout", 0, "Timeout of the ingester lookups of trace by id queries and searches. 0 leaves the time left of the query.")
+	f.DurationVar(&cfg.Search.QueryBackendTimeout, prefix+".search.query-backend-timeout", 0, "Timeout of the backend lookups of trace by id queries and searches. 0 leaves the time left of the query.")
+	f.DurationVar(&cfg.Search.QueryIngestersUntil, prefix+".search.query-ingesters-until", 2*time.Hour, "Age of the end hint of trace by id queries after which the ingesters are not queried. 0 always queries the ingesters.")
+	f.DurationVar(&cfg.Search.SlowerTierGrace, prefix+".search.slower-tier-grace", time.Second, "How long the ingesters or the backend still get to answer a trace by id query once the other one found the trace. 0 waits for both.")
+	f.IntVar(&cfg.Search.ConcurrentBlocks, prefix+".search.concurrent-blocks", 4, "Number of backend blocks a search searches at once.")
+	f.BoolVar(&cfg.AdaptiveConcurrency.Enabled, prefix+".adaptive-concurrency.enabled", false, "Adjust the number of queries of the query-frontend run at once to the queue length of the query-frontends.")
+	f.BoolVar(&cfg.TraceDeletionEnabled, prefix+".trace-deletion-enabled", false, "Enable the admin endpoint that deletes traces not yet flushed to the backend from the ingesters.")
+	f.BoolVar(&cfg.StructuredNotFound, prefix+".structured-not-found", false, "Return a json body with the checked ingesters and blocks with the 404 of trace by id queries that didn't find the trace.")
+	f.BoolVar(&cfg.BlocklistFlushEnabled, prefix+".blocklist-flush-enabled", false, "Enable the admin endpoint that polls the blocklist without waiting for the next poll cycle.")
 }
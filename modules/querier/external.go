@@ -0,0 +1,265 @@
+package querier
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/util/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+	"github.com/grafana/tempo/pkg/util"
+)
+
+const (
+	opExternalTraceByID = "trace_by_id"
+	opExternalSearch    = "search"
+
+	externalPathTraces = "/api/traces"
+	externalPathSearch = "/api/search"
+)
+
+var (
+	metricExternalRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "tempo",
+		Name:      "querier_external_request_duration_seconds",
+		Help:      "Time spent on the requests to external endpoints.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"endpoint", "operation"})
+	metricExternalRequestFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "querier_external_request_failures_total",
+		Help:      "The total number of failed requests to external endpoints.",
+	}, []string{"endpoint", "operation"})
+)
+
+// externalEndpoints queries the query-frontends of other Tempo clusters. A failing endpoint doesn't fail the query, its
+// results are left out and a warning is added to the externalQuery of the context.
+type externalEndpoints struct {
+	endpoints []*url.URL
+	timeout   time.Duration
+	client    *http.Client
+}
+
+func newExternalEndpoints(endpoints []string, timeout time.Duration) (*externalEndpoints, error) {
+	if len(endpoints) == 0 {
+		return nil, nil
+	}
+
+	e := &externalEndpoints{
+		timeout: timeout,
+		client:  &http.Client{},
+	}
+	for _, endpoint := range endpoints {
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			return nil, fmt.Errorf("invalid external endpoint %s: %w", endpoint, err)
+		}
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("invalid external endpoint %s: expected an http or https url", endpoint)
+		}
+		e.endpoints = append(e.endpoints, u)
+	}
+
+	return e, nil
+}
+
+// externalQuery is put into the context of a request by the http handlers if the external endpoints should be
+// queried. Requests sent by another cluster don't get one so clusters that query each other don't loop.
+type externalQuery struct {
+	mtx      sync.Mutex
+	warnings []string
+}
+
+type externalQueryKey struct{}
+
+func withExternalQuery(ctx context.Context, q *externalQuery) context.Context {
+	return context.WithValue(ctx, externalQueryKey{}, q)
+}
+
+func externalQueryFromContext(ctx context.Context) *externalQuery {
+	q, _ := ctx.Value(externalQueryKey{}).(*externalQuery)
+	return q
+}
+
+func (q *externalQuery) addWarning(warning string) {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+	q.warnings = append(q.warnings, warning)
+}
+
+// allWarnings returns the warnings of the external endpoints that failed.
+func (q *externalQuery) allWarnings() []string {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+	return append([]string(nil), q.warnings...)
+}
+
+type externalTraceResult struct {
+	traces  []*tempopb.Trace
+	partial bool
+}
+
+// findTraceByID looks up the trace in all external endpoints. partial is true if an endpoint failed or returned a
+// partial trace.
+func (e *externalEndpoints) findTraceByID(ctx context.Context, query *externalQuery, userID string, traceID []byte) externalTraceResult {
+	var mtx sync.Mutex
+	var result externalTraceResult
+
+	e.forEachEndpoint(func(endpoint *url.URL) {
+		resp, err := e.get(ctx, endpoint, opExternalTraceByID, path.Join(externalPathTraces, hex.EncodeToString(traceID)), nil, userID, util.ProtobufTypeHeaderValue)
+
+		var trace *tempopb.Trace
+		if err == nil && resp.statusCode == http.StatusOK {
+			trace = &tempopb.Trace{}
+			err = proto.Unmarshal(resp.body, trace)
+			// an unknown or invalid ingestion time leaves it at 0
+			trace.IngestionTimeUnixNano, _ = strconv.ParseUint(resp.header.Get(IngestionTimeHeader), 10, 64)
+		}
+
+		mtx.Lock()
+		defer mtx.Unlock()
+		if err != nil {
+			e.fail(query, endpoint, opExternalTraceByID, err)
+			result.partial = true
+			return
+		}
+		if trace != nil {
+			result.traces = append(result.traces, trace)
+		}
+		result.partial = result.partial || resp.header.Get(TracePartialHeader) == "true"
+	})
+
+	return result
+}
+
+// search sends the search to all external endpoints. partial is true if an endpoint failed or returned partial
+// results.
+func (e *externalEndpoints) search(ctx context.Context, query *externalQuery, userID string, params url.Values) ([]*tempopb.SearchResponse, bool) {
+	var mtx sync.Mutex
+	var responses []*tempopb.SearchResponse
+	var partial bool
+
+	e.forEachEndpoint(func(endpoint *url.URL) {
+		resp, err := e.get(ctx, endpoint, opExternalSearch, externalPathSearch, params, userID, util.JSONTypeHeaderValue)
+		if err == nil && resp.statusCode != http.StatusOK {
+			err = fmt.Errorf("unexpected status code %d", resp.statusCode)
+		}
+
+		var searchResp *tempopb.SearchResponse
+		if err == nil {
+			searchResp = &tempopb.SearchResponse{}
+			err = jsonpb.Unmarshal(bytes.NewReader(resp.body), searchResp)
+		}
+
+		mtx.Lock()
+		defer mtx.Unlock()
+		if err != nil {
+			e.fail(query, endpoint, opExternalSearch, err)
+			partial = true
+			return
+		}
+		responses = append(responses, searchResp)
+		partial = partial || resp.header.Get(SearchPartialHeader) == "true"
+	})
+
+	return responses, partial
+}
+
+func (e *externalEndpoints) forEachEndpoint(f func(endpoint *url.URL)) {
+	var wg sync.WaitGroup
+	for _, endpoint := range e.endpoints {
+		wg.Add(1)
+		go func(endpoint *url.URL) {
+			defer wg.Done()
+			f(endpoint)
+		}(endpoint)
+	}
+	wg.Wait()
+}
+
+type externalResponse struct {
+	statusCode int
+	header     http.Header
+	body       []byte
+}
+
+// get sends a request for the tenant to the endpoint. A not found response is not an error.
+func (e *externalEndpoints) get(ctx context.Context, endpoint *url.URL, op string, p string, params url.Values, userID string, accept string) (*externalResponse, error) {
+	start := time.Now()
+	defer func() {
+		metricExternalRequestDuration.WithLabelValues(endpoint.Host, op).Observe(time.Since(start).Seconds())
+	}()
+
+	if e.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.timeout)
+		defer cancel()
+	}
+
+	u := *endpoint
+	u.Path = path.Join(u.Path, p)
+	u.RawQuery = params.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set(user.OrgIDHeaderName, userID)
+	req.Header.Set(util.AcceptHeaderKey, accept)
+	req.Header.Set(FederatedHeader, "true")
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return nil, fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	return &externalResponse{
+		statusCode: resp.StatusCode,
+		header:     resp.Header,
+		body:       body,
+	}, nil
+}
+
+func (e *externalEndpoints) fail(query *externalQuery, endpoint *url.URL, op string, err error) {
+	metricExternalRequestFailures.WithLabelValues(endpoint.Host, op).Inc()
+	level.Warn(log.Logger).Log("msg", "external endpoint failed", "endpoint", endpoint.Host, "operation", op, "err", err)
+	query.addWarning(fmt.Sprintf("external endpoint %s failed: %v", endpoint.Host, err))
+}
+
+// externalSearchParams returns the parameters of a search to send to the external endpoints. The shard and query mode
+// only apply to the local cluster.
+func externalSearchParams(params url.Values) url.Values {
+	external := url.Values{}
+	for k, v := range params {
+		if k == SearchShardKey || k == SearchShardsKey || k == QueryModeKey {
+			continue
+		}
+		external[k] = v
+	}
+	return external
+}
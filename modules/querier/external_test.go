@@ -0,0 +1,235 @@
+package querier
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/ring"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/tempo/pkg/model"
+	"github.com/grafana/tempo/pkg/tempopb"
+	"github.com/grafana/tempo/pkg/util"
+	"github.com/grafana/tempo/pkg/util/test"
+)
+
+// mockExternalEndpoint records the requests of a querier and answers them with a fixed response.
+type mockExternalEndpoint struct {
+	*httptest.Server
+
+	mtx  sync.Mutex
+	reqs []*http.Request
+}
+
+func newMockExternalEndpoint(t *testing.T, status int, header http.Header, body []byte) *mockExternalEndpoint {
+	m := &mockExternalEndpoint{}
+	m.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.mtx.Lock()
+		m.reqs = append(m.reqs, r)
+		m.mtx.Unlock()
+
+		for k, v := range header {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(status)
+		_, _ = w.Write(body)
+	}))
+	t.Cleanup(m.Close)
+	return m
+}
+
+func (m *mockExternalEndpoint) requests() []*http.Request {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	return append([]*http.Request(nil), m.reqs...)
+}
+
+func externalQuerier(t *testing.T, ingester *mockIngesterClient, endpoints ...*mockExternalEndpoint) *Querier {
+	var urls []string
+	for _, e := range endpoints {
+		urls = append(urls, e.URL+"/tempo")
+	}
+
+	q := zoneQuerier(Config{QueryTimeout: 10 * time.Second}, map[string]*mockIngesterClient{"a": ingester})
+	q.ring = &mockReadRing{replicationSet: ring.ReplicationSet{Instances: []ring.InstanceDesc{{Addr: "a"}}}}
+
+	var err error
+	q.external, err = newExternalEndpoints(urls, time.Second)
+	require.NoError(t, err)
+	return q
+}
+
+func TestExternalTraceByID(t *testing.T) {
+	traceID := make([]byte, 16)
+	_, err := rand.Read(traceID)
+	require.NoError(t, err)
+	localTrace := test.MakeTrace(2, traceID)
+	externalTrace := test.MakeTrace(3, traceID)
+
+	b, err := proto.Marshal(externalTrace)
+	require.NoError(t, err)
+	found := newMockExternalEndpoint(t, http.StatusOK, http.Header{IngestionTimeHeader: []string{"10"}}, b)
+	notFound := newMockExternalEndpoint(t, http.StatusNotFound, nil, nil)
+	failing := newMockExternalEndpoint(t, http.StatusInternalServerError, nil, []byte("unavailable"))
+
+	ingesterTrace := proto.Clone(localTrace).(*tempopb.Trace)
+	ingesterTrace.IngestionTimeUnixNano = 20
+	q := externalQuerier(t, &mockIngesterClient{trace: ingesterTrace}, found, notFound, failing)
+
+	request := func(mode string, federated bool) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/api/traces/"+hex.EncodeToString(traceID)+"?mode="+mode, nil)
+		r = mux.SetURLVars(r, map[string]string{util.TraceIDVar: hex.EncodeToString(traceID)})
+		r = r.WithContext(user.InjectOrgID(r.Context(), "tenant"))
+		r.Header.Set(util.AcceptHeaderKey, util.ProtobufTypeHeaderValue)
+		if federated {
+			r.Header.Set(FederatedHeader, "true")
+		}
+
+		w := httptest.NewRecorder()
+		q.TraceByIDHandler(w, r)
+		return w
+	}
+
+	// the local and external traces are combined, the failing endpoint is left out with a warning. The earliest
+	// ingestion time is returned in a header instead of the body
+	w := request(QueryModeIngesters, false)
+	require.Equal(t, http.StatusOK, w.Code)
+	actual := &tempopb.Trace{}
+	require.NoError(t, proto.Unmarshal(w.Body.Bytes(), actual))
+	expected, _, _, _ := model.CombineTraceProtos(proto.Clone(localTrace).(*tempopb.Trace), proto.Clone(externalTrace).(*tempopb.Trace))
+	model.SortTrace(expected)
+	model.SortTrace(actual)
+	assert.Equal(t, expected, actual)
+	assert.Equal(t, "true", w.Header().Get(TracePartialHeader))
+	assert.Equal(t, "10", w.Header().Get(IngestionTimeHeader))
+	require.Len(t, w.Header().Values(WarningHeader), 1)
+	assert.Contains(t, w.Header().Get(WarningHeader), failing.Listener.Addr().String())
+
+	// the tenant is propagated and the request is marked so the other cluster doesn't query its external endpoints
+	reqs := found.requests()
+	require.Len(t, reqs, 1)
+	assert.Equal(t, "/tempo/api/traces/"+hex.EncodeToString(traceID), reqs[0].URL.Path)
+	assert.Equal(t, "tenant", reqs[0].Header.Get(user.OrgIDHeaderName))
+	assert.Equal(t, "true", reqs[0].Header.Get(FederatedHeader))
+
+	// requests of other clusters and shards that only search the blocks don't query the external endpoints
+	w = request(QueryModeIngesters, true)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get(TracePartialHeader))
+	assert.Equal(t, "20", w.Header().Get(IngestionTimeHeader))
+	assert.Empty(t, w.Header().Values(WarningHeader))
+
+	q.store = &mockStore{trace: test.MakeTrace(1, traceID)}
+	w = request(QueryModeBlocks, false)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get(TracePartialHeader))
+	assert.Len(t, found.requests(), 1)
+}
+
+func TestExternalSearch(t *testing.T) {
+	var b bytes.Buffer
+	err := (&jsonpb.Marshaler{}).Marshal(&b, &tempopb.SearchResponse{
+		Traces: []*tempopb.TraceSearchMetadata{
+			{TraceID: "b", StartTimeUnixNano: 2},
+			{TraceID: "c", StartTimeUnixNano: 1},
+		},
+		Metrics: &tempopb.SearchMetrics{InspectedTraces: 5},
+	})
+	require.NoError(t, err)
+	partialHeader := http.Header{SearchPartialHeader: []string{"true"}}
+	external := newMockExternalEndpoint(t, http.StatusOK, partialHeader, b.Bytes())
+	failing := newMockExternalEndpoint(t, http.StatusInternalServerError, nil, nil)
+
+	q := externalQuerier(t, &mockIngesterClient{searchTraces: []*tempopb.TraceSearchMetadata{
+		{TraceID: "a", StartTimeUnixNano: 3},
+		{TraceID: "b", StartTimeUnixNano: 2},
+	}}, external, failing)
+
+	request := func(query url.Values) (*httptest.ResponseRecorder, *tempopb.SearchResponse) {
+		r := httptest.NewRequest(http.MethodGet, "/api/search?"+query.Encode(), nil)
+		r = r.WithContext(user.InjectOrgID(r.Context(), "tenant"))
+
+		w := httptest.NewRecorder()
+		q.SearchHandler(w, r)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		resp := &tempopb.SearchResponse{}
+		require.NoError(t, jsonpb.Unmarshal(w.Body, resp))
+		return w, resp
+	}
+	traceIDs := func(resp *tempopb.SearchResponse) []string {
+		var ids []string
+		for _, tr := range resp.Traces {
+			ids = append(ids, tr.TraceID)
+		}
+		return ids
+	}
+
+	// the external results are combined with the local ones
+	w, resp := request(url.Values{"service.name": {"svc"}, "limit": {"10"}})
+	assert.Equal(t, []string{"a", "b", "c"}, traceIDs(resp))
+	assert.Equal(t, uint32(5), resp.Metrics.InspectedTraces)
+	assert.Equal(t, "true", w.Header().Get(SearchPartialHeader))
+	assert.Len(t, w.Header().Values(WarningHeader), 1)
+
+	reqs := external.requests()
+	require.Len(t, reqs, 1)
+	assert.Equal(t, "/tempo/api/search", reqs[0].URL.Path)
+	assert.Equal(t, url.Values{"service.name": {"svc"}, "limit": {"10"}}, reqs[0].URL.Query())
+	assert.Equal(t, "tenant", reqs[0].Header.Get(user.OrgIDHeaderName))
+
+	// only the first shard of a sharded search queries the external endpoints, without the shard
+	_, resp = request(url.Values{SearchShardKey: {"1"}, SearchShardsKey: {"2"}})
+	assert.Empty(t, traceIDs(resp))
+	assert.Len(t, external.requests(), 1)
+
+	_, resp = request(url.Values{SearchShardKey: {"0"}, SearchShardsKey: {"2"}})
+	assert.Equal(t, []string{"a", "b", "c"}, traceIDs(resp))
+	reqs = external.requests()
+	require.Len(t, reqs, 2)
+	assert.Empty(t, reqs[1].URL.Query())
+
+	// the ingesters part of a search sharded by time range queries the external endpoints with the range
+	_, resp = request(url.Values{SearchStartKey: {"10"}, SearchEndKey: {"20"}, QueryModeKey: {QueryModeIngesters}})
+	assert.Equal(t, []string{"a", "b", "c"}, traceIDs(resp))
+	reqs = external.requests()
+	require.Len(t, reqs, 3)
+	assert.Equal(t, url.Values{SearchStartKey: {"10"}, SearchEndKey: {"20"}}, reqs[2].URL.Query())
+}
+
+func TestNewExternalEndpoints(t *testing.T) {
+	e, err := newExternalEndpoints(nil, time.Second)
+	require.NoError(t, err)
+	assert.Nil(t, e)
+
+	e, err = newExternalEndpoints([]string{"https://tempo-eu.example.com/tempo"}, time.Second)
+	require.NoError(t, err)
+	require.NotNil(t, e)
+
+	_, err = newExternalEndpoints([]string{"tempo-eu.example.com"}, time.Second)
+	require.Error(t, err)
+
+	_, err = newExternalEndpoints([]string{"ftp://tempo-eu.example.com"}, time.Second)
+	require.Error(t, err)
+
+	// a request of another cluster leaves the context without an external query
+	q := &Querier{external: e}
+	r := httptest.NewRequest(http.MethodGet, "/api/search", nil)
+	r.Header.Set(FederatedHeader, "true")
+	ctx, external := q.externalQuery(context.Background(), r)
+	assert.Nil(t, external)
+	assert.Nil(t, externalQueryFromContext(ctx))
+}
@@ -3,6 +3,7 @@ package querier
 import (
 	"context"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -12,23 +13,83 @@ import (
 	"github.com/golang/protobuf/proto"
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
+	"github.com/grafana/tempo/pkg/model"
+	"github.com/grafana/tempo/pkg/querystats"
 	"github.com/grafana/tempo/pkg/tempopb"
 	"github.com/grafana/tempo/pkg/util"
 	"github.com/grafana/tempo/tempodb"
 	"github.com/opentracing/opentracing-go"
 	ot_log "github.com/opentracing/opentracing-go/log"
 	"github.com/pkg/errors"
+	"github.com/weaveworks/common/user"
 )
 
 const (
 	BlockStartKey = "blockStart"
 	BlockEndKey   = "blockEnd"
 	QueryModeKey  = "mode"
+	SortSpansKey  = "sortSpans"
+	// CacheKey set to false skips the trace cache of the querier for a trace by id query.
+	CacheKey = "cache"
+
+	// SearchShardKey and SearchShardsKey restrict a search to the shard of the ingesters with the given index. The
+	// query-frontend uses them to stream the results of a search shard by shard.
+	SearchShardKey  = "ingesterShard"
+	SearchShardsKey = "ingesterShards"
+	// SearchStartKey and SearchEndKey are the unix epoch seconds of the time range of a search of the backend blocks.
+	SearchStartKey = "start"
+	SearchEndKey   = "end"
+	// TraceStartKey and TraceEndKey are optional unix epoch seconds hints of when the trace of a trace by id query was
+	// written. Blocks that don't overlap them are not searched.
+	TraceStartKey = "start"
+	TraceEndKey   = "end"
+
+	// VerifyReplicasHeader requests replica verification for a single query. It is only honored for admin tenants.
+	VerifyReplicasHeader = "X-Tempo-Verify-Replicas"
+	// ReplicaDiffHeader contains the json encoded ReplicaDiff when replicas were verified, limited to
+	// maxReplicaDiffHeaderEntries missing spans and mismatches.
+	ReplicaDiffHeader = "X-Tempo-Replica-Diff"
+	// TraceTruncatedHeader is set to true if the returned trace only holds the earliest spans of a trace that
+	// exceeded the max trace bytes.
+	TraceTruncatedHeader = "X-Tempo-Trace-Truncated"
+	// MaxTraceBytesHeader holds the max_trace_bytes of the querier if it is set. The query-frontend applies it again to
+	// the trace combined from its shards.
+	MaxTraceBytesHeader = "X-Tempo-Max-Trace-Bytes"
+	// TracePartialHeader is set to true if the returned trace may be missing spans because the query stopped at the
+	// max_blocks_per_trace_query of the tenant.
+	TracePartialHeader = "X-Tempo-Trace-Partial"
+	// IngestionTimeHeader holds the time in unix nanoseconds a distributor first received the returned trace. It is
+	// not set if the trace was only pushed through distributors that didn't record ingestion times.
+	IngestionTimeHeader = "X-Tempo-Ingestion-Time"
+	// BlockCountHeader holds the number of blocks of the tenant with trace by id queries that searched the blocks. The
+	// query-frontend sizes the shards of the next queries of the tenant by it.
+	BlockCountHeader = "X-Tempo-Block-Count"
+	// TagValuesTruncatedHeader is set to true if the returned tag names or values were cut off at the
+	// max_bytes_per_tag_values_query of the tenant.
+	TagValuesTruncatedHeader = "X-Tempo-Tag-Values-Truncated"
+	// SearchPartialHeader is set to true if a search of the backend blocks stopped at the max_search_bytes_read of
+	// the tenant and the returned traces are partial.
+	SearchPartialHeader = "X-Tempo-Search-Partial"
+	// FederatedHeader marks the requests sent to external endpoints. The external endpoints of a querier are not queried
+	// for these requests so clusters that query each other don't loop.
+	FederatedHeader = "X-Tempo-Federated"
+	// WarningHeader holds the warnings of a query that still returned results, like the failures of external endpoints.
+	// A header holds a single warning.
+	WarningHeader = "X-Tempo-Warning"
+
+	// searchPageSize is the number of traces in a page of a search streamed as newline delimited JSON.
+	searchPageSize = 1000
+	// maxReplicaDiffHeaderEntries keeps the replica diff header within the limits of proxies. The complete diff is
+	// logged.
+	maxReplicaDiffHeaderEntries = 50
 
 	QueryModeIngesters = "ingesters"
 	QueryModeBlocks    = "blocks"
 	QueryModeAll       = "all"
 
+	// SortSpansStartTime sorts the spans of the returned trace by start time.
+	SortSpansStartTime = "startTime"
+
 	urlParamMinDuration = "minDuration"
 	urlParamMaxDuration = "maxDuration"
 	urlParamLimit       = "limit"
@@ -55,29 +116,152 @@ func (q *Querier) TraceByIDHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	timeRange, err := parseTraceTimeRange(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	span.LogFields(
 		ot_log.String("msg", "validated request"),
 		ot_log.String("blockStart", blockStart),
 		ot_log.String("blockEnd", blockEnd),
-		ot_log.String("queryMode", queryMode))
+		ot_log.String("queryMode", queryMode),
+		ot_log.String("start", r.URL.Query().Get(TraceStartKey)),
+		ot_log.String("end", r.URL.Query().Get(TraceEndKey)))
+
+	userID, err := user.ExtractOrgID(ctx)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !q.allowQuery(r, userID) {
+		http.Error(w, q.rateLimitedError(userID), http.StatusTooManyRequests)
+		return
+	}
+
+	verifyReplicas, err := q.verifyReplicasRequested(ctx, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sortSpans := r.URL.Query().Get(SortSpansKey)
+	if sortSpans != "" && sortSpans != SortSpansStartTime {
+		http.Error(w, fmt.Sprintf("invalid %s: %s. supported values are (%s)", SortSpansKey, sortSpans, SortSpansStartTime), http.StatusBadRequest)
+		return
+	}
+
+	// a spilled trace is streamed as is so it can only be returned as protobuf
+	format := util.NegotiateTraceFormat(r.Header.Get(util.AcceptHeaderKey))
+	protobufRequested := format == util.ProtobufTypeHeaderValue
+
+	ctx, external := q.externalQuery(ctx, r)
+	ctx = withTraceTimeRange(ctx, timeRange)
 
-	resp, err := q.FindTraceByID(ctx, &tempopb.TraceByIDRequest{
+	req := &tempopb.TraceByIDRequest{
 		TraceID:    byteID,
 		BlockStart: blockStart,
 		BlockEnd:   blockEnd,
 		QueryMode:  queryMode,
-	})
+	}
+
+	stats := querystats.NewCollector()
+	start := time.Now()
+
+	// a trace is often reloaded while it is investigated, the cache spares reading the backend again
+	var resp *tempopb.TraceByIDResponse
+	var cached bool
+	if q.traceCache != nil && !verifyReplicas && r.URL.Query().Get(CacheKey) != "false" {
+		resp, cached = q.traceCache.get(newTraceCacheKey(userID, req, timeRange))
+		span.SetTag("cached", cached)
+	}
+
+	var spilled *spilledTrace
+	var replicaDiff *ReplicaDiff
+	if !cached {
+		resp, spilled, replicaDiff, err = q.findTraceByID(querystats.NewContext(ctx, stats), req, verifyReplicas, protobufRequested)
+		if err != nil {
+			countRejectedTrace(userID, err)
+			http.Error(w, err.Error(), queryErrorStatus(err))
+			return
+		}
+	}
+	stats.ObserveTotal(time.Since(start))
+
+	statsHeader, err := stats.Stats().Encode()
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	w.Header().Set(querystats.Header, statsHeader)
+	if queryMode != QueryModeIngesters {
+		w.Header().Set(BlockCountHeader, strconv.Itoa(q.store.BlockCount(userID)))
+	}
+	if spilled != nil {
+		defer spilled.Close()
+	}
+
+	if replicaDiff != nil {
+		b, err := json.Marshal(replicaDiff.Limit(maxReplicaDiffHeaderEntries))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set(ReplicaDiffHeader, string(b))
+	}
+
+	if resp.TraceTruncated {
+		w.Header().Set(TraceTruncatedHeader, "true")
+	}
+	if q.cfg.MaxTraceBytes > 0 {
+		w.Header().Set(MaxTraceBytesHeader, strconv.Itoa(q.cfg.MaxTraceBytes))
+	}
+	if resp.Partial {
+		w.Header().Set(TracePartialHeader, "true")
+	}
+	setWarnings(w, external)
+	for _, warning := range resp.Warnings {
+		w.Header().Add(WarningHeader, warning)
+	}
+
+	// the ingestion time is returned in a header instead of the body, which holds the trace as sent by the client
+	var ingestionTime uint64
+	if spilled != nil {
+		ingestionTime = spilled.IngestionTime()
+	} else if resp.Trace != nil {
+		ingestionTime = resp.Trace.IngestionTimeUnixNano
+		resp.Trace.IngestionTimeUnixNano = 0
+	}
+	if ingestionTime != 0 {
+		w.Header().Set(IngestionTimeHeader, strconv.FormatUint(ingestionTime, 10))
+	}
+
+	if spilled != nil {
+		if spilled.Empty() {
+			q.traceNotFound(w, byteID, stats.Stats())
+			return
+		}
+
+		span.SetTag("response marshalling format", util.ProtobufTypeHeaderValue)
+		span.SetTag("spilled", true)
+		_, err = spilled.WriteTo(w)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
 
 	if resp.Trace == nil || len(resp.Trace.Batches) == 0 {
-		http.Error(w, fmt.Sprintf("Unable to find %s", hex.EncodeToString(byteID)), http.StatusNotFound)
+		q.traceNotFound(w, byteID, stats.Stats())
 		return
 	}
 
-	if r.Header.Get(util.AcceptHeaderKey) == util.ProtobufTypeHeaderValue {
+	// spans received out of order are only sorted on request, spilled traces are returned unsorted
+	if sortSpans == SortSpansStartTime {
+		model.SortSpansByStartTime(resp.Trace)
+	}
+
+	if protobufRequested {
 		span.SetTag("response marshalling format", util.ProtobufTypeHeaderValue)
 		b, err := proto.Marshal(resp.Trace)
 		if err != nil {
@@ -92,7 +276,24 @@ func (q *Querier) TraceByIDHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if format == util.JaegerJSONTypeHeaderValue {
+		span.SetTag("response marshalling format", util.JaegerJSONTypeHeaderValue)
+		b, err := model.MarshalJaegerJSON(resp.Trace)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", util.JaegerJSONTypeHeaderValue)
+		_, err = w.Write(b)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		return
+	}
+
 	span.SetTag("response marshalling format", util.JSONTypeHeaderValue)
+	w.Header().Set("Content-Type", util.JSONTypeHeaderValue)
 	marshaller := &jsonpb.Marshaler{}
 	err = marshaller.Marshal(w, resp.Trace)
 	if err != nil {
@@ -101,6 +302,66 @@ func (q *Querier) TraceByIDHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// TraceNotFoundResponse is the body of the 404 returned by a trace by id query that didn't find the trace if
+// structured_not_found is enabled. The query-frontend sums the checked ingesters and blocks of its shards.
+type TraceNotFoundResponse struct {
+	TraceID          string `json:"traceID"`
+	Status           string `json:"status"`
+	CheckedIngesters int64  `json:"checked_ingesters"`
+	CheckedBlocks    int64  `json:"checked_blocks"`
+}
+
+// TraceNotFoundStatus is the status of a TraceNotFoundResponse.
+const TraceNotFoundStatus = "not_found"
+
+// traceNotFound returns a 404 for the trace, with a TraceNotFoundResponse if structured_not_found is enabled.
+func (q *Querier) traceNotFound(w http.ResponseWriter, byteID []byte, stats querystats.Stats) {
+	if !q.cfg.StructuredNotFound {
+		http.Error(w, fmt.Sprintf("Unable to find %s", hex.EncodeToString(byteID)), http.StatusNotFound)
+		return
+	}
+
+	b, err := json.Marshal(&TraceNotFoundResponse{
+		TraceID:          hex.EncodeToString(byteID),
+		Status:           TraceNotFoundStatus,
+		CheckedIngesters: stats.IngestersQueried,
+		CheckedBlocks:    stats.BlocksInspected,
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", util.JSONTypeHeaderValue)
+	w.WriteHeader(http.StatusNotFound)
+	_, _ = w.Write(b)
+}
+
+// verifyReplicasRequested returns true if replicas should be verified for this request. Verification is always
+// on if enabled in config, otherwise admin tenants may request it with a header.
+func (q *Querier) verifyReplicasRequested(ctx context.Context, r *http.Request) (bool, error) {
+	if q.cfg.ReplicaVerification.Enabled {
+		return true, nil
+	}
+
+	h := r.Header.Get(VerifyReplicasHeader)
+	if h == "" {
+		return false, nil
+	}
+
+	verify, err := strconv.ParseBool(h)
+	if err != nil {
+		return false, errors.Wrap(err, "invalid value for "+VerifyReplicasHeader)
+	}
+
+	userID, err := user.ExtractOrgID(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	return verify && q.cfg.ReplicaVerification.allowedForTenant(userID), nil
+}
+
 // return values are (blockStart, blockEnd, queryMode, error)
 func validateAndSanitizeRequest(r *http.Request) (string, string, string, error) {
 	q := r.URL.Query().Get(QueryModeKey)
@@ -156,13 +417,24 @@ func (q *Querier) SearchHandler(w http.ResponseWriter, r *http.Request) {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "Querier.SearchHandler")
 	defer span.Finish()
 
+	userID, err := user.ExtractOrgID(ctx)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !q.allowQuery(r, userID) {
+		http.Error(w, q.rateLimitedError(userID), http.StatusTooManyRequests)
+		return
+	}
+
 	req := &tempopb.SearchRequest{
 		Tags: map[string]string{},
 	}
 
 	for k, v := range r.URL.Query() {
 		// Skip known values
-		if k == urlParamMinDuration || k == urlParamMaxDuration || k == urlParamLimit {
+		if k == urlParamMinDuration || k == urlParamMaxDuration || k == urlParamLimit || k == SearchShardKey || k == SearchShardsKey ||
+			k == SearchStartKey || k == SearchEndKey || k == QueryModeKey {
 			continue
 		}
 
@@ -196,12 +468,68 @@ func (q *Querier) SearchHandler(w http.ResponseWriter, r *http.Request) {
 		req.Limit = uint32(limit)
 	}
 
-	resp, err := q.Search(ctx, req)
+	ctx, external := q.externalQuery(ctx, r)
+
+	var resp *tempopb.SearchResponse
+	var partial bool
+	var warnings []string
+	switch {
+	case r.URL.Query().Get(SearchShardsKey) != "":
+		var shard, shards int
+		shard, shards, err = parseSearchShard(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		resp, err = q.SearchShard(ctx, req, shard, shards)
+		// the other shards of the query-frontend leave the external endpoints to the first one
+		if shard != 0 {
+			external = nil
+		}
+	case r.URL.Query().Get(SearchStartKey) != "" || r.URL.Query().Get(SearchEndKey) != "":
+		var start, end time.Time
+		start, end, err = parseSearchRange(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		switch r.URL.Query().Get(QueryModeKey) {
+		case QueryModeBlocks:
+			// the blocks are searched in addition to a search of the ingesters that queries the external endpoints
+			external = nil
+			resp, partial, err = q.SearchBlocks(ctx, req, start, end)
+		case QueryModeIngesters:
+			// the query-frontend searches the blocks of the range in separate requests
+			resp, err = q.Search(ctx, req)
+		default:
+			resp, partial, warnings, err = q.SearchRange(ctx, req, start, end)
+		}
+	default:
+		resp, err = q.Search(ctx, req)
+	}
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), queryErrorStatus(err))
 		return
 	}
 
+	if external != nil {
+		var externalPartial bool
+		resp, externalPartial, err = q.searchExternal(ctx, external, req, resp, externalSearchParams(r.URL.Query()))
+		if err != nil {
+			http.Error(w, err.Error(), queryErrorStatus(err))
+			return
+		}
+		partial = partial || externalPartial
+	}
+
+	if partial {
+		w.Header().Set(SearchPartialHeader, "true")
+	}
+	setWarnings(w, external)
+	for _, warning := range warnings {
+		w.Header().Add(WarningHeader, warning)
+	}
+
 	marshaller := &jsonpb.Marshaler{}
 	err = marshaller.Marshal(w, resp)
 	if err != nil {
@@ -210,6 +538,89 @@ func (q *Querier) SearchHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// externalQuery returns the context with an externalQuery if the external endpoints should be queried for the
+// request.
+func (q *Querier) externalQuery(ctx context.Context, r *http.Request) (context.Context, *externalQuery) {
+	if q.external == nil || r.Header.Get(FederatedHeader) != "" {
+		return ctx, nil
+	}
+
+	external := &externalQuery{}
+	return withExternalQuery(ctx, external), external
+}
+
+func setWarnings(w http.ResponseWriter, external *externalQuery) {
+	if external == nil {
+		return
+	}
+	for _, warning := range external.allWarnings() {
+		w.Header().Add(WarningHeader, warning)
+	}
+}
+
+func parseSearchShard(r *http.Request) (int, int, error) {
+	shards, err := strconv.Atoi(r.URL.Query().Get(SearchShardsKey))
+	if err != nil || shards <= 0 {
+		return 0, 0, fmt.Errorf("invalid %s. it should be a positive integer", SearchShardsKey)
+	}
+	shard, err := strconv.Atoi(r.URL.Query().Get(SearchShardKey))
+	if err != nil || shard < 0 || shard >= shards {
+		return 0, 0, fmt.Errorf("invalid %s. it should be between 0 and %s - 1", SearchShardKey, SearchShardsKey)
+	}
+	return shard, shards, nil
+}
+
+// parseSearchRange returns the time range of a search of the backend blocks. Both ends are required.
+func parseSearchRange(r *http.Request) (time.Time, time.Time, error) {
+	start, err := strconv.ParseInt(r.URL.Query().Get(SearchStartKey), 10, 64)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid %s. it should be unix epoch seconds", SearchStartKey)
+	}
+	end, err := strconv.ParseInt(r.URL.Query().Get(SearchEndKey), 10, 64)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid %s. it should be unix epoch seconds", SearchEndKey)
+	}
+	if end < start {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid %s. it should not be before %s", SearchEndKey, SearchStartKey)
+	}
+	return time.Unix(start, 0), time.Unix(end, 0), nil
+}
+
+// DeleteTraceHandler deletes a trace that has not been flushed to the backend from the ingesters and returns the
+// number of spans removed.
+func (q *Querier) DeleteTraceHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(q.cfg.QueryTimeout))
+	defer cancel()
+
+	span, ctx := opentracing.StartSpanFromContext(ctx, "Querier.DeleteTraceHandler")
+	defer span.Finish()
+
+	byteID, err := util.ParseTraceID(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resp, err := q.DeleteTrace(ctx, byteID)
+	if err != nil {
+		http.Error(w, err.Error(), queryErrorStatus(err))
+		return
+	}
+	span.LogFields(ot_log.Uint64("spansRemoved", resp.SpansRemoved))
+
+	// encoded with encoding/json as jsonpb omits a count of 0 and quotes 64 bit integers
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(struct {
+		SpansRemoved uint64 `json:"spansRemoved"`
+	}{
+		SpansRemoved: resp.SpansRemoved,
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
 func (q *Querier) SearchTagsHandler(w http.ResponseWriter, r *http.Request) {
 	// Enforce the query timeout while querying backends
 	ctx, cancel := context.WithDeadline(r.Context(), time.Now().Add(q.cfg.QueryTimeout))
@@ -220,11 +631,14 @@ func (q *Querier) SearchTagsHandler(w http.ResponseWriter, r *http.Request) {
 
 	req := &tempopb.SearchTagsRequest{}
 
-	resp, err := q.SearchTags(ctx, req)
+	resp, truncated, err := q.SearchTags(ctx, req)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), queryErrorStatus(err))
 		return
 	}
+	if truncated {
+		w.Header().Set(TagValuesTruncatedHeader, "true")
+	}
 
 	marshaller := &jsonpb.Marshaler{}
 	err = marshaller.Marshal(w, resp)
@@ -252,11 +666,14 @@ func (q *Querier) SearchTagValuesHandler(w http.ResponseWriter, r *http.Request)
 		TagName: tagName,
 	}
 
-	resp, err := q.SearchTagValues(ctx, req)
+	resp, truncated, err := q.SearchTagValues(ctx, req)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), queryErrorStatus(err))
 		return
 	}
+	if truncated {
+		w.Header().Set(TagValuesTruncatedHeader, "true")
+	}
 
 	marshaller := &jsonpb.Marshaler{}
 	err = marshaller.Marshal(w, resp)
@@ -2,14 +2,22 @@ package querier
 
 import (
 	"context"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
+	"os"
 	"sort"
+	"sync"
+	"time"
 
 	cortex_worker "github.com/cortexproject/cortex/pkg/querier/worker"
 	"github.com/cortexproject/cortex/pkg/ring"
 	ring_client "github.com/cortexproject/cortex/pkg/ring/client"
+	"github.com/cortexproject/cortex/pkg/util/limiter"
 	"github.com/cortexproject/cortex/pkg/util/log"
+	"github.com/go-kit/kit/log/level"
 	"github.com/grafana/dskit/services"
 	"github.com/opentracing/opentracing-go"
 	ot_log "github.com/opentracing/opentracing-go/log"
@@ -23,6 +31,7 @@ import (
 	"github.com/grafana/tempo/modules/overrides"
 	"github.com/grafana/tempo/modules/storage"
 	"github.com/grafana/tempo/pkg/model"
+	"github.com/grafana/tempo/pkg/querystats"
 	"github.com/grafana/tempo/pkg/tempopb"
 	"github.com/grafana/tempo/pkg/validation"
 )
@@ -33,8 +42,18 @@ var (
 		Name:      "querier_ingester_clients",
 		Help:      "The current number of ingester clients.",
 	})
+	metricQueryBlocksInspected = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "tempo",
+		Name:      "query_blocks_inspected",
+		Help:      "Number of backend blocks inspected by a trace by id query.",
+		Buckets:   prometheus.ExponentialBuckets(1, 2, 14),
+	})
 )
 
+// SkeletonWarning is returned with a trace that was deleted by retention of which only its skeleton is left: its root
+// spans, their children and its error spans.
+const SkeletonWarning = "the trace was deleted by retention, only its skeleton is returned"
+
 // Querier handlers queries.
 type Querier struct {
 	services.Service
@@ -45,6 +64,15 @@ type Querier struct {
 	store  storage.Store
 	limits *overrides.Overrides
 
+	// queried in addition to the local cluster, nil if there are none
+	external *externalEndpoints
+	// recently returned traces, nil if disabled
+	traceCache *traceCache
+
+	queryRateLimiter *limiter.RateLimiter
+	// starts and stops the frontend workers, nil unless adaptive concurrency is enabled
+	adaptiveConcurrency *adaptiveConcurrency
+
 	subservices        *services.Manager
 	subservicesWatcher *services.FailureWatcher
 
@@ -62,6 +90,11 @@ func New(cfg Config, clientCfg ingester_client.Config, ring ring.ReadRing, store
 		return ingester_client.New(addr, clientCfg)
 	}
 
+	external, err := newExternalEndpoints(cfg.ExternalEndpoints, cfg.ExternalEndpointTimeout)
+	if err != nil {
+		return nil, err
+	}
+
 	q := &Querier{
 		cfg:  cfg,
 		ring: ring,
@@ -71,9 +104,18 @@ func New(cfg Config, clientCfg ingester_client.Config, ring ring.ReadRing, store
 			factory,
 			metricIngesterClients,
 			log.Logger),
-		store:         store,
-		limits:        limits,
-		enablePolling: enablePolling,
+		store:            store,
+		limits:           limits,
+		external:         external,
+		traceCache:       newTraceCache(cfg.TraceCache),
+		queryRateLimiter: newQueryRateLimiter(limits),
+		enablePolling:    enablePolling,
+	}
+
+	if cfg.AdaptiveConcurrency.Enabled {
+		if len(cfg.AdaptiveConcurrency.MetricsURLs) == 0 || cfg.AdaptiveConcurrency.PollInterval <= 0 {
+			return nil, fmt.Errorf("adaptive concurrency requires metrics urls and a positive poll interval")
+		}
 	}
 
 	q.Service = services.NewBasicService(q.starting, q.running, q.stopping)
@@ -81,17 +123,32 @@ func New(cfg Config, clientCfg ingester_client.Config, ring ring.ReadRing, store
 }
 
 func (q *Querier) CreateAndRegisterWorker(tracesHandler http.Handler) error {
-	q.cfg.Worker.MaxConcurrentRequests = q.cfg.MaxConcurrentQueries
-	worker, err := cortex_worker.NewQuerierWorker(
-		q.cfg.Worker,
-		httpgrpc_server.NewServer(tracesHandler),
-		log.Logger,
-		nil,
-	)
-	if err != nil {
-		return fmt.Errorf("failed to create frontend worker: %w", err)
+	newWorker := func(concurrency int) (services.Service, error) {
+		cfg := q.cfg.Worker
+		cfg.MaxConcurrentRequests = concurrency
+
+		worker, err := cortex_worker.NewQuerierWorker(
+			cfg,
+			httpgrpc_server.NewServer(frontendRequests(tracesHandler)),
+			log.Logger,
+			nil,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create frontend worker: %w", err)
+		}
+		return worker, nil
 	}
 
+	// adaptive concurrency starts and stops workers to pull as many queries at once as its current limit
+	if q.cfg.AdaptiveConcurrency.Enabled {
+		q.adaptiveConcurrency = newAdaptiveConcurrency(q.cfg.AdaptiveConcurrency, q.cfg.MaxConcurrentQueries, newWorker)
+		return q.RegisterSubservices(q.pool, q.adaptiveConcurrency.service())
+	}
+
+	worker, err := newWorker(q.cfg.MaxConcurrentQueries)
+	if err != nil {
+		return err
+	}
 	return q.RegisterSubservices(worker, q.pool)
 }
 
@@ -111,6 +168,14 @@ func (q *Querier) starting(ctx context.Context) error {
 		}
 	}
 
+	if q.cfg.TraceSpill.ThresholdBytes > 0 {
+		err := os.MkdirAll(q.cfg.TraceSpill.Path, 0700)
+		if err != nil {
+			return fmt.Errorf("failed to create trace spill path %w", err)
+		}
+		removeSpillFiles(q.cfg.TraceSpill.Path)
+	}
+
 	if q.enablePolling {
 		// this will block until one poll cycle is complete
 		q.store.EnablePolling(q)
@@ -134,6 +199,8 @@ func (q *Querier) running(ctx context.Context) error {
 }
 
 func (q *Querier) stopping(_ error) error {
+	removeSpillFiles(q.cfg.TraceSpill.Path)
+
 	if q.subservices != nil {
 		return services.StopManagerAndAwaitStopped(context.Background(), q.subservices)
 	}
@@ -142,103 +209,542 @@ func (q *Querier) stopping(_ error) error {
 
 // FindTraceByID implements tempopb.Querier.
 func (q *Querier) FindTraceByID(ctx context.Context, req *tempopb.TraceByIDRequest) (*tempopb.TraceByIDResponse, error) {
+	resp, _, _, err := q.findTraceByID(ctx, req, q.cfg.ReplicaVerification.Enabled, false)
+	if err != nil {
+		if userID, orgErr := user.ExtractOrgID(ctx); orgErr == nil {
+			countRejectedTrace(userID, err)
+		}
+	}
+	return resp, err
+}
+
+// findTraceByID finds the trace and, if verifyReplicas is true, compares the traces returned by each ingester replica.
+// The returned diff is nil if replicas were not verified. If allowSpill is true and the partial traces found in the
+// backend exceed the spill threshold the trace is assembled on disk and returned as a spilledTrace instead of in the
+// response. The caller must close it.
+func (q *Querier) findTraceByID(ctx context.Context, req *tempopb.TraceByIDRequest, verifyReplicas bool, allowSpill bool) (*tempopb.TraceByIDResponse, *spilledTrace, *ReplicaDiff, error) {
 	if !validation.ValidTraceID(req.TraceID) {
-		return nil, fmt.Errorf("invalid trace id")
+		return nil, nil, nil, fmt.Errorf("invalid trace id")
 	}
 
 	userID, err := user.ExtractOrgID(ctx)
 	if err != nil {
-		return nil, errors.Wrap(err, "error extracting org id in Querier.FindTraceByID")
+		return nil, nil, nil, errors.Wrap(err, "error extracting org id in Querier.FindTraceByID")
 	}
 
 	span, ctx := opentracing.StartSpanFromContext(ctx, "Querier.FindTraceByID")
 	defer span.Finish()
 
-	var completeTrace *tempopb.Trace
-	var spanCount, spanCountTotal, traceCountTotal int
-	if req.QueryMode == QueryModeIngesters || req.QueryMode == QueryModeAll {
-		replicationSet, err := q.ring.GetReplicationSetForOperation(ring.Read)
+	// the max bytes of the request take precedence so a caller can ask for a partial trace
+	maxBytes := req.MaxBytes
+	if maxBytes == 0 && q.cfg.MaxTraceBytes > 0 {
+		maxBytes = uint64(q.cfg.MaxTraceBytes)
+	}
+
+	search := tierSearch{
+		req:             req,
+		userID:          userID,
+		maxBytes:        maxBytes,
+		verifyReplicas:  verifyReplicas,
+		allowSpill:      allowSpill,
+		searchIngesters: req.QueryMode == QueryModeIngesters || req.QueryMode == QueryModeAll,
+		searchStore:     req.QueryMode == QueryModeBlocks || req.QueryMode == QueryModeAll,
+	}
+
+	// a trace written before the ingesters hand their blocks off can only be in the backend
+	timeRange := traceTimeRangeFromContext(ctx)
+	if search.searchIngesters && q.cfg.Search.QueryIngestersUntil > 0 && timeRange.olderThan(time.Now().Add(-q.cfg.Search.QueryIngestersUntil)) {
+		search.searchIngesters = false
+		span.LogFields(ot_log.String("msg", "skipping ingesters for time range"))
+	}
+
+	tiers, err := q.searchTiers(ctx, span, search)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	// the spilled trace is handed to the caller if it's returned
+	defer tiers.close()
+
+	warnings := tiers.warnings(q.cfg.Search)
+	partial := tiers.partial()
+
+	combineStart := time.Now()
+	defer func() {
+		querystats.FromContext(ctx).ObserveCombine(time.Since(combineStart))
+	}()
+
+	// the trace is assembled up to the max bytes per trace query of the tenant. the parts found in the ingesters and
+	// the store may overlap, so they are checked on their own while they are combined and together afterwards
+	completeTrace, err := q.combineExternalTraces(userID, tiers)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if search.searchStore {
+		partialTraces, dataEncodings := tiers.store.partialTraces, tiers.store.dataEncodings
+
+		// a skeleton is only served if the trace wasn't found anywhere else
+		if tiers.store.skeleton && completeTrace != nil {
+			partialTraces, dataEncodings = nil, nil
+		} else if tiers.store.skeleton {
+			warnings = append(warnings, SkeletonWarning)
+		}
+
+		if tiers.store.spilled != nil {
+			spilled, err := q.spillTrace(span, userID, completeTrace, tiers)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			return &tempopb.TraceByIDResponse{Partial: partial, Warnings: warnings}, spilled, tiers.ingesters.replicaDiff, nil
+		}
+
+		completeTrace, err = q.combineStoreTraces(span, userID, completeTrace, partialTraces, dataEncodings)
 		if err != nil {
-			return nil, errors.Wrap(err, "error finding ingesters in Querier.FindTraceByID")
+			return nil, nil, nil, err
 		}
+	}
+
+	truncated := tiers.ingesters.truncated
+	if completeTrace != nil && maxBytes > 0 && uint64(completeTrace.Size()) > maxBytes {
+		var dropped int
+		completeTrace, dropped = model.TruncateTrace(completeTrace, int(maxBytes))
+		truncated = true
+		span.LogFields(ot_log.String("msg", "truncated trace"), ot_log.Int("droppedSpans", dropped))
+	}
+
+	resp := &tempopb.TraceByIDResponse{
+		Trace:          completeTrace,
+		TraceTruncated: truncated,
+		Partial:        partial,
+		Warnings:       warnings,
+	}
+	if !verifyReplicas {
+		q.cacheTrace(newTraceCacheKey(userID, req, timeRange), tiers, resp)
+	}
 
-		span.LogFields(ot_log.String("msg", "searching ingesters"))
-		// get responses from all ingesters in parallel
-		responses, err := q.forGivenIngesters(ctx, replicationSet, func(client tempopb.QuerierClient) (interface{}, error) {
-			return client.FindTraceByID(opentracing.ContextWithSpan(ctx, span), req)
+	return resp, nil, tiers.ingesters.replicaDiff, nil
+}
+
+// tierSearch is a search for a trace in the ingesters and the store.
+type tierSearch struct {
+	req             *tempopb.TraceByIDRequest
+	userID          string
+	maxBytes        uint64
+	verifyReplicas  bool
+	allowSpill      bool
+	searchIngesters bool
+	searchStore     bool
+}
+
+// tierSearchResult holds what the ingesters, the store and the other clusters found of a trace.
+type tierSearchResult struct {
+	ingesters ingesterSearchResult
+	store     storeSearchResult
+	external  externalTraceResult
+
+	// each tier may have a stricter deadline than the query. if both tiers are searched, a tier that times out while
+	// the other one answers is a warning instead of an error, as is a tier cancelled by the slower tier grace
+	ingestersTimedOut, storeTimedOut   bool
+	ingestersCancelled, storeCancelled bool
+}
+
+// warnings returns a warning for each tier that timed out or was cancelled.
+func (r *tierSearchResult) warnings(cfg SearchConfig) []string {
+	var warnings []string
+	if r.ingestersTimedOut {
+		warnings = append(warnings, tierTimeoutWarning(tierIngesters, cfg.QueryIngestersTimeout))
+	}
+	if r.storeTimedOut {
+		warnings = append(warnings, tierTimeoutWarning(tierBackend, cfg.QueryBackendTimeout))
+	}
+	if r.ingestersCancelled {
+		warnings = append(warnings, tierGraceWarning(tierIngesters, cfg.SlowerTierGrace))
+	}
+	if r.storeCancelled {
+		warnings = append(warnings, tierGraceWarning(tierBackend, cfg.SlowerTierGrace))
+	}
+	return warnings
+}
+
+// partial returns true if parts of the trace may be missing.
+func (r *tierSearchResult) partial() bool {
+	return r.store.partial || r.external.partial || r.ingestersTimedOut || r.storeTimedOut || r.ingestersCancelled || r.storeCancelled
+}
+
+// close removes the trace spilled by the store search unless it was handed to the caller.
+func (r *tierSearchResult) close() {
+	if r.store.spilled != nil {
+		r.store.spilled.Close()
+		r.store.spilled = nil
+	}
+}
+
+// searchTiers searches the ingesters and the store concurrently so a lookup of a trace that is only in the backend
+// doesn't wait for the ingesters first. A trace may be in both while its blocks are flushed, so once one of them finds
+// the trace the other one gets the slower tier grace period to answer before it's cancelled. A failure cancels the
+// other one right away. Other clusters are queried along with the ingesters.
+func (q *Querier) searchTiers(ctx context.Context, span opentracing.Span, search tierSearch) (*tierSearchResult, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// the first error cancels the other search so its cancellation isn't returned instead
+	var firstErr error
+	var errOnce sync.Once
+	fail := func(err error) {
+		errOnce.Do(func() {
+			firstErr = err
+			cancel()
 		})
-		if err != nil {
-			return nil, errors.Wrap(err, "error querying ingesters in Querier.FindTraceByID")
+	}
+
+	stats := querystats.FromContext(ctx)
+	searchBoth := search.searchIngesters && search.searchStore
+
+	ingestersCtx, ingestersGrace := q.slowerTierGrace(ctx, searchBoth)
+	defer ingestersGrace.stop()
+	storeCtx, storeGrace := q.slowerTierGrace(ctx, searchBoth)
+	defer storeGrace.stop()
+
+	result := &tierSearchResult{}
+	var wg sync.WaitGroup
+	if search.searchIngesters {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tierCtx, tierCancel := withTierTimeout(ingestersCtx, q.cfg.Search.QueryIngestersTimeout)
+			defer tierCancel()
+
+			start := time.Now()
+			result.ingesters = q.findTraceInIngesters(tierCtx, span, search.req, search.maxBytes, search.verifyReplicas, search.userID)
+			stats.ObserveIngesters(time.Since(start))
+			if result.ingesters.err != nil {
+				if ingestersGrace.cancelled(ctx) {
+					span.LogFields(ot_log.String("msg", "cancelled ingesters after the trace was found in the store"))
+					result.ingestersCancelled = true
+					result.ingesters = ingesterSearchResult{}
+					return
+				}
+				if search.searchStore && tierTimedOut(ctx, tierCtx) {
+					result.ingestersTimedOut = true
+					result.ingesters = ingesterSearchResult{}
+					return
+				}
+				fail(result.ingesters.err)
+				return
+			}
+			if result.ingesters.trace != nil {
+				storeGrace.start()
+			}
+		}()
+	}
+	if search.searchStore {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tierCtx, tierCancel := withTierTimeout(storeCtx, q.cfg.Search.QueryBackendTimeout)
+			defer tierCancel()
+
+			start := time.Now()
+			result.store = q.findTraceInStore(tierCtx, span, search.req, search.userID, search.allowSpill)
+			stats.ObserveStore(time.Since(start))
+			if result.store.err != nil {
+				if storeGrace.cancelled(ctx) {
+					span.LogFields(ot_log.String("msg", "cancelled store after the trace was found in the ingesters"))
+					result.storeCancelled = true
+					result.store = storeSearchResult{}
+					return
+				}
+				if search.searchIngesters && tierTimedOut(ctx, tierCtx) {
+					result.storeTimedOut = true
+					result.store = storeSearchResult{}
+					return
+				}
+				fail(result.store.err)
+				return
+			}
+			if result.store.found() {
+				ingestersGrace.start()
+			}
+		}()
+	}
+	// the external endpoints are queried along with the ingesters so a query sharded by the query-frontend only
+	// queries them once. their failures are warnings and don't cancel the local searches
+	if externalQuery := externalQueryFromContext(ctx); search.searchIngesters && q.external != nil && externalQuery != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result.external = q.external.findTraceByID(ctx, externalQuery, search.userID, search.req.TraceID)
+		}()
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		result.close()
+		return nil, firstErr
+	}
+	if result.ingestersTimedOut && result.storeTimedOut {
+		result.close()
+		return nil, errors.New("error querying ingesters and store in Querier.FindTraceByID: both timed out")
+	}
+
+	return result, nil
+}
+
+// combineExternalTraces combines the trace found in the ingesters with the traces found in other clusters. The max
+// bytes per trace query of the tenant is checked after each one.
+func (q *Querier) combineExternalTraces(userID string, tiers *tierSearchResult) (*tempopb.Trace, error) {
+	completeTrace := tiers.ingesters.trace
+	for _, externalTrace := range tiers.external.traces {
+		completeTrace, _, _, _ = model.CombineTraceProtos(completeTrace, externalTrace)
+		if err := q.checkTraceSize(userID, completeTrace.Size()); err != nil {
+			return nil, err
 		}
+	}
 
-		for _, r := range responses {
-			trace := r.response.(*tempopb.TraceByIDResponse).Trace
-			if trace != nil {
-				completeTrace, _, _, spanCount = model.CombineTraceProtos(completeTrace, trace)
-				spanCountTotal += spanCount
-				traceCountTotal++
+	return completeTrace, nil
+}
+
+// combineStoreTraces adds the partial traces found in the store to the trace found elsewhere. The partial traces are
+// checked against the max bytes per trace query of the tenant while they are combined, and the complete trace once
+// they are added.
+func (q *Querier) combineStoreTraces(span opentracing.Span, userID string, completeTrace *tempopb.Trace, partialTraces [][]byte, dataEncodings []string) (*tempopb.Trace, error) {
+	if len(partialTraces) != 0 {
+		var allBytes []byte
+		var err error
+		baseEncoding := dataEncodings[0] // just arbitrarily choose an encoding. generally they will all be the same
+		for i, partialTrace := range partialTraces {
+			allBytes, _, err = model.CombineTraceBytes(allBytes, partialTrace, baseEncoding, dataEncodings[i])
+			if err != nil {
+				return nil, errors.Wrap(err, "error querying store in Querier.FindTraceByID")
+			}
+			if err := q.checkTraceSize(userID, len(allBytes)); err != nil {
+				return nil, err
 			}
 		}
-		span.LogFields(ot_log.String("msg", "done searching ingesters"),
+
+		storeTrace, err := model.Unmarshal(allBytes, baseEncoding)
+		if err != nil {
+			return nil, errors.Wrap(err, "error unmarshaling combined trace in Querier.FindTraceByID")
+		}
+
+		var spanCount int
+		completeTrace, _, _, spanCount = model.CombineTraceProtos(completeTrace, storeTrace)
+		span.LogFields(ot_log.String("msg", "combined trace protos from store"),
 			ot_log.Bool("found", completeTrace != nil),
-			ot_log.Int("combinedSpans", spanCountTotal),
-			ot_log.Int("combinedTraces", traceCountTotal))
+			ot_log.Int("combinedSpans", spanCount),
+			ot_log.Int("combinedTraces", 1))
+	}
+
+	if completeTrace != nil {
+		if err := q.checkTraceSize(userID, completeTrace.Size()); err != nil {
+			return nil, err
+		}
 	}
 
-	if req.QueryMode == QueryModeBlocks || req.QueryMode == QueryModeAll {
-		span.LogFields(ot_log.String("msg", "searching store"))
-		partialTraces, dataEncodings, err := q.store.Find(opentracing.ContextWithSpan(ctx, span), userID, req.TraceID, req.BlockStart, req.BlockEnd)
+	return completeTrace, nil
+}
+
+// spillTrace appends the trace found elsewhere to the trace the store search spilled to disk and hands the spilled
+// trace over to the caller, who must close it.
+func (q *Querier) spillTrace(span opentracing.Span, userID string, completeTrace *tempopb.Trace, tiers *tierSearchResult) (*spilledTrace, error) {
+	span.LogFields(ot_log.String("msg", "spilled trace to disk"))
+	spilled := tiers.store.spilled
+	if completeTrace != nil {
+		err := spilled.append(completeTrace)
 		if err != nil {
-			return nil, errors.Wrap(err, "error querying store in Querier.FindTraceByID")
+			return nil, errors.Wrap(err, "error spilling trace in Querier.FindTraceByID")
+		}
+		if err := q.checkTraceSize(userID, int(spilled.size)); err != nil {
+			return nil, err
 		}
+	}
 
-		span.LogFields(ot_log.String("msg", "done searching store"))
-
-		if len(partialTraces) != 0 {
-			traceCountTotal = 0
-			spanCountTotal = 0
-			// combine partialTraces
-			var allBytes []byte
-			baseEncoding := dataEncodings[0] // just arbitrarily choose an encoding. generally they will all be the same
-			for i, partialTrace := range partialTraces {
-				dataEncoding := dataEncodings[i]
-				allBytes, _, err = model.CombineTraceBytes(allBytes, partialTrace, baseEncoding, dataEncoding)
-				if err != nil {
-					return nil, errors.Wrap(err, "error querying store in Querier.FindTraceByID")
-				}
-			}
+	tiers.store.spilled = nil
+	return spilled, nil
+}
 
-			// marshal to proto and add to completeTrace
-			storeTrace, err := model.Unmarshal(allBytes, baseEncoding)
-			if err != nil {
-				return nil, errors.Wrap(err, "error unmarshaling combined trace in Querier.FindTraceByID")
-			}
+// cacheTrace caches the response of a trace that was only found in the store. A trace found in the ingesters or in
+// other clusters may still be receiving spans, and partial responses or responses with warnings may be missing parts.
+func (q *Querier) cacheTrace(key traceCacheKey, tiers *tierSearchResult, resp *tempopb.TraceByIDResponse) {
+	if q.traceCache == nil || resp.Trace == nil || tiers.ingesters.trace != nil || len(tiers.external.traces) != 0 {
+		return
+	}
+	if resp.Partial || len(resp.Warnings) != 0 {
+		return
+	}
+
+	q.traceCache.put(key, resp)
+}
+
+type ingesterSearchResult struct {
+	trace       *tempopb.Trace
+	truncated   bool
+	replicaDiff *ReplicaDiff
+	spanCount   int
+	traceCount  int
+	err         error
+}
 
-			completeTrace, _, _, spanCount = model.CombineTraceProtos(completeTrace, storeTrace)
-			spanCountTotal += spanCount
-			traceCountTotal++
+// findTraceInIngesters finds the trace in the ingesters and combines their responses.
+func (q *Querier) findTraceInIngesters(ctx context.Context, span opentracing.Span, req *tempopb.TraceByIDRequest, maxBytes uint64, verifyReplicas bool, userID string) ingesterSearchResult {
+	ingesterReq := *req
+	ingesterReq.MaxBytes = maxBytes
 
-			span.LogFields(ot_log.String("msg", "combined trace protos from store"),
-				ot_log.Bool("found", completeTrace != nil),
-				ot_log.Int("combinedSpans", spanCountTotal),
-				ot_log.Int("combinedTraces", traceCountTotal))
+	replicationSet, err := q.ring.GetReplicationSetForOperation(ring.Read)
+	if err != nil {
+		return ingesterSearchResult{err: errors.Wrap(err, "error finding ingesters in Querier.FindTraceByID")}
+	}
+
+	span.LogFields(ot_log.String("msg", "searching ingesters"))
+	// get responses from all ingesters in parallel
+	findTraceByID := func(ctx context.Context, client tempopb.QuerierClient) (interface{}, error) {
+		return client.FindTraceByID(opentracing.ContextWithSpan(ctx, span), &ingesterReq)
+	}
+
+	// replica verification compares every replica so it always queries all zones
+	var responses []responseFromIngesters
+	if verifyReplicas {
+		responses, err = q.forGivenIngesters(ctx, replicationSet, findTraceByID)
+	} else {
+		responses, err = q.forIngestersPreferLocalZone(ctx, opFindTraceByID, replicationSet, traceFound, findTraceByID)
+	}
+	if err != nil {
+		return ingesterSearchResult{err: errors.Wrap(err, "error querying ingesters in Querier.FindTraceByID")}
+	}
+
+	result := combineIngesterResponses(responses, func(size int) error {
+		return q.checkTraceSize(userID, size)
+	})
+	if result.err != nil {
+		return result
+	}
+	if verifyReplicas {
+		result.replicaDiff = q.verifyReplicas(userID, req.TraceID, responses)
+	}
+
+	span.LogFields(ot_log.String("msg", "done searching ingesters"),
+		ot_log.Bool("found", result.trace != nil),
+		ot_log.Int("combinedSpans", result.spanCount),
+		ot_log.Int("combinedTraces", result.traceCount))
+
+	return result
+}
+
+type storeSearchResult struct {
+	partialTraces [][]byte
+	dataEncodings []string
+	// the partial traces exceeded the spill threshold and were assembled on disk
+	spilled *spilledTrace
+	partial bool
+	// the partial traces are the skeletons of a trace deleted by retention
+	skeleton bool
+	err      error
+}
+
+// findTraceInStore finds the partial traces in the blocks of the backend. The result is partial if there were more
+// blocks that may hold the trace than the max blocks per trace query of the tenant. If the trace is not found its
+// skeletons are returned instead, if it was deleted by retention and skeletons are enabled. If allowSpill is true the
+// partial traces are spilled to disk as they are found once they exceed the spill threshold.
+func (q *Querier) findTraceInStore(ctx context.Context, span opentracing.Span, req *tempopb.TraceByIDRequest, userID string, allowSpill bool) storeSearchResult {
+	span.LogFields(ot_log.String("msg", "searching store"))
+	timeRange := traceTimeRangeFromContext(ctx)
+	collector := &partialTraceCollector{
+		dir: q.cfg.TraceSpill.Path,
+		checkSize: func(size int) error {
+			return q.checkTraceSize(userID, size)
+		},
+	}
+	if allowSpill {
+		collector.threshold = q.cfg.TraceSpill.ThresholdBytes
+	}
+	metrics, err := q.store.FindEach(opentracing.ContextWithSpan(ctx, span), userID, req.TraceID, req.BlockStart, req.BlockEnd, timeRange.start, timeRange.end, q.limits.MaxBlocksPerTraceQuery(userID), collector.add)
+	if err != nil {
+		collector.close()
+		return storeSearchResult{err: errors.Wrap(err, "error querying store in Querier.FindTraceByID")}
+	}
+	metricQueryBlocksInspected.Observe(float64(metrics.InspectedBlocks))
+	span.LogFields(ot_log.String("msg", "done searching store"),
+		ot_log.Int("inspectedBlocks", metrics.InspectedBlocks),
+		ot_log.Int("skippedBlocks", metrics.SkippedBlocks))
+
+	result := storeSearchResult{
+		spilled: collector.spilled,
+		partial: metrics.SkippedBlocks > 0,
+	}
+	result.partialTraces, result.dataEncodings = collector.partialTraces()
+	if result.found() {
+		return result
+	}
+
+	skeletons, skeletonEncodings, err := q.store.FindSkeleton(opentracing.ContextWithSpan(ctx, span), userID, req.TraceID, req.BlockStart, req.BlockEnd, timeRange.start, timeRange.end)
+	if err != nil {
+		return storeSearchResult{err: errors.Wrap(err, "error querying skeletons in Querier.FindTraceByID")}
+	}
+	if len(skeletons) > 0 {
+		span.LogFields(ot_log.String("msg", "found trace skeleton"), ot_log.Int("skeletons", len(skeletons)))
+		result.partialTraces = skeletons
+		result.dataEncodings = skeletonEncodings
+		result.skeleton = true
+	}
+
+	return result
+}
+
+// found returns true if the trace itself, not only its skeletons, was found.
+func (r storeSearchResult) found() bool {
+	return r.spilled != nil || (len(r.partialTraces) != 0 && !r.skeleton)
+}
+
+// combineIngesterResponses combines the traces found by the ingesters. Truncated responses hold the earliest spans
+// of the trace and combine like any other partial trace, but the combined trace is truncated as well. checkSize is
+// called with the size of the combined trace after each response, its error stops the combining.
+func combineIngesterResponses(responses []responseFromIngesters, checkSize func(size int) error) ingesterSearchResult {
+	var result ingesterSearchResult
+	var spanCount int
+	for _, r := range responses {
+		resp := r.response.(*tempopb.TraceByIDResponse)
+		result.truncated = result.truncated || resp.TraceTruncated
+
+		if resp.Trace != nil {
+			result.trace, _, _, spanCount = model.CombineTraceProtos(result.trace, resp.Trace)
+			result.spanCount += spanCount
+			result.traceCount++
+
+			if err := checkSize(result.trace.Size()); err != nil {
+				return ingesterSearchResult{err: err}
+			}
 		}
 	}
 
-	return &tempopb.TraceByIDResponse{
-		Trace: completeTrace,
-	}, nil
+	return result
+}
+
+// verifyReplicas compares the traces returned by each ingester and logs any differences.
+func (q *Querier) verifyReplicas(userID string, traceID []byte, responses []responseFromIngesters) *ReplicaDiff {
+	traces := make(map[string]*tempopb.Trace, len(responses))
+	for _, r := range responses {
+		traces[r.addr] = r.response.(*tempopb.TraceByIDResponse).Trace
+	}
+
+	diff := diffReplicas(traces)
+	if !diff.Empty() {
+		b, _ := json.Marshal(diff)
+		level.Warn(log.Logger).Log("msg", "ingester replicas returned different traces", "tenant", userID, "traceID", hex.EncodeToString(traceID), "diff", string(b))
+	}
+
+	return diff
 }
 
 // forGivenIngesters runs f, in parallel, for given ingesters
-func (q *Querier) forGivenIngesters(ctx context.Context, replicationSet ring.ReplicationSet, f func(client tempopb.QuerierClient) (interface{}, error)) ([]responseFromIngesters, error) {
+func (q *Querier) forGivenIngesters(ctx context.Context, replicationSet ring.ReplicationSet, f func(context.Context, tempopb.QuerierClient) (interface{}, error)) ([]responseFromIngesters, error) {
 	results, err := replicationSet.Do(ctx, q.cfg.ExtraQueryDelay, func(ctx context.Context, ingester *ring.InstanceDesc) (interface{}, error) {
 		client, err := q.pool.GetClientFor(ingester.Addr)
 		if err != nil {
 			return nil, err
 		}
 
-		resp, err := f(client.(tempopb.QuerierClient))
+		querystats.FromContext(ctx).AddIngesterQueried()
+		resp, err := f(ctx, client.(tempopb.QuerierClient))
 		if err != nil {
 			return nil, err
 		}
@@ -268,7 +774,7 @@ func (q *Querier) Search(ctx context.Context, req *tempopb.SearchRequest) (*temp
 		return nil, errors.Wrap(err, "error finding ingesters in Querier.Search")
 	}
 
-	responses, err := q.forGivenIngesters(ctx, replicationSet, func(client tempopb.QuerierClient) (interface{}, error) {
+	responses, err := q.forIngestersPreferLocalZone(ctx, opSearch, replicationSet, searchFound, func(ctx context.Context, client tempopb.QuerierClient) (interface{}, error) {
 		return client.Search(ctx, req)
 	})
 	if err != nil {
@@ -278,23 +784,159 @@ func (q *Querier) Search(ctx context.Context, req *tempopb.SearchRequest) (*temp
 	return q.postProcessSearchResults(req, responses), nil
 }
 
-func (q *Querier) SearchTags(ctx context.Context, req *tempopb.SearchTagsRequest) (*tempopb.SearchTagsResponse, error) {
+// SearchShard searches the ingesters of one shard. The ingesters of the read replication set are sorted by address and
+// split into shards so every ingester is searched by exactly one shard. Traces are usually found in several shards
+// and are expected to be deduped by the caller. Unlike Search a failing ingester fails the shard.
+func (q *Querier) SearchShard(ctx context.Context, req *tempopb.SearchRequest, shard, shards int) (*tempopb.SearchResponse, error) {
 	_, err := user.ExtractOrgID(ctx)
 	if err != nil {
-		return nil, errors.Wrap(err, "error extracting org id in Querier.SearchTags")
+		return nil, errors.Wrap(err, "error extracting org id in Querier.SearchShard")
 	}
 
 	replicationSet, err := q.ring.GetReplicationSetForOperation(ring.Read)
 	if err != nil {
-		return nil, errors.Wrap(err, "error finding ingesters in Querier.SearchTags")
+		return nil, errors.Wrap(err, "error finding ingesters in Querier.SearchShard")
+	}
+
+	replicationSet = shardReplicationSet(replicationSet, shard, shards)
+	if len(replicationSet.Instances) == 0 {
+		return &tempopb.SearchResponse{
+			Metrics: &tempopb.SearchMetrics{},
+		}, nil
+	}
+
+	responses, err := q.forGivenIngesters(ctx, replicationSet, func(ctx context.Context, client tempopb.QuerierClient) (interface{}, error) {
+		return client.Search(ctx, req)
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error querying ingesters in Querier.SearchShard")
+	}
+
+	return q.postProcessSearchResults(req, responses), nil
+}
+
+// SearchBlocks searches the backend blocks of the tenant written between start and end. The returned bool is true if
+// the search stopped at the max_search_bytes_read of the tenant and the results are partial.
+func (q *Querier) SearchBlocks(ctx context.Context, req *tempopb.SearchRequest, start, end time.Time) (*tempopb.SearchResponse, bool, error) {
+	userID, err := user.ExtractOrgID(ctx)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "error extracting org id in Querier.SearchBlocks")
+	}
+
+	resp, partial, err := q.store.SearchBlocks(ctx, userID, req, start, end, q.cfg.Search.ConcurrentBlocks, uint64(q.limits.MaxSearchBytesRead(userID)))
+	if err != nil {
+		return nil, false, errors.Wrap(err, "error querying store in Querier.SearchBlocks")
+	}
+
+	return resp, partial, nil
+}
+
+// SearchRange searches the ingesters and the backend blocks written between start and end concurrently and combines
+// their results. The returned bool is true if the results of the backend blocks are partial or if one of the tiers
+// timed out, in which case the results of the other tier are returned with a warning.
+func (q *Querier) SearchRange(ctx context.Context, req *tempopb.SearchRequest, start, end time.Time) (*tempopb.SearchResponse, bool, []string, error) {
+	var wg sync.WaitGroup
+	var blocksResp *tempopb.SearchResponse
+	var partial bool
+	var blocksErr error
+	var blocksTimedOut bool
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		tierCtx, tierCancel := withTierTimeout(ctx, q.cfg.Search.QueryBackendTimeout)
+		defer tierCancel()
+
+		blocksResp, partial, blocksErr = q.SearchBlocks(tierCtx, req, start, end)
+		blocksTimedOut = blocksErr != nil && tierTimedOut(ctx, tierCtx)
+	}()
+
+	tierCtx, tierCancel := withTierTimeout(ctx, q.cfg.Search.QueryIngestersTimeout)
+	defer tierCancel()
+	ingestersResp, err := q.Search(tierCtx, req)
+	ingestersTimedOut := err != nil && tierTimedOut(ctx, tierCtx)
+	wg.Wait()
+
+	var warnings []string
+	switch {
+	case ingestersTimedOut && blocksTimedOut:
+		return nil, false, nil, errors.New("error searching ingesters and blocks in Querier.SearchRange: both timed out")
+	case ingestersTimedOut && blocksErr == nil:
+		ingestersResp, err = &tempopb.SearchResponse{}, nil
+		partial = true
+		warnings = append(warnings, tierTimeoutWarning(tierIngesters, q.cfg.Search.QueryIngestersTimeout))
+	case blocksTimedOut && err == nil:
+		blocksResp, blocksErr = &tempopb.SearchResponse{}, nil
+		partial = true
+		warnings = append(warnings, tierTimeoutWarning(tierBackend, q.cfg.Search.QueryBackendTimeout))
+	}
+	if err != nil {
+		return nil, false, nil, err
+	}
+	if blocksErr != nil {
+		return nil, false, nil, blocksErr
+	}
+
+	return q.postProcessSearchResults(req, []responseFromIngesters{
+		{response: ingestersResp},
+		{response: blocksResp},
+	}), partial, warnings, nil
+}
+
+// searchExternal sends the search to the external endpoints and combines their results with the local ones. The
+// returned bool is true if an external endpoint failed or returned partial results.
+func (q *Querier) searchExternal(ctx context.Context, external *externalQuery, req *tempopb.SearchRequest, local *tempopb.SearchResponse, params url.Values) (*tempopb.SearchResponse, bool, error) {
+	userID, err := user.ExtractOrgID(ctx)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "error extracting org id in Querier.searchExternal")
+	}
+
+	responses, partial := q.external.search(ctx, external, userID, params)
+
+	rr := []responseFromIngesters{{response: local}}
+	for _, resp := range responses {
+		rr = append(rr, responseFromIngesters{response: resp})
+	}
+
+	return q.postProcessSearchResults(req, rr), partial, nil
+}
+
+// shardReplicationSet returns the instances of the shard without tolerating any failures.
+func shardReplicationSet(replicationSet ring.ReplicationSet, shard, shards int) ring.ReplicationSet {
+	instances := make([]ring.InstanceDesc, len(replicationSet.Instances))
+	copy(instances, replicationSet.Instances)
+	sort.Slice(instances, func(i, j int) bool {
+		return instances[i].Addr < instances[j].Addr
+	})
+
+	sharded := ring.ReplicationSet{}
+	for i, instance := range instances {
+		if i%shards == shard {
+			sharded.Instances = append(sharded.Instances, instance)
+		}
+	}
+	return sharded
+}
+
+// SearchTags returns the sorted tag names of the live search data of the ingesters. At most the tag names that fit
+// into the max_bytes_per_tag_values_query of the tenant are returned, in which case truncated is true.
+func (q *Querier) SearchTags(ctx context.Context, req *tempopb.SearchTagsRequest) (*tempopb.SearchTagsResponse, bool, error) {
+	userID, err := user.ExtractOrgID(ctx)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "error extracting org id in Querier.SearchTags")
+	}
+
+	replicationSet, err := q.ring.GetReplicationSetForOperation(ring.Read)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "error finding ingesters in Querier.SearchTags")
 	}
 
 	// Get results from all ingesters
-	lookupResults, err := q.forGivenIngesters(ctx, replicationSet, func(client tempopb.QuerierClient) (interface{}, error) {
+	lookupResults, err := q.forGivenIngesters(ctx, replicationSet, func(ctx context.Context, client tempopb.QuerierClient) (interface{}, error) {
 		return client.SearchTags(ctx, req)
 	})
 	if err != nil {
-		return nil, errors.Wrap(err, "error querying ingesters in Querier.SearchTags")
+		return nil, false, errors.Wrap(err, "error querying ingesters in Querier.SearchTags")
 	}
 
 	// Collect only unique values
@@ -306,34 +948,31 @@ func (q *Querier) SearchTags(ctx context.Context, req *tempopb.SearchTagsRequest
 	}
 
 	// Final response (sorted)
-	resp := &tempopb.SearchTagsResponse{
-		TagNames: make([]string, 0, len(uniqueMap)),
-	}
-	for k := range uniqueMap {
-		resp.TagNames = append(resp.TagNames, k)
-	}
-	sort.Strings(resp.TagNames)
-
-	return resp, nil
+	tagNames, truncated := limitTagValues(uniqueMap, q.limits.MaxBytesPerTagValuesQuery(userID))
+	return &tempopb.SearchTagsResponse{
+		TagNames: tagNames,
+	}, truncated, nil
 }
 
-func (q *Querier) SearchTagValues(ctx context.Context, req *tempopb.SearchTagValuesRequest) (*tempopb.SearchTagValuesResponse, error) {
-	_, err := user.ExtractOrgID(ctx)
+// SearchTagValues returns the sorted values of the tag in the live search data of the ingesters. At most the values
+// that fit into the max_bytes_per_tag_values_query of the tenant are returned, in which case truncated is true.
+func (q *Querier) SearchTagValues(ctx context.Context, req *tempopb.SearchTagValuesRequest) (*tempopb.SearchTagValuesResponse, bool, error) {
+	userID, err := user.ExtractOrgID(ctx)
 	if err != nil {
-		return nil, errors.Wrap(err, "error extracting org id in Querier.SearchTagValues")
+		return nil, false, errors.Wrap(err, "error extracting org id in Querier.SearchTagValues")
 	}
 
 	replicationSet, err := q.ring.GetReplicationSetForOperation(ring.Read)
 	if err != nil {
-		return nil, errors.Wrap(err, "error finding ingesters in Querier.SearchTagValues")
+		return nil, false, errors.Wrap(err, "error finding ingesters in Querier.SearchTagValues")
 	}
 
 	// Get results from all ingesters
-	lookupResults, err := q.forGivenIngesters(ctx, replicationSet, func(client tempopb.QuerierClient) (interface{}, error) {
+	lookupResults, err := q.forGivenIngesters(ctx, replicationSet, func(ctx context.Context, client tempopb.QuerierClient) (interface{}, error) {
 		return client.SearchTagValues(ctx, req)
 	})
 	if err != nil {
-		return nil, errors.Wrap(err, "error querying ingesters in Querier.SearchTagValues")
+		return nil, false, errors.Wrap(err, "error querying ingesters in Querier.SearchTagValues")
 	}
 
 	// Collect only unique values
@@ -345,15 +984,33 @@ func (q *Querier) SearchTagValues(ctx context.Context, req *tempopb.SearchTagVal
 	}
 
 	// Final response (sorted)
-	resp := &tempopb.SearchTagValuesResponse{
-		TagValues: make([]string, 0, len(uniqueMap)),
+	tagValues, truncated := limitTagValues(uniqueMap, q.limits.MaxBytesPerTagValuesQuery(userID))
+	return &tempopb.SearchTagValuesResponse{
+		TagValues: tagValues,
+	}, truncated, nil
+}
+
+// limitTagValues returns the sorted values up to the last one that fits into maxBytes, and true if any were dropped.
+// maxBytes of 0 is unlimited.
+func limitTagValues(values map[string]struct{}, maxBytes int) ([]string, bool) {
+	sorted := make([]string, 0, len(values))
+	for v := range values {
+		sorted = append(sorted, v)
 	}
-	for k := range uniqueMap {
-		resp.TagValues = append(resp.TagValues, k)
+	sort.Strings(sorted)
+
+	if maxBytes <= 0 {
+		return sorted, false
 	}
-	sort.Strings(resp.TagValues)
 
-	return resp, nil
+	size := 0
+	for i, v := range sorted {
+		size += len(v)
+		if size > maxBytes {
+			return sorted[:i], true
+		}
+	}
+	return sorted, false
 }
 
 func (q *Querier) postProcessSearchResults(req *tempopb.SearchRequest, rr []responseFromIngesters) *tempopb.SearchResponse {
@@ -394,6 +1051,47 @@ func (q *Querier) postProcessSearchResults(req *tempopb.SearchRequest, rr []resp
 	return response
 }
 
+// DeleteTrace deletes a trace of the tenant that has not been flushed to the backend from every ingester. Traces in
+// the backend are not changed.
+func (q *Querier) DeleteTrace(ctx context.Context, traceID []byte) (*tempopb.DeleteTraceResponse, error) {
+	if !validation.ValidTraceID(traceID) {
+		return nil, fmt.Errorf("invalid trace id")
+	}
+
+	replicationSet, err := q.ring.GetReplicationSetForOperation(ring.Read)
+	if err != nil {
+		return nil, errors.Wrap(err, "error finding ingesters in Querier.DeleteTrace")
+	}
+
+	return q.deleteTraceFromIngesters(ctx, replicationSet, traceID)
+}
+
+// deleteTraceFromIngesters fails if any ingester fails so the deletion can be retried, which is safe as deletions are
+// idempotent. It returns the spans removed by the replica that held the most of them.
+func (q *Querier) deleteTraceFromIngesters(ctx context.Context, replicationSet ring.ReplicationSet, traceID []byte) (*tempopb.DeleteTraceResponse, error) {
+	replicationSet.MaxErrors = 0
+	replicationSet.MaxUnavailableZones = 0
+
+	req := &tempopb.DeleteTraceRequest{
+		TraceID: traceID,
+	}
+	responses, err := q.forGivenIngesters(ctx, replicationSet, func(ctx context.Context, client tempopb.QuerierClient) (interface{}, error) {
+		return client.DeleteTrace(ctx, req)
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error deleting trace from ingesters in Querier.DeleteTrace")
+	}
+
+	resp := &tempopb.DeleteTraceResponse{}
+	for _, r := range responses {
+		if spans := r.response.(*tempopb.DeleteTraceResponse).SpansRemoved; spans > resp.SpansRemoved {
+			resp.SpansRemoved = spans
+		}
+	}
+
+	return resp, nil
+}
+
 // implements blocklist.JobSharder. Queriers rely on compactors to build the tenant
 // index which they then consume.
 func (q *Querier) Owns(_ string) bool {
@@ -2,21 +2,32 @@ package querier
 
 import (
 	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"io/ioutil"
 	"math/rand"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path"
 	"testing"
 	"time"
 
+	"github.com/cortexproject/cortex/pkg/ring"
 	"github.com/go-kit/kit/log"
 	"github.com/golang/protobuf/proto"
 	"github.com/google/uuid"
+	"github.com/gorilla/mux"
 	"github.com/grafana/tempo/pkg/model"
 	v1 "github.com/grafana/tempo/pkg/tempopb/trace/v1"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
 
+	"github.com/grafana/tempo/modules/overrides"
+	"github.com/grafana/tempo/modules/storage"
+	"github.com/grafana/tempo/pkg/querystats"
 	"github.com/grafana/tempo/pkg/tempopb"
 	"github.com/grafana/tempo/pkg/util"
 	"github.com/grafana/tempo/pkg/util/test"
@@ -24,6 +35,7 @@ import (
 	"github.com/grafana/tempo/tempodb/backend"
 	"github.com/grafana/tempo/tempodb/backend/local"
 	"github.com/grafana/tempo/tempodb/encoding"
+	"github.com/grafana/tempo/tempodb/encoding/common"
 	"github.com/grafana/tempo/tempodb/pool"
 	"github.com/grafana/tempo/tempodb/wal"
 )
@@ -106,7 +118,7 @@ func TestReturnAllHits(t *testing.T) {
 	time.Sleep(200 * time.Millisecond)
 
 	// find should return both now
-	foundBytes, _, err := r.Find(context.Background(), util.FakeTenantID, testTraceID, tempodb.BlockIDMin, tempodb.BlockIDMax)
+	foundBytes, _, _, err := r.Find(context.Background(), util.FakeTenantID, testTraceID, tempodb.BlockIDMin, tempodb.BlockIDMax, time.Time{}, time.Time{}, 0)
 	assert.NoError(t, err)
 	require.Len(t, foundBytes, 2)
 
@@ -124,3 +136,635 @@ func TestReturnAllHits(t *testing.T) {
 	model.SortTrace(actualTrace)
 	assert.Equal(t, expectedTrace, actualTrace)
 }
+
+func TestCombineIngesterResponses(t *testing.T) {
+	id := []byte{0x01, 0x02}
+	full := test.MakeTrace(10, id)
+	for _, b := range full.Batches {
+		for _, ils := range b.InstrumentationLibrarySpans {
+			for _, s := range ils.Spans {
+				s.StartTimeUnixNano = uint64(rand.Intn(1000))
+			}
+		}
+	}
+	expected := proto.Clone(full).(*tempopb.Trace)
+	partial, dropped := model.TruncateTrace(full, full.Size()/2)
+	require.Greater(t, dropped, 0)
+
+	noLimit := func(int) error { return nil }
+	result := combineIngesterResponses([]responseFromIngesters{
+		{addr: "a", response: &tempopb.TraceByIDResponse{Trace: partial, TraceTruncated: true}},
+		{addr: "b", response: &tempopb.TraceByIDResponse{Trace: full}},
+		{addr: "c", response: &tempopb.TraceByIDResponse{}},
+	}, noLimit)
+	require.NoError(t, result.err)
+	assert.True(t, result.truncated)
+	assert.Equal(t, 2, result.traceCount)
+
+	// the earliest spans of the truncated response are merged with the complete trace
+	assert.ElementsMatch(t, spanIDs(expected), spanIDs(result.trace))
+
+	result = combineIngesterResponses([]responseFromIngesters{
+		{addr: "a", response: &tempopb.TraceByIDResponse{}},
+	}, noLimit)
+	assert.Nil(t, result.trace)
+	assert.False(t, result.truncated)
+	assert.Equal(t, 0, result.traceCount)
+
+	// the size is checked after each response is combined
+	tooLarge := errors.New("too large")
+	var sizes []int
+	result = combineIngesterResponses([]responseFromIngesters{
+		{addr: "a", response: &tempopb.TraceByIDResponse{Trace: partial}},
+		{addr: "b", response: &tempopb.TraceByIDResponse{Trace: full}},
+	}, func(size int) error {
+		sizes = append(sizes, size)
+		return tooLarge
+	})
+	assert.Equal(t, tooLarge, result.err)
+	assert.Equal(t, []int{partial.Size()}, sizes)
+}
+
+func TestDeleteTraceFromIngesters(t *testing.T) {
+	traceID := make([]byte, 16)
+	_, err := rand.Read(traceID)
+	require.NoError(t, err)
+
+	replicationSet := ring.ReplicationSet{
+		Instances: []ring.InstanceDesc{
+			{Addr: "a", Zone: "zone-a"},
+			{Addr: "b", Zone: "zone-b"},
+			{Addr: "c", Zone: "zone-c"},
+		},
+		MaxUnavailableZones: 1,
+	}
+
+	// the spans removed by the replica that held the most are returned
+	clients := map[string]*mockIngesterClient{
+		"a": {spansRemoved: 3},
+		"b": {spansRemoved: 5},
+		"c": {},
+	}
+	q := zoneQuerier(Config{}, clients)
+	resp, err := q.deleteTraceFromIngesters(context.Background(), replicationSet, traceID)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(5), resp.SpansRemoved)
+
+	// every ingester must delete the trace even if one zone may be unavailable for queries
+	clients["c"] = &mockIngesterClient{err: errors.New("unavailable")}
+	q = zoneQuerier(Config{}, clients)
+	_, err = q.deleteTraceFromIngesters(context.Background(), replicationSet, traceID)
+	require.Error(t, err)
+}
+
+type mockReadRing struct {
+	ring.ReadRing
+	replicationSet ring.ReplicationSet
+}
+
+func (m *mockReadRing) GetReplicationSetForOperation(ring.Operation) (ring.ReplicationSet, error) {
+	return m.replicationSet, nil
+}
+
+type mockStore struct {
+	storage.Store
+	trace *tempopb.Trace
+	err   error
+	delay time.Duration
+
+	findMetrics   tempodb.FindMetrics
+	findMaxBlocks int
+	findStart     time.Time
+	findEnd       time.Time
+	skeleton      *tempopb.Trace
+	blockCount    int
+
+	searchResp        *tempopb.SearchResponse
+	searchPartial     bool
+	searchConcurrency int
+	searchMaxBytes    uint64
+}
+
+func (m *mockStore) Find(ctx context.Context, _ string, _ common.ID, _ string, _ string, start time.Time, end time.Time, maxBlocks int) ([][]byte, []string, tempodb.FindMetrics, error) {
+	m.findMaxBlocks = maxBlocks
+	m.findStart = start
+	m.findEnd = end
+	select {
+	case <-time.After(m.delay):
+	case <-ctx.Done():
+		return nil, nil, tempodb.FindMetrics{}, ctx.Err()
+	}
+	if m.err != nil {
+		return nil, nil, tempodb.FindMetrics{}, m.err
+	}
+	if m.trace == nil {
+		return nil, nil, m.findMetrics, nil
+	}
+
+	b, err := proto.Marshal(m.trace)
+	if err != nil {
+		return nil, nil, tempodb.FindMetrics{}, err
+	}
+	return [][]byte{b}, []string{""}, m.findMetrics, nil
+}
+
+func (m *mockStore) FindEach(ctx context.Context, tenantID string, id common.ID, blockStart string, blockEnd string, start time.Time, end time.Time, maxBlocks int, fn tempodb.FoundFunc) (tempodb.FindMetrics, error) {
+	return foundEach(fn)(m.Find(ctx, tenantID, id, blockStart, blockEnd, start, end, maxBlocks))
+}
+
+// foundEach hands the results of a Find to fn one by one like FindEach.
+func foundEach(fn tempodb.FoundFunc) func([][]byte, []string, tempodb.FindMetrics, error) (tempodb.FindMetrics, error) {
+	return func(partialTraces [][]byte, dataEncodings []string, metrics tempodb.FindMetrics, err error) (tempodb.FindMetrics, error) {
+		if err != nil {
+			return metrics, err
+		}
+		for i := range partialTraces {
+			if err := fn(partialTraces[i], dataEncodings[i]); err != nil {
+				return metrics, err
+			}
+		}
+		return metrics, nil
+	}
+}
+
+func (m *mockStore) BlockCount(string) int {
+	return m.blockCount
+}
+
+func (m *mockStore) FindSkeleton(context.Context, string, common.ID, string, string, time.Time, time.Time) ([][]byte, []string, error) {
+	if m.skeleton == nil {
+		return nil, nil, nil
+	}
+
+	b, err := proto.Marshal(m.skeleton)
+	if err != nil {
+		return nil, nil, err
+	}
+	return [][]byte{b}, []string{""}, nil
+}
+
+func (m *mockStore) SearchBlocks(ctx context.Context, _ string, _ *tempopb.SearchRequest, _ time.Time, _ time.Time, concurrency int, maxBytes uint64) (*tempopb.SearchResponse, bool, error) {
+	m.searchConcurrency = concurrency
+	m.searchMaxBytes = maxBytes
+	select {
+	case <-time.After(m.delay):
+	case <-ctx.Done():
+		return nil, false, ctx.Err()
+	}
+	return m.searchResp, m.searchPartial, m.err
+}
+
+func TestFindTraceByIDSearchesConcurrently(t *testing.T) {
+	traceID := make([]byte, 16)
+	_, err := rand.Read(traceID)
+	require.NoError(t, err)
+	ingesterTrace := test.MakeTrace(2, traceID)
+	storeTrace := test.MakeTrace(3, traceID)
+
+	replicationSet := ring.ReplicationSet{
+		Instances: []ring.InstanceDesc{{Addr: "a"}},
+	}
+	ctx := user.InjectOrgID(context.Background(), util.FakeTenantID)
+	delay := 200 * time.Millisecond
+
+	// combining changes the traces
+	expected, _, _, _ := model.CombineTraceProtos(proto.Clone(ingesterTrace).(*tempopb.Trace), proto.Clone(storeTrace).(*tempopb.Trace))
+
+	// the trace is combined from both without waiting for one before the other
+	q := zoneQuerier(Config{}, map[string]*mockIngesterClient{
+		"a": {trace: proto.Clone(ingesterTrace).(*tempopb.Trace), delay: delay},
+	})
+	q.ring = &mockReadRing{replicationSet: replicationSet}
+	q.store = &mockStore{trace: storeTrace, delay: delay}
+
+	stats := querystats.NewCollector()
+	start := time.Now()
+	resp, _, _, err := q.findTraceByID(querystats.NewContext(ctx, stats), &tempopb.TraceByIDRequest{
+		TraceID:   traceID,
+		QueryMode: QueryModeAll,
+	}, false, false)
+	require.NoError(t, err)
+	assert.Less(t, time.Since(start), 2*delay)
+	assert.Equal(t, expected, resp.Trace)
+
+	// the phases are collected in the stats of the query
+	s := stats.Stats()
+	assert.Equal(t, int64(1), s.IngestersQueried)
+	assert.GreaterOrEqual(t, s.IngestersWallTimeMs, float64(delay.Milliseconds()))
+	assert.GreaterOrEqual(t, s.StoreWallTimeMs, float64(delay.Milliseconds()))
+	assert.Greater(t, s.CombineWallTimeMs, 0.0)
+
+	// the mode restricts the search to one of them
+	resp, _, _, err = q.findTraceByID(ctx, &tempopb.TraceByIDRequest{
+		TraceID:   traceID,
+		QueryMode: QueryModeBlocks,
+	}, false, false)
+	require.NoError(t, err)
+	model.SortTrace(storeTrace)
+	model.SortTrace(resp.Trace)
+	assert.Equal(t, storeTrace, resp.Trace)
+
+	// a failure cancels the other search and is returned
+	q = zoneQuerier(Config{}, map[string]*mockIngesterClient{
+		"a": {trace: ingesterTrace, delay: time.Minute},
+	})
+	q.ring = &mockReadRing{replicationSet: replicationSet}
+	q.store = &mockStore{err: errors.New("store failed")}
+
+	start = time.Now()
+	_, _, _, err = q.findTraceByID(ctx, &tempopb.TraceByIDRequest{
+		TraceID:   traceID,
+		QueryMode: QueryModeAll,
+	}, false, false)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "store failed")
+	assert.Less(t, time.Since(start), delay)
+
+	// the slower search is cancelled a grace period after the other one found the trace
+	q = zoneQuerier(Config{Search: SearchConfig{SlowerTierGrace: 50 * time.Millisecond}}, map[string]*mockIngesterClient{
+		"a": {trace: proto.Clone(ingesterTrace).(*tempopb.Trace)},
+	})
+	q.ring = &mockReadRing{replicationSet: replicationSet}
+	q.store = &mockStore{trace: storeTrace, delay: time.Minute}
+
+	start = time.Now()
+	resp, _, _, err = q.findTraceByID(ctx, &tempopb.TraceByIDRequest{
+		TraceID:   traceID,
+		QueryMode: QueryModeAll,
+	}, false, false)
+	require.NoError(t, err)
+	assert.Less(t, time.Since(start), delay)
+	assert.Equal(t, ingesterTrace, resp.Trace)
+	assert.True(t, resp.Partial)
+	assert.Equal(t, []string{tierGraceWarning(tierBackend, 50*time.Millisecond)}, resp.Warnings)
+}
+
+func TestFindTraceByIDSlowerIngesters(t *testing.T) {
+	traceID := make([]byte, 16)
+	_, err := rand.Read(traceID)
+	require.NoError(t, err)
+	storeTrace := test.MakeTrace(3, traceID)
+	ctx := user.InjectOrgID(context.Background(), util.FakeTenantID)
+	req := &tempopb.TraceByIDRequest{
+		TraceID:   traceID,
+		QueryMode: QueryModeAll,
+	}
+
+	// the ingesters answer after the grace period
+	q := zoneQuerier(Config{
+		Search:     SearchConfig{SlowerTierGrace: 50 * time.Millisecond},
+		TraceCache: TraceCacheConfig{MaxBytes: 1 << 20, TTL: time.Minute},
+	}, map[string]*mockIngesterClient{
+		"a": {trace: test.MakeTrace(2, traceID), delay: time.Minute},
+	})
+	q.ring = &mockReadRing{replicationSet: ring.ReplicationSet{Instances: []ring.InstanceDesc{{Addr: "a"}}}}
+	q.store = &mockStore{trace: storeTrace}
+	q.traceCache = newTraceCache(q.cfg.TraceCache)
+
+	// the trace of the store is returned as a partial trace and isn't cached, the ingesters may hold more spans
+	resp, _, _, err := q.findTraceByID(ctx, req, false, false)
+	require.NoError(t, err)
+	assert.NotNil(t, resp.Trace)
+	assert.True(t, resp.Partial)
+	assert.Equal(t, []string{tierGraceWarning(tierIngesters, 50*time.Millisecond)}, resp.Warnings)
+
+	_, ok := q.traceCache.get(newTraceCacheKey(util.FakeTenantID, req, traceTimeRange{}))
+	assert.False(t, ok)
+}
+
+func TestFindTraceByIDMaxBlocks(t *testing.T) {
+	traceID := make([]byte, 16)
+	_, err := rand.Read(traceID)
+	require.NoError(t, err)
+	ctx := user.InjectOrgID(context.Background(), util.FakeTenantID)
+
+	limits, err := overrides.NewOverrides(overrides.Limits{
+		MaxBlocksPerTraceQuery: 2,
+	})
+	require.NoError(t, err)
+
+	q := zoneQuerier(Config{}, nil)
+	q.limits = limits
+	store := &mockStore{trace: test.MakeTrace(1, traceID)}
+	q.store = store
+
+	// all blocks that may hold the trace were inspected
+	store.findMetrics = tempodb.FindMetrics{InspectedBlocks: 2}
+	resp, _, _, err := q.findTraceByID(ctx, &tempopb.TraceByIDRequest{
+		TraceID:   traceID,
+		QueryMode: QueryModeBlocks,
+	}, false, false)
+	require.NoError(t, err)
+	assert.Equal(t, 2, store.findMaxBlocks)
+	assert.NotNil(t, resp.Trace)
+	assert.False(t, resp.Partial)
+
+	// the spans found so far are returned as a partial trace
+	store.findMetrics = tempodb.FindMetrics{InspectedBlocks: 2, SkippedBlocks: 3}
+	resp, _, _, err = q.findTraceByID(ctx, &tempopb.TraceByIDRequest{
+		TraceID:   traceID,
+		QueryMode: QueryModeBlocks,
+	}, false, false)
+	require.NoError(t, err)
+	assert.NotNil(t, resp.Trace)
+	assert.True(t, resp.Partial)
+}
+
+func TestFindTraceByIDSkeleton(t *testing.T) {
+	traceID := make([]byte, 16)
+	_, err := rand.Read(traceID)
+	require.NoError(t, err)
+	skeleton := model.Skeleton(test.MakeTrace(2, traceID), []byte{0x01})
+
+	ingester := &mockIngesterClient{}
+	store := &mockStore{skeleton: skeleton}
+	q := zoneQuerier(Config{QueryTimeout: 10 * time.Second, TraceCache: TraceCacheConfig{MaxBytes: 1 << 20, TTL: time.Minute}}, map[string]*mockIngesterClient{"a": ingester})
+	q.ring = &mockReadRing{replicationSet: ring.ReplicationSet{Instances: []ring.InstanceDesc{{Addr: "a"}}}}
+	q.store = store
+	q.traceCache = newTraceCache(q.cfg.TraceCache)
+
+	request := func() *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/api/traces/"+hex.EncodeToString(traceID), nil)
+		r = mux.SetURLVars(r, map[string]string{util.TraceIDVar: hex.EncodeToString(traceID)})
+		r = r.WithContext(user.InjectOrgID(r.Context(), util.FakeTenantID))
+		r.Header.Set(util.AcceptHeaderKey, util.ProtobufTypeHeaderValue)
+
+		w := httptest.NewRecorder()
+		q.TraceByIDHandler(w, r)
+		return w
+	}
+	responseSpans := func(w *httptest.ResponseRecorder) []string {
+		require.Equal(t, http.StatusOK, w.Code)
+		actual := &tempopb.Trace{}
+		require.NoError(t, proto.Unmarshal(w.Body.Bytes(), actual))
+		return spanIDs(actual)
+	}
+
+	// the skeleton of a trace deleted by retention is returned with a warning
+	w := request()
+	assert.ElementsMatch(t, spanIDs(skeleton), responseSpans(w))
+	assert.Equal(t, []string{SkeletonWarning}, w.Header().Values(WarningHeader))
+
+	// and is not cached
+	store.skeleton = model.Skeleton(test.MakeTrace(1, traceID), []byte{0x01})
+	assert.ElementsMatch(t, spanIDs(store.skeleton), responseSpans(request()))
+
+	// a trace found in the ingesters is returned instead of the skeleton
+	ingester.trace = test.MakeTrace(1, traceID)
+	w = request()
+	assert.ElementsMatch(t, spanIDs(ingester.trace), responseSpans(w))
+	assert.Empty(t, w.Header().Values(WarningHeader))
+
+	// as is a trace found in the blocks
+	ingester.trace = nil
+	store.trace = test.MakeTrace(1, traceID)
+	w = request()
+	assert.ElementsMatch(t, spanIDs(store.trace), responseSpans(w))
+	assert.Empty(t, w.Header().Values(WarningHeader))
+}
+
+func TestSearchTagValues(t *testing.T) {
+	replicationSet := ring.ReplicationSet{
+		Instances: []ring.InstanceDesc{{Addr: "a"}, {Addr: "b"}},
+	}
+	ctx := user.InjectOrgID(context.Background(), util.FakeTenantID)
+
+	tests := []struct {
+		name              string
+		maxBytes          int
+		expected          []string
+		expectedTruncated bool
+	}{
+		{
+			name:     "unlimited",
+			expected: []string{"bar", "baz", "foo", "quux"},
+		},
+		{
+			name:     "fits",
+			maxBytes: 13,
+			expected: []string{"bar", "baz", "foo", "quux"},
+		},
+		{
+			name:              "truncated",
+			maxBytes:          12,
+			expected:          []string{"bar", "baz", "foo"},
+			expectedTruncated: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			limits, err := overrides.NewOverrides(overrides.Limits{
+				MaxBytesPerTagValuesQuery: tc.maxBytes,
+			})
+			require.NoError(t, err)
+
+			// the values of all ingesters are deduped and sorted
+			q := zoneQuerier(Config{}, map[string]*mockIngesterClient{
+				"a": {tagValues: []string{"foo", "bar"}},
+				"b": {tagValues: []string{"quux", "baz", "foo"}},
+			})
+			q.ring = &mockReadRing{replicationSet: replicationSet}
+			q.limits = limits
+
+			resp, truncated, err := q.SearchTagValues(ctx, &tempopb.SearchTagValuesRequest{TagName: "tag"})
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, resp.TagValues)
+			assert.Equal(t, tc.expectedTruncated, truncated)
+		})
+	}
+}
+
+func TestSearchRange(t *testing.T) {
+	replicationSet := ring.ReplicationSet{
+		Instances: []ring.InstanceDesc{{Addr: "a"}},
+	}
+	ctx := user.InjectOrgID(context.Background(), util.FakeTenantID)
+
+	limits, err := overrides.NewOverrides(overrides.Limits{
+		MaxSearchBytesRead: 1000,
+	})
+	require.NoError(t, err)
+
+	q := zoneQuerier(Config{MaxConcurrentQueries: 10, Search: SearchConfig{ConcurrentBlocks: 5}}, map[string]*mockIngesterClient{
+		"a": {searchTraces: []*tempopb.TraceSearchMetadata{
+			{TraceID: "a", StartTimeUnixNano: 3},
+			{TraceID: "b", StartTimeUnixNano: 2},
+		}},
+	})
+	q.ring = &mockReadRing{replicationSet: replicationSet}
+	q.limits = limits
+	store := &mockStore{
+		searchResp: &tempopb.SearchResponse{
+			Traces: []*tempopb.TraceSearchMetadata{
+				{TraceID: "b", StartTimeUnixNano: 2},
+				{TraceID: "c", StartTimeUnixNano: 1},
+			},
+			Metrics: &tempopb.SearchMetrics{InspectedBlocks: 2},
+		},
+		searchPartial: true,
+	}
+	q.store = store
+
+	// the results of the ingesters and the blocks are combined
+	resp, partial, warnings, err := q.SearchRange(ctx, &tempopb.SearchRequest{}, time.Unix(10, 0), time.Unix(20, 0))
+	require.NoError(t, err)
+	assert.True(t, partial)
+	assert.Empty(t, warnings)
+	var ids []string
+	for _, tr := range resp.Traces {
+		ids = append(ids, tr.TraceID)
+	}
+	assert.Equal(t, []string{"a", "b", "c"}, ids)
+	assert.Equal(t, uint32(2), resp.Metrics.InspectedBlocks)
+	assert.Equal(t, 5, store.searchConcurrency)
+	assert.Equal(t, uint64(1000), store.searchMaxBytes)
+
+	// a failing store fails the search
+	store.err = errors.New("store failed")
+	_, _, _, err = q.SearchRange(ctx, &tempopb.SearchRequest{}, time.Unix(10, 0), time.Unix(20, 0))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "store failed")
+}
+
+func TestParseSearchRange(t *testing.T) {
+	tests := []struct {
+		query         string
+		expectedStart time.Time
+		expectedEnd   time.Time
+		expectedErr   bool
+	}{
+		{
+			query:         "start=10&end=20",
+			expectedStart: time.Unix(10, 0),
+			expectedEnd:   time.Unix(20, 0),
+		},
+		{
+			query:       "start=10",
+			expectedErr: true,
+		},
+		{
+			query:       "start=foo&end=20",
+			expectedErr: true,
+		},
+		{
+			query:       "start=20&end=10",
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.query, func(t *testing.T) {
+			start, end, err := parseSearchRange(httptest.NewRequest(http.MethodGet, "/api/search?"+tc.query, nil))
+			if tc.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedStart, start)
+			assert.Equal(t, tc.expectedEnd, end)
+		})
+	}
+}
+
+func TestShardReplicationSet(t *testing.T) {
+	replicationSet := ring.ReplicationSet{
+		Instances: []ring.InstanceDesc{
+			{Addr: "d"},
+			{Addr: "b"},
+			{Addr: "a"},
+			{Addr: "e"},
+			{Addr: "c"},
+		},
+		MaxErrors: 1,
+	}
+
+	addrs := func(rs ring.ReplicationSet) []string {
+		var addrs []string
+		for _, instance := range rs.Instances {
+			addrs = append(addrs, instance.Addr)
+		}
+		return addrs
+	}
+
+	// every ingester is in exactly one shard and failures are not tolerated
+	shard := shardReplicationSet(replicationSet, 0, 2)
+	assert.Equal(t, []string{"a", "c", "e"}, addrs(shard))
+	assert.Equal(t, 0, shard.MaxErrors)
+	assert.Equal(t, []string{"b", "d"}, addrs(shardReplicationSet(replicationSet, 1, 2)))
+
+	// more shards than ingesters
+	assert.Empty(t, shardReplicationSet(replicationSet, 5, 6).Instances)
+
+	// the replication set is not changed
+	assert.Equal(t, []string{"d", "b", "a", "e", "c"}, addrs(replicationSet))
+}
+
+func spanIDs(trace *tempopb.Trace) []string {
+	var ids []string
+	for _, b := range trace.Batches {
+		for _, ils := range b.InstrumentationLibrarySpans {
+			for _, s := range ils.Spans {
+				ids = append(ids, string(s.SpanId))
+			}
+		}
+	}
+	return ids
+}
+
+func TestTraceByIDHandlerNotFound(t *testing.T) {
+	traceID := []byte{0x01, 0x02}
+
+	request := func(cfg Config) *httptest.ResponseRecorder {
+		q := zoneQuerier(cfg, map[string]*mockIngesterClient{"a": {}})
+		q.ring = &mockReadRing{replicationSet: ring.ReplicationSet{Instances: []ring.InstanceDesc{{Addr: "a"}}}}
+		q.store = &mockStore{}
+
+		r := httptest.NewRequest(http.MethodGet, "/api/traces/"+hex.EncodeToString(traceID), nil)
+		r = mux.SetURLVars(r, map[string]string{util.TraceIDVar: hex.EncodeToString(traceID)})
+		r = r.WithContext(user.InjectOrgID(r.Context(), util.FakeTenantID))
+
+		w := httptest.NewRecorder()
+		q.TraceByIDHandler(w, r)
+		return w
+	}
+
+	// plain text by default
+	w := request(Config{QueryTimeout: 10 * time.Second})
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "Unable to find 00000000000000000000000000000102\n", w.Body.String())
+
+	w = request(Config{QueryTimeout: 10 * time.Second, StructuredNotFound: true})
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, util.JSONTypeHeaderValue, w.Header().Get("Content-Type"))
+	actual := &TraceNotFoundResponse{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), actual))
+	assert.Equal(t, &TraceNotFoundResponse{
+		TraceID:          "00000000000000000000000000000102",
+		Status:           TraceNotFoundStatus,
+		CheckedIngesters: 1,
+	}, actual)
+}
+
+func TestTraceByIDHandlerBlockCount(t *testing.T) {
+	traceID := []byte{0x01, 0x02}
+
+	request := func(mode string) *httptest.ResponseRecorder {
+		q := zoneQuerier(Config{QueryTimeout: 10 * time.Second}, map[string]*mockIngesterClient{"a": {}})
+		q.ring = &mockReadRing{replicationSet: ring.ReplicationSet{Instances: []ring.InstanceDesc{{Addr: "a"}}}}
+		q.store = &mockStore{blockCount: 42}
+
+		r := httptest.NewRequest(http.MethodGet, "/api/traces/"+hex.EncodeToString(traceID)+"?mode="+mode, nil)
+		r = mux.SetURLVars(r, map[string]string{util.TraceIDVar: hex.EncodeToString(traceID)})
+		r = r.WithContext(user.InjectOrgID(r.Context(), util.FakeTenantID))
+
+		w := httptest.NewRecorder()
+		q.TraceByIDHandler(w, r)
+		return w
+	}
+
+	// queries of the blocks report the block count of the tenant for the query-frontend to size its shards
+	assert.Equal(t, "42", request(QueryModeBlocks).Header().Get(BlockCountHeader))
+	assert.Equal(t, "42", request(QueryModeAll).Header().Get(BlockCountHeader))
+	assert.Empty(t, request(QueryModeIngesters).Header().Get(BlockCountHeader))
+}
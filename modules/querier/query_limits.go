@@ -0,0 +1,129 @@
+package querier
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/util/limiter"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/grafana/tempo/modules/overrides"
+	"github.com/grafana/tempo/tempodb/backend"
+)
+
+const (
+	reasonRateLimited   = "rate_limited"
+	reasonTraceTooLarge = "trace_too_large"
+)
+
+var metricRejectedQueries = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "tempo",
+	Name:      "querier_rejected_queries_total",
+	Help:      "The total number of queries rejected by the per tenant query limits.",
+}, []string{"tenant", "reason"})
+
+// traceTooLargeError is returned by a trace by id query that exceeded the max_bytes_per_trace_query of the tenant.
+type traceTooLargeError struct {
+	size  int
+	limit int
+}
+
+func (e *traceTooLargeError) Error() string {
+	return fmt.Sprintf("trace exceeds the max bytes per trace query of the tenant: more than %d bytes, the max is %d", e.size, e.limit)
+}
+
+// checkTraceSize returns a traceTooLargeError if size exceeds the max_bytes_per_trace_query of the tenant. The
+// ingesters and the store are checked concurrently so both may reject the same query, the rejection is counted once
+// by countRejectedTrace.
+func (q *Querier) checkTraceSize(userID string, size int) error {
+	limit := q.limits.MaxBytesPerTraceQuery(userID)
+	if limit <= 0 || size <= limit {
+		return nil
+	}
+
+	return &traceTooLargeError{size: size, limit: limit}
+}
+
+// countRejectedTrace counts the query as rejected if err is a traceTooLargeError. It's called once per query with
+// the error returned by findTraceByID.
+func countRejectedTrace(userID string, err error) {
+	var tooLarge *traceTooLargeError
+	if errors.As(err, &tooLarge) {
+		metricRejectedQueries.WithLabelValues(userID, reasonTraceTooLarge).Inc()
+	}
+}
+
+// queryErrorStatus returns the http status of a failed query. Errors of the backend have the status of their kind,
+// i.e. a throttled read is http.StatusTooManyRequests, and errors of the ingesters the status of their gRPC code.
+func queryErrorStatus(err error) int {
+	var tooLarge *traceTooLargeError
+	if errors.As(err, &tooLarge) {
+		return http.StatusUnprocessableEntity
+	}
+	return backend.HTTPStatus(err)
+}
+
+type queryRateStrategy struct {
+	limits *overrides.Overrides
+}
+
+func newQueryRateLimiter(limits *overrides.Overrides) *limiter.RateLimiter {
+	return limiter.NewRateLimiter(&queryRateStrategy{limits: limits}, 10*time.Second)
+}
+
+func (s *queryRateStrategy) Limit(userID string) float64 {
+	return s.limits.MaxQueriesPerSecond(userID)
+}
+
+func (s *queryRateStrategy) Burst(userID string) int {
+	if burst := s.limits.MaxQueriesBurst(userID); burst > 0 {
+		return burst
+	}
+	return int(math.Ceil(s.limits.MaxQueriesPerSecond(userID)))
+}
+
+// QueryShardHeader is set by the query-frontend on the shards of a query that aren't counted by the query rate limit,
+// all shards but the one that searches the ingesters, or the first shard of the ingesters, so a query is counted once.
+// The query-frontend removes it from the requests of its clients.
+const QueryShardHeader = "X-Tempo-Query-Shard"
+
+type frontendRequestKey struct{}
+
+// frontendRequests marks the requests pulled from the query-frontend by the workers of the querier. Only their
+// QueryShardHeader is trusted, requests sent to the http api of the querier are always counted.
+func frontendRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), frontendRequestKey{}, true)))
+	})
+}
+
+// isQueryShard returns true if r is a shard of a query the query-frontend already counted.
+func isQueryShard(r *http.Request) bool {
+	fromFrontend, _ := r.Context().Value(frontendRequestKey{}).(bool)
+	return fromFrontend && r.Header.Get(QueryShardHeader) != ""
+}
+
+// allowQuery returns false if the tenant exceeded its max_queries_per_second. Shards of a query sent by the
+// query-frontend aren't counted.
+func (q *Querier) allowQuery(r *http.Request, userID string) bool {
+	if isQueryShard(r) || q.limits.MaxQueriesPerSecond(userID) <= 0 {
+		return true
+	}
+
+	if q.queryRateLimiter.AllowN(time.Now(), userID, 1) {
+		return true
+	}
+
+	metricRejectedQueries.WithLabelValues(userID, reasonRateLimited).Inc()
+	return false
+}
+
+// rateLimitedError is the body of the responses to rate limited queries.
+func (q *Querier) rateLimitedError(userID string) string {
+	return fmt.Sprintf("query rate limit (%d queries/second) exceeded while querying for tenant %s", int(q.limits.MaxQueriesPerSecond(userID)), userID)
+}
@@ -0,0 +1,116 @@
+package querier
+
+import (
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/ring"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/grafana/tempo/modules/overrides"
+	"github.com/grafana/tempo/pkg/util"
+	"github.com/grafana/tempo/pkg/util/test"
+	"github.com/grafana/tempo/tempodb/backend"
+)
+
+func TestTraceByIDHandlerQueryLimits(t *testing.T) {
+	traceID := []byte{0x01, 0x02}
+	trace := test.MakeTraceWithSpanCount(1, 10, traceID)
+
+	newQuerier := func(limits overrides.Limits) *Querier {
+		o, err := overrides.NewOverrides(limits)
+		require.NoError(t, err)
+
+		q := zoneQuerier(Config{QueryTimeout: 10 * time.Second}, map[string]*mockIngesterClient{"a": {trace: trace}})
+		q.ring = &mockReadRing{replicationSet: ring.ReplicationSet{Instances: []ring.InstanceDesc{{Addr: "a"}}}}
+		q.store = &mockStore{trace: trace}
+		q.limits = o
+		q.queryRateLimiter = newQueryRateLimiter(o)
+		return q
+	}
+	send := func(q *Querier, h http.Handler, tenant string, mode string, queryShard bool) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/api/traces/"+hex.EncodeToString(traceID)+"?mode="+mode, nil)
+		r = mux.SetURLVars(r, map[string]string{util.TraceIDVar: hex.EncodeToString(traceID)})
+		r = r.WithContext(user.InjectOrgID(r.Context(), tenant))
+		if queryShard {
+			r.Header.Set(QueryShardHeader, "true")
+		}
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+	request := func(q *Querier, tenant string, mode string) *httptest.ResponseRecorder {
+		return send(q, http.HandlerFunc(q.TraceByIDHandler), tenant, mode, false)
+	}
+
+	// traces larger than the max bytes per trace query are rejected
+	q := newQuerier(overrides.Limits{MaxBytesPerTraceQuery: trace.Size() - 1})
+	before, err := test.GetCounterValue(metricRejectedQueries.WithLabelValues("too-large", reasonTraceTooLarge))
+	require.NoError(t, err)
+	w := request(q, "too-large", QueryModeAll)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), "max bytes per trace query")
+	after, err := test.GetCounterValue(metricRejectedQueries.WithLabelValues("too-large", reasonTraceTooLarge))
+	require.NoError(t, err)
+	assert.Equal(t, before+1, after)
+
+	q = newQuerier(overrides.Limits{MaxBytesPerTraceQuery: trace.Size()})
+	assert.Equal(t, http.StatusOK, request(q, "too-large", QueryModeAll).Code)
+
+	// queries over the rate limit are rejected, the shards of the query-frontend aren't counted
+	q = newQuerier(overrides.Limits{MaxQueriesPerSecond: 1})
+	fromFrontend := frontendRequests(http.HandlerFunc(q.TraceByIDHandler))
+	assert.Equal(t, http.StatusOK, send(q, fromFrontend, "rate-limited", QueryModeIngesters, false).Code)
+	assert.Equal(t, http.StatusOK, send(q, fromFrontend, "rate-limited", QueryModeBlocks, true).Code)
+	w = request(q, "rate-limited", QueryModeAll)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Contains(t, w.Body.String(), "query rate limit")
+
+	// the shard header is only trusted on the requests of the query-frontend
+	assert.Equal(t, http.StatusTooManyRequests, request(q, "rate-limited", QueryModeBlocks).Code)
+	assert.Equal(t, http.StatusTooManyRequests, send(q, http.HandlerFunc(q.TraceByIDHandler), "rate-limited", QueryModeBlocks, true).Code)
+
+	// the limit is per tenant
+	assert.Equal(t, http.StatusOK, request(q, "other", QueryModeAll).Code)
+}
+
+func TestTraceByIDHandlerBackendErrors(t *testing.T) {
+	traceID := []byte{0x01, 0x02}
+	tests := []struct {
+		err      error
+		expected int
+	}{
+		{err: backend.ErrDoesNotExist, expected: http.StatusInternalServerError},
+		{err: status.Error(codes.ResourceExhausted, "ingester busy"), expected: http.StatusTooManyRequests},
+		{err: backend.WrapError(backend.ErrThrottled, errors.New("slow down")), expected: http.StatusTooManyRequests},
+		{err: backend.WrapError(backend.ErrBadRequest, errors.New("invalid argument")), expected: http.StatusBadRequest},
+		{err: backend.WrapError(backend.ErrReadOnly, errors.New("access denied")), expected: http.StatusConflict},
+		{err: backend.WrapError(backend.ErrCorrupt, errors.New("bad digest")), expected: http.StatusInternalServerError},
+		{err: errors.New("wups"), expected: http.StatusInternalServerError},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.err.Error(), func(t *testing.T) {
+			q := zoneQuerier(Config{QueryTimeout: 10 * time.Second}, map[string]*mockIngesterClient{})
+			q.store = &mockStore{err: tc.err}
+
+			r := httptest.NewRequest(http.MethodGet, "/api/traces/"+hex.EncodeToString(traceID)+"?mode="+QueryModeBlocks, nil)
+			r = mux.SetURLVars(r, map[string]string{util.TraceIDVar: hex.EncodeToString(traceID)})
+			r = r.WithContext(user.InjectOrgID(r.Context(), "test"))
+
+			w := httptest.NewRecorder()
+			q.TraceByIDHandler(w, r)
+			assert.Equal(t, tc.expected, w.Code)
+		})
+	}
+}
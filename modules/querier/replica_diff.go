@@ -0,0 +1,169 @@
+package querier
+
+import (
+	"encoding/hex"
+	"sort"
+
+	"github.com/gogo/protobuf/proto"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+	v1 "github.com/grafana/tempo/pkg/tempopb/trace/v1"
+)
+
+const (
+	mismatchReasonAttributes = "attributes"
+	mismatchReasonFields     = "fields"
+)
+
+// ReplicaDiff summarizes the differences between the traces returned by each ingester replica.
+type ReplicaDiff struct {
+	Replicas []string `json:"replicas"`
+	// MissingSpans maps each replica to the span ids it did not return but at least one other replica did.
+	MissingSpans map[string][]string `json:"missingSpans,omitempty"`
+	Mismatches   []SpanMismatch      `json:"mismatches,omitempty"`
+	// Truncated is true if missing spans or mismatches were dropped by Limit.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// SpanMismatch records a span that was returned by multiple replicas with different contents.
+type SpanMismatch struct {
+	SpanID string `json:"spanID"`
+	// Replica is the replica whose span differs from the span returned by Reference.
+	Replica   string `json:"replica"`
+	Reference string `json:"reference"`
+	Reason    string `json:"reason"`
+}
+
+// Empty returns true if all replicas returned identical spans.
+func (d *ReplicaDiff) Empty() bool {
+	return len(d.MissingSpans) == 0 && len(d.Mismatches) == 0
+}
+
+// Limit returns a copy of the diff with at most n missing spans and mismatches in total, so it fits in a response
+// header. The missing spans are kept first, in replica order.
+func (d *ReplicaDiff) Limit(n int) *ReplicaDiff {
+	limited := &ReplicaDiff{
+		Replicas:  d.Replicas,
+		Truncated: d.Truncated,
+	}
+
+	for _, r := range d.Replicas {
+		missing, ok := d.MissingSpans[r]
+		if !ok {
+			continue
+		}
+		if len(missing) > n {
+			missing = missing[:n]
+			limited.Truncated = true
+		}
+		if len(missing) == 0 {
+			continue
+		}
+		if limited.MissingSpans == nil {
+			limited.MissingSpans = map[string][]string{}
+		}
+		limited.MissingSpans[r] = missing
+		n -= len(missing)
+	}
+
+	limited.Mismatches = d.Mismatches
+	if len(limited.Mismatches) > n {
+		limited.Mismatches = limited.Mismatches[:n]
+		limited.Truncated = true
+	}
+	if len(limited.Mismatches) == 0 {
+		limited.Mismatches = nil
+	}
+
+	return limited
+}
+
+// diffReplicas compares the traces returned by each replica span by span. A nil trace is treated
+// as a replica that returned no spans. The result is deterministic regardless of map order.
+func diffReplicas(traces map[string]*tempopb.Trace) *ReplicaDiff {
+	replicas := make([]string, 0, len(traces))
+	for r := range traces {
+		replicas = append(replicas, r)
+	}
+	sort.Strings(replicas)
+
+	spansByReplica := make(map[string]map[string]*v1.Span, len(replicas))
+	allSpanIDs := map[string]struct{}{}
+	for _, r := range replicas {
+		spans := map[string]*v1.Span{}
+		if t := traces[r]; t != nil {
+			for _, b := range t.Batches {
+				for _, ils := range b.InstrumentationLibrarySpans {
+					for _, s := range ils.Spans {
+						id := hex.EncodeToString(s.SpanId)
+						spans[id] = s
+						allSpanIDs[id] = struct{}{}
+					}
+				}
+			}
+		}
+		spansByReplica[r] = spans
+	}
+
+	spanIDs := make([]string, 0, len(allSpanIDs))
+	for id := range allSpanIDs {
+		spanIDs = append(spanIDs, id)
+	}
+	sort.Strings(spanIDs)
+
+	diff := &ReplicaDiff{
+		Replicas: replicas,
+	}
+
+	for _, id := range spanIDs {
+		var reference string
+		var referenceSpan *v1.Span
+
+		for _, r := range replicas {
+			s, ok := spansByReplica[r][id]
+			if !ok {
+				if diff.MissingSpans == nil {
+					diff.MissingSpans = map[string][]string{}
+				}
+				diff.MissingSpans[r] = append(diff.MissingSpans[r], id)
+				continue
+			}
+
+			if referenceSpan == nil {
+				reference, referenceSpan = r, s
+				continue
+			}
+
+			if proto.Equal(referenceSpan, s) {
+				continue
+			}
+
+			reason := mismatchReasonFields
+			if !attributesEqual(referenceSpan, s) {
+				reason = mismatchReasonAttributes
+			}
+			diff.Mismatches = append(diff.Mismatches, SpanMismatch{
+				SpanID:    id,
+				Replica:   r,
+				Reference: reference,
+				Reason:    reason,
+			})
+		}
+	}
+
+	return diff
+}
+
+func attributesEqual(a, b *v1.Span) bool {
+	if len(a.Attributes) != len(b.Attributes) {
+		return false
+	}
+
+	for i := range a.Attributes {
+		if !proto.Equal(a.Attributes[i], b.Attributes[i]) {
+			return false
+		}
+	}
+
+	return true
+}
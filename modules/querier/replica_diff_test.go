@@ -0,0 +1,138 @@
+package querier
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+	v1_common "github.com/grafana/tempo/pkg/tempopb/common/v1"
+	v1 "github.com/grafana/tempo/pkg/tempopb/trace/v1"
+)
+
+func TestDiffReplicas(t *testing.T) {
+	span := func(id byte, name string, attrVal string) *v1.Span {
+		s := &v1.Span{
+			SpanId: []byte{id},
+			Name:   name,
+		}
+		if attrVal != "" {
+			s.Attributes = []*v1_common.KeyValue{
+				{Key: "foo", Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_StringValue{StringValue: attrVal}}},
+			}
+		}
+		return s
+	}
+	trace := func(spans ...*v1.Span) *tempopb.Trace {
+		return &tempopb.Trace{
+			Batches: []*v1.ResourceSpans{
+				{
+					InstrumentationLibrarySpans: []*v1.InstrumentationLibrarySpans{
+						{Spans: spans},
+					},
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		traces   map[string]*tempopb.Trace
+		expected *ReplicaDiff
+	}{
+		{
+			name: "identical",
+			traces: map[string]*tempopb.Trace{
+				"a": trace(span(1, "s", "bar"), span(2, "s", "")),
+				"b": trace(span(1, "s", "bar"), span(2, "s", "")),
+			},
+			expected: &ReplicaDiff{
+				Replicas: []string{"a", "b"},
+			},
+		},
+		{
+			name: "missing spans and nil trace",
+			traces: map[string]*tempopb.Trace{
+				"a": trace(span(1, "s", ""), span(2, "s", "")),
+				"b": trace(span(1, "s", "")),
+				"c": nil,
+			},
+			expected: &ReplicaDiff{
+				Replicas: []string{"a", "b", "c"},
+				MissingSpans: map[string][]string{
+					"b": {"02"},
+					"c": {"01", "02"},
+				},
+			},
+		},
+		{
+			name: "mismatches",
+			traces: map[string]*tempopb.Trace{
+				"a": trace(span(1, "s", "bar"), span(2, "s", "")),
+				"b": trace(span(1, "s", "baz"), span(2, "t", "")),
+			},
+			expected: &ReplicaDiff{
+				Replicas: []string{"a", "b"},
+				Mismatches: []SpanMismatch{
+					{SpanID: "01", Replica: "b", Reference: "a", Reason: mismatchReasonAttributes},
+					{SpanID: "02", Replica: "b", Reference: "a", Reason: mismatchReasonFields},
+				},
+			},
+		},
+		{
+			name: "reference is first replica with the span",
+			traces: map[string]*tempopb.Trace{
+				"a": trace(),
+				"b": trace(span(1, "s", "")),
+				"c": trace(span(1, "t", "")),
+			},
+			expected: &ReplicaDiff{
+				Replicas: []string{"a", "b", "c"},
+				MissingSpans: map[string][]string{
+					"a": {"01"},
+				},
+				Mismatches: []SpanMismatch{
+					{SpanID: "01", Replica: "c", Reference: "b", Reason: mismatchReasonFields},
+				},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			actual := diffReplicas(tc.traces)
+			assert.Equal(t, tc.expected, actual)
+			assert.Equal(t, tc.expected.Empty(), actual.Empty())
+		})
+	}
+}
+
+func TestReplicaDiffLimit(t *testing.T) {
+	diff := &ReplicaDiff{
+		Replicas: []string{"a", "b", "c"},
+		MissingSpans: map[string][]string{
+			"a": {"01", "02"},
+			"c": {"03"},
+		},
+		Mismatches: []SpanMismatch{
+			{SpanID: "04", Replica: "b", Reference: "a", Reason: mismatchReasonFields},
+		},
+	}
+
+	assert.Equal(t, diff, diff.Limit(4))
+	assert.Equal(t, &ReplicaDiff{
+		Replicas: []string{"a", "b", "c"},
+		MissingSpans: map[string][]string{
+			"a": {"01", "02"},
+			"c": {"03"},
+		},
+		Truncated: true,
+	}, diff.Limit(3))
+	assert.Equal(t, &ReplicaDiff{
+		Replicas: []string{"a", "b", "c"},
+		MissingSpans: map[string][]string{
+			"a": {"01"},
+		},
+		Truncated: true,
+	}, diff.Limit(1))
+}
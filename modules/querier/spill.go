@@ -0,0 +1,217 @@
+package querier
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/cortexproject/cortex/pkg/util/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/gogo/protobuf/proto"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/grafana/tempo/pkg/model"
+	"github.com/grafana/tempo/pkg/tempopb"
+)
+
+const spillFilePattern = "trace-spill-*"
+
+var (
+	metricTraceSpills = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "querier_trace_spills_total",
+		Help:      "Total number of traces assembled on disk because the partial traces found in the backend exceeded the spill threshold.",
+	})
+	metricTraceSpillBytes = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "querier_trace_spill_bytes_total",
+		Help:      "Total number of bytes written to trace spill files.",
+	})
+)
+
+// openSpills tracks the spill files in use so they can be removed when the querier stops.
+var openSpills sync.Map
+
+// spilledTrace is a marshalled tempopb.Trace assembled in a temporary file. Marshalled traces are appended one after
+// the other which unmarshals as a single trace with all of their batches. Spans already written are dropped so the
+// file holds every span once. The ingestion time is kept out of the file and returned by IngestionTime.
+type spilledTrace struct {
+	f             *os.File
+	size          int64
+	spanIDs       map[string]struct{}
+	ingestionTime uint64
+}
+
+// partialTraceCollector combines the partial traces found in the backend as their blocks are searched, and checks the
+// size of the combined trace after each of them so a trace is never assembled much beyond the limit. Once the combined
+// trace exceeds the spill threshold it is moved to a spilledTrace, and every later partial trace is appended to it as
+// soon as it is found, so a trace above the threshold is never held in memory as a whole. A threshold of 0 never spills.
+type partialTraceCollector struct {
+	dir       string
+	threshold int
+	// checkSize is called with the size of the combined trace, or of the spilled trace, after each partial trace
+	checkSize func(size int) error
+
+	combined     []byte
+	dataEncoding string
+	spilled      *spilledTrace
+}
+
+// add is a tempodb.FoundFunc.
+func (c *partialTraceCollector) add(partialTrace []byte, dataEncoding string) error {
+	if c.spilled != nil {
+		return c.spill(partialTrace, dataEncoding)
+	}
+
+	if c.combined == nil {
+		c.combined, c.dataEncoding = partialTrace, dataEncoding
+	} else {
+		combined, _, err := model.CombineTraceBytes(c.combined, partialTrace, c.dataEncoding, dataEncoding)
+		if err != nil {
+			return errors.Wrap(err, "error combining partial traces")
+		}
+		c.combined = combined
+	}
+	if c.threshold <= 0 || len(c.combined) <= c.threshold {
+		return c.checkSize(len(c.combined))
+	}
+
+	s, err := newSpilledTrace(c.dir)
+	if err != nil {
+		return err
+	}
+	c.spilled = s
+
+	combined := c.combined
+	c.combined = nil
+	return c.spill(combined, c.dataEncoding)
+}
+
+// partialTraces returns the combined trace if it's held in memory.
+func (c *partialTraceCollector) partialTraces() ([][]byte, []string) {
+	if c.combined == nil {
+		return nil, nil
+	}
+	return [][]byte{c.combined}, []string{c.dataEncoding}
+}
+
+func (c *partialTraceCollector) spill(partialTrace []byte, dataEncoding string) error {
+	trace, err := model.Unmarshal(partialTrace, dataEncoding)
+	if err != nil {
+		return errors.Wrap(err, "error unmarshaling partial trace")
+	}
+
+	err = c.spilled.append(trace)
+	if err != nil {
+		return err
+	}
+	return c.checkSize(int(c.spilled.size))
+}
+
+// close removes the spill file, if the partial traces were spilled.
+func (c *partialTraceCollector) close() {
+	if c.spilled != nil {
+		c.spilled.Close()
+		c.spilled = nil
+	}
+}
+
+// newSpilledTrace creates an empty spilled trace in a temporary file in dir.
+func newSpilledTrace(dir string) (*spilledTrace, error) {
+	f, err := ioutil.TempFile(dir, spillFilePattern)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating trace spill file")
+	}
+	openSpills.Store(f.Name(), struct{}{})
+	metricTraceSpills.Inc()
+
+	return &spilledTrace{
+		f:       f,
+		spanIDs: map[string]struct{}{},
+	}, nil
+}
+
+func (s *spilledTrace) append(trace *tempopb.Trace) error {
+	for _, b := range trace.Batches {
+		for _, ils := range b.InstrumentationLibrarySpans {
+			spans := ils.Spans[:0]
+			for _, span := range ils.Spans {
+				if _, ok := s.spanIDs[string(span.SpanId)]; ok {
+					continue
+				}
+				s.spanIDs[string(span.SpanId)] = struct{}{}
+				spans = append(spans, span)
+			}
+			ils.Spans = spans
+		}
+	}
+
+	s.ingestionTime = model.EarliestIngestionTime(s.ingestionTime, trace.IngestionTimeUnixNano)
+	trace.IngestionTimeUnixNano = 0
+
+	b, err := proto.Marshal(trace)
+	if err != nil {
+		return errors.Wrap(err, "error marshaling trace to spill")
+	}
+
+	n, err := s.f.Write(b)
+	s.size += int64(n)
+	metricTraceSpillBytes.Add(float64(n))
+	if err != nil {
+		return errors.Wrap(err, "error writing trace spill file")
+	}
+	return nil
+}
+
+// Empty returns true if no spans were written.
+func (s *spilledTrace) Empty() bool {
+	return len(s.spanIDs) == 0
+}
+
+// IngestionTime returns the earliest ingestion time of the spilled traces in unix nanoseconds, 0 if unknown.
+func (s *spilledTrace) IngestionTime() uint64 {
+	return s.ingestionTime
+}
+
+// WriteTo streams the marshalled trace to w.
+func (s *spilledTrace) WriteTo(w io.Writer) (int64, error) {
+	_, err := s.f.Seek(0, io.SeekStart)
+	if err != nil {
+		return 0, err
+	}
+	return io.Copy(w, s.f)
+}
+
+// Close removes the spill file.
+func (s *spilledTrace) Close() error {
+	openSpills.Delete(s.f.Name())
+	s.f.Close()
+	return os.Remove(s.f.Name())
+}
+
+// removeSpillFiles removes the spill files still in use, and any left in dir by a querier that did not stop cleanly.
+func removeSpillFiles(dir string) {
+	openSpills.Range(func(name, _ interface{}) bool {
+		_ = os.Remove(name.(string))
+		openSpills.Delete(name)
+		return true
+	})
+
+	if dir == "" {
+		return
+	}
+
+	leftovers, err := filepath.Glob(filepath.Join(dir, spillFilePattern))
+	if err != nil {
+		return
+	}
+	for _, name := range leftovers {
+		if err := os.Remove(name); err != nil {
+			level.Warn(log.Logger).Log("msg", "failed to remove trace spill file", "file", name, "err", err)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package querier
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tempo/pkg/model"
+	"github.com/grafana/tempo/pkg/tempopb"
+	"github.com/grafana/tempo/pkg/util/test"
+)
+
+func spanCount(trace *tempopb.Trace) int {
+	count := 0
+	for _, b := range trace.Batches {
+		for _, ils := range b.InstrumentationLibrarySpans {
+			count += len(ils.Spans)
+		}
+	}
+	return count
+}
+
+func TestPartialTraceCollector(t *testing.T) {
+	dir := t.TempDir()
+	id := []byte{0x01, 0x02, 0x03, 0x04}
+
+	ingesterTrace := test.MakeTrace(2, id)
+	traceA := test.MakeTrace(3, id)
+	traceB := test.MakeTrace(4, id)
+	expected := spanCount(ingesterTrace) + spanCount(traceA) + spanCount(traceB)
+
+	a, err := proto.Marshal(traceA)
+	require.NoError(t, err)
+	b, err := proto.Marshal(traceB)
+	require.NoError(t, err)
+
+	// the ingester trace is also found in the backend, its spans are written once
+	i, err := proto.Marshal(ingesterTrace)
+	require.NoError(t, err)
+
+	var sizes []int
+	c := &partialTraceCollector{
+		dir:       dir,
+		threshold: len(a),
+		checkSize: func(size int) error {
+			sizes = append(sizes, size)
+			return nil
+		},
+	}
+
+	// below the threshold the partial traces are combined in memory
+	require.NoError(t, c.add(a, model.TracePBEncoding))
+	assert.Nil(t, c.spilled)
+	partialTraces, _ := c.partialTraces()
+	assert.Len(t, partialTraces, 1)
+	assert.Equal(t, []int{len(a)}, sizes)
+
+	// above it they are spilled, and every later one as soon as it's added
+	require.NoError(t, c.add(i, model.TracePBEncoding))
+	require.NotNil(t, c.spilled)
+	partialTraces, _ = c.partialTraces()
+	assert.Empty(t, partialTraces)
+	assert.Len(t, sizes, 2)
+
+	require.NoError(t, c.add(b, model.TracePBEncoding))
+	assert.Len(t, sizes, 3)
+
+	require.NoError(t, c.spilled.append(ingesterTrace))
+	assert.False(t, c.spilled.Empty())
+
+	buffer := &bytes.Buffer{}
+	_, err = c.spilled.WriteTo(buffer)
+	require.NoError(t, err)
+
+	actual := &tempopb.Trace{}
+	require.NoError(t, proto.Unmarshal(buffer.Bytes(), actual))
+	assert.Equal(t, expected, spanCount(actual))
+
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, files, 1)
+
+	c.close()
+	files, err = ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, files, 0)
+}
+
+func TestPartialTraceCollectorTooLarge(t *testing.T) {
+	dir := t.TempDir()
+	tooLarge := errors.New("too large")
+
+	a, err := proto.Marshal(test.MakeTrace(2, nil))
+	require.NoError(t, err)
+
+	// the size is checked as the partial traces are combined, in memory and on disk
+	for _, threshold := range []int{0, 1} {
+		c := &partialTraceCollector{
+			dir:       dir,
+			threshold: threshold,
+			checkSize: func(int) error {
+				return tooLarge
+			},
+		}
+		require.ErrorIs(t, c.add(a, model.TracePBEncoding), tooLarge)
+
+		c.close()
+		files, err := ioutil.ReadDir(dir)
+		require.NoError(t, err)
+		assert.Len(t, files, 0)
+	}
+}
+
+func TestRemoveSpillFiles(t *testing.T) {
+	dir := t.TempDir()
+
+	// left by a previous process
+	leftover := filepath.Join(dir, "trace-spill-1234")
+	require.NoError(t, ioutil.WriteFile(leftover, []byte{0x01}, 0600))
+	other := filepath.Join(dir, "other")
+	require.NoError(t, ioutil.WriteFile(other, []byte{0x01}, 0600))
+
+	// in use by this process
+	spilled, err := newSpilledTrace(t.TempDir())
+	require.NoError(t, err)
+
+	removeSpillFiles(dir)
+
+	_, err = os.Stat(leftover)
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(other)
+	assert.NoError(t, err)
+	_, err = os.Stat(spilled.f.Name())
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,111 @@
+package querier
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/atomic"
+)
+
+const (
+	tierIngesters = "ingesters"
+	tierBackend   = "backend"
+)
+
+// tierDeadline returns the deadline of the fan-out of a query to a tier, the ingesters or the backend. It is timeout
+// from now unless the deadline of the parent is earlier. ok is false if there is no deadline: the parent has none and
+// timeout is 0.
+func tierDeadline(now time.Time, timeout time.Duration, parent time.Time, hasParent bool) (deadline time.Time, ok bool) {
+	if timeout <= 0 {
+		return parent, hasParent
+	}
+
+	deadline = now.Add(timeout)
+	if hasParent && parent.Before(deadline) {
+		return parent, true
+	}
+	return deadline, true
+}
+
+// withTierTimeout returns the context of the fan-out of a query to a tier. A timeout of 0 leaves the deadline of the
+// parent.
+func withTierTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	parent, hasParent := ctx.Deadline()
+	deadline, ok := tierDeadline(time.Now(), timeout, parent, hasParent)
+	if !ok || (hasParent && deadline.Equal(parent)) {
+		return context.WithCancel(ctx)
+	}
+	return context.WithDeadline(ctx, deadline)
+}
+
+// tierTimedOut returns true if the fan-out to a tier stopped at its own timeout while the query still had time left.
+// Errors returned after the timeout are wrapped differently by the ingester clients and the backends, so the contexts
+// are checked instead.
+func tierTimedOut(parent context.Context, tier context.Context) bool {
+	return parent.Err() == nil && tier.Err() == context.DeadlineExceeded
+}
+
+// tierTimeoutWarning is the warning of a query that returned the results of one tier while the other timed out.
+func tierTimeoutWarning(tier string, timeout time.Duration) string {
+	return fmt.Sprintf("the %s did not answer within %s, results may be incomplete", tier, timeout)
+}
+
+// tierGraceWarning is the warning of a query that returned the trace found by one tier while the other was cancelled
+// by the slower tier grace.
+func tierGraceWarning(tier string, grace time.Duration) string {
+	return fmt.Sprintf("the %s did not answer within %s of the trace being found, results may be incomplete", tier, grace)
+}
+
+// tierGrace cancels the search of a tier a grace period after the other tier found the trace.
+type tierGrace struct {
+	grace  time.Duration
+	cancel context.CancelFunc
+
+	once  sync.Once
+	mtx   sync.Mutex
+	timer *time.Timer
+	fired atomic.Bool
+}
+
+// slowerTierGrace returns the context of the search of a tier and its grace. The grace does nothing unless enabled and
+// the SlowerTierGrace of the search config is set.
+func (q *Querier) slowerTierGrace(ctx context.Context, enabled bool) (context.Context, *tierGrace) {
+	ctx, cancel := context.WithCancel(ctx)
+	g := &tierGrace{cancel: cancel}
+	if enabled {
+		g.grace = q.cfg.Search.SlowerTierGrace
+	}
+	return ctx, g
+}
+
+// start cancels the search after the grace period.
+func (g *tierGrace) start() {
+	if g.grace <= 0 {
+		return
+	}
+	g.once.Do(func() {
+		g.mtx.Lock()
+		defer g.mtx.Unlock()
+		g.timer = time.AfterFunc(g.grace, func() {
+			g.fired.Store(true)
+			g.cancel()
+		})
+	})
+}
+
+// cancelled returns true if the search was cancelled by the grace while the query still had time left.
+func (g *tierGrace) cancelled(parent context.Context) bool {
+	return parent.Err() == nil && g.fired.Load()
+}
+
+// stop releases the context and the timer of the grace.
+func (g *tierGrace) stop() {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+	if g.timer != nil {
+		g.timer.Stop()
+	}
+	g.cancel()
+}
@@ -0,0 +1,182 @@
+package querier
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/ring"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/tempo/modules/overrides"
+	"github.com/grafana/tempo/pkg/tempopb"
+	"github.com/grafana/tempo/pkg/util"
+	"github.com/grafana/tempo/pkg/util/test"
+)
+
+func TestTierDeadline(t *testing.T) {
+	now := time.Unix(1000, 0)
+
+	tests := []struct {
+		name      string
+		timeout   time.Duration
+		parent    time.Time
+		hasParent bool
+		expected  time.Time
+		ok        bool
+	}{
+		{
+			name: "no deadlines",
+		},
+		{
+			name:      "no timeout keeps the parent",
+			parent:    now.Add(time.Minute),
+			hasParent: true,
+			expected:  now.Add(time.Minute),
+			ok:        true,
+		},
+		{
+			name:     "timeout without parent",
+			timeout:  time.Second,
+			expected: now.Add(time.Second),
+			ok:       true,
+		},
+		{
+			name:      "timeout stricter than parent",
+			timeout:   time.Second,
+			parent:    now.Add(time.Minute),
+			hasParent: true,
+			expected:  now.Add(time.Second),
+			ok:        true,
+		},
+		{
+			name:      "parent stricter than timeout",
+			timeout:   time.Minute,
+			parent:    now.Add(time.Second),
+			hasParent: true,
+			expected:  now.Add(time.Second),
+			ok:        true,
+		},
+		{
+			name:      "parent passed",
+			timeout:   time.Minute,
+			parent:    now.Add(-time.Second),
+			hasParent: true,
+			expected:  now.Add(-time.Second),
+			ok:        true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			deadline, ok := tierDeadline(now, tc.timeout, tc.parent, tc.hasParent)
+			assert.Equal(t, tc.ok, ok)
+			assert.Equal(t, tc.expected, deadline)
+		})
+	}
+}
+
+func TestTierTimedOut(t *testing.T) {
+	parent, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	tier, tierCancel := withTierTimeout(parent, time.Millisecond)
+	defer tierCancel()
+	<-tier.Done()
+	assert.True(t, tierTimedOut(parent, tier))
+
+	// a tier cancelled by its parent didn't time out
+	tier, tierCancel = withTierTimeout(parent, time.Minute)
+	defer tierCancel()
+	cancel()
+	<-tier.Done()
+	assert.False(t, tierTimedOut(parent, tier))
+
+	// nor did a tier that stopped at the deadline of the query
+	parent, cancel = context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	tier, tierCancel = withTierTimeout(parent, time.Minute)
+	defer tierCancel()
+	<-tier.Done()
+	assert.False(t, tierTimedOut(parent, tier))
+}
+
+func TestFindTraceByIDTierTimeout(t *testing.T) {
+	traceID := make([]byte, 16)
+	_, err := rand.Read(traceID)
+	require.NoError(t, err)
+	ingesterTrace := test.MakeTrace(2, traceID)
+	storeTrace := test.MakeTrace(3, traceID)
+	ctx := user.InjectOrgID(context.Background(), util.FakeTenantID)
+
+	newQuerier := func(cfg SearchConfig, ingesterDelay time.Duration, storeDelay time.Duration) *Querier {
+		q := zoneQuerier(Config{QueryTimeout: 10 * time.Second, Search: cfg}, map[string]*mockIngesterClient{"a": {trace: ingesterTrace, delay: ingesterDelay}})
+		q.ring = &mockReadRing{replicationSet: ring.ReplicationSet{Instances: []ring.InstanceDesc{{Addr: "a"}}}}
+		q.store = &mockStore{trace: storeTrace, delay: storeDelay}
+		return q
+	}
+	find := func(q *Querier, mode string) (*tempopb.TraceByIDResponse, error) {
+		resp, _, _, err := q.findTraceByID(ctx, &tempopb.TraceByIDRequest{TraceID: traceID, QueryMode: mode}, false, false)
+		return resp, err
+	}
+
+	// slow ingesters are a warning if the store answered
+	q := newQuerier(SearchConfig{QueryIngestersTimeout: 10 * time.Millisecond}, time.Second, 0)
+	resp, err := find(q, QueryModeAll)
+	require.NoError(t, err)
+	assert.True(t, resp.Partial)
+	assert.Equal(t, []string{tierTimeoutWarning(tierIngesters, 10*time.Millisecond)}, resp.Warnings)
+	assert.ElementsMatch(t, spanIDs(storeTrace), spanIDs(resp.Trace))
+
+	// as is a slow store if the ingesters answered
+	q = newQuerier(SearchConfig{QueryBackendTimeout: 10 * time.Millisecond}, 0, time.Second)
+	resp, err = find(q, QueryModeAll)
+	require.NoError(t, err)
+	assert.True(t, resp.Partial)
+	assert.Equal(t, []string{tierTimeoutWarning(tierBackend, 10*time.Millisecond)}, resp.Warnings)
+	assert.ElementsMatch(t, spanIDs(ingesterTrace), spanIDs(resp.Trace))
+
+	// both timing out is an error
+	q = newQuerier(SearchConfig{QueryIngestersTimeout: 10 * time.Millisecond, QueryBackendTimeout: 10 * time.Millisecond}, time.Second, time.Second)
+	_, err = find(q, QueryModeAll)
+	assert.Error(t, err)
+
+	// as is a timeout of the only tier searched
+	q = newQuerier(SearchConfig{QueryIngestersTimeout: 10 * time.Millisecond}, time.Second, 0)
+	_, err = find(q, QueryModeIngesters)
+	assert.Error(t, err)
+
+	// tiers answering within their timeouts return complete results
+	q = newQuerier(SearchConfig{QueryIngestersTimeout: time.Second, QueryBackendTimeout: time.Second}, 0, 0)
+	resp, err = find(q, QueryModeAll)
+	require.NoError(t, err)
+	assert.False(t, resp.Partial)
+	assert.Empty(t, resp.Warnings)
+}
+
+func TestSearchRangeTierTimeout(t *testing.T) {
+	ctx := user.InjectOrgID(context.Background(), util.FakeTenantID)
+	limits, err := overrides.NewOverrides(overrides.Limits{})
+	require.NoError(t, err)
+
+	q := zoneQuerier(Config{Search: SearchConfig{QueryBackendTimeout: 10 * time.Millisecond}}, map[string]*mockIngesterClient{
+		"a": {searchTraces: []*tempopb.TraceSearchMetadata{{TraceID: "a"}}},
+	})
+	q.ring = &mockReadRing{replicationSet: ring.ReplicationSet{Instances: []ring.InstanceDesc{{Addr: "a"}}}}
+	q.limits = limits
+	q.store = &mockStore{
+		searchResp: &tempopb.SearchResponse{Traces: []*tempopb.TraceSearchMetadata{{TraceID: "b"}}},
+		delay:      time.Second,
+	}
+
+	// the results of the ingesters are returned if the blocks time out
+	resp, partial, warnings, err := q.SearchRange(ctx, &tempopb.SearchRequest{}, time.Unix(10, 0), time.Unix(20, 0))
+	require.NoError(t, err)
+	assert.True(t, partial)
+	assert.Equal(t, []string{tierTimeoutWarning(tierBackend, 10*time.Millisecond)}, warnings)
+	require.Len(t, resp.Traces, 1)
+	assert.Equal(t, "a", resp.Traces[0].TraceID)
+}
@@ -0,0 +1,151 @@
+package querier
+
+import (
+	"container/list"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+)
+
+var (
+	metricTraceCacheHits = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "querier_trace_cache_hits_total",
+		Help:      "The total number of trace by id queries answered from the trace cache.",
+	})
+	metricTraceCacheMisses = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "querier_trace_cache_misses_total",
+		Help:      "The total number of trace by id queries not found in the trace cache.",
+	})
+)
+
+// traceCacheKey identifies the response of a trace by id query. The shards of a query sent by the query-frontend
+// search different blocks and are cached separately, as are queries with different time range hints.
+type traceCacheKey struct {
+	tenantID   string
+	traceID    string
+	blockStart string
+	blockEnd   string
+	queryMode  string
+	timeRange  traceTimeRange
+}
+
+func newTraceCacheKey(tenantID string, req *tempopb.TraceByIDRequest, timeRange traceTimeRange) traceCacheKey {
+	return traceCacheKey{
+		tenantID:   tenantID,
+		traceID:    hex.EncodeToString(req.TraceID),
+		blockStart: req.BlockStart,
+		blockEnd:   req.BlockEnd,
+		queryMode:  req.QueryMode,
+		timeRange:  timeRange,
+	}
+}
+
+type traceCacheEntry struct {
+	key     traceCacheKey
+	resp    []byte
+	expires time.Time
+}
+
+// traceCache holds the marshalled responses of recent trace by id queries so reloading a trace doesn't read the
+// backend again. The least recently used responses are evicted once the cache exceeds maxBytes and responses expire
+// after ttl.
+type traceCache struct {
+	maxBytes int
+	ttl      time.Duration
+
+	mtx     sync.Mutex
+	bytes   int
+	entries map[traceCacheKey]*list.Element
+	lru     *list.List
+	now     func() time.Time
+}
+
+func newTraceCache(cfg TraceCacheConfig) *traceCache {
+	if cfg.MaxBytes <= 0 || cfg.TTL <= 0 {
+		return nil
+	}
+
+	return &traceCache{
+		maxBytes: cfg.MaxBytes,
+		ttl:      cfg.TTL,
+		entries:  map[traceCacheKey]*list.Element{},
+		lru:      list.New(),
+		now:      time.Now,
+	}
+}
+
+// get returns a copy of the cached response, which the caller is free to modify.
+func (c *traceCache) get(key traceCacheKey) (*tempopb.TraceByIDResponse, bool) {
+	b := c.getBytes(key)
+	if b == nil {
+		metricTraceCacheMisses.Inc()
+		return nil, false
+	}
+
+	resp := &tempopb.TraceByIDResponse{}
+	if err := resp.Unmarshal(b); err != nil {
+		metricTraceCacheMisses.Inc()
+		return nil, false
+	}
+
+	metricTraceCacheHits.Inc()
+	return resp, true
+}
+
+func (c *traceCache) getBytes(key traceCacheKey) []byte {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+
+	entry := e.Value.(*traceCacheEntry)
+	if c.now().After(entry.expires) {
+		c.remove(e)
+		return nil
+	}
+
+	c.lru.MoveToFront(e)
+	return entry.resp
+}
+
+// put caches the response. Responses larger than the cache are skipped.
+func (c *traceCache) put(key traceCacheKey, resp *tempopb.TraceByIDResponse) {
+	b, err := resp.Marshal()
+	if err != nil || len(b) > c.maxBytes {
+		return
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if e, ok := c.entries[key]; ok {
+		c.remove(e)
+	}
+
+	c.entries[key] = c.lru.PushFront(&traceCacheEntry{
+		key:     key,
+		resp:    b,
+		expires: c.now().Add(c.ttl),
+	})
+	c.bytes += len(b)
+
+	for c.bytes > c.maxBytes {
+		c.remove(c.lru.Back())
+	}
+}
+
+func (c *traceCache) remove(e *list.Element) {
+	entry := c.lru.Remove(e).(*traceCacheEntry)
+	delete(c.entries, entry.key)
+	c.bytes -= len(entry.resp)
+}
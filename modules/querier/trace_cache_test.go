@@ -0,0 +1,139 @@
+package querier
+
+import (
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/ring"
+	"github.com/gogo/protobuf/proto"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+	"github.com/grafana/tempo/pkg/util"
+	"github.com/grafana/tempo/pkg/util/test"
+	"github.com/grafana/tempo/tempodb"
+)
+
+func TestTraceCache(t *testing.T) {
+	resps := map[byte]*tempopb.TraceByIDResponse{}
+	resp := func(id byte) *tempopb.TraceByIDResponse {
+		if _, ok := resps[id]; !ok {
+			resps[id] = &tempopb.TraceByIDResponse{Trace: test.MakeTraceWithSpanCount(1, 5, []byte{id})}
+		}
+		return resps[id]
+	}
+	key := func(id byte) traceCacheKey {
+		return newTraceCacheKey("tenant", &tempopb.TraceByIDRequest{TraceID: []byte{id}}, traceTimeRange{})
+	}
+	// room for the first response and one of the others
+	maxBytes := resp(1).Size() + resp(2).Size()
+	if resp(3).Size() > resp(2).Size() {
+		maxBytes = resp(1).Size() + resp(3).Size()
+	}
+
+	now := time.Now()
+	c := newTraceCache(TraceCacheConfig{MaxBytes: maxBytes, TTL: time.Minute})
+	c.now = func() time.Time { return now }
+
+	// the returned response is a copy
+	c.put(key(1), resp(1))
+	actual, ok := c.get(key(1))
+	require.True(t, ok)
+	assert.True(t, proto.Equal(resp(1), actual))
+	actual.Trace = nil
+	actual, ok = c.get(key(1))
+	require.True(t, ok)
+	assert.True(t, proto.Equal(resp(1), actual))
+
+	_, ok = c.get(key(2))
+	assert.False(t, ok)
+	_, ok = c.get(newTraceCacheKey("other", &tempopb.TraceByIDRequest{TraceID: []byte{1}}, traceTimeRange{}))
+	assert.False(t, ok)
+
+	// the least recently used response is evicted once the cache is full
+	c.put(key(2), resp(2))
+	_, ok = c.get(key(1))
+	require.True(t, ok)
+	c.put(key(3), resp(3))
+	_, ok = c.get(key(2))
+	assert.False(t, ok)
+	_, ok = c.get(key(1))
+	assert.True(t, ok)
+	assert.Len(t, c.entries, 2)
+	assert.LessOrEqual(t, c.bytes, c.maxBytes)
+
+	// responses expire
+	now = now.Add(time.Minute + time.Second)
+	_, ok = c.get(key(1))
+	assert.False(t, ok)
+	assert.Len(t, c.entries, 1)
+
+	// responses larger than the cache are skipped
+	c.put(key(4), &tempopb.TraceByIDResponse{Trace: test.MakeTraceWithSpanCount(10, 10, []byte{4})})
+	_, ok = c.get(key(4))
+	assert.False(t, ok)
+
+	assert.Nil(t, newTraceCache(TraceCacheConfig{}))
+}
+
+func TestTraceByIDHandlerCache(t *testing.T) {
+	traceID := []byte{0x01, 0x02}
+	first := test.MakeTrace(1, traceID)
+	second := test.MakeTrace(2, traceID)
+
+	ingester := &mockIngesterClient{}
+	store := &mockStore{trace: first}
+	q := zoneQuerier(Config{QueryTimeout: 10 * time.Second, TraceCache: TraceCacheConfig{MaxBytes: 1 << 20, TTL: time.Minute}}, map[string]*mockIngesterClient{"a": ingester})
+	q.ring = &mockReadRing{replicationSet: ring.ReplicationSet{Instances: []ring.InstanceDesc{{Addr: "a"}}}}
+	q.store = store
+	q.traceCache = newTraceCache(q.cfg.TraceCache)
+
+	request := func(id []byte, params string) *tempopb.Trace {
+		r := httptest.NewRequest(http.MethodGet, "/api/traces/"+hex.EncodeToString(id)+"?"+params, nil)
+		r = mux.SetURLVars(r, map[string]string{util.TraceIDVar: hex.EncodeToString(id)})
+		r = r.WithContext(user.InjectOrgID(r.Context(), "tenant"))
+		r.Header.Set(util.AcceptHeaderKey, util.ProtobufTypeHeaderValue)
+
+		w := httptest.NewRecorder()
+		q.TraceByIDHandler(w, r)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		actual := &tempopb.Trace{}
+		require.NoError(t, proto.Unmarshal(w.Body.Bytes(), actual))
+		return actual
+	}
+
+	// a trace only found in the backend is cached
+	assert.ElementsMatch(t, spanIDs(first), spanIDs(request(traceID, "mode=blocks")))
+	store.trace = second
+	assert.ElementsMatch(t, spanIDs(first), spanIDs(request(traceID, "mode=blocks")))
+
+	// the cache can be skipped, the result is cached again
+	assert.ElementsMatch(t, spanIDs(second), spanIDs(request(traceID, "mode=blocks&cache=false")))
+	assert.ElementsMatch(t, spanIDs(second), spanIDs(request(traceID, "mode=blocks")))
+
+	// the shards of a query are cached separately
+	store.trace = first
+	assert.ElementsMatch(t, spanIDs(first), spanIDs(request(traceID, "mode=blocks&blockStart=00000000-0000-0000-0000-000000000000&blockEnd=7fffffff-ffff-ffff-ffff-ffffffffffff")))
+
+	// a trace found in the ingesters is not cached
+	ingesterTraceID := []byte{0x01, 0x03}
+	ingester.trace = test.MakeTrace(1, ingesterTraceID)
+	assert.ElementsMatch(t, spanIDs(ingester.trace), spanIDs(request(ingesterTraceID, "mode=ingesters")))
+	ingester.trace = test.MakeTrace(2, ingesterTraceID)
+	assert.ElementsMatch(t, spanIDs(ingester.trace), spanIDs(request(ingesterTraceID, "mode=ingesters")))
+
+	// a partial trace is not cached
+	partialTraceID := []byte{0x01, 0x04}
+	store.findMetrics = tempodb.FindMetrics{SkippedBlocks: 1}
+	store.trace = first
+	assert.ElementsMatch(t, spanIDs(first), spanIDs(request(partialTraceID, "mode=blocks")))
+	store.trace = second
+	assert.ElementsMatch(t, spanIDs(second), spanIDs(request(partialTraceID, "mode=blocks")))
+}
@@ -0,0 +1,61 @@
+package querier
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// traceTimeRange is the time range a trace by id query was told the trace was written in. Blocks that don't overlap
+// it are not searched. A zero start or end leaves that side of the range open.
+type traceTimeRange struct {
+	start time.Time
+	end   time.Time
+}
+
+type traceTimeRangeKey struct{}
+
+func withTraceTimeRange(ctx context.Context, tr traceTimeRange) context.Context {
+	return context.WithValue(ctx, traceTimeRangeKey{}, tr)
+}
+
+func traceTimeRangeFromContext(ctx context.Context) traceTimeRange {
+	tr, _ := ctx.Value(traceTimeRangeKey{}).(traceTimeRange)
+	return tr
+}
+
+// olderThan returns true if the range ends before t.
+func (tr traceTimeRange) olderThan(t time.Time) bool {
+	return !tr.end.IsZero() && tr.end.Before(t)
+}
+
+// parseTraceTimeRange returns the optional time range hints of a trace by id query. Both are unix epoch seconds.
+func parseTraceTimeRange(r *http.Request) (traceTimeRange, error) {
+	var tr traceTimeRange
+	var err error
+	if tr.start, err = parseTraceTimeHint(r, TraceStartKey); err != nil {
+		return traceTimeRange{}, err
+	}
+	if tr.end, err = parseTraceTimeHint(r, TraceEndKey); err != nil {
+		return traceTimeRange{}, err
+	}
+	if !tr.start.IsZero() && !tr.end.IsZero() && tr.end.Before(tr.start) {
+		return traceTimeRange{}, fmt.Errorf("invalid %s. it should not be before %s", TraceEndKey, TraceStartKey)
+	}
+	return tr, nil
+}
+
+func parseTraceTimeHint(r *http.Request, key string) (time.Time, error) {
+	s := r.URL.Query().Get(key)
+	if s == "" {
+		return time.Time{}, nil
+	}
+	// negative values and values that don't fit 32 bit unix seconds are out of range
+	v, err := strconv.ParseUint(s, 10, 32)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s. it should be unix epoch seconds", key)
+	}
+	return time.Unix(int64(v), 0), nil
+}
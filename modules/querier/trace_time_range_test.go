@@ -0,0 +1,98 @@
+package querier
+
+import (
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/ring"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/tempo/pkg/util"
+	"github.com/grafana/tempo/pkg/util/test"
+)
+
+func TestParseTraceTimeRange(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		expected traceTimeRange
+		err      bool
+	}{
+		{name: "none"},
+		{name: "both", query: "start=10&end=20", expected: traceTimeRange{start: time.Unix(10, 0), end: time.Unix(20, 0)}},
+		{name: "start only", query: "start=10", expected: traceTimeRange{start: time.Unix(10, 0)}},
+		{name: "end only", query: "end=20", expected: traceTimeRange{end: time.Unix(20, 0)}},
+		{name: "same second", query: "start=10&end=10", expected: traceTimeRange{start: time.Unix(10, 0), end: time.Unix(10, 0)}},
+		{name: "inverted", query: "start=20&end=10", err: true},
+		{name: "negative", query: "start=-10", err: true},
+		{name: "too large", query: "end=4294967296", err: true},
+		{name: "not a number", query: "start=yesterday", err: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/traces/0102?"+tc.query, nil)
+			actual, err := parseTraceTimeRange(r)
+			if tc.err {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestTraceByIDHandlerTimeRange(t *testing.T) {
+	traceID := []byte{0x01, 0x02}
+
+	request := func(query string) (*httptest.ResponseRecorder, *mockIngesterClient, *mockStore) {
+		ingester := &mockIngesterClient{}
+		store := &mockStore{trace: test.MakeTrace(1, traceID)}
+		q := zoneQuerier(Config{QueryTimeout: 10 * time.Second, Search: SearchConfig{QueryIngestersUntil: time.Hour}}, map[string]*mockIngesterClient{"a": ingester})
+		q.ring = &mockReadRing{replicationSet: ring.ReplicationSet{Instances: []ring.InstanceDesc{{Addr: "a"}}}}
+		q.store = store
+
+		r := httptest.NewRequest(http.MethodGet, "/api/traces/"+hex.EncodeToString(traceID)+"?"+query, nil)
+		r = mux.SetURLVars(r, map[string]string{util.TraceIDVar: hex.EncodeToString(traceID)})
+		r = r.WithContext(user.InjectOrgID(r.Context(), util.FakeTenantID))
+
+		w := httptest.NewRecorder()
+		q.TraceByIDHandler(w, r)
+		return w, ingester, store
+	}
+	unix := func(t time.Time) string {
+		return strconv.FormatInt(t.Unix(), 10)
+	}
+
+	// the hints are passed to the store and recent ones still query the ingesters
+	start := time.Now().Add(-10 * time.Minute).Truncate(time.Second)
+	end := time.Now().Truncate(time.Second)
+	w, ingester, store := request("start=" + unix(start) + "&end=" + unix(end))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 1, ingester.calls)
+	assert.True(t, start.Equal(store.findStart))
+	assert.True(t, end.Equal(store.findEnd))
+
+	// a range older than the ingesters keep traces only queries the store
+	w, ingester, _ = request("start=" + unix(start.Add(-3*time.Hour)) + "&end=" + unix(end.Add(-2*time.Hour)))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 0, ingester.calls)
+
+	// without an end the range may be recent
+	w, ingester, store = request("start=" + unix(start.Add(-3*time.Hour)))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 1, ingester.calls)
+	assert.True(t, store.findEnd.IsZero())
+
+	// inverted ranges are rejected
+	w, _, _ = request("start=" + unix(end) + "&end=" + unix(start))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,146 @@
+package querier
+
+import (
+	"context"
+
+	"github.com/cortexproject/cortex/pkg/ring"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+)
+
+const (
+	opFindTraceByID = "find_trace_by_id"
+	opSearch        = "search"
+
+	fallbackReasonNoLocal  = "no_local_ingesters"
+	fallbackReasonError    = "error"
+	fallbackReasonTimeout  = "timeout"
+	fallbackReasonNotFound = "not_found"
+)
+
+var (
+	metricCrossZoneQueriesAvoided = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "querier_cross_zone_queries_avoided_total",
+		Help:      "Total number of ingester queries answered by ingesters in the querier's zone.",
+	}, []string{"op"})
+	metricLocalZoneFallbacks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "querier_local_zone_fallbacks_total",
+		Help:      "Total number of ingester queries that fell back to ingesters in other zones.",
+	}, []string{"op", "reason"})
+)
+
+// forIngestersPreferLocalZone runs f against the ingesters in the querier's zone. Ingesters in other zones are only
+// queried if there are no local ingesters, a local ingester fails or times out, or found reports that the local
+// responses are empty. It is equivalent to forGivenIngesters if prefer_local_zone is disabled.
+func (q *Querier) forIngestersPreferLocalZone(ctx context.Context, op string, replicationSet ring.ReplicationSet, found func([]responseFromIngesters) bool, f func(context.Context, tempopb.QuerierClient) (interface{}, error)) ([]responseFromIngesters, error) {
+	if !q.cfg.PreferLocalZone || q.cfg.Zone == "" {
+		return q.forGivenIngesters(ctx, replicationSet, f)
+	}
+
+	local, remote := splitByZone(replicationSet, q.cfg.Zone)
+	if len(remote.Instances) == 0 {
+		return q.forGivenIngesters(ctx, replicationSet, f)
+	}
+	if len(local.Instances) == 0 {
+		metricLocalZoneFallbacks.WithLabelValues(op, fallbackReasonNoLocal).Inc()
+		return q.forGivenIngesters(ctx, replicationSet, f)
+	}
+
+	localCtx := ctx
+	if q.cfg.LocalZoneTimeout > 0 {
+		var cancel context.CancelFunc
+		localCtx, cancel = context.WithTimeout(ctx, q.cfg.LocalZoneTimeout)
+		defer cancel()
+	}
+
+	responses, err := q.forGivenIngesters(localCtx, local, f)
+
+	var reason string
+	switch {
+	case err != nil && ctx.Err() != nil:
+		return nil, err
+	case err != nil && localCtx.Err() == context.DeadlineExceeded:
+		reason = fallbackReasonTimeout
+	case err != nil:
+		reason = fallbackReasonError
+	case !found(responses):
+		reason = fallbackReasonNotFound
+	default:
+		metricCrossZoneQueriesAvoided.WithLabelValues(op).Inc()
+		return responses, nil
+	}
+	metricLocalZoneFallbacks.WithLabelValues(op, reason).Inc()
+
+	// a failing local zone counts against the tolerated failures
+	if err != nil {
+		remote = reduceTolerance(remote)
+	}
+
+	remoteResponses, remoteErr := q.forGivenIngesters(ctx, remote, f)
+	if remoteErr != nil {
+		return nil, remoteErr
+	}
+
+	return append(responses, remoteResponses...), nil
+}
+
+// splitByZone splits the replication set into the instances in zone and all others. The tolerated failures of the
+// original set are carried over to the remote set, capped so that at least one remote instance or zone must succeed.
+// All local instances must succeed.
+func splitByZone(replicationSet ring.ReplicationSet, zone string) (local ring.ReplicationSet, remote ring.ReplicationSet) {
+	remoteZones := map[string]struct{}{}
+	for _, instance := range replicationSet.Instances {
+		if instance.Zone == zone {
+			local.Instances = append(local.Instances, instance)
+		} else {
+			remote.Instances = append(remote.Instances, instance)
+			remoteZones[instance.Zone] = struct{}{}
+		}
+	}
+
+	if len(remote.Instances) > 0 {
+		remote.MaxErrors = minInt(replicationSet.MaxErrors, len(remote.Instances)-1)
+		remote.MaxUnavailableZones = minInt(replicationSet.MaxUnavailableZones, len(remoteZones)-1)
+	}
+
+	return local, remote
+}
+
+func reduceTolerance(replicationSet ring.ReplicationSet) ring.ReplicationSet {
+	if replicationSet.MaxUnavailableZones > 0 {
+		replicationSet.MaxUnavailableZones--
+	}
+	if replicationSet.MaxErrors > 0 {
+		replicationSet.MaxErrors--
+	}
+	return replicationSet
+}
+
+func traceFound(responses []responseFromIngesters) bool {
+	for _, r := range responses {
+		if r.response.(*tempopb.TraceByIDResponse).Trace != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func searchFound(responses []responseFromIngesters) bool {
+	for _, r := range responses {
+		if len(r.response.(*tempopb.SearchResponse).Traces) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
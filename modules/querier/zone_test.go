@@ -0,0 +1,258 @@
+package querier
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/ring"
+	ring_client "github.com/cortexproject/cortex/pkg/ring/client"
+	"github.com/go-kit/kit/log"
+	"github.com/gogo/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/grafana/tempo/modules/overrides"
+	"github.com/grafana/tempo/pkg/tempopb"
+	"github.com/grafana/tempo/pkg/util/test"
+)
+
+type mockIngesterClient struct {
+	grpc_health_v1.HealthClient
+
+	trace        *tempopb.Trace
+	tagValues    []string
+	searchTraces []*tempopb.TraceSearchMetadata
+	spansRemoved uint64
+	err          error
+	delay        time.Duration
+
+	mtx   sync.Mutex
+	calls int
+}
+
+func (m *mockIngesterClient) FindTraceByID(ctx context.Context, _ *tempopb.TraceByIDRequest, _ ...grpc.CallOption) (*tempopb.TraceByIDResponse, error) {
+	m.mtx.Lock()
+	m.calls++
+	m.mtx.Unlock()
+
+	if m.delay > 0 {
+		select {
+		case <-time.After(m.delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if m.err != nil {
+		return nil, m.err
+	}
+	// like a gRPC client every call returns a new trace, the querier combines traces in place
+	var trace *tempopb.Trace
+	if m.trace != nil {
+		trace = proto.Clone(m.trace).(*tempopb.Trace)
+	}
+	return &tempopb.TraceByIDResponse{Trace: trace}, nil
+}
+
+func (m *mockIngesterClient) Search(context.Context, *tempopb.SearchRequest, ...grpc.CallOption) (*tempopb.SearchResponse, error) {
+	return &tempopb.SearchResponse{Traces: m.searchTraces}, nil
+}
+
+func (m *mockIngesterClient) SearchTags(context.Context, *tempopb.SearchTagsRequest, ...grpc.CallOption) (*tempopb.SearchTagsResponse, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (m *mockIngesterClient) SearchTagValues(context.Context, *tempopb.SearchTagValuesRequest, ...grpc.CallOption) (*tempopb.SearchTagValuesResponse, error) {
+	return &tempopb.SearchTagValuesResponse{TagValues: m.tagValues}, nil
+}
+
+func (m *mockIngesterClient) LiveTraceStats(context.Context, *tempopb.LiveTraceStatsRequest, ...grpc.CallOption) (*tempopb.LiveTraceStatsResponse, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (m *mockIngesterClient) DeleteTrace(context.Context, *tempopb.DeleteTraceRequest, ...grpc.CallOption) (*tempopb.DeleteTraceResponse, error) {
+	m.mtx.Lock()
+	m.calls++
+	m.mtx.Unlock()
+
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &tempopb.DeleteTraceResponse{SpansRemoved: m.spansRemoved}, nil
+}
+
+func (m *mockIngesterClient) Close() error {
+	return nil
+}
+
+func (m *mockIngesterClient) callCount() int {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	return m.calls
+}
+
+func zoneQuerier(cfg Config, clients map[string]*mockIngesterClient) *Querier {
+	factory := func(addr string) (ring_client.PoolClient, error) {
+		return clients[addr], nil
+	}
+
+	limits, err := overrides.NewOverrides(overrides.Limits{})
+	if err != nil {
+		panic(err)
+	}
+
+	return &Querier{
+		cfg:    cfg,
+		pool:   ring_client.NewPool("test", ring_client.PoolConfig{}, nil, factory, nil, log.NewNopLogger()),
+		limits: limits,
+
+		queryRateLimiter: newQueryRateLimiter(limits),
+	}
+}
+
+func TestForIngestersPreferLocalZone(t *testing.T) {
+	trace := test.MakeTrace(1, []byte{0x01})
+	replicationSet := ring.ReplicationSet{
+		Instances: []ring.InstanceDesc{
+			{Addr: "a", Zone: "zone-a"},
+			{Addr: "b", Zone: "zone-b"},
+			{Addr: "c", Zone: "zone-c"},
+		},
+		MaxUnavailableZones: 1,
+	}
+
+	tests := []struct {
+		name           string
+		cfg            Config
+		local          *mockIngesterClient
+		expectedRemote int
+		expectedFound  bool
+	}{
+		{
+			name:           "disabled",
+			cfg:            Config{Zone: "zone-a"},
+			local:          &mockIngesterClient{trace: trace},
+			expectedRemote: 1,
+			expectedFound:  true,
+		},
+		{
+			name:          "local found",
+			cfg:           Config{Zone: "zone-a", PreferLocalZone: true},
+			local:         &mockIngesterClient{trace: trace},
+			expectedFound: true,
+		},
+		{
+			name:           "local missing data",
+			cfg:            Config{Zone: "zone-a", PreferLocalZone: true},
+			local:          &mockIngesterClient{},
+			expectedRemote: 1,
+			expectedFound:  true,
+		},
+		{
+			name:           "local error",
+			cfg:            Config{Zone: "zone-a", PreferLocalZone: true},
+			local:          &mockIngesterClient{err: errors.New("unavailable")},
+			expectedRemote: 1,
+			expectedFound:  true,
+		},
+		{
+			name:           "local timeout",
+			cfg:            Config{Zone: "zone-a", PreferLocalZone: true, LocalZoneTimeout: 10 * time.Millisecond},
+			local:          &mockIngesterClient{trace: trace, delay: time.Minute},
+			expectedRemote: 1,
+			expectedFound:  true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			clients := map[string]*mockIngesterClient{
+				"a": tc.local,
+				"b": {trace: trace},
+				"c": {trace: trace},
+			}
+			q := zoneQuerier(tc.cfg, clients)
+
+			responses, err := q.forIngestersPreferLocalZone(context.Background(), opFindTraceByID, replicationSet, traceFound, func(ctx context.Context, client tempopb.QuerierClient) (interface{}, error) {
+				return client.FindTraceByID(ctx, &tempopb.TraceByIDRequest{})
+			})
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedFound, traceFound(responses))
+
+			// with one unavailable zone tolerated at least one of the remote zones is queried
+			remoteCalls := clients["b"].callCount() + clients["c"].callCount()
+			if tc.expectedRemote == 0 {
+				assert.Equal(t, 0, remoteCalls)
+			} else {
+				assert.GreaterOrEqual(t, remoteCalls, tc.expectedRemote)
+			}
+		})
+	}
+}
+
+func TestForIngestersPreferLocalZoneRemoteMissingData(t *testing.T) {
+	trace := test.MakeTrace(1, []byte{0x01})
+	replicationSet := ring.ReplicationSet{
+		Instances: []ring.InstanceDesc{
+			{Addr: "a", Zone: "zone-a"},
+			{Addr: "b", Zone: "zone-b"},
+		},
+	}
+
+	// the local replica is missing the trace and the only remote replica has it
+	clients := map[string]*mockIngesterClient{
+		"a": {},
+		"b": {trace: trace},
+	}
+	q := zoneQuerier(Config{Zone: "zone-a", PreferLocalZone: true}, clients)
+
+	responses, err := q.forIngestersPreferLocalZone(context.Background(), opFindTraceByID, replicationSet, traceFound, func(ctx context.Context, client tempopb.QuerierClient) (interface{}, error) {
+		return client.FindTraceByID(ctx, &tempopb.TraceByIDRequest{})
+	})
+	require.NoError(t, err)
+	require.Len(t, responses, 2)
+	assert.True(t, traceFound(responses))
+	assert.Equal(t, 1, clients["b"].callCount())
+
+	// a failing remote replica after a failing local replica exceeds the tolerated failures
+	clients = map[string]*mockIngesterClient{
+		"a": {err: errors.New("unavailable")},
+		"b": {err: errors.New("unavailable")},
+	}
+	q = zoneQuerier(Config{Zone: "zone-a", PreferLocalZone: true}, clients)
+	_, err = q.forIngestersPreferLocalZone(context.Background(), opFindTraceByID, replicationSet, traceFound, func(ctx context.Context, client tempopb.QuerierClient) (interface{}, error) {
+		return client.FindTraceByID(ctx, &tempopb.TraceByIDRequest{})
+	})
+	assert.Error(t, err)
+}
+
+func TestSplitByZone(t *testing.T) {
+	local, remote := splitByZone(ring.ReplicationSet{
+		Instances: []ring.InstanceDesc{
+			{Addr: "a1", Zone: "zone-a"},
+			{Addr: "a2", Zone: "zone-a"},
+			{Addr: "b1", Zone: "zone-b"},
+			{Addr: "c1", Zone: "zone-c"},
+		},
+		MaxUnavailableZones: 1,
+	}, "zone-a")
+
+	assert.Equal(t, []string{"a1", "a2"}, local.GetAddresses())
+	assert.Equal(t, 0, local.MaxErrors)
+	assert.Equal(t, 0, local.MaxUnavailableZones)
+	assert.Equal(t, []string{"b1", "c1"}, remote.GetAddresses())
+	assert.Equal(t, 1, remote.MaxUnavailableZones)
+
+	_, remote = splitByZone(ring.ReplicationSet{
+		Instances: []ring.InstanceDesc{
+			{Addr: "a1", Zone: "zone-a"},
+			{Addr: "b1", Zone: "zone-b"},
+		},
+		MaxErrors: 1,
+	}, "zone-a")
+	assert.Equal(t, 0, remote.MaxErrors)
+}
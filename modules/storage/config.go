@@ -2,6 +2,7 @@ package storage
 
 import (
 	"flag"
+	"time"
 
 	cortex_cache "github.com/cortexproject/cortex/pkg/chunk/cache"
 
@@ -41,6 +42,8 @@ func (cfg *Config) RegisterFlagsAndApplyDefaults(prefix string, f *flag.FlagSet)
 	f.IntVar(&cfg.Trace.Block.BloomShardSizeBytes, util.PrefixConfig(prefix, "trace.block.bloom-filter-shard-size-bytes"), 100*1024, "Bloom Filter Shard Size in bytes.")
 	f.IntVar(&cfg.Trace.Block.IndexDownsampleBytes, util.PrefixConfig(prefix, "trace.block.index-downsample-bytes"), 1024*1024, "Number of bytes (before compression) per index record.")
 	f.IntVar(&cfg.Trace.Block.IndexPageSizeBytes, util.PrefixConfig(prefix, "trace.block.index-page-size-bytes"), 250*1024, "Number of bytes per index page.")
+	f.IntVar(&cfg.Trace.Block.IndexMinObjects, util.PrefixConfig(prefix, "trace.block.index-min-objects"), 0, "Blocks with fewer objects are written without an index and searched by scanning their data. 0 always writes an index.")
+	f.BoolVar(&cfg.Trace.Block.DataPageChecksums, util.PrefixConfig(prefix, "trace.block.data-page-checksums"), false, "Write the checksum of every data page. Blocks written with checksums can't be read by previous releases.")
 	cfg.Trace.Block.Encoding = backend.EncZstd
 
 	cfg.Trace.Azure = &azure.Config{}
@@ -50,19 +53,35 @@ func (cfg *Config) RegisterFlagsAndApplyDefaults(prefix string, f *flag.FlagSet)
 	f.StringVar(&cfg.Trace.Azure.Endpoint, util.PrefixConfig(prefix, "trace.azure.endpoint"), "blob.core.windows.net", "Azure endpoint to push blocks to.")
 	f.IntVar(&cfg.Trace.Azure.MaxBuffers, util.PrefixConfig(prefix, "trace.azure.max-buffers"), 4, "Number of simultaneous uploads.")
 	cfg.Trace.Azure.BufferSize = 3 * 1024 * 1024
+	f.IntVar(&cfg.Trace.Azure.HedgeRequestsUpTo, util.PrefixConfig(prefix, "trace.azure.hedge-requests-up-to"), 2, "Maximum number of requests sent for a hedged request, including the original one.")
+	cfg.Trace.Azure.HedgeRequestsAdaptive.RegisterFlagsAndApplyDefaults(util.PrefixConfig(prefix, "trace.azure.hedge-requests-adaptive"), f)
+	cfg.Trace.Azure.Retry.RegisterFlagsAndApplyDefaults(util.PrefixConfig(prefix, "trace.azure.retry"), f)
+	cfg.Trace.Azure.Transport.RegisterFlagsAndApplyDefaults(util.PrefixConfig(prefix, "trace.azure.transport"), f)
 
 	cfg.Trace.S3 = &s3.Config{}
 	f.StringVar(&cfg.Trace.S3.Bucket, util.PrefixConfig(prefix, "trace.s3.bucket"), "", "s3 bucket to store blocks in.")
 	f.StringVar(&cfg.Trace.S3.Endpoint, util.PrefixConfig(prefix, "trace.s3.endpoint"), "", "s3 endpoint to push blocks to.")
 	f.StringVar(&cfg.Trace.S3.AccessKey.Value, util.PrefixConfig(prefix, "trace.s3.access_key"), "", "s3 access key.")
 	f.StringVar(&cfg.Trace.S3.SecretKey.Value, util.PrefixConfig(prefix, "trace.s3.secret_key"), "", "s3 secret key.")
+	cfg.Trace.S3.HedgeRequestsAdaptive.RegisterFlagsAndApplyDefaults(util.PrefixConfig(prefix, "trace.s3.hedge-requests-adaptive"), f)
+	cfg.Trace.S3.Transport.RegisterFlagsAndApplyDefaults(util.PrefixConfig(prefix, "trace.s3.transport"), f)
 
 	cfg.Trace.GCS = &gcs.Config{}
 	f.StringVar(&cfg.Trace.GCS.BucketName, util.PrefixConfig(prefix, "trace.gcs.bucket"), "", "gcs bucket to store traces in.")
 	cfg.Trace.GCS.ChunkBufferSize = 10 * 1024 * 1024
+	cfg.Trace.GCS.HedgeRequestsAdaptive.RegisterFlagsAndApplyDefaults(util.PrefixConfig(prefix, "trace.gcs.hedge-requests-adaptive"), f)
+	cfg.Trace.GCS.Transport.RegisterFlagsAndApplyDefaults(util.PrefixConfig(prefix, "trace.gcs.transport"), f)
 
 	cfg.Trace.Local = &local.Config{}
 	f.StringVar(&cfg.Trace.Local.Path, util.PrefixConfig(prefix, "trace.local.path"), "", "path to store traces at.")
+	f.Float64Var(&cfg.Trace.Local.MaxDiskUtilization, util.PrefixConfig(prefix, "trace.local.max-disk-utilization"), 0, "Writes fail while the used fraction of the volume of the path exceeds this. 0 disables the limit.")
+
+	cfg.Trace.BackendRateLimit.RegisterFlagsAndApplyDefaults(util.PrefixConfig(prefix, "trace.backend-rate-limit"), f)
+	f.IntVar(&cfg.Trace.CoalesceReadsMaxBytes, util.PrefixConfig(prefix, "trace.coalesce-reads-max-bytes"), 1024*1024, "Identical concurrent reads of backend objects and ranges up to this size share a single request. 0 disables coalescing.")
+	f.Float64Var(&cfg.Trace.FindDeadlineReserve, util.PrefixConfig(prefix, "trace.find-deadline-reserve"), 0.1, "Fraction of the time left until the deadline of a trace by id query below which no more blocks are searched. 0 disables early termination.")
+	f.IntVar(&cfg.Trace.QueryBytesReadMaxTenants, util.PrefixConfig(prefix, "trace.query-bytes-read-max-tenants"), 100, "Number of tenants with their own series of the bytes read by queries. The bytes read for further tenants are counted as tenant \"other\". 0 disables the limit.")
+	f.BoolVar(&cfg.Trace.Skeleton.Enabled, util.PrefixConfig(prefix, "trace.skeleton.enabled"), false, "Write a skeleton of every trace, served by trace by id queries after the trace was deleted by retention.")
+	f.DurationVar(&cfg.Trace.Skeleton.Retention, util.PrefixConfig(prefix, "trace.skeleton.retention"), 30*24*time.Hour, "Duration to keep trace skeletons.")
 
 	cfg.Trace.BackgroundCache = &cortex_cache.BackgroundConfig{}
 	cfg.Trace.BackgroundCache.WriteBackBuffer = 10000
@@ -0,0 +1,28 @@
+package storage
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+const urlParamTenant = "tenant"
+
+// FlushBlocklistHandler polls the blocklist of the tenant passed in the tenant query param, or of all tenants, without
+// waiting for the next poll cycle. It returns the number of blocks added and removed once the poll is complete, so
+// blocks changed by hand are served right away.
+func FlushBlocklistHandler(s Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		changes, err := s.PollBlocklistNow(r.Context(), r.URL.Query().Get(urlParamTenant))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(w).Encode(changes)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/grafana/tempo/tempodb/backend"
+)
+
+const maxBatchSize = 100
+
+var metricWriteFailures = promauto.NewCounter(prometheus.CounterOpts{
+	Namespace: "tempo",
+	Name:      "audit_write_failures_total",
+	Help:      "The total number of audit entries that could not be written.",
+})
+
+// Entry is a single admin action recorded in the audit log.
+type Entry struct {
+	Time   time.Time `json:"time"`
+	Actor  string    `json:"actor"`
+	Action string    `json:"action"`
+	Target string    `json:"target"`
+	// Source is the process that performed the action, e.g. the instance id or tempo-cli.
+	Source string `json:"source,omitempty"`
+}
+
+// Logger asynchronously appends entries to the audit log. Entries are queued in a bounded queue and
+// dropped if the queue is full so that auditing never blocks the audited action. A nil *Logger is valid
+// and discards all entries.
+type Logger struct {
+	store  Store
+	source string
+	queue  chan *Entry
+	logger log.Logger
+
+	// closedMtx guards closing the queue. Admin handlers keep logging while the server shuts down, so Log
+	// checks closed instead of sending on a closed queue.
+	closedMtx sync.RWMutex
+	closed    bool
+	done      chan struct{}
+}
+
+// New creates an audit logger. It returns nil if auditing is disabled. r and w are only used if the
+// store is "backend". source identifies this process in the recorded entries.
+func New(cfg *Config, r backend.RawReader, w backend.RawWriter, source string, logger log.Logger) (*Logger, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	var store Store
+	switch cfg.Store {
+	case StoreLocal:
+		s, err := newLocalStore(cfg.LocalPath)
+		if err != nil {
+			return nil, err
+		}
+		store = s
+	case StoreBackend:
+		store = newBackendStore(r, w, source)
+	default:
+		return nil, fmt.Errorf("unknown audit store %s", cfg.Store)
+	}
+
+	return newLogger(cfg, store, source, logger), nil
+}
+
+func newLogger(cfg *Config, store Store, source string, logger log.Logger) *Logger {
+	queueSize := cfg.QueueSize
+	if queueSize <= 0 {
+		queueSize = DefaultQueueSize
+	}
+
+	l := &Logger{
+		store:  store,
+		source: source,
+		queue:  make(chan *Entry, queueSize),
+		logger: logger,
+		done:   make(chan struct{}),
+	}
+
+	go l.loop()
+
+	return l
+}
+
+// Log queues the entry to be written. It never blocks. Time and Source are set if empty.
+func (l *Logger) Log(e *Entry) {
+	if l == nil {
+		return
+	}
+
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	if e.Source == "" {
+		e.Source = l.source
+	}
+
+	l.closedMtx.RLock()
+	defer l.closedMtx.RUnlock()
+
+	if l.closed {
+		level.Warn(l.logger).Log("msg", "audit log is shut down. dropping entry", "action", e.Action, "target", e.Target, "actor", e.Actor)
+		metricWriteFailures.Inc()
+		return
+	}
+
+	select {
+	case l.queue <- e:
+	default:
+		level.Warn(l.logger).Log("msg", "audit queue full. dropping entry", "action", e.Action, "target", e.Target, "actor", e.Actor)
+		metricWriteFailures.Inc()
+	}
+}
+
+// Read returns all entries in [from, to] sorted by time.
+func (l *Logger) Read(ctx context.Context, from, to time.Time) ([]*Entry, error) {
+	if l == nil {
+		return nil, nil
+	}
+
+	entries, err := l.store.Read(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Time.Before(entries[j].Time)
+	})
+
+	return entries, nil
+}
+
+// Shutdown writes all queued entries and stops the logger. Entries logged afterwards are dropped.
+func (l *Logger) Shutdown() {
+	if l == nil {
+		return
+	}
+
+	l.closedMtx.Lock()
+	if !l.closed {
+		l.closed = true
+		close(l.queue)
+	}
+	l.closedMtx.Unlock()
+
+	<-l.done
+}
+
+func (l *Logger) loop() {
+	defer close(l.done)
+
+	for e := range l.queue {
+		batch := []*Entry{e}
+
+		// drain whatever else is queued to reduce writes
+	drain:
+		for len(batch) < maxBatchSize {
+			select {
+			case e, ok := <-l.queue:
+				if !ok {
+					break drain
+				}
+				batch = append(batch, e)
+			default:
+				break drain
+			}
+		}
+
+		l.write(batch)
+	}
+}
+
+func (l *Logger) write(batch []*Entry) {
+	err := l.store.Append(context.Background(), batch)
+	if err != nil {
+		level.Error(l.logger).Log("msg", "failed to write audit entries", "entries", len(batch), "err", err)
+		metricWriteFailures.Add(float64(len(batch)))
+	}
+}
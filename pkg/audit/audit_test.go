@@ -0,0 +1,210 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/tempo/tempodb/backend/local"
+)
+
+func TestLoggerStores(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("/tmp", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	r, w, _, err := local.New(&local.Config{
+		Path: tmpDir + "/backend",
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		cfg  *Config
+	}{
+		{
+			name: "local",
+			cfg:  &Config{Enabled: true, Store: StoreLocal, LocalPath: tmpDir + "/local"},
+		},
+		{
+			name: "backend",
+			cfg:  &Config{Enabled: true, Store: StoreBackend},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			now := time.Now()
+
+			l, err := New(tc.cfg, r, w, "test", log.NewNopLogger())
+			require.NoError(t, err)
+
+			// reading before anything is written is not an error
+			entries, err := l.Read(ctx, now.Add(-time.Hour), now)
+			require.NoError(t, err)
+			assert.Empty(t, entries)
+
+			l.Log(&Entry{Time: now.Add(-48 * time.Hour), Actor: "a", Action: "old", Target: "t"})
+			l.Log(&Entry{Time: now.Add(-time.Minute), Actor: "b", Action: "second", Target: "t"})
+			l.Log(&Entry{Time: now.Add(-2 * time.Minute), Actor: "c", Action: "first", Target: "t", Source: "tempo-cli"})
+			l.Shutdown()
+
+			// a new logger sees the entries written by the previous one
+			l, err = New(tc.cfg, r, w, "test", log.NewNopLogger())
+			require.NoError(t, err)
+			defer l.Shutdown()
+
+			entries, err = l.Read(ctx, now.Add(-time.Hour), now)
+			require.NoError(t, err)
+			require.Len(t, entries, 2)
+			assert.Equal(t, "first", entries[0].Action)
+			assert.Equal(t, "tempo-cli", entries[0].Source)
+			assert.Equal(t, "second", entries[1].Action)
+			assert.Equal(t, "test", entries[1].Source)
+
+			entries, err = l.Read(ctx, now.Add(-72*time.Hour), now)
+			require.NoError(t, err)
+			require.Len(t, entries, 3)
+			assert.Equal(t, "old", entries[0].Action)
+		})
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	l, err := New(&Config{}, nil, nil, "test", log.NewNopLogger())
+	require.NoError(t, err)
+	require.Nil(t, l)
+
+	// all methods are safe on a nil logger
+	l.Log(&Entry{Action: "foo"})
+	entries, err := l.Read(context.Background(), time.Now(), time.Now())
+	assert.NoError(t, err)
+	assert.Nil(t, entries)
+	l.Shutdown()
+
+	called := false
+	h := l.Wrap("foo", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { called = true }))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", nil))
+	assert.True(t, called)
+
+	rec := httptest.NewRecorder()
+	l.Handler(rec, httptest.NewRequest(http.MethodGet, "/audit", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestLogAfterShutdown(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("/tmp", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	l, err := New(&Config{Enabled: true, Store: StoreLocal, LocalPath: tmpDir}, nil, nil, "test", log.NewNopLogger())
+	require.NoError(t, err)
+
+	// admin handlers keep logging while the logger shuts down
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				l.Log(&Entry{Action: "flush"})
+			}
+		}()
+	}
+	l.Shutdown()
+	wg.Wait()
+
+	assert.NotPanics(t, func() {
+		l.Log(&Entry{Action: "flush"})
+		l.Shutdown()
+	})
+}
+
+func TestHandler(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("/tmp", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	l, err := New(&Config{Enabled: true, Store: StoreLocal, LocalPath: tmpDir}, nil, nil, "test", log.NewNopLogger())
+	require.NoError(t, err)
+
+	noop := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	// GET is not recorded by WrapMutating
+	req := httptest.NewRequest(http.MethodGet, "/ingester/ring", nil)
+	l.WrapMutating("ring.forget", noop).ServeHTTP(httptest.NewRecorder(), req)
+
+	req = httptest.NewRequest(http.MethodPost, "/ingester/ring", bytes.NewBufferString("forget=ingester-1"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("X-Scope-OrgID", "admin")
+	l.WrapMutating("ring.forget", noop).ServeHTTP(httptest.NewRecorder(), req)
+
+	l.Wrap("shutdown", noop).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/shutdown", nil))
+
+	// the actor, source and time of the posted entry are ignored
+	b, err := json.Marshal(&Entry{Time: time.Unix(1, 0), Actor: "user", Action: "cli.gen.index", Target: "1/2", Source: "tempo-cli"})
+	require.NoError(t, err)
+	req = httptest.NewRequest(http.MethodPost, "/audit", bytes.NewReader(b))
+	req.Header.Set(user.OrgIDHeaderName, "cli-tenant")
+	rec := httptest.NewRecorder()
+	l.Handler(rec, req)
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+
+	rec = httptest.NewRecorder()
+	l.Handler(rec, httptest.NewRequest(http.MethodPost, "/audit", bytes.NewBufferString("{}")))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	// flush queued entries
+	l.Shutdown()
+	l, err = New(&Config{Enabled: true, Store: StoreLocal, LocalPath: tmpDir}, nil, nil, "test", log.NewNopLogger())
+	require.NoError(t, err)
+	defer l.Shutdown()
+
+	rec = httptest.NewRecorder()
+	l.Handler(rec, httptest.NewRequest(http.MethodGet, "/audit", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var entries []*Entry
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &entries))
+	require.Len(t, entries, 3)
+
+	assert.Equal(t, "admin", entries[0].Actor)
+	assert.Equal(t, "ring.forget", entries[0].Action)
+	assert.Equal(t, "/ingester/ring forget=ingester-1", entries[0].Target)
+	assert.Equal(t, "test", entries[0].Source)
+
+	assert.Equal(t, "shutdown", entries[1].Action)
+	assert.Equal(t, "/shutdown", entries[1].Target)
+
+	assert.Equal(t, "cli.gen.index", entries[2].Action)
+	assert.Equal(t, "cli-tenant", entries[2].Actor)
+	assert.Equal(t, req.RemoteAddr, entries[2].Source)
+	assert.WithinDuration(t, time.Now(), entries[2].Time, time.Minute)
+
+	// time range
+	rec = httptest.NewRecorder()
+	l.Handler(rec, httptest.NewRequest(http.MethodGet, "/audit?from=0&to=1", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "[]\n", rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	l.Handler(rec, httptest.NewRequest(http.MethodGet, "/audit?from=2&to=1", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = httptest.NewRecorder()
+	l.Handler(rec, httptest.NewRequest(http.MethodGet, "/audit?from=yesterday", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
@@ -0,0 +1,30 @@
+package audit
+
+import (
+	"flag"
+)
+
+const (
+	StoreLocal   = "local"
+	StoreBackend = "backend"
+
+	DefaultQueueSize = 1000
+)
+
+// Config for the audit log.
+type Config struct {
+	Enabled bool `yaml:"enabled"`
+	// Store is either "local", which appends to a file per day in LocalPath, or "backend", which writes an
+	// object per day and writer under the audit/ keypath of the trace storage backend.
+	Store     string `yaml:"store"`
+	LocalPath string `yaml:"local_path"`
+	QueueSize int    `yaml:"queue_size"`
+}
+
+// RegisterFlagsAndApplyDefaults registers the flags.
+func (cfg *Config) RegisterFlagsAndApplyDefaults(prefix string, f *flag.FlagSet) {
+	f.BoolVar(&cfg.Enabled, prefix+".enabled", false, "Record admin actions to the audit log.")
+	f.StringVar(&cfg.Store, prefix+".store", StoreLocal, "Where to store the audit log. local or backend.")
+	f.StringVar(&cfg.LocalPath, prefix+".local-path", "/var/tempo/audit", "Directory of the audit log if the store is local.")
+	cfg.QueueSize = DefaultQueueSize
+}
@@ -0,0 +1,168 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/weaveworks/common/user"
+)
+
+const (
+	urlParamFrom = "from"
+	urlParamTo   = "to"
+
+	defaultReadWindow = 24 * time.Hour
+)
+
+// Wrap records an entry for every request to the handler before serving it.
+func (l *Logger) Wrap(action string, next http.Handler) http.Handler {
+	if l == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		l.Log(&Entry{
+			Actor:  actor(r),
+			Action: action,
+			Target: target(r),
+		})
+		next.ServeHTTP(w, r)
+	})
+}
+
+// WrapMutating records an entry for every request to the handler that is not a GET or HEAD.
+// It is used for pages that both display state and accept changes, like the ring pages.
+func (l *Logger) WrapMutating(action string, next http.Handler) http.Handler {
+	if l == nil {
+		return next
+	}
+
+	wrapped := l.Wrap(action, next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+		wrapped.ServeHTTP(w, r)
+	})
+}
+
+// Handler serves the audit log. GET returns the entries between the from and to query params,
+// which are unix seconds or RFC3339 and default to the last 24 hours. POST records the action and
+// target of the json entry in the request body, e.g. from tempo-cli. The log contains the actions of all
+// tenants, so it must only be served on an admin route and not behind the tenant auth.
+func (l *Logger) Handler(w http.ResponseWriter, r *http.Request) {
+	if l == nil {
+		http.Error(w, "audit log is disabled", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		l.handleRead(w, r)
+	case http.MethodPost:
+		l.handleWrite(w, r)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (l *Logger) handleRead(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+
+	to, err := parseTime(r.URL.Query().Get(urlParamTo), now)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	from, err := parseTime(r.URL.Query().Get(urlParamFrom), to.Add(-defaultReadWindow))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if from.After(to) {
+		http.Error(w, "from must not be after to", http.StatusBadRequest)
+		return
+	}
+
+	entries, err := l.Read(r.Context(), from, to)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if entries == nil {
+		entries = []*Entry{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(entries)
+}
+
+// handleWrite records the action and target of the entry in the request body. The client can't be trusted with the
+// rest, so the actor is taken from the request like for the other admin pages, the source is its remote address and
+// the time is now.
+func (l *Logger) handleWrite(w http.ResponseWriter, r *http.Request) {
+	e := &Entry{}
+	err := json.NewDecoder(r.Body).Decode(e)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if e.Action == "" {
+		http.Error(w, "action is required", http.StatusBadRequest)
+		return
+	}
+
+	l.Log(&Entry{
+		Time:   time.Now(),
+		Actor:  actor(r),
+		Action: e.Action,
+		Target: e.Target,
+		Source: r.RemoteAddr,
+	})
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// actor identifies who made the request. It prefers the tenant from the auth context and falls
+// back to the remote address.
+func actor(r *http.Request) string {
+	if orgID, err := user.ExtractOrgID(r.Context()); err == nil && orgID != "" {
+		return orgID
+	}
+	if orgID := r.Header.Get(user.OrgIDHeaderName); orgID != "" {
+		return orgID
+	}
+	return r.RemoteAddr
+}
+
+func target(r *http.Request) string {
+	t := r.URL.RequestURI()
+
+	// ring pages submit the instance to forget as a form value
+	if r.Method == http.MethodPost {
+		if forget := r.FormValue("forget"); forget != "" {
+			t += " forget=" + forget
+		}
+	}
+
+	return t
+}
+
+func parseTime(s string, def time.Time) (time.Time, error) {
+	if s == "" {
+		return def, nil
+	}
+
+	if secs, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(secs, 0), nil
+	}
+
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %s. expected unix seconds or RFC3339", s)
+	}
+	return t, nil
+}
@@ -0,0 +1,227 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/grafana/tempo/tempodb/backend"
+)
+
+const (
+	dayFormat = "2006-01-02"
+
+	objectName = "audit.jsonl"
+)
+
+// Store persists audit entries. Append is only called from a single goroutine.
+type Store interface {
+	Append(ctx context.Context, entries []*Entry) error
+	Read(ctx context.Context, from, to time.Time) ([]*Entry, error)
+}
+
+// days returns every utc day in [from, to].
+func days(from, to time.Time) []string {
+	var ds []string
+	for d := from.UTC().Truncate(24 * time.Hour); !d.After(to.UTC()); d = d.Add(24 * time.Hour) {
+		ds = append(ds, d.Format(dayFormat))
+	}
+	return ds
+}
+
+func encode(entries []*Entry) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, e := range entries {
+		if err := enc.Encode(e); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func decode(b []byte, from, to time.Time) ([]*Entry, error) {
+	var entries []*Entry
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		e := &Entry{}
+		if err := json.Unmarshal(scanner.Bytes(), e); err != nil {
+			return nil, err
+		}
+		if e.Time.Before(from) || e.Time.After(to) {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
+
+// localStore appends entries to a jsonl file per day.
+type localStore struct {
+	path string
+}
+
+func newLocalStore(path string) (*localStore, error) {
+	err := os.MkdirAll(path, os.ModePerm)
+	if err != nil {
+		return nil, err
+	}
+
+	return &localStore{path: path}, nil
+}
+
+func (s *localStore) fileName(day string) string {
+	return filepath.Join(s.path, day+".jsonl")
+}
+
+func (s *localStore) Append(_ context.Context, entries []*Entry) error {
+	byDay := map[string][]*Entry{}
+	for _, e := range entries {
+		day := e.Time.UTC().Format(dayFormat)
+		byDay[day] = append(byDay[day], e)
+	}
+
+	for day, es := range byDay {
+		b, err := encode(es)
+		if err != nil {
+			return err
+		}
+
+		f, err := os.OpenFile(s.fileName(day), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+		_, err = f.Write(b)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *localStore) Read(_ context.Context, from, to time.Time) ([]*Entry, error) {
+	var entries []*Entry
+	for _, day := range days(from, to) {
+		b, err := ioutil.ReadFile(s.fileName(day))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		es, err := decode(b, from, to)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, es...)
+	}
+
+	return entries, nil
+}
+
+// backendStore keeps an object per day and writer at audit/<day>/<writer>/audit.jsonl. Object storage does
+// not support appends so each writer rewrites its own object, which avoids races between writers.
+type backendStore struct {
+	r      backend.RawReader
+	w      backend.RawWriter
+	writer string
+
+	mtx sync.Mutex
+}
+
+func newBackendStore(r backend.RawReader, w backend.RawWriter, writer string) *backendStore {
+	return &backendStore{
+		r:      r,
+		w:      w,
+		writer: writer,
+	}
+}
+
+func (s *backendStore) Append(ctx context.Context, entries []*Entry) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	byDay := map[string][]*Entry{}
+	for _, e := range entries {
+		day := e.Time.UTC().Format(dayFormat)
+		byDay[day] = append(byDay[day], e)
+	}
+
+	for day, es := range byDay {
+		keypath := backend.KeyPath{backend.AuditKeyPath, day, s.writer}
+
+		existing, err := s.read(ctx, keypath)
+		if err != nil && err != backend.ErrDoesNotExist {
+			return err
+		}
+
+		b, err := encode(es)
+		if err != nil {
+			return err
+		}
+		b = append(existing, b...)
+
+		err = s.w.Write(ctx, objectName, keypath, bytes.NewReader(b), int64(len(b)), false)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *backendStore) Read(ctx context.Context, from, to time.Time) ([]*Entry, error) {
+	var entries []*Entry
+	for _, day := range days(from, to) {
+		writers, err := s.r.List(ctx, backend.KeyPath{backend.AuditKeyPath, day})
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for _, writer := range writers {
+			b, err := s.read(ctx, backend.KeyPath{backend.AuditKeyPath, day, writer})
+			if err == backend.ErrDoesNotExist {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			es, err := decode(b, from, to)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, es...)
+		}
+	}
+
+	return entries, nil
+}
+
+func (s *backendStore) read(ctx context.Context, keypath backend.KeyPath) ([]byte, error) {
+	r, _, err := s.r.Read(ctx, objectName, keypath, false)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return ioutil.ReadAll(r)
+}
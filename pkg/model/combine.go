@@ -101,6 +101,8 @@ func CombineTraceProtos(traceA, traceB *tempopb.Trace) (*tempopb.Trace, int, int
 		return traceA, -1, 0, -1
 	}
 
+	traceA.IngestionTimeUnixNano = EarliestIngestionTime(traceA.IngestionTimeUnixNano, traceB.IngestionTimeUnixNano)
+
 	spanCountA := 0
 	spanCountB := 0
 	spanCountTotal := 0
@@ -153,6 +155,15 @@ func CombineTraceProtos(traceA, traceB *tempopb.Trace) (*tempopb.Trace, int, int
 	return traceA, spanCountA, spanCountB, spanCountTotal
 }
 
+// EarliestIngestionTime returns the earlier of two ingestion times. Zero is an unknown ingestion time, e.g. of a trace
+// written before ingestion times were recorded, and is only returned if both are unknown.
+func EarliestIngestionTime(a, b uint64) uint64 {
+	if a == 0 || (b != 0 && b < a) {
+		return b
+	}
+	return a
+}
+
 // tokenForID returns a uint32 token for use in a hash map given a span id and span kind
 //  buffer must be a 4 byte slice and is reused for writing the span kind to the hashing function
 //  kind is used along with the actual id b/c in zipkin traces span id is not guaranteed to be unique
@@ -194,6 +194,45 @@ func TestCombineProtos(t *testing.T) {
 	}
 }
 
+func TestCombineIngestionTime(t *testing.T) {
+	tests := []struct {
+		name     string
+		a, b     uint64
+		expected uint64
+	}{
+		{name: "earliest a", a: 10, b: 20, expected: 10},
+		{name: "earliest b", a: 20, b: 10, expected: 10},
+		{name: "unknown a", a: 0, b: 20, expected: 20},
+		{name: "unknown b", a: 10, b: 0, expected: 10},
+		{name: "unknown", a: 0, b: 0, expected: 0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			traceA := test.MakeTrace(2, []byte{0x01, 0x02})
+			traceA.IngestionTimeUnixNano = tc.a
+			traceB := test.MakeTrace(2, []byte{0x01, 0x02})
+			traceB.IngestionTimeUnixNano = tc.b
+
+			for _, encoding := range allEncodings {
+				objA, err := marshal(traceA, encoding)
+				require.NoError(t, err)
+				objB, err := marshal(traceB, encoding)
+				require.NoError(t, err)
+
+				combined, _, err := CombineTraceBytes(objA, objB, encoding, encoding)
+				require.NoError(t, err)
+				actual, err := Unmarshal(combined, encoding)
+				require.NoError(t, err)
+				assert.Equal(t, tc.expected, actual.IngestionTimeUnixNano, encoding)
+			}
+
+			actual, _, _, _ := CombineTraceProtos(traceA, traceB)
+			assert.Equal(t, tc.expected, actual.IngestionTimeUnixNano)
+		})
+	}
+}
+
 func BenchmarkCombineTraces(b *testing.B) {
 	t1 := test.MakeTrace(10, []byte{0x01, 0x02})
 	t2 := test.MakeTrace(10, []byte{0x01, 0x03})
@@ -48,6 +48,7 @@ func Unmarshal(obj []byte, dataEncoding string) (*tempopb.Trace, error) {
 			}
 
 			trace.Batches = append(trace.Batches, innerTrace.Batches...)
+			trace.IngestionTimeUnixNano = EarliestIngestionTime(trace.IngestionTimeUnixNano, innerTrace.IngestionTimeUnixNano)
 		}
 	default:
 		return nil, fmt.Errorf("unrecognized dataEncoding in Unmarshal %s", dataEncoding)
@@ -56,6 +57,21 @@ func Unmarshal(obj []byte, dataEncoding string) (*tempopb.Trace, error) {
 	return trace, nil
 }
 
+// Transcode converts a byte slice of the encoding from into the encoding to. obj is returned as is if the
+// encodings are the same.
+func Transcode(obj []byte, from string, to string) ([]byte, error) {
+	if from == to {
+		return obj, nil
+	}
+
+	trace, err := Unmarshal(obj, from)
+	if err != nil {
+		return nil, err
+	}
+
+	return marshal(trace, to)
+}
+
 // marshal converts a tempopb.Trace into a byte slice encoded using dataEncoding
 // nolint:interfacer
 func marshal(trace *tempopb.Trace, dataEncoding string) ([]byte, error) {
@@ -31,3 +31,45 @@ func TestUnmarshal(t *testing.T) {
 		assert.True(t, proto.Equal(empty, actual))
 	}
 }
+
+func TestUnmarshalIngestionTime(t *testing.T) {
+	// the ingester appends the ingestion time as an inner trace without batches
+	trace := test.MakeTrace(2, nil)
+	traceBytes := &tempopb.TraceBytes{}
+	for _, inner := range []*tempopb.Trace{trace, {IngestionTimeUnixNano: 20}, {IngestionTimeUnixNano: 10}} {
+		b, err := proto.Marshal(inner)
+		require.NoError(t, err)
+		traceBytes.Traces = append(traceBytes.Traces, b)
+	}
+	obj, err := proto.Marshal(traceBytes)
+	require.NoError(t, err)
+
+	actual, err := Unmarshal(obj, CurrentEncoding)
+	require.NoError(t, err)
+	assert.Equal(t, trace.Batches, actual.Batches)
+	assert.Equal(t, uint64(10), actual.IngestionTimeUnixNano)
+}
+
+func TestTranscode(t *testing.T) {
+	trace := test.MakeTrace(100, nil)
+
+	for _, from := range allEncodings {
+		for _, to := range allEncodings {
+			bytes, err := marshal(trace, from)
+			require.NoError(t, err)
+
+			transcoded, err := Transcode(bytes, from, to)
+			require.NoError(t, err)
+			if from == to {
+				assert.Equal(t, bytes, transcoded)
+			}
+
+			actual, err := Unmarshal(transcoded, to)
+			require.NoError(t, err)
+			assert.True(t, proto.Equal(trace, actual))
+		}
+	}
+
+	_, err := Transcode([]byte{0x01}, CurrentEncoding, TracePBEncoding)
+	assert.Error(t, err)
+}
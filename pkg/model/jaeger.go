@@ -0,0 +1,598 @@
+package model
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	jaeger "github.com/jaegertracing/jaeger/model"
+	"github.com/pkg/errors"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+	v1_common "github.com/grafana/tempo/pkg/tempopb/common/v1"
+	v1_resource "github.com/grafana/tempo/pkg/tempopb/resource/v1"
+	v1_trace "github.com/grafana/tempo/pkg/tempopb/trace/v1"
+)
+
+// The tags the OpenTelemetry Jaeger exporter reports the fields of OTLP spans that Jaeger has no field for with.
+const (
+	jaegerTagLibraryName       = "otel.library.name"
+	jaegerTagLibraryVersion    = "otel.library.version"
+	jaegerTagSpanKind          = "span.kind"
+	jaegerTagStatusCode        = "otel.status_code"
+	jaegerTagStatusDescription = "otel.status_description"
+	jaegerTagError             = "error"
+	jaegerTagTraceState        = "w3c.tracestate"
+	jaegerFieldEvent           = "event"
+
+	jaegerUnknownService = "unknown_service"
+)
+
+// JaegerResponse is the response of the trace by id endpoint of the Jaeger query API, which the Jaeger UI reads.
+type JaegerResponse struct {
+	Data   []*JaegerTrace `json:"data"`
+	Total  int            `json:"total"`
+	Limit  int            `json:"limit"`
+	Offset int            `json:"offset"`
+	Errors []interface{}  `json:"errors"`
+}
+
+// JaegerTrace is a trace in the JSON model of the Jaeger UI.
+type JaegerTrace struct {
+	TraceID   string                   `json:"traceID"`
+	Spans     []JaegerSpan             `json:"spans"`
+	Processes map[string]JaegerProcess `json:"processes"`
+	Warnings  []string                 `json:"warnings"`
+}
+
+type JaegerSpan struct {
+	TraceID       string            `json:"traceID"`
+	SpanID        string            `json:"spanID"`
+	OperationName string            `json:"operationName"`
+	References    []JaegerReference `json:"references"`
+	// StartTime is in microseconds since the epoch, Duration in microseconds.
+	StartTime uint64           `json:"startTime"`
+	Duration  uint64           `json:"duration"`
+	Tags      []JaegerKeyValue `json:"tags"`
+	Logs      []JaegerLog      `json:"logs"`
+	ProcessID string           `json:"processID"`
+	// Process is set instead of ProcessID by the Jaeger APIs that don't dedupe the processes of a trace.
+	Process  *JaegerProcess `json:"process,omitempty"`
+	Warnings []string       `json:"warnings"`
+}
+
+type JaegerReference struct {
+	RefType string `json:"refType"`
+	TraceID string `json:"traceID"`
+	SpanID  string `json:"spanID"`
+}
+
+type JaegerProcess struct {
+	ServiceName string           `json:"serviceName"`
+	Tags        []JaegerKeyValue `json:"tags"`
+}
+
+type JaegerLog struct {
+	// Timestamp is in microseconds since the epoch.
+	Timestamp uint64           `json:"timestamp"`
+	Fields    []JaegerKeyValue `json:"fields"`
+}
+
+type JaegerKeyValue struct {
+	Key   string      `json:"key"`
+	Type  string      `json:"type"`
+	Value interface{} `json:"value"`
+}
+
+// MarshalJaegerJSON marshals a trace as the response of the Jaeger query API.
+func MarshalJaegerJSON(t *tempopb.Trace) ([]byte, error) {
+	return json.Marshal(&JaegerResponse{
+		Data: []*JaegerTrace{TraceToJaeger(t)},
+	})
+}
+
+// TraceToJaeger converts a trace to the JSON model of the Jaeger UI. The spans keep their order. Every distinct resource
+// is a process with the service.name of the resource as service name. The parent of a span is a CHILD_OF reference
+// and its links are FOLLOWS_FROM references, its events are logs and the fields of the span Jaeger has no field for
+// are tags named like the OpenTelemetry Jaeger exporter names them. The status is only reported if it is set.
+func TraceToJaeger(t *tempopb.Trace) *JaegerTrace {
+	jt := &JaegerTrace{
+		Spans:     []JaegerSpan{},
+		Processes: map[string]JaegerProcess{},
+	}
+
+	var processIDs []string
+	for _, b := range t.Batches {
+		process := resourceToJaegerProcess(b.Resource)
+		processID := ""
+		for _, id := range processIDs {
+			if jaegerProcessesEqual(jt.Processes[id], process) {
+				processID = id
+				break
+			}
+		}
+		if processID == "" {
+			processID = "p" + strconv.Itoa(len(processIDs)+1)
+			processIDs = append(processIDs, processID)
+			jt.Processes[processID] = process
+		}
+
+		for _, ils := range b.InstrumentationLibrarySpans {
+			for _, s := range ils.Spans {
+				js := spanToJaeger(s, ils.InstrumentationLibrary)
+				js.ProcessID = processID
+				if jt.TraceID == "" {
+					jt.TraceID = js.TraceID
+				}
+				jt.Spans = append(jt.Spans, js)
+			}
+		}
+	}
+
+	return jt
+}
+
+func resourceToJaegerProcess(r *v1_resource.Resource) JaegerProcess {
+	p := JaegerProcess{
+		ServiceName: jaegerUnknownService,
+		Tags:        []JaegerKeyValue{},
+	}
+	if r == nil {
+		return p
+	}
+
+	for _, kv := range r.Attributes {
+		if kv.Key == serviceNameAttribute {
+			if s, ok := kv.Value.GetValue().(*v1_common.AnyValue_StringValue); ok {
+				p.ServiceName = s.StringValue
+				continue
+			}
+		}
+		p.Tags = append(p.Tags, keyValueToJaeger(kv))
+	}
+	return p
+}
+
+func jaegerProcessesEqual(a, b JaegerProcess) bool {
+	if a.ServiceName != b.ServiceName || len(a.Tags) != len(b.Tags) {
+		return false
+	}
+	for i := range a.Tags {
+		if a.Tags[i].Key != b.Tags[i].Key || a.Tags[i].Type != b.Tags[i].Type || fmt.Sprint(a.Tags[i].Value) != fmt.Sprint(b.Tags[i].Value) {
+			return false
+		}
+	}
+	return true
+}
+
+func spanToJaeger(s *v1_trace.Span, library *v1_common.InstrumentationLibrary) JaegerSpan {
+	traceID := jaegerTraceID(s.TraceId)
+	js := JaegerSpan{
+		TraceID:       traceID,
+		SpanID:        jaegerSpanID(s.SpanId),
+		OperationName: s.Name,
+		References:    []JaegerReference{},
+		StartTime:     s.StartTimeUnixNano / 1000,
+		Tags:          []JaegerKeyValue{},
+		Logs:          []JaegerLog{},
+	}
+	if s.EndTimeUnixNano > s.StartTimeUnixNano {
+		js.Duration = (s.EndTimeUnixNano - s.StartTimeUnixNano) / 1000
+	}
+
+	// the parent goes first, the Jaeger UI takes the first CHILD_OF reference as parent
+	if len(s.ParentSpanId) > 0 {
+		js.References = append(js.References, JaegerReference{
+			RefType: jaeger.ChildOf.String(),
+			TraceID: traceID,
+			SpanID:  jaegerSpanID(s.ParentSpanId),
+		})
+	}
+	for _, l := range s.Links {
+		js.References = append(js.References, JaegerReference{
+			RefType: jaeger.FollowsFrom.String(),
+			TraceID: jaegerTraceID(l.TraceId),
+			SpanID:  jaegerSpanID(l.SpanId),
+		})
+	}
+
+	if library != nil && library.Name != "" {
+		js.Tags = append(js.Tags, jaegerString(jaegerTagLibraryName, library.Name))
+		if library.Version != "" {
+			js.Tags = append(js.Tags, jaegerString(jaegerTagLibraryVersion, library.Version))
+		}
+	}
+	for _, kv := range s.Attributes {
+		js.Tags = append(js.Tags, keyValueToJaeger(kv))
+	}
+	if kind := jaegerSpanKind(s.Kind); kind != "" {
+		js.Tags = append(js.Tags, jaegerString(jaegerTagSpanKind, kind))
+	}
+	if s.Status != nil {
+		switch s.Status.Code {
+		case v1_trace.Status_STATUS_CODE_OK:
+			js.Tags = append(js.Tags, jaegerString(jaegerTagStatusCode, "OK"))
+		case v1_trace.Status_STATUS_CODE_ERROR:
+			js.Tags = append(js.Tags,
+				jaegerString(jaegerTagStatusCode, "ERROR"),
+				JaegerKeyValue{Key: jaegerTagError, Type: "bool", Value: true},
+			)
+		}
+		if s.Status.Message != "" {
+			js.Tags = append(js.Tags, jaegerString(jaegerTagStatusDescription, s.Status.Message))
+		}
+	}
+	if s.TraceState != "" {
+		js.Tags = append(js.Tags, jaegerString(jaegerTagTraceState, s.TraceState))
+	}
+
+	for _, e := range s.Events {
+		log := JaegerLog{
+			Timestamp: e.TimeUnixNano / 1000,
+			Fields:    []JaegerKeyValue{},
+		}
+		if e.Name != "" {
+			log.Fields = append(log.Fields, jaegerString(jaegerFieldEvent, e.Name))
+		}
+		for _, kv := range e.Attributes {
+			log.Fields = append(log.Fields, keyValueToJaeger(kv))
+		}
+		js.Logs = append(js.Logs, log)
+	}
+
+	return js
+}
+
+func jaegerSpanKind(kind v1_trace.Span_SpanKind) string {
+	switch kind {
+	case v1_trace.Span_SPAN_KIND_INTERNAL:
+		return "internal"
+	case v1_trace.Span_SPAN_KIND_SERVER:
+		return "server"
+	case v1_trace.Span_SPAN_KIND_CLIENT:
+		return "client"
+	case v1_trace.Span_SPAN_KIND_PRODUCER:
+		return "producer"
+	case v1_trace.Span_SPAN_KIND_CONSUMER:
+		return "consumer"
+	default:
+		return ""
+	}
+}
+
+func jaegerString(key string, value string) JaegerKeyValue {
+	return JaegerKeyValue{Key: key, Type: "string", Value: value}
+}
+
+// keyValueToJaeger converts an attribute to a tag. Arrays and maps, which Jaeger has no type for, are JSON strings.
+func keyValueToJaeger(kv *v1_common.KeyValue) JaegerKeyValue {
+	switch v := kv.Value.GetValue().(type) {
+	case *v1_common.AnyValue_BoolValue:
+		return JaegerKeyValue{Key: kv.Key, Type: "bool", Value: v.BoolValue}
+	case *v1_common.AnyValue_IntValue:
+		return JaegerKeyValue{Key: kv.Key, Type: "int64", Value: v.IntValue}
+	case *v1_common.AnyValue_DoubleValue:
+		return JaegerKeyValue{Key: kv.Key, Type: "float64", Value: v.DoubleValue}
+	case *v1_common.AnyValue_StringValue:
+		return jaegerString(kv.Key, v.StringValue)
+	case nil:
+		return jaegerString(kv.Key, "")
+	default:
+		b, _ := json.Marshal(anyValueToInterface(kv.Value))
+		return jaegerString(kv.Key, string(b))
+	}
+}
+
+func anyValueToInterface(v *v1_common.AnyValue) interface{} {
+	switch v := v.GetValue().(type) {
+	case *v1_common.AnyValue_BoolValue:
+		return v.BoolValue
+	case *v1_common.AnyValue_IntValue:
+		return v.IntValue
+	case *v1_common.AnyValue_DoubleValue:
+		return v.DoubleValue
+	case *v1_common.AnyValue_StringValue:
+		return v.StringValue
+	case *v1_common.AnyValue_ArrayValue:
+		values := make([]interface{}, 0, len(v.ArrayValue.GetValues()))
+		for _, value := range v.ArrayValue.GetValues() {
+			values = append(values, anyValueToInterface(value))
+		}
+		return values
+	case *v1_common.AnyValue_KvlistValue:
+		values := make(map[string]interface{}, len(v.KvlistValue.GetValues()))
+		for _, kv := range v.KvlistValue.GetValues() {
+			values[kv.Key] = anyValueToInterface(kv.Value)
+		}
+		return values
+	default:
+		return nil
+	}
+}
+
+// jaegerTraceID formats an id like Jaeger does. Ids Jaeger can't hold are hex encoded as is.
+func jaegerTraceID(id []byte) string {
+	traceID, err := jaeger.TraceIDFromBytes(id)
+	if err != nil {
+		return hex.EncodeToString(id)
+	}
+	return traceID.String()
+}
+
+func jaegerSpanID(id []byte) string {
+	spanID, err := jaeger.SpanIDFromBytes(id)
+	if err != nil {
+		return hex.EncodeToString(id)
+	}
+	return spanID.String()
+}
+
+// JaegerToTrace converts a trace in the JSON model of the Jaeger UI to OTLP, reversing TraceToJaeger. Every process is a
+// batch and its tags are attributes of the resource. The first CHILD_OF reference to a span of the same trace is the
+// parent, the other references are links. The tags the OpenTelemetry Jaeger exporter reports the span kind, status,
+// trace state and instrumentation library with are turned back into the fields of the span, an error tag without a
+// status is an error status. Numbers are read whether they were decoded as float64 or json.Number.
+func JaegerToTrace(jt *JaegerTrace) (*tempopb.Trace, error) {
+	t := &tempopb.Trace{}
+	batches := map[string]*v1_trace.ResourceSpans{}
+	for i := range jt.Spans {
+		js := &jt.Spans[i]
+
+		processID := js.ProcessID
+		process, ok := jt.Processes[processID]
+		if js.Process != nil {
+			// processes embedded in the spans are not shared, the span id keeps them apart
+			processID = "span:" + js.SpanID
+			process, ok = *js.Process, true
+		}
+		if !ok {
+			return nil, fmt.Errorf("span %s references unknown process %s", js.SpanID, js.ProcessID)
+		}
+
+		batch, ok := batches[processID]
+		if !ok {
+			resource, err := jaegerProcessToResource(process)
+			if err != nil {
+				return nil, err
+			}
+			batch = &v1_trace.ResourceSpans{Resource: resource}
+			batches[processID] = batch
+			t.Batches = append(t.Batches, batch)
+		}
+
+		s, library, err := jaegerToSpan(js)
+		if err != nil {
+			return nil, err
+		}
+		ils := instrumentationLibrarySpans(batch, library)
+		ils.Spans = append(ils.Spans, s)
+	}
+
+	return t, nil
+}
+
+// instrumentationLibrarySpans returns the spans of the library in the batch and adds them if the batch has none yet.
+func instrumentationLibrarySpans(batch *v1_trace.ResourceSpans, library *v1_common.InstrumentationLibrary) *v1_trace.InstrumentationLibrarySpans {
+	for _, ils := range batch.InstrumentationLibrarySpans {
+		if ils.InstrumentationLibrary.GetName() == library.GetName() && ils.InstrumentationLibrary.GetVersion() == library.GetVersion() {
+			return ils
+		}
+	}
+	ils := &v1_trace.InstrumentationLibrarySpans{InstrumentationLibrary: library}
+	batch.InstrumentationLibrarySpans = append(batch.InstrumentationLibrarySpans, ils)
+	return ils
+}
+
+func jaegerProcessToResource(p JaegerProcess) (*v1_resource.Resource, error) {
+	r := &v1_resource.Resource{
+		Attributes: []*v1_common.KeyValue{stringAttribute(serviceNameAttribute, p.ServiceName)},
+	}
+	for _, tag := range p.Tags {
+		kv, err := jaegerToKeyValue(tag)
+		if err != nil {
+			return nil, err
+		}
+		r.Attributes = append(r.Attributes, kv)
+	}
+	return r, nil
+}
+
+func jaegerToSpan(js *JaegerSpan) (*v1_trace.Span, *v1_common.InstrumentationLibrary, error) {
+	traceID, err := parseJaegerID(js.TraceID, 16)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "invalid trace id of span %s", js.SpanID)
+	}
+	spanID, err := parseJaegerID(js.SpanID, 8)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "invalid span id")
+	}
+
+	s := &v1_trace.Span{
+		TraceId:           traceID,
+		SpanId:            spanID,
+		Name:              js.OperationName,
+		StartTimeUnixNano: js.StartTime * 1000,
+		EndTimeUnixNano:   (js.StartTime + js.Duration) * 1000,
+	}
+
+	for _, ref := range js.References {
+		refTraceID, err := parseJaegerID(ref.TraceID, 16)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "invalid trace id of reference of span %s", js.SpanID)
+		}
+		refSpanID, err := parseJaegerID(ref.SpanID, 8)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "invalid span id of reference of span %s", js.SpanID)
+		}
+		if s.ParentSpanId == nil && ref.RefType == jaeger.ChildOf.String() && bytes.Equal(refTraceID, traceID) {
+			s.ParentSpanId = refSpanID
+			continue
+		}
+		s.Links = append(s.Links, &v1_trace.Span_Link{TraceId: refTraceID, SpanId: refSpanID})
+	}
+
+	var library *v1_common.InstrumentationLibrary
+	var isError bool
+	for _, tag := range js.Tags {
+		switch tag.Key {
+		case jaegerTagLibraryName, jaegerTagLibraryVersion:
+			if library == nil {
+				library = &v1_common.InstrumentationLibrary{}
+			}
+			if tag.Key == jaegerTagLibraryName {
+				library.Name = fmt.Sprint(tag.Value)
+			} else {
+				library.Version = fmt.Sprint(tag.Value)
+			}
+			continue
+		case jaegerTagSpanKind:
+			if kind, ok := jaegerToSpanKind(fmt.Sprint(tag.Value)); ok {
+				s.Kind = kind
+				continue
+			}
+		case jaegerTagStatusCode:
+			if code, ok := jaegerToStatusCode(fmt.Sprint(tag.Value)); ok {
+				if s.Status == nil {
+					s.Status = &v1_trace.Status{}
+				}
+				s.Status.Code = code
+				continue
+			}
+		case jaegerTagStatusDescription:
+			if s.Status == nil {
+				s.Status = &v1_trace.Status{}
+			}
+			s.Status.Message = fmt.Sprint(tag.Value)
+			continue
+		case jaegerTagTraceState:
+			s.TraceState = fmt.Sprint(tag.Value)
+			continue
+		case jaegerTagError:
+			isError = fmt.Sprint(tag.Value) == "true"
+			continue
+		}
+
+		kv, err := jaegerToKeyValue(tag)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "invalid tag of span %s", js.SpanID)
+		}
+		s.Attributes = append(s.Attributes, kv)
+	}
+	// spans of Jaeger clients only have the error tag, the status code of the exporter takes precedence
+	if isError && (s.Status == nil || s.Status.Code == v1_trace.Status_STATUS_CODE_UNSET) {
+		if s.Status == nil {
+			s.Status = &v1_trace.Status{}
+		}
+		s.Status.Code = v1_trace.Status_STATUS_CODE_ERROR
+	}
+
+	for _, log := range js.Logs {
+		e := &v1_trace.Span_Event{TimeUnixNano: log.Timestamp * 1000}
+		for _, field := range log.Fields {
+			if field.Key == jaegerFieldEvent && e.Name == "" {
+				e.Name = fmt.Sprint(field.Value)
+				continue
+			}
+			kv, err := jaegerToKeyValue(field)
+			if err != nil {
+				return nil, nil, errors.Wrapf(err, "invalid log field of span %s", js.SpanID)
+			}
+			e.Attributes = append(e.Attributes, kv)
+		}
+		s.Events = append(s.Events, e)
+	}
+
+	return s, library, nil
+}
+
+func jaegerToSpanKind(kind string) (v1_trace.Span_SpanKind, bool) {
+	switch kind {
+	case "internal":
+		return v1_trace.Span_SPAN_KIND_INTERNAL, true
+	case "server":
+		return v1_trace.Span_SPAN_KIND_SERVER, true
+	case "client":
+		return v1_trace.Span_SPAN_KIND_CLIENT, true
+	case "producer":
+		return v1_trace.Span_SPAN_KIND_PRODUCER, true
+	case "consumer":
+		return v1_trace.Span_SPAN_KIND_CONSUMER, true
+	default:
+		return v1_trace.Span_SPAN_KIND_UNSPECIFIED, false
+	}
+}
+
+func jaegerToStatusCode(code string) (v1_trace.Status_StatusCode, bool) {
+	switch code {
+	case "OK":
+		return v1_trace.Status_STATUS_CODE_OK, true
+	case "ERROR":
+		return v1_trace.Status_STATUS_CODE_ERROR, true
+	default:
+		return v1_trace.Status_STATUS_CODE_UNSET, false
+	}
+}
+
+// jaegerToKeyValue converts a tag to an attribute of its type. Binary values are kept as the base64 string Jaeger
+// encodes them as, the attributes of this OTLP version have no bytes type.
+func jaegerToKeyValue(tag JaegerKeyValue) (*v1_common.KeyValue, error) {
+	switch strings.ToLower(tag.Type) {
+	case "bool":
+		switch v := tag.Value.(type) {
+		case bool:
+			return &v1_common.KeyValue{Key: tag.Key, Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_BoolValue{BoolValue: v}}}, nil
+		case string:
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid bool value of tag %s", tag.Key)
+			}
+			return &v1_common.KeyValue{Key: tag.Key, Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_BoolValue{BoolValue: b}}}, nil
+		}
+	case "int64":
+		i, err := strconv.ParseInt(jaegerNumber(tag.Value), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid int64 value of tag %s", tag.Key)
+		}
+		return intAttribute(tag.Key, i), nil
+	case "float64":
+		f, err := strconv.ParseFloat(jaegerNumber(tag.Value), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid float64 value of tag %s", tag.Key)
+		}
+		return &v1_common.KeyValue{Key: tag.Key, Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_DoubleValue{DoubleValue: f}}}, nil
+	case "string", "binary", "":
+		if tag.Value == nil {
+			return stringAttribute(tag.Key, ""), nil
+		}
+		return stringAttribute(tag.Key, fmt.Sprint(tag.Value)), nil
+	}
+	return nil, fmt.Errorf("invalid type %s of tag %s", tag.Type, tag.Key)
+}
+
+// jaegerNumber formats a number decoded from JSON without an exponent, so large integers decoded as float64 parse.
+func jaegerNumber(v interface{}) string {
+	switch v := v.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case json.Number:
+		return v.String()
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// parseJaegerID parses a hex id of at most size bytes. Jaeger omits leading zeros, like the high bits of 64 bit trace
+// ids.
+func parseJaegerID(id string, size int) ([]byte, error) {
+	if id == "" || len(id) > 2*size {
+		return nil, fmt.Errorf("invalid id %q", id)
+	}
+	b, err := hex.DecodeString(strings.Repeat("0", 2*size-len(id)) + id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid id %q", id)
+	}
+	return b, nil
+}
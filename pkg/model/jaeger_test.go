@@ -0,0 +1,319 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+	v1_common "github.com/grafana/tempo/pkg/tempopb/common/v1"
+	v1_resource "github.com/grafana/tempo/pkg/tempopb/resource/v1"
+	v1 "github.com/grafana/tempo/pkg/tempopb/trace/v1"
+)
+
+var updateGolden = flag.Bool("update-golden", false, "regenerate golden files in testdata")
+
+// goldenTrace has a span of every kind and status, span links, events and attributes of every type.
+func goldenTrace() *tempopb.Trace {
+	traceID := []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10}
+	linkedTraceID := []byte{0, 0, 0, 0, 0, 0, 0, 0, 0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff, 0x00, 0x11}
+	spanID := func(id byte) []byte {
+		return []byte{0, 0, 0, 0, 0, 0, 0, id}
+	}
+	resource := func(service string, host string) *v1_resource.Resource {
+		return &v1_resource.Resource{Attributes: []*v1_common.KeyValue{
+			stringAttribute(serviceNameAttribute, service),
+			stringAttribute("host.name", host),
+		}}
+	}
+
+	return &tempopb.Trace{
+		Batches: []*v1.ResourceSpans{
+			{
+				Resource: resource("frontend", "a"),
+				InstrumentationLibrarySpans: []*v1.InstrumentationLibrarySpans{{
+					InstrumentationLibrary: &v1_common.InstrumentationLibrary{Name: "http", Version: "1.0"},
+					Spans: []*v1.Span{
+						{
+							TraceId:           traceID,
+							SpanId:            spanID(1),
+							Name:              "GET /api",
+							Kind:              v1.Span_SPAN_KIND_SERVER,
+							StartTimeUnixNano: 1_000_000_000,
+							EndTimeUnixNano:   1_500_000_000,
+							TraceState:        "vendor=value",
+							Attributes: []*v1_common.KeyValue{
+								stringAttribute("http.method", "GET"),
+								{Key: "http.status_code", Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_IntValue{IntValue: 200}}},
+								{Key: "sampled", Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_BoolValue{BoolValue: true}}},
+								{Key: "ratio", Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_DoubleValue{DoubleValue: 0.5}}},
+								{Key: "tags", Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_ArrayValue{ArrayValue: &v1_common.ArrayValue{
+									Values: []*v1_common.AnyValue{
+										{Value: &v1_common.AnyValue_StringValue{StringValue: "a"}},
+										{Value: &v1_common.AnyValue_IntValue{IntValue: 1}},
+									},
+								}}}},
+							},
+							Status: &v1.Status{Code: v1.Status_STATUS_CODE_OK},
+							Links: []*v1.Span_Link{
+								{TraceId: linkedTraceID, SpanId: spanID(9), Attributes: []*v1_common.KeyValue{stringAttribute("link", "batch")}},
+							},
+						},
+						{
+							TraceId:           traceID,
+							SpanId:            spanID(2),
+							ParentSpanId:      spanID(1),
+							Name:              "query",
+							Kind:              v1.Span_SPAN_KIND_CLIENT,
+							StartTimeUnixNano: 1_100_000_000,
+							EndTimeUnixNano:   1_400_000_000,
+							Status:            &v1.Status{Code: v1.Status_STATUS_CODE_ERROR, Message: "connection refused"},
+							Events: []*v1.Span_Event{
+								{
+									TimeUnixNano: 1_200_000_000,
+									Name:         "exception",
+									Attributes: []*v1_common.KeyValue{
+										stringAttribute("exception.message", "connection refused"),
+									},
+								},
+								{TimeUnixNano: 1_300_000_000, Name: "retry"},
+							},
+						},
+					},
+				}},
+			},
+			{
+				Resource: resource("db", "b"),
+				InstrumentationLibrarySpans: []*v1.InstrumentationLibrarySpans{{
+					Spans: []*v1.Span{{
+						TraceId:           traceID,
+						SpanId:            spanID(3),
+						ParentSpanId:      spanID(2),
+						Name:              "select",
+						StartTimeUnixNano: 1_150_000_000,
+						EndTimeUnixNano:   1_350_000_000,
+					}},
+				}},
+			},
+			{
+				// the same resource as the first batch is the same process
+				Resource: resource("frontend", "a"),
+				InstrumentationLibrarySpans: []*v1.InstrumentationLibrarySpans{{
+					Spans: []*v1.Span{{
+						TraceId:           traceID,
+						SpanId:            spanID(4),
+						ParentSpanId:      spanID(1),
+						Name:              "render",
+						Kind:              v1.Span_SPAN_KIND_INTERNAL,
+						StartTimeUnixNano: 1_400_000_000,
+						EndTimeUnixNano:   1_450_000_000,
+					}},
+				}},
+			},
+		},
+	}
+}
+
+func TestTraceFormatsGolden(t *testing.T) {
+	var otlp bytes.Buffer
+	require.NoError(t, (&jsonpb.Marshaler{}).Marshal(&otlp, goldenTrace()))
+	jaeger, err := MarshalJaegerJSON(goldenTrace())
+	require.NoError(t, err)
+
+	for file, actual := range map[string][]byte{
+		"trace.otlp.json":   otlp.Bytes(),
+		"trace.jaeger.json": jaeger,
+	} {
+		t.Run(file, func(t *testing.T) {
+			goldenFile := filepath.Join("testdata", file)
+			if *updateGolden {
+				var indented bytes.Buffer
+				require.NoError(t, json.Indent(&indented, actual, "", "  "))
+				indented.WriteString("\n")
+				require.NoError(t, os.MkdirAll("testdata", 0o755))
+				require.NoError(t, os.WriteFile(goldenFile, indented.Bytes(), 0o644))
+			}
+
+			golden, err := os.ReadFile(goldenFile)
+			require.NoError(t, err, "golden file missing. run with -update-golden to create it")
+			assert.JSONEq(t, string(golden), string(actual))
+		})
+	}
+}
+
+func TestTraceToJaeger(t *testing.T) {
+	jt := TraceToJaeger(goldenTrace())
+
+	assert.Equal(t, "0102030405060708090a0b0c0d0e0f10", jt.TraceID)
+	require.Len(t, jt.Spans, 4)
+	assert.Len(t, jt.Processes, 2)
+	assert.Equal(t, "p1", jt.Spans[3].ProcessID)
+	assert.Equal(t, "db", jt.Processes[jt.Spans[2].ProcessID].ServiceName)
+
+	// the parent is the first reference, links follow
+	assert.Equal(t, []JaegerReference{{RefType: "FOLLOWS_FROM", TraceID: "aabbccddeeff0011", SpanID: "0000000000000009"}}, jt.Spans[0].References)
+	assert.Equal(t, []JaegerReference{{RefType: "CHILD_OF", TraceID: jt.TraceID, SpanID: "0000000000000001"}}, jt.Spans[1].References)
+
+	// the error status is an error tag
+	assert.Contains(t, jt.Spans[1].Tags, JaegerKeyValue{Key: "error", Type: "bool", Value: true})
+	assert.Contains(t, jt.Spans[1].Tags, JaegerKeyValue{Key: "otel.status_description", Type: "string", Value: "connection refused"})
+	// an unset status isn't reported
+	assert.Empty(t, jt.Spans[2].Tags)
+
+	require.Len(t, jt.Spans[1].Logs, 2)
+	assert.Equal(t, uint64(1_200_000), jt.Spans[1].Logs[0].Timestamp)
+	assert.Equal(t, JaegerKeyValue{Key: "event", Type: "string", Value: "exception"}, jt.Spans[1].Logs[0].Fields[0])
+
+	// spans without resources belong to an unknown service
+	jt = TraceToJaeger(&tempopb.Trace{Batches: []*v1.ResourceSpans{{
+		InstrumentationLibrarySpans: []*v1.InstrumentationLibrarySpans{{Spans: []*v1.Span{{SpanId: []byte{1}}}}},
+	}}})
+	assert.Equal(t, "unknown_service", jt.Processes["p1"].ServiceName)
+	assert.Equal(t, "01", jt.Spans[0].SpanID)
+}
+
+func TestJaegerToTrace(t *testing.T) {
+	// the spans of a Jaeger client with process tags, as exported from the Jaeger UI
+	dump := `{
+		"traceID": "aabbccddeeff0011",
+		"spans": [
+			{
+				"traceID": "aabbccddeeff0011",
+				"spanID": "1",
+				"operationName": "GET /api",
+				"references": [],
+				"startTime": 1000000,
+				"duration": 500000,
+				"tags": [
+					{"key": "span.kind", "type": "string", "value": "server"},
+					{"key": "http.status_code", "type": "int64", "value": 500},
+					{"key": "error", "type": "bool", "value": true},
+					{"key": "large", "type": "int64", "value": 9007199254740993}
+				],
+				"logs": [
+					{"timestamp": 1200000, "fields": [{"key": "event", "type": "string", "value": "exception"}, {"key": "message", "type": "string", "value": "timeout"}]}
+				],
+				"processID": "p1"
+			},
+			{
+				"traceID": "aabbccddeeff0011",
+				"spanID": "2",
+				"operationName": "query",
+				"references": [
+					{"refType": "FOLLOWS_FROM", "traceID": "ff", "spanID": "9"},
+					{"refType": "CHILD_OF", "traceID": "aabbccddeeff0011", "spanID": "1"}
+				],
+				"startTime": 1100000,
+				"duration": 100000,
+				"tags": [{"key": "ratio", "type": "float64", "value": 0.5}],
+				"processID": "p2"
+			}
+		],
+		"processes": {
+			"p1": {"serviceName": "frontend", "tags": [{"key": "hostname", "type": "string", "value": "a"}, {"key": "jaeger.version", "type": "string", "value": "Go-2.29.1"}]},
+			"p2": {"serviceName": "db", "tags": [{"key": "client-uuid", "type": "string", "value": "abc"}]}
+		}
+	}`
+	jt := &JaegerTrace{}
+	dec := json.NewDecoder(bytes.NewReader([]byte(dump)))
+	dec.UseNumber()
+	require.NoError(t, dec.Decode(jt))
+
+	trace, err := JaegerToTrace(jt)
+	require.NoError(t, err)
+	require.Len(t, trace.Batches, 2)
+
+	// the process tags are resource attributes
+	assert.Equal(t, []*v1_common.KeyValue{
+		stringAttribute(serviceNameAttribute, "frontend"),
+		stringAttribute("hostname", "a"),
+		stringAttribute("jaeger.version", "Go-2.29.1"),
+	}, trace.Batches[0].Resource.Attributes)
+	assert.Equal(t, []*v1_common.KeyValue{
+		stringAttribute(serviceNameAttribute, "db"),
+		stringAttribute("client-uuid", "abc"),
+	}, trace.Batches[1].Resource.Attributes)
+
+	// the short ids are padded
+	server := trace.Batches[0].InstrumentationLibrarySpans[0].Spans[0]
+	assert.Equal(t, []byte{0, 0, 0, 0, 0, 0, 0, 0, 0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff, 0x00, 0x11}, server.TraceId)
+	assert.Equal(t, []byte{0, 0, 0, 0, 0, 0, 0, 1}, server.SpanId)
+	assert.Equal(t, uint64(1_000_000_000), server.StartTimeUnixNano)
+	assert.Equal(t, uint64(1_500_000_000), server.EndTimeUnixNano)
+
+	// the kind and error tags are fields of the span, numbers keep their precision
+	assert.Equal(t, v1.Span_SPAN_KIND_SERVER, server.Kind)
+	assert.Equal(t, &v1.Status{Code: v1.Status_STATUS_CODE_ERROR}, server.Status)
+	assert.Equal(t, []*v1_common.KeyValue{intAttribute("http.status_code", 500), intAttribute("large", 9007199254740993)}, server.Attributes)
+	assert.Equal(t, []*v1.Span_Event{{
+		TimeUnixNano: 1_200_000_000,
+		Name:         "exception",
+		Attributes:   []*v1_common.KeyValue{stringAttribute("message", "timeout")},
+	}}, server.Events)
+
+	// the CHILD_OF reference is the parent wherever it is, the other references are links
+	query := trace.Batches[1].InstrumentationLibrarySpans[0].Spans[0]
+	assert.Equal(t, server.SpanId, query.ParentSpanId)
+	require.Len(t, query.Links, 1)
+	assert.Equal(t, []byte{0, 0, 0, 0, 0, 0, 0, 9}, query.Links[0].SpanId)
+	assert.Equal(t, v1.Span_SPAN_KIND_UNSPECIFIED, query.Kind)
+
+	// spans referencing processes that aren't in the trace are an error
+	jt.Spans[1].ProcessID = "p3"
+	_, err = JaegerToTrace(jt)
+	assert.Error(t, err)
+}
+
+func TestJaegerToTraceRoundTrip(t *testing.T) {
+	// the fields of the golden trace apart from array and map attributes, which Jaeger holds as JSON strings, and the
+	// attributes of links, which Jaeger has no field for
+	scalars := func(kvs []*v1_common.KeyValue) []*v1_common.KeyValue {
+		var kept []*v1_common.KeyValue
+		for _, kv := range kvs {
+			switch kv.Value.GetValue().(type) {
+			case *v1_common.AnyValue_ArrayValue, *v1_common.AnyValue_KvlistValue:
+				continue
+			}
+			kept = append(kept, kv)
+		}
+		return kept
+	}
+	expected := goldenTrace()
+	for _, s := range spansOf(expected) {
+		s.Attributes = scalars(s.Attributes)
+		for _, e := range s.Events {
+			e.Attributes = scalars(e.Attributes)
+		}
+		for _, l := range s.Links {
+			l.Attributes = nil
+		}
+	}
+
+	b, err := json.Marshal(TraceToJaeger(expected))
+	require.NoError(t, err)
+	jt := &JaegerTrace{}
+	require.NoError(t, json.Unmarshal(b, jt))
+
+	actual, err := JaegerToTrace(jt)
+	require.NoError(t, err)
+
+	// the spans are equal but batches of the same resource are merged
+	assert.ElementsMatch(t, spansOf(expected), spansOf(actual))
+}
+
+func spansOf(t *tempopb.Trace) []*v1.Span {
+	var spans []*v1.Span
+	for _, b := range t.Batches {
+		for _, ils := range b.InstrumentationLibrarySpans {
+			spans = append(spans, ils.Spans...)
+		}
+	}
+	return spans
+}
@@ -0,0 +1,162 @@
+package model
+
+import (
+	"github.com/grafana/tempo/pkg/tempopb"
+	v1_common "github.com/grafana/tempo/pkg/tempopb/common/v1"
+	v1_resource "github.com/grafana/tempo/pkg/tempopb/resource/v1"
+	v1 "github.com/grafana/tempo/pkg/tempopb/trace/v1"
+)
+
+const (
+	// SkeletonServiceName is the service name of the batch holding the summary span of a skeleton.
+	SkeletonServiceName = "tempo-skeleton"
+	// SkeletonSpanName is the name of the summary span of a skeleton.
+	SkeletonSpanName = "tempo.skeleton"
+
+	// Attributes of the summary span of a skeleton.
+	SkeletonSpanCountAttribute        = "tempo.skeleton.span_count"
+	SkeletonErrorSpanCountAttribute   = "tempo.skeleton.error_span_count"
+	SkeletonDroppedSpanCountAttribute = "tempo.skeleton.dropped_span_count"
+
+	serviceNameAttribute = "service.name"
+)
+
+// SkeletonBytes returns the skeleton of the trace encoded in obj, encoded with the same dataEncoding. See Skeleton.
+func SkeletonBytes(obj []byte, dataEncoding string, summaryID []byte) ([]byte, error) {
+	trace, err := Unmarshal(obj, dataEncoding)
+	if err != nil {
+		return nil, err
+	}
+
+	return marshal(Skeleton(trace, summaryID), dataEncoding)
+}
+
+// Skeleton returns a downsampled copy of trace that is kept after the trace itself was deleted. It holds the root
+// spans, their direct children and the spans with an error status without their attributes, events and links. A
+// summary span with the id summaryID, which spans the whole trace, records the number of spans of the trace.
+func Skeleton(trace *tempopb.Trace, summaryID []byte) *tempopb.Trace {
+	roots := map[string]struct{}{}
+	for _, b := range trace.Batches {
+		for _, ils := range b.InstrumentationLibrarySpans {
+			for _, s := range ils.Spans {
+				if len(s.ParentSpanId) == 0 {
+					roots[string(s.SpanId)] = struct{}{}
+				}
+			}
+		}
+	}
+
+	skeleton := &tempopb.Trace{IngestionTimeUnixNano: trace.IngestionTimeUnixNano}
+	summary := &v1.Span{
+		SpanId: summaryID,
+		Name:   SkeletonSpanName,
+		Kind:   v1.Span_SPAN_KIND_INTERNAL,
+	}
+	var spanCount, errorCount, keptCount int64
+
+	for _, b := range trace.Batches {
+		var keptILS []*v1.InstrumentationLibrarySpans
+		for _, ils := range b.InstrumentationLibrarySpans {
+			var keptSpans []*v1.Span
+			for _, s := range ils.Spans {
+				spanCount++
+				if len(summary.TraceId) == 0 {
+					summary.TraceId = s.TraceId
+				}
+				if summary.StartTimeUnixNano == 0 || s.StartTimeUnixNano < summary.StartTimeUnixNano {
+					summary.StartTimeUnixNano = s.StartTimeUnixNano
+				}
+				if s.EndTimeUnixNano > summary.EndTimeUnixNano {
+					summary.EndTimeUnixNano = s.EndTimeUnixNano
+				}
+
+				isError := s.Status != nil && s.Status.Code == v1.Status_STATUS_CODE_ERROR
+				if isError {
+					errorCount++
+				}
+
+				_, parentIsRoot := roots[string(s.ParentSpanId)]
+				if len(s.ParentSpanId) == 0 && len(summary.ParentSpanId) == 0 {
+					summary.ParentSpanId = s.SpanId
+				}
+				if len(s.ParentSpanId) != 0 && !parentIsRoot && !isError {
+					continue
+				}
+
+				keptCount++
+				keptSpans = append(keptSpans, skeletonSpan(s))
+			}
+
+			if len(keptSpans) > 0 {
+				keptILS = append(keptILS, &v1.InstrumentationLibrarySpans{
+					InstrumentationLibrary: ils.InstrumentationLibrary,
+					Spans:                  keptSpans,
+				})
+			}
+		}
+
+		if len(keptILS) > 0 {
+			skeleton.Batches = append(skeleton.Batches, &v1.ResourceSpans{
+				Resource:                    skeletonResource(b.Resource),
+				InstrumentationLibrarySpans: keptILS,
+			})
+		}
+	}
+
+	summary.Attributes = []*v1_common.KeyValue{
+		intAttribute(SkeletonSpanCountAttribute, spanCount),
+		intAttribute(SkeletonErrorSpanCountAttribute, errorCount),
+		intAttribute(SkeletonDroppedSpanCountAttribute, spanCount-keptCount),
+	}
+	skeleton.Batches = append(skeleton.Batches, &v1.ResourceSpans{
+		Resource: &v1_resource.Resource{
+			Attributes: []*v1_common.KeyValue{stringAttribute(serviceNameAttribute, SkeletonServiceName)},
+		},
+		InstrumentationLibrarySpans: []*v1.InstrumentationLibrarySpans{{Spans: []*v1.Span{summary}}},
+	})
+
+	return skeleton
+}
+
+// skeletonSpan copies the ids, name, kind, duration and status of s.
+func skeletonSpan(s *v1.Span) *v1.Span {
+	return &v1.Span{
+		TraceId:           s.TraceId,
+		SpanId:            s.SpanId,
+		ParentSpanId:      s.ParentSpanId,
+		Name:              s.Name,
+		Kind:              s.Kind,
+		StartTimeUnixNano: s.StartTimeUnixNano,
+		EndTimeUnixNano:   s.EndTimeUnixNano,
+		Status:            s.Status,
+	}
+}
+
+// skeletonResource keeps the service name of the resource only.
+func skeletonResource(r *v1_resource.Resource) *v1_resource.Resource {
+	kept := &v1_resource.Resource{}
+	if r == nil {
+		return kept
+	}
+
+	for _, attr := range r.Attributes {
+		if attr.Key == serviceNameAttribute {
+			kept.Attributes = append(kept.Attributes, attr)
+		}
+	}
+	return kept
+}
+
+func intAttribute(key string, value int64) *v1_common.KeyValue {
+	return &v1_common.KeyValue{
+		Key:   key,
+		Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_IntValue{IntValue: value}},
+	}
+}
+
+func stringAttribute(key string, value string) *v1_common.KeyValue {
+	return &v1_common.KeyValue{
+		Key:   key,
+		Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_StringValue{StringValue: value}},
+	}
+}
@@ -0,0 +1,111 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+	v1_common "github.com/grafana/tempo/pkg/tempopb/common/v1"
+	v1_resource "github.com/grafana/tempo/pkg/tempopb/resource/v1"
+	v1 "github.com/grafana/tempo/pkg/tempopb/trace/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSkeleton(t *testing.T) {
+	traceID := []byte{0x01, 0x02}
+	span := func(id byte, parent byte, start uint64, end uint64, status v1.Status_StatusCode) *v1.Span {
+		s := &v1.Span{
+			TraceId:           traceID,
+			SpanId:            []byte{id},
+			Name:              "span",
+			StartTimeUnixNano: start,
+			EndTimeUnixNano:   end,
+			Attributes:        []*v1_common.KeyValue{stringAttribute("foo", "bar")},
+			Status:            &v1.Status{Code: status},
+		}
+		if parent != 0 {
+			s.ParentSpanId = []byte{parent}
+		}
+		return s
+	}
+
+	trace := &tempopb.Trace{
+		IngestionTimeUnixNano: 10,
+		Batches: []*v1.ResourceSpans{
+			{
+				Resource: &v1_resource.Resource{Attributes: []*v1_common.KeyValue{
+					stringAttribute(serviceNameAttribute, "frontend"),
+					stringAttribute("host", "a"),
+				}},
+				InstrumentationLibrarySpans: []*v1.InstrumentationLibrarySpans{{Spans: []*v1.Span{
+					span(1, 0, 10, 100, v1.Status_STATUS_CODE_OK),
+					span(2, 1, 20, 90, v1.Status_STATUS_CODE_UNSET),
+				}}},
+			},
+			{
+				Resource: &v1_resource.Resource{Attributes: []*v1_common.KeyValue{stringAttribute(serviceNameAttribute, "backend")}},
+				InstrumentationLibrarySpans: []*v1.InstrumentationLibrarySpans{{Spans: []*v1.Span{
+					span(3, 2, 30, 40, v1.Status_STATUS_CODE_UNSET),
+					span(4, 2, 5, 110, v1.Status_STATUS_CODE_ERROR),
+				}}},
+			},
+			{
+				Resource: &v1_resource.Resource{},
+				InstrumentationLibrarySpans: []*v1.InstrumentationLibrarySpans{{Spans: []*v1.Span{
+					span(5, 3, 35, 36, v1.Status_STATUS_CODE_UNSET),
+				}}},
+			},
+		},
+	}
+
+	skeleton := Skeleton(trace, []byte{0xff})
+	assert.Equal(t, uint64(10), skeleton.IngestionTimeUnixNano)
+
+	// the root, its child and the error span are kept without attributes, batches without kept spans are dropped
+	require.Len(t, skeleton.Batches, 3)
+	assert.Equal(t, []*v1_common.KeyValue{stringAttribute(serviceNameAttribute, "frontend")}, skeleton.Batches[0].Resource.Attributes)
+	var ids []byte
+	for _, s := range allSpans(&tempopb.Trace{Batches: skeleton.Batches[:2]}) {
+		ids = append(ids, s.SpanId[0])
+		assert.Empty(t, s.Attributes)
+	}
+	assert.Equal(t, []byte{1, 2, 4}, ids)
+
+	// the summary spans the whole trace and counts its spans
+	summaryBatch := skeleton.Batches[2]
+	assert.Equal(t, []*v1_common.KeyValue{stringAttribute(serviceNameAttribute, SkeletonServiceName)}, summaryBatch.Resource.Attributes)
+	summary := summaryBatch.InstrumentationLibrarySpans[0].Spans[0]
+	assert.Equal(t, &v1.Span{
+		TraceId:           traceID,
+		SpanId:            []byte{0xff},
+		ParentSpanId:      []byte{1},
+		Name:              SkeletonSpanName,
+		Kind:              v1.Span_SPAN_KIND_INTERNAL,
+		StartTimeUnixNano: 5,
+		EndTimeUnixNano:   110,
+		Attributes: []*v1_common.KeyValue{
+			intAttribute(SkeletonSpanCountAttribute, 5),
+			intAttribute(SkeletonErrorSpanCountAttribute, 1),
+			intAttribute(SkeletonDroppedSpanCountAttribute, 2),
+		},
+	}, summary)
+}
+
+func TestSkeletonBytes(t *testing.T) {
+	for _, enc := range allEncodings {
+		t.Run(enc, func(t *testing.T) {
+			trace := &tempopb.Trace{Batches: []*v1.ResourceSpans{{
+				InstrumentationLibrarySpans: []*v1.InstrumentationLibrarySpans{{Spans: []*v1.Span{
+					{TraceId: []byte{0x01}, SpanId: []byte{0x01}},
+				}}},
+			}}}
+
+			obj, err := SkeletonBytes(mustMarshal(trace, enc), enc, []byte{0x02})
+			require.NoError(t, err)
+
+			actual, err := Unmarshal(obj, enc)
+			require.NoError(t, err)
+			assert.Equal(t, Skeleton(trace, []byte{0x02}), actual)
+		})
+	}
+}
@@ -58,3 +58,41 @@ func SortTraceBytes(t *tempopb.TraceBytes) {
 		return bytes.Compare(traceI, traceJ) == -1
 	})
 }
+
+// SortSpansByStartTime sorts the spans of every instrumentation library by start time, and the instrumentation
+// libraries of every batch by their earliest span. Batches are left in place. Unlike SortTrace it allocates once per
+// call instead of once per sorted slice and skips slices that are already in order.
+func SortSpansByStartTime(t *tempopb.Trace) {
+	spans := &spanSorter{}
+	ils := &ilsSorter{}
+
+	for _, b := range t.Batches {
+		for _, l := range b.InstrumentationLibrarySpans {
+			spans.spans = l.Spans
+			if !sort.IsSorted(spans) {
+				sort.Sort(spans)
+			}
+		}
+
+		ils.ils = b.InstrumentationLibrarySpans
+		if !sort.IsSorted(ils) {
+			sort.Sort(ils)
+		}
+	}
+}
+
+type spanSorter struct {
+	spans []*v1.Span
+}
+
+func (s *spanSorter) Len() int           { return len(s.spans) }
+func (s *spanSorter) Less(i, j int) bool { return compareSpans(s.spans[i], s.spans[j]) }
+func (s *spanSorter) Swap(i, j int)      { s.spans[i], s.spans[j] = s.spans[j], s.spans[i] }
+
+type ilsSorter struct {
+	ils []*v1.InstrumentationLibrarySpans
+}
+
+func (s *ilsSorter) Len() int           { return len(s.ils) }
+func (s *ilsSorter) Less(i, j int) bool { return compareIls(s.ils[i], s.ils[j]) }
+func (s *ilsSorter) Swap(i, j int)      { s.ils[i], s.ils[j] = s.ils[j], s.ils[i] }
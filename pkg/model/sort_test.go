@@ -138,3 +138,111 @@ func BenchmarkSortTraceBytes(b *testing.B) {
 		SortTraceBytes(traceBytes)
 	}
 }
+
+func TestSortSpansByStartTime(t *testing.T) {
+	trace := &tempopb.Trace{
+		Batches: []*v1.ResourceSpans{
+			{
+				InstrumentationLibrarySpans: []*v1.InstrumentationLibrarySpans{
+					{
+						Spans: []*v1.Span{
+							{StartTimeUnixNano: 5},
+							{StartTimeUnixNano: 3, SpanId: []byte{0x02}},
+							{StartTimeUnixNano: 3, SpanId: []byte{0x01}},
+						},
+					},
+					{
+						Spans: []*v1.Span{
+							{StartTimeUnixNano: 4},
+							{StartTimeUnixNano: 2},
+						},
+					},
+					{},
+				},
+			},
+			{
+				InstrumentationLibrarySpans: []*v1.InstrumentationLibrarySpans{
+					{
+						Spans: []*v1.Span{
+							{StartTimeUnixNano: 1},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	SortSpansByStartTime(trace)
+
+	// batches are not reordered
+	expected := &tempopb.Trace{
+		Batches: []*v1.ResourceSpans{
+			{
+				InstrumentationLibrarySpans: []*v1.InstrumentationLibrarySpans{
+					{
+						Spans: []*v1.Span{
+							{StartTimeUnixNano: 2},
+							{StartTimeUnixNano: 4},
+						},
+					},
+					{
+						Spans: []*v1.Span{
+							{StartTimeUnixNano: 3, SpanId: []byte{0x01}},
+							{StartTimeUnixNano: 3, SpanId: []byte{0x02}},
+							{StartTimeUnixNano: 5},
+						},
+					},
+					{},
+				},
+			},
+			{
+				InstrumentationLibrarySpans: []*v1.InstrumentationLibrarySpans{
+					{
+						Spans: []*v1.Span{
+							{StartTimeUnixNano: 1},
+						},
+					},
+				},
+			},
+		},
+	}
+	assert.Equal(t, expected, trace)
+}
+
+func BenchmarkSortSpansByStartTime(b *testing.B) {
+	benchmarkSortSpans(b, SortSpansByStartTime)
+}
+
+func BenchmarkSortTrace(b *testing.B) {
+	benchmarkSortSpans(b, SortTrace)
+}
+
+// benchmarkSortSpans sorts a trace of 100k spans in 100 instrumentation libraries whose spans are shuffled before
+// every iteration.
+func benchmarkSortSpans(b *testing.B, sortFn func(*tempopb.Trace)) {
+	batch := &v1.ResourceSpans{}
+	for i := 0; i < 100; i++ {
+		ils := &v1.InstrumentationLibrarySpans{}
+		for j := 0; j < 1000; j++ {
+			ils.Spans = append(ils.Spans, &v1.Span{StartTimeUnixNano: rand.Uint64()})
+		}
+		batch.InstrumentationLibrarySpans = append(batch.InstrumentationLibrarySpans, ils)
+	}
+	trace := &tempopb.Trace{
+		Batches: []*v1.ResourceSpans{batch},
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		for _, ils := range batch.InstrumentationLibrarySpans {
+			rand.Shuffle(len(ils.Spans), func(i, j int) {
+				ils.Spans[i], ils.Spans[j] = ils.Spans[j], ils.Spans[i]
+			})
+		}
+		b.StartTimer()
+
+		sortFn(trace)
+	}
+}
@@ -0,0 +1,167 @@
+package model
+
+import (
+	"sort"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+	v1_common "github.com/grafana/tempo/pkg/tempopb/common/v1"
+	v1_resource "github.com/grafana/tempo/pkg/tempopb/resource/v1"
+	v1 "github.com/grafana/tempo/pkg/tempopb/trace/v1"
+)
+
+// TruncatedAttribute is added to the resource of every batch of a trace that had spans dropped to fit a size limit.
+const TruncatedAttribute = "tempo.compaction.truncated"
+
+// TruncateTraceBytes drops spans from obj until it encodes to at most maxBytes. Spans are dropped latest start time
+// first, so the result is the same no matter the order the spans were combined in. The returned trace is encoded
+// with dataEncoding and is marked with TruncatedAttribute. The number of dropped spans is returned as well.
+func TruncateTraceBytes(obj []byte, dataEncoding string, maxBytes int) ([]byte, int, error) {
+	if len(obj) <= maxBytes {
+		return obj, 0, nil
+	}
+
+	trace, err := Unmarshal(obj, dataEncoding)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	for _, b := range trace.Batches {
+		markTruncated(b)
+	}
+
+	var truncated []byte
+	dropped, err := dropLatestSpans(trace, func(t *tempopb.Trace) (bool, error) {
+		bytes, err := marshal(t, dataEncoding)
+		if err != nil {
+			return false, err
+		}
+		if len(bytes) > maxBytes {
+			return false, nil
+		}
+		truncated = bytes
+		return true, nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if truncated == nil {
+		// not even the empty trace fits
+		truncated, err = marshal(&tempopb.Trace{}, dataEncoding)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+
+	return truncated, dropped, nil
+}
+
+// TruncateTrace returns a copy of trace with spans dropped until its size is at most maxBytes. Like
+// TruncateTraceBytes spans are dropped latest start time first, but the trace is not marked. The number of dropped
+// spans is returned as well.
+func TruncateTrace(trace *tempopb.Trace, maxBytes int) (*tempopb.Trace, int) {
+	if trace.Size() <= maxBytes {
+		return trace, 0
+	}
+
+	truncated := &tempopb.Trace{}
+	dropped, _ := dropLatestSpans(trace, func(t *tempopb.Trace) (bool, error) {
+		if t.Size() > maxBytes {
+			return false, nil
+		}
+		truncated = t
+		return true, nil
+	})
+
+	return truncated, dropped
+}
+
+// dropLatestSpans binary searches for the largest number of spans of trace, in start time order, that fits. fits is
+// last called with true for the trace with those spans. The number of spans that did not fit is returned.
+func dropLatestSpans(trace *tempopb.Trace, fits func(*tempopb.Trace) (bool, error)) (int, error) {
+	var spans []*v1.Span
+	for _, b := range trace.Batches {
+		for _, ils := range b.InstrumentationLibrarySpans {
+			spans = append(spans, ils.Spans...)
+		}
+	}
+	sort.SliceStable(spans, func(i, j int) bool {
+		return compareSpans(spans[i], spans[j])
+	})
+
+	kept := 0
+	low, high := 0, len(spans)
+	for low <= high {
+		keep := (low + high) / 2
+
+		ok, err := fits(keepSpans(trace, spans[:keep]))
+		if err != nil {
+			return 0, err
+		}
+
+		if ok {
+			kept = keep
+			low = keep + 1
+		} else {
+			high = keep - 1
+		}
+	}
+
+	return len(spans) - kept, nil
+}
+
+// keepSpans returns a copy of trace with only the passed spans. Batches and instrumentation libraries left without
+// spans are removed.
+func keepSpans(trace *tempopb.Trace, spans []*v1.Span) *tempopb.Trace {
+	keep := make(map[*v1.Span]struct{}, len(spans))
+	for _, s := range spans {
+		keep[s] = struct{}{}
+	}
+
+	kept := &tempopb.Trace{IngestionTimeUnixNano: trace.IngestionTimeUnixNano}
+	for _, b := range trace.Batches {
+		var keptILS []*v1.InstrumentationLibrarySpans
+		for _, ils := range b.InstrumentationLibrarySpans {
+			var keptSpans []*v1.Span
+			for _, s := range ils.Spans {
+				if _, ok := keep[s]; ok {
+					keptSpans = append(keptSpans, s)
+				}
+			}
+
+			if len(keptSpans) > 0 {
+				keptILS = append(keptILS, &v1.InstrumentationLibrarySpans{
+					InstrumentationLibrary: ils.InstrumentationLibrary,
+					Spans:                  keptSpans,
+				})
+			}
+		}
+
+		if len(keptILS) > 0 {
+			kept.Batches = append(kept.Batches, &v1.ResourceSpans{
+				Resource:                    b.Resource,
+				InstrumentationLibrarySpans: keptILS,
+			})
+		}
+	}
+
+	return kept
+}
+
+// markTruncated adds TruncatedAttribute to the resource of the batch if it's not already there.
+func markTruncated(b *v1.ResourceSpans) {
+	if b.Resource == nil {
+		b.Resource = &v1_resource.Resource{}
+	}
+
+	for _, attr := range b.Resource.Attributes {
+		if attr.Key == TruncatedAttribute {
+			return
+		}
+	}
+
+	b.Resource.Attributes = append(b.Resource.Attributes, &v1_common.KeyValue{
+		Key:   TruncatedAttribute,
+		Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_BoolValue{BoolValue: true}},
+	})
+}
@@ -0,0 +1,136 @@
+package model
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+	v1 "github.com/grafana/tempo/pkg/tempopb/trace/v1"
+	"github.com/grafana/tempo/pkg/util/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTruncateTraceBytes(t *testing.T) {
+	for _, enc := range allEncodings {
+		t.Run(enc, func(t *testing.T) {
+			trace := test.MakeTrace(10, []byte{0x01, 0x02})
+			for _, s := range allSpans(trace) {
+				s.StartTimeUnixNano = uint64(rand.Intn(1000))
+			}
+
+			obj := mustMarshal(trace, enc)
+
+			// under the limit the object is untouched
+			actual, dropped, err := TruncateTraceBytes(obj, enc, len(obj))
+			require.NoError(t, err)
+			assert.Equal(t, obj, actual)
+			assert.Equal(t, 0, dropped)
+
+			maxBytes := len(obj) / 2
+			actual, dropped, err = TruncateTraceBytes(obj, enc, maxBytes)
+			require.NoError(t, err)
+			assert.LessOrEqual(t, len(actual), maxBytes)
+
+			truncated, err := Unmarshal(actual, enc)
+			require.NoError(t, err)
+
+			kept := allSpans(truncated)
+			require.NotEmpty(t, kept)
+			assert.Equal(t, len(allSpans(trace)), len(kept)+dropped)
+
+			// only spans that started after every kept span are dropped
+			keptIDs := map[string]struct{}{}
+			for _, s := range kept {
+				keptIDs[string(s.SpanId)] = struct{}{}
+			}
+			for _, s := range allSpans(trace) {
+				if _, ok := keptIDs[string(s.SpanId)]; ok {
+					continue
+				}
+				for _, k := range kept {
+					assert.True(t, compareSpans(k, s))
+				}
+			}
+
+			for _, b := range truncated.Batches {
+				assert.True(t, isTruncated(b))
+				assert.NotEmpty(t, b.InstrumentationLibrarySpans)
+			}
+
+			// the same spans are kept regardless of their order
+			rand.Shuffle(len(trace.Batches), func(i, j int) {
+				trace.Batches[i], trace.Batches[j] = trace.Batches[j], trace.Batches[i]
+			})
+			shuffled, _, err := TruncateTraceBytes(mustMarshal(trace, enc), enc, maxBytes)
+			require.NoError(t, err)
+
+			shuffledTrace, err := Unmarshal(shuffled, enc)
+			require.NoError(t, err)
+			assert.ElementsMatch(t, kept, allSpans(shuffledTrace))
+
+			// truncating again does not add a second attribute
+			again, _, err := TruncateTraceBytes(actual, enc, maxBytes/2)
+			require.NoError(t, err)
+			assert.LessOrEqual(t, len(again), maxBytes/2)
+
+			againTrace, err := Unmarshal(again, enc)
+			require.NoError(t, err)
+			for _, b := range againTrace.Batches {
+				count := 0
+				for _, attr := range b.Resource.Attributes {
+					if attr.Key == TruncatedAttribute {
+						count++
+					}
+				}
+				assert.Equal(t, 1, count)
+			}
+		})
+	}
+}
+
+func allSpans(trace *tempopb.Trace) []*v1.Span {
+	var spans []*v1.Span
+	for _, b := range trace.Batches {
+		for _, ils := range b.InstrumentationLibrarySpans {
+			spans = append(spans, ils.Spans...)
+		}
+	}
+	return spans
+}
+
+func isTruncated(b *v1.ResourceSpans) bool {
+	for _, attr := range b.Resource.Attributes {
+		if attr.Key == TruncatedAttribute {
+			return attr.Value.GetBoolValue()
+		}
+	}
+	return false
+}
+
+func TestTruncateTrace(t *testing.T) {
+	trace := test.MakeTrace(10, []byte{0x01, 0x02})
+	for _, s := range allSpans(trace) {
+		s.StartTimeUnixNano = uint64(rand.Intn(1000))
+	}
+
+	// under the limit the trace is untouched
+	actual, dropped := TruncateTrace(trace, trace.Size())
+	assert.Equal(t, trace, actual)
+	assert.Equal(t, 0, dropped)
+
+	maxBytes := trace.Size() / 3
+	actual, dropped = TruncateTrace(trace, maxBytes)
+	assert.LessOrEqual(t, actual.Size(), maxBytes)
+	assert.Equal(t, len(allSpans(trace)), len(allSpans(actual))+dropped)
+
+	// the byte based truncation also marks the trace so it never keeps more spans
+	obj := mustMarshal(trace, TracePBEncoding)
+	_, droppedBytes, err := TruncateTraceBytes(obj, TracePBEncoding, maxBytes)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, droppedBytes, dropped)
+
+	for _, b := range actual.Batches {
+		assert.False(t, b.Resource != nil && isTruncated(b))
+	}
+}
@@ -0,0 +1,199 @@
+package model
+
+import (
+	"sort"
+
+	"github.com/pkg/errors"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+	v1_common "github.com/grafana/tempo/pkg/tempopb/common/v1"
+	v1_resource "github.com/grafana/tempo/pkg/tempopb/resource/v1"
+	v1_trace "github.com/grafana/tempo/pkg/tempopb/trace/v1"
+)
+
+// The attributes the endpoints of Zipkin spans are converted to, named like the OpenTelemetry Collector names them.
+const (
+	zipkinAttributeHostIP   = "net.host.ip"
+	zipkinAttributeHostPort = "net.host.port"
+	zipkinAttributePeerName = "peer.service"
+	zipkinAttributePeerIP   = "net.peer.ip"
+	zipkinAttributePeerPort = "net.peer.port"
+
+	zipkinTagError = "error"
+)
+
+// ZipkinSpan is a span in the JSON model of the Zipkin v2 API.
+type ZipkinSpan struct {
+	TraceID  string `json:"traceId"`
+	ID       string `json:"id"`
+	ParentID string `json:"parentId,omitempty"`
+	Name     string `json:"name,omitempty"`
+	Kind     string `json:"kind,omitempty"`
+	// Timestamp is in microseconds since the epoch, Duration in microseconds.
+	Timestamp      uint64             `json:"timestamp,omitempty"`
+	Duration       uint64             `json:"duration,omitempty"`
+	LocalEndpoint  *ZipkinEndpoint    `json:"localEndpoint,omitempty"`
+	RemoteEndpoint *ZipkinEndpoint    `json:"remoteEndpoint,omitempty"`
+	Annotations    []ZipkinAnnotation `json:"annotations,omitempty"`
+	Tags           map[string]string  `json:"tags,omitempty"`
+	Debug          bool               `json:"debug,omitempty"`
+	// Shared is set on the server side of an RPC that reuses the span id of the client side.
+	Shared bool `json:"shared,omitempty"`
+}
+
+type ZipkinEndpoint struct {
+	ServiceName string `json:"serviceName,omitempty"`
+	IPv4        string `json:"ipv4,omitempty"`
+	IPv6        string `json:"ipv6,omitempty"`
+	Port        int    `json:"port,omitempty"`
+}
+
+type ZipkinAnnotation struct {
+	// Timestamp is in microseconds since the epoch.
+	Timestamp uint64 `json:"timestamp"`
+	Value     string `json:"value"`
+}
+
+// ZipkinToTrace converts Zipkin v2 spans to OTLP. The spans of every distinct local endpoint are a batch with the
+// service name, ip and port of the endpoint as resource attributes, the remote endpoint is attributes of the span.
+// Tags are string attributes apart from the error tag, which is an error status with the tag value as message, and
+// annotations are events. Shared spans are server spans and keep the span id of the client span, the query-frontend
+// gives them a unique id when the trace is queried like for the spans received by the Zipkin receiver.
+func ZipkinToTrace(spans []ZipkinSpan) (*tempopb.Trace, error) {
+	t := &tempopb.Trace{}
+	batches := map[ZipkinEndpoint]*v1_trace.ResourceSpans{}
+	for i := range spans {
+		zs := &spans[i]
+
+		var endpoint ZipkinEndpoint
+		if zs.LocalEndpoint != nil {
+			endpoint = *zs.LocalEndpoint
+		}
+		batch, ok := batches[endpoint]
+		if !ok {
+			batch = &v1_trace.ResourceSpans{
+				Resource:                    zipkinEndpointToResource(endpoint),
+				InstrumentationLibrarySpans: []*v1_trace.InstrumentationLibrarySpans{{}},
+			}
+			batches[endpoint] = batch
+			t.Batches = append(t.Batches, batch)
+		}
+
+		s, err := zipkinToSpan(zs)
+		if err != nil {
+			return nil, err
+		}
+		batch.InstrumentationLibrarySpans[0].Spans = append(batch.InstrumentationLibrarySpans[0].Spans, s)
+	}
+
+	return t, nil
+}
+
+func zipkinEndpointToResource(e ZipkinEndpoint) *v1_resource.Resource {
+	service := e.ServiceName
+	if service == "" {
+		service = jaegerUnknownService
+	}
+	r := &v1_resource.Resource{
+		Attributes: []*v1_common.KeyValue{stringAttribute(serviceNameAttribute, service)},
+	}
+	if ip := zipkinEndpointIP(e); ip != "" {
+		r.Attributes = append(r.Attributes, stringAttribute(zipkinAttributeHostIP, ip))
+	}
+	if e.Port != 0 {
+		r.Attributes = append(r.Attributes, intAttribute(zipkinAttributeHostPort, int64(e.Port)))
+	}
+	return r
+}
+
+func zipkinEndpointIP(e ZipkinEndpoint) string {
+	if e.IPv4 != "" {
+		return e.IPv4
+	}
+	return e.IPv6
+}
+
+func zipkinToSpan(zs *ZipkinSpan) (*v1_trace.Span, error) {
+	traceID, err := parseJaegerID(zs.TraceID, 16)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid trace id of span %s", zs.ID)
+	}
+	spanID, err := parseJaegerID(zs.ID, 8)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid span id")
+	}
+
+	s := &v1_trace.Span{
+		TraceId:           traceID,
+		SpanId:            spanID,
+		Name:              zs.Name,
+		Kind:              zipkinToSpanKind(zs.Kind),
+		StartTimeUnixNano: zs.Timestamp * 1000,
+		EndTimeUnixNano:   (zs.Timestamp + zs.Duration) * 1000,
+	}
+	if zs.ParentID != "" {
+		s.ParentSpanId, err = parseJaegerID(zs.ParentID, 8)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid parent id of span %s", zs.ID)
+		}
+	}
+	// only the server side of an RPC is shared, Zipkin clients may leave its kind out
+	if zs.Shared {
+		s.Kind = v1_trace.Span_SPAN_KIND_SERVER
+	}
+
+	if e := zs.RemoteEndpoint; e != nil {
+		if e.ServiceName != "" {
+			s.Attributes = append(s.Attributes, stringAttribute(zipkinAttributePeerName, e.ServiceName))
+		}
+		if ip := zipkinEndpointIP(*e); ip != "" {
+			s.Attributes = append(s.Attributes, stringAttribute(zipkinAttributePeerIP, ip))
+		}
+		if e.Port != 0 {
+			s.Attributes = append(s.Attributes, intAttribute(zipkinAttributePeerPort, int64(e.Port)))
+		}
+	}
+
+	// tags are a map, they are sorted for a stable order of the attributes
+	keys := make([]string, 0, len(zs.Tags))
+	for k := range zs.Tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v := zs.Tags[k]
+		if k == zipkinTagError {
+			s.Status = &v1_trace.Status{Code: v1_trace.Status_STATUS_CODE_ERROR}
+			// instrumentation sets error to true or to the error message
+			if v != "" && v != "true" {
+				s.Status.Message = v
+			}
+			continue
+		}
+		s.Attributes = append(s.Attributes, stringAttribute(k, v))
+	}
+
+	for _, a := range zs.Annotations {
+		s.Events = append(s.Events, &v1_trace.Span_Event{
+			TimeUnixNano: a.Timestamp * 1000,
+			Name:         a.Value,
+		})
+	}
+
+	return s, nil
+}
+
+func zipkinToSpanKind(kind string) v1_trace.Span_SpanKind {
+	switch kind {
+	case "CLIENT":
+		return v1_trace.Span_SPAN_KIND_CLIENT
+	case "SERVER":
+		return v1_trace.Span_SPAN_KIND_SERVER
+	case "PRODUCER":
+		return v1_trace.Span_SPAN_KIND_PRODUCER
+	case "CONSUMER":
+		return v1_trace.Span_SPAN_KIND_CONSUMER
+	default:
+		return v1_trace.Span_SPAN_KIND_UNSPECIFIED
+	}
+}
@@ -0,0 +1,99 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	v1_common "github.com/grafana/tempo/pkg/tempopb/common/v1"
+	v1 "github.com/grafana/tempo/pkg/tempopb/trace/v1"
+)
+
+func TestZipkinToTrace(t *testing.T) {
+	dump := `[
+		{
+			"traceId": "5af7183fb1d4cf5f",
+			"id": "352bff9a74ca9ad2",
+			"name": "get /api",
+			"kind": "CLIENT",
+			"timestamp": 1556604172355737,
+			"duration": 1431,
+			"localEndpoint": {"serviceName": "frontend", "ipv4": "192.168.99.1", "port": 3306},
+			"remoteEndpoint": {"serviceName": "backend", "ipv4": "172.19.0.2", "port": 9000},
+			"annotations": [{"timestamp": 1556604172355800, "value": "ws"}],
+			"tags": {"http.path": "/api", "error": "connection refused"}
+		},
+		{
+			"traceId": "5af7183fb1d4cf5f",
+			"parentId": "6b221d5bc9e6496c",
+			"id": "352bff9a74ca9ad2",
+			"name": "get /api",
+			"timestamp": 1556604172355800,
+			"duration": 1300,
+			"localEndpoint": {"serviceName": "backend", "ipv4": "172.19.0.2", "port": 9000},
+			"tags": {"error": "true"},
+			"shared": true
+		},
+		{
+			"traceId": "5af7183fb1d4cf5f",
+			"id": "6b221d5bc9e6496c",
+			"name": "get",
+			"kind": "SERVER",
+			"timestamp": 1556604172355000,
+			"duration": 2000,
+			"localEndpoint": {"serviceName": "frontend", "ipv4": "192.168.99.1", "port": 3306}
+		}
+	]`
+	var spans []ZipkinSpan
+	require.NoError(t, json.Unmarshal([]byte(dump), &spans))
+
+	trace, err := ZipkinToTrace(spans)
+	require.NoError(t, err)
+
+	// the spans of an endpoint are a batch with the endpoint as resource
+	require.Len(t, trace.Batches, 2)
+	assert.Equal(t, []*v1_common.KeyValue{
+		stringAttribute(serviceNameAttribute, "frontend"),
+		stringAttribute("net.host.ip", "192.168.99.1"),
+		intAttribute("net.host.port", 3306),
+	}, trace.Batches[0].Resource.Attributes)
+	require.Len(t, trace.Batches[0].InstrumentationLibrarySpans[0].Spans, 2)
+	require.Len(t, trace.Batches[1].InstrumentationLibrarySpans[0].Spans, 1)
+
+	client := trace.Batches[0].InstrumentationLibrarySpans[0].Spans[0]
+	assert.Equal(t, []byte{0, 0, 0, 0, 0, 0, 0, 0, 0x5a, 0xf7, 0x18, 0x3f, 0xb1, 0xd4, 0xcf, 0x5f}, client.TraceId)
+	assert.Equal(t, v1.Span_SPAN_KIND_CLIENT, client.Kind)
+	assert.Equal(t, uint64(1556604172355737000), client.StartTimeUnixNano)
+	assert.Equal(t, uint64(1556604172357168000), client.EndTimeUnixNano)
+	assert.Empty(t, client.ParentSpanId)
+	// the remote endpoint and the tags are attributes, the error tag is the status
+	assert.Equal(t, []*v1_common.KeyValue{
+		stringAttribute("peer.service", "backend"),
+		stringAttribute("net.peer.ip", "172.19.0.2"),
+		intAttribute("net.peer.port", 9000),
+		stringAttribute("http.path", "/api"),
+	}, client.Attributes)
+	assert.Equal(t, &v1.Status{Code: v1.Status_STATUS_CODE_ERROR, Message: "connection refused"}, client.Status)
+	assert.Equal(t, []*v1.Span_Event{{TimeUnixNano: 1556604172355800000, Name: "ws"}}, client.Events)
+
+	// the shared span is a server span that keeps the id of the client span for the query-frontend to dedupe
+	shared := trace.Batches[1].InstrumentationLibrarySpans[0].Spans[0]
+	assert.Equal(t, v1.Span_SPAN_KIND_SERVER, shared.Kind)
+	assert.Equal(t, client.SpanId, shared.SpanId)
+	assert.Equal(t, []byte{0x6b, 0x22, 0x1d, 0x5b, 0xc9, 0xe6, 0x49, 0x6c}, shared.ParentSpanId)
+	assert.Equal(t, &v1.Status{Code: v1.Status_STATUS_CODE_ERROR}, shared.Status)
+	assert.Empty(t, shared.Attributes)
+
+	// spans without a local endpoint belong to an unknown service
+	trace, err = ZipkinToTrace([]ZipkinSpan{{TraceID: "01", ID: "02"}})
+	require.NoError(t, err)
+	assert.Equal(t, []*v1_common.KeyValue{stringAttribute(serviceNameAttribute, "unknown_service")}, trace.Batches[0].Resource.Attributes)
+
+	// invalid and too long ids are errors
+	_, err = ZipkinToTrace([]ZipkinSpan{{TraceID: "01", ID: "not hex"}})
+	assert.Error(t, err)
+	_, err = ZipkinToTrace([]ZipkinSpan{{TraceID: "0102030405060708090a0b0c0d0e0f1011", ID: "02"}})
+	assert.Error(t, err)
+}
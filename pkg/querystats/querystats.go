@@ -0,0 +1,183 @@
+package querystats
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// Header holds the json encoded Stats of a query.
+const Header = "X-Tempo-Query-Stats"
+
+// The roles of the backend objects read by a query.
+const (
+	RoleBloom = "bloom"
+	RoleIndex = "index"
+	RoleData  = "data"
+	RoleMeta  = "meta"
+)
+
+type contextKey struct{}
+
+// Stats are the statistics of a single query. Wall times are in milliseconds.
+type Stats struct {
+	BlocksInspected  int64 `json:"blocksInspected"`
+	BloomHits        int64 `json:"bloomHits"`
+	BloomMisses      int64 `json:"bloomMisses"`
+	BytesRead        int64 `json:"bytesRead"`
+	IngestersQueried int64 `json:"ingestersQueried"`
+
+	// BytesRead split by the role of the backend objects read.
+	BloomBytesRead int64 `json:"bloomBytesRead"`
+	IndexBytesRead int64 `json:"indexBytesRead"`
+	DataBytesRead  int64 `json:"dataBytesRead"`
+	MetaBytesRead  int64 `json:"metaBytesRead"`
+
+	IngestersWallTimeMs float64 `json:"ingestersWallTimeMs"`
+	StoreWallTimeMs     float64 `json:"storeWallTimeMs"`
+	CombineWallTimeMs   float64 `json:"combineWallTimeMs"`
+	TotalWallTimeMs     float64 `json:"totalWallTimeMs"`
+}
+
+// Merge adds the stats of another part of the same query, e.g. a shard. Counts are summed. The parts run in parallel
+// so the wall times are the ones of the slowest part.
+func (s *Stats) Merge(other Stats) {
+	s.BlocksInspected += other.BlocksInspected
+	s.BloomHits += other.BloomHits
+	s.BloomMisses += other.BloomMisses
+	s.BytesRead += other.BytesRead
+	s.IngestersQueried += other.IngestersQueried
+	s.BloomBytesRead += other.BloomBytesRead
+	s.IndexBytesRead += other.IndexBytesRead
+	s.DataBytesRead += other.DataBytesRead
+	s.MetaBytesRead += other.MetaBytesRead
+
+	s.IngestersWallTimeMs = max(s.IngestersWallTimeMs, other.IngestersWallTimeMs)
+	s.StoreWallTimeMs = max(s.StoreWallTimeMs, other.StoreWallTimeMs)
+	s.CombineWallTimeMs = max(s.CombineWallTimeMs, other.CombineWallTimeMs)
+	s.TotalWallTimeMs = max(s.TotalWallTimeMs, other.TotalWallTimeMs)
+}
+
+// Encode returns the stats as the value of the Header.
+func (s Stats) Encode() (string, error) {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// Decode parses the value of the Header.
+func Decode(header string) (Stats, error) {
+	s := Stats{}
+	err := json.Unmarshal([]byte(header), &s)
+	return s, err
+}
+
+// Collector accumulates the stats of a query from all the goroutines working on it. All methods of a nil Collector
+// are no-ops so code paths shared with other operations don't need to check for one.
+type Collector struct {
+	mtx   sync.Mutex
+	stats Stats
+}
+
+func NewCollector() *Collector {
+	return &Collector{}
+}
+
+// NewContext returns a context that carries the collector.
+func NewContext(ctx context.Context, c *Collector) context.Context {
+	return context.WithValue(ctx, contextKey{}, c)
+}
+
+// FromContext returns the collector of the context or nil if it has none.
+func FromContext(ctx context.Context) *Collector {
+	c, _ := ctx.Value(contextKey{}).(*Collector)
+	return c
+}
+
+// AddBlockInspected counts a block searched for the trace and whether its bloom filter matched.
+func (c *Collector) AddBlockInspected(bloomHit bool) {
+	c.update(func(s *Stats) {
+		s.BlocksInspected++
+		if bloomHit {
+			s.BloomHits++
+		} else {
+			s.BloomMisses++
+		}
+	})
+}
+
+func (c *Collector) AddBytesRead(n int) {
+	c.update(func(s *Stats) { s.BytesRead += int64(n) })
+}
+
+// AddObjectBytesRead counts bytes read from a backend object with one of the roles. Bytes of unknown roles count as
+// data.
+func (c *Collector) AddObjectBytesRead(role string, n int) {
+	c.update(func(s *Stats) {
+		switch role {
+		case RoleBloom:
+			s.BloomBytesRead += int64(n)
+		case RoleIndex:
+			s.IndexBytesRead += int64(n)
+		case RoleMeta:
+			s.MetaBytesRead += int64(n)
+		default:
+			s.DataBytesRead += int64(n)
+		}
+	})
+}
+
+func (c *Collector) AddIngesterQueried() {
+	c.update(func(s *Stats) { s.IngestersQueried++ })
+}
+
+func (c *Collector) ObserveIngesters(d time.Duration) {
+	c.update(func(s *Stats) { s.IngestersWallTimeMs += milliseconds(d) })
+}
+
+func (c *Collector) ObserveStore(d time.Duration) {
+	c.update(func(s *Stats) { s.StoreWallTimeMs += milliseconds(d) })
+}
+
+func (c *Collector) ObserveCombine(d time.Duration) {
+	c.update(func(s *Stats) { s.CombineWallTimeMs += milliseconds(d) })
+}
+
+func (c *Collector) ObserveTotal(d time.Duration) {
+	c.update(func(s *Stats) { s.TotalWallTimeMs += milliseconds(d) })
+}
+
+func (c *Collector) update(f func(s *Stats)) {
+	if c == nil {
+		return
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	f(&c.stats)
+}
+
+// Stats returns the stats collected so far.
+func (c *Collector) Stats() Stats {
+	if c == nil {
+		return Stats{}
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.stats
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+func max(a, b float64) float64 {
+	if a > b {
+		return a
+	}
+	return b
+}
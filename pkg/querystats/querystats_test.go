@@ -0,0 +1,91 @@
+package querystats
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollector(t *testing.T) {
+	c := NewCollector()
+	ctx := NewContext(context.Background(), c)
+	require.Equal(t, c, FromContext(ctx))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			c := FromContext(ctx)
+			c.AddBlockInspected(i%2 == 0)
+			c.AddBytesRead(100)
+			c.AddObjectBytesRead([]string{RoleBloom, RoleIndex, RoleData, RoleMeta, "other"}[i%5], 10)
+			c.AddIngesterQueried()
+		}(i)
+	}
+	wg.Wait()
+
+	c.ObserveIngesters(2 * time.Millisecond)
+	c.ObserveStore(3 * time.Millisecond)
+	c.ObserveCombine(500 * time.Microsecond)
+	c.ObserveTotal(4 * time.Millisecond)
+
+	assert.Equal(t, Stats{
+		BlocksInspected:     10,
+		BloomHits:           5,
+		BloomMisses:         5,
+		BytesRead:           1000,
+		IngestersQueried:    10,
+		BloomBytesRead:      20,
+		IndexBytesRead:      20,
+		DataBytesRead:       40,
+		MetaBytesRead:       20,
+		IngestersWallTimeMs: 2,
+		StoreWallTimeMs:     3,
+		CombineWallTimeMs:   0.5,
+		TotalWallTimeMs:     4,
+	}, c.Stats())
+}
+
+func TestCollectorNotInContext(t *testing.T) {
+	c := FromContext(context.Background())
+	require.Nil(t, c)
+
+	// a nil collector ignores everything
+	c.AddBlockInspected(true)
+	c.AddBytesRead(100)
+	c.AddIngesterQueried()
+	c.ObserveTotal(time.Second)
+	assert.Equal(t, Stats{}, c.Stats())
+}
+
+func TestStatsEncodeMerge(t *testing.T) {
+	a := Stats{BlocksInspected: 1, BytesRead: 10, IndexBytesRead: 4, DataBytesRead: 6, StoreWallTimeMs: 5, TotalWallTimeMs: 5}
+	b := Stats{BlocksInspected: 2, BytesRead: 3, BloomBytesRead: 1, DataBytesRead: 2, IngestersQueried: 3, IngestersWallTimeMs: 1, TotalWallTimeMs: 2}
+
+	h, err := a.Encode()
+	require.NoError(t, err)
+	decoded, err := Decode(h)
+	require.NoError(t, err)
+	assert.Equal(t, a, decoded)
+
+	decoded.Merge(b)
+	assert.Equal(t, Stats{
+		BlocksInspected:     3,
+		BytesRead:           13,
+		BloomBytesRead:      1,
+		IndexBytesRead:      4,
+		DataBytesRead:       8,
+		IngestersQueried:    3,
+		IngestersWallTimeMs: 1,
+		StoreWallTimeMs:     5,
+		TotalWallTimeMs:     5,
+	}, decoded)
+
+	_, err = Decode("{")
+	assert.Error(t, err)
+}
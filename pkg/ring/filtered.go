@@ -0,0 +1,47 @@
+package ring
+
+import (
+	"context"
+
+	"github.com/cortexproject/cortex/pkg/ring"
+	"github.com/grafana/dskit/kv"
+)
+
+// filteredKV wraps the kv client of a ring and removes the instances that aren't included from the ring descs it
+// reads. The descs of the wrapped client are shared with other rings and never modified.
+type filteredKV struct {
+	kv.Client
+
+	include func(ring.InstanceDesc) bool
+}
+
+// Get is called by the ring on startup.
+func (f *filteredKV) Get(ctx context.Context, key string) (interface{}, error) {
+	value, err := f.Client.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return f.filter(value), nil
+}
+
+// WatchKey is called by the ring for its lifetime.
+func (f *filteredKV) WatchKey(ctx context.Context, key string, fn func(interface{}) bool) {
+	f.Client.WatchKey(ctx, key, func(value interface{}) bool {
+		return fn(f.filter(value))
+	})
+}
+
+func (f *filteredKV) filter(value interface{}) interface{} {
+	desc, ok := value.(*ring.Desc)
+	if !ok || desc == nil {
+		return value
+	}
+
+	filtered := ring.NewDesc()
+	for id, instance := range desc.Ingesters {
+		if f.include(instance) {
+			filtered.Ingesters[id] = instance
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,129 @@
+package ring
+
+import (
+	"context"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/ring"
+	"github.com/cortexproject/cortex/pkg/util/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/grafana/dskit/kv"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/atomic"
+)
+
+var (
+	metricKVStoreUnreachableSeconds = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "tempo",
+		Name:      "ring_kv_store_unreachable_seconds",
+		Help:      "The time since the kv store of the ring was last reachable. 0 if it is reachable.",
+	}, []string{"name"})
+)
+
+// kvOutageTracker wraps the kv client of a ring and tracks when the kv store was last reachable. While the kv store
+// is unreachable the ring keeps the last known ring state but the heartbeats of the instances in it go stale.
+type kvOutageTracker struct {
+	kv.Client
+
+	name        string
+	key         string
+	probePeriod time.Duration
+	gracePeriod time.Duration
+
+	lastReachable *atomic.Int64 // unix nanos
+	unreachable   *atomic.Bool
+	now           func() time.Time // for testing
+}
+
+func newKVOutageTracker(client kv.Client, name, key string, probePeriod, gracePeriod time.Duration) *kvOutageTracker {
+	return &kvOutageTracker{
+		Client:        client,
+		name:          name,
+		key:           key,
+		probePeriod:   probePeriod,
+		gracePeriod:   gracePeriod,
+		lastReachable: atomic.NewInt64(time.Now().UnixNano()),
+		unreachable:   atomic.NewBool(false),
+		now:           time.Now,
+	}
+}
+
+// Get is called by the ring on startup and by the probe.
+func (t *kvOutageTracker) Get(ctx context.Context, key string) (interface{}, error) {
+	value, err := t.Client.Get(ctx, key)
+	t.record(err)
+	return value, err
+}
+
+// WatchKey is called by the ring for its lifetime. The kv store is probed until ctx is done.
+func (t *kvOutageTracker) WatchKey(ctx context.Context, key string, f func(interface{}) bool) {
+	go t.probe(ctx)
+	t.Client.WatchKey(ctx, key, f)
+}
+
+func (t *kvOutageTracker) probe(ctx context.Context) {
+	ticker := time.NewTicker(t.probePeriod)
+	defer ticker.Stop()
+
+	expired := false
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			metricKVStoreUnreachableSeconds.DeleteLabelValues(t.name)
+			return
+		}
+
+		probeCtx, cancel := context.WithTimeout(ctx, t.probePeriod)
+		_, _ = t.Get(probeCtx, t.key)
+		cancel()
+
+		outage := t.outage()
+		metricKVStoreUnreachableSeconds.WithLabelValues(t.name).Set(outage.Seconds())
+		if outage > t.gracePeriod && !expired {
+			level.Error(log.Logger).Log("msg", "kv store unreachable for longer than the grace period. instances with stale heartbeats are no longer used", "ring", t.name, "unreachable", outage)
+		}
+		expired = outage > t.gracePeriod
+	}
+}
+
+func (t *kvOutageTracker) record(err error) {
+	if err == nil {
+		t.lastReachable.Store(t.now().UnixNano())
+		if t.unreachable.CAS(true, false) {
+			level.Info(log.Logger).Log("msg", "kv store reachable again", "ring", t.name)
+		}
+		return
+	}
+
+	if t.unreachable.CAS(false, true) {
+		level.Warn(log.Logger).Log("msg", "kv store unreachable. routing with the last known ring until the grace period expires", "ring", t.name, "grace_period", t.gracePeriod, "err", err)
+	}
+}
+
+// outage returns how long the kv store has been unreachable, 0 if it is reachable.
+func (t *kvOutageTracker) outage() time.Duration {
+	if !t.unreachable.Load() {
+		return 0
+	}
+	return t.now().Sub(time.Unix(0, t.lastReachable.Load()))
+}
+
+// kvOutageStrategy extends the heartbeat timeout of the wrapped strategy by the duration of a kv store outage for up
+// to the grace period. Instances that were healthy when the kv store was last reachable keep receiving requests
+// instead of being dropped from the ring because their heartbeats can't be read. Once the grace period expires the
+// heartbeat timeout applies again.
+type kvOutageStrategy struct {
+	ring.ReplicationStrategy
+	tracker *kvOutageTracker
+}
+
+func (s *kvOutageStrategy) Filter(instances []ring.InstanceDesc, op ring.Operation, replicationFactor int, heartbeatTimeout time.Duration, zoneAwarenessEnabled bool) ([]ring.InstanceDesc, int, error) {
+	// a heartbeat timeout of 0 disables it
+	if outage := s.tracker.outage(); heartbeatTimeout > 0 && outage > 0 && outage <= s.tracker.gracePeriod {
+		heartbeatTimeout += outage
+	}
+
+	return s.ReplicationStrategy.Filter(instances, op, replicationFactor, heartbeatTimeout, zoneAwarenessEnabled)
+}
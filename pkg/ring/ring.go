@@ -12,15 +12,31 @@ import (
 
 // New creates a new distributed consistent hash ring.  It shadows the cortex
 // ring.New method so we can use our own replication strategy for repl factor = 2
-func New(cfg ring.Config, name, key string, reg prometheus.Registerer) (*ring.Ring, error) {
-	if cfg.ReplicationFactor == 2 {
-		return newEventuallyConsistentRing(cfg, name, key, reg)
+// and keep using the last known ring for up to kvOutageGracePeriod while the kv store
+// is unreachable. A kvOutageGracePeriod of 0 disables this.
+func New(cfg ring.Config, name, key string, kvOutageGracePeriod time.Duration, reg prometheus.Registerer) (*ring.Ring, error) {
+	if cfg.ReplicationFactor != 2 && (kvOutageGracePeriod <= 0 || cfg.HeartbeatTimeout <= 0) {
+		return ring.New(cfg, name, key, reg)
 	}
 
-	return ring.New(cfg, name, key, reg)
+	return newRing(cfg, name, key, kvOutageGracePeriod, nil, reg)
 }
 
-func newEventuallyConsistentRing(cfg ring.Config, name, key string, reg prometheus.Registerer) (*ring.Ring, error) {
+// NewFiltered creates a ring like New that only has the instances of the ring at key for which include returns true.
+// Keys are placed on their tokens like in the full ring, with the same replication and zone awareness, and the ring
+// is only rebuilt when the instances change. name must differ from the name of the full ring.
+func NewFiltered(cfg ring.Config, name, key string, kvOutageGracePeriod time.Duration, include func(ring.InstanceDesc) bool, reg prometheus.Registerer) (*ring.Ring, error) {
+	return newRing(cfg, name, key, kvOutageGracePeriod, include, reg)
+}
+
+func newRing(cfg ring.Config, name, key string, kvOutageGracePeriod time.Duration, include func(ring.InstanceDesc) bool, reg prometheus.Registerer) (*ring.Ring, error) {
+	var strategy ring.ReplicationStrategy = &EventuallyConsistentStrategy{}
+	if cfg.ReplicationFactor != 2 {
+		strategy = ring.NewDefaultReplicationStrategy()
+		// matches the kv client metrics registered by ring.New
+		reg = prometheus.WrapRegistererWithPrefix("cortex_", reg)
+	}
+
 	codec := ring.GetCodec()
 	// Suffix all client names with "-ring" to denote this kv client is used by the ring
 	store, err := kv.NewClient(
@@ -33,7 +49,25 @@ func newEventuallyConsistentRing(cfg ring.Config, name, key string, reg promethe
 		return nil, err
 	}
 
-	return ring.NewWithStoreClientAndStrategy(cfg, name, key, store, &EventuallyConsistentStrategy{})
+	// the heartbeats of healthy instances go stale after the heartbeat timeout. the kv store is probed often
+	// enough to detect an outage before that.
+	if kvOutageGracePeriod > 0 && cfg.HeartbeatTimeout > 0 {
+		tracker := newKVOutageTracker(store, name, key, cfg.HeartbeatTimeout/4, kvOutageGracePeriod)
+		store = tracker
+		strategy = &kvOutageStrategy{
+			ReplicationStrategy: strategy,
+			tracker:             tracker,
+		}
+	}
+
+	if include != nil {
+		store = &filteredKV{
+			Client:  store,
+			include: include,
+		}
+	}
+
+	return ring.NewWithStoreClientAndStrategy(cfg, name, key, store, strategy)
 }
 
 // EventuallyConsistentStrategy represents a repl strategy with a consistency of 1 on read and
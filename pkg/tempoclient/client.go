@@ -0,0 +1,310 @@
+package tempoclient
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/grafana/dskit/backoff"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+	"github.com/grafana/tempo/pkg/util"
+)
+
+const (
+	orgIDHeader = "X-Scope-OrgID"
+
+	apiPathTraces          = "/api/traces/"
+	apiPathSearch          = "/api/search"
+	apiPathSearchTags      = "/api/search/tags"
+	apiPathSearchTagValues = "/api/search/tag/%s/values"
+
+	urlParamBlockStart  = "blockStart"
+	urlParamBlockEnd    = "blockEnd"
+	urlParamMode        = "mode"
+	urlParamMinDuration = "minDuration"
+	urlParamMaxDuration = "maxDuration"
+	urlParamLimit       = "limit"
+)
+
+// ErrTraceNotFound is returned by TraceByID if Tempo does not have the trace.
+var ErrTraceNotFound = util.ErrTraceNotFound
+
+// Client is a client for the Tempo HTTP API. It is implemented by *HTTPClient and exists so users of the API can be
+// tested with a mock.
+type Client interface {
+	TraceByID(ctx context.Context, id []byte, opts TraceByIDOptions) (*tempopb.Trace, error)
+	Search(ctx context.Context, req *tempopb.SearchRequest) (*tempopb.SearchResponse, error)
+	TagNames(ctx context.Context) (*tempopb.SearchTagsResponse, error)
+	TagValues(ctx context.Context, tagName string) (*tempopb.SearchTagValuesResponse, error)
+}
+
+// TraceByIDOptions restricts where a trace is looked up. The zero value searches everywhere.
+type TraceByIDOptions struct {
+	// BlockStart and BlockEnd limit the backend blocks searched to a range of block IDs.
+	BlockStart string
+	BlockEnd   string
+	// Mode is one of ingesters, blocks or all.
+	Mode string
+}
+
+// Config configures an HTTPClient.
+type Config struct {
+	// Endpoint is the base url of the Tempo API including any http_api_prefix.
+	Endpoint string
+	// OrgID is sent as the X-Scope-OrgID header if set.
+	OrgID string
+	// Timeout applies to every call whose context has no deadline. 0 disables it.
+	Timeout time.Duration
+	// Backoff configures the retries of requests that failed with a 5xx or a transport error. A MaxRetries of 1
+	// disables retries, 0 retries until the context is done.
+	Backoff backoff.Config
+	// JSON requests traces as json instead of protobuf.
+	JSON bool
+	// HTTPClient is used to make requests. Defaults to http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+// DefaultConfig returns a config for the Tempo API at endpoint with a few retries.
+func DefaultConfig(endpoint string) Config {
+	return Config{
+		Endpoint: endpoint,
+		Timeout:  30 * time.Second,
+		Backoff: backoff.Config{
+			MinBackoff: 100 * time.Millisecond,
+			MaxBackoff: 2 * time.Second,
+			MaxRetries: 3,
+		},
+	}
+}
+
+// StatusError is returned for responses that are not successful.
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("tempo responded with %d: %s", e.StatusCode, e.Body)
+}
+
+// HTTPClient calls the Tempo HTTP API.
+type HTTPClient struct {
+	cfg    Config
+	client *http.Client
+}
+
+var _ Client = (*HTTPClient)(nil)
+
+// New returns a client for the Tempo HTTP API.
+func New(cfg Config) *HTTPClient {
+	client := cfg.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return &HTTPClient{
+		cfg:    cfg,
+		client: client,
+	}
+}
+
+// TraceByID returns the trace with the passed id or ErrTraceNotFound.
+func (c *HTTPClient) TraceByID(ctx context.Context, id []byte, opts TraceByIDOptions) (*tempopb.Trace, error) {
+	params := url.Values{}
+	if opts.BlockStart != "" {
+		params.Set(urlParamBlockStart, opts.BlockStart)
+	}
+	if opts.BlockEnd != "" {
+		params.Set(urlParamBlockEnd, opts.BlockEnd)
+	}
+	if opts.Mode != "" {
+		params.Set(urlParamMode, opts.Mode)
+	}
+
+	accept := util.ProtobufTypeHeaderValue
+	if c.cfg.JSON {
+		accept = util.JSONTypeHeaderValue
+	}
+
+	trace := &tempopb.Trace{}
+	err := c.get(ctx, apiPathTraces+util.TraceIDToHexString(id), params, accept, trace)
+	if err != nil {
+		if statusErr, ok := err.(*StatusError); ok && statusErr.StatusCode == http.StatusNotFound {
+			return nil, ErrTraceNotFound
+		}
+		return nil, err
+	}
+
+	return trace, nil
+}
+
+// Search returns the traces matching the request.
+func (c *HTTPClient) Search(ctx context.Context, req *tempopb.SearchRequest) (*tempopb.SearchResponse, error) {
+	params := url.Values{}
+	for k, v := range req.Tags {
+		params.Set(k, v)
+	}
+	if req.MinDurationMs > 0 {
+		params.Set(urlParamMinDuration, (time.Duration(req.MinDurationMs) * time.Millisecond).String())
+	}
+	if req.MaxDurationMs > 0 {
+		params.Set(urlParamMaxDuration, (time.Duration(req.MaxDurationMs) * time.Millisecond).String())
+	}
+	if req.Limit > 0 {
+		params.Set(urlParamLimit, strconv.FormatUint(uint64(req.Limit), 10))
+	}
+
+	resp := &tempopb.SearchResponse{}
+	err := c.get(ctx, apiPathSearch, params, util.JSONTypeHeaderValue, resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// TagNames returns the names of the tags that can be searched.
+func (c *HTTPClient) TagNames(ctx context.Context) (*tempopb.SearchTagsResponse, error) {
+	resp := &tempopb.SearchTagsResponse{}
+	err := c.get(ctx, apiPathSearchTags, nil, util.JSONTypeHeaderValue, resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// TagValues returns the values of a tag.
+func (c *HTTPClient) TagValues(ctx context.Context, tagName string) (*tempopb.SearchTagValuesResponse, error) {
+	resp := &tempopb.SearchTagValuesResponse{}
+	err := c.get(ctx, fmt.Sprintf(apiPathSearchTagValues, url.PathEscape(tagName)), nil, util.JSONTypeHeaderValue, resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// get requests apiPath and decodes the response into m. Requests that fail with a 5xx or a transport error are
+// retried until the backoff gives up or the context is done.
+func (c *HTTPClient) get(ctx context.Context, apiPath string, params url.Values, accept string, m proto.Message) error {
+	if _, ok := ctx.Deadline(); !ok && c.cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.cfg.Timeout)
+		defer cancel()
+	}
+
+	u, err := url.Parse(c.cfg.Endpoint)
+	if err != nil {
+		return fmt.Errorf("invalid tempo endpoint %s: %w", c.cfg.Endpoint, err)
+	}
+	u.Path = path.Join(u.Path, apiPath)
+	u.RawQuery = params.Encode()
+
+	var body []byte
+	b := backoff.New(ctx, c.cfg.Backoff)
+	for b.Ongoing() {
+		body, err = c.do(ctx, u.String(), accept)
+		if !retryable(err) {
+			break
+		}
+		b.Wait()
+	}
+	if err != nil {
+		return err
+	}
+	if body == nil {
+		// the context was done before the first attempt
+		return b.Err()
+	}
+
+	// none of the responses start with '{' when encoded as protobuf so the body is decoded according to its
+	// contents. this covers servers that ignore the accept header
+	if bytes.HasPrefix(body, []byte("{")) {
+		err = (&jsonpb.Unmarshaler{AllowUnknownFields: true}).Unmarshal(bytes.NewReader(body), m)
+		if err != nil {
+			return fmt.Errorf("error decoding %T json: %w", m, err)
+		}
+		return nil
+	}
+
+	err = proto.Unmarshal(body, m)
+	if err != nil {
+		return fmt.Errorf("error decoding %T protobuf: %w", m, err)
+	}
+	return nil
+}
+
+func (c *HTTPClient) do(ctx context.Context, u string, accept string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set(util.AcceptHeaderKey, accept)
+	req.Header.Set("Accept-Encoding", "gzip")
+	if c.cfg.OrgID != "" {
+		req.Header.Set(orgIDHeader, c.cfg.OrgID)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	var reader io.Reader = resp.Body
+	if resp.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			_ = gz.Close()
+		}()
+		reader = gz
+	}
+
+	body, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode/100 != 2 {
+		return nil, &StatusError{
+			StatusCode: resp.StatusCode,
+			Body:       string(bytes.TrimSpace(body)),
+		}
+	}
+
+	return body, nil
+}
+
+// retryable returns true if the request may succeed when sent again.
+func retryable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode/100 == 5
+	}
+
+	// transport errors are retried unless they were caused by the context
+	return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,233 @@
+package tempoclient
+
+import (
+	"compress/gzip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/grafana/dskit/backoff"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+
+	"github.com/grafana/tempo/pkg/tempopb"
+	"github.com/grafana/tempo/pkg/util"
+	"github.com/grafana/tempo/pkg/util/test"
+)
+
+func testConfig(endpoint string) Config {
+	cfg := DefaultConfig(endpoint)
+	cfg.OrgID = "test"
+	cfg.Backoff = backoff.Config{
+		MinBackoff: time.Millisecond,
+		MaxBackoff: time.Millisecond,
+		MaxRetries: 3,
+	}
+	return cfg
+}
+
+func TestTraceByID(t *testing.T) {
+	id := []byte{0x01, 0x02, 0x03, 0x04}
+	expected := test.MakeTrace(5, id)
+
+	tests := []struct {
+		name string
+		json bool
+		gzip bool
+	}{
+		{
+			name: "protobuf",
+		},
+		{
+			name: "json",
+			json: true,
+		},
+		{
+			name: "gzip",
+			gzip: true,
+		},
+		{
+			name: "json and gzip",
+			json: true,
+			gzip: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "/api/traces/"+util.TraceIDToHexString(id), r.URL.Path)
+				assert.Equal(t, "test", r.Header.Get(orgIDHeader))
+				assert.Equal(t, "blocks", r.URL.Query().Get(urlParamMode))
+
+				var body []byte
+				if r.Header.Get(util.AcceptHeaderKey) == util.ProtobufTypeHeaderValue {
+					assert.False(t, tc.json)
+					b, err := proto.Marshal(expected)
+					require.NoError(t, err)
+					body = b
+				} else {
+					assert.True(t, tc.json)
+					s, err := (&jsonpb.Marshaler{}).MarshalToString(expected)
+					require.NoError(t, err)
+					body = []byte(s)
+				}
+
+				if tc.gzip {
+					assert.Equal(t, "gzip", r.Header.Get("Accept-Encoding"))
+					w.Header().Set("Content-Encoding", "gzip")
+					gz := gzip.NewWriter(w)
+					_, _ = gz.Write(body)
+					_ = gz.Close()
+					return
+				}
+				_, _ = w.Write(body)
+			}))
+			defer srv.Close()
+
+			cfg := testConfig(srv.URL)
+			cfg.JSON = tc.json
+
+			actual, err := New(cfg).TraceByID(context.Background(), id, TraceByIDOptions{Mode: "blocks"})
+			require.NoError(t, err)
+			assert.True(t, proto.Equal(expected, actual))
+		})
+	}
+}
+
+func TestTraceByIDNotFound(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "not found", http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	_, err := New(testConfig(srv.URL)).TraceByID(context.Background(), []byte{0x01}, TraceByIDOptions{})
+	assert.Equal(t, ErrTraceNotFound, err)
+}
+
+func TestSearch(t *testing.T) {
+	expected := &tempopb.SearchResponse{
+		Traces: []*tempopb.TraceSearchMetadata{
+			{TraceID: "1234", RootServiceName: "svc", DurationMs: 10},
+		},
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/tempo/api/search":
+			assert.Equal(t, "bar", r.URL.Query().Get("foo"))
+			assert.Equal(t, "1s", r.URL.Query().Get(urlParamMinDuration))
+			assert.Equal(t, "", r.URL.Query().Get(urlParamMaxDuration))
+			assert.Equal(t, "5", r.URL.Query().Get(urlParamLimit))
+			_ = (&jsonpb.Marshaler{}).Marshal(w, expected)
+		case "/tempo/api/search/tags":
+			_ = (&jsonpb.Marshaler{}).Marshal(w, &tempopb.SearchTagsResponse{TagNames: []string{"foo"}})
+		case "/tempo/api/search/tag/foo/values":
+			_ = (&jsonpb.Marshaler{}).Marshal(w, &tempopb.SearchTagValuesResponse{TagValues: []string{"bar"}})
+		default:
+			http.Error(w, "unexpected path", http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+
+	client := New(testConfig(srv.URL + "/tempo"))
+
+	resp, err := client.Search(context.Background(), &tempopb.SearchRequest{
+		Tags:          map[string]string{"foo": "bar"},
+		MinDurationMs: 1000,
+		Limit:         5,
+	})
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(expected, resp))
+
+	tags, err := client.TagNames(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"foo"}, tags.TagNames)
+
+	values, err := client.TagValues(context.Background(), "foo")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"bar"}, values.TagValues)
+}
+
+func TestRetries(t *testing.T) {
+	tests := []struct {
+		name          string
+		failures      int32
+		status        int
+		expectedCalls int32
+		expectedErr   bool
+	}{
+		{
+			name:          "recovers",
+			failures:      2,
+			status:        http.StatusInternalServerError,
+			expectedCalls: 3,
+		},
+		{
+			name:          "gives up",
+			failures:      10,
+			status:        http.StatusServiceUnavailable,
+			expectedCalls: 3,
+			expectedErr:   true,
+		},
+		{
+			name:          "bad request is not retried",
+			failures:      10,
+			status:        http.StatusBadRequest,
+			expectedCalls: 1,
+			expectedErr:   true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			calls := atomic.NewInt32(0)
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if calls.Inc() <= tc.failures {
+					http.Error(w, "failed", tc.status)
+					return
+				}
+				_ = (&jsonpb.Marshaler{}).Marshal(w, &tempopb.SearchTagsResponse{TagNames: []string{"foo"}})
+			}))
+			defer srv.Close()
+
+			resp, err := New(testConfig(srv.URL)).TagNames(context.Background())
+			assert.Equal(t, tc.expectedCalls, calls.Load())
+			if tc.expectedErr {
+				var statusErr *StatusError
+				require.ErrorAs(t, err, &statusErr)
+				assert.Equal(t, tc.status, statusErr.StatusCode)
+				assert.Equal(t, "failed", statusErr.Body)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, []string{"foo"}, resp.TagNames)
+		})
+	}
+}
+
+func TestContextDeadline(t *testing.T) {
+	done := make(chan struct{})
+	defer close(done)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-done:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+
+	cfg := testConfig(srv.URL)
+	cfg.Timeout = 50 * time.Millisecond
+
+	start := time.Now()
+	_, err := New(cfg).TagNames(context.Background())
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
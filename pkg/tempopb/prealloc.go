@@ -10,6 +10,12 @@ var (
 )
 
 // PreallocBytes is a (repeated bytes slices) which preallocs slices on Unmarshal.
+//
+// Ownership: Unmarshal copies the bytes into a slice from bytePool so Slice never aliases the buffer the message was
+// decoded from, which gRPC may reuse as soon as the handler returns. The receiver of the message owns the slices. It
+// may keep them after the handler returns and hands them back to the pool with ReuseTraceBytes once it's done with
+// them. Messages passed in-process transfer ownership the same way: the sender must not read or modify the slices
+// after the call.
 type PreallocBytes struct {
 	Slice []byte
 }
@@ -46,5 +52,11 @@ func ReuseTraceBytes(trace *TraceBytes) {
 
 // SliceFromBytePool gets a slice from the byte pool
 func SliceFromBytePool(size int) []byte {
-	return bytePool.Get(size).([]byte)[:size]
+	b := bytePool.Get(size).([]byte)
+	// slices that did not come from the pool can be returned to it by ReuseTraceBytes and end up in a bucket
+	// larger than their capacity
+	if cap(b) < size {
+		return make([]byte, size)
+	}
+	return b[:size]
 }
@@ -18,6 +18,22 @@ func TestUnmarshal(t *testing.T) {
 	assert.Equal(t, dummyData, preallocReq.Slice)
 }
 
+func TestUnmarshalDoesNotAlias(t *testing.T) {
+	var dummyData = make([]byte, 10)
+	rand.Read(dummyData)
+	expected := append([]byte(nil), dummyData...)
+
+	preallocReq := &PreallocBytes{}
+	err := preallocReq.Unmarshal(dummyData)
+	assert.NoError(t, err)
+
+	// the buffer the message was decoded from is reused
+	for i := range dummyData {
+		dummyData[i] = 0
+	}
+	assert.Equal(t, expected, preallocReq.Slice)
+}
+
 func TestMarshal(t *testing.T) {
 	preallocReq := &PreallocBytes{
 		Slice: make([]byte, 10),
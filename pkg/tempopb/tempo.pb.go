@@ -34,6 +34,8 @@ type TraceByIDRequest struct {
 	BlockStart string `protobuf:"bytes,2,opt,name=blockStart,proto3" json:"blockStart,omitempty"`
 	BlockEnd   string `protobuf:"bytes,3,opt,name=blockEnd,proto3" json:"blockEnd,omitempty"`
 	QueryMode  string `protobuf:"bytes,5,opt,name=queryMode,proto3" json:"queryMode,omitempty"`
+	// If set, traces larger than maxBytes are truncated to their earliest spans
+	MaxBytes uint64 `protobuf:"varint,6,opt,name=maxBytes,proto3" json:"maxBytes,omitempty"`
 }
 
 func (m *TraceByIDRequest) Reset()         { *m = TraceByIDRequest{} }
@@ -97,8 +99,22 @@ func (m *TraceByIDRequest) GetQueryMode() string {
 	return ""
 }
 
+func (m *TraceByIDRequest) GetMaxBytes() uint64 {
+	if m != nil {
+		return m.MaxBytes
+	}
+	return 0
+}
+
 type TraceByIDResponse struct {
 	Trace *Trace `protobuf:"bytes,1,opt,name=trace,proto3" json:"trace,omitempty"`
+	// True if spans were dropped from the trace because it exceeded the maxBytes of the request
+	TraceTruncated bool `protobuf:"varint,2,opt,name=traceTruncated,proto3" json:"traceTruncated,omitempty"`
+	// True if not all backend blocks that may hold the trace were searched because of the max blocks per trace query
+	Partial bool `protobuf:"varint,3,opt,name=partial,proto3" json:"partial,omitempty"`
+	// Describe why the returned trace may not be what was asked for, e.g. that it's the skeleton of a trace deleted by
+	// retention
+	Warnings []string `protobuf:"bytes,4,rep,name=warnings,proto3" json:"warnings,omitempty"`
 }
 
 func (m *TraceByIDResponse) Reset()         { *m = TraceByIDResponse{} }
@@ -141,6 +157,211 @@ func (m *TraceByIDResponse) GetTrace() *Trace {
 	return nil
 }
 
+func (m *TraceByIDResponse) GetTraceTruncated() bool {
+	if m != nil {
+		return m.TraceTruncated
+	}
+	return false
+}
+
+func (m *TraceByIDResponse) GetPartial() bool {
+	if m != nil {
+		return m.Partial
+	}
+	return false
+}
+
+func (m *TraceByIDResponse) GetWarnings() []string {
+	if m != nil {
+		return m.Warnings
+	}
+	return nil
+}
+
+type TraceByIDBatchRequest struct {
+	// hex encoded trace ids
+	TraceIDs []string `protobuf:"bytes,1,rep,name=traceIDs,proto3" json:"traceIDs,omitempty"`
+}
+
+func (m *TraceByIDBatchRequest) Reset()         { *m = TraceByIDBatchRequest{} }
+func (m *TraceByIDBatchRequest) String() string { return proto.CompactTextString(m) }
+func (*TraceByIDBatchRequest) ProtoMessage()    {}
+func (*TraceByIDBatchRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f22805646f4f62b6, []int{2}
+}
+func (m *TraceByIDBatchRequest) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *TraceByIDBatchRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_TraceByIDBatchRequest.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *TraceByIDBatchRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TraceByIDBatchRequest.Merge(m, src)
+}
+func (m *TraceByIDBatchRequest) XXX_Size() int {
+	return m.Size()
+}
+func (m *TraceByIDBatchRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_TraceByIDBatchRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_TraceByIDBatchRequest proto.InternalMessageInfo
+
+func (m *TraceByIDBatchRequest) GetTraceIDs() []string {
+	if m != nil {
+		return m.TraceIDs
+	}
+	return nil
+}
+
+type TraceByIDBatchResult struct {
+	TraceID string `protobuf:"bytes,1,opt,name=traceID,proto3" json:"traceID,omitempty"`
+	// One of found, not_found, failed or size_limit_exceeded
+	Status string `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"`
+	Trace  *Trace `protobuf:"bytes,3,opt,name=trace,proto3" json:"trace,omitempty"`
+	// The reason a lookup failed
+	Error          string   `protobuf:"bytes,4,opt,name=error,proto3" json:"error,omitempty"`
+	TraceTruncated bool     `protobuf:"varint,5,opt,name=traceTruncated,proto3" json:"traceTruncated,omitempty"`
+	Partial        bool     `protobuf:"varint,6,opt,name=partial,proto3" json:"partial,omitempty"`
+	Warnings       []string `protobuf:"bytes,7,rep,name=warnings,proto3" json:"warnings,omitempty"`
+}
+
+func (m *TraceByIDBatchResult) Reset()         { *m = TraceByIDBatchResult{} }
+func (m *TraceByIDBatchResult) String() string { return proto.CompactTextString(m) }
+func (*TraceByIDBatchResult) ProtoMessage()    {}
+func (*TraceByIDBatchResult) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f22805646f4f62b6, []int{3}
+}
+func (m *TraceByIDBatchResult) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *TraceByIDBatchResult) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_TraceByIDBatchResult.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *TraceByIDBatchResult) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TraceByIDBatchResult.Merge(m, src)
+}
+func (m *TraceByIDBatchResult) XXX_Size() int {
+	return m.Size()
+}
+func (m *TraceByIDBatchResult) XXX_DiscardUnknown() {
+	xxx_messageInfo_TraceByIDBatchResult.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_TraceByIDBatchResult proto.InternalMessageInfo
+
+func (m *TraceByIDBatchResult) GetTraceID() string {
+	if m != nil {
+		return m.TraceID
+	}
+	return ""
+}
+
+func (m *TraceByIDBatchResult) GetStatus() string {
+	if m != nil {
+		return m.Status
+	}
+	return ""
+}
+
+func (m *TraceByIDBatchResult) GetTrace() *Trace {
+	if m != nil {
+		return m.Trace
+	}
+	return nil
+}
+
+func (m *TraceByIDBatchResult) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
+func (m *TraceByIDBatchResult) GetTraceTruncated() bool {
+	if m != nil {
+		return m.TraceTruncated
+	}
+	return false
+}
+
+func (m *TraceByIDBatchResult) GetPartial() bool {
+	if m != nil {
+		return m.Partial
+	}
+	return false
+}
+
+func (m *TraceByIDBatchResult) GetWarnings() []string {
+	if m != nil {
+		return m.Warnings
+	}
+	return nil
+}
+
+type TraceByIDBatchResponse struct {
+	// One result per distinct trace id of the request
+	Results []*TraceByIDBatchResult `protobuf:"bytes,1,rep,name=results,proto3" json:"results,omitempty"`
+}
+
+func (m *TraceByIDBatchResponse) Reset()         { *m = TraceByIDBatchResponse{} }
+func (m *TraceByIDBatchResponse) String() string { return proto.CompactTextString(m) }
+func (*TraceByIDBatchResponse) ProtoMessage()    {}
+func (*TraceByIDBatchResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f22805646f4f62b6, []int{4}
+}
+func (m *TraceByIDBatchResponse) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *TraceByIDBatchResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_TraceByIDBatchResponse.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *TraceByIDBatchResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TraceByIDBatchResponse.Merge(m, src)
+}
+func (m *TraceByIDBatchResponse) XXX_Size() int {
+	return m.Size()
+}
+func (m *TraceByIDBatchResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_TraceByIDBatchResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_TraceByIDBatchResponse proto.InternalMessageInfo
+
+func (m *TraceByIDBatchResponse) GetResults() []*TraceByIDBatchResult {
+	if m != nil {
+		return m.Results
+	}
+	return nil
+}
+
 type SearchRequest struct {
 	// case insensitive partial match
 	Tags          map[string]string `protobuf:"bytes,1,rep,name=Tags,proto3" json:"Tags" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
@@ -153,7 +374,7 @@ func (m *SearchRequest) Reset()         { *m = SearchRequest{} }
 func (m *SearchRequest) String() string { return proto.CompactTextString(m) }
 func (*SearchRequest) ProtoMessage()    {}
 func (*SearchRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_f22805646f4f62b6, []int{2}
+	return fileDescriptor_f22805646f4f62b6, []int{5}
 }
 func (m *SearchRequest) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -219,7 +440,7 @@ func (m *SearchResponse) Reset()         { *m = SearchResponse{} }
 func (m *SearchResponse) String() string { return proto.CompactTextString(m) }
 func (*SearchResponse) ProtoMessage()    {}
 func (*SearchResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_f22805646f4f62b6, []int{3}
+	return fileDescriptor_f22805646f4f62b6, []int{6}
 }
 func (m *SearchResponse) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -274,7 +495,7 @@ func (m *TraceSearchMetadata) Reset()         { *m = TraceSearchMetadata{} }
 func (m *TraceSearchMetadata) String() string { return proto.CompactTextString(m) }
 func (*TraceSearchMetadata) ProtoMessage()    {}
 func (*TraceSearchMetadata) Descriptor() ([]byte, []int) {
-	return fileDescriptor_f22805646f4f62b6, []int{4}
+	return fileDescriptor_f22805646f4f62b6, []int{7}
 }
 func (m *TraceSearchMetadata) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -349,7 +570,7 @@ func (m *SearchMetrics) Reset()         { *m = SearchMetrics{} }
 func (m *SearchMetrics) String() string { return proto.CompactTextString(m) }
 func (*SearchMetrics) ProtoMessage()    {}
 func (*SearchMetrics) Descriptor() ([]byte, []int) {
-	return fileDescriptor_f22805646f4f62b6, []int{5}
+	return fileDescriptor_f22805646f4f62b6, []int{8}
 }
 func (m *SearchMetrics) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -413,7 +634,7 @@ func (m *SearchTagsRequest) Reset()         { *m = SearchTagsRequest{} }
 func (m *SearchTagsRequest) String() string { return proto.CompactTextString(m) }
 func (*SearchTagsRequest) ProtoMessage()    {}
 func (*SearchTagsRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_f22805646f4f62b6, []int{6}
+	return fileDescriptor_f22805646f4f62b6, []int{9}
 }
 func (m *SearchTagsRequest) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -450,7 +671,7 @@ func (m *SearchTagsResponse) Reset()         { *m = SearchTagsResponse{} }
 func (m *SearchTagsResponse) String() string { return proto.CompactTextString(m) }
 func (*SearchTagsResponse) ProtoMessage()    {}
 func (*SearchTagsResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_f22805646f4f62b6, []int{7}
+	return fileDescriptor_f22805646f4f62b6, []int{10}
 }
 func (m *SearchTagsResponse) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -494,7 +715,7 @@ func (m *SearchTagValuesRequest) Reset()         { *m = SearchTagValuesRequest{}
 func (m *SearchTagValuesRequest) String() string { return proto.CompactTextString(m) }
 func (*SearchTagValuesRequest) ProtoMessage()    {}
 func (*SearchTagValuesRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_f22805646f4f62b6, []int{8}
+	return fileDescriptor_f22805646f4f62b6, []int{11}
 }
 func (m *SearchTagValuesRequest) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -538,7 +759,7 @@ func (m *SearchTagValuesResponse) Reset()         { *m = SearchTagValuesResponse
 func (m *SearchTagValuesResponse) String() string { return proto.CompactTextString(m) }
 func (*SearchTagValuesResponse) ProtoMessage()    {}
 func (*SearchTagValuesResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_f22805646f4f62b6, []int{9}
+	return fileDescriptor_f22805646f4f62b6, []int{12}
 }
 func (m *SearchTagValuesResponse) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -574,22 +795,21 @@ func (m *SearchTagValuesResponse) GetTagValues() []string {
 	return nil
 }
 
-type Trace struct {
-	Batches []*v1.ResourceSpans `protobuf:"bytes,1,rep,name=batches,proto3" json:"batches,omitempty"`
+type LiveTraceStatsRequest struct {
 }
 
-func (m *Trace) Reset()         { *m = Trace{} }
-func (m *Trace) String() string { return proto.CompactTextString(m) }
-func (*Trace) ProtoMessage()    {}
-func (*Trace) Descriptor() ([]byte, []int) {
-	return fileDescriptor_f22805646f4f62b6, []int{10}
+func (m *LiveTraceStatsRequest) Reset()         { *m = LiveTraceStatsRequest{} }
+func (m *LiveTraceStatsRequest) String() string { return proto.CompactTextString(m) }
+func (*LiveTraceStatsRequest) ProtoMessage()    {}
+func (*LiveTraceStatsRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f22805646f4f62b6, []int{13}
 }
-func (m *Trace) XXX_Unmarshal(b []byte) error {
+func (m *LiveTraceStatsRequest) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
 }
-func (m *Trace) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+func (m *LiveTraceStatsRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
 	if deterministic {
-		return xxx_messageInfo_Trace.Marshal(b, m, deterministic)
+		return xxx_messageInfo_LiveTraceStatsRequest.Marshal(b, m, deterministic)
 	} else {
 		b = b[:cap(b)]
 		n, err := m.MarshalToSizedBuffer(b)
@@ -599,42 +819,34 @@ func (m *Trace) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
 		return b[:n], nil
 	}
 }
-func (m *Trace) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_Trace.Merge(m, src)
+func (m *LiveTraceStatsRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_LiveTraceStatsRequest.Merge(m, src)
 }
-func (m *Trace) XXX_Size() int {
+func (m *LiveTraceStatsRequest) XXX_Size() int {
 	return m.Size()
 }
-func (m *Trace) XXX_DiscardUnknown() {
-	xxx_messageInfo_Trace.DiscardUnknown(m)
+func (m *LiveTraceStatsRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_LiveTraceStatsRequest.DiscardUnknown(m)
 }
 
-var xxx_messageInfo_Trace proto.InternalMessageInfo
-
-func (m *Trace) GetBatches() []*v1.ResourceSpans {
-	if m != nil {
-		return m.Batches
-	}
-	return nil
-}
+var xxx_messageInfo_LiveTraceStatsRequest proto.InternalMessageInfo
 
-// Write
-type PushRequest struct {
-	Batch *v1.ResourceSpans `protobuf:"bytes,1,opt,name=batch,proto3" json:"batch,omitempty"`
+type LiveTraceStatsResponse struct {
+	Tenants []*TenantLiveTraceStats `protobuf:"bytes,1,rep,name=tenants,proto3" json:"tenants,omitempty"`
 }
 
-func (m *PushRequest) Reset()         { *m = PushRequest{} }
-func (m *PushRequest) String() string { return proto.CompactTextString(m) }
-func (*PushRequest) ProtoMessage()    {}
-func (*PushRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_f22805646f4f62b6, []int{11}
+func (m *LiveTraceStatsResponse) Reset()         { *m = LiveTraceStatsResponse{} }
+func (m *LiveTraceStatsResponse) String() string { return proto.CompactTextString(m) }
+func (*LiveTraceStatsResponse) ProtoMessage()    {}
+func (*LiveTraceStatsResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f22805646f4f62b6, []int{14}
 }
-func (m *PushRequest) XXX_Unmarshal(b []byte) error {
+func (m *LiveTraceStatsResponse) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
 }
-func (m *PushRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+func (m *LiveTraceStatsResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
 	if deterministic {
-		return xxx_messageInfo_PushRequest.Marshal(b, m, deterministic)
+		return xxx_messageInfo_LiveTraceStatsResponse.Marshal(b, m, deterministic)
 	} else {
 		b = b[:cap(b)]
 		n, err := m.MarshalToSizedBuffer(b)
@@ -644,40 +856,43 @@ func (m *PushRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error)
 		return b[:n], nil
 	}
 }
-func (m *PushRequest) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_PushRequest.Merge(m, src)
+func (m *LiveTraceStatsResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_LiveTraceStatsResponse.Merge(m, src)
 }
-func (m *PushRequest) XXX_Size() int {
+func (m *LiveTraceStatsResponse) XXX_Size() int {
 	return m.Size()
 }
-func (m *PushRequest) XXX_DiscardUnknown() {
-	xxx_messageInfo_PushRequest.DiscardUnknown(m)
+func (m *LiveTraceStatsResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_LiveTraceStatsResponse.DiscardUnknown(m)
 }
 
-var xxx_messageInfo_PushRequest proto.InternalMessageInfo
+var xxx_messageInfo_LiveTraceStatsResponse proto.InternalMessageInfo
 
-func (m *PushRequest) GetBatch() *v1.ResourceSpans {
+func (m *LiveTraceStatsResponse) GetTenants() []*TenantLiveTraceStats {
 	if m != nil {
-		return m.Batch
+		return m.Tenants
 	}
 	return nil
 }
 
-type PushResponse struct {
+type TenantLiveTraceStats struct {
+	TenantID       string `protobuf:"bytes,1,opt,name=tenantID,proto3" json:"tenantID,omitempty"`
+	LiveTraces     uint64 `protobuf:"varint,2,opt,name=liveTraces,proto3" json:"liveTraces,omitempty"`
+	LiveTraceBytes uint64 `protobuf:"varint,3,opt,name=liveTraceBytes,proto3" json:"liveTraceBytes,omitempty"`
 }
 
-func (m *PushResponse) Reset()         { *m = PushResponse{} }
-func (m *PushResponse) String() string { return proto.CompactTextString(m) }
-func (*PushResponse) ProtoMessage()    {}
-func (*PushResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_f22805646f4f62b6, []int{12}
+func (m *TenantLiveTraceStats) Reset()         { *m = TenantLiveTraceStats{} }
+func (m *TenantLiveTraceStats) String() string { return proto.CompactTextString(m) }
+func (*TenantLiveTraceStats) ProtoMessage()    {}
+func (*TenantLiveTraceStats) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f22805646f4f62b6, []int{15}
 }
-func (m *PushResponse) XXX_Unmarshal(b []byte) error {
+func (m *TenantLiveTraceStats) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
 }
-func (m *PushResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+func (m *TenantLiveTraceStats) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
 	if deterministic {
-		return xxx_messageInfo_PushResponse.Marshal(b, m, deterministic)
+		return xxx_messageInfo_TenantLiveTraceStats.Marshal(b, m, deterministic)
 	} else {
 		b = b[:cap(b)]
 		n, err := m.MarshalToSizedBuffer(b)
@@ -687,41 +902,56 @@ func (m *PushResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error)
 		return b[:n], nil
 	}
 }
-func (m *PushResponse) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_PushResponse.Merge(m, src)
+func (m *TenantLiveTraceStats) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TenantLiveTraceStats.Merge(m, src)
 }
-func (m *PushResponse) XXX_Size() int {
+func (m *TenantLiveTraceStats) XXX_Size() int {
 	return m.Size()
 }
-func (m *PushResponse) XXX_DiscardUnknown() {
-	xxx_messageInfo_PushResponse.DiscardUnknown(m)
+func (m *TenantLiveTraceStats) XXX_DiscardUnknown() {
+	xxx_messageInfo_TenantLiveTraceStats.DiscardUnknown(m)
 }
 
-var xxx_messageInfo_PushResponse proto.InternalMessageInfo
+var xxx_messageInfo_TenantLiveTraceStats proto.InternalMessageInfo
 
-type PushBytesRequest struct {
-	// pre-marshalled PushRequests
-	Requests []PreallocBytes `protobuf:"bytes,1,rep,name=requests,proto3,customtype=PreallocBytes" json:"requests"` // Deprecated: Do not use.
-	// pre-marshalled Traces. length must match ids
-	Traces []PreallocBytes `protobuf:"bytes,2,rep,name=traces,proto3,customtype=PreallocBytes" json:"traces"`
-	// trace ids. length must match traces
-	Ids []PreallocBytes `protobuf:"bytes,3,rep,name=ids,proto3,customtype=PreallocBytes" json:"ids"`
-	// search data, length must match traces
-	SearchData []PreallocBytes `protobuf:"bytes,4,rep,name=searchData,proto3,customtype=PreallocBytes" json:"searchData"`
+func (m *TenantLiveTraceStats) GetTenantID() string {
+	if m != nil {
+		return m.TenantID
+	}
+	return ""
 }
 
-func (m *PushBytesRequest) Reset()         { *m = PushBytesRequest{} }
-func (m *PushBytesRequest) String() string { return proto.CompactTextString(m) }
-func (*PushBytesRequest) ProtoMessage()    {}
-func (*PushBytesRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_f22805646f4f62b6, []int{13}
+func (m *TenantLiveTraceStats) GetLiveTraces() uint64 {
+	if m != nil {
+		return m.LiveTraces
+	}
+	return 0
 }
-func (m *PushBytesRequest) XXX_Unmarshal(b []byte) error {
+
+func (m *TenantLiveTraceStats) GetLiveTraceBytes() uint64 {
+	if m != nil {
+		return m.LiveTraceBytes
+	}
+	return 0
+}
+
+// Deletes a trace of the tenant of the request that has not been flushed to the backend yet
+type DeleteTraceRequest struct {
+	TraceID []byte `protobuf:"bytes,1,opt,name=traceID,proto3" json:"traceID,omitempty"`
+}
+
+func (m *DeleteTraceRequest) Reset()         { *m = DeleteTraceRequest{} }
+func (m *DeleteTraceRequest) String() string { return proto.CompactTextString(m) }
+func (*DeleteTraceRequest) ProtoMessage()    {}
+func (*DeleteTraceRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f22805646f4f62b6, []int{16}
+}
+func (m *DeleteTraceRequest) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
 }
-func (m *PushBytesRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+func (m *DeleteTraceRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
 	if deterministic {
-		return xxx_messageInfo_PushBytesRequest.Marshal(b, m, deterministic)
+		return xxx_messageInfo_DeleteTraceRequest.Marshal(b, m, deterministic)
 	} else {
 		b = b[:cap(b)]
 		n, err := m.MarshalToSizedBuffer(b)
@@ -731,35 +961,42 @@ func (m *PushBytesRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, er
 		return b[:n], nil
 	}
 }
-func (m *PushBytesRequest) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_PushBytesRequest.Merge(m, src)
+func (m *DeleteTraceRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DeleteTraceRequest.Merge(m, src)
 }
-func (m *PushBytesRequest) XXX_Size() int {
+func (m *DeleteTraceRequest) XXX_Size() int {
 	return m.Size()
 }
-func (m *PushBytesRequest) XXX_DiscardUnknown() {
-	xxx_messageInfo_PushBytesRequest.DiscardUnknown(m)
+func (m *DeleteTraceRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_DeleteTraceRequest.DiscardUnknown(m)
 }
 
-var xxx_messageInfo_PushBytesRequest proto.InternalMessageInfo
+var xxx_messageInfo_DeleteTraceRequest proto.InternalMessageInfo
 
-type TraceBytes struct {
-	// pre-marshalled Traces
-	Traces [][]byte `protobuf:"bytes,1,rep,name=traces,proto3" json:"traces,omitempty"`
+func (m *DeleteTraceRequest) GetTraceID() []byte {
+	if m != nil {
+		return m.TraceID
+	}
+	return nil
 }
 
-func (m *TraceBytes) Reset()         { *m = TraceBytes{} }
-func (m *TraceBytes) String() string { return proto.CompactTextString(m) }
-func (*TraceBytes) ProtoMessage()    {}
-func (*TraceBytes) Descriptor() ([]byte, []int) {
-	return fileDescriptor_f22805646f4f62b6, []int{14}
+type DeleteTraceResponse struct {
+	// The number of spans removed. 0 if the trace was not found or already deleted
+	SpansRemoved uint64 `protobuf:"varint,1,opt,name=spansRemoved,proto3" json:"spansRemoved,omitempty"`
 }
-func (m *TraceBytes) XXX_Unmarshal(b []byte) error {
-	return m.Unmarshal(b)
+
+func (m *DeleteTraceResponse) Reset()         { *m = DeleteTraceResponse{} }
+func (m *DeleteTraceResponse) String() string { return proto.CompactTextString(m) }
+func (*DeleteTraceResponse) ProtoMessage()    {}
+func (*DeleteTraceResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f22805646f4f62b6, []int{17}
 }
-func (m *TraceBytes) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+func (m *DeleteTraceResponse) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *DeleteTraceResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
 	if deterministic {
-		return xxx_messageInfo_TraceBytes.Marshal(b, m, deterministic)
+		return xxx_messageInfo_DeleteTraceResponse.Marshal(b, m, deterministic)
 	} else {
 		b = b[:cap(b)]
 		n, err := m.MarshalToSizedBuffer(b)
@@ -769,881 +1006,896 @@ func (m *TraceBytes) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
 		return b[:n], nil
 	}
 }
-func (m *TraceBytes) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_TraceBytes.Merge(m, src)
+func (m *DeleteTraceResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DeleteTraceResponse.Merge(m, src)
 }
-func (m *TraceBytes) XXX_Size() int {
+func (m *DeleteTraceResponse) XXX_Size() int {
 	return m.Size()
 }
-func (m *TraceBytes) XXX_DiscardUnknown() {
-	xxx_messageInfo_TraceBytes.DiscardUnknown(m)
+func (m *DeleteTraceResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_DeleteTraceResponse.DiscardUnknown(m)
 }
 
-var xxx_messageInfo_TraceBytes proto.InternalMessageInfo
+var xxx_messageInfo_DeleteTraceResponse proto.InternalMessageInfo
 
-func (m *TraceBytes) GetTraces() [][]byte {
+func (m *DeleteTraceResponse) GetSpansRemoved() uint64 {
 	if m != nil {
-		return m.Traces
+		return m.SpansRemoved
 	}
-	return nil
+	return 0
 }
 
-func init() {
-	proto.RegisterType((*TraceByIDRequest)(nil), "tempopb.TraceByIDRequest")
-	proto.RegisterType((*TraceByIDResponse)(nil), "tempopb.TraceByIDResponse")
-	proto.RegisterType((*SearchRequest)(nil), "tempopb.SearchRequest")
-	proto.RegisterMapType((map[string]string)(nil), "tempopb.SearchRequest.TagsEntry")
-	proto.RegisterType((*SearchResponse)(nil), "tempopb.SearchResponse")
-	proto.RegisterType((*TraceSearchMetadata)(nil), "tempopb.TraceSearchMetadata")
-	proto.RegisterType((*SearchMetrics)(nil), "tempopb.SearchMetrics")
-	proto.RegisterType((*SearchTagsRequest)(nil), "tempopb.SearchTagsRequest")
-	proto.RegisterType((*SearchTagsResponse)(nil), "tempopb.SearchTagsResponse")
-	proto.RegisterType((*SearchTagValuesRequest)(nil), "tempopb.SearchTagValuesRequest")
-	proto.RegisterType((*SearchTagValuesResponse)(nil), "tempopb.SearchTagValuesResponse")
-	proto.RegisterType((*Trace)(nil), "tempopb.Trace")
-	proto.RegisterType((*PushRequest)(nil), "tempopb.PushRequest")
-	proto.RegisterType((*PushResponse)(nil), "tempopb.PushResponse")
-	proto.RegisterType((*PushBytesRequest)(nil), "tempopb.PushBytesRequest")
-	proto.RegisterType((*TraceBytes)(nil), "tempopb.TraceBytes")
+type Trace struct {
+	Batches []*v1.ResourceSpans `protobuf:"bytes,1,rep,name=batches,proto3" json:"batches,omitempty"`
+	// time the trace was first received by a distributor. combined traces keep the earliest time
+	IngestionTimeUnixNano uint64 `protobuf:"varint,2,opt,name=ingestionTimeUnixNano,proto3" json:"ingestionTimeUnixNano,omitempty"`
 }
 
-func init() { proto.RegisterFile("pkg/tempopb/tempo.proto", fileDescriptor_f22805646f4f62b6) }
-
-var fileDescriptor_f22805646f4f62b6 = []byte{
-	// 890 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x84, 0x55, 0x41, 0x8f, 0xda, 0x46,
-	0x14, 0xc6, 0x0b, 0x2c, 0xeb, 0xb7, 0xcb, 0x66, 0x77, 0xb2, 0x01, 0xd7, 0x5d, 0x01, 0xb2, 0x56,
-	0x2d, 0x87, 0x06, 0x12, 0xd2, 0x55, 0x9a, 0xf4, 0x50, 0x09, 0x91, 0xb6, 0x91, 0x4a, 0x94, 0x1a,
-	0x9a, 0xfb, 0x60, 0xa6, 0xac, 0x05, 0xd8, 0xce, 0x78, 0x8c, 0x96, 0x5b, 0x4f, 0x3d, 0xf7, 0xaf,
-	0xe4, 0x5f, 0xe4, 0x52, 0x29, 0xa7, 0xaa, 0xea, 0x21, 0xaa, 0x76, 0xa5, 0xfe, 0x8e, 0x6a, 0x66,
-	0xec, 0xc1, 0x36, 0x24, 0x39, 0xe1, 0xf7, 0xbd, 0xef, 0x3d, 0xcf, 0xfb, 0xfc, 0xbd, 0x01, 0xea,
-	0xc1, 0x7c, 0xd6, 0x65, 0x64, 0x19, 0xf8, 0xc1, 0x44, 0xfe, 0x76, 0x02, 0xea, 0x33, 0x1f, 0x55,
-	0x62, 0xd0, 0x3c, 0x63, 0x14, 0x3b, 0xa4, 0xbb, 0x7a, 0xd8, 0x15, 0x0f, 0x32, 0x6d, 0xde, 0x9f,
-	0xb9, 0xec, 0x2a, 0x9a, 0x74, 0x1c, 0x7f, 0xd9, 0x9d, 0xf9, 0x33, 0xbf, 0x2b, 0xe0, 0x49, 0xf4,
-	0xab, 0x88, 0x44, 0x20, 0x9e, 0x24, 0xdd, 0xfa, 0x5d, 0x83, 0x93, 0x31, 0x2f, 0xef, 0xaf, 0x9f,
-	0x0f, 0x6c, 0xf2, 0x3a, 0x22, 0x21, 0x43, 0x06, 0x54, 0x44, 0xcb, 0xe7, 0x03, 0x43, 0x6b, 0x69,
-	0xed, 0x23, 0x3b, 0x09, 0x51, 0x03, 0x60, 0xb2, 0xf0, 0x9d, 0xf9, 0x88, 0x61, 0xca, 0x8c, 0xbd,
-	0x96, 0xd6, 0xd6, 0xed, 0x14, 0x82, 0x4c, 0x38, 0x10, 0xd1, 0x33, 0x6f, 0x6a, 0x14, 0x45, 0x56,
-	0xc5, 0xe8, 0x1c, 0xf4, 0xd7, 0x11, 0xa1, 0xeb, 0xa1, 0x3f, 0x25, 0x46, 0x59, 0x24, 0x37, 0x80,
-	0xf5, 0x04, 0x4e, 0x53, 0xe7, 0x08, 0x03, 0xdf, 0x0b, 0x09, 0xba, 0x80, 0xb2, 0x78, 0xb3, 0x38,
-	0xc6, 0x61, 0xef, 0xb8, 0x13, 0xcf, 0xde, 0x11, 0x54, 0x5b, 0x26, 0xad, 0xff, 0x34, 0xa8, 0x8e,
-	0x08, 0xa6, 0xce, 0x55, 0x32, 0xc0, 0x53, 0x28, 0x8d, 0xf1, 0x2c, 0x34, 0xb4, 0x56, 0xb1, 0x7d,
-	0xd8, 0x6b, 0xa9, 0xb2, 0x0c, 0xab, 0xc3, 0x29, 0xcf, 0x3c, 0x46, 0xd7, 0xfd, 0xd2, 0xdb, 0xf7,
-	0xcd, 0x82, 0x2d, 0x6a, 0xd0, 0x05, 0x54, 0x87, 0xae, 0x37, 0x88, 0x28, 0x66, 0xae, 0xef, 0x0d,
-	0x43, 0x31, 0x65, 0xd5, 0xce, 0x82, 0x82, 0x85, 0xaf, 0x53, 0xac, 0x62, 0xcc, 0x4a, 0x83, 0xe8,
-	0x0c, 0xca, 0x3f, 0xb9, 0x4b, 0x97, 0x19, 0x25, 0x91, 0x95, 0x81, 0xf9, 0x18, 0x74, 0xf5, 0x6a,
-	0x74, 0x02, 0xc5, 0x39, 0x59, 0x8b, 0x01, 0x75, 0x9b, 0x3f, 0xf2, 0xa2, 0x15, 0x5e, 0x44, 0x24,
-	0x96, 0x57, 0x06, 0x4f, 0xf7, 0xbe, 0xd1, 0xac, 0x6b, 0x38, 0x4e, 0x26, 0x88, 0x05, 0xfa, 0x1a,
-	0xf6, 0x85, 0x06, 0xc9, 0xa8, 0xe7, 0x59, 0x85, 0x24, 0x7b, 0x48, 0x18, 0x9e, 0x62, 0x86, 0xed,
-	0x98, 0x8b, 0x1e, 0x40, 0x65, 0x49, 0x18, 0x75, 0x1d, 0x39, 0xdc, 0x61, 0xaf, 0x96, 0x53, 0x68,
-	0x28, 0xb3, 0x76, 0x42, 0xb3, 0xfe, 0xd4, 0xe0, 0xee, 0x8e, 0x8e, 0x79, 0xa7, 0xe8, 0x1b, 0xa7,
-	0xb4, 0xe1, 0x0e, 0xf5, 0x7d, 0x36, 0x22, 0x74, 0xe5, 0x3a, 0xe4, 0x05, 0x5e, 0x26, 0xf3, 0xe4,
-	0x61, 0x2e, 0x25, 0x87, 0x44, 0x7b, 0xc1, 0x93, 0xc6, 0xc9, 0x82, 0xe8, 0x2b, 0x38, 0x0d, 0xb9,
-	0xc5, 0xc6, 0xee, 0x92, 0xfc, 0xe2, 0xb9, 0xd7, 0x2f, 0xb0, 0xe7, 0x0b, 0x59, 0x4b, 0xf6, 0x76,
-	0x82, 0xfb, 0x74, 0xba, 0xf9, 0x36, 0x65, 0xa1, 0x7e, 0x0a, 0xb1, 0xde, 0x28, 0xcb, 0xc4, 0xa3,
-	0xf2, 0xf3, 0xba, 0x5e, 0x18, 0x10, 0x87, 0x91, 0xe9, 0x38, 0x91, 0x94, 0x97, 0xe5, 0x61, 0xf4,
-	0x05, 0x1c, 0x2b, 0xa8, 0xbf, 0x66, 0x44, 0x8a, 0x58, 0xb2, 0x73, 0x68, 0xa6, 0x63, 0x9f, 0x2f,
-	0x41, 0x62, 0x92, 0x3c, 0xcc, 0x15, 0x08, 0xe7, 0x6e, 0x10, 0x28, 0x9e, 0xb4, 0x4b, 0x16, 0xb4,
-	0xee, 0xc2, 0xa9, 0x3c, 0x32, 0x37, 0x4f, 0xec, 0x61, 0xeb, 0x01, 0xa0, 0x34, 0x18, 0xdb, 0xc2,
-	0x84, 0x03, 0x86, 0x67, 0x5c, 0x37, 0x69, 0x0c, 0xdd, 0x56, 0xb1, 0xd5, 0x83, 0x9a, 0xaa, 0x78,
-	0xc5, 0xad, 0x15, 0xa6, 0xd7, 0x5e, 0xb2, 0xd4, 0xc7, 0x94, 0xa1, 0xf5, 0x18, 0xea, 0x5b, 0x35,
-	0xf1, 0xab, 0xce, 0x41, 0x67, 0x09, 0x18, 0xbf, 0x6b, 0x03, 0x58, 0x7d, 0x28, 0x0b, 0xd5, 0xd0,
-	0x13, 0xa8, 0x4c, 0x30, 0x73, 0xae, 0x94, 0x53, 0x9b, 0xca, 0x72, 0xf2, 0xf6, 0x5a, 0x3d, 0xec,
-	0xd8, 0x24, 0xf4, 0x23, 0xea, 0x90, 0x51, 0x80, 0xbd, 0xd0, 0x4e, 0xf8, 0xd6, 0x00, 0x0e, 0x5f,
-	0x46, 0xa1, 0xda, 0xed, 0x4b, 0x28, 0x8b, 0x4c, 0x7c, 0x27, 0x7c, 0xb2, 0x8f, 0x64, 0x5b, 0xc7,
-	0x70, 0x24, 0xbb, 0xc8, 0x73, 0x5b, 0x7f, 0x69, 0x70, 0xc2, 0x01, 0xf1, 0xad, 0x92, 0xde, 0x8f,
-	0xe0, 0x80, 0xca, 0x47, 0x79, 0xcc, 0xa3, 0x7e, 0x9d, 0xdf, 0x0c, 0xff, 0xbc, 0x6f, 0x56, 0x5f,
-	0x52, 0x82, 0x17, 0x0b, 0xdf, 0x91, 0x5f, 0x5c, 0xb3, 0x15, 0x11, 0xdd, 0x57, 0x3b, 0xb8, 0x27,
-	0x4a, 0xee, 0xed, 0x2c, 0x51, 0xcb, 0xf7, 0x25, 0x14, 0xdd, 0x29, 0xb7, 0xc2, 0x47, 0xb8, 0x9c,
-	0x81, 0x2e, 0x01, 0x42, 0x21, 0xfa, 0x00, 0x33, 0x6c, 0x94, 0x3e, 0xc6, 0x4f, 0x11, 0xad, 0x0b,
-	0x80, 0xf8, 0x22, 0xe5, 0x26, 0xac, 0x65, 0x2e, 0x88, 0xa3, 0xe4, 0x14, 0xbd, 0xdf, 0x34, 0xd8,
-	0xe7, 0xe3, 0x13, 0x8a, 0x2e, 0xa1, 0xc4, 0x9f, 0xd0, 0x99, 0x52, 0x32, 0x25, 0xb7, 0x79, 0x2f,
-	0x87, 0xc6, 0xf2, 0x15, 0xd0, 0x77, 0xa0, 0x2b, 0xfd, 0xd0, 0x67, 0x19, 0x56, 0x5a, 0xd3, 0x0f,
-	0x36, 0xe8, 0xbd, 0xd9, 0x83, 0xca, 0xcf, 0x11, 0xa1, 0x2e, 0xa1, 0xe8, 0x47, 0xa8, 0x7e, 0xef,
-	0x7a, 0x53, 0xf5, 0x0f, 0x90, 0x6a, 0x98, 0xff, 0x77, 0x32, 0xcd, 0x5d, 0x29, 0x75, 0xac, 0x6f,
-	0x61, 0x5f, 0x5a, 0x15, 0xd5, 0x76, 0x5f, 0xfb, 0x66, 0x7d, 0x0b, 0x57, 0xc5, 0x3f, 0x00, 0x6c,
-	0xb6, 0x09, 0x99, 0x39, 0x62, 0x6a, 0xef, 0xcc, 0xcf, 0x77, 0xe6, 0x54, 0xa3, 0x57, 0x70, 0x27,
-	0xb7, 0x30, 0xa8, 0xb9, 0x5d, 0x91, 0x59, 0x3f, 0xb3, 0xf5, 0x61, 0x42, 0xd2, 0xb7, 0x6f, 0xbc,
-	0xbd, 0x69, 0x68, 0xef, 0x6e, 0x1a, 0xda, 0xbf, 0x37, 0x0d, 0xed, 0x8f, 0xdb, 0x46, 0xe1, 0xdd,
-	0x6d, 0xa3, 0xf0, 0xf7, 0x6d, 0xa3, 0x30, 0xd9, 0x17, 0xff, 0xe7, 0x8f, 0xfe, 0x0f, 0x00, 0x00,
-	0xff, 0xff, 0xcf, 0x63, 0xe2, 0x49, 0x38, 0x08, 0x00, 0x00,
+func (m *Trace) Reset()         { *m = Trace{} }
+func (m *Trace) String() string { return proto.CompactTextString(m) }
+func (*Trace) ProtoMessage()    {}
+func (*Trace) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f22805646f4f62b6, []int{18}
 }
-
-// Reference imports to suppress errors if they are not otherwise used.
-var _ context.Context
-var _ grpc.ClientConn
-
-// This is a compile-time assertion to ensure that this generated file
-// is compatible with the grpc package it is being compiled against.
-const _ = grpc.SupportPackageIsVersion4
-
-// PusherClient is the client API for Pusher service.
-//
-// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
-type PusherClient interface {
-	Push(ctx context.Context, in *PushRequest, opts ...grpc.CallOption) (*PushResponse, error)
-	PushBytes(ctx context.Context, in *PushBytesRequest, opts ...grpc.CallOption) (*PushResponse, error)
+func (m *Trace) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
 }
-
-type pusherClient struct {
-	cc *grpc.ClientConn
+func (m *Trace) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_Trace.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
 }
-
-func NewPusherClient(cc *grpc.ClientConn) PusherClient {
-	return &pusherClient{cc}
+func (m *Trace) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Trace.Merge(m, src)
+}
+func (m *Trace) XXX_Size() int {
+	return m.Size()
+}
+func (m *Trace) XXX_DiscardUnknown() {
+	xxx_messageInfo_Trace.DiscardUnknown(m)
 }
 
-func (c *pusherClient) Push(ctx context.Context, in *PushRequest, opts ...grpc.CallOption) (*PushResponse, error) {
-	out := new(PushResponse)
-	err := c.cc.Invoke(ctx, "/tempopb.Pusher/Push", in, out, opts...)
-	if err != nil {
-		return nil, err
+var xxx_messageInfo_Trace proto.InternalMessageInfo
+
+func (m *Trace) GetBatches() []*v1.ResourceSpans {
+	if m != nil {
+		return m.Batches
 	}
-	return out, nil
+	return nil
 }
 
-func (c *pusherClient) PushBytes(ctx context.Context, in *PushBytesRequest, opts ...grpc.CallOption) (*PushResponse, error) {
-	out := new(PushResponse)
-	err := c.cc.Invoke(ctx, "/tempopb.Pusher/PushBytes", in, out, opts...)
-	if err != nil {
-		return nil, err
+func (m *Trace) GetIngestionTimeUnixNano() uint64 {
+	if m != nil {
+		return m.IngestionTimeUnixNano
 	}
-	return out, nil
+	return 0
 }
 
-// PusherServer is the server API for Pusher service.
-type PusherServer interface {
-	Push(context.Context, *PushRequest) (*PushResponse, error)
-	PushBytes(context.Context, *PushBytesRequest) (*PushResponse, error)
+// Write
+type PushRequest struct {
+	Batch *v1.ResourceSpans `protobuf:"bytes,1,opt,name=batch,proto3" json:"batch,omitempty"`
 }
 
-// UnimplementedPusherServer can be embedded to have forward compatible implementations.
-type UnimplementedPusherServer struct {
+func (m *PushRequest) Reset()         { *m = PushRequest{} }
+func (m *PushRequest) String() string { return proto.CompactTextString(m) }
+func (*PushRequest) ProtoMessage()    {}
+func (*PushRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f22805646f4f62b6, []int{19}
 }
-
-func (*UnimplementedPusherServer) Push(ctx context.Context, req *PushRequest) (*PushResponse, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method Push not implemented")
+func (m *PushRequest) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
 }
-func (*UnimplementedPusherServer) PushBytes(ctx context.Context, req *PushBytesRequest) (*PushResponse, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method PushBytes not implemented")
+func (m *PushRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_PushRequest.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
 }
-
-func RegisterPusherServer(s *grpc.Server, srv PusherServer) {
-	s.RegisterService(&_Pusher_serviceDesc, srv)
+func (m *PushRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_PushRequest.Merge(m, src)
 }
-
-func _Pusher_Push_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(PushRequest)
-	if err := dec(in); err != nil {
-		return nil, err
-	}
-	if interceptor == nil {
-		return srv.(PusherServer).Push(ctx, in)
-	}
-	info := &grpc.UnaryServerInfo{
-		Server:     srv,
-		FullMethod: "/tempopb.Pusher/Push",
-	}
-	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(PusherServer).Push(ctx, req.(*PushRequest))
-	}
-	return interceptor(ctx, in, info, handler)
+func (m *PushRequest) XXX_Size() int {
+	return m.Size()
+}
+func (m *PushRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_PushRequest.DiscardUnknown(m)
 }
 
-func _Pusher_PushBytes_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(PushBytesRequest)
-	if err := dec(in); err != nil {
-		return nil, err
-	}
-	if interceptor == nil {
-		return srv.(PusherServer).PushBytes(ctx, in)
-	}
-	info := &grpc.UnaryServerInfo{
-		Server:     srv,
-		FullMethod: "/tempopb.Pusher/PushBytes",
-	}
-	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(PusherServer).PushBytes(ctx, req.(*PushBytesRequest))
+var xxx_messageInfo_PushRequest proto.InternalMessageInfo
+
+func (m *PushRequest) GetBatch() *v1.ResourceSpans {
+	if m != nil {
+		return m.Batch
 	}
-	return interceptor(ctx, in, info, handler)
+	return nil
 }
 
-var _Pusher_serviceDesc = grpc.ServiceDesc{
-	ServiceName: "tempopb.Pusher",
-	HandlerType: (*PusherServer)(nil),
-	Methods: []grpc.MethodDesc{
-		{
-			MethodName: "Push",
-			Handler:    _Pusher_Push_Handler,
-		},
-		{
-			MethodName: "PushBytes",
-			Handler:    _Pusher_PushBytes_Handler,
-		},
-	},
-	Streams:  []grpc.StreamDesc{},
-	Metadata: "pkg/tempopb/tempo.proto",
+type PushResponse struct {
+	// set by the distributor if some of the spans were rejected. PushResponse is wire compatible with the OTLP ExportTraceServiceResponse
+	PartialSuccess *PushPartialSuccess `protobuf:"bytes,1,opt,name=partialSuccess,proto3" json:"partialSuccess,omitempty"`
+	// set by the ingester for the traces of a PushBytesRequest with partialSuccess that were rejected
+	TraceErrors []*PushTraceError `protobuf:"bytes,2,rep,name=traceErrors,proto3" json:"traceErrors,omitempty"`
 }
 
-// QuerierClient is the client API for Querier service.
-//
-// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
-type QuerierClient interface {
-	FindTraceByID(ctx context.Context, in *TraceByIDRequest, opts ...grpc.CallOption) (*TraceByIDResponse, error)
-	Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error)
-	SearchTags(ctx context.Context, in *SearchTagsRequest, opts ...grpc.CallOption) (*SearchTagsResponse, error)
-	SearchTagValues(ctx context.Context, in *SearchTagValuesRequest, opts ...grpc.CallOption) (*SearchTagValuesResponse, error)
+func (m *PushResponse) Reset()         { *m = PushResponse{} }
+func (m *PushResponse) String() string { return proto.CompactTextString(m) }
+func (*PushResponse) ProtoMessage()    {}
+func (*PushResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f22805646f4f62b6, []int{20}
 }
-
-type querierClient struct {
-	cc *grpc.ClientConn
+func (m *PushResponse) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
 }
-
-func NewQuerierClient(cc *grpc.ClientConn) QuerierClient {
-	return &querierClient{cc}
+func (m *PushResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_PushResponse.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *PushResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_PushResponse.Merge(m, src)
+}
+func (m *PushResponse) XXX_Size() int {
+	return m.Size()
+}
+func (m *PushResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_PushResponse.DiscardUnknown(m)
 }
 
-func (c *querierClient) FindTraceByID(ctx context.Context, in *TraceByIDRequest, opts ...grpc.CallOption) (*TraceByIDResponse, error) {
-	out := new(TraceByIDResponse)
-	err := c.cc.Invoke(ctx, "/tempopb.Querier/FindTraceByID", in, out, opts...)
-	if err != nil {
-		return nil, err
+var xxx_messageInfo_PushResponse proto.InternalMessageInfo
+
+func (m *PushResponse) GetPartialSuccess() *PushPartialSuccess {
+	if m != nil {
+		return m.PartialSuccess
 	}
-	return out, nil
+	return nil
 }
 
-func (c *querierClient) Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error) {
-	out := new(SearchResponse)
-	err := c.cc.Invoke(ctx, "/tempopb.Querier/Search", in, out, opts...)
-	if err != nil {
-		return nil, err
+func (m *PushResponse) GetTraceErrors() []*PushTraceError {
+	if m != nil {
+		return m.TraceErrors
 	}
-	return out, nil
+	return nil
 }
 
-func (c *querierClient) SearchTags(ctx context.Context, in *SearchTagsRequest, opts ...grpc.CallOption) (*SearchTagsResponse, error) {
-	out := new(SearchTagsResponse)
-	err := c.cc.Invoke(ctx, "/tempopb.Querier/SearchTags", in, out, opts...)
-	if err != nil {
-		return nil, err
+// same as the OTLP ExportTracePartialSuccess
+type PushPartialSuccess struct {
+	RejectedSpans int64  `protobuf:"varint,1,opt,name=rejectedSpans,proto3" json:"rejectedSpans,omitempty"`
+	ErrorMessage  string `protobuf:"bytes,2,opt,name=errorMessage,proto3" json:"errorMessage,omitempty"`
+}
+
+func (m *PushPartialSuccess) Reset()         { *m = PushPartialSuccess{} }
+func (m *PushPartialSuccess) String() string { return proto.CompactTextString(m) }
+func (*PushPartialSuccess) ProtoMessage()    {}
+func (*PushPartialSuccess) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f22805646f4f62b6, []int{21}
+}
+func (m *PushPartialSuccess) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *PushPartialSuccess) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_PushPartialSuccess.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
 	}
-	return out, nil
+}
+func (m *PushPartialSuccess) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_PushPartialSuccess.Merge(m, src)
+}
+func (m *PushPartialSuccess) XXX_Size() int {
+	return m.Size()
+}
+func (m *PushPartialSuccess) XXX_DiscardUnknown() {
+	xxx_messageInfo_PushPartialSuccess.DiscardUnknown(m)
 }
 
-func (c *querierClient) SearchTagValues(ctx context.Context, in *SearchTagValuesRequest, opts ...grpc.CallOption) (*SearchTagValuesResponse, error) {
-	out := new(SearchTagValuesResponse)
-	err := c.cc.Invoke(ctx, "/tempopb.Querier/SearchTagValues", in, out, opts...)
-	if err != nil {
-		return nil, err
+var xxx_messageInfo_PushPartialSuccess proto.InternalMessageInfo
+
+func (m *PushPartialSuccess) GetRejectedSpans() int64 {
+	if m != nil {
+		return m.RejectedSpans
 	}
-	return out, nil
+	return 0
 }
 
-// QuerierServer is the server API for Querier service.
-type QuerierServer interface {
-	FindTraceByID(context.Context, *TraceByIDRequest) (*TraceByIDResponse, error)
-	Search(context.Context, *SearchRequest) (*SearchResponse, error)
-	SearchTags(context.Context, *SearchTagsRequest) (*SearchTagsResponse, error)
-	SearchTagValues(context.Context, *SearchTagValuesRequest) (*SearchTagValuesResponse, error)
+func (m *PushPartialSuccess) GetErrorMessage() string {
+	if m != nil {
+		return m.ErrorMessage
+	}
+	return ""
 }
 
-// UnimplementedQuerierServer can be embedded to have forward compatible implementations.
-type UnimplementedQuerierServer struct {
+type PushTraceError struct {
+	// index of the trace in the PushBytesRequest
+	Index uint32 `protobuf:"varint,1,opt,name=index,proto3" json:"index,omitempty"`
+	Error string `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+	// reason of the per tenant limit that rejected the trace, i.e. TRACE_TOO_LARGE
+	Reason string `protobuf:"bytes,3,opt,name=reason,proto3" json:"reason,omitempty"`
 }
 
-func (*UnimplementedQuerierServer) FindTraceByID(ctx context.Context, req *TraceByIDRequest) (*TraceByIDResponse, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method FindTraceByID not implemented")
+func (m *PushTraceError) Reset()         { *m = PushTraceError{} }
+func (m *PushTraceError) String() string { return proto.CompactTextString(m) }
+func (*PushTraceError) ProtoMessage()    {}
+func (*PushTraceError) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f22805646f4f62b6, []int{22}
 }
-func (*UnimplementedQuerierServer) Search(ctx context.Context, req *SearchRequest) (*SearchResponse, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method Search not implemented")
+func (m *PushTraceError) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
 }
-func (*UnimplementedQuerierServer) SearchTags(ctx context.Context, req *SearchTagsRequest) (*SearchTagsResponse, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method SearchTags not implemented")
+func (m *PushTraceError) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_PushTraceError.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
 }
-func (*UnimplementedQuerierServer) SearchTagValues(ctx context.Context, req *SearchTagValuesRequest) (*SearchTagValuesResponse, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method SearchTagValues not implemented")
+func (m *PushTraceError) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_PushTraceError.Merge(m, src)
 }
-
-func RegisterQuerierServer(s *grpc.Server, srv QuerierServer) {
-	s.RegisterService(&_Querier_serviceDesc, srv)
+func (m *PushTraceError) XXX_Size() int {
+	return m.Size()
 }
-
-func _Querier_FindTraceByID_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(TraceByIDRequest)
-	if err := dec(in); err != nil {
-		return nil, err
-	}
-	if interceptor == nil {
-		return srv.(QuerierServer).FindTraceByID(ctx, in)
-	}
-	info := &grpc.UnaryServerInfo{
-		Server:     srv,
-		FullMethod: "/tempopb.Querier/FindTraceByID",
-	}
-	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(QuerierServer).FindTraceByID(ctx, req.(*TraceByIDRequest))
-	}
-	return interceptor(ctx, in, info, handler)
+func (m *PushTraceError) XXX_DiscardUnknown() {
+	xxx_messageInfo_PushTraceError.DiscardUnknown(m)
 }
 
-func _Querier_Search_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(SearchRequest)
-	if err := dec(in); err != nil {
-		return nil, err
-	}
-	if interceptor == nil {
-		return srv.(QuerierServer).Search(ctx, in)
-	}
-	info := &grpc.UnaryServerInfo{
-		Server:     srv,
-		FullMethod: "/tempopb.Querier/Search",
-	}
-	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(QuerierServer).Search(ctx, req.(*SearchRequest))
+var xxx_messageInfo_PushTraceError proto.InternalMessageInfo
+
+func (m *PushTraceError) GetIndex() uint32 {
+	if m != nil {
+		return m.Index
 	}
-	return interceptor(ctx, in, info, handler)
+	return 0
 }
 
-func _Querier_SearchTags_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(SearchTagsRequest)
-	if err := dec(in); err != nil {
-		return nil, err
-	}
-	if interceptor == nil {
-		return srv.(QuerierServer).SearchTags(ctx, in)
-	}
-	info := &grpc.UnaryServerInfo{
-		Server:     srv,
-		FullMethod: "/tempopb.Querier/SearchTags",
-	}
-	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(QuerierServer).SearchTags(ctx, req.(*SearchTagsRequest))
+func (m *PushTraceError) GetError() string {
+	if m != nil {
+		return m.Error
 	}
-	return interceptor(ctx, in, info, handler)
+	return ""
 }
 
-func _Querier_SearchTagValues_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(SearchTagValuesRequest)
-	if err := dec(in); err != nil {
-		return nil, err
-	}
-	if interceptor == nil {
-		return srv.(QuerierServer).SearchTagValues(ctx, in)
-	}
-	info := &grpc.UnaryServerInfo{
-		Server:     srv,
-		FullMethod: "/tempopb.Querier/SearchTagValues",
-	}
-	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(QuerierServer).SearchTagValues(ctx, req.(*SearchTagValuesRequest))
+func (m *PushTraceError) GetReason() string {
+	if m != nil {
+		return m.Reason
 	}
-	return interceptor(ctx, in, info, handler)
+	return ""
 }
 
-var _Querier_serviceDesc = grpc.ServiceDesc{
-	ServiceName: "tempopb.Querier",
-	HandlerType: (*QuerierServer)(nil),
-	Methods: []grpc.MethodDesc{
-		{
-			MethodName: "FindTraceByID",
-			Handler:    _Querier_FindTraceByID_Handler,
-		},
-		{
-			MethodName: "Search",
-			Handler:    _Querier_Search_Handler,
-		},
-		{
-			MethodName: "SearchTags",
-			Handler:    _Querier_SearchTags_Handler,
-		},
-		{
-			MethodName: "SearchTagValues",
-			Handler:    _Querier_SearchTagValues_Handler,
-		},
-	},
-	Streams:  []grpc.StreamDesc{},
-	Metadata: "pkg/tempopb/tempo.proto",
+type PushBytesRequest struct {
+	// pre-marshalled PushRequests
+	Requests []PreallocBytes `protobuf:"bytes,1,rep,name=requests,proto3,customtype=PreallocBytes" json:"requests"` // Deprecated: Do not use.
+	// pre-marshalled Traces. length must match ids
+	Traces []PreallocBytes `protobuf:"bytes,2,rep,name=traces,proto3,customtype=PreallocBytes" json:"traces"`
+	// trace ids. length must match traces
+	Ids []PreallocBytes `protobuf:"bytes,3,rep,name=ids,proto3,customtype=PreallocBytes" json:"ids"`
+	// search data, length must match traces
+	SearchData []PreallocBytes `protobuf:"bytes,4,rep,name=searchData,proto3,customtype=PreallocBytes" json:"searchData"`
+	// traces rejected by a limit are returned in PushResponse.traceErrors instead of failing the request
+	PartialSuccess bool `protobuf:"varint,5,opt,name=partialSuccess,proto3" json:"partialSuccess,omitempty"`
+	// time the distributor received the traces
+	IngestionTimeUnixNano uint64 `protobuf:"varint,6,opt,name=ingestionTimeUnixNano,proto3" json:"ingestionTimeUnixNano,omitempty"`
+	// the tenant reached max_global_traces_per_user as of the last poll of the distributor. traces that are not live
+	// are rejected, spans of live traces are still appended
+	RejectNewTraces bool `protobuf:"varint,7,opt,name=rejectNewTraces,proto3" json:"rejectNewTraces,omitempty"`
 }
 
-func (m *TraceByIDRequest) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalToSizedBuffer(dAtA[:size])
-	if err != nil {
-		return nil, err
+func (m *PushBytesRequest) Reset()         { *m = PushBytesRequest{} }
+func (m *PushBytesRequest) String() string { return proto.CompactTextString(m) }
+func (*PushBytesRequest) ProtoMessage()    {}
+func (*PushBytesRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f22805646f4f62b6, []int{23}
+}
+func (m *PushBytesRequest) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *PushBytesRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_PushBytesRequest.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
 	}
-	return dAtA[:n], nil
 }
-
-func (m *TraceByIDRequest) MarshalTo(dAtA []byte) (int, error) {
-	size := m.Size()
-	return m.MarshalToSizedBuffer(dAtA[:size])
+func (m *PushBytesRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_PushBytesRequest.Merge(m, src)
+}
+func (m *PushBytesRequest) XXX_Size() int {
+	return m.Size()
+}
+func (m *PushBytesRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_PushBytesRequest.DiscardUnknown(m)
 }
 
-func (m *TraceByIDRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
-	i := len(dAtA)
-	_ = i
-	var l int
-	_ = l
-	if len(m.QueryMode) > 0 {
-		i -= len(m.QueryMode)
-		copy(dAtA[i:], m.QueryMode)
-		i = encodeVarintTempo(dAtA, i, uint64(len(m.QueryMode)))
-		i--
-		dAtA[i] = 0x2a
-	}
-	if len(m.BlockEnd) > 0 {
-		i -= len(m.BlockEnd)
-		copy(dAtA[i:], m.BlockEnd)
-		i = encodeVarintTempo(dAtA, i, uint64(len(m.BlockEnd)))
-		i--
-		dAtA[i] = 0x1a
-	}
-	if len(m.BlockStart) > 0 {
-		i -= len(m.BlockStart)
-		copy(dAtA[i:], m.BlockStart)
-		i = encodeVarintTempo(dAtA, i, uint64(len(m.BlockStart)))
-		i--
-		dAtA[i] = 0x12
+var xxx_messageInfo_PushBytesRequest proto.InternalMessageInfo
+
+func (m *PushBytesRequest) GetPartialSuccess() bool {
+	if m != nil {
+		return m.PartialSuccess
 	}
-	if len(m.TraceID) > 0 {
-		i -= len(m.TraceID)
-		copy(dAtA[i:], m.TraceID)
-		i = encodeVarintTempo(dAtA, i, uint64(len(m.TraceID)))
-		i--
-		dAtA[i] = 0xa
+	return false
+}
+
+func (m *PushBytesRequest) GetIngestionTimeUnixNano() uint64 {
+	if m != nil {
+		return m.IngestionTimeUnixNano
 	}
-	return len(dAtA) - i, nil
+	return 0
 }
 
-func (m *TraceByIDResponse) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalToSizedBuffer(dAtA[:size])
-	if err != nil {
-		return nil, err
+func (m *PushBytesRequest) GetRejectNewTraces() bool {
+	if m != nil {
+		return m.RejectNewTraces
 	}
-	return dAtA[:n], nil
+	return false
 }
 
-func (m *TraceByIDResponse) MarshalTo(dAtA []byte) (int, error) {
-	size := m.Size()
-	return m.MarshalToSizedBuffer(dAtA[:size])
+type TraceBytes struct {
+	// pre-marshalled Traces
+	Traces [][]byte `protobuf:"bytes,1,rep,name=traces,proto3" json:"traces,omitempty"`
 }
 
-func (m *TraceByIDResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
-	i := len(dAtA)
-	_ = i
-	var l int
-	_ = l
-	if m.Trace != nil {
-		{
-			size, err := m.Trace.MarshalToSizedBuffer(dAtA[:i])
-			if err != nil {
-				return 0, err
-			}
-			i -= size
-			i = encodeVarintTempo(dAtA, i, uint64(size))
+func (m *TraceBytes) Reset()         { *m = TraceBytes{} }
+func (m *TraceBytes) String() string { return proto.CompactTextString(m) }
+func (*TraceBytes) ProtoMessage()    {}
+func (*TraceBytes) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f22805646f4f62b6, []int{24}
+}
+func (m *TraceBytes) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *TraceBytes) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_TraceBytes.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
 		}
-		i--
-		dAtA[i] = 0xa
+		return b[:n], nil
 	}
-	return len(dAtA) - i, nil
 }
-
-func (m *SearchRequest) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalToSizedBuffer(dAtA[:size])
-	if err != nil {
-		return nil, err
-	}
-	return dAtA[:n], nil
+func (m *TraceBytes) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TraceBytes.Merge(m, src)
 }
-
-func (m *SearchRequest) MarshalTo(dAtA []byte) (int, error) {
-	size := m.Size()
-	return m.MarshalToSizedBuffer(dAtA[:size])
+func (m *TraceBytes) XXX_Size() int {
+	return m.Size()
+}
+func (m *TraceBytes) XXX_DiscardUnknown() {
+	xxx_messageInfo_TraceBytes.DiscardUnknown(m)
 }
 
-func (m *SearchRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
-	i := len(dAtA)
-	_ = i
-	var l int
-	_ = l
-	if m.Limit != 0 {
-		i = encodeVarintTempo(dAtA, i, uint64(m.Limit))
-		i--
-		dAtA[i] = 0x20
-	}
-	if m.MaxDurationMs != 0 {
-		i = encodeVarintTempo(dAtA, i, uint64(m.MaxDurationMs))
-		i--
-		dAtA[i] = 0x18
-	}
-	if m.MinDurationMs != 0 {
-		i = encodeVarintTempo(dAtA, i, uint64(m.MinDurationMs))
-		i--
-		dAtA[i] = 0x10
-	}
-	if len(m.Tags) > 0 {
-		for k := range m.Tags {
-			v := m.Tags[k]
-			baseI := i
-			i -= len(v)
-			copy(dAtA[i:], v)
-			i = encodeVarintTempo(dAtA, i, uint64(len(v)))
-			i--
-			dAtA[i] = 0x12
-			i -= len(k)
-			copy(dAtA[i:], k)
-			i = encodeVarintTempo(dAtA, i, uint64(len(k)))
-			i--
-			dAtA[i] = 0xa
-			i = encodeVarintTempo(dAtA, i, uint64(baseI-i))
-			i--
-			dAtA[i] = 0xa
-		}
+var xxx_messageInfo_TraceBytes proto.InternalMessageInfo
+
+func (m *TraceBytes) GetTraces() [][]byte {
+	if m != nil {
+		return m.Traces
 	}
-	return len(dAtA) - i, nil
+	return nil
 }
 
-func (m *SearchResponse) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalToSizedBuffer(dAtA[:size])
-	if err != nil {
-		return nil, err
-	}
-	return dAtA[:n], nil
+func init() {
+	proto.RegisterType((*TraceByIDRequest)(nil), "tempopb.TraceByIDRequest")
+	proto.RegisterType((*TraceByIDResponse)(nil), "tempopb.TraceByIDResponse")
+	proto.RegisterType((*TraceByIDBatchRequest)(nil), "tempopb.TraceByIDBatchRequest")
+	proto.RegisterType((*TraceByIDBatchResult)(nil), "tempopb.TraceByIDBatchResult")
+	proto.RegisterType((*TraceByIDBatchResponse)(nil), "tempopb.TraceByIDBatchResponse")
+	proto.RegisterType((*SearchRequest)(nil), "tempopb.SearchRequest")
+	proto.RegisterMapType((map[string]string)(nil), "tempopb.SearchRequest.TagsEntry")
+	proto.RegisterType((*SearchResponse)(nil), "tempopb.SearchResponse")
+	proto.RegisterType((*TraceSearchMetadata)(nil), "tempopb.TraceSearchMetadata")
+	proto.RegisterType((*SearchMetrics)(nil), "tempopb.SearchMetrics")
+	proto.RegisterType((*SearchTagsRequest)(nil), "tempopb.SearchTagsRequest")
+	proto.RegisterType((*SearchTagsResponse)(nil), "tempopb.SearchTagsResponse")
+	proto.RegisterType((*SearchTagValuesRequest)(nil), "tempopb.SearchTagValuesRequest")
+	proto.RegisterType((*SearchTagValuesResponse)(nil), "tempopb.SearchTagValuesResponse")
+	proto.RegisterType((*LiveTraceStatsRequest)(nil), "tempopb.LiveTraceStatsRequest")
+	proto.RegisterType((*LiveTraceStatsResponse)(nil), "tempopb.LiveTraceStatsResponse")
+	proto.RegisterType((*TenantLiveTraceStats)(nil), "tempopb.TenantLiveTraceStats")
+	proto.RegisterType((*DeleteTraceRequest)(nil), "tempopb.DeleteTraceRequest")
+	proto.RegisterType((*DeleteTraceResponse)(nil), "tempopb.DeleteTraceResponse")
+	proto.RegisterType((*Trace)(nil), "tempopb.Trace")
+	proto.RegisterType((*PushRequest)(nil), "tempopb.PushRequest")
+	proto.RegisterType((*PushResponse)(nil), "tempopb.PushResponse")
+	proto.RegisterType((*PushPartialSuccess)(nil), "tempopb.PushPartialSuccess")
+	proto.RegisterType((*PushTraceError)(nil), "tempopb.PushTraceError")
+	proto.RegisterType((*PushBytesRequest)(nil), "tempopb.PushBytesRequest")
+	proto.RegisterType((*TraceBytes)(nil), "tempopb.TraceBytes")
 }
 
-func (m *SearchResponse) MarshalTo(dAtA []byte) (int, error) {
-	size := m.Size()
-	return m.MarshalToSizedBuffer(dAtA[:size])
+func init() { proto.RegisterFile("pkg/tempopb/tempo.proto", fileDescriptor_f22805646f4f62b6) }
+
+var fileDescriptor_f22805646f4f62b6 = []byte{
+	// 1317 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x84, 0x57, 0x4d, 0x6f, 0x1b, 0x37,
+	0x13, 0xf6, 0x5a, 0x5f, 0xf6, 0xf8, 0x23, 0x09, 0x63, 0xcb, 0x7a, 0x37, 0x7e, 0x65, 0x83, 0x08,
+	0x5a, 0x1f, 0x1a, 0x39, 0x71, 0x12, 0xa4, 0x49, 0x0f, 0x05, 0x54, 0xa7, 0x6d, 0x8a, 0x28, 0x48,
+	0x56, 0x6a, 0x8e, 0x05, 0xa8, 0x15, 0xab, 0x6c, 0x2d, 0xed, 0x2a, 0x24, 0x57, 0x91, 0x7b, 0xea,
+	0x2f, 0x28, 0x7a, 0xeb, 0xbd, 0xff, 0xa0, 0xff, 0x22, 0x97, 0x02, 0x41, 0x4f, 0x45, 0x0f, 0x41,
+	0xe1, 0x00, 0xfd, 0x1d, 0x05, 0x3f, 0x96, 0xfb, 0x21, 0xd9, 0x3e, 0x79, 0xe7, 0xe1, 0x33, 0x43,
+	0xf2, 0x99, 0xe1, 0x8c, 0x0c, 0x3b, 0x93, 0x93, 0xe1, 0xa1, 0xa0, 0xe3, 0x49, 0x34, 0xe9, 0xeb,
+	0xbf, 0xad, 0x09, 0x8b, 0x44, 0x84, 0x6a, 0x06, 0x74, 0xb7, 0x04, 0x23, 0x3e, 0x3d, 0x9c, 0xde,
+	0x39, 0x54, 0x1f, 0x7a, 0xd9, 0xbd, 0x35, 0x0c, 0xc4, 0xab, 0xb8, 0xdf, 0xf2, 0xa3, 0xf1, 0xe1,
+	0x30, 0x1a, 0x46, 0x87, 0x0a, 0xee, 0xc7, 0xdf, 0x2b, 0x4b, 0x19, 0xea, 0x4b, 0xd3, 0xf1, 0x6f,
+	0x0e, 0x5c, 0xed, 0x49, 0xf7, 0xf6, 0xe9, 0x93, 0x63, 0x8f, 0xbe, 0x8e, 0x29, 0x17, 0xa8, 0x01,
+	0x35, 0x15, 0xf2, 0xc9, 0x71, 0xc3, 0xd9, 0x77, 0x0e, 0xd6, 0xbd, 0xc4, 0x44, 0x4d, 0x80, 0xfe,
+	0x28, 0xf2, 0x4f, 0xba, 0x82, 0x30, 0xd1, 0x58, 0xde, 0x77, 0x0e, 0x56, 0xbd, 0x0c, 0x82, 0x5c,
+	0x58, 0x51, 0xd6, 0xe3, 0x70, 0xd0, 0x28, 0xa9, 0x55, 0x6b, 0xa3, 0x5d, 0x58, 0x7d, 0x1d, 0x53,
+	0x76, 0xda, 0x89, 0x06, 0xb4, 0x51, 0x51, 0x8b, 0x29, 0x20, 0x3d, 0xc7, 0x64, 0xd6, 0x3e, 0x15,
+	0x94, 0x37, 0xaa, 0xfb, 0xce, 0x41, 0xd9, 0xb3, 0x36, 0xfe, 0xd5, 0x81, 0x6b, 0x99, 0x43, 0xf2,
+	0x49, 0x14, 0x72, 0x8a, 0x6e, 0x42, 0x45, 0x1d, 0x4b, 0x9d, 0x71, 0xed, 0x68, 0xb3, 0x65, 0x84,
+	0x69, 0x29, 0xaa, 0xa7, 0x17, 0xd1, 0x47, 0xb0, 0xa9, 0x3e, 0x7a, 0x2c, 0x0e, 0x7d, 0x22, 0xe8,
+	0x40, 0x9d, 0x7a, 0xc5, 0x2b, 0xa0, 0xf2, 0xce, 0x13, 0xc2, 0x44, 0x40, 0x46, 0xea, 0xe0, 0x2b,
+	0x5e, 0x62, 0xca, 0x93, 0xbd, 0x21, 0x2c, 0x0c, 0xc2, 0x21, 0x6f, 0x94, 0xf7, 0x4b, 0xf2, 0x4e,
+	0x89, 0x8d, 0xef, 0xc2, 0xb6, 0x3d, 0x58, 0x9b, 0x08, 0xff, 0x55, 0x22, 0xa1, 0x0b, 0x2b, 0x46,
+	0x33, 0xde, 0x70, 0xb4, 0x53, 0x62, 0xe3, 0x33, 0x07, 0xb6, 0x8a, 0x5e, 0x3c, 0x1e, 0xcd, 0xe9,
+	0xbe, 0x9a, 0xea, 0x5e, 0x87, 0x2a, 0x17, 0x44, 0xc4, 0xdc, 0x68, 0x6e, 0xac, 0x54, 0x83, 0xd2,
+	0x45, 0x1a, 0x6c, 0x41, 0x85, 0x32, 0x16, 0xb1, 0x46, 0x59, 0x39, 0x6b, 0x63, 0x81, 0x32, 0x95,
+	0xcb, 0x94, 0xa9, 0x9e, 0xaf, 0x4c, 0xad, 0xa0, 0xcc, 0x0b, 0xa8, 0xcf, 0xdd, 0x51, 0xe7, 0xed,
+	0x01, 0xd4, 0x98, 0xba, 0xaf, 0x56, 0x66, 0xed, 0xe8, 0xff, 0xf9, 0x53, 0x17, 0x54, 0xf1, 0x12,
+	0x36, 0xfe, 0xd7, 0x81, 0x8d, 0x2e, 0x25, 0x2c, 0x55, 0xf9, 0x11, 0x94, 0x7b, 0x64, 0x98, 0xc4,
+	0xd9, 0xb7, 0x71, 0x72, 0xac, 0x96, 0xa4, 0x3c, 0x0e, 0x05, 0x3b, 0x6d, 0x97, 0xdf, 0xbe, 0xdf,
+	0x5b, 0xf2, 0x94, 0x0f, 0xba, 0x09, 0x1b, 0x9d, 0x20, 0x3c, 0x8e, 0x19, 0x11, 0x41, 0x14, 0x76,
+	0xb4, 0xb2, 0x1b, 0x5e, 0x1e, 0x54, 0x2c, 0x32, 0xcb, 0xb0, 0x4a, 0x86, 0x95, 0x05, 0xa5, 0xc0,
+	0x4f, 0x83, 0x71, 0x20, 0x94, 0xc0, 0x1b, 0x9e, 0x36, 0xdc, 0x07, 0xb0, 0x6a, 0xb7, 0x46, 0x57,
+	0xa1, 0x74, 0x42, 0x4f, 0x4d, 0x5e, 0xe5, 0xa7, 0x74, 0x9a, 0x92, 0x51, 0x4c, 0x4d, 0x4a, 0xb5,
+	0xf1, 0x68, 0xf9, 0x53, 0x07, 0xcf, 0x60, 0x33, 0xb9, 0x81, 0xd1, 0xec, 0x1e, 0x54, 0x55, 0x56,
+	0x92, 0xab, 0xee, 0xe6, 0x25, 0xd3, 0xec, 0x0e, 0x15, 0x64, 0x40, 0x04, 0xf1, 0x0c, 0x17, 0xdd,
+	0x86, 0xda, 0x98, 0x0a, 0x16, 0xf8, 0xfa, 0x72, 0x6b, 0x47, 0xf5, 0x82, 0x42, 0x1d, 0xbd, 0xea,
+	0x25, 0x34, 0xfc, 0x87, 0x03, 0xd7, 0x17, 0x44, 0xbc, 0xa0, 0x32, 0x0f, 0xe0, 0x0a, 0x8b, 0x22,
+	0xd1, 0xa5, 0x6c, 0x1a, 0xf8, 0xf4, 0x19, 0x19, 0x27, 0xf7, 0x29, 0xc2, 0x52, 0x4a, 0x09, 0xa9,
+	0xf0, 0x8a, 0xa7, 0x1b, 0x44, 0x1e, 0x44, 0x9f, 0xc0, 0x35, 0x2e, 0x08, 0x13, 0xbd, 0x60, 0x4c,
+	0xbf, 0x0d, 0x83, 0xd9, 0x33, 0x12, 0x46, 0x4a, 0xd6, 0xb2, 0x37, 0xbf, 0x20, 0xfb, 0xd1, 0x20,
+	0xcd, 0x4d, 0x45, 0xa9, 0x9f, 0x41, 0xf0, 0xef, 0xb6, 0x64, 0xcc, 0x55, 0xe5, 0x79, 0x83, 0x90,
+	0x4f, 0xa8, 0x2f, 0xe8, 0xa0, 0x97, 0x48, 0x2a, 0xdd, 0x8a, 0xb0, 0x7c, 0x1f, 0x16, 0xd2, 0x7d,
+	0x69, 0x59, 0x1d, 0xa3, 0x80, 0xe6, 0x22, 0xb6, 0x65, 0xb3, 0x4b, 0x8a, 0xa4, 0x08, 0x4b, 0x05,
+	0xf8, 0x49, 0x30, 0x99, 0x58, 0x9e, 0x2e, 0x97, 0x3c, 0x88, 0xaf, 0xc3, 0x35, 0x7d, 0x64, 0x59,
+	0x3c, 0xa6, 0x86, 0xf1, 0x6d, 0x40, 0x59, 0xd0, 0x94, 0x85, 0xec, 0x32, 0x64, 0x28, 0x75, 0x4b,
+	0xbb, 0x8c, 0xb1, 0xf1, 0x11, 0xd4, 0xad, 0xc7, 0x4b, 0x59, 0x5a, 0x3c, 0xdb, 0xde, 0x35, 0xcb,
+	0x26, 0x53, 0x9b, 0xf8, 0x01, 0xec, 0xcc, 0xf9, 0x98, 0xad, 0x76, 0x61, 0x55, 0x24, 0xa0, 0xd9,
+	0x2b, 0x05, 0xf0, 0x0e, 0x6c, 0x3f, 0x0d, 0xa6, 0x54, 0x97, 0x8e, 0x20, 0xc2, 0x9e, 0xfb, 0x05,
+	0xd4, 0x8b, 0x0b, 0x69, 0x1b, 0x10, 0x34, 0x24, 0xe1, 0xa2, 0x36, 0xa0, 0xf0, 0x82, 0x5f, 0xc2,
+	0xc6, 0x3f, 0xc2, 0xd6, 0x22, 0x82, 0x12, 0x43, 0xe1, 0xb6, 0x48, 0xad, 0x2d, 0xeb, 0x64, 0x94,
+	0xb0, 0x93, 0x3c, 0x66, 0x10, 0x99, 0x6b, 0x6b, 0xe9, 0x5c, 0x97, 0x74, 0xae, 0xf3, 0x28, 0x6e,
+	0x01, 0x3a, 0xa6, 0x23, 0x2a, 0x34, 0x76, 0xe9, 0xbc, 0xc4, 0x0f, 0xe1, 0x7a, 0x8e, 0x6f, 0xee,
+	0x8e, 0x61, 0x9d, 0x4f, 0x48, 0xc8, 0x3d, 0x3a, 0x8e, 0xa6, 0x74, 0xa0, 0xbc, 0xca, 0x5e, 0x0e,
+	0xc3, 0x33, 0xa8, 0x28, 0x27, 0xf4, 0x10, 0x6a, 0x7d, 0xd9, 0x0e, 0xed, 0xe3, 0xdf, 0xb3, 0x42,
+	0xe9, 0xc1, 0x3f, 0xbd, 0xd3, 0xf2, 0x28, 0x8f, 0x62, 0xe6, 0xd3, 0xae, 0x8a, 0x90, 0xf0, 0xd1,
+	0x3d, 0xd8, 0x0e, 0xc2, 0x21, 0xe5, 0xf2, 0x35, 0xe4, 0x1e, 0x94, 0x56, 0x60, 0xf1, 0x22, 0x3e,
+	0x86, 0xb5, 0xe7, 0x31, 0xb7, 0x4d, 0xf6, 0x3e, 0x54, 0x54, 0x3c, 0x33, 0x67, 0x2f, 0xdd, 0x5d,
+	0xb3, 0xf1, 0xcf, 0x0e, 0xac, 0xeb, 0x30, 0xe6, 0xd2, 0x5f, 0xc0, 0xa6, 0x19, 0x1c, 0xdd, 0xd8,
+	0xf7, 0x29, 0xe7, 0x26, 0xe0, 0x0d, 0x1b, 0x50, 0xd2, 0x9f, 0xe7, 0x28, 0x5e, 0xc1, 0x05, 0x3d,
+	0x84, 0x35, 0xb5, 0xed, 0x63, 0x39, 0xc2, 0x64, 0x26, 0xa5, 0x20, 0x3b, 0xb9, 0x08, 0x3d, 0xbb,
+	0xee, 0x65, 0xb9, 0xf8, 0x3b, 0x40, 0xf3, 0x1b, 0xa8, 0xae, 0x44, 0x7f, 0x50, 0xaf, 0x54, 0x1d,
+	0x5f, 0x1d, 0xaa, 0xe4, 0xe5, 0x41, 0x99, 0x30, 0x35, 0x34, 0x3b, 0x94, 0x73, 0x32, 0x4c, 0x5a,
+	0x5c, 0x0e, 0xc3, 0x3d, 0xd8, 0xcc, 0x6f, 0x2f, 0x3b, 0x7c, 0x10, 0x0e, 0xe8, 0xcc, 0x74, 0x18,
+	0x6d, 0xa4, 0xd3, 0x78, 0x39, 0x3b, 0x8d, 0xeb, 0x50, 0x65, 0x94, 0xf0, 0x28, 0x34, 0x6d, 0xd1,
+	0x58, 0xf8, 0xfd, 0x32, 0x5c, 0x95, 0x61, 0x55, 0xfd, 0x25, 0x29, 0xb9, 0x0b, 0x2b, 0x4c, 0x7f,
+	0xea, 0x9a, 0x58, 0x6f, 0xef, 0xc8, 0xc9, 0xf6, 0xf7, 0xfb, 0xbd, 0x8d, 0xe7, 0x8c, 0x92, 0xd1,
+	0x28, 0xf2, 0x75, 0x15, 0x3b, 0x9e, 0x25, 0xa2, 0x5b, 0x76, 0x86, 0x2c, 0x2b, 0x97, 0xed, 0x85,
+	0x2e, 0x76, 0x78, 0x7c, 0x0c, 0xa5, 0x60, 0x20, 0xdf, 0xc1, 0x05, 0x5c, 0xc9, 0x40, 0xf7, 0x01,
+	0xb8, 0x6a, 0x1a, 0xc7, 0x44, 0x90, 0x46, 0xf9, 0x22, 0x7e, 0x86, 0x28, 0x9f, 0x5c, 0xa1, 0x1c,
+	0xcc, 0xcf, 0x8f, 0x42, 0xc6, 0xcf, 0xad, 0xe1, 0xea, 0x05, 0x35, 0xac, 0xc6, 0x92, 0xca, 0xe0,
+	0x33, 0xfa, 0xc6, 0xbc, 0xfa, 0x9a, 0x0a, 0x5f, 0x84, 0xf1, 0x4d, 0x80, 0xf4, 0x81, 0xcb, 0x34,
+	0x64, 0x06, 0xed, 0x7a, 0xa2, 0xc6, 0xd1, 0x4f, 0x0e, 0x54, 0x65, 0x1a, 0x28, 0x43, 0xf7, 0xa1,
+	0x2c, 0xbf, 0xd0, 0x56, 0xae, 0xea, 0x4c, 0x6a, 0xdc, 0xed, 0x02, 0xaa, 0x8b, 0x1f, 0x2f, 0xa1,
+	0xcf, 0x61, 0xd5, 0xe6, 0x11, 0xfd, 0x2f, 0xc7, 0xca, 0xe6, 0xf6, 0xdc, 0x00, 0x47, 0x7f, 0x96,
+	0xa0, 0xf6, 0x22, 0xa6, 0x2c, 0xa0, 0x0c, 0x7d, 0x0d, 0x1b, 0x5f, 0x06, 0xe1, 0xc0, 0xfe, 0x5e,
+	0xca, 0x04, 0x2c, 0xfe, 0x9a, 0x77, 0xdd, 0x45, 0x4b, 0xf6, 0x58, 0x9f, 0x41, 0x55, 0xb7, 0x7c,
+	0x54, 0x5f, 0xfc, 0xf3, 0xc9, 0xdd, 0x99, 0xc3, 0xad, 0xf3, 0x57, 0x00, 0xe9, 0x54, 0x42, 0x6e,
+	0x81, 0x98, 0x99, 0x5f, 0xee, 0x8d, 0x85, 0x6b, 0x36, 0xd0, 0x4b, 0xb8, 0x52, 0x18, 0x3c, 0x68,
+	0x6f, 0xde, 0x23, 0x37, 0xc6, 0xdc, 0xfd, 0xf3, 0x09, 0x36, 0x6e, 0x17, 0x36, 0x0b, 0x53, 0xa2,
+	0x69, 0xbd, 0x16, 0x0e, 0x2c, 0x77, 0xef, 0xdc, 0x75, 0x1b, 0xf4, 0x1b, 0x58, 0xcb, 0x34, 0x75,
+	0x94, 0x5e, 0x6d, 0x7e, 0x34, 0xb8, 0xbb, 0x8b, 0x17, 0x93, 0x58, 0xed, 0xc6, 0xdb, 0xb3, 0xa6,
+	0xf3, 0xee, 0xac, 0xe9, 0xfc, 0x73, 0xd6, 0x74, 0x7e, 0xf9, 0xd0, 0x5c, 0x7a, 0xf7, 0xa1, 0xb9,
+	0xf4, 0xd7, 0x87, 0xe6, 0x52, 0xbf, 0xaa, 0xfe, 0x41, 0xbb, 0xfb, 0xdf, 0x00, 0xa9, 0xa0, 0x0d,
+	0xc8, 0x09, 0x0e, 0x00, 0x00,
 }
 
-func (m *SearchResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
-	i := len(dAtA)
-	_ = i
-	var l int
-	_ = l
-	if m.Metrics != nil {
-		{
-			size, err := m.Metrics.MarshalToSizedBuffer(dAtA[:i])
-			if err != nil {
-				return 0, err
-			}
-			i -= size
-			i = encodeVarintTempo(dAtA, i, uint64(size))
-		}
-		i--
-		dAtA[i] = 0x12
-	}
-	if len(m.Traces) > 0 {
-		for iNdEx := len(m.Traces) - 1; iNdEx >= 0; iNdEx-- {
-			{
-				size, err := m.Traces[iNdEx].MarshalToSizedBuffer(dAtA[:i])
-				if err != nil {
-					return 0, err
-				}
-				i -= size
-				i = encodeVarintTempo(dAtA, i, uint64(size))
-			}
-			i--
-			dAtA[i] = 0xa
-		}
-	}
-	return len(dAtA) - i, nil
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// PusherClient is the client API for Pusher service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type PusherClient interface {
+	Push(ctx context.Context, in *PushRequest, opts ...grpc.CallOption) (*PushResponse, error)
+	PushBytes(ctx context.Context, in *PushBytesRequest, opts ...grpc.CallOption) (*PushResponse, error)
 }
 
-func (m *TraceSearchMetadata) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalToSizedBuffer(dAtA[:size])
-	if err != nil {
-		return nil, err
-	}
-	return dAtA[:n], nil
+type pusherClient struct {
+	cc *grpc.ClientConn
 }
 
-func (m *TraceSearchMetadata) MarshalTo(dAtA []byte) (int, error) {
-	size := m.Size()
-	return m.MarshalToSizedBuffer(dAtA[:size])
+func NewPusherClient(cc *grpc.ClientConn) PusherClient {
+	return &pusherClient{cc}
 }
 
-func (m *TraceSearchMetadata) MarshalToSizedBuffer(dAtA []byte) (int, error) {
-	i := len(dAtA)
-	_ = i
-	var l int
-	_ = l
-	if m.DurationMs != 0 {
-		i = encodeVarintTempo(dAtA, i, uint64(m.DurationMs))
-		i--
-		dAtA[i] = 0x28
-	}
-	if m.StartTimeUnixNano != 0 {
-		i = encodeVarintTempo(dAtA, i, uint64(m.StartTimeUnixNano))
-		i--
-		dAtA[i] = 0x20
-	}
-	if len(m.RootTraceName) > 0 {
-		i -= len(m.RootTraceName)
-		copy(dAtA[i:], m.RootTraceName)
-		i = encodeVarintTempo(dAtA, i, uint64(len(m.RootTraceName)))
-		i--
-		dAtA[i] = 0x1a
-	}
-	if len(m.RootServiceName) > 0 {
-		i -= len(m.RootServiceName)
-		copy(dAtA[i:], m.RootServiceName)
-		i = encodeVarintTempo(dAtA, i, uint64(len(m.RootServiceName)))
-		i--
-		dAtA[i] = 0x12
-	}
-	if len(m.TraceID) > 0 {
-		i -= len(m.TraceID)
-		copy(dAtA[i:], m.TraceID)
-		i = encodeVarintTempo(dAtA, i, uint64(len(m.TraceID)))
-		i--
-		dAtA[i] = 0xa
+func (c *pusherClient) Push(ctx context.Context, in *PushRequest, opts ...grpc.CallOption) (*PushResponse, error) {
+	out := new(PushResponse)
+	err := c.cc.Invoke(ctx, "/tempopb.Pusher/Push", in, out, opts...)
+	if err != nil {
+		return nil, err
 	}
-	return len(dAtA) - i, nil
+	return out, nil
 }
 
-func (m *SearchMetrics) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+func (c *pusherClient) PushBytes(ctx context.Context, in *PushBytesRequest, opts ...grpc.CallOption) (*PushResponse, error) {
+	out := new(PushResponse)
+	err := c.cc.Invoke(ctx, "/tempopb.Pusher/PushBytes", in, out, opts...)
 	if err != nil {
 		return nil, err
 	}
-	return dAtA[:n], nil
+	return out, nil
 }
 
-func (m *SearchMetrics) MarshalTo(dAtA []byte) (int, error) {
-	size := m.Size()
-	return m.MarshalToSizedBuffer(dAtA[:size])
+// PusherServer is the server API for Pusher service.
+type PusherServer interface {
+	Push(context.Context, *PushRequest) (*PushResponse, error)
+	PushBytes(context.Context, *PushBytesRequest) (*PushResponse, error)
 }
 
-func (m *SearchMetrics) MarshalToSizedBuffer(dAtA []byte) (int, error) {
-	i := len(dAtA)
-	_ = i
-	var l int
-	_ = l
-	if m.SkippedBlocks != 0 {
-		i = encodeVarintTempo(dAtA, i, uint64(m.SkippedBlocks))
-		i--
-		dAtA[i] = 0x20
+// UnimplementedPusherServer can be embedded to have forward compatible implementations.
+type UnimplementedPusherServer struct {
+}
+
+func (*UnimplementedPusherServer) Push(ctx context.Context, req *PushRequest) (*PushResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Push not implemented")
+}
+func (*UnimplementedPusherServer) PushBytes(ctx context.Context, req *PushBytesRequest) (*PushResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PushBytes not implemented")
+}
+
+func RegisterPusherServer(s *grpc.Server, srv PusherServer) {
+	s.RegisterService(&_Pusher_serviceDesc, srv)
+}
+
+func _Pusher_Push_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PushRequest)
+	if err := dec(in); err != nil {
+		return nil, err
 	}
-	if m.InspectedBlocks != 0 {
-		i = encodeVarintTempo(dAtA, i, uint64(m.InspectedBlocks))
-		i--
-		dAtA[i] = 0x18
+	if interceptor == nil {
+		return srv.(PusherServer).Push(ctx, in)
 	}
-	if m.InspectedBytes != 0 {
-		i = encodeVarintTempo(dAtA, i, uint64(m.InspectedBytes))
-		i--
-		dAtA[i] = 0x10
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/tempopb.Pusher/Push",
 	}
-	if m.InspectedTraces != 0 {
-		i = encodeVarintTempo(dAtA, i, uint64(m.InspectedTraces))
-		i--
-		dAtA[i] = 0x8
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PusherServer).Push(ctx, req.(*PushRequest))
 	}
-	return len(dAtA) - i, nil
+	return interceptor(ctx, in, info, handler)
 }
 
-func (m *SearchTagsRequest) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalToSizedBuffer(dAtA[:size])
-	if err != nil {
+func _Pusher_PushBytes_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PushBytesRequest)
+	if err := dec(in); err != nil {
 		return nil, err
 	}
-	return dAtA[:n], nil
+	if interceptor == nil {
+		return srv.(PusherServer).PushBytes(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/tempopb.Pusher/PushBytes",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PusherServer).PushBytes(ctx, req.(*PushBytesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
-func (m *SearchTagsRequest) MarshalTo(dAtA []byte) (int, error) {
-	size := m.Size()
-	return m.MarshalToSizedBuffer(dAtA[:size])
+var _Pusher_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "tempopb.Pusher",
+	HandlerType: (*PusherServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Push",
+			Handler:    _Pusher_Push_Handler,
+		},
+		{
+			MethodName: "PushBytes",
+			Handler:    _Pusher_PushBytes_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "pkg/tempopb/tempo.proto",
 }
 
-func (m *SearchTagsRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
-	i := len(dAtA)
-	_ = i
-	var l int
-	_ = l
-	return len(dAtA) - i, nil
+// QuerierClient is the client API for Querier service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type QuerierClient interface {
+	FindTraceByID(ctx context.Context, in *TraceByIDRequest, opts ...grpc.CallOption) (*TraceByIDResponse, error)
+	Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error)
+	SearchTags(ctx context.Context, in *SearchTagsRequest, opts ...grpc.CallOption) (*SearchTagsResponse, error)
+	SearchTagValues(ctx context.Context, in *SearchTagValuesRequest, opts ...grpc.CallOption) (*SearchTagValuesResponse, error)
+	LiveTraceStats(ctx context.Context, in *LiveTraceStatsRequest, opts ...grpc.CallOption) (*LiveTraceStatsResponse, error)
+	DeleteTrace(ctx context.Context, in *DeleteTraceRequest, opts ...grpc.CallOption) (*DeleteTraceResponse, error)
 }
 
-func (m *SearchTagsResponse) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalToSizedBuffer(dAtA[:size])
-	if err != nil {
-		return nil, err
-	}
-	return dAtA[:n], nil
+type querierClient struct {
+	cc *grpc.ClientConn
 }
 
-func (m *SearchTagsResponse) MarshalTo(dAtA []byte) (int, error) {
-	size := m.Size()
-	return m.MarshalToSizedBuffer(dAtA[:size])
+func NewQuerierClient(cc *grpc.ClientConn) QuerierClient {
+	return &querierClient{cc}
 }
 
-func (m *SearchTagsResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
-	i := len(dAtA)
-	_ = i
-	var l int
-	_ = l
-	if len(m.TagNames) > 0 {
-		for iNdEx := len(m.TagNames) - 1; iNdEx >= 0; iNdEx-- {
-			i -= len(m.TagNames[iNdEx])
-			copy(dAtA[i:], m.TagNames[iNdEx])
-			i = encodeVarintTempo(dAtA, i, uint64(len(m.TagNames[iNdEx])))
-			i--
-			dAtA[i] = 0xa
-		}
+func (c *querierClient) FindTraceByID(ctx context.Context, in *TraceByIDRequest, opts ...grpc.CallOption) (*TraceByIDResponse, error) {
+	out := new(TraceByIDResponse)
+	err := c.cc.Invoke(ctx, "/tempopb.Querier/FindTraceByID", in, out, opts...)
+	if err != nil {
+		return nil, err
 	}
-	return len(dAtA) - i, nil
+	return out, nil
 }
 
-func (m *SearchTagValuesRequest) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+func (c *querierClient) Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error) {
+	out := new(SearchResponse)
+	err := c.cc.Invoke(ctx, "/tempopb.Querier/Search", in, out, opts...)
 	if err != nil {
 		return nil, err
 	}
-	return dAtA[:n], nil
-}
-
-func (m *SearchTagValuesRequest) MarshalTo(dAtA []byte) (int, error) {
-	size := m.Size()
-	return m.MarshalToSizedBuffer(dAtA[:size])
+	return out, nil
 }
 
-func (m *SearchTagValuesRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
-	i := len(dAtA)
-	_ = i
-	var l int
-	_ = l
-	if len(m.TagName) > 0 {
-		i -= len(m.TagName)
-		copy(dAtA[i:], m.TagName)
-		i = encodeVarintTempo(dAtA, i, uint64(len(m.TagName)))
-		i--
-		dAtA[i] = 0xa
+func (c *querierClient) SearchTags(ctx context.Context, in *SearchTagsRequest, opts ...grpc.CallOption) (*SearchTagsResponse, error) {
+	out := new(SearchTagsResponse)
+	err := c.cc.Invoke(ctx, "/tempopb.Querier/SearchTags", in, out, opts...)
+	if err != nil {
+		return nil, err
 	}
-	return len(dAtA) - i, nil
+	return out, nil
 }
 
-func (m *SearchTagValuesResponse) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+func (c *querierClient) SearchTagValues(ctx context.Context, in *SearchTagValuesRequest, opts ...grpc.CallOption) (*SearchTagValuesResponse, error) {
+	out := new(SearchTagValuesResponse)
+	err := c.cc.Invoke(ctx, "/tempopb.Querier/SearchTagValues", in, out, opts...)
 	if err != nil {
 		return nil, err
 	}
-	return dAtA[:n], nil
-}
-
-func (m *SearchTagValuesResponse) MarshalTo(dAtA []byte) (int, error) {
-	size := m.Size()
-	return m.MarshalToSizedBuffer(dAtA[:size])
+	return out, nil
 }
 
-func (m *SearchTagValuesResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
-	i := len(dAtA)
-	_ = i
-	var l int
-	_ = l
-	if len(m.TagValues) > 0 {
-		for iNdEx := len(m.TagValues) - 1; iNdEx >= 0; iNdEx-- {
-			i -= len(m.TagValues[iNdEx])
-			copy(dAtA[i:], m.TagValues[iNdEx])
-			i = encodeVarintTempo(dAtA, i, uint64(len(m.TagValues[iNdEx])))
-			i--
-			dAtA[i] = 0xa
-		}
+func (c *querierClient) LiveTraceStats(ctx context.Context, in *LiveTraceStatsRequest, opts ...grpc.CallOption) (*LiveTraceStatsResponse, error) {
+	out := new(LiveTraceStatsResponse)
+	err := c.cc.Invoke(ctx, "/tempopb.Querier/LiveTraceStats", in, out, opts...)
+	if err != nil {
+		return nil, err
 	}
-	return len(dAtA) - i, nil
+	return out, nil
 }
 
-func (m *Trace) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+func (c *querierClient) DeleteTrace(ctx context.Context, in *DeleteTraceRequest, opts ...grpc.CallOption) (*DeleteTraceResponse, error) {
+	out := new(DeleteTraceResponse)
+	err := c.cc.Invoke(ctx, "/tempopb.Querier/DeleteTrace", in, out, opts...)
 	if err != nil {
 		return nil, err
 	}
-	return dAtA[:n], nil
+	return out, nil
 }
 
-func (m *Trace) MarshalTo(dAtA []byte) (int, error) {
-	size := m.Size()
-	return m.MarshalToSizedBuffer(dAtA[:size])
+// QuerierServer is the server API for Querier service.
+type QuerierServer interface {
+	FindTraceByID(context.Context, *TraceByIDRequest) (*TraceByIDResponse, error)
+	Search(context.Context, *SearchRequest) (*SearchResponse, error)
+	SearchTags(context.Context, *SearchTagsRequest) (*SearchTagsResponse, error)
+	SearchTagValues(context.Context, *SearchTagValuesRequest) (*SearchTagValuesResponse, error)
+	LiveTraceStats(context.Context, *LiveTraceStatsRequest) (*LiveTraceStatsResponse, error)
+	DeleteTrace(context.Context, *DeleteTraceRequest) (*DeleteTraceResponse, error)
 }
 
-func (m *Trace) MarshalToSizedBuffer(dAtA []byte) (int, error) {
-	i := len(dAtA)
-	_ = i
-	var l int
-	_ = l
-	if len(m.Batches) > 0 {
-		for iNdEx := len(m.Batches) - 1; iNdEx >= 0; iNdEx-- {
-			{
-				size, err := m.Batches[iNdEx].MarshalToSizedBuffer(dAtA[:i])
-				if err != nil {
-					return 0, err
-				}
-				i -= size
-				i = encodeVarintTempo(dAtA, i, uint64(size))
-			}
-			i--
-			dAtA[i] = 0xa
-		}
-	}
-	return len(dAtA) - i, nil
+// UnimplementedQuerierServer can be embedded to have forward compatible implementations.
+type UnimplementedQuerierServer struct {
 }
 
-func (m *PushRequest) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalToSizedBuffer(dAtA[:size])
-	if err != nil {
-		return nil, err
-	}
-	return dAtA[:n], nil
+func (*UnimplementedQuerierServer) FindTraceByID(ctx context.Context, req *TraceByIDRequest) (*TraceByIDResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method FindTraceByID not implemented")
+}
+func (*UnimplementedQuerierServer) Search(ctx context.Context, req *SearchRequest) (*SearchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Search not implemented")
+}
+func (*UnimplementedQuerierServer) SearchTags(ctx context.Context, req *SearchTagsRequest) (*SearchTagsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SearchTags not implemented")
+}
+func (*UnimplementedQuerierServer) SearchTagValues(ctx context.Context, req *SearchTagValuesRequest) (*SearchTagValuesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SearchTagValues not implemented")
+}
+func (*UnimplementedQuerierServer) LiveTraceStats(ctx context.Context, req *LiveTraceStatsRequest) (*LiveTraceStatsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method LiveTraceStats not implemented")
+}
+func (*UnimplementedQuerierServer) DeleteTrace(ctx context.Context, req *DeleteTraceRequest) (*DeleteTraceResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteTrace not implemented")
 }
 
-func (m *PushRequest) MarshalTo(dAtA []byte) (int, error) {
-	size := m.Size()
-	return m.MarshalToSizedBuffer(dAtA[:size])
+func RegisterQuerierServer(s *grpc.Server, srv QuerierServer) {
+	s.RegisterService(&_Querier_serviceDesc, srv)
 }
 
-func (m *PushRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
-	i := len(dAtA)
-	_ = i
-	var l int
-	_ = l
-	if m.Batch != nil {
-		{
-			size, err := m.Batch.MarshalToSizedBuffer(dAtA[:i])
-			if err != nil {
-				return 0, err
-			}
-			i -= size
-			i = encodeVarintTempo(dAtA, i, uint64(size))
-		}
-		i--
-		dAtA[i] = 0xa
+func _Querier_FindTraceByID_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TraceByIDRequest)
+	if err := dec(in); err != nil {
+		return nil, err
 	}
-	return len(dAtA) - i, nil
+	if interceptor == nil {
+		return srv.(QuerierServer).FindTraceByID(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/tempopb.Querier/FindTraceByID",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(QuerierServer).FindTraceByID(ctx, req.(*TraceByIDRequest))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
-func (m *PushResponse) Marshal() (dAtA []byte, err error) {
+func _Querier_Search_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(QuerierServer).Search(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/tempopb.Querier/Search",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(QuerierServer).Search(ctx, req.(*SearchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Querier_SearchTags_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchTagsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(QuerierServer).SearchTags(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/tempopb.Querier/SearchTags",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(QuerierServer).SearchTags(ctx, req.(*SearchTagsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Querier_SearchTagValues_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchTagValuesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(QuerierServer).SearchTagValues(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/tempopb.Querier/SearchTagValues",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(QuerierServer).SearchTagValues(ctx, req.(*SearchTagValuesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Querier_LiveTraceStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LiveTraceStatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(QuerierServer).LiveTraceStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/tempopb.Querier/LiveTraceStats",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(QuerierServer).LiveTraceStats(ctx, req.(*LiveTraceStatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Querier_DeleteTrace_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteTraceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(QuerierServer).DeleteTrace(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/tempopb.Querier/DeleteTrace",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(QuerierServer).DeleteTrace(ctx, req.(*DeleteTraceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _Querier_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "tempopb.Querier",
+	HandlerType: (*QuerierServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "FindTraceByID",
+			Handler:    _Querier_FindTraceByID_Handler,
+		},
+		{
+			MethodName: "Search",
+			Handler:    _Querier_Search_Handler,
+		},
+		{
+			MethodName: "SearchTags",
+			Handler:    _Querier_SearchTags_Handler,
+		},
+		{
+			MethodName: "SearchTagValues",
+			Handler:    _Querier_SearchTagValues_Handler,
+		},
+		{
+			MethodName: "LiveTraceStats",
+			Handler:    _Querier_LiveTraceStats_Handler,
+		},
+		{
+			MethodName: "DeleteTrace",
+			Handler:    _Querier_DeleteTrace_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "pkg/tempopb/tempo.proto",
+}
+
+func (m *TraceByIDRequest) Marshal() (dAtA []byte, err error) {
 	size := m.Size()
 	dAtA = make([]byte, size)
 	n, err := m.MarshalToSizedBuffer(dAtA[:size])
@@ -1653,20 +1905,53 @@ func (m *PushResponse) Marshal() (dAtA []byte, err error) {
 	return dAtA[:n], nil
 }
 
-func (m *PushResponse) MarshalTo(dAtA []byte) (int, error) {
+func (m *TraceByIDRequest) MarshalTo(dAtA []byte) (int, error) {
 	size := m.Size()
 	return m.MarshalToSizedBuffer(dAtA[:size])
 }
 
-func (m *PushResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+func (m *TraceByIDRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	i := len(dAtA)
 	_ = i
 	var l int
 	_ = l
+	if m.MaxBytes != 0 {
+		i = encodeVarintTempo(dAtA, i, uint64(m.MaxBytes))
+		i--
+		dAtA[i] = 0x30
+	}
+	if len(m.QueryMode) > 0 {
+		i -= len(m.QueryMode)
+		copy(dAtA[i:], m.QueryMode)
+		i = encodeVarintTempo(dAtA, i, uint64(len(m.QueryMode)))
+		i--
+		dAtA[i] = 0x2a
+	}
+	if len(m.BlockEnd) > 0 {
+		i -= len(m.BlockEnd)
+		copy(dAtA[i:], m.BlockEnd)
+		i = encodeVarintTempo(dAtA, i, uint64(len(m.BlockEnd)))
+		i--
+		dAtA[i] = 0x1a
+	}
+	if len(m.BlockStart) > 0 {
+		i -= len(m.BlockStart)
+		copy(dAtA[i:], m.BlockStart)
+		i = encodeVarintTempo(dAtA, i, uint64(len(m.BlockStart)))
+		i--
+		dAtA[i] = 0x12
+	}
+	if len(m.TraceID) > 0 {
+		i -= len(m.TraceID)
+		copy(dAtA[i:], m.TraceID)
+		i = encodeVarintTempo(dAtA, i, uint64(len(m.TraceID)))
+		i--
+		dAtA[i] = 0xa
+	}
 	return len(dAtA) - i, nil
 }
 
-func (m *PushBytesRequest) Marshal() (dAtA []byte, err error) {
+func (m *TraceByIDResponse) Marshal() (dAtA []byte, err error) {
 	size := m.Size()
 	dAtA = make([]byte, size)
 	n, err := m.MarshalToSizedBuffer(dAtA[:size])
@@ -1676,76 +1961,61 @@ func (m *PushBytesRequest) Marshal() (dAtA []byte, err error) {
 	return dAtA[:n], nil
 }
 
-func (m *PushBytesRequest) MarshalTo(dAtA []byte) (int, error) {
+func (m *TraceByIDResponse) MarshalTo(dAtA []byte) (int, error) {
 	size := m.Size()
 	return m.MarshalToSizedBuffer(dAtA[:size])
 }
 
-func (m *PushBytesRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+func (m *TraceByIDResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	i := len(dAtA)
 	_ = i
 	var l int
 	_ = l
-	if len(m.SearchData) > 0 {
-		for iNdEx := len(m.SearchData) - 1; iNdEx >= 0; iNdEx-- {
-			{
-				size := m.SearchData[iNdEx].Size()
-				i -= size
-				if _, err := m.SearchData[iNdEx].MarshalTo(dAtA[i:]); err != nil {
-					return 0, err
-				}
-				i = encodeVarintTempo(dAtA, i, uint64(size))
-			}
+	if len(m.Warnings) > 0 {
+		for iNdEx := len(m.Warnings) - 1; iNdEx >= 0; iNdEx-- {
+			i -= len(m.Warnings[iNdEx])
+			copy(dAtA[i:], m.Warnings[iNdEx])
+			i = encodeVarintTempo(dAtA, i, uint64(len(m.Warnings[iNdEx])))
 			i--
 			dAtA[i] = 0x22
 		}
 	}
-	if len(m.Ids) > 0 {
-		for iNdEx := len(m.Ids) - 1; iNdEx >= 0; iNdEx-- {
-			{
-				size := m.Ids[iNdEx].Size()
-				i -= size
-				if _, err := m.Ids[iNdEx].MarshalTo(dAtA[i:]); err != nil {
-					return 0, err
-				}
-				i = encodeVarintTempo(dAtA, i, uint64(size))
-			}
-			i--
-			dAtA[i] = 0x1a
+	if m.Partial {
+		i--
+		if m.Partial {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
 		}
+		i--
+		dAtA[i] = 0x18
 	}
-	if len(m.Traces) > 0 {
-		for iNdEx := len(m.Traces) - 1; iNdEx >= 0; iNdEx-- {
-			{
-				size := m.Traces[iNdEx].Size()
-				i -= size
-				if _, err := m.Traces[iNdEx].MarshalTo(dAtA[i:]); err != nil {
-					return 0, err
-				}
-				i = encodeVarintTempo(dAtA, i, uint64(size))
-			}
-			i--
-			dAtA[i] = 0x12
+	if m.TraceTruncated {
+		i--
+		if m.TraceTruncated {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
 		}
+		i--
+		dAtA[i] = 0x10
 	}
-	if len(m.Requests) > 0 {
-		for iNdEx := len(m.Requests) - 1; iNdEx >= 0; iNdEx-- {
-			{
-				size := m.Requests[iNdEx].Size()
-				i -= size
-				if _, err := m.Requests[iNdEx].MarshalTo(dAtA[i:]); err != nil {
-					return 0, err
-				}
-				i = encodeVarintTempo(dAtA, i, uint64(size))
+	if m.Trace != nil {
+		{
+			size, err := m.Trace.MarshalToSizedBuffer(dAtA[:i])
+			if err != nil {
+				return 0, err
 			}
-			i--
-			dAtA[i] = 0xa
+			i -= size
+			i = encodeVarintTempo(dAtA, i, uint64(size))
 		}
+		i--
+		dAtA[i] = 0xa
 	}
 	return len(dAtA) - i, nil
 }
 
-func (m *TraceBytes) Marshal() (dAtA []byte, err error) {
+func (m *TraceByIDBatchRequest) Marshal() (dAtA []byte, err error) {
 	size := m.Size()
 	dAtA = make([]byte, size)
 	n, err := m.MarshalToSizedBuffer(dAtA[:size])
@@ -1755,21 +2025,21 @@ func (m *TraceBytes) Marshal() (dAtA []byte, err error) {
 	return dAtA[:n], nil
 }
 
-func (m *TraceBytes) MarshalTo(dAtA []byte) (int, error) {
+func (m *TraceByIDBatchRequest) MarshalTo(dAtA []byte) (int, error) {
 	size := m.Size()
 	return m.MarshalToSizedBuffer(dAtA[:size])
 }
 
-func (m *TraceBytes) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+func (m *TraceByIDBatchRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	i := len(dAtA)
 	_ = i
 	var l int
 	_ = l
-	if len(m.Traces) > 0 {
-		for iNdEx := len(m.Traces) - 1; iNdEx >= 0; iNdEx-- {
-			i -= len(m.Traces[iNdEx])
-			copy(dAtA[i:], m.Traces[iNdEx])
-			i = encodeVarintTempo(dAtA, i, uint64(len(m.Traces[iNdEx])))
+	if len(m.TraceIDs) > 0 {
+		for iNdEx := len(m.TraceIDs) - 1; iNdEx >= 0; iNdEx-- {
+			i -= len(m.TraceIDs[iNdEx])
+			copy(dAtA[i:], m.TraceIDs[iNdEx])
+			i = encodeVarintTempo(dAtA, i, uint64(len(m.TraceIDs[iNdEx])))
 			i--
 			dAtA[i] = 0xa
 		}
@@ -1777,292 +2047,2570 @@ func (m *TraceBytes) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	return len(dAtA) - i, nil
 }
 
-func encodeVarintTempo(dAtA []byte, offset int, v uint64) int {
-	offset -= sovTempo(v)
-	base := offset
-	for v >= 1<<7 {
-		dAtA[offset] = uint8(v&0x7f | 0x80)
-		v >>= 7
-		offset++
+func (m *TraceByIDBatchResult) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
 	}
-	dAtA[offset] = uint8(v)
-	return base
+	return dAtA[:n], nil
 }
-func (m *TraceByIDRequest) Size() (n int) {
-	if m == nil {
-		return 0
-	}
-	var l int
-	_ = l
-	l = len(m.TraceID)
-	if l > 0 {
-		n += 1 + l + sovTempo(uint64(l))
-	}
-	l = len(m.BlockStart)
-	if l > 0 {
-		n += 1 + l + sovTempo(uint64(l))
-	}
-	l = len(m.BlockEnd)
-	if l > 0 {
-		n += 1 + l + sovTempo(uint64(l))
-	}
-	l = len(m.QueryMode)
-	if l > 0 {
-		n += 1 + l + sovTempo(uint64(l))
-	}
-	return n
+
+func (m *TraceByIDBatchResult) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
 }
 
-func (m *TraceByIDResponse) Size() (n int) {
-	if m == nil {
-		return 0
-	}
+func (m *TraceByIDBatchResult) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
 	var l int
 	_ = l
-	if m.Trace != nil {
-		l = m.Trace.Size()
-		n += 1 + l + sovTempo(uint64(l))
+	if len(m.Warnings) > 0 {
+		for iNdEx := len(m.Warnings) - 1; iNdEx >= 0; iNdEx-- {
+			i -= len(m.Warnings[iNdEx])
+			copy(dAtA[i:], m.Warnings[iNdEx])
+			i = encodeVarintTempo(dAtA, i, uint64(len(m.Warnings[iNdEx])))
+			i--
+			dAtA[i] = 0x3a
+		}
 	}
-	return n
-}
-
-func (m *SearchRequest) Size() (n int) {
-	if m == nil {
-		return 0
+	if m.Partial {
+		i--
+		if m.Partial {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i--
+		dAtA[i] = 0x30
 	}
-	var l int
-	_ = l
-	if len(m.Tags) > 0 {
-		for k, v := range m.Tags {
-			_ = k
-			_ = v
-			mapEntrySize := 1 + len(k) + sovTempo(uint64(len(k))) + 1 + len(v) + sovTempo(uint64(len(v)))
-			n += mapEntrySize + 1 + sovTempo(uint64(mapEntrySize))
+	if m.TraceTruncated {
+		i--
+		if m.TraceTruncated {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
 		}
+		i--
+		dAtA[i] = 0x28
 	}
-	if m.MinDurationMs != 0 {
-		n += 1 + sovTempo(uint64(m.MinDurationMs))
+	if len(m.Error) > 0 {
+		i -= len(m.Error)
+		copy(dAtA[i:], m.Error)
+		i = encodeVarintTempo(dAtA, i, uint64(len(m.Error)))
+		i--
+		dAtA[i] = 0x22
 	}
-	if m.MaxDurationMs != 0 {
-		n += 1 + sovTempo(uint64(m.MaxDurationMs))
+	if m.Trace != nil {
+		{
+			size, err := m.Trace.MarshalToSizedBuffer(dAtA[:i])
+			if err != nil {
+				return 0, err
+			}
+			i -= size
+			i = encodeVarintTempo(dAtA, i, uint64(size))
+		}
+		i--
+		dAtA[i] = 0x1a
 	}
-	if m.Limit != 0 {
-		n += 1 + sovTempo(uint64(m.Limit))
+	if len(m.Status) > 0 {
+		i -= len(m.Status)
+		copy(dAtA[i:], m.Status)
+		i = encodeVarintTempo(dAtA, i, uint64(len(m.Status)))
+		i--
+		dAtA[i] = 0x12
 	}
-	return n
+	if len(m.TraceID) > 0 {
+		i -= len(m.TraceID)
+		copy(dAtA[i:], m.TraceID)
+		i = encodeVarintTempo(dAtA, i, uint64(len(m.TraceID)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
 }
 
-func (m *SearchResponse) Size() (n int) {
-	if m == nil {
-		return 0
+func (m *TraceByIDBatchResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
 	}
+	return dAtA[:n], nil
+}
+
+func (m *TraceByIDBatchResponse) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *TraceByIDBatchResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
 	var l int
 	_ = l
-	if len(m.Traces) > 0 {
-		for _, e := range m.Traces {
-			l = e.Size()
-			n += 1 + l + sovTempo(uint64(l))
+	if len(m.Results) > 0 {
+		for iNdEx := len(m.Results) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.Results[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintTempo(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0xa
 		}
 	}
-	if m.Metrics != nil {
-		l = m.Metrics.Size()
-		n += 1 + l + sovTempo(uint64(l))
-	}
-	return n
+	return len(dAtA) - i, nil
 }
 
-func (m *TraceSearchMetadata) Size() (n int) {
-	if m == nil {
-		return 0
-	}
-	var l int
-	_ = l
-	l = len(m.TraceID)
-	if l > 0 {
-		n += 1 + l + sovTempo(uint64(l))
-	}
-	l = len(m.RootServiceName)
-	if l > 0 {
-		n += 1 + l + sovTempo(uint64(l))
-	}
-	l = len(m.RootTraceName)
-	if l > 0 {
-		n += 1 + l + sovTempo(uint64(l))
-	}
-	if m.StartTimeUnixNano != 0 {
-		n += 1 + sovTempo(uint64(m.StartTimeUnixNano))
-	}
-	if m.DurationMs != 0 {
-		n += 1 + sovTempo(uint64(m.DurationMs))
+func (m *SearchRequest) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
 	}
-	return n
+	return dAtA[:n], nil
 }
 
-func (m *SearchMetrics) Size() (n int) {
-	if m == nil {
-		return 0
-	}
+func (m *SearchRequest) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *SearchRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
 	var l int
 	_ = l
-	if m.InspectedTraces != 0 {
-		n += 1 + sovTempo(uint64(m.InspectedTraces))
+	if m.Limit != 0 {
+		i = encodeVarintTempo(dAtA, i, uint64(m.Limit))
+		i--
+		dAtA[i] = 0x20
 	}
-	if m.InspectedBytes != 0 {
-		n += 1 + sovTempo(uint64(m.InspectedBytes))
+	if m.MaxDurationMs != 0 {
+		i = encodeVarintTempo(dAtA, i, uint64(m.MaxDurationMs))
+		i--
+		dAtA[i] = 0x18
 	}
-	if m.InspectedBlocks != 0 {
-		n += 1 + sovTempo(uint64(m.InspectedBlocks))
+	if m.MinDurationMs != 0 {
+		i = encodeVarintTempo(dAtA, i, uint64(m.MinDurationMs))
+		i--
+		dAtA[i] = 0x10
 	}
-	if m.SkippedBlocks != 0 {
-		n += 1 + sovTempo(uint64(m.SkippedBlocks))
+	if len(m.Tags) > 0 {
+		for k := range m.Tags {
+			v := m.Tags[k]
+			baseI := i
+			i -= len(v)
+			copy(dAtA[i:], v)
+			i = encodeVarintTempo(dAtA, i, uint64(len(v)))
+			i--
+			dAtA[i] = 0x12
+			i -= len(k)
+			copy(dAtA[i:], k)
+			i = encodeVarintTempo(dAtA, i, uint64(len(k)))
+			i--
+			dAtA[i] = 0xa
+			i = encodeVarintTempo(dAtA, i, uint64(baseI-i))
+			i--
+			dAtA[i] = 0xa
+		}
 	}
-	return n
+	return len(dAtA) - i, nil
 }
 
-func (m *SearchTagsRequest) Size() (n int) {
-	if m == nil {
-		return 0
+func (m *SearchResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
 	}
-	var l int
-	_ = l
-	return n
+	return dAtA[:n], nil
 }
 
-func (m *SearchTagsResponse) Size() (n int) {
-	if m == nil {
-		return 0
-	}
-	var l int
-	_ = l
-	if len(m.TagNames) > 0 {
-		for _, s := range m.TagNames {
-			l = len(s)
-			n += 1 + l + sovTempo(uint64(l))
-		}
-	}
-	return n
+func (m *SearchResponse) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
 }
 
-func (m *SearchTagValuesRequest) Size() (n int) {
-	if m == nil {
-		return 0
-	}
+func (m *SearchResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
 	var l int
 	_ = l
-	l = len(m.TagName)
-	if l > 0 {
-		n += 1 + l + sovTempo(uint64(l))
-	}
-	return n
-}
-
-func (m *SearchTagValuesResponse) Size() (n int) {
-	if m == nil {
-		return 0
+	if m.Metrics != nil {
+		{
+			size, err := m.Metrics.MarshalToSizedBuffer(dAtA[:i])
+			if err != nil {
+				return 0, err
+			}
+			i -= size
+			i = encodeVarintTempo(dAtA, i, uint64(size))
+		}
+		i--
+		dAtA[i] = 0x12
 	}
-	var l int
-	_ = l
-	if len(m.TagValues) > 0 {
-		for _, s := range m.TagValues {
-			l = len(s)
-			n += 1 + l + sovTempo(uint64(l))
+	if len(m.Traces) > 0 {
+		for iNdEx := len(m.Traces) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.Traces[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintTempo(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0xa
 		}
 	}
-	return n
+	return len(dAtA) - i, nil
 }
 
-func (m *Trace) Size() (n int) {
-	if m == nil {
-		return 0
+func (m *TraceSearchMetadata) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
 	}
+	return dAtA[:n], nil
+}
+
+func (m *TraceSearchMetadata) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *TraceSearchMetadata) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
 	var l int
 	_ = l
-	if len(m.Batches) > 0 {
-		for _, e := range m.Batches {
-			l = e.Size()
-			n += 1 + l + sovTempo(uint64(l))
-		}
+	if m.DurationMs != 0 {
+		i = encodeVarintTempo(dAtA, i, uint64(m.DurationMs))
+		i--
+		dAtA[i] = 0x28
 	}
-	return n
+	if m.StartTimeUnixNano != 0 {
+		i = encodeVarintTempo(dAtA, i, uint64(m.StartTimeUnixNano))
+		i--
+		dAtA[i] = 0x20
+	}
+	if len(m.RootTraceName) > 0 {
+		i -= len(m.RootTraceName)
+		copy(dAtA[i:], m.RootTraceName)
+		i = encodeVarintTempo(dAtA, i, uint64(len(m.RootTraceName)))
+		i--
+		dAtA[i] = 0x1a
+	}
+	if len(m.RootServiceName) > 0 {
+		i -= len(m.RootServiceName)
+		copy(dAtA[i:], m.RootServiceName)
+		i = encodeVarintTempo(dAtA, i, uint64(len(m.RootServiceName)))
+		i--
+		dAtA[i] = 0x12
+	}
+	if len(m.TraceID) > 0 {
+		i -= len(m.TraceID)
+		copy(dAtA[i:], m.TraceID)
+		i = encodeVarintTempo(dAtA, i, uint64(len(m.TraceID)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
 }
 
-func (m *PushRequest) Size() (n int) {
-	if m == nil {
-		return 0
+func (m *SearchMetrics) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
 	}
+	return dAtA[:n], nil
+}
+
+func (m *SearchMetrics) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *SearchMetrics) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
 	var l int
 	_ = l
-	if m.Batch != nil {
-		l = m.Batch.Size()
-		n += 1 + l + sovTempo(uint64(l))
+	if m.SkippedBlocks != 0 {
+		i = encodeVarintTempo(dAtA, i, uint64(m.SkippedBlocks))
+		i--
+		dAtA[i] = 0x20
 	}
-	return n
+	if m.InspectedBlocks != 0 {
+		i = encodeVarintTempo(dAtA, i, uint64(m.InspectedBlocks))
+		i--
+		dAtA[i] = 0x18
+	}
+	if m.InspectedBytes != 0 {
+		i = encodeVarintTempo(dAtA, i, uint64(m.InspectedBytes))
+		i--
+		dAtA[i] = 0x10
+	}
+	if m.InspectedTraces != 0 {
+		i = encodeVarintTempo(dAtA, i, uint64(m.InspectedTraces))
+		i--
+		dAtA[i] = 0x8
+	}
+	return len(dAtA) - i, nil
 }
 
-func (m *PushResponse) Size() (n int) {
-	if m == nil {
-		return 0
+func (m *SearchTagsRequest) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
 	}
+	return dAtA[:n], nil
+}
+
+func (m *SearchTagsRequest) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *SearchTagsRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
 	var l int
 	_ = l
-	return n
+	return len(dAtA) - i, nil
 }
 
-func (m *PushBytesRequest) Size() (n int) {
-	if m == nil {
-		return 0
+func (m *SearchTagsResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
 	}
+	return dAtA[:n], nil
+}
+
+func (m *SearchTagsResponse) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *SearchTagsResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
 	var l int
 	_ = l
-	if len(m.Requests) > 0 {
-		for _, e := range m.Requests {
-			l = e.Size()
-			n += 1 + l + sovTempo(uint64(l))
-		}
-	}
-	if len(m.Traces) > 0 {
-		for _, e := range m.Traces {
-			l = e.Size()
-			n += 1 + l + sovTempo(uint64(l))
+	if len(m.TagNames) > 0 {
+		for iNdEx := len(m.TagNames) - 1; iNdEx >= 0; iNdEx-- {
+			i -= len(m.TagNames[iNdEx])
+			copy(dAtA[i:], m.TagNames[iNdEx])
+			i = encodeVarintTempo(dAtA, i, uint64(len(m.TagNames[iNdEx])))
+			i--
+			dAtA[i] = 0xa
 		}
 	}
-	if len(m.Ids) > 0 {
-		for _, e := range m.Ids {
-			l = e.Size()
-			n += 1 + l + sovTempo(uint64(l))
-		}
+	return len(dAtA) - i, nil
+}
+
+func (m *SearchTagValuesRequest) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
 	}
-	if len(m.SearchData) > 0 {
-		for _, e := range m.SearchData {
-			l = e.Size()
-			n += 1 + l + sovTempo(uint64(l))
-		}
+	return dAtA[:n], nil
+}
+
+func (m *SearchTagValuesRequest) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *SearchTagValuesRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.TagName) > 0 {
+		i -= len(m.TagName)
+		copy(dAtA[i:], m.TagName)
+		i = encodeVarintTempo(dAtA, i, uint64(len(m.TagName)))
+		i--
+		dAtA[i] = 0xa
 	}
-	return n
+	return len(dAtA) - i, nil
 }
 
-func (m *TraceBytes) Size() (n int) {
-	if m == nil {
-		return 0
+func (m *SearchTagValuesResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
 	}
+	return dAtA[:n], nil
+}
+
+func (m *SearchTagValuesResponse) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *SearchTagValuesResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
 	var l int
 	_ = l
-	if len(m.Traces) > 0 {
-		for _, b := range m.Traces {
+	if len(m.TagValues) > 0 {
+		for iNdEx := len(m.TagValues) - 1; iNdEx >= 0; iNdEx-- {
+			i -= len(m.TagValues[iNdEx])
+			copy(dAtA[i:], m.TagValues[iNdEx])
+			i = encodeVarintTempo(dAtA, i, uint64(len(m.TagValues[iNdEx])))
+			i--
+			dAtA[i] = 0xa
+		}
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *LiveTraceStatsRequest) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *LiveTraceStatsRequest) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *LiveTraceStatsRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	return len(dAtA) - i, nil
+}
+
+func (m *LiveTraceStatsResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *LiveTraceStatsResponse) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *LiveTraceStatsResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.Tenants) > 0 {
+		for iNdEx := len(m.Tenants) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.Tenants[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintTempo(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0xa
+		}
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *TenantLiveTraceStats) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *TenantLiveTraceStats) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *TenantLiveTraceStats) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.LiveTraceBytes != 0 {
+		i = encodeVarintTempo(dAtA, i, uint64(m.LiveTraceBytes))
+		i--
+		dAtA[i] = 0x18
+	}
+	if m.LiveTraces != 0 {
+		i = encodeVarintTempo(dAtA, i, uint64(m.LiveTraces))
+		i--
+		dAtA[i] = 0x10
+	}
+	if len(m.TenantID) > 0 {
+		i -= len(m.TenantID)
+		copy(dAtA[i:], m.TenantID)
+		i = encodeVarintTempo(dAtA, i, uint64(len(m.TenantID)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *DeleteTraceRequest) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *DeleteTraceRequest) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *DeleteTraceRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.TraceID) > 0 {
+		i -= len(m.TraceID)
+		copy(dAtA[i:], m.TraceID)
+		i = encodeVarintTempo(dAtA, i, uint64(len(m.TraceID)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *DeleteTraceResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *DeleteTraceResponse) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *DeleteTraceResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.SpansRemoved != 0 {
+		i = encodeVarintTempo(dAtA, i, uint64(m.SpansRemoved))
+		i--
+		dAtA[i] = 0x8
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *Trace) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *Trace) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *Trace) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.IngestionTimeUnixNano != 0 {
+		i = encodeVarintTempo(dAtA, i, uint64(m.IngestionTimeUnixNano))
+		i--
+		dAtA[i] = 0x10
+	}
+	if len(m.Batches) > 0 {
+		for iNdEx := len(m.Batches) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.Batches[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintTempo(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0xa
+		}
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *PushRequest) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *PushRequest) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *PushRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.Batch != nil {
+		{
+			size, err := m.Batch.MarshalToSizedBuffer(dAtA[:i])
+			if err != nil {
+				return 0, err
+			}
+			i -= size
+			i = encodeVarintTempo(dAtA, i, uint64(size))
+		}
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *PushResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *PushResponse) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *PushResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.TraceErrors) > 0 {
+		for iNdEx := len(m.TraceErrors) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.TraceErrors[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintTempo(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0x12
+		}
+	}
+	if m.PartialSuccess != nil {
+		{
+			size, err := m.PartialSuccess.MarshalToSizedBuffer(dAtA[:i])
+			if err != nil {
+				return 0, err
+			}
+			i -= size
+			i = encodeVarintTempo(dAtA, i, uint64(size))
+		}
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *PushPartialSuccess) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *PushPartialSuccess) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *PushPartialSuccess) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.ErrorMessage) > 0 {
+		i -= len(m.ErrorMessage)
+		copy(dAtA[i:], m.ErrorMessage)
+		i = encodeVarintTempo(dAtA, i, uint64(len(m.ErrorMessage)))
+		i--
+		dAtA[i] = 0x12
+	}
+	if m.RejectedSpans != 0 {
+		i = encodeVarintTempo(dAtA, i, uint64(m.RejectedSpans))
+		i--
+		dAtA[i] = 0x8
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *PushTraceError) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *PushTraceError) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *PushTraceError) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.Reason) > 0 {
+		i -= len(m.Reason)
+		copy(dAtA[i:], m.Reason)
+		i = encodeVarintTempo(dAtA, i, uint64(len(m.Reason)))
+		i--
+		dAtA[i] = 0x1a
+	}
+	if len(m.Error) > 0 {
+		i -= len(m.Error)
+		copy(dAtA[i:], m.Error)
+		i = encodeVarintTempo(dAtA, i, uint64(len(m.Error)))
+		i--
+		dAtA[i] = 0x12
+	}
+	if m.Index != 0 {
+		i = encodeVarintTempo(dAtA, i, uint64(m.Index))
+		i--
+		dAtA[i] = 0x8
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *PushBytesRequest) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *PushBytesRequest) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *PushBytesRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.RejectNewTraces {
+		i--
+		if m.RejectNewTraces {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i--
+		dAtA[i] = 0x38
+	}
+	if m.IngestionTimeUnixNano != 0 {
+		i = encodeVarintTempo(dAtA, i, uint64(m.IngestionTimeUnixNano))
+		i--
+		dAtA[i] = 0x30
+	}
+	if m.PartialSuccess {
+		i--
+		if m.PartialSuccess {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i--
+		dAtA[i] = 0x28
+	}
+	if len(m.SearchData) > 0 {
+		for iNdEx := len(m.SearchData) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size := m.SearchData[iNdEx].Size()
+				i -= size
+				if _, err := m.SearchData[iNdEx].MarshalTo(dAtA[i:]); err != nil {
+					return 0, err
+				}
+				i = encodeVarintTempo(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0x22
+		}
+	}
+	if len(m.Ids) > 0 {
+		for iNdEx := len(m.Ids) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size := m.Ids[iNdEx].Size()
+				i -= size
+				if _, err := m.Ids[iNdEx].MarshalTo(dAtA[i:]); err != nil {
+					return 0, err
+				}
+				i = encodeVarintTempo(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0x1a
+		}
+	}
+	if len(m.Traces) > 0 {
+		for iNdEx := len(m.Traces) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size := m.Traces[iNdEx].Size()
+				i -= size
+				if _, err := m.Traces[iNdEx].MarshalTo(dAtA[i:]); err != nil {
+					return 0, err
+				}
+				i = encodeVarintTempo(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0x12
+		}
+	}
+	if len(m.Requests) > 0 {
+		for iNdEx := len(m.Requests) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size := m.Requests[iNdEx].Size()
+				i -= size
+				if _, err := m.Requests[iNdEx].MarshalTo(dAtA[i:]); err != nil {
+					return 0, err
+				}
+				i = encodeVarintTempo(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0xa
+		}
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *TraceBytes) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *TraceBytes) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *TraceBytes) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.Traces) > 0 {
+		for iNdEx := len(m.Traces) - 1; iNdEx >= 0; iNdEx-- {
+			i -= len(m.Traces[iNdEx])
+			copy(dAtA[i:], m.Traces[iNdEx])
+			i = encodeVarintTempo(dAtA, i, uint64(len(m.Traces[iNdEx])))
+			i--
+			dAtA[i] = 0xa
+		}
+	}
+	return len(dAtA) - i, nil
+}
+
+func encodeVarintTempo(dAtA []byte, offset int, v uint64) int {
+	offset -= sovTempo(v)
+	base := offset
+	for v >= 1<<7 {
+		dAtA[offset] = uint8(v&0x7f | 0x80)
+		v >>= 7
+		offset++
+	}
+	dAtA[offset] = uint8(v)
+	return base
+}
+func (m *TraceByIDRequest) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.TraceID)
+	if l > 0 {
+		n += 1 + l + sovTempo(uint64(l))
+	}
+	l = len(m.BlockStart)
+	if l > 0 {
+		n += 1 + l + sovTempo(uint64(l))
+	}
+	l = len(m.BlockEnd)
+	if l > 0 {
+		n += 1 + l + sovTempo(uint64(l))
+	}
+	l = len(m.QueryMode)
+	if l > 0 {
+		n += 1 + l + sovTempo(uint64(l))
+	}
+	if m.MaxBytes != 0 {
+		n += 1 + sovTempo(uint64(m.MaxBytes))
+	}
+	return n
+}
+
+func (m *TraceByIDResponse) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.Trace != nil {
+		l = m.Trace.Size()
+		n += 1 + l + sovTempo(uint64(l))
+	}
+	if m.TraceTruncated {
+		n += 2
+	}
+	if m.Partial {
+		n += 2
+	}
+	if len(m.Warnings) > 0 {
+		for _, s := range m.Warnings {
+			l = len(s)
+			n += 1 + l + sovTempo(uint64(l))
+		}
+	}
+	return n
+}
+
+func (m *TraceByIDBatchRequest) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if len(m.TraceIDs) > 0 {
+		for _, s := range m.TraceIDs {
+			l = len(s)
+			n += 1 + l + sovTempo(uint64(l))
+		}
+	}
+	return n
+}
+
+func (m *TraceByIDBatchResult) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.TraceID)
+	if l > 0 {
+		n += 1 + l + sovTempo(uint64(l))
+	}
+	l = len(m.Status)
+	if l > 0 {
+		n += 1 + l + sovTempo(uint64(l))
+	}
+	if m.Trace != nil {
+		l = m.Trace.Size()
+		n += 1 + l + sovTempo(uint64(l))
+	}
+	l = len(m.Error)
+	if l > 0 {
+		n += 1 + l + sovTempo(uint64(l))
+	}
+	if m.TraceTruncated {
+		n += 2
+	}
+	if m.Partial {
+		n += 2
+	}
+	if len(m.Warnings) > 0 {
+		for _, s := range m.Warnings {
+			l = len(s)
+			n += 1 + l + sovTempo(uint64(l))
+		}
+	}
+	return n
+}
+
+func (m *TraceByIDBatchResponse) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if len(m.Results) > 0 {
+		for _, e := range m.Results {
+			l = e.Size()
+			n += 1 + l + sovTempo(uint64(l))
+		}
+	}
+	return n
+}
+
+func (m *SearchRequest) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if len(m.Tags) > 0 {
+		for k, v := range m.Tags {
+			_ = k
+			_ = v
+			mapEntrySize := 1 + len(k) + sovTempo(uint64(len(k))) + 1 + len(v) + sovTempo(uint64(len(v)))
+			n += mapEntrySize + 1 + sovTempo(uint64(mapEntrySize))
+		}
+	}
+	if m.MinDurationMs != 0 {
+		n += 1 + sovTempo(uint64(m.MinDurationMs))
+	}
+	if m.MaxDurationMs != 0 {
+		n += 1 + sovTempo(uint64(m.MaxDurationMs))
+	}
+	if m.Limit != 0 {
+		n += 1 + sovTempo(uint64(m.Limit))
+	}
+	return n
+}
+
+func (m *SearchResponse) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if len(m.Traces) > 0 {
+		for _, e := range m.Traces {
+			l = e.Size()
+			n += 1 + l + sovTempo(uint64(l))
+		}
+	}
+	if m.Metrics != nil {
+		l = m.Metrics.Size()
+		n += 1 + l + sovTempo(uint64(l))
+	}
+	return n
+}
+
+func (m *TraceSearchMetadata) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.TraceID)
+	if l > 0 {
+		n += 1 + l + sovTempo(uint64(l))
+	}
+	l = len(m.RootServiceName)
+	if l > 0 {
+		n += 1 + l + sovTempo(uint64(l))
+	}
+	l = len(m.RootTraceName)
+	if l > 0 {
+		n += 1 + l + sovTempo(uint64(l))
+	}
+	if m.StartTimeUnixNano != 0 {
+		n += 1 + sovTempo(uint64(m.StartTimeUnixNano))
+	}
+	if m.DurationMs != 0 {
+		n += 1 + sovTempo(uint64(m.DurationMs))
+	}
+	return n
+}
+
+func (m *SearchMetrics) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.InspectedTraces != 0 {
+		n += 1 + sovTempo(uint64(m.InspectedTraces))
+	}
+	if m.InspectedBytes != 0 {
+		n += 1 + sovTempo(uint64(m.InspectedBytes))
+	}
+	if m.InspectedBlocks != 0 {
+		n += 1 + sovTempo(uint64(m.InspectedBlocks))
+	}
+	if m.SkippedBlocks != 0 {
+		n += 1 + sovTempo(uint64(m.SkippedBlocks))
+	}
+	return n
+}
+
+func (m *SearchTagsRequest) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	return n
+}
+
+func (m *SearchTagsResponse) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if len(m.TagNames) > 0 {
+		for _, s := range m.TagNames {
+			l = len(s)
+			n += 1 + l + sovTempo(uint64(l))
+		}
+	}
+	return n
+}
+
+func (m *SearchTagValuesRequest) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.TagName)
+	if l > 0 {
+		n += 1 + l + sovTempo(uint64(l))
+	}
+	return n
+}
+
+func (m *SearchTagValuesResponse) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if len(m.TagValues) > 0 {
+		for _, s := range m.TagValues {
+			l = len(s)
+			n += 1 + l + sovTempo(uint64(l))
+		}
+	}
+	return n
+}
+
+func (m *LiveTraceStatsRequest) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	return n
+}
+
+func (m *LiveTraceStatsResponse) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if len(m.Tenants) > 0 {
+		for _, e := range m.Tenants {
+			l = e.Size()
+			n += 1 + l + sovTempo(uint64(l))
+		}
+	}
+	return n
+}
+
+func (m *TenantLiveTraceStats) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.TenantID)
+	if l > 0 {
+		n += 1 + l + sovTempo(uint64(l))
+	}
+	if m.LiveTraces != 0 {
+		n += 1 + sovTempo(uint64(m.LiveTraces))
+	}
+	if m.LiveTraceBytes != 0 {
+		n += 1 + sovTempo(uint64(m.LiveTraceBytes))
+	}
+	return n
+}
+
+func (m *DeleteTraceRequest) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.TraceID)
+	if l > 0 {
+		n += 1 + l + sovTempo(uint64(l))
+	}
+	return n
+}
+
+func (m *DeleteTraceResponse) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.SpansRemoved != 0 {
+		n += 1 + sovTempo(uint64(m.SpansRemoved))
+	}
+	return n
+}
+
+func (m *Trace) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if len(m.Batches) > 0 {
+		for _, e := range m.Batches {
+			l = e.Size()
+			n += 1 + l + sovTempo(uint64(l))
+		}
+	}
+	if m.IngestionTimeUnixNano != 0 {
+		n += 1 + sovTempo(uint64(m.IngestionTimeUnixNano))
+	}
+	return n
+}
+
+func (m *PushRequest) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.Batch != nil {
+		l = m.Batch.Size()
+		n += 1 + l + sovTempo(uint64(l))
+	}
+	return n
+}
+
+func (m *PushResponse) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.PartialSuccess != nil {
+		l = m.PartialSuccess.Size()
+		n += 1 + l + sovTempo(uint64(l))
+	}
+	if len(m.TraceErrors) > 0 {
+		for _, e := range m.TraceErrors {
+			l = e.Size()
+			n += 1 + l + sovTempo(uint64(l))
+		}
+	}
+	return n
+}
+
+func (m *PushPartialSuccess) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.RejectedSpans != 0 {
+		n += 1 + sovTempo(uint64(m.RejectedSpans))
+	}
+	l = len(m.ErrorMessage)
+	if l > 0 {
+		n += 1 + l + sovTempo(uint64(l))
+	}
+	return n
+}
+
+func (m *PushTraceError) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.Index != 0 {
+		n += 1 + sovTempo(uint64(m.Index))
+	}
+	l = len(m.Error)
+	if l > 0 {
+		n += 1 + l + sovTempo(uint64(l))
+	}
+	l = len(m.Reason)
+	if l > 0 {
+		n += 1 + l + sovTempo(uint64(l))
+	}
+	return n
+}
+
+func (m *PushBytesRequest) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if len(m.Requests) > 0 {
+		for _, e := range m.Requests {
+			l = e.Size()
+			n += 1 + l + sovTempo(uint64(l))
+		}
+	}
+	if len(m.Traces) > 0 {
+		for _, e := range m.Traces {
+			l = e.Size()
+			n += 1 + l + sovTempo(uint64(l))
+		}
+	}
+	if len(m.Ids) > 0 {
+		for _, e := range m.Ids {
+			l = e.Size()
+			n += 1 + l + sovTempo(uint64(l))
+		}
+	}
+	if len(m.SearchData) > 0 {
+		for _, e := range m.SearchData {
+			l = e.Size()
+			n += 1 + l + sovTempo(uint64(l))
+		}
+	}
+	if m.PartialSuccess {
+		n += 2
+	}
+	if m.IngestionTimeUnixNano != 0 {
+		n += 1 + sovTempo(uint64(m.IngestionTimeUnixNano))
+	}
+	if m.RejectNewTraces {
+		n += 2
+	}
+	return n
+}
+
+func (m *TraceBytes) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if len(m.Traces) > 0 {
+		for _, b := range m.Traces {
 			l = len(b)
 			n += 1 + l + sovTempo(uint64(l))
 		}
 	}
-	return n
-}
+	return n
+}
+
+func sovTempo(x uint64) (n int) {
+	return (math_bits.Len64(x|1) + 6) / 7
+}
+func sozTempo(x uint64) (n int) {
+	return sovTempo(uint64((x << 1) ^ uint64((int64(x) >> 63))))
+}
+func (m *TraceByIDRequest) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowTempo
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: TraceByIDRequest: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: TraceByIDRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field TraceID", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTempo
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthTempo
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthTempo
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.TraceID = append(m.TraceID[:0], dAtA[iNdEx:postIndex]...)
+			if m.TraceID == nil {
+				m.TraceID = []byte{}
+			}
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field BlockStart", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTempo
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthTempo
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthTempo
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.BlockStart = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field BlockEnd", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTempo
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthTempo
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthTempo
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.BlockEnd = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 5:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field QueryMode", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTempo
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthTempo
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthTempo
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.QueryMode = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 6:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field MaxBytes", wireType)
+			}
+			m.MaxBytes = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTempo
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.MaxBytes |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipTempo(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthTempo
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *TraceByIDResponse) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowTempo
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: TraceByIDResponse: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: TraceByIDResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Trace", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTempo
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthTempo
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthTempo
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.Trace == nil {
+				m.Trace = &Trace{}
+			}
+			if err := m.Trace.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field TraceTruncated", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTempo
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.TraceTruncated = bool(v != 0)
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Partial", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTempo
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.Partial = bool(v != 0)
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Warnings", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTempo
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthTempo
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthTempo
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Warnings = append(m.Warnings, string(dAtA[iNdEx:postIndex]))
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipTempo(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthTempo
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *TraceByIDBatchRequest) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowTempo
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: TraceByIDBatchRequest: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: TraceByIDBatchRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field TraceIDs", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTempo
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthTempo
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthTempo
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.TraceIDs = append(m.TraceIDs, string(dAtA[iNdEx:postIndex]))
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipTempo(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthTempo
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *TraceByIDBatchResult) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowTempo
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: TraceByIDBatchResult: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: TraceByIDBatchResult: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field TraceID", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTempo
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthTempo
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthTempo
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.TraceID = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Status", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTempo
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthTempo
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthTempo
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Status = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Trace", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTempo
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthTempo
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthTempo
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.Trace == nil {
+				m.Trace = &Trace{}
+			}
+			if err := m.Trace.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Error", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTempo
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthTempo
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthTempo
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Error = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 5:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field TraceTruncated", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTempo
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.TraceTruncated = bool(v != 0)
+		case 6:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Partial", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTempo
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.Partial = bool(v != 0)
+		case 7:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Warnings", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTempo
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthTempo
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthTempo
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Warnings = append(m.Warnings, string(dAtA[iNdEx:postIndex]))
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipTempo(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthTempo
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *TraceByIDBatchResponse) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowTempo
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: TraceByIDBatchResponse: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: TraceByIDBatchResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Results", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTempo
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthTempo
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthTempo
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Results = append(m.Results, &TraceByIDBatchResult{})
+			if err := m.Results[len(m.Results)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipTempo(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthTempo
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *SearchRequest) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowTempo
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: SearchRequest: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: SearchRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Tags", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTempo
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthTempo
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthTempo
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.Tags == nil {
+				m.Tags = make(map[string]string)
+			}
+			var mapkey string
+			var mapvalue string
+			for iNdEx < postIndex {
+				entryPreIndex := iNdEx
+				var wire uint64
+				for shift := uint(0); ; shift += 7 {
+					if shift >= 64 {
+						return ErrIntOverflowTempo
+					}
+					if iNdEx >= l {
+						return io.ErrUnexpectedEOF
+					}
+					b := dAtA[iNdEx]
+					iNdEx++
+					wire |= uint64(b&0x7F) << shift
+					if b < 0x80 {
+						break
+					}
+				}
+				fieldNum := int32(wire >> 3)
+				if fieldNum == 1 {
+					var stringLenmapkey uint64
+					for shift := uint(0); ; shift += 7 {
+						if shift >= 64 {
+							return ErrIntOverflowTempo
+						}
+						if iNdEx >= l {
+							return io.ErrUnexpectedEOF
+						}
+						b := dAtA[iNdEx]
+						iNdEx++
+						stringLenmapkey |= uint64(b&0x7F) << shift
+						if b < 0x80 {
+							break
+						}
+					}
+					intStringLenmapkey := int(stringLenmapkey)
+					if intStringLenmapkey < 0 {
+						return ErrInvalidLengthTempo
+					}
+					postStringIndexmapkey := iNdEx + intStringLenmapkey
+					if postStringIndexmapkey < 0 {
+						return ErrInvalidLengthTempo
+					}
+					if postStringIndexmapkey > l {
+						return io.ErrUnexpectedEOF
+					}
+					mapkey = string(dAtA[iNdEx:postStringIndexmapkey])
+					iNdEx = postStringIndexmapkey
+				} else if fieldNum == 2 {
+					var stringLenmapvalue uint64
+					for shift := uint(0); ; shift += 7 {
+						if shift >= 64 {
+							return ErrIntOverflowTempo
+						}
+						if iNdEx >= l {
+							return io.ErrUnexpectedEOF
+						}
+						b := dAtA[iNdEx]
+						iNdEx++
+						stringLenmapvalue |= uint64(b&0x7F) << shift
+						if b < 0x80 {
+							break
+						}
+					}
+					intStringLenmapvalue := int(stringLenmapvalue)
+					if intStringLenmapvalue < 0 {
+						return ErrInvalidLengthTempo
+					}
+					postStringIndexmapvalue := iNdEx + intStringLenmapvalue
+					if postStringIndexmapvalue < 0 {
+						return ErrInvalidLengthTempo
+					}
+					if postStringIndexmapvalue > l {
+						return io.ErrUnexpectedEOF
+					}
+					mapvalue = string(dAtA[iNdEx:postStringIndexmapvalue])
+					iNdEx = postStringIndexmapvalue
+				} else {
+					iNdEx = entryPreIndex
+					skippy, err := skipTempo(dAtA[iNdEx:])
+					if err != nil {
+						return err
+					}
+					if (skippy < 0) || (iNdEx+skippy) < 0 {
+						return ErrInvalidLengthTempo
+					}
+					if (iNdEx + skippy) > postIndex {
+						return io.ErrUnexpectedEOF
+					}
+					iNdEx += skippy
+				}
+			}
+			m.Tags[mapkey] = mapvalue
+			iNdEx = postIndex
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field MinDurationMs", wireType)
+			}
+			m.MinDurationMs = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTempo
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.MinDurationMs |= uint32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field MaxDurationMs", wireType)
+			}
+			m.MaxDurationMs = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTempo
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.MaxDurationMs |= uint32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Limit", wireType)
+			}
+			m.Limit = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTempo
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Limit |= uint32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipTempo(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthTempo
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *SearchResponse) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowTempo
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: SearchResponse: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: SearchResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Traces", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTempo
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthTempo
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthTempo
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Traces = append(m.Traces, &TraceSearchMetadata{})
+			if err := m.Traces[len(m.Traces)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Metrics", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTempo
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthTempo
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthTempo
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.Metrics == nil {
+				m.Metrics = &SearchMetrics{}
+			}
+			if err := m.Metrics.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipTempo(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthTempo
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
 
-func sovTempo(x uint64) (n int) {
-	return (math_bits.Len64(x|1) + 6) / 7
-}
-func sozTempo(x uint64) (n int) {
-	return sovTempo(uint64((x << 1) ^ uint64((int64(x) >> 63))))
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
 }
-func (m *TraceByIDRequest) Unmarshal(dAtA []byte) error {
+func (m *TraceSearchMetadata) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -2085,17 +4633,17 @@ func (m *TraceByIDRequest) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: TraceByIDRequest: wiretype end group for non-group")
+			return fmt.Errorf("proto: TraceSearchMetadata: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: TraceByIDRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: TraceSearchMetadata: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
 			if wireType != 2 {
 				return fmt.Errorf("proto: wrong wireType = %d for field TraceID", wireType)
 			}
-			var byteLen int
+			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowTempo
@@ -2105,29 +4653,27 @@ func (m *TraceByIDRequest) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				byteLen |= int(b&0x7F) << shift
+				stringLen |= uint64(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if byteLen < 0 {
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
 				return ErrInvalidLengthTempo
 			}
-			postIndex := iNdEx + byteLen
+			postIndex := iNdEx + intStringLen
 			if postIndex < 0 {
 				return ErrInvalidLengthTempo
 			}
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.TraceID = append(m.TraceID[:0], dAtA[iNdEx:postIndex]...)
-			if m.TraceID == nil {
-				m.TraceID = []byte{}
-			}
+			m.TraceID = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
 		case 2:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field BlockStart", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field RootServiceName", wireType)
 			}
 			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
@@ -2155,11 +4701,11 @@ func (m *TraceByIDRequest) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.BlockStart = string(dAtA[iNdEx:postIndex])
+			m.RootServiceName = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
 		case 3:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field BlockEnd", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field RootTraceName", wireType)
 			}
 			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
@@ -2187,13 +4733,32 @@ func (m *TraceByIDRequest) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.BlockEnd = string(dAtA[iNdEx:postIndex])
+			m.RootTraceName = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field StartTimeUnixNano", wireType)
+			}
+			m.StartTimeUnixNano = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTempo
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.StartTimeUnixNano |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		case 5:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field QueryMode", wireType)
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field DurationMs", wireType)
 			}
-			var stringLen uint64
+			m.DurationMs = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowTempo
@@ -2203,24 +4768,187 @@ func (m *TraceByIDRequest) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				stringLen |= uint64(b&0x7F) << shift
+				m.DurationMs |= uint32(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			intStringLen := int(stringLen)
-			if intStringLen < 0 {
+		default:
+			iNdEx = preIndex
+			skippy, err := skipTempo(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
 				return ErrInvalidLengthTempo
 			}
-			postIndex := iNdEx + intStringLen
-			if postIndex < 0 {
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *SearchMetrics) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowTempo
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: SearchMetrics: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: SearchMetrics: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field InspectedTraces", wireType)
+			}
+			m.InspectedTraces = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTempo
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.InspectedTraces |= uint32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field InspectedBytes", wireType)
+			}
+			m.InspectedBytes = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTempo
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.InspectedBytes |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field InspectedBlocks", wireType)
+			}
+			m.InspectedBlocks = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTempo
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.InspectedBlocks |= uint32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field SkippedBlocks", wireType)
+			}
+			m.SkippedBlocks = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTempo
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.SkippedBlocks |= uint32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipTempo(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
 				return ErrInvalidLengthTempo
 			}
-			if postIndex > l {
+			if (iNdEx + skippy) > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.QueryMode = string(dAtA[iNdEx:postIndex])
-			iNdEx = postIndex
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *SearchTagsRequest) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowTempo
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: SearchTagsRequest: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: SearchTagsRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
 		default:
 			iNdEx = preIndex
 			skippy, err := skipTempo(dAtA[iNdEx:])
@@ -2242,7 +4970,7 @@ func (m *TraceByIDRequest) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *TraceByIDResponse) Unmarshal(dAtA []byte) error {
+func (m *SearchTagsResponse) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -2265,17 +4993,17 @@ func (m *TraceByIDResponse) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: TraceByIDResponse: wiretype end group for non-group")
+			return fmt.Errorf("proto: SearchTagsResponse: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: TraceByIDResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: SearchTagsResponse: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Trace", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field TagNames", wireType)
 			}
-			var msglen int
+			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowTempo
@@ -2285,27 +5013,23 @@ func (m *TraceByIDResponse) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				msglen |= int(b&0x7F) << shift
+				stringLen |= uint64(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if msglen < 0 {
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
 				return ErrInvalidLengthTempo
 			}
-			postIndex := iNdEx + msglen
+			postIndex := iNdEx + intStringLen
 			if postIndex < 0 {
 				return ErrInvalidLengthTempo
 			}
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			if m.Trace == nil {
-				m.Trace = &Trace{}
-			}
-			if err := m.Trace.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
-			}
+			m.TagNames = append(m.TagNames, string(dAtA[iNdEx:postIndex]))
 			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
@@ -2328,7 +5052,7 @@ func (m *TraceByIDResponse) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *SearchRequest) Unmarshal(dAtA []byte) error {
+func (m *SearchTagValuesRequest) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -2351,17 +5075,17 @@ func (m *SearchRequest) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: SearchRequest: wiretype end group for non-group")
+			return fmt.Errorf("proto: SearchTagValuesRequest: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: SearchRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: SearchTagValuesRequest: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Tags", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field TagName", wireType)
 			}
-			var msglen int
+			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowTempo
@@ -2371,176 +5095,24 @@ func (m *SearchRequest) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				msglen |= int(b&0x7F) << shift
+				stringLen |= uint64(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if msglen < 0 {
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
 				return ErrInvalidLengthTempo
 			}
-			postIndex := iNdEx + msglen
+			postIndex := iNdEx + intStringLen
 			if postIndex < 0 {
 				return ErrInvalidLengthTempo
 			}
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			if m.Tags == nil {
-				m.Tags = make(map[string]string)
-			}
-			var mapkey string
-			var mapvalue string
-			for iNdEx < postIndex {
-				entryPreIndex := iNdEx
-				var wire uint64
-				for shift := uint(0); ; shift += 7 {
-					if shift >= 64 {
-						return ErrIntOverflowTempo
-					}
-					if iNdEx >= l {
-						return io.ErrUnexpectedEOF
-					}
-					b := dAtA[iNdEx]
-					iNdEx++
-					wire |= uint64(b&0x7F) << shift
-					if b < 0x80 {
-						break
-					}
-				}
-				fieldNum := int32(wire >> 3)
-				if fieldNum == 1 {
-					var stringLenmapkey uint64
-					for shift := uint(0); ; shift += 7 {
-						if shift >= 64 {
-							return ErrIntOverflowTempo
-						}
-						if iNdEx >= l {
-							return io.ErrUnexpectedEOF
-						}
-						b := dAtA[iNdEx]
-						iNdEx++
-						stringLenmapkey |= uint64(b&0x7F) << shift
-						if b < 0x80 {
-							break
-						}
-					}
-					intStringLenmapkey := int(stringLenmapkey)
-					if intStringLenmapkey < 0 {
-						return ErrInvalidLengthTempo
-					}
-					postStringIndexmapkey := iNdEx + intStringLenmapkey
-					if postStringIndexmapkey < 0 {
-						return ErrInvalidLengthTempo
-					}
-					if postStringIndexmapkey > l {
-						return io.ErrUnexpectedEOF
-					}
-					mapkey = string(dAtA[iNdEx:postStringIndexmapkey])
-					iNdEx = postStringIndexmapkey
-				} else if fieldNum == 2 {
-					var stringLenmapvalue uint64
-					for shift := uint(0); ; shift += 7 {
-						if shift >= 64 {
-							return ErrIntOverflowTempo
-						}
-						if iNdEx >= l {
-							return io.ErrUnexpectedEOF
-						}
-						b := dAtA[iNdEx]
-						iNdEx++
-						stringLenmapvalue |= uint64(b&0x7F) << shift
-						if b < 0x80 {
-							break
-						}
-					}
-					intStringLenmapvalue := int(stringLenmapvalue)
-					if intStringLenmapvalue < 0 {
-						return ErrInvalidLengthTempo
-					}
-					postStringIndexmapvalue := iNdEx + intStringLenmapvalue
-					if postStringIndexmapvalue < 0 {
-						return ErrInvalidLengthTempo
-					}
-					if postStringIndexmapvalue > l {
-						return io.ErrUnexpectedEOF
-					}
-					mapvalue = string(dAtA[iNdEx:postStringIndexmapvalue])
-					iNdEx = postStringIndexmapvalue
-				} else {
-					iNdEx = entryPreIndex
-					skippy, err := skipTempo(dAtA[iNdEx:])
-					if err != nil {
-						return err
-					}
-					if (skippy < 0) || (iNdEx+skippy) < 0 {
-						return ErrInvalidLengthTempo
-					}
-					if (iNdEx + skippy) > postIndex {
-						return io.ErrUnexpectedEOF
-					}
-					iNdEx += skippy
-				}
-			}
-			m.Tags[mapkey] = mapvalue
+			m.TagName = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
-		case 2:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field MinDurationMs", wireType)
-			}
-			m.MinDurationMs = 0
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowTempo
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				m.MinDurationMs |= uint32(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-		case 3:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field MaxDurationMs", wireType)
-			}
-			m.MaxDurationMs = 0
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowTempo
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				m.MaxDurationMs |= uint32(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-		case 4:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Limit", wireType)
-			}
-			m.Limit = 0
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowTempo
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				m.Limit |= uint32(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skipTempo(dAtA[iNdEx:])
@@ -2562,7 +5134,7 @@ func (m *SearchRequest) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *SearchResponse) Unmarshal(dAtA []byte) error {
+func (m *SearchTagValuesResponse) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -2585,17 +5157,17 @@ func (m *SearchResponse) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: SearchResponse: wiretype end group for non-group")
+			return fmt.Errorf("proto: SearchTagValuesResponse: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: SearchResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: SearchTagValuesResponse: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Traces", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field TagValues", wireType)
 			}
-			var msglen int
+			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowTempo
@@ -2605,62 +5177,74 @@ func (m *SearchResponse) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				msglen |= int(b&0x7F) << shift
+				stringLen |= uint64(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if msglen < 0 {
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
 				return ErrInvalidLengthTempo
 			}
-			postIndex := iNdEx + msglen
+			postIndex := iNdEx + intStringLen
 			if postIndex < 0 {
 				return ErrInvalidLengthTempo
 			}
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Traces = append(m.Traces, &TraceSearchMetadata{})
-			if err := m.Traces[len(m.Traces)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
-			}
+			m.TagValues = append(m.TagValues, string(dAtA[iNdEx:postIndex]))
 			iNdEx = postIndex
-		case 2:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Metrics", wireType)
-			}
-			var msglen int
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowTempo
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				msglen |= int(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipTempo(dAtA[iNdEx:])
+			if err != nil {
+				return err
 			}
-			if msglen < 0 {
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
 				return ErrInvalidLengthTempo
 			}
-			postIndex := iNdEx + msglen
-			if postIndex < 0 {
-				return ErrInvalidLengthTempo
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *LiveTraceStatsRequest) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowTempo
 			}
-			if postIndex > l {
+			if iNdEx >= l {
 				return io.ErrUnexpectedEOF
 			}
-			if m.Metrics == nil {
-				m.Metrics = &SearchMetrics{}
-			}
-			if err := m.Metrics.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
 			}
-			iNdEx = postIndex
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: LiveTraceStatsRequest: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: LiveTraceStatsRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
 		default:
 			iNdEx = preIndex
 			skippy, err := skipTempo(dAtA[iNdEx:])
@@ -2682,7 +5266,7 @@ func (m *SearchResponse) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *TraceSearchMetadata) Unmarshal(dAtA []byte) error {
+func (m *LiveTraceStatsResponse) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -2705,49 +5289,17 @@ func (m *TraceSearchMetadata) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: TraceSearchMetadata: wiretype end group for non-group")
+			return fmt.Errorf("proto: LiveTraceStatsResponse: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: TraceSearchMetadata: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: LiveTraceStatsResponse: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field TraceID", wireType)
-			}
-			var stringLen uint64
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowTempo
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				stringLen |= uint64(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-			intStringLen := int(stringLen)
-			if intStringLen < 0 {
-				return ErrInvalidLengthTempo
-			}
-			postIndex := iNdEx + intStringLen
-			if postIndex < 0 {
-				return ErrInvalidLengthTempo
-			}
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			m.TraceID = string(dAtA[iNdEx:postIndex])
-			iNdEx = postIndex
-		case 2:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field RootServiceName", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Tenants", wireType)
 			}
-			var stringLen uint64
+			var msglen int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowTempo
@@ -2757,94 +5309,26 @@ func (m *TraceSearchMetadata) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				stringLen |= uint64(b&0x7F) << shift
+				msglen |= int(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			intStringLen := int(stringLen)
-			if intStringLen < 0 {
+			if msglen < 0 {
 				return ErrInvalidLengthTempo
 			}
-			postIndex := iNdEx + intStringLen
+			postIndex := iNdEx + msglen
 			if postIndex < 0 {
 				return ErrInvalidLengthTempo
 			}
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.RootServiceName = string(dAtA[iNdEx:postIndex])
-			iNdEx = postIndex
-		case 3:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field RootTraceName", wireType)
-			}
-			var stringLen uint64
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowTempo
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				stringLen |= uint64(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-			intStringLen := int(stringLen)
-			if intStringLen < 0 {
-				return ErrInvalidLengthTempo
-			}
-			postIndex := iNdEx + intStringLen
-			if postIndex < 0 {
-				return ErrInvalidLengthTempo
-			}
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
+			m.Tenants = append(m.Tenants, &TenantLiveTraceStats{})
+			if err := m.Tenants[len(m.Tenants)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
 			}
-			m.RootTraceName = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
-		case 4:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field StartTimeUnixNano", wireType)
-			}
-			m.StartTimeUnixNano = 0
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowTempo
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				m.StartTimeUnixNano |= uint64(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
-		case 5:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field DurationMs", wireType)
-			}
-			m.DurationMs = 0
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowTempo
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				m.DurationMs |= uint32(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
-			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skipTempo(dAtA[iNdEx:])
@@ -2866,7 +5350,7 @@ func (m *TraceSearchMetadata) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *SearchMetrics) Unmarshal(dAtA []byte) error {
+func (m *TenantLiveTraceStats) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -2889,17 +5373,17 @@ func (m *SearchMetrics) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: SearchMetrics: wiretype end group for non-group")
+			return fmt.Errorf("proto: TenantLiveTraceStats: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: SearchMetrics: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: TenantLiveTraceStats: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field InspectedTraces", wireType)
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field TenantID", wireType)
 			}
-			m.InspectedTraces = 0
+			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowTempo
@@ -2909,35 +5393,29 @@ func (m *SearchMetrics) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.InspectedTraces |= uint32(b&0x7F) << shift
+				stringLen |= uint64(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-		case 2:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field InspectedBytes", wireType)
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthTempo
 			}
-			m.InspectedBytes = 0
-			for shift := uint(0); ; shift += 7 {
-				if shift >= 64 {
-					return ErrIntOverflowTempo
-				}
-				if iNdEx >= l {
-					return io.ErrUnexpectedEOF
-				}
-				b := dAtA[iNdEx]
-				iNdEx++
-				m.InspectedBytes |= uint64(b&0x7F) << shift
-				if b < 0x80 {
-					break
-				}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthTempo
 			}
-		case 3:
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.TenantID = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
 			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field InspectedBlocks", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field LiveTraces", wireType)
 			}
-			m.InspectedBlocks = 0
+			m.LiveTraces = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowTempo
@@ -2947,16 +5425,16 @@ func (m *SearchMetrics) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.InspectedBlocks |= uint32(b&0x7F) << shift
+				m.LiveTraces |= uint64(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-		case 4:
+		case 3:
 			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field SkippedBlocks", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field LiveTraceBytes", wireType)
 			}
-			m.SkippedBlocks = 0
+			m.LiveTraceBytes = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowTempo
@@ -2966,7 +5444,7 @@ func (m *SearchMetrics) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.SkippedBlocks |= uint32(b&0x7F) << shift
+				m.LiveTraceBytes |= uint64(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
@@ -2992,7 +5470,7 @@ func (m *SearchMetrics) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *SearchTagsRequest) Unmarshal(dAtA []byte) error {
+func (m *DeleteTraceRequest) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -3015,12 +5493,46 @@ func (m *SearchTagsRequest) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: SearchTagsRequest: wiretype end group for non-group")
+			return fmt.Errorf("proto: DeleteTraceRequest: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: SearchTagsRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: DeleteTraceRequest: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field TraceID", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTempo
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthTempo
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthTempo
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.TraceID = append(m.TraceID[:0], dAtA[iNdEx:postIndex]...)
+			if m.TraceID == nil {
+				m.TraceID = []byte{}
+			}
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipTempo(dAtA[iNdEx:])
@@ -3042,7 +5554,7 @@ func (m *SearchTagsRequest) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *SearchTagsResponse) Unmarshal(dAtA []byte) error {
+func (m *DeleteTraceResponse) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -3065,17 +5577,17 @@ func (m *SearchTagsResponse) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: SearchTagsResponse: wiretype end group for non-group")
+			return fmt.Errorf("proto: DeleteTraceResponse: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: SearchTagsResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: DeleteTraceResponse: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field TagNames", wireType)
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field SpansRemoved", wireType)
 			}
-			var stringLen uint64
+			m.SpansRemoved = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowTempo
@@ -3085,24 +5597,11 @@ func (m *SearchTagsResponse) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				stringLen |= uint64(b&0x7F) << shift
+				m.SpansRemoved |= uint64(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			intStringLen := int(stringLen)
-			if intStringLen < 0 {
-				return ErrInvalidLengthTempo
-			}
-			postIndex := iNdEx + intStringLen
-			if postIndex < 0 {
-				return ErrInvalidLengthTempo
-			}
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			m.TagNames = append(m.TagNames, string(dAtA[iNdEx:postIndex]))
-			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipTempo(dAtA[iNdEx:])
@@ -3124,7 +5623,7 @@ func (m *SearchTagsResponse) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *SearchTagValuesRequest) Unmarshal(dAtA []byte) error {
+func (m *Trace) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -3147,17 +5646,17 @@ func (m *SearchTagValuesRequest) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: SearchTagValuesRequest: wiretype end group for non-group")
+			return fmt.Errorf("proto: Trace: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: SearchTagValuesRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: Trace: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field TagName", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Batches", wireType)
 			}
-			var stringLen uint64
+			var msglen int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowTempo
@@ -3167,24 +5666,45 @@ func (m *SearchTagValuesRequest) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				stringLen |= uint64(b&0x7F) << shift
+				msglen |= int(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			intStringLen := int(stringLen)
-			if intStringLen < 0 {
+			if msglen < 0 {
 				return ErrInvalidLengthTempo
 			}
-			postIndex := iNdEx + intStringLen
+			postIndex := iNdEx + msglen
 			if postIndex < 0 {
 				return ErrInvalidLengthTempo
 			}
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.TagName = string(dAtA[iNdEx:postIndex])
+			m.Batches = append(m.Batches, &v1.ResourceSpans{})
+			if err := m.Batches[len(m.Batches)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
 			iNdEx = postIndex
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field IngestionTimeUnixNano", wireType)
+			}
+			m.IngestionTimeUnixNano = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTempo
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.IngestionTimeUnixNano |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skipTempo(dAtA[iNdEx:])
@@ -3206,7 +5726,7 @@ func (m *SearchTagValuesRequest) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *SearchTagValuesResponse) Unmarshal(dAtA []byte) error {
+func (m *PushRequest) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -3229,17 +5749,17 @@ func (m *SearchTagValuesResponse) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: SearchTagValuesResponse: wiretype end group for non-group")
+			return fmt.Errorf("proto: PushRequest: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: SearchTagValuesResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: PushRequest: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field TagValues", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Batch", wireType)
 			}
-			var stringLen uint64
+			var msglen int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowTempo
@@ -3249,23 +5769,27 @@ func (m *SearchTagValuesResponse) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				stringLen |= uint64(b&0x7F) << shift
+				msglen |= int(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			intStringLen := int(stringLen)
-			if intStringLen < 0 {
+			if msglen < 0 {
 				return ErrInvalidLengthTempo
 			}
-			postIndex := iNdEx + intStringLen
+			postIndex := iNdEx + msglen
 			if postIndex < 0 {
 				return ErrInvalidLengthTempo
 			}
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.TagValues = append(m.TagValues, string(dAtA[iNdEx:postIndex]))
+			if m.Batch == nil {
+				m.Batch = &v1.ResourceSpans{}
+			}
+			if err := m.Batch.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
 			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
@@ -3288,7 +5812,7 @@ func (m *SearchTagValuesResponse) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *Trace) Unmarshal(dAtA []byte) error {
+func (m *PushResponse) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -3311,15 +5835,15 @@ func (m *Trace) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: Trace: wiretype end group for non-group")
+			return fmt.Errorf("proto: PushResponse: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: Trace: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: PushResponse: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Batches", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field PartialSuccess", wireType)
 			}
 			var msglen int
 			for shift := uint(0); ; shift += 7 {
@@ -3346,8 +5870,44 @@ func (m *Trace) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Batches = append(m.Batches, &v1.ResourceSpans{})
-			if err := m.Batches[len(m.Batches)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+			if m.PartialSuccess == nil {
+				m.PartialSuccess = &PushPartialSuccess{}
+			}
+			if err := m.PartialSuccess.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field TraceErrors", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTempo
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthTempo
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthTempo
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.TraceErrors = append(m.TraceErrors, &PushTraceError{})
+			if err := m.TraceErrors[len(m.TraceErrors)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
 				return err
 			}
 			iNdEx = postIndex
@@ -3372,7 +5932,7 @@ func (m *Trace) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *PushRequest) Unmarshal(dAtA []byte) error {
+func (m *PushPartialSuccess) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -3395,17 +5955,36 @@ func (m *PushRequest) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: PushRequest: wiretype end group for non-group")
+			return fmt.Errorf("proto: PushPartialSuccess: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: PushRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: PushPartialSuccess: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field RejectedSpans", wireType)
+			}
+			m.RejectedSpans = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTempo
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.RejectedSpans |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 2:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Batch", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field ErrorMessage", wireType)
 			}
-			var msglen int
+			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowTempo
@@ -3415,27 +5994,23 @@ func (m *PushRequest) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				msglen |= int(b&0x7F) << shift
+				stringLen |= uint64(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if msglen < 0 {
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
 				return ErrInvalidLengthTempo
 			}
-			postIndex := iNdEx + msglen
+			postIndex := iNdEx + intStringLen
 			if postIndex < 0 {
 				return ErrInvalidLengthTempo
 			}
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			if m.Batch == nil {
-				m.Batch = &v1.ResourceSpans{}
-			}
-			if err := m.Batch.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
-			}
+			m.ErrorMessage = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
@@ -3458,7 +6033,7 @@ func (m *PushRequest) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *PushResponse) Unmarshal(dAtA []byte) error {
+func (m *PushTraceError) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -3481,12 +6056,95 @@ func (m *PushResponse) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: PushResponse: wiretype end group for non-group")
+			return fmt.Errorf("proto: PushTraceError: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: PushResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: PushTraceError: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Index", wireType)
+			}
+			m.Index = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTempo
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Index |= uint32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Error", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTempo
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthTempo
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthTempo
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Error = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Reason", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTempo
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthTempo
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthTempo
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Reason = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipTempo(dAtA[iNdEx:])
@@ -3677,6 +6335,65 @@ func (m *PushBytesRequest) Unmarshal(dAtA []byte) error {
 				return err
 			}
 			iNdEx = postIndex
+		case 5:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field PartialSuccess", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTempo
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.PartialSuccess = bool(v != 0)
+		case 6:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field IngestionTimeUnixNano", wireType)
+			}
+			m.IngestionTimeUnixNano = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTempo
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.IngestionTimeUnixNano |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 7:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field RejectNewTraces", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTempo
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.RejectNewTraces = bool(v != 0)
 		default:
 			iNdEx = preIndex
 			skippy, err := skipTempo(dAtA[iNdEx:])
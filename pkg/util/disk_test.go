@@ -0,0 +1,17 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVolumeUtilization(t *testing.T) {
+	u, err := VolumeUtilization(t.TempDir())
+	require.NoError(t, err)
+	assert.True(t, u >= 0 && u <= 1)
+
+	_, err = VolumeUtilization("/does/not/exist")
+	assert.Error(t, err)
+}
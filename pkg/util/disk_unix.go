@@ -0,0 +1,22 @@
+//go:build !windows
+// +build !windows
+
+package util
+
+import "syscall"
+
+// VolumeUtilization returns the used fraction of the volume of path as seen by unprivileged users.
+func VolumeUtilization(path string) (float64, error) {
+	var stat syscall.Statfs_t
+	err := syscall.Statfs(path, &stat)
+	if err != nil {
+		return 0, err
+	}
+
+	used := stat.Blocks - stat.Bfree
+	total := used + stat.Bavail
+	if total == 0 {
+		return 0, nil
+	}
+	return float64(used) / float64(total), nil
+}
@@ -0,0 +1,27 @@
+//go:build windows
+// +build windows
+
+package util
+
+import "golang.org/x/sys/windows"
+
+// VolumeUtilization returns the used fraction of the volume of path as seen by the user running Tempo.
+func VolumeUtilization(path string) (float64, error) {
+	dir, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+
+	var freeBytesAvailable, totalBytes, totalFreeBytes uint64
+	err = windows.GetDiskFreeSpaceEx(dir, &freeBytesAvailable, &totalBytes, &totalFreeBytes)
+	if err != nil {
+		return 0, err
+	}
+
+	used := totalBytes - totalFreeBytes
+	total := used + freeBytesAvailable
+	if total == 0 {
+		return 0, nil
+	}
+	return float64(used) / float64(total), nil
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/gorilla/mux"
@@ -16,6 +17,12 @@ const (
 	AcceptHeaderKey         = "Accept"
 	ProtobufTypeHeaderValue = "application/protobuf"
 	JSONTypeHeaderValue     = "application/json"
+	// JaegerJSONTypeHeaderValue requests traces in the JSON model of the Jaeger UI
+	JaegerJSONTypeHeaderValue = "application/vnd.jaeger+json"
+	// EventStreamTypeHeaderValue requests server-sent events
+	EventStreamTypeHeaderValue = "text/event-stream"
+	// NDJSONTypeHeaderValue requests newline delimited JSON, one JSON object per line
+	NDJSONTypeHeaderValue = "application/x-ndjson"
 )
 
 func ParseTraceID(r *http.Request) ([]byte, error) {
@@ -33,6 +40,42 @@ func ParseTraceID(r *http.Request) ([]byte, error) {
 	return byteID, nil
 }
 
+// NegotiateTraceFormat returns the format a trace is returned in for the Accept header of a request: one of
+// ProtobufTypeHeaderValue, JSONTypeHeaderValue or JaegerJSONTypeHeaderValue. The supported media type with the highest
+// quality wins, the first one listed on ties. Requests that accept none of them get JSON.
+func NegotiateTraceFormat(accept string) string {
+	format := JSONTypeHeaderValue
+	best := 0.0
+	for _, mediaRange := range strings.Split(accept, ",") {
+		parts := strings.Split(mediaRange, ";")
+		mediaType := strings.ToLower(strings.TrimSpace(parts[0]))
+
+		quality := 1.0
+		for _, param := range parts[1:] {
+			kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+			if len(kv) == 2 && strings.TrimSpace(kv[0]) == "q" {
+				if q, err := strconv.ParseFloat(strings.TrimSpace(kv[1]), 64); err == nil {
+					quality = q
+				}
+			}
+		}
+		if quality <= best {
+			continue
+		}
+
+		switch mediaType {
+		case ProtobufTypeHeaderValue, JSONTypeHeaderValue, JaegerJSONTypeHeaderValue:
+			format = mediaType
+		case "*/*", "application/*":
+			format = JSONTypeHeaderValue
+		default:
+			continue
+		}
+		best = quality
+	}
+	return format
+}
+
 func HexStringToTraceID(id string) ([]byte, error) {
 	// The encoding/hex package does not handle non-hex characters.
 	// Ensure the ID has only the proper characters
@@ -106,3 +106,28 @@ func TestEqualHexStringTraceIDs(t *testing.T) {
 	assert.Nil(t, err)
 	assert.True(t, v)
 }
+
+func TestNegotiateTraceFormat(t *testing.T) {
+	tc := []struct {
+		accept   string
+		expected string
+	}{
+		{accept: "", expected: JSONTypeHeaderValue},
+		{accept: "application/protobuf", expected: ProtobufTypeHeaderValue},
+		{accept: "application/json", expected: JSONTypeHeaderValue},
+		{accept: "application/vnd.jaeger+json", expected: JaegerJSONTypeHeaderValue},
+		{accept: "*/*", expected: JSONTypeHeaderValue},
+		{accept: "text/html", expected: JSONTypeHeaderValue},
+		{accept: "text/html, application/vnd.jaeger+json", expected: JaegerJSONTypeHeaderValue},
+		{accept: "application/json, application/protobuf", expected: JSONTypeHeaderValue},
+		{accept: "application/json;q=0.5, application/protobuf", expected: ProtobufTypeHeaderValue},
+		{accept: "application/vnd.jaeger+json;q=0, */*;q=0.1", expected: JSONTypeHeaderValue},
+		{accept: "Application/VND.Jaeger+JSON; charset=utf-8", expected: JaegerJSONTypeHeaderValue},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.accept, func(t *testing.T) {
+			assert.Equal(t, tt.expected, NegotiateTraceFormat(tt.accept))
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/gogo/protobuf/proto"
 	"github.com/grafana/tempo/pkg/tempopb"
 	v1_common "github.com/grafana/tempo/pkg/tempopb/common/v1"
+	v1_resource "github.com/grafana/tempo/pkg/tempopb/resource/v1"
 	v1_trace "github.com/grafana/tempo/pkg/tempopb/trace/v1"
 )
 
@@ -124,3 +125,102 @@ func MakeRequestWithByteLimit(maxBytes int, traceID []byte) *tempopb.PushRequest
 
 	return req
 }
+
+// MakeTraceWithAllFields returns a trace with a single batch that sets every OTLP field supported by
+// tempopb, including all attribute value types, events, links, status and tracestate. It is used to
+// verify that no fields are lost between ingest and query.
+func MakeTraceWithAllFields(traceID []byte) *tempopb.Trace {
+	if len(traceID) == 0 {
+		traceID = make([]byte, 16)
+		rand.Read(traceID)
+	}
+
+	attrs := func(prefix string) []*v1_common.KeyValue {
+		return []*v1_common.KeyValue{
+			{Key: prefix + ".string", Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_StringValue{StringValue: "value"}}},
+			{Key: prefix + ".bool", Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_BoolValue{BoolValue: true}}},
+			{Key: prefix + ".int", Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_IntValue{IntValue: -42}}},
+			{Key: prefix + ".double", Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_DoubleValue{DoubleValue: 3.14}}},
+			{Key: prefix + ".array", Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_ArrayValue{ArrayValue: &v1_common.ArrayValue{
+				Values: []*v1_common.AnyValue{
+					{Value: &v1_common.AnyValue_StringValue{StringValue: "a"}},
+					{Value: &v1_common.AnyValue_IntValue{IntValue: 1}},
+				},
+			}}}},
+			{Key: prefix + ".kvlist", Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_KvlistValue{KvlistValue: &v1_common.KeyValueList{
+				Values: []*v1_common.KeyValue{
+					{Key: "nested", Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_BoolValue{BoolValue: false}}},
+				},
+			}}}},
+		}
+	}
+
+	linkedTraceID := make([]byte, 16)
+	rand.Read(linkedTraceID)
+
+	span := func(spanID, parentSpanID []byte, start uint64) *v1_trace.Span {
+		return &v1_trace.Span{
+			TraceId:                traceID,
+			SpanId:                 spanID,
+			TraceState:             "vendor1=value1,vendor2=value2",
+			ParentSpanId:           parentSpanID,
+			Name:                   "all-fields",
+			Kind:                   v1_trace.Span_SPAN_KIND_SERVER,
+			StartTimeUnixNano:      start,
+			EndTimeUnixNano:        start + 1000,
+			Attributes:             attrs("span"),
+			DroppedAttributesCount: 1,
+			Events: []*v1_trace.Span_Event{
+				{
+					TimeUnixNano:           start + 500,
+					Name:                   "event",
+					Attributes:             attrs("event"),
+					DroppedAttributesCount: 2,
+				},
+			},
+			DroppedEventsCount: 3,
+			Links: []*v1_trace.Span_Link{
+				{
+					TraceId:                linkedTraceID,
+					SpanId:                 []byte{1, 2, 3, 4, 5, 6, 7, 8},
+					TraceState:             "vendor3=value3",
+					Attributes:             attrs("link"),
+					DroppedAttributesCount: 4,
+				},
+			},
+			DroppedLinksCount: 5,
+			Status: &v1_trace.Status{
+				Code:    v1_trace.Status_STATUS_CODE_ERROR,
+				Message: "error message",
+			},
+		}
+	}
+
+	rootID := make([]byte, 8)
+	rand.Read(rootID)
+	childID := make([]byte, 8)
+	rand.Read(childID)
+
+	return &tempopb.Trace{
+		Batches: []*v1_trace.ResourceSpans{
+			{
+				Resource: &v1_resource.Resource{
+					Attributes:             attrs("resource"),
+					DroppedAttributesCount: 6,
+				},
+				InstrumentationLibrarySpans: []*v1_trace.InstrumentationLibrarySpans{
+					{
+						InstrumentationLibrary: &v1_common.InstrumentationLibrary{
+							Name:    "super library",
+							Version: "0.0.1",
+						},
+						Spans: []*v1_trace.Span{
+							span(rootID, nil, 1000),
+							span(childID, rootID, 1100),
+						},
+					},
+				},
+			},
+		},
+	}
+}
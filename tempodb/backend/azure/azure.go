@@ -8,6 +8,7 @@ import (
 	"encoding/binary"
 	"io"
 	"io/ioutil"
+	"net/http"
 	"path"
 	"strings"
 
@@ -16,6 +17,7 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/grafana/tempo/tempodb/backend"
+	"github.com/grafana/tempo/tempodb/backend/instrumentation"
 )
 
 const (
@@ -25,6 +27,14 @@ const (
 	maxParallelism = 3
 )
 
+// service codes of newer versions of the storage api that azblob has no constants for
+const (
+	serviceCodeBlobImmutableDueToPolicy        blob.ServiceCodeType = "BlobImmutableDueToPolicy"
+	serviceCodeBlobImmutableDueToLegalHold     blob.ServiceCodeType = "BlobImmutableDueToLegalHold"
+	serviceCodeAuthorizationPermissionMismatch blob.ServiceCodeType = "AuthorizationPermissionMismatch"
+	serviceCodeCrc64Mismatch                   blob.ServiceCodeType = "Crc64Mismatch"
+)
+
 type readerWriter struct {
 	cfg                *Config
 	containerURL       blob.ContainerURL
@@ -39,12 +49,15 @@ type appendTracker struct {
 func New(cfg *Config) (backend.RawReader, backend.RawWriter, backend.Compactor, error) {
 	ctx := context.Background()
 
-	container, err := GetContainer(ctx, cfg, false)
+	// the container and the hedged container share one connection pool
+	transport := newTransport(cfg)
+
+	container, err := getContainer(ctx, cfg, transport, false)
 	if err != nil {
 		return nil, nil, nil, errors.Wrap(err, "getting storage container")
 	}
 
-	hedgedContainer, err := GetContainer(ctx, cfg, true)
+	hedgedContainer, err := getContainer(ctx, cfg, transport, true)
 	if err != nil {
 		return nil, nil, nil, errors.Wrap(err, "getting hedged storage container")
 	}
@@ -60,25 +73,27 @@ func New(cfg *Config) (backend.RawReader, backend.RawWriter, backend.Compactor,
 
 // Write implements backend.Writer
 func (rw *readerWriter) Write(ctx context.Context, name string, keypath backend.KeyPath, data io.Reader, _ int64, _ bool) error {
-	return rw.writer(ctx, bufio.NewReader(data), backend.ObjectFileName(keypath, name))
+	ctx = instrumentation.WithOperation(ctx, instrumentation.OperationWrite)
+	return writeError(rw.writer(ctx, bufio.NewReader(data), backend.ObjectFileName(keypath, name)))
 }
 
 // Append implements backend.Writer
 func (rw *readerWriter) Append(ctx context.Context, name string, keypath backend.KeyPath, tracker backend.AppendTracker, buffer []byte) (backend.AppendTracker, error) {
+	ctx = instrumentation.WithOperation(ctx, instrumentation.OperationWrite)
 	var a appendTracker
 	if tracker == nil {
 		a.Name = backend.ObjectFileName(keypath, name)
 
 		err := rw.writeAll(ctx, a.Name, buffer)
 		if err != nil {
-			return nil, err
+			return nil, writeError(err)
 		}
 	} else {
 		a = tracker.(appendTracker)
 
 		err := rw.append(ctx, buffer, a.Name)
 		if err != nil {
-			return nil, err
+			return nil, writeError(err)
 		}
 	}
 
@@ -92,6 +107,7 @@ func (rw *readerWriter) CloseAppend(ctx context.Context, tracker backend.AppendT
 
 // List implements backend.Reader
 func (rw *readerWriter) List(ctx context.Context, keypath backend.KeyPath) ([]string, error) {
+	ctx = instrumentation.WithOperation(ctx, instrumentation.OperationList)
 	marker := blob.Marker{}
 	prefix := path.Join(keypath...)
 
@@ -125,6 +141,7 @@ func (rw *readerWriter) List(ctx context.Context, keypath backend.KeyPath) ([]st
 
 // Read implements backend.Reader
 func (rw *readerWriter) Read(ctx context.Context, name string, keypath backend.KeyPath, _ bool) (io.ReadCloser, int64, error) {
+	ctx = instrumentation.WithOperation(ctx, instrumentation.OperationRead)
 	span, derivedCtx := opentracing.StartSpanFromContext(ctx, "Read")
 	defer span.Finish()
 
@@ -139,6 +156,7 @@ func (rw *readerWriter) Read(ctx context.Context, name string, keypath backend.K
 
 // ReadRange implements backend.Reader
 func (rw *readerWriter) ReadRange(ctx context.Context, name string, keypath backend.KeyPath, offset uint64, buffer []byte) error {
+	ctx = instrumentation.WithOperation(ctx, instrumentation.OperationReadRange)
 	span, derivedCtx := opentracing.StartSpanFromContext(ctx, "ReadRange")
 	defer span.Finish()
 
@@ -237,13 +255,13 @@ func (rw *readerWriter) readRange(ctx context.Context, name string, offset int64
 		size = props.ContentLength() - offset
 	}
 
-	if err := blob.DownloadBlobToBuffer(context.Background(), blobURL.BlobURL, offset, size,
+	if err := blob.DownloadBlobToBuffer(ctx, blobURL.BlobURL, offset, size,
 		destBuffer, blob.DownloadFromBlobOptions{
 			BlockSize:   blob.BlobDefaultDownloadBlockSize,
 			Parallelism: maxParallelism,
 			Progress:    nil,
 			RetryReaderOptionsPerBlock: blob.RetryReaderOptions{
-				MaxRetryRequests: maxRetries,
+				MaxRetryRequests: maxBodyRetries,
 			},
 		},
 	); err != nil {
@@ -269,13 +287,13 @@ func (rw *readerWriter) readAll(ctx context.Context, name string) ([]byte, error
 
 	destBuffer := make([]byte, props.ContentLength())
 
-	if err := blob.DownloadBlobToBuffer(context.Background(), blobURL.BlobURL, 0, props.ContentLength(),
+	if err := blob.DownloadBlobToBuffer(ctx, blobURL.BlobURL, 0, props.ContentLength(),
 		destBuffer, blob.DownloadFromBlobOptions{
 			BlockSize:   blob.BlobDefaultDownloadBlockSize,
 			Parallelism: uint16(maxParallelism),
 			Progress:    nil,
 			RetryReaderOptionsPerBlock: blob.RetryReaderOptions{
-				MaxRetryRequests: maxRetries,
+				MaxRetryRequests: maxBodyRetries,
 			},
 		},
 	); err != nil {
@@ -285,13 +303,41 @@ func (rw *readerWriter) readAll(ctx context.Context, name string) ([]byte, error
 	return destBuffer, nil
 }
 
+// readError maps the storage errors of azure onto the errors of the backend.
 func readError(err error) error {
-	ret, ok := err.(blob.StorageError)
-	if !ok {
+	var storageErr blob.StorageError
+	if !errors.As(err, &storageErr) {
 		return errors.Wrap(err, "reading storage container")
 	}
-	if ret.ServiceCode() == "BlobNotFound" {
+
+	var statusCode int
+	if resp := storageErr.Response(); resp != nil {
+		statusCode = resp.StatusCode
+	}
+
+	err = errors.Wrap(err, "reading Azure blob container")
+	switch code := storageErr.ServiceCode(); {
+	case code == blob.ServiceCodeBlobNotFound:
 		return backend.ErrDoesNotExist
+	case code == blob.ServiceCodeServerBusy || statusCode == http.StatusTooManyRequests:
+		return backend.WrapError(backend.ErrThrottled, err)
+	case code == blob.ServiceCodeMd5Mismatch || code == blob.ServiceCodeInvalidMd5 || code == serviceCodeCrc64Mismatch:
+		return backend.WrapError(backend.ErrCorrupt, err)
+	case statusCode == http.StatusBadRequest:
+		return backend.WrapError(backend.ErrBadRequest, err)
+	}
+	return err
+}
+
+// writeError maps the storage errors of writes to azure onto the errors of the backend. Writes to immutable blobs and
+// writes with credentials that lack the permission to are read only.
+func writeError(err error) error {
+	var storageErr blob.StorageError
+	if errors.As(err, &storageErr) {
+		switch storageErr.ServiceCode() {
+		case serviceCodeBlobImmutableDueToPolicy, serviceCodeBlobImmutableDueToLegalHold, serviceCodeAuthorizationPermissionMismatch:
+			return backend.WrapError(backend.ErrReadOnly, errors.Wrap(err, "writing Azure blob container"))
+		}
 	}
-	return errors.Wrap(err, "reading Azure blob container")
+	return readError(err)
 }
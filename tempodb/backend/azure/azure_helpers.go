@@ -12,38 +12,47 @@ import (
 
 	"github.com/Azure/azure-pipeline-go/pipeline"
 	blob "github.com/Azure/azure-storage-blob-go/azblob"
-	"github.com/cristalhq/hedgedhttp"
 )
 
 const (
-	maxRetries         = 1
+	// maxBodyRetries is the number of times the download of a block of a blob is resumed after the body failed
+	maxBodyRetries     = 1
 	uptoHedgedRequests = 2
 )
 
 func GetContainerURL(ctx context.Context, cfg *Config, hedge bool) (blob.ContainerURL, error) {
+	return getContainerURL(ctx, cfg, newTransport(cfg), hedge)
+}
+
+// newTransport returns the default transport tuned by the transport config.
+func newTransport(cfg *Config) *http.Transport {
+	customTransport := http.DefaultTransport.(*http.Transport).Clone()
+	cfg.Transport.Apply(customTransport)
+
+	return customTransport
+}
+
+func getContainerURL(ctx context.Context, cfg *Config, customTransport *http.Transport, hedge bool) (blob.ContainerURL, error) {
 	c, err := blob.NewSharedKeyCredential(cfg.StorageAccountName.String(), cfg.StorageAccountKey.String())
 	if err != nil {
 		return blob.ContainerURL{}, err
 	}
 
-	retryOptions := blob.RetryOptions{
-		MaxTries: int32(maxRetries),
-		Policy:   blob.RetryPolicyExponential,
-	}
+	retryOptions := retryOptions(cfg.Retry)
 	if deadline, ok := ctx.Deadline(); ok {
 		retryOptions.TryTimeout = time.Until(deadline)
 	}
 
-	customTransport := http.DefaultTransport.(*http.Transport).Clone()
-
 	// add instrumentation
 	transport := instrumentation.NewAzureTransport(customTransport)
-	var stats *hedgedhttp.Stats
 
-	// hedge if desired (0 means disabled)
-	if hedge && cfg.HedgeRequestsAt != 0 {
-		transport, stats = hedgedhttp.NewRoundTripperAndStats(cfg.HedgeRequestsAt, uptoHedgedRequests, transport)
-		instrumentation.PublishHedgedMetrics(stats)
+	// hedge if desired
+	if hedge {
+		upto := cfg.HedgeRequestsUpTo
+		if upto <= 0 {
+			upto = uptoHedgedRequests
+		}
+		transport = instrumentation.NewHedgedTransport(transport, upto, cfg.HedgeRequestsAt, cfg.HedgeRequestsAdaptive)
 	}
 
 	client := http.Client{Transport: transport}
@@ -81,8 +90,28 @@ func GetContainerURL(ctx context.Context, cfg *Config, hedge bool) (blob.Contain
 	return service.NewContainerURL(cfg.ContainerName), nil
 }
 
+// retryOptions returns the exponential retry policy of the pipeline for the retry config. The pipeline retries server
+// errors, including the 503 responses of throttled requests, and network errors. A request is tried once if retries
+// are disabled.
+func retryOptions(cfg RetryConfig) blob.RetryOptions {
+	maxRetries := cfg.MaxRetries
+	if maxRetries < 0 {
+		maxRetries = 0
+	}
+	return blob.RetryOptions{
+		Policy:        blob.RetryPolicyExponential,
+		MaxTries:      int32(maxRetries + 1),
+		RetryDelay:    cfg.MinBackoff,
+		MaxRetryDelay: cfg.MaxBackoff,
+	}
+}
+
 func GetContainer(ctx context.Context, conf *Config, hedge bool) (blob.ContainerURL, error) {
-	c, err := GetContainerURL(ctx, conf, hedge)
+	return getContainer(ctx, conf, newTransport(conf), hedge)
+}
+
+func getContainer(ctx context.Context, conf *Config, customTransport *http.Transport, hedge bool) (blob.ContainerURL, error) {
+	c, err := getContainerURL(ctx, conf, customTransport, hedge)
 	if err != nil {
 		return blob.ContainerURL{}, err
 	}
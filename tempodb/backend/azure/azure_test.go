@@ -9,10 +9,14 @@ import (
 	"testing"
 	"time"
 
+	blob "github.com/Azure/azure-storage-blob-go/azblob"
 	"github.com/google/uuid"
 	"github.com/grafana/tempo/tempodb/backend"
+	"github.com/grafana/tempo/tempodb/backend/instrumentation"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
 )
 
 func TestHedge(t *testing.T) {
@@ -20,6 +24,7 @@ func TestHedge(t *testing.T) {
 		name                   string
 		returnIn               time.Duration
 		hedgeAt                time.Duration
+		hedgeUpTo              int
 		expectedHedgedRequests int32
 	}{
 		{
@@ -37,6 +42,13 @@ func TestHedge(t *testing.T) {
 			returnIn:               100 * time.Millisecond,
 			expectedHedgedRequests: 2,
 		},
+		{
+			name:                   "hedge enabled and hits up to 3",
+			hedgeAt:                time.Millisecond,
+			hedgeUpTo:              3,
+			returnIn:               100 * time.Millisecond,
+			expectedHedgedRequests: 3,
+		},
 	}
 
 	for _, tc := range tests {
@@ -45,11 +57,12 @@ func TestHedge(t *testing.T) {
 			server := fakeServer(t, tc.returnIn, &count)
 
 			r, w, _, err := New(&Config{
-				MaxBuffers:      3,
-				BufferSize:      1000,
-				ContainerName:   "blerg",
-				Endpoint:        server.URL[7:], // [7:] -> strip http://,
-				HedgeRequestsAt: tc.hedgeAt,
+				MaxBuffers:        3,
+				BufferSize:        1000,
+				ContainerName:     "blerg",
+				Endpoint:          server.URL[7:], // [7:] -> strip http://,
+				HedgeRequestsAt:   tc.hedgeAt,
+				HedgeRequestsUpTo: tc.hedgeUpTo,
 			})
 			require.NoError(t, err)
 
@@ -85,6 +98,147 @@ func TestHedge(t *testing.T) {
 	}
 }
 
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		name             string
+		maxRetries       int
+		throttled        int32
+		expectedRequests int32
+		expectedErr      error
+	}{
+		{
+			name:             "retries disabled",
+			throttled:        1,
+			expectedRequests: 1,
+			expectedErr:      backend.ErrThrottled,
+		},
+		{
+			name:             "throttled request is retried",
+			maxRetries:       2,
+			throttled:        1,
+			expectedRequests: 2,
+		},
+		{
+			name:             "retries are bounded",
+			maxRetries:       2,
+			throttled:        5,
+			expectedRequests: 3,
+			expectedErr:      backend.ErrThrottled,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			count := int32(0)
+			// the requests of New are not throttled
+			throttle := int32(0)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if atomic.LoadInt32(&throttle) == 1 && atomic.AddInt32(&count, 1) <= tc.throttled {
+					w.Header().Set("x-ms-error-code", string(blob.ServiceCodeServerBusy))
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				_, _ = w.Write([]byte(`{}`))
+			}))
+			defer server.Close()
+
+			_, w, _, err := New(&Config{
+				MaxBuffers:    3,
+				BufferSize:    1000,
+				ContainerName: "blerg",
+				Endpoint:      server.URL[7:], // [7:] -> strip http://,
+				Retry: RetryConfig{
+					MaxRetries: tc.maxRetries,
+					MinBackoff: time.Millisecond,
+					MaxBackoff: 10 * time.Millisecond,
+				},
+			})
+			require.NoError(t, err)
+
+			atomic.StoreInt32(&throttle, 1)
+			err = w.Write(context.Background(), "object", backend.KeyPathForBlock(uuid.New(), "tenant"), bytes.NewReader(make([]byte, 10)), 10, false)
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.expectedRequests, atomic.LoadInt32(&count))
+		})
+	}
+}
+
+func TestRetryOptions(t *testing.T) {
+	opts := retryOptions(RetryConfig{MaxRetries: 3, MinBackoff: time.Second, MaxBackoff: time.Minute})
+	assert.Equal(t, int32(4), opts.MaxTries)
+	assert.Equal(t, time.Second, opts.RetryDelay)
+	assert.Equal(t, time.Minute, opts.MaxRetryDelay)
+	assert.Equal(t, blob.RetryPolicyExponential, opts.Policy)
+
+	// a request is tried once without retries
+	assert.Equal(t, int32(1), retryOptions(RetryConfig{}).MaxTries)
+	assert.Equal(t, int32(1), retryOptions(RetryConfig{MaxRetries: -1}).MaxTries)
+}
+
+func TestTransportConfig(t *testing.T) {
+	transport := newTransport(&Config{
+		Transport: instrumentation.TransportConfig{
+			MaxIdleConns:        10,
+			MaxIdleConnsPerHost: 5,
+			IdleConnTimeout:     time.Second,
+			TLSHandshakeTimeout: 2 * time.Second,
+			DisableHTTP2:        true,
+		},
+	})
+	assert.Equal(t, 10, transport.MaxIdleConns)
+	assert.Equal(t, 5, transport.MaxIdleConnsPerHost)
+	assert.Equal(t, time.Second, transport.IdleConnTimeout)
+	assert.Equal(t, 2*time.Second, transport.TLSHandshakeTimeout)
+	assert.False(t, transport.ForceAttemptHTTP2)
+
+	// the defaults of the http client are kept
+	transport = newTransport(&Config{})
+	defaults := http.DefaultTransport.(*http.Transport)
+	assert.Equal(t, defaults.MaxIdleConns, transport.MaxIdleConns)
+	assert.Equal(t, defaults.IdleConnTimeout, transport.IdleConnTimeout)
+	assert.True(t, transport.ForceAttemptHTTP2)
+}
+
+func TestReadError(t *testing.T) {
+	tests := []struct {
+		code     blob.ServiceCodeType
+		status   int
+		expected error
+	}{
+		{code: blob.ServiceCodeBlobNotFound, status: http.StatusNotFound, expected: backend.ErrDoesNotExist},
+		{code: blob.ServiceCodeServerBusy, status: http.StatusServiceUnavailable, expected: backend.ErrThrottled},
+		{code: blob.ServiceCodeMd5Mismatch, status: http.StatusBadRequest, expected: backend.ErrCorrupt},
+		{code: blob.ServiceCodeInvalidInput, status: http.StatusBadRequest, expected: backend.ErrBadRequest},
+	}
+	for _, tc := range tests {
+		err := readError(errors.Wrap(storageError(tc.code, tc.status), "cannot download blob"))
+		assert.ErrorIs(t, err, tc.expected, string(tc.code))
+	}
+	assert.Equal(t, backend.ErrDoesNotExist, readError(storageError(blob.ServiceCodeBlobNotFound, http.StatusNotFound)))
+	assert.Equal(t, codes.Internal, backend.GRPCCode(readError(storageError(blob.ServiceCodeInternalError, http.StatusInternalServerError))))
+	assert.NoError(t, readError(nil))
+}
+
+func TestWriteError(t *testing.T) {
+	err := writeError(storageError(serviceCodeBlobImmutableDueToPolicy, http.StatusConflict))
+	assert.ErrorIs(t, err, backend.ErrReadOnly)
+
+	err = writeError(storageError(blob.ServiceCodeServerBusy, http.StatusServiceUnavailable))
+	assert.ErrorIs(t, err, backend.ErrThrottled)
+
+	assert.NoError(t, writeError(nil))
+}
+
+func storageError(code blob.ServiceCodeType, status int) error {
+	header := http.Header{}
+	header.Set("x-ms-error-code", string(code))
+	return blob.NewResponseError(nil, &http.Response{StatusCode: status, Header: header, Request: &http.Request{Method: http.MethodGet}}, "")
+}
+
 func fakeServer(t *testing.T, returnIn time.Duration, counter *int32) *httptest.Server {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		time.Sleep(returnIn)
@@ -36,16 +36,16 @@ func (rw *readerWriter) MarkBlockCompacted(blockID uuid.UUID, tenantID string) e
 
 	src, err := rw.readAll(ctx, metaFilename)
 	if err != nil {
-		return err
+		return readError(err)
 	}
 
 	err = rw.writeAll(ctx, compactedMetaFilename, src)
 	if err != nil {
-		return err
+		return writeError(err)
 	}
 
 	// delete the old file
-	return rw.delete(ctx, metaFilename)
+	return writeError(rw.delete(ctx, metaFilename))
 }
 
 func (rw *readerWriter) ClearBlock(blockID uuid.UUID, tenantID string) error {
@@ -107,7 +107,7 @@ func (rw *readerWriter) CompactedBlockMeta(blockID uuid.UUID, tenantID string) (
 	out := &backend.CompactedBlockMeta{}
 	err = json.Unmarshal(bytes, out)
 	if err != nil {
-		return nil, err
+		return nil, backend.WrapError(backend.ErrCorrupt, err)
 	}
 	out.CompactedTime = modTime
 
@@ -1,17 +1,39 @@
 package azure
 
 import (
+	"flag"
 	"time"
 
 	"github.com/grafana/dskit/flagext"
+
+	"github.com/grafana/tempo/tempodb/backend/instrumentation"
 )
 
 type Config struct {
-	StorageAccountName flagext.Secret `yaml:"storage-account-name"`
-	StorageAccountKey  flagext.Secret `yaml:"storage-account-key"`
-	ContainerName      string         `yaml:"container-name"`
-	Endpoint           string         `yaml:"endpoint-suffix"`
-	MaxBuffers         int            `yaml:"max-buffers"`
-	BufferSize         int            `yaml:"buffer-size"`
-	HedgeRequestsAt    time.Duration  `yaml:"hedge-requests-at"`
+	StorageAccountName    flagext.Secret                      `yaml:"storage-account-name"`
+	StorageAccountKey     flagext.Secret                      `yaml:"storage-account-key"`
+	ContainerName         string                              `yaml:"container-name"`
+	Endpoint              string                              `yaml:"endpoint-suffix"`
+	MaxBuffers            int                                 `yaml:"max-buffers"`
+	BufferSize            int                                 `yaml:"buffer-size"`
+	HedgeRequestsAt       time.Duration                       `yaml:"hedge-requests-at"`
+	HedgeRequestsUpTo     int                                 `yaml:"hedge-requests-up-to"`
+	HedgeRequestsAdaptive instrumentation.AdaptiveHedgeConfig `yaml:"hedge-requests-adaptive"`
+	Retry                 RetryConfig                         `yaml:"retry"`
+	Transport             instrumentation.TransportConfig     `yaml:"transport"`
+}
+
+// RetryConfig bounds the retries of requests that failed with a server error, like the 503 responses Azure throttles
+// with, or a network error. Retries back off exponentially with jitter.
+type RetryConfig struct {
+	MaxRetries int           `yaml:"max-retries"`
+	MinBackoff time.Duration `yaml:"min-backoff"`
+	MaxBackoff time.Duration `yaml:"max-backoff"`
+}
+
+// RegisterFlagsAndApplyDefaults registers the flags.
+func (cfg *RetryConfig) RegisterFlagsAndApplyDefaults(prefix string, f *flag.FlagSet) {
+	f.IntVar(&cfg.MaxRetries, prefix+".max-retries", 3, "Maximum number of retries of a request that failed with a server error or a network error. 0 disables retries.")
+	f.DurationVar(&cfg.MinBackoff, prefix+".min-backoff", 100*time.Millisecond, "Backoff before the first retry, growing exponentially with every following retry.")
+	f.DurationVar(&cfg.MaxBackoff, prefix+".max-backoff", 3*time.Second, "Upper bound of the backoff between retries.")
 }
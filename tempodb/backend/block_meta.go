@@ -7,6 +7,10 @@ import (
 	"github.com/google/uuid"
 )
 
+// MaxCompactedFrom is the maximum number of input blocks recorded in a BlockMeta. The full list of inputs is
+// kept in the lineage object of the block.
+const MaxCompactedFrom = 32
+
 type CompactedBlockMeta struct {
 	BlockMeta
 
@@ -29,6 +33,12 @@ type BlockMeta struct {
 	TotalRecords    uint32    `json:"totalRecords"`    // Total Records stored in the index file
 	DataEncoding    string    `json:"dataEncoding"`    // DataEncoding is a string provided externally, but tracked by tempodb that indicates the way the bytes are encoded
 	BloomShardCount uint16    `json:"bloomShards"`     // Number of bloom filter shards
+	NoIndex         bool      `json:"noIndex"`         // The block has no index and is searched by scanning its data
+
+	CompactedFrom      []uuid.UUID `json:"compactedFrom,omitempty"`      // Blocks this block was compacted from. Capped at MaxCompactedFrom
+	CompactedFromCount int         `json:"compactedFromCount,omitempty"` // Total number of blocks this block was compacted from
+
+	HasSkeleton bool `json:"hasSkeleton,omitempty"` // A skeleton block with the same id holds the skeletons of the traces of this block
 }
 
 func NewBlockMeta(tenantID string, blockID uuid.UUID, version string, encoding Encoding, dataEncoding string) *BlockMeta {
@@ -61,3 +71,15 @@ func (b *BlockMeta) ObjectAdded(id []byte) {
 
 	b.TotalObjects++
 }
+
+// SetCompactedFrom records the blocks this block was compacted from. At most MaxCompactedFrom ids are kept.
+func (b *BlockMeta) SetCompactedFrom(metas []*BlockMeta) {
+	b.CompactedFromCount = len(metas)
+	b.CompactedFrom = make([]uuid.UUID, 0, len(metas))
+	for _, m := range metas {
+		if len(b.CompactedFrom) >= MaxCompactedFrom {
+			break
+		}
+		b.CompactedFrom = append(b.CompactedFrom, m.BlockID)
+	}
+}
@@ -43,6 +43,22 @@ func TestBlockMeta(t *testing.T) {
 	assert.Equal(t, 2, b.TotalObjects)
 }
 
+func TestBlockMetaSetCompactedFrom(t *testing.T) {
+	metas := make([]*BlockMeta, 0, MaxCompactedFrom+10)
+	for i := 0; i < MaxCompactedFrom+10; i++ {
+		metas = append(metas, NewBlockMeta(testTenantID, uuid.New(), "v2", EncNone, ""))
+	}
+
+	b := NewBlockMeta(testTenantID, uuid.New(), "v2", EncNone, "")
+	b.SetCompactedFrom(metas[:2])
+	assert.Equal(t, []uuid.UUID{metas[0].BlockID, metas[1].BlockID}, b.CompactedFrom)
+	assert.Equal(t, 2, b.CompactedFromCount)
+
+	b.SetCompactedFrom(metas)
+	assert.Len(t, b.CompactedFrom, MaxCompactedFrom)
+	assert.Equal(t, len(metas), b.CompactedFromCount)
+}
+
 func TestBlockMetaParsing(t *testing.T) {
 	inputJSON := `
 {
@@ -3,27 +3,56 @@ package cache
 import (
 	"bytes"
 	"context"
+	"encoding/binary"
+	"hash/crc32"
 	"io"
 	"io/ioutil"
+	"strconv"
 	"strings"
 
 	cortex_cache "github.com/cortexproject/cortex/pkg/chunk/cache"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 
 	tempo_io "github.com/grafana/tempo/pkg/io"
 	"github.com/grafana/tempo/tempodb/backend"
 )
 
+var (
+	metricChunkedStores = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "tempodb",
+		Name:      "cache_chunked_stores_total",
+		Help:      "The total number of objects larger than the max item size of the cache stored in chunks.",
+	})
+	metricChunkReassemblyFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "tempodb",
+		Name:      "cache_chunk_reassembly_failures_total",
+		Help:      "The total number of chunked objects read from the backend because chunks were missing from the cache.",
+	})
+)
+
+// chunkManifestMagic starts the manifest stored in place of an object that is stored in chunks. It is followed by
+// the number of chunks, the size and the crc32 of the object.
+var chunkManifestMagic = []byte("\x00tempodb-chunked\x00")
+
+const chunkManifestLen = 4 + 8 + 4
+
 type readerWriter struct {
-	nextReader backend.RawReader
-	nextWriter backend.RawWriter
-	cache      cortex_cache.Cache
+	nextReader   backend.RawReader
+	nextWriter   backend.RawWriter
+	cache        cortex_cache.Cache
+	maxItemBytes int
 }
 
-func NewCache(nextReader backend.RawReader, nextWriter backend.RawWriter, cache cortex_cache.Cache) (backend.RawReader, backend.RawWriter, error) {
+// NewCache caches the objects read and written through it. Objects larger than maxItemBytes are split into chunks
+// of up to maxItemBytes stored under derived keys and a manifest stored under the key of the object. 0 stores every
+// object as a single item.
+func NewCache(nextReader backend.RawReader, nextWriter backend.RawWriter, cache cortex_cache.Cache, maxItemBytes int) (backend.RawReader, backend.RawWriter, error) {
 	rw := &readerWriter{
-		cache:      cache,
-		nextReader: nextReader,
-		nextWriter: nextWriter,
+		cache:        cache,
+		nextReader:   nextReader,
+		nextWriter:   nextWriter,
+		maxItemBytes: maxItemBytes,
 	}
 
 	return rw, rw, nil
@@ -39,9 +68,8 @@ func (r *readerWriter) Read(ctx context.Context, name string, keypath backend.Ke
 	var k string
 	if shouldCache {
 		k = key(keypath, name)
-		found, vals, _ := r.cache.Fetch(ctx, []string{k})
-		if len(found) > 0 {
-			return ioutil.NopCloser(bytes.NewReader(vals[0])), int64(len(vals[0])), nil
+		if b, ok := r.fetch(ctx, k); ok {
+			return ioutil.NopCloser(bytes.NewReader(b)), int64(len(b)), nil
 		}
 	}
 
@@ -52,7 +80,7 @@ func (r *readerWriter) Read(ctx context.Context, name string, keypath backend.Ke
 
 	b, err := tempo_io.ReadAllWithEstimate(object, size)
 	if err == nil && shouldCache {
-		r.cache.Store(ctx, []string{k}, [][]byte{b})
+		r.store(ctx, k, b)
 	}
 
 	return ioutil.NopCloser(bytes.NewReader(b)), size, err
@@ -77,7 +105,7 @@ func (r *readerWriter) Write(ctx context.Context, name string, keypath backend.K
 	}
 
 	if shouldCache {
-		r.cache.Store(ctx, []string{key(keypath, name)}, [][]byte{b})
+		r.store(ctx, key(keypath, name), b)
 	}
 	return r.nextWriter.Write(ctx, name, keypath, bytes.NewReader(b), int64(len(b)), false)
 }
@@ -95,3 +123,81 @@ func (r *readerWriter) CloseAppend(ctx context.Context, tracker backend.AppendTr
 func key(keypath backend.KeyPath, name string) string {
 	return strings.Join(keypath, ":") + ":" + name
 }
+
+// fetch returns the object cached under k. Chunked objects are reassembled, if any chunk is missing or the object
+// doesn't match its manifest it is not found.
+func (r *readerWriter) fetch(ctx context.Context, k string) ([]byte, bool) {
+	found, vals, _ := r.cache.Fetch(ctx, []string{k})
+	if len(found) == 0 {
+		return nil, false
+	}
+
+	b := vals[0]
+	if !bytes.HasPrefix(b, chunkManifestMagic) || len(b) != len(chunkManifestMagic)+chunkManifestLen {
+		return b, true
+	}
+
+	manifest := b[len(chunkManifestMagic):]
+	chunks := int(binary.LittleEndian.Uint32(manifest))
+	size := binary.LittleEndian.Uint64(manifest[4:])
+	checksum := binary.LittleEndian.Uint32(manifest[12:])
+
+	keys := make([]string, chunks)
+	for i := range keys {
+		keys[i] = chunkKey(k, i)
+	}
+	found, vals, _ = r.cache.Fetch(ctx, keys)
+	if len(found) != chunks {
+		metricChunkReassemblyFailures.Inc()
+		return nil, false
+	}
+
+	// caches may return the found keys in any order
+	byKey := make(map[string][]byte, len(found))
+	for i, f := range found {
+		byKey[f] = vals[i]
+	}
+	object := make([]byte, 0, size)
+	for _, ck := range keys {
+		object = append(object, byKey[ck]...)
+	}
+
+	if uint64(len(object)) != size || crc32.ChecksumIEEE(object) != checksum {
+		metricChunkReassemblyFailures.Inc()
+		return nil, false
+	}
+	return object, true
+}
+
+// store caches the object under k, in chunks if it is larger than the max item size. The manifest is stored after
+// the chunks.
+func (r *readerWriter) store(ctx context.Context, k string, b []byte) {
+	if r.maxItemBytes <= 0 || len(b) <= r.maxItemBytes {
+		r.cache.Store(ctx, []string{k}, [][]byte{b})
+		return
+	}
+
+	var keys []string
+	var chunks [][]byte
+	for i := 0; i*r.maxItemBytes < len(b); i++ {
+		end := (i + 1) * r.maxItemBytes
+		if end > len(b) {
+			end = len(b)
+		}
+		keys = append(keys, chunkKey(k, i))
+		chunks = append(chunks, b[i*r.maxItemBytes:end])
+	}
+
+	manifest := make([]byte, len(chunkManifestMagic)+chunkManifestLen)
+	n := copy(manifest, chunkManifestMagic)
+	binary.LittleEndian.PutUint32(manifest[n:], uint32(len(chunks)))
+	binary.LittleEndian.PutUint64(manifest[n+4:], uint64(len(b)))
+	binary.LittleEndian.PutUint32(manifest[n+12:], crc32.ChecksumIEEE(b))
+
+	r.cache.Store(ctx, append(keys, k), append(chunks, manifest))
+	metricChunkedStores.Inc()
+}
+
+func chunkKey(k string, i int) string {
+	return k + ":chunk-" + strconv.Itoa(i)
+}
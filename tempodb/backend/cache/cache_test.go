@@ -10,6 +10,9 @@ import (
 	"github.com/google/uuid"
 	"github.com/grafana/tempo/tempodb/backend"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tempo/pkg/util/test"
 )
 
 type mockClient struct {
@@ -17,16 +20,20 @@ type mockClient struct {
 }
 
 func (m *mockClient) Store(_ context.Context, key []string, val [][]byte) {
-	m.client[key[0]] = val[0]
+	for i := range key {
+		m.client[key[i]] = val[i]
+	}
 }
 
 func (m *mockClient) Fetch(_ context.Context, key []string) (found []string, bufs [][]byte, missing []string) {
-	val, ok := m.client[key[0]]
-	if ok {
-		found = append(found, key[0])
-		bufs = append(bufs, val)
-	} else {
-		missing = append(missing, key[0])
+	for _, k := range key {
+		val, ok := m.client[k]
+		if ok {
+			found = append(found, k)
+			bufs = append(bufs, val)
+		} else {
+			missing = append(missing, k)
+		}
 	}
 	return
 }
@@ -77,7 +84,7 @@ func TestReadWrite(t *testing.T) {
 			mockW := &backend.MockRawWriter{}
 
 			// READ
-			r, _, _ := NewCache(mockR, mockW, NewMockClient())
+			r, _, _ := NewCache(mockR, mockW, NewMockClient(), 0)
 
 			ctx := context.Background()
 			reader, _, _ := r.Read(ctx, tt.readerName, backend.KeyPathForBlock(blockID, tenantID), tt.shouldCache)
@@ -92,7 +99,7 @@ func TestReadWrite(t *testing.T) {
 			assert.Equal(t, len(tt.expectedCache), len(read))
 
 			// WRITE
-			_, w, _ := NewCache(mockR, mockW, NewMockClient())
+			_, w, _ := NewCache(mockR, mockW, NewMockClient(), 0)
 			_ = w.Write(ctx, tt.readerName, backend.KeyPathForBlock(blockID, tenantID), bytes.NewReader(tt.readerRead), int64(len(tt.readerRead)), tt.shouldCache)
 			reader, _, _ = r.Read(ctx, tt.readerName, backend.KeyPathForBlock(blockID, tenantID), tt.shouldCache)
 			read, _ = ioutil.ReadAll(reader)
@@ -126,7 +133,7 @@ func TestList(t *testing.T) {
 			}
 			mockW := &backend.MockRawWriter{}
 
-			rw, _, _ := NewCache(mockR, mockW, NewMockClient())
+			rw, _, _ := NewCache(mockR, mockW, NewMockClient(), 0)
 
 			ctx := context.Background()
 			list, _ := rw.List(ctx, backend.KeyPathForBlock(blockID, tenantID))
@@ -141,3 +148,58 @@ func TestList(t *testing.T) {
 		})
 	}
 }
+
+func TestReadWriteChunked(t *testing.T) {
+	ctx := context.Background()
+	keypath := backend.KeyPathForBlock(uuid.New(), "test")
+	object := []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0A}
+
+	mockR := &backend.MockRawReader{}
+	mockW := &backend.MockRawWriter{}
+	client := NewMockClient().(*mockClient)
+	r, w, _ := NewCache(mockR, mockW, client, 4)
+
+	// objects larger than the max item size are stored in chunks under derived keys with a manifest
+	require.NoError(t, w.Write(ctx, "bloom-0", keypath, bytes.NewReader(object), int64(len(object)), true))
+	k := key(keypath, "bloom-0")
+	assert.Len(t, client.client, 4)
+	assert.Equal(t, []byte{0x01, 0x02, 0x03, 0x04}, client.client[chunkKey(k, 0)])
+	assert.Equal(t, []byte{0x09, 0x0A}, client.client[chunkKey(k, 2)])
+
+	// and reassembled on read
+	reader, size, err := r.Read(ctx, "bloom-0", keypath, true)
+	require.NoError(t, err)
+	read, _ := ioutil.ReadAll(reader)
+	assert.Equal(t, object, read)
+	assert.Equal(t, int64(len(object)), size)
+
+	// objects that fit are stored as a single item
+	require.NoError(t, w.Write(ctx, "meta", keypath, bytes.NewReader(object[:4]), 4, true))
+	assert.Equal(t, object[:4], client.client[key(keypath, "meta")])
+
+	// an evicted chunk falls back to the backend and the object is cached again
+	delete(client.client, chunkKey(k, 1))
+	mockR.R = object
+	before, err := test.GetCounterValue(metricChunkReassemblyFailures)
+	require.NoError(t, err)
+
+	reader, _, err = r.Read(ctx, "bloom-0", keypath, true)
+	require.NoError(t, err)
+	read, _ = ioutil.ReadAll(reader)
+	assert.Equal(t, object, read)
+
+	after, err := test.GetCounterValue(metricChunkReassemblyFailures)
+	require.NoError(t, err)
+	assert.Equal(t, float64(1), after-before)
+	assert.Contains(t, client.client, chunkKey(k, 1))
+
+	// a chunk that doesn't match the manifest is a failure too
+	client.client[chunkKey(k, 1)] = []byte{0xFF, 0xFF, 0xFF, 0xFF}
+	reader, _, err = r.Read(ctx, "bloom-0", keypath, true)
+	require.NoError(t, err)
+	read, _ = ioutil.ReadAll(reader)
+	assert.Equal(t, object, read)
+	after2, err := test.GetCounterValue(metricChunkReassemblyFailures)
+	require.NoError(t, err)
+	assert.Equal(t, float64(1), after2-after)
+}
@@ -13,8 +13,14 @@ type Config struct {
 	ClientConfig cortex_cache.MemcachedClientConfig `yaml:",inline"`
 
 	TTL time.Duration `yaml:"ttl"`
+	// MaxItemBytes is the size above which objects are stored in chunks. Defaults to the 1MB item size limit of
+	// memcached less room for the key and item overhead.
+	MaxItemBytes int `yaml:"max_item_bytes"`
 }
 
+// DefaultMaxItemBytes leaves 1KB of the default 1MB item size limit of memcached for the key and item overhead.
+const DefaultMaxItemBytes = 1<<20 - 1<<10
+
 func NewClient(cfg *Config, cfgBackground *cortex_cache.BackgroundConfig, logger log.Logger) cortex_cache.Cache {
 	if cfg.ClientConfig.MaxIdleConns == 0 {
 		cfg.ClientConfig.MaxIdleConns = 16
@@ -22,6 +28,9 @@ func NewClient(cfg *Config, cfgBackground *cortex_cache.BackgroundConfig, logger
 	if cfg.ClientConfig.Timeout == 0 {
 		cfg.ClientConfig.Timeout = 100 * time.Millisecond
 	}
+	if cfg.MaxItemBytes == 0 {
+		cfg.MaxItemBytes = DefaultMaxItemBytes
+	}
 	if cfg.ClientConfig.UpdateInterval == 0 {
 		cfg.ClientConfig.UpdateInterval = time.Minute
 	}
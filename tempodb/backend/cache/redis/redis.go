@@ -13,6 +13,8 @@ type Config struct {
 	ClientConfig cortex_cache.RedisConfig `yaml:",inline"`
 
 	TTL time.Duration `yaml:"ttl"`
+	// MaxItemBytes is the size above which objects are stored in chunks. 0 stores every object as a single item.
+	MaxItemBytes int `yaml:"max_item_bytes"`
 }
 
 func NewClient(cfg *Config, cfgBackground *cortex_cache.BackgroundConfig, logger log.Logger) cortex_cache.Cache {
@@ -0,0 +1,199 @@
+package coalesce
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"path"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	tempo_io "github.com/grafana/tempo/pkg/io"
+	"github.com/grafana/tempo/tempodb/backend"
+)
+
+const (
+	opRead      = "read"
+	opReadRange = "read_range"
+)
+
+var metricCoalescedRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "tempodb",
+	Name:      "backend_coalesced_requests_total",
+	Help:      "Total number of backend reads that shared the result of an identical read already in flight.",
+}, []string{"operation"})
+
+// key identifies identical reads. length is the length of the buffer of a ReadRange.
+type key struct {
+	op          string
+	keypath     string
+	name        string
+	offset      uint64
+	length      int
+	shouldCache bool
+}
+
+// call is a read in flight. Its result is set before done is closed and is never modified afterwards.
+type call struct {
+	done    chan struct{}
+	waiters int
+
+	b    []byte
+	size int64
+	err  error
+	// the object was larger than the max bytes and was streamed to the first caller only
+	tooLarge bool
+}
+
+type reader struct {
+	next     backend.RawReader
+	maxBytes int
+
+	mtx   sync.Mutex
+	calls map[key]*call
+}
+
+// New returns a RawReader that shares the result of a Read or ReadRange with identical reads issued while it is in
+// flight, e.g. by queries for different traces that read the same index page or bloom shard. Objects and ranges
+// larger than maxBytes are not shared so big buffers are not held for other callers.
+func New(next backend.RawReader, maxBytes int) backend.RawReader {
+	return &reader{
+		next:     next,
+		maxBytes: maxBytes,
+		calls:    map[key]*call{},
+	}
+}
+
+// List implements backend.RawReader
+func (r *reader) List(ctx context.Context, keypath backend.KeyPath) ([]string, error) {
+	return r.next.List(ctx, keypath)
+}
+
+// Read implements backend.RawReader. Every caller gets its own reader of the shared bytes, which can't be used to
+// modify them.
+func (r *reader) Read(ctx context.Context, name string, keypath backend.KeyPath, shouldCache bool) (io.ReadCloser, int64, error) {
+	k := key{op: opRead, keypath: path.Join(keypath...), name: name, shouldCache: shouldCache}
+
+	c, leader := r.join(k)
+	if !leader {
+		if r.wait(ctx, c, opRead) {
+			if c.err != nil {
+				return nil, 0, c.err
+			}
+			return ioutil.NopCloser(bytes.NewReader(c.b)), c.size, nil
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, 0, err
+		}
+		return r.next.Read(ctx, name, keypath, shouldCache)
+	}
+
+	object, size, err := r.next.Read(ctx, name, keypath, shouldCache)
+	if err != nil {
+		r.finish(k, c, func() { c.err = err })
+		return nil, 0, err
+	}
+
+	if size < 0 || size > int64(r.maxBytes) {
+		r.finish(k, c, func() { c.tooLarge = true })
+		return object, size, nil
+	}
+
+	b, err := tempo_io.ReadAllWithEstimate(object, size)
+	object.Close()
+	r.finish(k, c, func() {
+		c.b, c.size, c.err = b, size, err
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return ioutil.NopCloser(bytes.NewReader(b)), size, nil
+}
+
+// ReadRange implements backend.RawReader. The range is read into the buffer of the first caller and copied for the
+// other callers before it is returned, so the first caller can't modify the bytes the others read.
+func (r *reader) ReadRange(ctx context.Context, name string, keypath backend.KeyPath, offset uint64, buffer []byte) error {
+	if len(buffer) > r.maxBytes {
+		return r.next.ReadRange(ctx, name, keypath, offset, buffer)
+	}
+
+	k := key{op: opReadRange, keypath: path.Join(keypath...), name: name, offset: offset, length: len(buffer)}
+
+	c, leader := r.join(k)
+	if !leader {
+		if r.wait(ctx, c, opReadRange) {
+			if c.err != nil {
+				return c.err
+			}
+			copy(buffer, c.b)
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return r.next.ReadRange(ctx, name, keypath, offset, buffer)
+	}
+
+	err := r.next.ReadRange(ctx, name, keypath, offset, buffer)
+	r.finish(k, c, func() {
+		c.err = err
+		if err == nil && c.waiters > 0 {
+			c.b = append([]byte(nil), buffer...)
+		}
+	})
+	return err
+}
+
+// Shutdown implements backend.RawReader
+func (r *reader) Shutdown() {
+	r.next.Shutdown()
+}
+
+// join returns the call in flight for k, or starts a new one if there is none. leader is true if the caller started
+// the call and has to read and finish it.
+func (r *reader) join(k key) (c *call, leader bool) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if c, ok := r.calls[k]; ok {
+		c.waiters++
+		return c, false
+	}
+
+	c = &call{done: make(chan struct{})}
+	r.calls[k] = c
+	return c, true
+}
+
+// finish removes the call so later reads start a new one, sets its result and wakes up the waiters. No waiters can
+// join once the call is removed, so the result may depend on the final number of waiters.
+func (r *reader) finish(k key, c *call, setResult func()) {
+	r.mtx.Lock()
+	delete(r.calls, k)
+	r.mtx.Unlock()
+
+	setResult()
+	close(c.done)
+}
+
+// wait waits for the result of the call. It returns false if the caller has to read by itself: if its context is
+// done, if the object was too large to share or if the read was cancelled by the context of the first caller.
+func (r *reader) wait(ctx context.Context, c *call, op string) bool {
+	select {
+	case <-c.done:
+	case <-ctx.Done():
+		return false
+	}
+
+	if c.tooLarge || errors.Is(c.err, context.Canceled) || errors.Is(c.err, context.DeadlineExceeded) {
+		return false
+	}
+
+	metricCoalescedRequests.WithLabelValues(op).Inc()
+	return true
+}
@@ -0,0 +1,209 @@
+package coalesce
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+
+	"github.com/grafana/tempo/pkg/util/test"
+	"github.com/grafana/tempo/tempodb/backend"
+)
+
+// blockingReader serves data once release is closed and counts the reads that reached it.
+type blockingReader struct {
+	data    []byte
+	release chan struct{}
+	calls   atomic.Int32
+}
+
+func newBlockingReader(data []byte) *blockingReader {
+	return &blockingReader{
+		data:    data,
+		release: make(chan struct{}),
+	}
+}
+
+func (m *blockingReader) List(context.Context, backend.KeyPath) ([]string, error) {
+	return nil, nil
+}
+
+func (m *blockingReader) Read(ctx context.Context, _ string, _ backend.KeyPath, _ bool) (io.ReadCloser, int64, error) {
+	m.calls.Inc()
+	select {
+	case <-m.release:
+	case <-ctx.Done():
+		return nil, 0, ctx.Err()
+	}
+	return ioutil.NopCloser(bytes.NewReader(m.data)), int64(len(m.data)), nil
+}
+
+func (m *blockingReader) ReadRange(ctx context.Context, _ string, _ backend.KeyPath, offset uint64, buffer []byte) error {
+	m.calls.Inc()
+	select {
+	case <-m.release:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	copy(buffer, m.data[offset:])
+	return nil
+}
+
+func (m *blockingReader) Shutdown() {}
+
+// waitForWaiters waits until n callers wait for the call in flight for k.
+func waitForWaiters(t *testing.T, r *reader, k key, n int) {
+	require.Eventually(t, func() bool {
+		r.mtx.Lock()
+		defer r.mtx.Unlock()
+		c, ok := r.calls[k]
+		return ok && c.waiters == n
+	}, 5*time.Second, time.Millisecond)
+}
+
+func TestReadRangeCoalesced(t *testing.T) {
+	const callers = 10
+	data := []byte("0123456789")
+	next := newBlockingReader(data)
+	r := New(next, 1024).(*reader)
+	keypath := backend.KeyPath{"tenant", "block"}
+	before, err := test.GetCounterValue(metricCoalescedRequests.WithLabelValues(opReadRange))
+	require.NoError(t, err)
+
+	buffers := make([][]byte, callers)
+	var wg sync.WaitGroup
+	for i := range buffers {
+		buffers[i] = make([]byte, 4)
+		wg.Add(1)
+		go func(buffer []byte) {
+			defer wg.Done()
+			assert.NoError(t, r.ReadRange(context.Background(), "data", keypath, 2, buffer))
+			// callers own their buffer, changing it doesn't change the buffers of the others
+			buffer[0] = 'x'
+		}(buffers[i])
+	}
+
+	waitForWaiters(t, r, key{op: opReadRange, keypath: "tenant/block", name: "data", offset: 2, length: 4}, callers-1)
+	close(next.release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), next.calls.Load())
+	for _, buffer := range buffers {
+		assert.Equal(t, []byte("x345"), buffer)
+	}
+	after, err := test.GetCounterValue(metricCoalescedRequests.WithLabelValues(opReadRange))
+	require.NoError(t, err)
+	assert.Equal(t, float64(callers-1), after-before)
+
+	// a read that is not in flight anymore is not shared
+	buffer := make([]byte, 4)
+	require.NoError(t, r.ReadRange(context.Background(), "data", keypath, 2, buffer))
+	assert.Equal(t, []byte("2345"), buffer)
+	assert.Equal(t, int32(2), next.calls.Load())
+}
+
+func TestReadCoalesced(t *testing.T) {
+	const callers = 10
+	data := []byte("0123456789")
+	next := newBlockingReader(data)
+	r := New(next, 1024).(*reader)
+	keypath := backend.KeyPath{"tenant", "block"}
+
+	results := make([][]byte, callers)
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			object, size, err := r.Read(context.Background(), "bloom-0", keypath, true)
+			require.NoError(t, err)
+			assert.Equal(t, int64(len(data)), size)
+			results[i], err = ioutil.ReadAll(object)
+			assert.NoError(t, err)
+			assert.NoError(t, object.Close())
+		}(i)
+	}
+
+	waitForWaiters(t, r, key{op: opRead, keypath: "tenant/block", name: "bloom-0", shouldCache: true}, callers-1)
+	close(next.release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), next.calls.Load())
+	for _, result := range results {
+		assert.Equal(t, data, result)
+	}
+}
+
+func TestReadTooLarge(t *testing.T) {
+	data := []byte("0123456789")
+	next := newBlockingReader(data)
+	close(next.release)
+	r := New(next, 5).(*reader)
+	keypath := backend.KeyPath{"tenant", "block"}
+
+	// ranges larger than the max bytes are never shared
+	buffer := make([]byte, 6)
+	require.NoError(t, r.ReadRange(context.Background(), "data", keypath, 0, buffer))
+	assert.Equal(t, []byte("012345"), buffer)
+	assert.Empty(t, r.calls)
+
+	// objects larger than the max bytes are streamed to the first caller and read again by the others
+	next = newBlockingReader(data)
+	r = New(next, 5).(*reader)
+	k := key{op: opRead, keypath: "tenant/block", name: "data"}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			object, _, err := r.Read(context.Background(), "data", keypath, false)
+			require.NoError(t, err)
+			b, err := ioutil.ReadAll(object)
+			assert.NoError(t, err)
+			assert.Equal(t, data, b)
+		}()
+	}
+
+	waitForWaiters(t, r, k, 1)
+	close(next.release)
+	wg.Wait()
+	assert.Equal(t, int32(2), next.calls.Load())
+}
+
+func TestReadRangeLeaderCancelled(t *testing.T) {
+	data := []byte("0123456789")
+	next := newBlockingReader(data)
+	r := New(next, 1024).(*reader)
+	keypath := backend.KeyPath{"tenant", "block"}
+	k := key{op: opReadRange, keypath: "tenant/block", name: "data", length: 4}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	leaderErr := make(chan error)
+	go func() {
+		leaderErr <- r.ReadRange(ctx, "data", keypath, 0, make([]byte, 4))
+	}()
+	require.Eventually(t, func() bool { return next.calls.Load() == 1 }, 5*time.Second, time.Millisecond)
+
+	waiterErr := make(chan error)
+	buffer := make([]byte, 4)
+	go func() {
+		waiterErr <- r.ReadRange(context.Background(), "data", keypath, 0, buffer)
+	}()
+	waitForWaiters(t, r, k, 1)
+
+	// the waiter reads by itself instead of failing with the cancelled context of the first caller
+	cancel()
+	require.ErrorIs(t, <-leaderErr, context.Canceled)
+	close(next.release)
+	require.NoError(t, <-waiterErr)
+	assert.Equal(t, []byte("0123"), buffer)
+	assert.Equal(t, int32(2), next.calls.Load())
+}
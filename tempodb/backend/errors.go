@@ -0,0 +1,94 @@
+package backend
+
+import (
+	"errors"
+	"net/http"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	// ErrThrottled is returned when the provider rejected a request because of its rate limits
+	ErrThrottled = errors.New("throttled")
+	// ErrReadOnly is returned when the provider refused a write, i.e. the bucket is locked or the credentials are read only
+	ErrReadOnly = errors.New("read only")
+	// ErrBadRequest is returned when the provider rejected a request as invalid
+	ErrBadRequest = errors.New("bad request")
+	// ErrCorrupt is returned when an object failed its checksum or couldn't be decoded
+	ErrCorrupt = errors.New("corrupt")
+)
+
+// WrapError returns err as an error of the given kind, one of the errors of the backend. errors.Is(err, kind) is
+// true for the returned error and it keeps the message of err.
+func WrapError(kind error, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &kindError{kind: kind, err: err}
+}
+
+type kindError struct {
+	kind error
+	err  error
+}
+
+func (e *kindError) Error() string {
+	return e.kind.Error() + ": " + e.err.Error()
+}
+
+func (e *kindError) Is(target error) bool {
+	return target == e.kind
+}
+
+func (e *kindError) Unwrap() error {
+	return e.err
+}
+
+// GRPCCode returns the gRPC code of an error of the backend, errors with a gRPC status in their chain, i.e. the
+// errors of an ingester, have its code. Other errors, including ErrDoesNotExist, are codes.Internal: a query only
+// reads the objects of known blocks, so a missing object is a failure of the backend and not a missing trace.
+func GRPCCode(err error) codes.Code {
+	var withStatus interface{ GRPCStatus() *status.Status }
+	switch {
+	case errors.As(err, &withStatus):
+		return withStatus.GRPCStatus().Code()
+	case errors.Is(err, ErrThrottled):
+		return codes.ResourceExhausted
+	case errors.Is(err, ErrReadOnly):
+		return codes.FailedPrecondition
+	case errors.Is(err, ErrBadRequest), errors.Is(err, ErrEmptyTenantID), errors.Is(err, ErrEmptyBlockID):
+		return codes.InvalidArgument
+	case errors.Is(err, ErrCorrupt):
+		return codes.DataLoss
+	}
+	return codes.Internal
+}
+
+// GRPCError returns err with the gRPC status of GRPCCode, for the gRPC servers that return errors of the backend.
+// Errors that already have a gRPC status are returned as is.
+func GRPCError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	return status.Error(GRPCCode(err), err.Error())
+}
+
+// HTTPStatus returns the http status of an error of the backend, it matches GRPCCode. Other errors are
+// http.StatusInternalServerError.
+func HTTPStatus(err error) int {
+	switch GRPCCode(err) {
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.FailedPrecondition:
+		return http.StatusConflict
+	case codes.InvalidArgument:
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,52 @@
+package backend
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestWrapError(t *testing.T) {
+	err := errors.New("slow down")
+	wrapped := WrapError(ErrThrottled, err)
+
+	assert.ErrorIs(t, wrapped, ErrThrottled)
+	assert.ErrorIs(t, wrapped, err)
+	assert.NotErrorIs(t, wrapped, ErrCorrupt)
+	assert.Equal(t, "throttled: slow down", wrapped.Error())
+
+	// the kind is kept through further wrapping
+	assert.ErrorIs(t, fmt.Errorf("reading block: %w", wrapped), ErrThrottled)
+
+	assert.NoError(t, WrapError(ErrThrottled, nil))
+}
+
+func TestErrorStatus(t *testing.T) {
+	tests := []struct {
+		err    error
+		code   codes.Code
+		status int
+	}{
+		{err: ErrDoesNotExist, code: codes.Internal, status: http.StatusInternalServerError},
+		{err: status.Error(codes.ResourceExhausted, "a"), code: codes.ResourceExhausted, status: http.StatusTooManyRequests},
+		{err: WrapError(ErrThrottled, errors.New("a")), code: codes.ResourceExhausted, status: http.StatusTooManyRequests},
+		{err: WrapError(ErrReadOnly, errors.New("a")), code: codes.FailedPrecondition, status: http.StatusConflict},
+		{err: WrapError(ErrBadRequest, errors.New("a")), code: codes.InvalidArgument, status: http.StatusBadRequest},
+		{err: ErrEmptyTenantID, code: codes.InvalidArgument, status: http.StatusBadRequest},
+		{err: WrapError(ErrCorrupt, errors.New("a")), code: codes.DataLoss, status: http.StatusInternalServerError},
+		{err: errors.New("a"), code: codes.Internal, status: http.StatusInternalServerError},
+	}
+	for _, tc := range tests {
+		err := fmt.Errorf("wrapped: %w", tc.err)
+		assert.Equal(t, tc.code, GRPCCode(err), tc.err.Error())
+		assert.Equal(t, tc.status, HTTPStatus(err), tc.err.Error())
+		assert.Equal(t, tc.code, status.Code(GRPCError(tc.err)), tc.err.Error())
+	}
+
+	assert.NoError(t, GRPCError(nil))
+}
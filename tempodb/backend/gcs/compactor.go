@@ -22,10 +22,10 @@ func (rw *readerWriter) MarkBlockCompacted(blockID uuid.UUID, tenantID string) e
 	ctx := context.TODO()
 	_, err := dst.CopierFrom(src).Run(ctx)
 	if err != nil {
-		return err
+		return writeError(err)
 	}
 
-	return src.Delete(ctx)
+	return writeError(src.Delete(ctx))
 }
 
 func (rw *readerWriter) ClearBlock(blockID uuid.UUID, tenantID string) error {
@@ -49,13 +49,13 @@ func (rw *readerWriter) ClearBlock(blockID uuid.UUID, tenantID string) error {
 			break
 		}
 		if err != nil {
-			return err
+			return readError(err)
 		}
 
 		o := rw.bucket.Object(attrs.Name)
 		err = o.Delete(ctx)
 		if err != nil {
-			return err
+			return writeError(err)
 		}
 	}
 
@@ -73,7 +73,7 @@ func (rw *readerWriter) CompactedBlockMeta(blockID uuid.UUID, tenantID string) (
 	out := &backend.CompactedBlockMeta{}
 	err = json.Unmarshal(bytes, out)
 	if err != nil {
-		return nil, err
+		return nil, backend.WrapError(backend.ErrCorrupt, err)
 	}
 	out.CompactedTime = modTime
 
@@ -1,11 +1,17 @@
 package gcs
 
-import "time"
+import (
+	"time"
+
+	"github.com/grafana/tempo/tempodb/backend/instrumentation"
+)
 
 type Config struct {
-	BucketName      string        `yaml:"bucket_name"`
-	ChunkBufferSize int           `yaml:"chunk_buffer_size"`
-	Endpoint        string        `yaml:"endpoint"`
-	Insecure        bool          `yaml:"insecure"`
-	HedgeRequestsAt time.Duration `yaml:"hedge_requests_at"`
+	BucketName            string                              `yaml:"bucket_name"`
+	ChunkBufferSize       int                                 `yaml:"chunk_buffer_size"`
+	Endpoint              string                              `yaml:"endpoint"`
+	Insecure              bool                                `yaml:"insecure"`
+	HedgeRequestsAt       time.Duration                       `yaml:"hedge_requests_at"`
+	HedgeRequestsAdaptive instrumentation.AdaptiveHedgeConfig `yaml:"hedge_requests_adaptive"`
+	Transport             instrumentation.TransportConfig     `yaml:"transport"`
 }
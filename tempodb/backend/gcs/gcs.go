@@ -14,9 +14,9 @@ import (
 	"github.com/grafana/tempo/tempodb/backend/instrumentation"
 
 	"cloud.google.com/go/storage"
-	"github.com/cristalhq/hedgedhttp"
 	"github.com/opentracing/opentracing-go"
 	"github.com/pkg/errors"
+	"google.golang.org/api/googleapi"
 	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
 	google_http "google.golang.org/api/transport/http"
@@ -36,12 +36,15 @@ type readerWriter struct {
 func New(cfg *Config) (backend.RawReader, backend.RawWriter, backend.Compactor, error) {
 	ctx := context.Background()
 
-	bucket, err := createBucket(ctx, cfg, false)
+	// the bucket and the hedged bucket share one connection pool
+	transport := newTransport(cfg)
+
+	bucket, err := createBucket(ctx, cfg, transport, false)
 	if err != nil {
 		return nil, nil, nil, errors.Wrap(err, "creating bucket")
 	}
 
-	hedgedBucket, err := createBucket(ctx, cfg, true)
+	hedgedBucket, err := createBucket(ctx, cfg, transport, true)
 	if err != nil {
 		return nil, nil, nil, errors.Wrap(err, "creating hedged bucket")
 	}
@@ -62,18 +65,20 @@ func New(cfg *Config) (backend.RawReader, backend.RawWriter, backend.Compactor,
 
 // StreamWriter implements backend.Writer
 func (rw *readerWriter) Write(ctx context.Context, name string, keypath backend.KeyPath, data io.Reader, _ int64, _ bool) error {
+	ctx = instrumentation.WithOperation(ctx, instrumentation.OperationWrite)
 	w := rw.writer(ctx, backend.ObjectFileName(keypath, name))
 	_, err := io.Copy(w, data)
 	if err != nil {
 		w.Close()
-		return err
+		return writeError(err)
 	}
 
-	return w.Close()
+	return writeError(w.Close())
 }
 
 // Append implements backend.Writer
 func (rw *readerWriter) Append(ctx context.Context, name string, keypath backend.KeyPath, tracker backend.AppendTracker, buffer []byte) (backend.AppendTracker, error) {
+	ctx = instrumentation.WithOperation(ctx, instrumentation.OperationWrite)
 	var w *storage.Writer
 	if tracker == nil {
 		w = rw.writer(ctx, backend.ObjectFileName(keypath, name))
@@ -83,7 +88,7 @@ func (rw *readerWriter) Append(ctx context.Context, name string, keypath backend
 
 	_, err := w.Write(buffer)
 	if err != nil {
-		return nil, err
+		return nil, writeError(err)
 	}
 
 	return w, nil
@@ -96,11 +101,12 @@ func (rw *readerWriter) CloseAppend(_ context.Context, tracker backend.AppendTra
 	}
 
 	w := tracker.(*storage.Writer)
-	return w.Close()
+	return writeError(w.Close())
 }
 
 // List implements backend.Reader
 func (rw *readerWriter) List(ctx context.Context, keypath backend.KeyPath) ([]string, error) {
+	ctx = instrumentation.WithOperation(ctx, instrumentation.OperationList)
 	prefix := path.Join(keypath...)
 	if len(prefix) > 0 {
 		prefix = prefix + "/"
@@ -118,7 +124,7 @@ func (rw *readerWriter) List(ctx context.Context, keypath backend.KeyPath) ([]st
 			break
 		}
 		if err != nil {
-			return nil, errors.Wrap(err, "iterating blocks")
+			return nil, readError(errors.Wrap(err, "iterating blocks"))
 		}
 
 		obj := strings.TrimSuffix(strings.TrimPrefix(attrs.Prefix, prefix), "/")
@@ -130,6 +136,7 @@ func (rw *readerWriter) List(ctx context.Context, keypath backend.KeyPath) ([]st
 
 // Read implements backend.Reader
 func (rw *readerWriter) Read(ctx context.Context, name string, keypath backend.KeyPath, _ bool) (io.ReadCloser, int64, error) {
+	ctx = instrumentation.WithOperation(ctx, instrumentation.OperationRead)
 	span, derivedCtx := opentracing.StartSpanFromContext(ctx, "gcs.Read")
 	defer span.Finish()
 
@@ -144,6 +151,7 @@ func (rw *readerWriter) Read(ctx context.Context, name string, keypath backend.K
 
 // ReadRange implements backend.Reader
 func (rw *readerWriter) ReadRange(ctx context.Context, name string, keypath backend.KeyPath, offset uint64, buffer []byte) error {
+	ctx = instrumentation.WithOperation(ctx, instrumentation.OperationReadRange)
 	span, derivedCtx := opentracing.StartSpanFromContext(ctx, "gcs.ReadRange")
 	defer span.Finish()
 
@@ -212,17 +220,24 @@ func (rw *readerWriter) readRange(ctx context.Context, name string, offset int64
 	}
 }
 
-func createBucket(ctx context.Context, cfg *Config, hedge bool) (*storage.BucketHandle, error) {
-	// start with default transport
+// newTransport returns the default transport tuned by the transport config.
+func newTransport(cfg *Config) *http.Transport {
 	customTransport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.Insecure {
+		customTransport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	cfg.Transport.Apply(customTransport)
+
+	return customTransport
+}
 
+func createBucket(ctx context.Context, cfg *Config, customTransport *http.Transport, hedge bool) (*storage.BucketHandle, error) {
 	// add google auth
 	transportOptions := []option.ClientOption{
 		option.WithScopes(storage.ScopeReadWrite),
 	}
 	if cfg.Insecure {
 		transportOptions = append(transportOptions, option.WithoutAuthentication())
-		customTransport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
 	}
 	transport, err := google_http.NewTransport(ctx, customTransport, transportOptions...)
 	if err != nil {
@@ -231,12 +246,10 @@ func createBucket(ctx context.Context, cfg *Config, hedge bool) (*storage.Bucket
 
 	// add instrumentation
 	transport = instrumentation.NewGCSTransport(transport)
-	var stats *hedgedhttp.Stats
 
-	// hedge if desired (0 means disabled)
-	if hedge && cfg.HedgeRequestsAt != 0 {
-		transport, stats = hedgedhttp.NewRoundTripperAndStats(cfg.HedgeRequestsAt, uptoHedgedRequests, transport)
-		instrumentation.PublishHedgedMetrics(stats)
+	// hedge if desired
+	if hedge {
+		transport = instrumentation.NewHedgedTransport(transport, uptoHedgedRequests, cfg.HedgeRequestsAt, cfg.HedgeRequestsAdaptive)
 	}
 
 	// build client
@@ -258,10 +271,41 @@ func createBucket(ctx context.Context, cfg *Config, hedge bool) (*storage.Bucket
 	return client.Bucket(cfg.BucketName), nil
 }
 
+// readError maps the errors of the gcs client onto the errors of the backend.
 func readError(err error) error {
 	if err == storage.ErrObjectNotExist {
 		return backend.ErrDoesNotExist
 	}
+	// the client has no typed error for checksum mismatches
+	if err != nil && strings.Contains(err.Error(), "storage: bad CRC on read") {
+		return backend.WrapError(backend.ErrCorrupt, err)
+	}
 
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) {
+		return err
+	}
+	switch apiErr.Code {
+	case http.StatusNotFound:
+		return backend.ErrDoesNotExist
+	case http.StatusTooManyRequests:
+		return backend.WrapError(backend.ErrThrottled, err)
+	case http.StatusBadRequest:
+		return backend.WrapError(backend.ErrBadRequest, err)
+	}
 	return err
 }
+
+// writeError maps the errors of writes to gcs onto the errors of the backend. Overwrites and deletes of objects
+// under a retention policy or a hold are read only.
+func writeError(err error) error {
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) && apiErr.Code == http.StatusForbidden {
+		for _, item := range apiErr.Errors {
+			if item.Reason == "retentionPolicyNotMet" || item.Reason == "objectUnderActiveHold" {
+				return backend.WrapError(backend.ErrReadOnly, err)
+			}
+		}
+	}
+	return readError(err)
+}
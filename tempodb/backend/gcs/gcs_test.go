@@ -12,8 +12,12 @@ import (
 
 	"cloud.google.com/go/storage"
 	"github.com/grafana/tempo/tempodb/backend"
+	"github.com/grafana/tempo/tempodb/backend/instrumentation"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/grpc/codes"
 )
 
 func TestHedge(t *testing.T) {
@@ -84,6 +88,30 @@ func TestHedge(t *testing.T) {
 	}
 }
 
+func TestTransportConfig(t *testing.T) {
+	transport := newTransport(&Config{
+		Transport: instrumentation.TransportConfig{
+			MaxIdleConns:        10,
+			MaxIdleConnsPerHost: 5,
+			IdleConnTimeout:     time.Second,
+			TLSHandshakeTimeout: 2 * time.Second,
+			DisableHTTP2:        true,
+		},
+	})
+	assert.Equal(t, 10, transport.MaxIdleConns)
+	assert.Equal(t, 5, transport.MaxIdleConnsPerHost)
+	assert.Equal(t, time.Second, transport.IdleConnTimeout)
+	assert.Equal(t, 2*time.Second, transport.TLSHandshakeTimeout)
+	assert.False(t, transport.ForceAttemptHTTP2)
+
+	// the defaults of the http client are kept
+	transport = newTransport(&Config{})
+	defaults := http.DefaultTransport.(*http.Transport)
+	assert.Equal(t, defaults.MaxIdleConns, transport.MaxIdleConns)
+	assert.Equal(t, defaults.IdleConnTimeout, transport.IdleConnTimeout)
+	assert.True(t, transport.ForceAttemptHTTP2)
+}
+
 func fakeServer(t *testing.T, returnIn time.Duration, counter *int32) *httptest.Server {
 	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		time.Sleep(returnIn)
@@ -105,4 +133,30 @@ func TestReadError(t *testing.T) {
 	wups := fmt.Errorf("wups")
 	errB = readError(wups)
 	assert.Equal(t, wups, errB)
+
+	tests := []struct {
+		err      error
+		expected error
+	}{
+		{err: &googleapi.Error{Code: http.StatusNotFound}, expected: backend.ErrDoesNotExist},
+		{err: &googleapi.Error{Code: http.StatusTooManyRequests}, expected: backend.ErrThrottled},
+		{err: &googleapi.Error{Code: http.StatusBadRequest}, expected: backend.ErrBadRequest},
+		{err: fmt.Errorf("storage: bad CRC on read: got 1, want 2"), expected: backend.ErrCorrupt},
+	}
+	for _, tc := range tests {
+		assert.ErrorIs(t, readError(errors.Wrap(tc.err, "iterating blocks")), tc.expected, tc.err.Error())
+	}
+	assert.Equal(t, codes.Internal, backend.GRPCCode(readError(&googleapi.Error{Code: http.StatusInternalServerError})))
+}
+
+func TestWriteError(t *testing.T) {
+	retention := &googleapi.Error{Code: http.StatusForbidden, Errors: []googleapi.ErrorItem{{Reason: "retentionPolicyNotMet"}}}
+	assert.ErrorIs(t, writeError(retention), backend.ErrReadOnly)
+
+	// other forbidden writes aren't read only
+	forbidden := &googleapi.Error{Code: http.StatusForbidden, Errors: []googleapi.ErrorItem{{Reason: "forbidden"}}}
+	assert.Equal(t, forbidden, writeError(forbidden))
+
+	assert.ErrorIs(t, writeError(&googleapi.Error{Code: http.StatusTooManyRequests}), backend.ErrThrottled)
+	assert.NoError(t, writeError(nil))
 }
@@ -0,0 +1,200 @@
+package instrumentation
+
+import (
+	"flag"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cristalhq/hedgedhttp"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	// hedgeThresholdInterval is how often the hedging threshold of an operation is recalculated
+	hedgeThresholdInterval = time.Minute
+	// hedgeLatencyWindow is the number of recent latencies kept per operation
+	hedgeLatencyWindow = 1000
+	// hedgeLatencyMinSamples is the number of latencies required before the threshold follows them
+	hedgeLatencyMinSamples = 100
+
+	operationRangeRead = "GET_RANGE"
+)
+
+var (
+	hedgeThresholdSeconds = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "tempodb",
+		Name:      "backend_hedge_threshold_seconds",
+		Help:      "The duration after which a backend request is hedged when the threshold is adaptive.",
+	}, []string{"operation"})
+)
+
+// AdaptiveHedgeConfig configures hedging with a threshold that follows the latency of the backend.
+type AdaptiveHedgeConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Multiplier of the p99 latency of recent requests at which requests are hedged
+	Multiplier float64       `yaml:"multiplier"`
+	Min        time.Duration `yaml:"min"`
+	Max        time.Duration `yaml:"max"`
+}
+
+// RegisterFlagsAndApplyDefaults registers the flags.
+func (cfg *AdaptiveHedgeConfig) RegisterFlagsAndApplyDefaults(prefix string, f *flag.FlagSet) {
+	f.BoolVar(&cfg.Enabled, prefix+".enabled", false, "Hedge requests at a multiple of the p99 latency of recent requests. A static hedge_requests_at takes precedence.")
+	f.Float64Var(&cfg.Multiplier, prefix+".multiplier", 1, "Multiple of the p99 latency at which requests are hedged.")
+	f.DurationVar(&cfg.Min, prefix+".min", 100*time.Millisecond, "Lower bound of the hedging threshold.")
+	f.DurationVar(&cfg.Max, prefix+".max", 10*time.Second, "Upper bound of the hedging threshold. 0 for no bound.")
+}
+
+// NewHedgedTransport returns next with hedging of up to upto requests. A non zero hedgeAt hedges at that fixed
+// duration and takes precedence over the adaptive config. next is returned as is if hedging is disabled.
+func NewHedgedTransport(next http.RoundTripper, upto int, hedgeAt time.Duration, adaptive AdaptiveHedgeConfig) http.RoundTripper {
+	if hedgeAt != 0 {
+		return hedgeOutcomeTransport{next: hedgedhttp.NewRoundTripper(hedgeAt, upto, hedgeAttemptTransport{next: next})}
+	}
+
+	if adaptive.Enabled {
+		return hedgeOutcomeTransport{next: newAdaptiveHedgedTransport(hedgeAttemptTransport{next: next}, upto, adaptive)}
+	}
+
+	return next
+}
+
+// adaptiveHedgedTransport hedges requests at a multiple of the p99 latency of recent requests of the same operation.
+// The thresholds are recalculated every hedgeThresholdInterval by the first request after it passes. Requests are
+// not hedged until hedgeLatencyMinSamples latencies of the operation are known.
+type adaptiveHedgedTransport struct {
+	cfg  AdaptiveHedgeConfig
+	upto int
+	next http.RoundTripper
+
+	mtx        sync.Mutex
+	operations map[string]*hedgedOperation
+	now        func() time.Time // for testing
+}
+
+// hedgedOperation holds the recent latencies of an operation and the transport that hedges it at the current
+// threshold.
+type hedgedOperation struct {
+	latencies   []time.Duration // ring buffer of the last hedgeLatencyWindow latencies
+	next        int
+	threshold   time.Duration // 0 if not hedged
+	evaluatedAt time.Time
+	transport   http.RoundTripper
+}
+
+func newAdaptiveHedgedTransport(next http.RoundTripper, upto int, cfg AdaptiveHedgeConfig) *adaptiveHedgedTransport {
+	return &adaptiveHedgedTransport{
+		cfg:        cfg,
+		upto:       upto,
+		next:       next,
+		operations: map[string]*hedgedOperation{},
+		now:        time.Now,
+	}
+}
+
+func (t *adaptiveHedgedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.transport(requestOperation(req)).RoundTrip(req)
+}
+
+// transport returns the hedged transport for the operation at its current threshold.
+func (t *adaptiveHedgedTransport) transport(operation string) http.RoundTripper {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	op := t.operation(operation)
+	now := t.now()
+	if op.transport == nil || now.Sub(op.evaluatedAt) >= hedgeThresholdInterval {
+		op.evaluatedAt = now
+
+		threshold := t.threshold(op)
+		if op.transport == nil || threshold != op.threshold {
+			op.threshold = threshold
+
+			var transport http.RoundTripper = &latencyRecorder{
+				next:      t.next,
+				operation: operation,
+				parent:    t,
+			}
+			if threshold > 0 {
+				transport = hedgedhttp.NewRoundTripper(threshold, t.upto, transport)
+			}
+			op.transport = transport
+			hedgeThresholdSeconds.WithLabelValues(operation).Set(threshold.Seconds())
+		}
+	}
+
+	return op.transport
+}
+
+// threshold is the multiple of the p99 of the recent latencies of the operation bounded by the configured min and
+// max. 0 until enough latencies are known to not hedge.
+func (t *adaptiveHedgedTransport) threshold(op *hedgedOperation) time.Duration {
+	if len(op.latencies) < hedgeLatencyMinSamples {
+		return 0
+	}
+
+	sorted := make([]time.Duration, len(op.latencies))
+	copy(sorted, op.latencies)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	p99 := sorted[(len(sorted)*99-1)/100]
+
+	threshold := time.Duration(float64(p99) * t.cfg.Multiplier)
+	if threshold < t.cfg.Min {
+		threshold = t.cfg.Min
+	}
+	if t.cfg.Max > 0 && threshold > t.cfg.Max {
+		threshold = t.cfg.Max
+	}
+	return threshold
+}
+
+func (t *adaptiveHedgedTransport) observe(operation string, latency time.Duration) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	op := t.operation(operation)
+	if len(op.latencies) < hedgeLatencyWindow {
+		op.latencies = append(op.latencies, latency)
+		return
+	}
+	op.latencies[op.next] = latency
+	op.next = (op.next + 1) % hedgeLatencyWindow
+}
+
+// operation must be called under lock.
+func (t *adaptiveHedgedTransport) operation(operation string) *hedgedOperation {
+	op, ok := t.operations[operation]
+	if !ok {
+		op = &hedgedOperation{}
+		t.operations[operation] = op
+	}
+	return op
+}
+
+// latencyRecorder records the latency of every request sent by the hedged transport. Requests that failed or were
+// cancelled because another one returned first are recorded with the time they ran, a lower bound of their latency,
+// so the slow requests that are hedged still count towards the p99.
+type latencyRecorder struct {
+	next      http.RoundTripper
+	operation string
+	parent    *adaptiveHedgedTransport
+}
+
+func (r *latencyRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := r.next.RoundTrip(req)
+	r.parent.observe(r.operation, time.Since(start))
+	return resp, err
+}
+
+// requestOperation groups requests with comparable latencies. Range reads are much smaller than full reads so they are
+// tracked separately.
+func requestOperation(req *http.Request) string {
+	if req.Method == http.MethodGet && (req.Header.Get("Range") != "" || req.Header.Get("X-Ms-Range") != "") {
+		return operationRangeRead
+	}
+	return req.Method
+}
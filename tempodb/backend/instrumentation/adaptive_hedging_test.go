@@ -0,0 +1,137 @@
+package instrumentation
+
+import (
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+
+	"github.com/grafana/tempo/pkg/util/test"
+)
+
+func TestAdaptiveHedgeThreshold(t *testing.T) {
+	now := time.Unix(0, 0)
+	transport := newAdaptiveHedgedTransport(http.DefaultTransport, 2, AdaptiveHedgeConfig{
+		Enabled:    true,
+		Multiplier: 2,
+		Min:        10 * time.Millisecond,
+		Max:        5 * time.Second,
+	})
+	transport.now = func() time.Time { return now }
+
+	threshold := func(operation string) time.Duration {
+		transport.transport(operation)
+		return transport.operations[operation].threshold
+	}
+
+	// latencies uniformly distributed from 0 to max with a p99 of ~0.99 * max
+	observe := func(operation string, max time.Duration) {
+		for i := 0; i < hedgeLatencyWindow; i++ {
+			transport.observe(operation, time.Duration(rand.Int63n(int64(max))))
+		}
+	}
+
+	// not hedged until enough latencies are known
+	assert.Equal(t, time.Duration(0), threshold(http.MethodGet))
+	observe(http.MethodGet, 100*time.Millisecond)
+	assert.Equal(t, time.Duration(0), threshold(http.MethodGet))
+
+	now = now.Add(hedgeThresholdInterval)
+	assert.InDelta(t, 198*time.Millisecond, threshold(http.MethodGet), float64(5*time.Millisecond))
+
+	gauge, err := test.GetGaugeValue(hedgeThresholdSeconds.WithLabelValues(http.MethodGet))
+	require.NoError(t, err)
+	assert.Equal(t, threshold(http.MethodGet).Seconds(), gauge)
+
+	// the threshold follows the latency once it is reevaluated
+	observe(http.MethodGet, time.Second)
+	assert.InDelta(t, 198*time.Millisecond, threshold(http.MethodGet), float64(5*time.Millisecond))
+	now = now.Add(hedgeThresholdInterval)
+	assert.InDelta(t, 1980*time.Millisecond, threshold(http.MethodGet), float64(50*time.Millisecond))
+
+	// range reads are tracked separately
+	observe(operationRangeRead, 10*time.Second)
+	now = now.Add(hedgeThresholdInterval)
+	assert.Equal(t, 5*time.Second, threshold(operationRangeRead))
+	assert.InDelta(t, 1980*time.Millisecond, threshold(http.MethodGet), float64(50*time.Millisecond))
+
+	// bounded by the min
+	observe(http.MethodGet, time.Millisecond)
+	now = now.Add(hedgeThresholdInterval)
+	assert.Equal(t, 10*time.Millisecond, threshold(http.MethodGet))
+}
+
+func TestAdaptiveHedgeRequests(t *testing.T) {
+	requests := atomic.NewInt32(0)
+	slow := atomic.NewBool(false)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// only the first request of a slow call is slow
+		if requests.Inc() == 1 && slow.Load() {
+			time.Sleep(500 * time.Millisecond)
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	now := time.Unix(0, 0)
+	transport := newAdaptiveHedgedTransport(hedgeAttemptTransport{next: http.DefaultTransport}, 2, AdaptiveHedgeConfig{
+		Enabled:    true,
+		Multiplier: 1,
+		Min:        50 * time.Millisecond,
+		Max:        time.Second,
+	})
+	transport.now = func() time.Time { return now }
+	client := &http.Client{Transport: hedgeOutcomeTransport{next: transport}}
+
+	get := func() {
+		requests.Store(0)
+		resp, err := client.Get(server.URL)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+	}
+
+	// slow requests are not hedged while the latencies are unknown
+	for i := 0; i < hedgeLatencyMinSamples; i++ {
+		get()
+	}
+	slow.Store(true)
+	get()
+	assert.Equal(t, int32(1), requests.Load())
+
+	// once the threshold is known slow requests are hedged at the min
+	hedgedBefore, err := test.GetCounterValue(hedgedRequestsMetrics)
+	require.NoError(t, err)
+	wonBefore, err := test.GetCounterValue(hedgedRequestsWon.WithLabelValues(operationOther))
+	require.NoError(t, err)
+
+	now = now.Add(hedgeThresholdInterval)
+	start := time.Now()
+	get()
+	assert.Less(t, time.Since(start), 400*time.Millisecond)
+	assert.Equal(t, int32(2), requests.Load())
+
+	hedged, err := test.GetCounterValue(hedgedRequestsMetrics)
+	require.NoError(t, err)
+	assert.Equal(t, 1.0, hedged-hedgedBefore)
+	won, err := test.GetCounterValue(hedgedRequestsWon.WithLabelValues(operationOther))
+	require.NoError(t, err)
+	assert.Equal(t, 1.0, won-wonBefore)
+
+	// the cancelled slow request is recorded with the time it ran, like the slow request that wasn't hedged
+	require.Eventually(t, func() bool {
+		transport.mtx.Lock()
+		defer transport.mtx.Unlock()
+		slowLatencies := 0
+		for _, latency := range transport.operations[http.MethodGet].latencies {
+			if latency >= 50*time.Millisecond {
+				slowLatencies++
+			}
+		}
+		return slowLatencies == 2
+	}, time.Second, 10*time.Millisecond)
+}
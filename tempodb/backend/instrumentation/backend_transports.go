@@ -1,6 +1,8 @@
 package instrumentation
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
@@ -9,6 +11,19 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promauto"
 )
 
+// Operations of the backend readers and writers the requests to the backends are labelled with.
+const (
+	OperationRead      = "Read"
+	OperationReadRange = "ReadRange"
+	OperationList      = "List"
+	OperationWrite     = "Write"
+	// operationOther labels the requests sent without an operation, like the ones of the compactor
+	operationOther = "Other"
+
+	statusCodeError     = "error"
+	statusCodeCancelled = "cancelled"
+)
+
 var (
 	gcsRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
 		Namespace: "tempodb",
@@ -37,8 +52,37 @@ var (
 		Help:      "Time spent doing backend storage requests.",
 		Buckets:   prometheus.ExponentialBuckets(0.005, 4, 6),
 	}, []string{"operation", "status_code"})
+
+	requestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempodb",
+		Name:      "backend_requests_total",
+		Help:      "Total number of backend storage requests by operation of the reader or writer and status code.",
+	}, []string{"operation", "status_code"})
+
+	operationDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "tempodb",
+		Name:      "backend_operation_request_duration_seconds",
+		Help:      "Time spent doing backend storage requests by operation of the reader or writer.",
+		Buckets:   prometheus.ExponentialBuckets(0.005, 4, 6),
+	}, []string{"operation", "status_code"})
 )
 
+type operationKey struct{}
+
+// WithOperation returns ctx with the operation of the backend reader or writer that the requests sent with it are
+// labelled with. The metrics are the same for all backends.
+func WithOperation(ctx context.Context, operation string) context.Context {
+	return context.WithValue(ctx, operationKey{}, operation)
+}
+
+// backendOperation returns the operation of ctx, operationOther if it has none.
+func backendOperation(ctx context.Context) string {
+	if operation, ok := ctx.Value(operationKey{}).(string); ok {
+		return operation
+	}
+	return operationOther
+}
+
 type instrumentedTransport struct {
 	legacyObserver prometheus.ObserverVec
 	observer       prometheus.ObserverVec
@@ -72,9 +116,21 @@ func NewAzureTransport(next http.RoundTripper) http.RoundTripper {
 func (i instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 	start := time.Now()
 	resp, err := i.next.RoundTrip(req)
+	duration := time.Since(start).Seconds()
+
+	statusCode := statusCodeError
 	if err == nil {
-		i.legacyObserver.WithLabelValues(req.Method, strconv.Itoa(resp.StatusCode)).Observe(time.Since(start).Seconds())
-		i.observer.WithLabelValues(req.Method, strconv.Itoa(resp.StatusCode)).Observe(time.Since(start).Seconds())
+		statusCode = strconv.Itoa(resp.StatusCode)
+		i.legacyObserver.WithLabelValues(req.Method, statusCode).Observe(duration)
+		i.observer.WithLabelValues(req.Method, statusCode).Observe(duration)
+	} else if errors.Is(err, context.Canceled) || req.Context().Err() != nil {
+		// includes the hedged requests that lost
+		statusCode = statusCodeCancelled
 	}
+
+	operation := backendOperation(req.Context())
+	requestsTotal.WithLabelValues(operation, statusCode).Inc()
+	operationDuration.WithLabelValues(operation, statusCode).Observe(duration)
+
 	return resp, err
 }
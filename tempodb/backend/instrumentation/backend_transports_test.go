@@ -0,0 +1,84 @@
+package instrumentation
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+
+	"github.com/grafana/tempo/pkg/util/test"
+)
+
+func TestInstrumentedTransportOperations(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	transport := NewGCSTransport(http.DefaultTransport)
+	roundTrip := func(ctx context.Context, path string) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+path, nil)
+		require.NoError(t, err)
+		resp, err := transport.RoundTrip(req)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+	}
+
+	readBefore := counterValue(t, requestsTotal.WithLabelValues(OperationRead, "200"))
+	otherBefore := counterValue(t, requestsTotal.WithLabelValues(operationOther, "404"))
+
+	roundTrip(WithOperation(context.Background(), OperationRead), "/")
+	roundTrip(context.Background(), "/missing")
+
+	assert.Equal(t, 1.0, counterValue(t, requestsTotal.WithLabelValues(OperationRead, "200"))-readBefore)
+	assert.Equal(t, 1.0, counterValue(t, requestsTotal.WithLabelValues(operationOther, "404"))-otherBefore)
+}
+
+func TestHedgedTransportOutcomes(t *testing.T) {
+	requests := atomic.NewInt32(0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the first request is slow, the hedged request wins
+		if requests.Inc() == 1 {
+			select {
+			case <-time.After(5 * time.Second):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	issuedBefore := counterValue(t, hedgedRequestsIssued.WithLabelValues(OperationReadRange))
+	wonBefore := counterValue(t, hedgedRequestsWon.WithLabelValues(OperationReadRange))
+	cancelledBefore := counterValue(t, requestsTotal.WithLabelValues(OperationReadRange, statusCodeCancelled))
+
+	transport := NewHedgedTransport(NewS3Transport(http.DefaultTransport), 2, 50*time.Millisecond, AdaptiveHedgeConfig{})
+	req, err := http.NewRequestWithContext(WithOperation(context.Background(), OperationReadRange), http.MethodGet, server.URL, nil)
+	require.NoError(t, err)
+	resp, err := transport.RoundTrip(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+
+	assert.Equal(t, int32(2), requests.Load())
+	assert.Equal(t, 1.0, counterValue(t, hedgedRequestsIssued.WithLabelValues(OperationReadRange))-issuedBefore)
+	assert.Equal(t, 1.0, counterValue(t, hedgedRequestsWon.WithLabelValues(OperationReadRange))-wonBefore)
+	// the original request is cancelled once the hedged request returned
+	require.Eventually(t, func() bool {
+		return counterValue(t, requestsTotal.WithLabelValues(OperationReadRange, statusCodeCancelled))-cancelledBefore == 1
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func counterValue(t *testing.T, c prometheus.Counter) float64 {
+	v, err := test.GetCounterValue(c)
+	require.NoError(t, err)
+	return v
+}
@@ -1,15 +1,13 @@
 package instrumentation
 
 import (
-	"time"
+	"context"
+	"net/http"
+	"sync"
 
-	"github.com/cristalhq/hedgedhttp"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
-)
-
-const (
-	hedgedMetricsPublishDuration = 10 * time.Second
+	"go.uber.org/atomic"
 )
 
 var (
@@ -17,17 +15,82 @@ var (
 		prometheus.CounterOpts{
 			Namespace: "tempodb",
 			Name:      "backend_hedged_roundtrips_total",
-			Help:      "Total number of hedged backend requests",
+			Help:      "Total number of hedged backend requests. (DEPRECATED: See tempodb_backend_hedged_requests_total)",
 		},
 	)
+
+	hedgedRequestsIssued = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempodb",
+		Name:      "backend_hedged_requests_total",
+		Help:      "Total number of hedged backend requests issued by operation of the reader or writer.",
+	}, []string{"operation"})
+
+	hedgedRequestsWon = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempodb",
+		Name:      "backend_hedged_requests_won_total",
+		Help:      "Total number of hedged backend requests that returned before the original request by operation of the reader or writer.",
+	}, []string{"operation"})
 )
 
-// PublishHedgedMetrics flushes metrics from hedged requests every 10 seconds
-func PublishHedgedMetrics(s *hedgedhttp.Stats) {
-	ticker := time.NewTicker(hedgedMetricsPublishDuration)
-	go func() {
-		for range ticker.C {
-			hedgedRequestsMetrics.Add(float64(s.Snapshot().RequestedRoundTrips))
-		}
-	}()
+type hedgeAttemptsKey struct{}
+
+// hedgeAttempts are the requests sent by a hedging transport for a request. They are tracked through the context,
+// which is shared by the hedged requests.
+type hedgeAttempts struct {
+	sent atomic.Int32
+
+	mtx       sync.Mutex
+	responses map[*http.Response]int32
+}
+
+func (a *hedgeAttempts) record(resp *http.Response, attempt int32) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	a.responses[resp] = attempt
+}
+
+// attempt returns the attempt that returned resp, 0 if unknown.
+func (a *hedgeAttempts) attempt(resp *http.Response) int32 {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	return a.responses[resp]
+}
+
+// hedgeOutcomeTransport wraps a hedging transport and counts the requests won by a hedged request.
+type hedgeOutcomeTransport struct {
+	next http.RoundTripper
+}
+
+func (t hedgeOutcomeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	attempts := &hedgeAttempts{responses: map[*http.Response]int32{}}
+	resp, err := t.next.RoundTrip(req.WithContext(context.WithValue(req.Context(), hedgeAttemptsKey{}, attempts)))
+	if err == nil && attempts.attempt(resp) > 1 {
+		hedgedRequestsWon.WithLabelValues(backendOperation(req.Context())).Inc()
+	}
+	return resp, err
+}
+
+// hedgeAttemptTransport is wrapped by a hedging transport and counts the requests it sends. Every request after the
+// first one is a hedged request.
+type hedgeAttemptTransport struct {
+	next http.RoundTripper
+}
+
+func (t hedgeAttemptTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	attempts, ok := req.Context().Value(hedgeAttemptsKey{}).(*hedgeAttempts)
+	if !ok {
+		return t.next.RoundTrip(req)
+	}
+
+	attempt := attempts.sent.Inc()
+	if attempt > 1 {
+		hedgedRequestsMetrics.Inc()
+		hedgedRequestsIssued.WithLabelValues(backendOperation(req.Context())).Inc()
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err == nil {
+		attempts.record(resp, attempt)
+	}
+	return resp, err
 }
@@ -0,0 +1,48 @@
+package instrumentation
+
+import (
+	"crypto/tls"
+	"flag"
+	"net/http"
+	"time"
+)
+
+// TransportConfig tunes the connection pool of the http transport shared by the clients of a backend. Zero values keep
+// the defaults of the backend.
+type TransportConfig struct {
+	MaxIdleConns        int           `yaml:"max_idle_conns"`
+	MaxIdleConnsPerHost int           `yaml:"max_idle_conns_per_host"`
+	IdleConnTimeout     time.Duration `yaml:"idle_conn_timeout"`
+	TLSHandshakeTimeout time.Duration `yaml:"tls_handshake_timeout"`
+	DisableHTTP2        bool          `yaml:"disable_http2"`
+}
+
+// RegisterFlagsAndApplyDefaults registers the flags.
+func (cfg *TransportConfig) RegisterFlagsAndApplyDefaults(prefix string, f *flag.FlagSet) {
+	f.IntVar(&cfg.MaxIdleConns, prefix+".max-idle-conns", 0, "Maximum idle connections to the backend. 0 keeps the default of the backend.")
+	f.IntVar(&cfg.MaxIdleConnsPerHost, prefix+".max-idle-conns-per-host", 0, "Maximum idle connections per host of the backend. 0 keeps the default of the backend.")
+	f.DurationVar(&cfg.IdleConnTimeout, prefix+".idle-conn-timeout", 0, "Duration after which idle connections to the backend are closed. 0 keeps the default of the backend.")
+	f.DurationVar(&cfg.TLSHandshakeTimeout, prefix+".tls-handshake-timeout", 0, "Timeout of TLS handshakes with the backend. 0 keeps the default of the backend.")
+	f.BoolVar(&cfg.DisableHTTP2, prefix+".disable-http2", false, "Use HTTP/1.1 only for requests to the backend.")
+}
+
+// Apply sets the configured values on t.
+func (cfg TransportConfig) Apply(t *http.Transport) {
+	if cfg.MaxIdleConns > 0 {
+		t.MaxIdleConns = cfg.MaxIdleConns
+	}
+	if cfg.MaxIdleConnsPerHost > 0 {
+		t.MaxIdleConnsPerHost = cfg.MaxIdleConnsPerHost
+	}
+	if cfg.IdleConnTimeout > 0 {
+		t.IdleConnTimeout = cfg.IdleConnTimeout
+	}
+	if cfg.TLSHandshakeTimeout > 0 {
+		t.TLSHandshakeTimeout = cfg.TLSHandshakeTimeout
+	}
+	if cfg.DisableHTTP2 {
+		// a non nil TLSNextProto keeps the transport from upgrading connections to HTTP/2
+		t.ForceAttemptHTTP2 = false
+		t.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+}
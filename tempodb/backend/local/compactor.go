@@ -16,7 +16,7 @@ func (rw *Backend) MarkBlockCompacted(blockID uuid.UUID, tenantID string) error
 	metaFilename := rw.metaFileName(blockID, tenantID)
 	compactedMetaFilename := rw.compactedMetaFileName(blockID, tenantID)
 
-	return os.Rename(metaFilename, compactedMetaFilename)
+	return writeError(os.Rename(metaFilename, compactedMetaFilename))
 }
 
 func (rw *Backend) ClearBlock(blockID uuid.UUID, tenantID string) error {
@@ -28,7 +28,7 @@ func (rw *Backend) ClearBlock(blockID uuid.UUID, tenantID string) error {
 		return fmt.Errorf("empty block id")
 	}
 
-	return os.RemoveAll(rw.rootPath(backend.KeyPathForBlock(blockID, tenantID)))
+	return writeError(os.RemoveAll(rw.rootPath(backend.KeyPathForBlock(blockID, tenantID))))
 }
 
 func (rw *Backend) CompactedBlockMeta(blockID uuid.UUID, tenantID string) (*backend.CompactedBlockMeta, error) {
@@ -47,7 +47,7 @@ func (rw *Backend) CompactedBlockMeta(blockID uuid.UUID, tenantID string) (*back
 	out := &backend.CompactedBlockMeta{}
 	err = json.Unmarshal(bytes, out)
 	if err != nil {
-		return nil, err
+		return nil, backend.WrapError(backend.ErrCorrupt, err)
 	}
 	out.CompactedTime = fi.ModTime()
 
@@ -2,4 +2,6 @@ package local
 
 type Config struct {
 	Path string `yaml:"path"`
+	// writes of new objects fail while the used fraction of the volume of the path exceeds this, 0 disables it
+	MaxDiskUtilization float64 `yaml:"max_disk_utilization"`
 }
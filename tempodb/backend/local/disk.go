@@ -0,0 +1,37 @@
+package local
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// ErrMaxDiskUtilization is returned by writes while the utilization of the volume exceeds the max disk utilization.
+var ErrMaxDiskUtilization = errors.New("local backend disk utilization exceeds max_disk_utilization")
+
+var metricDiskUtilization = promauto.NewGauge(prometheus.GaugeOpts{
+	Namespace: "tempodb",
+	Name:      "local_disk_utilization",
+	Help:      "The used fraction of the volume of the local backend, updated by every write while max_disk_utilization is set.",
+})
+
+// checkDiskUtilization returns an error if the utilization of the volume of the backend exceeds the configured
+// maximum, so writes are rejected until retention and compaction free up space.
+func (rw *Backend) checkDiskUtilization() error {
+	if rw.cfg.MaxDiskUtilization <= 0 {
+		return nil
+	}
+
+	utilization, err := rw.utilization(rw.cfg.Path)
+	if err != nil {
+		return fmt.Errorf("failed to get local backend disk utilization: %w", err)
+	}
+	metricDiskUtilization.Set(utilization)
+
+	if utilization > rw.cfg.MaxDiskUtilization {
+		return fmt.Errorf("%w: %.3f used, max %.3f", ErrMaxDiskUtilization, utilization, rw.cfg.MaxDiskUtilization)
+	}
+	return nil
+}
@@ -2,17 +2,25 @@ package local
 
 import (
 	"context"
+	"errors"
 	"io"
-	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
+	"syscall"
 
 	"github.com/google/uuid"
+
+	"github.com/grafana/tempo/pkg/util"
 	"github.com/grafana/tempo/tempodb/backend"
 )
 
+const listBatchSize = 1000
+
 type Backend struct {
 	cfg *Config
+
+	utilization func(path string) (float64, error) // for testing
 }
 
 var _ backend.RawReader = (*Backend)(nil)
@@ -26,7 +34,8 @@ func NewBackend(cfg *Config) (*Backend, error) {
 	}
 
 	l := &Backend{
-		cfg: cfg,
+		cfg:         cfg,
+		utilization: util.VolumeUtilization,
 	}
 
 	return l, nil
@@ -39,40 +48,49 @@ func New(cfg *Config) (backend.RawReader, backend.RawWriter, backend.Compactor,
 
 // Write implements backend.Writer
 func (rw *Backend) Write(ctx context.Context, name string, keypath backend.KeyPath, data io.Reader, _ int64, _ bool) error {
+	if err := rw.checkDiskUtilization(); err != nil {
+		return err
+	}
+
 	blockFolder := rw.rootPath(keypath)
 	err := os.MkdirAll(blockFolder, os.ModePerm)
 	if err != nil {
-		return err
+		return writeError(err)
 	}
 
 	tracesFileName := rw.objectFileName(keypath, name)
 	dst, err := os.Create(tracesFileName)
 	if err != nil {
-		return err
+		return writeError(err)
 	}
 	defer dst.Close()
 
 	_, err = io.Copy(dst, data)
 	if err != nil {
-		return err
+		return writeError(err)
 	}
-	return err
+	return nil
 }
 
 // Append implements backend.Writer
 func (rw *Backend) Append(ctx context.Context, name string, keypath backend.KeyPath, tracker backend.AppendTracker, buffer []byte) (backend.AppendTracker, error) {
 	var dst *os.File
 	if tracker == nil {
+		// appends to objects already started are completed so no partial objects are left behind
+		if err := rw.checkDiskUtilization(); err != nil {
+			return nil, err
+		}
+
 		blockFolder := rw.rootPath(keypath)
 		err := os.MkdirAll(blockFolder, os.ModePerm)
 		if err != nil {
-			return nil, err
+			return nil, writeError(err)
 		}
 
 		tracesFileName := rw.objectFileName(keypath, name)
 		dst, err = os.Create(tracesFileName)
 		if err != nil {
-			return nil, err
+			return nil, writeError(err)
 		}
 	} else {
 		dst = tracker.(*os.File)
@@ -80,7 +98,7 @@ func (rw *Backend) Append(ctx context.Context, name string, keypath backend.KeyP
 
 	_, err := dst.Write(buffer)
 	if err != nil {
-		return nil, err
+		return nil, writeError(err)
 	}
 
 	return dst, nil
@@ -96,21 +114,35 @@ func (rw *Backend) CloseAppend(ctx context.Context, tracker backend.AppendTracke
 	return dst.Close()
 }
 
-// List implements backend.Reader
+// List implements backend.Reader. The directory is read in batches and only the names of its folders are kept, the
+// entries are not stat'ed.
 func (rw *Backend) List(ctx context.Context, keypath backend.KeyPath) ([]string, error) {
-	path := rw.rootPath(keypath)
-	folders, err := ioutil.ReadDir(path)
+	dir, err := os.Open(rw.rootPath(keypath))
 	if err != nil {
 		return nil, err
 	}
+	defer dir.Close()
 
-	objects := make([]string, 0, len(folders))
-	for _, f := range folders {
-		if !f.IsDir() {
-			continue
+	objects := make([]string, 0)
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		entries, err := dir.ReadDir(listBatchSize)
+		for _, e := range entries {
+			if e.IsDir() {
+				objects = append(objects, e.Name())
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
 		}
-		objects = append(objects, f.Name())
 	}
+	sort.Strings(objects)
 
 	return objects, nil
 }
@@ -167,10 +199,24 @@ func (rw *Backend) rootPath(keypath backend.KeyPath) string {
 	return filepath.Join(rw.cfg.Path, filepath.Join(keypath...))
 }
 
+// readError maps the errors of the file system onto the errors of the backend.
 func readError(err error) error {
 	if os.IsNotExist(err) {
 		return backend.ErrDoesNotExist
 	}
+	if errors.Is(err, syscall.ENAMETOOLONG) {
+		return backend.WrapError(backend.ErrBadRequest, err)
+	}
 
 	return err
 }
+
+// writeError maps the errors of writes to the file system onto the errors of the backend. Writes to a read only file
+// system or without the permission to are read only.
+func writeError(err error) error {
+	if errors.Is(err, syscall.EROFS) || os.IsPermission(err) {
+		return backend.WrapError(backend.ErrReadOnly, err)
+	}
+
+	return readError(err)
+}
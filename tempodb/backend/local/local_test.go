@@ -7,13 +7,18 @@ import (
 	"io/ioutil"
 	"math/rand"
 	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
 	"testing"
 
 	"github.com/grafana/tempo/pkg/io"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
+	"github.com/grafana/tempo/pkg/util/test"
 	"github.com/grafana/tempo/tempodb/backend"
 )
 
@@ -72,3 +77,93 @@ func TestReadWrite(t *testing.T) {
 	assert.Len(t, list, 1)
 	assert.Equal(t, blockID.String(), list[0])
 }
+
+func TestReadWriteError(t *testing.T) {
+	tempDir := t.TempDir()
+	r, w, c, err := New(&Config{Path: tempDir})
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+	_, _, err = r.Read(ctx, objectName, backend.KeyPathForBlock(uuid.New(), "fake"), false)
+	assert.Equal(t, backend.ErrDoesNotExist, err)
+	err = c.MarkBlockCompacted(uuid.New(), "fake")
+	assert.Equal(t, backend.ErrDoesNotExist, err)
+
+	tooLong := strings.Repeat("a", 1024)
+	err = w.Write(ctx, tooLong, backend.KeyPathForBlock(uuid.New(), "fake"), bytes.NewReader([]byte{0x01}), 1, false)
+	assert.ErrorIs(t, err, backend.ErrBadRequest)
+
+	tests := []struct {
+		err      error
+		expected error
+	}{
+		{err: &os.PathError{Op: "open", Path: "a", Err: syscall.EROFS}, expected: backend.ErrReadOnly},
+		{err: &os.PathError{Op: "open", Path: "a", Err: syscall.EACCES}, expected: backend.ErrReadOnly},
+		{err: &os.PathError{Op: "open", Path: "a", Err: syscall.ENOENT}, expected: backend.ErrDoesNotExist},
+	}
+	for _, tc := range tests {
+		assert.ErrorIs(t, writeError(tc.err), tc.expected, tc.err.Error())
+	}
+	assert.NoError(t, writeError(nil))
+}
+
+func TestList(t *testing.T) {
+	tempDir := t.TempDir()
+	r, _, _, err := New(&Config{Path: tempDir})
+	require.NoError(t, err)
+
+	// more folders than a batch, and files which are not listed
+	var expected []string
+	for i := 0; i < listBatchSize+10; i++ {
+		name := fmt.Sprintf("block-%05d", i)
+		expected = append(expected, name)
+		require.NoError(t, os.MkdirAll(filepath.Join(tempDir, "tenant", name), os.ModePerm))
+	}
+	require.NoError(t, ioutil.WriteFile(filepath.Join(tempDir, "tenant", "index.json.gz"), []byte{0x01}, 0644))
+
+	ctx := context.Background()
+	list, err := r.List(ctx, backend.KeyPath{"tenant"})
+	require.NoError(t, err)
+	assert.Equal(t, expected, list)
+
+	_, err = r.List(ctx, backend.KeyPath{"missing"})
+	assert.Error(t, err)
+
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = r.List(ctx, backend.KeyPath{"tenant"})
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestMaxDiskUtilization(t *testing.T) {
+	tempDir := t.TempDir()
+	b, err := NewBackend(&Config{Path: tempDir, MaxDiskUtilization: 0.9})
+	require.NoError(t, err)
+
+	utilization := 0.5
+	b.utilization = func(string) (float64, error) { return utilization, nil }
+
+	ctx := context.Background()
+	keypath := backend.KeyPathForBlock(uuid.New(), "fake")
+	require.NoError(t, b.Write(ctx, objectName, keypath, bytes.NewReader([]byte{0x01}), 1, false))
+	tracker, err := b.Append(ctx, "appended", keypath, nil, []byte{0x01})
+	require.NoError(t, err)
+
+	// new objects are rejected once the volume crosses the threshold, started appends are completed
+	utilization = 0.95
+	err = b.Write(ctx, objectName, keypath, bytes.NewReader([]byte{0x01}), 1, false)
+	assert.ErrorIs(t, err, ErrMaxDiskUtilization)
+	_, err = b.Append(ctx, "other", keypath, nil, []byte{0x01})
+	assert.ErrorIs(t, err, ErrMaxDiskUtilization)
+	tracker, err = b.Append(ctx, "appended", keypath, tracker, []byte{0x02})
+	require.NoError(t, err)
+	require.NoError(t, b.CloseAppend(ctx, tracker))
+
+	gauge, err := test.GetGaugeValue(metricDiskUtilization)
+	require.NoError(t, err)
+	assert.Equal(t, 0.95, gauge)
+
+	// writes are accepted again once space is freed
+	utilization = 0.8
+	assert.NoError(t, b.Write(ctx, objectName, keypath, bytes.NewReader([]byte{0x01}), 1, false))
+}
@@ -0,0 +1,158 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"io"
+	"math"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"golang.org/x/time/rate"
+
+	"github.com/grafana/tempo/tempodb/backend"
+)
+
+const (
+	opRead  = "read"
+	opWrite = "write"
+)
+
+var metricWaitDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: "tempodb",
+	Name:      "backend_rate_limit_wait_duration_seconds",
+	Help:      "Time backend requests were blocked by the rate limit of the process.",
+	Buckets:   prometheus.ExponentialBuckets(0.001, 4, 8),
+}, []string{"operation"})
+
+// Config limits the rate of the requests to the backend of the process, shared by all of its callers, e.g. to stay
+// below the request quota of a bucket when compactions and queries run at the same time. A limit of 0 disables it.
+type Config struct {
+	ReadQPSLimit  float64 `yaml:"read_qps_limit"`
+	ReadBurst     int     `yaml:"read_burst"`
+	WriteQPSLimit float64 `yaml:"write_qps_limit"`
+	WriteBurst    int     `yaml:"write_burst"`
+}
+
+// RegisterFlagsAndApplyDefaults registers the flags.
+func (cfg *Config) RegisterFlagsAndApplyDefaults(prefix string, f *flag.FlagSet) {
+	f.Float64Var(&cfg.ReadQPSLimit, prefix+".read-qps-limit", 0, "Maximum rate of the list and read requests to the backend per second. 0 disables the limit.")
+	f.IntVar(&cfg.ReadBurst, prefix+".read-burst", 0, "Number of read requests that can be sent at once above the read rate. 0 defaults to the read rate.")
+	f.Float64Var(&cfg.WriteQPSLimit, prefix+".write-qps-limit", 0, "Maximum rate of the write requests to the backend per second. 0 disables the limit.")
+	f.IntVar(&cfg.WriteBurst, prefix+".write-burst", 0, "Number of write requests that can be sent at once above the write rate. 0 defaults to the write rate.")
+}
+
+// Validate returns an error if a limit or burst is negative.
+func (cfg *Config) Validate() error {
+	if cfg.ReadQPSLimit < 0 || cfg.WriteQPSLimit < 0 {
+		return errors.New("backend qps limits can't be negative")
+	}
+	if cfg.ReadBurst < 0 || cfg.WriteBurst < 0 {
+		return errors.New("backend bursts can't be negative")
+	}
+	return nil
+}
+
+type reader struct {
+	next    backend.RawReader
+	limiter *rate.Limiter
+}
+
+// NewReader returns a RawReader that waits for the read limit of cfg before every List, Read and ReadRange. next is
+// returned as is if the limit is disabled.
+func NewReader(next backend.RawReader, cfg Config) backend.RawReader {
+	if cfg.ReadQPSLimit <= 0 {
+		return next
+	}
+	return &reader{
+		next:    next,
+		limiter: newLimiter(cfg.ReadQPSLimit, cfg.ReadBurst),
+	}
+}
+
+// List implements backend.RawReader
+func (r *reader) List(ctx context.Context, keypath backend.KeyPath) ([]string, error) {
+	if err := wait(ctx, r.limiter, opRead); err != nil {
+		return nil, err
+	}
+	return r.next.List(ctx, keypath)
+}
+
+// Read implements backend.RawReader
+func (r *reader) Read(ctx context.Context, name string, keypath backend.KeyPath, shouldCache bool) (io.ReadCloser, int64, error) {
+	if err := wait(ctx, r.limiter, opRead); err != nil {
+		return nil, 0, err
+	}
+	return r.next.Read(ctx, name, keypath, shouldCache)
+}
+
+// ReadRange implements backend.RawReader
+func (r *reader) ReadRange(ctx context.Context, name string, keypath backend.KeyPath, offset uint64, buffer []byte) error {
+	if err := wait(ctx, r.limiter, opRead); err != nil {
+		return err
+	}
+	return r.next.ReadRange(ctx, name, keypath, offset, buffer)
+}
+
+// Shutdown implements backend.RawReader
+func (r *reader) Shutdown() {
+	r.next.Shutdown()
+}
+
+type writer struct {
+	next    backend.RawWriter
+	limiter *rate.Limiter
+}
+
+// NewWriter returns a RawWriter that waits for the write limit of cfg before every Write and Append. CloseAppend isn't
+// limited so an append is always closed. next is returned as is if the limit is disabled.
+func NewWriter(next backend.RawWriter, cfg Config) backend.RawWriter {
+	if cfg.WriteQPSLimit <= 0 {
+		return next
+	}
+	return &writer{
+		next:    next,
+		limiter: newLimiter(cfg.WriteQPSLimit, cfg.WriteBurst),
+	}
+}
+
+// Write implements backend.RawWriter
+func (w *writer) Write(ctx context.Context, name string, keypath backend.KeyPath, data io.Reader, size int64, shouldCache bool) error {
+	if err := wait(ctx, w.limiter, opWrite); err != nil {
+		return err
+	}
+	return w.next.Write(ctx, name, keypath, data, size, shouldCache)
+}
+
+// Append implements backend.RawWriter
+func (w *writer) Append(ctx context.Context, name string, keypath backend.KeyPath, tracker backend.AppendTracker, buffer []byte) (backend.AppendTracker, error) {
+	if err := wait(ctx, w.limiter, opWrite); err != nil {
+		return nil, err
+	}
+	return w.next.Append(ctx, name, keypath, tracker, buffer)
+}
+
+// CloseAppend implements backend.RawWriter. It's not limited, failing it would leak the multipart upload or file of the
+// append.
+func (w *writer) CloseAppend(ctx context.Context, tracker backend.AppendTracker) error {
+	return w.next.CloseAppend(ctx, tracker)
+}
+
+// newLimiter returns a limiter of qps with the burst, which defaults to qps rounded up.
+func newLimiter(qps float64, burst int) *rate.Limiter {
+	if burst <= 0 {
+		burst = int(math.Ceil(qps))
+	}
+	return rate.NewLimiter(rate.Limit(qps), burst)
+}
+
+// wait blocks until the limiter allows a request and observes the blocked time. It fails without waiting if ctx is
+// done before then.
+func wait(ctx context.Context, limiter *rate.Limiter, op string) error {
+	start := time.Now()
+	err := limiter.Wait(ctx)
+	metricWaitDuration.WithLabelValues(op).Observe(time.Since(start).Seconds())
+	return err
+}
@@ -0,0 +1,93 @@
+package ratelimit
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+
+	"github.com/grafana/tempo/tempodb/backend"
+)
+
+func TestDisabled(t *testing.T) {
+	r := &backend.MockRawReader{}
+	w := &backend.MockRawWriter{}
+
+	// no limit doesn't wrap the reader and writer
+	assert.Same(t, r, NewReader(r, Config{}))
+	assert.Same(t, w, NewWriter(w, Config{ReadQPSLimit: 10}))
+	assert.NotSame(t, r, NewReader(r, Config{ReadQPSLimit: 10}))
+}
+
+func TestReaderLimit(t *testing.T) {
+	lists := atomic.NewInt32(0)
+	r := NewReader(&backend.MockRawReader{
+		ListFn: func(context.Context, backend.KeyPath) ([]string, error) {
+			lists.Inc()
+			return nil, nil
+		},
+	}, Config{ReadQPSLimit: 20, ReadBurst: 2})
+
+	ctx := context.Background()
+	start := time.Now()
+	// the burst is sent at once, the rest at the limit
+	for i := 0; i < 4; i++ {
+		_, err := r.List(ctx, backend.KeyPath{"tenant"})
+		require.NoError(t, err)
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond)
+	assert.Equal(t, int32(4), lists.Load())
+
+	// a read that would wait past the deadline of its context fails without reaching the backend
+	ctx, cancel := context.WithTimeout(ctx, time.Millisecond)
+	defer cancel()
+	_, _, err := r.Read(ctx, "object", backend.KeyPath{"tenant"}, false)
+	require.Error(t, err)
+	err = r.ReadRange(ctx, "object", backend.KeyPath{"tenant"}, 0, make([]byte, 1))
+	require.Error(t, err)
+}
+
+func TestWriterLimit(t *testing.T) {
+	w := NewWriter(&backend.MockRawWriter{}, Config{WriteQPSLimit: 20})
+
+	ctx := context.Background()
+	start := time.Now()
+	// the burst defaults to the limit
+	for i := 0; i < 22; i++ {
+		require.NoError(t, w.Write(ctx, "object", backend.KeyPath{"tenant"}, bytes.NewReader([]byte{1}), 1, false))
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond)
+	assert.Less(t, time.Since(start), time.Second)
+
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err := w.Append(ctx, "object", backend.KeyPath{"tenant"}, nil, []byte{1})
+	require.Error(t, err)
+
+	// an append is closed even if the context is done
+	closer := &closeAppendWriter{}
+	w = NewWriter(closer, Config{WriteQPSLimit: 20})
+	require.NoError(t, w.CloseAppend(ctx, nil))
+	assert.True(t, closer.closed)
+}
+
+type closeAppendWriter struct {
+	backend.MockRawWriter
+	closed bool
+}
+
+func (w *closeAppendWriter) CloseAppend(context.Context, backend.AppendTracker) error {
+	w.closed = true
+	return nil
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, (&Config{}).Validate())
+	assert.NoError(t, (&Config{ReadQPSLimit: 1.5, WriteQPSLimit: 10, WriteBurst: 20}).Validate())
+	assert.Error(t, (&Config{ReadQPSLimit: -1}).Validate())
+	assert.Error(t, (&Config{WriteBurst: -1}).Validate())
+}
@@ -11,12 +11,18 @@ import (
 	"github.com/google/uuid"
 
 	tempo_io "github.com/grafana/tempo/pkg/io"
+	"github.com/grafana/tempo/pkg/querystats"
 )
 
 const (
 	MetaName          = "meta.json"
 	CompactedMetaName = "meta.compacted.json"
 	TenantIndexName   = "index.json.gz"
+
+	// AuditKeyPath is reserved for the audit log and never returned as a tenant.
+	AuditKeyPath = "audit"
+	// SkeletonKeyPath holds the trace skeletons of the tenants, see SkeletonTenantID. It's never returned as a tenant.
+	SkeletonKeyPath = "skeleton"
 )
 
 // KeyPath is an ordered set of strings that govern where data is read/written from the backend
@@ -116,7 +122,9 @@ func (r *reader) Read(ctx context.Context, name string, blockID uuid.UUID, tenan
 		return nil, err
 	}
 	defer objReader.Close()
-	return tempo_io.ReadAllWithEstimate(objReader, size)
+	b, err := tempo_io.ReadAllWithEstimate(objReader, size)
+	querystats.FromContext(ctx).AddBytesRead(len(b))
+	return b, err
 }
 
 func (r *reader) StreamReader(ctx context.Context, name string, blockID uuid.UUID, tenantID string) (io.ReadCloser, int64, error) {
@@ -124,11 +132,25 @@ func (r *reader) StreamReader(ctx context.Context, name string, blockID uuid.UUI
 }
 
 func (r *reader) ReadRange(ctx context.Context, name string, blockID uuid.UUID, tenantID string, offset uint64, buffer []byte) error {
+	querystats.FromContext(ctx).AddBytesRead(len(buffer))
 	return r.r.ReadRange(ctx, name, KeyPathForBlock(blockID, tenantID), offset, buffer)
 }
 
 func (r *reader) Tenants(ctx context.Context) ([]string, error) {
-	return r.r.List(ctx, nil)
+	objects, err := r.r.List(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	tenants := make([]string, 0, len(objects))
+	for _, t := range objects {
+		if t == AuditKeyPath || t == SkeletonKeyPath {
+			continue
+		}
+		tenants = append(tenants, t)
+	}
+
+	return tenants, nil
 }
 
 func (r *reader) Blocks(ctx context.Context, tenantID string) ([]uuid.UUID, error) {
@@ -170,7 +192,7 @@ func (r *reader) BlockMeta(ctx context.Context, blockID uuid.UUID, tenantID stri
 	out := &BlockMeta{}
 	err = json.Unmarshal(bytes, out)
 	if err != nil {
-		return nil, err
+		return nil, WrapError(ErrCorrupt, err)
 	}
 
 	return out, nil
@@ -190,7 +212,7 @@ func (r *reader) TenantIndex(ctx context.Context, tenantID string) (*TenantIndex
 	i := &TenantIndex{}
 	err = i.unmarshal(bytes)
 	if err != nil {
-		return nil, err
+		return nil, WrapError(ErrCorrupt, err)
 	}
 
 	return i, nil
@@ -200,6 +222,12 @@ func (r *reader) Shutdown() {
 	r.r.Shutdown()
 }
 
+// SkeletonTenantID returns the tenant id the skeleton blocks of tenantID are stored under. Skeleton blocks have the
+// same layout as the blocks of a tenant and can be read and written like them.
+func SkeletonTenantID(tenantID string) string {
+	return path.Join(SkeletonKeyPath, tenantID)
+}
+
 // KeyPathForBlock returns a correctly ordered keypath given a block id and tenantid
 // nolint:interfacer
 func KeyPathForBlock(blockID uuid.UUID, tenantID string) KeyPath {
@@ -69,6 +69,11 @@ func TestReader(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, expectedTenants, actualTenants)
 
+	m.L = []string{"a", AuditKeyPath, "b", SkeletonKeyPath}
+	actualTenants, err = r.Tenants(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, actualTenants)
+
 	uuid1 := uuid.New()
 	uuid2 := uuid.New()
 	expectedBlocks := []uuid.UUID{uuid1, uuid2}
@@ -79,7 +84,7 @@ func TestReader(t *testing.T) {
 
 	// should fail b/c meta is not valid
 	meta, err := r.BlockMeta(ctx, uuid.New(), "test")
-	assert.Error(t, err)
+	assert.ErrorIs(t, err, ErrCorrupt)
 	assert.Nil(t, meta)
 
 	expectedMeta := NewBlockMeta("test", uuid.New(), "blerg", EncGZIP, "glarg")
@@ -90,7 +95,7 @@ func TestReader(t *testing.T) {
 
 	// should fail b/c tenant index is not valid
 	idx, err := r.TenantIndex(ctx, "test")
-	assert.Error(t, err)
+	assert.ErrorIs(t, err, ErrCorrupt)
 	assert.Nil(t, idx)
 
 	expectedIdx := newTenantIndex([]*BlockMeta{expectedMeta}, nil)
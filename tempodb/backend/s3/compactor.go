@@ -33,11 +33,11 @@ func (rw *readerWriter) MarkBlockCompacted(blockID uuid.UUID, tenantID string) e
 		minio.PutObjectOptions{},
 	)
 	if err != nil {
-		return errors.Wrap(err, "error copying obj meta to compacted obj meta")
+		return writeError(errors.Wrap(err, "error copying obj meta to compacted obj meta"))
 	}
 
 	// delete meta.json
-	return rw.core.RemoveObject(context.TODO(), rw.cfg.Bucket, metaFileName, minio.RemoveObjectOptions{})
+	return writeError(rw.core.RemoveObject(context.TODO(), rw.cfg.Bucket, metaFileName, minio.RemoveObjectOptions{}))
 }
 
 func (rw *readerWriter) ClearBlock(blockID uuid.UUID, tenantID string) error {
@@ -54,14 +54,14 @@ func (rw *readerWriter) ClearBlock(blockID uuid.UUID, tenantID string) error {
 	// ListObjects(bucket, prefix, marker, delimiter string, maxKeys int)
 	res, err := rw.core.ListObjects(rw.cfg.Bucket, path, "", "/", 0)
 	if err != nil {
-		return errors.Wrapf(err, "error listing objects in bucket %s", rw.cfg.Bucket)
+		return readError(errors.Wrapf(err, "error listing objects in bucket %s", rw.cfg.Bucket))
 	}
 
 	level.Debug(rw.logger).Log("msg", "listing objects", "found", len(res.Contents))
 	for _, obj := range res.Contents {
 		err = rw.core.RemoveObject(context.TODO(), rw.cfg.Bucket, obj.Key, minio.RemoveObjectOptions{})
 		if err != nil {
-			return errors.Wrapf(err, "error deleting obj from s3: %s", obj.Key)
+			return writeError(errors.Wrapf(err, "error deleting obj from s3: %s", obj.Key))
 		}
 	}
 
@@ -85,7 +85,7 @@ func (rw *readerWriter) CompactedBlockMeta(blockID uuid.UUID, tenantID string) (
 	out := &backend.CompactedBlockMeta{}
 	err = json.Unmarshal(bytes, out)
 	if err != nil {
-		return nil, err
+		return nil, backend.WrapError(backend.ErrCorrupt, err)
 	}
 	out.CompactedTime = info.LastModified
 
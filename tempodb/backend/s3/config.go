@@ -4,17 +4,30 @@ import (
 	"time"
 
 	"github.com/grafana/dskit/flagext"
+
+	"github.com/grafana/tempo/tempodb/backend/instrumentation"
 )
 
 type Config struct {
-	Bucket          string         `yaml:"bucket"`
-	Endpoint        string         `yaml:"endpoint"`
-	Region          string         `yaml:"region"`
-	AccessKey       flagext.Secret `yaml:"access_key"`
-	SecretKey       flagext.Secret `yaml:"secret_key"`
-	Insecure        bool           `yaml:"insecure"`
-	PartSize        uint64         `yaml:"part_size"`
-	HedgeRequestsAt time.Duration  `yaml:"hedge_requests_at"`
+	Bucket    string         `yaml:"bucket"`
+	Endpoint  string         `yaml:"endpoint"`
+	Region    string         `yaml:"region"`
+	AccessKey flagext.Secret `yaml:"access_key"`
+	SecretKey flagext.Secret `yaml:"secret_key"`
+	// RoleARN is the IAM role assumed through STS, with the token of WebIdentityTokenFile if set or the credentials of
+	// the environment or the instance otherwise. The credentials of the role are refreshed before they expire.
+	RoleARN              string `yaml:"role_arn"`
+	WebIdentityTokenFile string `yaml:"web_identity_token_file"`
+	RoleSessionName      string `yaml:"role_session_name"`
+	ExternalID           string `yaml:"external_id"`
+	// STSEndpoint overrides the endpoint of STS of the region
+	STSEndpoint     string        `yaml:"sts_endpoint"`
+	Insecure        bool          `yaml:"insecure"`
+	PartSize        uint64        `yaml:"part_size"`
+	HedgeRequestsAt time.Duration `yaml:"hedge_requests_at"`
+	// HedgeRequestsAdaptive hedges at a multiple of the recent p99 latency if HedgeRequestsAt is not set
+	HedgeRequestsAdaptive instrumentation.AdaptiveHedgeConfig `yaml:"hedge_requests_adaptive"`
+	Transport             instrumentation.TransportConfig     `yaml:"transport"`
 	// SignatureV2 configures the object storage to use V2 signing instead of V4
 	SignatureV2    bool `yaml:"signature_v2"`
 	ForcePathStyle bool `yaml:"forcepathstyle"`
@@ -0,0 +1,132 @@
+package s3
+
+import (
+	"errors"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	aws_credentials "github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	// credentialsExpiryWindow refreshes the credentials of an assumed role this long before they expire
+	credentialsExpiryWindow = 5 * time.Minute
+	defaultRoleSessionName  = "tempo"
+)
+
+var metricCredentialsRefreshes = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "tempodb",
+	Name:      "s3_credentials_refreshes_total",
+	Help:      "Total number of refreshes of the credentials of the assumed role of the s3 backend by result.",
+}, []string{"result"})
+
+// Validate returns an error if the credentials of the config are ambiguous. Static keys and a role to assume are
+// mutually exclusive, the source credentials of the role come from the environment or the instance metadata.
+func (cfg *Config) Validate() error {
+	if cfg.RoleARN == "" {
+		if cfg.WebIdentityTokenFile != "" || cfg.ExternalID != "" {
+			return errors.New("s3 web_identity_token_file and external_id require a role_arn")
+		}
+		return nil
+	}
+	if cfg.AccessKey.String() != "" || cfg.SecretKey.String() != "" {
+		return errors.New("s3 access_key and secret_key can't be combined with a role_arn, the role is assumed with the credentials of the environment or the instance")
+	}
+	if cfg.SignatureV2 {
+		return errors.New("s3 signature_v2 can't be combined with a role_arn, the credentials of a role need v4 signing")
+	}
+	if cfg.WebIdentityTokenFile != "" && cfg.ExternalID != "" {
+		return errors.New("s3 external_id can't be combined with a web_identity_token_file")
+	}
+	return nil
+}
+
+// newRoleCredentials returns the credentials of the role of the config, assumed with the token of the web identity
+// token file if set or with the credentials of the environment or the instance otherwise. The credentials are
+// refreshed before they expire.
+func newRoleCredentials(cfg *Config, logger log.Logger) (*credentials.Credentials, error) {
+	awsCfg := aws.NewConfig().WithRegion(cfg.Region)
+	if cfg.STSEndpoint != "" {
+		awsCfg = awsCfg.WithEndpoint(cfg.STSEndpoint)
+	}
+	sess, err := session.NewSession(awsCfg)
+	if err != nil {
+		return nil, err
+	}
+	client := sts.New(sess)
+
+	sessionName := cfg.RoleSessionName
+	if sessionName == "" {
+		sessionName = defaultRoleSessionName
+	}
+
+	var provider aws_credentials.Provider
+	if cfg.WebIdentityTokenFile != "" {
+		p := stscreds.NewWebIdentityRoleProvider(client, cfg.RoleARN, sessionName, cfg.WebIdentityTokenFile)
+		p.ExpiryWindow = credentialsExpiryWindow
+		provider = p
+	} else {
+		p := &stscreds.AssumeRoleProvider{
+			Client:          client,
+			RoleARN:         cfg.RoleARN,
+			RoleSessionName: sessionName,
+			Duration:        stscreds.DefaultDuration,
+			ExpiryWindow:    credentialsExpiryWindow,
+		}
+		if cfg.ExternalID != "" {
+			p.ExternalID = aws.String(cfg.ExternalID)
+		}
+		provider = p
+	}
+
+	return credentials.New(&roleProvider{
+		upstream: provider,
+		roleARN:  cfg.RoleARN,
+		logger:   logger,
+	}), nil
+}
+
+// roleProvider adapts the providers of assumed roles of the aws sdk to minio, counting and logging every refresh.
+type roleProvider struct {
+	upstream aws_credentials.Provider
+	roleARN  string
+	logger   log.Logger
+}
+
+// Retrieve implements credentials.Provider
+func (p *roleProvider) Retrieve() (credentials.Value, error) {
+	v, err := p.upstream.Retrieve()
+	if err != nil {
+		metricCredentialsRefreshes.WithLabelValues("failure").Inc()
+		level.Error(p.logger).Log("msg", "failed to refresh s3 credentials", "role_arn", p.roleARN, "err", err)
+		return credentials.Value{}, err
+	}
+
+	metricCredentialsRefreshes.WithLabelValues("success").Inc()
+	keyvals := []interface{}{"msg", "refreshed s3 credentials", "role_arn", p.roleARN}
+	if e, ok := p.upstream.(interface{ ExpiresAt() time.Time }); ok {
+		// the expiry of the provider is the expiration of the credentials minus the expiry window
+		keyvals = append(keyvals, "next_refresh", e.ExpiresAt())
+	}
+	level.Info(p.logger).Log(keyvals...)
+
+	return credentials.Value{
+		AccessKeyID:     v.AccessKeyID,
+		SecretAccessKey: v.SecretAccessKey,
+		SessionToken:    v.SessionToken,
+		SignerType:      credentials.SignatureV4,
+	}, nil
+}
+
+// IsExpired implements credentials.Provider
+func (p *roleProvider) IsExpired() bool {
+	return p.upstream.IsExpired()
+}
@@ -0,0 +1,117 @@
+package s3
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/grafana/dskit/flagext"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tempo/pkg/util/test"
+)
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name        string
+		cfg         Config
+		expectedErr bool
+	}{
+		{name: "static keys", cfg: Config{AccessKey: flagext.Secret{Value: "a"}, SecretKey: flagext.Secret{Value: "s"}}},
+		{name: "role", cfg: Config{RoleARN: "arn:aws:iam::123:role/tempo", ExternalID: "id"}},
+		{name: "web identity", cfg: Config{RoleARN: "arn:aws:iam::123:role/tempo", WebIdentityTokenFile: "/token"}},
+		{name: "role and static keys", cfg: Config{RoleARN: "arn:aws:iam::123:role/tempo", AccessKey: flagext.Secret{Value: "a"}}, expectedErr: true},
+		{name: "web identity without role", cfg: Config{WebIdentityTokenFile: "/token"}, expectedErr: true},
+		{name: "web identity and external id", cfg: Config{RoleARN: "arn:aws:iam::123:role/tempo", WebIdentityTokenFile: "/token", ExternalID: "id"}, expectedErr: true},
+		{name: "role and signature v2", cfg: Config{RoleARN: "arn:aws:iam::123:role/tempo", SignatureV2: true}, expectedErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.cfg.Validate()
+			if tc.expectedErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestRoleCredentials(t *testing.T) {
+	var params map[string]string
+	status := http.StatusOK
+	sts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		params = map[string]string{}
+		for k := range r.PostForm {
+			params[k] = r.PostForm.Get(k)
+		}
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			return
+		}
+
+		action := params["Action"]
+		expiration := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+		_, _ = fmt.Fprintf(w, `<%[1]sResponse><%[1]sResult><Credentials><AccessKeyId>key</AccessKeyId><SecretAccessKey>secret</SecretAccessKey><SessionToken>token</SessionToken><Expiration>%[2]s</Expiration></Credentials></%[1]sResult></%[1]sResponse>`, action, expiration)
+	}))
+	defer sts.Close()
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("web-identity-token"), 0o600))
+
+	// the role is assumed with the token of the web identity token file
+	creds, err := newRoleCredentials(&Config{
+		Region:               "us-east-1",
+		RoleARN:              "arn:aws:iam::123:role/tempo",
+		WebIdentityTokenFile: tokenFile,
+		STSEndpoint:          sts.URL,
+	}, log.NewNopLogger())
+	require.NoError(t, err)
+	v, err := creds.Get()
+	require.NoError(t, err)
+	assert.Equal(t, "key", v.AccessKeyID)
+	assert.Equal(t, "token", v.SessionToken)
+	assert.Equal(t, "AssumeRoleWithWebIdentity", params["Action"])
+	assert.Equal(t, "web-identity-token", params["WebIdentityToken"])
+	assert.Equal(t, defaultRoleSessionName, params["RoleSessionName"])
+	// the credentials are only refreshed once they're about to expire
+	assert.False(t, creds.IsExpired())
+
+	// the role is assumed with the credentials of the environment
+	t.Setenv("AWS_ACCESS_KEY_ID", "env-key")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "env-secret")
+	creds, err = newRoleCredentials(&Config{
+		Region:          "us-east-1",
+		RoleARN:         "arn:aws:iam::123:role/tempo",
+		RoleSessionName: "session",
+		ExternalID:      "external",
+		STSEndpoint:     sts.URL,
+	}, log.NewNopLogger())
+	require.NoError(t, err)
+	_, err = creds.Get()
+	require.NoError(t, err)
+	assert.Equal(t, "AssumeRole", params["Action"])
+	assert.Equal(t, "external", params["ExternalId"])
+	assert.Equal(t, "session", params["RoleSessionName"])
+
+	success, err := test.GetCounterValue(metricCredentialsRefreshes.WithLabelValues("success"))
+	require.NoError(t, err)
+	assert.Equal(t, float64(2), success)
+
+	// failed refreshes are counted
+	status = http.StatusForbidden
+	creds.Expire()
+	_, err = creds.Get()
+	assert.Error(t, err)
+	failure, err := test.GetCounterValue(metricCredentialsRefreshes.WithLabelValues("failure"))
+	require.NoError(t, err)
+	assert.Equal(t, float64(1), failure)
+}
@@ -14,7 +14,6 @@ import (
 
 	"github.com/aws/aws-sdk-go/service/s3"
 	log_util "github.com/cortexproject/cortex/pkg/util/log"
-	"github.com/cristalhq/hedgedhttp"
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
 	"github.com/grafana/tempo/tempodb/backend"
@@ -71,12 +70,26 @@ func (s *overrideSignatureVersion) IsExpired() bool {
 func New(cfg *Config) (backend.RawReader, backend.RawWriter, backend.Compactor, error) {
 	l := log_util.Logger
 
-	core, err := createCore(cfg, false)
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, nil, err
+	}
+
+	// the core and the hedged core share one connection pool and one set of credentials
+	transport, err := newTransport(cfg)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	creds, err := newCredentials(cfg)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("unexpected error creating credentials: %w", err)
+	}
+
+	core, err := createCore(cfg, creds, transport, false)
 	if err != nil {
 		return nil, nil, nil, fmt.Errorf("unexpected error creating core: %w", err)
 	}
 
-	hedgedCore, err := createCore(cfg, true)
+	hedgedCore, err := createCore(cfg, creds, transport, true)
 	if err != nil {
 		return nil, nil, nil, fmt.Errorf("unexpected error creating hedgedCore: %w", err)
 	}
@@ -98,6 +111,7 @@ func New(cfg *Config) (backend.RawReader, backend.RawWriter, backend.Compactor,
 
 // Write implements backend.Writer
 func (rw *readerWriter) Write(ctx context.Context, name string, keypath backend.KeyPath, data io.Reader, size int64, _ bool) error {
+	ctx = instrumentation.WithOperation(ctx, instrumentation.OperationWrite)
 	objName := backend.ObjectFileName(keypath, name)
 
 	info, err := rw.core.Client.PutObject(
@@ -109,7 +123,7 @@ func (rw *readerWriter) Write(ctx context.Context, name string, keypath backend.
 		minio.PutObjectOptions{PartSize: rw.cfg.PartSize},
 	)
 	if err != nil {
-		return errors.Wrapf(err, "error writing object to s3 backend, object %s", objName)
+		return writeError(errors.Wrapf(err, "error writing object to s3 backend, object %s", objName))
 	}
 	level.Debug(rw.logger).Log("msg", "object uploaded to s3", "objectName", objName, "size", info.Size)
 
@@ -118,6 +132,7 @@ func (rw *readerWriter) Write(ctx context.Context, name string, keypath backend.
 
 // AppendObject implements backend.Writer
 func (rw *readerWriter) Append(ctx context.Context, name string, keypath backend.KeyPath, tracker backend.AppendTracker, buffer []byte) (backend.AppendTracker, error) {
+	ctx = instrumentation.WithOperation(ctx, instrumentation.OperationWrite)
 	var a appendTracker
 	objectName := backend.ObjectFileName(keypath, name)
 
@@ -134,7 +149,7 @@ func (rw *readerWriter) Append(ctx context.Context, name string, keypath backend
 			options,
 		)
 		if err != nil {
-			return nil, err
+			return nil, writeError(err)
 		}
 		a.uploadID = id
 		a.objectName = objectName
@@ -156,7 +171,7 @@ func (rw *readerWriter) Append(ctx context.Context, name string, keypath backend
 		nil,
 	)
 	if err != nil {
-		return a, errors.Wrap(err, "error in multipart upload")
+		return a, writeError(errors.Wrap(err, "error in multipart upload"))
 	}
 	a.parts = append(a.parts, objPart)
 
@@ -165,6 +180,7 @@ func (rw *readerWriter) Append(ctx context.Context, name string, keypath backend
 
 // CloseAppend implements backend.Writer
 func (rw *readerWriter) CloseAppend(ctx context.Context, tracker backend.AppendTracker) error {
+	ctx = instrumentation.WithOperation(ctx, instrumentation.OperationWrite)
 	if tracker == nil {
 		return nil
 	}
@@ -186,7 +202,7 @@ func (rw *readerWriter) CloseAppend(ctx context.Context, tracker backend.AppendT
 		completeParts,
 	)
 	if err != nil {
-		return errors.Wrapf(err, "error completing multipart upload, object: %s, obj etag: %s", a.objectName, etag)
+		return writeError(errors.Wrapf(err, "error completing multipart upload, object: %s, obj etag: %s", a.objectName, etag))
 	}
 
 	return nil
@@ -194,6 +210,7 @@ func (rw *readerWriter) CloseAppend(ctx context.Context, tracker backend.AppendT
 
 // List implements backend.Reader
 func (rw *readerWriter) List(ctx context.Context, keypath backend.KeyPath) ([]string, error) {
+	ctx = instrumentation.WithOperation(ctx, instrumentation.OperationList)
 	prefix := path.Join(keypath...)
 	var objects []string
 
@@ -207,7 +224,7 @@ func (rw *readerWriter) List(ctx context.Context, keypath backend.KeyPath) ([]st
 		// ListObjects(bucket, prefix, nextMarker, delimiter string, maxKeys int)
 		res, err := rw.core.ListObjects(rw.cfg.Bucket, prefix, nextMarker, "/", 0)
 		if err != nil {
-			return nil, errors.Wrapf(err, "error listing blocks in s3 bucket, bucket: %s", rw.cfg.Bucket)
+			return nil, readError(errors.Wrapf(err, "error listing blocks in s3 bucket, bucket: %s", rw.cfg.Bucket))
 		}
 		isTruncated = res.IsTruncated
 		nextMarker = res.NextMarker
@@ -225,6 +242,7 @@ func (rw *readerWriter) List(ctx context.Context, keypath backend.KeyPath) ([]st
 
 // Read implements backend.Reader
 func (rw *readerWriter) Read(ctx context.Context, name string, keypath backend.KeyPath, _ bool) (io.ReadCloser, int64, error) {
+	ctx = instrumentation.WithOperation(ctx, instrumentation.OperationRead)
 	span, derivedCtx := opentracing.StartSpanFromContext(ctx, "Read")
 	defer span.Finish()
 
@@ -238,6 +256,7 @@ func (rw *readerWriter) Read(ctx context.Context, name string, keypath backend.K
 
 // ReadRange implements backend.Reader
 func (rw *readerWriter) ReadRange(ctx context.Context, name string, keypath backend.KeyPath, offset uint64, buffer []byte) error {
+	ctx = instrumentation.WithOperation(ctx, instrumentation.OperationReadRange)
 	span, derivedCtx := opentracing.StartSpanFromContext(ctx, "ReadRange")
 	defer span.Finish()
 
@@ -304,44 +323,23 @@ func (rw *readerWriter) readRange(ctx context.Context, objName string, offset in
 	}
 }
 
-func createCore(cfg *Config, hedge bool) (*minio.Core, error) {
-	wrapCredentialsProvider := func(p credentials.Provider) credentials.Provider {
-		if cfg.SignatureV2 {
-			return &overrideSignatureVersion{useV2: cfg.SignatureV2, upstream: p}
-		}
-		return p
-	}
-
-	creds := credentials.NewChainCredentials([]credentials.Provider{
-		wrapCredentialsProvider(&credentials.EnvAWS{}),
-		wrapCredentialsProvider(&credentials.Static{
-			Value: credentials.Value{
-				AccessKeyID:     cfg.AccessKey.String(),
-				SecretAccessKey: cfg.SecretKey.String(),
-			},
-		}),
-		wrapCredentialsProvider(&credentials.EnvMinio{}),
-		wrapCredentialsProvider(&credentials.FileAWSCredentials{}),
-		wrapCredentialsProvider(&credentials.FileMinioClient{}),
-		wrapCredentialsProvider(&credentials.IAM{
-			Client: &http.Client{
-				Transport: http.DefaultTransport,
-			},
-		}),
-	})
-
+// newTransport returns the default minio transport tuned by the transport config.
+func newTransport(cfg *Config) (*http.Transport, error) {
 	customTransport, err := minio.DefaultTransport(!cfg.Insecure)
 	if err != nil {
 		return nil, errors.Wrap(err, "create minio.DefaultTransport")
 	}
+	cfg.Transport.Apply(customTransport)
+
+	return customTransport, nil
+}
 
+func createCore(cfg *Config, creds *credentials.Credentials, customTransport *http.Transport, hedge bool) (*minio.Core, error) {
 	// add instrumentation
 	transport := instrumentation.NewS3Transport(customTransport)
-	var stats *hedgedhttp.Stats
 
-	if hedge && cfg.HedgeRequestsAt != 0 {
-		transport, stats = hedgedhttp.NewRoundTripperAndStats(cfg.HedgeRequestsAt, uptoHedgedRequests, transport)
-		instrumentation.PublishHedgedMetrics(stats)
+	if hedge {
+		transport = instrumentation.NewHedgedTransport(transport, uptoHedgedRequests, cfg.HedgeRequestsAt, cfg.HedgeRequestsAdaptive)
 	}
 
 	opts := &minio.Options{
@@ -358,9 +356,65 @@ func createCore(cfg *Config, hedge bool) (*minio.Core, error) {
 	return minio.NewCore(cfg.Endpoint, opts)
 }
 
+// newCredentials returns the credentials of the role of the config if set. Otherwise the first credentials found are
+// used, the static keys of the config before the ones of the environment, the credential files and the instance.
+func newCredentials(cfg *Config) (*credentials.Credentials, error) {
+	if cfg.RoleARN != "" {
+		return newRoleCredentials(cfg, log_util.Logger)
+	}
+
+	wrapCredentialsProvider := func(p credentials.Provider) credentials.Provider {
+		if cfg.SignatureV2 {
+			return &overrideSignatureVersion{useV2: cfg.SignatureV2, upstream: p}
+		}
+		return p
+	}
+
+	return credentials.NewChainCredentials([]credentials.Provider{
+		wrapCredentialsProvider(&credentials.Static{
+			Value: credentials.Value{
+				AccessKeyID:     cfg.AccessKey.String(),
+				SecretAccessKey: cfg.SecretKey.String(),
+			},
+		}),
+		wrapCredentialsProvider(&credentials.EnvAWS{}),
+		wrapCredentialsProvider(&credentials.EnvMinio{}),
+		wrapCredentialsProvider(&credentials.FileAWSCredentials{}),
+		wrapCredentialsProvider(&credentials.FileMinioClient{}),
+		wrapCredentialsProvider(&credentials.IAM{
+			Client: &http.Client{
+				Transport: http.DefaultTransport,
+			},
+		}),
+	}), nil
+}
+
+// readError maps the error responses of s3 onto the errors of the backend.
 func readError(err error) error {
-	if err != nil && minio.ToErrorResponse(err).Code == s3.ErrCodeNoSuchKey {
+	var resp minio.ErrorResponse
+	if !errors.As(err, &resp) {
+		return err
+	}
+
+	switch {
+	case resp.Code == s3.ErrCodeNoSuchKey:
 		return backend.ErrDoesNotExist
+	case resp.Code == "SlowDown" || resp.StatusCode == http.StatusTooManyRequests:
+		return backend.WrapError(backend.ErrThrottled, err)
+	case resp.Code == "BadDigest" || resp.Code == "InvalidDigest" || resp.Code == "XAmzContentSHA256Mismatch":
+		return backend.WrapError(backend.ErrCorrupt, err)
+	case resp.StatusCode == http.StatusBadRequest:
+		return backend.WrapError(backend.ErrBadRequest, err)
 	}
 	return err
 }
+
+// writeError maps the error responses of writes to s3 onto the errors of the backend. Writes denied by the policy of
+// the bucket or an object lock are read only.
+func writeError(err error) error {
+	var resp minio.ErrorResponse
+	if errors.As(err, &resp) && resp.Code == "AccessDenied" {
+		return backend.WrapError(backend.ErrReadOnly, err)
+	}
+	return readError(err)
+}
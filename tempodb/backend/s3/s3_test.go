@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"sync/atomic"
@@ -13,9 +14,12 @@ import (
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/grafana/dskit/flagext"
 	"github.com/grafana/tempo/tempodb/backend"
+	"github.com/grafana/tempo/tempodb/backend/instrumentation"
 	"github.com/minio/minio-go/v7"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
 )
 
 func TestHedge(t *testing.T) {
@@ -89,8 +93,67 @@ func TestHedge(t *testing.T) {
 	}
 }
 
+func TestTransportConfig(t *testing.T) {
+	cfg := &Config{
+		Insecure: true,
+		Transport: instrumentation.TransportConfig{
+			MaxIdleConns:        10,
+			MaxIdleConnsPerHost: 5,
+			IdleConnTimeout:     time.Second,
+			TLSHandshakeTimeout: 2 * time.Second,
+		},
+	}
+	transport, err := newTransport(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, 10, transport.MaxIdleConns)
+	assert.Equal(t, 5, transport.MaxIdleConnsPerHost)
+	assert.Equal(t, time.Second, transport.IdleConnTimeout)
+	assert.Equal(t, 2*time.Second, transport.TLSHandshakeTimeout)
+
+	// the defaults of minio are kept
+	transport, err = newTransport(&Config{Insecure: true})
+	require.NoError(t, err)
+	assert.Equal(t, 256, transport.MaxIdleConns)
+	assert.Equal(t, 16, transport.MaxIdleConnsPerHost)
+
+	// the core and the hedged core share their connections
+	count := int32(0)
+	server := httptest.NewUnstartedServer(fakeHandler(0, &count))
+	conns := int32(0)
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	server.Start()
+	t.Cleanup(server.Close)
+
+	r, _, _, err := New(&Config{
+		Region:          "blerg",
+		AccessKey:       flagext.Secret{Value: "test"},
+		SecretKey:       flagext.Secret{Value: "test"},
+		Bucket:          "blerg",
+		Insecure:        true,
+		Endpoint:        server.URL[7:], // [7:] -> strip http://
+		HedgeRequestsAt: time.Hour,
+	})
+	require.NoError(t, err)
+
+	// the fake response isn't a valid object, the request is all that matters
+	_, _, _ = r.Read(context.Background(), "object", []string{"test"}, false)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&count))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&conns))
+}
+
 func fakeServer(t *testing.T, returnIn time.Duration, counter *int32) *httptest.Server {
-	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	server := httptest.NewServer(fakeHandler(returnIn, counter))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func fakeHandler(returnIn time.Duration, counter *int32) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
 		time.Sleep(returnIn)
 
 		atomic.AddInt32(counter, 1)
@@ -98,10 +161,7 @@ func fakeServer(t *testing.T, returnIn time.Duration, counter *int32) *httptest.
 		_, _ = w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?>
 		<ListBucketResult>
 		</ListBucketResult>`))
-	}))
-	t.Cleanup(server.Close)
-
-	return server
+	}
 }
 
 func TestReadError(t *testing.T) {
@@ -114,4 +174,37 @@ func TestReadError(t *testing.T) {
 	wups := fmt.Errorf("wups")
 	errB = readError(wups)
 	assert.Equal(t, wups, errB)
+
+	tests := []struct {
+		err      minio.ErrorResponse
+		expected error
+	}{
+		{err: minio.ErrorResponse{Code: "SlowDown", StatusCode: http.StatusServiceUnavailable}, expected: backend.ErrThrottled},
+		{err: minio.ErrorResponse{StatusCode: http.StatusTooManyRequests}, expected: backend.ErrThrottled},
+		{err: minio.ErrorResponse{Code: "BadDigest", StatusCode: http.StatusBadRequest}, expected: backend.ErrCorrupt},
+		{err: minio.ErrorResponse{Code: "XAmzContentSHA256Mismatch", StatusCode: http.StatusBadRequest}, expected: backend.ErrCorrupt},
+		{err: minio.ErrorResponse{Code: "InvalidArgument", StatusCode: http.StatusBadRequest}, expected: backend.ErrBadRequest},
+		{err: minio.ErrorResponse{Code: "InternalError", StatusCode: http.StatusInternalServerError}},
+	}
+	for _, tc := range tests {
+		// the errors are mapped through the wrapping of the backend
+		err := readError(errors.Wrap(tc.err, "error in range read from s3 backend"))
+		if tc.expected == nil {
+			assert.Equal(t, codes.Internal, backend.GRPCCode(err), tc.err.Code)
+			continue
+		}
+		assert.ErrorIs(t, err, tc.expected, tc.err.Code)
+		assert.Contains(t, err.Error(), tc.err.Error())
+	}
+	assert.Equal(t, backend.ErrDoesNotExist, readError(errors.Wrap(errA, "error in range read from s3 backend")))
+}
+
+func TestWriteError(t *testing.T) {
+	err := writeError(errors.Wrap(minio.ErrorResponse{Code: "AccessDenied", StatusCode: http.StatusForbidden}, "error writing object to s3 backend"))
+	assert.ErrorIs(t, err, backend.ErrReadOnly)
+
+	err = writeError(minio.ErrorResponse{Code: "SlowDown", StatusCode: http.StatusServiceUnavailable})
+	assert.ErrorIs(t, err, backend.ErrThrottled)
+
+	assert.NoError(t, writeError(nil))
 }
@@ -13,6 +13,12 @@ type PerTenant map[string][]*backend.BlockMeta
 // PerTenantCompacted is a map of tenant ids to backend.CompactedBlockMetas
 type PerTenantCompacted map[string][]*backend.CompactedBlockMeta
 
+// Changes are the number of blocks a poll added to and removed from the blocklist.
+type Changes struct {
+	Added   int `json:"added"`
+	Removed int `json:"removed"`
+}
+
 // List controls access to a per tenant blocklist and compacted blocklist
 type List struct {
 	mtx            sync.Mutex
@@ -78,11 +84,12 @@ func (l *List) CompactedMetas(tenantID string) []*backend.CompactedBlockMeta {
 
 // ApplyPollResults applies the PerTenant and PerTenantCompacted maps to this blocklist
 // Note that it also applies any known local changes and then wipes them out to be restored
-// in the next polling cycle.
-func (l *List) ApplyPollResults(m PerTenant, c PerTenantCompacted) {
+// in the next polling cycle. It returns the blocks added and removed by the poll.
+func (l *List) ApplyPollResults(m PerTenant, c PerTenantCompacted) Changes {
 	l.mtx.Lock()
 	defer l.mtx.Unlock()
 
+	prev := l.metas
 	l.metas = m
 	l.compactedMetas = c
 
@@ -94,6 +101,40 @@ func (l *List) ApplyPollResults(m PerTenant, c PerTenantCompacted) {
 	l.added = make(PerTenant)
 	l.removed = make(PerTenant)
 	l.compactedAdded = make(PerTenantCompacted)
+
+	var changes Changes
+	for tenantID := range prev {
+		if _, ok := l.metas[tenantID]; !ok {
+			changes.add(diff(prev[tenantID], nil))
+		}
+	}
+	for tenantID, metas := range l.metas {
+		changes.add(diff(prev[tenantID], metas))
+	}
+
+	return changes
+}
+
+// ApplyTenantPollResults applies the poll results of a single tenant to this blocklist. The blocklists of the other
+// tenants and their local changes are left as they are. It returns the blocks added and removed by the poll.
+func (l *List) ApplyTenantPollResults(tenantID string, metas []*backend.BlockMeta, compactedMetas []*backend.CompactedBlockMeta) Changes {
+	if tenantID == "" {
+		return Changes{}
+	}
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	prev := l.metas[tenantID]
+	l.metas[tenantID] = metas
+	l.compactedMetas[tenantID] = compactedMetas
+
+	l.updateInternal(tenantID, l.added[tenantID], l.removed[tenantID], l.compactedAdded[tenantID])
+	delete(l.added, tenantID)
+	delete(l.removed, tenantID)
+	delete(l.compactedAdded, tenantID)
+
+	return diff(prev, l.metas[tenantID])
 }
 
 // Update Adds and removes regular or compacted blocks from the in-memory blocklist.
@@ -161,3 +202,28 @@ func (l *List) updateInternal(tenantID string, add []*backend.BlockMeta, remove
 	}
 	l.compactedMetas[tenantID] = newCompactedBlocklist
 }
+
+func (c *Changes) add(o Changes) {
+	c.Added += o.Added
+	c.Removed += o.Removed
+}
+
+// diff counts the blocks of next that are not in prev as added and the blocks of prev that are not in next as removed.
+func diff(prev []*backend.BlockMeta, next []*backend.BlockMeta) Changes {
+	prevIDs := make(map[uuid.UUID]struct{}, len(prev))
+	for _, b := range prev {
+		prevIDs[b.BlockID] = struct{}{}
+	}
+
+	var changes Changes
+	for _, b := range next {
+		if _, ok := prevIDs[b.BlockID]; ok {
+			delete(prevIDs, b.BlockID)
+			continue
+		}
+		changes.Added++
+	}
+	changes.Removed = len(prevIDs)
+
+	return changes
+}
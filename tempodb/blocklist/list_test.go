@@ -122,6 +122,42 @@ func TestApplyPollResults(t *testing.T) {
 	}
 }
 
+func TestApplyPollResultsChanges(t *testing.T) {
+	one := &backend.BlockMeta{BlockID: uuid.MustParse("00000000-0000-0000-0000-000000000001")}
+	two := &backend.BlockMeta{BlockID: uuid.MustParse("00000000-0000-0000-0000-000000000002")}
+	three := &backend.BlockMeta{BlockID: uuid.MustParse("00000000-0000-0000-0000-000000000003")}
+
+	l := New()
+	changes := l.ApplyPollResults(PerTenant{testTenantID: {one, two}, "other": {three}}, PerTenantCompacted{})
+	assert.Equal(t, Changes{Added: 3}, changes)
+
+	changes = l.ApplyPollResults(PerTenant{testTenantID: {two, three}}, PerTenantCompacted{})
+	assert.Equal(t, Changes{Added: 1, Removed: 2}, changes)
+}
+
+func TestApplyTenantPollResults(t *testing.T) {
+	one := &backend.BlockMeta{BlockID: uuid.MustParse("00000000-0000-0000-0000-000000000001")}
+	two := &backend.BlockMeta{BlockID: uuid.MustParse("00000000-0000-0000-0000-000000000002")}
+	three := &backend.BlockMeta{BlockID: uuid.MustParse("00000000-0000-0000-0000-000000000003")}
+	compacted := &backend.CompactedBlockMeta{BlockMeta: *three}
+
+	l := New()
+	l.ApplyPollResults(PerTenant{testTenantID: {one}, "other": {three}}, PerTenantCompacted{})
+
+	// local changes of the tenant are kept
+	l.Update(testTenantID, []*backend.BlockMeta{two}, nil, nil)
+
+	changes := l.ApplyTenantPollResults(testTenantID, []*backend.BlockMeta{three}, []*backend.CompactedBlockMeta{compacted})
+	assert.Equal(t, Changes{Added: 1, Removed: 1}, changes)
+	assert.ElementsMatch(t, []*backend.BlockMeta{three, two}, l.Metas(testTenantID))
+	assert.Equal(t, []*backend.CompactedBlockMeta{compacted}, l.CompactedMetas(testTenantID))
+
+	// other tenants are untouched
+	assert.Equal(t, []*backend.BlockMeta{three}, l.Metas("other"))
+
+	assert.Equal(t, Changes{}, l.ApplyTenantPollResults("", []*backend.BlockMeta{one}, nil))
+}
+
 func TestUpdate(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -33,6 +33,11 @@ var (
 		Name:      "blocklist_length",
 		Help:      "Total number of blocks per tenant.",
 	}, []string{"tenant"})
+	metricBlocklistDataEncodingBlocks = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "tempodb",
+		Name:      "blocklist_data_encoding_blocks",
+		Help:      "Number of blocks per tenant and data encoding. A migration to a new data encoding is complete once it is the only one left.",
+	}, []string{"tenant", "data_encoding"})
 	metricTenantIndexErrors = promauto.NewCounterVec(prometheus.CounterOpts{
 		Namespace: "tempodb",
 		Name:      "blocklist_tenant_index_errors_total",
@@ -75,6 +80,9 @@ type Poller struct {
 
 	sharder JobSharder
 	logger  log.Logger
+
+	// data encodings per tenant published by the last poll
+	dataEncodings map[string]map[string]struct{}
 }
 
 // NewPoller creates the Poller
@@ -87,6 +95,8 @@ func NewPoller(cfg *PollerConfig, sharder JobSharder, reader backend.Reader, com
 		cfg:     cfg,
 		sharder: sharder,
 		logger:  logger,
+
+		dataEncodings: map[string]map[string]struct{}{},
 	}
 }
 
@@ -112,6 +122,7 @@ func (p *Poller) Do() (PerTenant, PerTenantCompacted, error) {
 		}
 
 		metricBlocklistLength.WithLabelValues(tenantID).Set(float64(len(newBlockList)))
+		p.updateDataEncodingMetrics(tenantID, newBlockList)
 
 		blocklist[tenantID] = newBlockList
 		compactedBlocklist[tenantID] = newCompactedBlockList
@@ -120,6 +131,44 @@ func (p *Poller) Do() (PerTenant, PerTenantCompacted, error) {
 	return blocklist, compactedBlocklist, nil
 }
 
+// DoTenant polls the blocklist of a single tenant, e.g. to pick up blocks changed by hand without waiting for the
+// next poll of all tenants.
+func (p *Poller) DoTenant(tenantID string) ([]*backend.BlockMeta, []*backend.CompactedBlockMeta, error) {
+	start := time.Now()
+	defer func() { metricBlocklistPollDuration.Observe(time.Since(start).Seconds()) }()
+
+	newBlockList, newCompactedBlockList, err := p.pollTenantAndCreateIndex(context.Background(), tenantID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	metricBlocklistLength.WithLabelValues(tenantID).Set(float64(len(newBlockList)))
+	p.updateDataEncodingMetrics(tenantID, newBlockList)
+
+	return newBlockList, newCompactedBlockList, nil
+}
+
+// updateDataEncodingMetrics publishes the number of blocks per data encoding of the tenant. Data encodings that no
+// longer have blocks are removed.
+func (p *Poller) updateDataEncodingMetrics(tenantID string, blocklist []*backend.BlockMeta) {
+	blocks := map[string]int{}
+	for _, b := range blocklist {
+		blocks[b.DataEncoding]++
+	}
+
+	dataEncodings := make(map[string]struct{}, len(blocks))
+	for dataEncoding, n := range blocks {
+		metricBlocklistDataEncodingBlocks.WithLabelValues(tenantID, dataEncoding).Set(float64(n))
+		dataEncodings[dataEncoding] = struct{}{}
+	}
+	for dataEncoding := range p.dataEncodings[tenantID] {
+		if _, ok := dataEncodings[dataEncoding]; !ok {
+			metricBlocklistDataEncodingBlocks.DeleteLabelValues(tenantID, dataEncoding)
+		}
+	}
+	p.dataEncodings[tenantID] = dataEncodings
+}
+
 func (p *Poller) pollTenantAndCreateIndex(ctx context.Context, tenantID string) ([]*backend.BlockMeta, []*backend.CompactedBlockMeta, error) {
 	// are we a tenant index builder?
 	if !p.buildTenantIndex() {
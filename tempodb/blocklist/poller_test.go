@@ -7,8 +7,10 @@ import (
 
 	"github.com/go-kit/kit/log"
 	"github.com/google/uuid"
+	"github.com/grafana/tempo/pkg/util/test"
 	"github.com/grafana/tempo/tempodb/backend"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 var (
@@ -175,6 +177,32 @@ func TestTenantIndexBuilder(t *testing.T) {
 	}
 }
 
+func TestPollerDataEncodingMetrics(t *testing.T) {
+	poller := NewPoller(&PollerConfig{}, &mockJobSharder{}, nil, nil, nil, log.NewNopLogger())
+
+	blocks := func(dataEncodings ...string) []*backend.BlockMeta {
+		metas := make([]*backend.BlockMeta, 0, len(dataEncodings))
+		for _, e := range dataEncodings {
+			metas = append(metas, &backend.BlockMeta{DataEncoding: e})
+		}
+		return metas
+	}
+	gauge := func(dataEncoding string) float64 {
+		v, err := test.GetGaugeValue(metricBlocklistDataEncodingBlocks.WithLabelValues("test", dataEncoding))
+		require.NoError(t, err)
+		return v
+	}
+
+	poller.updateDataEncodingMetrics("test", blocks("", "v1", "v1"))
+	assert.Equal(t, 1.0, gauge(""))
+	assert.Equal(t, 2.0, gauge("v1"))
+
+	// the old encoding is removed once all blocks are migrated
+	poller.updateDataEncodingMetrics("test", blocks("v1", "v1"))
+	assert.False(t, metricBlocklistDataEncodingBlocks.DeleteLabelValues("test", ""))
+	assert.Equal(t, 2.0, gauge("v1"))
+}
+
 func TestTenantIndexFallback(t *testing.T) {
 	tests := []struct {
 		name                      string
@@ -0,0 +1,183 @@
+package tempodb
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/grafana/tempo/tempodb/backend"
+	"github.com/grafana/tempo/tempodb/encoding/common"
+)
+
+const (
+	bloomResultNegative      = "negative"
+	bloomResultTruePositive  = "true_positive"
+	bloomResultFalsePositive = "false_positive"
+
+	bloomStatsReportInterval = time.Hour
+	// bloomStatsMinLookups is the number of lookups of traces not in a block required to suggest a BloomFP
+	bloomStatsMinLookups = 1000
+	// bloomFPTargetShare is the share of the index lookups that false positives may cause with the suggested BloomFP
+	bloomFPTargetShare = 0.1
+	bloomFPMin         = 1e-6
+	bloomFPMax         = 0.5
+)
+
+var (
+	metricBloomLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempodb",
+		Name:      "bloom_filter_lookups_total",
+		Help:      "Total number of trace lookups in block bloom filters by result.",
+	}, []string{"tenant", "result"})
+	metricBloomObservedFPRatio = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "tempodb",
+		Name:      "bloom_filter_observed_false_positive_ratio",
+		Help:      "The share of lookups of traces not in a block that passed its bloom filter during the last report interval.",
+	}, []string{"tenant"})
+)
+
+type bloomCounts struct {
+	negatives      int
+	truePositives  int
+	falsePositives int
+}
+
+// observedFP returns the share of the lookups of traces not in a block that passed its bloom filter. It is comparable
+// to the configured BloomFP.
+func (c bloomCounts) observedFP() float64 {
+	absent := c.negatives + c.falsePositives
+	if absent == 0 {
+		return 0
+	}
+	return float64(c.falsePositives) / float64(absent)
+}
+
+// bloomStats counts per tenant how the bloom filters of the blocks searched for traces decided. A bloom filter match
+// for a block that doesn't hold the trace is an observed false positive that costs an index and a data read.
+type bloomStats struct {
+	mtx     sync.Mutex
+	tenants map[string]*bloomCounts
+}
+
+func newBloomStats() *bloomStats {
+	return &bloomStats{
+		tenants: map[string]*bloomCounts{},
+	}
+}
+
+func (s *bloomStats) observe(tenantID string, bloomMatched bool, found bool) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	c, ok := s.tenants[tenantID]
+	if !ok {
+		c = &bloomCounts{}
+		s.tenants[tenantID] = c
+	}
+
+	result := bloomResultNegative
+	switch {
+	case bloomMatched && found:
+		result = bloomResultTruePositive
+		c.truePositives++
+	case bloomMatched:
+		result = bloomResultFalsePositive
+		c.falsePositives++
+	default:
+		c.negatives++
+	}
+	metricBloomLookups.WithLabelValues(tenantID, result).Inc()
+}
+
+// reset returns the counts of every tenant since the last reset.
+func (s *bloomStats) reset() map[string]bloomCounts {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	counts := make(map[string]bloomCounts, len(s.tenants))
+	for tenantID, c := range s.tenants {
+		counts[tenantID] = *c
+	}
+	s.tenants = map[string]*bloomCounts{}
+	return counts
+}
+
+func (rw *readerWriter) bloomStatsLoop() {
+	ticker := time.NewTicker(bloomStatsReportInterval)
+	for range ticker.C {
+		rw.reportBloomStats()
+	}
+}
+
+// reportBloomStats updates the observed false positive ratio of every tenant and logs a BloomFP suggested from the
+// lookups since the last report and the current size of the blocks of the tenant.
+func (rw *readerWriter) reportBloomStats() {
+	for tenantID, c := range rw.bloomStats.reset() {
+		observed := c.observedFP()
+		metricBloomObservedFPRatio.WithLabelValues(tenantID).Set(observed)
+
+		if c.negatives+c.falsePositives < bloomStatsMinLookups {
+			continue
+		}
+
+		objects := averageBlockObjects(rw.blocklist.Metas(tenantID))
+		suggested := suggestBloomFP(c, objects, rw.cfg.Block.BloomShardSizeBytes)
+		level.Info(rw.logger).Log("msg", "bloom filter false positives",
+			"tenant", tenantID,
+			"true_positives", c.truePositives,
+			"false_positives", c.falsePositives,
+			"negatives", c.negatives,
+			"observed_fp", observed,
+			"configured_fp", rw.cfg.Block.BloomFP,
+			"suggested_fp", suggested,
+			"average_block_objects", int(objects),
+			"configured_bloom_bytes_per_block", int(bloomBytes(objects, rw.cfg.Block.BloomFP)),
+			"suggested_bloom_bytes_per_block", int(bloomBytes(objects, suggested)))
+	}
+}
+
+// suggestBloomFP returns the BloomFP at which false positives would cause bloomFPTargetShare of the index lookups of
+// the tenant. The number of false positives is proportional to the false positive rate of the bloom filters so it only
+// depends on how many of the lookups find a trace. The rate is bounded by the lowest one the bloom filters of blocks
+// with the given number of objects can have within the maximum number of shards.
+func suggestBloomFP(c bloomCounts, blockObjects float64, shardSizeBytes int) float64 {
+	absent := c.negatives + c.falsePositives
+	if absent == 0 {
+		return bloomFPMax
+	}
+
+	fp := bloomFPTargetShare / (1 - bloomFPTargetShare) * float64(c.truePositives) / float64(absent)
+	min := math.Max(bloomFPMin, minBloomFP(blockObjects, shardSizeBytes))
+	return math.Min(bloomFPMax, math.Max(min, fp))
+}
+
+// minBloomFP returns the lowest false positive rate of a bloom filter of the given number of objects that fits into
+// the maximum number of shards.
+func minBloomFP(objects float64, shardSizeBytes int) float64 {
+	if objects <= 0 {
+		return 0
+	}
+	bits := float64(common.MaxShardCount) * float64(shardSizeBytes) * 8
+	return math.Exp(-bits * math.Ln2 * math.Ln2 / objects)
+}
+
+// bloomBytes returns the size of an optimal bloom filter of the given number of objects and false positive rate.
+func bloomBytes(objects float64, fp float64) float64 {
+	return -objects * math.Log(fp) / (math.Ln2 * math.Ln2) / 8
+}
+
+func averageBlockObjects(metas []*backend.BlockMeta) float64 {
+	if len(metas) == 0 {
+		return 0
+	}
+
+	total := 0.0
+	for _, m := range metas {
+		total += float64(m.TotalObjects)
+	}
+	return total / float64(len(metas))
+}
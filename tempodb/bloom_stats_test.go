@@ -0,0 +1,144 @@
+package tempodb
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"math/rand"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/willf/bloom"
+
+	"github.com/grafana/tempo/pkg/util/test"
+	"github.com/grafana/tempo/tempodb/backend"
+	"github.com/grafana/tempo/tempodb/encoding/common"
+)
+
+func TestBloomStatsObservedFP(t *testing.T) {
+	const objects = 10_000
+	const lookups = 100_000
+
+	for _, fp := range []float64{0.01, 0.05, 0.2} {
+		// a bloom filter of a single shard has the configured false positive rate
+		m, _ := bloom.EstimateParameters(objects, fp)
+		filter := common.NewBloom(fp, uint(math.Ceil(float64(m)/8)), objects)
+		require.Equal(t, 1, filter.GetShardCount())
+
+		ids := make([][]byte, 0, objects)
+		for i := 0; i < objects; i++ {
+			id := make([]byte, 16)
+			rand.Read(id)
+			filter.Add(id)
+			ids = append(ids, id)
+		}
+
+		stats := newBloomStats()
+		for _, id := range ids {
+			stats.observe(testTenantID, filter.Test(id), true)
+		}
+		for i := 0; i < lookups; i++ {
+			id := make([]byte, 16)
+			rand.Read(id)
+			stats.observe(testTenantID, filter.Test(id), false)
+		}
+
+		counts := stats.reset()[testTenantID]
+		assert.Equal(t, objects, counts.truePositives)
+		assert.Equal(t, lookups, counts.negatives+counts.falsePositives)
+		assert.InEpsilon(t, fp, counts.observedFP(), 0.2, "fp %f", fp)
+
+		// the counts are reset
+		assert.Empty(t, stats.reset())
+	}
+}
+
+func TestSuggestBloomFP(t *testing.T) {
+	tests := []struct {
+		name     string
+		counts   bloomCounts
+		objects  float64
+		expected float64
+	}{
+		{
+			name:     "false positives are a tenth of the index lookups",
+			counts:   bloomCounts{truePositives: 900, falsePositives: 100, negatives: 9_900},
+			objects:  1_000_000,
+			expected: 0.01,
+		},
+		{
+			name:     "bounded by the max",
+			counts:   bloomCounts{truePositives: 100_000, negatives: 1_000},
+			objects:  1_000_000,
+			expected: bloomFPMax,
+		},
+		{
+			name:     "bounded by the min",
+			counts:   bloomCounts{negatives: 1_000},
+			objects:  1_000_000,
+			expected: bloomFPMin,
+		},
+		{
+			name:     "bounded by the max shard count",
+			counts:   bloomCounts{negatives: 1_000},
+			objects:  1e8,
+			expected: minBloomFP(1e8, 100_000),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.InEpsilon(t, tc.expected, suggestBloomFP(tc.counts, tc.objects, 100_000), 0.0001)
+		})
+	}
+
+	// the suggested rate fits into the max shard count
+	fp := minBloomFP(1e8, 100_000)
+	assert.InEpsilon(t, float64(common.MaxShardCount*100_000), bloomBytes(1e8, fp), 0.0001)
+}
+
+func TestFindObservesBloomStats(t *testing.T) {
+	r, w, _, tempDir := testConfig(t, backend.EncNone, 0)
+	defer os.RemoveAll(tempDir)
+
+	ids := cutTestBlocks(t, w, testTenantID, 1, 10)[0].IDs
+
+	r.EnablePolling(&mockJobSharder{})
+	rw := r.(*readerWriter)
+
+	before, err := test.GetCounterValue(metricBloomLookups.WithLabelValues(testTenantID, bloomResultTruePositive))
+	require.NoError(t, err)
+
+	for _, id := range ids {
+		_, _, _, err = r.Find(context.Background(), testTenantID, id, BlockIDMin, BlockIDMax, time.Time{}, time.Time{}, 0)
+		require.NoError(t, err)
+	}
+	// absent ids within the id range of the block
+	for i := 0; i < 100; i++ {
+		id := append([]byte{}, ids[5]...)
+		id[15] = byte(i)
+		if bytes.Equal(id, ids[5]) {
+			id[14]++
+		}
+		_, _, _, err = r.Find(context.Background(), testTenantID, id, BlockIDMin, BlockIDMax, time.Time{}, time.Time{}, 0)
+		require.NoError(t, err)
+	}
+
+	counts := rw.bloomStats.tenants[testTenantID]
+	assert.Equal(t, 10, counts.truePositives)
+	assert.Equal(t, 100, counts.negatives+counts.falsePositives)
+	observed := counts.observedFP()
+
+	after, err := test.GetCounterValue(metricBloomLookups.WithLabelValues(testTenantID, bloomResultTruePositive))
+	require.NoError(t, err)
+	assert.Equal(t, 10.0, after-before)
+
+	rw.reportBloomStats()
+	ratio, err := test.GetGaugeValue(metricBloomObservedFPRatio.WithLabelValues(testTenantID))
+	require.NoError(t, err)
+	assert.Equal(t, observed, ratio)
+	assert.Empty(t, rw.bloomStats.reset())
+}
@@ -611,7 +611,7 @@ func TestTimeWindowBlockSelectorBlocksToCompact(t *testing.T) {
 			},
 		},
 		{
-			name: "don't compact across dataEncodings",
+			name: "compact across dataEncodings",
 			blocklist: []*backend.BlockMeta{
 				{
 					BlockID:      uuid.MustParse("00000000-0000-0000-0000-000000000000"),
@@ -624,7 +624,19 @@ func TestTimeWindowBlockSelectorBlocksToCompact(t *testing.T) {
 					DataEncoding: "foo",
 				},
 			},
-			expected: nil,
+			expected: []*backend.BlockMeta{
+				{
+					BlockID:      uuid.MustParse("00000000-0000-0000-0000-000000000000"),
+					EndTime:      now,
+					DataEncoding: "bar",
+				},
+				{
+					BlockID:      uuid.MustParse("00000000-0000-0000-0000-000000000001"),
+					EndTime:      now,
+					DataEncoding: "foo",
+				},
+			},
+			expectedHash: fmt.Sprintf("%v-%v-%v", tenantID, 0, now.Unix()),
 		},
 	}
 
@@ -11,11 +11,14 @@ import (
 
 	"github.com/pkg/errors"
 
+	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
 	"github.com/google/uuid"
+	"github.com/grafana/tempo/pkg/model"
 	"github.com/grafana/tempo/tempodb/backend"
 	"github.com/grafana/tempo/tempodb/encoding"
 	"github.com/grafana/tempo/tempodb/encoding/common"
+	"github.com/grafana/tempo/tempodb/notifications"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 )
@@ -46,6 +49,26 @@ var (
 		Name:      "compaction_objects_combined_total",
 		Help:      "Total number of objects combined during compaction.",
 	}, []string{"level"})
+	metricCompactionTracesTruncated = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempodb",
+		Name:      "compaction_traces_truncated_total",
+		Help:      "Total number of combined traces truncated to the max bytes per trace during compaction.",
+	}, []string{"tenant"})
+	metricCompactionSpansDropped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempodb",
+		Name:      "compaction_spans_dropped_total",
+		Help:      "Total number of spans dropped truncating combined traces during compaction.",
+	}, []string{"tenant"})
+	metricCompactionObjectsTranscoded = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempodb",
+		Name:      "compaction_objects_transcoded_total",
+		Help:      "Total number of objects transcoded to the data encoding of the other blocks they are compacted with.",
+	}, []string{"tenant", "from", "to"})
+	metricCompactionReplicasKept = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempodb",
+		Name:      "compaction_largest_replicas_kept_total",
+		Help:      "Total number of traces not combined during compaction because the result would exceed the max bytes per trace.",
+	}, []string{"tenant"})
 )
 
 const (
@@ -55,6 +78,7 @@ const (
 	compactionCycle = 30 * time.Second
 
 	DefaultFlushSizeBytes uint32 = 30 * 1024 * 1024 // 30 MiB
+	DefaultChunkSizeBytes uint32 = 5 * 1024 * 1024  // 5 MiB
 
 	DefaultIteratorBufferSize = 1000
 )
@@ -145,11 +169,11 @@ func (rw *readerWriter) compact(blockMetas []*backend.BlockMeta, tenantID string
 	}()
 
 	var totalRecords int
-	var dataEncoding string
+	// blocks of different data encodings are transcoded to the encoding of the majority of the objects
+	dataEncoding := compactionDataEncoding(blockMetas)
 	for _, blockMeta := range blockMetas {
 		level.Info(rw.logger).Log("msg", "compacting block", "block", fmt.Sprintf("%+v", blockMeta))
 		totalRecords += blockMeta.TotalObjects
-		dataEncoding = blockMeta.DataEncoding // blocks chosen for compaction always have the same data encoding
 
 		// Make sure block still exists
 		_, err = rw.r.BlockMeta(ctx, blockMeta.BlockID, tenantID)
@@ -168,16 +192,37 @@ func (rw *readerWriter) compact(blockMetas []*backend.BlockMeta, tenantID string
 			return err
 		}
 
+		if blockMeta.DataEncoding != dataEncoding {
+			level.Info(rw.logger).Log("msg", "transcoding block", "block", blockMeta.BlockID, "from", blockMeta.DataEncoding, "to", dataEncoding)
+			iter = &transcodingIterator{
+				Iterator: iter,
+				tenantID: tenantID,
+				from:     blockMeta.DataEncoding,
+				to:       dataEncoding,
+			}
+		}
+
 		iters = append(iters, iter)
 	}
 
 	recordsPerBlock := (totalRecords / outputBlocks)
 	var newCompactedBlocks []*backend.BlockMeta
 	var currentBlock *encoding.StreamingBlock
+	var currentSkeleton *skeletonBlock
 	var tracker backend.AppendTracker
 
-	combiner := instrumentedObjectCombiner{
-		inner:                rw.compactorSharder,
+	var combiner common.ObjectCombiner = rw.compactorSharder
+	if maxBytes := rw.compactorOverrides.MaxBytesPerTraceForTenant(tenantID); maxBytes > 0 {
+		combiner = sizeLimitedObjectCombiner{
+			inner:    combiner,
+			logger:   rw.logger,
+			tenantID: tenantID,
+			maxBytes: maxBytes,
+			policy:   rw.compactorCfg.MaxTraceBytesPolicy,
+		}
+	}
+	combiner = instrumentedObjectCombiner{
+		inner:                combiner,
 		compactionLevelLabel: compactionLevelLabel,
 	}
 
@@ -197,12 +242,18 @@ func (rw *readerWriter) compact(blockMetas []*backend.BlockMeta, tenantID string
 
 		// make a new block if necessary
 		if currentBlock == nil {
-			currentBlock, err = encoding.NewStreamingBlock(rw.cfg.Block, uuid.New(), tenantID, blockMetas, recordsPerBlock)
+			currentBlock, err = encoding.NewStreamingBlock(rw.cfg.Block, uuid.New(), tenantID, blockMetas, dataEncoding, recordsPerBlock)
 			if err != nil {
 				return errors.Wrap(err, "error making new compacted block")
 			}
 			currentBlock.BlockMeta().CompactionLevel = nextCompactionLevel
+			currentBlock.BlockMeta().SetCompactedFrom(blockMetas)
 			newCompactedBlocks = append(newCompactedBlocks, currentBlock.BlockMeta())
+
+			currentSkeleton, err = rw.newSkeletonBlock(currentBlock.BlockMeta(), recordsPerBlock)
+			if err != nil {
+				return err
+			}
 		}
 
 		err = currentBlock.AddObject(id, body)
@@ -210,6 +261,11 @@ func (rw *readerWriter) compact(blockMetas []*backend.BlockMeta, tenantID string
 			return err
 		}
 
+		err = currentSkeleton.add(ctx, rw.w, id, body)
+		if err != nil {
+			return err
+		}
+
 		// write partial block
 		if currentBlock.CurrentBufferLength() >= int(rw.compactorCfg.FlushSizeBytes) {
 			runtime.GC()
@@ -221,18 +277,19 @@ func (rw *readerWriter) compact(blockMetas []*backend.BlockMeta, tenantID string
 
 		// ship block to backend if done
 		if currentBlock.Length() >= recordsPerBlock {
-			err = finishBlock(rw, tracker, currentBlock)
+			err = finishBlock(rw, tracker, currentBlock, currentSkeleton)
 			if err != nil {
 				return errors.Wrap(err, "error shipping block to backend")
 			}
 			currentBlock = nil
+			currentSkeleton = nil
 			tracker = nil
 		}
 	}
 
 	// ship final block to backend
 	if currentBlock != nil {
-		err = finishBlock(rw, tracker, currentBlock)
+		err = finishBlock(rw, tracker, currentBlock, currentSkeleton)
 		if err != nil {
 			return errors.Wrap(err, "error shipping block to backend")
 		}
@@ -241,6 +298,11 @@ func (rw *readerWriter) compact(blockMetas []*backend.BlockMeta, tenantID string
 	// mark old blocks compacted so they don't show up in polling
 	markCompacted(rw, tenantID, blockMetas, newCompactedBlocks)
 
+	// the skeletons of the traces of the old blocks were written along with the new blocks
+	if rw.cfg.Skeleton.Enabled {
+		rw.clearSkeletons(tenantID, blockMetas)
+	}
+
 	metricCompactionBlocks.WithLabelValues(compactionLevelLabel).Add(float64(len(blockMetas)))
 
 	return nil
@@ -259,11 +321,16 @@ func appendBlock(rw *readerWriter, tracker backend.AppendTracker, block *encodin
 	return tracker, nil
 }
 
-func finishBlock(rw *readerWriter, tracker backend.AppendTracker, block *encoding.StreamingBlock) error {
+func finishBlock(rw *readerWriter, tracker backend.AppendTracker, block *encoding.StreamingBlock, skeleton *skeletonBlock) error {
 	level.Info(rw.logger).Log("msg", "writing compacted block", "block", fmt.Sprintf("%+v", block.BlockMeta()))
 
 	w := rw.getWriterForBlock(block.BlockMeta(), time.Now())
 
+	err := skeleton.complete(context.TODO(), w, block.BlockMeta())
+	if err != nil {
+		return err
+	}
+
 	bytesFlushed, err := block.Complete(context.TODO(), tracker, w)
 	if err != nil {
 		return err
@@ -271,6 +338,8 @@ func finishBlock(rw *readerWriter, tracker backend.AppendTracker, block *encodin
 	compactionLevelLabel := strconv.Itoa(int(block.BlockMeta().CompactionLevel - 1))
 	metricCompactionBytesWritten.WithLabelValues(compactionLevelLabel).Add(float64(bytesFlushed))
 
+	rw.notifier.Notify(notifications.NewEvent(notifications.EventBlockCompacted, block.BlockMeta()))
+
 	return nil
 }
 
@@ -286,6 +355,25 @@ func compactionLevelForBlocks(blockMetas []*backend.BlockMeta) uint8 {
 	return level
 }
 
+// compactionDataEncoding returns the data encoding of the majority of the objects in the blocks. Ties are broken in
+// favor of the current encoding so mixed blocks converge on it.
+func compactionDataEncoding(blockMetas []*backend.BlockMeta) string {
+	objects := map[string]int{}
+	for _, m := range blockMetas {
+		objects[m.DataEncoding] += m.TotalObjects
+	}
+
+	dataEncoding := blockMetas[0].DataEncoding
+	for _, m := range blockMetas {
+		n, most := objects[m.DataEncoding], objects[dataEncoding]
+		if n > most || (n == most && m.DataEncoding == model.CurrentEncoding) {
+			dataEncoding = m.DataEncoding
+		}
+	}
+
+	return dataEncoding
+}
+
 func markCompacted(rw *readerWriter, tenantID string, oldBlocks []*backend.BlockMeta, newBlocks []*backend.BlockMeta) {
 	for _, meta := range oldBlocks {
 		// Mark in the backend
@@ -321,3 +409,71 @@ func (i instrumentedObjectCombiner) Combine(dataEncoding string, objs ...[]byte)
 	}
 	return b, wasCombined
 }
+
+// sizeLimitedObjectCombiner keeps combined objects within the max bytes per trace of the tenant. Replicas of a trace
+// can combine into an object that is larger than any the ingesters would accept.
+type sizeLimitedObjectCombiner struct {
+	inner    common.ObjectCombiner
+	logger   log.Logger
+	tenantID string
+	maxBytes int
+	policy   string
+}
+
+// Combine wraps the inner combiner and applies the max trace bytes policy to objects that don't fit
+func (s sizeLimitedObjectCombiner) Combine(dataEncoding string, objs ...[]byte) ([]byte, bool) {
+	b, wasCombined := s.inner.Combine(dataEncoding, objs...)
+	if !wasCombined || len(b) <= s.maxBytes {
+		return b, wasCombined
+	}
+
+	if s.policy == MaxTraceBytesPolicyKeepLargest {
+		largest := objs[0]
+		for _, obj := range objs[1:] {
+			if len(obj) > len(largest) {
+				largest = obj
+			}
+		}
+
+		if len(largest) <= s.maxBytes {
+			metricCompactionReplicasKept.WithLabelValues(s.tenantID).Inc()
+			return largest, false
+		}
+		// even the largest replica is over the limit. fall back to truncating it
+		b = largest
+	}
+
+	truncated, dropped, err := model.TruncateTraceBytes(b, dataEncoding, s.maxBytes)
+	if err != nil {
+		level.Error(s.logger).Log("msg", "error truncating combined trace", "tenant", s.tenantID, "err", err)
+		return b, wasCombined
+	}
+
+	metricCompactionTracesTruncated.WithLabelValues(s.tenantID).Inc()
+	metricCompactionSpansDropped.WithLabelValues(s.tenantID).Add(float64(dropped))
+	return truncated, true
+}
+
+// transcodingIterator converts the objects of a block to the data encoding of the blocks it is compacted with. This is
+// slow as every object is unmarshalled and marshalled again.
+type transcodingIterator struct {
+	encoding.Iterator
+	tenantID string
+	from     string
+	to       string
+}
+
+func (t *transcodingIterator) Next(ctx context.Context) (common.ID, []byte, error) {
+	id, obj, err := t.Iterator.Next(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	obj, err = model.Transcode(obj, t.from, t.to)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "error transcoding object %x from %q to %q", id, t.from, t.to)
+	}
+	metricCompactionObjectsTranscoded.WithLabelValues(t.tenantID, t.from, t.to).Inc()
+
+	return id, obj, nil
+}
@@ -7,6 +7,7 @@ import (
 	"math/rand"
 	"os"
 	"path"
+	"sort"
 	"testing"
 	"time"
 
@@ -16,11 +17,13 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/grafana/tempo/pkg/model"
 	"github.com/grafana/tempo/pkg/tempopb"
 	"github.com/grafana/tempo/pkg/util/test"
 	"github.com/grafana/tempo/tempodb/backend"
 	"github.com/grafana/tempo/tempodb/backend/local"
 	"github.com/grafana/tempo/tempodb/encoding"
+	"github.com/grafana/tempo/tempodb/encoding/encodingtest"
 	"github.com/grafana/tempo/tempodb/pool"
 	"github.com/grafana/tempo/tempodb/wal"
 )
@@ -49,13 +52,18 @@ type mockJobSharder struct{}
 func (m *mockJobSharder) Owns(_ string) bool { return true }
 
 type mockOverrides struct {
-	blockRetention time.Duration
+	blockRetention   time.Duration
+	maxBytesPerTrace int
 }
 
 func (m *mockOverrides) BlockRetentionForTenant(_ string) time.Duration {
 	return m.blockRetention
 }
 
+func (m *mockOverrides) MaxBytesPerTraceForTenant(_ string) int {
+	return m.maxBytesPerTrace
+}
+
 func TestCompaction(t *testing.T) {
 	tempDir, err := ioutil.TempDir("/tmp", "")
 	defer os.RemoveAll(tempDir)
@@ -175,7 +183,7 @@ func TestCompaction(t *testing.T) {
 
 	// now see if we can find our ids
 	for i, id := range allIds {
-		b, _, err := rw.Find(context.Background(), testTenantID, id, BlockIDMin, BlockIDMax)
+		b, _, _, err := rw.Find(context.Background(), testTenantID, id, BlockIDMin, BlockIDMax, time.Time{}, time.Time{}, 0)
 		assert.NoError(t, err)
 
 		out := &tempopb.PushRequest{}
@@ -276,6 +284,173 @@ func TestSameIDCompaction(t *testing.T) {
 	assert.Equal(t, float64(1), combinedFinish-combinedStart)
 }
 
+type mockCombiningSharder struct{}
+
+func (m *mockCombiningSharder) Owns(hash string) bool {
+	return true
+}
+
+func (m *mockCombiningSharder) Combine(dataEncoding string, objs ...[]byte) ([]byte, bool) {
+	return model.ObjectCombiner.Combine(dataEncoding, objs...)
+}
+
+func TestMixedDataEncodingCompaction(t *testing.T) {
+	tempDir, err := ioutil.TempDir("/tmp", "")
+	defer os.RemoveAll(tempDir)
+	require.NoError(t, err, "unexpected error creating temp dir")
+
+	r, w, c, err := New(&Config{
+		Backend: "local",
+		Pool: &pool.Config{
+			MaxWorkers: 10,
+			QueueDepth: 100,
+		},
+		Local: &local.Config{
+			Path: path.Join(tempDir, "traces"),
+		},
+		Block: &encoding.BlockConfig{
+			IndexDownsampleBytes: 11,
+			BloomFP:              .01,
+			BloomShardSizeBytes:  100_000,
+			Encoding:             backend.EncSnappy,
+			IndexPageSizeBytes:   1000,
+		},
+		WAL: &wal.Config{
+			Filepath: path.Join(tempDir, "wal"),
+		},
+		BlocklistPoll: 0,
+	}, log.NewNopLogger())
+	require.NoError(t, err)
+
+	c.EnableCompaction(&CompactorConfig{
+		ChunkSizeBytes:          10,
+		MaxCompactionRange:      24 * time.Hour,
+		BlockRetention:          0,
+		CompactedBlockRetention: 0,
+	}, &mockCombiningSharder{}, &mockOverrides{})
+
+	r.EnablePolling(&mockJobSharder{})
+	rw := r.(*readerWriter)
+
+	// the trace with the shared id is split across blocks of both encodings
+	sharedID := makeTraceID(0, 0)
+	sharedA := test.MakeTrace(2, sharedID)
+	sharedB := test.MakeTrace(2, sharedID)
+	expected := map[string]*tempopb.Trace{}
+	expected[string(sharedID)], _, _, _ = model.CombineTraceProtos(proto.Clone(sharedA).(*tempopb.Trace), proto.Clone(sharedB).(*tempopb.Trace))
+
+	writeBlock := func(dataEncoding string, traces map[string]*tempopb.Trace) {
+		head, err := w.WAL().NewBlock(uuid.New(), testTenantID, dataEncoding)
+		require.NoError(t, err)
+
+		ids := make([]string, 0, len(traces))
+		for id := range traces {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+
+		for _, id := range ids {
+			obj, err := model.Transcode(mustMarshalTrace(t, traces[id]), model.CurrentEncoding, dataEncoding)
+			require.NoError(t, err)
+			require.NoError(t, head.Write([]byte(id), obj))
+		}
+
+		_, err = w.CompleteBlock(head, &mockCombiningSharder{})
+		require.NoError(t, err)
+	}
+
+	next := 0
+	newTraces := func(count int) map[string]*tempopb.Trace {
+		traces := map[string]*tempopb.Trace{}
+		for i := 0; i < count; i++ {
+			next++
+			id := makeTraceID(1, next)
+			traces[string(id)] = test.MakeTrace(2, id)
+			expected[string(id)] = traces[string(id)]
+		}
+		return traces
+	}
+
+	// most objects are in the current encoding
+	current := newTraces(5)
+	current[string(sharedID)] = sharedA
+	writeBlock(model.CurrentEncoding, current)
+	writeBlock(model.CurrentEncoding, newTraces(5))
+	old := newTraces(3)
+	old[string(sharedID)] = sharedB
+	writeBlock(model.TracePBEncoding, old)
+
+	transcodedStart, err := test.GetCounterValue(metricCompactionObjectsTranscoded.WithLabelValues(testTenantID, model.TracePBEncoding, model.CurrentEncoding))
+	require.NoError(t, err)
+
+	checkBlocklists(t, uuid.Nil, 3, 0, rw)
+	require.NoError(t, rw.compact(rw.blocklist.Metas(testTenantID), testTenantID))
+	checkBlocklists(t, uuid.Nil, 1, 3, rw)
+
+	meta := rw.blocklist.Metas(testTenantID)[0]
+	assert.Equal(t, model.CurrentEncoding, meta.DataEncoding)
+	assert.Equal(t, len(expected), meta.TotalObjects)
+
+	transcoded, err := test.GetCounterValue(metricCompactionObjectsTranscoded.WithLabelValues(testTenantID, model.TracePBEncoding, model.CurrentEncoding))
+	require.NoError(t, err)
+	assert.Equal(t, float64(len(old)), transcoded-transcodedStart)
+
+	block, err := encoding.NewBackendBlock(meta, rw.r)
+	require.NoError(t, err)
+
+	for id, expectedTrace := range expected {
+		obj, err := block.Find(context.Background(), []byte(id))
+		require.NoError(t, err)
+
+		actual, err := model.Unmarshal(obj, meta.DataEncoding)
+		require.NoError(t, err)
+
+		model.SortTrace(actual)
+		model.SortTrace(expectedTrace)
+		assert.True(t, proto.Equal(expectedTrace, actual))
+	}
+}
+
+func TestCompactionDataEncoding(t *testing.T) {
+	tests := []struct {
+		name     string
+		metas    []*backend.BlockMeta
+		expected string
+	}{
+		{
+			name: "majority of objects",
+			metas: []*backend.BlockMeta{
+				{DataEncoding: model.CurrentEncoding, TotalObjects: 10},
+				{DataEncoding: model.TracePBEncoding, TotalObjects: 6},
+				{DataEncoding: model.TracePBEncoding, TotalObjects: 6},
+			},
+			expected: model.TracePBEncoding,
+		},
+		{
+			name: "ties prefer the current encoding",
+			metas: []*backend.BlockMeta{
+				{DataEncoding: model.TracePBEncoding, TotalObjects: 10},
+				{DataEncoding: model.CurrentEncoding, TotalObjects: 10},
+			},
+			expected: model.CurrentEncoding,
+		},
+		{
+			name: "ties prefer the first block",
+			metas: []*backend.BlockMeta{
+				{DataEncoding: "foo", TotalObjects: 10},
+				{DataEncoding: "bar", TotalObjects: 10},
+			},
+			expected: "foo",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, compactionDataEncoding(tt.metas))
+		})
+	}
+}
+
 func TestCompactionUpdatesBlocklist(t *testing.T) {
 	tempDir, err := ioutil.TempDir("/tmp", "")
 	defer os.RemoveAll(tempDir)
@@ -316,7 +491,7 @@ func TestCompactionUpdatesBlocklist(t *testing.T) {
 	// Cut x blocks with y records each
 	blockCount := 5
 	recordCount := 1
-	cutTestBlocks(t, w, testTenantID, blockCount, recordCount)
+	inputs := cutTestBlocks(t, w, testTenantID, blockCount, recordCount)
 
 	rw := r.(*readerWriter)
 	rw.pollBlocklist()
@@ -335,16 +510,102 @@ func TestCompactionUpdatesBlocklist(t *testing.T) {
 	assert.Equal(t, blockCount, len(rw.blocklist.CompactedMetas(testTenantID)))
 
 	// Make sure all expected traces are found.
-	for i := 0; i < blockCount; i++ {
-		for j := 0; j < recordCount; j++ {
-			trace, _, err := rw.Find(context.TODO(), testTenantID, makeTraceID(i, j), BlockIDMin, BlockIDMax)
-			assert.NotNil(t, trace)
-			assert.Greater(t, len(trace), 0)
+	for _, input := range inputs {
+		for _, id := range input.IDs {
+			trace, _, _, err := rw.Find(context.TODO(), testTenantID, id, BlockIDMin, BlockIDMax, time.Time{}, time.Time{}, 0)
 			assert.NoError(t, err)
+			// the trace is found in the compacted block and in its input, which is kept for the compacted block retention
+			require.NotEmpty(t, trace)
+			for _, partial := range trace {
+				assert.Equal(t, input.Object(id), partial)
+			}
 		}
 	}
 }
 
+func TestCompactionRecordsLineage(t *testing.T) {
+	tempDir := t.TempDir()
+
+	r, w, c, err := New(&Config{
+		Backend: "local",
+		Pool: &pool.Config{
+			MaxWorkers: 10,
+			QueueDepth: 100,
+		},
+		Local: &local.Config{
+			Path: path.Join(tempDir, "traces"),
+		},
+		Block: &encoding.BlockConfig{
+			IndexDownsampleBytes: 11,
+			BloomFP:              .01,
+			BloomShardSizeBytes:  100_000,
+			Encoding:             backend.EncNone,
+			IndexPageSizeBytes:   1000,
+		},
+		WAL: &wal.Config{
+			Filepath: path.Join(tempDir, "wal"),
+		},
+		BlocklistPoll: 0,
+	}, log.NewNopLogger())
+	require.NoError(t, err)
+
+	c.EnableCompaction(&CompactorConfig{
+		ChunkSizeBytes:          10,
+		MaxCompactionRange:      24 * time.Hour,
+		BlockRetention:          0,
+		CompactedBlockRetention: 0,
+	}, &mockSharder{}, &mockOverrides{})
+
+	r.EnablePolling(&mockJobSharder{})
+
+	blockCount := 3
+	cutTestBlocks(t, w, testTenantID, blockCount, 1)
+
+	rw := r.(*readerWriter)
+	rw.pollBlocklist()
+
+	inputs := rw.blocklist.Metas(testTenantID)
+	require.NoError(t, rw.compact(inputs, testTenantID))
+
+	blocks := rw.blocklist.Metas(testTenantID)
+	require.Len(t, blocks, 1)
+
+	var inputIDs []uuid.UUID
+	for _, m := range inputs {
+		inputIDs = append(inputIDs, m.BlockID)
+	}
+	assert.ElementsMatch(t, inputIDs, blocks[0].CompactedFrom)
+	assert.Equal(t, blockCount, blocks[0].CompactedFromCount)
+
+	// the meta in the backend records the inputs
+	meta, err := rw.r.BlockMeta(context.Background(), blocks[0].BlockID, testTenantID)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, inputIDs, meta.CompactedFrom)
+
+	lineage, err := encoding.ReadLineage(context.Background(), rw.r, meta.BlockID, testTenantID)
+	require.NoError(t, err)
+	assert.Equal(t, blockCount, lineage.TotalInputs)
+	require.Len(t, lineage.Inputs, blockCount)
+	for _, in := range lineage.Inputs {
+		assert.Contains(t, inputIDs, in.BlockID)
+		assert.Equal(t, 1, in.TotalObjects)
+	}
+
+	// lineage survives copying the block
+	rawR, rawW, _, err := local.New(&local.Config{Path: path.Join(tempDir, "copy")})
+	require.NoError(t, err)
+	copyR, copyW := backend.NewReader(rawR), backend.NewWriter(rawW)
+	require.NoError(t, encoding.CopyBlock(context.Background(), meta, rw.r, copyW))
+
+	copiedMeta, err := copyR.BlockMeta(context.Background(), meta.BlockID, testTenantID)
+	require.NoError(t, err)
+	assert.Equal(t, meta.CompactedFrom, copiedMeta.CompactedFrom)
+
+	copiedLineage, err := encoding.ReadLineage(context.Background(), copyR, meta.BlockID, testTenantID)
+	require.NoError(t, err)
+	assert.Equal(t, lineage.Inputs, copiedLineage.Inputs)
+}
+
 func TestCompactionMetrics(t *testing.T) {
 	tempDir, err := ioutil.TempDir("/tmp", "")
 	defer os.RemoveAll(tempDir)
@@ -479,29 +740,19 @@ func TestCompactionIteratesThroughTenants(t *testing.T) {
 	assert.Equal(t, 1, len(rw.blocklist.Metas(testTenantID2)))
 }
 
-func cutTestBlocks(t testing.TB, w Writer, tenantID string, blockCount int, recordCount int) []*encoding.BackendBlock {
-	blocks := make([]*encoding.BackendBlock, 0)
-
-	wal := w.WAL()
+// cutTestBlocks writes blockCount blocks of the tenant with recordCount objects each to the backend of w.
+func cutTestBlocks(t testing.TB, w Writer, tenantID string, blockCount int, recordCount int) []*encodingtest.Block {
+	blocks := make([]*encodingtest.Block, 0, blockCount)
 	for i := 0; i < blockCount; i++ {
-		head, err := wal.NewBlock(uuid.New(), tenantID, "")
-		assert.NoError(t, err)
-
-		for j := 0; j < recordCount; j++ {
-			// Use i and j to ensure unique ids
-			body := make([]byte, 1024)
-			rand.Read(body)
-
-			err = head.Write(
-				makeTraceID(i, j),
-				body)
-			//[]byte{0x01, 0x02, 0x03})
-			assert.NoError(t, err, "unexpected error writing rec")
-		}
-
-		b, err := w.CompleteBlock(head, &mockSharder{})
-		assert.NoError(t, err)
-		blocks = append(blocks, b)
+		block, err := encodingtest.WriteBlock(context.Background(), w.(*readerWriter).w, encodingtest.BlockOptions{
+			Seed:           int64(i),
+			TenantID:       tenantID,
+			Objects:        recordCount,
+			MinObjectBytes: 1024,
+			StartTime:      time.Now(),
+		})
+		require.NoError(t, err)
+		blocks = append(blocks, block)
 	}
 
 	return blocks
@@ -554,7 +805,7 @@ func BenchmarkCompaction(b *testing.B) {
 	blocks := cutTestBlocks(b, w, testTenantID, 8, n)
 	metas := make([]*backend.BlockMeta, 0)
 	for _, b := range blocks {
-		metas = append(metas, b.BlockMeta())
+		metas = append(metas, b.Meta)
 	}
 
 	b.ResetTimer()
@@ -562,3 +813,87 @@ func BenchmarkCompaction(b *testing.B) {
 	err = rw.compact(metas, testTenantID)
 	require.NoError(b, err)
 }
+
+func TestSizeLimitedObjectCombiner(t *testing.T) {
+	id := []byte{0x01, 0x02}
+	objA := mustMarshalTrace(t, test.MakeTraceWithSpanCount(10, 10, id))
+	objB := mustMarshalTrace(t, test.MakeTraceWithSpanCount(5, 10, id))
+
+	combined, wasCombined := model.ObjectCombiner.Combine(model.CurrentEncoding, objA, objB)
+	require.True(t, wasCombined)
+	require.Greater(t, len(combined), len(objA))
+
+	tests := []struct {
+		name      string
+		policy    string
+		maxBytes  int
+		expected  []byte // only checked if set
+		truncated bool
+	}{
+		{
+			name:     "under the limit",
+			policy:   MaxTraceBytesPolicyTruncate,
+			maxBytes: len(combined),
+		},
+		{
+			name:      "truncate",
+			policy:    MaxTraceBytesPolicyTruncate,
+			maxBytes:  len(objA),
+			truncated: true,
+		},
+		{
+			name:     "keep largest",
+			policy:   MaxTraceBytesPolicyKeepLargest,
+			maxBytes: len(objA),
+			expected: objA,
+		},
+		{
+			name:      "keep largest over the limit",
+			policy:    MaxTraceBytesPolicyKeepLargest,
+			maxBytes:  len(objB),
+			truncated: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			combiner := sizeLimitedObjectCombiner{
+				inner:    model.ObjectCombiner,
+				logger:   log.NewNopLogger(),
+				tenantID: testTenantID,
+				maxBytes: tc.maxBytes,
+				policy:   tc.policy,
+			}
+
+			for _, objs := range [][][]byte{{objA, objB}, {objB, objA}} {
+				actual, _ := combiner.Combine(model.CurrentEncoding, objs...)
+				assert.LessOrEqual(t, len(actual), tc.maxBytes)
+				if tc.expected != nil {
+					assert.Equal(t, tc.expected, actual)
+				}
+
+				trace, err := model.Unmarshal(actual, model.CurrentEncoding)
+				require.NoError(t, err)
+				require.NotEmpty(t, trace.Batches)
+				for _, b := range trace.Batches {
+					truncated := false
+					if b.Resource != nil {
+						for _, attr := range b.Resource.Attributes {
+							truncated = truncated || attr.Key == model.TruncatedAttribute
+						}
+					}
+					assert.Equal(t, tc.truncated, truncated)
+				}
+			}
+		})
+	}
+}
+
+func mustMarshalTrace(t *testing.T, trace *tempopb.Trace) []byte {
+	b, err := proto.Marshal(trace)
+	require.NoError(t, err)
+
+	b, err = proto.Marshal(&tempopb.TraceBytes{Traces: [][]byte{b}})
+	require.NoError(t, err)
+	return b
+}
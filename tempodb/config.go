@@ -11,8 +11,10 @@ import (
 	"github.com/grafana/tempo/tempodb/backend/cache/redis"
 	"github.com/grafana/tempo/tempodb/backend/gcs"
 	"github.com/grafana/tempo/tempodb/backend/local"
+	"github.com/grafana/tempo/tempodb/backend/ratelimit"
 	"github.com/grafana/tempo/tempodb/backend/s3"
 	"github.com/grafana/tempo/tempodb/encoding"
+	"github.com/grafana/tempo/tempodb/notifications"
 	"github.com/grafana/tempo/tempodb/pool"
 	"github.com/grafana/tempo/tempodb/wal"
 )
@@ -24,6 +26,13 @@ const (
 	DefaultTenantIndexBuilders      = 2
 )
 
+const (
+	// MaxTraceBytesPolicyTruncate drops the latest spans of a combined trace until it fits the max bytes per trace.
+	MaxTraceBytesPolicyTruncate = "truncate"
+	// MaxTraceBytesPolicyKeepLargest skips combining a trace that would not fit and keeps its largest replica.
+	MaxTraceBytesPolicyKeepLargest = "keep_largest"
+)
+
 // Config holds the entirety of tempodb configuration
 // Defaults are in modules/storage/config.go
 type Config struct {
@@ -50,6 +59,26 @@ type Config struct {
 	BackgroundCache         *cortex_cache.BackgroundConfig `yaml:"background_cache"`
 	Memcached               *memcached.Config              `yaml:"memcached"`
 	Redis                   *redis.Config                  `yaml:"redis"`
+
+	// limits the rate of the requests of the process to the backend, shared by all of its callers
+	BackendRateLimit ratelimit.Config `yaml:"backend_rate_limit"`
+
+	// identical concurrent reads of objects and ranges up to this size share a single backend request, 0 disables it
+	CoalesceReadsMaxBytes int `yaml:"coalesce_reads_max_bytes"`
+
+	// Find stops searching new blocks once less than this fraction of the time between its start and the deadline of
+	// its context remains, so the blocks already found are returned before the query times out. 0 disables it
+	FindDeadlineReserve float64 `yaml:"find_deadline_reserve"`
+
+	// tempodb_query_bytes_read_total has series for this many tenants, the bytes read for the tenants beyond it are
+	// counted as the other tenant. 0 doesn't limit the tenants
+	QueryBytesReadMaxTenants int `yaml:"query_bytes_read_max_tenants"`
+
+	// downsampled copies of the traces kept after their blocks are deleted
+	Skeleton SkeletonConfig `yaml:"skeleton"`
+
+	// block lifecycle notifications
+	Notifications *notifications.Config `yaml:"notifications"`
 }
 
 // CompactorConfig contains compaction configuration options
@@ -63,6 +92,34 @@ type CompactorConfig struct {
 	CompactedBlockRetention time.Duration `yaml:"compacted_block_retention"`
 	RetentionConcurrency    uint          `yaml:"retention_concurrency"`
 	IteratorBufferSize      int           `yaml:"iterator_buffer_size"`
+	MaxTraceBytesPolicy     string        `yaml:"max_trace_bytes_policy"`
+
+	Scrubber ScrubberConfig `yaml:"scrubber"`
+}
+
+// ScrubberConfig controls the background verification of backend blocks.
+type ScrubberConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Number of randomly chosen blocks verified per tenant every hour.
+	BlocksPerTenantPerHour int `yaml:"blocks_per_tenant_per_hour"`
+	// Maximum bytes read from the backend every hour. Remaining blocks are skipped until the next hour.
+	MaxBytesPerHour uint64 `yaml:"max_bytes_per_hour"`
+	// Maximum backend requests per second.
+	MaxRequestsPerSecond float64 `yaml:"max_requests_per_second"`
+	// Number of data pages per block to decode and check against the index and blooms. 0 disables it.
+	SampleDataPages int `yaml:"sample_data_pages"`
+	// If true corrupt blocks are marked compacted so they are no longer queried or compacted and are
+	// eventually deleted by retention.
+	QuarantineCorruptBlocks bool `yaml:"quarantine_corrupt_blocks"`
+}
+
+// SkeletonConfig controls the skeletons of traces. A skeleton holds the root spans of a trace, their children and its
+// error spans and is served by trace by id queries once the trace itself was deleted by retention.
+type SkeletonConfig struct {
+	// If true a skeleton block is written along with every block completed or compacted.
+	Enabled bool `yaml:"enabled"`
+	// Skeleton blocks are deleted once they are older than this.
+	Retention time.Duration `yaml:"retention"`
 }
 
 func validateConfig(cfg *Config) error {
@@ -79,5 +136,13 @@ func validateConfig(cfg *Config) error {
 		return fmt.Errorf("block config validation failed: %w", err)
 	}
 
+	if cfg.Local != nil && (cfg.Local.MaxDiskUtilization < 0 || cfg.Local.MaxDiskUtilization > 1) {
+		return errors.New("local max_disk_utilization must be between 0 and 1")
+	}
+
+	if err := cfg.BackendRateLimit.Validate(); err != nil {
+		return err
+	}
+
 	return nil
 }
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io"
 
 	"github.com/opentracing/opentracing-go"
 	willf_bloom "github.com/willf/bloom"
@@ -37,6 +38,13 @@ func NewBackendBlock(meta *backend.BlockMeta, r backend.Reader) (*BackendBlock,
 
 // Find searches a block for the ID and returns an object if found.
 func (b *BackendBlock) Find(ctx context.Context, id common.ID) ([]byte, error) {
+	obj, _, err := b.FindWithBloomResult(ctx, id)
+	return obj, err
+}
+
+// FindWithBloomResult is like Find but also returns whether the bloom filter of the block matched the ID. A match
+// that doesn't find an object is a false positive of the bloom filter.
+func (b *BackendBlock) FindWithBloomResult(ctx context.Context, id common.ID) ([]byte, bool, error) {
 	var err error
 	span, ctx := opentracing.StartSpanFromContext(ctx, "BackendBlock.Find")
 	defer func() {
@@ -55,29 +63,37 @@ func (b *BackendBlock) Find(ctx context.Context, id common.ID) ([]byte, error) {
 	nameBloom := bloomName(shardKey)
 	bloomBytes, err := b.reader.Read(ctx, nameBloom, blockID, tenantID, true)
 	if err != nil {
-		return nil, fmt.Errorf("error retrieving bloom (%s, %s): %w", b.meta.TenantID, b.meta.BlockID, err)
+		return nil, false, fmt.Errorf("error retrieving bloom (%s, %s): %w", b.meta.TenantID, b.meta.BlockID, err)
 	}
 
 	filter := &willf_bloom.BloomFilter{}
 	_, err = filter.ReadFrom(bytes.NewReader(bloomBytes))
 	if err != nil {
-		return nil, fmt.Errorf("error parsing bloom (%s, %s): %w", b.meta.TenantID, b.meta.BlockID, err)
+		return nil, false, fmt.Errorf("error parsing bloom (%s, %s): %w", b.meta.TenantID, b.meta.BlockID, err)
 	}
 
 	if !filter.Test(id) {
-		return nil, nil
+		return nil, false, nil
+	}
+
+	if b.meta.NoIndex {
+		objectBytes, err := b.scan(ctx, id)
+		if err != nil {
+			return nil, true, fmt.Errorf("error scanning block (%s, %s): %w", b.meta.TenantID, b.meta.BlockID, err)
+		}
+		return objectBytes, true, nil
 	}
 
 	indexReaderAt := backend.NewContextReader(b.meta, nameIndex, b.reader, false)
 	indexReader, err := b.encoding.NewIndexReader(indexReaderAt, int(b.meta.IndexPageSize), int(b.meta.TotalRecords))
 	if err != nil {
-		return nil, fmt.Errorf("error building index reader (%s, %s): %w", b.meta.TenantID, b.meta.BlockID, err)
+		return nil, true, fmt.Errorf("error building index reader (%s, %s): %w", b.meta.TenantID, b.meta.BlockID, err)
 	}
 
 	ra := backend.NewContextReader(b.meta, nameObjects, b.reader, false)
 	dataReader, err := b.encoding.NewDataReader(ra, b.meta.Encoding)
 	if err != nil {
-		return nil, fmt.Errorf("error building page reader (%s, %s): %w", b.meta.TenantID, b.meta.BlockID, err)
+		return nil, true, fmt.Errorf("error building page reader (%s, %s): %w", b.meta.TenantID, b.meta.BlockID, err)
 	}
 	defer dataReader.Close()
 
@@ -86,14 +102,42 @@ func (b *BackendBlock) Find(ctx context.Context, id common.ID) ([]byte, error) {
 	objectBytes, err := finder.Find(ctx, id)
 
 	if err != nil {
-		return nil, fmt.Errorf("error using pageFinder (%s, %s): %w", b.meta.TenantID, b.meta.BlockID, err)
+		return nil, true, fmt.Errorf("error using pageFinder (%s, %s): %w", b.meta.TenantID, b.meta.BlockID, err)
 	}
 
-	return objectBytes, nil
+	return objectBytes, true, nil
+}
+
+// scan searches the data of a block without an index for the ID.
+func (b *BackendBlock) scan(ctx context.Context, id common.ID) ([]byte, error) {
+	iter := newScanIterator(b.meta, b.reader, b.encoding)
+	defer iter.Close()
+
+	for {
+		foundID, obj, err := iter.Next(ctx)
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// objects are sorted by id
+		switch bytes.Compare(foundID, id) {
+		case 0:
+			return obj, nil
+		case 1:
+			return nil, nil
+		}
+	}
 }
 
 // Iterator returns an Iterator that iterates over the objects in the block from the backend
 func (b *BackendBlock) Iterator(chunkSizeBytes uint32) (Iterator, error) {
+	if b.meta.NoIndex {
+		return newScanIterator(b.meta, b.reader, b.encoding), nil
+	}
+
 	// read index
 	ra := backend.NewContextReader(b.meta, nameObjects, b.reader, false)
 	dataReader, err := b.encoding.NewDataReader(ra, b.meta.Encoding)
@@ -110,6 +154,10 @@ func (b *BackendBlock) Iterator(chunkSizeBytes uint32) (Iterator, error) {
 }
 
 func (b *BackendBlock) NewIndexReader() (common.IndexReader, error) {
+	if b.meta.NoIndex {
+		return nil, fmt.Errorf("block has no index (%s, %s)", b.meta.TenantID, b.meta.BlockID)
+	}
+
 	indexReaderAt := backend.NewContextReader(b.meta, nameIndex, b.reader, false)
 	reader, err := b.encoding.NewIndexReader(indexReaderAt, int(b.meta.IndexPageSize), int(b.meta.TotalRecords))
 	if err != nil {
@@ -0,0 +1,208 @@
+package encoding
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+
+	willf_bloom "github.com/willf/bloom"
+
+	"github.com/grafana/tempo/tempodb/backend"
+	"github.com/grafana/tempo/tempodb/encoding/common"
+)
+
+// Verify checks that the meta, bloom filters and index of the block are consistent with each other. If
+// sampleDataPages > 0 up to that many randomly chosen data pages are also decoded and every object in them is
+// checked against the index and the bloom filters. The data of blocks without an index is always decoded and
+// checked against the meta and the bloom filters.
+func (b *BackendBlock) Verify(ctx context.Context, sampleDataPages int) error {
+	m := b.meta
+
+	err := verifyMeta(m)
+	if err != nil {
+		return err
+	}
+
+	blooms := make([]*willf_bloom.BloomFilter, m.BloomShardCount)
+	for i := range blooms {
+		bloomBytes, err := b.reader.Read(ctx, bloomName(i), m.BlockID, m.TenantID, false)
+		if err != nil {
+			return fmt.Errorf("error retrieving bloom %d: %w", i, err)
+		}
+
+		filter := &willf_bloom.BloomFilter{}
+		_, err = filter.ReadFrom(bytes.NewReader(bloomBytes))
+		if err != nil {
+			return fmt.Errorf("error parsing bloom %d: %w", i, err)
+		}
+		blooms[i] = filter
+	}
+
+	if m.NoIndex {
+		return b.verifyData(ctx, blooms)
+	}
+
+	indexReader, err := b.NewIndexReader()
+	if err != nil {
+		return err
+	}
+
+	// every record points to a data page and the pages are written back to back in id order
+	records := make([]common.Record, 0, m.TotalRecords)
+	var nextStart uint64
+	for i := 0; i < int(m.TotalRecords); i++ {
+		record, err := indexReader.At(ctx, i)
+		if err != nil {
+			return fmt.Errorf("error reading index record %d: %w", i, err)
+		}
+		if record == nil {
+			return fmt.Errorf("index record %d missing", i)
+		}
+		if record.Start != nextStart {
+			return fmt.Errorf("index record %d starts at %d, expected %d", i, record.Start, nextStart)
+		}
+		if len(records) > 0 && bytes.Compare(record.ID, records[len(records)-1].ID) < 0 {
+			return fmt.Errorf("index record %d is out of order", i)
+		}
+
+		nextStart = record.Start + uint64(record.Length)
+		records = append(records, *record)
+	}
+
+	if nextStart != m.Size {
+		return fmt.Errorf("index covers %d bytes but meta size is %d", nextStart, m.Size)
+	}
+	if len(m.MaxID) > 0 && !bytes.Equal(records[len(records)-1].ID, m.MaxID) {
+		return fmt.Errorf("last index record id %x does not match meta max id %x", []byte(records[len(records)-1].ID), m.MaxID)
+	}
+
+	if sampleDataPages <= 0 {
+		return nil
+	}
+
+	dataReader, err := b.encoding.NewDataReader(backend.NewContextReader(m, nameObjects, b.reader, false), m.Encoding)
+	if err != nil {
+		return fmt.Errorf("error building data reader: %w", err)
+	}
+	defer dataReader.Close()
+
+	objectRW := b.encoding.NewObjectReaderWriter()
+	sampled := rand.Perm(len(records))
+	if len(sampled) > sampleDataPages {
+		sampled = sampled[:sampleDataPages]
+	}
+
+	for _, i := range sampled {
+		pages, _, err := dataReader.Read(ctx, records[i:i+1], nil, nil)
+		if err != nil {
+			return fmt.Errorf("error reading data page %d: %w", i, err)
+		}
+
+		var minID common.ID
+		if i > 0 {
+			minID = records[i-1].ID
+		}
+
+		buffer := pages[0]
+		for {
+			var id common.ID
+			buffer, id, _, err = objectRW.UnmarshalAndAdvanceBuffer(buffer)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return fmt.Errorf("error decoding data page %d: %w", i, err)
+			}
+
+			if bytes.Compare(id, records[i].ID) > 0 || bytes.Compare(id, minID) < 0 {
+				return fmt.Errorf("object %x in data page %d is outside of its index range", []byte(id), i)
+			}
+			if !blooms[common.ShardKeyForTraceID(id, len(blooms))].Test(id) {
+				return fmt.Errorf("object %x in data page %d is missing from the bloom", []byte(id), i)
+			}
+		}
+	}
+
+	return nil
+}
+
+// verifyData checks that the data pages of a block without an index hold the objects of the meta in id order and
+// that every object is in the bloom filters.
+func (b *BackendBlock) verifyData(ctx context.Context, blooms []*willf_bloom.BloomFilter) error {
+	m := b.meta
+
+	data, err := b.reader.Read(ctx, nameObjects, m.BlockID, m.TenantID, false)
+	if err != nil {
+		return fmt.Errorf("error reading data: %w", err)
+	}
+	if uint64(len(data)) != m.Size {
+		return fmt.Errorf("data is %d bytes but meta size is %d", len(data), m.Size)
+	}
+
+	dataReader, err := b.encoding.NewDataReader(backend.NewContextReaderWithAllReader(bytes.NewReader(data)), m.Encoding)
+	if err != nil {
+		return fmt.Errorf("error building data reader: %w", err)
+	}
+	defer dataReader.Close()
+
+	objectRW := b.encoding.NewObjectReaderWriter()
+	var buffer []byte
+	var lastID common.ID
+	objects := 0
+	for page := 0; ; page++ {
+		buffer, _, err = dataReader.NextPage(buffer)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("error reading data page %d: %w", page, err)
+		}
+
+		remaining := buffer
+		for {
+			var id common.ID
+			remaining, id, _, err = objectRW.UnmarshalAndAdvanceBuffer(remaining)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return fmt.Errorf("error decoding data page %d: %w", page, err)
+			}
+
+			if bytes.Compare(id, lastID) < 0 {
+				return fmt.Errorf("object %x in data page %d is out of order", []byte(id), page)
+			}
+			if !blooms[common.ShardKeyForTraceID(id, len(blooms))].Test(id) {
+				return fmt.Errorf("object %x in data page %d is missing from the bloom", []byte(id), page)
+			}
+			lastID = append(lastID[:0], id...)
+			objects++
+		}
+	}
+
+	if objects != m.TotalObjects {
+		return fmt.Errorf("data has %d objects but meta has %d", objects, m.TotalObjects)
+	}
+	if len(m.MaxID) > 0 && !bytes.Equal(lastID, m.MaxID) {
+		return fmt.Errorf("last object id %x does not match meta max id %x", []byte(lastID), m.MaxID)
+	}
+
+	return nil
+}
+
+func verifyMeta(m *backend.BlockMeta) error {
+	switch {
+	case m.TotalRecords == 0 && !m.NoIndex:
+		return fmt.Errorf("meta has no index records")
+	case m.BloomShardCount == 0:
+		return fmt.Errorf("meta has no bloom shards")
+	case m.IndexPageSize == 0 && !m.NoIndex:
+		return fmt.Errorf("meta has no index page size")
+	case m.EndTime.Before(m.StartTime):
+		return fmt.Errorf("meta end time %s is before start time %s", m.EndTime, m.StartTime)
+	}
+
+	return nil
+}
@@ -24,7 +24,8 @@ func bloomName(shard int) string {
 	return nameBloomPrefix + strconv.Itoa(shard)
 }
 
-// writeBlockMeta writes the bloom filter, meta and index to the passed in backend.Writer
+// writeBlockMeta writes the bloom filter, meta and index to the passed in backend.Writer. The index is not written
+// for blocks without one.
 func writeBlockMeta(ctx context.Context, w backend.Writer, meta *backend.BlockMeta, indexBytes []byte, b *common.ShardedBloomFilter) error {
 	blooms, err := b.Marshal()
 	if err != nil {
@@ -32,9 +33,11 @@ func writeBlockMeta(ctx context.Context, w backend.Writer, meta *backend.BlockMe
 	}
 
 	// index
-	err = w.Write(ctx, nameIndex, meta.BlockID, meta.TenantID, indexBytes, false)
-	if err != nil {
-		return fmt.Errorf("unexpected error writing index %w", err)
+	if !meta.NoIndex {
+		err = w.Write(ctx, nameIndex, meta.BlockID, meta.TenantID, indexBytes, false)
+		if err != nil {
+			return fmt.Errorf("unexpected error writing index %w", err)
+		}
 	}
 
 	// bloom
@@ -61,6 +64,7 @@ func appendBlockData(ctx context.Context, w backend.Writer, meta *backend.BlockM
 }
 
 // CopyBlock copies a block from one backend to another.   It is done at a low level, all encoding/formatting is preserved.
+// The skeleton of the block is copied along with it.
 func CopyBlock(ctx context.Context, meta *backend.BlockMeta, src backend.Reader, dest backend.Writer) error {
 	blockID := meta.BlockID
 	tenantID := meta.TenantID
@@ -101,9 +105,32 @@ func CopyBlock(ctx context.Context, meta *backend.BlockMeta, src backend.Reader,
 	}
 
 	// Index
-	err = copyStream(nameIndex)
-	if err != nil {
-		return err
+	if !meta.NoIndex {
+		err = copyStream(nameIndex)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Lineage
+	if meta.CompactedFromCount > 0 {
+		err = copy(nameLineage)
+		if err != nil && errors.Cause(err) != backend.ErrDoesNotExist {
+			return err
+		}
+	}
+
+	// Skeleton
+	if meta.HasSkeleton {
+		skeletonMeta, err := src.BlockMeta(ctx, blockID, backend.SkeletonTenantID(tenantID))
+		if err != nil {
+			return errors.Wrap(err, "error reading skeleton meta")
+		}
+
+		err = CopyBlock(ctx, skeletonMeta, src, dest)
+		if err != nil {
+			return errors.Wrap(err, "error copying skeleton")
+		}
 	}
 
 	// Meta
@@ -14,7 +14,8 @@ import (
 const (
 	legacyShardCount = 10
 	minShardCount    = 1
-	maxShardCount    = 1000
+	// MaxShardCount is the maximum number of shards of a bloom filter
+	MaxShardCount = 1000
 )
 
 type ShardedBloomFilter struct {
@@ -34,8 +35,8 @@ func NewBloom(fp float64, shardSize, estimatedObjects uint) *ShardedBloomFilter
 		shardCount = minShardCount
 	}
 
-	if shardCount > maxShardCount {
-		shardCount = maxShardCount
+	if shardCount > MaxShardCount {
+		shardCount = MaxShardCount
 		level.Warn(cortex_util.Logger).Log("msg", "required bloom filter shard count exceeded max. consider increasing bloom_filter_shard_size_bytes")
 	}
 
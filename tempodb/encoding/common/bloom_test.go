@@ -130,7 +130,7 @@ func TestBloomShardCount(t *testing.T) {
 			bloomFP:          0.01,
 			shardSize:        1,
 			estimatedObjects: 100000,
-			expectedShards:   maxShardCount,
+			expectedShards:   MaxShardCount,
 		},
 		{
 			name:             "too few shards",
@@ -0,0 +1,32 @@
+package common
+
+import (
+	"bytes"
+	"sync"
+)
+
+// maxPooledBufferSize is the largest buffer returned to the pool. Larger buffers are left to the gc so a single
+// oversized block does not pin its memory for the lifetime of the process.
+const maxPooledBufferSize = 64 * 1024 * 1024
+
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		return &bytes.Buffer{}
+	},
+}
+
+// GetBuffer returns an empty buffer from the pool. Buffers are shared by the block writers so that concurrent
+// completions and compactions reuse memory instead of each growing their own.
+func GetBuffer() *bytes.Buffer {
+	return bufferPool.Get().(*bytes.Buffer)
+}
+
+// PutBuffer resets the buffer and returns it to the pool. The buffer must not be used afterwards.
+func PutBuffer(b *bytes.Buffer) {
+	if b == nil || b.Cap() > maxPooledBufferSize {
+		return
+	}
+
+	b.Reset()
+	bufferPool.Put(b)
+}
@@ -10,9 +10,13 @@ import (
 type BlockConfig struct {
 	IndexDownsampleBytes int              `yaml:"index_downsample_bytes"`
 	IndexPageSizeBytes   int              `yaml:"index_page_size_bytes"`
+	IndexMinObjects      int              `yaml:"index_min_objects"`
 	BloomFP              float64          `yaml:"bloom_filter_false_positive"`
 	BloomShardSizeBytes  int              `yaml:"bloom_filter_shard_size_bytes"`
 	Encoding             backend.Encoding `yaml:"encoding"`
+	// DataPageChecksums writes the checksum of every data page, blocks written with it can't be read by previous
+	// releases
+	DataPageChecksums bool `yaml:"data_page_checksums"`
 }
 
 // ValidateConfig returns true if the config is valid
@@ -25,6 +29,10 @@ func ValidateConfig(b *BlockConfig) error {
 		return fmt.Errorf("Positive index page size required")
 	}
 
+	if b.IndexMinObjects < 0 {
+		return fmt.Errorf("Non-negative index min objects required")
+	}
+
 	if b.BloomFP <= 0.0 {
 		return fmt.Errorf("invalid bloom filter fp rate %v", b.BloomFP)
 	}
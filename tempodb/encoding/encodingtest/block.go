@@ -0,0 +1,241 @@
+// Package encodingtest generates complete blocks for tests of tempodb and the tools built on it.
+package encodingtest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/grafana/tempo/pkg/model"
+	"github.com/grafana/tempo/pkg/tempopb"
+	v1_common "github.com/grafana/tempo/pkg/tempopb/common/v1"
+	v1_trace "github.com/grafana/tempo/pkg/tempopb/trace/v1"
+	"github.com/grafana/tempo/tempodb/backend"
+	"github.com/grafana/tempo/tempodb/encoding"
+)
+
+// BlockOptions describe a generated block. The zero value of every field is replaced by the default documented
+// on it, so tests only set what they care about.
+type BlockOptions struct {
+	// Seed makes the generated ids and objects, and the block id if BlockID is not set, reproducible.
+	Seed int64
+
+	TenantID string    // default "fake"
+	BlockID  uuid.UUID // default generated from Seed
+	Version  string    // default the latest version. only the latest version can be written.
+
+	// Encoding is the compression of the data pages and DataEncoding the model encoding of the objects.
+	Encoding     backend.Encoding // default none
+	DataEncoding string           // default model.TracePBEncoding
+
+	// Objects is the number of objects written, including duplicates. Object sizes are spread uniformly between
+	// MinObjectBytes and MaxObjectBytes. Objects are never smaller than the single span trace they hold.
+	Objects        int // default 100
+	MinObjectBytes int // default 100
+	MaxObjectBytes int // default MinObjectBytes
+
+	// DuplicateRatio is the share of Objects that repeat the id and bytes of another object in the block.
+	DuplicateRatio float64
+
+	BloomFP              float64 // default 0.01
+	BloomShardSizeBytes  int     // default 100KiB
+	IndexDownsampleBytes int     // default 1000
+	IndexPageSizeBytes   int     // default 1000
+
+	StartTime time.Time // default 2021-01-01T00:00:00Z
+	EndTime   time.Time // default StartTime plus one hour
+}
+
+// Block is a block written by WriteBlock along with what a reader is expected to find in it.
+type Block struct {
+	Meta *backend.BlockMeta
+
+	// IDs are the unique ids of the block in ascending order, and Objects the bytes stored for each of them.
+	IDs     [][]byte
+	Objects map[string][]byte
+}
+
+// Object returns the bytes expected for id, or nil if the id is not in the block.
+func (b *Block) Object(id []byte) []byte {
+	return b.Objects[string(id)]
+}
+
+// WriteBlock generates a complete, valid block described by opts and writes it to w. Objects are marshalled
+// tempopb.Traces in opts.DataEncoding so the block can be read, combined and compacted like any other.
+func WriteBlock(ctx context.Context, w backend.Writer, opts BlockOptions) (*Block, error) {
+	opts = applyDefaults(opts)
+	rng := rand.New(rand.NewSource(opts.Seed))
+
+	if opts.BlockID == uuid.Nil {
+		opts.BlockID = randomUUID(rng)
+	}
+
+	v, err := encoding.FromVersion(opts.Version)
+	if err != nil {
+		return nil, err
+	}
+	if latest := encoding.LatestEncoding().Version(); v.Version() != latest {
+		return nil, fmt.Errorf("blocks can only be written in the latest version %s, not %s", latest, v.Version())
+	}
+
+	duplicates := int(float64(opts.Objects) * opts.DuplicateRatio)
+	if duplicates >= opts.Objects && opts.Objects > 0 {
+		duplicates = opts.Objects - 1
+	}
+
+	type object struct {
+		id   []byte
+		data []byte
+	}
+	objects := make([]object, 0, opts.Objects)
+	expected := &Block{
+		Objects: map[string][]byte{},
+	}
+	for i := 0; i < opts.Objects-duplicates; i++ {
+		id := make([]byte, 16)
+		_, _ = rng.Read(id)
+
+		size := opts.MinObjectBytes
+		if opts.MaxObjectBytes > opts.MinObjectBytes {
+			size += rng.Intn(opts.MaxObjectBytes - opts.MinObjectBytes + 1)
+		}
+
+		data, err := makeObject(rng, id, size, opts.DataEncoding)
+		if err != nil {
+			return nil, err
+		}
+
+		objects = append(objects, object{id: id, data: data})
+		expected.IDs = append(expected.IDs, id)
+		expected.Objects[string(id)] = data
+	}
+	for i := 0; i < duplicates; i++ {
+		objects = append(objects, objects[rng.Intn(opts.Objects-duplicates)])
+	}
+
+	sort.SliceStable(objects, func(i, j int) bool { return bytes.Compare(objects[i].id, objects[j].id) == -1 })
+	sort.Slice(expected.IDs, func(i, j int) bool { return bytes.Compare(expected.IDs[i], expected.IDs[j]) == -1 })
+
+	cfg := &encoding.BlockConfig{
+		IndexDownsampleBytes: opts.IndexDownsampleBytes,
+		IndexPageSizeBytes:   opts.IndexPageSizeBytes,
+		BloomFP:              opts.BloomFP,
+		BloomShardSizeBytes:  opts.BloomShardSizeBytes,
+		Encoding:             opts.Encoding,
+	}
+
+	inMeta := backend.NewBlockMeta(opts.TenantID, opts.BlockID, v.Version(), opts.Encoding, opts.DataEncoding)
+	inMeta.StartTime = opts.StartTime
+	inMeta.EndTime = opts.EndTime
+
+	block, err := encoding.NewStreamingBlock(cfg, opts.BlockID, opts.TenantID, []*backend.BlockMeta{inMeta}, inMeta.DataEncoding, len(objects))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, o := range objects {
+		err = block.AddObject(o.id, o.data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	_, err = block.Complete(ctx, nil, w)
+	if err != nil {
+		return nil, err
+	}
+
+	expected.Meta = block.BlockMeta()
+	return expected, nil
+}
+
+func applyDefaults(opts BlockOptions) BlockOptions {
+	if opts.TenantID == "" {
+		opts.TenantID = "fake"
+	}
+	if opts.Version == "" {
+		opts.Version = encoding.LatestEncoding().Version()
+	}
+	if opts.Objects == 0 {
+		opts.Objects = 100
+	}
+	if opts.MinObjectBytes == 0 {
+		opts.MinObjectBytes = 100
+	}
+	if opts.MaxObjectBytes == 0 {
+		opts.MaxObjectBytes = opts.MinObjectBytes
+	}
+	if opts.BloomFP == 0 {
+		opts.BloomFP = 0.01
+	}
+	if opts.BloomShardSizeBytes == 0 {
+		opts.BloomShardSizeBytes = 100 * 1024
+	}
+	if opts.IndexDownsampleBytes == 0 {
+		opts.IndexDownsampleBytes = 1000
+	}
+	if opts.IndexPageSizeBytes == 0 {
+		opts.IndexPageSizeBytes = 1000
+	}
+	if opts.StartTime.IsZero() {
+		opts.StartTime = time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	if opts.EndTime.IsZero() {
+		opts.EndTime = opts.StartTime.Add(time.Hour)
+	}
+	return opts
+}
+
+// makeObject returns a trace with a single span padded by an attribute to roughly size bytes, marshalled in
+// dataEncoding.
+func makeObject(rng *rand.Rand, id []byte, size int, dataEncoding string) ([]byte, error) {
+	span := &v1_trace.Span{
+		TraceId: id,
+		SpanId:  make([]byte, 8),
+		Name:    "test",
+	}
+	_, _ = rng.Read(span.SpanId)
+
+	trace := &tempopb.Trace{
+		Batches: []*v1_trace.ResourceSpans{{
+			InstrumentationLibrarySpans: []*v1_trace.InstrumentationLibrarySpans{{
+				Spans: []*v1_trace.Span{span},
+			}},
+		}},
+	}
+
+	if padding := size - trace.Size(); padding > 0 {
+		value := make([]byte, padding)
+		for i := range value {
+			value[i] = byte('a' + rng.Intn(26))
+		}
+		span.Attributes = []*v1_common.KeyValue{{
+			Key:   "padding",
+			Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_StringValue{StringValue: string(value)}},
+		}}
+	}
+
+	b, err := trace.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	switch dataEncoding {
+	case model.TracePBEncoding:
+		return b, nil
+	case model.CurrentEncoding:
+		return (&tempopb.TraceBytes{Traces: [][]byte{b}}).Marshal()
+	}
+	return nil, fmt.Errorf("unknown data encoding %s", dataEncoding)
+}
+
+func randomUUID(rng *rand.Rand) uuid.UUID {
+	var id uuid.UUID
+	_, _ = rng.Read(id[:])
+	return id
+}
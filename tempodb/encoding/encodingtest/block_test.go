@@ -0,0 +1,84 @@
+package encodingtest
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tempo/pkg/model"
+	"github.com/grafana/tempo/tempodb/backend"
+	"github.com/grafana/tempo/tempodb/backend/local"
+	"github.com/grafana/tempo/tempodb/encoding"
+)
+
+func TestWriteBlock(t *testing.T) {
+	for name, dataEncoding := range map[string]string{"tracepb": model.TracePBEncoding, "current": model.CurrentEncoding} {
+		t.Run(name, func(t *testing.T) {
+			rawR, rawW, _, err := local.New(&local.Config{Path: t.TempDir()})
+			require.NoError(t, err)
+			r, w := backend.NewReader(rawR), backend.NewWriter(rawW)
+
+			opts := BlockOptions{
+				Seed:           42,
+				Encoding:       backend.EncSnappy,
+				DataEncoding:   dataEncoding,
+				Objects:        200,
+				MinObjectBytes: 100,
+				MaxObjectBytes: 2000,
+				DuplicateRatio: 0.1,
+			}
+			block, err := WriteBlock(context.Background(), w, opts)
+			require.NoError(t, err)
+
+			assert.Len(t, block.IDs, 180)
+			assert.Equal(t, 200, block.Meta.TotalObjects)
+			assert.Equal(t, dataEncoding, block.Meta.DataEncoding)
+			assert.Equal(t, backend.EncSnappy, block.Meta.Encoding)
+
+			meta, err := r.BlockMeta(context.Background(), block.Meta.BlockID, block.Meta.TenantID)
+			require.NoError(t, err)
+			backendBlock, err := encoding.NewBackendBlock(meta, r)
+			require.NoError(t, err)
+
+			for _, id := range block.IDs {
+				obj, err := backendBlock.Find(context.Background(), id)
+				require.NoError(t, err)
+				assert.Equal(t, block.Object(id), obj)
+
+				_, err = model.Unmarshal(obj, dataEncoding)
+				require.NoError(t, err)
+			}
+
+			iter, err := backendBlock.Iterator(10 * 1024)
+			require.NoError(t, err)
+			count := 0
+			for {
+				id, obj, err := iter.Next(context.Background())
+				if err == io.EOF {
+					break
+				}
+				require.NoError(t, err)
+				assert.Equal(t, block.Object(id), obj)
+				count++
+			}
+			assert.Equal(t, 200, count)
+
+			// the same seed generates the same block
+			again, err := WriteBlock(context.Background(), backend.NewWriter(rawW), opts)
+			require.NoError(t, err)
+			assert.Equal(t, block.Meta.BlockID, again.Meta.BlockID)
+			assert.Equal(t, block.Objects, again.Objects)
+		})
+	}
+}
+
+func TestWriteBlockVersion(t *testing.T) {
+	_, rawW, _, err := local.New(&local.Config{Path: t.TempDir()})
+	require.NoError(t, err)
+
+	_, err = WriteBlock(context.Background(), backend.NewWriter(rawW), BlockOptions{Version: "v0"})
+	assert.Error(t, err)
+}
@@ -47,6 +47,11 @@ func (f *pagedFinder) Find(ctx context.Context, id common.ID) ([]byte, error) {
 	}
 
 	for {
+		// stop reading records once the query is cancelled or timed out
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
 		bytesOne, err := f.findOne(ctx, id, *record)
 		if err != nil {
 			return nil, err
@@ -95,6 +100,10 @@ func (f *pagedFinder) findOne(ctx context.Context, id common.ID, record common.R
 	}
 
 	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
 		foundID, b, err := iter.Next(ctx)
 		if err == io.EOF {
 			break
@@ -37,3 +37,16 @@ func TestCommonRecordsFind(t *testing.T) {
 	assert.Equal(t, uint64(0), rec.Start)
 	assert.Equal(t, 0, i)
 }
+
+func TestPagedFinderCancelled(t *testing.T) {
+	id := []byte{0x01}
+	recs := common.Records{{ID: id}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// the data reader is never reached once the context is done
+	finder := NewPagedFinder(recs, nil, nil, nil, "")
+	_, err := finder.Find(ctx, id)
+	assert.ErrorIs(t, err, context.Canceled)
+}
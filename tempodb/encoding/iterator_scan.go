@@ -0,0 +1,78 @@
+package encoding
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/grafana/tempo/tempodb/backend"
+	"github.com/grafana/tempo/tempodb/encoding/common"
+)
+
+type scanIterator struct {
+	meta     *backend.BlockMeta
+	reader   backend.Reader
+	encoding VersionedEncoding
+	objectRW common.ObjectReaderWriter
+
+	dataReader common.DataReader
+	page       []byte
+	buffer     []byte
+}
+
+// newScanIterator returns an iterator over the objects of a block without an index. The data object is read in
+// a single request and its pages are decoded one at a time.
+func newScanIterator(meta *backend.BlockMeta, reader backend.Reader, encoding VersionedEncoding) Iterator {
+	return &scanIterator{
+		meta:     meta,
+		reader:   reader,
+		encoding: encoding,
+		objectRW: encoding.NewObjectReaderWriter(),
+	}
+}
+
+// For performance reasons the ID and object slices returned from this method are owned by
+// the iterator.  If you have need to keep these values for longer than a single iteration
+// you need to make a copy of them.
+func (i *scanIterator) Next(ctx context.Context) (common.ID, []byte, error) {
+	if i.dataReader == nil {
+		data, err := i.reader.Read(ctx, nameObjects, i.meta.BlockID, i.meta.TenantID, false)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error reading objects, blockID: %s, err: %w", i.meta.BlockID.String(), err)
+		}
+
+		i.dataReader, err = i.encoding.NewDataReader(backend.NewContextReaderWithAllReader(bytes.NewReader(data)), i.meta.Encoding)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error building data reader, blockID: %s, err: %w", i.meta.BlockID.String(), err)
+		}
+	}
+
+	for {
+		var id common.ID
+		var object []byte
+		var err error
+		i.page, id, object, err = i.objectRW.UnmarshalAndAdvanceBuffer(i.page)
+		if err == nil {
+			return id, object, nil
+		}
+		if err != io.EOF {
+			return nil, nil, fmt.Errorf("error unmarshalling page, blockID: %s, err: %w", i.meta.BlockID.String(), err)
+		}
+
+		i.buffer, _, err = i.dataReader.NextPage(i.buffer)
+		if err == io.EOF {
+			return nil, nil, io.EOF
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("error reading page, blockID: %s, err: %w", i.meta.BlockID.String(), err)
+		}
+		i.page = i.buffer
+	}
+}
+
+func (i *scanIterator) Close() {
+	if i.dataReader != nil {
+		i.dataReader.Close()
+	}
+}
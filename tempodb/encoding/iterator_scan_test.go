@@ -0,0 +1,215 @@
+package encoding
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tempo/pkg/util/test"
+	"github.com/grafana/tempo/tempodb/backend"
+	"github.com/grafana/tempo/tempodb/backend/local"
+)
+
+func TestBackendBlockNoIndex(t *testing.T) {
+	tempDir, err := ioutil.TempDir("/tmp", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	rawR, rawW, _, err := local.New(&local.Config{
+		Path: tempDir,
+	})
+	require.NoError(t, err)
+	r := backend.NewReader(rawR)
+	w := backend.NewWriter(rawW)
+
+	cfg := &BlockConfig{
+		IndexDownsampleBytes: 1000,
+		IndexPageSizeBytes:   1000,
+		IndexMinObjects:      100,
+		BloomFP:              .01,
+		BloomShardSizeBytes:  100,
+		Encoding:             backend.EncSnappy,
+	}
+
+	// blocks under the threshold are written without an index
+	meta, ids, objs := writeTestBlock(t, cfg, w, 99)
+	assert.True(t, meta.NoIndex)
+	assert.Equal(t, uint32(0), meta.TotalRecords)
+	_, err = r.Read(context.Background(), nameIndex, meta.BlockID, meta.TenantID, false)
+	assert.Equal(t, backend.ErrDoesNotExist, err)
+
+	meta, err = r.BlockMeta(context.Background(), meta.BlockID, meta.TenantID)
+	require.NoError(t, err)
+	assert.True(t, meta.NoIndex)
+
+	block, err := NewBackendBlock(meta, r)
+	require.NoError(t, err)
+
+	for i, id := range ids {
+		obj, err := block.Find(context.Background(), id)
+		require.NoError(t, err)
+		assert.Equal(t, objs[i], obj)
+	}
+	for i := 0; i < 10; i++ {
+		id := make([]byte, 16)
+		rand.Read(id)
+		obj, err := block.Find(context.Background(), id)
+		require.NoError(t, err)
+		assert.Nil(t, obj)
+	}
+
+	iter, err := block.Iterator(1024)
+	require.NoError(t, err)
+	for i := range ids {
+		id, obj, err := iter.Next(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, ids[i], []byte(id))
+		assert.Equal(t, objs[i], obj)
+	}
+	_, _, err = iter.Next(context.Background())
+	assert.Equal(t, io.EOF, err)
+	iter.Close()
+
+	require.NoError(t, block.Verify(context.Background(), 0))
+
+	_, err = block.NewIndexReader()
+	assert.Error(t, err)
+
+	// copied without an index
+	copyDir, err := ioutil.TempDir("/tmp", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(copyDir)
+
+	copyR, copyW, _, err := local.New(&local.Config{
+		Path: copyDir,
+	})
+	require.NoError(t, err)
+	require.NoError(t, CopyBlock(context.Background(), meta, r, backend.NewWriter(copyW)))
+
+	copied, err := NewBackendBlock(meta, backend.NewReader(copyR))
+	require.NoError(t, err)
+	obj, err := copied.Find(context.Background(), ids[50])
+	require.NoError(t, err)
+	assert.Equal(t, objs[50], obj)
+	require.NoError(t, copied.Verify(context.Background(), 0))
+
+	// corrupt meta is detected
+	meta.TotalObjects++
+	assert.Error(t, block.Verify(context.Background(), 0))
+
+	// blocks at the threshold are indexed
+	meta, _, _ = writeTestBlock(t, cfg, w, 100)
+	assert.False(t, meta.NoIndex)
+	assert.NotZero(t, meta.TotalRecords)
+}
+
+// latencyReader adds a round trip latency and a transfer time to every read from the backend.
+type latencyReader struct {
+	backend.RawReader
+	latency        time.Duration
+	bytesPerSecond int64
+}
+
+func (r *latencyReader) Read(ctx context.Context, name string, keypath backend.KeyPath, shouldCache bool) (io.ReadCloser, int64, error) {
+	rc, size, err := r.RawReader.Read(ctx, name, keypath, shouldCache)
+	r.wait(size)
+	return rc, size, err
+}
+
+func (r *latencyReader) ReadRange(ctx context.Context, name string, keypath backend.KeyPath, offset uint64, buffer []byte) error {
+	r.wait(int64(len(buffer)))
+	return r.RawReader.ReadRange(ctx, name, keypath, offset, buffer)
+}
+
+func (r *latencyReader) wait(size int64) {
+	time.Sleep(r.latency + time.Duration(size*int64(time.Second)/r.bytesPerSecond))
+}
+
+// BenchmarkFindNoIndex compares finding objects in blocks with and without an index. Skipping the index saves a round
+// trip per lookup but the whole data object is transferred and decoded, so scanning is only faster up to a block size
+// that depends on the latency and throughput of the backend.
+func BenchmarkFindNoIndex(b *testing.B) {
+	const latency = 10 * time.Millisecond
+	const bytesPerSecond = 100 * 1024 * 1024
+
+	for _, objects := range []int{10, 100, 1000, 10_000, 20_000} {
+		for _, noIndex := range []bool{false, true} {
+			b.Run(fmt.Sprintf("objects=%d/noIndex=%t", objects, noIndex), func(b *testing.B) {
+				tempDir, err := ioutil.TempDir("/tmp", "")
+				require.NoError(b, err)
+				defer os.RemoveAll(tempDir)
+
+				rawR, rawW, _, err := local.New(&local.Config{
+					Path: tempDir,
+				})
+				require.NoError(b, err)
+
+				cfg := &BlockConfig{
+					IndexDownsampleBytes: 1024 * 1024,
+					IndexPageSizeBytes:   250 * 1024,
+					BloomFP:              .01,
+					BloomShardSizeBytes:  100 * 1024,
+					Encoding:             backend.EncZstd,
+				}
+				if noIndex {
+					cfg.IndexMinObjects = objects + 1
+				}
+				meta, ids, _ := writeTestBlock(b, cfg, backend.NewWriter(rawW), objects)
+				require.Equal(b, noIndex, meta.NoIndex)
+
+				block, err := NewBackendBlock(meta, backend.NewReader(&latencyReader{RawReader: rawR, latency: latency, bytesPerSecond: bytesPerSecond}))
+				require.NoError(b, err)
+
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					obj, err := block.Find(context.Background(), ids[i%len(ids)])
+					require.NoError(b, err)
+					require.NotNil(b, obj)
+				}
+			})
+		}
+	}
+}
+
+// writeTestBlock writes a block of random objects and returns its meta and the objects sorted by id.
+func writeTestBlock(t testing.TB, cfg *BlockConfig, w backend.Writer, objects int) (*backend.BlockMeta, [][]byte, [][]byte) {
+	ids := make([][]byte, 0, objects)
+	for i := 0; i < objects; i++ {
+		id := make([]byte, 16)
+		rand.Read(id)
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return bytes.Compare(ids[i], ids[j]) < 0 })
+
+	inMeta := backend.NewBlockMeta(testTenantID, uuid.New(), currentVersion, backend.EncNone, "")
+	block, err := NewStreamingBlock(cfg, uuid.New(), testTenantID, []*backend.BlockMeta{inMeta}, "", objects)
+	require.NoError(t, err)
+
+	objs := make([][]byte, 0, objects)
+	for _, id := range ids {
+		obj, err := proto.Marshal(test.MakeRequest(10, id))
+		require.NoError(t, err)
+		objs = append(objs, obj)
+		require.NoError(t, block.AddObject(id, obj))
+	}
+
+	ctx := context.Background()
+	tracker, _, err := block.FlushBuffer(ctx, nil, w)
+	require.NoError(t, err)
+	_, err = block.Complete(ctx, tracker, w)
+	require.NoError(t, err)
+
+	return block.BlockMeta(), ids, objs
+}
@@ -0,0 +1,97 @@
+package encoding
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/grafana/tempo/tempodb/backend"
+)
+
+const (
+	// nameLineage names the backend lineage object
+	nameLineage = "lineage"
+
+	// maxLineageInputs caps the number of inputs recorded in the lineage object
+	maxLineageInputs = 1000
+)
+
+// Lineage records the blocks a compacted block was created from. It is written alongside the block so the
+// inputs can still be described after their metas have been cleared by retention.
+type Lineage struct {
+	BlockID     uuid.UUID      `json:"blockID"`
+	TenantID    string         `json:"tenantID"`
+	CompactedAt time.Time      `json:"compactedAt"`
+	Inputs      []LineageInput `json:"inputs"`
+	TotalInputs int            `json:"totalInputs"`
+}
+
+// LineageInput describes a single input block of a compaction.
+type LineageInput struct {
+	BlockID         uuid.UUID `json:"blockID"`
+	CompactionLevel uint8     `json:"compactionLevel"`
+	StartTime       time.Time `json:"startTime"`
+	EndTime         time.Time `json:"endTime"`
+	TotalObjects    int       `json:"totalObjects"`
+	Size            uint64    `json:"size"`
+}
+
+// NewLineage returns the lineage of a block compacted from the passed metas. At most maxLineageInputs inputs are kept.
+func NewLineage(meta *backend.BlockMeta, inputs []*backend.BlockMeta) *Lineage {
+	l := &Lineage{
+		BlockID:     meta.BlockID,
+		TenantID:    meta.TenantID,
+		CompactedAt: time.Now(),
+		TotalInputs: len(inputs),
+	}
+
+	for _, m := range inputs {
+		if len(l.Inputs) >= maxLineageInputs {
+			break
+		}
+		l.Inputs = append(l.Inputs, LineageInput{
+			BlockID:         m.BlockID,
+			CompactionLevel: m.CompactionLevel,
+			StartTime:       m.StartTime,
+			EndTime:         m.EndTime,
+			TotalObjects:    m.TotalObjects,
+			Size:            m.Size,
+		})
+	}
+
+	return l
+}
+
+// WriteLineage writes the lineage object of a block
+func WriteLineage(ctx context.Context, w backend.Writer, l *Lineage) error {
+	b, err := json.Marshal(l)
+	if err != nil {
+		return err
+	}
+
+	err = w.Write(ctx, nameLineage, l.BlockID, l.TenantID, b, false)
+	if err != nil {
+		return fmt.Errorf("unexpected error writing lineage %w", err)
+	}
+
+	return nil
+}
+
+// ReadLineage reads the lineage object of a block. It returns backend.ErrDoesNotExist if the block was not
+// created by a compaction.
+func ReadLineage(ctx context.Context, r backend.Reader, blockID uuid.UUID, tenantID string) (*Lineage, error) {
+	b, err := r.Read(ctx, nameLineage, blockID, tenantID, false)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &Lineage{}
+	err = json.Unmarshal(b, l)
+	if err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
@@ -0,0 +1,223 @@
+package encoding
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/grafana/tempo/tempodb/backend"
+	"github.com/grafana/tempo/tempodb/encoding/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var updateGolden = flag.Bool("update-golden", false, "regenerate golden files in testdata")
+
+const (
+	conformanceObjects = 50
+	goldenSeed         = 42
+	fuzzIterations     = 1000
+)
+
+type testObject struct {
+	id  common.ID
+	obj []byte
+}
+
+// makeTestObjects returns a deterministic set of objects for the provided seed. Objects include empty payloads
+// and ids of varying length to exercise edge cases in the object format.
+func makeTestObjects(seed int64, count int) []testObject {
+	r := rand.New(rand.NewSource(seed))
+
+	objs := make([]testObject, 0, count)
+	for i := 0; i < count; i++ {
+		id := make([]byte, r.Intn(32)+1)
+		r.Read(id)
+
+		obj := make([]byte, r.Intn(512))
+		r.Read(obj)
+
+		objs = append(objs, testObject{id: id, obj: obj})
+	}
+
+	return objs
+}
+
+func marshalTestObjects(t *testing.T, o common.ObjectReaderWriter, objs []testObject) []byte {
+	buff := &bytes.Buffer{}
+	for _, obj := range objs {
+		_, err := o.MarshalObjectToWriter(obj.id, obj.obj, buff)
+		require.NoError(t, err)
+	}
+	return buff.Bytes()
+}
+
+func TestObjectReaderWriterConformance(t *testing.T) {
+	for _, v := range allEncodings() {
+		t.Run(v.Version(), func(t *testing.T) {
+			o := v.NewObjectReaderWriter()
+			objs := makeTestObjects(randomSeed(t), conformanceObjects)
+			marshalled := marshalTestObjects(t, o, objs)
+
+			// reader path via NewIterator
+			iter := NewIterator(bytes.NewReader(marshalled), o)
+			defer iter.Close()
+			for _, expected := range objs {
+				id, obj, err := iter.Next(context.Background())
+				require.NoError(t, err)
+				assert.Equal(t, []byte(expected.id), []byte(id))
+				assert.Equal(t, expected.obj, nonNil(obj))
+			}
+			_, _, err := iter.Next(context.Background())
+			assert.Equal(t, io.EOF, err)
+
+			// buffer path
+			buffer := marshalled
+			for _, expected := range objs {
+				var id common.ID
+				var obj []byte
+				buffer, id, obj, err = o.UnmarshalAndAdvanceBuffer(buffer)
+				require.NoError(t, err)
+				assert.Equal(t, []byte(expected.id), []byte(id))
+				assert.Equal(t, expected.obj, nonNil(obj))
+			}
+			_, _, _, err = o.UnmarshalAndAdvanceBuffer(buffer)
+			assert.Equal(t, io.EOF, err)
+		})
+	}
+}
+
+func TestObjectReaderWriterCorruption(t *testing.T) {
+	for _, v := range allEncodings() {
+		t.Run(v.Version(), func(t *testing.T) {
+			o := v.NewObjectReaderWriter()
+			objs := makeTestObjects(randomSeed(t), 10)
+			marshalled := marshalTestObjects(t, o, objs)
+			r := rand.New(rand.NewSource(randomSeed(t)))
+
+			for i := 0; i < fuzzIterations; i++ {
+				// truncation must never panic on either path
+				truncated := marshalled[:r.Intn(len(marshalled))]
+				require.NotPanics(t, func() {
+					drainReader(o, truncated)
+					drainBuffer(o, truncated)
+				}, "truncated at %d", len(truncated))
+
+				// corruption of arbitrary bytes (including lengths) must never panic on the buffer path
+				corrupted := append([]byte(nil), marshalled...)
+				for j := 0; j < r.Intn(8)+1; j++ {
+					corrupted[r.Intn(len(corrupted))] = byte(r.Intn(256))
+				}
+				require.NotPanics(t, func() {
+					drainBuffer(o, corrupted)
+				})
+			}
+		})
+	}
+}
+
+func TestObjectReaderWriterGolden(t *testing.T) {
+	for _, v := range allEncodings() {
+		t.Run(v.Version(), func(t *testing.T) {
+			objs := makeTestObjects(goldenSeed, conformanceObjects)
+			// pages are written without checksums unless they are enabled, both formats must never change
+			goldenFiles := []struct {
+				file          string
+				newDataWriter func(io.Writer, backend.Encoding) (common.DataWriter, error)
+			}{
+				{file: filepath.Join("testdata", v.Version(), "page.golden"), newDataWriter: v.NewDataWriter},
+				{file: filepath.Join("testdata", v.Version(), "page_checksum.golden"), newDataWriter: v.NewChecksumDataWriter},
+			}
+
+			for _, g := range goldenFiles {
+				buff := &bytes.Buffer{}
+				dataWriter, err := g.newDataWriter(buff, backend.EncNone)
+				require.NoError(t, err)
+				for _, obj := range objs {
+					_, err = dataWriter.Write(obj.id, obj.obj)
+					require.NoError(t, err)
+				}
+				_, err = dataWriter.CutPage()
+				require.NoError(t, err)
+				require.NoError(t, dataWriter.Complete())
+
+				if *updateGolden {
+					require.NoError(t, os.MkdirAll(filepath.Dir(g.file), 0755))
+					require.NoError(t, os.WriteFile(g.file, buff.Bytes(), 0644))
+				}
+
+				golden, err := os.ReadFile(g.file)
+				require.NoError(t, err, "golden file missing. run with -update-golden to create it")
+				assert.Equal(t, golden, buff.Bytes(), "marshalled page no longer matches historical bytes: %s", g.file)
+			}
+
+			// historical bytes must always be readable
+			for _, g := range goldenFiles {
+				goldenFile := g.file
+				golden, err := os.ReadFile(goldenFile)
+				require.NoError(t, err)
+
+				dataReader, err := v.NewDataReader(backend.NewContextReaderWithAllReader(bytes.NewReader(golden)), backend.EncNone)
+				require.NoError(t, err)
+				defer dataReader.Close()
+
+				pages, _, err := dataReader.Read(context.Background(), []common.Record{
+					{
+						Start:  0,
+						Length: uint32(len(golden)),
+					},
+				}, nil, nil)
+				require.NoError(t, err, goldenFile)
+				require.Len(t, pages, 1)
+
+				iter := NewIterator(bytes.NewReader(pages[0]), v.NewObjectReaderWriter())
+				defer iter.Close()
+				for _, expected := range objs {
+					id, obj, err := iter.Next(context.Background())
+					require.NoError(t, err)
+					assert.Equal(t, []byte(expected.id), []byte(id))
+					assert.Equal(t, expected.obj, nonNil(obj))
+				}
+				_, _, err = iter.Next(context.Background())
+				assert.Equal(t, io.EOF, err)
+			}
+		})
+	}
+}
+
+func drainReader(o common.ObjectReaderWriter, b []byte) {
+	r := bytes.NewReader(b)
+	for {
+		if _, _, err := o.UnmarshalObjectFromReader(r); err != nil {
+			return
+		}
+	}
+}
+
+func drainBuffer(o common.ObjectReaderWriter, b []byte) {
+	var err error
+	for {
+		if b, _, _, err = o.UnmarshalAndAdvanceBuffer(b); err != nil {
+			return
+		}
+	}
+}
+
+// randomSeed returns a seed that is logged so that failing randomized runs can be reproduced
+func randomSeed(t *testing.T) int64 {
+	seed := rand.Int63()
+	t.Logf("seed: %d", seed)
+	return seed
+}
+
+func nonNil(b []byte) []byte {
+	if b == nil {
+		return []byte{}
+	}
+	return b
+}
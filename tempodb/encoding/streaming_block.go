@@ -26,18 +26,13 @@ type StreamingBlock struct {
 }
 
 // NewStreamingBlock creates a ... new streaming block. Objects are appended one at a time to the backend.
-func NewStreamingBlock(cfg *BlockConfig, id uuid.UUID, tenantID string, metas []*backend.BlockMeta, estimatedObjects int) (*StreamingBlock, error) {
+// All objects must be encoded with dataEncoding, which may differ from the data encodings of the input blocks if
+// they were transcoded.
+func NewStreamingBlock(cfg *BlockConfig, id uuid.UUID, tenantID string, metas []*backend.BlockMeta, dataEncoding string, estimatedObjects int) (*StreamingBlock, error) {
 	if len(metas) == 0 {
 		return nil, fmt.Errorf("empty block meta list")
 	}
 
-	dataEncoding := metas[0].DataEncoding
-	for _, meta := range metas {
-		if meta.DataEncoding != dataEncoding {
-			return nil, fmt.Errorf("two blocks of different data encodings can not be streamed together: %s: %s", dataEncoding, meta.DataEncoding)
-		}
-	}
-
 	c := &StreamingBlock{
 		encoding:      LatestEncoding(),
 		compactedMeta: backend.NewBlockMeta(tenantID, id, currentVersion, cfg.Encoding, dataEncoding),
@@ -46,8 +41,12 @@ func NewStreamingBlock(cfg *BlockConfig, id uuid.UUID, tenantID string, metas []
 		cfg:           cfg,
 	}
 
-	c.appendBuffer = &bytes.Buffer{}
-	dataWriter, err := c.encoding.NewDataWriter(c.appendBuffer, cfg.Encoding)
+	c.appendBuffer = common.GetBuffer()
+	newDataWriter := c.encoding.NewDataWriter
+	if cfg.DataPageChecksums {
+		newDataWriter = c.encoding.NewChecksumDataWriter
+	}
+	dataWriter, err := newDataWriter(c.appendBuffer, cfg.Encoding)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create page writer: %w", err)
 	}
@@ -121,18 +120,36 @@ func (c *StreamingBlock) Complete(ctx context.Context, tracker backend.AppendTra
 		return 0, err
 	}
 
-	records := c.appender.Records()
+	// the append buffer is empty after the final flush and can be shared with the next block
+	common.PutBuffer(c.appendBuffer)
+	c.appendBuffer = &bytes.Buffer{}
+
 	meta := c.BlockMeta()
+	meta.BloomShardCount = uint16(c.bloom.GetShardCount())
 
-	indexWriter := c.encoding.NewIndexWriter(c.cfg.IndexPageSizeBytes)
-	indexBytes, err := indexWriter.Write(records)
-	if err != nil {
-		return 0, err
+	// small blocks are searched faster by scanning their data than by reading an index first
+	var indexBytes []byte
+	if meta.TotalObjects < c.cfg.IndexMinObjects {
+		meta.NoIndex = true
+	} else {
+		records := c.appender.Records()
+		indexWriter := c.encoding.NewIndexWriter(c.cfg.IndexPageSizeBytes)
+		indexBytes, err = indexWriter.Write(records)
+		if err != nil {
+			return 0, err
+		}
+
+		meta.TotalRecords = uint32(len(records)) // casting
+		meta.IndexPageSize = uint32(c.cfg.IndexPageSizeBytes)
 	}
 
-	meta.TotalRecords = uint32(len(records)) // casting
-	meta.IndexPageSize = uint32(c.cfg.IndexPageSizeBytes)
-	meta.BloomShardCount = uint16(c.bloom.GetShardCount())
+	// lineage is written before the meta so that it exists for every visible block
+	if meta.CompactedFromCount > 0 {
+		err = WriteLineage(ctx, w, NewLineage(meta, c.inMetas))
+		if err != nil {
+			return 0, err
+		}
+	}
 
 	return bytesFlushed, writeBlockMeta(ctx, w, meta, indexBytes, c.bloom)
 }
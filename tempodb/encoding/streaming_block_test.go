@@ -33,14 +33,7 @@ const (
 
 func TestStreamingBlockError(t *testing.T) {
 	// no block metas
-	_, err := NewStreamingBlock(nil, uuid.New(), "", nil, 0)
-	assert.Error(t, err)
-
-	// mixed data encodings
-	_, err = NewStreamingBlock(nil, uuid.New(), "", []*backend.BlockMeta{
-		backend.NewBlockMeta("", uuid.New(), "", backend.EncNone, "foo"),
-		backend.NewBlockMeta("", uuid.New(), "", backend.EncNone, "bar"),
-	}, 0)
+	_, err := NewStreamingBlock(nil, uuid.New(), "", nil, "", 0)
 	assert.Error(t, err)
 }
 
@@ -64,7 +57,7 @@ func TestStreamingBlockAddObject(t *testing.T) {
 		BloomShardSizeBytes:  100,
 		IndexDownsampleBytes: indexDownsample,
 		Encoding:             backend.EncGZIP,
-	}, uuid.New(), testTenantID, metas, numObjects)
+	}, uuid.New(), testTenantID, metas, "", numObjects)
 	assert.NoError(t, err)
 
 	var minID common.ID
@@ -138,6 +131,7 @@ func TestStreamingBlockAll(t *testing.T) {
 		bloomFP := float64(rand.Intn(99)+1) / 100.0
 		bloomShardSize := rand.Intn(10_000) + 10_000
 		indexPageSize := rand.Intn(5000) + 1000
+		dataPageChecksums := rand.Intn(2) == 0
 
 		for _, enc := range backend.SupportedEncoding {
 			t.Run(enc.String(), func(t *testing.T) {
@@ -148,6 +142,7 @@ func TestStreamingBlockAll(t *testing.T) {
 						BloomShardSizeBytes:  bloomShardSize,
 						Encoding:             enc,
 						IndexPageSizeBytes:   indexPageSize,
+						DataPageChecksums:    dataPageChecksums,
 					},
 				)
 			})
@@ -262,7 +257,7 @@ func streamingBlock(t *testing.T, cfg *BlockConfig, w backend.Writer) (*Streamin
 		dataReader,
 		v2.NewObjectReaderWriter())
 
-	block, err := NewStreamingBlock(cfg, originatingMeta.BlockID, originatingMeta.TenantID, []*backend.BlockMeta{originatingMeta}, originatingMeta.TotalObjects)
+	block, err := NewStreamingBlock(cfg, originatingMeta.BlockID, originatingMeta.TenantID, []*backend.BlockMeta{originatingMeta}, originatingMeta.DataEncoding, originatingMeta.TotalObjects)
 	require.NoError(t, err, "unexpected error completing block")
 
 	expectedBloomShards := block.bloom.GetShardCount()
@@ -391,7 +386,7 @@ func benchmarkCompressBlock(b *testing.B, encoding backend.Encoding, indexDownsa
 		Encoding:             encoding,
 		IndexPageSizeBytes:   10 * 1024 * 1024,
 		BloomShardSizeBytes:  100000,
-	}, uuid.New(), meta.TenantID, []*backend.BlockMeta{meta}, meta.TotalObjects)
+	}, uuid.New(), meta.TenantID, []*backend.BlockMeta{meta}, meta.DataEncoding, meta.TotalObjects)
 	require.NoError(b, err, "unexpected error completing block")
 
 	ctx := context.Background()
@@ -20,12 +20,10 @@ type dataReader struct {
 	encoding         backend.Encoding
 	pool             ReaderPool
 	compressedReader io.Reader
-}
 
-// constDataHeader is a singleton data header.  the data header is
-//  stateless b/c there are no fields.  to very minorly reduce allocations all
-//  data should just use this.
-var constDataHeader = &dataHeader{}
+	// header is reused and reset for every page. The checksum of every page is verified before it is decompressed.
+	header dataHeader
+}
 
 // NewDataReader constructs a v2 DataReader that handles paged...reading
 func NewDataReader(r backend.ContextReader, encoding backend.Encoding) (common.DataReader, error) {
@@ -84,7 +82,12 @@ func (r *dataReader) Read(ctx context.Context, records []common.Record, pagesBuf
 	// read and strip page data
 	compressedPages := make([][]byte, 0, len(compressedPagesBuffer))
 	for _, v0Page := range compressedPagesBuffer {
-		page, err := unmarshalPageFromBytes(v0Page, constDataHeader)
+		r.header = dataHeader{}
+		page, err := unmarshalPageFromBytes(v0Page, &r.header)
+		if err != nil {
+			return nil, nil, err
+		}
+		err = r.header.verify(page.data)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -137,7 +140,12 @@ func (r *dataReader) NextPage(buffer []byte) ([]byte, uint32, error) {
 		return nil, 0, err
 	}
 
-	page, err := unmarshalPageFromReader(reader, constDataHeader, r.pageBuffer)
+	r.header = dataHeader{}
+	page, err := unmarshalPageFromReader(reader, &r.header, r.pageBuffer)
+	if err != nil {
+		return nil, 0, err
+	}
+	err = r.header.verify(page.data)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -31,6 +31,29 @@ func TestReaderRead(t *testing.T) {
 	testRead(t, totalObjects, enc, ids, objs, buffer, recs)
 }
 
+func TestReaderReadMixedChecksums(t *testing.T) {
+	// an empty page without a checksum after a page with one. it's shorter than a data header with a checksum
+	buffer := &bytes.Buffer{}
+	checksummed := []byte{0x01, 0x02, 0x03}
+	first, err := marshalPageToWriter(checksummed, buffer, newDataHeader(checksummed))
+	require.NoError(t, err)
+	second, err := marshalPageToWriter(nil, buffer, constDataHeader)
+	require.NoError(t, err)
+
+	r, err := NewDataReader(backend.NewContextReaderWithAllReader(bytes.NewReader(buffer.Bytes())), backend.EncNone)
+	require.NoError(t, err)
+	defer r.Close()
+
+	pages, _, err := r.Read(context.Background(), []common.Record{
+		{Start: 0, Length: uint32(first)},
+		{Start: uint64(first), Length: uint32(second)},
+	}, nil, nil)
+	require.NoError(t, err)
+	require.Len(t, pages, 2)
+	assert.Equal(t, checksummed, pages[0])
+	assert.Empty(t, pages[1])
+}
+
 func BenchmarkReaderRead(b *testing.B) {
 	totalObjects := 10000
 	objsPerPage := 100
@@ -17,18 +17,32 @@ type dataWriter struct {
 
 	objectRW     common.ObjectReaderWriter
 	objectBuffer *bytes.Buffer
+
+	// checksums writes the checksum of every page in its data header
+	checksums bool
 }
 
-// NewDataWriter creates a paged page writer
+// NewDataWriter creates a paged page writer. The pages have an empty data header.
 func NewDataWriter(writer io.Writer, encoding backend.Encoding) (common.DataWriter, error) {
+	return newDataWriter(writer, encoding, false)
+}
+
+// NewChecksumDataWriter creates a paged page writer that writes the checksum of every page in its data header. The
+// pages are verified by the readers of this release, but the readers of previous releases can't read them.
+func NewChecksumDataWriter(writer io.Writer, encoding backend.Encoding) (common.DataWriter, error) {
+	return newDataWriter(writer, encoding, true)
+}
+
+func newDataWriter(writer io.Writer, encoding backend.Encoding, checksums bool) (common.DataWriter, error) {
 	pool, err := GetWriterPool(encoding)
 	if err != nil {
 		return nil, err
 	}
 
-	compressedBuffer := &bytes.Buffer{}
+	compressedBuffer := common.GetBuffer()
 	compressionWriter, err := pool.GetWriter(compressedBuffer)
 	if err != nil {
+		common.PutBuffer(compressedBuffer)
 		return nil, err
 	}
 
@@ -38,7 +52,8 @@ func NewDataWriter(writer io.Writer, encoding backend.Encoding) (common.DataWrit
 		compressionWriter: compressionWriter,
 		compressedBuffer:  compressedBuffer,
 		objectRW:          NewObjectReaderWriter(),
-		objectBuffer:      &bytes.Buffer{},
+		objectBuffer:      common.GetBuffer(),
+		checksums:         checksums,
 	}, nil
 }
 
@@ -60,7 +75,12 @@ func (p *dataWriter) CutPage() (int, error) {
 	p.compressionWriter.Close()
 
 	// now marshal the buffer as a page to the output
-	bytesWritten, err := marshalPageToWriter(p.compressedBuffer.Bytes(), p.outputWriter, constDataHeader)
+	compressed := p.compressedBuffer.Bytes()
+	header := constDataHeader
+	if p.checksums {
+		header = newDataHeader(compressed)
+	}
+	bytesWritten, err := marshalPageToWriter(compressed, p.outputWriter, header)
 	if err != nil {
 		return 0, err
 	}
@@ -83,5 +103,13 @@ func (p *dataWriter) Complete() error {
 		p.compressionWriter = nil
 	}
 
+	// return the page buffers, they are not needed once the last page is cut
+	if p.compressedBuffer != nil {
+		common.PutBuffer(p.compressedBuffer)
+		common.PutBuffer(p.objectBuffer)
+		p.compressedBuffer = nil
+		p.objectBuffer = nil
+	}
+
 	return nil
 }
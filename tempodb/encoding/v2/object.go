@@ -60,6 +60,10 @@ func (object) UnmarshalObjectFromReader(r io.Reader) (common.ID, []byte, error)
 		return nil, nil, err
 	}
 
+	if totalLength < uint32Size*2 {
+		return nil, nil, fmt.Errorf("total length %d smaller than header. corrupt buffer?", totalLength)
+	}
+
 	protoLength := totalLength - uint32Size*2
 	b := make([]byte, protoLength)
 	readLength, err := r.Read(b)
@@ -99,10 +103,17 @@ func (object) UnmarshalAndAdvanceBuffer(buffer []byte) ([]byte, common.ID, []byt
 	idLength = binary.LittleEndian.Uint32(buffer)
 	buffer = buffer[uint32Size:]
 
+	if totalLength < uint32Size*2 {
+		return nil, nil, nil, fmt.Errorf("total length %d smaller than header. corrupt buffer?", totalLength)
+	}
+
 	restLength := totalLength - uint32Size*2
 	if uint32(len(buffer)) < restLength {
 		return nil, nil, nil, fmt.Errorf("unable to read id/object from buffer")
 	}
+	if idLength > restLength {
+		return nil, nil, nil, fmt.Errorf("id length %d outside bounds of buffer %d. corrupt buffer?", idLength, restLength)
+	}
 
 	bytesID := buffer[:idLength]
 	bytesObject := buffer[idLength:restLength]
@@ -26,8 +26,7 @@ type page struct {
   | totalLength | header len | header fields      | page bytes |
 */
 func unmarshalPageFromBytes(b []byte, header pageHeader) (*page, error) {
-	totalHeaderSize := baseHeaderSize + header.headerLength()
-	if len(b) < totalHeaderSize {
+	if len(b) < baseHeaderSize+header.headerLength() {
 		return nil, fmt.Errorf("page of size %d too small", len(b))
 	}
 
@@ -44,7 +43,8 @@ func unmarshalPageFromBytes(b []byte, header pageHeader) (*page, error) {
 	}
 	b = b[headerLength:]
 
-	dataLength := int(totalLength) - totalHeaderSize
+	// the header length read from the page is used as data headers are written with and without a checksum
+	dataLength := int(totalLength) - baseHeaderSize - int(headerLength)
 	if len(b) != dataLength {
 		return nil, fmt.Errorf("expected data len %d does not match actual %d", dataLength, len(b))
 	}
@@ -57,8 +57,6 @@ func unmarshalPageFromBytes(b []byte, header pageHeader) (*page, error) {
 }
 
 func unmarshalPageFromReader(r io.Reader, header pageHeader, buffer []byte) (*page, error) {
-	totalHeaderSize := baseHeaderSize + header.headerLength()
-
 	var totalLength uint32
 	var headerLength uint16
 
@@ -79,7 +77,7 @@ func unmarshalPageFromReader(r io.Reader, header pageHeader, buffer []byte) (*pa
 	if err != nil {
 		return nil, err
 	}
-	dataLength := int(totalLength) - totalHeaderSize
+	dataLength := int(totalLength) - baseHeaderSize - int(headerLength)
 
 	if dataLength < 0 {
 		return nil, fmt.Errorf("unexpected negative dataLength unmarshalling page: %d", dataLength)
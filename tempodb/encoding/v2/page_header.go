@@ -4,6 +4,8 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
+
+	"github.com/cespare/xxhash"
 )
 
 type pageHeader interface {
@@ -12,29 +14,74 @@ type pageHeader interface {
 	marshalHeader([]byte) error
 }
 
-// DataHeaderLength is the length in bytes for the data header
-const DataHeaderLength = 0
+// DataHeaderLength is the length in bytes for the data header of pages with a checksum, pages without one have an
+// empty data header
+const DataHeaderLength = int(uint64Size) // 64bit checksum (xxhash)
 
 // IndexHeaderLength is the length in bytes for the record header
 const IndexHeaderLength = int(uint64Size) // 64bit checksum (xxhash)
 
-// dataHeader implements a pageHeader that has no fields
+// dataHeader implements a pageHeader that has data fields
+//   checksum - 64 bit xxhash of the page bytes
+// Pages written without checksums, the default, have an empty header and are not verified. Pages with a checksum can
+// only be read by releases that know the checksum.
 type dataHeader struct {
+	checksum    uint64
+	hasChecksum bool
+}
+
+// constDataHeader is the empty data header of pages without a checksum. it is only marshalled, so all pages without a
+// checksum share it.
+var constDataHeader = &dataHeader{}
+
+// newDataHeader returns a header with the checksum of the page bytes.
+func newDataHeader(page []byte) *dataHeader {
+	return &dataHeader{
+		checksum:    xxhash.Sum64(page),
+		hasChecksum: true,
+	}
 }
 
 func (h *dataHeader) unmarshalHeader(b []byte) error {
-	if len(b) != 0 {
-		return errors.New("unexpected non-zero len data header")
+	switch len(b) {
+	case 0:
+		h.checksum = 0
+		h.hasChecksum = false
+	case DataHeaderLength:
+		h.checksum = binary.LittleEndian.Uint64(b[:uint64Size])
+		h.hasChecksum = true
+	default:
+		return fmt.Errorf("unexpected data header len of %d", len(b))
 	}
 
 	return nil
 }
 
 func (h *dataHeader) headerLength() int {
+	if !h.hasChecksum {
+		return 0
+	}
 	return DataHeaderLength
 }
 
 func (h *dataHeader) marshalHeader(b []byte) error {
+	if len(b) != h.headerLength() {
+		return fmt.Errorf("unexpected data header len of %d", len(b))
+	}
+
+	if h.hasChecksum {
+		binary.LittleEndian.PutUint64(b, h.checksum)
+	}
+
+	return nil
+}
+
+// verify checks the page bytes against the checksum. Pages without a checksum always pass.
+func (h *dataHeader) verify(page []byte) error {
+	if h.hasChecksum && xxhash.Sum64(page) != h.checksum {
+		return errors.New("mismatched data page checksum")
+	}
+
 	return nil
 }
 
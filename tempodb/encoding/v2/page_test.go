@@ -113,9 +113,10 @@ func TestCorruptHeader(t *testing.T) {
 	data := []byte{0x01, 0x02, 0x03}
 	buff := &bytes.Buffer{}
 
-	bytesWritten, err := marshalPageToWriter(data, buff, constDataHeader)
+	header := newDataHeader(data)
+	bytesWritten, err := marshalPageToWriter(data, buff, header)
 	require.NoError(t, err)
-	assert.Equal(t, len(data)+constDataHeader.headerLength()+int(baseHeaderSize), bytesWritten)
+	assert.Equal(t, len(data)+header.headerLength()+int(baseHeaderSize), bytesWritten)
 
 	buffBytes := buff.Bytes()
 
@@ -123,11 +124,11 @@ func TestCorruptHeader(t *testing.T) {
 	zeroHeader := bytes.Repeat([]byte{0x00}, baseHeaderSize)
 	copy(buffBytes, zeroHeader)
 
-	page, err := unmarshalPageFromBytes(buffBytes, constDataHeader)
+	page, err := unmarshalPageFromBytes(buffBytes, &dataHeader{})
 	assert.Nil(t, page)
-	assert.EqualError(t, err, "expected data len -6 does not match actual 3")
+	assert.EqualError(t, err, "expected data len -6 does not match actual 11")
 
-	page, err = unmarshalPageFromReader(bytes.NewReader(buffBytes), constDataHeader, nil)
+	page, err = unmarshalPageFromReader(bytes.NewReader(buffBytes), &dataHeader{}, nil)
 	assert.Nil(t, page)
 	assert.EqualError(t, err, "unexpected negative dataLength unmarshalling page: -6")
 
@@ -135,11 +136,63 @@ func TestCorruptHeader(t *testing.T) {
 	zeroHeader = bytes.Repeat([]byte{0xFF}, baseHeaderSize)
 	copy(buffBytes, zeroHeader)
 
-	page, err = unmarshalPageFromBytes(buffBytes, constDataHeader)
+	page, err = unmarshalPageFromBytes(buffBytes, &dataHeader{})
 	assert.Nil(t, page)
-	assert.EqualError(t, err, "headerLen 65535 greater than remaining len 3")
+	assert.EqualError(t, err, "headerLen 65535 greater than remaining len 11")
 
-	page, err = unmarshalPageFromReader(bytes.NewReader(buffBytes), constDataHeader, nil)
+	page, err = unmarshalPageFromReader(bytes.NewReader(buffBytes), &dataHeader{}, nil)
 	assert.Nil(t, page)
-	assert.EqualError(t, err, "unexpected non-zero len data header")
+	assert.EqualError(t, err, "unexpected data header len of 65535")
+}
+
+func TestDataHeaderChecksum(t *testing.T) {
+	data := []byte{0x01, 0x02, 0x03}
+
+	tests := []struct {
+		name     string
+		header   *dataHeader
+		corrupt  bool
+		expected string
+	}{
+		{
+			name:   "checksum",
+			header: newDataHeader(data),
+		},
+		{
+			name:     "corrupt",
+			header:   newDataHeader(data),
+			corrupt:  true,
+			expected: "mismatched data page checksum",
+		},
+		{
+			name:    "no checksum",
+			header:  &dataHeader{},
+			corrupt: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			buff := &bytes.Buffer{}
+			_, err := marshalPageToWriter(data, buff, tc.header)
+			require.NoError(t, err)
+
+			buffBytes := buff.Bytes()
+			if tc.corrupt {
+				buffBytes[len(buffBytes)-1]++
+			}
+
+			header := &dataHeader{}
+			page, err := unmarshalPageFromBytes(buffBytes, header)
+			require.NoError(t, err)
+			assert.Equal(t, tc.header.hasChecksum, header.hasChecksum)
+
+			err = header.verify(page.data)
+			if tc.expected == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.expected)
+			}
+		})
+	}
 }
@@ -18,6 +18,7 @@ type VersionedEncoding interface {
 	Version() string
 
 	NewDataWriter(writer io.Writer, encoding backend.Encoding) (common.DataWriter, error)
+	NewChecksumDataWriter(writer io.Writer, encoding backend.Encoding) (common.DataWriter, error)
 	NewIndexWriter(pageSizeBytes int) common.IndexWriter
 
 	NewDataReader(ra backend.ContextReader, encoding backend.Encoding) (common.DataReader, error)
@@ -61,6 +62,9 @@ func (v v2Encoding) NewIndexWriter(pageSizeBytes int) common.IndexWriter {
 func (v v2Encoding) NewDataWriter(writer io.Writer, encoding backend.Encoding) (common.DataWriter, error) {
 	return v2.NewDataWriter(writer, encoding)
 }
+func (v v2Encoding) NewChecksumDataWriter(writer io.Writer, encoding backend.Encoding) (common.DataWriter, error) {
+	return v2.NewChecksumDataWriter(writer, encoding)
+}
 func (v v2Encoding) NewIndexReader(ra backend.ContextReader, pageSizeBytes int, totalPages int) (common.IndexReader, error) {
 	return v2.NewIndexReader(ra, pageSizeBytes, totalPages)
 }
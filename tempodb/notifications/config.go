@@ -0,0 +1,40 @@
+package notifications
+
+import "time"
+
+const (
+	DefaultQueueSize  = 1000
+	DefaultMaxRetries = 3
+	DefaultBackoff    = time.Second
+	DefaultTimeout    = 5 * time.Second
+)
+
+// Config for block lifecycle notifications. Notifications are disabled unless a webhook url
+// or kafka topic is configured.
+type Config struct {
+	Webhook *WebhookConfig `yaml:"webhook"`
+	Kafka   *KafkaConfig   `yaml:"kafka"`
+
+	QueueSize  int           `yaml:"queue_size"`
+	MaxRetries int           `yaml:"max_retries"`
+	Backoff    time.Duration `yaml:"backoff"`
+}
+
+type WebhookConfig struct {
+	URL     string        `yaml:"url"`
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+type KafkaConfig struct {
+	Brokers []string      `yaml:"brokers"`
+	Topic   string        `yaml:"topic"`
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+func (cfg *Config) webhookEnabled() bool {
+	return cfg != nil && cfg.Webhook != nil && cfg.Webhook.URL != ""
+}
+
+func (cfg *Config) kafkaEnabled() bool {
+	return cfg != nil && cfg.Kafka != nil && cfg.Kafka.Topic != ""
+}
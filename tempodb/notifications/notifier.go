@@ -0,0 +1,218 @@
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/grafana/tempo/tempodb/backend"
+)
+
+type EventType string
+
+const (
+	EventBlockFlushed   EventType = "block_flushed"
+	EventBlockCompacted EventType = "block_compacted"
+	EventBlockDeleted   EventType = "block_deleted"
+)
+
+var (
+	metricNotificationsSent = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempodb",
+		Name:      "notifications_sent_total",
+		Help:      "Total number of block lifecycle notifications delivered.",
+	}, []string{"type"})
+	metricNotificationsFailed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempodb",
+		Name:      "notifications_failed_total",
+		Help:      "Total number of block lifecycle notifications that could not be delivered after all retries.",
+	}, []string{"type"})
+	metricNotificationsDropped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tempodb",
+		Name:      "notifications_dropped_total",
+		Help:      "Total number of block lifecycle notifications dropped because the queue was full.",
+	}, []string{"type"})
+)
+
+// Event is the json payload emitted for block lifecycle changes.
+type Event struct {
+	Type            EventType `json:"type"`
+	TenantID        string    `json:"tenantID"`
+	BlockID         string    `json:"blockID"`
+	Size            uint64    `json:"size"`
+	StartTime       time.Time `json:"startTime"`
+	EndTime         time.Time `json:"endTime"`
+	CompactionLevel uint8     `json:"compactionLevel"`
+	Timestamp       time.Time `json:"timestamp"`
+}
+
+// NewEvent creates an event of the given type for the block.
+func NewEvent(t EventType, meta *backend.BlockMeta) *Event {
+	return &Event{
+		Type:            t,
+		TenantID:        meta.TenantID,
+		BlockID:         meta.BlockID.String(),
+		Size:            meta.Size,
+		StartTime:       meta.StartTime,
+		EndTime:         meta.EndTime,
+		CompactionLevel: meta.CompactionLevel,
+		Timestamp:       time.Now(),
+	}
+}
+
+// Notifier asynchronously delivers events to the configured sinks. Events are queued in a bounded
+// queue and dropped if the queue is full so that delivery never blocks the write path. A nil
+// *Notifier is valid and discards all events.
+type Notifier struct {
+	cfg    *Config
+	sinks  []Sink
+	queue  chan *Event
+	logger log.Logger
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// New creates a notifier for the configured sinks. It returns nil if notifications are not configured.
+func New(cfg *Config, logger log.Logger) (*Notifier, error) {
+	var sinks []Sink
+
+	if cfg.webhookEnabled() {
+		sinks = append(sinks, newWebhookSink(cfg.Webhook))
+	}
+
+	if cfg.kafkaEnabled() {
+		s, err := newKafkaSink(cfg.Kafka)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, s)
+	}
+
+	if len(sinks) == 0 {
+		return nil, nil
+	}
+
+	return newNotifier(cfg, logger, sinks...), nil
+}
+
+func newNotifier(cfg *Config, logger log.Logger, sinks ...Sink) *Notifier {
+	queueSize := cfg.QueueSize
+	if queueSize <= 0 {
+		queueSize = DefaultQueueSize
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	n := &Notifier{
+		cfg:    cfg,
+		sinks:  sinks,
+		queue:  make(chan *Event, queueSize),
+		logger: logger,
+		ctx:    ctx,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+
+	go n.loop()
+
+	return n
+}
+
+// Notify queues the event for delivery. It never blocks.
+func (n *Notifier) Notify(e *Event) {
+	if n == nil {
+		return
+	}
+
+	select {
+	case n.queue <- e:
+	default:
+		metricNotificationsDropped.WithLabelValues(string(e.Type)).Inc()
+	}
+}
+
+// Shutdown stops delivery and closes all sinks. Queued events are discarded.
+func (n *Notifier) Shutdown() {
+	if n == nil {
+		return
+	}
+
+	n.cancel()
+	<-n.done
+
+	for _, s := range n.sinks {
+		if err := s.Close(); err != nil {
+			level.Warn(n.logger).Log("msg", "failed to close notification sink", "err", err)
+		}
+	}
+}
+
+func (n *Notifier) loop() {
+	defer close(n.done)
+
+	for {
+		select {
+		case <-n.ctx.Done():
+			return
+		case e := <-n.queue:
+			n.deliver(e)
+		}
+	}
+}
+
+func (n *Notifier) deliver(e *Event) {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		level.Error(n.logger).Log("msg", "failed to marshal notification", "err", err)
+		metricNotificationsFailed.WithLabelValues(string(e.Type)).Inc()
+		return
+	}
+
+	for _, s := range n.sinks {
+		if err := n.send(s, e, payload); err != nil {
+			level.Error(n.logger).Log("msg", "failed to deliver notification", "type", e.Type, "blockID", e.BlockID, "tenantID", e.TenantID, "err", err)
+			metricNotificationsFailed.WithLabelValues(string(e.Type)).Inc()
+			continue
+		}
+		metricNotificationsSent.WithLabelValues(string(e.Type)).Inc()
+	}
+}
+
+func (n *Notifier) send(s Sink, e *Event, payload []byte) error {
+	maxRetries := n.cfg.MaxRetries
+	if maxRetries == 0 {
+		maxRetries = DefaultMaxRetries
+	} else if maxRetries < 0 {
+		// negative disables retries
+		maxRetries = 0
+	}
+	backoff := n.cfg.Backoff
+	if backoff <= 0 {
+		backoff = DefaultBackoff
+	}
+
+	var err error
+	for attempt := 0; attempt <= maxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-n.ctx.Done():
+				return err
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+
+		err = s.Send(n.ctx, e.TenantID, payload)
+		if err == nil {
+			return nil
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,134 @@
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tempo/tempodb/backend"
+)
+
+type mockSink struct {
+	mtx      sync.Mutex
+	failures int
+	payloads [][]byte
+}
+
+func (m *mockSink) Send(_ context.Context, _ string, payload []byte) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if m.failures > 0 {
+		m.failures--
+		return errors.New("failed")
+	}
+	m.payloads = append(m.payloads, payload)
+	return nil
+}
+
+func (m *mockSink) Close() error {
+	return nil
+}
+
+func (m *mockSink) received() int {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	return len(m.payloads)
+}
+
+func TestNotifierDisabled(t *testing.T) {
+	n, err := New(&Config{}, log.NewNopLogger())
+	require.NoError(t, err)
+	assert.Nil(t, n)
+
+	// nil notifier is safe to use
+	n.Notify(&Event{})
+	n.Shutdown()
+}
+
+func TestNotifierRetries(t *testing.T) {
+	sink := &mockSink{failures: 2}
+	n := newNotifier(&Config{MaxRetries: 2, Backoff: time.Millisecond}, log.NewNopLogger(), sink)
+	defer n.Shutdown()
+
+	meta := backend.NewBlockMeta("test", uuid.New(), "v2", backend.EncNone, "")
+	meta.Size = 100
+	meta.CompactionLevel = 2
+	n.Notify(NewEvent(EventBlockCompacted, meta))
+
+	require.Eventually(t, func() bool { return sink.received() == 1 }, time.Second, 5*time.Millisecond)
+
+	actual := &Event{}
+	require.NoError(t, json.Unmarshal(sink.payloads[0], actual))
+	assert.Equal(t, EventBlockCompacted, actual.Type)
+	assert.Equal(t, "test", actual.TenantID)
+	assert.Equal(t, meta.BlockID.String(), actual.BlockID)
+	assert.Equal(t, uint64(100), actual.Size)
+	assert.Equal(t, uint8(2), actual.CompactionLevel)
+}
+
+func TestNotifierDefaultRetries(t *testing.T) {
+	// max retries is unset
+	sink := &mockSink{failures: DefaultMaxRetries}
+	n := newNotifier(&Config{Backoff: time.Millisecond}, log.NewNopLogger(), sink)
+	defer n.Shutdown()
+
+	n.Notify(&Event{Type: EventBlockFlushed})
+	require.Eventually(t, func() bool { return sink.received() == 1 }, time.Second, 5*time.Millisecond)
+
+	// negative disables retries, the first event is never delivered
+	sink = &mockSink{failures: 1}
+	n2 := newNotifier(&Config{MaxRetries: -1, Backoff: time.Millisecond}, log.NewNopLogger(), sink)
+	defer n2.Shutdown()
+
+	n2.Notify(&Event{Type: EventBlockFlushed})
+	n2.Notify(&Event{Type: EventBlockDeleted})
+	require.Eventually(t, func() bool { return sink.received() == 1 }, time.Second, 5*time.Millisecond)
+
+	actual := &Event{}
+	require.NoError(t, json.Unmarshal(sink.payloads[0], actual))
+	assert.Equal(t, EventBlockDeleted, actual.Type)
+}
+
+func TestNotifierQueueFull(t *testing.T) {
+	n := &Notifier{
+		queue: make(chan *Event, 1),
+	}
+
+	// never blocks even though nothing drains the queue
+	n.Notify(&Event{Type: EventBlockFlushed})
+	n.Notify(&Event{Type: EventBlockFlushed})
+	assert.Len(t, n.queue, 1)
+}
+
+func TestWebhookSink(t *testing.T) {
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = ioutil.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	s := newWebhookSink(&WebhookConfig{URL: server.URL})
+	require.NoError(t, s.Send(context.Background(), "test", []byte(`{"type":"block_deleted"}`)))
+	assert.Equal(t, `{"type":"block_deleted"}`, string(body))
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	s = newWebhookSink(&WebhookConfig{URL: failing.URL})
+	assert.Error(t, s.Send(context.Background(), "test", []byte(`{}`)))
+}
@@ -0,0 +1,105 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/Shopify/sarama"
+)
+
+// Sink delivers a single serialized event downstream.
+type Sink interface {
+	Send(ctx context.Context, tenantID string, payload []byte) error
+	Close() error
+}
+
+type webhookSink struct {
+	url    string
+	client *http.Client
+}
+
+func newWebhookSink(cfg *WebhookConfig) *webhookSink {
+	timeout := cfg.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+
+	return &webhookSink{
+		url: cfg.URL,
+		client: &http.Client{
+			Timeout: timeout,
+		},
+	}
+}
+
+func (s *webhookSink) Send(ctx context.Context, _ string, payload []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook returned unexpected status %s", resp.Status)
+	}
+
+	return nil
+}
+
+func (s *webhookSink) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
+
+type kafkaSink struct {
+	topic    string
+	producer sarama.SyncProducer
+}
+
+func newKafkaSink(cfg *KafkaConfig) (*kafkaSink, error) {
+	timeout := cfg.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+
+	saramaCfg := sarama.NewConfig()
+	saramaCfg.Producer.Return.Successes = true
+	saramaCfg.Producer.RequiredAcks = sarama.WaitForAll
+	saramaCfg.Producer.Timeout = timeout
+	// retries are handled by the notifier
+	saramaCfg.Producer.Retry.Max = 0
+
+	producer, err := sarama.NewSyncProducer(cfg.Brokers, saramaCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &kafkaSink{
+		topic:    cfg.Topic,
+		producer: producer,
+	}, nil
+}
+
+func (s *kafkaSink) Send(_ context.Context, tenantID string, payload []byte) error {
+	_, _, err := s.producer.SendMessage(&sarama.ProducerMessage{
+		Topic: s.topic,
+		Key:   sarama.StringEncoder(tenantID),
+		Value: sarama.ByteEncoder(payload),
+	})
+	return err
+}
+
+func (s *kafkaSink) Close() error {
+	return s.producer.Close()
+}
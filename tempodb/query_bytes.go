@@ -0,0 +1,140 @@
+package tempodb
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/grafana/tempo/pkg/querystats"
+	"github.com/grafana/tempo/tempodb/backend"
+)
+
+// queryBytesOtherTenant is the tenant label of the bytes read for the tenants beyond QueryBytesReadMaxTenants.
+const queryBytesOtherTenant = "other"
+
+var metricQueryBytesRead = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "tempodb",
+	Name:      "query_bytes_read_total",
+	Help:      "Total number of bytes read from the backend by queries by tenant and object role.",
+}, []string{"tenant", "role"})
+
+// objectRole returns the role of a backend object of a block. The names are the ones written by the encoding and
+// search packages.
+func objectRole(name string) string {
+	switch {
+	case strings.HasPrefix(name, "bloom-"):
+		return querystats.RoleBloom
+	case name == "index" || name == "search-index":
+		return querystats.RoleIndex
+	case strings.HasSuffix(name, ".json") || name == "search-header":
+		return querystats.RoleMeta
+	default:
+		return querystats.RoleData
+	}
+}
+
+// queryBytesTenants limits the tenants of tempodb_query_bytes_read_total. The first max tenants that read bytes get
+// their own series, the bytes of all later tenants are counted as the other tenant. A max of 0 doesn't limit them.
+type queryBytesTenants struct {
+	mtx     sync.Mutex
+	max     int
+	tenants map[string]struct{}
+}
+
+func newQueryBytesTenants(max int) *queryBytesTenants {
+	return &queryBytesTenants{
+		max:     max,
+		tenants: map[string]struct{}{},
+	}
+}
+
+func (t *queryBytesTenants) label(tenantID string) string {
+	if t.max <= 0 {
+		return tenantID
+	}
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	if _, ok := t.tenants[tenantID]; ok {
+		return tenantID
+	}
+	if len(t.tenants) >= t.max {
+		return queryBytesOtherTenant
+	}
+	t.tenants[tenantID] = struct{}{}
+	return tenantID
+}
+
+// observe counts bytes read by a query of the tenant in tempodb_query_bytes_read_total and the query stats of ctx.
+func (t *queryBytesTenants) observe(ctx context.Context, tenantID string, name string, n int) {
+	if n <= 0 {
+		return
+	}
+
+	role := objectRole(name)
+	metricQueryBytesRead.WithLabelValues(t.label(tenantID), role).Add(float64(n))
+	querystats.FromContext(ctx).AddObjectBytesRead(role, n)
+}
+
+type queryTenantKey struct{}
+
+// withQueryTenant returns a context whose backend reads are counted as bytes read by a query of the tenant. The tenant
+// is the one of the query, not the one the blocks are stored under, e.g. for skeleton blocks.
+func withQueryTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, queryTenantKey{}, tenantID)
+}
+
+// queryBytesReader counts the bytes that queries read from the backend. It wraps the backend below the cache and the
+// coalescing of reads so only the bytes actually read from the backend are counted. Reads without the tenant of a
+// query, like the ones of the compactor and the poller, aren't counted.
+type queryBytesReader struct {
+	backend.RawReader
+	tenants *queryBytesTenants
+}
+
+func newQueryBytesReader(r backend.RawReader, tenants *queryBytesTenants) backend.RawReader {
+	return &queryBytesReader{
+		RawReader: r,
+		tenants:   tenants,
+	}
+}
+
+func (r *queryBytesReader) Read(ctx context.Context, name string, keyPath backend.KeyPath, shouldCache bool) (io.ReadCloser, int64, error) {
+	rc, size, err := r.RawReader.Read(ctx, name, keyPath, shouldCache)
+	if err != nil {
+		return nil, 0, err
+	}
+	tenantID, ok := ctx.Value(queryTenantKey{}).(string)
+	if !ok {
+		return rc, size, nil
+	}
+	return &queryReadCloser{ReadCloser: rc, ctx: ctx, tenants: r.tenants, tenantID: tenantID, name: name}, size, nil
+}
+
+func (r *queryBytesReader) ReadRange(ctx context.Context, name string, keyPath backend.KeyPath, offset uint64, buffer []byte) error {
+	err := r.RawReader.ReadRange(ctx, name, keyPath, offset, buffer)
+	if tenantID, ok := ctx.Value(queryTenantKey{}).(string); ok && err == nil {
+		r.tenants.observe(ctx, tenantID, name, len(buffer))
+	}
+	return err
+}
+
+// queryReadCloser counts the bytes of a streamed object as they are read.
+type queryReadCloser struct {
+	io.ReadCloser
+	ctx      context.Context
+	tenants  *queryBytesTenants
+	tenantID string
+	name     string
+}
+
+func (r *queryReadCloser) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.tenants.observe(r.ctx, r.tenantID, r.name, n)
+	return n, err
+}
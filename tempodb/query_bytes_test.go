@@ -0,0 +1,75 @@
+package tempodb
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+
+	cortex_cache "github.com/cortexproject/cortex/pkg/chunk/cache"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tempo/pkg/querystats"
+	"github.com/grafana/tempo/pkg/util/test"
+	"github.com/grafana/tempo/tempodb/backend"
+	"github.com/grafana/tempo/tempodb/backend/cache"
+)
+
+func TestObjectRole(t *testing.T) {
+	assert.Equal(t, querystats.RoleBloom, objectRole("bloom-0"))
+	assert.Equal(t, querystats.RoleIndex, objectRole("index"))
+	assert.Equal(t, querystats.RoleIndex, objectRole("search-index"))
+	assert.Equal(t, querystats.RoleData, objectRole("data"))
+	assert.Equal(t, querystats.RoleData, objectRole("search"))
+	assert.Equal(t, querystats.RoleMeta, objectRole("meta.json"))
+	assert.Equal(t, querystats.RoleMeta, objectRole("search.meta.json"))
+	assert.Equal(t, querystats.RoleMeta, objectRole("search-header"))
+}
+
+func TestQueryBytesTenants(t *testing.T) {
+	tenants := newQueryBytesTenants(2)
+	assert.Equal(t, "query-bytes-a", tenants.label("query-bytes-a"))
+	assert.Equal(t, "query-bytes-b", tenants.label("query-bytes-b"))
+	assert.Equal(t, queryBytesOtherTenant, tenants.label("query-bytes-c"))
+	// tenants already seen keep their series
+	assert.Equal(t, "query-bytes-a", tenants.label("query-bytes-a"))
+
+	stats := querystats.NewCollector()
+	ctx := querystats.NewContext(context.Background(), stats)
+	before, err := test.GetCounterValue(metricQueryBytesRead.WithLabelValues(queryBytesOtherTenant, querystats.RoleIndex))
+	require.NoError(t, err)
+	tenants.observe(ctx, "query-bytes-d", "index", 10)
+	tenants.observe(ctx, "query-bytes-a", "bloom-1", 5)
+	after, err := test.GetCounterValue(metricQueryBytesRead.WithLabelValues(queryBytesOtherTenant, querystats.RoleIndex))
+	require.NoError(t, err)
+	assert.Equal(t, float64(10), after-before)
+	assert.Equal(t, querystats.Stats{IndexBytesRead: 10, BloomBytesRead: 5}, stats.Stats())
+
+	unlimited := newQueryBytesTenants(0)
+	for _, tenant := range []string{"query-bytes-a", "query-bytes-b", "query-bytes-c"} {
+		assert.Equal(t, tenant, unlimited.label(tenant))
+	}
+}
+
+func TestQueryBytesReader(t *testing.T) {
+	raw := newQueryBytesReader(&backend.MockRawReader{R: []byte("0123456789")}, newQueryBytesTenants(0))
+	r, _, err := cache.NewCache(raw, &backend.MockRawWriter{}, cortex_cache.NewMockCache(), 0)
+	require.NoError(t, err)
+
+	read := func(ctx context.Context, name string) querystats.Stats {
+		stats := querystats.NewCollector()
+		rc, _, err := r.Read(querystats.NewContext(ctx, stats), name, backend.KeyPathForBlock(uuid.UUID{}, "query-bytes-reader"), true)
+		require.NoError(t, err)
+		_, err = ioutil.ReadAll(rc)
+		require.NoError(t, err)
+		return stats.Stats()
+	}
+
+	// reads outside of queries aren't counted
+	assert.Equal(t, querystats.Stats{}, read(context.Background(), "bloom-0"))
+	// only the reads from the backend are counted, not the cache hits
+	ctx := withQueryTenant(context.Background(), "query-bytes-reader")
+	assert.Equal(t, querystats.Stats{BloomBytesRead: 10}, read(ctx, "bloom-1"))
+	assert.Equal(t, querystats.Stats{}, read(ctx, "bloom-1"))
+}
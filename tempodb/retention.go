@@ -6,6 +6,7 @@ import (
 	"github.com/go-kit/kit/log/level"
 
 	"github.com/grafana/tempo/pkg/boundedwaitgroup"
+	"github.com/grafana/tempo/tempodb/notifications"
 )
 
 // todo: pass a context/chan in to cancel this cleanly
@@ -31,6 +32,10 @@ func (rw *readerWriter) doRetention() {
 	}
 
 	bg.Wait()
+
+	if rw.cfg.Skeleton.Enabled {
+		rw.retainSkeletons()
+	}
 }
 
 func (rw *readerWriter) retainTenant(tenantID string) {
@@ -72,6 +77,7 @@ func (rw *readerWriter) retainTenant(tenantID string) {
 				metricRetentionErrors.Inc()
 			} else {
 				metricDeleted.Inc()
+				rw.notifier.Notify(notifications.NewEvent(notifications.EventBlockDeleted, &b.BlockMeta))
 			}
 		}
 	}
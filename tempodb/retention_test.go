@@ -1,6 +1,7 @@
 package tempodb
 
 import (
+	"context"
 	"io/ioutil"
 	"os"
 	"path"
@@ -10,10 +11,12 @@ import (
 	"github.com/go-kit/kit/log"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/grafana/tempo/tempodb/backend"
 	"github.com/grafana/tempo/tempodb/backend/local"
 	"github.com/grafana/tempo/tempodb/encoding"
+	"github.com/grafana/tempo/tempodb/encoding/encodingtest"
 	"github.com/grafana/tempo/tempodb/wal"
 )
 
@@ -80,7 +83,7 @@ func TestBlockRetentionOverride(t *testing.T) {
 	defer os.RemoveAll(tempDir)
 	assert.NoError(t, err, "unexpected error creating temp dir")
 
-	r, w, c, err := New(&Config{
+	r, _, c, err := New(&Config{
 		Backend: "local",
 		Local: &local.Config{
 			Path: path.Join(tempDir, "traces"),
@@ -110,9 +113,17 @@ func TestBlockRetentionOverride(t *testing.T) {
 
 	r.EnablePolling(&mockJobSharder{})
 
-	cutTestBlocks(t, w, testTenantID, 10, 10)
-
 	rw := r.(*readerWriter)
+	for i := 0; i < 10; i++ {
+		_, err = encodingtest.WriteBlock(context.Background(), rw.w, encodingtest.BlockOptions{
+			Seed:      int64(i),
+			TenantID:  testTenantID,
+			Objects:   10,
+			StartTime: time.Now(),
+			EndTime:   time.Now(),
+		})
+		require.NoError(t, err)
+	}
 	rw.pollBlocklist()
 
 	// Retention = 1 hour, does nothing
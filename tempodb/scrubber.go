@@ -0,0 +1,199 @@
+package tempodb
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/atomic"
+	"golang.org/x/time/rate"
+
+	"github.com/grafana/tempo/tempodb/backend"
+	"github.com/grafana/tempo/tempodb/encoding"
+)
+
+const scrubCycle = time.Hour
+
+var (
+	metricScrubberBlocksChecked = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "scrubber_blocks_checked_total",
+		Help:      "Total number of blocks verified by the scrubber.",
+	})
+	metricScrubberBlocksCorrupt = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "scrubber_blocks_corrupt_total",
+		Help:      "Total number of blocks found corrupt by the scrubber.",
+	})
+	metricScrubberBlocksQuarantined = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "scrubber_blocks_quarantined_total",
+		Help:      "Total number of corrupt blocks marked compacted by the scrubber.",
+	})
+	metricScrubberErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "scrubber_errors_total",
+		Help:      "Total number of blocks that could not be verified due to backend errors.",
+	})
+	metricScrubberBytesRead = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "tempo",
+		Name:      "scrubber_bytes_read_total",
+		Help:      "Total number of bytes read from the backend by the scrubber.",
+	})
+)
+
+func (rw *readerWriter) scrubLoop() {
+	ticker := time.NewTicker(scrubCycle)
+	for range ticker.C {
+		rw.doScrub(context.Background())
+	}
+}
+
+// doScrub verifies a random sample of the blocks of every tenant within the configured budgets.
+func (rw *readerWriter) doScrub(ctx context.Context) {
+	cfg := rw.compactorCfg.Scrubber
+	r := newBudgetReader(rw.r, cfg.MaxRequestsPerSecond)
+
+	for _, tenantID := range rw.blocklist.Tenants() {
+		for _, meta := range rw.scrubSample(tenantID, cfg.BlocksPerTenantPerHour) {
+			if cfg.MaxBytesPerHour > 0 && r.bytesRead() >= cfg.MaxBytesPerHour {
+				level.Info(rw.logger).Log("msg", "scrubber byte budget exhausted", "bytes", r.bytesRead())
+				return
+			}
+
+			rw.scrubBlock(ctx, r, meta)
+		}
+	}
+}
+
+// scrubSample returns up to n random blocks of the tenant owned by this compactor
+func (rw *readerWriter) scrubSample(tenantID string, n int) []*backend.BlockMeta {
+	var owned []*backend.BlockMeta
+	for _, m := range rw.blocklist.Metas(tenantID) {
+		if rw.compactorSharder.Owns(m.BlockID.String()) {
+			owned = append(owned, m)
+		}
+	}
+
+	rand.Shuffle(len(owned), func(i, j int) { owned[i], owned[j] = owned[j], owned[i] })
+	if len(owned) > n {
+		owned = owned[:n]
+	}
+	return owned
+}
+
+func (rw *readerWriter) scrubBlock(ctx context.Context, r *budgetReader, meta *backend.BlockMeta) {
+	cfg := rw.compactorCfg.Scrubber
+
+	block, err := encoding.NewBackendBlock(meta, r)
+	if err == nil {
+		r.resetErr()
+		err = block.Verify(ctx, cfg.SampleDataPages)
+	}
+	metricScrubberBlocksChecked.Inc()
+	if err == nil {
+		return
+	}
+
+	// failed backend requests do not say anything about the block
+	if readErr := r.lastErr(); readErr != nil {
+		level.Warn(rw.logger).Log("msg", "scrubber unable to verify block", "blockID", meta.BlockID, "tenantID", meta.TenantID, "err", readErr)
+		metricScrubberErrors.Inc()
+		return
+	}
+
+	level.Error(rw.logger).Log("msg", "scrubber found corrupt block", "blockID", meta.BlockID, "tenantID", meta.TenantID, "err", err)
+	metricScrubberBlocksCorrupt.Inc()
+
+	if cfg.QuarantineCorruptBlocks {
+		level.Info(rw.logger).Log("msg", "quarantining corrupt block", "blockID", meta.BlockID, "tenantID", meta.TenantID)
+		markCompacted(rw, meta.TenantID, []*backend.BlockMeta{meta}, nil)
+		metricScrubberBlocksQuarantined.Inc()
+	}
+}
+
+// budgetReader rate limits and counts the reads of the scrubber. It remembers the last backend error other than
+// backend.ErrDoesNotExist so these can be told apart from corruption. A missing object is treated as corruption.
+type budgetReader struct {
+	backend.Reader
+
+	limiter *rate.Limiter
+	bytes   *atomic.Uint64
+
+	mtx sync.Mutex
+	err error
+}
+
+func newBudgetReader(r backend.Reader, qps float64) *budgetReader {
+	limit := rate.Inf
+	if qps > 0 {
+		limit = rate.Limit(qps)
+	}
+
+	return &budgetReader{
+		Reader:  r,
+		limiter: rate.NewLimiter(limit, 1),
+		bytes:   atomic.NewUint64(0),
+	}
+}
+
+func (b *budgetReader) Read(ctx context.Context, name string, blockID uuid.UUID, tenantID string, shouldCache bool) ([]byte, error) {
+	if err := b.limiter.Wait(ctx); err != nil {
+		b.recordErr(err)
+		return nil, err
+	}
+
+	buffer, err := b.Reader.Read(ctx, name, blockID, tenantID, shouldCache)
+	b.recordErr(err)
+	b.addBytes(len(buffer))
+	return buffer, err
+}
+
+func (b *budgetReader) ReadRange(ctx context.Context, name string, blockID uuid.UUID, tenantID string, offset uint64, buffer []byte) error {
+	if err := b.limiter.Wait(ctx); err != nil {
+		b.recordErr(err)
+		return err
+	}
+
+	err := b.Reader.ReadRange(ctx, name, blockID, tenantID, offset, buffer)
+	b.recordErr(err)
+	b.addBytes(len(buffer))
+	return err
+}
+
+func (b *budgetReader) addBytes(n int) {
+	b.bytes.Add(uint64(n))
+	metricScrubberBytesRead.Add(float64(n))
+}
+
+func (b *budgetReader) bytesRead() uint64 {
+	return b.bytes.Load()
+}
+
+func (b *budgetReader) recordErr(err error) {
+	if err == nil || errors.Is(err, backend.ErrDoesNotExist) {
+		return
+	}
+
+	b.mtx.Lock()
+	b.err = err
+	b.mtx.Unlock()
+}
+
+func (b *budgetReader) lastErr() error {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return b.err
+}
+
+func (b *budgetReader) resetErr() {
+	b.mtx.Lock()
+	b.err = nil
+	b.mtx.Unlock()
+}
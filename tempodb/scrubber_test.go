@@ -0,0 +1,205 @@
+package tempodb
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tempo/pkg/util/test"
+	"github.com/grafana/tempo/tempodb/backend"
+	"github.com/grafana/tempo/tempodb/backend/local"
+	"github.com/grafana/tempo/tempodb/encoding"
+	"github.com/grafana/tempo/tempodb/encoding/encodingtest"
+	"github.com/grafana/tempo/tempodb/wal"
+)
+
+func TestScrubber(t *testing.T) {
+	tests := []struct {
+		name       string
+		object     string
+		quarantine bool
+		expectedB  int
+		expectedCB int
+	}{
+		{
+			name:      "healthy",
+			expectedB: 1,
+		},
+		{
+			name:       "corrupt index",
+			object:     "index",
+			expectedB:  1,
+			expectedCB: 0,
+		},
+		{
+			name:       "corrupt data quarantined",
+			object:     "data",
+			quarantine: true,
+			expectedB:  0,
+			expectedCB: 1,
+		},
+		{
+			name:       "missing bloom quarantined",
+			object:     "bloom-0",
+			quarantine: true,
+			expectedB:  0,
+			expectedCB: 1,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tempDir, err := ioutil.TempDir("/tmp", "")
+			defer os.RemoveAll(tempDir)
+			require.NoError(t, err)
+
+			r, _, c, err := New(&Config{
+				Backend: "local",
+				Local: &local.Config{
+					Path: path.Join(tempDir, "traces"),
+				},
+				Block: &encoding.BlockConfig{
+					IndexDownsampleBytes: 1000,
+					BloomFP:              0.01,
+					BloomShardSizeBytes:  100_000,
+					Encoding:             backend.EncNone,
+					IndexPageSizeBytes:   1000,
+				},
+				WAL: &wal.Config{
+					Filepath: path.Join(tempDir, "wal"),
+				},
+				BlocklistPoll: 0,
+			}, log.NewNopLogger())
+			require.NoError(t, err)
+
+			c.EnableCompaction(&CompactorConfig{
+				ChunkSizeBytes:          10,
+				MaxCompactionRange:      time.Hour,
+				BlockRetention:          time.Hour,
+				CompactedBlockRetention: time.Hour,
+				Scrubber: ScrubberConfig{
+					Enabled:                 true,
+					BlocksPerTenantPerHour:  1,
+					SampleDataPages:         1000,
+					QuarantineCorruptBlocks: tc.quarantine,
+				},
+			}, &mockSharder{}, &mockOverrides{})
+
+			r.EnablePolling(&mockJobSharder{})
+			rw := r.(*readerWriter)
+
+			block, err := encodingtest.WriteBlock(context.Background(), rw.w, encodingtest.BlockOptions{
+				TenantID:       testTenantID,
+				Objects:        100,
+				MinObjectBytes: 1024,
+				StartTime:      time.Now(),
+			})
+			require.NoError(t, err)
+			meta := block.Meta
+			checkBlocklists(t, meta.BlockID, 1, 0, rw)
+
+			if tc.object != "" {
+				objectPath := path.Join(tempDir, "traces", testTenantID, meta.BlockID.String(), tc.object)
+				if tc.object == "bloom-0" {
+					require.NoError(t, os.Remove(objectPath))
+				} else {
+					corruptFile(t, objectPath)
+				}
+			}
+
+			checked := counterValue(t, metricScrubberBlocksChecked)
+			corrupt := counterValue(t, metricScrubberBlocksCorrupt)
+
+			rw.doScrub(context.Background())
+
+			assert.Equal(t, checked+1, counterValue(t, metricScrubberBlocksChecked))
+			if tc.object == "" {
+				assert.Equal(t, corrupt, counterValue(t, metricScrubberBlocksCorrupt))
+			} else {
+				assert.Equal(t, corrupt+1, counterValue(t, metricScrubberBlocksCorrupt))
+			}
+
+			assert.Len(t, rw.blocklist.Metas(testTenantID), tc.expectedB)
+			assert.Len(t, rw.blocklist.CompactedMetas(testTenantID), tc.expectedCB)
+		})
+	}
+}
+
+func TestScrubberByteBudget(t *testing.T) {
+	tempDir, err := ioutil.TempDir("/tmp", "")
+	defer os.RemoveAll(tempDir)
+	require.NoError(t, err)
+
+	r, _, c, err := New(&Config{
+		Backend: "local",
+		Local: &local.Config{
+			Path: path.Join(tempDir, "traces"),
+		},
+		Block: &encoding.BlockConfig{
+			IndexDownsampleBytes: 1000,
+			BloomFP:              0.01,
+			BloomShardSizeBytes:  100_000,
+			Encoding:             backend.EncNone,
+			IndexPageSizeBytes:   1000,
+		},
+		WAL: &wal.Config{
+			Filepath: path.Join(tempDir, "wal"),
+		},
+		BlocklistPoll: 0,
+	}, log.NewNopLogger())
+	require.NoError(t, err)
+
+	c.EnableCompaction(&CompactorConfig{
+		ChunkSizeBytes:     10,
+		MaxCompactionRange: time.Hour,
+		Scrubber: ScrubberConfig{
+			Enabled:                true,
+			BlocksPerTenantPerHour: 10,
+			MaxBytesPerHour:        1,
+		},
+	}, &mockSharder{}, &mockOverrides{})
+
+	r.EnablePolling(&mockJobSharder{})
+	rw := r.(*readerWriter)
+
+	for i := 0; i < 3; i++ {
+		_, err = encodingtest.WriteBlock(context.Background(), rw.w, encodingtest.BlockOptions{
+			Seed:           int64(i),
+			TenantID:       testTenantID,
+			Objects:        10,
+			MinObjectBytes: 1024,
+			StartTime:      time.Now(),
+		})
+		require.NoError(t, err)
+	}
+	rw.pollBlocklist()
+
+	// the first block exhausts the budget
+	checked := counterValue(t, metricScrubberBlocksChecked)
+	rw.doScrub(context.Background())
+	assert.Equal(t, checked+1, counterValue(t, metricScrubberBlocksChecked))
+}
+
+func corruptFile(t *testing.T, p string) {
+	b, err := ioutil.ReadFile(p)
+	require.NoError(t, err)
+
+	for i := len(b) / 4; i < len(b)/2; i++ {
+		b[i] = ^b[i]
+	}
+	require.NoError(t, ioutil.WriteFile(p, b, 0644))
+}
+
+func counterValue(t *testing.T, c prometheus.Counter) float64 {
+	v, err := test.GetCounterValue(c)
+	require.NoError(t, err)
+	return v
+}
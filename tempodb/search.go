@@ -0,0 +1,205 @@
+package tempodb
+
+import (
+	"context"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/opentracing/opentracing-go"
+	"github.com/pkg/errors"
+
+	"github.com/grafana/tempo/pkg/model"
+	"github.com/grafana/tempo/pkg/tempofb"
+	"github.com/grafana/tempo/pkg/tempopb"
+	"github.com/grafana/tempo/tempodb/backend"
+	"github.com/grafana/tempo/tempodb/encoding"
+	"github.com/grafana/tempo/tempodb/search"
+)
+
+const (
+	defaultSearchLimit = 20
+	// searchChunkSizeBytes is the size of the reads when iterating the objects of blocks without search data.
+	searchChunkSizeBytes = 1024 * 1024
+)
+
+// SearchBlocks searches the blocks of the tenant written between start and end for traces matching the request. The
+// time range is compared to the start and end times of the blocks. Blocks are searched newest first by up to
+// concurrency goroutines, through their search data if they have any and otherwise by unmarshalling and matching
+// every trace. The search stops once the limit of the request is reached or more than maxBytes were inspected. In the
+// latter case the returned bool is true as the results are partial. A maxBytes of 0 disables the limit.
+func (rw *readerWriter) SearchBlocks(ctx context.Context, tenantID string, req *tempopb.SearchRequest, start time.Time, end time.Time, concurrency int, maxBytes uint64) (*tempopb.SearchResponse, bool, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "store.SearchBlocks")
+	defer span.Finish()
+
+	maxResults := defaultSearchLimit
+	if req.Limit != 0 {
+		maxResults = int(req.Limit)
+	}
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	metas := searchableBlocks(rw.blocklist.Metas(tenantID), start, end)
+	span.SetTag("blocks", len(metas))
+
+	p := search.NewSearchPipeline(req)
+
+	sr := search.NewResults()
+	defer sr.Close()
+	sr.SetMaxBytesInspected(maxBytes)
+
+	var errMtx sync.Mutex
+	var searchErr error
+
+	blocks := make(chan *backend.BlockMeta, len(metas))
+	for _, m := range metas {
+		blocks <- m
+	}
+	close(blocks)
+
+	for i := 0; i < concurrency && i < len(metas); i++ {
+		sr.StartWorker()
+		go func() {
+			defer sr.FinishWorker()
+
+			for m := range blocks {
+				if sr.Quit() {
+					return
+				}
+
+				err := rw.searchBlock(ctx, m, p, sr)
+				if err != nil {
+					level.Error(rw.logger).Log("msg", "error searching block", "tenant", tenantID, "block", m.BlockID, "err", err)
+
+					errMtx.Lock()
+					if searchErr == nil {
+						searchErr = errors.Wrapf(err, "error searching block %s", m.BlockID)
+					}
+					errMtx.Unlock()
+					return
+				}
+			}
+		}()
+	}
+
+	sr.AllWorkersStarted()
+
+	resultsMap := map[string]*tempopb.TraceSearchMetadata{}
+	for result := range sr.Results() {
+		// Dedupe/combine results
+		if existing := resultsMap[result.TraceID]; existing != nil {
+			search.CombineSearchResults(existing, result)
+		} else {
+			resultsMap[result.TraceID] = result
+		}
+
+		if len(resultsMap) >= maxResults {
+			break
+		}
+	}
+
+	errMtx.Lock()
+	defer errMtx.Unlock()
+	if searchErr != nil {
+		return nil, false, searchErr
+	}
+
+	results := make([]*tempopb.TraceSearchMetadata, 0, len(resultsMap))
+	for _, result := range resultsMap {
+		results = append(results, result)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].StartTimeUnixNano > results[j].StartTimeUnixNano
+	})
+
+	partial := sr.BytesExhausted()
+	span.SetTag("partial", partial)
+
+	return &tempopb.SearchResponse{
+		Traces: results,
+		Metrics: &tempopb.SearchMetrics{
+			InspectedTraces: sr.TracesInspected(),
+			InspectedBytes:  sr.BytesInspected(),
+			InspectedBlocks: sr.BlocksInspected(),
+			SkippedBlocks:   sr.BlocksSkipped(),
+		},
+	}, partial, nil
+}
+
+// searchBlock searches a block through its search data or, if it has none, by iterating its objects.
+func (rw *readerWriter) searchBlock(ctx context.Context, meta *backend.BlockMeta, p search.Pipeline, sr *search.Results) error {
+	ctx = withQueryTenant(ctx, meta.TenantID)
+	_, err := search.ReadSearchBlockMeta(ctx, rw.rawR, meta.BlockID, meta.TenantID)
+	if err == nil {
+		return search.OpenBackendSearchBlock(rw.rawR, meta.BlockID, meta.TenantID).Search(ctx, p, sr)
+	}
+	if err != backend.ErrDoesNotExist {
+		return errors.Wrap(err, "error reading search block meta")
+	}
+
+	return rw.searchBlockObjects(ctx, meta, p, sr)
+}
+
+// searchBlockObjects searches a block without search data by extracting the search data of every trace.
+func (rw *readerWriter) searchBlockObjects(ctx context.Context, meta *backend.BlockMeta, p search.Pipeline, sr *search.Results) error {
+	block, err := encoding.NewBackendBlock(meta, rw.r)
+	if err != nil {
+		return err
+	}
+
+	iter, err := block.Iterator(searchChunkSizeBytes)
+	if err != nil {
+		return err
+	}
+	defer iter.Close()
+
+	sr.AddBlockInspected()
+
+	for !sr.Quit() {
+		id, obj, err := iter.Next(ctx)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		sr.AddTraceInspected(1)
+		sr.AddBytesInspected(uint64(len(obj)))
+
+		trace, err := model.Unmarshal(obj, meta.DataEncoding)
+		if err != nil {
+			return err
+		}
+
+		entry := tempofb.SearchEntryFromBytes(search.ExtractSearchData(trace, id))
+		if !p.Matches(entry) {
+			continue
+		}
+
+		if quit := sr.AddResult(ctx, search.GetSearchResultFromData(entry)); quit {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// searchableBlocks returns the blocks written between start and end, newest first.
+func searchableBlocks(metas []*backend.BlockMeta, start time.Time, end time.Time) []*backend.BlockMeta {
+	matching := make([]*backend.BlockMeta, 0, len(metas))
+	for _, m := range metas {
+		if m.StartTime.After(end) || m.EndTime.Before(start) {
+			continue
+		}
+		matching = append(matching, m)
+	}
+
+	sort.Slice(matching, func(i, j int) bool {
+		return matching[i].EndTime.After(matching[j].EndTime)
+	})
+	return matching
+}
@@ -23,7 +23,7 @@ const defaultBackendSearchBlockPageSize = 2 * 1024 * 1024
 type BackendSearchBlock struct {
 	id       uuid.UUID
 	tenantID string
-	l        *local.Backend
+	r        backend.RawReader
 }
 
 // NewBackendSearchBlock iterates through the given WAL search data and writes it to the persistent backend
@@ -132,11 +132,11 @@ func NewBackendSearchBlock(input *StreamingSearchBlock, l *local.Backend, blockI
 }
 
 // OpenBackendSearchBlock opens the search data for an existing block in the given backend.
-func OpenBackendSearchBlock(l *local.Backend, blockID uuid.UUID, tenantID string) *BackendSearchBlock {
+func OpenBackendSearchBlock(r backend.RawReader, blockID uuid.UUID, tenantID string) *BackendSearchBlock {
 	return &BackendSearchBlock{
 		id:       blockID,
 		tenantID: tenantID,
-		l:        l,
+		r:        r,
 	}
 }
 
@@ -148,7 +148,7 @@ func (s *BackendSearchBlock) Search(ctx context.Context, p Pipeline, sr *Results
 	indexBuf := []common.Record{{}}
 	entry := &tempofb.SearchEntry{} // Buffer
 
-	meta, err := ReadSearchBlockMeta(ctx, s.l, s.id, s.tenantID)
+	meta, err := ReadSearchBlockMeta(ctx, s.r, s.id, s.tenantID)
 	if err != nil {
 		return err
 	}
@@ -160,12 +160,12 @@ func (s *BackendSearchBlock) Search(ctx context.Context, p Pipeline, sr *Results
 
 	// Read header
 	// Verify something in the block matches by checking the header
-	hbr, hbrlen, err := s.l.Read(ctx, "search-header", backend.KeyPathForBlock(s.id, s.tenantID), true)
+	hbr, hbrlen, err := s.r.Read(ctx, "search-header", backend.KeyPathForBlock(s.id, s.tenantID), true)
 	if err != nil {
 		return err
 	}
 
-	sr.bytesInspected.Add(uint64(hbrlen))
+	sr.AddBytesInspected(uint64(hbrlen))
 
 	hb, err := tempo_io.ReadAllWithEstimate(hbr, hbrlen)
 	if err != nil {
@@ -183,14 +183,14 @@ func (s *BackendSearchBlock) Search(ctx context.Context, p Pipeline, sr *Results
 
 	// Read index
 	bmeta := backend.NewBlockMeta(s.tenantID, s.id, meta.Version, meta.Encoding, "")
-	cr := backend.NewContextReader(bmeta, "search-index", backend.NewReader(s.l), false)
+	cr := backend.NewContextReader(bmeta, "search-index", backend.NewReader(s.r), false)
 
 	ir, err := vers.NewIndexReader(cr, int(meta.IndexPageSize), int(meta.IndexRecords))
 	if err != nil {
 		return err
 	}
 
-	dcr := backend.NewContextReader(bmeta, "search", backend.NewReader(s.l), false)
+	dcr := backend.NewContextReader(bmeta, "search", backend.NewReader(s.r), false)
 	dr, err := vers.NewDataReader(dcr, meta.Encoding)
 	if err != nil {
 		return err
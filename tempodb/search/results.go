@@ -21,6 +21,9 @@ type Results struct {
 	bytesInspected  atomic.Uint64
 	blocksInspected atomic.Uint32
 	blocksSkipped   atomic.Uint32
+
+	maxBytes       uint64
+	bytesExhausted atomic.Bool
 }
 
 func NewResults() *Results {
@@ -116,7 +119,22 @@ func (sr *Results) BytesInspected() uint64 {
 }
 
 func (sr *Results) AddBytesInspected(c uint64) {
-	sr.bytesInspected.Add(c)
+	if n := sr.bytesInspected.Add(c); sr.maxBytes > 0 && n > sr.maxBytes {
+		sr.bytesExhausted.Store(true)
+		sr.quit.Store(true)
+	}
+}
+
+// SetMaxBytesInspected limits the number of bytes the search inspects. Once more bytes were inspected search tasks
+// are signaled to quit and BytesExhausted returns true. 0 disables the limit. Must be called before any worker starts.
+func (sr *Results) SetMaxBytesInspected(max uint64) {
+	sr.maxBytes = max
+}
+
+// BytesExhausted returns if the search quit early because it inspected more than the max bytes. The results are
+// partial in this case.
+func (sr *Results) BytesExhausted() bool {
+	return sr.bytesExhausted.Load()
 }
 
 func (sr *Results) AddBlockInspected() {
@@ -1,24 +1,33 @@
 package search
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"encoding/binary"
+	"fmt"
 	"io"
 	"os"
 
 	"github.com/grafana/tempo/pkg/tempofb"
 	"github.com/grafana/tempo/tempodb/encoding"
 	"github.com/grafana/tempo/tempodb/encoding/common"
+	v2 "github.com/grafana/tempo/tempodb/encoding/v2"
 	"github.com/pkg/errors"
 )
 
 var _ SearchableBlock = (*StreamingSearchBlock)(nil)
 var _ common.DataWriter = (*StreamingSearchBlock)(nil)
 
+// Entries are written with their trace id so the file can be replayed after a restart.
+var streamingObjectReaderWriter = v2.NewObjectReaderWriter()
+
 // StreamingSearchBlock is search data that is read/write, i.e. for traces in the WAL.
 type StreamingSearchBlock struct {
 	appender     encoding.Appender
 	file         *os.File
 	bytesWritten int
+	buffer       bytes.Buffer
 }
 
 // Clear deletes the files for this block.
@@ -40,16 +49,21 @@ func (s *StreamingSearchBlock) CutPage() (int, error) {
 
 // Write the entry to the end of the file. The number of bytes written is saved and returned through CutPage.
 func (s *StreamingSearchBlock) Write(id common.ID, obj []byte) (int, error) {
-	var err error
+	s.buffer.Reset()
+	_, err := streamingObjectReaderWriter.MarshalObjectToWriter(id, obj, &s.buffer)
+	if err != nil {
+		return 0, err
+	}
 
-	_, err = s.file.Write(obj)
+	// The entry is written at once so a crash leaves at most one partial entry at the end of the file.
+	n, err := s.file.Write(s.buffer.Bytes())
 	if err != nil {
 		return 0, err
 	}
 
-	s.bytesWritten += len(obj)
+	s.bytesWritten += n
 
-	return len(obj), err
+	return n, err
 }
 
 // NewStreamingSearchBlockForFile creates a new streaming block that will read/write the given file.
@@ -66,6 +80,97 @@ func NewStreamingSearchBlockForFile(f *os.File) (*StreamingSearchBlock, error) {
 	return s, nil
 }
 
+// NewStreamingSearchBlockFromFile replays the search data of an existing file written by a streaming block, i.e.
+// after a restart. The file is truncated at the first entry that can not be read and the error is returned as a
+// warning. Replayed blocks are read only.
+func NewStreamingSearchBlockFromFile(f *os.File) (*StreamingSearchBlock, error, error) {
+	var warning error
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	_, err = f.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var buffer []byte
+	var records []common.Record
+	r := bufio.NewReader(f)
+	currentOffset := uint64(0)
+	for {
+		var id common.ID
+		buffer, id, err = readStreamingObject(r, buffer, uint64(info.Size())-currentOffset)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			warning = err
+			break
+		}
+
+		records = append(records, common.Record{
+			ID:     append([]byte(nil), id...),
+			Start:  currentOffset,
+			Length: uint32(len(buffer)),
+		})
+		currentOffset += uint64(len(buffer))
+	}
+
+	// drop everything after the last good entry so the corrupt data is not replayed again
+	if warning != nil {
+		err = f.Truncate(int64(currentOffset))
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	common.SortRecords(records)
+
+	return &StreamingSearchBlock{
+		file:     f,
+		appender: encoding.NewRecordAppender(records),
+	}, warning, nil
+}
+
+// readStreamingObject reads the next entry into buffer and returns the buffer and the id of the entry. remaining is
+// the size of the rest of the file and protects against allocating a corrupt length.
+func readStreamingObject(r io.Reader, buffer []byte, remaining uint64) ([]byte, common.ID, error) {
+	var totalLength uint32
+	err := binary.Read(r, binary.LittleEndian, &totalLength)
+	if err == io.EOF {
+		return nil, nil, io.EOF
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if uint64(totalLength) > remaining {
+		return nil, nil, fmt.Errorf("entry length %d exceeds remaining file size %d", totalLength, remaining)
+	}
+
+	if cap(buffer) < int(totalLength) {
+		buffer = make([]byte, totalLength)
+	}
+	buffer = buffer[:totalLength]
+	binary.LittleEndian.PutUint32(buffer, totalLength)
+
+	if totalLength > 4 {
+		_, err = io.ReadFull(r, buffer[4:])
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	_, id, _, err := streamingObjectReaderWriter.UnmarshalAndAdvanceBuffer(buffer)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return buffer, id, nil
+}
+
 // Append the given search data to the streaming block. Multiple byte buffers of search data for
 // the same trace can be passed and are merged into one entry.
 func (s *StreamingSearchBlock) Append(ctx context.Context, id common.ID, searchData [][]byte) error {
@@ -88,10 +193,6 @@ func (s *StreamingSearchBlock) Search(ctx context.Context, p Pipeline, sr *Resul
 			return nil
 		}
 
-		if r.Length == 0 {
-			continue
-		}
-
 		// Reset/resize buffer
 		if cap(buf) < int(r.Length) {
 			buf = make([]byte, r.Length)
@@ -103,10 +204,20 @@ func (s *StreamingSearchBlock) Search(ctx context.Context, p Pipeline, sr *Resul
 			return err
 		}
 
-		sr.AddBytesInspected(uint64(r.Length))
+		_, _, obj, err := streamingObjectReaderWriter.UnmarshalAndAdvanceBuffer(buf)
+		if err != nil {
+			return err
+		}
+
+		// Traces without search data
+		if len(obj) == 0 {
+			continue
+		}
+
+		sr.AddBytesInspected(uint64(len(obj)))
 		sr.AddTraceInspected(1)
 
-		entry := tempofb.SearchEntryFromBytes(buf)
+		entry := tempofb.SearchEntryFromBytes(obj)
 
 		if !p.Matches(entry) {
 			continue
@@ -158,9 +269,14 @@ func (s *streamingSearchBlockIterator) Next(_ context.Context) (common.ID, []byt
 		return nil, nil, errors.Wrap(err, "error reading search file")
 	}
 
+	_, _, obj, err := streamingObjectReaderWriter.UnmarshalAndAdvanceBuffer(buffer)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "error unmarshalling search entry")
+	}
+
 	s.currentIndex++
 
-	return currentRecord.ID, buffer, nil
+	return currentRecord.ID, obj, nil
 }
 
 func (*streamingSearchBlockIterator) Close() {
@@ -65,6 +65,57 @@ func TestStreamingSearchBlockSearch(t *testing.T) {
 	require.Equal(t, traceCount, int(sr.TracesInspected()))
 }
 
+func TestStreamingSearchBlockReplay(t *testing.T) {
+	traceCount := 10
+
+	sb := newStreamingSearchBlockWithTraces(traceCount, t)
+	info, err := sb.file.Stat()
+	require.NoError(t, err)
+
+	// partial entry at the end of the file
+	_, err = sb.file.Write([]byte{0x01, 0x02, 0x03})
+	require.NoError(t, err)
+	require.NoError(t, sb.file.Close())
+
+	f, err := os.OpenFile(sb.file.Name(), os.O_RDWR, 0644)
+	require.NoError(t, err)
+
+	replayed, warning, err := NewStreamingSearchBlockFromFile(f)
+	require.NoError(t, err)
+	require.Error(t, warning)
+	require.Len(t, replayed.appender.Records(), traceCount)
+
+	// truncated to the last good entry
+	replayedInfo, err := f.Stat()
+	require.NoError(t, err)
+	require.Equal(t, info.Size(), replayedInfo.Size())
+
+	p := NewSearchPipeline(&tempopb.SearchRequest{
+		Tags: map[string]string{"key1": "value10"},
+	})
+
+	sr := NewResults()
+	sr.StartWorker()
+	go func() {
+		defer sr.FinishWorker()
+		err := replayed.Search(context.TODO(), p, sr)
+		require.NoError(t, err)
+	}()
+	sr.AllWorkersStarted()
+
+	var results []*tempopb.TraceSearchMetadata
+	for r := range sr.Results() {
+		results = append(results, r)
+	}
+	require.Equal(t, traceCount, len(results))
+
+	// replaying again finds no corruption
+	replayed, warning, err = NewStreamingSearchBlockFromFile(f)
+	require.NoError(t, err)
+	require.NoError(t, warning)
+	require.Len(t, replayed.appender.Records(), traceCount)
+}
+
 func BenchmarkStreamingSearchBlockSearch(b *testing.B) {
 
 	sb := newStreamingSearchBlockWithTraces(b.N, b)
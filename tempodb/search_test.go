@@ -0,0 +1,125 @@
+package tempodb
+
+import (
+	"context"
+	"math/rand"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tempo/pkg/model"
+	"github.com/grafana/tempo/pkg/tempopb"
+	v1_common "github.com/grafana/tempo/pkg/tempopb/common/v1"
+	v1_resource "github.com/grafana/tempo/pkg/tempopb/resource/v1"
+	"github.com/grafana/tempo/pkg/util/test"
+	"github.com/grafana/tempo/tempodb/backend"
+	"github.com/grafana/tempo/tempodb/search"
+)
+
+func TestSearchBlocks(t *testing.T) {
+	r, w, _, tempDir := testConfig(t, backend.EncSnappy, 0)
+	defer os.RemoveAll(tempDir)
+
+	// two blocks with a trace of each service
+	for i := 0; i < 2; i++ {
+		head, err := w.WAL().NewBlock(uuid.New(), testTenantID, model.CurrentEncoding)
+		require.NoError(t, err)
+
+		for _, service := range []string{"svc-a", "svc-b"} {
+			id := make([]byte, 16)
+			rand.Read(id)
+			require.NoError(t, head.Write(id, searchTestObject(t, id, service)))
+		}
+
+		_, err = w.CompleteBlock(head, &mockSharder{})
+		require.NoError(t, err)
+	}
+	r.EnablePolling(&mockJobSharder{})
+
+	ctx := context.Background()
+	now := time.Now()
+	req := &tempopb.SearchRequest{
+		Tags: map[string]string{search.ServiceNameTag: "svc-a"},
+	}
+
+	resp, partial, err := r.SearchBlocks(ctx, testTenantID, req, now.Add(-time.Hour), now.Add(time.Hour), 2, 0)
+	require.NoError(t, err)
+	assert.False(t, partial)
+	require.Len(t, resp.Traces, 2)
+	for _, tr := range resp.Traces {
+		assert.Equal(t, "svc-a", tr.RootServiceName)
+		assert.Equal(t, "test", tr.RootTraceName)
+		assert.Equal(t, uint32(1000), tr.DurationMs)
+	}
+	assert.Equal(t, uint32(2), resp.Metrics.InspectedBlocks)
+	assert.Equal(t, uint32(4), resp.Metrics.InspectedTraces)
+
+	// the limit of the request
+	resp, _, err = r.SearchBlocks(ctx, testTenantID, &tempopb.SearchRequest{Limit: 1}, now.Add(-time.Hour), now.Add(time.Hour), 2, 0)
+	require.NoError(t, err)
+	assert.Len(t, resp.Traces, 1)
+
+	// blocks outside the time range are not searched
+	resp, _, err = r.SearchBlocks(ctx, testTenantID, req, now.Add(-2*time.Hour), now.Add(-time.Hour), 2, 0)
+	require.NoError(t, err)
+	assert.Empty(t, resp.Traces)
+	assert.Equal(t, uint32(0), resp.Metrics.InspectedBlocks)
+
+	// the search stops once the max bytes were inspected
+	resp, partial, err = r.SearchBlocks(ctx, testTenantID, req, now.Add(-time.Hour), now.Add(time.Hour), 1, 1)
+	require.NoError(t, err)
+	assert.True(t, partial)
+	assert.Equal(t, uint32(1), resp.Metrics.InspectedBlocks)
+	assert.Equal(t, uint32(1), resp.Metrics.InspectedTraces)
+}
+
+func TestSearchableBlocks(t *testing.T) {
+	meta := func(start, end int64) *backend.BlockMeta {
+		return &backend.BlockMeta{
+			BlockID:   uuid.New(),
+			StartTime: time.Unix(start, 0),
+			EndTime:   time.Unix(end, 0),
+		}
+	}
+	a := meta(0, 10)
+	b := meta(10, 20)
+	c := meta(30, 40)
+	metas := []*backend.BlockMeta{a, b, c}
+
+	assert.Equal(t, []*backend.BlockMeta{b, a}, searchableBlocks(metas, time.Unix(5, 0), time.Unix(15, 0)))
+	assert.Equal(t, []*backend.BlockMeta{c, b, a}, searchableBlocks(metas, time.Unix(0, 0), time.Unix(40, 0)))
+	assert.Empty(t, searchableBlocks(metas, time.Unix(21, 0), time.Unix(29, 0)))
+}
+
+// searchTestObject returns a trace of the service that took a second encoded as an object of the current encoding.
+func searchTestObject(t *testing.T, id []byte, service string) []byte {
+	trace := test.MakeTrace(1, id)
+	start := uint64(time.Now().UnixNano())
+	for _, b := range trace.Batches {
+		b.Resource = &v1_resource.Resource{
+			Attributes: []*v1_common.KeyValue{
+				{
+					Key:   search.ServiceNameTag,
+					Value: &v1_common.AnyValue{Value: &v1_common.AnyValue_StringValue{StringValue: service}},
+				},
+			},
+		}
+		for _, ils := range b.InstrumentationLibrarySpans {
+			for _, s := range ils.Spans {
+				s.StartTimeUnixNano = start
+				s.EndTimeUnixNano = start + uint64(time.Second)
+			}
+		}
+	}
+
+	b, err := proto.Marshal(trace)
+	require.NoError(t, err)
+	obj, err := proto.Marshal(&tempopb.TraceBytes{Traces: [][]byte{b}})
+	require.NoError(t, err)
+	return obj
+}
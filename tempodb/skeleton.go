@@ -0,0 +1,319 @@
+package tempodb
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/google/uuid"
+	"github.com/opentracing/opentracing-go"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/grafana/tempo/pkg/model"
+	"github.com/grafana/tempo/tempodb/backend"
+	"github.com/grafana/tempo/tempodb/encoding"
+	"github.com/grafana/tempo/tempodb/encoding/common"
+)
+
+var (
+	metricSkeletonBlocksWritten = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "tempodb",
+		Name:      "skeleton_blocks_written_total",
+		Help:      "Total number of skeleton blocks written.",
+	})
+	metricSkeletonBlocksDeleted = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "tempodb",
+		Name:      "skeleton_blocks_deleted_total",
+		Help:      "Total number of skeleton blocks deleted by compaction or retention.",
+	})
+	metricSkeletonErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "tempodb",
+		Name:      "skeleton_errors_total",
+		Help:      "Total number of errors polling or deleting skeleton blocks.",
+	})
+)
+
+// skeletonBlock is written along with a block and holds the skeletons of its traces. It has the same id as the block
+// and is stored under backend.SkeletonTenantID of its tenant. Objects must be added in the order they are added to
+// the block. A nil skeletonBlock doesn't write anything, so callers don't need to check if skeletons are enabled.
+type skeletonBlock struct {
+	block     *encoding.StreamingBlock
+	tracker   backend.AppendTracker
+	summaryID []byte
+	flushSize int
+}
+
+// newSkeletonBlock returns the skeleton block of the block with meta, or nil if skeletons are disabled.
+func (rw *readerWriter) newSkeletonBlock(meta *backend.BlockMeta, estimatedObjects int) (*skeletonBlock, error) {
+	if !rw.cfg.Skeleton.Enabled {
+		return nil, nil
+	}
+
+	block, err := encoding.NewStreamingBlock(rw.cfg.Block, meta.BlockID, backend.SkeletonTenantID(meta.TenantID), []*backend.BlockMeta{meta}, meta.DataEncoding, estimatedObjects)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating skeleton block")
+	}
+
+	flushSize := DefaultFlushSizeBytes
+	if rw.compactorCfg != nil && rw.compactorCfg.FlushSizeBytes > 0 {
+		flushSize = rw.compactorCfg.FlushSizeBytes
+	}
+
+	return &skeletonBlock{
+		block: block,
+		// the summary spans of the skeletons written by different blocks have different ids so they are all kept
+		// when the skeletons of a trace are combined
+		summaryID: append([]byte(nil), meta.BlockID[:8]...),
+		flushSize: int(flushSize),
+	}, nil
+}
+
+// add adds the skeleton of the trace in obj, flushing the skeletons buffered so far to w if needed.
+func (s *skeletonBlock) add(ctx context.Context, w backend.Writer, id common.ID, obj []byte) error {
+	if s == nil {
+		return nil
+	}
+
+	skeleton, err := model.SkeletonBytes(obj, s.block.BlockMeta().DataEncoding, s.summaryID)
+	if err != nil {
+		return errors.Wrap(err, "error creating skeleton")
+	}
+
+	err = s.block.AddObject(id, skeleton)
+	if err != nil {
+		return errors.Wrap(err, "error adding object to skeleton block")
+	}
+
+	if s.block.CurrentBufferLength() > s.flushSize {
+		s.tracker, _, err = s.block.FlushBuffer(ctx, s.tracker, w)
+		if err != nil {
+			return errors.Wrap(err, "error flushing skeleton block")
+		}
+	}
+
+	return nil
+}
+
+// complete writes the rest of the skeleton block to w and marks meta, the meta of the block it belongs to, as having
+// a skeleton. It must be called before the block is completed, so every visible block that has a skeleton has a
+// complete one. The skeleton block takes the start and end time of the block.
+func (s *skeletonBlock) complete(ctx context.Context, w backend.Writer, meta *backend.BlockMeta) error {
+	if s == nil {
+		return nil
+	}
+
+	s.block.BlockMeta().StartTime = meta.StartTime
+	s.block.BlockMeta().EndTime = meta.EndTime
+	_, err := s.block.Complete(ctx, s.tracker, w)
+	if err != nil {
+		return errors.Wrap(err, "error completing skeleton block")
+	}
+
+	meta.HasSkeleton = true
+	metricSkeletonBlocksWritten.Inc()
+	return nil
+}
+
+// skeletonList holds the metas of the skeleton blocks of every tenant by block id.
+type skeletonList struct {
+	mtx   sync.RWMutex
+	metas map[string]map[uuid.UUID]*backend.BlockMeta
+}
+
+func newSkeletonList() *skeletonList {
+	return &skeletonList{
+		metas: map[string]map[uuid.UUID]*backend.BlockMeta{},
+	}
+}
+
+func (l *skeletonList) Tenants() []string {
+	l.mtx.RLock()
+	defer l.mtx.RUnlock()
+
+	tenants := make([]string, 0, len(l.metas))
+	for t := range l.metas {
+		tenants = append(tenants, t)
+	}
+	return tenants
+}
+
+func (l *skeletonList) Metas(tenantID string) map[uuid.UUID]*backend.BlockMeta {
+	l.mtx.RLock()
+	defer l.mtx.RUnlock()
+
+	metas := make(map[uuid.UUID]*backend.BlockMeta, len(l.metas[tenantID]))
+	for id, m := range l.metas[tenantID] {
+		metas[id] = m
+	}
+	return metas
+}
+
+func (l *skeletonList) apply(tenantID string, metas map[uuid.UUID]*backend.BlockMeta) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	if len(metas) == 0 {
+		delete(l.metas, tenantID)
+		return
+	}
+	l.metas[tenantID] = metas
+}
+
+func (l *skeletonList) remove(tenantID string, blockID uuid.UUID) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	delete(l.metas[tenantID], blockID)
+}
+
+// pollSkeletons lists the skeleton blocks of every tenant. Only the metas of the blocks that were not polled before
+// are read, skeleton blocks are never modified once written.
+func (rw *readerWriter) pollSkeletons() {
+	ctx := context.Background()
+
+	tenants, err := rw.rawR.List(ctx, backend.KeyPath{backend.SkeletonKeyPath})
+	if err != nil {
+		level.Error(rw.logger).Log("msg", "failed to poll skeleton tenants. using previously polled lists", "err", err)
+		metricSkeletonErrors.Inc()
+		return
+	}
+
+	polled := map[string]struct{}{}
+	for _, tenantID := range tenants {
+		if tenantID == "" {
+			continue
+		}
+		polled[tenantID] = struct{}{}
+
+		blockIDs, err := rw.r.Blocks(ctx, backend.SkeletonTenantID(tenantID))
+		if err != nil {
+			level.Error(rw.logger).Log("msg", "failed to poll skeleton blocks. using previously polled list", "tenantID", tenantID, "err", err)
+			metricSkeletonErrors.Inc()
+			continue
+		}
+
+		previous := rw.skeletons.Metas(tenantID)
+		metas := make(map[uuid.UUID]*backend.BlockMeta, len(blockIDs))
+		for _, id := range blockIDs {
+			if m, ok := previous[id]; ok {
+				metas[id] = m
+				continue
+			}
+
+			m, err := rw.r.BlockMeta(ctx, id, backend.SkeletonTenantID(tenantID))
+			if err == backend.ErrDoesNotExist {
+				// the skeleton block is being written or deleted
+				continue
+			}
+			if err != nil {
+				level.Error(rw.logger).Log("msg", "failed to read skeleton meta", "tenantID", tenantID, "blockID", id, "err", err)
+				metricSkeletonErrors.Inc()
+				continue
+			}
+			metas[id] = m
+		}
+
+		rw.skeletons.apply(tenantID, metas)
+	}
+
+	for _, tenantID := range rw.skeletons.Tenants() {
+		if _, ok := polled[tenantID]; !ok {
+			rw.skeletons.apply(tenantID, nil)
+		}
+	}
+}
+
+// FindSkeleton returns the skeletons of the trace with the id in the skeleton blocks between blockStart and blockEnd
+// whose blocks are not in the blocklist anymore, i.e. the parts of the trace that were deleted by retention. Like Find
+// it only searches the blocks that overlap the time range between start and end.
+func (rw *readerWriter) FindSkeleton(ctx context.Context, tenantID string, id common.ID, blockStart string, blockEnd string, start time.Time, end time.Time) ([][]byte, []string, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "store.FindSkeleton")
+	defer span.Finish()
+
+	blockStartBytes, blockEndBytes, err := parseBlockRange(blockStart, blockEnd)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	live := map[uuid.UUID]struct{}{}
+	for _, b := range rw.blocklist.Metas(tenantID) {
+		live[b.BlockID] = struct{}{}
+	}
+	for _, c := range rw.blocklist.CompactedMetas(tenantID) {
+		live[c.BlockID] = struct{}{}
+	}
+
+	var candidates []interface{}
+	for blockID, m := range rw.skeletons.Metas(tenantID) {
+		if _, ok := live[blockID]; ok {
+			continue
+		}
+		if includeBlock(m, id, blockStartBytes, blockEndBytes) && overlapsTimeRange(m, start, end) {
+			candidates = append(candidates, m)
+		}
+	}
+
+	if len(candidates) == 0 {
+		return nil, nil, nil
+	}
+
+	return rw.pool.RunJobs(ctx, candidates, func(ctx context.Context, payload interface{}) ([]byte, string, error) {
+		meta := payload.(*backend.BlockMeta)
+
+		block, err := encoding.NewBackendBlock(meta, rw.r)
+		if err != nil {
+			return nil, "", err
+		}
+
+		foundObject, err := block.Find(withQueryTenant(ctx, tenantID), id)
+		if err != nil {
+			return nil, "", err
+		}
+
+		return foundObject, meta.DataEncoding, nil
+	})
+}
+
+// clearSkeletons deletes the skeleton blocks of compacted blocks. The skeletons of their traces were written again
+// along with the blocks they were compacted into.
+func (rw *readerWriter) clearSkeletons(tenantID string, metas []*backend.BlockMeta) {
+	for _, m := range metas {
+		if !m.HasSkeleton {
+			continue
+		}
+
+		err := rw.c.ClearBlock(m.BlockID, backend.SkeletonTenantID(tenantID))
+		if err != nil {
+			level.Error(rw.logger).Log("msg", "failed to clear skeleton of compacted block", "blockID", m.BlockID, "tenantID", tenantID, "err", err)
+			metricSkeletonErrors.Inc()
+			continue
+		}
+		rw.skeletons.remove(tenantID, m.BlockID)
+		metricSkeletonBlocksDeleted.Inc()
+	}
+}
+
+// retainSkeletons deletes the skeleton blocks older than the skeleton retention.
+func (rw *readerWriter) retainSkeletons() {
+	cutoff := time.Now().Add(-rw.cfg.Skeleton.Retention)
+	for _, tenantID := range rw.skeletons.Tenants() {
+		for blockID, m := range rw.skeletons.Metas(tenantID) {
+			if !m.EndTime.Before(cutoff) || !rw.compactorSharder.Owns(blockID.String()) {
+				continue
+			}
+
+			level.Info(rw.logger).Log("msg", "deleting skeleton block", "blockID", blockID, "tenantID", tenantID)
+			err := rw.c.ClearBlock(blockID, backend.SkeletonTenantID(tenantID))
+			if err != nil {
+				level.Error(rw.logger).Log("msg", "failed to clear skeleton block during retention", "blockID", blockID, "tenantID", tenantID, "err", err)
+				metricSkeletonErrors.Inc()
+				continue
+			}
+			rw.skeletons.remove(tenantID, blockID)
+			metricSkeletonBlocksDeleted.Inc()
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package tempodb
+
+import (
+	"context"
+	"math/rand"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/tempo/pkg/model"
+	"github.com/grafana/tempo/pkg/util/test"
+	"github.com/grafana/tempo/tempodb/backend"
+	"github.com/grafana/tempo/tempodb/encoding"
+)
+
+func TestSkeleton(t *testing.T) {
+	r, w, c, tempDir := testConfig(t, backend.EncNone, 0)
+	defer os.RemoveAll(tempDir)
+
+	rw := r.(*readerWriter)
+	rw.cfg.Skeleton = SkeletonConfig{Enabled: true, Retention: time.Hour}
+	c.EnableCompaction(&CompactorConfig{
+		ChunkSizeBytes:          10,
+		MaxCompactionRange:      time.Hour,
+		BlockRetention:          time.Hour,
+		CompactedBlockRetention: time.Hour,
+	}, &mockSharder{}, &mockOverrides{})
+
+	// two blocks with parts of the same trace
+	id := make([]byte, 16)
+	rand.Read(id)
+	var objs [][]byte
+	for i := 1; i <= 2; i++ {
+		obj, err := proto.Marshal(test.MakeTraceWithSpanCount(i, 5, id))
+		require.NoError(t, err)
+		objs = append(objs, obj)
+
+		head, err := w.WAL().NewBlock(uuid.New(), testTenantID, model.TracePBEncoding)
+		require.NoError(t, err)
+		require.NoError(t, head.Write(id, obj))
+		complete, err := w.CompleteBlock(head, &mockSharder{})
+		require.NoError(t, err)
+		assert.True(t, complete.BlockMeta().HasSkeleton)
+	}
+	r.EnablePolling(&mockJobSharder{})
+
+	blocks := rw.blocklist.Metas(testTenantID)
+	require.Len(t, blocks, 2)
+	skeletons := rw.skeletons.Metas(testTenantID)
+	require.Len(t, skeletons, 2)
+	for _, b := range blocks {
+		assert.Contains(t, skeletons, b.BlockID)
+	}
+
+	// skeletons are not served while the blocks exist
+	found, _, err := r.FindSkeleton(context.Background(), testTenantID, id, BlockIDMin, BlockIDMax, time.Time{}, time.Time{})
+	require.NoError(t, err)
+	assert.Empty(t, found)
+
+	// compaction writes the skeletons of the new block and deletes the ones of the compacted blocks
+	require.NoError(t, rw.compact(blocks, testTenantID))
+	rw.pollBlocklist()
+	blocks = rw.blocklist.Metas(testTenantID)
+	require.Len(t, blocks, 1)
+	assert.True(t, blocks[0].HasSkeleton)
+	skeletons = rw.skeletons.Metas(testTenantID)
+	require.Len(t, skeletons, 1)
+	assert.Contains(t, skeletons, blocks[0].BlockID)
+
+	// the skeleton is served once the block is deleted
+	compactedID := blocks[0].BlockID
+	require.NoError(t, rw.c.MarkBlockCompacted(compactedID, testTenantID))
+	require.NoError(t, rw.c.ClearBlock(compactedID, testTenantID))
+	rw.pollBlocklist()
+	assert.Empty(t, rw.blocklist.Metas(testTenantID))
+
+	found, dataEncodings, err := r.FindSkeleton(context.Background(), testTenantID, id, BlockIDMin, BlockIDMax, time.Time{}, time.Time{})
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+	// the mock sharder keeps the larger part of a trace
+	expected, err := model.SkeletonBytes(objs[1], model.TracePBEncoding, compactedID[:8])
+	require.NoError(t, err)
+	assert.Equal(t, expected, found[0])
+	assert.Equal(t, []string{model.TracePBEncoding}, dataEncodings)
+
+	// skeletons outside of the block range are not searched
+	found, _, err = r.FindSkeleton(context.Background(), testTenantID, id, BlockIDMin, BlockIDMin, time.Time{}, time.Time{})
+	require.NoError(t, err)
+	assert.Empty(t, found)
+
+	// retention keeps recent skeletons and deletes old ones
+	rw.doRetention()
+	rw.pollBlocklist()
+	assert.Len(t, rw.skeletons.Metas(testTenantID), 1)
+
+	rw.cfg.Skeleton.Retention = -time.Hour
+	rw.doRetention()
+	assert.Empty(t, rw.skeletons.Metas(testTenantID))
+	rw.pollBlocklist()
+	assert.Empty(t, rw.skeletons.Tenants())
+
+	found, _, err = r.FindSkeleton(context.Background(), testTenantID, id, BlockIDMin, BlockIDMax, time.Time{}, time.Time{})
+	require.NoError(t, err)
+	assert.Empty(t, found)
+}
+
+func TestCopyBlockWithSkeleton(t *testing.T) {
+	r, w, _, tempDir := testConfig(t, backend.EncNone, 0)
+	defer os.RemoveAll(tempDir)
+	r.(*readerWriter).cfg.Skeleton.Enabled = true
+
+	id := make([]byte, 16)
+	rand.Read(id)
+	obj, err := proto.Marshal(test.MakeTraceWithSpanCount(1, 5, id))
+	require.NoError(t, err)
+
+	head, err := w.WAL().NewBlock(uuid.New(), testTenantID, model.TracePBEncoding)
+	require.NoError(t, err)
+	require.NoError(t, head.Write(id, obj))
+
+	// the skeleton is written to the backend the block is completed to and copied with it
+	_, dest, _, destDir := testConfig(t, backend.EncNone, 0)
+	defer os.RemoveAll(destDir)
+	destRW := dest.(*readerWriter)
+	srcRW := r.(*readerWriter)
+
+	complete, err := w.CompleteBlockWithBackend(context.Background(), head, &mockSharder{}, srcRW.r, srcRW.w, nil)
+	require.NoError(t, err)
+	require.NoError(t, encoding.CopyBlock(context.Background(), complete.BlockMeta(), srcRW.r, destRW.w))
+
+	meta, err := destRW.r.BlockMeta(context.Background(), complete.BlockMeta().BlockID, backend.SkeletonTenantID(testTenantID))
+	require.NoError(t, err)
+	assert.Equal(t, 1, meta.TotalObjects)
+}
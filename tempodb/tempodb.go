@@ -3,9 +3,13 @@ package tempodb
 import (
 	"bytes"
 	"context"
+	"encoding/binary"
 	"encoding/hex"
 	"fmt"
 	"io"
+	"math"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/go-kit/kit/log"
@@ -14,20 +18,26 @@ import (
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/atomic"
 
 	cortex_cache "github.com/cortexproject/cortex/pkg/chunk/cache"
 	log_util "github.com/cortexproject/cortex/pkg/util/log"
+	"github.com/grafana/tempo/pkg/querystats"
+	"github.com/grafana/tempo/pkg/tempopb"
 	"github.com/grafana/tempo/tempodb/backend"
 	"github.com/grafana/tempo/tempodb/backend/azure"
 	"github.com/grafana/tempo/tempodb/backend/cache"
 	"github.com/grafana/tempo/tempodb/backend/cache/memcached"
 	"github.com/grafana/tempo/tempodb/backend/cache/redis"
+	"github.com/grafana/tempo/tempodb/backend/coalesce"
 	"github.com/grafana/tempo/tempodb/backend/gcs"
 	"github.com/grafana/tempo/tempodb/backend/local"
+	"github.com/grafana/tempo/tempodb/backend/ratelimit"
 	"github.com/grafana/tempo/tempodb/backend/s3"
 	"github.com/grafana/tempo/tempodb/blocklist"
 	"github.com/grafana/tempo/tempodb/encoding"
 	"github.com/grafana/tempo/tempodb/encoding/common"
+	"github.com/grafana/tempo/tempodb/notifications"
 	"github.com/grafana/tempo/tempodb/pool"
 	"github.com/grafana/tempo/tempodb/wal"
 	"github.com/opentracing/opentracing-go"
@@ -63,18 +73,29 @@ var (
 		Name:      "retention_deleted_total",
 		Help:      "Total number of blocks deleted.",
 	})
+	metricFindDeadlineTerminations = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "tempodb",
+		Name:      "find_deadline_terminations_total",
+		Help:      "Total number of trace by id lookups that stopped searching blocks because their deadline was close.",
+	})
 )
 
 type Writer interface {
 	WriteBlock(ctx context.Context, block WriteableBlock) error
 	CompleteBlock(block *wal.AppendBlock, combiner common.ObjectCombiner) (*encoding.BackendBlock, error)
-	CompleteBlockWithBackend(ctx context.Context, block *wal.AppendBlock, combiner common.ObjectCombiner, r backend.Reader, w backend.Writer) (*encoding.BackendBlock, error)
+	CompleteBlockWithBackend(ctx context.Context, block *wal.AppendBlock, combiner common.ObjectCombiner, r backend.Reader, w backend.Writer, drop func(common.ID) bool) (*encoding.BackendBlock, error)
+	RewriteBlockWithBackend(ctx context.Context, block *encoding.BackendBlock, w backend.Writer, drop func(common.ID) bool) (*backend.BlockMeta, error)
 	WAL() *wal.WAL
 }
 
 type Reader interface {
-	Find(ctx context.Context, tenantID string, id common.ID, blockStart string, blockEnd string) ([][]byte, []string, error)
+	Find(ctx context.Context, tenantID string, id common.ID, blockStart string, blockEnd string, start time.Time, end time.Time, maxBlocks int) ([][]byte, []string, FindMetrics, error)
+	FindEach(ctx context.Context, tenantID string, id common.ID, blockStart string, blockEnd string, start time.Time, end time.Time, maxBlocks int, fn FoundFunc) (FindMetrics, error)
+	FindSkeleton(ctx context.Context, tenantID string, id common.ID, blockStart string, blockEnd string, start time.Time, end time.Time) ([][]byte, []string, error)
+	SearchBlocks(ctx context.Context, tenantID string, req *tempopb.SearchRequest, start time.Time, end time.Time, concurrency int, maxBytes uint64) (*tempopb.SearchResponse, bool, error)
+	BlockCount(tenantID string) int
 	EnablePolling(sharder blocklist.JobSharder)
+	PollBlocklistNow(ctx context.Context, tenantID string) (blocklist.Changes, error)
 
 	Shutdown()
 }
@@ -90,6 +111,7 @@ type CompactorSharder interface {
 
 type CompactorOverrides interface {
 	BlockRetentionForTenant(tenantID string) time.Duration
+	MaxBytesPerTraceForTenant(tenantID string) int
 }
 
 type WriteableBlock interface {
@@ -98,21 +120,29 @@ type WriteableBlock interface {
 }
 
 type readerWriter struct {
-	r backend.Reader
-	w backend.Writer
-	c backend.Compactor
+	r    backend.Reader
+	rawR backend.RawReader
+	w    backend.Writer
+	c    backend.Compactor
 
 	uncachedReader backend.Reader
 	uncachedWriter backend.Writer
 
-	wal  *wal.WAL
-	pool *pool.Pool
+	wal      *wal.WAL
+	pool     *pool.Pool
+	notifier *notifications.Notifier
 
 	logger log.Logger
 	cfg    *Config
 
 	blocklistPoller *blocklist.Poller
 	blocklist       *blocklist.List
+	// pollMtx serializes polls of the blocklist, polls requested while one runs wait in pendingPolls
+	pollMtx         sync.Mutex
+	pendingPollsMtx sync.Mutex
+	pendingPolls    map[string]*pendingPoll
+	skeletons       *skeletonList
+	bloomStats      *bloomStats
 
 	compactorCfg          *CompactorConfig
 	compactorSharder      CompactorSharder
@@ -120,58 +150,71 @@ type readerWriter struct {
 	compactorTenantOffset uint
 }
 
-// New creates a new tempodb
-func New(cfg *Config, logger log.Logger) (Reader, Writer, Compactor, error) {
-	var rawR backend.RawReader
-	var rawW backend.RawWriter
-	var c backend.Compactor
-
-	err := validateConfig(cfg)
-	if err != nil {
-		return nil, nil, nil, fmt.Errorf("invalid config while creating tempodb: %w", err)
-	}
-
+// NewBackend creates the uncached raw backend for the configured storage.
+func NewBackend(cfg *Config) (backend.RawReader, backend.RawWriter, backend.Compactor, error) {
 	switch cfg.Backend {
 	case "local":
-		rawR, rawW, c, err = local.New(cfg.Local)
+		return local.New(cfg.Local)
 	case "gcs":
-		rawR, rawW, c, err = gcs.New(cfg.GCS)
+		return gcs.New(cfg.GCS)
 	case "s3":
-		rawR, rawW, c, err = s3.New(cfg.S3)
+		return s3.New(cfg.S3)
 	case "azure":
-		rawR, rawW, c, err = azure.New(cfg.Azure)
+		return azure.New(cfg.Azure)
 	default:
-		err = fmt.Errorf("unknown backend %s", cfg.Backend)
+		return nil, nil, nil, fmt.Errorf("unknown backend %s", cfg.Backend)
 	}
+}
 
+// New creates a new tempodb
+func New(cfg *Config, logger log.Logger) (Reader, Writer, Compactor, error) {
+	err := validateConfig(cfg)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid config while creating tempodb: %w", err)
+	}
+
+	rawR, rawW, c, err := NewBackend(cfg)
 	if err != nil {
 		return nil, nil, nil, err
 	}
 
+	// cache hits and coalesced reads don't count towards the limits and the bytes read by queries
+	rawR = newQueryBytesReader(rawR, newQueryBytesTenants(cfg.QueryBytesReadMaxTenants))
+	rawR = ratelimit.NewReader(rawR, cfg.BackendRateLimit)
+	rawW = ratelimit.NewWriter(rawW, cfg.BackendRateLimit)
+
 	uncachedReader := backend.NewReader(rawR)
 	uncachedWriter := backend.NewWriter(rawW)
 
 	var cacheBackend cortex_cache.Cache
+	var cacheMaxItemBytes int
 
 	switch cfg.Cache {
 	case "redis":
 		cacheBackend = redis.NewClient(cfg.Redis, cfg.BackgroundCache, logger)
+		cacheMaxItemBytes = cfg.Redis.MaxItemBytes
 	case "memcached":
 		cacheBackend = memcached.NewClient(cfg.Memcached, cfg.BackgroundCache, logger)
+		cacheMaxItemBytes = cfg.Memcached.MaxItemBytes
 	}
 
 	if cacheBackend != nil {
-		rawR, rawW, err = cache.NewCache(rawR, rawW, cacheBackend)
+		rawR, rawW, err = cache.NewCache(rawR, rawW, cacheBackend, cacheMaxItemBytes)
 		if err != nil {
 			return nil, nil, nil, err
 		}
 	}
 
+	if cfg.CoalesceReadsMaxBytes > 0 {
+		rawR = coalesce.New(rawR, cfg.CoalesceReadsMaxBytes)
+	}
+
 	r := backend.NewReader(rawR)
 	w := backend.NewWriter(rawW)
 	rw := &readerWriter{
 		c:              c,
 		r:              r,
+		rawR:           rawR,
 		uncachedReader: uncachedReader,
 		uncachedWriter: uncachedWriter,
 		w:              w,
@@ -179,6 +222,9 @@ func New(cfg *Config, logger log.Logger) (Reader, Writer, Compactor, error) {
 		logger:         logger,
 		pool:           pool.NewPool(cfg.Pool),
 		blocklist:      blocklist.New(),
+		pendingPolls:   map[string]*pendingPoll{},
+		skeletons:      newSkeletonList(),
+		bloomStats:     newBloomStats(),
 	}
 
 	rw.wal, err = wal.New(rw.cfg.WAL)
@@ -186,41 +232,87 @@ func New(cfg *Config, logger log.Logger) (Reader, Writer, Compactor, error) {
 		return nil, nil, nil, err
 	}
 
+	rw.notifier, err = notifications.New(rw.cfg.Notifications, logger)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to create notifier: %w", err)
+	}
+
 	return rw, rw, rw, nil
 }
 
 func (rw *readerWriter) WriteBlock(ctx context.Context, c WriteableBlock) error {
 	w := rw.getWriterForBlock(c.BlockMeta(), time.Now())
-	return c.Write(ctx, w)
+	err := c.Write(ctx, w)
+	if err != nil {
+		return err
+	}
+
+	rw.notifier.Notify(notifications.NewEvent(notifications.EventBlockFlushed, c.BlockMeta()))
+	return nil
 }
 
 // CompleteBlock iterates the given WAL block and flushes it to the TempoDB backend.
 func (rw *readerWriter) CompleteBlock(block *wal.AppendBlock, combiner common.ObjectCombiner) (*encoding.BackendBlock, error) {
-	return rw.CompleteBlockWithBackend(context.TODO(), block, combiner, rw.r, rw.w)
+	return rw.CompleteBlockWithBackend(context.TODO(), block, combiner, rw.r, rw.w, nil)
 }
 
 // CompleteBlock iterates the given WAL block but flushes it to the given backend instead of the default TempoDB backend. The
-// new block will have the same ID as the input block.
-func (rw *readerWriter) CompleteBlockWithBackend(ctx context.Context, block *wal.AppendBlock, combiner common.ObjectCombiner, r backend.Reader, w backend.Writer) (*encoding.BackendBlock, error) {
-	meta := block.Meta()
-	blockID := meta.BlockID
-	tenantID := meta.TenantID
+// new block will have the same ID as the input block. Objects for which drop returns true are not written, drop may be nil.
+func (rw *readerWriter) CompleteBlockWithBackend(ctx context.Context, block *wal.AppendBlock, combiner common.ObjectCombiner, r backend.Reader, w backend.Writer, drop func(common.ID) bool) (*encoding.BackendBlock, error) {
+	iter, err := block.GetIterator(combiner)
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting completing block iterator")
+	}
+	defer iter.Close()
+
+	meta, err := rw.writeBlock(ctx, block.Meta(), filterIterator(iter, drop), w)
+	if err != nil {
+		return nil, err
+	}
+
+	backendBlock, err := encoding.NewBackendBlock(meta, r)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating creating backend block")
+	}
+
+	return backendBlock, nil
+}
+
+// RewriteBlockWithBackend writes the objects of a complete block for which drop returns false to the given backend. The
+// new block has the same ID as the input block, which must be read from a different backend. It returns the meta of
+// the new block.
+func (rw *readerWriter) RewriteBlockWithBackend(ctx context.Context, block *encoding.BackendBlock, w backend.Writer, drop func(common.ID) bool) (*backend.BlockMeta, error) {
+	chunkSize := DefaultChunkSizeBytes
+	if rw.compactorCfg != nil && rw.compactorCfg.ChunkSizeBytes > 0 {
+		chunkSize = rw.compactorCfg.ChunkSizeBytes
+	}
+
+	iter, err := block.Iterator(chunkSize)
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting block iterator")
+	}
+	defer iter.Close()
+
+	return rw.writeBlock(ctx, block.BlockMeta(), filterIterator(iter, drop), w)
+}
 
+// writeBlock writes the objects of iter to a new block with the ID and tenant of meta. If skeletons are enabled their
+// skeletons are written to the skeleton block of the new block as well.
+func (rw *readerWriter) writeBlock(ctx context.Context, meta *backend.BlockMeta, iter encoding.Iterator, w backend.Writer) (*backend.BlockMeta, error) {
 	// Default and nil check is primarily to make testing easier.
 	flushSize := DefaultFlushSizeBytes
 	if rw.compactorCfg != nil && rw.compactorCfg.FlushSizeBytes > 0 {
 		flushSize = rw.compactorCfg.FlushSizeBytes
 	}
 
-	iter, err := block.GetIterator(combiner)
+	newBlock, err := encoding.NewStreamingBlock(rw.cfg.Block, meta.BlockID, meta.TenantID, []*backend.BlockMeta{meta}, meta.DataEncoding, meta.TotalObjects)
 	if err != nil {
-		return nil, errors.Wrap(err, "error getting completing block iterator")
+		return nil, errors.Wrap(err, "error creating compactor block")
 	}
-	defer iter.Close()
 
-	newBlock, err := encoding.NewStreamingBlock(rw.cfg.Block, blockID, tenantID, []*backend.BlockMeta{meta}, meta.TotalObjects)
+	skeleton, err := rw.newSkeletonBlock(newBlock.BlockMeta(), meta.TotalObjects)
 	if err != nil {
-		return nil, errors.Wrap(err, "error creating compactor block")
+		return nil, err
 	}
 
 	var tracker backend.AppendTracker
@@ -239,6 +331,11 @@ func (rw *readerWriter) CompleteBlockWithBackend(ctx context.Context, block *wal
 			return nil, errors.Wrap(err, "error adding object to compactor block")
 		}
 
+		err = skeleton.add(ctx, w, id, data)
+		if err != nil {
+			return nil, err
+		}
+
 		if newBlock.CurrentBufferLength() > int(flushSize) {
 			tracker, _, err = newBlock.FlushBuffer(ctx, tracker, w)
 			if err != nil {
@@ -247,82 +344,134 @@ func (rw *readerWriter) CompleteBlockWithBackend(ctx context.Context, block *wal
 		}
 	}
 
-	_, err = newBlock.Complete(ctx, tracker, w)
+	err = skeleton.complete(ctx, w, newBlock.BlockMeta())
 	if err != nil {
-		return nil, errors.Wrap(err, "error completing compactor block")
+		return nil, err
 	}
 
-	backendBlock, err := encoding.NewBackendBlock(newBlock.BlockMeta(), r)
+	_, err = newBlock.Complete(ctx, tracker, w)
 	if err != nil {
-		return nil, errors.Wrap(err, "error creating creating backend block")
+		return nil, errors.Wrap(err, "error completing compactor block")
 	}
 
-	return backendBlock, nil
+	return newBlock.BlockMeta(), nil
 }
 
 func (rw *readerWriter) WAL() *wal.WAL {
 	return rw.wal
 }
 
-func (rw *readerWriter) Find(ctx context.Context, tenantID string, id common.ID, blockStart string, blockEnd string) ([][]byte, []string, error) {
+// FindMetrics describes the blocks searched by a Find.
+type FindMetrics struct {
+	// InspectedBlocks is the number of blocks searched for the trace.
+	InspectedBlocks int
+	// SkippedBlocks is the number of blocks that may hold the trace but were not searched because of the max blocks
+	// or because the deadline of the lookup was close.
+	SkippedBlocks int
+}
+
+// FoundFunc receives a partial trace found by FindEach and its data encoding. Calls are serialized. An error stops the
+// search and is returned by FindEach.
+type FoundFunc func(partialTrace []byte, dataEncoding string) error
+
+// Find returns the partial traces of the id in the blocks between blockStart and blockEnd and their data encodings.
+// Blocks that don't overlap the time range between start and end are not searched, a zero start or end leaves that
+// side of the range open. If more than maxBlocks blocks may hold the trace only the newest are searched and the rest
+// are counted as skipped in the returned metrics. maxBlocks applies to the whole block id space and is scaled down to
+// the share of the blocks between blockStart and blockEnd, see shardMaxBlocks. A maxBlocks of 0 searches all blocks. Blocks that are not searched yet when the
+// deadline of ctx is close are skipped as well, see Config.FindDeadlineReserve.
+func (rw *readerWriter) Find(ctx context.Context, tenantID string, id common.ID, blockStart string, blockEnd string, start time.Time, end time.Time, maxBlocks int) ([][]byte, []string, FindMetrics, error) {
+	var partialTraces [][]byte
+	var dataEncodings []string
+	metrics, err := rw.FindEach(ctx, tenantID, id, blockStart, blockEnd, start, end, maxBlocks, func(partialTrace []byte, dataEncoding string) error {
+		partialTraces = append(partialTraces, partialTrace)
+		dataEncodings = append(dataEncodings, dataEncoding)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, metrics, err
+	}
+
+	return partialTraces, dataEncodings, metrics, nil
+}
+
+// FindEach searches the blocks like Find but hands each partial trace to fn as soon as its block is searched instead
+// of returning them all at once.
+func (rw *readerWriter) FindEach(ctx context.Context, tenantID string, id common.ID, blockStart string, blockEnd string, start time.Time, end time.Time, maxBlocks int, fn FoundFunc) (FindMetrics, error) {
 	// tracing instrumentation
 	logger := log_util.WithContext(ctx, log_util.Logger)
 	span, ctx := opentracing.StartSpanFromContext(ctx, "store.Find")
 	defer span.Finish()
 
-	blockStartUUID, err := uuid.Parse(blockStart)
-	if err != nil {
-		return nil, nil, err
-	}
-	blockStartBytes, err := blockStartUUID.MarshalBinary()
+	blockStartBytes, blockEndBytes, err := parseBlockRange(blockStart, blockEnd)
 	if err != nil {
-		return nil, nil, err
-	}
-	blockEndUUID, err := uuid.Parse(blockEnd)
-	if err != nil {
-		return nil, nil, err
-	}
-	blockEndBytes, err := blockEndUUID.MarshalBinary()
-	if err != nil {
-		return nil, nil, err
+		return FindMetrics{}, err
 	}
 
 	// gather appropriate blocks
 	blocklist := rw.blocklist.Metas(tenantID)
 	compactedBlocklist := rw.blocklist.CompactedMetas(tenantID)
-	copiedBlocklist := make([]interface{}, 0, len(blocklist))
-	blocksSearched := 0
-	compactedBlocksSearched := 0
+	liveBlocks := make([]*backend.BlockMeta, 0, len(blocklist))
+	compactedBlocks := make([]*backend.BlockMeta, 0)
 
 	for _, b := range blocklist {
-		if includeBlock(b, id, blockStartBytes, blockEndBytes) {
-			copiedBlocklist = append(copiedBlocklist, b)
-			blocksSearched++
+		if includeBlock(b, id, blockStartBytes, blockEndBytes) && overlapsTimeRange(b, start, end) {
+			liveBlocks = append(liveBlocks, b)
 		}
 	}
 	for _, c := range compactedBlocklist {
-		if includeCompactedBlock(c, id, blockStartBytes, blockEndBytes, rw.cfg.BlocklistPoll) {
-			copiedBlocklist = append(copiedBlocklist, &c.BlockMeta)
-			compactedBlocksSearched++
+		if includeCompactedBlock(c, id, blockStartBytes, blockEndBytes, rw.cfg.BlocklistPoll) && overlapsTimeRange(&c.BlockMeta, start, end) {
+			compactedBlocks = append(compactedBlocks, &c.BlockMeta)
 		}
 	}
-	if len(copiedBlocklist) == 0 {
-		return nil, nil, nil
+
+	metrics := FindMetrics{}
+	candidates := limitBlocks(liveBlocks, compactedBlocks, shardMaxBlocks(maxBlocks, blockStartBytes, blockEndBytes))
+	metrics.InspectedBlocks = len(candidates)
+	metrics.SkippedBlocks = len(liveBlocks) + len(compactedBlocks) - len(candidates)
+	if metrics.SkippedBlocks > 0 {
+		span.LogFields(ot_log.Int("skipped blocks", metrics.SkippedBlocks))
+	}
+
+	if len(candidates) == 0 {
+		return metrics, nil
+	}
+
+	// limitBlocks keeps the live blocks before the compacted ones
+	blocksSearched := len(liveBlocks)
+	if blocksSearched > len(candidates) {
+		blocksSearched = len(candidates)
+	}
+	compactedBlocksSearched := len(candidates) - blocksSearched
+	copiedBlocklist := make([]interface{}, 0, len(candidates))
+	for _, b := range candidates {
+		copiedBlocklist = append(copiedBlocklist, b)
 	}
 
 	curTime := time.Now()
-	partialTraces, dataEncodings, err := rw.pool.RunJobs(ctx, copiedBlocklist, func(ctx context.Context, payload interface{}) ([]byte, string, error) {
+	deadlineSkipped := atomic.NewInt32(0)
+	var fnMtx sync.Mutex
+	_, _, err = rw.pool.RunJobs(ctx, copiedBlocklist, func(ctx context.Context, payload interface{}) ([]byte, string, error) {
 		meta := payload.(*backend.BlockMeta)
-		r := rw.getReaderForBlock(meta, curTime)
-		block, err := encoding.NewBackendBlock(meta, r)
+		if err := ctx.Err(); err != nil {
+			return nil, "", err
+		}
+		if rw.deadlineClose(ctx, curTime) {
+			deadlineSkipped.Inc()
+			return nil, "", nil
+		}
+
+		block, err := encoding.NewBackendBlock(meta, rw.getReaderForBlock(meta, curTime))
 		if err != nil {
 			return nil, "", err
 		}
 
-		foundObject, err := block.Find(ctx, id)
+		foundObject, bloomMatched, err := block.FindWithBloomResult(withQueryTenant(ctx, tenantID), id)
 		if err != nil {
 			return nil, "", err
 		}
+		rw.bloomStats.observe(tenantID, bloomMatched, foundObject != nil)
+		querystats.FromContext(ctx).AddBlockInspected(bloomMatched)
 
 		level.Info(logger).Log("msg", "searching for trace in block", "findTraceID", hex.EncodeToString(id), "block", meta.BlockID, "found", foundObject != nil)
 		span.LogFields(
@@ -336,16 +485,64 @@ func (rw *readerWriter) Find(ctx context.Context, tenantID string, id common.ID,
 			ot_log.Int("compacted blocks searched", compactedBlocksSearched),
 		)
 
-		return foundObject, meta.DataEncoding, nil
+		if foundObject == nil {
+			return nil, "", nil
+		}
+
+		fnMtx.Lock()
+		defer fnMtx.Unlock()
+		return nil, "", fn(foundObject, meta.DataEncoding)
 	})
 
-	return partialTraces, dataEncodings, err
+	if skipped := int(deadlineSkipped.Load()); skipped > 0 {
+		metricFindDeadlineTerminations.Inc()
+		metrics.InspectedBlocks -= skipped
+		metrics.SkippedBlocks += skipped
+		span.LogFields(ot_log.Int("blocks skipped at deadline", skipped))
+	}
+
+	return metrics, err
+}
+
+// parseBlockRange parses the block ids bounding the blocks searched by a query.
+func parseBlockRange(blockStart string, blockEnd string) ([]byte, []byte, error) {
+	blockStartUUID, err := uuid.Parse(blockStart)
+	if err != nil {
+		return nil, nil, err
+	}
+	blockStartBytes, err := blockStartUUID.MarshalBinary()
+	if err != nil {
+		return nil, nil, err
+	}
+	blockEndUUID, err := uuid.Parse(blockEnd)
+	if err != nil {
+		return nil, nil, err
+	}
+	blockEndBytes, err := blockEndUUID.MarshalBinary()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return blockStartBytes, blockEndBytes, nil
+}
+
+// deadlineClose returns true if less than FindDeadlineReserve of the time between start and the deadline of ctx
+// remains.
+func (rw *readerWriter) deadlineClose(ctx context.Context, start time.Time) bool {
+	deadline, ok := ctx.Deadline()
+	if !ok || rw.cfg.FindDeadlineReserve <= 0 {
+		return false
+	}
+
+	reserve := time.Duration(rw.cfg.FindDeadlineReserve * float64(deadline.Sub(start)))
+	return time.Until(deadline) < reserve
 }
 
 func (rw *readerWriter) Shutdown() {
 	// todo: stop blocklist poll
 	rw.pool.Shutdown()
 	rw.r.Shutdown()
+	rw.notifier.Shutdown()
 }
 
 // EnableCompaction activates the compaction/retention loops
@@ -368,6 +565,11 @@ func (rw *readerWriter) EnableCompaction(cfg *CompactorConfig, c CompactorSharde
 		level.Info(rw.logger).Log("msg", "compaction and retention enabled.")
 		go rw.compactionLoop()
 		go rw.retentionLoop()
+
+		if cfg.Scrubber.Enabled {
+			level.Info(rw.logger).Log("msg", "block scrubber enabled.")
+			go rw.scrubLoop()
+		}
 	}
 }
 
@@ -400,6 +602,7 @@ func (rw *readerWriter) EnablePolling(sharder blocklist.JobSharder) {
 	rw.pollBlocklist()
 
 	go rw.pollingLoop()
+	go rw.bloomStatsLoop()
 }
 
 func (rw *readerWriter) pollingLoop() {
@@ -410,14 +613,87 @@ func (rw *readerWriter) pollingLoop() {
 }
 
 func (rw *readerWriter) pollBlocklist() {
-	blocklist, compactedBlocklist, err := rw.blocklistPoller.Do()
+	rw.pollMtx.Lock()
+	defer rw.pollMtx.Unlock()
+
+	_, _ = rw.pollBlocklistTenant("")
+}
+
+// pollBlocklistTenant polls the blocklist of the tenant, or of all tenants if tenantID is empty, and returns the
+// blocks added and removed. It must be called under pollMtx.
+func (rw *readerWriter) pollBlocklistTenant(tenantID string) (blocklist.Changes, error) {
+	if tenantID != "" {
+		metas, compactedMetas, err := rw.blocklistPoller.DoTenant(tenantID)
+		if err != nil {
+			level.Error(rw.logger).Log("msg", "failed to poll tenant blocklist. using previously polled list", "tenantID", tenantID, "err", err)
+			return blocklist.Changes{}, err
+		}
+
+		return rw.blocklist.ApplyTenantPollResults(tenantID, metas, compactedMetas), nil
+	}
+
+	metas, compactedMetas, err := rw.blocklistPoller.Do()
 
 	if err != nil {
 		level.Error(rw.logger).Log("msg", "failed to poll blocklist. using previously polled lists", "err", err)
-		return
+		return blocklist.Changes{}, err
+	}
+
+	changes := rw.blocklist.ApplyPollResults(metas, compactedMetas)
+
+	if rw.cfg.Skeleton.Enabled {
+		rw.pollSkeletons()
 	}
 
-	rw.blocklist.ApplyPollResults(blocklist, compactedBlocklist)
+	return changes, nil
+}
+
+// pendingPoll is a poll of the blocklist requested by PollBlocklistNow that has not started yet. Its result is set
+// before done is closed.
+type pendingPoll struct {
+	done chan struct{}
+
+	changes blocklist.Changes
+	err     error
+}
+
+// PollBlocklistNow polls the blocklist of the tenant, or of all tenants if tenantID is empty, without waiting for the
+// next poll cycle and returns when the poll is complete. Requests for the same tenant share a poll that has not
+// started yet, so concurrent requests result in at most one poll after the one in progress.
+func (rw *readerWriter) PollBlocklistNow(ctx context.Context, tenantID string) (blocklist.Changes, error) {
+	if rw.blocklistPoller == nil {
+		return blocklist.Changes{}, errors.New("polling is not enabled")
+	}
+
+	rw.pendingPollsMtx.Lock()
+	p, ok := rw.pendingPolls[tenantID]
+	if !ok {
+		p = &pendingPoll{done: make(chan struct{})}
+		rw.pendingPolls[tenantID] = p
+		go rw.runPendingPoll(tenantID, p)
+	}
+	rw.pendingPollsMtx.Unlock()
+
+	select {
+	case <-p.done:
+		return p.changes, p.err
+	case <-ctx.Done():
+		return blocklist.Changes{}, ctx.Err()
+	}
+}
+
+func (rw *readerWriter) runPendingPoll(tenantID string, p *pendingPoll) {
+	// wait for the poll in progress before removing the pending poll, requests made until then have to be served by
+	// a poll that starts after them
+	rw.pollMtx.Lock()
+	defer rw.pollMtx.Unlock()
+
+	rw.pendingPollsMtx.Lock()
+	delete(rw.pendingPolls, tenantID)
+	rw.pendingPollsMtx.Unlock()
+
+	p.changes, p.err = rw.pollBlocklistTenant(tenantID)
+	close(p.done)
 }
 
 func (rw *readerWriter) shouldCache(meta *backend.BlockMeta, curTime time.Time) bool {
@@ -450,6 +726,44 @@ func (rw *readerWriter) getWriterForBlock(meta *backend.BlockMeta, curTime time.
 	return rw.uncachedWriter
 }
 
+// limitBlocks returns the blocks to search for a trace, at most maxBlocks of them unless it is 0. Compacted blocks
+// are only searched until their replacements are polled, so live blocks are preferred and newer blocks are preferred
+// over older ones as most lookups are for recent traces.
+func limitBlocks(live []*backend.BlockMeta, compacted []*backend.BlockMeta, maxBlocks int) []*backend.BlockMeta {
+	blocks := append(append(make([]*backend.BlockMeta, 0, len(live)+len(compacted)), live...), compacted...)
+	if maxBlocks <= 0 || len(blocks) <= maxBlocks {
+		return blocks
+	}
+
+	newestFirst := func(metas []*backend.BlockMeta) {
+		sort.SliceStable(metas, func(i, j int) bool {
+			return metas[i].EndTime.After(metas[j].EndTime)
+		})
+	}
+	newestFirst(blocks[:len(live)])
+	newestFirst(blocks[len(live):])
+
+	return blocks[:maxBlocks]
+}
+
+// shardMaxBlocks returns the share of maxBlocks of the blocks between blockStart and blockEnd. The query-frontend
+// splits the block id space evenly between the shards of a lookup, so the shards of a lookup together inspect about
+// maxBlocks blocks, rounded up by at most one block per shard.
+func shardMaxBlocks(maxBlocks int, blockStart []byte, blockEnd []byte) int {
+	if maxBlocks <= 0 {
+		return maxBlocks
+	}
+
+	start := binary.BigEndian.Uint64(blockStart)
+	end := binary.BigEndian.Uint64(blockEnd)
+	if end <= start {
+		return 1
+	}
+
+	share := float64(end-start) / float64(math.MaxUint64)
+	return int(math.Ceil(float64(maxBlocks) * share))
+}
+
 // includeBlock indicates whether a given block should be included in a backend search
 func includeBlock(b *backend.BlockMeta, id common.ID, blockStart []byte, blockEnd []byte) bool {
 	if bytes.Compare(id, b.MinID) == -1 || bytes.Compare(id, b.MaxID) == 1 {
@@ -474,3 +788,45 @@ func includeCompactedBlock(c *backend.CompactedBlockMeta, id common.ID, blockSta
 	}
 	return includeBlock(&c.BlockMeta, id, blockStart, blockEnd)
 }
+
+// BlockCount returns the number of blocks of the tenant in the last polled blocklist.
+func (rw *readerWriter) BlockCount(tenantID string) int {
+	return len(rw.blocklist.Metas(tenantID))
+}
+
+// overlapsTimeRange returns true if the block was written to between start and end. A zero start or end leaves that
+// side of the range open.
+func overlapsTimeRange(b *backend.BlockMeta, start time.Time, end time.Time) bool {
+	if !end.IsZero() && b.StartTime.After(end) {
+		return false
+	}
+	if !start.IsZero() && b.EndTime.Before(start) {
+		return false
+	}
+	return true
+}
+
+// filterIterator skips the objects for which drop returns true. iter is returned as is if drop is nil.
+func filterIterator(iter encoding.Iterator, drop func(common.ID) bool) encoding.Iterator {
+	if drop == nil {
+		return iter
+	}
+	return &filteringIterator{
+		Iterator: iter,
+		drop:     drop,
+	}
+}
+
+type filteringIterator struct {
+	encoding.Iterator
+	drop func(common.ID) bool
+}
+
+func (f *filteringIterator) Next(ctx context.Context) (common.ID, []byte, error) {
+	for {
+		id, obj, err := f.Iterator.Next(ctx)
+		if err != nil || id == nil || !f.drop(id) {
+			return id, obj, err
+		}
+	}
+}
@@ -2,6 +2,7 @@ package tempodb
 
 import (
 	"context"
+	"errors"
 	"io/ioutil"
 	"math/rand"
 	"os"
@@ -15,10 +16,12 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/grafana/tempo/pkg/querystats"
 	"github.com/grafana/tempo/pkg/tempopb"
 	"github.com/grafana/tempo/pkg/util/test"
 	"github.com/grafana/tempo/tempodb/backend"
 	"github.com/grafana/tempo/tempodb/backend/local"
+	"github.com/grafana/tempo/tempodb/blocklist"
 	"github.com/grafana/tempo/tempodb/encoding"
 	"github.com/grafana/tempo/tempodb/encoding/common"
 	"github.com/grafana/tempo/tempodb/wal"
@@ -101,7 +104,7 @@ func TestDB(t *testing.T) {
 
 	// read
 	for i, id := range ids {
-		bFound, actualDataEncoding, err := r.Find(context.Background(), testTenantID, id, BlockIDMin, BlockIDMax)
+		bFound, actualDataEncoding, _, err := r.Find(context.Background(), testTenantID, id, BlockIDMin, BlockIDMax, time.Time{}, time.Time{}, 0)
 		assert.NoError(t, err)
 		assert.Equal(t, []string{testDataEncoding}, actualDataEncoding)
 
@@ -153,7 +156,7 @@ func TestBlockSharding(t *testing.T) {
 	// check if it respects the blockstart/blockend params - case1: hit
 	blockStart := uuid.MustParse(BlockIDMin).String()
 	blockEnd := uuid.MustParse(BlockIDMax).String()
-	bFound, _, err := r.Find(context.Background(), testTenantID, id, blockStart, blockEnd)
+	bFound, _, _, err := r.Find(context.Background(), testTenantID, id, blockStart, blockEnd, time.Time{}, time.Time{}, 0)
 	assert.NoError(t, err)
 	assert.Greater(t, len(bFound), 0)
 
@@ -165,7 +168,7 @@ func TestBlockSharding(t *testing.T) {
 	// check if it respects the blockstart/blockend params - case2: miss
 	blockStart = uuid.MustParse(BlockIDMin).String()
 	blockEnd = uuid.MustParse(BlockIDMin).String()
-	bFound, _, err = r.Find(context.Background(), testTenantID, id, blockStart, blockEnd)
+	bFound, _, _, err = r.Find(context.Background(), testTenantID, id, blockStart, blockEnd, time.Time{}, time.Time{}, 0)
 	assert.NoError(t, err)
 	assert.Len(t, bFound, 0)
 }
@@ -174,7 +177,7 @@ func TestNilOnUnknownTenantID(t *testing.T) {
 	r, _, _, tempDir := testConfig(t, backend.EncLZ4_256k, 0)
 	defer os.RemoveAll(tempDir)
 
-	buff, _, err := r.Find(context.Background(), "unknown", []byte{0x01}, BlockIDMin, BlockIDMax)
+	buff, _, _, err := r.Find(context.Background(), "unknown", []byte{0x01}, BlockIDMin, BlockIDMax, time.Time{}, time.Time{}, 0)
 	assert.Nil(t, buff)
 	assert.Nil(t, err)
 }
@@ -469,6 +472,37 @@ func TestIncludeCompactedBlock(t *testing.T) {
 
 }
 
+func TestOverlapsTimeRange(t *testing.T) {
+	meta := &backend.BlockMeta{
+		StartTime: time.Unix(100, 0),
+		EndTime:   time.Unix(200, 0),
+	}
+
+	tests := []struct {
+		name     string
+		start    time.Time
+		end      time.Time
+		expected bool
+	}{
+		{name: "no range", expected: true},
+		{name: "within", start: time.Unix(120, 0), end: time.Unix(180, 0), expected: true},
+		{name: "overlaps start", start: time.Unix(50, 0), end: time.Unix(100, 0), expected: true},
+		{name: "overlaps end", start: time.Unix(200, 0), end: time.Unix(250, 0), expected: true},
+		{name: "open start", end: time.Unix(150, 0), expected: true},
+		{name: "open end", start: time.Unix(150, 0), expected: true},
+		{name: "before", start: time.Unix(10, 0), end: time.Unix(99, 0), expected: false},
+		{name: "after", start: time.Unix(201, 0), end: time.Unix(300, 0), expected: false},
+		{name: "open start before", end: time.Unix(99, 0), expected: false},
+		{name: "open end after", start: time.Unix(201, 0), expected: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, overlapsTimeRange(meta, tc.start, tc.end))
+		})
+	}
+}
+
 func TestSearchCompactedBlocks(t *testing.T) {
 	r, w, c, tempDir := testConfig(t, backend.EncLZ4_256k, time.Minute)
 	defer os.RemoveAll(tempDir)
@@ -516,7 +550,7 @@ func TestSearchCompactedBlocks(t *testing.T) {
 
 	// read
 	for i, id := range ids {
-		bFound, _, err := r.Find(context.Background(), testTenantID, id, blockID, blockID)
+		bFound, _, _, err := r.Find(context.Background(), testTenantID, id, blockID, blockID, time.Time{}, time.Time{}, 0)
 		assert.NoError(t, err)
 
 		out := &tempopb.PushRequest{}
@@ -544,7 +578,7 @@ func TestSearchCompactedBlocks(t *testing.T) {
 
 	// find should succeed with old block range
 	for i, id := range ids {
-		bFound, _, err := r.Find(context.Background(), testTenantID, id, blockID, blockID)
+		bFound, _, _, err := r.Find(context.Background(), testTenantID, id, blockID, blockID, time.Time{}, time.Time{}, 0)
 		assert.NoError(t, err)
 
 		out := &tempopb.PushRequest{}
@@ -662,3 +696,245 @@ func TestShouldCache(t *testing.T) {
 		})
 	}
 }
+
+func TestFindCollectsQueryStats(t *testing.T) {
+	r, w, _, tempDir := testConfig(t, backend.EncNone, 0)
+	defer os.RemoveAll(tempDir)
+
+	// two blocks with the same trace
+	id := make([]byte, 16)
+	rand.Read(id)
+	bReq, err := proto.Marshal(test.MakeRequest(10, id))
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		head, err := w.WAL().NewBlock(uuid.New(), testTenantID, testDataEncoding)
+		require.NoError(t, err)
+		require.NoError(t, head.Write(id, bReq))
+		_, err = w.CompleteBlock(head, &mockSharder{})
+		require.NoError(t, err)
+	}
+	r.EnablePolling(&mockJobSharder{})
+
+	dataBytesBefore, err := test.GetCounterValue(metricQueryBytesRead.WithLabelValues(testTenantID, querystats.RoleData))
+	require.NoError(t, err)
+
+	stats := querystats.NewCollector()
+	objs, _, _, err := r.Find(querystats.NewContext(context.Background(), stats), testTenantID, id, BlockIDMin, BlockIDMax, time.Time{}, time.Time{}, 0)
+	require.NoError(t, err)
+	require.Len(t, objs, 2)
+
+	s := stats.Stats()
+	assert.Equal(t, int64(2), s.BlocksInspected)
+	assert.Equal(t, int64(2), s.BloomHits)
+	assert.Equal(t, int64(0), s.BloomMisses)
+	// the bloom filter, index and data of both blocks
+	assert.Greater(t, s.BytesRead, int64(2*len(bReq)))
+	assert.Greater(t, s.BloomBytesRead, int64(0))
+	assert.Greater(t, s.IndexBytesRead, int64(0))
+	assert.GreaterOrEqual(t, s.DataBytesRead, int64(2*len(bReq)))
+	assert.Equal(t, s.BytesRead, s.BloomBytesRead+s.IndexBytesRead+s.DataBytesRead+s.MetaBytesRead)
+
+	dataBytesAfter, err := test.GetCounterValue(metricQueryBytesRead.WithLabelValues(testTenantID, querystats.RoleData))
+	require.NoError(t, err)
+	assert.Equal(t, float64(s.DataBytesRead), dataBytesAfter-dataBytesBefore)
+}
+
+func TestFindMaxBlocks(t *testing.T) {
+	r, w, _, tempDir := testConfig(t, backend.EncNone, 0)
+	defer os.RemoveAll(tempDir)
+
+	// three blocks with the same trace
+	id := make([]byte, 16)
+	rand.Read(id)
+	bReq, err := proto.Marshal(test.MakeRequest(10, id))
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		head, err := w.WAL().NewBlock(uuid.New(), testTenantID, testDataEncoding)
+		require.NoError(t, err)
+		require.NoError(t, head.Write(id, bReq))
+		_, err = w.CompleteBlock(head, &mockSharder{})
+		require.NoError(t, err)
+	}
+	r.EnablePolling(&mockJobSharder{})
+	assert.Equal(t, 3, r.BlockCount(testTenantID))
+
+	objs, _, metrics, err := r.Find(context.Background(), testTenantID, id, BlockIDMin, BlockIDMax, time.Time{}, time.Time{}, 0)
+	require.NoError(t, err)
+	assert.Len(t, objs, 3)
+	assert.Equal(t, FindMetrics{InspectedBlocks: 3}, metrics)
+
+	objs, _, metrics, err = r.Find(context.Background(), testTenantID, id, BlockIDMin, BlockIDMax, time.Time{}, time.Time{}, 2)
+	require.NoError(t, err)
+	assert.Len(t, objs, 2)
+	assert.Equal(t, FindMetrics{InspectedBlocks: 2, SkippedBlocks: 1}, metrics)
+}
+
+func TestFindEach(t *testing.T) {
+	r, w, _, tempDir := testConfig(t, backend.EncNone, 0)
+	defer os.RemoveAll(tempDir)
+
+	// three blocks with the same trace
+	id := make([]byte, 16)
+	rand.Read(id)
+	bReq, err := proto.Marshal(test.MakeRequest(10, id))
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		head, err := w.WAL().NewBlock(uuid.New(), testTenantID, testDataEncoding)
+		require.NoError(t, err)
+		require.NoError(t, head.Write(id, bReq))
+		_, err = w.CompleteBlock(head, &mockSharder{})
+		require.NoError(t, err)
+	}
+	r.EnablePolling(&mockJobSharder{})
+
+	found := 0
+	metrics, err := r.FindEach(context.Background(), testTenantID, id, BlockIDMin, BlockIDMax, time.Time{}, time.Time{}, 0, func(partialTrace []byte, dataEncoding string) error {
+		found++
+		assert.NotEmpty(t, partialTrace)
+		assert.Equal(t, testDataEncoding, dataEncoding)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 3, found)
+	assert.Equal(t, FindMetrics{InspectedBlocks: 3}, metrics)
+
+	// an error of fn stops the lookup
+	stop := errors.New("stop")
+	_, err = r.FindEach(context.Background(), testTenantID, id, BlockIDMin, BlockIDMax, time.Time{}, time.Time{}, 0, func([]byte, string) error {
+		return stop
+	})
+	assert.ErrorIs(t, err, stop)
+}
+
+func TestFindDeadlineReserve(t *testing.T) {
+	r, w, _, tempDir := testConfig(t, backend.EncNone, 0)
+	defer os.RemoveAll(tempDir)
+
+	// two blocks with the same trace
+	id := make([]byte, 16)
+	rand.Read(id)
+	bReq, err := proto.Marshal(test.MakeRequest(10, id))
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		head, err := w.WAL().NewBlock(uuid.New(), testTenantID, testDataEncoding)
+		require.NoError(t, err)
+		require.NoError(t, head.Write(id, bReq))
+		_, err = w.CompleteBlock(head, &mockSharder{})
+		require.NoError(t, err)
+	}
+	r.EnablePolling(&mockJobSharder{})
+	r.(*readerWriter).cfg.FindDeadlineReserve = 0.5
+
+	// plenty of time left
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	objs, _, metrics, err := r.Find(ctx, testTenantID, id, BlockIDMin, BlockIDMax, time.Time{}, time.Time{}, 0)
+	require.NoError(t, err)
+	assert.Len(t, objs, 2)
+	assert.Equal(t, FindMetrics{InspectedBlocks: 2}, metrics)
+
+	// a reserve of the whole deadline skips every block
+	before, err := test.GetCounterValue(metricFindDeadlineTerminations)
+	require.NoError(t, err)
+	r.(*readerWriter).cfg.FindDeadlineReserve = 1
+	objs, _, metrics, err = r.Find(ctx, testTenantID, id, BlockIDMin, BlockIDMax, time.Time{}, time.Time{}, 0)
+	require.NoError(t, err)
+	assert.Empty(t, objs)
+	assert.Equal(t, FindMetrics{SkippedBlocks: 2}, metrics)
+	after, err := test.GetCounterValue(metricFindDeadlineTerminations)
+	require.NoError(t, err)
+	assert.Equal(t, float64(1), after-before)
+
+	// a cancelled lookup stops
+	cancel()
+	_, _, _, err = r.Find(ctx, testTenantID, id, BlockIDMin, BlockIDMax, time.Time{}, time.Time{}, 0)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestLimitBlocks(t *testing.T) {
+	meta := func(end int64) *backend.BlockMeta {
+		return &backend.BlockMeta{BlockID: uuid.New(), EndTime: time.Unix(end, 0)}
+	}
+	live := []*backend.BlockMeta{meta(1), meta(3), meta(2)}
+	compacted := []*backend.BlockMeta{meta(4)}
+
+	assert.Equal(t, []*backend.BlockMeta{live[0], live[1], live[2], compacted[0]}, limitBlocks(live, compacted, 0))
+	assert.Equal(t, []*backend.BlockMeta{live[0], live[1], live[2], compacted[0]}, limitBlocks(live, compacted, 4))
+	// live blocks are preferred over compacted ones, newest first
+	assert.Equal(t, []*backend.BlockMeta{live[1], live[2]}, limitBlocks(live, compacted, 2))
+}
+
+func TestShardMaxBlocks(t *testing.T) {
+	blockRange := func(start string, end string) ([]byte, []byte) {
+		s, e, err := parseBlockRange(start, end)
+		require.NoError(t, err)
+		return s, e
+	}
+
+	start, end := blockRange(BlockIDMin, BlockIDMax)
+	assert.Equal(t, 0, shardMaxBlocks(0, start, end))
+	assert.Equal(t, 100, shardMaxBlocks(100, start, end))
+
+	// a quarter of the block id space
+	start, end = blockRange("40000000-0000-0000-0000-000000000000", "7fffffff-ffff-ffff-ffff-ffffffffffff")
+	assert.Equal(t, 25, shardMaxBlocks(100, start, end))
+	assert.Equal(t, 1, shardMaxBlocks(1, start, end))
+}
+
+func TestPollBlocklistNow(t *testing.T) {
+	r, w, _, tempDir := testConfig(t, backend.EncNone, 0)
+	defer os.RemoveAll(tempDir)
+
+	_, err := r.PollBlocklistNow(context.Background(), testTenantID)
+	assert.EqualError(t, err, "polling is not enabled")
+
+	r.EnablePolling(&mockJobSharder{})
+
+	for _, tenantID := range []string{testTenantID, testTenantID2} {
+		head, err := w.WAL().NewBlock(uuid.New(), tenantID, testDataEncoding)
+		require.NoError(t, err)
+		_, err = w.CompleteBlock(head, &mockSharder{})
+		require.NoError(t, err)
+	}
+
+	// a single tenant
+	changes, err := r.PollBlocklistNow(context.Background(), testTenantID)
+	require.NoError(t, err)
+	assert.Equal(t, blocklist.Changes{Added: 1}, changes)
+
+	rw := r.(*readerWriter)
+	assert.Len(t, rw.blocklist.Metas(testTenantID), 1)
+	assert.Len(t, rw.blocklist.Metas(testTenantID2), 0)
+
+	// requests made while a poll is in progress share the next poll
+	rw.pollMtx.Lock()
+	results := make(chan blocklist.Changes, 10)
+	for i := 0; i < cap(results); i++ {
+		go func() {
+			changes, err := r.PollBlocklistNow(context.Background(), "")
+			assert.NoError(t, err)
+			results <- changes
+		}()
+	}
+	time.Sleep(100 * time.Millisecond)
+	rw.pollMtx.Unlock()
+
+	for i := 0; i < cap(results); i++ {
+		assert.Equal(t, blocklist.Changes{Added: 1}, <-results)
+	}
+	assert.Len(t, rw.blocklist.Metas(testTenantID2), 1)
+
+	// removed blocks
+	require.NoError(t, os.RemoveAll(path.Join(tempDir, "traces", testTenantID)))
+	changes, err = r.PollBlocklistNow(context.Background(), "")
+	require.NoError(t, err)
+	assert.Equal(t, blocklist.Changes{Removed: 1}, changes)
+
+	// the context of the request
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	rw.pollMtx.Lock()
+	_, err = r.PollBlocklistNow(ctx, testTenantID)
+	rw.pollMtx.Unlock()
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -68,7 +68,8 @@ func newAppendBlock(id uuid.UUID, tenantID string, filepath string, e backend.En
 }
 
 // newAppendBlockFromFile returns an AppendBlock that can not be appended to, but can
-// be completed. It can return a warning or a fatal error
+// be completed. It can return a warning or a fatal error. If a warning is returned the file
+// has been truncated after the last page that could be read.
 func newAppendBlockFromFile(filename string, path string) (*AppendBlock, error, error) {
 	var warning error
 	blockID, tenantID, version, e, dataEncoding, err := parseFilename(filename)
@@ -136,6 +137,14 @@ func newAppendBlockFromFile(filename string, path string) (*AppendBlock, error,
 		currentOffset += uint64(pageLen)
 	}
 
+	// drop everything after the last good page so the corrupt data is not replayed again
+	if warning != nil {
+		err = os.Truncate(b.fullFilename(), int64(currentOffset))
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
 	common.SortRecords(records)
 
 	b.appender = encoding.NewRecordAppender(records)
@@ -0,0 +1,117 @@
+package wal
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	deadLetterDir = "deadletter"
+
+	// DeadLetterReportName is the name of the error report written alongside a dead-lettered block.
+	DeadLetterReportName = "deadletter.json"
+)
+
+// DeadLetterReport describes why a block was moved to the dead-letter folder.
+type DeadLetterReport struct {
+	Error    string    `json:"error"`
+	Attempts uint      `json:"attempts"`
+	Time     time.Time `json:"time"`
+}
+
+// DeadLetterBlock moves a local block and its meta out of the blocks folder and into the dead-letter
+// folder along with an error report. Dead-lettered blocks are not rediscovered on startup.
+func (w *WAL) DeadLetterBlock(blockID uuid.UUID, tenantID string, report DeadLetterReport) error {
+	src := filepath.Join(w.c.Filepath, blocksDir, tenantID, blockID.String())
+	dst := filepath.Join(w.c.Filepath, deadLetterDir, tenantID, blockID.String())
+
+	err := os.MkdirAll(filepath.Dir(dst), os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(src, dst)
+	if err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(dst, DeadLetterReportName), b, 0644)
+}
+
+// RequeueDeadLetterBlocks moves dead-lettered blocks of the wal at walPath back into the blocks folder so
+// they are rediscovered and flushed on the next ingester start. If tenantID is empty blocks of all tenants
+// are moved. It returns the paths of the moved blocks relative to the blocks folder.
+func RequeueDeadLetterBlocks(walPath string, tenantID string) ([]string, error) {
+	root := filepath.Join(walPath, deadLetterDir)
+
+	tenants := []string{tenantID}
+	if tenantID == "" {
+		files, err := ioutil.ReadDir(root)
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		tenants = tenants[:0]
+		for _, f := range files {
+			if f.IsDir() {
+				tenants = append(tenants, f.Name())
+			}
+		}
+	}
+
+	var moved []string
+	for _, t := range tenants {
+		blocks, err := ioutil.ReadDir(filepath.Join(root, t))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return moved, err
+		}
+
+		for _, b := range blocks {
+			if !b.IsDir() {
+				continue
+			}
+
+			rel := filepath.Join(t, b.Name())
+			dst := filepath.Join(walPath, blocksDir, rel)
+			if _, err := os.Stat(dst); err == nil {
+				return moved, fmt.Errorf("block %s already exists in %s", rel, blocksDir)
+			}
+
+			err = os.MkdirAll(filepath.Dir(dst), os.ModePerm)
+			if err != nil {
+				return moved, err
+			}
+
+			err = os.Rename(filepath.Join(root, rel), dst)
+			if err != nil {
+				return moved, err
+			}
+
+			err = os.Remove(filepath.Join(dst, DeadLetterReportName))
+			if err != nil && !os.IsNotExist(err) {
+				return moved, err
+			}
+
+			moved = append(moved, rel)
+		}
+	}
+
+	return moved, nil
+}
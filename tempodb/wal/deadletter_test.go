@@ -0,0 +1,70 @@
+package wal
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeadLetterBlock(t *testing.T) {
+	tempDir, err := ioutil.TempDir("/tmp", "")
+	require.NoError(t, err, "unexpected error creating temp dir")
+	defer os.RemoveAll(tempDir)
+
+	wal, err := New(&Config{
+		Filepath: tempDir,
+	})
+	require.NoError(t, err, "unexpected error creating temp wal")
+
+	blockID := uuid.New()
+	blockPath := filepath.Join(tempDir, blocksDir, testTenantID, blockID.String())
+	require.NoError(t, os.MkdirAll(blockPath, os.ModePerm))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(blockPath, "meta.json"), []byte("{}"), 0644))
+
+	report := DeadLetterReport{
+		Error:    "object too large",
+		Attempts: 10,
+		Time:     time.Unix(100, 0).UTC(),
+	}
+	err = wal.DeadLetterBlock(blockID, testTenantID, report)
+	require.NoError(t, err)
+
+	// block and meta moved with an error report
+	_, err = os.Stat(blockPath)
+	assert.True(t, os.IsNotExist(err))
+
+	deadLetterPath := filepath.Join(tempDir, deadLetterDir, testTenantID, blockID.String())
+	assert.FileExists(t, filepath.Join(deadLetterPath, "meta.json"))
+
+	b, err := ioutil.ReadFile(filepath.Join(deadLetterPath, DeadLetterReportName))
+	require.NoError(t, err)
+	actual := DeadLetterReport{}
+	require.NoError(t, json.Unmarshal(b, &actual))
+	assert.Equal(t, report, actual)
+
+	// not replayed as a wal file
+	blocks, err := wal.RescanBlocks(1, nil)
+	require.NoError(t, err)
+	assert.Len(t, blocks, 0)
+
+	// other tenants are left alone
+	moved, err := RequeueDeadLetterBlocks(tempDir, "other")
+	require.NoError(t, err)
+	assert.Len(t, moved, 0)
+
+	moved, err = RequeueDeadLetterBlocks(tempDir, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(testTenantID, blockID.String())}, moved)
+
+	assert.FileExists(t, filepath.Join(blockPath, "meta.json"))
+	assert.NoFileExists(t, filepath.Join(blockPath, DeadLetterReportName))
+	_, err = os.Stat(deadLetterPath)
+	assert.True(t, os.IsNotExist(err))
+}
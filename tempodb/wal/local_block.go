@@ -13,7 +13,10 @@ import (
 	"github.com/pkg/errors"
 )
 
-const nameFlushed = "flushed"
+const (
+	nameFlushed      = "flushed"
+	nameFlushSkipped = "flush_skipped"
+)
 
 // LocalBlock is a block stored in a local storage.  It can be searched and flushed to a remote backend, and
 // permanently tracks the flushed time with a special file in the block
@@ -22,7 +25,8 @@ type LocalBlock struct {
 	reader backend.Reader
 	writer backend.Writer
 
-	flushedTime atomic.Int64 // protecting flushedTime b/c it's accessed from the store on flush and from the ingester instance checking flush time
+	flushedTime  atomic.Int64 // protecting flushedTime b/c it's accessed from the store on flush and from the ingester instance checking flush time
+	flushSkipped atomic.Bool
 }
 
 func NewLocalBlock(ctx context.Context, existingBlock *encoding.BackendBlock, l *local.Backend) (*LocalBlock, error) {
@@ -42,6 +46,11 @@ func NewLocalBlock(ctx context.Context, existingBlock *encoding.BackendBlock, l
 		}
 	}
 
+	_, err = c.reader.Read(ctx, nameFlushSkipped, c.BlockMeta().BlockID, c.BlockMeta().TenantID, false)
+	if err == nil {
+		c.flushSkipped.Store(true)
+	}
+
 	return c, nil
 }
 
@@ -75,6 +84,23 @@ func (c *LocalBlock) SetFlushed(ctx context.Context) error {
 	return nil
 }
 
+// SetFlushSkipped marks the block as flushed without writing it to a remote backend. The block is cleared like a
+// flushed block, but FlushSkipped returns true so it's not deleted in place of one that is in the backend.
+func (c *LocalBlock) SetFlushSkipped(ctx context.Context) error {
+	err := c.writer.Write(ctx, nameFlushSkipped, c.BlockMeta().BlockID, c.BlockMeta().TenantID, []byte{}, false)
+	if err != nil {
+		return errors.Wrap(err, "error writing ingester block flush skipped file")
+	}
+	c.flushSkipped.Store(true)
+
+	return c.SetFlushed(ctx)
+}
+
+// FlushSkipped returns true if the block was marked as flushed without being written to a remote backend.
+func (c *LocalBlock) FlushSkipped() bool {
+	return c.flushSkipped.Load()
+}
+
 func (c *LocalBlock) Write(ctx context.Context, w backend.Writer) error {
 	err := encoding.CopyBlock(ctx, c.BlockMeta(), c.reader, w)
 	if err != nil {
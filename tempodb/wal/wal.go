@@ -5,11 +5,17 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
 	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/atomic"
+
+	"github.com/grafana/tempo/pkg/boundedwaitgroup"
 	"github.com/grafana/tempo/tempodb/backend"
 	"github.com/grafana/tempo/tempodb/backend/local"
 )
@@ -19,6 +25,19 @@ const (
 	blocksDir    = "blocks"
 )
 
+var metricReplayCorruptBytesDiscarded = promauto.NewCounter(prometheus.CounterOpts{
+	Namespace: "tempodb",
+	Name:      "wal_replay_corrupt_bytes_discarded_total",
+	Help:      "Total number of bytes truncated from wal files during replay due to corruption.",
+})
+
+// File identifies a file created with NewFile.
+type File struct {
+	BlockID  uuid.UUID
+	TenantID string
+	Name     string
+}
+
 type WAL struct {
 	c *Config
 	l *local.Backend
@@ -76,53 +95,96 @@ func New(c *Config) (*WAL, error) {
 	}, nil
 }
 
-// RescanBlocks returns a slice of append blocks from the wal folder
-func (w *WAL) RescanBlocks(log log.Logger) ([]*AppendBlock, error) {
+// RescanBlocks returns a slice of append blocks from the wal folder. Up to concurrency files are
+// replayed at once. Corrupt files are truncated at the first page that can not be read.
+func (w *WAL) RescanBlocks(concurrency uint, log log.Logger) ([]*AppendBlock, error) {
 	files, err := ioutil.ReadDir(w.c.Filepath)
 	if err != nil {
 		return nil, err
 	}
 
-	blocks := make([]*AppendBlock, 0, len(files))
+	walFiles := make([]os.FileInfo, 0, len(files))
 	for _, f := range files {
 		if f.IsDir() {
 			continue
 		}
+		walFiles = append(walFiles, f)
+	}
 
-		start := time.Now()
-		level.Info(log).Log("msg", "beginning replay", "file", f.Name(), "size", f.Size())
-		b, warning, err := newAppendBlockFromFile(f.Name(), w.c.Filepath)
+	if concurrency == 0 {
+		concurrency = 1
+	}
 
-		remove := false
-		if err != nil {
-			// wal replay failed, clear and warn
-			level.Warn(log).Log("msg", "failed to replay block. removing.", "file", f.Name(), "err", err)
-			remove = true
-		}
+	// replay into a slice indexed by file so the returned blocks keep the order of the folder
+	var (
+		replayed = make([]*AppendBlock, len(walFiles))
+		errs     = make([]error, len(walFiles))
+		done     = atomic.NewInt32(0)
+		bg       = boundedwaitgroup.New(concurrency)
+	)
+	for i, f := range walFiles {
+		bg.Add(1)
+		go func(i int, f os.FileInfo) {
+			defer bg.Done()
 
-		if b != nil && b.appender.Length() == 0 {
-			level.Warn(log).Log("msg", "empty wal file. ignoring.", "file", f.Name(), "err", err)
-			remove = true
-		}
+			replayed[i], errs[i] = w.replayFile(f, log)
+			level.Info(log).Log("msg", "replay progress", "file", f.Name(), "completed", done.Inc(), "total", len(walFiles))
+		}(i, f)
+	}
+	bg.Wait()
 
-		if warning != nil {
-			level.Warn(log).Log("msg", "received warning while replaying block. partial replay likely.", "file", f.Name(), "warning", warning, "records", b.appender.Length())
+	blocks := make([]*AppendBlock, 0, len(walFiles))
+	for i := range walFiles {
+		if errs[i] != nil {
+			return nil, errs[i]
 		}
-
-		if remove {
-			err = os.Remove(filepath.Join(w.c.Filepath, f.Name()))
-			if err != nil {
-				return nil, err
-			}
-			continue
+		if replayed[i] != nil {
+			blocks = append(blocks, replayed[i])
 		}
+	}
+
+	return blocks, nil
+}
 
-		level.Info(log).Log("msg", "replay complete", "file", f.Name(), "duration", time.Since(start))
+// replayFile replays a single wal file. It returns a nil block if the file could not be replayed
+// and was removed.
+func (w *WAL) replayFile(f os.FileInfo, log log.Logger) (*AppendBlock, error) {
+	start := time.Now()
+	level.Info(log).Log("msg", "beginning replay", "file", f.Name(), "size", f.Size())
+	b, warning, err := newAppendBlockFromFile(f.Name(), w.c.Filepath)
 
-		blocks = append(blocks, b)
+	remove := false
+	if err != nil {
+		// wal replay failed, clear and warn
+		level.Warn(log).Log("msg", "failed to replay block. removing.", "file", f.Name(), "err", err)
+		remove = true
 	}
 
-	return blocks, nil
+	if b != nil && b.appender.Length() == 0 {
+		level.Warn(log).Log("msg", "empty wal file. ignoring.", "file", f.Name(), "err", err)
+		remove = true
+	}
+
+	if warning != nil {
+		var discarded int64
+		if info, err := os.Stat(b.fullFilename()); err == nil {
+			discarded = f.Size() - info.Size()
+		}
+		metricReplayCorruptBytesDiscarded.Add(float64(discarded))
+		level.Warn(log).Log("msg", "received warning while replaying block. truncated at corruption point.", "file", f.Name(), "warning", warning, "records", b.appender.Length(), "discardedBytes", discarded)
+	}
+
+	if remove {
+		err = os.Remove(filepath.Join(w.c.Filepath, f.Name()))
+		if err != nil {
+			return nil, err
+		}
+		return nil, nil
+	}
+
+	level.Info(log).Log("msg", "replay complete", "file", f.Name(), "duration", time.Since(start))
+
+	return b, nil
 }
 
 func (w *WAL) NewBlock(id uuid.UUID, tenantID string, dataEncoding string) (*AppendBlock, error) {
@@ -135,7 +197,56 @@ func (w *WAL) NewFile(blockid uuid.UUID, tenantid string, dir string, name strin
 	if err != nil {
 		return nil, err
 	}
-	return os.OpenFile(filepath.Join(p, fmt.Sprintf("%v:%v:%v", blockid, tenantid, name)), os.O_CREATE|os.O_RDWR, 0644)
+	return os.OpenFile(filepath.Join(p, fileName(blockid, tenantid, name)), os.O_CREATE|os.O_RDWR, 0644)
+}
+
+// RemoveFile removes a file created with NewFile. It is not an error if the file does not exist.
+func (w *WAL) RemoveFile(blockid uuid.UUID, tenantid string, dir string, name string) error {
+	err := os.Remove(filepath.Join(w.c.Filepath, dir, fileName(blockid, tenantid, name)))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func fileName(blockid uuid.UUID, tenantid string, name string) string {
+	return fmt.Sprintf("%v:%v:%v", blockid, tenantid, name)
+}
+
+// Files returns the files created with NewFile in the given folder. Files with other names are ignored.
+func (w *WAL) Files(dir string) ([]File, error) {
+	infos, err := ioutil.ReadDir(filepath.Join(w.c.Filepath, dir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]File, 0, len(infos))
+	for _, info := range infos {
+		if info.IsDir() {
+			continue
+		}
+
+		splits := strings.Split(info.Name(), ":")
+		if len(splits) != 3 {
+			continue
+		}
+
+		blockID, err := uuid.Parse(splits[0])
+		if err != nil {
+			continue
+		}
+
+		files = append(files, File{
+			BlockID:  blockID,
+			TenantID: splits[1],
+			Name:     splits[2],
+		})
+	}
+
+	return files, nil
 }
 
 func (w *WAL) ClearFolder(dir string) error {
@@ -146,3 +257,8 @@ func (w *WAL) ClearFolder(dir string) error {
 func (w *WAL) LocalBackend() *local.Backend {
 	return w.l
 }
+
+// BlocksFilepath returns the folder the complete blocks are stored in.
+func (w *WAL) BlocksFilepath() string {
+	return w.c.BlocksFilepath
+}
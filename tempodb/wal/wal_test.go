@@ -3,6 +3,7 @@ package wal
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"math/rand"
@@ -117,6 +118,31 @@ func TestCompletedDirIsRemoved(t *testing.T) {
 	assert.Error(t, err, "completedDir should not exist")
 }
 
+func TestFiles(t *testing.T) {
+	wal, err := New(&Config{
+		Filepath: t.TempDir(),
+	})
+	require.NoError(t, err)
+
+	// no folder
+	files, err := wal.Files("test")
+	require.NoError(t, err)
+	assert.Len(t, files, 0)
+
+	blockID := uuid.New()
+	f, err := wal.NewFile(blockID, testTenantID, "test", "data")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	// ignored
+	_, err = os.Create(filepath.Join(wal.c.Filepath, "test", "unknown"))
+	require.NoError(t, err)
+
+	files, err = wal.Files("test")
+	require.NoError(t, err)
+	assert.Equal(t, []File{{BlockID: blockID, TenantID: testTenantID, Name: "data"}}, files)
+}
+
 func TestErrorConditions(t *testing.T) {
 	tempDir, err := ioutil.TempDir("/tmp", "")
 	defer os.RemoveAll(tempDir)
@@ -160,7 +186,7 @@ func TestErrorConditions(t *testing.T) {
 	err = os.WriteFile(filepath.Join(tempDir, "fe0b83eb-a86b-4b6c-9a74-dc272cd5700e:blerg:v2:gzip"), []byte{}, 0644)
 	require.NoError(t, err)
 
-	blocks, err := wal.RescanBlocks(log.NewNopLogger())
+	blocks, err := wal.RescanBlocks(1, log.NewNopLogger())
 	require.NoError(t, err, "unexpected error getting blocks")
 	require.Len(t, blocks, 1)
 
@@ -174,7 +200,7 @@ func TestErrorConditions(t *testing.T) {
 func TestAppendReplayFind(t *testing.T) {
 	for _, e := range backend.SupportedEncoding {
 		t.Run(e.String(), func(t *testing.T) {
-			testAppendReplayFind(t, backend.EncZstd)
+			testAppendReplayFind(t, e)
 		})
 	}
 }
@@ -217,6 +243,10 @@ func testAppendReplayFind(t *testing.T, e backend.Encoding) {
 		assert.Equal(t, objs[i], obj)
 	}
 
+	info, err := os.Stat(block.fullFilename())
+	require.NoError(t, err)
+	goodSize := info.Size()
+
 	// write garbage data at the end to confirm a partial block will load
 	appendFile, err := os.OpenFile(block.fullFilename(), os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
 	require.NoError(t, err)
@@ -225,10 +255,15 @@ func testAppendReplayFind(t *testing.T, e backend.Encoding) {
 	err = appendFile.Close()
 	require.NoError(t, err)
 
-	blocks, err := wal.RescanBlocks(log.NewNopLogger())
+	blocks, err := wal.RescanBlocks(1, log.NewNopLogger())
 	require.NoError(t, err, "unexpected error getting blocks")
 	require.Len(t, blocks, 1)
 
+	// confirm the garbage was truncated
+	info, err = os.Stat(block.fullFilename())
+	require.NoError(t, err)
+	assert.Equal(t, goodSize, info.Size())
+
 	iterator, err := blocks[0].GetIterator(&mockCombiner{})
 	require.NoError(t, err)
 	defer iterator.Close()
@@ -270,6 +305,101 @@ func testAppendReplayFind(t *testing.T, e backend.Encoding) {
 	require.NoError(t, err)
 }
 
+func TestRescanBlocksConcurrency(t *testing.T) {
+	tempDir, err := ioutil.TempDir("/tmp", "")
+	defer os.RemoveAll(tempDir)
+	require.NoError(t, err, "unexpected error creating temp dir")
+
+	wal, err := New(&Config{
+		Filepath: tempDir,
+		Encoding: backend.EncSnappy,
+	})
+	require.NoError(t, err, "unexpected error creating temp wal")
+
+	// several blocks for several tenants
+	expected := map[uuid.UUID]int{}
+	for i := 0; i < 12; i++ {
+		block, err := wal.NewBlock(uuid.New(), fmt.Sprintf("tenant-%d", i%3), "")
+		require.NoError(t, err)
+
+		objects := i + 1
+		for j := 0; j < objects; j++ {
+			id := make([]byte, 16)
+			rand.Read(id)
+			bObj, err := proto.Marshal(test.MakeRequest(1, id))
+			require.NoError(t, err)
+			require.NoError(t, block.Write(id, bObj))
+		}
+		expected[block.BlockID()] = objects
+	}
+
+	blocks, err := wal.RescanBlocks(4, log.NewNopLogger())
+	require.NoError(t, err)
+	require.Len(t, blocks, len(expected))
+
+	// blocks are returned in folder order
+	for i := 1; i < len(blocks); i++ {
+		assert.Less(t, filepath.Base(blocks[i-1].fullFilename()), filepath.Base(blocks[i].fullFilename()))
+	}
+	for _, b := range blocks {
+		assert.Equal(t, expected[b.BlockID()], b.appender.Length())
+	}
+}
+
+func TestReplayMixedEncodings(t *testing.T) {
+	tempDir, err := ioutil.TempDir("/tmp", "")
+	defer os.RemoveAll(tempDir)
+	require.NoError(t, err, "unexpected error creating temp dir")
+
+	// write a block with every encoding as if the configured encoding changed between restarts
+	expected := map[uuid.UUID]map[string][]byte{}
+	for _, e := range backend.SupportedEncoding {
+		wal, err := New(&Config{
+			Filepath: tempDir,
+			Encoding: e,
+		})
+		require.NoError(t, err)
+
+		block, err := wal.NewBlock(uuid.New(), testTenantID, "")
+		require.NoError(t, err)
+
+		objs := map[string][]byte{}
+		for i := 0; i < 10; i++ {
+			id := make([]byte, 16)
+			rand.Read(id)
+			obj, err := proto.Marshal(test.MakeRequest(rand.Int()%10+1, id))
+			require.NoError(t, err)
+			require.NoError(t, block.Write(id, obj))
+			objs[string(id)] = obj
+		}
+		expected[block.BlockID()] = objs
+	}
+
+	wal, err := New(&Config{
+		Filepath: tempDir,
+		Encoding: backend.EncNone,
+	})
+	require.NoError(t, err)
+
+	blocks, err := wal.RescanBlocks(1, log.NewNopLogger())
+	require.NoError(t, err)
+	require.Len(t, blocks, len(backend.SupportedEncoding))
+
+	encodings := map[backend.Encoding]struct{}{}
+	for _, b := range blocks {
+		encodings[b.Meta().Encoding] = struct{}{}
+
+		objs, ok := expected[b.BlockID()]
+		require.True(t, ok)
+		for id, obj := range objs {
+			actual, err := b.Find([]byte(id), &mockCombiner{})
+			require.NoError(t, err)
+			assert.Equal(t, obj, actual)
+		}
+	}
+	assert.Len(t, encodings, len(backend.SupportedEncoding))
+}
+
 func BenchmarkWALNone(b *testing.B) {
 	benchmarkWriteFindReplay(b, backend.EncNone)
 }
@@ -286,6 +416,56 @@ func BenchmarkWALZSTD(b *testing.B) {
 	benchmarkWriteFindReplay(b, backend.EncZstd)
 }
 
+func BenchmarkWALReplayNone(b *testing.B) {
+	benchmarkReplay(b, backend.EncNone)
+}
+func BenchmarkWALReplaySnappy(b *testing.B) {
+	benchmarkReplay(b, backend.EncSnappy)
+}
+func BenchmarkWALReplayZSTD(b *testing.B) {
+	benchmarkReplay(b, backend.EncZstd)
+}
+
+// benchmarkReplay measures replaying a single wal file. The size of the file relative to the objects appended to
+// it is reported as write-amp.
+func benchmarkReplay(b *testing.B, encoding backend.Encoding) {
+	tempDir, err := ioutil.TempDir("/tmp", "")
+	defer os.RemoveAll(tempDir)
+	require.NoError(b, err)
+
+	wal, err := New(&Config{
+		Filepath: tempDir,
+		Encoding: encoding,
+	})
+	require.NoError(b, err)
+
+	block, err := wal.NewBlock(uuid.New(), testTenantID, "")
+	require.NoError(b, err)
+
+	written := 0
+	for i := 0; i < 1000; i++ {
+		id := make([]byte, 16)
+		rand.Read(id)
+		obj, err := proto.Marshal(test.MakeRequest(rand.Int()%1000, id))
+		require.NoError(b, err)
+		require.NoError(b, block.Write(id, obj))
+		written += len(obj)
+	}
+
+	info, err := os.Stat(block.fullFilename())
+	require.NoError(b, err)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		blocks, err := wal.RescanBlocks(1, log.NewNopLogger())
+		require.NoError(b, err)
+		require.Len(b, blocks, 1)
+		_ = blocks[0].readFile.Close()
+	}
+
+	b.ReportMetric(float64(info.Size())/float64(written), "write-amp")
+}
+
 func benchmarkWriteFindReplay(b *testing.B, encoding backend.Encoding) {
 	objects := 1000
 	objs := make([][]byte, 0, objects)
@@ -326,7 +506,7 @@ func benchmarkWriteFindReplay(b *testing.B, encoding backend.Encoding) {
 		}
 
 		// replay
-		_, err = wal.RescanBlocks(log.NewNopLogger())
+		_, err = wal.RescanBlocks(1, log.NewNopLogger())
 		require.NoError(b, err)
 
 		os.RemoveAll(tempDir)